@@ -0,0 +1,144 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/consts"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/dbusservice"
+	"github.com/godbus/dbus/v5"
+	"github.com/spf13/cobra"
+)
+
+// installControl adds the commands which manage the running broker.
+func (a *App) installControl() {
+	tokenCmd := &cobra.Command{
+		Use:   "token",
+		Short: "Manages the tokens cached by the broker",
+		Args:  cobra.NoArgs,
+	}
+	tokenCmd.AddCommand(&cobra.Command{
+		Use:   "status USER",
+		Short: "Shows the status of the token cached for a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var encoded string
+			if err := a.callBroker("TokenStatus", &encoded, args[0]); err != nil {
+				return err
+			}
+			var status broker.TokenStatus
+			if err := json.Unmarshal([]byte(encoded), &status); err != nil {
+				return fmt.Errorf("could not parse token status: %v", err)
+			}
+			return printTokenStatus(cmd.OutOrStdout(), status)
+		},
+	})
+	a.rootCmd.AddCommand(tokenCmd)
+
+	a.rootCmd.AddCommand(&cobra.Command{
+		Use:   "logout USER",
+		Short: "Removes the token and the local password cached for a user",
+		Long: "Removes the token and the local password cached for a user, " +
+			"so that they have to authenticate with the identity provider on their next login.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return a.callBroker("Logout", nil, args[0])
+		},
+	})
+
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manages the cache of the broker",
+		Args:  cobra.NoArgs,
+	}
+	cacheCmd.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "Clears the metadata and the signing keys of the identity provider cached by the broker",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return a.callBroker("ClearCache", nil)
+		},
+	})
+	a.rootCmd.AddCommand(cacheCmd)
+
+	a.rootCmd.AddCommand(&cobra.Command{
+		Use:   "whoami",
+		Short: "Shows the identity provider and the client used by the broker",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var encoded string
+			if err := a.callBroker("WhoAmI", &encoded); err != nil {
+				return err
+			}
+			var identity broker.Identity
+			if err := json.Unmarshal([]byte(encoded), &identity); err != nil {
+				return fmt.Errorf("could not parse broker identity: %v", err)
+			}
+			return printIdentity(cmd.OutOrStdout(), identity)
+		},
+	})
+}
+
+// callBroker calls the method of the control interface of the running broker and stores its result in ret, if any.
+func (a *App) callBroker(method string, ret any, args ...any) error {
+	a.readyWithoutDaemon()
+
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return fmt.Errorf("could not connect to the system bus: %v", err)
+	}
+	defer conn.Close()
+
+	call := conn.Object(consts.DbusName, consts.DbusObject).Call(dbusservice.ControlInterface+"."+method, 0, args...)
+	if ret == nil {
+		return call.Err
+	}
+	return call.Store(ret)
+}
+
+func printTokenStatus(out io.Writer, s broker.TokenStatus) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	expiry := s.Expiry.Local().Format(time.RFC1123)
+	if s.Expiry.Before(time.Now()) {
+		expiry += " (expired)"
+	}
+
+	fmt.Fprintf(w, "User:\t%s\n", s.Username)
+	if s.ProviderID != "" {
+		fmt.Fprintf(w, "Provider ID:\t%s\n", s.ProviderID)
+	}
+	fmt.Fprintf(w, "Expiry:\t%s\n", expiry)
+	fmt.Fprintf(w, "Refresh token:\t%s\n", yesNo(s.HasRefreshToken))
+	fmt.Fprintf(w, "Local password:\t%s\n", yesNo(s.HasLocalPassword))
+	fmt.Fprintf(w, "Device registered:\t%s\n", yesNo(s.DeviceRegistered))
+	fmt.Fprintf(w, "User disabled:\t%s\n", yesNo(s.UserIsDisabled))
+	fmt.Fprintf(w, "Device disabled:\t%s\n", yesNo(s.DeviceIsDisabled))
+
+	return w.Flush()
+}
+
+func printIdentity(out io.Writer, i broker.Identity) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Provider:\t%s\n", i.Provider)
+	fmt.Fprintf(w, "Version:\t%s\n", i.Version)
+	fmt.Fprintf(w, "Issuer:\t%s\n", i.Issuer)
+	fmt.Fprintf(w, "Client ID:\t%s\n", i.ClientID)
+	if i.Owner != "" {
+		fmt.Fprintf(w, "Owner:\t%s\n", i.Owner)
+	}
+
+	return w.Flush()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -25,6 +25,8 @@ type App struct {
 
 	daemon *daemon.Daemon
 	name   string
+	// cancelSetup stops the setup command, if it's running.
+	cancelSetup context.CancelFunc
 
 	ready chan struct{}
 }
@@ -110,6 +112,9 @@ func New(name string) *App {
 
 	// subcommands
 	a.installVersion()
+	a.installRefreshToken()
+	a.installSetup()
+	a.installControl()
 
 	return &a
 }
@@ -164,8 +169,9 @@ func (a *App) serve(config daemonConfig) error {
 	}
 
 	brokerConfig := broker.Config{
-		ConfigFile: config.Paths.BrokerConf,
-		DataDir:    config.Paths.DataDir,
+		ConfigFile:  config.Paths.BrokerConf,
+		DataDir:     config.Paths.DataDir,
+		MessagesDir: broker.GetMessagesDir(config.Paths.BrokerConf),
 	}
 
 	s, err := dbusservice.New(ctx, brokerConfig)
@@ -218,12 +224,21 @@ func (a App) Hup() (shouldQuit bool) {
 // Quit gracefully shutdown the service.
 func (a *App) Quit() {
 	a.WaitReady()
+	if a.cancelSetup != nil {
+		a.cancelSetup()
+	}
 	if a.daemon == nil {
 		return
 	}
 	a.daemon.Quit()
 }
 
+// readyWithoutDaemon marks the App as ready for the commands which don't start the daemon, so that Quit doesn't wait
+// for it forever.
+func (a *App) readyWithoutDaemon() {
+	close(a.ready)
+}
+
 // WaitReady signals when the daemon is ready
 // Note: we need to use a pointer to not copy the App object before the daemon is ready, and thus, creates a data race.
 func (a *App) WaitReady() {
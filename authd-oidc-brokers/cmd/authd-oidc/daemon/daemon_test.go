@@ -54,6 +54,58 @@ func TestVersion(t *testing.T) {
 	require.Equal(t, consts.Version, fields[1], "Wrong version")
 }
 
+func TestSetup(t *testing.T) {
+	brokerConf := filepath.Join(t.TempDir(), "broker.conf")
+	a := daemon.NewForTests(t, &daemon.DaemonConfig{Paths: daemon.SystemPaths{BrokerConf: brokerConf}}, issuerURL,
+		"setup", "--skip-test-login")
+
+	// The test configuration already exists, so it has to be overwritten.
+	input := strings.Join([]string{issuerURL, "new-client-id", "", "", "y"}, "\n") + "\n"
+	var out bytes.Buffer
+	a.SetInput(strings.NewReader(input), &out)
+
+	err := a.Run()
+	require.NoError(t, err, "Run should not return an error. Output: %v", out.String())
+
+	got, err := os.ReadFile(brokerConf)
+	require.NoError(t, err, "Broker configuration should have been written")
+	require.Contains(t, string(got), "client_id = new-client-id", "Broker configuration should contain the new client ID")
+}
+
+func TestControlCommands(t *testing.T) {
+	a, wait := startDaemon(t, nil)
+	defer wait()
+	defer a.Quit()
+
+	tests := map[string]struct {
+		args []string
+
+		wantOutput string
+		wantErr    bool
+	}{
+		"Whoami_shows_the_issuer": {args: []string{"whoami"}, wantOutput: "Issuer:     " + issuerURL},
+		"Cache_clear_succeeds":    {args: []string{"cache", "clear"}},
+
+		"Error_for_token_status_of_unknown_user": {args: []string{"token", "status", "unknown@example.com"}, wantErr: true},
+		"Error_for_logout_of_unknown_user":       {args: []string{"logout", "unknown@example.com"}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := daemon.NewForTests(t, nil, issuerURL, tc.args...)
+			var out bytes.Buffer
+			c.SetInput(strings.NewReader(""), &out)
+
+			err := c.Run()
+			if tc.wantErr {
+				require.Error(t, err, "Run should return an error")
+				return
+			}
+			require.NoError(t, err, "Run should not return an error")
+			require.Contains(t, out.String(), tc.wantOutput, "Output should contain the expected text")
+		})
+	}
+}
+
 func TestNoUsageError(t *testing.T) {
 	a := daemon.NewForTests(t, nil, issuerURL, "completion", "bash")
 
@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -93,3 +94,9 @@ func (a App) Config() DaemonConfig {
 func (a *App) SetArgs(args ...string) {
 	a.rootCmd.SetArgs(args)
 }
+
+// SetInput sets the input and output of the interactive commands for tests.
+func (a *App) SetInput(in io.Reader, out io.Writer) {
+	a.rootCmd.SetIn(in)
+	a.rootCmd.SetOut(out)
+}
@@ -0,0 +1,22 @@
+package daemon
+
+import (
+	"os"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/tokenrefresh"
+	"github.com/spf13/cobra"
+)
+
+// installRefreshToken adds the hidden command run by the broker to refresh tokens in a sandboxed child process.
+func (a *App) installRefreshToken() {
+	cmd := &cobra.Command{
+		Use:    tokenrefresh.Command,
+		Short:  "Refreshes the token read from stdin in a sandbox and exits",
+		Args:   cobra.NoArgs,
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return tokenrefresh.Serve(cmd.Context(), os.Stdin, os.Stdout)
+		},
+	}
+	a.rootCmd.AddCommand(cmd)
+}
@@ -0,0 +1,35 @@
+package daemon
+
+import (
+	"context"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/setup"
+	"github.com/spf13/cobra"
+)
+
+// installSetup adds the command which walks the administrator through the configuration of the broker.
+func (a *App) installSetup() {
+	var skipTestLogin bool
+
+	cmd := &cobra.Command{
+		Use:   "setup",
+		Short: "Interactively configures the broker",
+		Long: "Asks for the issuer URL, the client ID and the scopes, checks them against the identity provider " +
+			"with a test login and writes the broker configuration file.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithCancel(cmd.Context())
+			defer cancel()
+			a.cancelSetup = cancel
+			a.readyWithoutDaemon()
+
+			var opts []setup.Option
+			if skipTestLogin {
+				opts = append(opts, setup.WithoutTestLogin())
+			}
+			return setup.Run(ctx, a.config.Paths.BrokerConf, cmd.InOrStdin(), cmd.OutOrStdout(), opts...)
+		},
+	}
+	cmd.Flags().BoolVar(&skipTestLogin, "skip-test-login", false, "don't log in with the identity provider to test the configuration")
+	a.rootCmd.AddCommand(cmd)
+}
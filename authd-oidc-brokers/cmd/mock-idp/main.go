@@ -0,0 +1,72 @@
+// Package main runs a mock OpenID Connect identity provider, to rehearse the authentication flows of the broker
+// without a tenant of a real provider.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/mockidp"
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:8080", "address on which the provider listens")
+	issuer := flag.String("issuer", "", "issuer URL of the provider (default: the URL at which it's reached)")
+	usersFile := flag.String("users", "", "YAML file listing the users of the provider (default: a single user1@example.com user)")
+	flag.Parse()
+
+	if err := run(*listen, *issuer, *usersFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(listen, issuer, usersFile string) error {
+	var opts []mockidp.Option
+	if issuer != "" {
+		opts = append(opts, mockidp.WithIssuer(issuer))
+	}
+	if usersFile != "" {
+		data, err := os.ReadFile(usersFile)
+		if err != nil {
+			return err
+		}
+		var users []mockidp.User
+		if err := yaml.Unmarshal(data, &users); err != nil {
+			return fmt.Errorf("could not parse %s: %w", usersFile, err)
+		}
+		opts = append(opts, mockidp.WithUsers(users))
+	}
+
+	idp, err := mockidp.New(opts...)
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: idp}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+
+	fmt.Fprintf(os.Stderr, "Mock identity provider listening on http://%s\n", l.Addr())
+	if err := server.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -13,6 +13,10 @@ const (
 	// The ID value remains "device_auth_qr" for compatibility.
 	DeviceQr = "device_auth_qr"
 
+	// Browser is the ID of the authorization code flow, in which the user logs in with a web browser running on the
+	// same machine which is redirected to the broker.
+	Browser = "browser"
+
 	// NewPassword is the ID of the new password configuration method.
 	NewPassword = "newpassword"
 
@@ -24,6 +28,10 @@ const (
 
 	// EntraMFACode is the ID of the code-entry MFA follow-up mode.
 	EntraMFACode = "entra_mfa_code"
+
+	// EntraMFAFIDO is the ID of the MFA follow-up mode in which the user signs a WebAuthn challenge with a security key
+	// attached to the machine.
+	EntraMFAFIDO = "entra_mfa_fido"
 )
 
 var (
@@ -33,9 +41,11 @@ var (
 		Password:      "Local password",
 		Device:        "Device code flow",
 		DeviceQr:      "Device code flow",
+		Browser:       "Web browser login",
 		NewPassword:   "Define your local password",
 		EntraPassword: "Entra ID password + MFA",
 		EntraMFAWait:  "Waiting for MFA approval",
 		EntraMFACode:  "Enter your MFA code",
+		EntraMFAFIDO:  "Use your security key",
 	}
 )
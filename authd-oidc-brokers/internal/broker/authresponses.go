@@ -16,6 +16,10 @@ type isAuthenticatedDataResponse interface {
 type userInfoMessage struct {
 	UserInfo info.User `json:"userinfo"`
 	Message  string    `json:"message,omitempty"`
+	// Offline is set when the user was authenticated without contacting the provider.
+	Offline bool `json:"offline,omitempty"`
+	// args are formatted into Message once it's translated in the language of the session.
+	args []any
 }
 
 func (userInfoMessage) isAuthenticatedDataResponse() {}
@@ -23,6 +27,34 @@ func (userInfoMessage) isAuthenticatedDataResponse() {}
 // errorMessage represents the error message that is returned to authd.
 type errorMessage struct {
 	Message string `json:"message"`
+	// PasswordReset tells the PAM client that the next steps re-authenticate the user with the provider and reset their
+	// local password, so that it can present them as a single flow.
+	PasswordReset bool `json:"password_reset,omitempty"`
+	// args are formatted into Message once it's translated in the language of the session.
+	args []any
 }
 
 func (errorMessage) isAuthenticatedDataResponse() {}
+
+// localizedResponse returns r with its message translated in lang.
+func (b *Broker) localizedResponse(lang string, r isAuthenticatedDataResponse) isAuthenticatedDataResponse {
+	switch r := r.(type) {
+	case errorMessage:
+		if len(r.args) > 0 {
+			r.Message = b.messages.Getf(lang, r.Message, r.args...)
+		} else {
+			r.Message = b.messages.Get(lang, r.Message)
+		}
+		r.args = nil
+		return r
+	case userInfoMessage:
+		if len(r.args) > 0 {
+			r.Message = b.messages.Getf(lang, r.Message, r.args...)
+		} else if r.Message != "" {
+			r.Message = b.messages.Get(lang, r.Message)
+		}
+		r.args = nil
+		return r
+	}
+	return r
+}
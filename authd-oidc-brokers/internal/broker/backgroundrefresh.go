@@ -0,0 +1,127 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
+	"github.com/canonical/authd/log"
+)
+
+// backgroundRefresh is the result of the last background refresh of the token of a user.
+type backgroundRefresh struct {
+	time time.Time
+	err  error
+}
+
+// RefreshTokensPeriodically refreshes the tokens cached for the users at the interval set in the configuration, so
+// that their refresh tokens don't expire when they don't log in for a while. It returns when ctx is done, or
+// immediately if the background refresh is disabled.
+func (b *Broker) RefreshTokensPeriodically(ctx context.Context) {
+	if b.cfg.tokenRefreshInterval == 0 {
+		return
+	}
+
+	for {
+		wait := b.cfg.tokenRefreshInterval
+		if b.cfg.tokenRefreshJitter > 0 {
+			wait += rand.N(b.cfg.tokenRefreshJitter)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		b.RefreshCachedTokens(ctx)
+	}
+}
+
+// RefreshCachedTokens refreshes the tokens cached for the users which have a refresh token. Failures are logged and
+// reported in the status of the token of the user.
+func (b *Broker) RefreshCachedTokens(ctx context.Context) {
+	if b.machineIsOffline() {
+		log.Debugf(ctx, "Skipping the background refresh of the tokens because the machine is offline")
+		return
+	}
+
+	for _, iss := range b.issuers() {
+		b.refreshCachedTokensOfIssuer(ctx, iss)
+	}
+}
+
+// refreshCachedTokensOfIssuer refreshes the tokens cached for the users of the issuer.
+func (b *Broker) refreshCachedTokensOfIssuer(ctx context.Context, iss *issuer) {
+	dir, err := b.issuerDataDir(iss)
+	if err != nil {
+		log.Warningf(ctx, "Could not refresh the cached tokens: %v", err)
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Warningf(ctx, "Could not list the cached tokens: %v", err)
+		return
+	}
+
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		// The compatibility symlinks of the migrated users point to directories which are listed too.
+		if !e.IsDir() {
+			continue
+		}
+		b.refreshCachedToken(ctx, iss, filepath.Join(dir, e.Name()))
+	}
+}
+
+// refreshCachedToken refreshes the token cached in the user data directory dir of the issuer, if it can be.
+func (b *Broker) refreshCachedToken(ctx context.Context, iss *issuer, dir string) {
+	s := session{issuer: iss}
+	setCachePaths(&s, dir)
+	if !tokenExists(s) {
+		return
+	}
+
+	b.sessionTokensMu.Lock()
+	defer b.sessionTokensMu.Unlock()
+
+	authInfo, err := token.LoadAuthInfo(s.tokenPath, token.WithStorage(b.storage))
+	if err != nil {
+		log.Warningf(ctx, "Could not load the token cached in %q: %v", dir, err)
+		return
+	}
+	if authInfo.Token == nil || authInfo.Token.RefreshToken == "" || authInfo.UserIsDisabled {
+		return
+	}
+	if authInfo.UserInfo.Name == "" {
+		return
+	}
+	s.username = b.provider.NormalizeUsername(authInfo.UserInfo.Name)
+
+	_, err = b.refreshSessionToken(ctx, &s, authInfo)
+	if err != nil {
+		log.Warningf(ctx, "Background refresh of the token of user %q failed: %v", s.username, err)
+	} else {
+		log.Debugf(ctx, "Refreshed the token of user %q in the background", s.username)
+	}
+
+	b.backgroundRefreshesMu.Lock()
+	defer b.backgroundRefreshesMu.Unlock()
+	b.backgroundRefreshes[s.username] = backgroundRefresh{time: time.Now(), err: err}
+}
+
+// lastBackgroundRefresh returns the result of the last background refresh of the token of the user, if any.
+func (b *Broker) lastBackgroundRefresh(username string) (backgroundRefresh, bool) {
+	b.backgroundRefreshesMu.Lock()
+	defer b.backgroundRefreshesMu.Unlock()
+
+	r, ok := b.backgroundRefreshes[username]
+	return r, ok
+}
@@ -0,0 +1,123 @@
+package broker_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshCachedTokens(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		token          tokenOptions
+		machineOffline bool
+
+		wantRefresh bool
+		wantErr     bool
+	}{
+		"Refresh_the_cached_token":                   {wantRefresh: true},
+		"Refresh_the_cached_token_if_it_expired":     {token: tokenOptions{expired: true}, wantRefresh: true},
+		"Skip_the_token_without_refresh_token":       {token: tokenOptions{noRefreshToken: true}},
+		"Skip_the_token_of_a_disabled_user":          {token: tokenOptions{userIsDisabled: true}},
+		"Skip_the_refresh_if_the_machine_is_offline": {machineOffline: true},
+
+		"Error_if_the_refresh_token_expired_at_provider": {token: tokenOptions{refreshTokenExpired: true}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			connectivity := &connectivityMock{}
+			connectivity.offline.Store(tc.machineOffline)
+			b := newBrokerForTests(t, &brokerForTestConfig{connectivity: connectivity})
+
+			// The name of the user is the one in the tokens returned by the mock provider.
+			const username = "test-user@email.com"
+			tokenPath := filepath.Join(setupUserCache(t, b, username, tc.token, false, false), "token.json")
+			before, err := token.LoadAuthInfo(tokenPath)
+			require.NoError(t, err, "Setup: could not load the cached token")
+
+			b.RefreshCachedTokens(context.Background())
+
+			status, err := b.TokenStatus(username)
+			require.NoError(t, err, "TokenStatus should not have returned an error")
+			after, err := token.LoadAuthInfo(tokenPath)
+			require.NoError(t, err, "Setup: could not load the cached token")
+
+			if tc.wantErr {
+				require.NotZero(t, status.LastBackgroundRefresh, "TokenStatus should report the failed background refresh")
+				require.NotEmpty(t, status.BackgroundRefreshError, "TokenStatus should report the error of the background refresh")
+				require.Equal(t, before.Token.Expiry.Unix(), after.Token.Expiry.Unix(), "The cached token should not have changed")
+				return
+			}
+			require.Empty(t, status.BackgroundRefreshError, "TokenStatus should not report any background refresh error")
+			if !tc.wantRefresh {
+				require.Zero(t, status.LastBackgroundRefresh, "The token should not have been refreshed")
+				require.Equal(t, before.Token.Expiry.Unix(), after.Token.Expiry.Unix(), "The cached token should not have changed")
+				return
+			}
+			require.NotZero(t, status.LastBackgroundRefresh, "TokenStatus should report the background refresh")
+			require.NotEqual(t, before.Token.Expiry.Unix(), after.Token.Expiry.Unix(), "The refreshed token should have been cached")
+			require.Equal(t, before.UserInfo.Groups, after.UserInfo.Groups, "The groups of the user should have been kept")
+		})
+	}
+}
+
+func TestRefreshTokensPeriodically(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		interval time.Duration
+
+		wantRefresh bool
+	}{
+		"Refresh_the_tokens_at_the_interval":    {interval: 10 * time.Millisecond, wantRefresh: true},
+		"Do_nothing_if_the_refresh_is_disabled": {},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newBrokerForTests(t, &brokerForTestConfig{tokenRefreshInterval: tc.interval})
+			const username = "test-user@email.com"
+			setupUserCache(t, b, username, tokenOptions{}, false, false)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				b.RefreshTokensPeriodically(ctx)
+			}()
+
+			if !tc.wantRefresh {
+				select {
+				case <-done:
+				case <-time.After(5 * time.Second):
+					t.Fatal("RefreshTokensPeriodically should have returned immediately")
+				}
+				cancel()
+				status, err := b.TokenStatus(username)
+				require.NoError(t, err, "TokenStatus should not have returned an error")
+				require.Zero(t, status.LastBackgroundRefresh, "The token should not have been refreshed")
+				return
+			}
+
+			require.Eventually(t, func() bool {
+				status, err := b.TokenStatus(username)
+				return err == nil && !status.LastBackgroundRefresh.IsZero()
+			}, 5*time.Second, 10*time.Millisecond, "The token should have been refreshed in the background")
+
+			cancel()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("RefreshTokensPeriodically should have returned once the context is done")
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package broker
 
 import (
 	"context"
+	"crypto/fips140"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -11,9 +12,11 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -22,15 +25,21 @@ import (
 	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/sessionmode"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/consts"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/fileutils"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/l10n"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/password"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
 	providerErrors "github.com/canonical/authd/authd-oidc-brokers/internal/providers/errors"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/genericprovider"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/msentraid/himmelblau"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/secretstorage"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/tokenrefresh"
+	"github.com/canonical/authd/authd-oidc-brokers/po"
 	"github.com/canonical/authd/log"
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
 	"golang.org/x/oauth2"
 )
 
@@ -52,10 +61,15 @@ const (
 // (e.g. after token revocation, expiry, or password change).
 var reauthModes = []string{authmodes.EntraPassword, authmodes.Device, authmodes.DeviceQr}
 
+// errMachineOffline is the connection error of the sessions started while the machine was offline.
+var errMachineOffline = errors.New("the machine is not connected to any network")
+
 // Config is the configuration for the broker.
 type Config struct {
 	ConfigFile string
 	DataDir    string
+	// MessagesDir contains the po files with which the administrator overrides the messages displayed to the users.
+	MessagesDir string
 
 	userConfig
 }
@@ -65,14 +79,37 @@ type Broker struct {
 	cfg        Config
 	apiVersion uint
 
-	provider         providers.Provider
-	oidcCfg          oidc.Config
-	oidcClientSecret string
+	provider providers.Provider
+	// issuer is the issuer of the [oidc] section, which authenticates the users whose usernames don't match the
+	// domains of any of additionalIssuers.
+	issuer            *issuer
+	additionalIssuers []*issuer
 
 	currentSessions   map[string]session
 	currentSessionsMu sync.RWMutex
 
 	privateKey *rsa.PrivateKey
+
+	messages *l10n.Catalog
+
+	// connectivity is nil if the state of the network is not monitored, in which case the machine is assumed to be
+	// online.
+	connectivity connectivityMonitor
+	// httpClient sends the requests to the provider and detects the captive portals answering them.
+	httpClient *http.Client
+	// retry is how the requests to the provider failing with a transient error are retried.
+	retry retryPolicy
+	// storage seals the tokens and the password hashes cached for the users. It's nil if they are stored in
+	// plaintext.
+	storage *secretstorage.Storage
+
+	// sessionTokensMu serializes the refreshes of the session tokens, so that concurrent requests of the applications
+	// of a session don't rotate the refresh token of the user more than once.
+	sessionTokensMu sync.Mutex
+
+	// backgroundRefreshes are the results of the last background refreshes of the tokens, by username.
+	backgroundRefreshes   map[string]backgroundRefresh
+	backgroundRefreshesMu sync.Mutex
 }
 
 type session struct {
@@ -80,26 +117,38 @@ type session struct {
 	providerID string // stable provider identifier; empty until learned via auth or cache migration
 	lang       string
 	mode       string
+	// timezone is the time zone of the user, if authd passed it in the session hints.
+	timezone *time.Location
 
 	selectedMode    string
 	authModes       []string
 	attemptsPerMode map[string]int
 	nextAuthModes   []string
 
+	// issuer is the issuer the user authenticates with, chosen from the domain of their username.
+	issuer                  *issuer
 	oidcServer              *oidc.Provider
 	oauth2Config            oauth2.Config
 	isOffline               bool
 	providerConnectionError error
 	userDataDir             string
 	passwordPath            string
+	passwordHistoryPath     string
 	tokenPath               string
 
 	// Data to pass from one request to another.
 	deviceAuthResponse *oauth2.DeviceAuthResponse
+	browserLogin       *browserLogin
 	authInfo           *token.AuthCachedInfo
 	mfaFlowActive      *himmelblau.MFAFlowState
 	mfaChallengeInfo   *himmelblau.MFAChallengeInfo
 	entraPasswordHash  string // pre-computed hash (not plaintext) for offline use
+	// passwordExpired is true if the user logged in with a local password older than the max_age of the password
+	// policy, and has to choose a new one.
+	passwordExpired bool
+	// providerPasswordExpired is true if the provider rejected the login because the password of the user expired, so
+	// that they have to change it with the provider and then choose a new local password.
+	providerPasswordExpired bool
 
 	isAuthenticating *isAuthenticatedCtx
 }
@@ -130,6 +179,8 @@ func (b *Broker) verifyAndExtractEntraUserInfo(ctx context.Context, token *oauth
 	if !filepath.IsAbs(userInfo.Home) {
 		userInfo.Home = filepath.Join(b.cfg.homeBaseDir, userInfo.Home)
 	}
+	userInfo.Env = b.sessionEnv(ctx, accessTokenClaims(token.AccessToken))
+	userInfo.SSHKeys = b.sshKeys(ctx, accessTokenClaims(token.AccessToken))
 
 	return userInfo, nil
 }
@@ -166,6 +217,7 @@ func (b *Broker) populateAuthInfo(ctx context.Context, session *session, t *oaut
 		extraFields = mp.GetExtraFields(t)
 	}
 	authInfo := token.NewAuthCachedInfo(t, rawIDToken, extraFields)
+	authInfo.LastInteractiveAuth = time.Now()
 
 	var err error
 	if mpOK {
@@ -196,11 +248,29 @@ func (b *Broker) populateAuthInfo(ctx context.Context, session *session, t *oaut
 
 type option struct {
 	provider providers.Provider
+	// newProvider returns the providers of the additional issuers.
+	newProvider  func() providers.Provider
+	connectivity connectivityMonitor
+	retry        retryPolicy
 }
 
 // Option is a func that allows to override some of the broker default settings.
 type Option func(*option)
 
+// connectivityMonitor reports whether the machine is connected to a network.
+type connectivityMonitor interface {
+	Offline() bool
+	CaptivePortal() bool
+}
+
+// WithConnectivityMonitor returns an option to start the sessions in offline mode right away, without waiting for
+// the connection to the provider to time out, when the monitor reports that the machine is offline.
+func WithConnectivityMonitor(m connectivityMonitor) Option {
+	return func(o *option) {
+		o.connectivity = m
+	}
+}
+
 // New returns a new oidc Broker with the providers listed in the configuration file.
 func New(cfg Config, apiVersion uint, args ...Option) (b *Broker, err error) {
 	p := providers.CurrentProvider()
@@ -213,7 +283,9 @@ func New(cfg Config, apiVersion uint, args ...Option) (b *Broker, err error) {
 	}
 
 	opts := option{
-		provider: p,
+		provider:    p,
+		newProvider: providers.CurrentProvider,
+		retry:       defaultRetryPolicy,
 	}
 	for _, arg := range args {
 		arg(&opts)
@@ -222,25 +294,7 @@ func New(cfg Config, apiVersion uint, args ...Option) (b *Broker, err error) {
 	if cfg.DataDir == "" {
 		err = errors.Join(err, errors.New("cache path is required and was not provided"))
 	}
-	if cfg.issuerURL == "" {
-		err = errors.Join(err, errors.New("issuer URL is required and was not provided"))
-	}
-	if cfg.clientID == "" {
-		err = errors.Join(err, errors.New("client ID is required and was not provided"))
-	}
-	// The entra_password flow can only retrieve groups from Microsoft Graph when
-	// device registration or a client secret is available (see the matching check
-	// in isAuthModeAvailable). If neither is configured, the flow is unusable, so
-	// fail here rather than silently falling back at login time: a startup failure
-	// is far more visible to the administrator than a per-login denial.
-	if cfg.flows.EntraPassword && !cfg.registerDevice && cfg.clientSecret == "" {
-		if _, ok := providers.ProviderAs[himmelblau.EntraPasswordProvider](opts.provider); ok {
-			err = errors.Join(err, fmt.Errorf(
-				"invalid configuration: the %[1]q flow is enabled in [%[2]s], but it cannot retrieve group memberships from Microsoft Graph without %[3]q enabled or a %[4]q configured; "+
-					"fix this by either disabling %[1]q, enabling %[3]q, or granting the app the GroupMember.Read.All application permission and configuring a %[4]q",
-				flowsEntraPasswordKey, flowsSection, registerDeviceKey, clientSecret))
-		}
-	}
+	err = errors.Join(err, validateUserConfig(cfg.userConfig, opts.provider))
 	if err != nil {
 		return nil, err
 	}
@@ -249,6 +303,16 @@ func New(cfg Config, apiVersion uint, args ...Option) (b *Broker, err error) {
 		cfg.homeBaseDir = "/home"
 	}
 
+	storage, err := secretstorage.New(cfg.secretStorage, cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := newProviderTransport(cfg.network)
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate a new private key for the broker.
 	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
@@ -257,7 +321,8 @@ func New(cfg Config, apiVersion uint, args ...Option) (b *Broker, err error) {
 	}
 
 	clientID := cfg.clientID
-	if _, ok := providers.ProviderAs[providers.DeviceRegisterer](opts.provider); ok && cfg.registerDevice {
+	_, isDR := providers.ProviderAs[providers.DeviceRegisterer](opts.provider)
+	if isDR && cfg.registerDevice {
 		clientID = consts.MicrosoftBrokerAppID
 	}
 
@@ -270,25 +335,100 @@ func New(cfg Config, apiVersion uint, args ...Option) (b *Broker, err error) {
 	}
 
 	b = &Broker{
-		cfg:              cfg,
-		apiVersion:       apiVersion,
-		provider:         opts.provider,
-		oidcCfg:          oidc.Config{ClientID: clientID},
-		oidcClientSecret: oidcClientSecret,
-		privateKey:       privateKey,
+		cfg:        cfg,
+		apiVersion: apiVersion,
+		provider:   opts.provider,
+		privateKey: privateKey,
+		messages:   l10n.New(po.Files, cfg.MessagesDir),
+		issuer: &issuer{
+			url:            cfg.issuerURL,
+			clientID:       cfg.clientID,
+			oidcCfg:        oidc.Config{ClientID: clientID},
+			clientSecret:   oidcClientSecret,
+			registerDevice: isDR && cfg.registerDevice,
+			provider:       opts.provider,
+		},
+		additionalIssuers: newIssuers(cfg.userConfig, opts.newProvider, transport),
+		connectivity:      opts.connectivity,
+		httpClient:        newProviderHTTPClient(transport),
+		retry:             opts.retry,
+		storage:           storage,
 
 		currentSessions:   make(map[string]session),
 		currentSessionsMu: sync.RWMutex{},
+
+		backgroundRefreshes: make(map[string]backgroundRefresh),
 	}
 
+	configureProvider(opts.provider, cfg.clientSecret, cfg.claims, transport)
+
+	return b, nil
+}
+
+// configureProvider passes the settings of its issuer to the provider p.
+func configureProvider(p providers.Provider, clientSecret string, claims genericprovider.ClaimMapping, transport http.RoundTripper) {
 	// If the provider supports app-only Graph API group lookup and a client secret
 	// is configured, propagate the secret so it can use client credentials as
 	// a fallback when the delegated token lacks GroupMember.Read.All.
-	if setter, ok := providers.ProviderAs[providers.GraphClientSecretSetter](opts.provider); ok && cfg.clientSecret != "" {
-		setter.SetGraphClientSecret(cfg.clientSecret)
+	if setter, ok := providers.ProviderAs[providers.GraphClientSecretSetter](p); ok && clientSecret != "" {
+		setter.SetGraphClientSecret(clientSecret)
 	}
+	if setter, ok := providers.ProviderAs[providers.ClaimMappingSetter](p); ok && claims != (genericprovider.ClaimMapping{}) {
+		setter.SetClaimMapping(claims)
+	}
+	// The providers sending requests with their own clients go through the proxy of the broker as well.
+	if setter, ok := providers.ProviderAs[providers.TransportSetter](p); ok {
+		setter.SetTransport(transport)
+	}
+}
 
-	return b, nil
+// validateUserConfig checks that the configuration can be used by the broker with the provider p.
+func validateUserConfig(cfg userConfig, p providers.Provider) (err error) {
+	if cfg.issuerURL == "" {
+		err = errors.Join(err, errors.New("issuer URL is required and was not provided"))
+	}
+	if cfg.clientID == "" {
+		err = errors.Join(err, errors.New("client ID is required and was not provided"))
+	}
+	// The entra_password flow can only retrieve groups from Microsoft Graph when
+	// device registration or a client secret is available (see the matching check
+	// in isAuthModeAvailable). If neither is configured, the flow is unusable, so
+	// fail here rather than silently falling back at login time: a startup failure
+	// is far more visible to the administrator than a per-login denial.
+	if cfg.flows.EntraPassword && !cfg.registerDevice && cfg.clientSecret == "" {
+		if _, ok := providers.ProviderAs[himmelblau.EntraPasswordProvider](p); ok {
+			err = errors.Join(err, fmt.Errorf(
+				"invalid configuration: the %[1]q flow is enabled in [%[2]s], but it cannot retrieve group memberships from Microsoft Graph without %[3]q enabled or a %[4]q configured; "+
+					"fix this by either disabling %[1]q, enabling %[3]q, or granting the app the GroupMember.Read.All application permission and configuring a %[4]q",
+				flowsEntraPasswordKey, flowsSection, registerDeviceKey, clientSecret))
+		}
+	}
+	if _, ok := providers.ProviderAs[providers.ClaimMappingSetter](p); cfg.claims != (genericprovider.ClaimMapping{}) && !ok {
+		err = errors.Join(err, fmt.Errorf(
+			"invalid configuration: the claims of the users are set in [%s], but %s can't read the users from other claims",
+			usersSection, p.DisplayName()))
+	}
+	// The access tokens handed to the applications are the ones obtained when the users log in, so they can't have
+	// other scopes.
+	for _, scope := range cfg.sessionTokenScopes {
+		if !slices.Contains(loginScopes(cfg, p), scope) {
+			err = errors.Join(err, fmt.Errorf(
+				"invalid configuration: scope %q of %q in [%s] is not requested when the users log in; add it to %q",
+				scope, sessionTokenScopesKey, oidcSection, extraScopesKey))
+		}
+	}
+	if cfg.fipsMode && !fips140.Enabled() {
+		err = errors.Join(err, fmt.Errorf(
+			"invalid configuration: %q is enabled in [%s], but the Go cryptographic module is not running in FIPS 140 mode; "+
+				"start the broker with GODEBUG=fips140=on", fipsModeKey, oidcSection))
+	}
+	if (cfg.network.clientCertificate == "") != (cfg.network.clientKey == "") {
+		err = errors.Join(err, fmt.Errorf(
+			"invalid configuration: %q and %q must be set together in [%s]", clientCertificateKey, clientKeyKey, networkSection))
+	}
+	err = errors.Join(err, validateIssuersConfig(cfg, p))
+
+	return err
 }
 
 // normalizedIssuer converts an issuer URL into a filesystem-safe directory name
@@ -310,6 +450,7 @@ func setCachePaths(s *session, dataDir string) {
 	s.userDataDir = dataDir
 	s.tokenPath = filepath.Join(dataDir, "token.json")
 	s.passwordPath = filepath.Join(dataDir, "password")
+	s.passwordHistoryPath = filepath.Join(dataDir, "password_history")
 }
 
 func childDir(parent, name string) (string, error) {
@@ -337,21 +478,21 @@ func pathIsInside(parent, child string) bool {
 	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
 }
 
-func (b *Broker) issuerDataDir() (string, error) {
-	dir, err := childDir(b.cfg.DataDir, normalizedIssuer(b.cfg.issuerURL))
+func (b *Broker) issuerDataDir(iss *issuer) (string, error) {
+	dir, err := childDir(b.cfg.DataDir, normalizedIssuer(iss.url))
 	if err != nil {
-		return "", fmt.Errorf("can't use issuer URL %q for data dir: %w", b.cfg.issuerURL, err)
+		return "", fmt.Errorf("can't use issuer URL %q for data dir: %w", iss.url, err)
 	}
 	return dir, nil
 }
 
-func (b *Broker) cacheSymlinkTarget(linkPath string) (string, error) {
+func (b *Broker) cacheSymlinkTarget(iss *issuer, linkPath string) (string, error) {
 	target, err := filepath.EvalSymlinks(linkPath)
 	if err != nil {
 		return "", err
 	}
 
-	issuerDataDir, err := b.issuerDataDir()
+	issuerDataDir, err := b.issuerDataDir(iss)
 	if err != nil {
 		return "", err
 	}
@@ -370,7 +511,7 @@ func (b *Broker) cacheSymlinkTarget(linkPath string) (string, error) {
 	return target, nil
 }
 
-func (b *Broker) ensureCompatibilitySymlink(linkPath, target string) error {
+func (b *Broker) ensureCompatibilitySymlink(iss *issuer, linkPath, target string) error {
 	// Use a relative symlink so that the link remains valid when the path prefix
 	// changes (e.g. a snap revision bump moves everything from .../316/... to
 	// .../317/... while the relative offset between the two sibling directories
@@ -392,7 +533,7 @@ func (b *Broker) ensureCompatibilitySymlink(linkPath, target string) error {
 		return fmt.Errorf("path already exists and is not a symlink")
 	}
 
-	existingTarget, err := b.cacheSymlinkTarget(linkPath)
+	existingTarget, err := b.cacheSymlinkTarget(iss, linkPath)
 	if err != nil {
 		if rmErr := os.Remove(linkPath); rmErr != nil {
 			return fmt.Errorf("could not replace invalid cache compatibility symlink %q: %w", linkPath, rmErr)
@@ -419,7 +560,7 @@ func (b *Broker) ensureCompatibilitySymlink(linkPath, target string) error {
 	return os.Symlink(relTarget, linkPath)
 }
 
-func consolidateKnownCacheFiles(sourceDir, targetDir string) error {
+func consolidateKnownCacheFiles(sourceDir, targetDir string, storage *secretstorage.Storage) error {
 	entries, err := os.ReadDir(sourceDir)
 	if errors.Is(err, os.ErrNotExist) {
 		return nil
@@ -473,6 +614,10 @@ func consolidateKnownCacheFiles(sourceDir, targetDir string) error {
 			if err := os.Rename(sourcePath, targetPath); err != nil {
 				return err
 			}
+			// The sealed secrets are bound to their path.
+			if err := storage.Rebind(targetPath, sourcePath); err != nil {
+				return err
+			}
 			continue
 		} else if err != nil {
 			return err
@@ -486,31 +631,31 @@ func consolidateKnownCacheFiles(sourceDir, targetDir string) error {
 	return os.Remove(sourceDir)
 }
 
-func (b *Broker) ensureUsernameCompatibilityPath(username, providerIDDir string) error {
-	usernameDir, err := b.userDataDir(username)
+func (b *Broker) ensureUsernameCompatibilityPath(iss *issuer, username, providerIDDir string) error {
+	usernameDir, err := b.userDataDir(iss, username)
 	if err != nil {
 		return err
 	}
 
 	info, err := os.Lstat(usernameDir)
 	if errors.Is(err, os.ErrNotExist) {
-		return b.ensureCompatibilitySymlink(usernameDir, providerIDDir)
+		return b.ensureCompatibilitySymlink(iss, usernameDir, providerIDDir)
 	}
 	if err != nil {
 		return err
 	}
 
 	if info.Mode()&os.ModeSymlink != 0 {
-		return b.ensureCompatibilitySymlink(usernameDir, providerIDDir)
+		return b.ensureCompatibilitySymlink(iss, usernameDir, providerIDDir)
 	}
 	if !info.IsDir() {
 		return fmt.Errorf("path already exists and is not a directory or symlink")
 	}
-	if err := consolidateKnownCacheFiles(usernameDir, providerIDDir); err != nil {
+	if err := consolidateKnownCacheFiles(usernameDir, providerIDDir, b.storage); err != nil {
 		return err
 	}
 
-	return b.ensureCompatibilitySymlink(usernameDir, providerIDDir)
+	return b.ensureCompatibilitySymlink(iss, usernameDir, providerIDDir)
 }
 
 // adoptProviderIDDir points the session at the provider ID-keyed cache directory. It is only
@@ -537,7 +682,7 @@ func adoptProviderIDDir(s *session, providerID, providerIDDir string) {
 // paths, leaving the username-based layout in place for a later attempt. s.providerID is set only
 // after the cache has been switched to the provider ID directory.
 func (b *Broker) ensureProviderIDCacheDir(s *session, providerID string) {
-	providerIDDir, err := b.userDataDir(providerID)
+	providerIDDir, err := b.userDataDir(s.issuer, providerID)
 	if err != nil {
 		log.Warningf(context.Background(), "Could not determine cache directory for provider ID %q: %v", providerID, err)
 		return
@@ -568,12 +713,12 @@ func (b *Broker) redirectToExistingProviderIDDir(s *session, providerID, provide
 	log.Infof(context.Background(), "Redirecting cache for user %q to existing provider ID-based directory %q", s.username, providerIDDir)
 
 	if info, lstatErr := os.Lstat(s.userDataDir); lstatErr == nil && info.IsDir() {
-		if moveErr := consolidateKnownCacheFiles(s.userDataDir, providerIDDir); moveErr != nil {
+		if moveErr := consolidateKnownCacheFiles(s.userDataDir, providerIDDir, b.storage); moveErr != nil {
 			log.Warningf(context.Background(), "Could not consolidate cache directory %q into %q: %v", s.userDataDir, providerIDDir, moveErr)
 			return
 		}
 	}
-	if linkErr := b.ensureCompatibilitySymlink(s.userDataDir, providerIDDir); linkErr != nil {
+	if linkErr := b.ensureCompatibilitySymlink(s.issuer, s.userDataDir, providerIDDir); linkErr != nil {
 		log.Warningf(context.Background(), "Could not create cache compatibility symlink %q: %v", s.userDataDir, linkErr)
 		return
 	}
@@ -596,8 +741,9 @@ func (b *Broker) migrateUsernameDirToProviderIDDir(s *session, providerID, provi
 		return
 	}
 	log.Infof(context.Background(), "Migrated cache directory for user %q from %q to %q", s.username, s.userDataDir, providerIDDir)
+	b.rebindCacheFiles(providerIDDir, s.userDataDir)
 
-	linkErr := b.ensureCompatibilitySymlink(s.userDataDir, providerIDDir)
+	linkErr := b.ensureCompatibilitySymlink(s.issuer, s.userDataDir, providerIDDir)
 	if linkErr == nil {
 		adoptProviderIDDir(s, providerID, providerIDDir)
 		return
@@ -611,6 +757,27 @@ func (b *Broker) migrateUsernameDirToProviderIDDir(s *session, providerID, provi
 	if rollbackErr := os.Rename(providerIDDir, s.userDataDir); rollbackErr != nil {
 		log.Warningf(context.Background(), "Could not roll back cache directory migration from %q to %q: %v", providerIDDir, s.userDataDir, rollbackErr)
 		adoptProviderIDDir(s, providerID, providerIDDir)
+		return
+	}
+	b.rebindCacheFiles(s.userDataDir, providerIDDir)
+}
+
+// rebindCacheFiles binds the sealed secrets of the cache directory dir, which was moved from previousDir, to their new
+// path. The secrets which can't be rebound are only logged, as the user can cache them again by authenticating online.
+func (b *Broker) rebindCacheFiles(dir, previousDir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Warningf(context.Background(), "Could not read cache directory %q: %v", dir, err)
+		return
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err := b.storage.Rebind(path, filepath.Join(previousDir, entry.Name())); err != nil {
+			log.Warningf(context.Background(), "Could not bind %q to its new path: %v", path, err)
+		}
 	}
 }
 
@@ -623,7 +790,7 @@ func (b *Broker) createProviderIDDirWithSymlink(s *session, providerID, provider
 		log.Warningf(context.Background(), "Could not create provider ID cache directory %q: %v", providerIDDir, mkdirErr)
 		return
 	}
-	if linkErr := b.ensureCompatibilitySymlink(s.userDataDir, providerIDDir); linkErr != nil {
+	if linkErr := b.ensureCompatibilitySymlink(s.issuer, s.userDataDir, providerIDDir); linkErr != nil {
 		log.Warningf(context.Background(), "Could not create cache compatibility symlink %q: %v", s.userDataDir, linkErr)
 		if rmErr := os.RemoveAll(providerIDDir); rmErr != nil {
 			log.Warningf(context.Background(), "Could not remove unused provider ID cache directory %q: %v", providerIDDir, rmErr)
@@ -633,16 +800,16 @@ func (b *Broker) createProviderIDDirWithSymlink(s *session, providerID, provider
 	adoptProviderIDDir(s, providerID, providerIDDir)
 }
 
-// userDataDir returns the path to the broker's data directory for the given user.
+// userDataDir returns the path to the broker's data directory for the given user of the issuer.
 // If the issuer URL or the username contains path traversal characters, an error is returned.
-func (b *Broker) userDataDir(basePath string) (string, error) {
+func (b *Broker) userDataDir(iss *issuer, basePath string) (string, error) {
 	if basePath == "" {
 		return "", errors.New("base path cannot be empty")
 	}
 
-	issuerDataDir, err := b.issuerDataDir()
+	issuerDataDir, err := b.issuerDataDir(iss)
 	if err != nil {
-		return "", fmt.Errorf("invalid issuer URL %q: %w", b.cfg.issuerURL, err)
+		return "", fmt.Errorf("invalid issuer URL %q: %w", iss.url, err)
 	}
 
 	dir, err := childDir(issuerDataDir, basePath)
@@ -666,6 +833,7 @@ func (b *Broker) NewSession(username, lang, mode, providerID string) (sessionID,
 		username: username,
 		lang:     lang,
 		mode:     mode,
+		issuer:   b.issuerFor(username),
 
 		attemptsPerMode: make(map[string]int),
 	}
@@ -680,11 +848,11 @@ func (b *Broker) NewSession(username, lang, mode, providerID string) (sessionID,
 	// the case where the username changed at the IdP (no symlink exists for
 	// the new email).
 	if providerID != "" {
-		if providerIDDir, providerIDErr := b.userDataDir(providerID); providerIDErr == nil {
+		if providerIDDir, providerIDErr := b.userDataDir(s.issuer, providerID); providerIDErr == nil {
 			if fi, statErr := os.Stat(providerIDDir); statErr == nil && fi.IsDir() {
 				s.providerID = providerID
 				setCachePaths(&s, providerIDDir)
-				if linkErr := b.ensureUsernameCompatibilityPath(username, providerIDDir); linkErr != nil {
+				if linkErr := b.ensureUsernameCompatibilityPath(s.issuer, username, providerIDDir); linkErr != nil {
 					log.Warningf(context.Background(), "Could not repair cache compatibility path for user %q: %v", username, linkErr)
 				}
 			}
@@ -693,7 +861,7 @@ func (b *Broker) NewSession(username, lang, mode, providerID string) (sessionID,
 
 	// Fall back to the username-based path when provider ID lookup didn't resolve.
 	if s.userDataDir == "" {
-		s.userDataDir, err = b.userDataDir(username)
+		s.userDataDir, err = b.userDataDir(s.issuer, username)
 		if err != nil {
 			return "", "", err
 		}
@@ -706,9 +874,9 @@ func (b *Broker) NewSession(username, lang, mode, providerID string) (sessionID,
 		if linkInfo, lstatErr := os.Lstat(s.userDataDir); lstatErr == nil {
 			switch {
 			case linkInfo.Mode()&os.ModeSymlink != 0:
-				if providerIDDir, evalErr := b.cacheSymlinkTarget(s.userDataDir); evalErr == nil {
+				if providerIDDir, evalErr := b.cacheSymlinkTarget(s.issuer, s.userDataDir); evalErr == nil {
 					setCachePaths(&s, providerIDDir)
-					if cachedInfo, loadErr := token.LoadAuthInfo(s.tokenPath); loadErr == nil {
+					if cachedInfo, loadErr := token.LoadAuthInfo(s.tokenPath, token.WithStorage(b.storage)); loadErr == nil {
 						s.providerID = cachedInfo.UserInfo.ProviderID
 					}
 				} else {
@@ -721,17 +889,27 @@ func (b *Broker) NewSession(username, lang, mode, providerID string) (sessionID,
 					}
 				}
 			case linkInfo.IsDir():
-				if cachedInfo, loadErr := token.LoadAuthInfo(s.tokenPath); loadErr == nil && cachedInfo.UserInfo.ProviderID != "" {
+				if cachedInfo, loadErr := token.LoadAuthInfo(s.tokenPath, token.WithStorage(b.storage)); loadErr == nil && cachedInfo.UserInfo.ProviderID != "" {
 					b.ensureProviderIDCacheDir(&s, cachedInfo.UserInfo.ProviderID)
 				}
 			}
 		}
 	}
 
-	// Construct an OIDC provider via OIDC discovery.
-	s.oidcServer, err = b.connectToOIDCServer(context.Background())
+	// Construct an OIDC provider via OIDC discovery, unless the machine is offline.
+	if b.machineIsOffline() {
+		err = errMachineOffline
+	} else {
+		s.oidcServer, err = b.connectToOIDCServer(context.Background(), s.issuer)
+	}
 	if err != nil && b.cfg.forceAccessCheckWithProvider {
 		log.Errorf(context.Background(), "Could not connect to the provider and force_access_check_with_provider is set, denying authentication: %v", err)
+		if b.behindCaptivePortal(err) {
+			return "", "", errors.New(b.messages.Get(lang, captivePortalMsg))
+		}
+		if errors.Is(err, errProviderUnavailable) {
+			return "", "", errors.New(b.messages.Get(lang, providerUnavailableMsg))
+		}
 		//nolint:staticcheck,revive // ST1005 This error is displayed as is to the user, so it should be capitalized
 		return "", "", errors.New("Error connecting to provider. Check your network connection.")
 	}
@@ -741,19 +919,8 @@ func (b *Broker) NewSession(username, lang, mode, providerID string) (sessionID,
 		s.providerConnectionError = err
 	}
 
-	scopes := append(consts.DefaultScopes, b.provider.AdditionalScopes()...)
-	if _, ok := providers.ProviderAs[providers.DeviceRegisterer](b.provider); ok && b.cfg.registerDevice {
-		scopes = consts.MicrosoftBrokerAppScopes
-	}
-	// Append extra scopes from config
-	scopes = append(scopes, b.cfg.extraScopes...)
 	if s.oidcServer != nil {
-		s.oauth2Config = oauth2.Config{
-			ClientID:     b.oidcCfg.ClientID,
-			ClientSecret: b.oidcClientSecret,
-			Endpoint:     s.oidcServer.Endpoint(),
-			Scopes:       scopes,
-		}
+		s.oauth2Config = b.oauth2Config(s.issuer, s.oidcServer)
 	}
 
 	b.currentSessionsMu.Lock()
@@ -763,11 +930,67 @@ func (b *Broker) NewSession(username, lang, mode, providerID string) (sessionID,
 	return sessionID, base64.StdEncoding.EncodeToString(pubASN1), nil
 }
 
-func (b *Broker) connectToOIDCServer(ctx context.Context) (*oidc.Provider, error) {
-	ctx, cancel := context.WithTimeout(ctx, maxRequestDuration)
-	defer cancel()
+// oauth2Config returns the configuration to authenticate the users of the issuer with the provider and to refresh
+// their tokens.
+func (b *Broker) oauth2Config(iss *issuer, oidcServer *oidc.Provider) oauth2.Config {
+	cfg := b.cfg.userConfig
+	cfg.registerDevice = iss.registerDevice
+	return oauth2.Config{
+		ClientID:     iss.oidcCfg.ClientID,
+		ClientSecret: iss.clientSecret,
+		Endpoint:     oidcServer.Endpoint(),
+		Scopes:       loginScopes(cfg, iss.provider),
+	}
+}
+
+// loginScopes returns the scopes requested to the provider p when the users authenticate.
+func loginScopes(cfg userConfig, p providers.Provider) []string {
+	scopes := append(consts.DefaultScopes, p.AdditionalScopes()...)
+	if _, ok := providers.ProviderAs[providers.DeviceRegisterer](p); ok && cfg.registerDevice {
+		scopes = consts.MicrosoftBrokerAppScopes
+	}
+	// Append extra scopes from config
+	return append(scopes, cfg.extraScopes...)
+}
+
+func (b *Broker) connectToOIDCServer(ctx context.Context, iss *issuer) (*oidc.Provider, error) {
+	var p *oidc.Provider
+	err := b.withRetries(ctx, "discover the provider", func() (err error) {
+		ctx, cancel := context.WithTimeout(ctx, maxRequestDuration)
+		defer cancel()
+
+		p, err = oidc.NewProvider(b.providerContext(ctx), iss.url)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return iss.sharedOIDCServer(p), nil
+}
+
+// machineIsOffline returns true if the machine is known not to be connected to any network.
+func (b *Broker) machineIsOffline() bool {
+	return b.connectivity != nil && b.connectivity.Offline()
+}
 
-	return oidc.NewProvider(ctx, b.cfg.issuerURL)
+// reconnectIfOnline connects the session to the provider if it was started while the machine was offline and the
+// machine is now back online, so that the user is authenticated online again.
+func (b *Broker) reconnectIfOnline(session *session) {
+	if !session.isOffline || !errors.Is(session.providerConnectionError, errMachineOffline) || b.machineIsOffline() {
+		return
+	}
+
+	oidcServer, err := b.connectToOIDCServer(context.Background(), session.issuer)
+	if err != nil {
+		log.Noticef(context.Background(), "The machine is back online but the provider can't be reached, the session stays in offline mode: %v", err)
+		session.providerConnectionError = err
+		return
+	}
+	log.Noticef(context.Background(), "The machine is back online, switching the session of user %q to online mode", session.username)
+	session.oidcServer = oidcServer
+	session.oauth2Config = b.oauth2Config(session.issuer, oidcServer)
+	session.isOffline = false
+	session.providerConnectionError = nil
 }
 
 // GetAuthenticationModes returns the authentication modes available for the user.
@@ -776,6 +999,7 @@ func (b *Broker) GetAuthenticationModes(sessionID string, supportedUILayouts []m
 	if err != nil {
 		return nil, err
 	}
+	b.reconnectIfOnline(&session)
 
 	availableModes, err := b.availableAuthModes(session)
 	if err != nil {
@@ -797,7 +1021,7 @@ func (b *Broker) GetAuthenticationModes(sessionID string, supportedUILayouts []m
 
 		authModesWithLabels = append(authModesWithLabels, map[string]string{
 			"id":    mode,
-			"label": authmodes.Label[mode],
+			"label": b.messages.Get(session.lang, authmodes.Label[mode]),
 		})
 	}
 
@@ -806,8 +1030,17 @@ func (b *Broker) GetAuthenticationModes(sessionID string, supportedUILayouts []m
 		// report the connection error.
 		if session.providerConnectionError != nil {
 			log.Errorf(context.Background(), "Error connecting to provider: %v", session.providerConnectionError)
+			if b.sessionCredentialsExpired(session) {
+				return nil, errors.New(b.messages.Getf(session.lang, credentialsExpiredMsg, b.provider.DisplayName()))
+			}
+			if b.behindCaptivePortal(session.providerConnectionError) {
+				return nil, errors.New(b.messages.Get(session.lang, captivePortalMsg))
+			}
+			if errors.Is(session.providerConnectionError, errProviderUnavailable) {
+				return nil, errors.New(b.messages.Get(session.lang, providerUnavailableMsg))
+			}
 			//nolint:staticcheck,revive // ST1005 This error is displayed as is to the user, so it should be capitalized
-			return nil, errors.New("Error connecting to provider. Check your network connection.")
+			return nil, errors.New(b.messages.Get(session.lang, "Error connecting to provider. Check your network connection."))
 		}
 		return nil, fmt.Errorf("no authentication modes available for user %q", session.username)
 	}
@@ -865,12 +1098,31 @@ func (b *Broker) authModeIsAvailable(session session, authMode string) bool {
 			return false
 		}
 
-		authInfo, err := token.LoadAuthInfo(session.tokenPath)
+		if b.cfg.fipsMode {
+			// Passwords hashed before the FIPS mode was enabled can't be checked, so the user has to authenticate
+			// with the provider again and set a new local password, which is then hashed with an approved algorithm.
+			approved, err := password.IsFIPSApproved(session.passwordPath, password.WithStorage(b.storage))
+			if err != nil {
+				log.Warningf(context.Background(), "Could not check local password hash, so local password authentication is not available: %v", err)
+				return false
+			}
+			if !approved {
+				log.Noticef(context.Background(), "Local password of user %q was not hashed with a FIPS approved algorithm, so local password authentication is not available", session.username)
+				return false
+			}
+		}
+
+		authInfo, err := token.LoadAuthInfo(session.tokenPath, token.WithStorage(b.storage))
 		if err != nil {
 			log.Warningf(context.Background(), "Could not load token, so local password authentication is not available: %v", err)
 			return false
 		}
 
+		if b.credentialsExpired(authInfo) {
+			log.Noticef(context.Background(), "User %q did not authenticate with the provider for more than %s, so local password authentication is not available", session.username, b.cfg.maxCredentialsAge)
+			return false
+		}
+
 		dr, isDR := providers.ProviderAs[providers.DeviceRegisterer](b.provider)
 		if !isDR {
 			// If the provider does not support device registration,
@@ -888,12 +1140,12 @@ func (b *Broker) authModeIsAvailable(session session, authMode string) bool {
 
 		isTokenForDeviceRegistration := dr.IsTokenForDeviceRegistration(authInfo)
 
-		if b.cfg.registerDevice && !isTokenForDeviceRegistration {
+		if session.issuer.registerDevice && !isTokenForDeviceRegistration {
 			// TODO: We might want to display a message to the user in this case
 			log.Noticef(context.Background(), "Token exists for user %q, but it cannot be used for device registration, so local password authentication is not available", session.username)
 			return false
 		}
-		if !b.cfg.registerDevice && isTokenForDeviceRegistration {
+		if !session.issuer.registerDevice && isTokenForDeviceRegistration {
 			// TODO: We might want to display a message to the user in this case
 			log.Noticef(context.Background(), "Token exists for user %q, but it requires device registration, so local password authentication is not available", session.username)
 			return false
@@ -920,10 +1172,32 @@ func (b *Broker) authModeIsAvailable(session session, authMode string) bool {
 			return false
 		}
 		return true
+	case authmodes.Browser:
+		if !b.cfg.flows.Browser {
+			log.Debugf(context.Background(), "The browser login is disabled in the [flows] config, so it is not available")
+			return false
+		}
+		if session.oidcServer == nil {
+			log.Debugf(context.Background(), "OIDC server is not initialized, so the browser login is not available")
+			return false
+		}
+		if session.oidcServer.Endpoint().AuthURL == "" {
+			log.Debugf(context.Background(), "OIDC server does not support the authorization code flow, so the browser login is not available")
+			return false
+		}
+		if session.isOffline {
+			log.Noticef(context.Background(), "Session is in offline mode, so the browser login is not available")
+			return false
+		}
+		return true
 	case authmodes.EntraPassword:
 		if _, ok := providers.ProviderAs[himmelblau.EntraPasswordProvider](b.provider); !ok {
 			return false
 		}
+		if session.issuer != b.issuer {
+			log.Debugf(context.Background(), "User %q does not authenticate with the issuer of the [%s] section, so Entra password authentication is not available", session.username, oidcSection)
+			return false
+		}
 		if !b.cfg.flows.EntraPassword {
 			log.Debugf(context.Background(), "The %q flow is disabled in the [flows] config, so it is not available", authmodes.EntraPassword)
 			return false
@@ -944,7 +1218,7 @@ func (b *Broker) authModeIsAvailable(session session, authMode string) bool {
 			return false
 		}
 		return true
-	case authmodes.EntraMFAWait, authmodes.EntraMFACode:
+	case authmodes.EntraMFAWait, authmodes.EntraMFACode, authmodes.EntraMFAFIDO:
 		// MFA follow-up modes are always available when offered via AuthNext.
 		return true
 	}
@@ -987,6 +1261,15 @@ func (b *Broker) supportedAuthModesFromLayout(layout map[string]string) []string
 		}
 		return []string{authmodes.DeviceQr}
 
+	case "browser":
+		if !strings.Contains(layout["wait"], "true") {
+			return nil
+		}
+		return []string{authmodes.Browser}
+
+	case "webauthn":
+		return []string{authmodes.EntraMFAFIDO}
+
 	case "form":
 		var modes []string
 		if slices.Contains(supportedEntries, "chars_password") {
@@ -1036,12 +1319,29 @@ func (b *Broker) generateUILayout(session *session, authModeID string) (map[stri
 		return nil, fmt.Errorf("selected authentication mode %q does not exist", authModeID)
 	}
 
+	// The redirect URI of a previous browser login is not served anymore once another mode is selected.
+	session.browserLogin.close()
+	session.browserLogin = nil
+
 	var uiLayout map[string]string
 	switch authModeID {
-	case authmodes.Device, authmodes.DeviceQr:
-		ctx, cancel := context.WithTimeout(context.Background(), maxRequestDuration)
-		defer cancel()
+	case authmodes.Browser:
+		login, err := b.startBrowserLogin(session)
+		if err != nil {
+			return nil, fmt.Errorf("could not generate browser login layout: %v", err)
+		}
+		session.browserLogin = login
+
+		uiLayout = map[string]string{
+			"type":    "browser",
+			"label":   b.messages.Get(session.lang, "Open the URL in a web browser on this machine and log in."),
+			"wait":    "true",
+			"button":  b.messages.Get(session.lang, "Start again"),
+			"content": login.authURL,
+		}
 
+	case authmodes.Device, authmodes.DeviceQr:
+		ctx := context.Background()
 		var authOpts []oauth2.AuthCodeOption
 
 		// Workaround to cater for RFC compliant oauth2 server. Public providers do not properly
@@ -1061,9 +1361,27 @@ func (b *Broker) generateUILayout(session *session, authModeID string) (map[stri
 		if secret := session.oauth2Config.ClientSecret; secret != "" {
 			authOpts = append(authOpts, oauth2.SetAuthURLParam("client_secret", secret))
 		}
+		if locales := uiLocales(session.lang); locales != "" {
+			authOpts = append(authOpts, oauth2.SetAuthURLParam("ui_locales", locales))
+		}
 
 		log.Debug(ctx, "Sending Device Authorization Request to retrieve device code...")
-		response, err := session.oauth2Config.DeviceAuth(ctx, authOpts...)
+		var response *oauth2.DeviceAuthResponse
+		err := b.withRetries(ctx, "request a device code", func() (err error) {
+			ctx, cancel := context.WithTimeout(ctx, maxRequestDuration)
+			defer cancel()
+
+			response, err = session.oauth2Config.DeviceAuth(b.providerContext(ctx), authOpts...)
+			return err
+		})
+		if err != nil && b.behindCaptivePortal(err) {
+			log.Errorf(ctx, "Could not request a device code: %v", err)
+			return nil, errors.New(b.messages.Get(session.lang, captivePortalOrPasswordMsg))
+		}
+		if errors.Is(err, errProviderUnavailable) {
+			log.Errorf(ctx, "Could not request a device code: %v", err)
+			return nil, errors.New(b.messages.Get(session.lang, providerUnavailableMsg))
+		}
 		if err != nil {
 			return nil, fmt.Errorf("could not generate device code flow layout: %v", err)
 		}
@@ -1074,12 +1392,16 @@ func (b *Broker) generateUILayout(session *session, authModeID string) (map[stri
 		if authModeID == authmodes.DeviceQr {
 			label = "Scan the QR code or open the URL and enter the code below."
 		}
+		label = b.messages.Get(session.lang, label)
+		if expiryMsg := b.deviceCodeExpiryMessage(session, response.Expiry); expiryMsg != "" {
+			label += " " + expiryMsg
+		}
 
 		uiLayout = map[string]string{
 			"type":    "qrcode",
 			"label":   label,
 			"wait":    "true",
-			"button":  "Request new code",
+			"button":  b.messages.Get(session.lang, "Request new code"),
 			"content": response.VerificationURI,
 			"code":    response.UserCode,
 		}
@@ -1087,19 +1409,19 @@ func (b *Broker) generateUILayout(session *session, authModeID string) (map[stri
 	case authmodes.Password:
 		uiLayout = map[string]string{
 			"type":  "form",
-			"label": "Enter your password",
+			"label": b.messages.Get(session.lang, "Enter your password"),
 			"entry": "chars_password",
 		}
 
 	case authmodes.EntraPassword:
 		uiLayout = map[string]string{
 			"type":  "form",
-			"label": "Enter your Entra ID password",
+			"label": b.messages.Get(session.lang, "Enter your Entra ID password"),
 			"entry": "chars_password",
 		}
 
 	case authmodes.EntraMFAWait:
-		mfaWaitLabel := "Waiting for MFA approval..."
+		mfaWaitLabel := b.messages.Get(session.lang, "Waiting for MFA approval...")
 		if session.mfaChallengeInfo != nil && session.mfaChallengeInfo.Message != "" {
 			mfaWaitLabel = session.mfaChallengeInfo.Message
 		}
@@ -1113,7 +1435,18 @@ func (b *Broker) generateUILayout(session *session, authModeID string) (map[stri
 		uiLayout = map[string]string{
 			"type":  "form",
 			"entry": "chars",
-			"label": "Enter your MFA code",
+			"label": b.messages.Get(session.lang, "Enter your MFA code"),
+		}
+
+	case authmodes.EntraMFAFIDO:
+		content, err := webAuthnLayoutContent(session.mfaChallengeInfo)
+		if err != nil {
+			return nil, err
+		}
+		uiLayout = map[string]string{
+			"type":    "webauthn",
+			"label":   b.messages.Get(session.lang, "Insert your security key and touch it"),
+			"content": content,
 		}
 
 	case authmodes.NewPassword:
@@ -1121,10 +1454,13 @@ func (b *Broker) generateUILayout(session *session, authModeID string) (map[stri
 		if session.mode == sessionmode.ChangePassword || session.mode == sessionmode.ChangePasswordOld {
 			label = "Update your local password"
 		}
+		if session.passwordExpired || session.providerPasswordExpired {
+			label = "Choose a new local password"
+		}
 
 		uiLayout = map[string]string{
 			"type":  "newpassword",
-			"label": label,
+			"label": b.messages.Get(session.lang, label),
 			"entry": "chars_password",
 		}
 	}
@@ -1146,6 +1482,8 @@ func (b *Broker) IsAuthenticated(sessionID, authenticationData string) (string,
 		}
 	}
 
+	b.reconnectIfOnline(&session)
+
 	ctx, err := b.startAuthenticate(sessionID)
 	if err != nil {
 		return AuthDenied, "{}", err
@@ -1166,7 +1504,7 @@ func (b *Broker) IsAuthenticated(sessionID, authenticationData string) (string,
 	case <-authDone:
 	case <-ctx.Done():
 		// We can ignore the error here since the message is constant.
-		msg, _ := json.Marshal(errorMessage{Message: "Authentication request cancelled"})
+		msg, _ := json.Marshal(errorMessage{Message: b.messages.Get(session.lang, "Authentication request cancelled")})
 		return AuthCancelled, string(msg), ctx.Err()
 	}
 
@@ -1189,7 +1527,7 @@ func (b *Broker) IsAuthenticated(sessionID, authenticationData string) (string,
 		return AuthDenied, "{}", err
 	}
 
-	encoded, err := json.Marshal(iadResponse)
+	encoded, err := json.Marshal(b.localizedResponse(session.lang, iadResponse))
 	if err != nil {
 		return AuthDenied, "{}", fmt.Errorf("could not parse data to JSON: %v", err)
 	}
@@ -1202,7 +1540,10 @@ func (b *Broker) IsAuthenticated(sessionID, authenticationData string) (string,
 }
 
 func unexpectedErrMsg(msg string) errorMessage {
-	return errorMessage{Message: fmt.Sprintf("An unexpected error occurred: %s. Please report this error on https://github.com/canonical/authd/issues", msg)}
+	return errorMessage{
+		Message: "An unexpected error occurred: %s. Please report this error on https://github.com/canonical/authd/issues",
+		args:    []any{msg},
+	}
 }
 
 func (b *Broker) handleIsAuthenticated(ctx context.Context, session *session, authData map[string]string) (access string, data isAuthenticatedDataResponse) {
@@ -1221,6 +1562,8 @@ func (b *Broker) handleIsAuthenticated(ctx context.Context, session *session, au
 	switch session.selectedMode {
 	case authmodes.Device, authmodes.DeviceQr:
 		return b.deviceAuth(ctx, session)
+	case authmodes.Browser:
+		return b.browserAuth(ctx, session)
 	case authmodes.Password:
 		return b.passwordAuth(ctx, session, secret)
 	case authmodes.NewPassword:
@@ -1231,6 +1574,8 @@ func (b *Broker) handleIsAuthenticated(ctx context.Context, session *session, au
 		return b.entraMFAWaitAuth(ctx, session)
 	case authmodes.EntraMFACode:
 		return b.entraMFACodeAuth(ctx, session, secret)
+	case authmodes.EntraMFAFIDO:
+		return b.entraMFAFIDOAuth(ctx, session, authData[AuthDataWebAuthnAssertion])
 	default:
 		log.Errorf(context.Background(), "unknown authentication mode %q", session.selectedMode)
 		return AuthDenied, unexpectedErrMsg("unknown authentication mode")
@@ -1261,6 +1606,9 @@ func (b *Broker) deviceAuth(ctx context.Context, session *session) (string, isAu
 	t, err := session.oauth2Config.DeviceAccessToken(expiryCtx, response, b.provider.AuthOptions()...)
 	if err != nil {
 		log.Errorf(context.Background(), "Error retrieving access token: %s", err)
+		if b.behindCaptivePortal(err) {
+			return AuthRetry, errorMessage{Message: captivePortalOrPasswordMsg}
+		}
 		return AuthRetry, errorMessage{Message: "Error retrieving access token. Please try again."}
 	}
 	log.Debug(ctx, "Exchanged device code for token.")
@@ -1269,6 +1617,12 @@ func (b *Broker) deviceAuth(ctx context.Context, session *session) (string, isAu
 		log.Warningf(context.Background(), "No refresh token returned for user during device code flow. You might have to add the 'offline_access' scope to the 'extra_scopes' setting.")
 	}
 
+	return b.authenticateWithProviderToken(ctx, session, t)
+}
+
+// authenticateWithProviderToken gets the information of the user from the token which the provider returned at the end
+// of the device code flow or of the browser login, before they set their local password.
+func (b *Broker) authenticateWithProviderToken(ctx context.Context, session *session, t *oauth2.Token) (string, isAuthenticatedDataResponse) {
 	rawIDToken, ok := t.Extra("id_token").(string)
 	if !ok {
 		log.Error(context.Background(), "token response does not contain an ID token")
@@ -1282,7 +1636,7 @@ func (b *Broker) deviceAuth(ctx context.Context, session *session) (string, isAu
 
 	// Load existing device registration data if there is any, to avoid re-registering the device.
 	var deviceRegistrationData []byte
-	if oldAuthInfo, err := token.LoadAuthInfo(session.tokenPath); err == nil {
+	if oldAuthInfo, err := token.LoadAuthInfo(session.tokenPath, token.WithStorage(b.storage)); err == nil {
 		deviceRegistrationData = oldAuthInfo.DeviceRegistrationData
 	}
 	if authInfo.UserInfo.ProviderID != "" && session.providerID == "" {
@@ -1297,6 +1651,7 @@ func (b *Broker) deviceAuth(ctx context.Context, session *session) (string, isAu
 
 	// We can only fetch the groups after registering the device, because the token acquired for device registration
 	// cannot be used with the Microsoft Graph API and a new token must be acquired for the Graph API.
+	var err error
 	authInfo.UserInfo.Groups, err = b.getGroups(ctx, session, authInfo)
 	if err != nil {
 		log.Errorf(context.Background(), "failed to get groups: %s", err)
@@ -1312,7 +1667,11 @@ func (b *Broker) deviceAuth(ctx context.Context, session *session) (string, isAu
 }
 
 func (b *Broker) passwordAuth(ctx context.Context, session *session, secret string) (string, isAuthenticatedDataResponse) {
-	ok, err := password.CheckPassword(secret, session.passwordPath)
+	ok, err := password.CheckPassword(secret, session.passwordPath, password.WithFIPSMode(b.cfg.fipsMode), password.WithStorage(b.storage))
+	if errors.Is(err, password.ErrNotFIPSApproved) {
+		log.Noticef(context.Background(), "Authentication failure: local password of user %q was not hashed with a FIPS approved algorithm", session.username)
+		return AuthDenied, errorMessage{Message: "Your local password can't be used anymore. Please log in again with your identity provider to set a new one."}
+	}
 	if err != nil {
 		log.Error(context.Background(), err.Error())
 		return AuthDenied, unexpectedErrMsg("could not check password")
@@ -1322,7 +1681,7 @@ func (b *Broker) passwordAuth(ctx context.Context, session *session, secret stri
 		return AuthRetry, errorMessage{Message: "Incorrect password, please try again."}
 	}
 
-	authInfo, err := token.LoadAuthInfo(session.tokenPath)
+	authInfo, err := token.LoadAuthInfo(session.tokenPath, token.WithStorage(b.storage))
 	if err != nil {
 		log.Error(context.Background(), err.Error())
 		return AuthDenied, unexpectedErrMsg("could not load stored token")
@@ -1366,6 +1725,11 @@ func (b *Broker) passwordAuth(ctx context.Context, session *session, secret stri
 				session.nextAuthModes = reauthModes
 				return AuthNext, errorMessage{Message: "Your password was changed remotely. Please re-authenticate."}
 			}
+			if irc, ok := providers.ProviderAs[providers.InteractionRequiredChecker](b.provider); ok {
+				if interaction := irc.RequiredInteraction(retrieveErr); interaction != providerErrors.NoInteraction {
+					return b.routeRequiredInteraction(interaction, session)
+				}
+			}
 			if b.provider.IsTokenExpiredError(retrieveErr) {
 				log.Noticef(context.Background(), "Refresh token expired for user %q, re-authentication required", session.username)
 				session.nextAuthModes = reauthModes
@@ -1377,24 +1741,29 @@ func (b *Broker) passwordAuth(ctx context.Context, session *session, secret stri
 
 				// Store the information that the user is disabled, so that we can deny login on subsequent offline attempts.
 				oldAuthInfo.UserIsDisabled = true
-				if err = token.CacheAuthInfo(session.tokenPath, oldAuthInfo); err != nil {
+				if err = token.CacheAuthInfo(session.tokenPath, oldAuthInfo, token.WithStorage(b.storage)); err != nil {
 					log.Errorf(context.Background(), "Failed to store token: %s", err)
 					return AuthDenied, unexpectedErrMsg("failed to store token")
 				}
 
-				return AuthDenied, errorMessage{Message: fmt.Sprintf("Your user account is disabled in %s, please contact your administrator.", b.provider.DisplayName())}
+				return AuthDenied, errorMessage{Message: "Your user account is disabled in %s, please contact your administrator.", args: []any{b.provider.DisplayName()}}
 			}
 		}
 		if err != nil {
 			log.Errorf(context.Background(), "Failed to refresh token: %s", err)
 
-			// Fall back to offline mode for transient network failures (e.g. timeout, DNS,
-			// connection refused). Unless provider authentication is forced.
+			// Fall back to offline mode for network failures (e.g. DNS, connection refused) and for the transient
+			// errors of the provider which persisted after the retries. Unless provider authentication is forced.
 			var netErr net.Error
-			if errors.As(err, &netErr) && !b.cfg.forceAccessCheckWithProvider {
+			if (errors.As(err, &netErr) || errors.Is(err, errProviderUnavailable)) && !b.cfg.forceAccessCheckWithProvider {
 				log.Warningf(context.Background(), "Network error during token refresh for user %q, skipping token refresh", session.username)
 				authInfo = oldAuthInfo
 				session.isOffline = true
+				session.providerConnectionError = err
+			} else if b.behindCaptivePortal(err) {
+				return AuthDenied, errorMessage{Message: captivePortalMsg}
+			} else if errors.Is(err, errProviderUnavailable) {
+				return AuthRetry, errorMessage{Message: providerUnavailableMsg}
 			} else {
 				return AuthDenied, errorMessage{Message: "Failed to refresh token"}
 			}
@@ -1406,12 +1775,12 @@ func (b *Broker) passwordAuth(ctx context.Context, session *session, secret stri
 	// to offline mode.
 	if authInfo.UserIsDisabled && session.isOffline {
 		log.Errorf(context.Background(), "Login denied: user %q is disabled in %s and session is offline", session.username, b.provider.DisplayName())
-		return AuthDenied, errorMessage{Message: fmt.Sprintf("Your user account is disabled in %s. Please contact your administrator or try again with a working network connection.", b.provider.DisplayName())}
+		return AuthDenied, errorMessage{Message: "Your user account is disabled in %s. Please contact your administrator or try again with a working network connection.", args: []any{b.provider.DisplayName()}}
 	}
 
 	if authInfo.DeviceIsDisabled && session.isOffline {
 		log.Errorf(context.Background(), "Login denied: device %q is disabled in %s and session is offline", session.username, b.provider.DisplayName())
-		return AuthDenied, errorMessage{Message: fmt.Sprintf("This device is disabled in %s. Please contact your administrator or try again with a working network connection.", b.provider.DisplayName())}
+		return AuthDenied, errorMessage{Message: "This device is disabled in %s. Please contact your administrator or try again with a working network connection.", args: []any{b.provider.DisplayName()}}
 	}
 
 	// If device registration is enabled, ensure that the device is registered.
@@ -1432,12 +1801,12 @@ func (b *Broker) passwordAuth(ctx context.Context, session *session, secret stri
 
 		// Store the information that the device is disabled, so that we can deny login on subsequent offline attempts.
 		authInfo.DeviceIsDisabled = true
-		if err = token.CacheAuthInfo(session.tokenPath, authInfo); err != nil {
+		if err = token.CacheAuthInfo(session.tokenPath, authInfo, token.WithStorage(b.storage)); err != nil {
 			log.Errorf(context.Background(), "Failed to store token: %s", err)
 			return AuthDenied, unexpectedErrMsg("failed to store token")
 		}
 
-		return AuthDenied, errorMessage{Message: fmt.Sprintf("This device is disabled in %s, please contact your administrator.", b.provider.DisplayName())}
+		return AuthDenied, errorMessage{Message: "This device is disabled in %s, please contact your administrator.", args: []any{b.provider.DisplayName()}}
 	}
 	if errors.Is(err, providerErrors.ErrInvalidRedirectURI) {
 		// Deny login if the redirect URI is invalid, so that users and administrators are aware of the issue.
@@ -1453,7 +1822,7 @@ func (b *Broker) passwordAuth(ctx context.Context, session *session, secret stri
 		// and register the device again, allowing the user to log in.
 		// We delete the device registration data to cause device code flow to re-register the device.
 		authInfo.DeviceRegistrationData = nil
-		if err = token.CacheAuthInfo(session.tokenPath, authInfo); err != nil {
+		if err = token.CacheAuthInfo(session.tokenPath, authInfo, token.WithStorage(b.storage)); err != nil {
 			log.Errorf(context.Background(), "Failed to store token: %s", err)
 			return AuthDenied, unexpectedErrMsg("failed to store token")
 		}
@@ -1472,6 +1841,16 @@ func (b *Broker) passwordAuth(ctx context.Context, session *session, secret stri
 		authInfo.UserInfo.Groups = groups
 	}
 
+	if b.localPasswordExpired(session) {
+		log.Noticef(context.Background(), "Local password of user %q is older than %s, so the user has to choose a new one", session.username, b.cfg.passwordPolicy.maxAge)
+		// Store the auth info in the session so that we can use it when handling the
+		// next IsAuthenticated call for the new password mode.
+		session.authInfo = authInfo
+		session.passwordExpired = true
+		session.nextAuthModes = []string{authmodes.NewPassword}
+		return AuthNext, errorMessage{Message: passwordExpiredMsg}
+	}
+
 	return b.finishAuth(session, authInfo)
 }
 
@@ -1495,7 +1874,7 @@ func (b *Broker) entraPasswordAuth(ctx context.Context, session *session, userPa
 	// yet), and for any other reason (e.g. an unreadable token) the flow can still
 	// proceed by treating it as "no prior device data". A nil session.authInfo is
 	// the correct state in both cases; log it for visibility.
-	cachedAuthInfo, err := token.LoadAuthInfo(session.tokenPath)
+	cachedAuthInfo, err := token.LoadAuthInfo(session.tokenPath, token.WithStorage(b.storage))
 	if err != nil {
 		log.Debugf(context.Background(), "No cached auth info for user %q (first login or unreadable token): %v", session.username, err)
 	}
@@ -1543,7 +1922,7 @@ func (b *Broker) entraPasswordAuth(ctx context.Context, session *session, userPa
 
 	// Hash the password immediately to narrow the plaintext memory window.
 	// The hash is written to disk in finishEntraAuth after MFA succeeds.
-	passwordHash, hashErr := password.HashPassword(userPassword)
+	passwordHash, hashErr := password.HashPassword(userPassword, password.WithFIPSMode(b.cfg.fipsMode))
 	if hashErr != nil {
 		log.Errorf(context.Background(), "Failed to hash password: %v", hashErr)
 		clearEntraMFAState(session)
@@ -1555,19 +1934,31 @@ func (b *Broker) entraPasswordAuth(ctx context.Context, session *session, userPa
 	mfaMethod := challengeInfo.Method
 	pollingInterval := challengeInfo.PollingIntervalMs
 
-	// FIDO/security-key MFA is not yet wired up in this terminal-based flow.
-	// This is an implementation gap, not a fundamental limitation: libhimmelblau
-	// can do FIDO (see https://github.com/himmelblau-idm/himmelblau/blob/main/src/common/src/auth.rs).
-	// TODO: support FIDO MFA directly without redirecting to the device code flow.
+	// FIDO/security-key MFA: the client signs the challenge with a security key
+	// attached to the machine if it supports it, otherwise the user has to
+	// complete the authentication with the device code flow. Old versions of
+	// libhimmelblau don't return the challenge, in which case only the device
+	// code flow is offered.
 	if isFIDOMethod(mfaMethod) {
-		log.Noticef(context.Background(), "FIDO MFA method %q detected for user %q; redirecting to the device code flow", mfaMethod, session.username)
-		session.entraPasswordHash = ""
-		clearEntraMFAState(session)
+		var modes []string
+		if challengeInfo.FIDOChallenge != "" && len(challengeInfo.FIDOAllowList) > 0 {
+			modes = append(modes, authmodes.EntraMFAFIDO)
+		} else {
+			log.Noticef(context.Background(), "FIDO MFA method %q detected for user %q without a challenge; redirecting to the device code flow", mfaMethod, session.username)
+			session.entraPasswordHash = ""
+			clearEntraMFAState(session)
+		}
 		if b.cfg.flows.DeviceAuth {
-			session.nextAuthModes = []string{authmodes.Device, authmodes.DeviceQr}
+			modes = append(modes, authmodes.Device, authmodes.DeviceQr)
+		}
+		if len(modes) == 0 {
+			return AuthDenied, errorMessage{Message: "This account requires FIDO/security key authentication, which is not yet supported in this mode. The device code flow is also unavailable. Please contact your administrator."}
+		}
+		session.nextAuthModes = modes
+		if !slices.Contains(modes, authmodes.EntraMFAFIDO) {
 			return AuthNext, errorMessage{Message: "This account requires FIDO/security key authentication. Please complete authentication using the device code flow."}
 		}
-		return AuthDenied, errorMessage{Message: "This account requires FIDO/security key authentication, which is not yet supported in this mode. The device code flow is also unavailable. Please contact your administrator."}
+		return AuthNext, nil
 	}
 
 	switch {
@@ -1863,7 +2254,7 @@ func (b *Broker) finishEntraAuth(ctx context.Context, session *session, mfaToken
 	// disk without a cached token (token-then-password matches the ordering of
 	// the device-auth flow).
 	if session.entraPasswordHash != "" {
-		if hashErr := password.StoreHashedPassword(session.entraPasswordHash, session.passwordPath); hashErr != nil {
+		if hashErr := password.StoreHashedPassword(session.entraPasswordHash, session.passwordPath, password.WithStorage(b.storage)); hashErr != nil {
 			log.Errorf(context.Background(), "Failed to store password hash: %v", hashErr)
 			return AuthDenied, unexpectedErrMsg("failed to store password")
 		}
@@ -1890,7 +2281,7 @@ func (b *Broker) routeMFAInitError(mfaErr *himmelblau.MFAError, session *session
 		return AuthDenied, errorMessage{Message: "Your password has expired. Please change it via the Entra portal."}
 	case 50057:
 		log.Noticef(context.Background(), "Login denied: user %q is disabled in %s (AADSTS50057)", session.username, b.provider.DisplayName())
-		return AuthDenied, errorMessage{Message: fmt.Sprintf("Your user account is disabled in %s, please contact your administrator.", b.provider.DisplayName())}
+		return AuthDenied, errorMessage{Message: "Your user account is disabled in %s, please contact your administrator.", args: []any{b.provider.DisplayName()}}
 	case 50072, 50079, 50203:
 		log.Noticef(context.Background(), "MFA enrollment required for user %q (AADSTS%d)", session.username, mfaErr.AADSTS)
 		if b.cfg.flows.DeviceAuth {
@@ -2027,9 +2418,17 @@ func (b *Broker) finishAuth(session *session, authInfo *token.AuthCachedInfo) (s
 		// offline session when the cached token already carries a provider ID, so this only defers
 		// the migration for a legacy cache whose token predates the provider ID (it then migrates on
 		// the next online login).
-		return AuthGranted, userInfoMessage{UserInfo: authInfo.UserInfo}
+		msg := userInfoMessage{UserInfo: authInfo.UserInfo, Offline: true}
+		if errors.Is(session.providerConnectionError, errProviderUnavailable) {
+			msg.Message = providerUnavailableOfflineMsg
+		} else {
+			msg.Message, msg.args = b.credentialsExpiryWarningMessage(authInfo)
+		}
+		return AuthGranted, msg
 	}
 
+	b.maybeFetchPicture(context.Background(), authInfo)
+
 	// If we are authenticating a cached user without refreshing the token, we might not have the providerID cached yet.
 	// So, before migrating it, we need to ensure that we have the information required and that the dir was not
 	// migrated yet.
@@ -2037,7 +2436,7 @@ func (b *Broker) finishAuth(session *session, authInfo *token.AuthCachedInfo) (s
 		b.ensureProviderIDCacheDir(session, authInfo.UserInfo.ProviderID)
 	}
 
-	err := token.CacheAuthInfo(session.tokenPath, authInfo)
+	err := token.CacheAuthInfo(session.tokenPath, authInfo, token.WithStorage(b.storage))
 	if err != nil && b.cfg.forceAccessCheckWithProvider {
 		log.Errorf(context.Background(), "Failed to store token: %s", err)
 		return AuthDenied, unexpectedErrMsg("failed to store token")
@@ -2046,7 +2445,11 @@ func (b *Broker) finishAuth(session *session, authInfo *token.AuthCachedInfo) (s
 		log.Errorf(context.Background(), "Failed to store token: %s. Continuing with login since provider access check is not forced.", err)
 	}
 
-	return AuthGranted, userInfoMessage{UserInfo: authInfo.UserInfo}
+	msg := userInfoMessage{UserInfo: authInfo.UserInfo}
+	if session.selectedMode == authmodes.Password {
+		msg.Message, msg.args = b.credentialsExpiryWarningMessage(authInfo)
+	}
+	return AuthGranted, msg
 }
 
 func (b *Broker) newPassword(session *session, secret string) (string, isAuthenticatedDataResponse) {
@@ -2062,7 +2465,17 @@ func (b *Broker) newPassword(session *session, secret string) (string, isAuthent
 		return AuthDenied, unexpectedErrMsg("auth info is not set")
 	}
 
-	if err := password.HashAndStorePassword(secret, session.passwordPath); err != nil {
+	if msg, args := b.passwordPolicyViolation(session, secret); msg != "" {
+		log.Noticef(context.Background(), "New local password of user %q does not comply with the password policy", session.username)
+		return AuthRetry, errorMessage{Message: msg, args: args}
+	}
+
+	if err := b.updatePasswordHistory(session, secret); err != nil {
+		log.Errorf(context.Background(), "Failed to update password history: %s", err)
+		return AuthDenied, unexpectedErrMsg("failed to update password history")
+	}
+
+	if err := password.HashAndStorePassword(secret, session.passwordPath, password.WithFIPSMode(b.cfg.fipsMode), password.WithStorage(b.storage)); err != nil {
 		log.Errorf(context.Background(), "Failed to store password: %s", err)
 		return AuthDenied, unexpectedErrMsg("failed to store password")
 	}
@@ -2097,7 +2510,7 @@ func (b *Broker) startAuthenticate(sessionID string) (context.Context, error) {
 		return nil, errors.New("authentication already running for this user session")
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(b.providerContext(context.Background()))
 	session.isAuthenticating = &isAuthenticatedCtx{ctx: ctx, cancelFunc: cancel}
 
 	if err := b.updateSession(sessionID, session); err != nil {
@@ -2129,6 +2542,7 @@ func (b *Broker) EndSession(sessionID string) error {
 	} else {
 		himmelblau.FreeMFAFlowState(session.mfaFlowActive)
 	}
+	session.browserLogin.close()
 
 	b.currentSessionsMu.Lock()
 	defer b.currentSessionsMu.Unlock()
@@ -2161,10 +2575,13 @@ func (b *Broker) CancelIsAuthenticated(sessionID string) {
 // created after the broker cache migration. username is always tried as a
 // fallback to support pre-migration caches.
 func (b *Broker) DeleteUser(username, providerID string) error {
+	// The provider ID is only unique within the issuer, which is the one of the user.
+	iss := b.issuerFor(username)
+
 	var providerIDDir string
 	if providerID != "" {
 		var err error
-		providerIDDir, err = b.userDataDir(providerID)
+		providerIDDir, err = b.userDataDir(iss, providerID)
 		if err != nil {
 			return err
 		}
@@ -2175,7 +2592,7 @@ func (b *Broker) DeleteUser(username, providerID string) error {
 	}
 
 	if username != "" {
-		userDataDir, err := b.userDataDir(username)
+		userDataDir, err := b.userDataDir(iss, username)
 		if err != nil {
 			return err
 		}
@@ -2187,7 +2604,7 @@ func (b *Broker) DeleteUser(username, providerID string) error {
 		var target string
 		if info, lstatErr := os.Lstat(userDataDir); lstatErr == nil && info.Mode()&os.ModeSymlink != 0 {
 			var evalErr error
-			target, evalErr = b.cacheSymlinkTarget(userDataDir)
+			target, evalErr = b.cacheSymlinkTarget(iss, userDataDir)
 			if evalErr != nil && !errors.Is(evalErr, os.ErrNotExist) {
 				return fmt.Errorf("refusing to follow cache compatibility symlink %q: %w", userDataDir, evalErr)
 			}
@@ -2283,9 +2700,13 @@ func (b *Broker) refreshEntraPasswordToken(ctx context.Context, session *session
 		// logging in with the cached token.
 		return nil, fmt.Errorf("provider does not implement EntraPasswordProvider; cannot refresh entra_password token for user %q", oldToken.UserInfo.Name)
 	}
-	refreshCtx, cancel := context.WithTimeout(ctx, maxRequestDuration)
-	defer cancel()
-	newTok, err := ep.RefreshEntraPasswordToken(refreshCtx, b.cfg.issuerURL, oldToken.Token.RefreshToken)
+	var newTok *oauth2.Token
+	err := b.withRetries(ctx, "refresh the token", func() (err error) {
+		refreshCtx, cancel := context.WithTimeout(b.providerContext(ctx), maxRequestDuration)
+		defer cancel()
+		newTok, err = ep.RefreshEntraPasswordToken(refreshCtx, b.cfg.issuerURL, oldToken.Token.RefreshToken)
+		return err
+	})
 	if err != nil {
 		return oldToken, err
 	}
@@ -2295,7 +2716,7 @@ func (b *Broker) refreshEntraPasswordToken(ctx context.Context, session *session
 	refreshed.Token.RefreshToken = newTok.RefreshToken
 	oldToken = &refreshed
 	cacheRotatedToken := func(reason string) {
-		if cacheErr := token.CacheAuthInfo(session.tokenPath, oldToken); cacheErr != nil {
+		if cacheErr := token.CacheAuthInfo(session.tokenPath, oldToken, token.WithStorage(b.storage)); cacheErr != nil {
 			log.Errorf(context.Background(), "Failed to store rotated refresh token after %s: %s", reason, cacheErr)
 		}
 	}
@@ -2333,6 +2754,9 @@ func (b *Broker) refreshEntraPasswordToken(ctx context.Context, session *session
 		userInfo.Gecos = oldToken.UserInfo.Gecos
 	}
 	userInfo.Groups = oldToken.UserInfo.Groups
+	userInfo.Picture = oldToken.UserInfo.Picture
+	userInfo.Env = b.sessionEnv(ctx, accessTokenClaims(newTok.AccessToken))
+	userInfo.SSHKeys = b.sshKeys(ctx, accessTokenClaims(newTok.AccessToken))
 	oldToken.UserInfo = userInfo
 
 	return oldToken, nil
@@ -2340,13 +2764,22 @@ func (b *Broker) refreshEntraPasswordToken(ctx context.Context, session *session
 
 // refreshToken refreshes the OAuth2 token and returns the updated AuthCachedInfo.
 func (b *Broker) refreshToken(ctx context.Context, session *session, oldToken *token.AuthCachedInfo) (*token.AuthCachedInfo, error) {
-	timeoutCtx, cancel := context.WithTimeout(ctx, maxRequestDuration)
-	defer cancel()
 	// Build a token carrying only the refresh token, like refreshEntraPasswordToken
 	// does: oauth2.Token.Valid() requires a non-empty AccessToken, so omitting it
 	// forces TokenSource to hit the token endpoint even if the cached token has not
 	// actually expired, without mutating the caller's cached oldToken.
-	oauthToken, err := session.oauth2Config.TokenSource(timeoutCtx, &oauth2.Token{RefreshToken: oldToken.Token.RefreshToken}).Token()
+	var oauthToken *oauth2.Token
+	err := b.withRetries(ctx, "refresh the token", func() (err error) {
+		timeoutCtx, cancel := context.WithTimeout(b.providerContext(ctx), maxRequestDuration)
+		defer cancel()
+		if b.cfg.sandboxTokenRefresh {
+			// Parse the response of the provider in a sandboxed child process, see the tokenrefresh package.
+			oauthToken, err = tokenrefresh.Refresh(timeoutCtx, tokenrefresh.NewRequest(session.oauth2Config, oldToken.Token.RefreshToken))
+		} else {
+			oauthToken, err = session.oauth2Config.TokenSource(timeoutCtx, &oauth2.Token{RefreshToken: oldToken.Token.RefreshToken}).Token()
+		}
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -2358,7 +2791,7 @@ func (b *Broker) refreshToken(ctx context.Context, session *session, oldToken *t
 	}
 	oldToken = &refreshed
 	cacheRotatedToken := func(reason string) {
-		if cacheErr := token.CacheAuthInfo(session.tokenPath, oldToken); cacheErr != nil {
+		if cacheErr := token.CacheAuthInfo(session.tokenPath, oldToken, token.WithStorage(b.storage)); cacheErr != nil {
 			log.Errorf(context.Background(), "Failed to store rotated refresh token after %s: %s", reason, cacheErr)
 		}
 	}
@@ -2378,6 +2811,7 @@ func (b *Broker) refreshToken(ctx context.Context, session *session, oldToken *t
 	t := token.NewAuthCachedInfo(oauthToken, rawIDToken, extraFields)
 	t.ProviderMetadata = oldToken.ProviderMetadata
 	t.DeviceRegistrationData = oldToken.DeviceRegistrationData
+	t.LastInteractiveAuth = oldToken.LastInteractiveAuth
 
 	t.UserInfo, err = b.getUserInfo(ctx, session, oauthToken, rawIDToken, true)
 	if err != nil {
@@ -2390,8 +2824,14 @@ func (b *Broker) refreshToken(ctx context.Context, session *session, oldToken *t
 	if t.UserInfo.Gecos == "" {
 		t.UserInfo.Gecos = oldToken.UserInfo.Gecos
 	}
+	if t.UserInfo.Picture == nil {
+		t.UserInfo.Picture = oldToken.UserInfo.Picture
+	}
 
-	t.UserInfo.Groups = oldToken.UserInfo.Groups
+	// The groups read from the claims are the ones of the new ID token, the other ones are fetched separately.
+	if _, ok := providers.ProviderAs[providers.GroupFetcher](session.issuer.provider); ok {
+		t.UserInfo.Groups = oldToken.UserInfo.Groups
+	}
 
 	return t, nil
 }
@@ -2416,36 +2856,29 @@ func (b *Broker) getUserInfo(ctx context.Context, session *session, token *oauth
 		}
 	} else {
 		var verifyErr error
-		idToken, verifyErr = session.oidcServer.Verifier(&b.oidcCfg).Verify(ctx, rawIDToken)
+		idToken, verifyErr = session.oidcServer.Verifier(&session.issuer.oidcCfg).Verify(ctx, rawIDToken)
 		if verifyErr != nil {
 			return info.User{}, fmt.Errorf("could not verify token: %w", verifyErr)
 		}
 		claims = idToken
+
+		if csr, ok := providers.ProviderAs[providers.ClaimSourcesReader](session.issuer.provider); ok {
+			claims, err = b.additionalClaims(ctx, session, token, idToken, csr)
+			if err != nil {
+				return info.User{}, err
+			}
+		}
 	}
 
-	userInfo, err = b.provider.GetUserInfo(claims, isRefresh)
+	userInfo, err = session.issuer.provider.GetUserInfo(claims, isRefresh)
 	var missingClaimErr *providerErrors.MissingClaimError
 	if rawIDToken != "" && errors.As(err, &missingClaimErr) {
 		// The ID token is missing a required claim. Try fetching the claims from the UserInfo endpoint.
 		log.Infof(context.Background(), "ID token is missing claim %q. Fetching claims from UserInfo endpoint.", missingClaimErr.Claim)
 		var userInfoClaims info.Claimer
-		userInfoClaims, err = session.oidcServer.UserInfo(ctx, oauth2.StaticTokenSource(token))
+		userInfoClaims, err = b.userInfoEndpointClaims(ctx, session, token, idToken.Subject)
 		if err != nil {
-			return info.User{}, fmt.Errorf("could not get user info from UserInfo endpoint: %w", err)
-		}
-
-		// OIDC Core §5.3.2: if the UserInfo response provides a sub claim, it MUST
-		// equal the sub from the verified ID token. A mismatch means the /userinfo
-		// response is attempting to substitute a different ProviderID and must be
-		// rejected to prevent UID takeover.
-		var subClaimCheck struct {
-			Sub string `json:"sub"`
-		}
-		if err = userInfoClaims.Claims(&subClaimCheck); err != nil {
-			return info.User{}, fmt.Errorf("could not decode UserInfo endpoint claims: %w", err)
-		}
-		if subClaimCheck.Sub != "" && subClaimCheck.Sub != idToken.Subject {
-			return info.User{}, fmt.Errorf("userinfo sub %q does not match ID token sub %q: rejecting potential identity substitution", subClaimCheck.Sub, idToken.Subject)
+			return info.User{}, err
 		}
 
 		// Merge ID token claims with UserInfo claims.
@@ -2454,24 +2887,160 @@ func (b *Broker) getUserInfo(ctx context.Context, session *session, token *oauth
 		if err != nil {
 			return info.User{}, fmt.Errorf("could not merge ID token and UserInfo endpoint claims: %w", err)
 		}
-		userInfo, err = b.provider.GetUserInfo(claims, isRefresh)
+		userInfo, err = session.issuer.provider.GetUserInfo(claims, isRefresh)
 	}
 	if err != nil {
 		return info.User{}, err
 	}
 
-	if err = b.provider.VerifyUsername(session.username, userInfo.Name); err != nil {
+	if err = session.issuer.provider.VerifyUsername(session.username, userInfo.Name); err != nil {
 		return info.User{}, fmt.Errorf("username verification failed: %w", err)
 	}
+	userInfo.ProviderID = session.issuer.providerID(userInfo.ProviderID)
 
 	// This means that home was not provided by the claims, so we need to set it to the broker default.
 	if !filepath.IsAbs(userInfo.Home) {
 		userInfo.Home = filepath.Join(b.cfg.homeBaseDir, userInfo.Home)
 	}
+	userInfo.Env = b.sessionEnv(ctx, claims)
+	userInfo.SSHKeys = b.sshKeys(ctx, claims)
+	userInfo.Picture = b.userPicture(ctx, claims)
 
 	return userInfo, nil
 }
 
+// userInfoEndpointClaims returns the claims of the UserInfo endpoint, after checking that they are the ones of the
+// subject of the ID token.
+func (b *Broker) userInfoEndpointClaims(ctx context.Context, session *session, token *oauth2.Token, subject string) (info.Claimer, error) {
+	userInfoClaims, err := session.oidcServer.UserInfo(ctx, oauth2.StaticTokenSource(token))
+	if err != nil {
+		return nil, fmt.Errorf("could not get user info from UserInfo endpoint: %w", err)
+	}
+
+	// OIDC Core §5.3.2: if the UserInfo response provides a sub claim, it MUST
+	// equal the sub from the verified ID token. A mismatch means the /userinfo
+	// response is attempting to substitute a different ProviderID and must be
+	// rejected to prevent UID takeover.
+	var subClaimCheck struct {
+		Sub string `json:"sub"`
+	}
+	if err = userInfoClaims.Claims(&subClaimCheck); err != nil {
+		return nil, fmt.Errorf("could not decode UserInfo endpoint claims: %w", err)
+	}
+	if subClaimCheck.Sub != "" && subClaimCheck.Sub != subject {
+		return nil, fmt.Errorf("userinfo sub %q does not match ID token sub %q: rejecting potential identity substitution", subClaimCheck.Sub, subject)
+	}
+	return userInfoClaims, nil
+}
+
+// sessionEnv returns the environment variables of session_env, with the values of their claims. The variables whose
+// claim is missing or isn't a string, a number or a boolean are not set.
+func (b *Broker) sessionEnv(ctx context.Context, claims info.Claimer) map[string]string {
+	if len(b.cfg.sessionEnv) == 0 {
+		return nil
+	}
+
+	var values map[string]any
+	if err := claims.Claims(&values); err != nil {
+		log.Warningf(ctx, "Could not get the claims for the session environment: %v", err)
+		return nil
+	}
+
+	env := make(map[string]string)
+	for name, claim := range b.cfg.sessionEnv {
+		var value string
+		switch v := values[claim].(type) {
+		case nil:
+			log.Debugf(ctx, "Not setting %s in the session environment: claim %q is missing", name, claim)
+			continue
+		case string:
+			value = v
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			value = strconv.FormatBool(v)
+		default:
+			log.Warningf(ctx, "Not setting %s in the session environment: claim %q is of unsupported type %T", name, claim, v)
+			continue
+		}
+		if strings.ContainsAny(value, "\x00\n") {
+			log.Warningf(ctx, "Not setting %s in the session environment: claim %q has an invalid value", name, claim)
+			continue
+		}
+		env[name] = value
+	}
+	return env
+}
+
+// sshKeys returns the SSH public keys of the claim of ssh_keys_claim, which is either a list of keys or a string with
+// one key per line. The options of the keys are removed, and the keys which can't be parsed are ignored.
+func (b *Broker) sshKeys(ctx context.Context, claims info.Claimer) []string {
+	if b.cfg.sshKeysClaim == "" {
+		return nil
+	}
+
+	var values map[string]any
+	if err := claims.Claims(&values); err != nil {
+		log.Warningf(ctx, "Could not get the claims for the SSH keys: %v", err)
+		return nil
+	}
+
+	var lines []string
+	switch v := values[b.cfg.sshKeysClaim].(type) {
+	case nil:
+		log.Debugf(ctx, "No SSH keys: claim %q is missing", b.cfg.sshKeysClaim)
+		return nil
+	case string:
+		lines = strings.Split(v, "\n")
+	case []any:
+		for _, e := range v {
+			l, ok := e.(string)
+			if !ok {
+				log.Warningf(ctx, "Ignoring SSH key of claim %q of unsupported type %T", b.cfg.sshKeysClaim, e)
+				continue
+			}
+			lines = append(lines, l)
+		}
+	default:
+		log.Warningf(ctx, "No SSH keys: claim %q is of unsupported type %T", b.cfg.sshKeysClaim, v)
+		return nil
+	}
+
+	var keys []string
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		pubKey, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(l))
+		if err != nil {
+			log.Warningf(ctx, "Ignoring invalid SSH key of claim %q: %v", b.cfg.sshKeysClaim, err)
+			continue
+		}
+		// The options, such as from= or command=, are decided by the administrators of the machine, not the provider.
+		key := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pubKey)))
+		if comment != "" {
+			key += " " + comment
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// accessTokenClaims is a [info.Claimer] for the claims of a JWT access token. The token must have been verified.
+type accessTokenClaims string
+
+func (t accessTokenClaims) Claims(v any) error {
+	parts := strings.Split(string(t), ".")
+	if len(parts) != 3 {
+		return errors.New("access token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("could not decode access token payload: %w", err)
+	}
+	return json.Unmarshal(payload, v)
+}
+
 // maybeRegisterDevice registers the device when the provider supports it and
 // register_device is enabled, updating and persisting authInfo.DeviceRegistrationData.
 // regToken is the token used to perform the registration; existingData is any
@@ -2486,7 +3055,7 @@ func (b *Broker) maybeRegisterDevice(ctx context.Context, session *session, auth
 	cleanup = func() {}
 
 	dr, ok := providers.ProviderAs[providers.DeviceRegisterer](b.provider)
-	if !ok || !b.cfg.registerDevice {
+	if !ok || !session.issuer.registerDevice {
 		return cleanup, "", nil
 	}
 
@@ -2502,7 +3071,7 @@ func (b *Broker) maybeRegisterDevice(ctx context.Context, session *session, auth
 	}
 
 	// Store the auth info, so that the device registration data is not lost if the login fails after this point.
-	if err := token.CacheAuthInfo(session.tokenPath, authInfo); err != nil {
+	if err := token.CacheAuthInfo(session.tokenPath, authInfo, token.WithStorage(b.storage)); err != nil {
 		log.Errorf(context.Background(), "Failed to store token: %s", err)
 		return cleanup, AuthDenied, unexpectedErrMsg("failed to store token")
 	}
@@ -2515,21 +3084,57 @@ func (b *Broker) getGroups(ctx context.Context, session *session, t *token.AuthC
 		return nil, errors.New("session is in offline mode")
 	}
 
-	gf, ok := providers.ProviderAs[providers.GroupFetcher](b.provider)
-	if !ok {
-		return nil, nil
+	// The providers which can't fetch the groups read them from the claims of the ID token, if they read them at all.
+	groups := t.UserInfo.Groups
+	if gf, ok := providers.ProviderAs[providers.GroupFetcher](session.issuer.provider); ok {
+		// A cached token that carries device-registration data has a PRT that must be
+		// exchanged for a Graph-scoped token (strategy 2). Derive this from the
+		// presence of that data rather than tracking a separate persisted flag.
+		var err error
+		groups, err = gf.GetGroups(ctx,
+			session.issuer.clientID,
+			session.issuer.url,
+			t.Token,
+			t.ProviderMetadata,
+			t.DeviceRegistrationData,
+			len(t.DeviceRegistrationData) > 0,
+		)
+		if err != nil {
+			return nil, err
+		}
 	}
-	// A cached token that carries device-registration data has a PRT that must be
-	// exchanged for a Graph-scoped token (strategy 2). Derive this from the
-	// presence of that data rather than tracking a separate persisted flag.
-	return gf.GetGroups(ctx,
-		b.cfg.clientID,
-		b.cfg.issuerURL,
-		t.Token,
-		t.ProviderMetadata,
-		t.DeviceRegistrationData,
-		len(t.DeviceRegistrationData) > 0,
-	)
+
+	if b.cfg.maxGroups > 0 && len(groups) > b.cfg.maxGroups {
+		log.Warningf(ctx, "User is a member of %d groups, only keeping %d of them (max_groups)", len(groups), b.cfg.maxGroups)
+		groups = limitGroups(groups, b.cfg.maxGroups, b.cfg.priorityGroups)
+	}
+	return groups, nil
+}
+
+// limitGroups returns the first maxGroups groups, ordered by priority: the local groups first, then the groups
+// matching the patterns of priorityGroups, in the order of the patterns, then the other groups by name.
+func limitGroups(groups []info.Group, maxGroups int, priorityGroups []string) []info.Group {
+	rank := func(g info.Group) int {
+		if g.UGID == "" {
+			return 0
+		}
+		for i, pattern := range priorityGroups {
+			// The patterns were validated when parsing the config.
+			if ok, _ := filepath.Match(pattern, g.Name); ok {
+				return i + 1
+			}
+		}
+		return len(priorityGroups) + 1
+	}
+
+	groups = slices.Clone(groups)
+	slices.SortStableFunc(groups, func(a, b info.Group) int {
+		if c := rank(a) - rank(b); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return groups[:maxGroups]
 }
 
 // Checks if the provided error is of type ForDisplayError. If it is, it returns the error message. Else, it returns
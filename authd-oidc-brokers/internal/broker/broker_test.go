@@ -2,6 +2,7 @@ package broker_test
 
 import (
 	"context"
+	"crypto/fips140"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"reflect"
 	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 	"unsafe"
@@ -21,9 +23,11 @@ import (
 	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/sessionmode"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/consts"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/password"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
 	providerErrors "github.com/canonical/authd/authd-oidc-brokers/internal/providers/errors"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/msentraid/himmelblau"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/secretstorage"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/testutils"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
 	"github.com/canonical/authd/internal/testutils/golden"
@@ -95,6 +99,15 @@ func (p *mockGrantRevokedProvider) IsTokenExpiredError(err *oauth2.RetrieveError
 	return err != nil && err.ErrorCode == "invalid_grant" && strings.HasPrefix(err.ErrorDescription, "AADSTS50173:")
 }
 
+type mockInteractionRequiredProvider struct {
+	*testutils.MockProvider
+	interaction providerErrors.Interaction
+}
+
+func (p *mockInteractionRequiredProvider) RequiredInteraction(*oauth2.RetrieveError) providerErrors.Interaction {
+	return p.interaction
+}
+
 var mockDeviceRegistrationData = []byte(`{"device_id":"test-device-id","cert_key":"Y2VydA==","transport_key":"dHJhbnNwb3J0","auth_value":"test-auth-value","tpm_machine_key":"dHBtLW1hY2hpbmUta2V5"}`)
 
 func (p *mockEntraPasswordProvider) InitiateEntraPasswordAuth(_ context.Context, _, _ string, _, _ string, _ []byte, _ bool) (*himmelblau.MFAFlowState, *himmelblau.MFAChallengeInfo, error) {
@@ -307,15 +320,29 @@ func TestNew(t *testing.T) {
 		issuer   string
 		clientID string
 		dataDir  string
+		fipsMode bool
+
+		sessionTokenScopes []string
+		clientCertOnly     bool
+		// issuersDomains are the domains of the additional issuers.
+		issuersDomains [][]string
 
 		wantErr bool
 	}{
 		"Successfully_create_new_broker":                              {},
 		"Successfully_create_new_even_if_can_not_connect_to_provider": {issuer: "https://notavailable"},
+		"Successfully_create_new_broker_with_session_token_scopes":    {sessionTokenScopes: []string{"openid", "email"}},
+		"Successfully_create_new_broker_with_additional_issuers":      {issuersDomains: [][]string{{"contoso.com"}, {"fabrikam.com", "fabrikam.net"}}},
 
 		"Error_if_issuer_is_not_provided":   {issuer: "-", wantErr: true},
 		"Error_if_clientID_is_not_provided": {clientID: "-", wantErr: true},
 		"Error_if_dataDir_is_not_provided":  {dataDir: "-", wantErr: true},
+		// Only succeeds if the tests are run with GODEBUG=fips140=on.
+		"Error_if_FIPS_mode_is_enabled_without_FIPS_140_crypto":  {fipsMode: true, wantErr: !fips140.Enabled()},
+		"Error_if_session_token_scope_is_not_requested_at_login": {sessionTokenScopes: []string{"openid", "api://other"}, wantErr: true},
+		"Error_if_client_certificate_is_set_without_key":         {clientCertOnly: true, wantErr: true},
+		"Error_if_additional_issuer_has_no_domains":              {issuersDomains: [][]string{nil}, wantErr: true},
+		"Error_if_domain_is_set_for_several_issuers":             {issuersDomains: [][]string{{"contoso.com"}, {"contoso.com"}}, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -343,6 +370,14 @@ func TestNew(t *testing.T) {
 			bCfg := &broker.Config{DataDir: tc.dataDir}
 			bCfg.SetIssuerURL(tc.issuer)
 			bCfg.SetClientID(tc.clientID)
+			bCfg.SetFIPSMode(tc.fipsMode)
+			bCfg.SetSessionTokenScopes(tc.sessionTokenScopes)
+			if tc.clientCertOnly {
+				bCfg.SetClientCertificate("/etc/authd/client.pem", "")
+			}
+			for i, domains := range tc.issuersDomains {
+				bCfg.AddIssuer(fmt.Sprintf("issuer%d", i), fmt.Sprintf("https://issuer%d.example.com", i), "other-client-id", domains)
+			}
 			b, err := broker.New(*bCfg, broker.LatestAPIVersion)
 			if tc.wantErr {
 				require.Error(t, err, "New should have returned an error")
@@ -400,6 +435,68 @@ func TestNewRejectsUnusableEntraPasswordWithoutGroupSource(t *testing.T) {
 	}
 }
 
+func TestPrewarm(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		discoveryHandler testutils.EndpointHandler
+		keysHandler      testutils.EndpointHandler
+
+		wantKeysRequests int32
+		wantOffline      bool
+	}{
+		"Fetch_discovery_document_and_signing_keys": {wantKeysRequests: 1},
+		"Do_not_fail_if_signing_keys_are_not_available": {
+			keysHandler:      testutils.UnavailableHandler(),
+			wantKeysRequests: 1,
+		},
+
+		"Do_not_fetch_signing_keys_if_provider_is_not_available": {
+			discoveryHandler: testutils.UnavailableHandler(),
+			wantOffline:      true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.discoveryHandler == nil {
+				tc.discoveryHandler = func(w http.ResponseWriter, r *http.Request) {
+					testutils.DefaultOpenIDHandler("http://"+r.Host)(w, r)
+				}
+			}
+			if tc.keysHandler == nil {
+				tc.keysHandler = testutils.DefaultJWKHandler()
+			}
+			var keysRequests atomic.Int32
+			b := newBrokerForTests(t, &brokerForTestConfig{
+				customHandlers: map[string]testutils.EndpointHandler{
+					"/.well-known/openid-configuration": tc.discoveryHandler,
+					"/keys": func(w http.ResponseWriter, r *http.Request) {
+						keysRequests.Add(1)
+						tc.keysHandler(w, r)
+					},
+				},
+			})
+
+			b.Prewarm(context.Background())
+			require.Equal(t, tc.wantKeysRequests, keysRequests.Load(), "Prewarm should have fetched the signing keys")
+
+			id, _ := newSessionForTests(t, b, "", "")
+			gotOffline, err := b.IsOffline(id)
+			require.NoError(t, err, "Session should have been created")
+			require.Equal(t, tc.wantOffline, gotOffline, "Session should have been created in the expected mode")
+			if tc.wantOffline {
+				return
+			}
+
+			shared, err := b.UsesSharedOIDCServer(id)
+			require.NoError(t, err, "Session should have been created")
+			require.True(t, shared, "Session should use the provider fetched by Prewarm")
+		})
+	}
+}
+
 func TestNewSession(t *testing.T) {
 	t.Parallel()
 
@@ -409,11 +506,16 @@ func TestNewSession(t *testing.T) {
 		issuerURL                    string
 		customHandlers               map[string]testutils.EndpointHandler
 		forceAccessCheckWithProvider bool
+		machineOffline               bool
 
 		wantOffline bool
 		wantErr     bool
 	}{
 		"Successfully_create_new_session": {},
+		"Creates_new_session_in_offline_mode_if_machine_is_offline": {
+			machineOffline: true,
+			wantOffline:    true,
+		},
 		"Creates_new_session_in_offline_mode_if_provider_is_not_available": {
 			customHandlers: map[string]testutils.EndpointHandler{
 				"/.well-known/openid-configuration": testutils.UnavailableHandler(),
@@ -438,6 +540,11 @@ func TestNewSession(t *testing.T) {
 			forceAccessCheckWithProvider: true,
 			wantErr:                      true,
 		},
+		"Error_when_provider_authentication_is_forced_and_machine_is_offline": {
+			machineOffline:               true,
+			forceAccessCheckWithProvider: true,
+			wantErr:                      true,
+		},
 		"Error_when_username_is_empty": {
 			emptyUsername: true,
 			wantErr:       true,
@@ -451,10 +558,13 @@ func TestNewSession(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
+			connectivity := &connectivityMock{}
+			connectivity.offline.Store(tc.machineOffline)
 			b := newBrokerForTests(t, &brokerForTestConfig{
 				customHandlers:               tc.customHandlers,
 				forceAccessCheckWithProvider: tc.forceAccessCheckWithProvider,
 				issuerURL:                    tc.issuerURL,
+				connectivity:                 connectivity,
 			})
 
 			username := tc.username
@@ -480,6 +590,171 @@ func TestNewSession(t *testing.T) {
 	}
 }
 
+func TestNewSessionRoutesUsersToTheirIssuer(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		username          string
+		issuerUnavailable bool
+
+		wantOtherIssuer bool
+		wantOffline     bool
+	}{
+		"Users_without_domain_authenticate_with_the_main_issuer":          {username: "test-user"},
+		"Users_of_other_domains_authenticate_with_the_main_issuer":        {username: "user@example.com"},
+		"Users_of_a_domain_with_the_same_suffix_use_the_main_issuer":      {username: "user@notcontoso.com"},
+		"Users_of_the_domain_authenticate_with_its_issuer":                {username: "user@contoso.com", wantOtherIssuer: true},
+		"Users_of_a_subdomain_of_the_domain_authenticate_with_its_issuer": {username: "user@eu.contoso.com", wantOtherIssuer: true},
+		"Domains_are_matched_case_insensitively":                          {username: "user@Contoso.COM", wantOtherIssuer: true},
+
+		"Session_is_offline_if_the_issuer_of_the_user_is_not_available": {
+			username:          "user@contoso.com",
+			issuerUnavailable: true,
+			wantOtherIssuer:   true,
+			wantOffline:       true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var serverOpts []testutils.ProviderServerOption
+			if tc.issuerUnavailable {
+				serverOpts = append(serverOpts, testutils.WithHandler("/.well-known/openid-configuration", testutils.UnavailableHandler()))
+			}
+			otherIssuerURL, cleanup := testutils.StartMockProviderServer("", nil, serverOpts...)
+			t.Cleanup(cleanup)
+
+			cfg := &brokerForTestConfig{}
+			cfg.AddIssuer("contoso", otherIssuerURL, "other-client-id", []string{"contoso.com"})
+			b := newBrokerForTests(t, cfg)
+
+			id, _, err := b.NewSession(tc.username, "lang", sessionmode.Login, "")
+			require.NoError(t, err, "NewSession should not have returned an error")
+
+			wantIssuerURL := cfg.IssuerURL()
+			if tc.wantOtherIssuer {
+				wantIssuerURL = otherIssuerURL
+			}
+			gotIssuerURL, err := b.SessionIssuerURL(id)
+			require.NoError(t, err, "Session should have been created")
+			require.Equal(t, wantIssuerURL, gotIssuerURL, "Session should use the issuer of the user")
+
+			wantDataDir := filepath.Join(b.DataDir(), b.NormalizedIssuer(wantIssuerURL))
+			require.Equal(t, wantDataDir, filepath.Dir(b.UserDataDirForSession(id)), "Data of the user should be cached in the directory of their issuer")
+
+			gotOffline, err := b.IsOffline(id)
+			require.NoError(t, err, "Session should have been created")
+			require.Equal(t, tc.wantOffline, gotOffline, "Session should have been created in the expected mode")
+		})
+	}
+}
+
+func TestUsersOfTheIssuersHaveDistinctProviderIDs(t *testing.T) {
+	t.Parallel()
+
+	// Both issuers return the same subject, "test-user-id", for their users.
+	otherIssuerURL, cleanup := testutils.StartMockProviderServer("", &testutils.TokenHandlerOptions{
+		IDTokenClaims: []map[string]interface{}{{"email": "test-user@contoso.com"}},
+	})
+	t.Cleanup(cleanup)
+
+	cfg := &brokerForTestConfig{issuerURL: defaultIssuerURL, allUsersAllowed: true}
+	cfg.AddIssuer("contoso", otherIssuerURL, "test-client-id", []string{"contoso.com"})
+	b := newBrokerForTests(t, cfg)
+
+	tests := map[string]struct {
+		username string
+
+		wantProviderID string
+	}{
+		"Users_of_the_main_issuer_have_their_subject_as_provider_ID": {
+			username:       "test-user@email.com",
+			wantProviderID: "test-user-id",
+		},
+		"Users_of_other_issuers_have_their_subject_prefixed_with_the_issuer_name": {
+			username:       "test-user@contoso.com",
+			wantProviderID: "contoso:test-user-id",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			sessionID, key, err := b.NewSession(tc.username, "lang", sessionmode.Login, "")
+			require.NoError(t, err, "Setup: NewSession should not have returned an error")
+
+			updateAuthModes(t, b, sessionID, authmodes.DeviceQr)
+			access, _, err := b.IsAuthenticated(sessionID, "{}")
+			require.NoError(t, err, "Device auth IsAuthenticated should not have returned an error")
+			require.Equal(t, broker.AuthNext, access, "Device auth should advance to the new password step")
+
+			updateAuthModes(t, b, sessionID, authmodes.NewPassword)
+			secret := encryptSecret(t, "new-password", key)
+			access, data, err := b.IsAuthenticated(sessionID, fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, secret))
+			require.NoError(t, err, "New password IsAuthenticated should not have returned an error")
+			require.Equal(t, broker.AuthGranted, access, "New password step should grant access")
+
+			var userInfoMsg struct {
+				UserInfo info.User `json:"userinfo"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(data), &userInfoMsg), "Failed to unmarshal user info message")
+			require.Equal(t, tc.wantProviderID, userInfoMsg.UserInfo.ProviderID, "Provider ID should be the one of the user at their issuer")
+		})
+	}
+}
+
+func TestSessionSwitchesToOnlineModeWhenMachineIsBackOnline(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		providerUnavailable bool
+		stillOffline        bool
+
+		wantOffline bool
+	}{
+		"Switch_to_online_mode": {},
+
+		"Stay_offline_if_machine_is_still_offline":  {stillOffline: true, wantOffline: true},
+		"Stay_offline_if_provider_is_not_reachable": {providerUnavailable: true, wantOffline: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var customHandlers map[string]testutils.EndpointHandler
+			if tc.providerUnavailable {
+				customHandlers = map[string]testutils.EndpointHandler{
+					"/.well-known/openid-configuration": testutils.UnavailableHandler(),
+				}
+			}
+			connectivity := &connectivityMock{}
+			connectivity.offline.Store(true)
+			b := newBrokerForTests(t, &brokerForTestConfig{customHandlers: customHandlers, connectivity: connectivity})
+
+			id, _, err := b.NewSession("test-user", "lang", sessionmode.Login, "")
+			require.NoError(t, err, "Setup: NewSession should not have returned an error")
+			offline, err := b.IsOffline(id)
+			require.NoError(t, err, "Setup: session should have been created")
+			require.True(t, offline, "Setup: session should have been created in offline mode")
+
+			connectivity.offline.Store(tc.stillOffline)
+			// The user has no local password, so no mode is available while offline.
+			layouts := []map[string]string{supportedUILayouts["form"], supportedUILayouts["qrcode"]}
+			_, err = b.GetAuthenticationModes(id, layouts)
+			if tc.wantOffline {
+				require.Error(t, err, "GetAuthenticationModes should have returned an error while offline")
+			} else {
+				require.NoError(t, err, "GetAuthenticationModes should not have returned an error")
+			}
+
+			offline, err = b.IsOffline(id)
+			require.NoError(t, err, "IsOffline should not have returned an error")
+			require.Equal(t, tc.wantOffline, offline, "Session should be in the expected mode")
+		})
+	}
+}
+
 // TestNewSessionRecoversFromDanglingCacheSymlink verifies that when the username cache path is a
 // dangling compatibility symlink (its provider ID-keyed target was removed, e.g. by a partial
 // DeleteUser), NewSession removes the broken link and falls back to a fresh username-based cache
@@ -641,6 +916,11 @@ var supportedUILayouts = map[string]map[string]string{
 		"renders_qrcode": "false",
 	},
 
+	"browser": {
+		"type": "browser",
+		"wait": "true",
+	},
+
 	"newpassword": {
 		"type":  "newpassword",
 		"entry": "chars_password",
@@ -666,6 +946,7 @@ func TestGetAuthenticationModes(t *testing.T) {
 		deviceAuthUnsupported              bool
 		registerDevice                     bool
 		providerSupportsDeviceRegistration bool
+		browserFlowEnabled                 bool
 
 		wantErr   bool
 		wantModes []string
@@ -689,6 +970,22 @@ func TestGetAuthenticationModes(t *testing.T) {
 			wantModes:      []string{authmodes.DeviceQr},
 		},
 
+		// --- Browser login ---
+		"Get_password_browser_and_device_auth_qr_if_browser_flow_is_enabled": {
+			browserFlowEnabled: true,
+			token:              &tokenOptions{},
+			supportedLayouts:   []string{"form", "browser", "qrcode", "newpassword"},
+			wantModes:          []string{authmodes.Password, authmodes.Browser, authmodes.DeviceQr},
+		},
+		"Get_only_device_auth_qr_if_browser_flow_is_enabled_but_not_supported_by_the_client": {
+			browserFlowEnabled: true,
+			wantModes:          []string{authmodes.DeviceQr},
+		},
+		"Get_only_device_auth_qr_if_browser_flow_is_disabled": {
+			supportedLayouts: []string{"form", "browser", "qrcode", "newpassword"},
+			wantModes:        []string{authmodes.DeviceQr},
+		},
+
 		// --- Next auth mode ---
 		"Get_only_newpassword_if_next_auth_mode_is_newpassword": {
 			nextAuthMode: authmodes.NewPassword,
@@ -839,6 +1136,7 @@ func TestGetAuthenticationModes(t *testing.T) {
 			cfg := &brokerForTestConfig{
 				registerDevice:             tc.registerDevice,
 				supportsDeviceRegistration: tc.providerSupportsDeviceRegistration,
+				browserFlowEnabled:         tc.browserFlowEnabled,
 			}
 			if tc.providerAddress == "" {
 				// Use the default provider URL if no address is provided.
@@ -903,6 +1201,52 @@ func TestGetAuthenticationModes(t *testing.T) {
 	}
 }
 
+func TestLocalizedMessages(t *testing.T) {
+	t.Parallel()
+
+	messagesDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(messagesDir, "fr.po"), []byte(`
+msgid "Local password"
+msgstr "Mot de passe local"
+
+msgid "Enter your password"
+msgstr "Saisissez votre mot de passe"
+
+msgid "Incorrect password, please try again."
+msgstr "Mot de passe incorrect, veuillez réessayer."
+`), 0600)
+	require.NoError(t, err, "Setup: could not write the messages overrides")
+
+	b := newBrokerForTests(t, &brokerForTestConfig{
+		Config:    broker.Config{MessagesDir: messagesDir},
+		issuerURL: defaultIssuerURL,
+	})
+
+	sessionID, key, err := b.NewSession("test-user@email.com", "fr_FR.UTF-8", sessionmode.Login, "")
+	require.NoError(t, err, "Setup: NewSession should not have returned an error")
+	generateAndStoreCachedInfo(t, tokenOptions{}, b.TokenPathForSession(sessionID))
+	err = password.HashAndStorePassword("password", b.PasswordFilepathForSession(sessionID))
+	require.NoError(t, err, "Setup: HashAndStorePassword should not have returned an error")
+
+	modes, err := b.GetAuthenticationModes(sessionID, supportedLayouts)
+	require.NoError(t, err, "GetAuthenticationModes should not have returned an error")
+	require.Contains(t, modes, map[string]string{"id": authmodes.Password, "label": "Mot de passe local"},
+		"GetAuthenticationModes should have translated the label of the password mode")
+	require.Contains(t, modes, map[string]string{"id": authmodes.DeviceQr, "label": authmodes.Label[authmodes.DeviceQr]},
+		"GetAuthenticationModes should have kept the labels without translation")
+
+	layout, err := b.SelectAuthenticationMode(sessionID, authmodes.Password)
+	require.NoError(t, err, "SelectAuthenticationMode should not have returned an error")
+	require.Equal(t, "Saisissez votre mot de passe", layout["label"], "SelectAuthenticationMode should have translated the label")
+
+	authData := fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, encryptSecret(t, "wrong password", key))
+	access, data, err := b.IsAuthenticated(sessionID, authData)
+	require.NoError(t, err, "IsAuthenticated should not have returned an error")
+	require.Equal(t, broker.AuthRetry, access, "IsAuthenticated should have asked to retry")
+	require.JSONEq(t, `{"message":"Mot de passe incorrect, veuillez réessayer."}`, data,
+		"IsAuthenticated should have translated the error message")
+}
+
 var supportedLayouts = []map[string]string{
 	supportedUILayouts["form"],
 	supportedUILayouts["qrcode"],
@@ -1031,6 +1375,10 @@ func TestIsAuthenticated(t *testing.T) {
 		allUsersAllowed                    bool
 		extraGroups                        []string
 		ownerExtraGroups                   []string
+		maxGroups                          int
+		priorityGroups                     []string
+		sessionEnv                         map[string]string
+		sshKeysClaim                       string
 		providerSupportsDeviceRegistration bool
 		registerDevice                     bool
 		requireNameClaimOnInitialAuth      bool
@@ -1244,6 +1592,47 @@ func TestIsAuthenticated(t *testing.T) {
 			ownerExtraGroups: []string{"owner-group"},
 			wantGroups:       []info.Group{{Name: "remote-group"}, {Name: "extra-group"}, {Name: "owner-group"}},
 		},
+		"Groups_limited_by_max_groups_keep_local_and_priority_groups_first": {
+			firstMode: authmodes.Password,
+			token:     &tokenOptions{},
+			groupsReturnedByProvider: []info.Group{
+				{Name: "c-group", UGID: "c-id"},
+				{Name: "a-group", UGID: "a-id"},
+				{Name: "dev-team", UGID: "dev-id"},
+				{Name: "local-group"},
+				{Name: "b-group", UGID: "b-id"},
+			},
+			extraGroups:    []string{"extra-group"},
+			maxGroups:      3,
+			priorityGroups: []string{"dev-*"},
+			wantGroups:     []info.Group{{Name: "local-group"}, {Name: "dev-team", UGID: "dev-id"}, {Name: "a-group", UGID: "a-id"}, {Name: "extra-group"}},
+		},
+		"Authenticating_with_password_sets_session_environment_from_claims": {
+			firstMode: authmodes.Password,
+			token:     &tokenOptions{expired: true},
+			sessionEnv: map[string]string{
+				"USER_EMAIL":     "email",
+				"EMAIL_VERIFIED": "email_verified",
+				"NOT_SET":        "missing-claim",
+			},
+		},
+		"Authenticating_with_password_sets_ssh_keys_from_claims": {
+			firstMode:    authmodes.Password,
+			token:        &tokenOptions{expired: true},
+			sshKeysClaim: "ssh_public_keys",
+			customHandlers: map[string]testutils.EndpointHandler{
+				"/token": testutils.TokenHandler("http://127.0.0.1:31318", &testutils.TokenHandlerOptions{
+					IDTokenClaims: []map[string]interface{}{
+						{"ssh_public_keys": []any{
+							`from="10.0.0.1" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAICAjYInYNym6SDqrfs4E1smDBXnnCmcKOISOubs679Cy laptop`,
+							"not-a-key",
+							42,
+						}},
+					},
+				}),
+			},
+			address: "127.0.0.1:31318",
+		},
 		"Owner_extra_groups_configured_but_user_does_not_become_owner": {
 			firstMode:                authmodes.Password,
 			token:                    &tokenOptions{},
@@ -1324,6 +1713,23 @@ func TestIsAuthenticated(t *testing.T) {
 			},
 		},
 
+		"Error_when_mode_is_password_and_token_refresh_is_answered_by_captive_portal_with_forced_provider_auth": {
+			firstMode:                    authmodes.Password,
+			token:                        &tokenOptions{expired: true},
+			forceAccessCheckWithProvider: true,
+			customHandlers: map[string]testutils.EndpointHandler{
+				"/token": testutils.CaptivePortalHandler(http.StatusOK),
+			},
+		},
+		"Authenticating_with_password_when_token_refresh_is_answered_by_captive_portal": {
+			firstMode: authmodes.Password,
+			token:     &tokenOptions{expired: true},
+			customHandlers: map[string]testutils.EndpointHandler{
+				"/token": testutils.CaptivePortalHandler(http.StatusOK),
+			},
+			wantOffline: true,
+		},
+
 		"Error_when_mode_is_qrcode_and_link_expires": {
 			customHandlers: map[string]testutils.EndpointHandler{
 				"/device_auth": testutils.ExpiryDeviceAuthHandler(),
@@ -1339,6 +1745,11 @@ func TestIsAuthenticated(t *testing.T) {
 				"/token": testutils.HangingHandler(broker.MaxRequestDuration + 1),
 			},
 		},
+		"Error_when_mode_is_qrcode_and_token_request_is_answered_by_captive_portal": {
+			customHandlers: map[string]testutils.EndpointHandler{
+				"/token": testutils.CaptivePortalHandler(http.StatusOK),
+			},
+		},
 		"Error_when_mode_is_link_code_and_link_expires": {
 			customHandlers: map[string]testutils.EndpointHandler{
 				"/device_auth": testutils.ExpiryDeviceAuthHandler(),
@@ -1445,7 +1856,8 @@ func TestIsAuthenticated(t *testing.T) {
 		},
 
 		// NoGroupFetcher: when the provider does not implement GroupFetcher, getGroups
-		// returns nil and the user is authenticated without remote groups.
+		// returns the groups read from the claims, which the mock doesn't read, and the
+		// user is authenticated without remote groups.
 		"Authenticating_with_password_when_provider_has_no_group_fetcher": {
 			firstMode:                 authmodes.Password,
 			token:                     &tokenOptions{},
@@ -1520,6 +1932,10 @@ func TestIsAuthenticated(t *testing.T) {
 				forceAccessCheckWithProvider:  tc.forceAccessCheckWithProvider,
 				extraGroups:                   tc.extraGroups,
 				ownerExtraGroups:              tc.ownerExtraGroups,
+				maxGroups:                     tc.maxGroups,
+				priorityGroups:                tc.priorityGroups,
+				sessionEnv:                    tc.sessionEnv,
+				sshKeysClaim:                  tc.sshKeysClaim,
 				supportsDeviceRegistration:    tc.providerSupportsDeviceRegistration,
 				requireNameClaimOnInitialAuth: tc.requireNameClaimOnInitialAuth,
 				registerDevice:                tc.registerDevice,
@@ -2755,6 +3171,116 @@ func TestIsAuthenticatedPasswordGrantRevokedInvalidatesCachedCredentials(t *test
 	require.ElementsMatch(t, []string{authmodes.DeviceQr}, modeIDs)
 }
 
+// TestIsAuthenticatedRefreshRequiringInteraction verifies that a returning login whose token refresh is rejected
+// because the user has to interact with the provider tells the user what to do, and redirects them to the device code
+// flow when it's enabled. An expired password starts the flow which resets the local password too.
+func TestIsAuthenticatedRefreshRequiringInteraction(t *testing.T) {
+	t.Parallel()
+
+	const correctPassword = "password"
+
+	tests := map[string]struct {
+		interaction            providerErrors.Interaction
+		tokenError             string
+		deviceAuthFlowDisabled bool
+
+		wantAccess        string
+		wantMessage       string
+		wantPasswordReset bool
+		wantNextAuthModes []string
+	}{
+		"Expired_password_redirects_to_password_reset_flow": {
+			interaction:       providerErrors.InteractionPasswordExpired,
+			wantAccess:        broker.AuthNext,
+			wantMessage:       "Your password in the identity provider has expired. Change it using the device code flow, then choose a new local password.",
+			wantPasswordReset: true,
+			wantNextAuthModes: []string{authmodes.Device, authmodes.DeviceQr},
+		},
+		"Required_MFA_redirects_to_device_code_flow": {
+			interaction:       providerErrors.InteractionMFA,
+			wantAccess:        broker.AuthNext,
+			wantMessage:       "Multi-factor authentication is required by the identity provider. Please complete it using the device code flow.",
+			wantNextAuthModes: []string{authmodes.Device, authmodes.DeviceQr},
+		},
+		"Interaction_required_error_code_redirects_to_device_code_flow": {
+			tokenError:        `{"error":"interaction_required","error_description":"The user must sign in interactively."}`,
+			wantAccess:        broker.AuthNext,
+			wantMessage:       "An interactive sign-in is required by the identity provider. Please authenticate using the device code flow.",
+			wantNextAuthModes: []string{authmodes.Device, authmodes.DeviceQr},
+		},
+
+		"Expired_password_is_denied_if_device_code_flow_is_disabled": {
+			interaction:            providerErrors.InteractionPasswordExpired,
+			deviceAuthFlowDisabled: true,
+			wantAccess:             broker.AuthDenied,
+			wantMessage:            "Your password in the identity provider has expired. Please change it and try again.",
+		},
+		"Required_MFA_is_denied_if_device_code_flow_is_disabled": {
+			interaction:            providerErrors.InteractionMFA,
+			deviceAuthFlowDisabled: true,
+			wantAccess:             broker.AuthDenied,
+			wantMessage:            "Multi-factor authentication is required by the identity provider, but the device code flow is disabled. Please contact your administrator.",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.tokenError == "" {
+				tc.tokenError = `{"error":"invalid_grant","error_description":"The user must interact with the provider."}`
+			}
+			var provider providers.Provider = &testutils.MockProvider{}
+			if tc.interaction != providerErrors.NoInteraction {
+				provider = &mockInteractionRequiredProvider{MockProvider: &testutils.MockProvider{}, interaction: tc.interaction}
+			}
+
+			b := newBrokerForTests(t, &brokerForTestConfig{
+				Config:                 broker.Config{DataDir: t.TempDir()},
+				provider:               provider,
+				deviceAuthFlowDisabled: tc.deviceAuthFlowDisabled,
+				ownerAllowed:           true,
+				firstUserBecomesOwner:  true,
+				customHandlers: map[string]testutils.EndpointHandler{
+					"/token": testutils.ErrorResponseHandler(http.StatusBadRequest, tc.tokenError),
+				},
+			})
+
+			sessionID, key := newSessionForTests(t, b, "test-user@email.com", sessionmode.Login)
+			generateAndStoreCachedInfo(t, tokenOptions{}, b.TokenPathForSession(sessionID))
+			err := password.HashAndStorePassword(correctPassword, b.PasswordFilepathForSession(sessionID))
+			require.NoError(t, err, "Setup: HashAndStorePassword should not have returned an error")
+
+			updateAuthModes(t, b, sessionID, authmodes.Password)
+			authData := fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, encryptSecret(t, correctPassword, key))
+
+			access, data, err := b.IsAuthenticated(sessionID, authData)
+			require.NoError(t, err, "IsAuthenticated should not have returned an error")
+			require.Equal(t, tc.wantAccess, access, "IsAuthenticated returned an unexpected access")
+
+			var msg struct {
+				Message       string `json:"message"`
+				PasswordReset bool   `json:"password_reset"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(data), &msg), "IsAuthenticated returned data must be valid JSON")
+			require.Equal(t, tc.wantMessage, msg.Message, "IsAuthenticated returned an unexpected message")
+			require.Equal(t, tc.wantPasswordReset, msg.PasswordReset, "IsAuthenticated returned an unexpected password reset flag")
+
+			if tc.wantNextAuthModes != nil {
+				require.Equal(t, tc.wantNextAuthModes, b.GetNextAuthModes(sessionID), "Unexpected next authentication modes")
+			}
+			if !tc.wantPasswordReset {
+				return
+			}
+
+			err = b.SetAvailableMode(sessionID, authmodes.NewPassword)
+			require.NoError(t, err, "Setup: SetAvailableMode should not have returned an error")
+			layout, err := b.SelectAuthenticationMode(sessionID, authmodes.NewPassword)
+			require.NoError(t, err, "SelectAuthenticationMode should not have returned an error")
+			require.Equal(t, "Choose a new local password", layout["label"], "The new password should be asked as part of the reset")
+		})
+	}
+}
+
 // TestIsAuthenticatedPasswordEntraTokenFallsBackToCachedGroupsOnGroupFetchError
 // verifies that on a returning login with a cached Entra password + MFA token, a
 // group-fetch failure — even a user-displayable ForDisplayError such as a missing
@@ -3695,6 +4221,118 @@ func TestIsAuthenticatedFIDOMethodRoutesToDevice(t *testing.T) {
 	}
 }
 
+// TestIsAuthenticatedFIDOMethodWithSecurityKey verifies that a FidoKey MFA
+// challenge is offered to be signed with a security key, and that the assertion
+// returned by the client is passed to AcquireTokenByMFAFlow.
+func TestIsAuthenticatedFIDOMethodWithSecurityKey(t *testing.T) {
+	t.Parallel()
+
+	validAssertion := `{"id":"Y3JlZC1pZA","rawId":"Y3JlZC1pZA","type":"public-key","response":{"authenticatorData":"YXV0aC1kYXRh","clientDataJSON":"Y2xpZW50LWRhdGE","signature":"c2lnbmF0dXJl"}}`
+
+	tests := map[string]struct {
+		assertion          string
+		deviceAuthDisabled bool
+
+		wantNextModes     []string
+		wantAccess        string
+		wantChallengeData []string
+	}{
+		"Granted_with_a_valid_assertion": {
+			assertion:         validAssertion,
+			wantNextModes:     []string{authmodes.EntraMFAFIDO, authmodes.Device, authmodes.DeviceQr},
+			wantAccess:        broker.AuthGranted,
+			wantChallengeData: []string{`{"id":"Y3JlZC1pZA","rawId":"Y3JlZC1pZA","type":"public-key","response":{"authenticatorData":"YXV0aC1kYXRh","clientDataJSON":"Y2xpZW50LWRhdGE","signature":"c2lnbmF0dXJl"},"extensions":{}}`},
+		},
+		"Granted_without_the_device_code_flow": {
+			assertion:          validAssertion,
+			deviceAuthDisabled: true,
+			wantNextModes:      []string{authmodes.EntraMFAFIDO},
+			wantAccess:         broker.AuthGranted,
+		},
+
+		"Retry_if_the_assertion_is_not_JSON": {
+			assertion:     "not-json",
+			wantNextModes: []string{authmodes.EntraMFAFIDO, authmodes.Device, authmodes.DeviceQr},
+			wantAccess:    broker.AuthRetry,
+		},
+		"Retry_if_the_assertion_has_no_signature": {
+			assertion:     `{"id":"Y3JlZC1pZA","rawId":"Y3JlZC1pZA","type":"public-key","response":{"authenticatorData":"YXV0aC1kYXRh","clientDataJSON":"Y2xpZW50LWRhdGE"}}`,
+			wantNextModes: []string{authmodes.EntraMFAFIDO, authmodes.Device, authmodes.DeviceQr},
+			wantAccess:    broker.AuthRetry,
+		},
+		"Retry_if_the_assertion_is_not_base64url_encoded": {
+			assertion:     `{"id":"Y3JlZC1pZA","rawId":"Y3JlZC1pZA","type":"public-key","response":{"authenticatorData":"YXV0aC1kYXRh","clientDataJSON":"Y2xpZW50LWRhdGE","signature":"not base64!"}}`,
+			wantNextModes: []string{authmodes.EntraMFAFIDO, authmodes.Device, authmodes.DeviceQr},
+			wantAccess:    broker.AuthRetry,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			username := "test-user@email.com"
+			mfaAuthInfo := generateCachedInfo(t, tokenOptions{username: username, issuer: defaultIssuerURL})
+			provider := &mockEntraPasswordProvider{
+				MockProvider: &testutils.MockProvider{},
+				flowState:    &himmelblau.MFAFlowState{},
+				challengeInfo: &himmelblau.MFAChallengeInfo{
+					Message:       "Use your security key",
+					Method:        "FidoKey",
+					FIDOChallenge: "Y2hhbGxlbmdl",
+					FIDOAllowList: []string{"Y3JlZC1pZA"},
+				},
+				mfaTokenResult: newMFATokenResult(mfaAuthInfo.Token),
+			}
+
+			b := newBrokerForTests(t, &brokerForTestConfig{
+				Config:                 broker.Config{DataDir: t.TempDir()},
+				ownerAllowed:           true,
+				firstUserBecomesOwner:  true,
+				provider:               provider,
+				issuerURL:              defaultIssuerURL,
+				deviceAuthFlowDisabled: tc.deviceAuthDisabled,
+				registerDevice:         tc.deviceAuthDisabled,
+			})
+
+			sessionID, key := newSessionForTests(t, b, username, sessionmode.Login)
+			updateAuthModes(t, b, sessionID, authmodes.EntraPassword)
+
+			passwordAuthData := fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, encryptSecret(t, "password", key))
+			access, data, err := b.IsAuthenticated(sessionID, passwordAuthData)
+			require.NoError(t, err)
+			require.Equal(t, broker.AuthNext, access)
+			require.Equal(t, "{}", data, "AuthNext after password should carry no message")
+			require.Equal(t, tc.wantNextModes, b.GetNextAuthModes(sessionID))
+
+			err = b.SetAvailableMode(sessionID, authmodes.EntraMFAFIDO)
+			require.NoError(t, err, "Setup: SetAvailableMode should not have returned an error")
+			layout, err := b.SelectAuthenticationMode(sessionID, authmodes.EntraMFAFIDO)
+			require.NoError(t, err, "SelectAuthenticationMode should not have returned an error")
+			require.Equal(t, "webauthn", layout["type"], "Layout should be a WebAuthn one")
+			require.JSONEq(t, `{"challenge":"Y2hhbGxlbmdl","rpId":"login.microsoft.com","allowCredentials":[{"type":"public-key","id":"Y3JlZC1pZA"}],"userVerification":"preferred","timeout":60000}`,
+				layout["content"], "Layout content should be the WebAuthn request")
+
+			assertionAuthData, err := json.Marshal(map[string]string{broker.AuthDataWebAuthnAssertion: tc.assertion})
+			require.NoError(t, err, "Setup: could not encode the authentication data")
+			access, data, err = b.IsAuthenticated(sessionID, string(assertionAuthData))
+			require.NoError(t, err)
+			require.Equal(t, tc.wantAccess, access, "IsAuthenticated should have returned the expected access, data: %s", data)
+
+			if tc.wantAccess != broker.AuthGranted {
+				require.Empty(t, provider.recordedChallengeData, "An invalid assertion must not be sent to the provider")
+				require.NoFileExists(t, b.PasswordFilepathForSession(sessionID))
+				return
+			}
+			require.Equal(t, []int{0}, provider.recordedPollAttempts, "The assertion must be submitted with poll_attempt=0")
+			if tc.wantChallengeData != nil {
+				require.Equal(t, tc.wantChallengeData, provider.recordedChallengeData, "The assertion must be passed as auth_data")
+			}
+			require.FileExists(t, b.PasswordFilepathForSession(sessionID), "Entra MFA completion should cache the offline password")
+		})
+	}
+}
+
 // TestIsAuthenticatedEntraMFACodeDenied verifies that a denied code submission
 // returns AuthDenied.
 func TestIsAuthenticatedEntraMFACodeDenied(t *testing.T) {
@@ -4645,6 +5283,48 @@ func TestEnsureProviderIDCacheDir(t *testing.T) {
 	}
 }
 
+func TestEnsureProviderIDCacheDirKeepsSealedSecrets(t *testing.T) {
+	t.Parallel()
+
+	const (
+		username   = "user@example.com"
+		providerID = "provider-id-123"
+	)
+
+	tests := map[string]struct {
+		createProviderIDDir bool
+	}{
+		"Migrates_the_sealed_secrets_of_the_username_directory":     {},
+		"Consolidates_the_sealed_secrets_of_the_username_directory": {createProviderIDDir: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newBrokerForTests(t, &brokerForTestConfig{issuerURL: defaultIssuerURL, secretStorage: secretstorage.Keyring})
+			storage, err := secretstorage.New(secretstorage.Keyring, b.DataDir())
+			require.NoError(t, err, "Setup: could not create the secret storage of the broker")
+
+			usernameDir, err := b.UserDataDir(username)
+			require.NoError(t, err, "Setup: deriving the username data dir should not fail")
+			providerIDDir, err := b.UserDataDir(providerID)
+			require.NoError(t, err, "Setup: deriving the provider ID data dir should not fail")
+			require.NoError(t, os.MkdirAll(usernameDir, 0700), "Setup: creating the username dir")
+			if tc.createProviderIDDir {
+				require.NoError(t, os.MkdirAll(providerIDDir, 0700), "Setup: creating the provider ID dir")
+			}
+			require.NoError(t, storage.WriteFile(filepath.Join(usernameDir, "password"), []byte("hash")), "Setup: sealing the password hash")
+
+			got := b.EnsureProviderIDCacheDir(username, usernameDir, providerID)
+			require.Equal(t, providerIDDir, got.UserDataDir, "Session data dir should be the provider ID dir")
+
+			hash, err := storage.ReadFile(got.PasswordPath)
+			require.NoError(t, err, "The sealed secret should be readable at its new path")
+			require.Equal(t, []byte("hash"), hash, "The sealed secret should have been preserved")
+		})
+	}
+}
+
 // TestCompatibilitySymlinkSurvivesIssuerTreeMove verifies that the compatibility
 // symlink created by ensureCompatibilitySymlink is stored as a relative path and
 // therefore continues to resolve correctly after the entire issuer cache tree is
@@ -0,0 +1,185 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/canonical/authd/log"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// browserLoginTimeout is how long the user has to log in with their web browser before the redirect URI is not
+	// served anymore.
+	browserLoginTimeout = 10 * time.Minute
+	// browserLoginAddress is where the redirect URI is served. As recommended for native apps by RFC 8252, it's on the
+	// loopback interface and the port is chosen by the system, so the providers must allow any port.
+	browserLoginAddress = "127.0.0.1:0"
+)
+
+// browserLogin is a login of the user with the authorization code flow in a web browser running on the same machine.
+// The broker serves the redirect URI, to which the provider sends the browser with the authorization code once the
+// user logged in. It's shared by the copies of the session, so that the one waiting for the code can close it.
+type browserLogin struct {
+	authURL     string
+	redirectURL string
+	state       string
+	verifier    string
+	expiry      time.Time
+
+	server *http.Server
+	timer  *time.Timer
+	// result receives the authorization codes, or the errors, with which the browser is redirected.
+	result    chan browserLoginResult
+	closeOnce sync.Once
+}
+
+type browserLoginResult struct {
+	code string
+	err  error
+}
+
+// startBrowserLogin serves the redirect URI of a new browser login for the session. The user can open its
+// authorization URL several times, for example if the provider rejected the first attempt.
+func (b *Broker) startBrowserLogin(session *session) (*browserLogin, error) {
+	lis, err := net.Listen("tcp", browserLoginAddress)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen for the redirection of the browser: %v", err)
+	}
+
+	l := &browserLogin{
+		redirectURL: fmt.Sprintf("http://%s/", lis.Addr()),
+		// The state only has to be unguessable, as the verifier.
+		state:    oauth2.GenerateVerifier(),
+		verifier: oauth2.GenerateVerifier(),
+		expiry:   time.Now().Add(browserLoginTimeout),
+		result:   make(chan browserLoginResult, 1),
+	}
+
+	authOpts := append([]oauth2.AuthCodeOption{oauth2.S256ChallengeOption(l.verifier)}, b.provider.AuthOptions()...)
+	if session.username != "" {
+		authOpts = append(authOpts, oauth2.SetAuthURLParam("login_hint", session.username))
+	}
+	if locales := uiLocales(session.lang); locales != "" {
+		authOpts = append(authOpts, oauth2.SetAuthURLParam("ui_locales", locales))
+	}
+	cfg := session.oauth2Config
+	cfg.RedirectURL = l.redirectURL
+	l.authURL = cfg.AuthCodeURL(l.state, authOpts...)
+
+	lang := session.lang
+	l.server = &http.Server{
+		Handler:           http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { l.handleRedirect(w, r, b, lang) }),
+		ReadHeaderTimeout: maxRequestDuration,
+	}
+	go func() {
+		if err := l.server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Warningf(context.Background(), "Could not serve the redirect URI of the browser login: %v", err)
+		}
+	}()
+	l.timer = time.AfterFunc(browserLoginTimeout, l.close)
+
+	log.Debugf(context.Background(), "Serving the redirect URI %q of the browser login", l.redirectURL)
+	return l, nil
+}
+
+// handleRedirect passes the authorization code of the redirection of the browser to the broker, and tells the user
+// the result of the login.
+func (l *browserLogin) handleRedirect(w http.ResponseWriter, r *http.Request, b *Broker, lang string) {
+	query := r.URL.Query()
+	// Only the browser opening the authorization URL knows the state, other local processes can't inject a code.
+	if query.Get("state") != l.state {
+		http.Error(w, "Invalid login request", http.StatusBadRequest)
+		return
+	}
+
+	res := browserLoginResult{code: query.Get("code")}
+	if e := query.Get("error"); e != "" {
+		res.err = fmt.Errorf("the provider returned an error: %s: %s", e, query.Get("error_description"))
+	} else if res.code == "" {
+		res.err = errors.New("the provider returned no authorization code")
+	}
+	// The redirections which happen when nobody is waiting are dropped, except the first one.
+	select {
+	case l.result <- res:
+	default:
+	}
+
+	msg := "You can close this window and return to the login screen."
+	if res.err != nil {
+		msg = "The login failed. You can close this window and try again."
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = io.WriteString(w, b.messages.Get(lang, msg))
+}
+
+// wait waits for the browser to be redirected and returns the authorization code.
+func (l *browserLogin) wait(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithDeadline(ctx, l.expiry)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res := <-l.result:
+		return res.code, res.err
+	}
+}
+
+// close stops serving the redirect URI. It can be called on a nil login.
+func (l *browserLogin) close() {
+	if l == nil {
+		return
+	}
+	l.closeOnce.Do(func() {
+		l.timer.Stop()
+		if err := l.server.Close(); err != nil {
+			log.Warningf(context.Background(), "Could not stop serving the redirect URI of the browser login: %v", err)
+		}
+	})
+}
+
+func (b *Broker) browserAuth(ctx context.Context, session *session) (string, isAuthenticatedDataResponse) {
+	login := session.browserLogin
+	if login == nil {
+		log.Error(context.Background(), "browser login is not started")
+		return AuthDenied, unexpectedErrMsg("browser login is not started")
+	}
+
+	log.Debug(ctx, "Waiting for the redirection of the browser...")
+	code, err := login.wait(ctx)
+	if err != nil {
+		log.Errorf(context.Background(), "Error waiting for the login in the browser: %s", err)
+		return AuthRetry, errorMessage{Message: "The login in the web browser failed. Please try again."}
+	}
+
+	cfg := session.oauth2Config
+	cfg.RedirectURL = login.redirectURL
+	// The exchange is not retried, as the provider may have consumed the code of a request which timed out.
+	exchangeCtx, cancel := context.WithTimeout(ctx, maxRequestDuration)
+	defer cancel()
+	t, err := cfg.Exchange(exchangeCtx, code, append([]oauth2.AuthCodeOption{oauth2.VerifierOption(login.verifier)}, b.provider.AuthOptions()...)...)
+	if err != nil {
+		log.Errorf(context.Background(), "Error exchanging the authorization code for a token: %s", err)
+		if b.behindCaptivePortal(err) {
+			return AuthRetry, errorMessage{Message: captivePortalOrPasswordMsg}
+		}
+		return AuthRetry, errorMessage{Message: "Error retrieving access token. Please try again."}
+	}
+	log.Debug(ctx, "Exchanged authorization code for token.")
+
+	login.close()
+	session.browserLogin = nil
+
+	if t.RefreshToken == "" {
+		log.Warningf(context.Background(), "No refresh token returned for user during browser login. You might have to add the 'offline_access' scope to the 'extra_scopes' setting.")
+	}
+
+	return b.authenticateWithProviderToken(ctx, session, t)
+}
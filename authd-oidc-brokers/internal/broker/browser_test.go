@@ -0,0 +1,99 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/authmodes"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrowserLogin(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		redirectQuery  url.Values
+		customHandlers map[string]testutils.EndpointHandler
+		endSession     bool
+
+		wantAccess string
+	}{
+		"Successfully_log_in_with_the_browser": {
+			redirectQuery: url.Values{"code": {"some-code"}},
+			wantAccess:    broker.AuthNext,
+		},
+
+		"Retry_if_the_provider_returns_an_error": {
+			redirectQuery: url.Values{"error": {"access_denied"}, "error_description": {"The user denied the access"}},
+			wantAccess:    broker.AuthRetry,
+		},
+		"Retry_if_the_provider_returns_no_code": {
+			redirectQuery: url.Values{},
+			wantAccess:    broker.AuthRetry,
+		},
+		"Retry_if_the_code_can_not_be_exchanged": {
+			redirectQuery:  url.Values{"code": {"some-code"}},
+			customHandlers: map[string]testutils.EndpointHandler{"/token": testutils.BadRequestHandler()},
+			wantAccess:     broker.AuthRetry,
+		},
+		"Stop_serving_the_redirect_URI_when_the_session_ends": {endSession: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &brokerForTestConfig{browserFlowEnabled: true, allUsersAllowed: true, customHandlers: tc.customHandlers}
+			if tc.customHandlers == nil {
+				cfg.issuerURL = defaultIssuerURL
+			}
+			b := newBrokerForTests(t, cfg)
+			sessionID, _ := newSessionForTests(t, b, "", "")
+
+			_, err := b.GetAuthenticationModes(sessionID, []map[string]string{supportedUILayouts["browser"]})
+			require.NoError(t, err, "Setup: GetAuthenticationModes should not have returned an error")
+			layout, err := b.SelectAuthenticationMode(sessionID, authmodes.Browser)
+			require.NoError(t, err, "SelectAuthenticationMode should not have returned an error")
+			require.Equal(t, "browser", layout["type"], "Layout should be a browser one")
+			require.Equal(t, "true", layout["wait"], "Layout should wait for the login")
+
+			authURL, err := url.Parse(layout["content"])
+			require.NoError(t, err, "Layout content should be the authorization URL")
+			params := authURL.Query()
+			require.Equal(t, "S256", params.Get("code_challenge_method"), "Authorization URL should have a PKCE challenge")
+			require.NotEmpty(t, params.Get("code_challenge"), "Authorization URL should have a PKCE challenge")
+			require.Equal(t, "test-user@email.com", params.Get("login_hint"), "Authorization URL should hint the user")
+			redirectURL, err := url.Parse(params.Get("redirect_uri"))
+			require.NoError(t, err, "Authorization URL should have a redirect URI")
+			require.Equal(t, "127.0.0.1", redirectURL.Hostname(), "Redirect URI should be on the loopback interface")
+
+			if tc.endSession {
+				require.NoError(t, b.EndSession(sessionID), "EndSession should not have returned an error")
+				_, err = http.Get(redirectURL.String())
+				require.Error(t, err, "Redirect URI should not be served after the end of the session")
+				return
+			}
+
+			// A redirection with another state is rejected.
+			resp, err := http.Get(redirectURL.String() + "?" + url.Values{"state": {"other"}, "code": {"some-code"}}.Encode())
+			require.NoError(t, err, "Redirect URI should be served")
+			require.NoError(t, resp.Body.Close(), "Closing the response should not have failed")
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode, "Redirection with another state should be rejected")
+
+			query := url.Values{"state": {params.Get("state")}}
+			for k, v := range tc.redirectQuery {
+				query[k] = v
+			}
+			resp, err = http.Get(redirectURL.String() + "?" + query.Encode())
+			require.NoError(t, err, "Redirect URI should be served")
+			require.NoError(t, resp.Body.Close(), "Closing the response should not have failed")
+			require.Equal(t, http.StatusOK, resp.StatusCode, "Redirection should be accepted")
+
+			access, data, err := b.IsAuthenticated(sessionID, "{}")
+			require.NoError(t, err, "IsAuthenticated should not have returned an error")
+			require.Equal(t, tc.wantAccess, access, "IsAuthenticated should have returned the expected access, data: %s", data)
+		})
+	}
+}
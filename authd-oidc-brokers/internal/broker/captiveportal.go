@@ -0,0 +1,77 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// errCaptivePortal is returned by the requests to the provider which were answered by a captive portal.
+var errCaptivePortal = errors.New("the request was answered by a captive portal")
+
+const (
+	// captivePortalMsg is displayed when the provider can't be reached because of a captive portal and the user can't
+	// use their local password.
+	captivePortalMsg = "This network requires signing in through a captive portal. Connect through the captive portal first."
+	// captivePortalOrPasswordMsg is displayed when the provider can't be reached because of a captive portal during
+	// an authentication with the provider.
+	captivePortalOrPasswordMsg = "This network requires signing in through a captive portal. Connect through the captive portal first, or use your local password."
+)
+
+// captivePortalTransport detects the responses of captive portals, which answer the requests to any host until the
+// user signs in to the network.
+type captivePortalTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip sends the request and returns errCaptivePortal if the response is the one of a captive portal: either a
+// 511 Network Authentication Required status, or an HTML page, which the endpoints of the provider used by the
+// broker never return on success.
+func (t captivePortalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode == http.StatusNetworkAuthenticationRequired ||
+		(resp.StatusCode < http.StatusBadRequest && mediaType == "text/html") {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s answered %q with %s", errCaptivePortal, req.URL.Host, resp.Status, mediaType)
+	}
+	return resp, nil
+}
+
+// newProviderHTTPClient returns the HTTP client used for the requests to the provider, sent with the given transport.
+func newProviderHTTPClient(transport http.RoundTripper) *http.Client {
+	// The captive portals answering with a 511 status are detected before the status is reported as transient.
+	return &http.Client{Transport: providerStatusTransport{base: captivePortalTransport{base: transport}}}
+}
+
+// providerContext returns a context in which the OIDC and OAuth2 libraries send their requests to the provider with
+// the HTTP client of the broker.
+func (b *Broker) providerContext(ctx context.Context) context.Context {
+	return oidc.ClientContext(ctx, b.httpClient)
+}
+
+// behindCaptivePortal returns true if the request to the provider which returned err failed because of a captive
+// portal, either because the response was the one of a captive portal or because NetworkManager detected one.
+func (b *Broker) behindCaptivePortal(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, errCaptivePortal) {
+		return true
+	}
+	// The errors returned by the provider itself don't come from a captive portal.
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		return false
+	}
+	return b.connectivity != nil && b.connectivity.CaptivePortal()
+}
@@ -0,0 +1,70 @@
+package broker_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/sessionmode"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptivePortal(t *testing.T) {
+	t.Parallel()
+
+	const (
+		captivePortalMsg = "This network requires signing in through a captive portal. Connect through the captive portal first."
+		connectionMsg    = "Error connecting to provider. Check your network connection."
+	)
+
+	tests := map[string]struct {
+		discoveryHandler             testutils.EndpointHandler
+		portalDetectedByNM           bool
+		forceAccessCheckWithProvider bool
+
+		wantNewSessionErr string
+		wantAuthModesErr  string
+	}{
+		"Report_captive_portal_answering_with_a_page":         {discoveryHandler: testutils.CaptivePortalHandler(http.StatusOK), wantAuthModesErr: captivePortalMsg},
+		"Report_captive_portal_requiring_authentication":      {discoveryHandler: testutils.CaptivePortalHandler(http.StatusNetworkAuthenticationRequired), wantAuthModesErr: captivePortalMsg},
+		"Report_captive_portal_detected_by_NetworkManager":    {discoveryHandler: testutils.UnavailableHandler(), portalDetectedByNM: true, wantAuthModesErr: captivePortalMsg},
+		"Report_captive_portal_with_forced_access_check":      {discoveryHandler: testutils.CaptivePortalHandler(http.StatusOK), forceAccessCheckWithProvider: true, wantNewSessionErr: captivePortalMsg},
+		"Report_connection_error_if_there_is_no_portal":       {discoveryHandler: testutils.BadRequestHandler(), wantAuthModesErr: connectionMsg},
+		"Report_connection_error_for_provider_error_page":     {discoveryHandler: testutils.CaptivePortalHandler(http.StatusNotFound), wantAuthModesErr: connectionMsg},
+		"Report_connection_error_with_forced_access_check":    {discoveryHandler: testutils.BadRequestHandler(), forceAccessCheckWithProvider: true, wantNewSessionErr: connectionMsg},
+		"Do_not_report_captive_portal_if_provider_is_reached": {portalDetectedByNM: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var customHandlers map[string]testutils.EndpointHandler
+			if tc.discoveryHandler != nil {
+				customHandlers = map[string]testutils.EndpointHandler{"/.well-known/openid-configuration": tc.discoveryHandler}
+			}
+			connectivity := &connectivityMock{}
+			connectivity.portal.Store(tc.portalDetectedByNM)
+			b := newBrokerForTests(t, &brokerForTestConfig{
+				customHandlers:               customHandlers,
+				forceAccessCheckWithProvider: tc.forceAccessCheckWithProvider,
+				connectivity:                 connectivity,
+			})
+
+			id, _, err := b.NewSession("test-user", "lang", sessionmode.Login, "")
+			if tc.wantNewSessionErr != "" {
+				require.EqualError(t, err, tc.wantNewSessionErr, "NewSession should return the expected error")
+				return
+			}
+			require.NoError(t, err, "NewSession should not return an error")
+
+			// The user never logged in, so they have no local password to use while the provider can't be reached.
+			layouts := []map[string]string{supportedUILayouts["form"], supportedUILayouts["qrcode"]}
+			_, err = b.GetAuthenticationModes(id, layouts)
+			if tc.wantAuthModesErr != "" {
+				require.EqualError(t, err, tc.wantAuthModesErr, "GetAuthenticationModes should return the expected error")
+				return
+			}
+			require.NoError(t, err, "GetAuthenticationModes should not return an error")
+		})
+	}
+}
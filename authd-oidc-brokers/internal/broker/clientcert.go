@@ -0,0 +1,152 @@
+package broker
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/canonical/authd/log"
+	"golang.org/x/sys/unix"
+)
+
+// keyringKeyPrefix starts the client_key values which are the description of a key in the kernel keyring instead of
+// the path of a file.
+const keyringKeyPrefix = "keyring:"
+
+// clientCertificate is the certificate with which the broker authenticates to the provider with mutual TLS. It's
+// loaded again when its files are replaced, so that it can be rotated without restarting the broker.
+type clientCertificate struct {
+	certPath string
+	// keyPath is the path of the file of the private key, or empty if it's read from the kernel keyring.
+	keyPath string
+	// keyDescription is the description of the key in the user keyring of root with the private key in PEM.
+	keyDescription string
+
+	mu   sync.Mutex
+	cert *tls.Certificate
+	// modTimes are the modification times of the files of the loaded certificate.
+	modTimes [2]time.Time
+}
+
+// newClientCertificate returns the client certificate of the certificate file and of the key, which is either the
+// path of a file or the description of a key in the kernel keyring prefixed with "keyring:". The certificate is
+// loaded right away, so that an invalid one is reported when the broker starts.
+func newClientCertificate(certPath, key string) (*clientCertificate, error) {
+	c := &clientCertificate{certPath: certPath, keyPath: key}
+	if description, ok := strings.CutPrefix(key, keyringKeyPrefix); ok {
+		c.keyPath, c.keyDescription = "", description
+	}
+
+	if _, err := c.certificate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetClientCertificate returns the certificate sent to the servers requesting one, and satisfies the signature of
+// tls.Config.GetClientCertificate.
+func (c *clientCertificate) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return c.certificate()
+}
+
+// certificate returns the certificate, which is loaded again if its files were modified since it was loaded. The
+// certificate loaded before is kept if the new one is invalid, e.g. if it's read while it's being rotated.
+func (c *clientCertificate) certificate() (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	modTimes, err := c.fileModTimes()
+	if err != nil && c.cert == nil {
+		return nil, err
+	}
+	if err != nil {
+		log.Warningf(context.Background(), "Could not check whether the client certificate was rotated, using the current one: %v", err)
+		return c.cert, nil
+	}
+	if c.cert != nil && modTimes == c.modTimes {
+		return c.cert, nil
+	}
+
+	cert, err := c.load()
+	if err != nil && c.cert == nil {
+		return nil, err
+	}
+	if err != nil {
+		log.Warningf(context.Background(), "Could not load the rotated client certificate, using the current one: %v", err)
+		return c.cert, nil
+	}
+	if c.cert != nil {
+		log.Noticef(context.Background(), "Loaded the rotated client certificate %q", c.certPath)
+	}
+	c.cert, c.modTimes = cert, modTimes
+	return c.cert, nil
+}
+
+// fileModTimes returns the modification times of the certificate file and of the key file, if any. The key of the
+// kernel keyring is only read again with the certificate, which is replaced when the key is rotated.
+func (c *clientCertificate) fileModTimes() (modTimes [2]time.Time, err error) {
+	for i, path := range []string{c.certPath, c.keyPath} {
+		if path == "" {
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return modTimes, fmt.Errorf("could not read the client certificate: %w", err)
+		}
+		modTimes[i] = fi.ModTime()
+	}
+	return modTimes, nil
+}
+
+// load reads the certificate and its private key.
+func (c *clientCertificate) load() (*tls.Certificate, error) {
+	certPEM, err := os.ReadFile(c.certPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the client certificate: %w", err)
+	}
+
+	var keyPEM []byte
+	if c.keyDescription != "" {
+		keyPEM, err = readKeyringKey(c.keyDescription)
+	} else {
+		keyPEM, err = os.ReadFile(c.keyPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the private key of the client certificate: %w", err)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client certificate %q: %w", c.certPath, err)
+	}
+	return &cert, nil
+}
+
+// readKeyringKey returns the payload of the user key with the description in the user keyring of the broker, e.g.
+// added with "keyctl padd user <description> @u".
+func readKeyringKey(description string) ([]byte, error) {
+	id, err := unix.KeyctlSearch(unix.KEY_SPEC_USER_KEYRING, "user", description, 0)
+	if errors.Is(err, unix.ENOKEY) {
+		return nil, fmt.Errorf("no key %q in the kernel keyring", description)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not search the kernel keyring: %w", err)
+	}
+
+	// Reading with an empty buffer returns the size of the payload.
+	size, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, nil, 0)
+	if err != nil {
+		return nil, fmt.Errorf("could not read key %q from the kernel keyring: %w", description, err)
+	}
+	payload := make([]byte, size)
+	n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, payload, 0)
+	if err != nil {
+		return nil, fmt.Errorf("could not read key %q from the kernel keyring: %w", description, err)
+	}
+	return payload[:min(n, size)], nil
+}
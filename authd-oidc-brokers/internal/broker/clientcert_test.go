@@ -0,0 +1,208 @@
+package broker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestClientCertificate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		keyInKeyring   bool
+		noCert         bool
+		noKeyringKey   bool
+		mismatchingKey bool
+		rotate         bool
+		rotateInvalid  bool
+
+		wantErr bool
+	}{
+		"Loads_the_certificate_and_the_key_from_files":                {},
+		"Loads_the_key_from_the_kernel_keyring":                       {keyInKeyring: true},
+		"Loads_the_certificate_again_when_it_is_rotated":              {rotate: true},
+		"Loads_the_certificate_again_when_it_is_rotated_with_keyring": {keyInKeyring: true, rotate: true},
+		"Keeps_the_current_certificate_if_the_rotated_one_is_invalid": {rotateInvalid: true},
+
+		"Error_if_the_certificate_can_not_be_read":        {noCert: true, wantErr: true},
+		"Error_if_the_key_is_not_in_the_kernel_keyring":   {keyInKeyring: true, noKeyringKey: true, wantErr: true},
+		"Error_if_the_key_does_not_match_the_certificate": {mismatchingKey: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			certPath, keyPath := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+			certPEM, keyPEM := generateCertificate(t, nil, nil)
+			if tc.mismatchingKey {
+				_, keyPEM = generateCertificate(t, nil, nil)
+			}
+			if !tc.noCert {
+				writeFileWithModTime(t, certPath, certPEM, time.Now().Add(-time.Hour))
+			}
+
+			key := keyPath
+			if tc.keyInKeyring {
+				// The temporary directory makes the description unique to the test.
+				description := "authd-oidc-broker-test:" + dir
+				key = keyringKeyPrefix + description
+				if !tc.noKeyringKey {
+					addKeyringKey(t, description, keyPEM)
+				}
+			} else {
+				writeFileWithModTime(t, keyPath, keyPEM, time.Now().Add(-time.Hour))
+			}
+
+			c, err := newClientCertificate(certPath, key)
+			if tc.wantErr {
+				require.Error(t, err, "newClientCertificate should return an error")
+				return
+			}
+			require.NoError(t, err, "newClientCertificate should not return an error")
+
+			got, err := c.GetClientCertificate(nil)
+			require.NoError(t, err, "GetClientCertificate should not return an error")
+			wantCert := certPEM
+
+			if tc.rotate || tc.rotateInvalid {
+				newCertPEM, newKeyPEM := generateCertificate(t, nil, nil)
+				if tc.rotateInvalid {
+					newCertPEM = []byte("not a certificate")
+				} else {
+					wantCert = newCertPEM
+				}
+				if tc.keyInKeyring {
+					addKeyringKey(t, key[len(keyringKeyPrefix):], newKeyPEM)
+				} else {
+					writeFileWithModTime(t, keyPath, newKeyPEM, time.Now())
+				}
+				writeFileWithModTime(t, certPath, newCertPEM, time.Now())
+
+				got, err = c.GetClientCertificate(nil)
+				require.NoError(t, err, "GetClientCertificate should not return an error")
+			}
+
+			block, _ := pem.Decode(wantCert)
+			require.Equal(t, block.Bytes, got.Certificate[0], "GetClientCertificate should return the expected certificate")
+		})
+	}
+}
+
+func TestNewProviderTransportWithClientCertificate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		noClientCert bool
+
+		wantErr bool
+	}{
+		"Authenticates_with_the_client_certificate": {},
+
+		"Error_if_the_server_requires_a_client_certificate": {noClientCert: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			caCertPEM, caKeyPEM := generateCertificate(t, nil, nil)
+			caCert, err := tls.X509KeyPair(caCertPEM, caKeyPEM)
+			require.NoError(t, err, "Setup: could not parse CA certificate")
+			clientCertPEM, clientKeyPEM := generateCertificate(t, caCert.Leaf, caCert.PrivateKey)
+
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			clientCAs := x509.NewCertPool()
+			clientCAs.AddCert(caCert.Leaf)
+			server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs, MinVersion: tls.VersionTLS12}
+			server.StartTLS()
+			t.Cleanup(server.Close)
+
+			nc := networkConfig{caCertificates: filepath.Join(dir, "ca.pem")}
+			serverCertPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+			require.NoError(t, os.WriteFile(nc.caCertificates, serverCertPEM, 0600), "Setup: could not write CA certificates")
+			if !tc.noClientCert {
+				nc.clientCertificate, nc.clientKey = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+				require.NoError(t, os.WriteFile(nc.clientCertificate, clientCertPEM, 0600), "Setup: could not write client certificate")
+				require.NoError(t, os.WriteFile(nc.clientKey, clientKeyPEM, 0600), "Setup: could not write client key")
+			}
+
+			transport, err := newProviderTransport(nc)
+			require.NoError(t, err, "newProviderTransport should not return an error")
+
+			resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+			if tc.wantErr {
+				require.Error(t, err, "The request should fail")
+				return
+			}
+			require.NoError(t, err, "The request should succeed")
+			_ = resp.Body.Close()
+		})
+	}
+}
+
+// generateCertificate returns a new certificate and its private key in PEM, signed by parent or self-signed if
+// parent is nil.
+func generateCertificate(t *testing.T, parent *x509.Certificate, parentKey any) (certPEM, keyPEM []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Setup: could not generate key")
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err, "Setup: could not generate serial number")
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "authd-oidc-broker-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err, "Setup: could not create certificate")
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err, "Setup: could not marshal key")
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeFileWithModTime writes the file with the modification time, so that the rotations are detected even if they
+// happen within the resolution of the modification times of the file system.
+func writeFileWithModTime(t *testing.T, path string, data []byte, modTime time.Time) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, data, 0600), "Setup: could not write file")
+	require.NoError(t, os.Chtimes(path, modTime, modTime), "Setup: could not set modification time")
+}
+
+// addKeyringKey adds or replaces the user key with the description in the user keyring, and removes it once the test
+// is done.
+func addKeyringKey(t *testing.T, description string, payload []byte) {
+	t.Helper()
+
+	id, err := unix.AddKey("user", description, payload, unix.KEY_SPEC_USER_KEYRING)
+	require.NoError(t, err, "Setup: could not add key to the kernel keyring")
+	t.Cleanup(func() { _, _ = unix.KeyctlInt(unix.KEYCTL_UNLINK, id, unix.KEY_SPEC_USER_KEYRING, 0, 0) })
+}
@@ -8,11 +8,16 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"text/template"
+	"time"
 	"unicode"
 
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/genericprovider"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/secretstorage"
 	"github.com/canonical/authd/log"
 	"gopkg.in/ini.v1"
 )
@@ -34,6 +39,26 @@ const (
 	clientSecret = "client_secret"
 	// extraScopesKey is the key in the config file for extra OIDC scopes.
 	extraScopesKey = "extra_scopes"
+	// sandboxTokenRefreshKey is the key in the config file for the setting to refresh the tokens in a sandboxed
+	// child process.
+	sandboxTokenRefreshKey = "sandbox_token_refresh"
+	// fipsModeKey is the key in the config file for the setting to only use FIPS 140 approved cryptography.
+	fipsModeKey = "fips_mode"
+	// sessionTokenScopesKey is the key in the config file for the scopes of the access tokens which the applications
+	// of the sessions of the users can request.
+	sessionTokenScopesKey = "session_token_scopes"
+	// maxCredentialsAgeKey is the key in the config file for the number of days after which the users have to
+	// authenticate interactively with the identity provider again to keep using their local password.
+	maxCredentialsAgeKey = "max_credentials_age"
+	// tokenRefreshIntervalKey is the key in the config file for the interval at which the cached tokens of the users
+	// are refreshed in the background.
+	tokenRefreshIntervalKey = "token_refresh_interval"
+	// tokenRefreshJitterKey is the key in the config file for the maximum random delay added to the interval between
+	// the background refreshes of the tokens.
+	tokenRefreshJitterKey = "token_refresh_jitter"
+	// secretStorageKey is the key in the config file for the backend sealing the tokens and the password hashes
+	// cached for the users.
+	secretStorageKey = "secret_storage"
 
 	// entraIDSection is the section name in the config file for Microsoft Entra ID specific configuration.
 	entraIDSection = "msentraid"
@@ -56,6 +81,28 @@ const (
 	extraGroupsKey = "extra_groups"
 	// ownerExtraGroupsKey is the key in the config file for the extra groups to add to the owner.
 	ownerExtraGroupsKey = "owner_extra_groups"
+	// maxGroupsKey is the key in the config file for the maximum number of groups of the provider a user is added to.
+	maxGroupsKey = "max_groups"
+	// priorityGroupsKey is the key in the config file for the patterns of the groups which are kept first when the
+	// groups of a user are limited by max_groups.
+	priorityGroupsKey = "priority_groups"
+	// sessionEnvKey is the key in the config file for the environment variables set in the sessions of the users
+	// from the claims of their tokens.
+	sessionEnvKey = "session_env"
+	// sshKeysClaimKey is the key in the config file for the claim of the tokens with the SSH public keys of the users.
+	sshKeysClaimKey = "ssh_keys_claim"
+	// usernameClaimKey is the key in the config file for the claim of the ID token with the names of the users.
+	usernameClaimKey = "username_claim"
+	// uidClaimKey is the key in the config file for the claim of the ID token with the UIDs suggested for the users.
+	uidClaimKey = "uid_claim"
+	// homeClaimKey is the key in the config file for the claim of the ID token with the home directories of the users.
+	homeClaimKey = "home_claim"
+	// shellClaimKey is the key in the config file for the claim of the ID token with the shells of the users.
+	shellClaimKey = "shell_claim"
+	// gecosClaimKey is the key in the config file for the claim of the ID token with the full names of the users.
+	gecosClaimKey = "gecos_claim"
+	// groupsClaimKey is the key in the config file for the claim of the ID token listing the groups of the users.
+	groupsClaimKey = "groups_claim"
 	// allUsersKeyword is the keyword for the `allowed_users` key that allows access to all users.
 	allUsersKeyword = "ALL"
 	// ownerUserKeyword is the keyword for the `allowed_users` key that allows access to the owner.
@@ -67,6 +114,44 @@ const (
 	flowsDeviceAuthKey = "device_code"
 	// flowsEntraPasswordKey controls whether entra_password mode is enabled.
 	flowsEntraPasswordKey = "entra_password"
+	// flowsBrowserKey controls whether the browser mode is enabled.
+	flowsBrowserKey = "browser"
+
+	// passwordPolicySection is the section name in the config file for the policy of the local passwords.
+	passwordPolicySection = "password_policy"
+	// minLengthKey is the key in the config file for the minimum number of characters of the local passwords.
+	minLengthKey = "min_length"
+	// requiredClassesKey is the key in the config file for the classes of characters the local passwords must contain.
+	requiredClassesKey = "required_classes"
+	// dictionaryFileKey is the key in the config file for the list of words the local passwords must not be based on.
+	dictionaryFileKey = "dictionary_file"
+	// historyKey is the key in the config file for the number of previous local passwords which can't be reused.
+	historyKey = "history"
+	// maxAgeKey is the key in the config file for the number of days after which the users have to change their
+	// local password.
+	maxAgeKey = "max_age"
+
+	// networkSection is the section name in the config file for the connections to the provider.
+	networkSection = "network"
+	// proxyKey is the key in the config file for the URL of the proxy through which the provider is reached.
+	proxyKey = "proxy"
+	// noProxyKey is the key in the config file for the hosts which are reached without the proxy.
+	noProxyKey = "no_proxy"
+	// caCertificatesKey is the key in the config file for the certificates of the authorities trusted in addition to
+	// the ones of the system.
+	caCertificatesKey = "ca_certificates"
+	// clientCertificateKey is the key in the config file for the certificate with which the broker authenticates to
+	// the provider with mutual TLS.
+	clientCertificateKey = "client_certificate"
+	// clientKeyKey is the key in the config file for the private key of the client certificate.
+	clientKeyKey = "client_key"
+
+	// issuerSectionPrefix is the prefix of the names of the sections in the config file for the issuers whose users
+	// are authenticated in addition to the ones of the issuer of the [oidc] section, followed by the name of the
+	// issuer.
+	issuerSectionPrefix = "issuer "
+	// domainsKey is the key in the config file for the domains of the usernames of the users of an issuer.
+	domainsKey = "domains"
 
 	// ownerAutoRegistrationConfigPath is the name of the file that will be auto-generated to register the owner.
 	ownerAutoRegistrationConfigPath     = "20-owner-autoregistration.conf"
@@ -77,6 +162,9 @@ var (
 	//go:embed templates/20-owner-autoregistration.conf.tmpl
 	ownerAutoRegistrationConfig embed.FS
 
+	// sessionEnvNameRegex matches the valid names of the environment variables of session_env.
+	sessionEnvNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 	// knownConfigKeys maps each known config section to its known keys.
 	knownConfigKeys = map[string]map[string]struct{}{
 		oidcSection: {
@@ -86,6 +174,13 @@ var (
 			extraScopesKey:                     {},
 			forceAccessCheckWithProviderKey:    {},
 			forceAccessCheckWithProviderKeyOld: {},
+			sandboxTokenRefreshKey:             {},
+			fipsModeKey:                        {},
+			sessionTokenScopesKey:              {},
+			maxCredentialsAgeKey:               {},
+			tokenRefreshIntervalKey:            {},
+			tokenRefreshJitterKey:              {},
+			secretStorageKey:                   {},
 		},
 		entraIDSection: {
 			registerDeviceKey: {},
@@ -98,12 +193,51 @@ var (
 			sshSuffixesKeyOld:   {},
 			extraGroupsKey:      {},
 			ownerExtraGroupsKey: {},
+			maxGroupsKey:        {},
+			priorityGroupsKey:   {},
+			sessionEnvKey:       {},
+			sshKeysClaimKey:     {},
+			usernameClaimKey:    {},
+			uidClaimKey:         {},
+			homeClaimKey:        {},
+			shellClaimKey:       {},
+			gecosClaimKey:       {},
+			groupsClaimKey:      {},
 		},
 		flowsSection: {
 			flowsDeviceAuthKey:    {},
 			flowsEntraPasswordKey: {},
+			flowsBrowserKey:       {},
+		},
+		passwordPolicySection: {
+			minLengthKey:       {},
+			requiredClassesKey: {},
+			dictionaryFileKey:  {},
+			historyKey:         {},
+			maxAgeKey:          {},
+		},
+		networkSection: {
+			proxyKey:             {},
+			noProxyKey:           {},
+			caCertificatesKey:    {},
+			clientCertificateKey: {},
+			clientKeyKey:         {},
 		},
 	}
+
+	// knownIssuerKeys are the known keys of the [issuer <name>] sections.
+	knownIssuerKeys = map[string]struct{}{
+		issuerKey:        {},
+		clientIDKey:      {},
+		clientSecret:     {},
+		domainsKey:       {},
+		usernameClaimKey: {},
+		uidClaimKey:      {},
+		homeClaimKey:     {},
+		shellClaimKey:    {},
+		gecosClaimKey:    {},
+		groupsClaimKey:   {},
+	}
 )
 
 type provider interface {
@@ -126,6 +260,8 @@ type userConfig struct {
 	issuerURL    string
 
 	forceAccessCheckWithProvider bool
+	sandboxTokenRefresh          bool
+	fipsMode                     bool
 	registerDevice               bool
 
 	allowedUsers          map[string]struct{}
@@ -138,9 +274,22 @@ type userConfig struct {
 	allowedSSHSuffixes    []string
 	extraGroups           []string
 	ownerExtraGroups      []string
+	maxGroups             int
+	priorityGroups        []string
+	sessionEnv            map[string]string
+	sshKeysClaim          string
+	claims                genericprovider.ClaimMapping
 	extraScopes           []string
+	sessionTokenScopes    []string
+	maxCredentialsAge     time.Duration
+	tokenRefreshInterval  time.Duration
+	tokenRefreshJitter    time.Duration
+	secretStorage         string
 
-	flows flowsConfig
+	flows          flowsConfig
+	passwordPolicy passwordPolicy
+	network        networkConfig
+	issuers        []issuerConfig
 
 	provider provider
 }
@@ -149,9 +298,11 @@ type userConfig struct {
 type flowsConfig struct {
 	DeviceAuth    bool
 	EntraPassword bool
+	// Browser is disabled by default, because the redirect URI of the broker must be registered with the provider.
+	Browser bool
 }
 
-// defaultFlowsConfig returns the default flows configuration (all modes enabled).
+// defaultFlowsConfig returns the default flows configuration (all modes but the browser one enabled).
 func defaultFlowsConfig() flowsConfig {
 	return flowsConfig{
 		DeviceAuth:    true,
@@ -164,6 +315,12 @@ func GetDropInDir(cfgPath string) string {
 	return cfgPath + ".d"
 }
 
+// GetMessagesDir takes the broker configuration path and returns the path of the directory with the messages
+// overridden by the administrator.
+func GetMessagesDir(cfgPath string) string {
+	return filepath.Join(filepath.Dir(cfgPath), "messages")
+}
+
 func readDropInFiles(cfgPath string) ([]configFile, error) {
 	// Check if a .d directory exists and return the paths to the files in it.
 	dropInDir := GetDropInDir(cfgPath)
@@ -245,6 +402,28 @@ func (uc *userConfig) populateUsersConfig(users *ini.Section) {
 
 	uc.extraGroups = users.Key(extraGroupsKey).Strings(",")
 	uc.ownerExtraGroups = users.Key(ownerExtraGroupsKey).Strings(",")
+
+	// Already validated per-file; ignore error.
+	uc.maxGroups, _ = users.Key(maxGroupsKey).Int()
+	uc.priorityGroups = users.Key(priorityGroupsKey).Strings(",")
+	uc.claims = genericprovider.ClaimMapping{
+		Username: users.Key(usernameClaimKey).String(),
+		UIDHint:  users.Key(uidClaimKey).String(),
+		Home:     users.Key(homeClaimKey).String(),
+		Shell:    users.Key(shellClaimKey).String(),
+		Gecos:    users.Key(gecosClaimKey).String(),
+		Groups:   users.Key(groupsClaimKey).String(),
+	}
+
+	if users.HasKey(sessionEnvKey) {
+		uc.sessionEnv = make(map[string]string)
+		for _, entry := range users.Key(sessionEnvKey).Strings(",") {
+			// Already validated per-file.
+			name, claim, _ := strings.Cut(entry, "=")
+			uc.sessionEnv[strings.TrimSpace(name)] = strings.TrimSpace(claim)
+		}
+	}
+	uc.sshKeysClaim = users.Key(sshKeysClaimKey).String()
 }
 
 // parseConfigFromPath parses the config file and returns a map with the configuration keys and values.
@@ -263,6 +442,23 @@ func parseConfigFromPath(cfgPath string, p provider) (userConfig, error) {
 	return parseConfig(cfgFile, dropInFiles, p)
 }
 
+// ValidateConfig checks that content, along with the drop-in files of cfgPath, is a configuration the broker can start
+// with.
+func ValidateConfig(cfgPath string, content []byte) error {
+	dropInFiles, err := readDropInFiles(cfgPath)
+	if err != nil {
+		return err
+	}
+
+	p := providers.CurrentProvider()
+	uc, err := parseConfig(configFile{path: cfgPath, content: content}, dropInFiles, p)
+	if err != nil {
+		return err
+	}
+
+	return validateUserConfig(uc, p)
+}
+
 // validatePlaceholders checks that no values in iniCfg still contain unedited
 // template placeholders (e.g. "<ISSUER_ID>"). path is used only for the error
 // message and should be the main config file path.
@@ -283,7 +479,7 @@ func validatePlaceholders(path string, iniCfg *ini.File) error {
 }
 
 // validateConfigFile checks a parsed ini config for validity: parseable boolean
-// fields, and returns errors for unknown sections/keys. It does not check for
+// and integer fields, valid group patterns, and returns errors for unknown sections/keys. It does not check for
 // template placeholders; call validatePlaceholders for that.
 func validateConfigFile(path string, iniCfg *ini.File) error {
 	// Return errors for unknown sections and keys.
@@ -295,6 +491,12 @@ func validateConfigFile(path string, iniCfg *ini.File) error {
 			continue
 		}
 		sectionKeys, ok := knownConfigKeys[section.Name()]
+		if _, isIssuer := issuerSectionName(section.Name()); isIssuer {
+			if err := validateIssuerSection(path, section); err != nil {
+				return err
+			}
+			sectionKeys, ok = knownIssuerKeys, true
+		}
 		if !ok {
 			return fmt.Errorf("unknown section %q in config file %q", section.Name(), path)
 		}
@@ -317,6 +519,42 @@ func validateConfigFile(path string, iniCfg *ini.File) error {
 				return fmt.Errorf("error parsing '%s' in config file %q: %w", forceAccessCheckKey, path, err)
 			}
 		}
+		if oidc.HasKey(sandboxTokenRefreshKey) {
+			if _, err := oidc.Key(sandboxTokenRefreshKey).Bool(); err != nil {
+				return fmt.Errorf("error parsing '%s' in config file %q: %w", sandboxTokenRefreshKey, path, err)
+			}
+		}
+		if oidc.HasKey(fipsModeKey) {
+			if _, err := oidc.Key(fipsModeKey).Bool(); err != nil {
+				return fmt.Errorf("error parsing '%s' in config file %q: %w", fipsModeKey, path, err)
+			}
+		}
+		if oidc.HasKey(maxCredentialsAgeKey) {
+			days, err := oidc.Key(maxCredentialsAgeKey).Int()
+			if err != nil {
+				return fmt.Errorf("error parsing '%s' in config file %q: %w", maxCredentialsAgeKey, path, err)
+			}
+			if days < 0 {
+				return fmt.Errorf("error parsing '%s' in config file %q: %d is negative", maxCredentialsAgeKey, path, days)
+			}
+		}
+		for _, key := range []string{tokenRefreshIntervalKey, tokenRefreshJitterKey} {
+			if !oidc.HasKey(key) {
+				continue
+			}
+			d, err := oidc.Key(key).Duration()
+			if err != nil {
+				return fmt.Errorf("error parsing '%s' in config file %q: %w", key, path, err)
+			}
+			if d < 0 {
+				return fmt.Errorf("error parsing '%s' in config file %q: %s is negative", key, path, d)
+			}
+		}
+		if oidc.HasKey(secretStorageKey) {
+			if v := oidc.Key(secretStorageKey).String(); !secretstorage.IsValid(v) {
+				return fmt.Errorf("error parsing '%s' in config file %q: %q is not one of %v", secretStorageKey, path, v, secretstorage.Backends)
+			}
+		}
 	}
 
 	entraID := iniCfg.Section(entraIDSection)
@@ -326,13 +564,78 @@ func validateConfigFile(path string, iniCfg *ini.File) error {
 		}
 	}
 
+	users := iniCfg.Section(usersSection)
+	if users != nil && users.HasKey(maxGroupsKey) {
+		maxGroups, err := users.Key(maxGroupsKey).Int()
+		if err != nil {
+			return fmt.Errorf("error parsing '%s' in config file %q: %w", maxGroupsKey, path, err)
+		}
+		if maxGroups < 0 {
+			return fmt.Errorf("error parsing '%s' in config file %q: %d is negative", maxGroupsKey, path, maxGroups)
+		}
+	}
+	if users != nil && users.HasKey(priorityGroupsKey) {
+		for _, pattern := range users.Key(priorityGroupsKey).Strings(",") {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("error parsing '%s' in config file %q: invalid pattern %q", priorityGroupsKey, path, pattern)
+			}
+		}
+	}
+	if users != nil && users.HasKey(sessionEnvKey) {
+		for _, entry := range users.Key(sessionEnvKey).Strings(",") {
+			name, claim, ok := strings.Cut(entry, "=")
+			if !ok || strings.TrimSpace(claim) == "" {
+				return fmt.Errorf("error parsing '%s' in config file %q: %q is not of the form NAME=claim", sessionEnvKey, path, entry)
+			}
+			if !sessionEnvNameRegex.MatchString(strings.TrimSpace(name)) {
+				return fmt.Errorf("error parsing '%s' in config file %q: invalid environment variable name %q", sessionEnvKey, path, name)
+			}
+		}
+	}
+
+	if err := validatePasswordPolicySection(path, iniCfg.Section(passwordPolicySection)); err != nil {
+		return err
+	}
+	if err := validateNetworkSection(path, iniCfg.Section(networkSection)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validatePasswordPolicySection checks the values of the [password_policy] section.
+func validatePasswordPolicySection(path string, section *ini.Section) error {
+	for _, key := range []string{minLengthKey, historyKey, maxAgeKey} {
+		if !section.HasKey(key) {
+			continue
+		}
+		v, err := section.Key(key).Int()
+		if err != nil {
+			return fmt.Errorf("error parsing '%s' in config file %q: %w", key, path, err)
+		}
+		if v < 0 {
+			return fmt.Errorf("error parsing '%s' in config file %q: %d is negative", key, path, v)
+		}
+	}
+	if section.HasKey(requiredClassesKey) {
+		for _, class := range section.Key(requiredClassesKey).Strings(",") {
+			if _, ok := characterClasses[class]; !ok {
+				return fmt.Errorf("error parsing '%s' in config file %q: unknown character class %q", requiredClassesKey, path, class)
+			}
+		}
+	}
+	if section.HasKey(dictionaryFileKey) {
+		if p := section.Key(dictionaryFileKey).String(); p != "" && !filepath.IsAbs(p) {
+			return fmt.Errorf("error parsing '%s' in config file %q: %q is not an absolute path", dictionaryFileKey, path, p)
+		}
+	}
 	return nil
 }
 
 // parseConfig parses the config file and returns a userConfig struct with the configuration keys and values.
 // It also checks if the keys contain any placeholders and returns an error if they do.
 func parseConfig(cfg configFile, dropInCfgs []configFile, p provider) (userConfig, error) {
-	uc := userConfig{provider: p, ownerMutex: &sync.RWMutex{}}
+	uc := userConfig{provider: p, ownerMutex: &sync.RWMutex{}, sandboxTokenRefresh: true}
 
 	iniCfg, err := ini.Load(cfg.content)
 	if err != nil {
@@ -372,6 +675,7 @@ func parseConfig(cfg configFile, dropInCfgs []configFile, p provider) (userConfi
 		uc.clientID = oidc.Key(clientIDKey).String()
 		uc.clientSecret = strings.TrimSpace(oidc.Key(clientSecret).String())
 		uc.extraScopes = oidc.Key(extraScopesKey).Strings(",")
+		uc.sessionTokenScopes = oidc.Key(sessionTokenScopesKey).Strings(",")
 
 		forceAccessCheckKey := forceAccessCheckWithProviderKey
 		// If we don't have the new key, we should try reading the old one instead.
@@ -382,6 +686,31 @@ func parseConfig(cfg configFile, dropInCfgs []configFile, p provider) (userConfi
 			// Already validated per-file above; ignore error.
 			uc.forceAccessCheckWithProvider, _ = oidc.Key(forceAccessCheckKey).Bool()
 		}
+		if oidc.HasKey(sandboxTokenRefreshKey) {
+			// Already validated per-file above; ignore error.
+			uc.sandboxTokenRefresh, _ = oidc.Key(sandboxTokenRefreshKey).Bool()
+		}
+		if oidc.HasKey(fipsModeKey) {
+			// Already validated per-file above; ignore error.
+			uc.fipsMode, _ = oidc.Key(fipsModeKey).Bool()
+		}
+		if oidc.HasKey(maxCredentialsAgeKey) {
+			// Already validated per-file above; ignore error.
+			days, _ := oidc.Key(maxCredentialsAgeKey).Int()
+			uc.maxCredentialsAge = time.Duration(days) * 24 * time.Hour
+		}
+		if oidc.HasKey(tokenRefreshIntervalKey) {
+			// Already validated per-file above; ignore error.
+			uc.tokenRefreshInterval, _ = oidc.Key(tokenRefreshIntervalKey).Duration()
+		}
+		if oidc.HasKey(tokenRefreshJitterKey) {
+			// Already validated per-file above; ignore error.
+			uc.tokenRefreshJitter, _ = oidc.Key(tokenRefreshJitterKey).Duration()
+		}
+		if oidc.HasKey(secretStorageKey) {
+			// Already validated per-file above.
+			uc.secretStorage = oidc.Key(secretStorageKey).String()
+		}
 	}
 
 	entraID := iniCfg.Section(entraIDSection)
@@ -396,6 +725,9 @@ func parseConfig(cfg configFile, dropInCfgs []configFile, p provider) (userConfi
 	}
 
 	uc.populateUsersConfig(iniCfg.Section(usersSection))
+	uc.passwordPolicy = parsePasswordPolicySection(iniCfg.Section(passwordPolicySection))
+	uc.network = parseNetworkSection(iniCfg.Section(networkSection))
+	uc.issuers = parseIssuerSections(iniCfg, uc.claims)
 
 	return uc, nil
 }
@@ -507,10 +839,31 @@ func parseFlowsConfig(section *ini.Section) (flowsConfig, error) {
 		}
 	}
 
-	if !fc.DeviceAuth && !fc.EntraPassword {
-		return flowsConfig{}, fmt.Errorf("invalid [%s] configuration: all authentication flows are disabled; at least one of the %q or %q flows must be enabled",
-			flowsSection, flowsDeviceAuthKey, flowsEntraPasswordKey)
+	if section.HasKey(flowsBrowserKey) {
+		val, err := section.Key(flowsBrowserKey).Bool()
+		if err != nil {
+			log.Warningf(context.Background(), "invalid value for %q in [%s] section, using default (false)", flowsBrowserKey, flowsSection)
+		} else {
+			fc.Browser = val
+		}
+	}
+
+	if !fc.DeviceAuth && !fc.EntraPassword && !fc.Browser {
+		return flowsConfig{}, fmt.Errorf("invalid [%s] configuration: all authentication flows are disabled; at least one of the %q, %q or %q flows must be enabled",
+			flowsSection, flowsDeviceAuthKey, flowsEntraPasswordKey, flowsBrowserKey)
 	}
 
 	return fc, nil
 }
+
+// parsePasswordPolicySection parses the [password_policy] section, which was already validated.
+func parsePasswordPolicySection(section *ini.Section) passwordPolicy {
+	var pp passwordPolicy
+	pp.minLength, _ = section.Key(minLengthKey).Int()
+	pp.requiredClasses = section.Key(requiredClassesKey).Strings(",")
+	pp.dictionaryFile = section.Key(dictionaryFileKey).String()
+	pp.history, _ = section.Key(historyKey).Int()
+	days, _ := section.Key(maxAgeKey).Int()
+	pp.maxAge = time.Duration(days) * 24 * time.Hour
+	return pp
+}
@@ -25,4 +25,7 @@ const (
 	// AuthDataSecretOld is the old key for the secret in the authentication data, which is now deprecated
 	// TODO(UDENG-5844): Remove this once all authd installations use "secret" instead of "challenge".
 	AuthDataSecretOld = "challenge"
+	// AuthDataWebAuthnAssertion is the key for the WebAuthn assertion signed by a security key in the authentication
+	// data.
+	AuthDataWebAuthnAssertion = "webauthnAssertion"
 )
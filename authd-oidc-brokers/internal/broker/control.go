@@ -0,0 +1,207 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/consts"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
+	"github.com/canonical/authd/log"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ErrNoCachedToken is returned when no token is cached for a user.
+var ErrNoCachedToken = errors.New("no token is cached for this user")
+
+// TokenStatus describes the token cached by the broker for a user.
+type TokenStatus struct {
+	Username         string    `json:"username"`
+	ProviderID       string    `json:"provider_id,omitempty"`
+	Expiry           time.Time `json:"expiry"`
+	HasRefreshToken  bool      `json:"has_refresh_token"`
+	HasLocalPassword bool      `json:"has_local_password"`
+	DeviceRegistered bool      `json:"device_registered"`
+	UserIsDisabled   bool      `json:"user_is_disabled"`
+	DeviceIsDisabled bool      `json:"device_is_disabled"`
+	// LastBackgroundRefresh is when the token was last refreshed in the background, zero if it never was.
+	LastBackgroundRefresh time.Time `json:"last_background_refresh,omitzero"`
+	// BackgroundRefreshError is why the last background refresh of the token failed, empty if it succeeded.
+	BackgroundRefreshError string `json:"background_refresh_error,omitempty"`
+}
+
+// Identity describes the broker and its configuration.
+type Identity struct {
+	Provider string `json:"provider"`
+	Version  string `json:"version"`
+	Issuer   string `json:"issuer"`
+	ClientID string `json:"client_id"`
+	Owner    string `json:"owner,omitempty"`
+}
+
+// The statuses of the checks of the connection to the provider.
+const (
+	ProviderCheckOK      = "ok"
+	ProviderCheckFailed  = "failed"
+	ProviderCheckSkipped = "skipped"
+)
+
+// ProviderCheck is the result of a check of the connection of the broker to its provider.
+type ProviderCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Details string `json:"details,omitempty"`
+}
+
+// TokenStatus returns the status of the token cached for the user.
+func (b *Broker) TokenStatus(username string) (TokenStatus, error) {
+	s, err := b.cachedSession(username)
+	if err != nil {
+		return TokenStatus{}, err
+	}
+
+	if !tokenExists(s) {
+		return TokenStatus{}, ErrNoCachedToken
+	}
+	authInfo, err := token.LoadAuthInfo(s.tokenPath, token.WithStorage(b.storage))
+	if err != nil {
+		return TokenStatus{}, err
+	}
+
+	status := TokenStatus{
+		Username:         s.username,
+		ProviderID:       authInfo.UserInfo.ProviderID,
+		HasLocalPassword: passwordFileExists(s),
+		DeviceRegistered: len(authInfo.DeviceRegistrationData) > 0,
+		UserIsDisabled:   authInfo.UserIsDisabled,
+		DeviceIsDisabled: authInfo.DeviceIsDisabled,
+	}
+	if authInfo.Token != nil {
+		status.Expiry = authInfo.Token.Expiry
+		status.HasRefreshToken = authInfo.Token.RefreshToken != ""
+	}
+	if r, ok := b.lastBackgroundRefresh(s.username); ok {
+		status.LastBackgroundRefresh = r.time
+		if r.err != nil {
+			status.BackgroundRefreshError = r.err.Error()
+		}
+	}
+
+	return status, nil
+}
+
+// Logout removes the token and the local password cached for the user, so that they have to authenticate with the
+// provider on their next login.
+func (b *Broker) Logout(username string) error {
+	s, err := b.cachedSession(username)
+	if err != nil {
+		return err
+	}
+
+	if !tokenExists(s) && !passwordFileExists(s) {
+		return ErrNoCachedToken
+	}
+	for _, path := range []string{s.tokenPath, s.passwordPath} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("could not remove %q: %v", path, err)
+		}
+	}
+	log.Noticef(context.Background(), "Removed the cached credentials of user %q", s.username)
+
+	return nil
+}
+
+// ClearCache drops the metadata and the signing keys of the issuers, so that they are retrieved again on the next
+// authentication.
+func (b *Broker) ClearCache() {
+	for _, iss := range b.issuers() {
+		iss.oidcServerMu.Lock()
+		iss.oidcServer = nil
+		iss.oidcServerMu.Unlock()
+		log.Debugf(context.Background(), "Cleared the cached metadata of OIDC provider %q", iss.url)
+	}
+}
+
+// Identity returns the description of the broker.
+func (b *Broker) Identity() Identity {
+	b.cfg.ownerMutex.RLock()
+	defer b.cfg.ownerMutex.RUnlock()
+
+	return Identity{
+		Provider: b.provider.DisplayName(),
+		Version:  consts.Version,
+		Issuer:   b.cfg.issuerURL,
+		ClientID: b.cfg.clientID,
+		Owner:    b.cfg.owner,
+	}
+}
+
+// CheckProvider checks that the users can authenticate with the provider, without any of them logging in: it fetches
+// the discovery document of the provider and, if the device authentication flow is enabled, requests a device code
+// which is never used. The requests are not retried, so that the checks reflect the current state of the connection.
+func (b *Broker) CheckProvider(ctx context.Context) []ProviderCheck {
+	discovery := ProviderCheck{Name: "discovery"}
+	device := ProviderCheck{Name: "device_code"}
+
+	discoveryCtx, cancel := context.WithTimeout(ctx, maxRequestDuration)
+	oidcServer, err := oidc.NewProvider(b.providerContext(discoveryCtx), b.cfg.issuerURL)
+	cancel()
+	if err != nil {
+		discovery.Status, discovery.Details = ProviderCheckFailed, err.Error()
+		device.Status, device.Details = ProviderCheckSkipped, "the discovery document could not be fetched"
+		return []ProviderCheck{discovery, device}
+	}
+	discovery.Status, discovery.Details = ProviderCheckOK, fmt.Sprintf("fetched the discovery document of %s", b.cfg.issuerURL)
+
+	switch {
+	case !b.cfg.flows.DeviceAuth:
+		device.Status, device.Details = ProviderCheckSkipped, "the device authentication flow is disabled"
+	case oidcServer.Endpoint().DeviceAuthURL == "":
+		device.Status, device.Details = ProviderCheckFailed, "the provider has no device authorization endpoint"
+	default:
+		cfg := b.oauth2Config(b.issuer, oidcServer)
+		var authOpts []oauth2.AuthCodeOption
+		// Same client authentication as when the users log in, see generateUILayout.
+		if cfg.ClientSecret != "" {
+			authOpts = append(authOpts, oauth2.SetAuthURLParam("client_secret", cfg.ClientSecret))
+		}
+
+		deviceCtx, cancel := context.WithTimeout(ctx, maxRequestDuration)
+		response, err := cfg.DeviceAuth(b.providerContext(deviceCtx), authOpts...)
+		cancel()
+		if err != nil {
+			device.Status, device.Details = ProviderCheckFailed, err.Error()
+			break
+		}
+		device.Status, device.Details = ProviderCheckOK, fmt.Sprintf("got a device code to enter at %s", response.VerificationURI)
+	}
+
+	return []ProviderCheck{discovery, device}
+}
+
+// cachedSession returns a session with the paths of the data cached for the user.
+func (b *Broker) cachedSession(username string) (session, error) {
+	if username == "" {
+		return session{}, errors.New("username is required")
+	}
+	username = b.provider.NormalizeUsername(username)
+	iss := b.issuerFor(username)
+
+	dir, err := b.userDataDir(iss, username)
+	if err != nil {
+		return session{}, err
+	}
+	// The data of the users migrated to the provider ID-keyed cache is behind a compatibility symlink.
+	if fi, err := os.Lstat(dir); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if dir, err = b.cacheSymlinkTarget(iss, dir); err != nil {
+			return session{}, fmt.Errorf("could not resolve the cache directory of user %q: %w", username, err)
+		}
+	}
+
+	s := session{username: username, issuer: iss}
+	setCachePaths(&s, dir)
+	return s, nil
+}
@@ -0,0 +1,235 @@
+package broker_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/consts"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+// setupUserCache writes the cached token and local password of a user in the data directory of the broker.
+func setupUserCache(t *testing.T, b *broker.Broker, username string, options tokenOptions, withPassword, migrated bool) string {
+	t.Helper()
+
+	dir, err := b.UserDataDir(username)
+	require.NoError(t, err, "Setup: UserDataDir should not have returned an error")
+	if migrated {
+		link := dir
+		dir, err = b.UserDataDir("saved-user-id")
+		require.NoError(t, err, "Setup: UserDataDir should not have returned an error")
+		err = os.MkdirAll(dir, 0700)
+		require.NoError(t, err, "Setup: could not create provider ID directory")
+		err = os.Symlink(filepath.Base(dir), link)
+		require.NoError(t, err, "Setup: could not create compatibility symlink")
+	}
+
+	options.username = username
+	generateAndStoreCachedInfo(t, options, filepath.Join(dir, "token.json"))
+	if withPassword {
+		err = os.WriteFile(filepath.Join(dir, "password"), []byte("hashed-password"), 0600)
+		require.NoError(t, err, "Setup: could not write password file")
+	}
+
+	return dir
+}
+
+func TestTokenStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		username     string
+		token        *tokenOptions
+		withPassword bool
+		migrated     bool
+
+		want    broker.TokenStatus
+		wantErr bool
+	}{
+		"Cached_token_and_local_password": {
+			token:        &tokenOptions{},
+			withPassword: true,
+			want:         broker.TokenStatus{HasRefreshToken: true, HasLocalPassword: true},
+		},
+		"Cached_token_without_refresh_token": {
+			token: &tokenOptions{noRefreshToken: true},
+		},
+		"Cached_token_for_device_registration": {
+			token: &tokenOptions{isForDeviceRegistration: true},
+			want:  broker.TokenStatus{HasRefreshToken: true, DeviceRegistered: true},
+		},
+		"Cached_token_of_disabled_user": {
+			token: &tokenOptions{userIsDisabled: true},
+			want:  broker.TokenStatus{HasRefreshToken: true, UserIsDisabled: true},
+		},
+		"Cached_token_of_user_migrated_to_provider_ID_cache": {
+			token:    &tokenOptions{},
+			migrated: true,
+			want:     broker.TokenStatus{HasRefreshToken: true},
+		},
+		"Username_is_normalized": {
+			username: "User@Example.com",
+			token:    &tokenOptions{},
+			want:     broker.TokenStatus{HasRefreshToken: true},
+		},
+
+		"Error_if_no_token_is_cached":  {wantErr: true},
+		"Error_if_token_is_invalid":    {token: &tokenOptions{invalid: true}, wantErr: true},
+		"Error_if_username_is_empty":   {username: "-", wantErr: true},
+		"Error_if_username_is_invalid": {username: "../user", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newBrokerForTests(t, &brokerForTestConfig{issuerURL: defaultIssuerURL})
+
+			const cachedName = "user@example.com"
+			if tc.token != nil {
+				setupUserCache(t, b, cachedName, *tc.token, tc.withPassword, tc.migrated)
+			}
+
+			username := tc.username
+			switch username {
+			case "":
+				username = cachedName
+			case "-":
+				username = ""
+			}
+
+			got, err := b.TokenStatus(username)
+			if tc.wantErr {
+				require.Error(t, err, "TokenStatus should have returned an error")
+				return
+			}
+			require.NoError(t, err, "TokenStatus should not have returned an error")
+
+			require.False(t, got.Expiry.IsZero(), "TokenStatus should return the expiry of the token")
+			got.Expiry = tc.want.Expiry
+			tc.want.Username = cachedName
+			tc.want.ProviderID = "saved-user-id"
+			require.Equal(t, tc.want, got, "TokenStatus should return the status of the cached token")
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		token        *tokenOptions
+		withPassword bool
+		migrated     bool
+
+		wantErr bool
+	}{
+		"Remove_token_and_local_password":                    {token: &tokenOptions{}, withPassword: true},
+		"Remove_token_without_local_password":                {token: &tokenOptions{}},
+		"Remove_credentials_of_user_migrated_to_provider_ID": {token: &tokenOptions{}, withPassword: true, migrated: true},
+
+		"Error_if_no_credentials_are_cached": {wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newBrokerForTests(t, &brokerForTestConfig{issuerURL: defaultIssuerURL})
+
+			const username = "user@example.com"
+			var dir string
+			if tc.token != nil {
+				dir = setupUserCache(t, b, username, *tc.token, tc.withPassword, tc.migrated)
+			}
+
+			err := b.Logout(username)
+			if tc.wantErr {
+				require.ErrorIs(t, err, broker.ErrNoCachedToken, "Logout should have returned ErrNoCachedToken")
+				return
+			}
+			require.NoError(t, err, "Logout should not have returned an error")
+
+			for _, name := range []string{"token.json", "password"} {
+				require.NoFileExists(t, filepath.Join(dir, name), "Logout should have removed the cached credentials")
+			}
+			require.DirExists(t, dir, "Logout should keep the user data directory")
+
+			_, err = b.TokenStatus(username)
+			require.ErrorIs(t, err, broker.ErrNoCachedToken, "No token should be cached after Logout")
+		})
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	t.Parallel()
+
+	b := newBrokerForTests(t, &brokerForTestConfig{
+		issuerURL:    defaultIssuerURL,
+		ownerAllowed: true,
+		owner:        "owner@example.com",
+	})
+
+	want := broker.Identity{
+		Provider: "the identity provider",
+		Version:  consts.Version,
+		Issuer:   defaultIssuerURL,
+		ClientID: "test-client-id",
+		Owner:    "owner@example.com",
+	}
+	require.Equal(t, want, b.Identity(), "Identity should describe the broker")
+}
+
+func TestCheckProvider(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		customHandlers         map[string]testutils.EndpointHandler
+		deviceAuthFlowDisabled bool
+
+		wantDiscovery  string
+		wantDeviceCode string
+	}{
+		"Successfully_check_the_provider": {wantDiscovery: broker.ProviderCheckOK, wantDeviceCode: broker.ProviderCheckOK},
+		"Skip_the_device_code_if_the_device_flow_is_disabled": {
+			deviceAuthFlowDisabled: true,
+			wantDiscovery:          broker.ProviderCheckOK,
+			wantDeviceCode:         broker.ProviderCheckSkipped,
+		},
+
+		"Error_when_the_discovery_document_can_not_be_fetched": {
+			customHandlers: map[string]testutils.EndpointHandler{
+				"/.well-known/openid-configuration": testutils.UnavailableHandler(),
+			},
+			wantDiscovery:  broker.ProviderCheckFailed,
+			wantDeviceCode: broker.ProviderCheckSkipped,
+		},
+		"Error_when_no_device_code_is_issued": {
+			customHandlers: map[string]testutils.EndpointHandler{
+				"/device_auth": testutils.UnavailableHandler(),
+			},
+			wantDiscovery:  broker.ProviderCheckOK,
+			wantDeviceCode: broker.ProviderCheckFailed,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newBrokerForTests(t, &brokerForTestConfig{
+				customHandlers:         tc.customHandlers,
+				deviceAuthFlowDisabled: tc.deviceAuthFlowDisabled,
+			})
+
+			checks := b.CheckProvider(context.Background())
+			require.Len(t, checks, 2, "CheckProvider should return the discovery and device code checks")
+			require.Equal(t, "discovery", checks[0].Name, "The first check should be the discovery one")
+			require.Equal(t, tc.wantDiscovery, checks[0].Status, "Unexpected status of the discovery check: %s", checks[0].Details)
+			require.Equal(t, "device_code", checks[1].Name, "The second check should be the device code one")
+			require.Equal(t, tc.wantDeviceCode, checks[1].Status, "Unexpected status of the device code check: %s", checks[1].Details)
+			require.NotEmpty(t, checks[1].Details, "The device code check should be explained")
+		})
+	}
+}
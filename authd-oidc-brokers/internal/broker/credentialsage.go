@@ -0,0 +1,68 @@
+package broker
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
+	"github.com/canonical/authd/log"
+)
+
+// credentialsExpiryWarning is how long before their cached credentials expire the users are warned when they log in
+// with their local password.
+const credentialsExpiryWarning = 7 * 24 * time.Hour
+
+// credentialsExpiredMsg is displayed when the user can't log in with their local password anymore and can't
+// authenticate with the provider either, because the session is offline.
+const credentialsExpiredMsg = "You have to log in with %s again to keep using your local password. Connect to the network and try again."
+
+// credentialsExpiry returns when the user with authInfo can't log in with their local password anymore and has to
+// authenticate interactively with the provider again. ok is false if the age of the credentials is not limited.
+func (b *Broker) credentialsExpiry(authInfo *token.AuthCachedInfo) (expiry time.Time, ok bool) {
+	if b.cfg.maxCredentialsAge == 0 {
+		return time.Time{}, false
+	}
+	// The credentials cached before the interactive authentications were recorded have the zero time, so they are
+	// expired.
+	if authInfo.LastInteractiveAuth.IsZero() {
+		return time.Time{}, true
+	}
+	return authInfo.LastInteractiveAuth.Add(b.cfg.maxCredentialsAge), true
+}
+
+// credentialsExpired returns true if the user with authInfo has to authenticate interactively with the provider
+// before they can log in with their local password again.
+func (b *Broker) credentialsExpired(authInfo *token.AuthCachedInfo) bool {
+	expiry, ok := b.credentialsExpiry(authInfo)
+	return ok && !time.Now().Before(expiry)
+}
+
+// sessionCredentialsExpired returns true if the credentials cached for the user of the session expired.
+func (b *Broker) sessionCredentialsExpired(session session) bool {
+	if b.cfg.maxCredentialsAge == 0 || !tokenExists(session) {
+		return false
+	}
+	authInfo, err := token.LoadAuthInfo(session.tokenPath, token.WithStorage(b.storage))
+	if err != nil {
+		log.Warningf(context.Background(), "Could not load token: %v", err)
+		return false
+	}
+	return b.credentialsExpired(authInfo)
+}
+
+// credentialsExpiryWarningMessage returns the message counting down the days before the user with authInfo has to
+// authenticate interactively with the provider again, or an empty message if that's not due soon.
+func (b *Broker) credentialsExpiryWarningMessage(authInfo *token.AuthCachedInfo) (msg string, args []any) {
+	expiry, ok := b.credentialsExpiry(authInfo)
+	remaining := time.Until(expiry)
+	if !ok || remaining > credentialsExpiryWarning {
+		return "", nil
+	}
+
+	days := int(math.Ceil(remaining.Hours() / 24))
+	if days <= 1 {
+		return "You will have to log in with %s again within a day to keep using your local password.", []any{b.provider.DisplayName()}
+	}
+	return "You will have to log in with %s again within %d days to keep using your local password.", []any{b.provider.DisplayName(), days}
+}
@@ -0,0 +1,147 @@
+package broker_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/authmodes"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/sessionmode"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/password"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxCredentialsAge(t *testing.T) {
+	t.Parallel()
+
+	const (
+		correctPassword = "password"
+		day             = 24 * time.Hour
+		maxAge          = 30 * day
+	)
+
+	tests := map[string]struct {
+		maxCredentialsAge   time.Duration
+		lastInteractiveAuth time.Duration
+		noInteractiveAuth   bool
+		machineOffline      bool
+
+		wantPasswordMode bool
+		wantAuthModesErr string
+		wantMessage      string
+	}{
+		"Offer_local_password_if_user_authenticated_with_provider_recently": {
+			maxCredentialsAge: maxAge, lastInteractiveAuth: day, wantPasswordMode: true,
+		},
+		"Offer_local_password_if_age_of_credentials_is_not_limited": {
+			noInteractiveAuth: true, wantPasswordMode: true,
+		},
+		"Offer_local_password_offline_if_user_authenticated_with_provider_recently": {
+			maxCredentialsAge: maxAge, lastInteractiveAuth: day, machineOffline: true, wantPasswordMode: true,
+		},
+		"Warn_user_that_credentials_expire_in_a_few_days": {
+			maxCredentialsAge: maxAge, lastInteractiveAuth: 25 * day, wantPasswordMode: true,
+			wantMessage: "You will have to log in with the identity provider again within 5 days to keep using your local password.",
+		},
+		"Warn_user_that_credentials_expire_within_a_day": {
+			maxCredentialsAge: maxAge, lastInteractiveAuth: maxAge - time.Hour, wantPasswordMode: true,
+			wantMessage: "You will have to log in with the identity provider again within a day to keep using your local password.",
+		},
+		"Warn_user_offline_that_credentials_expire_in_a_few_days": {
+			maxCredentialsAge: maxAge, lastInteractiveAuth: 25 * day, machineOffline: true, wantPasswordMode: true,
+			wantMessage: "You will have to log in with the identity provider again within 5 days to keep using your local password.",
+		},
+
+		"Require_authentication_with_provider_if_credentials_expired": {
+			maxCredentialsAge: maxAge, lastInteractiveAuth: maxAge + day,
+		},
+		"Require_authentication_with_provider_if_credentials_predate_the_policy": {
+			maxCredentialsAge: maxAge, noInteractiveAuth: true,
+		},
+		"Error_if_credentials_expired_and_machine_is_offline": {
+			maxCredentialsAge: maxAge, lastInteractiveAuth: maxAge + day, machineOffline: true,
+			wantAuthModesErr: "You have to log in with the identity provider again to keep using your local password. Connect to the network and try again.",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			connectivity := &connectivityMock{}
+			connectivity.offline.Store(tc.machineOffline)
+			b := newBrokerForTests(t, &brokerForTestConfig{
+				maxCredentialsAge: tc.maxCredentialsAge,
+				connectivity:      connectivity,
+				allUsersAllowed:   true,
+			})
+
+			sessionID, key := newSessionForTests(t, b, "", sessionmode.Login)
+			cached := generateCachedInfo(t, tokenOptions{})
+			if !tc.noInteractiveAuth {
+				cached.LastInteractiveAuth = time.Now().Add(-tc.lastInteractiveAuth)
+			}
+			err := token.CacheAuthInfo(b.TokenPathForSession(sessionID), cached)
+			require.NoError(t, err, "Setup: CacheAuthInfo should not have returned an error")
+			err = password.HashAndStorePassword(correctPassword, b.PasswordFilepathForSession(sessionID))
+			require.NoError(t, err, "Setup: HashAndStorePassword should not have returned an error")
+
+			modes, err := b.GetAuthenticationModes(sessionID, []map[string]string{supportedUILayouts["form"], supportedUILayouts["qrcode"]})
+			if tc.wantAuthModesErr != "" {
+				require.EqualError(t, err, tc.wantAuthModesErr, "GetAuthenticationModes should return the expected error")
+				return
+			}
+			require.NoError(t, err, "GetAuthenticationModes should not return an error")
+			require.NotEmpty(t, modes, "GetAuthenticationModes should return at least one mode")
+			if !tc.wantPasswordMode {
+				require.NotEqual(t, authmodes.Password, modes[0]["id"], "Local password authentication should not be offered")
+				return
+			}
+			require.Equal(t, authmodes.Password, modes[0]["id"], "Local password authentication should be offered first")
+
+			updateAuthModes(t, b, sessionID, authmodes.Password)
+			authData := fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, encryptSecret(t, correctPassword, key))
+			access, data, err := b.IsAuthenticated(sessionID, authData)
+			require.NoError(t, err, "IsAuthenticated should not return an error")
+			require.Equal(t, broker.AuthGranted, access, "IsAuthenticated should grant access")
+
+			var got struct {
+				Message string `json:"message"`
+			}
+			err = json.Unmarshal([]byte(data), &got)
+			require.NoError(t, err, "IsAuthenticated should return valid JSON data")
+			require.Equal(t, tc.wantMessage, got.Message, "IsAuthenticated should return the expected message")
+
+			// Logging in with the local password must not extend the validity of the credentials, even if the token
+			// was refreshed.
+			stored, err := token.LoadAuthInfo(b.TokenPathForSession(sessionID))
+			require.NoError(t, err, "Setup: LoadAuthInfo should not have returned an error")
+			require.True(t, cached.LastInteractiveAuth.Equal(stored.LastInteractiveAuth), "The last interactive authentication should not change")
+		})
+	}
+}
+
+func TestLastInteractiveAuthIsRecorded(t *testing.T) {
+	t.Parallel()
+
+	b := newBrokerForTests(t, &brokerForTestConfig{allUsersAllowed: true})
+	sessionID, key := newSessionForTests(t, b, "", sessionmode.Login)
+
+	before := time.Now()
+	updateAuthModes(t, b, sessionID, authmodes.DeviceQr)
+	access, _, err := b.IsAuthenticated(sessionID, "{}")
+	require.NoError(t, err, "IsAuthenticated should not return an error")
+	require.Equal(t, broker.AuthNext, access, "The device authentication should ask for a new local password")
+
+	updateAuthModes(t, b, sessionID, authmodes.NewPassword)
+	authData := fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, encryptSecret(t, "password", key))
+	access, _, err = b.IsAuthenticated(sessionID, authData)
+	require.NoError(t, err, "IsAuthenticated should not return an error")
+	require.Equal(t, broker.AuthGranted, access, "IsAuthenticated should grant access")
+
+	stored, err := token.LoadAuthInfo(b.TokenPathForSession(sessionID))
+	require.NoError(t, err, "Setup: LoadAuthInfo should not have returned an error")
+	require.False(t, stored.LastInteractiveAuth.Before(before), "The device authentication should be recorded as the last interactive authentication")
+}
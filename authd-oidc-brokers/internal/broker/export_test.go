@@ -2,6 +2,7 @@ package broker
 
 import (
 	"sync"
+	"time"
 
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/msentraid/himmelblau"
 )
@@ -12,6 +13,9 @@ var (
 	IsPromptMethod = isPromptMethod
 )
 
+// IsTransientProviderError exposes the classification of the errors of the provider which are retried for tests.
+var IsTransientProviderError = isTransientProviderError
+
 func (cfg *Config) Init() {
 	cfg.ownerMutex = &sync.RWMutex{}
 	cfg.flows = defaultFlowsConfig()
@@ -37,6 +41,43 @@ func (cfg *Config) SetRegisterDevice(value bool) {
 	cfg.registerDevice = value
 }
 
+func (cfg *Config) SetFIPSMode(value bool) {
+	cfg.fipsMode = value
+}
+
+func (cfg *Config) SetClientCertificate(certPath, key string) {
+	cfg.network.clientCertificate = certPath
+	cfg.network.clientKey = key
+}
+
+// AddIssuer adds an issuer whose users are the ones with a username in one of the domains.
+func (cfg *Config) AddIssuer(name, issuerURL, clientID string, domains []string) {
+	cfg.issuers = append(cfg.issuers, issuerConfig{name: name, issuerURL: issuerURL, clientID: clientID, domains: domains})
+}
+
+func (cfg *Config) SetMaxCredentialsAge(maxAge time.Duration) {
+	cfg.maxCredentialsAge = maxAge
+}
+
+func (cfg *Config) SetPasswordPolicy(minLength int, requiredClasses []string, dictionaryFile string, history int, maxAge time.Duration) {
+	cfg.passwordPolicy = passwordPolicy{
+		minLength:       minLength,
+		requiredClasses: requiredClasses,
+		dictionaryFile:  dictionaryFile,
+		history:         history,
+		maxAge:          maxAge,
+	}
+}
+
+func (cfg *Config) SetTokenRefresh(interval, jitter time.Duration) {
+	cfg.tokenRefreshInterval = interval
+	cfg.tokenRefreshJitter = jitter
+}
+
+func (cfg *Config) SetSecretStorage(backend string) {
+	cfg.secretStorage = backend
+}
+
 func (cfg *Config) SetHomeBaseDir(homeBaseDir string) {
 	cfg.homeBaseDir = homeBaseDir
 }
@@ -78,6 +119,23 @@ func (cfg *Config) SetOwnerExtraGroups(ownerExtraGroups []string) {
 	cfg.ownerExtraGroups = ownerExtraGroups
 }
 
+func (cfg *Config) SetGroupsLimit(maxGroups int, priorityGroups []string) {
+	cfg.maxGroups = maxGroups
+	cfg.priorityGroups = priorityGroups
+}
+
+func (cfg *Config) SetSessionEnv(sessionEnv map[string]string) {
+	cfg.sessionEnv = sessionEnv
+}
+
+func (cfg *Config) SetSSHKeysClaim(claim string) {
+	cfg.sshKeysClaim = claim
+}
+
+func (cfg *Config) SetSessionTokenScopes(scopes []string) {
+	cfg.sessionTokenScopes = scopes
+}
+
 func (cfg *Config) SetAllowedSSHSuffixes(allowedSSHSuffixes []string) {
 	cfg.allowedSSHSuffixes = allowedSSHSuffixes
 }
@@ -88,6 +146,10 @@ func (cfg *Config) SetFlows(deviceAuth, entraPassword bool) {
 	cfg.flows.EntraPassword = entraPassword
 }
 
+func (cfg *Config) SetBrowserFlow(enabled bool) {
+	cfg.flows.Browser = enabled
+}
+
 func (cfg *Config) SetProvider(provider provider) {
 	cfg.provider = provider
 }
@@ -146,7 +208,7 @@ func (b *Broker) DataDir() string {
 
 // UserDataDir exposes the broker's userDataDir method for tests.
 func (b *Broker) UserDataDir(username string) (string, error) {
-	return b.userDataDir(username)
+	return b.userDataDir(b.issuerFor(username), username)
 }
 
 // EnsuredCachePaths captures the cache-related fields of a session after running
@@ -162,7 +224,7 @@ type EnsuredCachePaths struct {
 // rooted at currentDataDir, runs ensureProviderIDCacheDir for providerID and
 // returns the resulting cache-related session fields.
 func (b *Broker) EnsureProviderIDCacheDir(username, currentDataDir, providerID string) EnsuredCachePaths {
-	s := &session{username: username}
+	s := &session{username: username, issuer: b.issuerFor(username)}
 	setCachePaths(s, currentDataDir)
 	b.ensureProviderIDCacheDir(s, providerID)
 	return EnsuredCachePaths{
@@ -173,6 +235,15 @@ func (b *Broker) EnsureProviderIDCacheDir(username, currentDataDir, providerID s
 	}
 }
 
+// SessionIssuerURL returns the URL of the issuer the user of the session authenticates with.
+func (b *Broker) SessionIssuerURL(sessionID string) (string, error) {
+	session, err := b.getSession(sessionID)
+	if err != nil {
+		return "", err
+	}
+	return session.issuer.url, nil
+}
+
 // NormalizedIssuer exposes the broker's normalizedIssuer method for tests.
 func (b *Broker) NormalizedIssuer(issuerURL string) string {
 	return normalizedIssuer(issuerURL)
@@ -223,6 +294,19 @@ func (b *Broker) IsOffline(sessionID string) (bool, error) {
 	return session.isOffline, nil
 }
 
+// UsesSharedOIDCServer returns whether the given session uses the OIDC provider shared by the sessions or an error if
+// the session does not exist.
+func (b *Broker) UsesSharedOIDCServer(sessionID string) (bool, error) {
+	session, err := b.getSession(sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	session.issuer.oidcServerMu.Lock()
+	defer session.issuer.oidcServerMu.Unlock()
+	return session.oidcServer != nil && session.oidcServer == session.issuer.oidcServer, nil
+}
+
 func (b *Broker) SetAttemptsPerMode(sessionID, mode string, attempts int) error {
 	s, err := b.getSession(sessionID)
 	if err != nil {
@@ -257,3 +341,6 @@ func (b *Broker) SetSessionMFAFlowActive(sessionID string, flow *himmelblau.MFAF
 	s.mfaFlowActive = flow
 	return b.updateSession(sessionID, s)
 }
+
+// ClaimPicture exposes the download of the picture of the picture claim for tests.
+var ClaimPicture = claimPicture
@@ -8,6 +8,7 @@ import (
 	"encoding/base64"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -31,6 +32,7 @@ type brokerForTestConfig struct {
 	registerDevice               bool
 	deviceAuthFlowDisabled       bool
 	entraPasswordFlowDisabled    bool
+	browserFlowEnabled           bool
 	allowedUsers                 map[string]struct{}
 	allUsersAllowed              bool
 	ownerAllowed                 bool
@@ -38,10 +40,20 @@ type brokerForTestConfig struct {
 	owner                        string
 	extraGroups                  []string
 	ownerExtraGroups             []string
+	maxGroups                    int
+	priorityGroups               []string
+	sessionEnv                   map[string]string
+	sshKeysClaim                 string
+	sessionTokenScopes           []string
+	maxCredentialsAge            time.Duration
+	tokenRefreshInterval         time.Duration
+	secretStorage                string
 	homeBaseDir                  string
 	allowedSSHSuffixes           []string
 	provider                     providers.Provider
 	apiVersion                   uint
+	connectivity                 *connectivityMock
+	providerRetries              int
 
 	getGroupsFails                bool
 	supportsDeviceRegistration    bool
@@ -58,6 +70,8 @@ type brokerForTestConfig struct {
 	listenAddress       string
 	tokenHandlerOptions *testutils.TokenHandlerOptions
 	customHandlers      map[string]testutils.EndpointHandler
+	// transientErrors is the number of requests to each endpoint which fail with a 503 status before it answers.
+	transientErrors map[string]int
 }
 
 func brokerProviderWithOptionalCapabilities(provider *testutils.MockProvider, cfg *brokerForTestConfig) providers.Provider {
@@ -101,6 +115,9 @@ func newBrokerForTests(t *testing.T, cfg *brokerForTestConfig) (b *broker.Broker
 	if cfg.deviceAuthFlowDisabled || cfg.entraPasswordFlowDisabled {
 		cfg.SetFlows(!cfg.deviceAuthFlowDisabled, !cfg.entraPasswordFlowDisabled)
 	}
+	if cfg.browserFlowEnabled {
+		cfg.SetBrowserFlow(cfg.browserFlowEnabled)
+	}
 	if cfg.homeBaseDir != "" {
 		cfg.SetHomeBaseDir(cfg.homeBaseDir)
 	}
@@ -128,6 +145,27 @@ func newBrokerForTests(t *testing.T, cfg *brokerForTestConfig) (b *broker.Broker
 	if cfg.ownerExtraGroups != nil {
 		cfg.SetOwnerExtraGroups(cfg.ownerExtraGroups)
 	}
+	if cfg.maxGroups != 0 {
+		cfg.SetGroupsLimit(cfg.maxGroups, cfg.priorityGroups)
+	}
+	if cfg.sessionEnv != nil {
+		cfg.SetSessionEnv(cfg.sessionEnv)
+	}
+	if cfg.sshKeysClaim != "" {
+		cfg.SetSSHKeysClaim(cfg.sshKeysClaim)
+	}
+	if cfg.sessionTokenScopes != nil {
+		cfg.SetSessionTokenScopes(cfg.sessionTokenScopes)
+	}
+	if cfg.maxCredentialsAge != 0 {
+		cfg.SetMaxCredentialsAge(cfg.maxCredentialsAge)
+	}
+	if cfg.tokenRefreshInterval != 0 {
+		cfg.SetTokenRefresh(cfg.tokenRefreshInterval, 0)
+	}
+	if cfg.secretStorage != "" {
+		cfg.SetSecretStorage(cfg.secretStorage)
+	}
 
 	provider := cfg.provider
 	if provider == nil {
@@ -161,6 +199,9 @@ func newBrokerForTests(t *testing.T, cfg *brokerForTestConfig) (b *broker.Broker
 		for endpoint, handler := range cfg.customHandlers {
 			serverOpts = append(serverOpts, testutils.WithHandler(endpoint, handler))
 		}
+		for endpoint, n := range cfg.transientErrors {
+			serverOpts = append(serverOpts, testutils.WithTransientErrors(endpoint, n))
+		}
 		issuerURL, cleanup := testutils.StartMockProviderServer(
 			cfg.listenAddress,
 			cfg.tokenHandlerOptions,
@@ -175,7 +216,13 @@ func newBrokerForTests(t *testing.T, cfg *brokerForTestConfig) (b *broker.Broker
 		apiVersion = cfg.apiVersion
 	}
 
-	b, err := broker.New(cfg.Config, apiVersion, broker.WithCustomProvider(provider))
+	// The requests failing with a transient error are only retried by the tests which ask for it, so that the other
+	// ones don't wait for the retries.
+	opts := []broker.Option{broker.WithCustomProvider(provider), broker.WithProviderRetries(cfg.providerRetries, time.Millisecond)}
+	if cfg.connectivity != nil {
+		opts = append(opts, broker.WithConnectivityMonitor(cfg.connectivity))
+	}
+	b, err := broker.New(cfg.Config, apiVersion, opts...)
 	require.NoError(t, err, "Setup: New should not have returned an error")
 	return b
 }
@@ -365,3 +412,17 @@ func writeTrashToken(t *testing.T, path string) {
 	err = os.WriteFile(path, content, 0600)
 	require.NoError(t, err, "Setup: writing trash token should not have failed")
 }
+
+// connectivityMock reports the network state set by the test.
+type connectivityMock struct {
+	offline atomic.Bool
+	portal  atomic.Bool
+}
+
+func (m *connectivityMock) Offline() bool {
+	return m.offline.Load()
+}
+
+func (m *connectivityMock) CaptivePortal() bool {
+	return m.portal.Load()
+}
@@ -0,0 +1,47 @@
+package broker
+
+import (
+	"context"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/authmodes"
+	providerErrors "github.com/canonical/authd/authd-oidc-brokers/internal/providers/errors"
+	"github.com/canonical/authd/log"
+)
+
+// routeRequiredInteraction routes a token refresh which the provider rejected because the user has to interact with
+// it (change an expired password, complete the multi-factor authentication...) to a response telling the user what to
+// do. The interaction happens in the browser, so the user is redirected to the device code flow.
+func (b *Broker) routeRequiredInteraction(interaction providerErrors.Interaction, session *session) (string, isAuthenticatedDataResponse) {
+	provider := b.provider.DisplayName()
+
+	switch interaction {
+	case providerErrors.InteractionPasswordExpired:
+		log.Noticef(context.Background(), "Password of user %q expired in %s", session.username, provider)
+		if b.cfg.flows.DeviceAuth {
+			// The user changes their password in the browser, and then chooses a new local password, which must differ from
+			// the current one, as it's likely the same as the expired one.
+			session.providerPasswordExpired = true
+			session.nextAuthModes = []string{authmodes.Device, authmodes.DeviceQr}
+			return AuthNext, errorMessage{
+				Message:       "Your password in %s has expired. Change it using the device code flow, then choose a new local password.",
+				args:          []any{provider},
+				PasswordReset: true,
+			}
+		}
+		return AuthDenied, errorMessage{Message: "Your password in %s has expired. Please change it and try again.", args: []any{provider}}
+	case providerErrors.InteractionMFA:
+		log.Noticef(context.Background(), "Multi-factor authentication required by %s for user %q", provider, session.username)
+		if b.cfg.flows.DeviceAuth {
+			session.nextAuthModes = []string{authmodes.Device, authmodes.DeviceQr}
+			return AuthNext, errorMessage{Message: "Multi-factor authentication is required by %s. Please complete it using the device code flow.", args: []any{provider}}
+		}
+		return AuthDenied, errorMessage{Message: "Multi-factor authentication is required by %s, but the device code flow is disabled. Please contact your administrator.", args: []any{provider}}
+	default:
+		log.Noticef(context.Background(), "Interaction with %s required for user %q", provider, session.username)
+		if b.cfg.flows.DeviceAuth {
+			session.nextAuthModes = []string{authmodes.Device, authmodes.DeviceQr}
+			return AuthNext, errorMessage{Message: "An interactive sign-in is required by %s. Please authenticate using the device code flow.", args: []any{provider}}
+		}
+		return AuthDenied, errorMessage{Message: "An interactive sign-in is required by %s, but the device code flow is disabled. Please contact your administrator.", args: []any{provider}}
+	}
+}
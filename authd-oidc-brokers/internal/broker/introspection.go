@@ -0,0 +1,124 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
+	"github.com/canonical/authd/log"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// errTokenNotActive is returned when the token introspection endpoint reports that the access token is not active,
+// e.g. because the user was disabled or their sessions were revoked.
+var errTokenNotActive = errors.New("the access token is not active")
+
+// mapClaimer is a Claimer of the claims of a decoded JSON object.
+type mapClaimer map[string]interface{}
+
+// Claims decodes the claims into v.
+func (m mapClaimer) Claims(v any) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// additionalClaims returns the claims of the ID token merged with the ones of the other endpoints of the provider
+// which csr reads them from.
+func (b *Broker) additionalClaims(ctx context.Context, session *session, token *oauth2.Token, idToken *oidc.IDToken, csr providers.ClaimSourcesReader) (info.Claimer, error) {
+	claimers := []info.Claimer{idToken}
+
+	if csr.ReadsIntrospectionEndpoint() {
+		introspectionClaims, err := b.introspectionClaims(ctx, session, token, idToken.Subject)
+		if err != nil {
+			return nil, err
+		}
+		if introspectionClaims != nil {
+			claimers = append(claimers, introspectionClaims)
+		}
+	}
+
+	if csr.ReadsUserInfoEndpoint() {
+		userInfoClaims, err := b.userInfoEndpointClaims(ctx, session, token, idToken.Subject)
+		if err != nil {
+			return nil, err
+		}
+		claimers = append(claimers, userInfoClaims)
+	}
+
+	claims, err := info.NewMergedClaimer(claimers...)
+	if err != nil {
+		return nil, fmt.Errorf("could not merge the claims of the ID token and of the provider: %w", err)
+	}
+	return claims, nil
+}
+
+// introspectionClaims returns the claims of the access token returned by the token introspection endpoint of the
+// provider, or nil if the endpoint can't be queried. The endpoint requires the broker to authenticate as the client,
+// so it's only queried when the client secret is set.
+func (b *Broker) introspectionClaims(ctx context.Context, session *session, token *oauth2.Token, subject string) (info.Claimer, error) {
+	if session.issuer.clientSecret == "" {
+		log.Debugf(ctx, "Not reading the claims of the token introspection endpoint: no client secret")
+		return nil, nil
+	}
+
+	var metadata struct {
+		IntrospectionEndpoint string `json:"introspection_endpoint"`
+	}
+	if err := session.oidcServer.Claims(&metadata); err != nil {
+		return nil, fmt.Errorf("could not read the metadata of the provider: %w", err)
+	}
+	if metadata.IntrospectionEndpoint == "" {
+		log.Warningf(ctx, "Not reading the claims of the token introspection endpoint: the provider has none")
+		return nil, nil
+	}
+
+	form := url.Values{"token": {token.AccessToken}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.IntrospectionEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("could not create the token introspection request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// RFC 6749 §2.3.1: the client credentials are form-encoded before being used as the basic authentication.
+	req.SetBasicAuth(url.QueryEscape(session.issuer.oidcCfg.ClientID), url.QueryEscape(session.issuer.clientSecret))
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not query the token introspection endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("could not read the response of the token introspection endpoint: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token introspection endpoint returned %s: %s", resp.Status, body)
+	}
+
+	var claims mapClaimer
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return nil, fmt.Errorf("could not decode the response of the token introspection endpoint: %w", err)
+	}
+	if active, _ := claims["active"].(bool); !active {
+		return nil, errTokenNotActive
+	}
+	// Like for the UserInfo endpoint, the claims of another subject must not replace the ones of the ID token.
+	if sub, _ := claims["sub"].(string); sub != "" && sub != subject {
+		return nil, fmt.Errorf("introspection sub %q does not match ID token sub %q: rejecting potential identity substitution", sub, subject)
+	}
+	delete(claims, "active")
+
+	return claims, nil
+}
@@ -0,0 +1,102 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestIntrospectionClaims(t *testing.T) {
+	t.Parallel()
+
+	const activeResponse = `{"active": true, "sub": "sub123", "client_id": "client-id", "roles": ["developers"]}`
+
+	tests := map[string]struct {
+		noClientSecret        bool
+		noEndpoint            bool
+		introspectionStatus   int
+		introspectionResponse string
+
+		wantClaims map[string]interface{}
+		wantErr    bool
+	}{
+		"Returns_the_claims_of_the_access_token": {
+			wantClaims: map[string]interface{}{"sub": "sub123", "client_id": "client-id", "roles": []interface{}{"developers"}},
+		},
+		"Returns_the_claims_of_the_access_token_without_subject": {
+			introspectionResponse: `{"active": true, "roles": ["developers"]}`,
+			wantClaims:            map[string]interface{}{"roles": []interface{}{"developers"}},
+		},
+		"Returns_no_claims_without_client_secret":                         {noClientSecret: true},
+		"Returns_no_claims_if_the_provider_has_no_introspection_endpoint": {noEndpoint: true},
+
+		"Error_if_the_token_is_not_active":                 {introspectionResponse: `{"active": false}`, wantErr: true},
+		"Error_if_the_subject_is_not_the_one_of_the_token": {introspectionResponse: `{"active": true, "sub": "other-sub"}`, wantErr: true},
+		"Error_if_the_endpoint_returns_an_error":           {introspectionStatus: http.StatusUnauthorized, wantErr: true},
+		"Error_if_the_response_is_not_JSON":                {introspectionResponse: "not json", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.introspectionStatus == 0 {
+				tc.introspectionStatus = http.StatusOK
+			}
+			if tc.introspectionResponse == "" {
+				tc.introspectionResponse = activeResponse
+			}
+
+			mux := http.NewServeMux()
+			server := httptest.NewServer(mux)
+			t.Cleanup(server.Close)
+
+			mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+				introspectionEndpoint := ""
+				if !tc.noEndpoint {
+					introspectionEndpoint = server.URL + "/introspect"
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = fmt.Fprintf(w, `{"issuer": %q, "introspection_endpoint": %q}`, server.URL, introspectionEndpoint)
+			})
+			mux.HandleFunc("/introspect", func(w http.ResponseWriter, r *http.Request) {
+				clientID, clientSecret, ok := r.BasicAuth()
+				if !ok || clientID != "client-id" || clientSecret != "client-secret" || r.FormValue("token") != "access-token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(tc.introspectionStatus)
+				_, _ = w.Write([]byte(tc.introspectionResponse))
+			})
+
+			oidcServer, err := oidc.NewProvider(context.Background(), server.URL)
+			require.NoError(t, err, "Setup: could not create the OIDC provider")
+
+			b := &Broker{httpClient: server.Client()}
+			iss := &issuer{oidcCfg: oidc.Config{ClientID: "client-id"}, clientSecret: "client-secret"}
+			if tc.noClientSecret {
+				iss.clientSecret = ""
+			}
+
+			claims, err := b.introspectionClaims(context.Background(), &session{issuer: iss, oidcServer: oidcServer}, &oauth2.Token{AccessToken: "access-token"}, "sub123")
+			if tc.wantErr {
+				require.Error(t, err, "introspectionClaims should return an error")
+				return
+			}
+			require.NoError(t, err, "introspectionClaims should not return an error")
+
+			if tc.wantClaims == nil {
+				require.Nil(t, claims, "introspectionClaims should return no claims")
+				return
+			}
+			var got map[string]interface{}
+			require.NoError(t, claims.Claims(&got), "Claims should not return an error")
+			require.Equal(t, tc.wantClaims, got, "introspectionClaims should return the claims of the access token")
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/genericprovider"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"gopkg.in/ini.v1"
+)
+
+// issuerConfig holds a parsed [issuer <name>] section: an issuer whose users are authenticated in addition to the
+// ones of the issuer of the [oidc] section.
+type issuerConfig struct {
+	// name is the name of the section, without the "issuer " prefix.
+	name         string
+	issuerURL    string
+	clientID     string
+	clientSecret string
+	// domains are the lowercase domains of the usernames of the users of the issuer, which also match their
+	// subdomains.
+	domains []string
+	// claims is the mapping of the claims of the issuer, defaulting to the one of the [users] section.
+	claims genericprovider.ClaimMapping
+}
+
+// issuer is an issuer the users authenticate with.
+type issuer struct {
+	// name is the name of the [issuer <name>] section, which is empty for the issuer of the [oidc] section.
+	name     string
+	url      string
+	clientID string
+	// oidcCfg is the configuration of the OIDC client, whose client ID is the one of the Microsoft Broker App
+	// instead of clientID when the device is registered.
+	oidcCfg oidc.Config
+	// clientSecret is the client secret sent when authenticating the users, which is empty for public clients.
+	clientSecret string
+	domains      []string
+	// registerDevice is true if the device is registered with the issuer when the users log in, which is only
+	// supported for the issuer of the [oidc] section.
+	registerDevice bool
+	// provider reads the information of the users of the issuer. Each issuer has its own, as the providers hold the
+	// mapping of the claims and the client secret of the issuer.
+	provider providers.Provider
+
+	// oidcServer is the last OIDC provider discovered for the issuer. It's shared by the sessions for which the
+	// discovery returns the same metadata, so that the signing keys it fetched from the JWKS endpoint are reused.
+	oidcServer   *oidc.Provider
+	oidcServerMu sync.Mutex
+}
+
+// issuerSectionName returns the name of the issuer of the section name, and whether it's the one of an
+// [issuer <name>] section.
+func issuerSectionName(sectionName string) (string, bool) {
+	name, ok := strings.CutPrefix(sectionName, issuerSectionPrefix)
+	return strings.TrimSpace(name), ok
+}
+
+// validateIssuerSection checks the values of an [issuer <name>] section.
+func validateIssuerSection(path string, section *ini.Section) error {
+	if name, _ := issuerSectionName(section.Name()); name == "" {
+		return fmt.Errorf("error parsing section %q in config file %q: the name of the issuer is empty", section.Name(), path)
+	}
+	for _, domain := range section.Key(domainsKey).Strings(",") {
+		if d := strings.TrimPrefix(domain, "@"); d == "" || strings.ContainsAny(d, "@/ ") {
+			return fmt.Errorf("error parsing '%s' in section %q in config file %q: invalid domain %q", domainsKey, section.Name(), path, domain)
+		}
+	}
+	return nil
+}
+
+// parseIssuerSections parses the [issuer <name>] sections of the config, which were already validated. The claims
+// which are not set in a section are the ones of usersClaims.
+func parseIssuerSections(iniCfg *ini.File, usersClaims genericprovider.ClaimMapping) (issuers []issuerConfig) {
+	for _, section := range iniCfg.Sections() {
+		name, ok := issuerSectionName(section.Name())
+		if !ok {
+			continue
+		}
+
+		var domains []string
+		for _, domain := range section.Key(domainsKey).Strings(",") {
+			domains = append(domains, strings.ToLower(strings.TrimPrefix(domain, "@")))
+		}
+
+		issuers = append(issuers, issuerConfig{
+			name:         name,
+			issuerURL:    section.Key(issuerKey).String(),
+			clientID:     section.Key(clientIDKey).String(),
+			clientSecret: strings.TrimSpace(section.Key(clientSecret).String()),
+			domains:      domains,
+			claims: genericprovider.ClaimMapping{
+				Username: section.Key(usernameClaimKey).MustString(usersClaims.Username),
+				UIDHint:  section.Key(uidClaimKey).MustString(usersClaims.UIDHint),
+				Home:     section.Key(homeClaimKey).MustString(usersClaims.Home),
+				Shell:    section.Key(shellClaimKey).MustString(usersClaims.Shell),
+				Gecos:    section.Key(gecosClaimKey).MustString(usersClaims.Gecos),
+				Groups:   section.Key(groupsClaimKey).MustString(usersClaims.Groups),
+			},
+		})
+	}
+	return issuers
+}
+
+// validateIssuersConfig checks that the [issuer <name>] sections of cfg can be used by the broker with the provider
+// p.
+func validateIssuersConfig(cfg userConfig, p providers.Provider) (err error) {
+	domains := make(map[string]string)
+	for _, ic := range cfg.issuers {
+		section := issuerSectionPrefix + ic.name
+		if ic.issuerURL == "" {
+			err = errors.Join(err, fmt.Errorf("issuer URL is required in [%s] and was not provided", section))
+		}
+		if ic.clientID == "" {
+			err = errors.Join(err, fmt.Errorf("client ID is required in [%s] and was not provided", section))
+		}
+		if len(ic.domains) == 0 {
+			err = errors.Join(err, fmt.Errorf(
+				"invalid configuration: %q is required in [%s], the users are routed to the issuer by the domains of their usernames",
+				domainsKey, section))
+		}
+		for _, domain := range ic.domains {
+			if other, ok := domains[domain]; ok {
+				err = errors.Join(err, fmt.Errorf("invalid configuration: domain %q is set in both [%s] and [%s]", domain, other, section))
+				continue
+			}
+			domains[domain] = section
+		}
+		if _, ok := providers.ProviderAs[providers.ClaimMappingSetter](p); ic.claims != cfg.claims && !ok {
+			err = errors.Join(err, fmt.Errorf(
+				"invalid configuration: the claims of the users are set in [%s], but %s can't read the users from other claims",
+				section, p.DisplayName()))
+		}
+	}
+	return err
+}
+
+// newIssuers returns the issuers of the [issuer <name>] sections of cfg, with the providers returned by newProvider.
+func newIssuers(cfg userConfig, newProvider func() providers.Provider, transport http.RoundTripper) []*issuer {
+	var issuers []*issuer
+	for _, ic := range cfg.issuers {
+		p := newProvider()
+		configureProvider(p, ic.clientSecret, ic.claims, transport)
+		issuers = append(issuers, &issuer{
+			name:         ic.name,
+			url:          ic.issuerURL,
+			clientID:     ic.clientID,
+			oidcCfg:      oidc.Config{ClientID: ic.clientID},
+			clientSecret: ic.clientSecret,
+			domains:      ic.domains,
+			provider:     p,
+		})
+	}
+	return issuers
+}
+
+// providerID returns the provider ID of a user whose ID at the issuer is id. The IDs of the users of the issuers of
+// [issuer <name>] sections are prefixed with the name of the section: the issuers don't share their namespace of IDs,
+// and authd matches the users who log in again by their provider ID, whatever their issuer.
+func (iss *issuer) providerID(id string) string {
+	if iss.name == "" || id == "" {
+		return id
+	}
+	return iss.name + ":" + id
+}
+
+// issuerFor returns the issuer of the user: the first additional issuer with a domain matching the one of the
+// username, or the issuer of the [oidc] section.
+func (b *Broker) issuerFor(username string) *issuer {
+	i := strings.LastIndex(username, "@")
+	if i < 0 {
+		return b.issuer
+	}
+	domain := strings.ToLower(username[i+1:])
+
+	for _, iss := range b.additionalIssuers {
+		if slices.ContainsFunc(iss.domains, func(d string) bool {
+			return domain == d || strings.HasSuffix(domain, "."+d)
+		}) {
+			return iss
+		}
+	}
+	return b.issuer
+}
+
+// issuers returns the issuer of the [oidc] section followed by the additional ones.
+func (b *Broker) issuers() []*issuer {
+	return append([]*issuer{b.issuer}, b.additionalIssuers...)
+}
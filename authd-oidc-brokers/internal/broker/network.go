@@ -0,0 +1,122 @@
+package broker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+	"gopkg.in/ini.v1"
+)
+
+// proxySchemes are the schemes of the proxies supported by the HTTP clients of the broker.
+var proxySchemes = map[string]struct{}{"http": {}, "https": {}, "socks5": {}}
+
+// networkConfig holds the parsed [network] section: the settings of the connections to the provider.
+type networkConfig struct {
+	// proxy is the URL of the proxy through which the provider is reached, with the credentials to authenticate with
+	// it if any. The proxy of the environment of the broker is used if it's empty.
+	proxy string
+	// noProxy is the comma-separated list of the hosts, domains and networks reached without the proxy, in the
+	// format of the NO_PROXY environment variable.
+	noProxy string
+	// caCertificates is the path of a PEM file with the certificates of the authorities trusted in addition to the
+	// ones of the system, e.g. the private CA of a TLS inspecting proxy.
+	caCertificates string
+	// clientCertificate is the path of the PEM certificate with which the broker authenticates to the provider
+	// with mutual TLS.
+	clientCertificate string
+	// clientKey is the path of the PEM private key of the client certificate, or the description of a key in the
+	// kernel keyring with it, prefixed with "keyring:".
+	clientKey string
+}
+
+// validateNetworkSection checks the values of the [network] section.
+func validateNetworkSection(path string, section *ini.Section) error {
+	if section.HasKey(proxyKey) {
+		if v := section.Key(proxyKey).String(); v != "" {
+			u, err := url.Parse(v)
+			if err != nil {
+				// The error of url.Parse contains the URL, which may contain the credentials of the proxy.
+				return fmt.Errorf("error parsing '%s' in config file %q: invalid URL", proxyKey, path)
+			}
+			if _, ok := proxySchemes[u.Scheme]; !ok || u.Host == "" {
+				return fmt.Errorf("error parsing '%s' in config file %q: the URL must be of the form http://[user:password@]host[:port], with an http, https or socks5 scheme", proxyKey, path)
+			}
+		}
+	}
+	for _, key := range []string{caCertificatesKey, clientCertificateKey} {
+		if p := section.Key(key).String(); p != "" && !filepath.IsAbs(p) {
+			return fmt.Errorf("error parsing '%s' in config file %q: %q is not an absolute path", key, path, p)
+		}
+	}
+	if k := section.Key(clientKeyKey).String(); k != "" {
+		description, inKeyring := strings.CutPrefix(k, keyringKeyPrefix)
+		if inKeyring && description == "" {
+			return fmt.Errorf("error parsing '%s' in config file %q: the description of the key in the kernel keyring is empty", clientKeyKey, path)
+		}
+		if !inKeyring && !filepath.IsAbs(k) {
+			return fmt.Errorf("error parsing '%s' in config file %q: %q is neither an absolute path nor a key of the kernel keyring", clientKeyKey, path, k)
+		}
+	}
+	return nil
+}
+
+// parseNetworkSection parses the [network] section, which was already validated.
+func parseNetworkSection(section *ini.Section) networkConfig {
+	return networkConfig{
+		proxy:             section.Key(proxyKey).String(),
+		noProxy:           section.Key(noProxyKey).String(),
+		caCertificates:    section.Key(caCertificatesKey).String(),
+		clientCertificate: section.Key(clientCertificateKey).String(),
+		clientKey:         section.Key(clientKeyKey).String(),
+	}
+}
+
+// newProviderTransport returns the transport of the HTTP requests to the provider, which goes through the proxy,
+// trusts the certificates and authenticates with the client certificate of the network configuration.
+func newProviderTransport(nc networkConfig) (*http.Transport, error) {
+	//nolint:forcetypeassert // The default transport of net/http is always an *http.Transport.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if nc.proxy != "" {
+		proxyFunc := (&httpproxy.Config{HTTPProxy: nc.proxy, HTTPSProxy: nc.proxy, NoProxy: nc.noProxy}).ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) { return proxyFunc(req.URL) }
+	}
+
+	var tlsConfig *tls.Config
+	if nc.caCertificates != "" || nc.clientCertificate != "" {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	if nc.caCertificates != "" {
+		pem, err := os.ReadFile(nc.caCertificates)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the CA certificates of '%s': %w", caCertificatesKey, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("could not load the CA certificates of the system: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificate found in %q set in '%s'", nc.caCertificates, caCertificatesKey)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if nc.clientCertificate != "" {
+		cert, err := newClientCertificate(nc.clientCertificate, nc.clientKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = cert.GetClientCertificate
+	}
+
+	return transport, nil
+}
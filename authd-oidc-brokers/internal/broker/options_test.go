@@ -1,10 +1,23 @@
 package broker
 
-import "github.com/canonical/authd/authd-oidc-brokers/internal/providers"
+import (
+	"time"
 
-// WithCustomProvider returns an option that sets a custom provider for the broker.
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
+)
+
+// WithCustomProvider returns an option that sets a custom provider for the broker, which is used for all its issuers.
 func WithCustomProvider(p providers.Provider) Option {
 	return func(o *option) {
 		o.provider = p
+		o.newProvider = func() providers.Provider { return p }
+	}
+}
+
+// WithProviderRetries returns an option that sets how many times the requests to the provider failing with a transient
+// error are retried, and the delay between the retries.
+func WithProviderRetries(retries int, backoff time.Duration) Option {
+	return func(o *option) {
+		o.retry = retryPolicy{retries: retries, backoff: backoff, maxBackoff: backoff}
 	}
 }
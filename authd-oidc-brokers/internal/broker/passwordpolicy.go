@@ -0,0 +1,166 @@
+package broker
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/sessionmode"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/password"
+	"github.com/canonical/authd/log"
+)
+
+// passwordExpiredMsg is displayed when the user logged in with a local password older than the max_age of the
+// password policy, before they are asked for a new one.
+const passwordExpiredMsg = "Your local password expired. Please choose a new one."
+
+// passwordPolicy holds the parsed [password_policy] section: the rules the local passwords must comply with when the
+// users set them.
+type passwordPolicy struct {
+	minLength       int
+	requiredClasses []string
+	dictionaryFile  string
+	// history is the number of previous passwords, including the current one, which can't be set again.
+	history int
+	maxAge  time.Duration
+}
+
+// characterClasses maps the classes of characters of required_classes to the message displayed when the password
+// doesn't contain any character of the class.
+var characterClasses = map[string]struct {
+	contains func(r rune) bool
+	msg      string
+}{
+	"lowercase": {unicode.IsLower, "Your password must contain a lowercase letter."},
+	"uppercase": {unicode.IsUpper, "Your password must contain an uppercase letter."},
+	"digit":     {unicode.IsDigit, "Your password must contain a digit."},
+	"other": {
+		func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) },
+		"Your password must contain a character which is neither a letter nor a digit.",
+	},
+}
+
+// passwordPolicyViolation returns the message explaining which rule of the password policy the new local password of
+// the user of the session breaks, or an empty message if it complies with all of them.
+func (b *Broker) passwordPolicyViolation(session *session, secret string) (msg string, args []any) {
+	pp := b.cfg.passwordPolicy
+
+	if n := utf8.RuneCountInString(secret); n < pp.minLength {
+		return "Your password must have at least %d characters.", []any{pp.minLength}
+	}
+
+	for _, name := range pp.requiredClasses {
+		class := characterClasses[name]
+		if !strings.ContainsFunc(secret, class.contains) {
+			return class.msg, nil
+		}
+	}
+
+	if pp.dictionaryFile != "" && isDictionaryWord(pp.dictionaryFile, secret) {
+		return "Your password must not be based on a dictionary word.", nil
+	}
+
+	if b.mustChangeCurrentPassword(session) && b.isCurrentPassword(session, secret) {
+		return "Your password must be different from your current password.", nil
+	}
+
+	if pp.history > 1 && b.isInPasswordHistory(session, secret) {
+		return "Your password must be different from your last %d passwords.", []any{pp.history}
+	}
+
+	return "", nil
+}
+
+// isDictionaryWord returns true if the password, ignoring the case and the characters which are not letters at its
+// start and end, is one of the words of the dictionary file, which has one word per line.
+func isDictionaryWord(dictionaryFile, secret string) bool {
+	word := strings.ToLower(strings.TrimFunc(secret, func(r rune) bool { return !unicode.IsLetter(r) }))
+	if word == "" {
+		return false
+	}
+
+	f, err := os.Open(dictionaryFile)
+	if err != nil {
+		log.Warningf(context.Background(), "Could not open the dictionary of the password policy, so the passwords are not checked against it: %v", err)
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.ToLower(strings.TrimSpace(scanner.Text())) == word {
+			return true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Warningf(context.Background(), "Could not read the dictionary of the password policy: %v", err)
+	}
+	return false
+}
+
+// mustChangeCurrentPassword returns true if the user of the session can't set their current local password again:
+// when their local password or their password in the provider expired, or when they change it and the password policy
+// keeps a history.
+//
+// When the users authenticate with the provider again, for example because their refresh token expired, they are
+// allowed to keep their current password, so that they are not forced to change it.
+func (b *Broker) mustChangeCurrentPassword(session *session) bool {
+	if session.passwordExpired || session.providerPasswordExpired {
+		return true
+	}
+	changingPassword := session.mode == sessionmode.ChangePassword || session.mode == sessionmode.ChangePasswordOld
+	return changingPassword && b.cfg.passwordPolicy.history > 0
+}
+
+// isCurrentPassword returns true if the new local password of the user of the session is their current one.
+func (b *Broker) isCurrentPassword(session *session, secret string) bool {
+	same, err := password.CheckPassword(secret, session.passwordPath, password.WithFIPSMode(b.cfg.fipsMode), password.WithStorage(b.storage))
+	if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, password.ErrNotFIPSApproved) {
+		log.Warningf(context.Background(), "Could not check the new password against the current one: %v", err)
+	}
+	return same
+}
+
+// isInPasswordHistory returns true if the new local password of the user of the session is one of their previous
+// passwords kept in the history of the password policy.
+func (b *Broker) isInPasswordHistory(session *session, secret string) bool {
+	inHistory, err := password.IsInHistory(secret, session.passwordHistoryPath, b.cfg.passwordPolicy.history-1,
+		password.WithFIPSMode(b.cfg.fipsMode), password.WithStorage(b.storage))
+	if err != nil {
+		log.Warningf(context.Background(), "Could not check the new password against the previous ones: %v", err)
+	}
+	return inHistory
+}
+
+// updatePasswordHistory adds the current local password of the user of the session to their history before it's
+// replaced by the new one, unless the user set the same password again.
+func (b *Broker) updatePasswordHistory(session *session, secret string) error {
+	if b.cfg.passwordPolicy.history <= 1 || !passwordFileExists(*session) {
+		return nil
+	}
+
+	if b.isCurrentPassword(session, secret) {
+		return nil
+	}
+	return password.AddToHistory(session.passwordPath, session.passwordHistoryPath, b.cfg.passwordPolicy.history-1,
+		password.WithFIPSMode(b.cfg.fipsMode), password.WithStorage(b.storage))
+}
+
+// localPasswordExpired returns true if the local password of the user of the session is older than the max_age of
+// the password policy.
+func (b *Broker) localPasswordExpired(session *session) bool {
+	if b.cfg.passwordPolicy.maxAge == 0 {
+		return false
+	}
+	fi, err := os.Stat(session.passwordPath)
+	if err != nil {
+		log.Warningf(context.Background(), "Could not check the age of the local password: %v", err)
+		return false
+	}
+	return time.Since(fi.ModTime()) >= b.cfg.passwordPolicy.maxAge
+}
@@ -0,0 +1,254 @@
+package broker_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/authmodes"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/sessionmode"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/password"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordPolicyComplexity(t *testing.T) {
+	t.Parallel()
+
+	dictionary := filepath.Join(t.TempDir(), "words")
+	err := os.WriteFile(dictionary, []byte("dragon\nSunshine\n"), 0600)
+	require.NoError(t, err, "Setup: could not write the dictionary")
+
+	tests := map[string]struct {
+		newPassword string
+
+		wantAccess  string
+		wantMessage string
+	}{
+		"Accept_a_password_complying_with_all_rules": {newPassword: "Correct-Horse-42", wantAccess: broker.AuthGranted},
+		"Accept_a_password_containing_a_dictionary_word": {
+			newPassword: "Sunshine-and-Dragon-7", wantAccess: broker.AuthGranted,
+		},
+
+		"Reject_a_password_which_is_too_short": {
+			newPassword: "Ab-4", wantAccess: broker.AuthRetry,
+			wantMessage: "Your password must have at least 10 characters.",
+		},
+		"Reject_a_password_without_uppercase_letter": {
+			newPassword: "correct-horse-42", wantAccess: broker.AuthRetry,
+			wantMessage: "Your password must contain an uppercase letter.",
+		},
+		"Reject_a_password_without_digit": {
+			newPassword: "Correct-Horse", wantAccess: broker.AuthRetry,
+			wantMessage: "Your password must contain a digit.",
+		},
+		"Reject_a_password_without_other_character": {
+			newPassword: "CorrectHorse42", wantAccess: broker.AuthRetry,
+			wantMessage: "Your password must contain a character which is neither a letter nor a digit.",
+		},
+		"Reject_a_password_based_on_a_dictionary_word": {
+			newPassword: "1Sunshine!", wantAccess: broker.AuthRetry,
+			wantMessage: "Your password must not be based on a dictionary word.",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &brokerForTestConfig{allUsersAllowed: true}
+			cfg.SetPasswordPolicy(10, []string{"lowercase", "uppercase", "digit", "other"}, dictionary, 0, 0)
+			b := newBrokerForTests(t, cfg)
+
+			sessionID, key := newSessionForTests(t, b, "", sessionmode.Login)
+			updateAuthModes(t, b, sessionID, authmodes.DeviceQr)
+			access, _, err := b.IsAuthenticated(sessionID, "{}")
+			require.NoError(t, err, "IsAuthenticated should not return an error")
+			require.Equal(t, broker.AuthNext, access, "The device authentication should ask for a new local password")
+
+			access, msg := setNewPassword(t, b, sessionID, key, tc.newPassword)
+			require.Equal(t, tc.wantAccess, access, "IsAuthenticated should return the expected access")
+			if tc.wantAccess == broker.AuthRetry {
+				require.Equal(t, tc.wantMessage, msg, "IsAuthenticated should explain which rule the password breaks")
+				require.NoFileExists(t, b.PasswordFilepathForSession(sessionID), "The password should not be stored")
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyHistory(t *testing.T) {
+	t.Parallel()
+
+	// The passwords the user set, from the oldest one.
+	previousPasswords := []string{"first", "second", "third"}
+
+	tests := map[string]struct {
+		history     int
+		newPassword string
+		sessionMode string
+
+		wantAccess  string
+		wantMessage string
+	}{
+		"Accept_a_password_which_was_never_set": {history: 3, newPassword: "other", wantAccess: broker.AuthGranted},
+		"Accept_a_password_older_than_the_history": {
+			history: 2, newPassword: "first", wantAccess: broker.AuthGranted,
+		},
+		"Accept_the_current_password_without_history": {
+			newPassword: "third", wantAccess: broker.AuthGranted,
+		},
+		"Accept_the_current_password_when_authenticating_with_the_provider_again": {
+			history: 3, newPassword: "third", sessionMode: sessionmode.Login, wantAccess: broker.AuthGranted,
+		},
+
+		"Reject_the_current_password": {
+			history: 1, newPassword: "third", wantAccess: broker.AuthRetry,
+			wantMessage: "Your password must be different from your current password.",
+		},
+		"Reject_a_password_of_the_history": {
+			history: 3, newPassword: "first", wantAccess: broker.AuthRetry,
+			wantMessage: "Your password must be different from your last 3 passwords.",
+		},
+		"Reject_a_password_of_the_history_when_authenticating_with_the_provider_again": {
+			history: 3, newPassword: "second", sessionMode: sessionmode.Login, wantAccess: broker.AuthRetry,
+			wantMessage: "Your password must be different from your last 3 passwords.",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &brokerForTestConfig{allUsersAllowed: true}
+			cfg.SetPasswordPolicy(0, nil, "", tc.history, 0)
+			b := newBrokerForTests(t, cfg)
+
+			for i, p := range previousPasswords {
+				if i == 0 {
+					sessionID, key := newSessionForTests(t, b, "", sessionmode.Login)
+					_, access := runDeviceAuthAndNewPassword(t, b, sessionID, key, p)
+					require.Equal(t, broker.AuthGranted, access, "Setup: could not set the first password")
+					continue
+				}
+				access, _ := changePassword(t, b, previousPasswords[i-1], p)
+				require.Equal(t, broker.AuthGranted, access, "Setup: could not change the password to %q", p)
+			}
+
+			var access, msg string
+			if tc.sessionMode == sessionmode.Login {
+				sessionID, key := newSessionForTests(t, b, "", sessionmode.Login)
+				updateAuthModes(t, b, sessionID, authmodes.DeviceQr)
+				_, _, err := b.IsAuthenticated(sessionID, "{}")
+				require.NoError(t, err, "IsAuthenticated should not return an error")
+				access, msg = setNewPassword(t, b, sessionID, key, tc.newPassword)
+			} else {
+				access, msg = changePassword(t, b, previousPasswords[len(previousPasswords)-1], tc.newPassword)
+			}
+			require.Equal(t, tc.wantAccess, access, "IsAuthenticated should return the expected access")
+			require.Equal(t, tc.wantMessage, msg, "IsAuthenticated should return the expected message")
+		})
+	}
+}
+
+func TestPasswordPolicyMaxAge(t *testing.T) {
+	t.Parallel()
+
+	const (
+		day    = 24 * time.Hour
+		maxAge = 90 * day
+	)
+
+	tests := map[string]struct {
+		passwordAge time.Duration
+
+		wantExpired bool
+	}{
+		"Grant_access_if_password_is_recent":    {passwordAge: day},
+		"Require_new_password_if_it_is_expired": {passwordAge: maxAge + day, wantExpired: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &brokerForTestConfig{allUsersAllowed: true}
+			cfg.SetPasswordPolicy(0, nil, "", 0, maxAge)
+			b := newBrokerForTests(t, cfg)
+
+			sessionID, key := newSessionForTests(t, b, "", sessionmode.Login)
+			err := token.CacheAuthInfo(b.TokenPathForSession(sessionID), generateCachedInfo(t, tokenOptions{}))
+			require.NoError(t, err, "Setup: CacheAuthInfo should not have returned an error")
+			passwordPath := b.PasswordFilepathForSession(sessionID)
+			err = password.HashAndStorePassword("current", passwordPath)
+			require.NoError(t, err, "Setup: HashAndStorePassword should not have returned an error")
+			setTime := time.Now().Add(-tc.passwordAge)
+			err = os.Chtimes(passwordPath, setTime, setTime)
+			require.NoError(t, err, "Setup: could not change the age of the password")
+
+			updateAuthModes(t, b, sessionID, authmodes.Password)
+			authData := fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, encryptSecret(t, "current", key))
+			access, data, err := b.IsAuthenticated(sessionID, authData)
+			require.NoError(t, err, "IsAuthenticated should not return an error")
+			if !tc.wantExpired {
+				require.Equal(t, broker.AuthGranted, access, "IsAuthenticated should grant access")
+				return
+			}
+			require.Equal(t, broker.AuthNext, access, "IsAuthenticated should ask for a new password")
+			require.Equal(t, "Your local password expired. Please choose a new one.", authMessage(t, data),
+				"IsAuthenticated should tell the user that their password expired")
+			require.Equal(t, []string{authmodes.NewPassword}, b.GetNextAuthModes(sessionID), "The next mode should be the new password one")
+
+			access, msg := setNewPassword(t, b, sessionID, key, "current")
+			require.Equal(t, broker.AuthRetry, access, "The expired password should not be accepted again")
+			require.Equal(t, "Your password must be different from your current password.", msg,
+				"IsAuthenticated should explain why the password is rejected")
+
+			access, _ = setNewPassword(t, b, sessionID, key, "brand-new")
+			require.Equal(t, broker.AuthGranted, access, "IsAuthenticated should grant access with the new password")
+
+			ok, err := password.CheckPassword("brand-new", passwordPath)
+			require.NoError(t, err, "CheckPassword should not return an error")
+			require.True(t, ok, "The new password should be stored")
+		})
+	}
+}
+
+// setNewPassword sends the new password of the session to the broker, and returns the access and the message of the
+// response.
+func setNewPassword(t *testing.T, b *broker.Broker, sessionID, key, newPassword string) (access, msg string) {
+	t.Helper()
+
+	updateAuthModes(t, b, sessionID, authmodes.NewPassword)
+	authData := fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, encryptSecret(t, newPassword, key))
+	access, data, err := b.IsAuthenticated(sessionID, authData)
+	require.NoError(t, err, "IsAuthenticated should not return an error")
+	return access, authMessage(t, data)
+}
+
+// changePassword changes the local password of the user in a passwd session, and returns the access and the message
+// of the response to the new password.
+func changePassword(t *testing.T, b *broker.Broker, currentPassword, newPassword string) (access, msg string) {
+	t.Helper()
+
+	sessionID, key := newSessionForTests(t, b, "", sessionmode.ChangePassword)
+	updateAuthModes(t, b, sessionID, authmodes.Password)
+	authData := fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, encryptSecret(t, currentPassword, key))
+	access, _, err := b.IsAuthenticated(sessionID, authData)
+	require.NoError(t, err, "IsAuthenticated should not return an error")
+	require.Equal(t, broker.AuthNext, access, "The current password should be accepted")
+
+	return setNewPassword(t, b, sessionID, key, newPassword)
+}
+
+// authMessage returns the message of the data returned by IsAuthenticated.
+func authMessage(t *testing.T, data string) string {
+	t.Helper()
+
+	var got struct {
+		Message string `json:"message"`
+	}
+	err := json.Unmarshal([]byte(data), &got)
+	require.NoError(t, err, "IsAuthenticated should return valid JSON data")
+	return got.Message
+}
@@ -0,0 +1,90 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
+	"github.com/canonical/authd/log"
+)
+
+// pictureClaim is the standard claim with the URL of the picture of the user.
+const pictureClaim = "picture"
+
+// claimPicture downloads the picture of the URL of the picture claim, which must use HTTPS. It returns nil if there is
+// no such claim or if the picture can't be downloaded, which doesn't prevent the user from logging in.
+func claimPicture(ctx context.Context, client *http.Client, claims info.Claimer) []byte {
+	var values map[string]any
+	if err := claims.Claims(&values); err != nil {
+		log.Warningf(ctx, "Could not get the claims for the picture: %v", err)
+		return nil
+	}
+	rawURL, _ := values[pictureClaim].(string)
+	if rawURL == "" {
+		log.Debugf(ctx, "No picture: claim %q is missing", pictureClaim)
+		return nil
+	}
+
+	picture, err := downloadPicture(ctx, client, rawURL)
+	if err != nil {
+		log.Warningf(ctx, "Could not download the picture of claim %q: %v", pictureClaim, err)
+		return nil
+	}
+	return picture
+}
+
+func downloadPicture(ctx context.Context, client *http.Client, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("URL %q does not use HTTPS", rawURL)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, maxRequestDuration)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %s", u.Host, resp.Status)
+	}
+	return info.ReadPicture(resp.Body)
+}
+
+// userPicture returns the picture of the user from the claims, unless the provider fetches the pictures itself.
+func (b *Broker) userPicture(ctx context.Context, claims info.Claimer) []byte {
+	if _, ok := providers.ProviderAs[providers.PictureFetcher](b.provider); ok {
+		return nil
+	}
+	return claimPicture(ctx, b.httpClient, claims)
+}
+
+// maybeFetchPicture fetches the picture of the user with the providers which can, keeping the one which was cached if
+// it fails.
+func (b *Broker) maybeFetchPicture(ctx context.Context, authInfo *token.AuthCachedInfo) {
+	pf, ok := providers.ProviderAs[providers.PictureFetcher](b.provider)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, maxRequestDuration)
+	defer cancel()
+	picture, err := pf.GetPicture(ctx, b.httpClient, authInfo.Token, authInfo.ProviderMetadata)
+	if err != nil {
+		log.Warningf(ctx, "Could not get the picture of user %q: %v", authInfo.UserInfo.Name, err)
+		return
+	}
+	authInfo.UserInfo.Picture = picture
+}
@@ -0,0 +1,63 @@
+package broker_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker"
+	"github.com/stretchr/testify/require"
+)
+
+const testPicture = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+
+// claimsForTests implements info.Claimer for the given claims.
+type claimsForTests map[string]any
+
+func (c claimsForTests) Claims(v any) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func TestClaimPicture(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/picture.png":
+			_, _ = w.Write([]byte(testPicture))
+		case "/page.html":
+			_, _ = w.Write([]byte("<html><body>Not a picture</body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	tests := map[string]struct {
+		claims claimsForTests
+
+		want []byte
+	}{
+		"Download_picture_of_the_claim": {claims: claimsForTests{"picture": server.URL + "/picture.png"}, want: []byte(testPicture)},
+
+		"No_picture_if_there_is_no_claim":           {claims: claimsForTests{"name": "Test User"}},
+		"No_picture_if_claim_is_not_a_string":       {claims: claimsForTests{"picture": 42}},
+		"No_picture_if_URL_does_not_use_HTTPS":      {claims: claimsForTests{"picture": "http://example.com/picture.png"}},
+		"No_picture_if_it_is_not_found":             {claims: claimsForTests{"picture": server.URL + "/missing.png"}},
+		"No_picture_if_URL_is_not_the_one_of_image": {claims: claimsForTests{"picture": server.URL + "/page.html"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := broker.ClaimPicture(context.Background(), server.Client(), tc.claims)
+			require.Equal(t, tc.want, got, "ClaimPicture should return the expected picture")
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package broker
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/canonical/authd/log"
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// Prewarm fetches the OIDC discovery documents and the signing keys of the issuers, so that the first
+// authentication doesn't have to wait for them. Errors are only logged, as the broker works without it.
+func (b *Broker) Prewarm(ctx context.Context) {
+	for _, iss := range b.issuers() {
+		b.prewarmIssuer(ctx, iss)
+	}
+}
+
+// prewarmIssuer fetches the OIDC discovery document and the signing keys of the issuer.
+func (b *Broker) prewarmIssuer(ctx context.Context, iss *issuer) {
+	p, err := b.connectToOIDCServer(ctx, iss)
+	if err != nil {
+		log.Noticef(ctx, "Could not prefetch the OIDC discovery document: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, maxRequestDuration)
+	defer cancel()
+	if err := fetchSigningKeys(ctx, p); err != nil {
+		log.Noticef(ctx, "Could not prefetch the provider signing keys: %v", err)
+		return
+	}
+	log.Debugf(ctx, "Prefetched the metadata and the signing keys of OIDC provider %q", iss.url)
+}
+
+// sharedOIDCServer returns the provider previously discovered for the issuer if it has the same metadata as p,
+// otherwise p, which replaces it.
+func (iss *issuer) sharedOIDCServer(p *oidc.Provider) *oidc.Provider {
+	iss.oidcServerMu.Lock()
+	defer iss.oidcServerMu.Unlock()
+
+	if iss.oidcServer != nil && sameMetadata(iss.oidcServer, p) {
+		return iss.oidcServer
+	}
+	iss.oidcServer = p
+	return p
+}
+
+func sameMetadata(a, b *oidc.Provider) bool {
+	var metadataA, metadataB json.RawMessage
+	if err := a.Claims(&metadataA); err != nil {
+		return false
+	}
+	if err := b.Claims(&metadataB); err != nil {
+		return false
+	}
+	return bytes.Equal(metadataA, metadataB)
+}
+
+// fetchSigningKeys fills the key cache of the provider from its JWKS endpoint.
+//
+// go-oidc only fetches the keys when verifying a token signed with a key it doesn't know, so this verifies a token
+// with an unknown key ID. The verification always fails, but the keys are cached once fetched, so only the errors
+// returned while fetching them are reported.
+func fetchSigningKeys(ctx context.Context, p *oidc.Provider) error {
+	var metadata struct {
+		Algorithms []string `json:"id_token_signing_alg_values_supported"`
+	}
+	if err := p.Claims(&metadata); err != nil {
+		return err
+	}
+	alg := oidc.RS256
+	if len(metadata.Algorithms) > 0 && !slices.Contains(metadata.Algorithms, oidc.RS256) {
+		alg = metadata.Algorithms[0]
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": "authd-prewarm"})
+	if err != nil {
+		return err
+	}
+	enc := base64.RawURLEncoding
+	probe := enc.EncodeToString(header) + "." + enc.EncodeToString([]byte("{}")) + "." + enc.EncodeToString([]byte("prewarm"))
+
+	verifier := p.Verifier(&oidc.Config{SkipClientIDCheck: true, SkipIssuerCheck: true, SkipExpiryCheck: true})
+	if _, err = verifier.Verify(ctx, probe); err != nil && strings.Contains(err.Error(), "fetching keys") {
+		return err
+	}
+	return nil
+}
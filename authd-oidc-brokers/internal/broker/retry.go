@@ -0,0 +1,159 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/canonical/authd/log"
+	"golang.org/x/oauth2"
+)
+
+// errProviderUnavailable is returned when the requests to the provider still fail with a transient error once the
+// retries are exhausted.
+var errProviderUnavailable = errors.New("the provider is temporarily unavailable")
+
+const (
+	// providerUnavailableMsg is displayed when the provider is temporarily unavailable and the user can't be
+	// authenticated without it.
+	providerUnavailableMsg = "The identity provider is temporarily unavailable. Please try again in a few minutes."
+	// providerUnavailableOfflineMsg is displayed when the user was authenticated with their local password because the
+	// provider is temporarily unavailable.
+	providerUnavailableOfflineMsg = "The identity provider is temporarily unavailable. You were logged in with your local password."
+)
+
+// transientStatuses are the HTTP statuses with which a provider reports it's overloaded or down for a short while,
+// including when the client is rate limited.
+var transientStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// retryPolicy is how the requests to the provider failing with a transient error are retried.
+type retryPolicy struct {
+	// retries is the number of times a request is sent again before giving up.
+	retries int
+	// backoff is the delay before the first retry. It's doubled for every other retry, up to maxBackoff.
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// defaultRetryPolicy keeps the worst case of a login with an unresponsive provider under 20 seconds.
+var defaultRetryPolicy = retryPolicy{
+	retries:    2,
+	backoff:    500 * time.Millisecond,
+	maxBackoff: 2 * time.Second,
+}
+
+// withRetries calls request until it succeeds, fails with an error which is not transient, or the retries are
+// exhausted, in which case the returned error wraps errProviderUnavailable. what describes the request in the logs.
+func (b *Broker) withRetries(ctx context.Context, what string, request func() error) error {
+	delay := b.retry.backoff
+	for attempt := 1; ; attempt++ {
+		err := request()
+		if !isTransientProviderError(err) {
+			return err
+		}
+		if attempt > b.retry.retries || ctx.Err() != nil {
+			return fmt.Errorf("%w: %w", errProviderUnavailable, err)
+		}
+
+		wait := min(max(delay, retryAfter(err)), b.retry.maxBackoff)
+		log.Noticef(ctx, "Could not %s because of a transient error, retrying in %s (%d/%d): %v", what, wait, attempt, b.retry.retries, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", errProviderUnavailable, err)
+		case <-time.After(wait):
+		}
+		delay = min(2*delay, b.retry.maxBackoff)
+	}
+}
+
+// isTransientProviderError returns true if err is a failure of the provider which is likely to go away if the
+// request is sent again: a server error, rate limiting or a timeout. The rejections of the request by the provider
+// and the other connection errors, which mean that the machine is offline, are not transient.
+func isTransientProviderError(err error) bool {
+	if err == nil || errors.Is(err, errCaptivePortal) {
+		return false
+	}
+
+	var statusErr *providerStatusError
+	if errors.As(err, &statusErr) {
+		return true
+	}
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		// temporarily_unavailable is the error code defined by RFC 6749 for the providers which can't handle the
+		// request because of a temporary overloading or maintenance.
+		return retrieveErr.ErrorCode == "temporarily_unavailable" ||
+			(retrieveErr.Response != nil && slices.Contains(transientStatuses, retrieveErr.Response.StatusCode))
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryAfter returns the delay after which the provider asked to send the request again, if any.
+func retryAfter(err error) time.Duration {
+	var statusErr *providerStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.retryAfter
+	}
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && retrieveErr.Response != nil {
+		return parseRetryAfter(retrieveErr.Response.Header)
+	}
+	return 0
+}
+
+// parseRetryAfter returns the delay of the Retry-After header, which is either a number of seconds or a date.
+func parseRetryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(v); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+// providerStatusError is returned by the GET requests to the provider answered with a transient status. The OIDC
+// library used for the discovery and the signing keys only reports the status in the text of its errors.
+type providerStatusError struct {
+	host       string
+	status     string
+	retryAfter time.Duration
+}
+
+func (e *providerStatusError) Error() string {
+	return fmt.Sprintf("%s answered %q", e.host, e.status)
+}
+
+// providerStatusTransport turns the transient statuses of the responses to GET requests into a providerStatusError.
+// The token and device authorization requests are POST requests, whose status the OAuth2 library returns in an
+// oauth2.RetrieveError.
+type providerStatusTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip sends the request and returns a providerStatusError if the response to a GET request has a transient
+// status.
+func (t providerStatusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet || !slices.Contains(transientStatuses, resp.StatusCode) {
+		return resp, err
+	}
+	_ = resp.Body.Close()
+	return nil, &providerStatusError{host: req.URL.Host, status: resp.Status, retryAfter: parseRetryAfter(resp.Header)}
+}
@@ -0,0 +1,182 @@
+package broker_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/authmodes"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/sessionmode"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/password"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestIsTransientProviderError(t *testing.T) {
+	t.Parallel()
+
+	retrieveError := func(status int, code string) error {
+		return &oauth2.RetrieveError{Response: &http.Response{StatusCode: status}, ErrorCode: code}
+	}
+
+	tests := map[string]struct {
+		err error
+
+		want bool
+	}{
+		"Server_error":                       {err: retrieveError(http.StatusInternalServerError, ""), want: true},
+		"Service_unavailable":                {err: retrieveError(http.StatusServiceUnavailable, ""), want: true},
+		"Gateway_timeout":                    {err: retrieveError(http.StatusGatewayTimeout, ""), want: true},
+		"Rate_limiting":                      {err: retrieveError(http.StatusTooManyRequests, ""), want: true},
+		"Provider_temporarily_unavailable":   {err: retrieveError(http.StatusBadRequest, "temporarily_unavailable"), want: true},
+		"Request_timeout":                    {err: &url.Error{Op: "Post", URL: "https://issuer/token", Err: context.DeadlineExceeded}, want: true},
+		"Wrapped_request_timeout":            {err: fmt.Errorf("could not refresh: %w", context.DeadlineExceeded), want: true},
+		"Not_transient_if_no_error":          {},
+		"Not_transient_if_grant_is_invalid":  {err: retrieveError(http.StatusBadRequest, "invalid_grant")},
+		"Not_transient_if_client_is_invalid": {err: retrieveError(http.StatusUnauthorized, "invalid_client")},
+		"Not_transient_if_not_implemented":   {err: retrieveError(http.StatusNotImplemented, "")},
+		"Not_transient_if_connection_fails":  {err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}},
+		"Not_transient_if_request_cancelled": {err: context.Canceled},
+		"Not_transient_if_error_is_unknown":  {err: errors.New("some error")},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.want, broker.IsTransientProviderError(tc.err), "IsTransientProviderError should return the expected value")
+		})
+	}
+}
+
+func TestProviderRetries(t *testing.T) {
+	t.Parallel()
+
+	const (
+		correctPassword        = "password"
+		unavailableMsg         = "The identity provider is temporarily unavailable. Please try again in a few minutes."
+		unavailableOfflineMsg  = "The identity provider is temporarily unavailable. You were logged in with your local password."
+		discoveryEndpoint      = "/.well-known/openid-configuration"
+		allowedProviderRetries = 2
+		// tooManyErrors is more transient errors than the retries can absorb.
+		tooManyErrors = 10
+	)
+
+	tests := map[string]struct {
+		transientErrors              map[string]int
+		forceAccessCheckWithProvider bool
+		noLocalPassword              bool
+		selectDeviceAuth             bool
+
+		wantNewSessionErr string
+		wantAuthModesErr  string
+		wantSelectModeErr string
+		wantAccess        string
+		wantMessage       string
+		wantOffline       bool
+	}{
+		"Discover_the_provider_after_transient_errors": {transientErrors: map[string]int{discoveryEndpoint: 2}, wantAccess: broker.AuthGranted},
+		// The OAuth2 library sends every refresh request twice until it detects how the provider authenticates the client.
+		"Refresh_the_token_after_transient_errors":     {transientErrors: map[string]int{"/token": 4}, wantAccess: broker.AuthGranted},
+		"Request_a_device_code_after_transient_errors": {transientErrors: map[string]int{"/device_auth": 2}, selectDeviceAuth: true},
+
+		"Log_in_offline_if_the_provider_can_not_be_discovered_after_retries": {
+			transientErrors: map[string]int{discoveryEndpoint: tooManyErrors},
+			wantAccess:      broker.AuthGranted,
+			wantMessage:     unavailableOfflineMsg,
+			wantOffline:     true,
+		},
+		"Log_in_offline_if_the_token_can_not_be_refreshed_after_retries": {
+			transientErrors: map[string]int{"/token": tooManyErrors},
+			wantAccess:      broker.AuthGranted,
+			wantMessage:     unavailableOfflineMsg,
+			wantOffline:     true,
+		},
+
+		"Error_if_the_provider_can_not_be_discovered_after_retries_and_user_has_no_local_password": {
+			transientErrors:  map[string]int{discoveryEndpoint: tooManyErrors},
+			noLocalPassword:  true,
+			wantAuthModesErr: unavailableMsg,
+		},
+		"Error_if_the_provider_can_not_be_discovered_after_retries_with_forced_provider_access_check": {
+			transientErrors:              map[string]int{discoveryEndpoint: tooManyErrors},
+			forceAccessCheckWithProvider: true,
+			wantNewSessionErr:            unavailableMsg,
+		},
+		"Retry_if_the_token_can_not_be_refreshed_after_retries_with_forced_provider_access_check": {
+			transientErrors:              map[string]int{"/token": tooManyErrors},
+			forceAccessCheckWithProvider: true,
+			wantAccess:                   broker.AuthRetry,
+			wantMessage:                  unavailableMsg,
+		},
+		"Error_if_a_device_code_can_not_be_requested_after_retries": {
+			transientErrors:   map[string]int{"/device_auth": tooManyErrors},
+			selectDeviceAuth:  true,
+			wantSelectModeErr: unavailableMsg,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newBrokerForTests(t, &brokerForTestConfig{
+				transientErrors:              tc.transientErrors,
+				providerRetries:              allowedProviderRetries,
+				forceAccessCheckWithProvider: tc.forceAccessCheckWithProvider,
+				allUsersAllowed:              true,
+			})
+
+			sessionID, key, err := b.NewSession("test-user@email.com", "lang", sessionmode.Login, "")
+			if tc.wantNewSessionErr != "" {
+				require.EqualError(t, err, tc.wantNewSessionErr, "NewSession should return the expected error")
+				return
+			}
+			require.NoError(t, err, "NewSession should not return an error")
+
+			if !tc.noLocalPassword && !tc.selectDeviceAuth {
+				err = token.CacheAuthInfo(b.TokenPathForSession(sessionID), generateCachedInfo(t, tokenOptions{}))
+				require.NoError(t, err, "Setup: CacheAuthInfo should not have returned an error")
+				err = password.HashAndStorePassword(correctPassword, b.PasswordFilepathForSession(sessionID))
+				require.NoError(t, err, "Setup: HashAndStorePassword should not have returned an error")
+			}
+
+			_, err = b.GetAuthenticationModes(sessionID, []map[string]string{supportedUILayouts["form"], supportedUILayouts["qrcode"]})
+			if tc.wantAuthModesErr != "" {
+				require.EqualError(t, err, tc.wantAuthModesErr, "GetAuthenticationModes should return the expected error")
+				return
+			}
+			require.NoError(t, err, "GetAuthenticationModes should not return an error")
+
+			if tc.selectDeviceAuth {
+				_, err = b.SelectAuthenticationMode(sessionID, authmodes.DeviceQr)
+				if tc.wantSelectModeErr != "" {
+					require.EqualError(t, err, tc.wantSelectModeErr, "SelectAuthenticationMode should return the expected error")
+					return
+				}
+				require.NoError(t, err, "SelectAuthenticationMode should not return an error")
+				return
+			}
+
+			updateAuthModes(t, b, sessionID, authmodes.Password)
+			authData := fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, encryptSecret(t, correctPassword, key))
+			access, data, err := b.IsAuthenticated(sessionID, authData)
+			require.NoError(t, err, "IsAuthenticated should not return an error")
+			require.Equal(t, tc.wantAccess, access, "IsAuthenticated should return the expected access")
+
+			var got struct {
+				Message string `json:"message"`
+				Offline bool   `json:"offline"`
+			}
+			err = json.Unmarshal([]byte(data), &got)
+			require.NoError(t, err, "IsAuthenticated should return valid JSON data")
+			require.Equal(t, tc.wantMessage, got.Message, "IsAuthenticated should return the expected message")
+			require.Equal(t, tc.wantOffline, got.Offline, "IsAuthenticated should return whether the user was authenticated offline")
+		})
+	}
+}
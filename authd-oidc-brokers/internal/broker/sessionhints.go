@@ -0,0 +1,51 @@
+package broker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sessionHintTimezone is the session hint with the IANA name of the time zone of the user.
+const sessionHintTimezone = "timezone"
+
+// SetSessionHints records the hints given by authd about the session, to adapt the interactive flows to the user. The
+// unknown hints are ignored, so that newer versions of authd can pass hints which this broker doesn't use.
+func (b *Broker) SetSessionHints(sessionID string, hints map[string]string) error {
+	session, err := b.getSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	if tz, ok := hints[sessionHintTimezone]; ok {
+		location, err := time.LoadLocation(tz)
+		if err != nil {
+			return fmt.Errorf("invalid time zone %q: %v", tz, err)
+		}
+		session.timezone = location
+	}
+
+	return b.updateSession(sessionID, session)
+}
+
+// uiLocales returns the language of the session in the format of the ui_locales parameter of OpenID Connect (a BCP 47
+// language tag, e.g. "pt-BR"), with which the provider can localize its pages. It's empty if the session uses the
+// default language.
+func uiLocales(lang string) string {
+	// Drop the codeset and the modifier of the locale (e.g. "de_DE.ISO-8859-1@euro").
+	lang, _, _ = strings.Cut(lang, ".")
+	lang, _, _ = strings.Cut(lang, "@")
+	if lang == "" || lang == "C" || lang == "POSIX" {
+		return ""
+	}
+	return strings.ReplaceAll(lang, "_", "-")
+}
+
+// deviceCodeExpiryMessage returns the message telling the user until when they can enter the device code, in their
+// time zone. It's empty if the time zone of the user is not known, as the one of the broker may be different.
+func (b *Broker) deviceCodeExpiryMessage(session *session, expiry time.Time) string {
+	if session.timezone == nil || expiry.IsZero() {
+		return ""
+	}
+	return b.messages.Getf(session.lang, "The code expires at %s.", expiry.In(session.timezone).Format("15:04"))
+}
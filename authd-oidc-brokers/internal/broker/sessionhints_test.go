@@ -0,0 +1,105 @@
+package broker_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/authmodes"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/sessionmode"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionHints(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		lang  string
+		hints map[string]string
+
+		wantUILocales string
+		wantExpiryIn  string
+		wantErr       bool
+	}{
+		"Request_pages_in_the_language_of_the_user": {lang: "pt_BR", wantUILocales: "pt-BR"},
+		"Request_pages_in_the_language_of_the_user_without_the_codeset": {
+			lang: "de_DE.ISO-8859-1@euro", wantUILocales: "de-DE",
+		},
+		"Do_not_request_a_language_for_the_default_locale": {lang: "C"},
+
+		"Show_the_device_code_expiry_in_the_timezone_of_the_user": {
+			lang: "C", hints: map[string]string{"timezone": "Asia/Tokyo"}, wantExpiryIn: "Asia/Tokyo",
+		},
+		"Do_not_show_the_device_code_expiry_without_a_timezone": {lang: "C", hints: map[string]string{}},
+		"Ignore_unknown_hints": {
+			lang: "C", hints: map[string]string{"timezone": "UTC", "unknown": "value"}, wantExpiryIn: "UTC",
+		},
+
+		"Error_when_the_timezone_is_invalid": {lang: "C", hints: map[string]string{"timezone": "Not/A_Timezone"}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uiLocales := make(chan string, 1)
+			b := newBrokerForTests(t, &brokerForTestConfig{
+				customHandlers: map[string]testutils.EndpointHandler{
+					"/device_auth": func(w http.ResponseWriter, r *http.Request) {
+						uiLocales <- r.FormValue("ui_locales")
+						w.Header().Add("Content-Type", "application/json")
+						_, _ = fmt.Fprint(w, `{
+							"device_code": "device_code",
+							"user_code": "user_code",
+							"verification_uri": "https://verification_uri.com",
+							"expires_in": 600
+						}`)
+					},
+				},
+			})
+			sessionID, _, err := b.NewSession("test-user@email.com", tc.lang, sessionmode.Login, "")
+			require.NoError(t, err, "Setup: NewSession should not have returned an error")
+
+			if tc.hints != nil {
+				err = b.SetSessionHints(sessionID, tc.hints)
+				if tc.wantErr {
+					require.Error(t, err, "SetSessionHints should return an error")
+					return
+				}
+				require.NoError(t, err, "SetSessionHints should not return an error")
+			}
+
+			err = b.SetAvailableMode(sessionID, authmodes.DeviceQr)
+			require.NoError(t, err, "Setup: SetAvailableMode should not have returned an error")
+			before := time.Now()
+			layout, err := b.SelectAuthenticationMode(sessionID, authmodes.DeviceQr)
+			require.NoError(t, err, "SelectAuthenticationMode should not return an error")
+			after := time.Now()
+
+			require.Equal(t, tc.wantUILocales, <-uiLocales, "The device authorization request should have the expected ui_locales")
+
+			if tc.wantExpiryIn == "" {
+				require.NotContains(t, layout["label"], "expires", "The label should not show when the code expires")
+				return
+			}
+			location, err := time.LoadLocation(tc.wantExpiryIn)
+			require.NoError(t, err, "Setup: could not load the time zone")
+			// The minute may change while the device code is requested.
+			var wantLabels []string
+			for _, requested := range []time.Time{before, after} {
+				expiry := requested.Add(10 * time.Minute).In(location).Format("15:04")
+				wantLabels = append(wantLabels, "Scan the QR code or open the URL and enter the code below. The code expires at "+expiry+".")
+			}
+			require.Contains(t, wantLabels, layout["label"], "The label should show when the code expires in the time zone of the user")
+		})
+	}
+}
+
+func TestSetSessionHintsForUnknownSession(t *testing.T) {
+	t.Parallel()
+
+	b := newBrokerForTests(t, &brokerForTestConfig{})
+	err := b.SetSessionHints("unknown-session", map[string]string{"timezone": "UTC"})
+	require.Error(t, err, "SetSessionHints should return an error for an unknown session")
+}
@@ -0,0 +1,155 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
+	"github.com/canonical/authd/log"
+)
+
+// minSessionTokenValidity is the time for which a session token must still be valid when it's handed to an
+// application. A token expiring sooner is refreshed first.
+const minSessionTokenValidity = 5 * time.Minute
+
+var (
+	// ErrSessionTokensDisabled is returned when no scope is approved for the session tokens.
+	ErrSessionTokensDisabled = errors.New("session tokens are disabled in the broker configuration")
+	// ErrScopeNotApproved is returned when a session token is requested for a scope which is not approved.
+	ErrScopeNotApproved = errors.New("scope is not approved for session tokens")
+)
+
+// SessionToken is an access token of a user handed to an application running in their session, so that it doesn't
+// have to authenticate them again with the provider.
+type SessionToken struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	Expiry      time.Time `json:"expiry"`
+	Scopes      []string  `json:"scopes"`
+}
+
+// SessionToken returns the access token of the user for scopes, which must all be approved in the configuration.
+//
+// The access token is the one obtained when the user logged in, which is refreshed if it expires soon. The rotated
+// refresh token is stored, so that the next login can still refresh it.
+func (b *Broker) SessionToken(ctx context.Context, username string, scopes []string) (SessionToken, error) {
+	if len(b.cfg.sessionTokenScopes) == 0 {
+		return SessionToken{}, ErrSessionTokensDisabled
+	}
+	if len(scopes) == 0 {
+		return SessionToken{}, errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(b.cfg.sessionTokenScopes, scope) {
+			return SessionToken{}, fmt.Errorf("%w: %q", ErrScopeNotApproved, scope)
+		}
+	}
+
+	s, err := b.cachedSession(username)
+	if err != nil {
+		return SessionToken{}, err
+	}
+	if !tokenExists(s) {
+		return SessionToken{}, ErrNoCachedToken
+	}
+
+	b.sessionTokensMu.Lock()
+	defer b.sessionTokensMu.Unlock()
+
+	authInfo, err := token.LoadAuthInfo(s.tokenPath, token.WithStorage(b.storage))
+	if err != nil {
+		return SessionToken{}, err
+	}
+	if authInfo.Token == nil {
+		return SessionToken{}, ErrNoCachedToken
+	}
+	if authInfo.UserIsDisabled {
+		return SessionToken{}, fmt.Errorf("user %q is disabled in %s", s.username, b.provider.DisplayName())
+	}
+
+	if time.Until(authInfo.Token.Expiry) < minSessionTokenValidity {
+		if authInfo, err = b.refreshSessionToken(ctx, &s, authInfo); err != nil {
+			return SessionToken{}, err
+		}
+	}
+
+	log.Infof(ctx, "Handing an access token of user %q for scopes %v to their session", s.username, scopes)
+	return SessionToken{
+		AccessToken: authInfo.Token.AccessToken,
+		TokenType:   authInfo.Token.Type(),
+		Expiry:      authInfo.Token.Expiry,
+		Scopes:      scopes,
+	}, nil
+}
+
+// RefreshToken refreshes the token cached for the user, even if it's still valid, so that their session is extended
+// without them logging in again. It returns the status of the refreshed token.
+func (b *Broker) RefreshToken(ctx context.Context, username string) (TokenStatus, error) {
+	s, err := b.cachedSession(username)
+	if err != nil {
+		return TokenStatus{}, err
+	}
+	if !tokenExists(s) {
+		return TokenStatus{}, ErrNoCachedToken
+	}
+
+	if err := b.refreshCachedSessionToken(ctx, &s); err != nil {
+		return TokenStatus{}, err
+	}
+	log.Infof(ctx, "Refreshed the token of user %q on request of their session", s.username)
+
+	return b.TokenStatus(s.username)
+}
+
+func (b *Broker) refreshCachedSessionToken(ctx context.Context, s *session) error {
+	b.sessionTokensMu.Lock()
+	defer b.sessionTokensMu.Unlock()
+
+	authInfo, err := token.LoadAuthInfo(s.tokenPath, token.WithStorage(b.storage))
+	if err != nil {
+		return err
+	}
+	if authInfo.Token == nil {
+		return ErrNoCachedToken
+	}
+	if authInfo.UserIsDisabled {
+		return fmt.Errorf("user %q is disabled in %s", s.username, b.provider.DisplayName())
+	}
+
+	_, err = b.refreshSessionToken(ctx, s, authInfo)
+	return err
+}
+
+// refreshSessionToken refreshes the token of the user and stores it.
+func (b *Broker) refreshSessionToken(ctx context.Context, s *session, authInfo *token.AuthCachedInfo) (*token.AuthCachedInfo, error) {
+	if authInfo.Token.RefreshToken == "" {
+		return nil, errors.New("the access token expired and no refresh token is cached, the user has to log in again")
+	}
+
+	oidcServer, err := b.connectToOIDCServer(ctx, s.issuer)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to the provider: %w", err)
+	}
+	s.oidcServer = oidcServer
+	s.oauth2Config = b.oauth2Config(s.issuer, oidcServer)
+
+	var refreshed *token.AuthCachedInfo
+	if authInfo.ObtainedViaEntraPasswordAuth {
+		refreshed, err = b.refreshEntraPasswordToken(ctx, s, authInfo)
+	} else {
+		refreshed, err = b.refreshToken(ctx, s, authInfo)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not refresh the token of user %q: %w", s.username, err)
+	}
+	// The groups are only refreshed when the user logs in.
+	refreshed.UserInfo.Groups = authInfo.UserInfo.Groups
+
+	if err := token.CacheAuthInfo(s.tokenPath, refreshed, token.WithStorage(b.storage)); err != nil {
+		return nil, fmt.Errorf("could not store the refreshed token of user %q: %w", s.username, err)
+	}
+	return refreshed, nil
+}
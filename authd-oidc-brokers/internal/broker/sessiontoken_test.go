@@ -0,0 +1,150 @@
+package broker_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/secretstorage"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionToken(t *testing.T) {
+	t.Parallel()
+
+	approvedScopes := []string{"openid", "email"}
+
+	tests := map[string]struct {
+		token          *tokenOptions
+		scopes         []string
+		approvedScopes []string
+		secretStorage  string
+
+		wantRefresh bool
+		wantErr     error
+		wantAnyErr  bool
+	}{
+		"Return_the_cached_access_token":               {token: &tokenOptions{}},
+		"Return_the_token_for_several_scopes":          {token: &tokenOptions{}, scopes: []string{"openid", "email"}},
+		"Refresh_the_access_token_if_it_expired":       {token: &tokenOptions{expired: true}, wantRefresh: true},
+		"Return_the_valid_token_without_refresh_token": {token: &tokenOptions{noRefreshToken: true}},
+		"Seal_the_refreshed_token_with_the_secret_storage": {
+			token:         &tokenOptions{expired: true},
+			secretStorage: secretstorage.Keyring,
+			wantRefresh:   true,
+		},
+
+		"Error_if_session_tokens_are_disabled":           {token: &tokenOptions{}, approvedScopes: []string{}, wantErr: broker.ErrSessionTokensDisabled},
+		"Error_if_a_scope_is_not_approved":               {token: &tokenOptions{}, scopes: []string{"openid", "profile"}, wantErr: broker.ErrScopeNotApproved},
+		"Error_if_no_scope_is_requested":                 {token: &tokenOptions{}, scopes: []string{}, wantAnyErr: true},
+		"Error_if_no_token_is_cached":                    {wantErr: broker.ErrNoCachedToken},
+		"Error_if_the_user_is_disabled":                  {token: &tokenOptions{userIsDisabled: true}, wantAnyErr: true},
+		"Error_if_the_token_expired_without_refresh":     {token: &tokenOptions{expired: true, noRefreshToken: true}, wantAnyErr: true},
+		"Error_if_the_refresh_token_expired_at_provider": {token: &tokenOptions{expired: true, refreshTokenExpired: true}, wantAnyErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.approvedScopes == nil {
+				tc.approvedScopes = approvedScopes
+			}
+			if tc.scopes == nil {
+				tc.scopes = []string{"openid"}
+			}
+
+			b := newBrokerForTests(t, &brokerForTestConfig{sessionTokenScopes: tc.approvedScopes, secretStorage: tc.secretStorage})
+
+			// The name of the user is the one in the tokens returned by the mock provider.
+			const username = "test-user@email.com"
+			var tokenPath string
+			if tc.token != nil {
+				tokenPath = filepath.Join(setupUserCache(t, b, username, *tc.token, false, false), "token.json")
+			}
+
+			got, err := b.SessionToken(context.Background(), username, tc.scopes)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr, "SessionToken should have returned the expected error")
+				return
+			}
+			if tc.wantAnyErr {
+				require.Error(t, err, "SessionToken should have returned an error")
+				return
+			}
+			require.NoError(t, err, "SessionToken should not have returned an error")
+
+			require.Equal(t, "accesstoken", got.AccessToken, "SessionToken should return the access token of the user")
+			require.Equal(t, "Bearer", got.TokenType, "SessionToken should return the type of the access token")
+			require.Equal(t, tc.scopes, got.Scopes, "SessionToken should return the requested scopes")
+			require.Greater(t, time.Until(got.Expiry), 5*time.Minute, "SessionToken should return a token which is still valid")
+
+			var opts []token.Option
+			if tc.secretStorage != "" {
+				onDisk, err := os.ReadFile(tokenPath)
+				require.NoError(t, err, "Setup: could not read the cached token")
+				require.NotContains(t, string(onDisk), "accesstoken", "The refreshed token should be sealed")
+				storage, err := secretstorage.New(tc.secretStorage, b.DataDir())
+				require.NoError(t, err, "Setup: could not open the secret storage")
+				opts = append(opts, token.WithStorage(storage))
+			}
+			cached, err := token.LoadAuthInfo(tokenPath, opts...)
+			require.NoError(t, err, "Setup: could not load the cached token")
+			require.Equal(t, got.Expiry.Unix(), cached.Token.Expiry.Unix(), "The returned token should be the cached one")
+			if tc.wantRefresh {
+				require.Less(t, time.Until(got.Expiry), 2*time.Hour, "SessionToken should return the refreshed token")
+			}
+		})
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		token *tokenOptions
+
+		wantErr    error
+		wantAnyErr bool
+	}{
+		"Refresh_the_valid_access_token":   {token: &tokenOptions{}},
+		"Refresh_the_expired_access_token": {token: &tokenOptions{expired: true}},
+
+		"Error_if_no_token_is_cached":                    {wantErr: broker.ErrNoCachedToken},
+		"Error_if_the_user_is_disabled":                  {token: &tokenOptions{userIsDisabled: true}, wantAnyErr: true},
+		"Error_if_there_is_no_refresh_token":             {token: &tokenOptions{noRefreshToken: true}, wantAnyErr: true},
+		"Error_if_the_refresh_token_expired_at_provider": {token: &tokenOptions{refreshTokenExpired: true}, wantAnyErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newBrokerForTests(t, &brokerForTestConfig{})
+
+			// The name of the user is the one in the tokens returned by the mock provider.
+			const username = "test-user@email.com"
+			if tc.token != nil {
+				setupUserCache(t, b, username, *tc.token, false, false)
+			}
+
+			got, err := b.RefreshToken(context.Background(), username)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr, "RefreshToken should have returned the expected error")
+				return
+			}
+			if tc.wantAnyErr {
+				require.Error(t, err, "RefreshToken should have returned an error")
+				return
+			}
+			require.NoError(t, err, "RefreshToken should not have returned an error")
+
+			require.Equal(t, username, got.Username, "RefreshToken should return the status of the token of the user")
+			require.True(t, got.HasRefreshToken, "RefreshToken should keep the refresh token")
+			require.Greater(t, time.Until(got.Expiry), 5*time.Minute, "RefreshToken should return a token which is still valid")
+			require.Less(t, time.Until(got.Expiry), 2*time.Hour, "RefreshToken should return the expiry of the refreshed token")
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package broker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/authmodes"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/msentraid/himmelblau"
+	"github.com/canonical/authd/log"
+)
+
+const (
+	// entraWebAuthnRPID is the relying party to which the security keys of the Entra ID users are registered.
+	entraWebAuthnRPID = "login.microsoft.com"
+	// webAuthnTimeoutMs is how long the client waits for the user to touch their security key.
+	webAuthnTimeoutMs = 60000
+)
+
+// webAuthnRequestOptions are the options of the WebAuthn assertion which the client asks the security key, in the
+// format of the PublicKeyCredentialRequestOptions of the WebAuthn API, with the binary values encoded in base64url.
+type webAuthnRequestOptions struct {
+	Challenge        string                    `json:"challenge"`
+	RPID             string                    `json:"rpId"`
+	AllowCredentials []webAuthnAllowCredential `json:"allowCredentials"`
+	UserVerification string                    `json:"userVerification"`
+	Timeout          int                       `json:"timeout"`
+}
+
+type webAuthnAllowCredential struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// webAuthnAssertion is the assertion signed by the security key, in the format of the PublicKeyCredential of the
+// WebAuthn API, with the binary values encoded in base64url.
+type webAuthnAssertion struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		AuthenticatorData string `json:"authenticatorData"`
+		ClientDataJSON    string `json:"clientDataJSON"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response"`
+	// Extensions is always empty, but Entra ID expects it.
+	Extensions struct{} `json:"extensions"`
+}
+
+// webAuthnLayoutContent returns the content of the layout asking the client to sign the FIDO challenge of the MFA
+// flow with a security key.
+func webAuthnLayoutContent(challengeInfo *himmelblau.MFAChallengeInfo) (string, error) {
+	if challengeInfo == nil || challengeInfo.FIDOChallenge == "" {
+		return "", errors.New("no active FIDO challenge")
+	}
+
+	opts := webAuthnRequestOptions{
+		Challenge:        challengeInfo.FIDOChallenge,
+		RPID:             entraWebAuthnRPID,
+		AllowCredentials: []webAuthnAllowCredential{},
+		UserVerification: "preferred",
+		Timeout:          webAuthnTimeoutMs,
+	}
+	for _, id := range challengeInfo.FIDOAllowList {
+		opts.AllowCredentials = append(opts.AllowCredentials, webAuthnAllowCredential{Type: "public-key", ID: id})
+	}
+
+	content, err := json.Marshal(opts)
+	if err != nil {
+		return "", fmt.Errorf("could not encode the WebAuthn request: %v", err)
+	}
+	return string(content), nil
+}
+
+// parseWebAuthnAssertion parses and validates the assertion sent by the client.
+func parseWebAuthnAssertion(rawAssertion string) (webAuthnAssertion, error) {
+	var a webAuthnAssertion
+	if err := json.Unmarshal([]byte(rawAssertion), &a); err != nil {
+		return webAuthnAssertion{}, fmt.Errorf("could not parse the WebAuthn assertion: %v", err)
+	}
+	if a.Type != "public-key" {
+		return webAuthnAssertion{}, fmt.Errorf("unexpected WebAuthn credential type %q", a.Type)
+	}
+
+	fields := map[string]string{
+		"id":                a.ID,
+		"rawId":             a.RawID,
+		"authenticatorData": a.Response.AuthenticatorData,
+		"clientDataJSON":    a.Response.ClientDataJSON,
+		"signature":         a.Response.Signature,
+	}
+	for name, value := range fields {
+		if value == "" {
+			return webAuthnAssertion{}, fmt.Errorf("the WebAuthn assertion has no %q", name)
+		}
+		if _, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "=")); err != nil {
+			return webAuthnAssertion{}, fmt.Errorf("the %q of the WebAuthn assertion is not base64url encoded: %v", name, err)
+		}
+	}
+	return a, nil
+}
+
+func (b *Broker) entraMFAFIDOAuth(ctx context.Context, session *session, rawAssertion string) (string, isAuthenticatedDataResponse) {
+	entraProvider, ok := providers.ProviderAs[himmelblau.EntraPasswordProvider](b.provider)
+	if !ok {
+		log.Error(context.Background(), "entra_mfa_fido mode selected but provider does not support it")
+		return AuthDenied, unexpectedErrMsg("provider does not support Entra MFA")
+	}
+
+	if session.mfaFlowActive == nil {
+		log.Error(context.Background(), "MFA FIDO mode selected but no active MFA flow")
+		return AuthDenied, unexpectedErrMsg("no active MFA flow")
+	}
+
+	assertion, err := parseWebAuthnAssertion(rawAssertion)
+	if err != nil {
+		log.Errorf(context.Background(), "Invalid WebAuthn assertion for user %q: %v", session.username, err)
+		return AuthRetry, errorMessage{Message: "The security key returned an invalid response. Please try again."}
+	}
+	authData, err := json.Marshal(assertion)
+	if err != nil {
+		log.Errorf(context.Background(), "Could not encode the WebAuthn assertion: %v", err)
+		return AuthDenied, unexpectedErrMsg("could not encode the WebAuthn assertion")
+	}
+
+	oauthToken, err := entraProvider.AcquireTokenByMFAFlow(
+		ctx, b.cfg.clientID, b.cfg.issuerURL, session.username,
+		session.mfaFlowActive, string(authData), 0,
+		b.cachedDeviceRegistrationData(session),
+	)
+	if err != nil {
+		var mfaErr *himmelblau.MFAError
+		if errors.As(err, &mfaErr) && mfaErr.IsMFADenied() {
+			log.Noticef(context.Background(), "MFA security key verification denied for user %q", session.username)
+			session.entraPasswordHash = ""
+			clearEntraMFAState(session)
+			return AuthDenied, errorMessage{Message: "MFA authentication was denied."}
+		}
+		if errors.As(err, &mfaErr) && mfaErr.IsMFARetryableCode() {
+			// The assertion was rejected while the flow is still valid, for
+			// example because another security key was used: let the user try
+			// again with the same challenge.
+			log.Noticef(context.Background(), "Security key assertion rejected for user %q, re-prompting", session.username)
+			return AuthRetry, errorMessage{Message: "The security key could not be verified. Please try again."}
+		}
+		log.Noticef(context.Background(), "MFA security key verification failed for user %q: %v", session.username, err)
+		session.entraPasswordHash = ""
+		clearEntraMFAState(session)
+		session.nextAuthModes = []string{authmodes.EntraPassword}
+		return AuthNext, errorMessage{Message: "MFA authentication failed. Please try again."}
+	}
+
+	clearEntraMFAState(session)
+	return b.finishEntraAuth(ctx, session, oauthToken)
+}
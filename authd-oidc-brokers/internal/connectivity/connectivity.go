@@ -0,0 +1,155 @@
+// Package connectivity tracks whether the machine is connected to a network, as reported by NetworkManager or
+// systemd-networkd on the system bus.
+package connectivity
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/canonical/authd/log"
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	propertiesIface   = "org.freedesktop.DBus.Properties"
+	propertiesChanged = propertiesIface + ".PropertiesChanged"
+
+	nmName  = "org.freedesktop.NetworkManager"
+	nmPath  = dbus.ObjectPath("/org/freedesktop/NetworkManager")
+	nmIface = nmName
+	// nmStateProperty is the overall networking state of NetworkManager.
+	nmStateProperty = "State"
+	// nmConnectivityProperty is the result of the last connectivity check of NetworkManager.
+	nmConnectivityProperty = "Connectivity"
+	// nmConnectivityPortal is the connectivity of a network which requires signing in through a captive portal
+	// before reaching the internet (NM_CONNECTIVITY_PORTAL).
+	nmConnectivityPortal = 2
+
+	networkdName  = "org.freedesktop.network1"
+	networkdPath  = dbus.ObjectPath("/org/freedesktop/network1")
+	networkdIface = networkdName + ".Manager"
+	// networkdStateProperty is the aggregated operational state of the links managed by systemd-networkd.
+	networkdStateProperty = "OperationalState"
+)
+
+// nmOfflineStates are the NetworkManager states in which no network is connected, for example in airplane mode
+// (NM_STATE_ASLEEP and NM_STATE_DISCONNECTED).
+var nmOfflineStates = []uint32{10, 20}
+
+// networkdOfflineStates are the systemd-networkd operational states in which no link has an address.
+var networkdOfflineStates = []string{"off", "no-carrier", "dormant", "degraded-carrier", "carrier"}
+
+// Monitor reports whether the machine is clearly offline, or behind a captive portal. It errs on the side of being
+// online: if the state of the network can't be determined, for example because neither NetworkManager nor
+// systemd-networkd is running, the machine is considered online so that the provider is still tried.
+type Monitor struct {
+	conn *dbus.Conn
+
+	offline   bool
+	portal    bool
+	mu        sync.RWMutex
+	callbacks []func(offline bool)
+}
+
+// New returns a Monitor of the network managers on the bus of conn. The state is updated with the signals of the
+// network managers until the connection is closed.
+func New(ctx context.Context, conn *dbus.Conn) *Monitor {
+	m := &Monitor{conn: conn}
+
+	for _, opts := range [][]dbus.MatchOption{
+		{dbus.WithMatchObjectPath(nmPath), dbus.WithMatchInterface(propertiesIface), dbus.WithMatchMember("PropertiesChanged")},
+		{dbus.WithMatchObjectPath(networkdPath), dbus.WithMatchInterface(propertiesIface), dbus.WithMatchMember("PropertiesChanged")},
+	} {
+		if err := conn.AddMatchSignal(opts...); err != nil {
+			log.Warningf(ctx, "Could not subscribe to the network state: %v", err)
+		}
+	}
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+
+	m.update(ctx)
+	go func() {
+		for s := range signals {
+			if s.Name != propertiesChanged || (s.Path != nmPath && s.Path != networkdPath) {
+				continue
+			}
+			m.update(ctx)
+		}
+	}()
+
+	return m
+}
+
+// Offline returns true if the machine is not connected to any network.
+func (m *Monitor) Offline() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.offline
+}
+
+// CaptivePortal returns true if NetworkManager detected that the network requires signing in through a captive
+// portal. It's always false if the connectivity checks of NetworkManager are disabled.
+func (m *Monitor) CaptivePortal() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.portal
+}
+
+// OnChange registers f to be called when the machine goes offline or back online.
+func (m *Monitor) OnChange(f func(offline bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.callbacks = append(m.callbacks, f)
+}
+
+// update reads the state of the network managers and calls the callbacks if it changed.
+func (m *Monitor) update(ctx context.Context) {
+	offline, portal := m.read(ctx)
+
+	m.mu.Lock()
+	changed := offline != m.offline
+	m.offline = offline
+	m.portal = portal
+	callbacks := slices.Clone(m.callbacks)
+	m.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if offline {
+		log.Notice(ctx, "The machine is offline, the users will be authenticated locally")
+	} else {
+		log.Notice(ctx, "The machine is back online")
+	}
+	for _, f := range callbacks {
+		f(offline)
+	}
+}
+
+// read returns whether NetworkManager, or systemd-networkd if NetworkManager is not running, reports that no network
+// is connected, and whether NetworkManager detected a captive portal.
+func (m *Monitor) read(ctx context.Context) (offline, portal bool) {
+	nm := m.conn.Object(nmName, nmPath)
+	var nmState uint32
+	err := nm.StoreProperty(nmIface+"."+nmStateProperty, &nmState)
+	if err == nil {
+		var nmConnectivity uint32
+		if err := nm.StoreProperty(nmIface+"."+nmConnectivityProperty, &nmConnectivity); err != nil {
+			log.Debugf(ctx, "Could not get the connectivity of NetworkManager: %v", err)
+		}
+		log.Debugf(ctx, "NetworkManager state: %d, connectivity: %d", nmState, nmConnectivity)
+		return slices.Contains(nmOfflineStates, nmState), nmConnectivity == nmConnectivityPortal
+	}
+	log.Debugf(ctx, "Could not get the state of NetworkManager: %v", err)
+
+	var networkdState string
+	err = m.conn.Object(networkdName, networkdPath).StoreProperty(networkdIface+"."+networkdStateProperty, &networkdState)
+	if err == nil {
+		log.Debugf(ctx, "systemd-networkd operational state: %s", networkdState)
+		return slices.Contains(networkdOfflineStates, networkdState), false
+	}
+	log.Debugf(ctx, "Could not get the state of systemd-networkd: %v", err)
+
+	return false, false
+}
@@ -0,0 +1,135 @@
+package connectivity_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/connectivity"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/testutils"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	nmName    = "org.freedesktop.NetworkManager"
+	nmPath    = "/org/freedesktop/NetworkManager"
+	nmConnect = uint32(70) // NM_STATE_CONNECTED_GLOBAL
+	nmAsleep  = uint32(10) // NM_STATE_ASLEEP
+
+	networkdName  = "org.freedesktop.network1"
+	networkdPath  = "/org/freedesktop/network1"
+	networkdIface = "org.freedesktop.network1.Manager"
+)
+
+func TestMonitor(t *testing.T) {
+	tests := map[string]struct {
+		nmState        *uint32
+		nmConnectivity uint32
+		networkdState  string
+
+		wantOffline bool
+		wantPortal  bool
+	}{
+		"Online_when_NetworkManager_is_connected":         {nmState: ptr(nmConnect)},
+		"Online_when_NetworkManager_is_connecting":        {nmState: ptr(uint32(40))},
+		"Online_when_systemd-networkd_has_routable_links": {networkdState: "routable"},
+		"Online_when_systemd-networkd_has_degraded_links": {networkdState: "degraded"},
+		"Online_when_no_network_manager_is_running":       {},
+		"Online_when_NetworkManager_is_preferred":         {nmState: ptr(nmConnect), networkdState: "no-carrier"},
+
+		"Offline_when_NetworkManager_is_asleep":            {nmState: ptr(nmAsleep), wantOffline: true},
+		"Offline_when_NetworkManager_is_disconnected":      {nmState: ptr(uint32(20)), wantOffline: true},
+		"Offline_when_systemd-networkd_has_no_carrier":     {networkdState: "no-carrier", wantOffline: true},
+		"Offline_when_systemd-networkd_links_are_off":      {networkdState: "off", wantOffline: true},
+		"Offline_when_systemd-networkd_links_have_no_addr": {networkdState: "carrier", wantOffline: true},
+
+		"Captive_portal_when_detected_by_NetworkManager":        {nmState: ptr(nmConnect), nmConnectivity: 2, wantPortal: true},
+		"No_captive_portal_when_NetworkManager_has_full_access": {nmState: ptr(nmConnect), nmConnectivity: 4},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The mocks own well-known names on the bus, so the tests can't run in parallel.
+			if tc.nmState != nil {
+				exportManager(t, nmName, nmPath, nmName, map[string]any{"State": *tc.nmState, "Connectivity": tc.nmConnectivity})
+			}
+			if tc.networkdState != "" {
+				exportManager(t, networkdName, networkdPath, networkdIface, map[string]any{"OperationalState": tc.networkdState})
+			}
+
+			m := connectivity.New(context.Background(), connectToBus(t))
+			require.Equal(t, tc.wantOffline, m.Offline(), "Offline should return the expected state")
+			require.Equal(t, tc.wantPortal, m.CaptivePortal(), "CaptivePortal should return the expected state")
+		})
+	}
+}
+
+func TestMonitorFollowsChanges(t *testing.T) {
+	props := exportManager(t, nmName, nmPath, nmName, map[string]any{"State": nmConnect})
+
+	m := connectivity.New(context.Background(), connectToBus(t))
+	require.False(t, m.Offline(), "Setup: the machine should be online")
+	changes := make(chan bool, 10)
+	m.OnChange(func(offline bool) { changes <- offline })
+
+	for _, wantOffline := range []bool{true, false} {
+		state := nmConnect
+		if wantOffline {
+			state = nmAsleep
+		}
+		props.SetMust(nmName, "State", state)
+
+		select {
+		case offline := <-changes:
+			require.Equal(t, wantOffline, offline, "The callback should be called with the new state")
+		case <-time.After(5 * time.Second):
+			t.Fatal("The callback was not called after the network state changed")
+		}
+		require.Equal(t, wantOffline, m.Offline(), "Offline should return the new state")
+	}
+}
+
+// exportManager exports the state properties of a network manager on the bus. The name is released at the end of the
+// test.
+func exportManager(t *testing.T, name string, path dbus.ObjectPath, iface string, values map[string]any) *prop.Properties {
+	t.Helper()
+
+	conn := connectToBus(t)
+	properties := map[string]*prop.Prop{}
+	for property, value := range values {
+		properties[property] = &prop.Prop{Value: value, Emit: prop.EmitTrue}
+	}
+	props, err := prop.Export(conn, path, prop.Map{iface: properties})
+	require.NoError(t, err, "Setup: could not export the properties of %s", name)
+	reply, err := conn.RequestName(name, dbus.NameFlagDoNotQueue)
+	require.NoError(t, err, "Setup: could not request name %s", name)
+	require.Equal(t, dbus.RequestNameReplyPrimaryOwner, reply, "Setup: name %s should not be owned already", name)
+	return props
+}
+
+func connectToBus(t *testing.T) *dbus.Conn {
+	t.Helper()
+
+	conn, err := testutils.GetSystemBusConnection(t)
+	require.NoError(t, err, "Setup: could not connect to the system bus")
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestMain(m *testing.M) {
+	cleanup, err := testutils.StartSystemBusMock()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	defer cleanup()
+
+	m.Run()
+}
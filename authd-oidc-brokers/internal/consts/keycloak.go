@@ -0,0 +1,10 @@
+//go:build withkeycloak
+
+package consts
+
+const (
+	// DbusName owned by the broker for authd to contact us.
+	DbusName = "com.ubuntu.authd.Keycloak"
+	// DbusObject main object path for authd to contact us.
+	DbusObject = "/com/ubuntu/authd/Keycloak"
+)
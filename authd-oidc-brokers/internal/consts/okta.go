@@ -0,0 +1,10 @@
+//go:build withokta
+
+package consts
+
+const (
+	// DbusName owned by the broker for authd to contact us.
+	DbusName = "com.ubuntu.authd.Okta"
+	// DbusObject main object path for authd to contact us.
+	DbusObject = "/com/ubuntu/authd/Okta"
+)
@@ -0,0 +1,173 @@
+package dbusservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker"
+	"github.com/canonical/authd/log"
+	"github.com/godbus/dbus/v5"
+)
+
+// ControlInterface is the name of the dbus interface through which the administrators manage the broker.
+const ControlInterface = "com.ubuntu.authd.BrokerControl"
+
+// Control is the object representing the control dbus interface. Its calls are only accepted from callers running as
+// the same user as the broker.
+type Control struct {
+	// brokers are the brokers of all the versions of the interface, from the oldest to the latest.
+	brokers   []*broker.Broker
+	callerUID func(sender dbus.Sender) (uint32, error)
+}
+
+func newControl(conn *dbus.Conn, brokers []*broker.Broker) *Control {
+	return &Control{
+		brokers: brokers,
+		callerUID: func(sender dbus.Sender) (uid uint32, err error) {
+			err = conn.BusObject().Call("org.freedesktop.DBus.GetConnectionUnixUser", 0, string(sender)).Store(&uid)
+			return uid, err
+		},
+	}
+}
+
+// TokenStatus returns the status of the token cached for the user, in JSON format.
+func (c *Control) TokenStatus(sender dbus.Sender, username string) (status string, dbusErr *dbus.Error) {
+	if dbusErr := c.checkCaller(sender); dbusErr != nil {
+		return "", dbusErr
+	}
+	log.Debugf(context.Background(), "TokenStatus: %s", username)
+
+	s, err := c.latestBroker().TokenStatus(username)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return "", dbus.MakeFailedError(fmt.Errorf("could not marshal token status: %v", err))
+	}
+	return string(encoded), nil
+}
+
+// Logout removes the credentials cached for the user.
+func (c *Control) Logout(sender dbus.Sender, username string) (dbusErr *dbus.Error) {
+	if dbusErr := c.checkCaller(sender); dbusErr != nil {
+		return dbusErr
+	}
+	log.Debugf(context.Background(), "Logout: %s", username)
+
+	if err := c.latestBroker().Logout(username); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// ClearCache drops the provider metadata cached by the brokers of all the interfaces.
+func (c *Control) ClearCache(sender dbus.Sender) (dbusErr *dbus.Error) {
+	if dbusErr := c.checkCaller(sender); dbusErr != nil {
+		return dbusErr
+	}
+	log.Debug(context.Background(), "ClearCache")
+
+	for _, b := range c.brokers {
+		b.ClearCache()
+	}
+	return nil
+}
+
+// WhoAmI returns the description of the broker, in JSON format.
+func (c *Control) WhoAmI(sender dbus.Sender) (identity string, dbusErr *dbus.Error) {
+	if dbusErr := c.checkCaller(sender); dbusErr != nil {
+		return "", dbusErr
+	}
+
+	encoded, err := json.Marshal(c.latestBroker().Identity())
+	if err != nil {
+		return "", dbus.MakeFailedError(fmt.Errorf("could not marshal broker identity: %v", err))
+	}
+	return string(encoded), nil
+}
+
+// SessionToken returns the access token of the user for scopes, in JSON format. It's called by authd on behalf of the
+// applications running in the session of the user.
+func (c *Control) SessionToken(sender dbus.Sender, username string, scopes []string) (sessionToken string, dbusErr *dbus.Error) {
+	if dbusErr := c.checkCaller(sender); dbusErr != nil {
+		return "", dbusErr
+	}
+	log.Debugf(context.Background(), "SessionToken: %s %v", username, scopes)
+
+	t, err := c.latestBroker().SessionToken(context.Background(), username, scopes)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	encoded, err := json.Marshal(t)
+	if err != nil {
+		return "", dbus.MakeFailedError(fmt.Errorf("could not marshal session token: %v", err))
+	}
+	return string(encoded), nil
+}
+
+// RefreshToken refreshes the token cached for the user and returns its new status, in JSON format. It's called by authd
+// when the session of the user asks to be extended.
+func (c *Control) RefreshToken(sender dbus.Sender, username string) (status string, dbusErr *dbus.Error) {
+	if dbusErr := c.checkCaller(sender); dbusErr != nil {
+		return "", dbusErr
+	}
+	log.Debugf(context.Background(), "RefreshToken: %s", username)
+
+	s, err := c.latestBroker().RefreshToken(context.Background(), username)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return "", dbus.MakeFailedError(fmt.Errorf("could not marshal token status: %v", err))
+	}
+	return string(encoded), nil
+}
+
+// SetSessionHints passes the hints given by authd about the session to the broker, like the time zone of the user.
+func (c *Control) SetSessionHints(sender dbus.Sender, sessionID string, hints map[string]string) (dbusErr *dbus.Error) {
+	if dbusErr := c.checkCaller(sender); dbusErr != nil {
+		return dbusErr
+	}
+	log.Debugf(context.Background(), "SetSessionHints: %s %v", sessionID, hints)
+
+	if err := c.latestBroker().SetSessionHints(sessionID, hints); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// CheckProvider checks the connection of the broker to its provider, without any user logging in, and returns the
+// results of the checks in JSON format.
+func (c *Control) CheckProvider(sender dbus.Sender) (checks string, dbusErr *dbus.Error) {
+	if dbusErr := c.checkCaller(sender); dbusErr != nil {
+		return "", dbusErr
+	}
+	log.Debug(context.Background(), "CheckProvider")
+
+	encoded, err := json.Marshal(c.latestBroker().CheckProvider(context.Background()))
+	if err != nil {
+		return "", dbus.MakeFailedError(fmt.Errorf("could not marshal provider checks: %v", err))
+	}
+	return string(encoded), nil
+}
+
+func (c *Control) checkCaller(sender dbus.Sender) *dbus.Error {
+	uid, err := c.callerUID(sender)
+	if err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("could not identify the caller: %v", err))
+	}
+	if uid != uint32(os.Geteuid()) {
+		log.Warningf(context.Background(), "Denied control request from UID %d", uid)
+		return &dbus.Error{Name: "org.freedesktop.DBus.Error.AccessDenied", Body: []any{"permission denied"}}
+	}
+	return nil
+}
+
+// latestBroker returns the broker of the latest interface version, which is the one used by authd.
+func (c *Control) latestBroker() *broker.Broker {
+	return c.brokers[len(c.brokers)-1]
+}
@@ -0,0 +1,65 @@
+package dbusservice_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/dbusservice"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControl(t *testing.T) {
+	t.Parallel()
+
+	b := newInterfaceForTests(t).Broker()
+	c := dbusservice.NewControlForTests(os.Geteuid(), b)
+
+	identity, dbusErr := c.WhoAmI("")
+	require.Nil(t, dbusErr, "WhoAmI should not return a D-Bus error")
+	var got broker.Identity
+	require.NoError(t, json.Unmarshal([]byte(identity), &got), "WhoAmI should return the identity in JSON format")
+	require.Equal(t, b.Identity(), got, "WhoAmI should return the identity of the broker")
+
+	require.Nil(t, c.ClearCache(""), "ClearCache should not return a D-Bus error")
+
+	_, dbusErr = c.TokenStatus("", "unknown@example.com")
+	require.NotNil(t, dbusErr, "TokenStatus for a user without a cached token should return a D-Bus error")
+	require.NotNil(t, c.Logout("", "unknown@example.com"), "Logout for a user without a cached token should return a D-Bus error")
+	_, dbusErr = c.SessionToken("", "unknown@example.com", []string{"openid"})
+	require.NotNil(t, dbusErr, "SessionToken without approved scopes should return a D-Bus error")
+	_, dbusErr = c.RefreshToken("", "unknown@example.com")
+	require.NotNil(t, dbusErr, "RefreshToken for a user without a cached token should return a D-Bus error")
+	require.NotNil(t, c.SetSessionHints("", "unknown-session", map[string]string{"timezone": "UTC"}),
+		"SetSessionHints for an unknown session should return a D-Bus error")
+
+	checks, dbusErr := c.CheckProvider("")
+	require.Nil(t, dbusErr, "CheckProvider should not return a D-Bus error")
+	var gotChecks []broker.ProviderCheck
+	require.NoError(t, json.Unmarshal([]byte(checks), &gotChecks), "CheckProvider should return the checks in JSON format")
+	require.NotEmpty(t, gotChecks, "CheckProvider should return the checks of the provider")
+}
+
+func TestControlDeniesOtherUsers(t *testing.T) {
+	t.Parallel()
+
+	b := newInterfaceForTests(t).Broker()
+	c := dbusservice.NewControlForTests(os.Geteuid()+1, b)
+
+	const accessDenied = "org.freedesktop.DBus.Error.AccessDenied"
+
+	_, dbusErr := c.WhoAmI("")
+	require.Equal(t, accessDenied, dbusErr.Name, "WhoAmI should be denied")
+	_, dbusErr = c.TokenStatus("", "user@example.com")
+	require.Equal(t, accessDenied, dbusErr.Name, "TokenStatus should be denied")
+	require.Equal(t, accessDenied, c.Logout("", "user@example.com").Name, "Logout should be denied")
+	require.Equal(t, accessDenied, c.ClearCache("").Name, "ClearCache should be denied")
+	_, dbusErr = c.SessionToken("", "user@example.com", []string{"openid"})
+	require.Equal(t, accessDenied, dbusErr.Name, "SessionToken should be denied")
+	_, dbusErr = c.RefreshToken("", "user@example.com")
+	require.Equal(t, accessDenied, dbusErr.Name, "RefreshToken should be denied")
+	require.Equal(t, accessDenied, c.SetSessionHints("", "session-id", nil).Name, "SetSessionHints should be denied")
+	_, dbusErr = c.CheckProvider("")
+	require.Equal(t, accessDenied, dbusErr.Name, "CheckProvider should be denied")
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 
 	"github.com/canonical/authd/authd-oidc-brokers/internal/broker"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/connectivity"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/consts"
 	"github.com/canonical/authd/log"
 	"github.com/godbus/dbus/v5"
@@ -25,6 +26,9 @@ var (
 	// interfaceV3 extends the base interface with a provider_id argument in NewSession and DeleteUser.
 	//go:embed interfaces/com.ubuntu.authd.BrokerV3.xml
 	interfaceV3 string
+
+	//go:embed interfaces/com.ubuntu.authd.BrokerControl.xml
+	controlInterface string
 )
 
 // Service is the object representing the dbus service, which contains the exported interfaces and the necessary
@@ -34,6 +38,8 @@ type Service struct {
 	interfaces []*Interface
 	disconnect func()
 	serve      chan struct{}
+	// stopTokenRefresh stops the background refresh of the cached tokens.
+	stopTokenRefresh context.CancelFunc
 }
 
 // Interface is the object representing a dbus interface, which contains the broker to which delegate the calls and the
@@ -64,15 +70,25 @@ func New(_ context.Context, brokerConfig broker.Config) (*Service, error) {
 		return nil, err
 	}
 
+	monitor := connectivity.New(context.Background(), conn)
+
 	var introspectableBody string
 	for i, iface := range interfaceNames {
 		log.Debugf(context.Background(), "Initializing broker for interface %s", iface)
 		version := uint(i) + 1 // There's no 0 version, so we start from 1.
-		b, err := broker.New(brokerConfig, version)
+		b, err := broker.New(brokerConfig, version, broker.WithConnectivityMonitor(monitor))
 		if err != nil {
 			service.disconnect()
 			return nil, err
 		}
+		// Fetch the provider metadata in the background, so that the first login after boot is as fast as the next ones.
+		go b.Prewarm(context.Background())
+		// The metadata couldn't be fetched while the machine was offline.
+		monitor.OnChange(func(offline bool) {
+			if !offline {
+				go b.Prewarm(context.Background())
+			}
+		})
 
 		s := &Interface{
 			iface:  iface,
@@ -99,6 +115,16 @@ func New(_ context.Context, brokerConfig broker.Config) (*Service, error) {
 		service.interfaces = append(service.interfaces, s)
 	}
 
+	brokers := make([]*broker.Broker, 0, len(service.interfaces))
+	for _, s := range service.interfaces {
+		brokers = append(brokers, s.broker)
+	}
+	if err := conn.Export(newControl(conn, brokers), object, ControlInterface); err != nil {
+		service.disconnect()
+		return nil, err
+	}
+	introspectableBody = introspectableBody + fmt.Sprintf(controlInterface, ControlInterface)
+
 	// Build combined introspection XML for all versioned interfaces and export once.
 	introspectable := introspect.Introspectable(introspectableHeader + introspectableBody + introspectableFooter)
 	if err := conn.Export(introspectable, object, "org.freedesktop.DBus.Introspectable"); err != nil {
@@ -116,6 +142,11 @@ func New(_ context.Context, brokerConfig broker.Config) (*Service, error) {
 		return nil, fmt.Errorf("%q is already taken in the bus", name)
 	}
 
+	// The tokens are refreshed by the broker used by authd only, as the brokers of all versions share the cache.
+	ctx, cancel := context.WithCancel(context.Background())
+	service.stopTokenRefresh = cancel
+	go brokers[len(brokers)-1].RefreshTokensPeriodically(ctx)
+
 	return service, nil
 }
 
@@ -137,6 +168,7 @@ func (s *Service) Stop() error {
 	case <-s.serve:
 	default:
 		close(s.serve)
+		s.stopTokenRefresh()
 		s.disconnect()
 	}
 
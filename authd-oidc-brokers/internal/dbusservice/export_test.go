@@ -1,6 +1,9 @@
 package dbusservice
 
-import "github.com/canonical/authd/authd-oidc-brokers/internal/broker"
+import (
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker"
+	"github.com/godbus/dbus/v5"
+)
 
 // NewInterfaceForTests returns an Interface wrapping the given broker, exposing
 // the v3 D-Bus methods for tests.
@@ -18,3 +21,12 @@ func NewInterfaceV2ForTests(b *broker.Broker) *InterfaceV2 {
 func (s *Interface) Broker() *broker.Broker {
 	return s.broker
 }
+
+// NewControlForTests returns a Control managing the given brokers, for tests. The calls are made by a caller with the
+// given UID.
+func NewControlForTests(callerUID int, brokers ...*broker.Broker) *Control {
+	return &Control{
+		brokers:   brokers,
+		callerUID: func(dbus.Sender) (uint32, error) { return uint32(callerUID), nil },
+	}
+}
@@ -0,0 +1,141 @@
+// Package l10n translates the messages that the broker displays to the users in their language.
+package l10n
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/canonical/authd/log"
+	"github.com/leonelquinteros/gotext"
+)
+
+// DefaultLanguage is the language of the messages in the source code, which the administrators can override too.
+const DefaultLanguage = "C"
+
+// validLanguage matches the language codes which are looked up, like "fr" or "pt_BR". It also prevents the language
+// sent by the client from being used to read files outside of the catalog directories.
+var validLanguage = regexp.MustCompile(`^[a-z]{2,3}(_[A-Z]{2})?$`)
+
+// Catalog returns the translations of the messages from the po files embedded in the broker and from the ones
+// provided by the administrator.
+type Catalog struct {
+	embedded     fs.FS
+	overridesDir string
+
+	// translations are the translated messages of each language, from the overrides to the embedded ones.
+	translations   map[string][]map[string]string
+	translationsMu sync.Mutex
+}
+
+// New returns a catalog which loads the translations from the <lang>.po files of embedded and of overridesDir. The
+// translations of overridesDir take precedence. Any of them can be empty, in which case it's not used.
+func New(embedded fs.FS, overridesDir string) *Catalog {
+	return &Catalog{
+		embedded:     embedded,
+		overridesDir: overridesDir,
+		translations: make(map[string][]map[string]string),
+	}
+}
+
+// Get returns msgid translated in lang.
+//
+// The translation of the locale (e.g. "pt_BR") is preferred to the one of its language ("pt"). If neither is found,
+// the override of DefaultLanguage is used if any, so that the administrator can customize the messages for all the
+// users, otherwise msgid itself.
+func (c *Catalog) Get(lang, msgid string) string {
+	if tr, ok := c.translation(lang, msgid); ok {
+		return tr
+	}
+	return msgid
+}
+
+// Getf is like Get, but formats the translation of format with vars.
+func (c *Catalog) Getf(lang, format string, vars ...any) string {
+	if tr, ok := c.translation(lang, format); ok {
+		format = tr
+	}
+	return fmt.Sprintf(format, vars...)
+}
+
+func (c *Catalog) translation(lang, msgid string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	for _, l := range candidates(lang) {
+		for _, translations := range c.translationsOf(l) {
+			if tr, ok := translations[msgid]; ok {
+				return tr, true
+			}
+		}
+	}
+	return "", false
+}
+
+// candidates returns the languages in which a message is looked up for lang, from the most to the least specific.
+func candidates(lang string) []string {
+	lang = gotext.SimplifiedLocale(lang)
+	if !validLanguage.MatchString(lang) {
+		return []string{DefaultLanguage}
+	}
+
+	langs := []string{lang}
+	if base, _, found := strings.Cut(lang, "_"); found {
+		langs = append(langs, base)
+	}
+	return append(langs, DefaultLanguage)
+}
+
+// translationsOf returns the translations of lang, loading them on first use.
+func (c *Catalog) translationsOf(lang string) []map[string]string {
+	c.translationsMu.Lock()
+	defer c.translationsMu.Unlock()
+
+	if t, ok := c.translations[lang]; ok {
+		return t
+	}
+
+	var all []map[string]string
+	if c.overridesDir != "" {
+		if t := loadPo(os.DirFS(c.overridesDir), lang); t != nil {
+			all = append(all, t)
+		}
+	}
+	// The messages of the source code are in the default language, so there is nothing embedded for it.
+	if c.embedded != nil && lang != DefaultLanguage {
+		if t := loadPo(c.embedded, lang); t != nil {
+			all = append(all, t)
+		}
+	}
+
+	c.translations[lang] = all
+	return all
+}
+
+// loadPo returns the translated messages of the po file of lang in fsys, or nil if there is none.
+func loadPo(fsys fs.FS, lang string) map[string]string {
+	buf, err := fs.ReadFile(fsys, lang+".po")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		log.Warningf(context.Background(), "Could not read the translations for %q: %v", lang, err)
+		return nil
+	}
+
+	po := gotext.NewPo()
+	po.Parse(buf)
+
+	translations := make(map[string]string)
+	for msgid, t := range po.GetDomain().GetTranslations() {
+		if msgid != "" && t.IsTranslated() {
+			translations[msgid] = t.Get()
+		}
+	}
+	return translations
+}
@@ -0,0 +1,102 @@
+package l10n_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/l10n"
+	"github.com/stretchr/testify/require"
+)
+
+func po(translations ...string) *fstest.MapFile {
+	var content string
+	for i := 0; i+1 < len(translations); i += 2 {
+		content += "msgid \"" + translations[i] + "\"\nmsgstr \"" + translations[i+1] + "\"\n\n"
+	}
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	embedded := fstest.MapFS{
+		"fr.po":    po("Hello", "Bonjour", "Goodbye", "Au revoir", "Untranslated", ""),
+		"pt.po":    po("Hello", "Olá"),
+		"pt_BR.po": po("Goodbye", "Tchau"),
+		"C.po":     po("Hello", "Embedded default language is ignored"),
+	}
+	overrides := fstest.MapFS{
+		"fr.po": po("Goodbye", "À bientôt"),
+		"C.po":  po("Welcome", "Welcome to the company"),
+	}
+
+	tests := map[string]struct {
+		lang  string
+		msgid string
+
+		noOverrides bool
+
+		want string
+	}{
+		"Translation_of_the_language":                     {lang: "fr", msgid: "Hello", want: "Bonjour"},
+		"Translation_of_the_language_of_the_locale":       {lang: "fr_FR.UTF-8", msgid: "Hello", want: "Bonjour"},
+		"Translation_of_the_locale_has_precedence":        {lang: "pt_BR", msgid: "Goodbye", want: "Tchau"},
+		"Translation_of_the_language_is_used_as_fallback": {lang: "pt_BR", msgid: "Hello", want: "Olá"},
+		"Override_has_precedence":                         {lang: "fr_FR", msgid: "Goodbye", want: "À bientôt"},
+		"Override_of_default_language_is_used_for_any_language": {
+			lang: "fr", msgid: "Welcome", want: "Welcome to the company",
+		},
+		"Override_of_default_language_is_used_for_it":       {lang: "C", msgid: "Welcome", want: "Welcome to the company"},
+		"Message_is_translated_without_overrides_directory": {lang: "fr", msgid: "Goodbye", noOverrides: true, want: "Au revoir"},
+
+		"Message_is_returned_as_is_for_the_default_language":      {lang: "C", msgid: "Hello", want: "Hello"},
+		"Message_is_returned_as_is_for_an_empty_language":         {msgid: "Hello", want: "Hello"},
+		"Message_is_returned_as_is_without_translation":           {lang: "fr", msgid: "Unknown", want: "Unknown"},
+		"Message_is_returned_as_is_with_empty_translation":        {lang: "fr", msgid: "Untranslated", want: "Untranslated"},
+		"Message_is_returned_as_is_for_a_language_without_po":     {lang: "de_DE", msgid: "Hello", want: "Hello"},
+		"Message_is_returned_as_is_for_an_invalid_language":       {lang: "../fr", msgid: "Hello", want: "Hello"},
+		"Message_is_returned_as_is_for_a_language_with_a_path":    {lang: "fr/../fr", msgid: "Hello", want: "Hello"},
+		"Modifier_and_codeset_of_the_locale_are_ignored":          {lang: "fr_FR.ISO-8859-1@euro", msgid: "Hello", want: "Bonjour"},
+		"Override_of_default_language_is_used_for_invalid_locale": {lang: "-", msgid: "Welcome", want: "Welcome to the company"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var overridesDir string
+			if !tc.noOverrides {
+				overridesDir = t.TempDir()
+				for name, f := range overrides {
+					err := os.WriteFile(filepath.Join(overridesDir, name), f.Data, 0600)
+					require.NoError(t, err, "Setup: could not write override")
+				}
+			}
+
+			c := l10n.New(embedded, overridesDir)
+			require.Equal(t, tc.want, c.Get(tc.lang, tc.msgid), "Get should return the expected message")
+			// The translations are loaded once, so the result of the second call should come from the cache.
+			require.Equal(t, tc.want, c.Get(tc.lang, tc.msgid), "Get should return the same message again")
+		})
+	}
+}
+
+func TestGetf(t *testing.T) {
+	t.Parallel()
+
+	c := l10n.New(fstest.MapFS{"fr.po": po("Your account is disabled in %s", "Votre compte est désactivé dans %s")}, "")
+
+	require.Equal(t, "Votre compte est désactivé dans Entra ID", c.Getf("fr_FR", "Your account is disabled in %s", "Entra ID"),
+		"Getf should format the translated message")
+	require.Equal(t, "Your account is disabled in Entra ID", c.Getf("de_DE", "Your account is disabled in %s", "Entra ID"),
+		"Getf should format the message without translation")
+}
+
+func TestNilCatalog(t *testing.T) {
+	t.Parallel()
+
+	var c *l10n.Catalog
+	require.Equal(t, "Hello", c.Get("fr", "Hello"), "Get on a nil catalog should return the message as is")
+	require.Equal(t, "Hello world", c.Getf("fr", "Hello %s", "world"), "Getf on a nil catalog should format the message")
+}
@@ -0,0 +1,123 @@
+package mockidp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Behavior is how the provider deviates from a healthy one. It can be changed while the server is running, with
+// SetBehavior or with a PUT request to /mock/behavior.
+type Behavior struct {
+	// Delay is how long the endpoints of the provider wait before answering, to rehearse a slow provider.
+	Delay time.Duration
+	// FailRequests is the number of the next requests to the endpoints of the provider which are answered with a 503
+	// Service Unavailable status. It decreases with each failed request.
+	FailRequests int
+	// ExpiredTokens makes the provider issue tokens which are already expired.
+	ExpiredTokens bool
+	// RejectRefreshTokens makes the provider reject the refresh tokens, as when the session of the user was revoked.
+	RejectRefreshTokens bool
+	// AutoApprove is the preferred username of the user as whom the device authorizations are approved without
+	// visiting the verification page. If empty, they have to be approved on the verification page or with Approve.
+	AutoApprove string
+}
+
+// behaviorJSON is the representation of a Behavior in the requests to /mock/behavior, in which the durations are
+// strings like "1.5s".
+type behaviorJSON struct {
+	Delay               string `json:"delay,omitempty"`
+	FailRequests        int    `json:"fail_requests,omitempty"`
+	ExpiredTokens       bool   `json:"expired_tokens,omitempty"`
+	RejectRefreshTokens bool   `json:"reject_refresh_tokens,omitempty"`
+	AutoApprove         string `json:"auto_approve,omitempty"`
+}
+
+// SetBehavior changes the behavior of the provider for the next requests.
+func (s *Server) SetBehavior(b Behavior) error {
+	if b.AutoApprove != "" {
+		if _, err := s.user(b.AutoApprove); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.behavior = b
+	s.failures = b.FailRequests
+	return nil
+}
+
+// withBehavior returns a handler which applies the delay and the failures of the behavior before calling handler.
+func (s *Server) withBehavior(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		delay := s.behavior.Delay
+		fail := s.failures > 0
+		if fail {
+			s.failures--
+		}
+		s.mu.Unlock()
+
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		handler(w, r)
+	})
+}
+
+func (s *Server) getBehavior(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	b := behaviorJSON{
+		FailRequests:        s.failures,
+		ExpiredTokens:       s.behavior.ExpiredTokens,
+		RejectRefreshTokens: s.behavior.RejectRefreshTokens,
+		AutoApprove:         s.behavior.AutoApprove,
+	}
+	if s.behavior.Delay > 0 {
+		b.Delay = s.behavior.Delay.String()
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, b)
+}
+
+func (s *Server) putBehavior(w http.ResponseWriter, r *http.Request) {
+	var b behaviorJSON
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&b); err != nil {
+		http.Error(w, fmt.Sprintf("invalid behavior: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	behavior := Behavior{
+		FailRequests:        b.FailRequests,
+		ExpiredTokens:       b.ExpiredTokens,
+		RejectRefreshTokens: b.RejectRefreshTokens,
+		AutoApprove:         b.AutoApprove,
+	}
+	if b.Delay != "" {
+		d, err := time.ParseDuration(b.Delay)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid delay: %v", err), http.StatusBadRequest)
+			return
+		}
+		behavior.Delay = d
+	}
+	if err := s.SetBehavior(behavior); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
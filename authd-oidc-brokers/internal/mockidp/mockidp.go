@@ -0,0 +1,440 @@
+// Package mockidp implements a mock OpenID Connect identity provider, which supports the device authorization flow
+// used by the broker. It's meant to test the broker and to rehearse the authentication flows without a tenant of a
+// real provider.
+package mockidp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// deviceCodeLifetime is how long the users have to approve a device code.
+	deviceCodeLifetime = 10 * time.Minute
+	// pollingInterval is how often the clients are asked to poll the token endpoint during a device authorization.
+	pollingInterval = 1
+	// tokenLifetime is the lifetime of the issued access and ID tokens.
+	tokenLifetime = time.Hour
+
+	deviceCodeGrantType   = "urn:ietf:params:oauth:grant-type:device_code"
+	refreshTokenGrantType = "refresh_token"
+)
+
+// User is a user of the mock provider.
+type User struct {
+	Subject           string   `yaml:"sub"`
+	Name              string   `yaml:"name"`
+	PreferredUsername string   `yaml:"preferred_username"`
+	Email             string   `yaml:"email"`
+	Groups            []string `yaml:"groups"`
+}
+
+// DefaultUsers are the users of the mock provider if none are configured.
+var DefaultUsers = []User{
+	{Subject: "user1-id", Name: "User 1", PreferredUsername: "user1@example.com", Email: "user1@example.com"},
+}
+
+// Server is a mock identity provider. Its issuer is the URL at which it's reached, unless set with WithIssuer.
+type Server struct {
+	issuer string
+	users  []User
+	key    *rsa.PrivateKey
+	keyID  string
+	mux    *http.ServeMux
+
+	mu            sync.Mutex
+	behavior      Behavior
+	failures      int
+	deviceAuths   map[string]*deviceAuth
+	accessTokens  map[string]grant
+	refreshTokens map[string]grant
+}
+
+// deviceAuth is a pending device authorization.
+type deviceAuth struct {
+	clientID string
+	userCode string
+	scopes   []string
+	expiry   time.Time
+	// user is the user who approved the authorization, nil until then.
+	user   *User
+	denied bool
+}
+
+// grant is what an access or refresh token gives access to.
+type grant struct {
+	clientID string
+	user     User
+	scopes   []string
+	expiry   time.Time
+}
+
+type options struct {
+	issuer string
+	users  []User
+}
+
+// Option is a function that allows changing some of the default behaviors of the server.
+type Option func(*options)
+
+// WithIssuer sets the issuer URL of the server, which is otherwise the URL at which it's reached.
+func WithIssuer(issuer string) Option {
+	return func(o *options) {
+		o.issuer = strings.TrimSuffix(issuer, "/")
+	}
+}
+
+// WithUsers sets the users of the server, instead of DefaultUsers.
+func WithUsers(users []User) Option {
+	return func(o *options) {
+		o.users = users
+	}
+}
+
+// New returns a new mock identity provider, which signs its tokens with a newly generated key.
+func New(args ...Option) (*Server, error) {
+	opts := options{users: DefaultUsers}
+	for _, arg := range args {
+		arg(&opts)
+	}
+	if len(opts.users) == 0 {
+		return nil, errors.New("the mock provider needs at least one user")
+	}
+	for _, u := range opts.users {
+		if u.Subject == "" || u.PreferredUsername == "" {
+			return nil, fmt.Errorf("user %q needs a subject and a preferred username", u.PreferredUsername)
+		}
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate the signing key: %w", err)
+	}
+
+	s := &Server{
+		issuer:        opts.issuer,
+		users:         opts.users,
+		key:           key,
+		keyID:         randomString(8),
+		mux:           http.NewServeMux(),
+		deviceAuths:   make(map[string]*deviceAuth),
+		accessTokens:  make(map[string]grant),
+		refreshTokens: make(map[string]grant),
+	}
+
+	s.mux.Handle("GET /.well-known/openid-configuration", s.withBehavior(s.discovery))
+	s.mux.Handle("GET /keys", s.withBehavior(s.keys))
+	s.mux.Handle("POST /device_auth", s.withBehavior(s.deviceAuthorization))
+	s.mux.Handle("GET /device", http.HandlerFunc(s.verificationPage))
+	s.mux.Handle("POST /device", http.HandlerFunc(s.verify))
+	s.mux.Handle("POST /token", s.withBehavior(s.token))
+	s.mux.Handle("GET /userinfo", s.withBehavior(s.userInfo))
+	s.mux.Handle("GET /mock/behavior", http.HandlerFunc(s.getBehavior))
+	s.mux.Handle("PUT /mock/behavior", http.HandlerFunc(s.putBehavior))
+
+	return s, nil
+}
+
+// ServeHTTP answers the requests to the endpoints of the provider.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Approve approves the device authorization with userCode as the user with the given preferred username, as if they
+// entered the code on the verification page.
+func (s *Server) Approve(userCode, username string) error {
+	return s.completeDeviceAuth(userCode, username, false)
+}
+
+// Deny denies the device authorization with userCode, as if the user refused it on the verification page.
+func (s *Server) Deny(userCode string) error {
+	return s.completeDeviceAuth(userCode, "", true)
+}
+
+func (s *Server) completeDeviceAuth(userCode, username string, deny bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var da *deviceAuth
+	for _, d := range s.deviceAuths {
+		if strings.EqualFold(d.userCode, userCode) {
+			da = d
+			break
+		}
+	}
+	if da == nil || time.Now().After(da.expiry) {
+		return fmt.Errorf("no pending device authorization with code %q", userCode)
+	}
+	if deny {
+		da.denied = true
+		return nil
+	}
+
+	u, err := s.user(username)
+	if err != nil {
+		return err
+	}
+	da.user = &u
+	return nil
+}
+
+// user returns the user with the given preferred username.
+func (s *Server) user(username string) (User, error) {
+	i := slices.IndexFunc(s.users, func(u User) bool { return strings.EqualFold(u.PreferredUsername, username) })
+	if i < 0 {
+		return User{}, fmt.Errorf("unknown user %q", username)
+	}
+	return s.users[i], nil
+}
+
+// issuerURL returns the issuer of the server, which is the URL of the request if it was not set.
+func (s *Server) issuerURL(r *http.Request) string {
+	if s.issuer != "" {
+		return s.issuer
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := s.issuerURL(r)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/auth",
+		"device_authorization_endpoint":         issuer + "/device_auth",
+		"token_endpoint":                        issuer + "/token",
+		"jwks_uri":                              issuer + "/keys",
+		"userinfo_endpoint":                     issuer + "/userinfo",
+		"grant_types_supported":                 []string{deviceCodeGrantType, refreshTokenGrantType},
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (s *Server) keys(w http.ResponseWriter, _ *http.Request) {
+	jwk := jose.JSONWebKey{Key: &s.key.PublicKey, KeyID: s.keyID, Algorithm: "RS256", Use: "sig"}
+	writeJSON(w, http.StatusOK, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}})
+}
+
+func (s *Server) deviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	clientID := requestClientID(r)
+	if clientID == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "missing client_id")
+		return
+	}
+
+	deviceCode := randomString(32)
+	// The user codes only use uppercase letters which can't be confused with each other.
+	userCode := randomCode("BCDFGHJKLMNPQRSTVWXZ", 4) + "-" + randomCode("BCDFGHJKLMNPQRSTVWXZ", 4)
+	da := &deviceAuth{
+		clientID: clientID,
+		userCode: userCode,
+		scopes:   strings.Fields(r.FormValue("scope")),
+		expiry:   time.Now().Add(deviceCodeLifetime),
+	}
+
+	s.mu.Lock()
+	for code, d := range s.deviceAuths {
+		if time.Now().After(d.expiry.Add(deviceCodeLifetime)) {
+			delete(s.deviceAuths, code)
+		}
+	}
+	if s.behavior.AutoApprove != "" {
+		u, err := s.user(s.behavior.AutoApprove)
+		if err != nil {
+			s.mu.Unlock()
+			writeError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+		da.user = &u
+	}
+	s.deviceAuths[deviceCode] = da
+	s.mu.Unlock()
+
+	verificationURI := s.issuerURL(r) + "/device"
+	writeJSON(w, http.StatusOK, map[string]any{
+		"device_code":               deviceCode,
+		"user_code":                 userCode,
+		"verification_uri":          verificationURI,
+		"verification_uri_complete": verificationURI + "?user_code=" + userCode,
+		"expires_in":                int(deviceCodeLifetime.Seconds()),
+		"interval":                  pollingInterval,
+	})
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	switch r.FormValue("grant_type") {
+	case deviceCodeGrantType:
+		s.deviceCodeToken(w, r)
+	case refreshTokenGrantType:
+		s.refreshToken(w, r)
+	default:
+		writeError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+	}
+}
+
+func (s *Server) deviceCodeToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deviceCode := r.FormValue("device_code")
+	da, ok := s.deviceAuths[deviceCode]
+	switch {
+	case !ok || da.clientID != requestClientID(r):
+		writeError(w, http.StatusBadRequest, "invalid_grant", "unknown device code")
+	// The expired and denied authorizations are kept until they are purged, so that the clients sending the request
+	// again with another authentication style get the same error.
+	case time.Now().After(da.expiry):
+		writeError(w, http.StatusBadRequest, "expired_token", "the device code expired")
+	case da.denied:
+		writeError(w, http.StatusBadRequest, "access_denied", "the user denied the authorization")
+	case da.user == nil:
+		writeError(w, http.StatusBadRequest, "authorization_pending", "")
+	default:
+		delete(s.deviceAuths, deviceCode)
+		s.issueTokens(w, r, grant{clientID: da.clientID, user: *da.user, scopes: da.scopes})
+	}
+}
+
+func (s *Server) refreshToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refreshToken := r.FormValue("refresh_token")
+	g, ok := s.refreshTokens[refreshToken]
+	if !ok || g.clientID != requestClientID(r) {
+		writeError(w, http.StatusBadRequest, "invalid_grant", "unknown refresh token")
+		return
+	}
+	if s.behavior.RejectRefreshTokens {
+		writeError(w, http.StatusBadRequest, "invalid_grant", "the session was revoked")
+		return
+	}
+
+	// The refresh tokens are rotated, as most providers do.
+	delete(s.refreshTokens, refreshToken)
+	s.issueTokens(w, r, g)
+}
+
+// issueTokens answers the token request with new tokens for the grant. s.mu must be held.
+func (s *Server) issueTokens(w http.ResponseWriter, r *http.Request, g grant) {
+	now := time.Now()
+	g.expiry = now.Add(tokenLifetime)
+	if s.behavior.ExpiredTokens {
+		g.expiry = now.Add(-time.Minute)
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                s.issuerURL(r),
+		"sub":                g.user.Subject,
+		"aud":                g.clientID,
+		"iat":                now.Unix(),
+		"exp":                g.expiry.Unix(),
+		"name":               g.user.Name,
+		"preferred_username": g.user.PreferredUsername,
+		"email":              g.user.Email,
+		"email_verified":     true,
+		"groups":             g.user.Groups,
+	})
+	idToken.Header["kid"] = s.keyID
+	rawIDToken, err := idToken.SignedString(s.key)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	accessToken, refreshToken := randomString(32), randomString(32)
+	s.accessTokens[accessToken] = g
+	s.refreshTokens[refreshToken] = g
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"id_token":      rawIDToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(time.Until(g.expiry).Seconds()),
+		"scope":         strings.Join(g.scopes, " "),
+	})
+}
+
+func (s *Server) userInfo(w http.ResponseWriter, r *http.Request) {
+	accessToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	s.mu.Lock()
+	g, found := s.accessTokens[accessToken]
+	s.mu.Unlock()
+
+	if !ok || !found || time.Now().After(g.expiry) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeError(w, http.StatusUnauthorized, "invalid_token", "the access token is invalid or expired")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"sub":                g.user.Subject,
+		"name":               g.user.Name,
+		"preferred_username": g.user.PreferredUsername,
+		"email":              g.user.Email,
+		"email_verified":     true,
+		"groups":             g.user.Groups,
+	})
+}
+
+// requestClientID returns the ID of the client which sent the request, either with HTTP basic authentication or in
+// the parameters of the request.
+func requestClientID(r *http.Request) string {
+	if id, _, ok := r.BasicAuth(); ok {
+		if unescaped, err := url.QueryUnescape(id); err == nil {
+			return unescaped
+		}
+		return id
+	}
+	return r.FormValue("client_id")
+}
+
+// writeJSON writes v as the JSON body of the response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an OAuth 2.0 error response, as defined by RFC 6749.
+func writeError(w http.ResponseWriter, status int, code, description string) {
+	body := map[string]string{"error": code}
+	if description != "" {
+		body["error_description"] = description
+	}
+	writeJSON(w, status, body)
+}
+
+// randomString returns a random string of n letters and digits.
+func randomString(n int) string {
+	return randomCode("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", n)
+}
+
+// randomCode returns a random string of n characters of alphabet.
+func randomCode(alphabet string, n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b)
+}
@@ -0,0 +1,289 @@
+package mockidp_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/mockidp"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+const clientID = "test-client-id"
+
+var testUsers = []mockidp.User{
+	{Subject: "user1-id", Name: "User 1", PreferredUsername: "user1@example.com", Email: "user1@example.com", Groups: []string{"group1"}},
+	{Subject: "user2-id", Name: "User 2", PreferredUsername: "user2@example.com", Email: "user2@example.com"},
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		users []mockidp.User
+
+		wantErr bool
+	}{
+		"Successfully_create_server_with_default_users": {},
+		"Successfully_create_server_with_users":         {users: testUsers},
+
+		"Error_when_there_are_no_users":           {users: []mockidp.User{}, wantErr: true},
+		"Error_when_a_user_has_no_subject":        {users: []mockidp.User{{PreferredUsername: "user@example.com"}}, wantErr: true},
+		"Error_when_a_user_has_no_preferred_name": {users: []mockidp.User{{Subject: "user-id"}}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var opts []mockidp.Option
+			if tc.users != nil {
+				opts = append(opts, mockidp.WithUsers(tc.users))
+			}
+			_, err := mockidp.New(opts...)
+			if tc.wantErr {
+				require.Error(t, err, "New should return an error")
+				return
+			}
+			require.NoError(t, err, "New should not return an error")
+		})
+	}
+}
+
+func TestDeviceFlow(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		behavior mockidp.Behavior
+		deny     bool
+		username string
+
+		wantErrCode     string
+		wantExpiredID   bool
+		wantUserInfoErr bool
+	}{
+		"Successfully_log_in_as_the_user_who_approved":      {username: "user2@example.com"},
+		"Successfully_log_in_with_automatic_approval":       {behavior: mockidp.Behavior{AutoApprove: "user1@example.com"}},
+		"Successfully_log_in_with_slow_provider":            {username: "user1@example.com", behavior: mockidp.Behavior{Delay: 100 * time.Millisecond}},
+		"Successfully_issue_expired_tokens_when_configured": {username: "user1@example.com", behavior: mockidp.Behavior{ExpiredTokens: true}, wantExpiredID: true, wantUserInfoErr: true},
+
+		"Error_when_the_user_denies_the_authorization": {deny: true, wantErrCode: "access_denied"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			idp, serverURL := startServer(t)
+			require.NoError(t, idp.SetBehavior(tc.behavior), "Setup: SetBehavior should not return an error")
+
+			ctx := context.Background()
+			provider, cfg := providerConfig(t, serverURL)
+
+			da, err := cfg.DeviceAuth(ctx)
+			require.NoError(t, err, "DeviceAuth should not return an error")
+			require.NotEmpty(t, da.UserCode, "DeviceAuth should return a user code")
+			require.Equal(t, serverURL+"/device", da.VerificationURI, "DeviceAuth should return the verification page")
+
+			switch {
+			case tc.deny:
+				require.NoError(t, idp.Deny(da.UserCode), "Deny should not return an error")
+			case tc.username != "":
+				require.NoError(t, idp.Approve(da.UserCode, tc.username), "Approve should not return an error")
+			}
+
+			tok, err := cfg.DeviceAccessToken(ctx, da)
+			if tc.wantErrCode != "" {
+				var retrieveErr *oauth2.RetrieveError
+				require.ErrorAs(t, err, &retrieveErr, "DeviceAccessToken should return a provider error")
+				require.Equal(t, tc.wantErrCode, retrieveErr.ErrorCode, "DeviceAccessToken should return the expected error code")
+				return
+			}
+			require.NoError(t, err, "DeviceAccessToken should not return an error")
+
+			rawIDToken, ok := tok.Extra("id_token").(string)
+			require.True(t, ok, "The token response should include an ID token")
+			idToken, err := provider.Verifier(&oidc.Config{ClientID: clientID}).Verify(ctx, rawIDToken)
+			if tc.wantExpiredID {
+				var expiredErr *oidc.TokenExpiredError
+				require.ErrorAs(t, err, &expiredErr, "The ID token should be expired")
+			} else {
+				require.NoError(t, err, "The ID token should be valid")
+				var claims struct {
+					PreferredUsername string   `json:"preferred_username"`
+					Groups            []string `json:"groups"`
+				}
+				require.NoError(t, idToken.Claims(&claims), "The claims of the ID token should be parsable")
+				wantUser := tc.username
+				if wantUser == "" {
+					wantUser = tc.behavior.AutoApprove
+				}
+				require.Equal(t, wantUser, claims.PreferredUsername, "The ID token should be for the user who approved")
+			}
+
+			_, err = provider.UserInfo(ctx, oauth2.StaticTokenSource(tok))
+			if tc.wantUserInfoErr {
+				require.Error(t, err, "UserInfo should return an error")
+				return
+			}
+			require.NoError(t, err, "UserInfo should not return an error")
+		})
+	}
+}
+
+func TestApprove(t *testing.T) {
+	t.Parallel()
+
+	idp, serverURL := startServer(t)
+	_, cfg := providerConfig(t, serverURL)
+	da, err := cfg.DeviceAuth(context.Background())
+	require.NoError(t, err, "Setup: DeviceAuth should not return an error")
+
+	require.Error(t, idp.Approve("WRONG-CODE", "user1@example.com"), "Approve should fail with an unknown code")
+	require.Error(t, idp.Approve(da.UserCode, "unknown@example.com"), "Approve should fail with an unknown user")
+	require.Error(t, idp.Deny("WRONG-CODE"), "Deny should fail with an unknown code")
+
+	// The verification page approves the authorization as the selected user.
+	resp, err := http.PostForm(serverURL+"/device", url.Values{"user_code": {strings.ToLower(da.UserCode)}, "username": {"user1@example.com"}})
+	require.NoError(t, err, "The verification page should answer")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "The verification page should approve the authorization")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = cfg.DeviceAccessToken(ctx, da)
+	require.NoError(t, err, "DeviceAccessToken should not return an error once approved")
+}
+
+func TestRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		rejectRefreshTokens bool
+
+		wantErr bool
+	}{
+		"Successfully_refresh_the_token": {},
+
+		"Error_when_the_refresh_tokens_are_rejected": {rejectRefreshTokens: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			idp, serverURL := startServer(t)
+			require.NoError(t, idp.SetBehavior(mockidp.Behavior{AutoApprove: "user1@example.com"}), "Setup: SetBehavior should not return an error")
+			ctx := context.Background()
+			_, cfg := providerConfig(t, serverURL)
+			da, err := cfg.DeviceAuth(ctx)
+			require.NoError(t, err, "Setup: DeviceAuth should not return an error")
+			tok, err := cfg.DeviceAccessToken(ctx, da)
+			require.NoError(t, err, "Setup: DeviceAccessToken should not return an error")
+
+			require.NoError(t, idp.SetBehavior(mockidp.Behavior{RejectRefreshTokens: tc.rejectRefreshTokens}), "Setup: SetBehavior should not return an error")
+			expired := &oauth2.Token{RefreshToken: tok.RefreshToken, Expiry: time.Now().Add(-time.Minute)}
+			refreshed, err := cfg.TokenSource(ctx, expired).Token()
+			if tc.wantErr {
+				var retrieveErr *oauth2.RetrieveError
+				require.ErrorAs(t, err, &retrieveErr, "Refreshing the token should return a provider error")
+				require.Equal(t, "invalid_grant", retrieveErr.ErrorCode, "Refreshing the token should return the expected error code")
+				return
+			}
+			require.NoError(t, err, "Refreshing the token should not return an error")
+			require.NotEqual(t, tok.AccessToken, refreshed.AccessToken, "Refreshing the token should return a new access token")
+			require.NotEqual(t, tok.RefreshToken, refreshed.RefreshToken, "The refresh tokens should be rotated")
+
+			// The old refresh token can't be used anymore.
+			_, err = cfg.TokenSource(ctx, expired).Token()
+			require.Error(t, err, "The refresh token should not be usable twice")
+		})
+	}
+}
+
+func TestFailRequests(t *testing.T) {
+	t.Parallel()
+
+	idp, serverURL := startServer(t)
+	require.NoError(t, idp.SetBehavior(mockidp.Behavior{FailRequests: 2}), "Setup: SetBehavior should not return an error")
+
+	for _, want := range []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK} {
+		resp, err := http.Get(serverURL + "/.well-known/openid-configuration")
+		require.NoError(t, err, "The provider should answer")
+		resp.Body.Close()
+		require.Equal(t, want, resp.StatusCode, "The provider should only fail the requested number of requests")
+	}
+}
+
+func TestBehaviorEndpoint(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		body string
+
+		wantStatus int
+		wantBody   string
+	}{
+		"Successfully_set_behavior":   {body: `{"delay": "1.5s", "fail_requests": 2, "auto_approve": "user1@example.com"}`, wantStatus: http.StatusNoContent, wantBody: `{"delay":"1.5s","fail_requests":2,"auto_approve":"user1@example.com"}`},
+		"Successfully_reset_behavior": {body: `{}`, wantStatus: http.StatusNoContent, wantBody: `{}`},
+
+		"Error_when_the_body_is_not_valid":    {body: `{"delay":`, wantStatus: http.StatusBadRequest},
+		"Error_when_the_field_is_unknown":     {body: `{"unknown": true}`, wantStatus: http.StatusBadRequest},
+		"Error_when_the_delay_is_not_valid":   {body: `{"delay": "soon"}`, wantStatus: http.StatusBadRequest},
+		"Error_when_the_user_does_not_exist":  {body: `{"auto_approve": "unknown@example.com"}`, wantStatus: http.StatusBadRequest},
+		"Error_when_the_value_has_wrong_type": {body: `{"fail_requests": "2"}`, wantStatus: http.StatusBadRequest},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, serverURL := startServer(t)
+
+			req, err := http.NewRequest(http.MethodPut, serverURL+"/mock/behavior", strings.NewReader(tc.body))
+			require.NoError(t, err, "Setup: could not create request")
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err, "The behavior endpoint should answer")
+			resp.Body.Close()
+			require.Equal(t, tc.wantStatus, resp.StatusCode, "The behavior endpoint should return the expected status")
+			if tc.wantBody == "" {
+				return
+			}
+
+			resp, err = http.Get(serverURL + "/mock/behavior")
+			require.NoError(t, err, "The behavior endpoint should answer")
+			defer resp.Body.Close()
+			got, err := io.ReadAll(resp.Body)
+			require.NoError(t, err, "Setup: could not read the behavior")
+			require.JSONEq(t, tc.wantBody, string(got), "The behavior endpoint should return the behavior which was set")
+		})
+	}
+}
+
+// startServer starts a mock provider with the test users, which is stopped at the end of the test.
+func startServer(t *testing.T) (*mockidp.Server, string) {
+	t.Helper()
+
+	idp, err := mockidp.New(mockidp.WithUsers(testUsers))
+	require.NoError(t, err, "Setup: could not create the mock provider")
+	server := httptest.NewServer(idp)
+	t.Cleanup(server.Close)
+	return idp, server.URL
+}
+
+// providerConfig returns the OIDC provider and the OAuth2 configuration of the client for the mock provider.
+func providerConfig(t *testing.T, serverURL string) (*oidc.Provider, *oauth2.Config) {
+	t.Helper()
+
+	provider, err := oidc.NewProvider(context.Background(), serverURL)
+	require.NoError(t, err, "Setup: could not discover the mock provider")
+
+	return provider, &oauth2.Config{
+		ClientID: clientID,
+		Endpoint: provider.Endpoint(),
+		Scopes:   []string{oidc.ScopeOpenID, "profile", "email"},
+	}
+}
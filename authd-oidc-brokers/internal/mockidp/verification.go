@@ -0,0 +1,63 @@
+package mockidp
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// verificationTemplate is the page on which the users approve the device authorizations, choosing as whom they log in.
+var verificationTemplate = template.Must(template.New("device").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Mock identity provider</title></head>
+<body>
+{{- if .Message}}
+<p>{{.Message}}</p>
+{{- else}}
+<form method="post">
+<p><label>Code: <input name="user_code" value="{{.UserCode}}" required></label></p>
+<p><label>User: <select name="username">
+{{- range .Users}}
+<option value="{{.PreferredUsername}}">{{.Name}} ({{.PreferredUsername}})</option>
+{{- end}}
+</select></label></p>
+<p><button name="action" value="approve">Approve</button> <button name="action" value="deny">Deny</button></p>
+</form>
+{{- end}}
+</body>
+</html>
+`))
+
+type verificationData struct {
+	UserCode string
+	Users    []User
+	Message  string
+}
+
+func (s *Server) verificationPage(w http.ResponseWriter, r *http.Request) {
+	renderVerification(w, http.StatusOK, verificationData{UserCode: r.FormValue("user_code"), Users: s.users})
+}
+
+func (s *Server) verify(w http.ResponseWriter, r *http.Request) {
+	userCode := r.FormValue("user_code")
+
+	var err error
+	msg := "The device was authorized, you can close this page."
+	if r.FormValue("action") == "deny" {
+		err = s.Deny(userCode)
+		msg = "The authorization was denied, you can close this page."
+	} else {
+		err = s.Approve(userCode, r.FormValue("username"))
+	}
+	if err != nil {
+		renderVerification(w, http.StatusBadRequest, verificationData{Message: err.Error()})
+		return
+	}
+
+	renderVerification(w, http.StatusOK, verificationData{Message: msg})
+}
+
+func renderVerification(w http.ResponseWriter, status int, data verificationData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = verificationTemplate.Execute(w, data)
+}
@@ -0,0 +1,79 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readHistory returns the hashes of the history file, from the most recent one. A missing file is an empty history.
+func readHistory(historyPath string, opts options) ([]string, error) {
+	data, err := opts.storage.ReadFile(historyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read password history file: %w", err)
+	}
+	return strings.Fields(string(data)), nil
+}
+
+// IsInHistory returns true if the password matches one of the n most recent hashes of the history file, which holds
+// the hashes of the previous passwords of the user.
+//
+// In FIPS mode, the hashes computed with Argon2id can't be checked, so they are ignored.
+func IsInHistory(password, historyPath string, n int, args ...Option) (bool, error) {
+	var opts options
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	hashes, err := readHistory(historyPath, opts)
+	if err != nil {
+		return false, err
+	}
+
+	for _, encoded := range hashes[:min(n, len(hashes))] {
+		ok, err := checkHashedPassword(password, encoded, opts)
+		if errors.Is(err, ErrNotFIPSApproved) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AddToHistory adds the hash stored in the password file to the history file, which keeps the n most recent hashes.
+// It does nothing if the password file doesn't exist.
+func AddToHistory(path, historyPath string, n int, args ...Option) error {
+	var opts options
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	current, err := opts.storage.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read password file: %w", err)
+	}
+
+	hashes, err := readHistory(historyPath, opts)
+	if err != nil {
+		return err
+	}
+	hashes = append([]string{string(current)}, hashes...)
+	hashes = hashes[:min(n, len(hashes))]
+
+	if err := opts.storage.WriteFile(historyPath, []byte(strings.Join(hashes, "\n"))); err != nil {
+		return fmt.Errorf("could not store password history: %w", err)
+	}
+	return nil
+}
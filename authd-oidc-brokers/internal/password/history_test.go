@@ -0,0 +1,79 @@
+package password_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/password"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordHistory(t *testing.T) {
+	t.Parallel()
+
+	// The passwords set, from the oldest one. The last one is the current password, which is not in the history.
+	passwords := []string{"first", "second", "third", "current"}
+
+	tests := map[string]struct {
+		password       string
+		historySize    int
+		checkedHashes  int
+		argon2Previous bool
+		checkInFIPS    bool
+
+		wantInHistory bool
+	}{
+		"In_history_when_password_is_the_previous_one":         {password: "third", historySize: 3, checkedHashes: 3, wantInHistory: true},
+		"In_history_when_password_is_the_oldest_one_kept":      {password: "first", historySize: 3, checkedHashes: 3, wantInHistory: true},
+		"In_history_when_hash_is_PBKDF2_in_FIPS_mode":          {password: "second", historySize: 3, checkedHashes: 3, checkInFIPS: true, wantInHistory: true},
+		"Not_in_history_when_password_was_never_set":           {password: "other", historySize: 3, checkedHashes: 3},
+		"Not_in_history_when_password_is_the_current_one":      {password: "current", historySize: 3, checkedHashes: 3},
+		"Not_in_history_when_password_is_older_than_kept_ones": {password: "first", historySize: 2, checkedHashes: 3},
+		"Not_in_history_when_password_is_older_than_checked":   {password: "first", historySize: 3, checkedHashes: 2},
+		"Not_in_history_when_hash_is_Argon2id_in_FIPS_mode":    {password: "third", historySize: 3, checkedHashes: 3, argon2Previous: true, checkInFIPS: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			path := filepath.Join(dir, "password")
+			historyPath := filepath.Join(dir, "password_history")
+
+			for i, p := range passwords {
+				err := password.AddToHistory(path, historyPath, tc.historySize)
+				require.NoError(t, err, "Setup: AddToHistory() failed")
+
+				inFIPS := !tc.argon2Previous || i == len(passwords)-1
+				err = password.HashAndStorePassword(p, path, password.WithFIPSMode(inFIPS))
+				require.NoError(t, err, "Setup: HashAndStorePassword() failed")
+			}
+
+			data, err := os.ReadFile(historyPath)
+			require.NoError(t, err, "Setup: could not read the history file")
+			require.Len(t, strings.Fields(string(data)), tc.historySize, "AddToHistory() should keep the expected number of hashes")
+
+			inHistory, err := password.IsInHistory(tc.password, historyPath, tc.checkedHashes, password.WithFIPSMode(tc.checkInFIPS))
+			require.NoError(t, err, "IsInHistory() failed")
+			require.Equal(t, tc.wantInHistory, inHistory, "IsInHistory() returned unexpected result")
+		})
+	}
+}
+
+func TestPasswordHistoryWithoutFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "password")
+	historyPath := filepath.Join(dir, "password_history")
+
+	inHistory, err := password.IsInHistory("test123", historyPath, 3)
+	require.NoError(t, err, "IsInHistory() should not fail without a history file")
+	require.False(t, inHistory, "IsInHistory() should return false without a history file")
+
+	err = password.AddToHistory(path, historyPath, 3)
+	require.NoError(t, err, "AddToHistory() should not fail without a password file")
+	require.NoFileExists(t, historyPath, "AddToHistory() should not create a history file without a password file")
+}
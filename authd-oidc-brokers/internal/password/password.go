@@ -2,23 +2,68 @@
 package password
 
 import (
+	"crypto/pbkdf2"
 	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/canonical/authd/authd-oidc-brokers/internal/secretstorage"
 	"golang.org/x/crypto/argon2"
 )
 
+const (
+	// pbkdf2Prefix is the prefix of the hashes computed with PBKDF2, followed by the number of iterations.
+	// Argon2id hashes have no prefix, as they were the only ones stored before the FIPS mode was introduced.
+	pbkdf2Prefix = "pbkdf2-sha512$"
+	// pbkdf2Iterations is the number of PBKDF2-HMAC-SHA512 iterations recommended by OWASP.
+	pbkdf2Iterations = 210000
+
+	saltSize = 16
+	hashSize = 32
+)
+
+// ErrNotFIPSApproved is returned when checking a password against a hash computed with an algorithm which is not
+// approved in FIPS mode.
+var ErrNotFIPSApproved = errors.New("the password hash was not computed with a FIPS approved algorithm")
+
+type options struct {
+	fipsMode bool
+	storage  *secretstorage.Storage
+}
+
+// Option is a function that modifies how passwords are hashed and checked.
+type Option func(*options)
+
+// WithFIPSMode makes the password hashed with PBKDF2-HMAC-SHA512, which is approved by FIPS 140, instead of
+// Argon2id, and refuses to check passwords against Argon2id hashes.
+func WithFIPSMode(enabled bool) Option {
+	return func(o *options) {
+		o.fipsMode = enabled
+	}
+}
+
+// WithStorage seals the password file with the storage instead of writing it in plaintext.
+func WithStorage(storage *secretstorage.Storage) Option {
+	return func(o *options) {
+		o.storage = storage
+	}
+}
+
 // HashAndStorePassword hashes the password and stores it in the data directory.
-func HashAndStorePassword(password, path string) error {
-	encoded, err := HashPassword(password)
+func HashAndStorePassword(password, path string, args ...Option) error {
+	encoded, err := HashPassword(password, args...)
 	if err != nil {
 		return err
 	}
-	return StoreHashedPassword(encoded, path)
+	return StoreHashedPassword(encoded, path, args...)
 }
 
 // HashPassword hashes a plaintext password and returns the base64-encoded
@@ -26,42 +71,80 @@ func HashAndStorePassword(password, path string) error {
 //
 // Splitting hashing from storage lets callers narrow the plaintext memory
 // window: hash early, then drop the plaintext.
-func HashPassword(password string) (string, error) {
-	salt := make([]byte, 16)
+func HashPassword(password string, args ...Option) (string, error) {
+	var opts options
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	salt := make([]byte, saltSize)
 	if _, err := rand.Read(salt); err != nil {
 		return "", fmt.Errorf("could not generate salt: %w", err)
 	}
 
+	if opts.fipsMode {
+		hash, err := pbkdf2.Key(sha512.New, password, salt, pbkdf2Iterations, hashSize)
+		if err != nil {
+			return "", fmt.Errorf("could not hash password: %w", err)
+		}
+		return pbkdf2Prefix + strconv.Itoa(pbkdf2Iterations) + "$" + base64.StdEncoding.EncodeToString(append(salt, hash...)), nil
+	}
+
 	hash := hashPassword(password, salt)
 	return base64.StdEncoding.EncodeToString(append(salt, hash...)), nil
 }
 
 // StoreHashedPassword writes a pre-computed password hash (from HashPassword)
 // to the given path.
-func StoreHashedPassword(encoded, path string) error {
+func StoreHashedPassword(encoded, path string, args ...Option) error {
+	var opts options
+	for _, arg := range args {
+		arg(&opts)
+	}
+
 	// Ensure that the password file's parent directory exists.
 	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
 		return fmt.Errorf("could not create password parent directory: %w", err)
 	}
-	if err := os.WriteFile(path, []byte(encoded), 0o600); err != nil {
+	if err := opts.storage.WriteFile(path, []byte(encoded)); err != nil {
 		return fmt.Errorf("could not store password: %w", err)
 	}
 	return nil
 }
 
 // CheckPassword checks if the provided password matches the hash stored in the password file.
-func CheckPassword(password, path string) (bool, error) {
-	data, err := os.ReadFile(path)
+//
+// In FIPS mode, ErrNotFIPSApproved is returned if the stored hash was computed with Argon2id.
+func CheckPassword(password, path string, args ...Option) (bool, error) {
+	var opts options
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	data, err := opts.storage.ReadFile(path)
 	if err != nil {
 		return false, fmt.Errorf("could not read password file: %w", err)
 	}
 
-	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	return checkHashedPassword(password, string(data), opts)
+}
+
+// checkHashedPassword checks the password against an encoded hash, as returned by HashPassword.
+func checkHashedPassword(password, encoded string, opts options) (bool, error) {
+	if rest, ok := strings.CutPrefix(encoded, pbkdf2Prefix); ok {
+		return checkPBKDF2Password(password, rest)
+	}
+
+	if opts.fipsMode {
+		return false, ErrNotFIPSApproved
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
 		return false, fmt.Errorf("could not decode password: %w", err)
 	}
 
-	salt, hash := decoded[:16], decoded[16:]
+	salt, hash := decoded[:saltSize], decoded[saltSize:]
 	if !slices.Equal(hash, hashPassword(password, salt)) {
 		return false, nil
 	}
@@ -69,6 +152,48 @@ func CheckPassword(password, path string) (bool, error) {
 	return true, nil
 }
 
+// IsFIPSApproved returns true if the hash stored in the password file was computed with an algorithm approved in
+// FIPS mode.
+func IsFIPSApproved(path string, args ...Option) (bool, error) {
+	var opts options
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	data, err := opts.storage.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("could not read password file: %w", err)
+	}
+	return strings.HasPrefix(string(data), pbkdf2Prefix), nil
+}
+
+// checkPBKDF2Password checks the password against a "<iterations>$<base64 salt+hash>" encoded PBKDF2 hash.
+func checkPBKDF2Password(password, encoded string) (bool, error) {
+	iterationsStr, encodedHash, ok := strings.Cut(encoded, "$")
+	if !ok {
+		return false, errors.New("could not decode password: missing PBKDF2 iterations")
+	}
+	iterations, err := strconv.Atoi(iterationsStr)
+	if err != nil || iterations <= 0 {
+		return false, fmt.Errorf("could not decode password: invalid PBKDF2 iterations %q", iterationsStr)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encodedHash)
+	if err != nil {
+		return false, fmt.Errorf("could not decode password: %w", err)
+	}
+	if len(decoded) <= saltSize {
+		return false, errors.New("could not decode password: hash is too short")
+	}
+
+	salt, hash := decoded[:saltSize], decoded[saltSize:]
+	got, err := pbkdf2.Key(sha512.New, password, salt, iterations, len(hash))
+	if err != nil {
+		return false, fmt.Errorf("could not hash password: %w", err)
+	}
+	return subtle.ConstantTimeCompare(hash, got) == 1, nil
+}
+
 func hashPassword(password string, salt []byte) []byte {
 	// If you change these parameters, update the section in the security overview doc.
 	return argon2.IDKey([]byte(password), salt, 1, 64*1024, 4, 32)
@@ -4,10 +4,12 @@ import (
 	"encoding/base64"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/canonical/authd/authd-oidc-brokers/internal/fileutils"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/password"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/secretstorage"
 	"github.com/stretchr/testify/require"
 )
 
@@ -26,6 +28,21 @@ func TestHashPassword(t *testing.T) {
 	require.NotEqual(t, encoded, encoded2, "HashPassword() should use a fresh random salt")
 }
 
+func TestHashPasswordInFIPSMode(t *testing.T) {
+	t.Parallel()
+
+	encoded, err := password.HashPassword("test123", password.WithFIPSMode(true))
+	require.NoError(t, err, "HashPassword() failed")
+
+	iterations, encodedHash, ok := strings.Cut(strings.TrimPrefix(encoded, "pbkdf2-sha512$"), "$")
+	require.True(t, strings.HasPrefix(encoded, "pbkdf2-sha512$") && ok, "HashPassword() should return a PBKDF2 hash, got %q", encoded)
+	require.Equal(t, "210000", iterations, "HashPassword() should use the recommended number of iterations")
+
+	decoded, err := base64.StdEncoding.DecodeString(encodedHash)
+	require.NoError(t, err, "HashPassword() did not return valid base64")
+	require.Len(t, decoded, 16+32, "HashPassword() should return salt(16)+hash(32)")
+}
+
 func TestStoreHashedPassword(t *testing.T) {
 	t.Parallel()
 
@@ -122,15 +139,24 @@ func TestCheckPassword(t *testing.T) {
 		password     string
 		pathToRead   string
 		writeGarbage bool
+		hashInFIPS   bool
+		checkInFIPS  bool
+		sealed       bool
 
 		wantMatch     bool
 		expectedError error
 	}{
-		"Success_when_password_matches":         {password: "test123", wantMatch: true},
-		"No_match_when_password_does_not_match": {password: "not-test123", wantMatch: false},
-
-		"Error_when_password_file_does_not_exist":   {password: "test123", pathToRead: "nonexistent", expectedError: os.ErrNotExist},
-		"Error_when_password_file_contains_garbage": {password: "test123", writeGarbage: true, expectedError: base64.CorruptInputError(0)},
+		"Success_when_password_matches":                                  {password: "test123", wantMatch: true},
+		"Success_when_password_matches_PBKDF2_hash":                      {password: "test123", hashInFIPS: true, wantMatch: true},
+		"Success_when_password_matches_PBKDF2_hash_in_FIPS_mode":         {password: "test123", hashInFIPS: true, checkInFIPS: true, wantMatch: true},
+		"Success_when_password_matches_sealed_hash":                      {password: "test123", sealed: true, wantMatch: true},
+		"No_match_when_password_does_not_match":                          {password: "not-test123", wantMatch: false},
+		"No_match_when_password_does_not_match_PBKDF2_hash":              {password: "not-test123", hashInFIPS: true, wantMatch: false},
+		"No_match_when_password_does_not_match_PBKDF2_hash_in_FIPS_mode": {password: "not-test123", hashInFIPS: true, checkInFIPS: true},
+
+		"Error_when_password_file_does_not_exist":        {password: "test123", pathToRead: "nonexistent", expectedError: os.ErrNotExist},
+		"Error_when_password_file_contains_garbage":      {password: "test123", writeGarbage: true, expectedError: base64.CorruptInputError(0)},
+		"Error_when_checking_Argon2id_hash_in_FIPS_mode": {password: "test123", checkInFIPS: true, expectedError: password.ErrNotFIPSApproved},
 	}
 
 	for name, tc := range tests {
@@ -144,7 +170,14 @@ func TestCheckPassword(t *testing.T) {
 				tc.pathToRead = path
 			}
 
-			err := password.HashAndStorePassword("test123", path)
+			var storage *secretstorage.Storage
+			if tc.sealed {
+				var err error
+				storage, err = secretstorage.New(secretstorage.Keyring, parentDir)
+				require.NoError(t, err, "Setup: could not open the secret storage")
+			}
+
+			err := password.HashAndStorePassword("test123", path, password.WithFIPSMode(tc.hashInFIPS), password.WithStorage(storage))
 			require.NoError(t, err, "HashAndStorePassword() failed")
 
 			if tc.writeGarbage {
@@ -152,7 +185,7 @@ func TestCheckPassword(t *testing.T) {
 				require.NoError(t, err, "Writing garbage to password file failed")
 			}
 
-			match, err := password.CheckPassword(tc.password, tc.pathToRead)
+			match, err := password.CheckPassword(tc.password, tc.pathToRead, password.WithFIPSMode(tc.checkInFIPS), password.WithStorage(storage))
 			if tc.expectedError != nil {
 				require.ErrorIs(t, err, tc.expectedError, "CheckPassword() failed")
 			} else {
@@ -163,3 +196,39 @@ func TestCheckPassword(t *testing.T) {
 		})
 	}
 }
+
+func TestIsFIPSApproved(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		hashInFIPS bool
+		noFile     bool
+
+		wantApproved bool
+		wantErr      bool
+	}{
+		"Approved_when_password_is_hashed_with_PBKDF2":       {hashInFIPS: true, wantApproved: true},
+		"Not_approved_when_password_is_hashed_with_Argon2id": {},
+
+		"Error_when_password_file_does_not_exist": {noFile: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "password")
+			if !tc.noFile {
+				err := password.HashAndStorePassword("test123", path, password.WithFIPSMode(tc.hashInFIPS))
+				require.NoError(t, err, "Setup: HashAndStorePassword() failed")
+			}
+
+			approved, err := password.IsFIPSApproved(path)
+			if tc.wantErr {
+				require.Error(t, err, "IsFIPSApproved() should have failed")
+				return
+			}
+			require.NoError(t, err, "IsFIPSApproved() failed")
+			require.Equal(t, tc.wantApproved, approved, "IsFIPSApproved() returned unexpected result")
+		})
+	}
+}
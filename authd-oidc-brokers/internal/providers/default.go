@@ -1,4 +1,4 @@
-//go:build !withgoogle && !withmsentraid
+//go:build !withgoogle && !withmsentraid && !withokta && !withkeycloak
 
 package providers
 
@@ -8,5 +8,7 @@ import (
 
 // CurrentProvider returns a generic oidc provider implementation.
 func CurrentProvider() Provider {
-	return genericprovider.New()
+	p := genericprovider.New()
+	// Return a pointer, so that the broker can set the mapping of the claims.
+	return &p
 }
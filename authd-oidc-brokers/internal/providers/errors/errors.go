@@ -60,3 +60,19 @@ func (e *MissingClaimError) Error() string {
 func NewMissingClaimError(claim string) error {
 	return &MissingClaimError{Claim: claim}
 }
+
+// Interaction is the interaction with the identity provider which the user has to go through before the provider
+// issues tokens again.
+type Interaction int
+
+const (
+	// NoInteraction means that the error isn't about a required interaction.
+	NoInteraction Interaction = iota
+	// InteractionPasswordExpired means that the password of the user expired and must be changed.
+	InteractionPasswordExpired
+	// InteractionMFA means that the user must complete (or register) a multi-factor authentication.
+	InteractionMFA
+	// InteractionOther means that the provider requires any other interaction, like consenting to the application
+	// or completing the actions required by an administrator.
+	InteractionOther
+)
@@ -4,7 +4,9 @@ package genericprovider
 import (
 	"errors"
 	"fmt"
+	"math"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/authmodes"
@@ -13,12 +15,94 @@ import (
 	"golang.org/x/oauth2"
 )
 
+// LocalGroupPrefix is the prefix of the groups of the groups claim which are local groups, like with Microsoft Entra ID.
+const LocalGroupPrefix = "linux-"
+
+// ClaimMapping holds the names of the claims of the ID token which the information of the users is read from.
+type ClaimMapping struct {
+	// Username is the claim of the name of the user. If it's the email, the provider must have verified it.
+	Username string
+	// UIDHint is the claim of the UID suggested for the user, e.g. "uidNumber". The UID is not read if it's empty.
+	UIDHint string
+	// Home is the claim of the home directory of the user.
+	Home string
+	// Shell is the claim of the shell of the user.
+	Shell string
+	// Gecos is the claim of the full name of the user.
+	Gecos string
+	// Groups is the claim listing the groups of the user. The groups are not read if it's empty.
+	Groups string
+}
+
+// DefaultClaimMapping is the mapping of the claims used for the claims which are not set.
+var DefaultClaimMapping = ClaimMapping{
+	Username: "email",
+	Home:     "home",
+	Shell:    "shell",
+	Gecos:    "name",
+}
+
+// withDefaults returns the mapping with the claims which are not set replaced by the ones of defaults.
+func (m ClaimMapping) withDefaults(defaults ClaimMapping) ClaimMapping {
+	if m.Username == "" {
+		m.Username = defaults.Username
+	}
+	if m.UIDHint == "" {
+		m.UIDHint = defaults.UIDHint
+	}
+	if m.Home == "" {
+		m.Home = defaults.Home
+	}
+	if m.Shell == "" {
+		m.Shell = defaults.Shell
+	}
+	if m.Gecos == "" {
+		m.Gecos = defaults.Gecos
+	}
+	if m.Groups == "" {
+		m.Groups = defaults.Groups
+	}
+	return m
+}
+
 // GenericProvider is a generic OIDC provider.
-type GenericProvider struct{}
+type GenericProvider struct {
+	claims ClaimMapping
+	// defaultClaims is the mapping of the claims of the provider used for the claims which are not set, before
+	// DefaultClaimMapping.
+	defaultClaims ClaimMapping
+}
+
+// Option is a func that allows to override some of the provider default settings.
+type Option func(*GenericProvider)
+
+// WithClaimMapping returns an option to read the information of the users from the claims of m.
+func WithClaimMapping(m ClaimMapping) Option {
+	return func(p *GenericProvider) {
+		p.claims = m
+	}
+}
+
+// WithDefaultClaimMapping returns an option to read the information of the users from the claims of m when they
+// are not set in the mapping of the configuration, e.g. for the claims specific to an identity provider.
+func WithDefaultClaimMapping(m ClaimMapping) Option {
+	return func(p *GenericProvider) {
+		p.defaultClaims = m
+	}
+}
 
 // New returns a new GenericProvider.
-func New() GenericProvider {
-	return GenericProvider{}
+func New(args ...Option) GenericProvider {
+	var p GenericProvider
+	for _, arg := range args {
+		arg(&p)
+	}
+	return p
+}
+
+// SetClaimMapping sets the claims of the ID token which the information of the users is read from.
+func (p *GenericProvider) SetClaimMapping(m ClaimMapping) {
+	p.claims = m
 }
 
 // DisplayName returns the display name of the provider.
@@ -49,38 +133,129 @@ func (p GenericProvider) GetUserInfo(claimer info.Claimer, _ bool) (info.User, e
 		return info.User{}, providerErrors.NewMissingClaimError("sub")
 	}
 
-	email, ok := claimsMap["email"].(string)
-	if !ok || email == "" {
-		return info.User{}, providerErrors.NewMissingClaimError("email")
+	claims := p.claims.withDefaults(p.defaultClaims).withDefaults(DefaultClaimMapping)
+
+	username, ok := claimsMap[claims.Username].(string)
+	if !ok || username == "" {
+		return info.User{}, providerErrors.NewMissingClaimError(claims.Username)
 	}
 
-	rawEmailVerified, present := claimsMap["email_verified"]
-	if !present {
-		return info.User{}, &providerErrors.ForDisplayError{
-			Message: "Authentication failure: email not verified",
-			Err:     providerErrors.NewMissingClaimError("email_verified"),
+	// Only a verified email identifies the user, anyone can usually set theirs at the provider.
+	if claims.Username == "email" {
+		rawEmailVerified, present := claimsMap["email_verified"]
+		if !present {
+			return info.User{}, &providerErrors.ForDisplayError{
+				Message: "Authentication failure: email not verified",
+				Err:     providerErrors.NewMissingClaimError("email_verified"),
+			}
 		}
-	}
-	if verified, ok := rawEmailVerified.(bool); !ok || !verified {
-		return info.User{}, &providerErrors.ForDisplayError{
-			Message: "Authentication failure: email not verified",
-			Err:     errors.New("email_verified claim value is false or malformed"),
+		if verified, ok := rawEmailVerified.(bool); !ok || !verified {
+			return info.User{}, &providerErrors.ForDisplayError{
+				Message: "Authentication failure: email not verified",
+				Err:     errors.New("email_verified claim value is false or malformed"),
+			}
 		}
 	}
 
 	// Optional claims: home, shell, name
-	home, _ := claimsMap["home"].(string)
-	shell, _ := claimsMap["shell"].(string)
-	gecos, _ := claimsMap["name"].(string)
+	home, _ := claimsMap[claims.Home].(string)
+	shell, _ := claimsMap[claims.Shell].(string)
+	gecos, _ := claimsMap[claims.Gecos].(string)
 
-	return info.NewUser(
-		email,
+	uid, err := uidHint(claimsMap, claims.UIDHint)
+	if err != nil {
+		return info.User{}, err
+	}
+
+	groups, err := groups(claimsMap, claims.Groups)
+	if err != nil {
+		return info.User{}, err
+	}
+
+	u := info.NewUser(
+		username,
 		home,
 		providerID,
 		shell,
 		gecos,
-		nil,
-	), nil
+		groups,
+	)
+	u.UIDHint = uid
+	return u, nil
+}
+
+// uidHint returns the UID of the claim, if it is set and present.
+func uidHint(claims map[string]interface{}, claim string) (uint32, error) {
+	if claim == "" {
+		return 0, nil
+	}
+
+	var uid uint64
+	var err error
+	switch v := claims[claim].(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		// The numbers of the JSON claims are decoded as floats.
+		if v != math.Trunc(v) || v < 0 {
+			err = errors.New("not an integer")
+		}
+		uid = uint64(v)
+	case string:
+		// Some providers map the LDAP attributes, like uidNumber, to string claims.
+		uid, err = strconv.ParseUint(v, 10, 32)
+	default:
+		err = fmt.Errorf("unexpected type %T", v)
+	}
+	if err == nil && (uid == 0 || uid > math.MaxUint32) {
+		err = errors.New("out of range")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("claim %q is not a valid UID: %v", claim, err)
+	}
+	return uint32(uid), nil
+}
+
+// groups returns the groups listed in the claim, if it is set. The groups prefixed with "linux-" are local groups,
+// which are identified by their name without the prefix.
+func groups(claims map[string]interface{}, claim string) ([]info.Group, error) {
+	if claim == "" {
+		return nil, nil
+	}
+
+	var names []string
+	switch v := claims[claim].(type) {
+	case nil:
+		// The identity providers usually omit the claim when the user is not a member of any group.
+		return nil, nil
+	case string:
+		names = []string{v}
+	case []interface{}:
+		for _, name := range v {
+			s, ok := name.(string)
+			if !ok {
+				return nil, fmt.Errorf("claim %q contains %v, which is not a group name", claim, name)
+			}
+			names = append(names, s)
+		}
+	default:
+		return nil, fmt.Errorf("claim %q is not a list of group names", claim)
+	}
+
+	var groups []info.Group
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		// Don't set the UGID for local groups, because that's how the user manager differentiates between local and
+		// remote groups.
+		if local, ok := strings.CutPrefix(name, LocalGroupPrefix); ok {
+			groups = append(groups, info.Group{Name: local})
+			continue
+		}
+		groups = append(groups, info.Group{Name: name, UGID: name})
+	}
+	return groups, nil
 }
 
 // NormalizeUsername parses a username into a normalized version.
@@ -100,7 +275,7 @@ func (p GenericProvider) VerifyUsername(requestedUsername, username string) erro
 // SupportedOnlineAuthModes returns the authentication modes supported by the
 // provider that require a connection to the identity provider.
 func (p GenericProvider) SupportedOnlineAuthModes() []string {
-	return []string{authmodes.Device, authmodes.DeviceQr}
+	return []string{authmodes.Browser, authmodes.Device, authmodes.DeviceQr}
 }
 
 // IsTokenExpiredError returns true if the reason for the error is that the refresh token is expired.
@@ -120,3 +295,18 @@ func (p GenericProvider) IsTokenExpiredError(err *oauth2.RetrieveError) bool {
 		return strings.Contains(err.ErrorDescription, desc)
 	})
 }
+
+// RequiredInteraction returns the interaction with the provider which the error requires from the user, if any.
+func (p GenericProvider) RequiredInteraction(err *oauth2.RetrieveError) providerErrors.Interaction {
+	switch err.ErrorCode {
+	// The error codes of the OpenID Connect specification for the authentication requests.
+	case "interaction_required", "consent_required", "login_required":
+		return providerErrors.InteractionOther
+	case "invalid_grant":
+		// Keycloak: the user has required actions (like updating the password or configuring OTP) to complete.
+		if strings.Contains(err.ErrorDescription, "Account is not fully set up") {
+			return providerErrors.InteractionOther
+		}
+	}
+	return providerErrors.NoInteraction
+}
@@ -16,10 +16,12 @@ func TestGetUserInfo(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
-		claims      map[string]interface{}
-		wantUser    info.User
-		wantErr     bool
-		wantErrType error
+		claims              map[string]interface{}
+		claimMapping        genericprovider.ClaimMapping
+		defaultClaimMapping genericprovider.ClaimMapping
+		wantUser            info.User
+		wantErr             bool
+		wantErrType         error
 	}{
 		"Successfully_get_user_info_with_all_fields": {
 			claims: map[string]interface{}{
@@ -40,6 +42,110 @@ func TestGetUserInfo(t *testing.T) {
 			},
 			wantUser: info.NewUser("user@example.com", "", "sub123", "", "", nil),
 		},
+		"Successfully_get_user_info_with_groups_from_the_groups_claim": {
+			claims: map[string]interface{}{
+				"sub":            "sub123",
+				"email":          "user@example.com",
+				"email_verified": true,
+				"groups":         []interface{}{"developers", "linux-sudo", ""},
+			},
+			claimMapping: genericprovider.ClaimMapping{Groups: "groups"},
+			wantUser: info.NewUser("user@example.com", "", "sub123", "", "", []info.Group{
+				{Name: "developers", UGID: "developers"},
+				{Name: "sudo"},
+			}),
+		},
+		"Successfully_get_user_info_with_a_single_group_in_the_groups_claim": {
+			claims: map[string]interface{}{
+				"sub":            "sub123",
+				"email":          "user@example.com",
+				"email_verified": true,
+				"roles":          "developers",
+			},
+			claimMapping: genericprovider.ClaimMapping{Groups: "roles"},
+			wantUser: info.NewUser("user@example.com", "", "sub123", "", "", []info.Group{
+				{Name: "developers", UGID: "developers"},
+			}),
+		},
+		"Successfully_get_user_info_without_groups_if_the_groups_claim_is_missing": {
+			claims: map[string]interface{}{
+				"sub":            "sub123",
+				"email":          "user@example.com",
+				"email_verified": true,
+			},
+			claimMapping: genericprovider.ClaimMapping{Groups: "groups"},
+			wantUser:     info.NewUser("user@example.com", "", "sub123", "", "", nil),
+		},
+		"Successfully_get_user_info_from_the_mapped_claims": {
+			claims: map[string]interface{}{
+				"sub":                "sub123",
+				"preferred_username": "user",
+				"uidNumber":          "10042",
+				"homeDirectory":      "/home/user",
+				"loginShell":         "/bin/zsh",
+				"displayName":        "Test User",
+			},
+			claimMapping: genericprovider.ClaimMapping{
+				Username: "preferred_username",
+				UIDHint:  "uidNumber",
+				Home:     "homeDirectory",
+				Shell:    "loginShell",
+				Gecos:    "displayName",
+			},
+			wantUser: withUIDHint(info.NewUser("user", "/home/user", "sub123", "/bin/zsh", "Test User", nil), 10042),
+		},
+		"Successfully_get_user_info_from_the_default_claims_of_the_provider": {
+			claims: map[string]interface{}{
+				"sub":                "sub123",
+				"preferred_username": "user",
+				"uidNumber":          "10042",
+				"homeDirectory":      "/home/user",
+				"loginShell":         "/bin/zsh",
+				"groups":             []interface{}{"developers"},
+			},
+			defaultClaimMapping: genericprovider.ClaimMapping{
+				Username: "preferred_username",
+				UIDHint:  "uidNumber",
+				Home:     "homeDirectory",
+				Shell:    "loginShell",
+				Groups:   "groups",
+			},
+			wantUser: withUIDHint(info.NewUser("user", "/home/user", "sub123", "/bin/zsh", "", []info.Group{
+				{Name: "developers", UGID: "developers"},
+			}), 10042),
+		},
+		"Successfully_get_user_info_from_the_mapped_claims_over_the_default_claims_of_the_provider": {
+			claims: map[string]interface{}{
+				"sub":                "sub123",
+				"preferred_username": "user",
+				"email":              "user@example.com",
+				"email_verified":     true,
+				"homeDirectory":      "/home/user",
+				"home":               "/srv/home/user",
+			},
+			claimMapping:        genericprovider.ClaimMapping{Username: "email", Home: "home"},
+			defaultClaimMapping: genericprovider.ClaimMapping{Username: "preferred_username", Home: "homeDirectory"},
+			wantUser:            info.NewUser("user@example.com", "/srv/home/user", "sub123", "", "", nil),
+		},
+		"Successfully_get_user_info_with_a_numeric_uid_claim": {
+			claims: map[string]interface{}{
+				"sub":            "sub123",
+				"email":          "user@example.com",
+				"email_verified": true,
+				"uidNumber":      10042.0,
+			},
+			claimMapping: genericprovider.ClaimMapping{UIDHint: "uidNumber"},
+			wantUser:     withUIDHint(info.NewUser("user@example.com", "", "sub123", "", "", nil), 10042),
+		},
+		"Successfully_get_user_info_without_groups_if_no_groups_claim_is_set": {
+			claims: map[string]interface{}{
+				"sub":            "sub123",
+				"email":          "user@example.com",
+				"email_verified": true,
+				"groups":         []interface{}{"developers"},
+			},
+			wantUser: info.NewUser("user@example.com", "", "sub123", "", "", nil),
+		},
 
 		"Error_when_sub_is_missing": {
 			claims: map[string]interface{}{
@@ -71,13 +177,76 @@ func TestGetUserInfo(t *testing.T) {
 			wantErr:     true,
 			wantErrType: &providerErrors.ForDisplayError{},
 		},
+		"Error_when_the_username_claim_is_missing": {
+			claims: map[string]interface{}{
+				"sub":            "sub123",
+				"email":          "user@example.com",
+				"email_verified": true,
+			},
+			claimMapping: genericprovider.ClaimMapping{Username: "preferred_username"},
+			wantErr:      true,
+			wantErrType:  &providerErrors.MissingClaimError{Claim: "preferred_username"},
+		},
+		"Error_when_the_uid_claim_is_not_a_number": {
+			claims: map[string]interface{}{
+				"sub":            "sub123",
+				"email":          "user@example.com",
+				"email_verified": true,
+				"uidNumber":      "user",
+			},
+			claimMapping: genericprovider.ClaimMapping{UIDHint: "uidNumber"},
+			wantErr:      true,
+		},
+		"Error_when_the_uid_claim_is_not_an_integer": {
+			claims: map[string]interface{}{
+				"sub":            "sub123",
+				"email":          "user@example.com",
+				"email_verified": true,
+				"uidNumber":      10042.5,
+			},
+			claimMapping: genericprovider.ClaimMapping{UIDHint: "uidNumber"},
+			wantErr:      true,
+		},
+		"Error_when_the_uid_claim_is_out_of_range": {
+			claims: map[string]interface{}{
+				"sub":            "sub123",
+				"email":          "user@example.com",
+				"email_verified": true,
+				"uidNumber":      -1.0,
+			},
+			claimMapping: genericprovider.ClaimMapping{UIDHint: "uidNumber"},
+			wantErr:      true,
+		},
+		"Error_when_the_groups_claim_is_not_a_list": {
+			claims: map[string]interface{}{
+				"sub":            "sub123",
+				"email":          "user@example.com",
+				"email_verified": true,
+				"groups":         42.0,
+			},
+			claimMapping: genericprovider.ClaimMapping{Groups: "groups"},
+			wantErr:      true,
+		},
+		"Error_when_the_groups_claim_contains_a_value_which_is_not_a_string": {
+			claims: map[string]interface{}{
+				"sub":            "sub123",
+				"email":          "user@example.com",
+				"email_verified": true,
+				"groups":         []interface{}{"developers", 42.0},
+			},
+			claimMapping: genericprovider.ClaimMapping{Groups: "groups"},
+			wantErr:      true,
+		},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			p := genericprovider.New()
+			p := genericprovider.New(
+				genericprovider.WithClaimMapping(tc.claimMapping),
+				genericprovider.WithDefaultClaimMapping(tc.defaultClaimMapping),
+			)
 			mockToken := &mockIDToken{claims: tc.claims}
 
 			user, err := p.GetUserInfo(mockToken, false)
@@ -96,6 +265,11 @@ func TestGetUserInfo(t *testing.T) {
 	}
 }
 
+func withUIDHint(u info.User, uid uint32) info.User {
+	u.UIDHint = uid
+	return u
+}
+
 type mockIDToken struct {
 	claims map[string]interface{}
 }
@@ -146,3 +320,35 @@ func TestIsTokenExpiredError(t *testing.T) {
 		})
 	}
 }
+
+func TestRequiredInteraction(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		errorCode        string
+		errorDescription string
+
+		want providerErrors.Interaction
+	}{
+		"Interaction_required":             {errorCode: "interaction_required", want: providerErrors.InteractionOther},
+		"Consent_required":                 {errorCode: "consent_required", want: providerErrors.InteractionOther},
+		"Login_required":                   {errorCode: "login_required", want: providerErrors.InteractionOther},
+		"Keycloak_account_not_fully_setup": {errorCode: "invalid_grant", errorDescription: "Account is not fully set up", want: providerErrors.InteractionOther},
+
+		"Keycloak_session_not_active": {errorCode: "invalid_grant", errorDescription: "Session not active", want: providerErrors.NoInteraction},
+		"Access_denied":               {errorCode: "access_denied", errorDescription: "Account is not fully set up", want: providerErrors.NoInteraction},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := genericprovider.New()
+			err := &oauth2.RetrieveError{
+				ErrorCode:        tc.errorCode,
+				ErrorDescription: tc.errorDescription,
+			}
+			got := p.RequiredInteraction(err)
+			require.Equal(t, tc.want, got, "RequiredInteraction returned unexpected result")
+		})
+	}
+}
@@ -22,6 +22,15 @@ type User struct {
 	Shell      string  `json:"shell"`
 	Gecos      string  `json:"gecos"`
 	Groups     []Group `json:"groups"`
+	// UIDHint is the UID suggested by the provider for the user, which authd gives them when they are created if it's
+	// free. It is 0 if the provider suggests none.
+	UIDHint uint32 `json:"uid_hint,omitempty"`
+	// Env holds the environment variables to set in the sessions of the user.
+	Env map[string]string `json:"env,omitempty"`
+	// SSHKeys holds the SSH public keys which the user can log in with, in the format of the authorized_keys files.
+	SSHKeys []string `json:"ssh_keys,omitempty"`
+	// Picture is the PNG or JPEG picture of the user, shown by the greeter of the display manager.
+	Picture []byte `json:"picture,omitempty"`
 }
 
 // NewUser creates a new user with the specified values.
@@ -0,0 +1,30 @@
+package info
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// MaxPictureSize is the maximum size of the pictures of the users, which are sent to authd along with their
+// information.
+const MaxPictureSize = 512 << 10
+
+// ReadPicture reads the picture of a user from r, which must be a PNG or JPEG image of at most MaxPictureSize bytes.
+func ReadPicture(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxPictureSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxPictureSize {
+		return nil, fmt.Errorf("picture is larger than %d bytes", MaxPictureSize)
+	}
+	if len(data) == 0 {
+		return nil, errors.New("picture is empty")
+	}
+	if t := http.DetectContentType(data); t != "image/png" && t != "image/jpeg" {
+		return nil, fmt.Errorf("picture is of unsupported type %s", t)
+	}
+	return data, nil
+}
@@ -0,0 +1,47 @@
+package info_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testPNG  = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	testJPEG = "\xff\xd8\xff\xe0\x00\x10JFIF\x00"
+)
+
+func TestReadPicture(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		data string
+
+		wantErr bool
+	}{
+		"Read_PNG_picture":                 {data: testPNG},
+		"Read_JPEG_picture":                {data: testJPEG},
+		"Read_picture_of_the_maximum_size": {data: testPNG + strings.Repeat("\x00", info.MaxPictureSize-len(testPNG))},
+
+		"Error_when_picture_is_empty":               {wantErr: true},
+		"Error_when_picture_is_too_large":           {data: testPNG + strings.Repeat("\x00", info.MaxPictureSize), wantErr: true},
+		"Error_when_picture_is_of_another_type":     {data: "GIF89a\x01\x00\x01\x00", wantErr: true},
+		"Error_when_picture_is_not_an_image_at_all": {data: "<html><body>Not found</body></html>", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := info.ReadPicture(bytes.NewReader([]byte(tc.data)))
+			if tc.wantErr {
+				require.Error(t, err, "ReadPicture should return an error")
+				return
+			}
+			require.NoError(t, err, "ReadPicture should not return an error")
+			require.Equal(t, []byte(tc.data), got, "ReadPicture should return the picture")
+		})
+	}
+}
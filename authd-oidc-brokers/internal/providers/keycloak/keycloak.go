@@ -0,0 +1,103 @@
+// Package keycloak is the Keycloak specific extension.
+package keycloak
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/genericprovider"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// defaultClaimMapping is the mapping of the claims of Keycloak, with the names of the POSIX attributes of the users
+// federated from LDAP directories. The username is the one of the user in the realm, as the emails of the users
+// created by the administrators are usually not verified.
+var defaultClaimMapping = genericprovider.ClaimMapping{
+	Username: "preferred_username",
+	UIDHint:  "uidNumber",
+	Home:     "homeDirectory",
+	Shell:    "loginShell",
+	Groups:   "groups",
+}
+
+// Provider is the Keycloak provider implementation.
+type Provider struct {
+	genericprovider.GenericProvider
+}
+
+// New returns a new Keycloak provider.
+func New() *Provider {
+	return &Provider{
+		GenericProvider: genericprovider.New(genericprovider.WithDefaultClaimMapping(defaultClaimMapping)),
+	}
+}
+
+// DisplayName returns the display name of the provider.
+func (*Provider) DisplayName() string {
+	return "Keycloak"
+}
+
+// AdditionalScopes returns the scopes required by the provider. With the offline_access scope, the refresh tokens
+// don't expire with the SSO session of the users, which is usually much shorter than their offline session.
+func (*Provider) AdditionalScopes() []string {
+	return []string{oidc.ScopeOfflineAccess}
+}
+
+// GetUserInfo returns the user info from the provided Claimer, with the groups named after their full path.
+func (p *Provider) GetUserInfo(claimer info.Claimer, isRefresh bool) (info.User, error) {
+	userInfo, err := p.GenericProvider.GetUserInfo(claimer, isRefresh)
+	if err != nil {
+		return info.User{}, err
+	}
+
+	userInfo.Groups = groupsFromPaths(userInfo.Groups)
+	return userInfo, nil
+}
+
+// groupsFromPaths returns the groups whose names are the full paths of the group membership mapper of Keycloak, like
+// "/engineering/admins", named after the path without the leading slash and with the other slashes replaced with
+// dashes, like "engineering-admins". The path stays their UGID. The groups whose last element of the path is prefixed
+// with "linux-" are local groups.
+func groupsFromPaths(groups []info.Group) []info.Group {
+	var converted []info.Group
+	for _, g := range groups {
+		path, ok := strings.CutPrefix(g.UGID, "/")
+		if !ok {
+			converted = append(converted, g)
+			continue
+		}
+
+		elems := slices.DeleteFunc(strings.Split(path, "/"), func(e string) bool { return e == "" })
+		if len(elems) == 0 {
+			continue
+		}
+		if local, ok := strings.CutPrefix(elems[len(elems)-1], genericprovider.LocalGroupPrefix); ok {
+			converted = append(converted, info.Group{Name: local})
+			continue
+		}
+		converted = append(converted, info.Group{Name: strings.Join(elems, "-"), UGID: g.UGID})
+	}
+	return converted
+}
+
+// ReadsUserInfoEndpoint returns false: the protocol mappers of Keycloak add the claims to the ID token by default.
+func (*Provider) ReadsUserInfoEndpoint() bool {
+	return false
+}
+
+// ReadsIntrospectionEndpoint returns true: the claims of the protocol mappers which are only added to the access
+// token, like the realm roles of the users, are returned by the token introspection endpoint. It also checks that the
+// session of the user is still active.
+func (*Provider) ReadsIntrospectionEndpoint() bool {
+	return true
+}
+
+// IsUserDisabledError returns true if the reason for the error is that the user is disabled in the realm.
+func (*Provider) IsUserDisabledError(err *oauth2.RetrieveError) bool {
+	if err.ErrorCode != "invalid_grant" {
+		return false
+	}
+	return strings.Contains(err.ErrorDescription, "User disabled") || strings.Contains(err.ErrorDescription, "Account disabled")
+}
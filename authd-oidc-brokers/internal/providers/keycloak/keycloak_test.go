@@ -0,0 +1,157 @@
+package keycloak_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	providerErrors "github.com/canonical/authd/authd-oidc-brokers/internal/providers/errors"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/keycloak"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestAdditionalScopes(t *testing.T) {
+	t.Parallel()
+
+	p := keycloak.New()
+
+	require.Equal(t, []string{"offline_access"}, p.AdditionalScopes(), "Keycloak provider should require the offline_access scope")
+}
+
+func TestClaimSources(t *testing.T) {
+	t.Parallel()
+
+	p := keycloak.New()
+
+	require.False(t, p.ReadsUserInfoEndpoint(), "Keycloak provider should not read the claims of the UserInfo endpoint")
+	require.True(t, p.ReadsIntrospectionEndpoint(), "Keycloak provider should read the claims of the token introspection endpoint")
+}
+
+func TestGetUserInfo(t *testing.T) {
+	t.Parallel()
+
+	baseClaims := map[string]interface{}{
+		"sub":                "sub123",
+		"preferred_username": "user",
+	}
+
+	tests := map[string]struct {
+		claims map[string]interface{}
+
+		wantUser    info.User
+		wantErr     bool
+		wantErrType error
+	}{
+		"Successfully_get_user_info_with_the_POSIX_attributes": {
+			claims: map[string]interface{}{
+				"uidNumber":     "10042",
+				"homeDirectory": "/home/user",
+				"loginShell":    "/bin/zsh",
+				"name":          "Test User",
+			},
+			wantUser: withUIDHint(info.NewUser("user", "/home/user", "sub123", "/bin/zsh", "Test User", nil), 10042),
+		},
+		"Successfully_get_user_info_with_the_groups_named_after_their_path": {
+			claims: map[string]interface{}{"groups": []interface{}{"/developers", "/engineering/admins", "/linux-sudo", "/ops/linux-docker", "/"}},
+			wantUser: info.NewUser("user", "", "sub123", "", "", []info.Group{
+				{Name: "developers", UGID: "/developers"},
+				{Name: "engineering-admins", UGID: "/engineering/admins"},
+				{Name: "sudo"},
+				{Name: "docker"},
+			}),
+		},
+		"Successfully_get_user_info_with_the_groups_without_path": {
+			claims: map[string]interface{}{"groups": []interface{}{"developers", "linux-sudo"}},
+			wantUser: info.NewUser("user", "", "sub123", "", "", []info.Group{
+				{Name: "developers", UGID: "developers"},
+				{Name: "sudo"},
+			}),
+		},
+
+		"Error_when_the_preferred_username_claim_is_missing": {
+			claims:      map[string]interface{}{"preferred_username": nil},
+			wantErr:     true,
+			wantErrType: &providerErrors.MissingClaimError{Claim: "preferred_username"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			claims := map[string]interface{}{}
+			for k, v := range baseClaims {
+				claims[k] = v
+			}
+			for k, v := range tc.claims {
+				claims[k] = v
+			}
+
+			p := keycloak.New()
+			got, err := p.GetUserInfo(&mockIDToken{claims: claims}, false)
+			if tc.wantErr {
+				require.Error(t, err, "GetUserInfo should return an error")
+				if tc.wantErrType != nil {
+					require.ErrorAs(t, err, &tc.wantErrType)
+				}
+				return
+			}
+			require.NoError(t, err, "GetUserInfo should not return an error")
+			require.Equal(t, tc.wantUser, got, "GetUserInfo returned unexpected user")
+		})
+	}
+}
+
+func TestIsUserDisabledError(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		errorCode        string
+		errorDescription string
+
+		wantDisabled bool
+	}{
+		"User_disabled":    {errorCode: "invalid_grant", errorDescription: "User disabled", wantDisabled: true},
+		"Account_disabled": {errorCode: "invalid_grant", errorDescription: "Account disabled", wantDisabled: true},
+
+		"Non_invalid_grant_error":     {errorCode: "access_denied", errorDescription: "User disabled", wantDisabled: false},
+		"Keycloak_session_not_active": {errorCode: "invalid_grant", errorDescription: "Session not active", wantDisabled: false},
+		"Empty_description":           {errorCode: "invalid_grant", errorDescription: "", wantDisabled: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := keycloak.New()
+			err := &oauth2.RetrieveError{
+				ErrorCode:        tc.errorCode,
+				ErrorDescription: tc.errorDescription,
+			}
+			got := p.IsUserDisabledError(err)
+			require.Equal(t, tc.wantDisabled, got, "IsUserDisabledError returned unexpected result")
+		})
+	}
+}
+
+func withUIDHint(u info.User, uid uint32) info.User {
+	u.UIDHint = uid
+	return u
+}
+
+type mockIDToken struct {
+	claims map[string]interface{}
+}
+
+func (m *mockIDToken) Claims(v interface{}) error {
+	data, err := json.Marshal(m.claims)
+	if err != nil {
+		return fmt.Errorf("failed to marshal claims: %v", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to unmarshal claims: %v", err)
+	}
+
+	return nil
+}
@@ -117,6 +117,12 @@ type MFAChallengeInfo struct {
 	Method            string
 	PollingIntervalMs int
 	MaxPollAttempts   int
+	// FIDOChallenge is the WebAuthn challenge to sign with a security key, set
+	// only for the FidoKey method.
+	FIDOChallenge string
+	// FIDOAllowList is the IDs of the security keys registered for the user,
+	// which can sign the FIDOChallenge.
+	FIDOAllowList []string
 }
 
 // MFAErrorCategory classifies an MFA error so the broker can route
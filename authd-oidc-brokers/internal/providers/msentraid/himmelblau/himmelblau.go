@@ -379,6 +379,14 @@ func InitiateMFAFlowWithPassword(ctx context.Context, clientID, tenantID string,
 		MaxPollAttempts:   mfaFlowMaxPollAttempts(flow),
 	}
 
+	if method == "FidoKey" {
+		challengeInfo.FIDOChallenge, challengeInfo.FIDOAllowList, err = mfaFlowFIDOChallenge(flow)
+		if err != nil {
+			FreeMFAFlowState(flow)
+			return nil, nil, err
+		}
+	}
+
 	return flow, challengeInfo, nil
 }
 
@@ -606,6 +606,41 @@ func mfaFlowMethod(flow *MFAFlowState) (string, error) {
 	return C.GoString(cMethod), nil
 }
 
+// mfaFlowFIDOChallenge returns the WebAuthn challenge of a FidoKey MFA flow and
+// the IDs of the credentials allowed to sign it.
+func mfaFlowFIDOChallenge(flow *MFAFlowState) (string, []string, error) {
+	if flow == nil {
+		return "", nil, fmt.Errorf("missing MFA flow state")
+	}
+	flow.mu.Lock()
+	defer flow.mu.Unlock()
+	c := cFlow(flow)
+	if c == nil {
+		return "", nil, fmt.Errorf("missing MFA flow state")
+	}
+
+	var cChallenge *C.char
+	msalErr := C.mfa_auth_continue_fido_challenge(c, &cChallenge)
+	if msalErr != nil {
+		return "", nil, fmt.Errorf("failed to get FIDO challenge: %v", msalErrorMsg(msalErr))
+	}
+	defer C.free(unsafe.Pointer(cChallenge))
+
+	var cAllowList **C.char
+	var cLen C.int
+	msalErr = C.mfa_auth_continue_fido_allow_list(c, &cAllowList, &cLen)
+	if msalErr != nil {
+		return "", nil, fmt.Errorf("failed to get FIDO allow list: %v", msalErrorMsg(msalErr))
+	}
+	defer C.string_array_free(cAllowList, cLen)
+
+	var allowList []string
+	for _, id := range unsafe.Slice(cAllowList, int(cLen)) {
+		allowList = append(allowList, C.GoString(id))
+	}
+	return C.GoString(cChallenge), allowList, nil
+}
+
 func mfaFlowPollingInterval(flow *MFAFlowState) int {
 	if flow == nil {
 		return -1
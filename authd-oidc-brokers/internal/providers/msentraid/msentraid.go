@@ -31,9 +31,12 @@ import (
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/k0kubun/pp"
+	kiotahttp "github.com/microsoft/kiota-http-go"
 	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	msgraphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
 	msgraphauth "github.com/microsoftgraph/msgraph-sdk-go-core/authentication"
 	msgraphmodels "github.com/microsoftgraph/msgraph-sdk-go/models"
+	msgraphusers "github.com/microsoftgraph/msgraph-sdk-go/users"
 	"golang.org/x/oauth2"
 )
 
@@ -57,6 +60,10 @@ type Provider struct {
 	// admin-consented in Entra. Populated by SetGraphClientSecret after parsing config.
 	graphClientSecret string
 
+	// transport is the transport of the requests to Microsoft Entra ID and to the Graph API, or nil for the default
+	// one. Populated by SetTransport with the one of the broker, which goes through its proxy.
+	transport http.RoundTripper
+
 	// Used as the token scopes of the access token for the Microsoft Graph API in tests.
 	tokenScopesForGraphAPI []string
 
@@ -74,6 +81,12 @@ func (p *Provider) SetGraphClientSecret(secret string) {
 	p.graphClientSecret = secret
 }
 
+// SetTransport sets the transport of the requests sent by the provider with its own HTTP clients, so that they go
+// through the proxy of the broker and trust its CA certificates.
+func (p *Provider) SetTransport(transport http.RoundTripper) {
+	p.transport = transport
+}
+
 // New returns a new MSEntraID provider.
 func New() *Provider {
 	return &Provider{
@@ -361,14 +374,22 @@ func (p *Provider) userClaims(idToken info.Claimer) (claims, error) {
 
 // newGraphServiceClient builds a Microsoft Graph client that authenticates with
 // the given parsed JWT against the resolved Graph host.
-func newGraphServiceClient(token *jwt.Token, msgraphHost string) (*msgraphsdk.GraphServiceClient, error) {
+func newGraphServiceClient(token *jwt.Token, msgraphHost string, transport http.RoundTripper) (*msgraphsdk.GraphServiceClient, error) {
 	cred := azureTokenCredential{token: token}
 	auth, err := msgraphauth.NewAzureIdentityAuthenticationProvider(cred)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create AzureIdentityAuthenticationProvider: %w", err)
 	}
 
-	adapter, err := msgraphsdk.NewGraphRequestAdapter(auth)
+	// A nil client is replaced by the default one of the SDK.
+	var httpClient *http.Client
+	if transport != nil {
+		options := msgraphsdk.GetDefaultClientOptions()
+		httpClient = msgraphcore.GetDefaultClient(&options)
+		httpClient.Transport = kiotahttp.NewCustomTransportWithParentTransport(transport, msgraphcore.GetDefaultMiddlewaresWithOptions(&options)...)
+	}
+
+	adapter, err := msgraphsdk.NewGraphRequestAdapterWithParseNodeFactoryAndSerializationWriterFactoryAndHttpClient(auth, nil, nil, httpClient)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create GraphRequestAdapter: %w", err)
 	}
@@ -383,7 +404,7 @@ func newGraphServiceClient(token *jwt.Token, msgraphHost string) (*msgraphsdk.Gr
 func (p *Provider) fetchUserGroupsByClientCredentials(ctx context.Context, clientID, issuerURL, userOID, msgraphHost string) ([]info.Group, error) {
 	log.Debugf(ctx, "Getting user groups via client credentials for OID %s", userOID)
 
-	appTokenStr, err := acquireClientCredentialsToken(ctx, issuerURL, clientID, p.graphClientSecret, msgraphHost)
+	appTokenStr, err := acquireClientCredentialsToken(ctx, p.transport, issuerURL, clientID, p.graphClientSecret, msgraphHost)
 	if err != nil {
 		return nil, fmt.Errorf("failed to acquire client credentials token for Graph API: %w", err)
 	}
@@ -393,7 +414,7 @@ func (p *Provider) fetchUserGroupsByClientCredentials(ctx context.Context, clien
 		return nil, fmt.Errorf("failed to parse client credentials token: %w", err)
 	}
 
-	client, err := newGraphServiceClient(appToken, msgraphHost)
+	client, err := newGraphServiceClient(appToken, msgraphHost, p.transport)
 	if err != nil {
 		return nil, err
 	}
@@ -424,8 +445,9 @@ func (p *Provider) getOIDFromToken(token *jwt.Token) (string, error) {
 }
 
 // acquireClientCredentialsToken obtains an application-level access token for
-// the resolved Microsoft Graph host using the OAuth2 client credentials flow.
-func acquireClientCredentialsToken(ctx context.Context, issuerURL, clientID, clientSecret, msgraphHost string) (string, error) {
+// the resolved Microsoft Graph host using the OAuth2 client credentials flow. The request is sent with transport, or
+// with the default one if it's nil.
+func acquireClientCredentialsToken(ctx context.Context, transport http.RoundTripper, issuerURL, clientID, clientSecret, msgraphHost string) (string, error) {
 	tokenURL, err := clientCredentialsTokenURL(issuerURL)
 	if err != nil {
 		return "", err
@@ -447,7 +469,7 @@ func acquireClientCredentialsToken(ctx context.Context, issuerURL, clientID, cli
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Transport: transport, Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("client credentials token request failed: %w", err)
@@ -531,7 +553,7 @@ func (p *Provider) fetchUserGroups(token *jwt.Token, msgraphHost string) ([]info
 		return nil, &providerErrors.ForDisplayError{Message: msg}
 	}
 
-	client, err := newGraphServiceClient(token, msgraphHost)
+	client, err := newGraphServiceClient(token, msgraphHost, p.transport)
 	if err != nil {
 		return nil, err
 	}
@@ -546,6 +568,50 @@ func (p *Provider) fetchUserGroups(token *jwt.Token, msgraphHost string) ([]info
 	return processSecurityGroups(graphGroups)
 }
 
+// GetPicture retrieves the profile photo of the user via the Microsoft Graph API. It returns nil if the user has no
+// photo or if the access token doesn't carry the User.Read scope, as it is the case for the tokens acquired for
+// device registration.
+func (p *Provider) GetPicture(ctx context.Context, client *http.Client, token *oauth2.Token, providerMetadata map[string]interface{}) ([]byte, error) {
+	accessToken, _, err := new(jwt.Parser).ParseUnverified(token.AccessToken, jwt.MapClaims{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse access token: %w", err)
+	}
+	scopes := p.tokenScopesForGraphAPI
+	if scopes == nil {
+		scopes, err = p.getTokenScopes(accessToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !slices.Contains(scopes, "User.Read") {
+		log.Debug(ctx, "Not getting the photo of the user: the access token is missing the User.Read scope")
+		return nil, nil
+	}
+
+	log.Debug(ctx, "Getting the photo of the user from Microsoft Graph API")
+	// The smallest size at which Microsoft Entra ID stores the photos which is large enough for the greeters.
+	photoURL := resolveMSGraphHost(providerMetadata) + "/me/photos/240x240/$value"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, photoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the photo of the user: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get the photo of the user: %s", resp.Status)
+	}
+	return info.ReadPicture(resp.Body)
+}
+
 // processSecurityGroups converts a slice of Graph API group objects into the
 // internal info.Group representation, deduplicating and normalising names.
 func processSecurityGroups(graphGroups []msgraphmodels.Groupable) ([]info.Group, error) {
@@ -677,14 +743,30 @@ func collectSecurityGroups(logContext string, getPage func(nextLink string) ([]m
 	return groups, nil
 }
 
+// groupsPageSize is the number of groups requested per page of the Microsoft Graph API. It's the maximum allowed,
+// the default being 100, so that users who are members of many groups need fewer requests.
+var groupsPageSize int32 = 999
+
+// groupsQueryConfig returns the configuration of the requests for the groups of a user. Only the properties we use
+// are requested, which keeps the responses small for users who are members of many groups.
+func groupsQueryConfig() *msgraphusers.ItemTransitiveMemberOfGraphGroupRequestBuilderGetRequestConfiguration {
+	return &msgraphusers.ItemTransitiveMemberOfGraphGroupRequestBuilderGetRequestConfiguration{
+		QueryParameters: &msgraphusers.ItemTransitiveMemberOfGraphGroupRequestBuilderGetQueryParameters{
+			Top:    &groupsPageSize,
+			Select: []string{"id", "displayName", "description", "uniqueName", "securityEnabled", "groupTypes"},
+		},
+	}
+}
+
 func getSecurityGroups(client *msgraphsdk.GraphServiceClient) ([]msgraphmodels.Groupable, error) {
 	requestBuilder := client.Me().TransitiveMemberOf().GraphGroup()
 	return collectSecurityGroups("", func(nextLink string) ([]msgraphmodels.Groupable, *string, error) {
-		rb := requestBuilder
+		rb, config := requestBuilder, groupsQueryConfig()
 		if nextLink != "" {
-			rb = requestBuilder.WithUrl(nextLink)
+			// The next link already contains the query parameters.
+			rb, config = requestBuilder.WithUrl(nextLink), nil
 		}
-		result, err := rb.Get(context.Background(), nil)
+		result, err := rb.Get(context.Background(), config)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to get user groups: %v", err)
 		}
@@ -702,11 +784,12 @@ func getSecurityGroups(client *msgraphsdk.GraphServiceClient) ([]msgraphmodels.G
 func getSecurityGroupsByUserID(client *msgraphsdk.GraphServiceClient, userID string) ([]msgraphmodels.Groupable, error) {
 	requestBuilder := client.Users().ByUserId(userID).TransitiveMemberOf().GraphGroup()
 	return collectSecurityGroups(fmt.Sprintf(" for user %s", userID), func(nextLink string) ([]msgraphmodels.Groupable, *string, error) {
-		rb := requestBuilder
+		rb, config := requestBuilder, groupsQueryConfig()
 		if nextLink != "" {
-			rb = requestBuilder.WithUrl(nextLink)
+			// The next link already contains the query parameters.
+			rb, config = requestBuilder.WithUrl(nextLink), nil
 		}
-		result, err := rb.Get(context.Background(), nil)
+		result, err := rb.Get(context.Background(), config)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to get user groups by user ID: %v", err)
 		}
@@ -740,7 +823,7 @@ func (p *Provider) NormalizeUsername(username string) string {
 // SupportedOnlineAuthModes returns the authentication modes supported by the
 // provider that require a connection to Entra ID.
 func (p *Provider) SupportedOnlineAuthModes() []string {
-	return []string{authmodes.EntraPassword, authmodes.Device, authmodes.DeviceQr}
+	return []string{authmodes.EntraPassword, authmodes.Browser, authmodes.Device, authmodes.DeviceQr}
 }
 
 // unmarshalOptionalDeviceRegistrationData decodes JSON device-registration data
@@ -847,7 +930,11 @@ func (p *Provider) VerifyAccessToken(ctx context.Context, issuerURL, accessToken
 		p.keySets = make(map[string]*tokenverify.RemoteKeySet)
 	}
 	if _, ok := p.keySets[jwksURI]; !ok {
-		p.keySets[jwksURI] = tokenverify.NewRemoteKeySet(jwksURI, nil)
+		var client *http.Client
+		if p.transport != nil {
+			client = &http.Client{Transport: p.transport, Timeout: 10 * time.Second}
+		}
+		p.keySets[jwksURI] = tokenverify.NewRemoteKeySet(jwksURI, client)
 	}
 	keySet := p.keySets[jwksURI]
 	p.keySetMu.Unlock()
@@ -996,3 +1083,40 @@ func (p *Provider) IsTokenExpiredError(err *oauth2.RetrieveError) bool {
 func (p *Provider) IsUserDisabledError(err *oauth2.RetrieveError) bool {
 	return err.ErrorCode == "invalid_grant" && strings.HasPrefix(err.ErrorDescription, "AADSTS50057:")
 }
+
+// RequiredInteraction returns the interaction with Microsoft Entra ID which the error requires from the user, if any.
+// Entra ID returns these AADSTS codes with either the interaction_required or the invalid_grant error code, so only
+// the description is matched.
+func (p *Provider) RequiredInteraction(err *oauth2.RetrieveError) providerErrors.Interaction {
+	hasPrefix := func(prefix string) bool { return strings.HasPrefix(err.ErrorDescription, prefix) }
+
+	passwordPrefixes := []string{
+		"AADSTS50055:", // password expired
+		"AADSTS50144:", // Active Directory password expired
+	}
+	if slices.ContainsFunc(passwordPrefixes, hasPrefix) {
+		return providerErrors.InteractionPasswordExpired
+	}
+
+	mfaPrefixes := []string{
+		"AADSTS50072:", // MFA enrollment required
+		"AADSTS50074:", // strong authentication required
+		"AADSTS50076:", // MFA required by a policy (e.g. after a change of location)
+		"AADSTS50079:", // MFA registration required
+		"AADSTS50203:", // authenticator app registration required
+	}
+	if slices.ContainsFunc(mfaPrefixes, hasPrefix) {
+		return providerErrors.InteractionMFA
+	}
+
+	otherPrefixes := []string{
+		"AADSTS16000:", // interactive sign-in required
+		"AADSTS50158:", // external security challenge (e.g. Conditional Access terms of use) not satisfied
+		"AADSTS65001:", // consent to the application required
+	}
+	if slices.ContainsFunc(otherPrefixes, hasPrefix) || err.ErrorCode == "interaction_required" {
+		return providerErrors.InteractionOther
+	}
+
+	return providerErrors.NoInteraction
+}
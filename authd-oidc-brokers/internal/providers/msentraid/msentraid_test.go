@@ -15,6 +15,7 @@ import (
 	"testing"
 	"time"
 
+	providerErrors "github.com/canonical/authd/authd-oidc-brokers/internal/providers/errors"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/msentraid"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/msentraid/himmelblau"
@@ -218,6 +219,7 @@ func TestGetGroups(t *testing.T) {
 		"Successfully_get_groups_with_mixed_groups":             {groupEndpointHandler: mixedGroupHandler},
 		"Successfully_get_groups_filtering_non_security_groups": {groupEndpointHandler: nonSecurityGroupHandler},
 		"Successfully_get_groups_with_acquired_access_token":    {acquireAccessToken: true},
+		"Successfully_get_groups_over_several_pages":            {groupEndpointHandler: pagedGroupHandler},
 
 		"Error_when_msgraph_host_is_invalid":             {providerMetadata: map[string]any{"msgraph_host": "invalid"}, wantErr: true},
 		"Error_when_token_does_not_have_required_scopes": {tokenScopes: []string{"not the required scopes"}, wantErr: true},
@@ -479,6 +481,58 @@ func TestGetGroupsClientCredentialsUsesConfiguredIssuerAndGraphHosts(t *testing.
 	}, got)
 }
 
+func TestGetPicture(t *testing.T) {
+	t.Parallel()
+
+	accessToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{})
+	accessTokenStr, err := accessToken.SignedString(testutils.MockKey)
+	require.NoError(t, err, "Failed to sign access token")
+	token := &oauth2.Token{AccessToken: accessTokenStr}
+
+	tests := map[string]struct {
+		tokenScopes          []string
+		photoEndpointHandler http.HandlerFunc
+
+		want    []byte
+		wantErr bool
+	}{
+		"Successfully_get_picture":                          {want: []byte(testPhoto)},
+		"No_picture_if_user_has_no_photo":                   {photoEndpointHandler: http.NotFound},
+		"No_picture_if_token_does_not_have_User.Read_scope": {tokenScopes: []string{"GroupMember.Read.All"}},
+
+		"Error_when_getting_the_photo_fails": {
+			photoEndpointHandler: func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusForbidden) },
+			wantErr:              true,
+		},
+		"Error_when_photo_is_not_a_picture": {
+			photoEndpointHandler: func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte(`{"error": "oops"}`)) },
+			wantErr:              true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.tokenScopes == nil {
+				tc.tokenScopes = strings.Split(msentraid.AllExpectedScopes(), " ")
+			}
+			mockServer, cleanup := startMockMSServer(t, &mockMSServerConfig{PhotoEndpointHandler: tc.photoEndpointHandler})
+			t.Cleanup(cleanup)
+
+			p := msentraid.New()
+			p.SetTokenScopesForGraphAPI(tc.tokenScopes)
+
+			got, err := p.GetPicture(context.Background(), mockServer.Client(), token, map[string]any{"msgraph_host": mockServer.URL})
+			if tc.wantErr {
+				require.Error(t, err, "GetPicture should return an error")
+				return
+			}
+			require.NoError(t, err, "GetPicture should not return an error")
+			require.Equal(t, tc.want, got, "GetPicture should return the expected picture")
+		})
+	}
+}
+
 func TestIsTokenForDeviceRegistration(t *testing.T) {
 	t.Parallel()
 
@@ -657,6 +711,41 @@ func TestIsTokenExpiredError(t *testing.T) {
 	}
 }
 
+func TestRequiredInteraction(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		errorCode        string
+		errorDescription string
+
+		want providerErrors.Interaction
+	}{
+		"AADSTS50055_password_expired":        {errorCode: "invalid_grant", errorDescription: "AADSTS50055: The password is expired.", want: providerErrors.InteractionPasswordExpired},
+		"AADSTS50144_ad_password_expired":     {errorCode: "invalid_grant", errorDescription: "AADSTS50144: The user's Active Directory password has expired.", want: providerErrors.InteractionPasswordExpired},
+		"AADSTS50076_mfa_required":            {errorCode: "interaction_required", errorDescription: "AADSTS50076: Due to a configuration change made by your administrator, or because you moved to a new location, you must use multi-factor authentication.", want: providerErrors.InteractionMFA},
+		"AADSTS50079_mfa_registration":        {errorCode: "interaction_required", errorDescription: "AADSTS50079: Due to a configuration change made by your administrator, you must enroll in multi-factor authentication.", want: providerErrors.InteractionMFA},
+		"AADSTS50158_external_challenge":      {errorCode: "interaction_required", errorDescription: "AADSTS50158: External security challenge not satisfied.", want: providerErrors.InteractionOther},
+		"AADSTS65001_consent_required":        {errorCode: "invalid_grant", errorDescription: "AADSTS65001: The user or administrator has not consented to use the application.", want: providerErrors.InteractionOther},
+		"Unknown_interaction_required_AADSTS": {errorCode: "interaction_required", errorDescription: "AADSTS12345: Unknown.", want: providerErrors.InteractionOther},
+
+		"AADSTS50173_grant_revoked": {errorCode: "invalid_grant", errorDescription: "AADSTS50173: The provided grant has expired", want: providerErrors.NoInteraction},
+		"AADSTS50057_user_disabled": {errorCode: "invalid_grant", errorDescription: "AADSTS50057: The user account is disabled.", want: providerErrors.NoInteraction},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := msentraid.New()
+			err := &oauth2.RetrieveError{
+				ErrorCode:        tc.errorCode,
+				ErrorDescription: tc.errorDescription,
+			}
+			got := p.RequiredInteraction(err)
+			require.Equal(t, tc.want, got, "RequiredInteraction returned unexpected result")
+		})
+	}
+}
+
 func TestMain(m *testing.M) {
 	log.SetLevel(log.DebugLevel)
 
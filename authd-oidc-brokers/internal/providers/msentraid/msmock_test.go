@@ -59,6 +59,8 @@ type mockMSServerConfig struct {
 	// If empty, requests to the token endpoint will be accepted for any tenant.
 	TenantID             string
 	GroupEndpointHandler http.HandlerFunc
+	// PhotoEndpointHandler overrides the response of the photo endpoint. Defaults to a PNG picture.
+	PhotoEndpointHandler http.HandlerFunc
 	// RefreshHandler overrides the refresh_token grant response. Defaults to a
 	// successful token; set it to simulate a disabled/revoked user (e.g. AADSTS50057).
 	RefreshHandler http.HandlerFunc
@@ -72,6 +74,9 @@ func startMockMSServer(t *testing.T, config *mockMSServerConfig) (mockServer *mo
 	if config.GroupEndpointHandler == nil {
 		config.GroupEndpointHandler = simpleGroupHandler
 	}
+	if config.PhotoEndpointHandler == nil {
+		config.PhotoEndpointHandler = photoHandler
+	}
 
 	rsaPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	require.NoError(t, err, "failed to generate RSA private key")
@@ -104,6 +109,9 @@ func startMockMSServer(t *testing.T, config *mockMSServerConfig) (mockServer *mo
 		case r.Method == http.MethodGet && (strings.HasSuffix(r.URL.Path, "/me/transitiveMemberOf/graph.group") || strings.Contains(r.URL.Path, "/transitiveMemberOf/graph.group")):
 			config.GroupEndpointHandler(w, r)
 
+		case r.Method == http.MethodGet && r.URL.Path == "/me/photos/240x240/$value":
+			config.PhotoEndpointHandler(w, r)
+
 		default:
 			require.Fail(t, "unexpected request", "path=%s, method=%s", r.URL.Path, r.Method)
 		}
@@ -406,6 +414,41 @@ func simpleGroupHandler(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(response)
 }
 
+// testPhoto is the beginning of a PNG picture, returned as the photo of the user.
+const testPhoto = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+
+func photoHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "image/png")
+	_, _ = w.Write([]byte(testPhoto))
+}
+
+// pagedGroupHandler simulates a successful response split over two pages. The first page is only returned if the
+// request asks for the maximum page size and only for the properties we use.
+func pagedGroupHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var response map[string]any
+	switch {
+	case query.Get("$skiptoken") == "page2":
+		response = map[string]any{
+			"value": []map[string]any{
+				{"id": "id2", "displayName": "Group2", "securityEnabled": true},
+			},
+		}
+	case query.Get("$top") == "999" && query.Get("$select") == "id,displayName,description,uniqueName,securityEnabled,groupTypes":
+		response = map[string]any{
+			"value": []map[string]any{
+				{"id": "id1", "displayName": "Group1", "securityEnabled": true},
+			},
+			"@odata.nextLink": fmt.Sprintf("http://%s%s?$skiptoken=page2", r.Host, r.URL.Path),
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unexpected query %q", r.URL.RawQuery), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
 // disabledRefreshHandler simulates Entra rejecting a refresh_token grant for a
 // disabled user with AADSTS50057, mirroring the real token-endpoint response.
 func disabledRefreshHandler(w http.ResponseWriter, _ *http.Request) {
@@ -0,0 +1,78 @@
+// Package okta is the Okta specific extension.
+package okta
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/genericprovider"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// everyoneGroup is the group of Okta which all the users of the organization are a member of.
+const everyoneGroup = "Everyone"
+
+// defaultClaimMapping is the mapping of the claims of Okta, with the names of the POSIX attributes of the profiles
+// imported from LDAP directories.
+var defaultClaimMapping = genericprovider.ClaimMapping{
+	UIDHint: "uidNumber",
+	Home:    "homeDirectory",
+	Shell:   "loginShell",
+	Groups:  "groups",
+}
+
+// Provider is the Okta provider implementation.
+type Provider struct {
+	genericprovider.GenericProvider
+}
+
+// New returns a new Okta provider.
+func New() *Provider {
+	return &Provider{
+		GenericProvider: genericprovider.New(genericprovider.WithDefaultClaimMapping(defaultClaimMapping)),
+	}
+}
+
+// DisplayName returns the display name of the provider.
+func (*Provider) DisplayName() string {
+	return "Okta"
+}
+
+// AdditionalScopes returns the scopes required by the provider. Okta only issues refresh tokens for the
+// offline_access scope.
+func (*Provider) AdditionalScopes() []string {
+	return []string{oidc.ScopeOfflineAccess}
+}
+
+// GetUserInfo returns the user info from the provided Claimer. The Everyone group, which all the users are a member
+// of, is not one of their groups.
+func (p *Provider) GetUserInfo(claimer info.Claimer, isRefresh bool) (info.User, error) {
+	userInfo, err := p.GenericProvider.GetUserInfo(claimer, isRefresh)
+	if err != nil {
+		return info.User{}, err
+	}
+
+	userInfo.Groups = slices.DeleteFunc(userInfo.Groups, func(g info.Group) bool {
+		return g.UGID == everyoneGroup
+	})
+	return userInfo, nil
+}
+
+// ReadsUserInfoEndpoint returns true: Okta only puts the base claims in the ID tokens issued with an access token,
+// the claims of the profile of the users are returned by the UserInfo endpoint.
+func (*Provider) ReadsUserInfoEndpoint() bool {
+	return true
+}
+
+// ReadsIntrospectionEndpoint returns false: the access tokens of the org authorization server of Okta have no claims
+// of the users.
+func (*Provider) ReadsIntrospectionEndpoint() bool {
+	return false
+}
+
+// IsTokenExpiredError returns true if the reason for the error is that the refresh token is expired.
+func (*Provider) IsTokenExpiredError(err *oauth2.RetrieveError) bool {
+	return err.ErrorCode == "invalid_grant" && strings.Contains(err.ErrorDescription, "The refresh token is invalid or expired")
+}
@@ -0,0 +1,149 @@
+package okta_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/genericprovider"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/okta"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestAdditionalScopes(t *testing.T) {
+	t.Parallel()
+
+	p := okta.New()
+
+	require.Equal(t, []string{"offline_access"}, p.AdditionalScopes(), "Okta provider should require the offline_access scope")
+}
+
+func TestClaimSources(t *testing.T) {
+	t.Parallel()
+
+	p := okta.New()
+
+	require.True(t, p.ReadsUserInfoEndpoint(), "Okta provider should read the claims of the UserInfo endpoint")
+	require.False(t, p.ReadsIntrospectionEndpoint(), "Okta provider should not read the claims of the token introspection endpoint")
+}
+
+func TestGetUserInfo(t *testing.T) {
+	t.Parallel()
+
+	baseClaims := map[string]interface{}{
+		"sub":            "sub123",
+		"email":          "user@example.com",
+		"email_verified": true,
+	}
+
+	tests := map[string]struct {
+		claims       map[string]interface{}
+		claimMapping genericprovider.ClaimMapping
+
+		wantUser info.User
+		wantErr  bool
+	}{
+		"Successfully_get_user_info_with_the_POSIX_attributes": {
+			claims: map[string]interface{}{
+				"uidNumber":     "10042",
+				"homeDirectory": "/home/user",
+				"loginShell":    "/bin/zsh",
+				"name":          "Test User",
+			},
+			wantUser: withUIDHint(info.NewUser("user@example.com", "/home/user", "sub123", "/bin/zsh", "Test User", nil), 10042),
+		},
+		"Successfully_get_user_info_without_the_Everyone_group": {
+			claims: map[string]interface{}{"groups": []interface{}{"Everyone", "developers", "linux-sudo"}},
+			wantUser: info.NewUser("user@example.com", "", "sub123", "", "", []info.Group{
+				{Name: "developers", UGID: "developers"},
+				{Name: "sudo"},
+			}),
+		},
+		"Successfully_get_user_info_from_the_mapped_claims": {
+			claims:       map[string]interface{}{"roles": []interface{}{"developers"}, "groups": []interface{}{"admins"}},
+			claimMapping: genericprovider.ClaimMapping{Groups: "roles"},
+			wantUser: info.NewUser("user@example.com", "", "sub123", "", "", []info.Group{
+				{Name: "developers", UGID: "developers"},
+			}),
+		},
+
+		"Error_when_the_uid_claim_is_not_a_number": {claims: map[string]interface{}{"uidNumber": "user"}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			claims := map[string]interface{}{}
+			for k, v := range baseClaims {
+				claims[k] = v
+			}
+			for k, v := range tc.claims {
+				claims[k] = v
+			}
+
+			p := okta.New()
+			p.SetClaimMapping(tc.claimMapping)
+			got, err := p.GetUserInfo(&mockIDToken{claims: claims}, false)
+			if tc.wantErr {
+				require.Error(t, err, "GetUserInfo should return an error")
+				return
+			}
+			require.NoError(t, err, "GetUserInfo should not return an error")
+			require.Equal(t, tc.wantUser, got, "GetUserInfo returned unexpected user")
+		})
+	}
+}
+
+func TestIsTokenExpiredError(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		errorCode        string
+		errorDescription string
+
+		wantExpired bool
+	}{
+		"Refresh_token_invalid_or_expired": {errorCode: "invalid_grant", errorDescription: "The refresh token is invalid or expired.", wantExpired: true},
+
+		"Non_invalid_grant_error":           {errorCode: "access_denied", errorDescription: "The refresh token is invalid or expired.", wantExpired: false},
+		"Unknown_invalid_grant_description": {errorCode: "invalid_grant", errorDescription: "The authorization code is invalid or has expired.", wantExpired: false},
+		"Empty_description":                 {errorCode: "invalid_grant", errorDescription: "", wantExpired: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := okta.New()
+			err := &oauth2.RetrieveError{
+				ErrorCode:        tc.errorCode,
+				ErrorDescription: tc.errorDescription,
+			}
+			got := p.IsTokenExpiredError(err)
+			require.Equal(t, tc.wantExpired, got, "IsTokenExpiredError returned unexpected result")
+		})
+	}
+}
+
+func withUIDHint(u info.User, uid uint32) info.User {
+	u.UIDHint = uid
+	return u
+}
+
+type mockIDToken struct {
+	claims map[string]interface{}
+}
+
+func (m *mockIDToken) Claims(v interface{}) error {
+	data, err := json.Marshal(m.claims)
+	if err != nil {
+		return fmt.Errorf("failed to marshal claims: %v", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to unmarshal claims: %v", err)
+	}
+
+	return nil
+}
@@ -3,7 +3,10 @@ package providers
 
 import (
 	"context"
+	"net/http"
 
+	providerErrors "github.com/canonical/authd/authd-oidc-brokers/internal/providers/errors"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/genericprovider"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
 	"github.com/coreos/go-oidc/v3/oidc"
@@ -40,6 +43,14 @@ type GroupFetcher interface {
 	) ([]info.Group, error)
 }
 
+// PictureFetcher is implemented by providers that can fetch the pictures of the users from the identity provider,
+// instead of downloading the one of the picture claim.
+type PictureFetcher interface {
+	// GetPicture returns the picture of the user of the token, or nil if they have none or if the token can't be
+	// used to fetch it.
+	GetPicture(ctx context.Context, client *http.Client, token *oauth2.Token, providerMetadata map[string]interface{}) ([]byte, error)
+}
+
 // MetadataProvider is implemented by providers that supply extra metadata or token fields.
 type MetadataProvider interface {
 	GetMetadata(provider *oidc.Provider) (map[string]interface{}, error)
@@ -69,6 +80,12 @@ type UserDisabledChecker interface {
 	IsUserDisabledError(err *oauth2.RetrieveError) bool
 }
 
+// InteractionRequiredChecker is implemented by providers that can detect, from
+// token refresh errors, that the user has to interact with the provider.
+type InteractionRequiredChecker interface {
+	RequiredInteraction(err *oauth2.RetrieveError) providerErrors.Interaction
+}
+
 // GraphClientSecretSetter is implemented by providers that can use the OIDC
 // app's client secret for app-only (client credentials) group lookup as a
 // fallback when the delegated token cannot be used against the Graph API.
@@ -76,6 +93,30 @@ type GraphClientSecretSetter interface {
 	SetGraphClientSecret(secret string)
 }
 
+// ClaimMappingSetter is implemented by providers that can read the information
+// of the users from claims of the ID token chosen in the configuration.
+type ClaimMappingSetter interface {
+	SetClaimMapping(m genericprovider.ClaimMapping)
+}
+
+// ClaimSourcesReader is implemented by providers which read the information of the users from the claims returned
+// by other endpoints of the identity provider, merged with the claims of the ID token.
+type ClaimSourcesReader interface {
+	// ReadsUserInfoEndpoint reports whether the claims of the UserInfo endpoint are read, e.g. because the identity
+	// provider only puts the base claims in the ID token.
+	ReadsUserInfoEndpoint() bool
+	// ReadsIntrospectionEndpoint reports whether the claims of the access token returned by the token introspection
+	// endpoint (RFC 7662) are read, e.g. because the identity provider only puts some claims in the access token.
+	ReadsIntrospectionEndpoint() bool
+}
+
+// TransportSetter is implemented by providers that send requests to the
+// identity provider with their own HTTP clients, which must use the transport
+// of the broker to go through its proxy and trust its CA certificates.
+type TransportSetter interface {
+	SetTransport(transport http.RoundTripper)
+}
+
 // Capability is an optional interface that allows a Provider to expose optional
 // interfaces dynamically, similar to errors.As. Composed or wrapped providers
 // should implement this to avoid combinatorial type-switch boilerplate.
@@ -0,0 +1,10 @@
+//go:build withkeycloak
+
+package providers
+
+import "github.com/canonical/authd/authd-oidc-brokers/internal/providers/keycloak"
+
+// CurrentProvider returns a Keycloak provider implementation.
+func CurrentProvider() Provider {
+	return keycloak.New()
+}
@@ -0,0 +1,10 @@
+//go:build withokta
+
+package providers
+
+import "github.com/canonical/authd/authd-oidc-brokers/internal/providers/okta"
+
+// CurrentProvider returns an Okta provider implementation.
+func CurrentProvider() Provider {
+	return okta.New()
+}
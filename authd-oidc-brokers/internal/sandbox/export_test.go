@@ -0,0 +1,6 @@
+package sandbox
+
+// LandlockABI returns the Landlock ABI version supported by the kernel.
+func LandlockABI() (int, error) {
+	return landlockABI()
+}
@@ -0,0 +1,159 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/canonical/authd/log"
+	"golang.org/x/sys/unix"
+)
+
+// landlockRuleNetPort is the LANDLOCK_RULE_NET_PORT rule type, which is not defined in x/sys/unix.
+const landlockRuleNetPort = 2
+
+// landlockNetPortAttr is struct landlock_net_port_attr, which is not defined in x/sys/unix.
+type landlockNetPortAttr struct {
+	allowedAccess uint64
+	port          uint64
+}
+
+// landlockFSAccess returns the filesystem accesses handled by the given Landlock ABI version. All of them are
+// denied, except the ones explicitly allowed by the rules.
+func landlockFSAccess(abi int) uint64 {
+	access := uint64(unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM)
+	if abi >= 2 {
+		access |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		access |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	if abi >= 5 {
+		access |= unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	}
+	return access
+}
+
+// landlockABI returns the Landlock ABI version supported by the kernel.
+func landlockABI() (int, error) {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		// ENOSYS and EOPNOTSUPP are returned if Landlock is not built in or disabled, EPERM if a seccomp
+		// filter (e.g. the snap confinement) denies it.
+		return 0, fmt.Errorf("%w: %v", errLandlockUnsupported, errno)
+	}
+	return int(abi), nil
+}
+
+func restrictLandlock(rules Rules) error {
+	abi, err := landlockABI()
+	if err != nil {
+		return err
+	}
+
+	attr := unix.LandlockRulesetAttr{Access_fs: landlockFSAccess(abi)}
+	if abi >= 4 {
+		attr.Access_net = unix.LANDLOCK_ACCESS_NET_BIND_TCP | unix.LANDLOCK_ACCESS_NET_CONNECT_TCP
+	} else {
+		log.Warningf(context.Background(), "Network access can't be restricted: Landlock ABI version %d is too old", abi)
+	}
+	if abi >= 6 {
+		attr.Scoped = unix.LANDLOCK_SCOPE_ABSTRACT_UNIX_SOCKET | unix.LANDLOCK_SCOPE_SIGNAL
+	}
+
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("could not create ruleset: %w", errno)
+	}
+	rulesetFd := int(fd)
+	defer unix.Close(rulesetFd)
+
+	for _, path := range rules.ReadOnlyPaths {
+		if err := addLandlockPathRule(rulesetFd, path); err != nil {
+			return err
+		}
+	}
+	if attr.Access_net != 0 {
+		for _, port := range rules.ConnectTCPPorts {
+			netAttr := landlockNetPortAttr{allowedAccess: unix.LANDLOCK_ACCESS_NET_CONNECT_TCP, port: uint64(port)}
+			_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(rulesetFd), landlockRuleNetPort,
+				uintptr(unsafe.Pointer(&netAttr)), 0, 0, 0)
+			if errno != 0 {
+				return fmt.Errorf("could not allow connecting to TCP port %d: %w", port, errno)
+			}
+		}
+	}
+
+	return restrictSelf(rulesetFd)
+}
+
+func addLandlockPathRule(rulesetFd int, path string) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not open %q: %w", path, err)
+	}
+	defer unix.Close(fd)
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return fmt.Errorf("could not stat %q: %w", path, err)
+	}
+	// Directory accesses can't be granted on files.
+	access := uint64(unix.LANDLOCK_ACCESS_FS_READ_FILE)
+	if st.Mode&unix.S_IFMT == unix.S_IFDIR {
+		access |= unix.LANDLOCK_ACCESS_FS_READ_DIR
+	}
+
+	pathAttr := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+	_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(rulesetFd), unix.LANDLOCK_RULE_PATH_BENEATH,
+		uintptr(unsafe.Pointer(&pathAttr)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("could not allow reading %q: %w", path, errno)
+	}
+	return nil
+}
+
+// restrictSelf enforces the ruleset on all the threads of the process, as the Go runtime schedules goroutines
+// on any of them.
+func restrictSelf(rulesetFd int) error {
+	_, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(rulesetFd), unix.LANDLOCK_RESTRICT_SELF_TSYNC, 0)
+	if errno == 0 {
+		return nil
+	}
+	if errno != unix.EINVAL {
+		return fmt.Errorf("could not enforce ruleset: %w", errno)
+	}
+
+	// Older kernels can't restrict all threads at once, so we restrict each of them ourselves. Each
+	// thread must set no_new_privs on its own for this to be allowed.
+	_, _, errno = syscall.AllThreadsSyscall(unix.SYS_PRCTL, unix.PR_SET_NO_NEW_PRIVS, 1, 0)
+	if errno == 0 {
+		_, _, errno = syscall.AllThreadsSyscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(rulesetFd), 0, 0)
+	}
+	if errno == syscall.ENOTSUP {
+		// AllThreadsSyscall is not available in binaries using cgo.
+		return fmt.Errorf("%w: the ruleset can't be enforced on all threads", errLandlockUnsupported)
+	}
+	if errno != 0 {
+		return fmt.Errorf("could not enforce ruleset: %w", errno)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// Package sandbox restricts the current process with Landlock and seccomp.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/canonical/authd/log"
+	"golang.org/x/sys/unix"
+)
+
+// Rules define what the process is still allowed to do once restricted.
+type Rules struct {
+	// ReadOnlyPaths are the files and directories that can be read. Paths which don't exist are ignored.
+	ReadOnlyPaths []string
+	// ConnectTCPPorts are the TCP ports that can be connected to. Landlock can't filter on the destination
+	// host, so any host can be reached on these ports.
+	ConnectTCPPorts []uint16
+}
+
+// errLandlockUnsupported is returned when the running kernel does not support Landlock or does not
+// allow us to use it.
+var errLandlockUnsupported = errors.New("landlock is not supported")
+
+// Restrict irreversibly restricts all the threads of the current process: it can only access the
+// filesystem and the network as allowed by rules, and the system calls which are not needed to
+// perform network requests (execve, ptrace, mount, bpf…) are denied.
+//
+// If the kernel does not support Landlock, only the seccomp filter is applied and a warning is logged.
+func Restrict(rules Rules) error {
+	// Landlock checks no_new_privs on the thread restricting itself.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// This is required to install a seccomp filter or a Landlock ruleset without CAP_SYS_ADMIN, and prevents
+	// the process from gaining privileges through setuid binaries. The seccomp filter below propagates it to
+	// all threads.
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("could not set no_new_privs: %w", err)
+	}
+
+	err := restrictLandlock(rules)
+	if errors.Is(err, errLandlockUnsupported) {
+		log.Warningf(context.Background(), "Filesystem and network access can't be restricted: %v", err)
+	} else if err != nil {
+		return fmt.Errorf("could not apply Landlock rules: %w", err)
+	}
+
+	if err := restrictSyscalls(); err != nil {
+		return fmt.Errorf("could not apply seccomp filter: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package sandbox_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/sandbox"
+	"github.com/canonical/authd/log"
+	"github.com/stretchr/testify/require"
+)
+
+// childActionEnv is set when the test binary is run as the restricted child, to the action it must perform.
+const childActionEnv = "AUTHD_TESTS_SANDBOX_CHILD_ACTION"
+
+func TestRestrict(t *testing.T) {
+	t.Parallel()
+
+	abi, err := sandbox.LandlockABI()
+	if err != nil {
+		t.Logf("Landlock is not supported, only checking the seccomp filter: %v", err)
+	}
+
+	allowedDir := t.TempDir()
+	allowedFile := filepath.Join(allowedDir, "allowed")
+	require.NoError(t, os.WriteFile(allowedFile, []byte("allowed"), 0600), "Setup: could not write allowed file")
+	deniedFile := filepath.Join(t.TempDir(), "denied")
+	require.NoError(t, os.WriteFile(deniedFile, []byte("denied"), 0600), "Setup: could not write denied file")
+
+	allowedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Setup: could not listen on allowed port")
+	t.Cleanup(func() { _ = allowedListener.Close() })
+	deniedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Setup: could not listen on denied port")
+	t.Cleanup(func() { _ = deniedListener.Close() })
+
+	tests := map[string]struct {
+		action     string
+		minABI     int
+		wantDenied bool
+	}{
+		"Reading_allowed_file_is_allowed":       {action: "read:" + allowedFile},
+		"Listing_allowed_dir_is_allowed":        {action: "list:" + allowedDir},
+		"Connecting_to_allowed_port_is_allowed": {action: "connect:" + allowedListener.Addr().String()},
+
+		"Executing_programs_is_denied":        {action: "exec:/bin/true", wantDenied: true},
+		"Reading_other_files_is_denied":       {action: "read:" + deniedFile, minABI: 1, wantDenied: true},
+		"Listing_other_dirs_is_denied":        {action: "list:" + filepath.Dir(deniedFile), minABI: 1, wantDenied: true},
+		"Writing_allowed_file_is_denied":      {action: "write:" + allowedFile, minABI: 1, wantDenied: true},
+		"Connecting_to_other_ports_is_denied": {action: "connect:" + deniedListener.Addr().String(), minABI: 4, wantDenied: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if abi < tc.minABI {
+				t.Skipf("Landlock ABI %d is required, kernel supports %d", tc.minABI, abi)
+			}
+
+			//nolint:gosec // We run the test binary itself.
+			cmd := exec.Command(os.Args[0], "-test.run=^$")
+			cmd.Env = append(os.Environ(),
+				childActionEnv+"="+tc.action,
+				"AUTHD_TESTS_SANDBOX_ALLOWED_DIR="+allowedDir,
+				"AUTHD_TESTS_SANDBOX_ALLOWED_PORT="+strconv.Itoa(allowedListener.Addr().(*net.TCPAddr).Port),
+			)
+			var stderr strings.Builder
+			cmd.Stderr = &stderr
+			out, err := cmd.Output()
+			require.NoError(t, err, "Child process failed: %s", stderr.String())
+
+			landlock, got, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+			if tc.minABI > 0 && landlock != "landlock: enforced" {
+				// This happens if the kernel can't restrict all threads at once and the test binary uses cgo.
+				t.Skipf("Landlock could not be enforced: %s", stderr.String())
+			}
+			if tc.wantDenied {
+				require.True(t, strings.HasPrefix(got, "denied:"), "Action %q should be denied, got %q", tc.action, got)
+				return
+			}
+			require.Equal(t, "allowed", got, "Action %q should be allowed", tc.action)
+		})
+	}
+}
+
+// runChild restricts the process and performs the requested action, printing whether it was allowed.
+func runChild(action string) error {
+	port, err := strconv.ParseUint(os.Getenv("AUTHD_TESTS_SANDBOX_ALLOWED_PORT"), 10, 16)
+	if err != nil {
+		return err
+	}
+	rules := sandbox.Rules{
+		ReadOnlyPaths:   []string{os.Getenv("AUTHD_TESTS_SANDBOX_ALLOWED_DIR"), "/does/not/exist"},
+		ConnectTCPPorts: []uint16{uint16(port)},
+	}
+	landlock := "enforced"
+	log.SetLevelHandler(log.WarnLevel, func(_ context.Context, _ log.Level, format string, args ...interface{}) {
+		landlock = "unavailable"
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	})
+	if err := sandbox.Restrict(rules); err != nil {
+		return err
+	}
+	fmt.Printf("landlock: %s\n", landlock)
+
+	kind, arg, _ := strings.Cut(action, ":")
+	switch kind {
+	case "read":
+		_, err = os.ReadFile(arg)
+	case "write":
+		err = os.WriteFile(arg, []byte("overwritten"), 0600)
+	case "list":
+		_, err = os.ReadDir(arg)
+	case "connect":
+		var conn net.Conn
+		conn, err = net.Dial("tcp", arg)
+		if err == nil {
+			_ = conn.Close()
+		}
+	case "exec":
+		err = exec.Command(arg).Run()
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+
+	if err != nil {
+		fmt.Printf("denied: %v\n", err)
+		return nil
+	}
+	fmt.Println("allowed")
+	return nil
+}
+
+func TestMain(m *testing.M) {
+	if action := os.Getenv(childActionEnv); action != "" {
+		if err := runChild(action); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	m.Run()
+}
@@ -0,0 +1,6 @@
+package secretstorage
+
+// SetSystemdCreds overrides the command sealing the secrets with the TPM2 chip.
+func SetSystemdCreds(path string) {
+	systemdCreds = path
+}
@@ -0,0 +1,95 @@
+package secretstorage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// keyringKeySize is the size of the AES-256 key stored in the kernel keyring.
+const keyringKeySize = 32
+
+// keyringPermissions allows the possessors of the key and its owner, root, to view, read, write, search, link and
+// set the attributes of the key, and nobody else to use it.
+const keyringPermissions = 0x3f3f0000
+
+// keyringBackend encrypts the secrets with AES-GCM, with a key only stored in the user keyring of root. The key is
+// lost on reboot, so the users have to authenticate with their provider again after each boot.
+type keyringBackend struct {
+	aead cipher.AEAD
+}
+
+func newKeyringBackend(dataDir string) (*keyringBackend, error) {
+	description := "authd-oidc-broker:" + dataDir
+	key, err := keyringKey(description)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &keyringBackend{aead: aead}, nil
+}
+
+// keyringKey returns the key with the description in the user keyring, creating it if it doesn't exist yet.
+func keyringKey(description string) ([]byte, error) {
+	id, err := unix.KeyctlSearch(unix.KEY_SPEC_USER_KEYRING, "user", description, 0)
+	if errors.Is(err, unix.ENOKEY) {
+		key := make([]byte, keyringKeySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("could not generate key: %w", err)
+		}
+		id, err = unix.AddKey("user", description, key, unix.KEY_SPEC_USER_KEYRING)
+		if err != nil {
+			return nil, fmt.Errorf("could not add key to the kernel keyring: %w", err)
+		}
+		if err := unix.KeyctlSetperm(id, keyringPermissions); err != nil {
+			return nil, fmt.Errorf("could not restrict the permissions of the key: %w", err)
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not search the kernel keyring: %w", err)
+	}
+
+	key := make([]byte, keyringKeySize)
+	n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, key, 0)
+	if err != nil {
+		return nil, fmt.Errorf("could not read key from the kernel keyring: %w", err)
+	}
+	if n != keyringKeySize {
+		return nil, fmt.Errorf("invalid key in the kernel keyring: %d bytes instead of %d", n, keyringKeySize)
+	}
+	return key, nil
+}
+
+func (b *keyringBackend) seal(name string, data []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("could not generate nonce: %w", err)
+	}
+	return b.aead.Seal(nonce, nonce, data, []byte(name)), nil
+}
+
+func (b *keyringBackend) unseal(name string, sealed []byte) ([]byte, error) {
+	if len(sealed) < b.aead.NonceSize() {
+		return nil, errors.New("sealed data is too short")
+	}
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	data, err := b.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		// The key changed, most likely because the machine rebooted, or the secret was sealed for another file.
+		return nil, errors.New("it was sealed for another file or with a key of the kernel keyring which is lost")
+	}
+	return data, nil
+}
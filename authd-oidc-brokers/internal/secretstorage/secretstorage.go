@@ -0,0 +1,165 @@
+// Package secretstorage seals the secrets cached by the broker on disk, like the tokens and the password hashes of
+// the users, so that they are unusable if the disk is cloned.
+package secretstorage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// The backends sealing the secrets.
+const (
+	// File stores the secrets in plaintext files, only readable by root.
+	File = "file"
+	// Keyring encrypts the secrets with a key which is only kept in the kernel keyring, so they can't be read
+	// anymore after a reboot.
+	Keyring = "keyring"
+	// TPM2 seals the secrets with the TPM2 chip of the machine, with systemd-creds.
+	TPM2 = "tpm2"
+)
+
+// Backends are the names of the supported backends.
+var Backends = []string{File, Keyring, TPM2}
+
+// sealedHeader starts the files sealed by a backend, followed by the name of the backend and a new line.
+const sealedHeader = "authd-sealed-v1:"
+
+type backend interface {
+	// seal returns data sealed, bound to the name of the secret.
+	seal(name string, data []byte) ([]byte, error)
+	// unseal returns the data sealed with the same name.
+	unseal(name string, sealed []byte) ([]byte, error)
+}
+
+// Storage reads and writes the secrets in files, sealed by its backend. A nil Storage stores them in plaintext.
+//
+// Each secret is bound to the path of its file relative to the data directory of the broker, so that the secret of a
+// user can't be passed off as the one of another user by moving it to their directory.
+type Storage struct {
+	name    string
+	backend backend
+	// dataDir is the directory which the paths of the secrets are relative to. The snap moves it on each refresh, so
+	// the secrets can't be bound to their absolute path.
+	dataDir string
+}
+
+// New returns the storage sealing the secrets with the backend name. dataDir is the data directory of the broker,
+// which identifies it, to separate its secrets from the ones of the other brokers. The File backend returns a nil
+// Storage.
+func New(name, dataDir string) (*Storage, error) {
+	var b backend
+	var err error
+	switch name {
+	case "", File:
+		return nil, nil
+	case Keyring:
+		b, err = newKeyringBackend(dataDir)
+	case TPM2:
+		b, err = newTPM2Backend()
+	default:
+		return nil, fmt.Errorf("unknown secret storage %q, must be one of %v", name, Backends)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not use %s secret storage: %w", name, err)
+	}
+	return &Storage{name: name, backend: b, dataDir: resolvePath(dataDir)}, nil
+}
+
+// IsValid returns true if name is a supported backend.
+func IsValid(name string) bool {
+	return slices.Contains(Backends, name)
+}
+
+// WriteFile seals data and writes it to the file path, readable only by its owner.
+func (s *Storage) WriteFile(path string, data []byte) error {
+	if s != nil {
+		sealed, err := s.backend.seal(s.secretName(path), data)
+		if err != nil {
+			return fmt.Errorf("could not seal %q: %w", path, err)
+		}
+		data = append([]byte(sealedHeader+s.name+"\n"), sealed...)
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// ReadFile reads the file path and unseals its content.
+//
+// The files written in plaintext, before a backend was configured, are returned as is: they are sealed on their next
+// write.
+func (s *Storage) ReadFile(path string) ([]byte, error) {
+	data, _, err := s.readFile(path, path)
+	return data, err
+}
+
+// Rebind seals again the file path, which was moved from previousPath, so that it's bound to its new path. The files
+// in plaintext are left as is.
+func (s *Storage) Rebind(path, previousPath string) error {
+	if s == nil {
+		return nil
+	}
+	data, sealed, err := s.readFile(path, previousPath)
+	if err != nil || !sealed {
+		return err
+	}
+	return s.WriteFile(path, data)
+}
+
+// readFile reads the file path and unseals its content, which was sealed for the file boundPath. It also returns
+// whether the content was sealed.
+func (s *Storage) readFile(path, boundPath string) (data []byte, sealed bool, err error) {
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rest, ok := bytes.CutPrefix(data, []byte(sealedHeader))
+	if !ok {
+		return data, false, nil
+	}
+	name, sealedData, ok := bytes.Cut(rest, []byte("\n"))
+	if !ok {
+		return nil, true, fmt.Errorf("invalid sealed file %q", path)
+	}
+	if s == nil || string(name) != s.name {
+		return nil, true, fmt.Errorf("%q was sealed with the %s secret storage, which is not configured", path, name)
+	}
+
+	data, err = s.backend.unseal(s.secretName(boundPath), sealedData)
+	if err != nil {
+		return nil, true, fmt.Errorf("could not unseal %q: %w", path, err)
+	}
+	return data, true, nil
+}
+
+// secretName returns the name which the secret of the file path is bound to: its path relative to the data directory
+// of the broker, once the symbolic links are resolved.
+func (s *Storage) secretName(path string) string {
+	path = resolvePath(path)
+	if rel, err := filepath.Rel(s.dataDir, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// resolvePath returns the absolute path of path with the symbolic links of its existing ancestors resolved. The parts
+// which don't exist are kept as is, as the secrets are also bound to the paths which they were moved from.
+func resolvePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	dir, rest := filepath.Dir(path), filepath.Base(path)
+	for {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
@@ -0,0 +1,272 @@
+package secretstorage_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/secretstorage"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSystemdCredsEnv makes the test binary behave as systemd-creds, sealing the data in plaintext.
+const fakeSystemdCredsEnv = "AUTHD_TESTS_FAKE_SYSTEMD_CREDS"
+
+func TestStorage(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		backend       string
+		readBackend   string
+		existingPlain bool
+
+		wantSealed  bool
+		wantNewErr  bool
+		wantReadErr bool
+	}{
+		"Store_the_secret_in_plaintext_with_the_file_backend": {backend: secretstorage.File},
+		"Store_the_secret_in_plaintext_by_default":            {},
+		"Seal_the_secret_with_the_keyring_backend":            {backend: secretstorage.Keyring, wantSealed: true},
+		"Seal_the_secret_with_the_tpm2_backend":               {backend: secretstorage.TPM2, wantSealed: true},
+		"Read_a_plaintext_secret_with_the_keyring_backend":    {backend: secretstorage.Keyring, existingPlain: true},
+
+		"Error_if_the_backend_is_unknown":                   {backend: "vault", wantNewErr: true},
+		"Error_if_a_sealed_secret_is_read_in_plaintext":     {backend: secretstorage.Keyring, readBackend: secretstorage.File, wantSealed: true, wantReadErr: true},
+		"Error_if_a_sealed_secret_is_read_by_other_backend": {backend: secretstorage.TPM2, readBackend: secretstorage.Keyring, wantSealed: true, wantReadErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// The ID of the broker separates the keys of the tests in the kernel keyring.
+			id := t.TempDir()
+			s, err := secretstorage.New(tc.backend, id)
+			if tc.wantNewErr {
+				require.Error(t, err, "New should have returned an error")
+				return
+			}
+			require.NoError(t, err, "New should not have returned an error")
+
+			secret := []byte("refresh token")
+			path := filepath.Join(t.TempDir(), "token.json")
+			if tc.existingPlain {
+				err = os.WriteFile(path, secret, 0600)
+			} else {
+				err = s.WriteFile(path, secret)
+			}
+			require.NoError(t, err, "WriteFile should not have returned an error")
+
+			onDisk, err := os.ReadFile(path)
+			require.NoError(t, err, "Setup: could not read the stored file")
+			require.Equal(t, tc.wantSealed, !bytes.Equal(secret, onDisk), "The secret should only be sealed by the backends")
+			fi, err := os.Stat(path)
+			require.NoError(t, err, "Setup: could not stat the stored file")
+			require.Equal(t, os.FileMode(0600), fi.Mode().Perm(), "The file should only be readable by its owner")
+
+			if tc.readBackend != "" {
+				s, err = secretstorage.New(tc.readBackend, id)
+				require.NoError(t, err, "New should not have returned an error")
+			}
+			got, err := s.ReadFile(path)
+			if tc.wantReadErr {
+				require.Error(t, err, "ReadFile should have returned an error")
+				return
+			}
+			require.NoError(t, err, "ReadFile should not have returned an error")
+			require.Equal(t, secret, got, "ReadFile should return the stored secret")
+		})
+	}
+}
+
+func TestKeyringKeyIsSharedByTheBrokerOnly(t *testing.T) {
+	t.Parallel()
+
+	id := t.TempDir()
+	s, err := secretstorage.New(secretstorage.Keyring, id)
+	require.NoError(t, err, "New should not have returned an error")
+	path := filepath.Join(t.TempDir(), "password")
+	err = s.WriteFile(path, []byte("hash"))
+	require.NoError(t, err, "WriteFile should not have returned an error")
+
+	same, err := secretstorage.New(secretstorage.Keyring, id)
+	require.NoError(t, err, "New should not have returned an error")
+	got, err := same.ReadFile(path)
+	require.NoError(t, err, "The secret should be readable by the same broker after a restart")
+	require.Equal(t, []byte("hash"), got, "ReadFile should return the stored secret")
+
+	other, err := secretstorage.New(secretstorage.Keyring, t.TempDir())
+	require.NoError(t, err, "New should not have returned an error")
+	_, err = other.ReadFile(path)
+	require.Error(t, err, "The secret should not be readable with the key of another broker")
+}
+
+func TestSecretsAreBoundToTheirPath(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		backend string
+		// move moves the secret of user1 in dataDir and returns its new path.
+		move func(t *testing.T, dataDir, path string) string
+		// rebind rebinds the moved secret to its new path.
+		rebind bool
+		// moveDataDir moves the whole data directory, like the refreshes of the snap do.
+		moveDataDir bool
+
+		wantReadErr bool
+	}{
+		"Read_the_secret_through_a_symbolic_link_to_its_directory": {
+			backend: secretstorage.Keyring,
+			move: func(t *testing.T, dataDir, path string) string {
+				link := filepath.Join(dataDir, "issuer", "user1-alias")
+				require.NoError(t, os.Symlink("user1", link), "Setup: could not create symlink")
+				return filepath.Join(link, filepath.Base(path))
+			},
+		},
+		"Read_the_secret_moved_to_another_directory_once_rebound": {
+			backend: secretstorage.Keyring,
+			move:    moveToUser2,
+			rebind:  true,
+		},
+		"Read_the_secret_of_a_moved_data_directory": {backend: secretstorage.TPM2, moveDataDir: true},
+
+		"Error_if_the_keyring_secret_of_a_user_is_moved_to_another_user": {backend: secretstorage.Keyring, move: moveToUser2, wantReadErr: true},
+		"Error_if_the_tpm2_secret_of_a_user_is_moved_to_another_user":    {backend: secretstorage.TPM2, move: moveToUser2, wantReadErr: true},
+		"Error_if_the_secret_is_rebound_from_another_path":               {backend: secretstorage.Keyring, move: moveToUser2, rebind: true, wantReadErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dataDir := t.TempDir()
+			s, err := secretstorage.New(tc.backend, dataDir)
+			require.NoError(t, err, "New should not have returned an error")
+
+			secret := []byte("refresh token of user1")
+			path := filepath.Join(dataDir, "issuer", "user1", "token.json")
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700), "Setup: could not create user directory")
+			require.NoError(t, s.WriteFile(path, secret), "WriteFile should not have returned an error")
+
+			if tc.moveDataDir {
+				newDataDir := filepath.Join(t.TempDir(), "new")
+				require.NoError(t, os.Rename(dataDir, newDataDir), "Setup: could not move data directory")
+				path = filepath.Join(newDataDir, "issuer", "user1", "token.json")
+				s, err = secretstorage.New(tc.backend, newDataDir)
+				require.NoError(t, err, "New should not have returned an error")
+			}
+			if tc.move != nil {
+				previousPath := path
+				path = tc.move(t, dataDir, path)
+				if tc.rebind {
+					if tc.wantReadErr {
+						// The secret of user1 is passed off as the one of user3 moved to user2.
+						previousPath = filepath.Join(dataDir, "issuer", "user3", "token.json")
+					}
+					err := s.Rebind(path, previousPath)
+					if tc.wantReadErr {
+						require.Error(t, err, "Rebind should have returned an error")
+						return
+					}
+					require.NoError(t, err, "Rebind should not have returned an error")
+				}
+			}
+
+			got, err := s.ReadFile(path)
+			if tc.wantReadErr {
+				require.Error(t, err, "ReadFile should have returned an error")
+				return
+			}
+			require.NoError(t, err, "ReadFile should not have returned an error")
+			require.Equal(t, secret, got, "ReadFile should return the stored secret")
+		})
+	}
+}
+
+// moveToUser2 moves the secret of user1 to the directory of user2 and returns its new path.
+func moveToUser2(t *testing.T, dataDir, path string) string {
+	t.Helper()
+
+	newPath := filepath.Join(dataDir, "issuer", "user2", filepath.Base(path))
+	require.NoError(t, os.MkdirAll(filepath.Dir(newPath), 0700), "Setup: could not create user directory")
+	require.NoError(t, os.Rename(path, newPath), "Setup: could not move secret")
+	return newPath
+}
+
+func TestRebindLeavesPlaintextSecretsAsIs(t *testing.T) {
+	t.Parallel()
+
+	s, err := secretstorage.New(secretstorage.Keyring, t.TempDir())
+	require.NoError(t, err, "New should not have returned an error")
+	path := filepath.Join(t.TempDir(), "token.json")
+	require.NoError(t, os.WriteFile(path, []byte("plaintext"), 0600), "Setup: could not write secret")
+
+	err = s.Rebind(path, filepath.Join(t.TempDir(), "token.json"))
+	require.NoError(t, err, "Rebind should not have returned an error")
+	got, err := os.ReadFile(path)
+	require.NoError(t, err, "Setup: could not read the stored file")
+	require.Equal(t, []byte("plaintext"), got, "Rebind should not seal the plaintext secrets")
+}
+
+func TestIsValid(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range secretstorage.Backends {
+		require.True(t, secretstorage.IsValid(name), "%q should be a valid backend", name)
+	}
+	require.False(t, secretstorage.IsValid("vault"), "An unknown backend should not be valid")
+}
+
+// fakeSystemdCreds seals the data by prefixing it with its name.
+func fakeSystemdCreds(args []string) error {
+	var name string
+	for _, arg := range args {
+		if n, ok := strings.CutPrefix(arg, "--name="); ok {
+			name = n
+		}
+	}
+	in, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	prefix := "sealed:" + name + ":"
+	switch args[0] {
+	case "has-tpm2":
+		return nil
+	case "encrypt":
+		_, err = os.Stdout.WriteString(prefix + string(in))
+		return err
+	case "decrypt":
+		data, ok := strings.CutPrefix(string(in), prefix)
+		if !ok {
+			return fmt.Errorf("credential %q was not sealed with this name", name)
+		}
+		_, err = os.Stdout.WriteString(data)
+		return err
+	}
+	return fmt.Errorf("unexpected command %q", args[0])
+}
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeSystemdCredsEnv) != "" {
+		if err := fakeSystemdCreds(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+	secretstorage.SetSystemdCreds(exe)
+	if err := os.Setenv(fakeSystemdCredsEnv, "1"); err != nil {
+		panic(err)
+	}
+
+	m.Run()
+}
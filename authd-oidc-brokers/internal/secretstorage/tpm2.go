@@ -0,0 +1,69 @@
+package secretstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// systemdCreds is the command sealing the secrets with the TPM2 chip.
+var systemdCreds = "systemd-creds"
+
+// tpm2Timeout is the maximum duration of a call to systemd-creds.
+const tpm2Timeout = 30 * time.Second
+
+// tpm2Backend seals the secrets with the TPM2 chip of the machine, through systemd-creds, so that they can only be
+// unsealed on this machine.
+type tpm2Backend struct{}
+
+func newTPM2Backend() (tpm2Backend, error) {
+	if _, err := exec.LookPath(systemdCreds); err != nil {
+		return tpm2Backend{}, err
+	}
+	// has-tpm2 exits with an error if the TPM2 chip, its driver or the firmware support is missing.
+	if _, err := runSystemdCreds(nil, "has-tpm2", "--quiet"); err != nil {
+		return tpm2Backend{}, fmt.Errorf("no usable TPM2 chip: %w", err)
+	}
+	return tpm2Backend{}, nil
+}
+
+func (tpm2Backend) seal(name string, data []byte) ([]byte, error) {
+	return runSystemdCreds(data, "encrypt", "--with-key=tpm2", "--name="+credentialName(name), "-", "-")
+}
+
+func (tpm2Backend) unseal(name string, sealed []byte) ([]byte, error) {
+	return runSystemdCreds(sealed, "decrypt", "--name="+credentialName(name), "-", "-")
+}
+
+// credentialName returns the name of the credential of the secret, which systemd-creds embeds in the sealed data and
+// checks when unsealing it. The names of the credentials can't contain slashes, unlike the ones of the secrets.
+func credentialName(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return "authd-" + hex.EncodeToString(sum[:])
+}
+
+// runSystemdCreds runs systemd-creds with stdin as input and returns its output.
+func runSystemdCreds(stdin []byte, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tpm2Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, systemdCreds, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return nil, fmt.Errorf("%s %s failed: %s", systemdCreds, args[0], strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("%s %s failed: %w", systemdCreds, args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
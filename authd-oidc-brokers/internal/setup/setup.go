@@ -0,0 +1,435 @@
+// Package setup implements the interactive configuration of the broker.
+package setup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/consts"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/fileutils"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/msentraid/himmelblau"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// discoveryTimeout is the maximum duration of the discovery of the OpenID Provider configuration.
+	discoveryTimeout = 10 * time.Second
+	// defaultLoginTimeout is the maximum duration of the test login.
+	defaultLoginTimeout = 5 * time.Minute
+
+	brokerConfTemplate = "templates/broker.conf.tmpl"
+)
+
+//go:embed templates/broker.conf.tmpl
+var templates embed.FS
+
+// ErrAborted is returned when the input ends before the configuration is complete.
+var ErrAborted = errors.New("setup aborted")
+
+type option struct {
+	skipTestLogin bool
+	loginTimeout  time.Duration
+}
+
+// Option is a func that allows to override some of the setup default settings.
+type Option func(*option)
+
+// WithoutTestLogin skips the test login with the identity provider, e.g. on machines without access to a browser.
+func WithoutTestLogin() Option {
+	return func(o *option) {
+		o.skipTestLogin = true
+	}
+}
+
+// WithLoginTimeout overrides the maximum duration of the test login.
+func WithLoginTimeout(timeout time.Duration) Option {
+	return func(o *option) {
+		o.loginTimeout = timeout
+	}
+}
+
+// brokerConf holds the settings written by the setup.
+type brokerConf struct {
+	Issuer               string
+	ClientID             string
+	ClientSecret         string
+	ExtraScopes          string
+	RegisterDevice       bool
+	DisableEntraPassword bool
+}
+
+type wizard struct {
+	lines    <-chan line
+	out      io.Writer
+	provider providers.Provider
+}
+
+// line is a line read from the input, or the error which stopped the reading.
+type line struct {
+	text string
+	err  error
+}
+
+// Run asks for the settings of the broker on in, checks them against the identity provider and writes the
+// configuration to cfgPath. It stops waiting for the answers when ctx is cancelled.
+func Run(ctx context.Context, cfgPath string, in io.Reader, out io.Writer, args ...Option) error {
+	opts := option{loginTimeout: defaultLoginTimeout}
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	w := wizard{lines: readLines(in, done), out: out, provider: providers.CurrentProvider()}
+	w.printf("This will configure the broker for %s in %s.\n\n", w.provider.DisplayName(), cfgPath)
+
+	var conf brokerConf
+	server, err := w.discover(ctx, &conf)
+	if err != nil {
+		return err
+	}
+
+	if conf.ClientID, err = w.askValue(ctx, "Client ID", true); err != nil {
+		return err
+	}
+	if conf.ClientSecret, err = w.askValue(ctx, "Client secret (leave empty if the client has none)", false); err != nil {
+		return err
+	}
+	if conf.ExtraScopes, err = w.askScopes(ctx, server); err != nil {
+		return err
+	}
+
+	if _, ok := providers.ProviderAs[himmelblau.EntraPasswordProvider](w.provider); ok && conf.ClientSecret == "" {
+		if conf.RegisterDevice, err = w.confirm(ctx, "Register the device with Microsoft Entra ID?", false); err != nil {
+			return err
+		}
+		conf.DisableEntraPassword = !conf.RegisterDevice
+		if conf.DisableEntraPassword {
+			w.printf("The entra_password flow needs a client secret or the registration of the device, it will be disabled.\n")
+		}
+	}
+
+	if !opts.skipTestLogin {
+		if err := w.testLogin(ctx, server, conf, opts.loginTimeout); err != nil {
+			return fmt.Errorf("test login failed, the configuration was not written: %w", err)
+		}
+	}
+
+	content, err := render(conf)
+	if err != nil {
+		return err
+	}
+	if err := broker.ValidateConfig(cfgPath, content); err != nil {
+		return fmt.Errorf("invalid configuration, it was not written: %w", err)
+	}
+
+	exists, err := fileutils.FileExists(cfgPath)
+	if err != nil {
+		return err
+	}
+	if exists {
+		overwrite, err := w.confirm(ctx, fmt.Sprintf("%s already exists. Overwrite it?", cfgPath), false)
+		if err != nil {
+			return err
+		}
+		if !overwrite {
+			return fmt.Errorf("%w: not overwriting %s", ErrAborted, cfgPath)
+		}
+	}
+
+	if err := writeFile(cfgPath, content); err != nil {
+		return err
+	}
+	w.printf("\nThe configuration was written to %s. Restart the broker to apply it.\n", cfgPath)
+
+	return nil
+}
+
+// discover asks for the issuer until the configuration of its OpenID Provider can be retrieved.
+func (w wizard) discover(ctx context.Context, conf *brokerConf) (*oidc.Provider, error) {
+	for {
+		issuer, err := w.askValue(ctx, "Issuer URL", true)
+		if err != nil {
+			return nil, err
+		}
+
+		u, err := url.Parse(issuer)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			w.printf("%q is not an HTTP(S) URL.\n", issuer)
+			continue
+		}
+		if u.Scheme != "https" {
+			w.printf("Warning: the issuer doesn't use HTTPS.\n")
+		}
+
+		discoveryCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+		server, err := oidc.NewProvider(discoveryCtx, issuer)
+		cancel()
+		if err != nil {
+			w.printf("Could not retrieve the OpenID Provider configuration of %s: %v\n", issuer, err)
+			continue
+		}
+		if server.Endpoint().DeviceAuthURL == "" {
+			w.printf("%s doesn't support the device authorization grant, which the broker requires.\n", issuer)
+			continue
+		}
+
+		w.printf("Found the OpenID Provider configuration of %s.\n", issuer)
+		conf.Issuer = issuer
+		return server, nil
+	}
+}
+
+// askScopes asks for the extra scopes, warning about the ones the OpenID Provider doesn't advertise.
+func (w wizard) askScopes(ctx context.Context, server *oidc.Provider) (string, error) {
+	var claims struct {
+		ScopesSupported []string `json:"scopes_supported"`
+	}
+	if err := server.Claims(&claims); err != nil {
+		return "", fmt.Errorf("could not read the OpenID Provider configuration: %v", err)
+	}
+
+	answer, err := w.askValue(ctx, "Extra scopes, separated by commas (leave empty for none)", false)
+	if err != nil {
+		return "", err
+	}
+
+	var scopes []string
+	for _, scope := range strings.Split(answer, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+		if len(claims.ScopesSupported) > 0 && !slices.Contains(claims.ScopesSupported, scope) {
+			w.printf("Warning: the scope %q is not supported by the identity provider.\n", scope)
+		}
+		scopes = append(scopes, scope)
+	}
+
+	return strings.Join(scopes, ","), nil
+}
+
+// testLogin authenticates a user with the device authorization grant, the same way the broker does.
+func (w wizard) testLogin(ctx context.Context, server *oidc.Provider, conf brokerConf, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	clientID := conf.ClientID
+	clientSecret := conf.ClientSecret
+	scopes := append(slices.Clone(consts.DefaultScopes), w.provider.AdditionalScopes()...)
+	if _, ok := providers.ProviderAs[providers.DeviceRegisterer](w.provider); ok && conf.RegisterDevice {
+		clientID = consts.MicrosoftBrokerAppID
+		clientSecret = ""
+		scopes = slices.Clone(consts.MicrosoftBrokerAppScopes)
+	}
+	if conf.ExtraScopes != "" {
+		scopes = append(scopes, strings.Split(conf.ExtraScopes, ",")...)
+	}
+
+	cfg := oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Endpoint:     server.Endpoint(),
+		Scopes:       scopes,
+	}
+
+	authOpts := w.provider.AuthOptions()
+	if clientSecret != "" {
+		authOpts = append(authOpts, oauth2.SetAuthURLParam("client_secret", clientSecret))
+	}
+
+	w.printf("\nLog in with a user of the identity provider to test the configuration.\n")
+	response, err := cfg.DeviceAuth(ctx, authOpts...)
+	if err != nil {
+		return fmt.Errorf("could not start the device authorization: %v", err)
+	}
+	if response.VerificationURIComplete != "" {
+		w.printf("Open %s and check that the code is %s.\n", response.VerificationURIComplete, response.UserCode)
+	} else {
+		w.printf("Open %s and enter the code %s.\n", response.VerificationURI, response.UserCode)
+	}
+
+	t, err := cfg.DeviceAccessToken(ctx, response, w.provider.AuthOptions()...)
+	if err != nil {
+		return fmt.Errorf("could not retrieve the token: %v", err)
+	}
+
+	rawIDToken, ok := t.Extra("id_token").(string)
+	if !ok {
+		return errors.New("the identity provider didn't return an ID token")
+	}
+	idToken, err := server.Verifier(&oidc.Config{ClientID: clientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return fmt.Errorf("could not verify the ID token: %v", err)
+	}
+
+	userInfo, err := w.provider.GetUserInfo(idToken, false)
+	if err != nil {
+		return fmt.Errorf("could not get the user info from the ID token: %v", err)
+	}
+	w.printf("Logged in as %s.\n", w.provider.NormalizeUsername(userInfo.Name))
+
+	return nil
+}
+
+// askValue asks for a value until a valid one is given.
+func (w wizard) askValue(ctx context.Context, question string, required bool) (string, error) {
+	for {
+		answer, err := w.ask(ctx, question)
+		if err != nil {
+			return "", err
+		}
+		if answer == "" && required {
+			w.printf("A value is required.\n")
+			continue
+		}
+		// The values are written as is in the configuration file, so they can't contain control characters.
+		if strings.ContainsFunc(answer, func(r rune) bool { return !unicode.IsGraphic(r) }) {
+			w.printf("The value contains invalid characters.\n")
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// confirm asks a yes or no question.
+func (w wizard) confirm(ctx context.Context, question string, defaultAnswer bool) (bool, error) {
+	choices := "[y/N]"
+	if defaultAnswer {
+		choices = "[Y/n]"
+	}
+
+	for {
+		answer, err := w.ask(ctx, question+" "+choices)
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return defaultAnswer, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		w.printf("Please answer yes or no.\n")
+	}
+}
+
+func (w wizard) ask(ctx context.Context, question string) (string, error) {
+	w.printf("%s: ", question)
+
+	var l line
+	var ok bool
+	select {
+	case <-ctx.Done():
+		w.printf("\n")
+		return "", ctx.Err()
+	case l, ok = <-w.lines:
+	}
+	if !ok || (errors.Is(l.err, io.EOF) && l.text == "") {
+		w.printf("\n")
+		return "", ErrAborted
+	}
+	if l.err != nil && !errors.Is(l.err, io.EOF) {
+		return "", fmt.Errorf("could not read the answer: %v", l.err)
+	}
+
+	return strings.TrimSpace(l.text), nil
+}
+
+// readLines reads the lines of in until done is closed. Reading from in can't be interrupted, so it's done in a
+// goroutine.
+func readLines(in io.Reader, done <-chan struct{}) <-chan line {
+	lines := make(chan line)
+
+	go func() {
+		defer close(lines)
+
+		r := bufio.NewReader(in)
+		for {
+			text, err := r.ReadString('\n')
+			select {
+			case lines <- line{text: text, err: err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return lines
+}
+
+func (w wizard) printf(format string, a ...any) {
+	_, _ = fmt.Fprintf(w.out, format, a...)
+}
+
+// render returns the content of the configuration file.
+func render(conf brokerConf) ([]byte, error) {
+	// A value containing a comment character has to be quoted, otherwise the rest of it is ignored.
+	funcs := template.FuncMap{"value": func(v string) string {
+		if strings.ContainsAny(v, "#;") && !strings.Contains(v, "`") {
+			return "`" + v + "`"
+		}
+		return v
+	}}
+
+	t, err := template.New(filepath.Base(brokerConfTemplate)).Funcs(funcs).ParseFS(templates, brokerConfTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open configuration template: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, conf); err != nil {
+		return nil, fmt.Errorf("failed to generate configuration: %v", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// writeFile replaces the configuration file atomically, so that the broker never reads a partial one.
+func writeFile(cfgPath string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(cfgPath), 0700); err != nil {
+		return fmt.Errorf("could not create the configuration directory: %v", err)
+	}
+
+	// The file is created with 0600 permissions, which the broker requires.
+	f, err := os.CreateTemp(filepath.Dir(cfgPath), filepath.Base(cfgPath)+".*")
+	if err != nil {
+		return fmt.Errorf("could not create the configuration file: %v", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("could not write the configuration file: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not write the configuration file: %v", err)
+	}
+
+	if err := os.Rename(f.Name(), cfgPath); err != nil {
+		return fmt.Errorf("could not write the configuration file: %v", err)
+	}
+
+	return nil
+}
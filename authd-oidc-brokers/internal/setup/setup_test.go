@@ -0,0 +1,149 @@
+package setup_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/setup"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/testutils"
+	"github.com/canonical/authd/internal/testutils/golden"
+	"github.com/canonical/authd/log"
+	"github.com/stretchr/testify/require"
+)
+
+var issuerURL string
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	noDeviceAuthIssuer, cleanup := testutils.StartMockProviderServer("", nil,
+		testutils.WithHandler("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+			testutils.OpenIDHandlerWithNoDeviceEndpoint("http://"+r.Host)(w, r)
+		}),
+	)
+	t.Cleanup(cleanup)
+
+	tests := map[string]struct {
+		input          []string
+		existingConfig string
+		invalidDropIn  bool
+		testLogin      bool
+
+		wantOutput  []string
+		wantErr     bool
+		wantAborted bool
+	}{
+		"Minimal_configuration": {
+			input: []string{issuerURL, "client-id", "", ""},
+		},
+		"Configuration_with_client_secret_and_extra_scopes": {
+			input:      []string{issuerURL, "client-id", "s3cr3t#!", "offline_access, groups"},
+			wantOutput: []string{"Warning: the issuer doesn't use HTTPS."},
+		},
+		"Configuration_after_successful_test_login": {
+			input:      []string{issuerURL, "test-client-id", "", ""},
+			testLogin:  true,
+			wantOutput: []string{"Open https://verification_uri.com and enter the code user_code.", "Logged in as test-user@email.com."},
+		},
+		"Ask_again_for_invalid_values": {
+			input: []string{"", "not-a-url", noDeviceAuthIssuer, issuerURL, "", "client-id", "", ""},
+			wantOutput: []string{
+				"A value is required.",
+				`"not-a-url" is not an HTTP(S) URL.`,
+				noDeviceAuthIssuer + " doesn't support the device authorization grant",
+			},
+		},
+		"Overwrite_existing_configuration_if_confirmed": {
+			input:          []string{issuerURL, "client-id", "", "", "y"},
+			existingConfig: "[oidc]\nissuer = https://old.example.com\n",
+		},
+
+		"Error_if_test_login_fails": {
+			input:     []string{issuerURL, "wrong-client-id", "", ""},
+			testLogin: true,
+			wantErr:   true,
+		},
+		"Error_if_drop_in_files_are_invalid": {
+			input:         []string{issuerURL, "client-id", "", ""},
+			invalidDropIn: true,
+			wantErr:       true,
+		},
+		"Error_if_input_ends_before_the_configuration_is_complete": {
+			input:       []string{issuerURL, "client-id"},
+			wantAborted: true,
+		},
+		"Error_if_overwriting_existing_configuration_is_not_confirmed": {
+			input:          []string{issuerURL, "client-id", "", "", ""},
+			existingConfig: "[oidc]\nissuer = https://old.example.com\n",
+			wantAborted:    true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfgPath := filepath.Join(t.TempDir(), "broker.conf")
+			if tc.existingConfig != "" {
+				err := os.WriteFile(cfgPath, []byte(tc.existingConfig), 0600)
+				require.NoError(t, err, "Setup: could not write existing configuration")
+			}
+			if tc.invalidDropIn {
+				err := os.MkdirAll(cfgPath+".d", 0700)
+				require.NoError(t, err, "Setup: could not create drop-in directory")
+				err = os.WriteFile(filepath.Join(cfgPath+".d", "10-invalid.conf"), []byte("[unknown]\nkey = value\n"), 0600)
+				require.NoError(t, err, "Setup: could not write drop-in file")
+			}
+
+			opts := []setup.Option{setup.WithoutTestLogin()}
+			if tc.testLogin {
+				opts = []setup.Option{setup.WithLoginTimeout(30 * time.Second)}
+			}
+
+			in := strings.NewReader(strings.Join(tc.input, "\n") + "\n")
+			var out strings.Builder
+			err := setup.Run(context.Background(), cfgPath, in, &out, opts...)
+			for _, want := range tc.wantOutput {
+				require.Contains(t, out.String(), want, "Output should contain the expected message")
+			}
+			if tc.wantAborted {
+				require.ErrorIs(t, err, setup.ErrAborted, "Run should return ErrAborted")
+			}
+			if tc.wantErr || tc.wantAborted {
+				require.Error(t, err, "Run should return an error, but did not")
+
+				got, readErr := os.ReadFile(cfgPath)
+				if tc.existingConfig == "" {
+					require.ErrorIs(t, readErr, os.ErrNotExist, "No configuration should have been written")
+					return
+				}
+				require.NoError(t, readErr, "Existing configuration should still be readable")
+				require.Equal(t, tc.existingConfig, string(got), "Existing configuration should not have been changed")
+				return
+			}
+			require.NoError(t, err, "Run should not return an error, but did")
+
+			fi, err := os.Stat(cfgPath)
+			require.NoError(t, err, "Configuration file should have been written")
+			require.Equal(t, os.FileMode(0600), fi.Mode().Perm(), "Configuration file should only be accessible by its owner")
+
+			got, err := os.ReadFile(cfgPath)
+			require.NoError(t, err, "Configuration file should be readable")
+			golden.CheckOrUpdate(t, strings.ReplaceAll(string(got), issuerURL, "ISSUER_URL"))
+		})
+	}
+}
+
+func TestMain(m *testing.M) {
+	log.SetLevel(log.DebugLevel)
+
+	var cleanup func()
+	issuerURL, cleanup = testutils.StartMockProviderServer("", nil)
+	defer cleanup()
+
+	m.Run()
+}
@@ -17,6 +17,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/canonical/authd/authd-oidc-brokers/internal/consts"
@@ -96,6 +97,23 @@ func WithHandler(path string, handler func(http.ResponseWriter, *http.Request))
 	}
 }
 
+// WithTransientErrors returns a ProviderServerOption that makes the first n requests to the endpoint specified by path
+// fail with a 503 Service Unavailable response, before they are handled by the handler of the endpoint.
+// It must be passed after the WithHandler option of the same endpoint, if any.
+func WithTransientErrors(path string, n int) ProviderServerOption {
+	return func(o *providerServerOption) {
+		handler := o.handlers[path]
+		var failed atomic.Int32
+		o.handlers[path] = func(w http.ResponseWriter, r *http.Request) {
+			if failed.Add(1) <= int32(n) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			handler(w, r)
+		}
+	}
+}
+
 // StartMockProviderServer starts a new HTTP server to be used as an OIDC provider for tests.
 func StartMockProviderServer(address string, tokenHandlerOpts *TokenHandlerOptions, args ...ProviderServerOption) (string, func()) {
 	servMux := http.NewServeMux()
@@ -346,6 +364,16 @@ func UnavailableHandler() EndpointHandler {
 	}
 }
 
+// CaptivePortalHandler returns a handler that answers like a captive portal, with the given status and the HTML page to
+// sign in to the network.
+func CaptivePortalHandler(status int) EndpointHandler {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("<html><body>Accept the terms of use to access the internet.</body></html>"))
+	}
+}
+
 // BadRequestHandler returns a handler that returns a 400 Bad Request response.
 func BadRequestHandler() EndpointHandler {
 	return func(w http.ResponseWriter, _ *http.Request) {
@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/secretstorage"
 	"golang.org/x/oauth2"
 )
 
@@ -27,6 +29,9 @@ type AuthCachedInfo struct {
 	// client_secret) for the liveness/revocation check, rather than via the OIDC
 	// app refresh used by device-auth tokens.
 	ObtainedViaEntraPasswordAuth bool
+	// LastInteractiveAuth is when the user last authenticated interactively with the provider, as opposed to a
+	// login with the local password during which the token was only refreshed.
+	LastInteractiveAuth time.Time
 }
 
 // NewAuthCachedInfo creates a new AuthCachedInfo. It sets the provided token and rawIDToken and the provider-specific
@@ -39,8 +44,27 @@ func NewAuthCachedInfo(token *oauth2.Token, rawIDToken string, extraFields map[s
 	}
 }
 
+type options struct {
+	storage *secretstorage.Storage
+}
+
+// Option is a function that modifies how the tokens are stored.
+type Option func(*options)
+
+// WithStorage seals the tokens with the storage instead of saving them in plaintext.
+func WithStorage(storage *secretstorage.Storage) Option {
+	return func(o *options) {
+		o.storage = storage
+	}
+}
+
 // CacheAuthInfo saves the token to the given path.
-func CacheAuthInfo(path string, token *AuthCachedInfo) (err error) {
+func CacheAuthInfo(path string, token *AuthCachedInfo, args ...Option) (err error) {
+	var opts options
+	for _, arg := range args {
+		arg(&opts)
+	}
+
 	jsonData, err := json.Marshal(token)
 	if err != nil {
 		return fmt.Errorf("could not marshal token: %v", err)
@@ -51,7 +75,7 @@ func CacheAuthInfo(path string, token *AuthCachedInfo) (err error) {
 		return fmt.Errorf("could not create token directory: %v", err)
 	}
 
-	if err = os.WriteFile(path, jsonData, 0600); err != nil {
+	if err = opts.storage.WriteFile(path, jsonData); err != nil {
 		return fmt.Errorf("could not save token: %v", err)
 	}
 
@@ -59,8 +83,13 @@ func CacheAuthInfo(path string, token *AuthCachedInfo) (err error) {
 }
 
 // LoadAuthInfo reads the token from the given path.
-func LoadAuthInfo(path string) (*AuthCachedInfo, error) {
-	jsonData, err := os.ReadFile(path)
+func LoadAuthInfo(path string, args ...Option) (*AuthCachedInfo, error) {
+	var opts options
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	jsonData, err := opts.storage.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("could not read token: %v", err)
 	}
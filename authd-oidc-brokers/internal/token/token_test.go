@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/secretstorage"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
@@ -89,18 +90,33 @@ func TestLoadAuthInfo(t *testing.T) {
 		expectedRet *token.AuthCachedInfo
 		fileExists  bool
 		invalidJSON bool
+		sealed      bool
+		readSealed  bool
 
 		wantError bool
 	}{
-		"Successfully_load_token_from_existing_file": {fileExists: true, expectedRet: testToken},
-		"Error_when_file_does_not_exist":             {wantError: true},
-		"Error_when_file_contains_invalid_JSON":      {fileExists: true, invalidJSON: true, wantError: true},
+		"Successfully_load_token_from_existing_file":        {fileExists: true, expectedRet: testToken},
+		"Successfully_load_token_sealed_with_the_storage":   {fileExists: true, sealed: true, readSealed: true, expectedRet: testToken},
+		"Successfully_load_plaintext_token_with_a_storage":  {fileExists: true, readSealed: true, expectedRet: testToken},
+		"Error_when_file_does_not_exist":                    {wantError: true},
+		"Error_when_file_contains_invalid_JSON":             {fileExists: true, invalidJSON: true, wantError: true},
+		"Error_when_sealed_token_is_loaded_without_storage": {fileExists: true, sealed: true, wantError: true},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
+			storage, err := secretstorage.New(secretstorage.Keyring, t.TempDir())
+			require.NoError(t, err, "Setup: could not open the secret storage")
+			var writeOpts, readOpts []token.Option
+			if tc.sealed {
+				writeOpts = append(writeOpts, token.WithStorage(storage))
+			}
+			if tc.readSealed {
+				readOpts = append(readOpts, token.WithStorage(storage))
+			}
+
 			tokenPath := filepath.Join(t.TempDir(), "parent", "token.json")
 			if tc.fileExists {
 				err := os.MkdirAll(filepath.Dir(tokenPath), 0700)
@@ -110,12 +126,12 @@ func TestLoadAuthInfo(t *testing.T) {
 					err = os.WriteFile(tokenPath, []byte("invalid json"), 0600)
 					require.NoError(t, err, "WriteFile should not return an error")
 				} else {
-					err = token.CacheAuthInfo(tokenPath, testToken)
+					err = token.CacheAuthInfo(tokenPath, testToken, writeOpts...)
 					require.NoError(t, err, "CacheAuthInfo should not return an error")
 				}
 			}
 
-			got, err := token.LoadAuthInfo(tokenPath)
+			got, err := token.LoadAuthInfo(tokenPath, readOpts...)
 			if tc.wantError {
 				require.Error(t, err, "LoadAuthInfo should return an error")
 				return
@@ -0,0 +1,6 @@
+package tokenrefresh
+
+// SetCommandArgs overrides the arguments passed to the child process.
+func SetCommandArgs(args ...string) {
+	commandArgs = args
+}
@@ -0,0 +1,284 @@
+// Package tokenrefresh refreshes OAuth2 tokens in a sandboxed child process.
+//
+// Refreshing a token means sending the refresh token to the identity provider and parsing its response. Doing it in
+// a child process which can only read the files needed for TLS and name resolution, and only connect to the ports of
+// the token endpoint, limits what an attacker can do if the response parsing is ever exploited.
+package tokenrefresh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/sandbox"
+	"golang.org/x/oauth2"
+)
+
+// Command is the name of the hidden broker command which runs the sandboxed token refresh.
+const Command = "refresh-token"
+
+// maxMessageSize bounds the size of the messages exchanged with the child process.
+const maxMessageSize = 1 << 20
+
+var (
+	// executable is the program run to refresh the token. It is overridden in tests.
+	executable = "/proc/self/exe"
+	// commandArgs are the arguments passed to executable. They are overridden in tests.
+	commandArgs = []string{Command}
+)
+
+// extraFields are the extra fields of the token response which are used by the broker and the providers, and thus
+// returned by the child process.
+var extraFields = []string{"id_token", "scope", "scp"}
+
+// readOnlyPaths are the paths the child process needs to read to verify the TLS certificates and to resolve names.
+var readOnlyPaths = []string{
+	"/etc/ssl",
+	"/etc/pki",
+	"/usr/lib/ssl",
+	"/usr/share/ca-certificates",
+	"/etc/resolv.conf",
+	"/run/systemd/resolve",
+	"/etc/hosts",
+	"/etc/nsswitch.conf",
+	"/etc/gai.conf",
+	"/etc/services",
+}
+
+// Request is the refresh request sent to the child process.
+type Request struct {
+	TokenURL     string           `json:"token_url"`
+	AuthStyle    oauth2.AuthStyle `json:"auth_style"`
+	ClientID     string           `json:"client_id"`
+	ClientSecret string           `json:"client_secret,omitempty"`
+	Scopes       []string         `json:"scopes,omitempty"`
+	RefreshToken string           `json:"refresh_token"`
+}
+
+// NewRequest returns the request to refresh refreshToken with cfg.
+func NewRequest(cfg oauth2.Config, refreshToken string) Request {
+	return Request{
+		TokenURL:     cfg.Endpoint.TokenURL,
+		AuthStyle:    cfg.Endpoint.AuthStyle,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Scopes:       cfg.Scopes,
+		RefreshToken: refreshToken,
+	}
+}
+
+// response is the result of the refresh, sent back by the child process.
+type response struct {
+	Token *oauth2.Token          `json:"token,omitempty"`
+	Extra map[string]interface{} `json:"extra,omitempty"`
+	Error *responseError         `json:"error,omitempty"`
+}
+
+// responseError carries enough information for the parent to classify the error like when refreshing in process.
+type responseError struct {
+	Message string `json:"message"`
+
+	// Set when the identity provider rejected the request.
+	Retrieve         bool   `json:"retrieve,omitempty"`
+	StatusCode       int    `json:"status_code,omitempty"`
+	Body             []byte `json:"body,omitempty"`
+	ErrorCode        string `json:"error_code,omitempty"`
+	ErrorDescription string `json:"error_description,omitempty"`
+	ErrorURI         string `json:"error_uri,omitempty"`
+
+	// Set when the identity provider could not be reached.
+	Network bool `json:"network,omitempty"`
+	Timeout bool `json:"timeout,omitempty"`
+}
+
+// networkError is a network error which happened in the child process.
+type networkError struct {
+	msg     string
+	timeout bool
+}
+
+func (e networkError) Error() string   { return e.msg }
+func (e networkError) Timeout() bool   { return e.timeout }
+func (e networkError) Temporary() bool { return false }
+
+func (e *responseError) toError() error {
+	if e.Retrieve {
+		return &oauth2.RetrieveError{
+			Response:         &http.Response{StatusCode: e.StatusCode, Status: fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))},
+			Body:             e.Body,
+			ErrorCode:        e.ErrorCode,
+			ErrorDescription: e.ErrorDescription,
+			ErrorURI:         e.ErrorURI,
+		}
+	}
+	if e.Network {
+		return networkError{msg: e.Message, timeout: e.Timeout}
+	}
+	return errors.New(e.Message)
+}
+
+func newResponseError(err error) *responseError {
+	e := &responseError{Message: err.Error()}
+
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		e.Retrieve = true
+		if retrieveErr.Response != nil {
+			e.StatusCode = retrieveErr.Response.StatusCode
+		}
+		e.Body = retrieveErr.Body
+		e.ErrorCode = retrieveErr.ErrorCode
+		e.ErrorDescription = retrieveErr.ErrorDescription
+		e.ErrorURI = retrieveErr.ErrorURI
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		e.Network = true
+		e.Timeout = netErr.Timeout()
+	}
+
+	return e
+}
+
+// Refresh refreshes the token in a sandboxed child process. Errors returned by the identity provider are returned as
+// *oauth2.RetrieveError and failures to reach it as net.Error, like when refreshing with an oauth2.TokenSource.
+func Refresh(ctx context.Context, req Request) (*oauth2.Token, error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal refresh request: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	// #nosec:G204 - we run ourselves or, in tests, the test binary.
+	cmd := exec.CommandContext(ctx, executable, commandArgs...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// Failing to refresh the token in time is a network error, as when refreshing in process.
+		if ctx.Err() != nil {
+			return nil, networkError{msg: fmt.Sprintf("token refresh interrupted: %v", ctx.Err()), timeout: true}
+		}
+		return nil, fmt.Errorf("token refresh process failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var resp response
+	if err := json.NewDecoder(io.LimitReader(&stdout, maxMessageSize)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("could not decode token refresh response: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, resp.Error.toError()
+	}
+	if resp.Token == nil {
+		return nil, errors.New("token refresh process did not return a token")
+	}
+
+	return resp.Token.WithExtra(resp.Extra), nil
+}
+
+// Serve is run in the child process. It reads the request from r, restricts the process, refreshes the token and
+// writes the result to w.
+func Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(r, maxMessageSize)).Decode(&req); err != nil {
+		return fmt.Errorf("could not decode refresh request: %w", err)
+	}
+
+	rules, err := sandboxRules(req.TokenURL)
+	if err != nil {
+		return err
+	}
+	if err := sandbox.Restrict(rules); err != nil {
+		return fmt.Errorf("could not restrict token refresh process: %w", err)
+	}
+
+	return json.NewEncoder(w).Encode(refresh(ctx, req))
+}
+
+func refresh(ctx context.Context, req Request) response {
+	cfg := oauth2.Config{
+		ClientID:     req.ClientID,
+		ClientSecret: req.ClientSecret,
+		Endpoint:     oauth2.Endpoint{TokenURL: req.TokenURL, AuthStyle: req.AuthStyle},
+		Scopes:       req.Scopes,
+	}
+	// Omit the access token, so that the token endpoint is always called.
+	t, err := cfg.TokenSource(ctx, &oauth2.Token{RefreshToken: req.RefreshToken}).Token()
+	if err != nil {
+		return response{Error: newResponseError(err)}
+	}
+
+	extra := make(map[string]interface{})
+	for _, field := range extraFields {
+		if v := t.Extra(field); v != nil {
+			extra[field] = v
+		}
+	}
+	return response{Token: t, Extra: extra}
+}
+
+// sandboxRules returns the rules allowing to reach the token endpoint, directly or through the configured proxy.
+func sandboxRules(tokenURL string) (sandbox.Rules, error) {
+	u, err := url.Parse(tokenURL)
+	if err != nil {
+		return sandbox.Rules{}, fmt.Errorf("invalid token URL %q: %w", tokenURL, err)
+	}
+
+	rules := sandbox.Rules{ReadOnlyPaths: readOnlyPaths}
+	for _, v := range []string{"SSL_CERT_FILE", "SSL_CERT_DIR"} {
+		if p := os.Getenv(v); p != "" {
+			rules.ReadOnlyPaths = append(rules.ReadOnlyPaths, strings.Split(p, ":")...)
+		}
+	}
+
+	port, err := urlPort(u)
+	if err != nil {
+		return sandbox.Rules{}, err
+	}
+	// DNS falls back to TCP for large responses.
+	rules.ConnectTCPPorts = []uint16{port, 53}
+
+	proxy, err := http.ProxyFromEnvironment(&http.Request{URL: u})
+	if err != nil {
+		return sandbox.Rules{}, fmt.Errorf("invalid proxy configuration: %w", err)
+	}
+	if proxy != nil {
+		port, err := urlPort(proxy)
+		if err != nil {
+			return sandbox.Rules{}, err
+		}
+		rules.ConnectTCPPorts = append(rules.ConnectTCPPorts, port)
+	}
+
+	return rules, nil
+}
+
+func urlPort(u *url.URL) (uint16, error) {
+	p := u.Port()
+	if p == "" {
+		switch u.Scheme {
+		case "https":
+			return 443, nil
+		case "http":
+			return 80, nil
+		}
+		return 0, fmt.Errorf("no port in URL %q", u.Redacted())
+	}
+
+	port, err := strconv.ParseUint(p, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port in URL %q: %w", u.Redacted(), err)
+	}
+	return uint16(port), nil
+}
@@ -0,0 +1,135 @@
+package tokenrefresh_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/tokenrefresh"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// childEnv is set when the test binary is run as the token refresh child process.
+const childEnv = "AUTHD_TESTS_TOKENREFRESH_CHILD"
+
+func TestRefresh(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		status   int
+		response string
+		tokenURL string
+
+		wantAccessToken  string
+		wantRefreshToken string
+		wantIDToken      string
+		wantErrorCode    string
+		wantNetErr       bool
+		wantErr          bool
+	}{
+		"Successfully_refresh_token": {
+			response:         `{"access_token":"new-access-token","refresh_token":"new-refresh-token","id_token":"new-id-token","token_type":"Bearer","expires_in":3600}`,
+			wantAccessToken:  "new-access-token",
+			wantRefreshToken: "new-refresh-token",
+			wantIDToken:      "new-id-token",
+		},
+		"Successfully_refresh_token_without_ID_token": {
+			response:         `{"access_token":"new-access-token","refresh_token":"new-refresh-token","token_type":"Bearer"}`,
+			wantAccessToken:  "new-access-token",
+			wantRefreshToken: "new-refresh-token",
+		},
+
+		"Error_when_provider_rejects_refresh_token": {
+			status:        http.StatusBadRequest,
+			response:      `{"error":"invalid_grant","error_description":"Token is not active"}`,
+			wantErrorCode: "invalid_grant",
+			wantErr:       true,
+		},
+		"Error_when_provider_is_unreachable": {tokenURL: "closed", wantNetErr: true, wantErr: true},
+		"Error_when_token_URL_is_invalid":    {tokenURL: "http://invalid:port/token", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "old-refresh-token" {
+					http.Error(w, fmt.Sprintf("unexpected request: %v", r.Form), http.StatusBadRequest)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				_, _ = w.Write([]byte(tc.response))
+			}))
+			t.Cleanup(srv.Close)
+
+			tokenURL := srv.URL + "/token"
+			switch tc.tokenURL {
+			case "":
+			case "closed":
+				srv.Close()
+			default:
+				tokenURL = tc.tokenURL
+			}
+
+			cfg := oauth2.Config{
+				ClientID: "client-id",
+				Endpoint: oauth2.Endpoint{TokenURL: tokenURL, AuthStyle: oauth2.AuthStyleInParams},
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			got, err := tokenrefresh.Refresh(ctx, tokenrefresh.NewRequest(cfg, "old-refresh-token"))
+			if tc.wantErr {
+				require.Error(t, err, "Refresh should have failed")
+
+				var retrieveErr *oauth2.RetrieveError
+				if tc.wantErrorCode != "" {
+					require.ErrorAs(t, err, &retrieveErr, "Refresh should return the error of the provider")
+					require.Equal(t, tc.wantErrorCode, retrieveErr.ErrorCode, "Unexpected error code")
+					require.Equal(t, tc.status, retrieveErr.Response.StatusCode, "Unexpected status code")
+				}
+				var netErr net.Error
+				require.Equal(t, tc.wantNetErr, errors.As(err, &netErr), "Refresh should return a network error only if the provider is unreachable")
+				return
+			}
+			require.NoError(t, err, "Refresh should not have failed")
+
+			require.Equal(t, tc.wantAccessToken, got.AccessToken, "Unexpected access token")
+			require.Equal(t, tc.wantRefreshToken, got.RefreshToken, "Unexpected refresh token")
+			idToken, _ := got.Extra("id_token").(string)
+			require.Equal(t, tc.wantIDToken, idToken, "Unexpected ID token")
+		})
+	}
+}
+
+func TestMain(m *testing.M) {
+	if os.Getenv(childEnv) != "" {
+		if err := tokenrefresh.Serve(context.Background(), os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Run the test binary itself as the child process, without running any test.
+	tokenrefresh.SetCommandArgs("-test.run=^$")
+	if err := os.Setenv(childEnv, "1"); err != nil {
+		panic(err)
+	}
+
+	m.Run()
+}
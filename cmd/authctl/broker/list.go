@@ -0,0 +1,74 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// listCmd is a command to list the brokers registered in authd.
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the brokers registered in authd",
+	Long: `List the brokers registered in authd, in the order they are offered to the users, with the version of the broker API they use, the optional features they support and the PAM services they are the default broker of.
+
+Use "authctl broker show" for the configuration of a broker and "authctl broker test" to check that it can reach its identity provider.
+
+The command must be run as root.`,
+	Example: `  # List the brokers
+  authctl broker list`,
+	Args:              cobra.NoArgs,
+	ValidArgsFunction: completion.NoArgs,
+	RunE:              runList,
+}
+
+func runList(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.ListBrokers(context.Background(), &authd.Empty{})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tAPI\tCAPABILITIES\tDEFAULT")
+	for _, b := range resp.GetBrokers() {
+		api := "-"
+		if b.GetApiVersion() != 0 {
+			api = fmt.Sprint(b.GetApiVersion())
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", b.GetName(), b.GetId(), api, capabilities(b), defaultFor(b))
+	}
+	return w.Flush()
+}
+
+// capabilities returns the optional features supported by the broker, or "-" if there is none.
+func capabilities(b *authd.BrokerDetails) string {
+	if len(b.GetCapabilities()) == 0 {
+		return "-"
+	}
+	return strings.Join(b.GetCapabilities(), ", ")
+}
+
+// defaultFor returns the PAM services which the broker is the default one of, or "-" if there is none.
+func defaultFor(b *authd.BrokerDetails) string {
+	var services []string
+	if b.GetDefault() {
+		services = append(services, "all services")
+	}
+	services = append(services, b.GetDefaultServices()...)
+	if len(services) == 0 {
+		return "-"
+	}
+	return strings.Join(services, ", ")
+}
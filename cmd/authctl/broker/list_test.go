@@ -0,0 +1,52 @@
+package broker_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/envutils"
+	"github.com/canonical/authd/internal/testutils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestListCommand(t *testing.T) {
+	daemonSocket := testutils.StartAuthd(t, daemonPath,
+		testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+		testutils.WithCurrentUserAsRoot,
+	)
+
+	authctlEnv := []string{
+		"AUTHD_SOCKET=" + daemonSocket,
+		testutils.CoverDirEnv(),
+	}
+
+	tests := map[string]struct {
+		args             []string
+		authdUnavailable bool
+
+		expectedExitCode int
+	}{
+		"List_brokers": {args: []string{"list"}, expectedExitCode: 0},
+
+		"Error_when_too_many_arguments":   {args: []string{"list", "ExampleBroker"}, expectedExitCode: 1},
+		"Error_when_authd_is_unavailable": {args: []string{"list"}, authdUnavailable: true, expectedExitCode: int(codes.Unavailable)},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			authctlEnv := append([]string{}, authctlEnv...)
+			if tc.authdUnavailable {
+				var err error
+				authctlEnv, err = envutils.Setenv(authctlEnv, "AUTHD_SOCKET", "/non-existent")
+				require.NoError(t, err, "Failed to set AUTHD_SOCKET environment variable")
+			}
+
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, append([]string{"broker"}, tc.args...)...)
+			cmd.Env = authctlEnv
+			testutils.CheckCommand(t, cmd, tc.expectedExitCode)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package broker
+
+import (
+	"context"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// setDefaultCmd is a command to select the broker of the users who never logged in.
+var setDefaultCmd = &cobra.Command{
+	Use:   "set-default <broker>",
+	Short: "Set the broker used by default by users who never logged in",
+	Long: `Set the broker selected for the users who never logged in on this machine, so that they are not asked
+to choose one. Users who already logged in keep using the broker they logged in with.
+
+The broker is identified by its name, as shown when selecting a broker, and must be registered in
+authd. With --service, the broker is only used by this PAM service, for example sshd, and takes
+precedence over the broker set for all the services.
+
+The command must be run as root.`,
+	Example: `  # Use the "Microsoft Entra ID" broker by default
+  authctl broker set-default "Microsoft Entra ID"
+
+  # Use the "Google" broker by default for SSH logins
+  authctl broker set-default --service sshd Google`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSetDefault,
+}
+
+var setDefaultService string
+
+func init() {
+	setDefaultCmd.Flags().StringVar(&setDefaultService, "service", "", "PAM service using the broker by default, instead of all the services")
+}
+
+func runSetDefault(cmd *cobra.Command, args []string) error {
+	broker := args[0]
+
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.SetDefaultBroker(context.Background(), &authd.SetDefaultBrokerRequest{
+		Broker:  broker,
+		Service: setDefaultService,
+	})
+	if err != nil {
+		return err
+	}
+
+	if setDefaultService == "" {
+		log.Infof("Broker %q is now used by default.", broker)
+	} else {
+		log.Infof("Broker %q is now used by default by %s.", broker, setDefaultService)
+	}
+	return nil
+}
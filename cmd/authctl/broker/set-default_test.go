@@ -0,0 +1,55 @@
+package broker_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/envutils"
+	"github.com/canonical/authd/internal/testutils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestSetDefaultCommand(t *testing.T) {
+	daemonSocket := testutils.StartAuthd(t, daemonPath,
+		testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+		testutils.WithCurrentUserAsRoot,
+	)
+
+	authctlEnv := []string{
+		"AUTHD_SOCKET=" + daemonSocket,
+		testutils.CoverDirEnv(),
+	}
+
+	tests := map[string]struct {
+		args             []string
+		authdUnavailable bool
+
+		expectedExitCode int
+	}{
+		"Set_default_broker":                     {args: []string{"set-default", "ExampleBroker"}, expectedExitCode: 0},
+		"Set_default_broker_of_a_service":        {args: []string{"set-default", "--service", "sshd", "ExampleBroker"}, expectedExitCode: 0},
+		"Set_default_broker_with_different_case": {args: []string{"set-default", "examplebroker"}, expectedExitCode: 0},
+		"Error_when_broker_is_not_registered":    {args: []string{"set-default", "DoesNotExist"}, expectedExitCode: int(codes.NotFound)},
+		"Error_when_broker_is_the_local_broker":  {args: []string{"set-default", "local"}, expectedExitCode: int(codes.InvalidArgument)},
+		"Error_when_broker_is_missing":           {args: []string{"set-default"}, expectedExitCode: 1},
+		"Error_when_authd_is_unavailable":        {args: []string{"set-default", "ExampleBroker"}, authdUnavailable: true, expectedExitCode: int(codes.Unavailable)},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			authctlEnv := append([]string{}, authctlEnv...)
+			if tc.authdUnavailable {
+				var err error
+				authctlEnv, err = envutils.Setenv(authctlEnv, "AUTHD_SOCKET", "/non-existent")
+				require.NoError(t, err, "Failed to set AUTHD_SOCKET environment variable")
+			}
+
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, append([]string{"broker"}, tc.args...)...)
+			cmd.Env = authctlEnv
+			testutils.CheckCommand(t, cmd, tc.expectedExitCode)
+		})
+	}
+}
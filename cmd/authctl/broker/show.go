@@ -0,0 +1,88 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// showCmd is a command to show the configuration of a broker.
+var showCmd = &cobra.Command{
+	Use:   "show <broker>",
+	Short: "Show the configuration of a broker",
+	Long: `Show the configuration of a broker registered in authd: where it was loaded from and where it answers on the bus, the version of the broker API it uses, the optional features it supports, the PAM services it is the default broker of and the attributes given to its users when their identity provider doesn't supply them.
+
+The broker is identified by its name or its ID, as shown by "authctl broker list".
+
+The optional features are:
+  verify_user     Checking online whether the account of a user is still enabled, see "authctl user verify".
+  session_tokens  Handing access tokens to the applications running in the sessions of the users, see "authctl token".
+  token_status    Reporting the status of the token cached for a user, see "authctl whoami".
+  token_refresh   Refreshing the token cached for a user on request of their desktop session.
+  session_hints   Adapting the authentication to the session of the user, like to their time zone.
+  provider_check  Checking the connection to the identity provider, see "authctl broker test".
+
+The command must be run as root.`,
+	Example: `  # Show the configuration of the "Google" broker
+  authctl broker show Google`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Brokers,
+	RunE:              runShow,
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	b, err := c.GetBrokerDetails(context.Background(), &authd.GetBrokerDetailsRequest{Broker: args[0]})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Name: %s\n", b.GetName())
+	fmt.Printf("ID: %s\n", b.GetId())
+	if b.GetConfigFile() != "" {
+		fmt.Printf("Configuration file: %s\n", b.GetConfigFile())
+	}
+	if b.GetDbusName() != "" {
+		fmt.Printf("D-Bus name: %s\n", b.GetDbusName())
+		fmt.Printf("D-Bus object: %s\n", b.GetDbusObject())
+	}
+	if b.GetApiVersion() != 0 {
+		fmt.Printf("API version: %d\n", b.GetApiVersion())
+	}
+	if b.GetBrandIcon() != "" {
+		fmt.Printf("Brand icon: %s\n", b.GetBrandIcon())
+	}
+	fmt.Printf("Capabilities: %s\n", capabilities(b))
+	fmt.Printf("Default broker of: %s\n", defaultFor(b))
+
+	// The users of the local broker are provided by another NSS service.
+	if b.GetConfigFile() == "" {
+		return nil
+	}
+	fmt.Println("\nDefaults of the users:")
+	shell := "the default shell of authd"
+	if b.GetDefaultShell() != "" {
+		shell = b.GetDefaultShell()
+	}
+	fmt.Printf("  Shell: %s\n", shell)
+	primaryGroup := "a private group named after the user"
+	if b.DefaultPrimaryGroupGid != nil {
+		primaryGroup = fmt.Sprintf("GID %d", b.GetDefaultPrimaryGroupGid())
+	}
+	fmt.Printf("  Primary group: %s\n", primaryGroup)
+	groups := "the default groups of authd"
+	if len(b.GetDefaultGroups()) > 0 {
+		groups = strings.Join(b.GetDefaultGroups(), ", ")
+	}
+	fmt.Printf("  Local groups: %s\n", groups)
+	return nil
+}
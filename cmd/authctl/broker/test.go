@@ -0,0 +1,62 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// testCmd is a command to check that a broker can reach its identity provider.
+var testCmd = &cobra.Command{
+	Use:   "test <broker>",
+	Short: "Check that a broker can reach its identity provider",
+	Long: `Check that a broker answers on the bus and that it can reach its identity provider, without any user logging in, to debug the setup of the broker.
+
+The broker checks its connection to its identity provider by fetching the discovery document of the provider and, if the device authentication flow is enabled, by requesting a device code which is never used. The broker must support the provider_check feature, as shown by "authctl broker show", otherwise only the bus is checked.
+
+Each check is ok, failed or skipped. The command fails if any check failed.
+
+The command must be run as root.`,
+	Example: `  # Check that the "Google" broker can reach Google
+  authctl broker test Google`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Brokers,
+	RunE:              runTest,
+}
+
+func runTest(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.CheckBroker(context.Background(), &authd.CheckBrokerRequest{Broker: args[0]})
+	if err != nil {
+		return err
+	}
+
+	var failed bool
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tSTATUS\tDETAILS")
+	for _, check := range resp.GetChecks() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", check.GetName(), check.GetStatus(), check.GetDetails())
+		if check.GetStatus() == "failed" {
+			failed = true
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed {
+		return errors.New("some checks of the broker failed")
+	}
+	return nil
+}
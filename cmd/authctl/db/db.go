@@ -0,0 +1,18 @@
+// Package db provides utilities for managing the authd database.
+package db
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// DBCmd is a command to perform operations on the authd database.
+var DBCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Commands related to the authd database",
+	Args:  cobra.NoArgs,
+	RunE:  func(cmd *cobra.Command, args []string) error { return cmd.Usage() },
+}
+
+func init() {
+	DBCmd.AddCommand(rollbackCmd)
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+const rollbackWarningMessage = `Warning: The changes made to the database since the upgrade of authd are kept,
+but the data which the previous version of authd does not support is removed.
+
+authd stops once the database is rolled back, as it can't use the database anymore. Install the previous
+version of authd right after rolling back the database. If the current version of authd is started again, it
+migrates the database again.`
+
+// rollbackCmd is a command to revert the schema migrations applied to the database by an upgrade of authd.
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Roll back the database to the schema of the previous version of authd",
+	Long: `Revert the schema migrations applied to the authd database since its last backup, which authd makes before
+migrating the database to a new schema version. This allows reinstalling the previous version of authd after a
+failed upgrade, keeping the UIDs and GIDs of the users and groups.
+
+` + rollbackWarningMessage + `
+
+Some migrations can't be reverted. In that case, stop authd and restore the backup of the database instead.
+The command must be run as root.`,
+	Example: `  # Roll back the database before reinstalling the previous version of authd
+  authctl db rollback
+
+  # Roll back the database without confirmation prompt
+  authctl db rollback --yes`,
+	Args: cobra.NoArgs,
+	RunE: runRollback,
+}
+
+var rollbackYes bool
+
+func init() {
+	rollbackCmd.Flags().BoolVarP(&rollbackYes, "yes", "y", false, "Skip confirmation prompt")
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	if !rollbackYes {
+		log.Warning(rollbackWarningMessage)
+		fmt.Fprint(os.Stderr, "\nAre you sure you want to roll back the database? [y/N] ")
+
+		reader := bufio.NewReader(os.Stdin)
+		answer, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || len(answer) == 0) {
+			return fmt.Errorf("failed to read confirmation: %w", err)
+		}
+		answer = strings.TrimSpace(strings.ToLower(answer))
+		if answer != "y" && answer != "yes" {
+			log.Info("Aborted.")
+			return nil
+		}
+	}
+
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.RollBackDatabase(context.Background(), &authd.Empty{})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("The database was rolled back from schema version %d to %d and authd stopped. Install the previous version of authd now.",
+		resp.GetFromVersion(), resp.GetToVersion())
+	return nil
+}
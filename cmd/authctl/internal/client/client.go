@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"sync"
 
 	"github.com/canonical/authd/internal/consts"
 	"github.com/canonical/authd/internal/proto/authd"
@@ -12,8 +13,18 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 )
 
-// NewUserServiceClient creates and returns a new [authd.UserServiceClient].
-func NewUserServiceClient() (authd.UserServiceClient, error) {
+// conn is shared by all the clients, so that the commands run in "authctl shell" reuse the same connection to the
+// daemon instead of connecting again for each of them.
+var conn = sync.OnceValues(func() (*grpc.ClientConn, error) {
+	c, err := grpc.NewClient(SocketAddress(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC client: %w", err)
+	}
+	return c, nil
+})
+
+// SocketAddress returns the address of the socket of the daemon which the clients connect to.
+func SocketAddress() string {
 	authdSocket := os.Getenv("AUTHD_SOCKET")
 	if authdSocket == "" {
 		authdSocket = "unix://" + consts.DefaultSocketPath
@@ -24,12 +35,16 @@ func NewUserServiceClient() (authd.UserServiceClient, error) {
 	if !schemeRegex.MatchString(authdSocket) {
 		authdSocket = "unix://" + authdSocket
 	}
+	return authdSocket
+}
 
-	conn, err := grpc.NewClient(authdSocket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// NewUserServiceClient creates and returns a new [authd.UserServiceClient].
+func NewUserServiceClient() (authd.UserServiceClient, error) {
+	c, err := conn()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create gRPC client: %w", err)
+		return nil, err
 	}
 
-	client := authd.NewUserServiceClient(conn)
+	client := authd.NewUserServiceClient(c)
 	return client, nil
 }
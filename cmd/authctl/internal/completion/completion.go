@@ -23,7 +23,7 @@ func Users(cmd *cobra.Command, args []string, toComplete string) ([]string, cobr
 	ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
 	defer cancel()
 
-	resp, err := svc.ListUsers(ctx, &authd.Empty{})
+	resp, err := svc.ListUsers(ctx, &authd.ListUsersRequest{})
 	if err != nil {
 		return showError(err)
 	}
@@ -46,7 +46,7 @@ func Groups(cmd *cobra.Command, args []string, toComplete string) ([]string, cob
 	ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
 	defer cancel()
 
-	resp, err := c.ListGroups(ctx, &authd.Empty{})
+	resp, err := c.ListGroups(ctx, &authd.ListGroupsRequest{})
 	if err != nil {
 		return showError(err)
 	}
@@ -59,6 +59,29 @@ func Groups(cmd *cobra.Command, args []string, toComplete string) ([]string, cob
 	return groupNames, cobra.ShellCompDirectiveNoFileComp
 }
 
+// Brokers returns the names of the brokers registered in authd for shell completion.
+func Brokers(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return showError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+	defer cancel()
+
+	resp, err := c.ListBrokers(ctx, &authd.Empty{})
+	if err != nil {
+		return showError(err)
+	}
+
+	var brokerNames []string
+	for _, b := range resp.GetBrokers() {
+		brokerNames = append(brokerNames, b.GetName())
+	}
+
+	return brokerNames, cobra.ShellCompDirectiveNoFileComp
+}
+
 // NoArgs returns no arguments and disables file completion.
 func NoArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	return nil, cobra.ShellCompDirectiveNoFileComp
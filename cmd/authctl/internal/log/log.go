@@ -7,6 +7,8 @@ import (
 	"sync"
 
 	"golang.org/x/term"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var useColor = sync.OnceValue(func() bool {
@@ -68,3 +70,29 @@ func Error(a ...any) {
 func Errorf(format string, args ...any) {
 	Error(fmt.Sprintf(format, args...))
 }
+
+// CommandError prints the error returned by a command to stderr and returns the exit code it maps to.
+func CommandError(err error) int {
+	s, ok := status.FromError(err)
+	if !ok {
+		// If the error is not a gRPC status, we print it as is.
+		Error(err.Error())
+		return 1
+	}
+
+	// If the error is a gRPC status, we print the message and exit with the gRPC status code.
+	switch s.Code() {
+	case codes.PermissionDenied:
+		Errorf("Permission denied: %s", s.Message())
+	default:
+		Errorf("Error: %s", s.Message())
+	}
+	code := int(s.Code())
+	if code < 0 || code > 255 {
+		// We cannot exit with a negative code or a code greater than 255,
+		// so we map it to 1 in that case.
+		code = 1
+	}
+
+	return code
+}
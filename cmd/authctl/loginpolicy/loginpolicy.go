@@ -0,0 +1,106 @@
+// Package loginpolicy provides the authctl command to show which users can log in on the machine.
+package loginpolicy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// LoginPolicyCmd is a command to show the login policy of the machine.
+var LoginPolicyCmd = &cobra.Command{
+	Use:   "login-policy",
+	Short: "Show which users can log in on this machine",
+	Long: `Show the login policy of this machine, configured with allowed_groups, denied_groups, lockdown,
+login_hours, offline_max_age and offline_max_logins in the authd configuration, or fetched from the
+central server set with policy_url.
+
+When groups are listed, only the members of these groups of the identity provider can log in, in
+addition to the checks of their broker. Otherwise, all the users granted access by their broker
+can log in. The members of the denied groups can't log in, even if they are members of one of the
+allowed groups.
+
+In lockdown, only the users who already logged in on this machine can log in again: no new user
+is provisioned.
+
+When login hours are listed, the users can only log in during these periods. When offline logins
+are limited, the users must have authenticated with their identity provider within that duration,
+or less than that number of offline logins ago, to log in offline.`,
+	Example: `  # Show the login policy of this machine
+  authctl login-policy`,
+	Args: cobra.NoArgs,
+	RunE: runLoginPolicy,
+}
+
+func runLoginPolicy(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.GetLoginPolicy(context.Background(), &authd.Empty{})
+	if err != nil {
+		return err
+	}
+
+	if central := resp.GetCentral(); central != nil {
+		printCentralPolicy(central)
+	}
+
+	if resp.GetLockdown() {
+		fmt.Println("Lockdown: only the users who already logged in on this machine can log in.")
+	}
+
+	if len(resp.GetAllowedGroups()) == 0 {
+		if !resp.GetLockdown() {
+			fmt.Println("All users granted access by their broker can log in.")
+		}
+	} else {
+		fmt.Println("Only the members of these groups can log in:")
+		for _, g := range resp.GetAllowedGroups() {
+			fmt.Printf("  %s\n", g)
+		}
+	}
+
+	if len(resp.GetDeniedGroups()) > 0 {
+		fmt.Println("The members of these groups can't log in:")
+		for _, g := range resp.GetDeniedGroups() {
+			fmt.Printf("  %s\n", g)
+		}
+	}
+
+	if len(resp.GetLoginHours()) > 0 {
+		fmt.Println("Users can only log in during these hours:")
+		for _, h := range resp.GetLoginHours() {
+			fmt.Printf("  %s\n", h)
+		}
+	}
+
+	if maxAge := resp.GetOfflineMaxAge(); maxAge > 0 {
+		fmt.Printf("Users must have authenticated online within %s to log in offline.\n", time.Duration(maxAge)*time.Second)
+	}
+	if maxLogins := resp.GetOfflineMaxLogins(); maxLogins > 0 {
+		fmt.Printf("Users can log in offline %d times since they last authenticated online.\n", maxLogins)
+	}
+
+	return nil
+}
+
+func printCentralPolicy(central *authd.CentralPolicy) {
+	if central.GetApplied() {
+		fmt.Printf("Central policy: serial %d from %s\n", central.GetSerial(), central.GetUrl())
+	} else {
+		fmt.Printf("Central policy: not fetched yet from %s, the local policy applies\n", central.GetUrl())
+	}
+	if fetchedAt := central.GetFetchedAt(); fetchedAt > 0 {
+		fmt.Printf("Last fetched: %s\n", time.Unix(fetchedAt, 0).Format(time.DateTime))
+	}
+	if err := central.GetError(); err != "" {
+		fmt.Printf("Last fetch failed: %s\n", err)
+	}
+	fmt.Println()
+}
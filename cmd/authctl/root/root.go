@@ -2,8 +2,16 @@
 package root
 
 import (
+	"github.com/canonical/authd/cmd/authctl/broker"
+	"github.com/canonical/authd/cmd/authctl/db"
 	"github.com/canonical/authd/cmd/authctl/group"
+	"github.com/canonical/authd/cmd/authctl/loginpolicy"
+	"github.com/canonical/authd/cmd/authctl/securityreport"
+	"github.com/canonical/authd/cmd/authctl/shell"
+	"github.com/canonical/authd/cmd/authctl/status"
+	"github.com/canonical/authd/cmd/authctl/token"
 	"github.com/canonical/authd/cmd/authctl/user"
+	"github.com/canonical/authd/cmd/authctl/whoami"
 	"github.com/spf13/cobra"
 )
 
@@ -35,4 +43,12 @@ func init() {
 
 	RootCmd.AddCommand(user.UserCmd)
 	RootCmd.AddCommand(group.GroupCmd)
+	RootCmd.AddCommand(broker.BrokerCmd)
+	RootCmd.AddCommand(securityreport.SecurityReportCmd)
+	RootCmd.AddCommand(loginpolicy.LoginPolicyCmd)
+	RootCmd.AddCommand(status.StatusCmd)
+	RootCmd.AddCommand(token.TokenCmd)
+	RootCmd.AddCommand(whoami.WhoamiCmd)
+	RootCmd.AddCommand(db.DBCmd)
+	RootCmd.AddCommand(shell.ShellCmd)
 }
@@ -0,0 +1,59 @@
+// Package securityreport provides the authctl command to evaluate the security of the authd deployment.
+package securityreport
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// SecurityReportCmd is a command to evaluate the authd deployment against security best practices.
+var SecurityReportCmd = &cobra.Command{
+	Use:   "security-report",
+	Short: "Evaluate the security of the authd deployment",
+	Long: `Evaluate the authd deployment against security best practices and print a scored report.
+
+Each check either passes, emits a warning, fails or is skipped when it does not apply.
+The score is the percentage of the maximum score, where passed checks count fully and
+checks with warnings count half. Skipped checks are not taken into account.
+
+This command requires root privileges.`,
+	Example: `  # Show the security report of the authd deployment
+  authctl security-report`,
+	Args: cobra.NoArgs,
+	RunE: runSecurityReport,
+}
+
+func runSecurityReport(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.GetSecurityReport(context.Background(), &authd.Empty{})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tCHECK\tDETAILS")
+	for _, check := range resp.GetChecks() {
+		details := check.GetDetails()
+		if details == "" {
+			details = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", strings.ToUpper(check.GetStatus()), check.GetDescription(), details)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nScore: %d/100\n", resp.GetScore())
+	return nil
+}
@@ -0,0 +1,200 @@
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+const activeHelpPrefix = "_activeHelp_ "
+
+// word is a word of the command line, with its quotes and escapes removed.
+type word struct {
+	value string
+	// start is the offset in the command line of the first character of the word.
+	start int
+}
+
+// splitWords splits the command line into words like a POSIX shell, without expansions. It returns whether the last
+// word goes on until the end of the line, and whether a quote is left open.
+func splitWords(line string) (words []word, trailing, open bool) {
+	var cur strings.Builder
+	var quote rune
+	inWord, escaped := false, false
+	start := 0
+
+	for i, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+			continue
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' {
+				escaped = true
+			} else {
+				cur.WriteRune(r)
+			}
+			continue
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word{value: cur.String(), start: start})
+				cur.Reset()
+				inWord = false
+			}
+			continue
+		}
+
+		if !inWord {
+			inWord, start = true, i
+		}
+		switch r {
+		case '\'', '"':
+			quote = r
+		case '\\':
+			escaped = true
+		default:
+			cur.WriteRune(r)
+		}
+	}
+
+	if inWord {
+		words = append(words, word{value: cur.String(), start: start})
+	}
+	return words, inWord, quote != 0 || escaped
+}
+
+// splitArgs splits the command line into arguments.
+func splitArgs(line string) ([]string, error) {
+	words, _, open := splitWords(line)
+	if open {
+		return nil, errors.New("unterminated quote or escape in the command line")
+	}
+
+	var args []string
+	for _, w := range words {
+		args = append(args, w.value)
+	}
+	return args, nil
+}
+
+// quoteWord quotes the word if needed, so that it's split as a single argument.
+func quoteWord(w string) string {
+	if w != "" && !strings.ContainsFunc(w, func(r rune) bool { return unicode.IsSpace(r) || strings.ContainsRune(`'"\#`, r) }) {
+		return w
+	}
+	return strconv.Quote(w)
+}
+
+// autoComplete completes the word before the cursor when Tab is pressed. It completes the word entirely if it has a
+// single completion, or up to the longest prefix of its completions and lists them otherwise.
+func (s *shell) autoComplete(line string, pos int, key rune) (newLine string, newPos int, ok bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	head, tail := line[:pos], line[pos:]
+	words, trailing, _ := splitWords(head)
+	partial := word{start: len(head)}
+	if trailing {
+		partial, words = words[len(words)-1], words[:len(words)-1]
+	}
+
+	var args []string
+	for _, w := range words {
+		args = append(args, w.value)
+	}
+	if len(s.scope) == 0 && len(args) > 0 && args[0] == s.root.Name() {
+		args = args[1:]
+	}
+
+	completions, noSpace, help := s.complete(slices.Concat(s.scope, args), partial.value)
+	if len(completions) == 0 {
+		if len(help) > 0 {
+			s.printList(help)
+		}
+		return line, pos, true
+	}
+
+	var completed string
+	if len(completions) == 1 {
+		completed = quoteWord(completions[0])
+		if !noSpace {
+			completed += " "
+		}
+	} else {
+		prefix := commonPrefix(completions)
+		if prefix == partial.value || quoteWord(prefix) != prefix {
+			s.printList(completions)
+			return line, pos, true
+		}
+		completed = prefix
+	}
+
+	newHead := head[:partial.start] + completed
+	return newHead + tail, len(newHead), true
+}
+
+// complete returns the completions of toComplete after the arguments, using the completion of cobra, whether no space
+// should be added after the completion and the help messages of the completion.
+func (s *shell) complete(args []string, toComplete string) (completions []string, noSpace bool, help []string) {
+	var out bytes.Buffer
+	s.root.SetOut(&out)
+	s.root.SetErr(io.Discard)
+	defer func() {
+		s.root.SetOut(nil)
+		s.root.SetErr(nil)
+	}()
+
+	resetCommands(s.root)
+	s.root.SetArgs(slices.Concat([]string{cobra.ShellCompRequestCmd}, args, []string{toComplete}))
+	if err := s.root.Execute(); err != nil {
+		return nil, false, nil
+	}
+
+	for _, line := range strings.Split(out.String(), "\n") {
+		if d, found := strings.CutPrefix(line, ":"); found {
+			directive, err := strconv.Atoi(d)
+			if err == nil {
+				noSpace = cobra.ShellCompDirective(directive)&cobra.ShellCompDirectiveNoSpace != 0
+			}
+			break
+		}
+		if msg, found := strings.CutPrefix(line, activeHelpPrefix); found {
+			help = append(help, msg)
+			continue
+		}
+
+		// The completions can be followed by their description.
+		value, _, _ := strings.Cut(line, "\t")
+		if value != "" && strings.HasPrefix(value, toComplete) {
+			completions = append(completions, value)
+		}
+	}
+
+	return completions, noSpace, help
+}
+
+// printList prints the items above the prompt.
+func (s *shell) printList(items []string) {
+	//nolint:errcheck // The list is only informative.
+	s.term.Write([]byte(strings.Join(items, "  ") + "\n"))
+}
+
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,99 @@
+package shell_test
+
+import (
+	"testing"
+
+	"github.com/canonical/authd/cmd/authctl/root"
+	"github.com/canonical/authd/cmd/authctl/shell"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		line string
+
+		want    []string
+		wantErr bool
+	}{
+		"Empty_line":                 {line: "", want: nil},
+		"Only_spaces":                {line: "  \t ", want: nil},
+		"Words_separated_by_spaces":  {line: " user  lock\talice ", want: []string{"user", "lock", "alice"}},
+		"Double_quoted_word":         {line: `user link alice "Microsoft Entra ID"`, want: []string{"user", "link", "alice", "Microsoft Entra ID"}},
+		"Single_quoted_word":         {line: `user link alice 'Microsoft "Entra" ID'`, want: []string{"user", "link", "alice", `Microsoft "Entra" ID`}},
+		"Quotes_inside_word":         {line: `a"b c"d`, want: []string{"ab cd"}},
+		"Escaped_space":              {line: `Microsoft\ Entra\ ID`, want: []string{"Microsoft Entra ID"}},
+		"Escaped_quote_in_quotes":    {line: `"a \" b"`, want: []string{`a " b`}},
+		"Backslash_in_single_quotes": {line: `'a\b'`, want: []string{`a\b`}},
+		"Empty_quoted_word":          {line: `user ""`, want: []string{"user", ""}},
+
+		"Error_on_unterminated_double_quote": {line: `user lock "alice`, wantErr: true},
+		"Error_on_unterminated_single_quote": {line: `user lock 'alice`, wantErr: true},
+		"Error_on_trailing_backslash":        {line: `user lock alice\`, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := shell.SplitArgs(tc.line)
+			if tc.wantErr {
+				require.Error(t, err, "SplitArgs should have failed")
+				return
+			}
+			require.NoError(t, err, "SplitArgs should not have failed")
+			require.Equal(t, tc.want, got, "SplitArgs returned unexpected arguments")
+		})
+	}
+}
+
+func TestComplete(t *testing.T) {
+	tests := map[string]struct {
+		scope []string
+		line  string
+
+		wantLine   string
+		wantListed string
+	}{
+		"Complete_command":                {line: "us", wantLine: "user "},
+		"Complete_subcommand":             {line: "user unlo", wantLine: "user unlock "},
+		"Complete_flag":                   {line: "user delete --pur", wantLine: "user delete --purge-home "},
+		"Complete_after_authctl_prefix":   {line: "authctl gro", wantLine: "authctl group "},
+		"Complete_in_entered_command":     {scope: []string{"user"}, line: "unlo", wantLine: "unlock "},
+		"Complete_up_to_common_prefix":    {line: "user set-s", wantLine: "user set-shell "},
+		"Complete_common_prefix_of_many":  {line: "user unl", wantLine: "user unl", wantListed: "unlock  unlink"},
+		"List_completions_without_prefix": {line: "user set-", wantLine: "user set-", wantListed: "set-uid  set-shell  set-home"},
+
+		"No_completion_of_unknown_command": {line: "invalid-command ", wantLine: "invalid-command "},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The completion runs the root command, which can't be run concurrently.
+			gotLine, gotListed := shell.Complete(root.RootCmd, tc.scope, tc.line)
+			require.Equal(t, tc.wantLine, gotLine, "Complete returned an unexpected line")
+			require.Equal(t, tc.wantListed, gotListed, "Complete listed unexpected completions")
+		})
+	}
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir() + "/authctl/history"
+
+	h := shell.NewHistory(path)
+	require.Zero(t, h.Len(), "History should be empty when the file does not exist")
+
+	h.Add("user lock alice")
+	h.Add("user lock alice")
+	h.Add("user unlock alice")
+	require.Equal(t, 2, h.Len(), "Consecutive duplicates should be recorded once")
+	require.Equal(t, "user unlock alice", h.At(0), "The most recent entry should be first")
+	require.Equal(t, "user lock alice", h.At(1), "The oldest entry should be last")
+
+	h = shell.NewHistory(path)
+	require.Equal(t, 2, h.Len(), "History should be loaded from the file")
+	require.Equal(t, "user unlock alice", h.At(0), "The most recent entry should be first after loading")
+}
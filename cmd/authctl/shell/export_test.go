@@ -0,0 +1,32 @@
+package shell
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// SplitArgs exposes splitArgs for tests.
+var SplitArgs = splitArgs
+
+// Complete completes the line as if Tab was pressed at its end, in the scope of the commands entered. It returns the
+// new line and the items listed above the prompt, if any.
+func Complete(root *cobra.Command, scope []string, line string) (newLine, listed string) {
+	var out bytes.Buffer
+	s := &shell{root: root, scope: scope}
+	s.term = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader(""), &out}, "")
+
+	newLine, _, _ = s.autoComplete(line, len(line), '\t')
+	return newLine, strings.TrimSpace(out.String())
+}
+
+// NewHistory returns the history stored in the file.
+func NewHistory(path string) term.History {
+	return loadHistory(path)
+}
@@ -0,0 +1,90 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxHistory = 1000
+
+// history is the history of the commands typed in the shell, stored in a file to be available in the next sessions.
+type history struct {
+	path string
+	// entries are ordered from the oldest to the most recent.
+	entries []string
+}
+
+// historyPath returns the path of the history file, following the XDG base directory specification.
+func historyPath() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "authctl", "history"), nil
+}
+
+// loadHistory returns the history stored in the file, which is empty if the file doesn't exist or can't be read.
+func loadHistory(path string) *history {
+	h := &history{path: path}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return h
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	h.trim()
+
+	return h
+}
+
+// Add adds the entry to the history and stores it. The history is only a convenience, so failing to store it isn't
+// reported.
+func (h *history) Add(entry string) {
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	h.trim()
+
+	//nolint:errcheck // See above.
+	h.save()
+}
+
+// Len returns the number of entries in the history.
+func (h *history) Len() int {
+	return len(h.entries)
+}
+
+// At returns the entry at the index, 0 being the most recent one.
+func (h *history) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
+
+func (h *history) trim() {
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+}
+
+func (h *history) save() error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return err
+	}
+
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(h.entries, "\n")+"\n"), 0600); err != nil {
+		//nolint:errcheck // The file may not have been created.
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
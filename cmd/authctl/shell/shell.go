@@ -0,0 +1,229 @@
+// Package shell provides the authctl command running the other commands interactively.
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+// ShellCmd is a command to run authctl commands interactively.
+var ShellCmd = &cobra.Command{
+	Use:   shellCommand,
+	Short: "Run authctl commands interactively",
+	Long: `Start an interactive shell which runs authctl commands, with completion of the
+commands, users and groups, and a history of the commands kept across sessions.
+The connection to authd is kept open between the commands.
+
+The commands are typed without the "authctl" prefix. Typing a command which has
+subcommands, like "user", enters it, so that its subcommands can be typed
+directly, and ".." leaves it. "help" shows the help of the commands, and "exit",
+"quit" or Ctrl+D leave the shell.
+
+When the standard input is not a terminal, the commands are read from it, one per
+line. Lines starting with "#" are ignored. Commands asking for confirmation must
+be run with "--yes" in that case.
+
+The history is stored in $XDG_STATE_HOME/authctl/history, or
+~/.local/state/authctl/history if XDG_STATE_HOME is not set.`,
+	Example: `  # Start the interactive shell
+  authctl shell
+
+  # Lock several users in a row
+  printf 'user lock alice\nuser lock bob\n' | authctl shell`,
+	Args: cobra.NoArgs,
+	RunE: runShell,
+}
+
+const (
+	shellCommand = "shell"
+	leaveCommand = ".."
+)
+
+type shell struct {
+	root *cobra.Command
+	// scope is the path of the command the user entered, from the root command excluded.
+	scope []string
+	term  *term.Terminal
+}
+
+func runShell(cmd *cobra.Command, args []string) error {
+	s := &shell{root: cmd.Root()}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return s.runScript(os.Stdin)
+	}
+
+	s.term = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, s.prompt())
+	s.term.AutoCompleteCallback = s.autoComplete
+	if path, err := historyPath(); err == nil {
+		s.term.History = loadHistory(path)
+	}
+
+	fmt.Printf("Type \"help\" for the list of commands, \"exit\" or Ctrl+D to leave.\n")
+	for {
+		line, err := s.readLine(fd)
+		if errors.Is(err, io.EOF) {
+			fmt.Println()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if s.run(line) {
+			return nil
+		}
+		s.term.SetPrompt(s.prompt())
+	}
+}
+
+// readLine reads a line from the terminal in raw mode. The terminal is restored afterwards, so that the commands
+// print their output and read their confirmations as usual.
+func (s *shell) readLine(fd int) (string, error) {
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", fmt.Errorf("failed to set up the terminal: %w", err)
+	}
+	//nolint:errcheck // There is nothing we can do if the terminal can't be restored.
+	defer term.Restore(fd, state)
+
+	if width, height, err := term.GetSize(fd); err == nil && width > 0 {
+		//nolint:errcheck // The terminal keeps its previous size, which only affects the display.
+		s.term.SetSize(width, height)
+	}
+
+	line, err := s.term.ReadLine()
+	if errors.Is(err, term.ErrPasteIndicator) {
+		// The line was pasted, which is fine.
+		err = nil
+	}
+	return line, err
+}
+
+// runScript runs the commands read from r, one per line.
+func (s *shell) runScript(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if s.run(scanner.Text()) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *shell) prompt() string {
+	return strings.Join(append([]string{s.root.Name()}, s.scope...), " ") + "> "
+}
+
+// run runs the command line and returns true if the user asked to leave the shell.
+func (s *shell) run(line string) (exit bool) {
+	words, err := splitArgs(line)
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+	if len(words) == 0 || strings.HasPrefix(words[0], "#") {
+		return false
+	}
+
+	switch words[0] {
+	case "exit", "quit":
+		return true
+	case leaveCommand:
+		if len(s.scope) > 0 {
+			s.scope = s.scope[:len(s.scope)-1]
+		}
+		return false
+	}
+
+	// Allow pasting the examples of the documentation.
+	if len(s.scope) == 0 && words[0] == s.root.Name() {
+		words = words[1:]
+		if len(words) == 0 {
+			return false
+		}
+	}
+
+	args := s.args(words)
+	if args[0] == shellCommand {
+		log.Error("Already running the authctl shell.")
+		return false
+	}
+
+	if cmd, rest, err := s.root.Find(args); err == nil && cmd != s.root && len(rest) == 0 &&
+		cmd.HasAvailableSubCommands() && !slices.ContainsFunc(words, isFlag) {
+		s.scope = commandPath(cmd)
+		return false
+	}
+
+	resetCommands(s.root)
+	s.root.SetArgs(args)
+	if _, err := s.root.ExecuteC(); err != nil {
+		log.CommandError(err)
+	}
+
+	return false
+}
+
+// args returns the arguments of the root command running the words typed in the current scope.
+func (s *shell) args(words []string) []string {
+	if words[0] == "help" {
+		// The help command is only a subcommand of the root command.
+		return slices.Concat(words[:1], s.scope, words[1:])
+	}
+	return slices.Concat(s.scope, words)
+}
+
+// commandPath returns the names of the commands leading to cmd, from the root command excluded.
+func commandPath(cmd *cobra.Command) []string {
+	var path []string
+	for c := cmd; c.HasParent(); c = c.Parent() {
+		path = append([]string{c.Name()}, path...)
+	}
+	return path
+}
+
+func isFlag(word string) bool {
+	return strings.HasPrefix(word, "-")
+}
+
+// resetCommands resets the flags of the commands to their default values, as they keep the values they were set to
+// when the previous command ran.
+func resetCommands(cmd *cobra.Command) {
+	cmd.SilenceUsage = false
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		if v, ok := f.Value.(pflag.SliceValue); ok {
+			var def []string
+			if d := strings.Trim(f.DefValue, "[]"); d != "" {
+				def = strings.Split(d, ",")
+			}
+			//nolint:errcheck // The default value was valid when the flag was defined.
+			v.Replace(def)
+		} else {
+			//nolint:errcheck // The default value was valid when the flag was defined.
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
+
+	for _, c := range cmd.Commands() {
+		resetCommands(c)
+	}
+}
@@ -0,0 +1,79 @@
+package shell_test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/internal/testutils"
+)
+
+var authctlPath string
+var daemonPath string
+
+func TestShellCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input string
+	}{
+		"Run_commands_in_sequence":              {input: "user lock user1@example.com\nuser lifecycle user1@example.com\nuser unlock user1@example.com\n"},
+		"Run_subcommands_of_entered_command":    {input: "user\nlock user1@example.com\nunlock user1@example.com\n..\nuser lifecycle user1@example.com\n"},
+		"Show_help_of_subcommand":               {input: "user\nhelp lock\n"},
+		"Reset_flags_between_commands":          {input: "user lock --help\nuser lock invaliduser\n"},
+		"Ignore_comments_and_authctl_prefix":    {input: "# Lock the user\nauthctl user lock user1@example.com\n"},
+		"Accept_quoted_arguments":               {input: "user lock 'user1@example.com'\nuser unlock \"user1@example.com\"\n"},
+		"Stop_reading_commands_on_exit":         {input: "user lock user1@example.com\nexit\nuser lock invaliduser\n"},
+		"Leaving_without_entered_command_is_ok": {input: "..\nuser lock user1@example.com\n"},
+
+		"Error_on_invalid_command_continues": {input: "invalid-command\nuser lock invaliduser\nuser lock user1@example.com\n"},
+		"Error_on_unterminated_quote":        {input: "user lock \"user1@example.com\nuser lock user1@example.com\n"},
+		"Error_on_running_shell_in_shell":    {input: "shell\n"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			daemonSocket := testutils.StartAuthd(t, daemonPath,
+				testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+				testutils.WithPreviousDBState("one_user_and_group"),
+				testutils.WithCurrentUserAsRoot,
+			)
+
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, "shell")
+			cmd.Env = []string{
+				"AUTHD_SOCKET=" + daemonSocket,
+				"XDG_STATE_HOME=" + t.TempDir(),
+				testutils.CoverDirEnv(),
+			}
+			cmd.Stdin = strings.NewReader(tc.input)
+			testutils.CheckCommand(t, cmd, 0)
+		})
+	}
+}
+
+func TestMain(m *testing.M) {
+	var authctlCleanup func()
+	var err error
+	authctlPath, authctlCleanup, err = testutils.BuildAuthctl()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Setup: %v\n", err)
+		os.Exit(1)
+	}
+	defer authctlCleanup()
+
+	var daemonCleanup func()
+	daemonPath, daemonCleanup, err = testutils.BuildAuthdWithExampleBroker()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Setup: %v\n", err)
+		os.Exit(1)
+	}
+	defer daemonCleanup()
+
+	m.Run()
+}
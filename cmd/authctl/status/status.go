@@ -0,0 +1,251 @@
+// Package status provides the authctl command to show the state of the authd daemon.
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var statusJSON bool
+
+// StatusCmd is a command to show the state of the authd daemon.
+var StatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the state of the authd daemon",
+	Long: `Show the version and uptime of the authd daemon, whether it answers on its socket, the state of its database, whether its brokers answer and when they last got a new token for a user.
+
+The last login with the identity provider of a broker is when it last got a new token for one of its users. If it is old while users keep logging in, the broker can't reach the identity provider and the users log in with the information it cached.
+
+Attach the output of this command to support requests.
+
+This command requires root privileges.`,
+	Example: `  # Show the state of the authd daemon
+  authctl status
+
+  # Show the state of the authd daemon in JSON
+  authctl status --json`,
+	Args: cobra.NoArgs,
+	RunE: runStatus,
+}
+
+func init() {
+	StatusCmd.Flags().BoolVar(&statusJSON, "json", false, "Print the state in JSON")
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	socket := client.SocketAddress()
+	resp, err := c.GetStatus(context.Background(), &authd.Empty{})
+	if status.Code(err) == codes.Unavailable {
+		// The daemon doesn't answer, which is the first thing to report.
+		if statusJSON {
+			if err := printJSON(socket, nil, err); err != nil {
+				return err
+			}
+		} else {
+			fmt.Printf("Socket: %s (not answering)\n", socket)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if statusJSON {
+		return printJSON(socket, resp, nil)
+	}
+
+	started := time.Unix(resp.GetStartTime(), 0)
+	fmt.Printf("Version: %s\n", resp.GetVersion())
+	fmt.Printf("Started: %s (up %s)\n", started.Format(time.DateTime), time.Since(started).Round(time.Second))
+	fmt.Printf("Socket: %s (answering)\n", socket)
+
+	db := resp.GetDatabase()
+	fmt.Printf("\nDatabase: %s\n", db.GetPath())
+	fmt.Printf("  Schema version: %d\n", db.GetSchemaVersion())
+	fmt.Printf("  Size: %s\n", formatSize(db.GetSize()))
+	fmt.Printf("  Users: %d (%d locked, %d authenticating for the first time)\n",
+		db.GetUsers(), db.GetLockedUsers(), db.GetPendingUsers())
+	fmt.Printf("  Groups: %d\n", db.GetGroups())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if len(db.GetOperations()) > 0 {
+		fmt.Println("\nDatabase operations:")
+		fmt.Fprintf(w, "  OPERATION\tCOUNT\tAVERAGE\tSLOWER THAN %s\n", slowOperation)
+		for _, op := range db.GetOperations() {
+			fmt.Fprintf(w, "  %s\t%d\t%s\t%d\n", op.GetOperation(), op.GetCount(), average(op).Round(time.Microsecond), slowerThan(op, slowOperation))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("\nBrokers:")
+	fmt.Fprintln(w, "  NAME\tID\tAPI\tSESSIONS\tLAST LOGIN WITH THE PROVIDER\tSTATUS")
+	for _, b := range resp.GetBrokers() {
+		api := "-"
+		if b.GetApiVersion() != 0 {
+			api = fmt.Sprint(b.GetApiVersion())
+		}
+		lastLogin := "-"
+		if b.GetLastOnlineLogin() != 0 {
+			lastLogin = time.Unix(b.GetLastOnlineLogin(), 0).Format(time.DateTime)
+		}
+		health := "ok"
+		if b.GetError() != "" {
+			health = "not answering: " + b.GetError()
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%d\t%s\t%s\n", b.GetName(), b.GetId(), api, b.GetPendingSessions(), lastLogin, health)
+	}
+	return w.Flush()
+}
+
+type socketJSON struct {
+	Address   string `json:"address"`
+	Answering bool   `json:"answering"`
+	Error     string `json:"error,omitempty"`
+}
+
+type operationJSON struct {
+	Operation      string  `json:"operation"`
+	Count          uint64  `json:"count"`
+	AverageSeconds float64 `json:"average_seconds"`
+	// Slow is the number of operations slower than slowOperation.
+	Slow uint64 `json:"slow"`
+}
+
+type databaseJSON struct {
+	Path          string          `json:"path"`
+	Size          int64           `json:"size"`
+	SchemaVersion uint32          `json:"schema_version"`
+	Users         uint32          `json:"users"`
+	LockedUsers   uint32          `json:"locked_users"`
+	PendingUsers  uint32          `json:"pending_users"`
+	Groups        uint32          `json:"groups"`
+	Operations    []operationJSON `json:"operations"`
+}
+
+type brokerJSON struct {
+	Name            string     `json:"name"`
+	ID              string     `json:"id"`
+	APIVersion      uint32     `json:"api_version,omitempty"`
+	PendingSessions uint32     `json:"pending_sessions"`
+	LastOnlineLogin *time.Time `json:"last_online_login,omitempty"`
+	Answering       bool       `json:"answering"`
+	Error           string     `json:"error,omitempty"`
+}
+
+type daemonJSON struct {
+	Version   string        `json:"version,omitempty"`
+	StartTime *time.Time    `json:"start_time,omitempty"`
+	Socket    socketJSON    `json:"socket"`
+	Database  *databaseJSON `json:"database,omitempty"`
+	Brokers   []brokerJSON  `json:"brokers,omitempty"`
+}
+
+// printJSON prints the state of the daemon, or only the one of its socket if it doesn't answer with err.
+func printJSON(socket string, resp *authd.DaemonStatus, err error) error {
+	s := daemonJSON{Socket: socketJSON{Address: socket, Answering: err == nil}}
+	if err != nil {
+		s.Socket.Error = status.Convert(err).Message()
+	}
+
+	if resp != nil {
+		s.Version = resp.GetVersion()
+		s.StartTime = unixTime(resp.GetStartTime())
+
+		db := resp.GetDatabase()
+		s.Database = &databaseJSON{
+			Path:          db.GetPath(),
+			Size:          db.GetSize(),
+			SchemaVersion: db.GetSchemaVersion(),
+			Users:         db.GetUsers(),
+			LockedUsers:   db.GetLockedUsers(),
+			PendingUsers:  db.GetPendingUsers(),
+			Groups:        db.GetGroups(),
+			Operations:    []operationJSON{},
+		}
+		for _, op := range db.GetOperations() {
+			s.Database.Operations = append(s.Database.Operations, operationJSON{
+				Operation:      op.GetOperation(),
+				Count:          op.GetCount(),
+				AverageSeconds: average(op).Seconds(),
+				Slow:           slowerThan(op, slowOperation),
+			})
+		}
+
+		s.Brokers = []brokerJSON{}
+		for _, b := range resp.GetBrokers() {
+			bs := brokerJSON{
+				Name:            b.GetName(),
+				ID:              b.GetId(),
+				APIVersion:      b.GetApiVersion(),
+				PendingSessions: b.GetPendingSessions(),
+				Answering:       b.GetError() == "",
+				Error:           b.GetError(),
+			}
+			if b.GetLastOnlineLogin() != 0 {
+				bs.LastOnlineLogin = unixTime(b.GetLastOnlineLogin())
+			}
+			s.Brokers = append(s.Brokers, bs)
+		}
+	}
+
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal the status: %w", err)
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func unixTime(sec int64) *time.Time {
+	t := time.Unix(sec, 0).UTC()
+	return &t
+}
+
+// slowOperation is the duration above which the database operations are counted as slow in the output.
+const slowOperation = 100 * time.Millisecond
+
+// average returns the average duration of the operation.
+func average(op *authd.OperationLatency) time.Duration {
+	return time.Duration(op.GetSumSeconds() / float64(op.GetCount()) * float64(time.Second))
+}
+
+// slowerThan returns the number of operations which took longer than the upper bound of the bucket d.
+func slowerThan(op *authd.OperationLatency, d time.Duration) uint64 {
+	for _, b := range op.GetBuckets() {
+		if b.GetUpperBoundSeconds() >= d.Seconds() {
+			return op.GetCount() - b.GetCount()
+		}
+	}
+	return 0
+}
+
+// formatSize returns the size in bytes in a human readable form.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
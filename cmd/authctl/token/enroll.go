@@ -0,0 +1,59 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+var enrollValidity time.Duration
+
+// enrollCmd is a command to enroll a machine token for a user.
+var enrollCmd = &cobra.Command{
+	Use:   "enroll <user>",
+	Short: "Enroll a machine token with which automation logs in as a user",
+	Long: `Generate a new machine token for a user and print it, so that cron jobs, CI runners and other automation on
+the host can log in as the user without an interactive prompt of their broker.
+
+The token is only accepted in the login sessions of a user who already logged in with their broker, and grants access
+with the information stored on that login. A previously enrolled token is replaced. It never expires unless a validity
+is given. The token can't be printed again, store it where the automation can read it. The command must be run as root.`,
+	Example: `  # Enroll a machine token for ci-runner@example.com
+  authctl token enroll ci-runner@example.com
+
+  # Enroll a machine token which expires in 30 days
+  authctl token enroll --validity 720h ci-runner@example.com`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Users,
+	RunE:              runEnroll,
+}
+
+func init() {
+	enrollCmd.Flags().DurationVar(&enrollValidity, "validity", 0, "Duration after which the token expires, e.g. 720h (never expires if 0)")
+}
+
+func runEnroll(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	t, err := c.EnrollMachineToken(context.Background(), &authd.EnrollMachineTokenRequest{
+		Name:     args[0],
+		Validity: int64(enrollValidity.Seconds()),
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(t.GetToken())
+	if t.GetExpiresAt() != 0 {
+		fmt.Fprintf(cmd.ErrOrStderr(), "The token expires on %s\n", time.Unix(t.GetExpiresAt(), 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package token
+
+import (
+	"context"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// revokeCmd is a command to revoke the machine token of a user.
+var revokeCmd = &cobra.Command{
+	Use:   "revoke <user>",
+	Short: "Revoke the machine token of a user",
+	Long:  `Remove the machine token of a user enrolled with 'authctl token enroll'. The command must be run as root.`,
+	Example: `  # Revoke the machine token of ci-runner@example.com
+  authctl token revoke ci-runner@example.com`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Users,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := client.NewUserServiceClient()
+		if err != nil {
+			return err
+		}
+
+		_, err = client.RevokeMachineToken(context.Background(), &authd.RevokeMachineTokenRequest{Name: args[0]})
+		return err
+	},
+}
@@ -0,0 +1,74 @@
+// Package token provides the authctl commands to get an access token of the current user from their broker and to
+// manage the machine tokens with which automation logs in as users.
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+var tokenJSON bool
+
+// TokenCmd is a command to print an access token of the current user.
+var TokenCmd = &cobra.Command{
+	Use:   "token <scope>...",
+	Short: "Print an access token of the current user for the given scopes",
+	Long: `Print the access token obtained by the broker when the current user logged in, so that applications and scripts running in their session can access the services of the identity provider without authenticating them again.
+
+The token is refreshed by the broker if it expires soon. All the scopes must be approved in the session_token_scopes setting of the broker configuration, which is empty by default.
+
+This command must be run by the user the token is for, not by root. The machine tokens with which automation logs in as users are managed with the enroll and revoke subcommands.`,
+	Example: `  # Call an API of the identity provider with the token of the current user
+  curl -H "Authorization: Bearer $(authctl token api://my-app/read)" https://api.example.com/
+
+  # Print the token with its type and expiry date
+  authctl token --json openid email`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runToken,
+}
+
+func init() {
+	TokenCmd.Flags().BoolVar(&tokenJSON, "json", false, "Print the token with its type, expiry date and scopes in JSON")
+
+	TokenCmd.AddCommand(enrollCmd)
+	TokenCmd.AddCommand(revokeCmd)
+}
+
+func runToken(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	t, err := c.GetSessionToken(context.Background(), &authd.GetSessionTokenRequest{Scopes: args})
+	if err != nil {
+		return err
+	}
+
+	if !tokenJSON {
+		fmt.Println(t.GetAccessToken())
+		return nil
+	}
+	out, err := json.MarshalIndent(struct {
+		AccessToken string    `json:"access_token"`
+		TokenType   string    `json:"token_type"`
+		Expiry      time.Time `json:"expiry"`
+		Scopes      []string  `json:"scopes"`
+	}{
+		AccessToken: t.GetAccessToken(),
+		TokenType:   t.GetTokenType(),
+		Expiry:      time.Unix(t.GetExpiry(), 0).UTC(),
+		Scopes:      t.GetScopes(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal the token: %w", err)
+	}
+	fmt.Println(string(out))
+	return nil
+}
@@ -0,0 +1,36 @@
+package user
+
+import (
+	"context"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// archiveCmd is a command to archive the home directory of a disabled user.
+var archiveCmd = &cobra.Command{
+	Use:   "archive <user>",
+	Short: "Archive the home directory of a disabled user",
+	Long: `Move the home directory of a disabled user to a compressed archive in /var/lib/authd/home-archives,
+without waiting for the dwell time set by deprovision_archive_after in the authd configuration.
+
+The user keeps their UID until they are deleted. Use 'authctl user restore' to restore their home
+directory and allow them to log in again.
+
+The command must be run as root.`,
+	Example: `  # Archive the home directory of the disabled user "alice"
+  authctl user archive alice`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Users,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := client.NewUserServiceClient()
+		if err != nil {
+			return err
+		}
+
+		_, err = client.ArchiveUser(context.Background(), &authd.ArchiveUserRequest{Name: args[0]})
+		return err
+	},
+}
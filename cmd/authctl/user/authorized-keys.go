@@ -0,0 +1,55 @@
+package user
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// authorizedKeysCmd is a command to print the SSH public keys of a user.
+var authorizedKeysCmd = &cobra.Command{
+	Use:   "authorized-keys <user>",
+	Short: "Print the SSH public keys of a user",
+	Long: `Print the SSH public keys which a user can log in with, in the format of the authorized_keys files.
+
+The keys are provided by the broker of the user when they authenticate with their identity provider. Nothing is printed
+for the users which authd doesn't know about and for the locked users.
+
+The command is meant to be used as the AuthorizedKeysCommand of sshd, so that the users can log in over SSH with their
+keys without authenticating with their identity provider, for example in /etc/ssh/sshd_config.d/authd.conf:
+
+  AuthorizedKeysCommand /usr/bin/authctl user authorized-keys %u
+  AuthorizedKeysCommandUser nobody`,
+	Example: `  # Print the SSH public keys of user1@example.com
+  authctl user authorized-keys user1@example.com`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Users,
+	RunE:              runAuthorizedKeys,
+}
+
+func runAuthorizedKeys(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.GetAuthorizedKeys(context.Background(), &authd.GetAuthorizedKeysRequest{Name: args[0]})
+	// sshd runs the command for all the users, not only the ones of authd.
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, key := range resp.GetKeys() {
+		fmt.Println(key)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// cleanupCmd is a command to clean up the users who haven't logged in for a long time.
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Clean up the users who haven't logged in for a long time",
+	Long: `Disable, archive or delete the users whose last successful login is older than the stale_users_after setting
+of authd, according to its stale_users_action setting.
+
+authd does this periodically when stale_users_after is set. This command does it immediately and reports what was
+done for each stale user. The users without any recorded login are reported but left untouched. Use --dry-run to only
+report what would be done.
+
+The command must be run as root.`,
+	Example: `  # Show which users would be cleaned up
+  authctl user cleanup --dry-run
+
+  # Clean up the stale users
+  authctl user cleanup`,
+	Args: cobra.NoArgs,
+	RunE: runCleanup,
+}
+
+var cleanupDryRun bool
+
+func init() {
+	cleanupCmd.Flags().BoolVar(&cleanupDryRun, "dry-run", false, "Only report what would be done")
+}
+
+func runCleanup(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.CleanupStaleUsers(context.Background(), &authd.CleanupStaleUsersRequest{DryRun: cleanupDryRun})
+	if err != nil {
+		return err
+	}
+	if resp.GetStaleAfter() == 0 {
+		fmt.Fprintln(os.Stderr, "Stale users are not cleaned up, stale_users_after is not set in the configuration of authd.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tUID\tHOME\tLAST LOGIN\tSTATUS")
+	for _, e := range resp.GetEntries() {
+		lastLogin := "-"
+		if e.GetLastLogin() != 0 {
+			lastLogin = time.Unix(e.GetLastLogin(), 0).Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", e.GetName(), e.GetUid(), e.GetHome(), lastLogin, staleUserStatus(e))
+	}
+
+	return w.Flush()
+}
+
+// staleUserStatus describes what was, or would be, done with a stale user.
+func staleUserStatus(e *authd.StaleUser) string {
+	switch {
+	case e.GetSkipped() != "":
+		return "skipped: " + e.GetSkipped()
+	case !e.GetDone():
+		return "would " + e.GetAction()
+	case e.GetArchive() != "":
+		return "archived to " + e.GetArchive()
+	case e.GetAction() == "archive":
+		// The user had no home to archive.
+		return "archived"
+	case e.GetAction() == "delete":
+		return "deleted"
+	default:
+		return "disabled"
+	}
+}
@@ -0,0 +1,82 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// cleanupHomesCmd is a command to clean up the home directories of deleted users.
+var cleanupHomesCmd = &cobra.Command{
+	Use:   "cleanup-homes",
+	Short: "Clean up the home directories of deleted users",
+	Long: `Archive or remove the home directories and the mail spools left behind by deleted users, according to the
+home_cleanup_policy setting of authd, once home_cleanup_after elapsed since their deletion.
+
+authd does this periodically when the policy is "archive" or "remove". This command does it immediately and reports
+what was done for each deleted user. Use --dry-run to only report what would be done.
+
+The command must be run as root.`,
+	Example: `  # Show what would be done with the home directories of deleted users
+  authctl user cleanup-homes --dry-run
+
+  # Clean up the home directories of deleted users
+  authctl user cleanup-homes`,
+	Args: cobra.NoArgs,
+	RunE: runCleanupHomes,
+}
+
+var cleanupHomesDryRun bool
+
+func init() {
+	cleanupHomesCmd.Flags().BoolVar(&cleanupHomesDryRun, "dry-run", false, "Only report what would be done")
+}
+
+func runCleanupHomes(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.CleanupHomes(context.Background(), &authd.CleanupHomesRequest{DryRun: cleanupHomesDryRun})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tUID\tHOME\tDELETED\tCLEANUP\tSTATUS")
+	for _, e := range resp.GetEntries() {
+		due := "-"
+		if e.GetDueAt() != 0 {
+			due = time.Unix(e.GetDueAt(), 0).Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", e.GetName(), e.GetUid(), e.GetHome(),
+			time.Unix(e.GetDeletedAt(), 0).Format(time.DateTime), due, homeCleanupStatus(e))
+	}
+
+	return w.Flush()
+}
+
+// homeCleanupStatus describes what was, or would be, done with the home directory of a deleted user.
+func homeCleanupStatus(e *authd.HomeCleanup) string {
+	switch {
+	case e.GetDone() && e.GetArchive() != "":
+		return "archived to " + e.GetArchive()
+	case e.GetDone():
+		return "removed"
+	case e.GetSkipped() != "":
+		return "skipped: " + e.GetSkipped()
+	case e.GetDueAt() == 0:
+		return "kept"
+	case time.Now().Before(time.Unix(e.GetDueAt(), 0)):
+		return "pending"
+	default:
+		return "would " + e.GetAction()
+	}
+}
@@ -0,0 +1,55 @@
+package user_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanupHomesCommand(t *testing.T) {
+	t.Parallel()
+
+	// The home left behind is owned by the current user, not by the deleted user, so it must never be cleaned up.
+	home := "/tmp/authd-cleanup-homes-cmd-test/home/user2@example.com"
+	require.NoError(t, os.MkdirAll(home, 0700), "Setup: could not create home directory")
+	t.Cleanup(func() { _ = os.RemoveAll("/tmp/authd-cleanup-homes-cmd-test") })
+
+	daemonSocket := testutils.StartAuthd(t, daemonPath,
+		testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+		testutils.WithPreviousDBState("one_user_and_deleted_users"),
+		testutils.WithCurrentUserAsRoot,
+	)
+
+	authctlEnv := []string{
+		"AUTHD_SOCKET=" + daemonSocket,
+		// The deletion times are printed in the local time zone.
+		"TZ=UTC",
+		testutils.CoverDirEnv(),
+	}
+
+	// The steps share the same daemon, so they are run in order.
+	steps := []struct {
+		name             string
+		args             []string
+		expectedExitCode int
+	}{
+		{name: "Dry_run_reports_deleted_users", args: []string{"--dry-run"}},
+		{name: "Cleanup_skips_home_not_owned_by_deleted_user"},
+		{name: "Skipped_home_is_still_reported", args: []string{"--dry-run"}},
+
+		{name: "Error_with_unexpected_argument", args: []string{"user2@example.com"}, expectedExitCode: 1},
+	}
+
+	for _, tc := range steps {
+		t.Run(tc.name, func(t *testing.T) {
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, append([]string{"user", "cleanup-homes"}, tc.args...)...)
+			cmd.Env = authctlEnv
+			testutils.CheckCommand(t, cmd, tc.expectedExitCode)
+		})
+	}
+}
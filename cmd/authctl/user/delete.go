@@ -13,6 +13,7 @@ import (
 	"github.com/canonical/authd/cmd/authctl/internal/log"
 	"github.com/canonical/authd/internal/proto/authd"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 const warningMessage = `Warning: Deleting a user that still owns files on the filesystem can lead
@@ -28,9 +29,15 @@ no other user can be assigned the same UID.`
 
 // deleteCmd is a command to delete a user from the authd database.
 var deleteCmd = &cobra.Command{
-	Use:   "delete <user>",
-	Short: "Delete a user managed by authd",
-	Long:  "Delete a user from the authd database.\n\n" + warningMessage + "\nThe command must be run as root.",
+	Use:     "delete <user>",
+	Aliases: []string{"remove"},
+	Short:   "Delete a user managed by authd",
+	Long: "Delete a user from the authd database.\n\n" + warningMessage + `
+
+The home directory of the user is kept by default, and cleaned up later according to the home_cleanup_policy
+setting of authd. Use --purge-home to remove it right away, or --archive-home to move it to the archives of authd.
+
+The command must be run as root.`,
 	Example: `  # Delete user "alice" from the authd database
   authctl user delete alice
 
@@ -38,7 +45,10 @@ var deleteCmd = &cobra.Command{
   authctl user delete --yes alice
 
   # Delete user "alice" and remove their home directory
-  authctl user delete --remove alice`,
+  authctl user delete --purge-home alice
+
+  # Delete user "alice" and archive their home directory
+  authctl user delete --archive-home alice`,
 	Args:              cobra.ExactArgs(1),
 	ValidArgsFunction: completion.Users,
 	RunE:              runDeleteUser,
@@ -46,10 +56,20 @@ var deleteCmd = &cobra.Command{
 
 var deleteUserYes bool
 var deleteUserRemoveHome bool
+var deleteUserArchiveHome bool
 
 func init() {
 	deleteCmd.Flags().BoolVarP(&deleteUserYes, "yes", "y", false, "Skip confirmation prompt")
-	deleteCmd.Flags().BoolVarP(&deleteUserRemoveHome, "remove", "r", false, "Remove the user's home directory")
+	deleteCmd.Flags().BoolVarP(&deleteUserRemoveHome, "purge-home", "r", false, "Remove the user's home directory")
+	deleteCmd.Flags().BoolVar(&deleteUserArchiveHome, "archive-home", false, "Move the user's home directory to the archives of authd")
+	deleteCmd.MarkFlagsMutuallyExclusive("purge-home", "archive-home")
+	// --remove is the former name of --purge-home.
+	deleteCmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "remove" {
+			name = "purge-home"
+		}
+		return pflag.NormalizedName(name)
+	})
 }
 
 func runDeleteUser(cmd *cobra.Command, args []string) error {
@@ -80,7 +100,11 @@ func runDeleteUser(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	resp, err := c.DeleteUser(context.Background(), &authd.DeleteUserRequest{Name: name, RemoveHome: deleteUserRemoveHome})
+	resp, err := c.DeleteUser(context.Background(), &authd.DeleteUserRequest{
+		Name:        name,
+		RemoveHome:  deleteUserRemoveHome,
+		ArchiveHome: deleteUserArchiveHome,
+	})
 	if err != nil {
 		return err
 	}
@@ -90,5 +114,8 @@ func runDeleteUser(cmd *cobra.Command, args []string) error {
 	}
 
 	log.Infof("User %q has been deleted from the authd database.", name)
+	if resp.GetHomeArchive() != "" {
+		log.Infof("Their home directory has been archived to %s.", resp.GetHomeArchive())
+	}
 	return nil
 }
@@ -88,6 +88,21 @@ func TestUserDeleteCommand(t *testing.T) {
 			wantHomeDirRemoved: true,
 			expectedExitCode:   0,
 		},
+		"Delete_with_purge_home_flag_removes_home_dir": {
+			args:               []string{"delete", "--yes", "--purge-home", "user8@example.com"},
+			createHomeDir:      true,
+			wantHomeDirRemoved: true,
+			expectedExitCode:   0,
+		},
+		"Delete_with_remove_alias": {
+			args:             []string{"remove", "--yes", "user9@example.com"},
+			expectedExitCode: 0,
+		},
+		"Delete_with_archive_home_flag_succeeds_when_home_dir_does_not_exist": {
+			args:               []string{"delete", "--yes", "--archive-home", "user10@example.com"},
+			wantHomeDirRemoved: true,
+			expectedExitCode:   0,
+		},
 		"Delete_succeeds_with_warning_when_broker_cleanup_fails": {
 			args:             []string{"delete", "--yes", "delete_error@example.com"},
 			expectedExitCode: 0,
@@ -101,6 +116,10 @@ func TestUserDeleteCommand(t *testing.T) {
 			args:             []string{"delete", "nonexistent@example.com"},
 			expectedExitCode: int(codes.NotFound),
 		},
+		"Error_when_purge_home_and_archive_home_are_combined": {
+			args:             []string{"delete", "--yes", "--purge-home", "--archive-home", "user1@example.com"},
+			expectedExitCode: 1,
+		},
 		"Error_when_authd_is_unavailable": {
 			args:             []string{"delete", "--yes", "user1@example.com"},
 			authdUnavailable: true,
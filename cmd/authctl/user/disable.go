@@ -0,0 +1,36 @@
+package user
+
+import (
+	"context"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// disableCmd is a command to start the deprovisioning of a user.
+var disableCmd = &cobra.Command{
+	Use:   "disable <user>",
+	Short: "Disable a user and start their deprovisioning",
+	Long: `Disable a user so that they cannot log in, as the first step of their deprovisioning.
+
+The home directory of a disabled user is kept as is. Disabled users are archived, then deleted,
+once the dwell times set by deprovision_archive_after and deprovision_delete_after in the authd
+configuration elapsed. Use 'authctl user restore' to undo it.
+
+The command must be run as root.`,
+	Example: `  # Start the deprovisioning of user "alice"
+  authctl user disable alice`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Users,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := client.NewUserServiceClient()
+		if err != nil {
+			return err
+		}
+
+		_, err = client.DisableUser(context.Background(), &authd.DisableUserRequest{Name: args[0]})
+		return err
+	},
+}
@@ -0,0 +1,119 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// exportCmd is a command to export the users and groups of the authd database.
+var exportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Export the users and groups of the authd database",
+	Long: `Export the users and groups of the authd database with their UIDs, GIDs, gecos, home directories, shells,
+brokers and group memberships, to a file or, if none is given, to the standard output.
+
+The exported records can be imported on other machines with 'authctl user import', e.g. to pre-seed the
+database of a golden image, so that the users keep the same UIDs and GIDs on all the machines.
+
+The command must be run as root.`,
+	Example: `  # Export the users and groups to users.json
+  authctl user export users.json
+
+  # Export the users and groups in YAML to the standard output
+  authctl user export --format yaml`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runExport,
+}
+
+var exportFormat string
+
+func init() {
+	exportCmd.Flags().StringVar(&exportFormat, "format", "json", "Format of the records, json or yaml")
+}
+
+// userRecords is the format of the exported records.
+type userRecords struct {
+	Users  []userRecord  `json:"users" yaml:"users"`
+	Groups []groupRecord `json:"groups" yaml:"groups"`
+}
+
+type userRecord struct {
+	Name        string   `json:"name" yaml:"name"`
+	UID         uint32   `json:"uid" yaml:"uid"`
+	GID         uint32   `json:"gid" yaml:"gid"`
+	Gecos       string   `json:"gecos,omitempty" yaml:"gecos,omitempty"`
+	Homedir     string   `json:"homedir" yaml:"homedir"`
+	Shell       string   `json:"shell" yaml:"shell"`
+	BrokerID    string   `json:"broker_id" yaml:"broker_id"`
+	ProviderID  string   `json:"provider_id,omitempty" yaml:"provider_id,omitempty"`
+	Locked      bool     `json:"locked,omitempty" yaml:"locked,omitempty"`
+	Groups      []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+	LocalGroups []string `json:"local_groups,omitempty" yaml:"local_groups,omitempty"`
+}
+
+type groupRecord struct {
+	Name string `json:"name" yaml:"name"`
+	GID  uint32 `json:"gid" yaml:"gid"`
+	UGID string `json:"ugid" yaml:"ugid"`
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	if exportFormat != "json" && exportFormat != "yaml" {
+		return fmt.Errorf("invalid format %q, must be json or yaml", exportFormat)
+	}
+
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.ExportUsers(context.Background(), &authd.Empty{})
+	if err != nil {
+		return err
+	}
+
+	records := userRecords{Users: []userRecord{}, Groups: []groupRecord{}}
+	for _, u := range resp.GetUsers() {
+		records.Users = append(records.Users, userRecord{
+			Name:        u.GetName(),
+			UID:         u.GetUid(),
+			GID:         u.GetGid(),
+			Gecos:       u.GetGecos(),
+			Homedir:     u.GetHomedir(),
+			Shell:       u.GetShell(),
+			BrokerID:    u.GetBrokerId(),
+			ProviderID:  u.GetProviderId(),
+			Locked:      u.GetLocked(),
+			Groups:      u.GetGroups(),
+			LocalGroups: u.GetLocalGroups(),
+		})
+	}
+	for _, g := range resp.GetGroups() {
+		records.Groups = append(records.Groups, groupRecord{Name: g.GetName(), GID: g.GetGid(), UGID: g.GetUgid()})
+	}
+
+	var out []byte
+	if exportFormat == "yaml" {
+		out, err = yaml.Marshal(records)
+	} else {
+		out, err = json.MarshalIndent(records, "", "  ")
+		out = append(out, '\n')
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	// The records contain the provider IDs of the users, only readable by root.
+	return os.WriteFile(args[0], out, 0600)
+}
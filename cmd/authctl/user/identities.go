@@ -0,0 +1,54 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// identitiesCmd is a command to show the brokers a user can log in with.
+var identitiesCmd = &cobra.Command{
+	Use:   "identities <user>",
+	Short: "Show the brokers a user can log in with",
+	Long: `Show the broker a user is bound to and the identities linked to them at other brokers.
+
+A linked identity is pending until the user logs in with its broker. The command must be run as root.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Users,
+	RunE:              runIdentities,
+}
+
+func runIdentities(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.GetLinkedIdentities(context.Background(), &authd.GetLinkedIdentitiesRequest{Name: args[0]})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BROKER\tNAME\tSTATUS")
+	fmt.Fprintf(w, "%s\t-\tbound\n", resp.GetBrokerId())
+	for _, i := range resp.GetIdentities() {
+		name := i.GetBrokerName()
+		if name == "" {
+			name = "-"
+		}
+		status := "linked"
+		if i.GetProviderId() == "" {
+			status = "pending"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", i.GetBrokerId(), name, status)
+	}
+
+	return w.Flush()
+}
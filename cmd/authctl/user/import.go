@@ -0,0 +1,86 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// importCmd is a command to import users and groups to the authd database.
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import users and groups to the authd database",
+	Long: `Import the users and groups exported with 'authctl user export' to the authd database, from a JSON or YAML
+file or, if the file is "-", from the standard input.
+
+The users and groups keep their UIDs and GIDs. Either all of them are imported or none: the import fails if
+one of their names or IDs is already used by another user or group, in the authd database or on the system,
+if the IDs of the new ones are not in the ranges configured for authd, or if one of their names, home
+directories, shells or local groups is not valid on this system.
+The users and groups which are already in the database with the same IDs are updated, but the users keep their
+home directory and shell.
+
+The home directories of the imported users are created when they first log in. The command must be run as root.`,
+	Example: `  # Import the users and groups from users.json
+  authctl user import users.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImport,
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	var data []byte
+	var err error
+	if args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	// JSON is a subset of YAML, so both formats are decoded as YAML.
+	var records userRecords
+	if err := yaml.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("invalid records in %s: %w", args[0], err)
+	}
+
+	req := &authd.UserRecords{}
+	for _, u := range records.Users {
+		req.Users = append(req.Users, &authd.UserRecords_User{
+			Name:        u.Name,
+			Uid:         u.UID,
+			Gid:         u.GID,
+			Gecos:       u.Gecos,
+			Homedir:     u.Homedir,
+			Shell:       u.Shell,
+			BrokerId:    u.BrokerID,
+			ProviderId:  u.ProviderID,
+			Locked:      u.Locked,
+			Groups:      u.Groups,
+			LocalGroups: u.LocalGroups,
+		})
+	}
+	for _, g := range records.Groups {
+		req.Groups = append(req.Groups, &authd.UserRecords_Group{Name: g.Name, Gid: g.GID, Ugid: g.UGID})
+	}
+
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.ImportUsers(context.Background(), req); err != nil {
+		return err
+	}
+
+	log.Infof("Imported %d users and %d groups.", len(req.Users), len(req.Groups))
+	return nil
+}
@@ -0,0 +1,72 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// lastCmd is a command to show the login history of a user.
+var lastCmd = &cobra.Command{
+	Use:   "last <user>",
+	Short: "Show the recent logins of a user",
+	Long: `Show the recent logins of a user managed by authd, newest first.
+
+Root can show the login history of all users, other users can only show their own.`,
+	Example: `  # Show the recent logins of user "alice"
+  authctl user last alice
+
+  # Show the 5 most recent logins of user "alice"
+  authctl user last --limit 5 alice`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Users,
+	RunE:              runLast,
+}
+
+var lastLimit uint32
+
+func init() {
+	lastCmd.Flags().Uint32VarP(&lastLimit, "limit", "n", 0, "Maximum number of logins to show (0 shows all stored logins)")
+}
+
+func runLast(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.GetLoginHistory(context.Background(), &authd.GetLoginHistoryRequest{
+		Name:  args[0],
+		Limit: lastLimit,
+	})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSERVICE\tBROKER\tMODE\tRESULT")
+	for _, r := range resp.GetRecords() {
+		mode := "online"
+		if r.GetOffline() {
+			mode = "offline"
+		}
+		if r.GetMachineToken() {
+			mode = "machine-token"
+		}
+		service := r.GetService()
+		if service == "" {
+			service = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			time.Unix(r.GetTime(), 0).Format(time.DateTime), service, r.GetBrokerId(), mode, r.GetResult())
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,50 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// lifecycleCmd is a command to show the deprovisioning state of a user.
+var lifecycleCmd = &cobra.Command{
+	Use:   "lifecycle <user>",
+	Short: "Show the deprovisioning state of a user",
+	Long: `Show whether a user is active, disabled or archived, and when they are moved to the next state.
+
+The command must be run as root.`,
+	Example: `  # Show the deprovisioning state of user "alice"
+  authctl user lifecycle alice`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Users,
+	RunE:              runLifecycle,
+}
+
+func runLifecycle(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	lc, err := c.GetUserLifecycle(context.Background(), &authd.GetUserLifecycleRequest{Name: args[0]})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("State: %s\n", lc.GetState())
+	if lc.GetSince() != 0 {
+		fmt.Printf("Since: %s\n", time.Unix(lc.GetSince(), 0).Format(time.DateTime))
+	}
+	if lc.GetArchive() != "" {
+		fmt.Printf("Archive: %s\n", lc.GetArchive())
+	}
+	if lc.GetNextState() != "" {
+		fmt.Printf("Next state: %s on %s\n", lc.GetNextState(), time.Unix(lc.GetNextTime(), 0).Format(time.DateTime))
+	}
+	return nil
+}
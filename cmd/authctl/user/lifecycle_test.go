@@ -0,0 +1,54 @@
+package user_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/testutils"
+	"google.golang.org/grpc/codes"
+)
+
+func TestUserLifecycleCommands(t *testing.T) {
+	t.Parallel()
+
+	daemonSocket := testutils.StartAuthd(t, daemonPath,
+		testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+		testutils.WithPreviousDBState("one_user_and_group"),
+		testutils.WithCurrentUserAsRoot,
+	)
+
+	authctlEnv := []string{
+		"AUTHD_SOCKET=" + daemonSocket,
+		testutils.CoverDirEnv(),
+	}
+
+	// The steps share the same daemon, so they are run in order.
+	steps := []struct {
+		name             string
+		args             []string
+		expectedExitCode int
+	}{
+		{name: "Show_state_of_active_user", args: []string{"lifecycle", "user1@example.com"}},
+		{name: "Error_archiving_active_user", args: []string{"archive", "user1@example.com"}, expectedExitCode: int(codes.FailedPrecondition)},
+		{name: "Error_restoring_active_user", args: []string{"restore", "user1@example.com"}, expectedExitCode: int(codes.FailedPrecondition)},
+		{name: "Disable_user_success", args: []string{"disable", "user1@example.com"}},
+		{name: "Error_disabling_disabled_user", args: []string{"disable", "user1@example.com"}, expectedExitCode: int(codes.FailedPrecondition)},
+		{name: "Error_unlocking_disabled_user", args: []string{"unlock", "user1@example.com"}, expectedExitCode: int(codes.FailedPrecondition)},
+		{name: "Archive_user_success", args: []string{"archive", "user1@example.com"}},
+		{name: "Restore_user_success", args: []string{"restore", "user1@example.com"}},
+		{name: "Show_state_of_restored_user", args: []string{"lifecycle", "user1@example.com"}},
+
+		{name: "Error_disabling_invalid_user", args: []string{"disable", "invaliduser"}, expectedExitCode: int(codes.NotFound)},
+		{name: "Error_showing_state_of_invalid_user", args: []string{"lifecycle", "invaliduser"}, expectedExitCode: int(codes.NotFound)},
+	}
+
+	for _, tc := range steps {
+		t.Run(tc.name, func(t *testing.T) {
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, append([]string{"user"}, tc.args...)...)
+			cmd.Env = authctlEnv
+			testutils.CheckCommand(t, cmd, tc.expectedExitCode)
+		})
+	}
+}
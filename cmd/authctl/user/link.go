@@ -0,0 +1,88 @@
+package user
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+const linkWarningMessage = `Warning: The first account of the provider of this broker which logs in with
+this user name is linked to the user. It gets the UID, home directory and
+files of the user, and can't be replaced by another account afterwards.
+
+Only link an identity if the accounts of the provider are managed by your
+organization, and if the user will log in with it soon.`
+
+// linkCmd is a command to link the identity of a user at another broker.
+var linkCmd = &cobra.Command{
+	Use:   "link <user> <broker>",
+	Short: "Allow a user to log in with another broker",
+	Long: `Link the identity of a user at another broker than the one they're bound to,
+so that they can log in with both and keep their UID, home directory and groups,
+e.g. when the organization migrates to another identity provider.
+
+The link is confirmed when the user first logs in with the broker.
+
+` + linkWarningMessage + `
+
+The command must be run as root.`,
+	Example: `  # Allow user "alice" to log in with the broker "Microsoft Entra ID"
+  authctl user link alice "Microsoft Entra ID"
+
+  # Link the identity without confirmation prompt
+  authctl user link --yes alice "Microsoft Entra ID"`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completion.Users,
+	RunE:              runLink,
+}
+
+var linkYes bool
+
+func init() {
+	linkCmd.Flags().BoolVarP(&linkYes, "yes", "y", false, "Skip confirmation prompt")
+}
+
+func runLink(cmd *cobra.Command, args []string) error {
+	name, broker := args[0], args[1]
+
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	if _, err = c.GetUserByName(context.Background(), &authd.GetUserByNameRequest{Name: name}); err != nil {
+		return err
+	}
+
+	if !linkYes {
+		log.Warning(linkWarningMessage)
+		fmt.Fprintf(os.Stderr, "\nAre you sure you want to allow user %q to log in with broker %q? [y/N] ", name, broker)
+
+		reader := bufio.NewReader(os.Stdin)
+		answer, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || len(answer) == 0) {
+			return fmt.Errorf("failed to read confirmation: %w", err)
+		}
+		answer = strings.TrimSpace(strings.ToLower(answer))
+		if answer != "y" && answer != "yes" {
+			log.Info("Aborted.")
+			return nil
+		}
+	}
+
+	if _, err = c.LinkIdentity(context.Background(), &authd.LinkIdentityRequest{Name: name, Broker: broker}); err != nil {
+		return err
+	}
+
+	log.Infof("User %q can now log in with broker %q.", name, broker)
+	return nil
+}
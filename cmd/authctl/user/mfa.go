@@ -0,0 +1,84 @@
+package user
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/skip2/go-qrcode"
+	"github.com/spf13/cobra"
+)
+
+// mfaCmd is a command to manage the local second factor of the users.
+var mfaCmd = &cobra.Command{
+	Use:   "mfa",
+	Short: "Commands related to the local second factor of users",
+	Long: `Manage the TOTP secrets which the users enter a code of, generated by an authenticator app, when their broker
+grants them access offline, e.g. with the password cached on their last login while their identity provider can't be
+reached.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error { return cmd.Usage() },
+}
+
+// mfaEnrollCmd is a command to generate a TOTP secret for a user.
+var mfaEnrollCmd = &cobra.Command{
+	Use:   "enroll <user>",
+	Short: "Require a TOTP code when a user logs in offline",
+	Long: `Generate a new TOTP secret for a user and print it as a QR code to scan with an authenticator app, along with its
+otpauth URI.
+
+Once enrolled, the user has to enter a code of the secret after their password when their broker grants them access
+offline. A previously enrolled secret is replaced. The command must be run as root.`,
+	Example: `  # Enroll a TOTP secret for user1@example.com
+  authctl user mfa enroll user1@example.com`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Users,
+	RunE:              runMFAEnroll,
+}
+
+// mfaDisableCmd is a command to remove the TOTP secret of a user.
+var mfaDisableCmd = &cobra.Command{
+	Use:   "disable <user>",
+	Short: "Stop requiring a TOTP code when a user logs in offline",
+	Long:  `Remove the TOTP secret of a user enrolled with 'authctl user mfa enroll'. The command must be run as root.`,
+	Example: `  # Remove the TOTP secret of user1@example.com
+  authctl user mfa disable user1@example.com`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Users,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := client.NewUserServiceClient()
+		if err != nil {
+			return err
+		}
+
+		_, err = client.DisableTOTP(context.Background(), &authd.DisableTOTPRequest{Name: args[0]})
+		return err
+	},
+}
+
+func init() {
+	mfaCmd.AddCommand(mfaEnrollCmd)
+	mfaCmd.AddCommand(mfaDisableCmd)
+}
+
+func runMFAEnroll(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.EnrollTOTP(context.Background(), &authd.EnrollTOTPRequest{Name: args[0]})
+	if err != nil {
+		return err
+	}
+
+	qr, err := qrcode.New(resp.GetUri(), qrcode.Medium)
+	if err != nil {
+		return fmt.Errorf("could not generate the QR code: %w", err)
+	}
+	fmt.Print(qr.ToSmallString(false))
+	fmt.Println(resp.GetUri())
+	return nil
+}
@@ -0,0 +1,81 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// orphansCmd is a command to list the home directories whose owner no longer exists.
+var orphansCmd = &cobra.Command{
+	Use:   "orphans",
+	Short: "List the home directories whose owner no longer exists",
+	Long: `List the directories in the home prefixes whose owner is neither a user of authd nor a local user, such as the
+home directories left behind by deleted users.
+
+By default, the home prefixes are the parent directories of the home directories of the current users of authd and
+of the deleted users whose home directory is still tracked. Use --prefix to look in other directories instead.
+
+When the home directory belonged to a deleted user of authd, the name of the user and the time of the deletion are
+reported too.
+
+The command must be run as root.`,
+	Example: `  # List the orphaned home directories
+  authctl user orphans
+
+  # List the orphaned home directories in /home and /srv/home
+  authctl user orphans --prefix /home --prefix /srv/home`,
+	Args: cobra.NoArgs,
+	RunE: runOrphans,
+}
+
+var orphansPrefixes []string
+
+func init() {
+	orphansCmd.Flags().StringArrayVar(&orphansPrefixes, "prefix", nil, "Directory in which to look for orphaned home directories (can be repeated)")
+}
+
+func runOrphans(cmd *cobra.Command, args []string) error {
+	prefixes := make([]string, 0, len(orphansPrefixes))
+	for _, p := range orphansPrefixes {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		prefixes = append(prefixes, abs)
+	}
+
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.ListOrphanedHomes(context.Background(), &authd.ListOrphanedHomesRequest{Prefixes: prefixes})
+	if err != nil {
+		return err
+	}
+	if len(resp.GetEntries()) == 0 {
+		fmt.Fprintln(os.Stderr, "No orphaned home directories found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tUID\tDELETED USER\tDELETED AT")
+	for _, e := range resp.GetEntries() {
+		deletedUser, deletedAt := "-", "-"
+		if e.GetDeletedUser() != "" {
+			deletedUser = e.GetDeletedUser()
+			deletedAt = time.Unix(e.GetDeletedAt(), 0).Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", e.GetPath(), e.GetUid(), deletedUser, deletedAt)
+	}
+
+	return w.Flush()
+}
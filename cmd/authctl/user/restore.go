@@ -0,0 +1,35 @@
+package user
+
+import (
+	"context"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// restoreCmd is a command to stop the deprovisioning of a user.
+var restoreCmd = &cobra.Command{
+	Use:   "restore <user>",
+	Short: "Restore a disabled or archived user",
+	Long: `Stop the deprovisioning of a disabled or archived user, so that they can log in again.
+
+The home directory of an archived user is restored from its archive. This fails if a directory
+already exists at the path of their home.
+
+The command must be run as root.`,
+	Example: `  # Allow user "alice" to log in again
+  authctl user restore alice`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Users,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := client.NewUserServiceClient()
+		if err != nil {
+			return err
+		}
+
+		_, err = client.RestoreUser(context.Background(), &authd.RestoreUserRequest{Name: args[0]})
+		return err
+	},
+}
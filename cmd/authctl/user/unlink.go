@@ -0,0 +1,34 @@
+package user
+
+import (
+	"context"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// unlinkCmd is a command to unlink the identity of a user at another broker.
+var unlinkCmd = &cobra.Command{
+	Use:   "unlink <user> <broker>",
+	Short: "Prevent a user from logging in with a linked broker",
+	Long: `Remove the identity of a user at a broker which was linked with 'authctl user link'.
+
+The broker the user is bound to is not affected. The command must be run as root.`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completion.Users,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := client.NewUserServiceClient()
+		if err != nil {
+			return err
+		}
+
+		_, err = client.UnlinkIdentity(context.Background(), &authd.UnlinkIdentityRequest{Name: args[0], Broker: args[1]})
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
@@ -11,9 +11,11 @@ import (
 
 // unlockCmd is a command to unlock (enable) a user.
 var unlockCmd = &cobra.Command{
-	Use:               "unlock <user>",
-	Short:             "Unlock (enable) a user managed by authd",
-	Long:              `Unlock a locked user so that they can log in again.`,
+	Use:   "unlock <user>",
+	Short: "Unlock (enable) a user managed by authd",
+	Long: `Unlock a locked user so that they can log in again.
+
+This also unlocks a user locked out after too many failed authentication attempts, even if they never logged in.`,
 	Args:              cobra.ExactArgs(1),
 	ValidArgsFunction: completion.Users,
 	RunE: func(cmd *cobra.Command, args []string) error {
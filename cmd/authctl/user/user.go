@@ -20,4 +20,20 @@ func init() {
 	UserCmd.AddCommand(setShellCmd)
 	UserCmd.AddCommand(setHomeDirCmd)
 	UserCmd.AddCommand(deleteCmd)
+	UserCmd.AddCommand(lastCmd)
+	UserCmd.AddCommand(disableCmd)
+	UserCmd.AddCommand(archiveCmd)
+	UserCmd.AddCommand(restoreCmd)
+	UserCmd.AddCommand(lifecycleCmd)
+	UserCmd.AddCommand(cleanupHomesCmd)
+	UserCmd.AddCommand(cleanupCmd)
+	UserCmd.AddCommand(orphansCmd)
+	UserCmd.AddCommand(verifyCmd)
+	UserCmd.AddCommand(linkCmd)
+	UserCmd.AddCommand(unlinkCmd)
+	UserCmd.AddCommand(identitiesCmd)
+	UserCmd.AddCommand(exportCmd)
+	UserCmd.AddCommand(importCmd)
+	UserCmd.AddCommand(authorizedKeysCmd)
+	UserCmd.AddCommand(mfaCmd)
 }
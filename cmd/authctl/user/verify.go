@@ -0,0 +1,53 @@
+package user
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// verifyCmd is a command to check online whether a user can still log in.
+var verifyCmd = &cobra.Command{
+	Use:   "verify <user>",
+	Short: "Check with the identity provider whether a user can still log in",
+	Long: `Ask the broker of a user to check online whether their account is still enabled at the identity provider, and whether they are still a member of a group allowed to log in on this machine, without authenticating them.
+
+The status is one of:
+  active       The account is enabled and the user is allowed to log in.
+  disabled     The account is disabled or deleted at the identity provider.
+  not_allowed  The account is enabled, but the user is not a member of any group allowed to log in on this machine.
+  unknown      The account could not be checked, for example because the identity provider is not reachable.
+
+The broker must support verifying users. The command must be run as root.`,
+	Example: `  # Check whether the access of user "alice" was revoked
+  authctl user verify alice`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Users,
+	RunE:              runVerify,
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	v, err := c.VerifyUser(context.Background(), &authd.VerifyUserRequest{Name: args[0]})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Status: %s\n", v.GetStatus())
+	if v.GetMessage() != "" {
+		fmt.Printf("Details: %s\n", v.GetMessage())
+	}
+	fmt.Printf("Broker: %s\n", v.GetBroker())
+	if v.GetLocked() {
+		fmt.Println("The user is locked in authd and can't log in, whatever their status at the identity provider.")
+	}
+	return nil
+}
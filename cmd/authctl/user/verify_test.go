@@ -0,0 +1,55 @@
+package user_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/testutils"
+	"google.golang.org/grpc/codes"
+)
+
+func TestUserVerifyCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		args             []string
+		previousDBState  string
+		expectedExitCode int
+	}{
+		"Error_verifying_user_of_broker_without_support": {
+			args:             []string{"verify", "user1@example.com"},
+			previousDBState:  "one_user_of_example_broker",
+			expectedExitCode: int(codes.Unimplemented),
+		},
+		"Error_verifying_user_of_unavailable_broker": {
+			args:             []string{"verify", "user1@example.com"},
+			expectedExitCode: int(codes.FailedPrecondition),
+		},
+		"Error_verifying_invalid_user":   {args: []string{"verify", "invaliduser"}, expectedExitCode: int(codes.NotFound)},
+		"Error_when_no_user_is_provided": {args: []string{"verify"}, expectedExitCode: 1},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.previousDBState == "" {
+				tc.previousDBState = "one_user_and_group"
+			}
+			daemonSocket := testutils.StartAuthd(t, daemonPath,
+				testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+				testutils.WithPreviousDBState(tc.previousDBState),
+				testutils.WithCurrentUserAsRoot,
+			)
+
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, append([]string{"user"}, tc.args...)...)
+			cmd.Env = []string{
+				"AUTHD_SOCKET=" + daemonSocket,
+				testutils.CoverDirEnv(),
+			}
+			testutils.CheckCommand(t, cmd, tc.expectedExitCode)
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Package whoami provides the authctl command to show the status of the current user in authd.
+package whoami
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+var whoamiJSON bool
+
+// WhoamiCmd is a command to show the status of the current user.
+var WhoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the status of the current user in authd",
+	Long: `Show the broker of the current user, the state of the token cached by the broker, how long they can still log in without their identity provider, their groups and their last logins.
+
+This helps to understand why a login asks to authenticate with the identity provider again, for example because the offline login period elapsed or because the broker has no refresh token.
+
+This command must be run by the user to show the status of, not by root.`,
+	Example: `  # Show the status of the current user
+  authctl whoami
+
+  # Show the status of the current user in JSON
+  authctl whoami --json`,
+	Args: cobra.NoArgs,
+	RunE: runWhoami,
+}
+
+func init() {
+	WhoamiCmd.Flags().BoolVar(&whoamiJSON, "json", false, "Print the status in JSON")
+}
+
+func runWhoami(cmd *cobra.Command, args []string) error {
+	c, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.GetUserStatus(context.Background(), &authd.Empty{})
+	if err != nil {
+		return err
+	}
+
+	if whoamiJSON {
+		return printJSON(resp)
+	}
+
+	now := time.Now()
+	fmt.Printf("User: %s (UID %d)\n", resp.GetName(), resp.GetUid())
+	fmt.Printf("Broker: %s\n", resp.GetBroker())
+	if resp.GetLocked() {
+		fmt.Println("Locked: yes, the logins are denied until an administrator unlocks the user")
+	}
+	fmt.Printf("Groups: %s\n", strings.Join(resp.GetGroups(), ", "))
+
+	switch t := resp.GetToken(); {
+	case resp.GetTokenError() != "":
+		fmt.Printf("\nToken: unknown (%s)\n", resp.GetTokenError())
+	case t != nil:
+		fmt.Println("\nToken:")
+		if t.GetExpiry() != 0 {
+			fmt.Printf("  Expires: %s\n", formatTime(time.Unix(t.GetExpiry(), 0), now))
+		}
+		fmt.Printf("  Refresh token: %s\n", yesNo(t.GetHasRefreshToken()))
+		fmt.Printf("  Local password: %s\n", yesNo(t.GetHasLocalPassword()))
+		if t.GetUserIsDisabled() {
+			fmt.Println("  Disabled by the identity provider: yes")
+		}
+	}
+
+	fmt.Println("\nOffline logins:")
+	var lastOnline time.Time
+	if resp.GetLastOnlineLogin() != 0 {
+		lastOnline = time.Unix(resp.GetLastOnlineLogin(), 0)
+		fmt.Printf("  Last login with the identity provider: %s\n", formatTime(lastOnline, now))
+	} else {
+		fmt.Println("  Last login with the identity provider: none recorded")
+	}
+	maxAge := time.Duration(resp.GetOfflineMaxAge()) * time.Second
+	switch {
+	case maxAge == 0:
+		fmt.Println("  Allowed: without time limit")
+	case lastOnline.IsZero():
+		fmt.Println("  Allowed: no, the next login must be done with the identity provider")
+	case lastOnline.Add(maxAge).Before(now):
+		fmt.Printf("  Allowed: no, the period ended on %s, the next login must be done with the identity provider\n",
+			formatTime(lastOnline.Add(maxAge), now))
+	default:
+		fmt.Printf("  Allowed until: %s\n", formatTime(lastOnline.Add(maxAge), now))
+	}
+	if maxLogins := resp.GetOfflineMaxLogins(); maxLogins > 0 {
+		fmt.Printf("  Offline logins since the last login with the identity provider: %d of %d\n", resp.GetOfflineLogins(), maxLogins)
+	}
+
+	if len(resp.GetLastLogins()) == 0 {
+		return nil
+	}
+	fmt.Println("\nLast logins:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  TIME\tSERVICE\tMODE\tRESULT")
+	for _, r := range resp.GetLastLogins() {
+		mode := "online"
+		if r.GetOffline() {
+			mode = "offline"
+		}
+		if r.GetMachineToken() {
+			mode = "machine-token"
+		}
+		service := r.GetService()
+		if service == "" {
+			service = "-"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", time.Unix(r.GetTime(), 0).Format(time.DateTime), service, mode, r.GetResult())
+	}
+	return w.Flush()
+}
+
+type loginJSON struct {
+	Time         time.Time `json:"time"`
+	Service      string    `json:"service"`
+	Offline      bool      `json:"offline"`
+	MachineToken bool      `json:"machine_token,omitempty"`
+	Result       string    `json:"result"`
+}
+
+type tokenJSON struct {
+	Expiry           *time.Time `json:"expiry,omitempty"`
+	HasRefreshToken  bool       `json:"has_refresh_token"`
+	HasLocalPassword bool       `json:"has_local_password"`
+	UserIsDisabled   bool       `json:"user_is_disabled"`
+}
+
+type statusJSON struct {
+	Name             string      `json:"name"`
+	UID              uint32      `json:"uid"`
+	Broker           string      `json:"broker"`
+	Groups           []string    `json:"groups"`
+	Locked           bool        `json:"locked"`
+	Token            *tokenJSON  `json:"token,omitempty"`
+	TokenError       string      `json:"token_error,omitempty"`
+	LastOnlineLogin  *time.Time  `json:"last_online_login,omitempty"`
+	OfflineMaxAge    string      `json:"offline_max_age,omitempty"`
+	OfflineMaxLogins uint32      `json:"offline_max_logins,omitempty"`
+	OfflineLogins    uint32      `json:"offline_logins"`
+	LastLogins       []loginJSON `json:"last_logins"`
+}
+
+func printJSON(resp *authd.UserStatus) error {
+	s := statusJSON{
+		Name:             resp.GetName(),
+		UID:              resp.GetUid(),
+		Broker:           resp.GetBroker(),
+		Groups:           resp.GetGroups(),
+		Locked:           resp.GetLocked(),
+		TokenError:       resp.GetTokenError(),
+		OfflineMaxLogins: resp.GetOfflineMaxLogins(),
+		OfflineLogins:    resp.GetOfflineLogins(),
+		LastLogins:       []loginJSON{},
+	}
+	if t := resp.GetToken(); t != nil {
+		s.Token = &tokenJSON{
+			HasRefreshToken:  t.GetHasRefreshToken(),
+			HasLocalPassword: t.GetHasLocalPassword(),
+			UserIsDisabled:   t.GetUserIsDisabled(),
+		}
+		if t.GetExpiry() != 0 {
+			s.Token.Expiry = unixTime(t.GetExpiry())
+		}
+	}
+	if resp.GetLastOnlineLogin() != 0 {
+		s.LastOnlineLogin = unixTime(resp.GetLastOnlineLogin())
+	}
+	if resp.GetOfflineMaxAge() != 0 {
+		s.OfflineMaxAge = (time.Duration(resp.GetOfflineMaxAge()) * time.Second).String()
+	}
+	for _, r := range resp.GetLastLogins() {
+		s.LastLogins = append(s.LastLogins, loginJSON{
+			Time:         *unixTime(r.GetTime()),
+			Service:      r.GetService(),
+			Offline:      r.GetOffline(),
+			MachineToken: r.GetMachineToken(),
+			Result:       r.GetResult(),
+		})
+	}
+
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal the status: %w", err)
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func unixTime(sec int64) *time.Time {
+	t := time.Unix(sec, 0).UTC()
+	return &t
+}
+
+// formatTime returns the time with how long ago or how long from now it is.
+func formatTime(t, now time.Time) string {
+	d := t.Sub(now).Round(time.Minute)
+	if d < 0 {
+		return fmt.Sprintf("%s (%s ago)", t.Format(time.DateTime), -d)
+	}
+	return fmt.Sprintf("%s (in %s)", t.Format(time.DateTime), d)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -3,12 +3,14 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"runtime"
 
 	"github.com/canonical/authd/internal/consts"
 	"github.com/canonical/authd/internal/daemon"
 	"github.com/canonical/authd/internal/decorate"
+	"github.com/canonical/authd/internal/fips"
 	"github.com/canonical/authd/internal/services"
 	"github.com/canonical/authd/internal/services/pam"
 	"github.com/canonical/authd/internal/users"
@@ -30,24 +32,31 @@ type App struct {
 	config  daemonConfig
 
 	daemon *daemon.Daemon
+	// stopScheduledTasks stops the tasks which the daemon runs periodically.
+	stopScheduledTasks func()
 
 	ready chan struct{}
 }
 
 // only overriable for tests.
 type systemPaths struct {
-	BrokersConf string
-	Database    string
-	Socket      string
+	BrokersConf  string
+	Database     string
+	Socket       string
+	UserDBSocket string
 }
 
 // daemonConfig defines configuration parameters of the daemon.
 type daemonConfig struct {
-	Brokers     []string
-	Verbosity   int
-	Paths       systemPaths
-	UsersConfig *users.Config `mapstructure:",squash" yaml:",inline"`
-	PAMConfig   *pam.Config   `mapstructure:",squash" yaml:",inline"`
+	Brokers    []string
+	Verbosity  int
+	LogSecrets bool `mapstructure:"log_secrets" yaml:"log_secrets"`
+	FIPSMode   bool `mapstructure:"fips_mode" yaml:"fips_mode"`
+	// MetricsListen is the unix socket path or the loopback address on which the metrics are served, if set.
+	MetricsListen string `mapstructure:"metrics_listen" yaml:"metrics_listen"`
+	Paths         systemPaths
+	UsersConfig   *users.Config `mapstructure:",squash" yaml:",inline"`
+	PAMConfig     *pam.Config   `mapstructure:",squash" yaml:",inline"`
 }
 
 type options struct {
@@ -88,9 +97,10 @@ func New(args ...Option) *App {
 			// Set config defaults
 			a.config = daemonConfig{
 				Paths: systemPaths{
-					BrokersConf: consts.DefaultBrokersConfPath,
-					Database:    consts.DefaultDatabaseDir,
-					Socket:      "",
+					BrokersConf:  consts.DefaultBrokersConfPath,
+					Database:     consts.DefaultDatabaseDir,
+					Socket:       "",
+					UserDBSocket: consts.DefaultUserDBSocketPath,
 				},
 				UsersConfig: &users.DefaultConfig,
 				PAMConfig:   &pam.DefaultConfig,
@@ -106,6 +116,13 @@ func New(args ...Option) *App {
 
 			setVerboseMode(a.config.Verbosity)
 			log.Debugf(context.Background(), "Verbosity: %d", a.config.Verbosity)
+			log.SetLogSecrets(a.config.LogSecrets)
+			if a.config.LogSecrets {
+				log.Warning(context.Background(), "Secrets are not redacted from the logs. This must only be used for development.")
+			}
+			if err := checkFIPSMode(a.config.FIPSMode); err != nil {
+				return err
+			}
 
 			// If we are only checking the configuration, we exit now.
 			if check, _ := cmd.Flags().GetBool("check-config"); check {
@@ -131,6 +148,7 @@ func New(args ...Option) *App {
 
 	installVerbosityFlag(&a.rootCmd, a.viper)
 	installConfigFlag(&a.rootCmd)
+	installLogSecretsFlag(&a.rootCmd, a.viper)
 	// Install the --check-config flag to check the configuration and exit.
 	a.rootCmd.Flags().Bool("check-config", false /*i18n.G(*/, "check configuration and exit" /*)*/)
 
@@ -168,6 +186,20 @@ func (a *App) serve(config daemonConfig) error {
 	// We are closing the database on exit.
 	defer func() { _ = m.Stop() }()
 
+	if config.Paths.UserDBSocket != "" {
+		if err := m.ServeUserDB(ctx, config.Paths.UserDBSocket); err != nil {
+			close(a.ready)
+			return err
+		}
+	}
+
+	if config.MetricsListen != "" {
+		if err := m.ServeMetrics(ctx, config.MetricsListen); err != nil {
+			close(a.ready)
+			return err
+		}
+	}
+
 	socketPath := config.Paths.Socket
 	var daemonopts []daemon.Option
 	if socketPath != "" {
@@ -181,11 +213,42 @@ func (a *App) serve(config daemonConfig) error {
 	}
 
 	a.daemon = daemon
+	a.stopScheduledTasks = m.StopScheduledTasks
 	close(a.ready)
 
+	// The database can't be used anymore once it's rolled back, so we stop until the previous version of authd is
+	// installed, instead of serving the users and groups from a closed database.
+	served := make(chan struct{})
+	defer close(served)
+	go func() {
+		select {
+		case <-m.DatabaseRolledBack():
+		case <-served:
+			return
+		}
+		log.Warning(ctx, "The database was rolled back, stopping authd")
+		daemon.Quit(ctx, false)
+	}()
+
 	return daemon.Serve(ctx)
 }
 
+// checkFIPSMode returns an error if the FIPS mode is enabled but can't be enforced.
+func checkFIPSMode(enabled bool) error {
+	if !enabled {
+		if fips.KernelEnabled() {
+			log.Warning(context.Background(), "The kernel runs in FIPS mode, but the FIPS mode of authd is not enabled")
+		}
+		return nil
+	}
+
+	if err := fips.Check(); err != nil {
+		return err
+	}
+	log.Info(context.Background(), "FIPS mode is enabled")
+	return nil
+}
+
 // installVerbosityFlag adds the -v and -vv options and returns the reference to it.
 func installVerbosityFlag(cmd *cobra.Command, viper *viper.Viper) *int {
 	r := cmd.PersistentFlags().CountP("verbosity", "v" /*i18n.G(*/, "issue INFO (-v), DEBUG (-vv) or DEBUG with caller (-vvv) output") //)
@@ -193,6 +256,12 @@ func installVerbosityFlag(cmd *cobra.Command, viper *viper.Viper) *int {
 	return r
 }
 
+func installLogSecretsFlag(cmd *cobra.Command, viper *viper.Viper) *bool {
+	r := cmd.PersistentFlags().Bool("log-secrets", false /*i18n.G(*/, "do not redact secrets (tokens, passwords…) from the logs, for development only") //)
+	decorate.LogOnError(viper.BindPFlag("log_secrets", cmd.PersistentFlags().Lookup("log-secrets")))
+	return r
+}
+
 // Run executes the command and associated process. It returns an error on syntax/usage error.
 func (a *App) Run() error {
 	return a.rootCmd.Execute()
@@ -220,6 +289,23 @@ func (a *App) Quit() {
 	a.daemon.Quit(context.Background(), false)
 }
 
+// Handover hands the socket over to a new daemon started from the current executable, e.g. after a package upgrade.
+// The daemon keeps serving its open connections and quits once they are closed.
+func (a *App) Handover() error {
+	a.WaitReady()
+	if a.daemon == nil {
+		return errors.New("the daemon is not running")
+	}
+	if err := a.daemon.Handover(context.Background()); err != nil {
+		return err
+	}
+
+	// The new daemon runs the scheduled tasks now, so that both don't write to the database for them while we keep
+	// serving the open connections.
+	a.stopScheduledTasks()
+	return nil
+}
+
 // WaitReady signals when the daemon is ready
 // Note: we need to use a pointer to not copy the App object before the daemon is ready, and thus, creates a data race.
 func (a *App) WaitReady() {
@@ -2,6 +2,8 @@ package daemon_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/fips140"
 	"fmt"
 	"io"
 	"os"
@@ -12,13 +14,20 @@ import (
 	"time"
 
 	"github.com/canonical/authd/cmd/authd/daemon"
+	"github.com/canonical/authd/internal/alerts"
 	"github.com/canonical/authd/internal/consts"
 	"github.com/canonical/authd/internal/fileutils"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/services/pam"
+	"github.com/canonical/authd/internal/services/permissions"
 	"github.com/canonical/authd/internal/testutils"
 	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
 	userslocking "github.com/canonical/authd/internal/users/locking"
 	"github.com/canonical/authd/log"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func TestHelp(t *testing.T) {
@@ -80,6 +89,43 @@ func TestCanQuitWhenExecute(t *testing.T) {
 	a.Quit()
 }
 
+func TestQuitsWhenTheDatabaseIsRolledBack(t *testing.T) {
+	t.Parallel()
+
+	// The database was migrated from the schema version 1 by the current version of authd, which backed it up.
+	dbDir := t.TempDir()
+	//nolint: gosec // This is a directory owned only by the current user for tests.
+	require.NoError(t, os.Chmod(dbDir, 0700), "Setup: could not change permission on database directory")
+	sqlDump := filepath.Join("..", "..", "..", "internal", "users", "db", "testdata",
+		"TestMigrationAddLockedColumnToUsersTable", "one_user_and_group_without_locked_column.sql")
+	require.NoError(t, db.Z_ForTests_CreateDBFromDump(sqlDump, dbDir), "Setup: could not create database")
+
+	a, wait := startDaemon(t, &daemon.DaemonConfig{Paths: daemon.SystemPaths{Database: dbDir}})
+	stopped := make(chan struct{})
+	go func() {
+		wait()
+		close(stopped)
+	}()
+
+	conn, err := grpc.NewClient("unix://"+a.Config().Paths.Socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err, "Setup: could not connect to the daemon")
+	t.Cleanup(func() { _ = conn.Close() })
+	client := authd.NewUserServiceClient(conn)
+
+	resp, err := client.RollBackDatabase(context.Background(), &authd.Empty{})
+	require.NoError(t, err, "RollBackDatabase should not return an error")
+	require.Equal(t, uint32(1), resp.GetToVersion(), "The database should be rolled back to the schema of the backup")
+
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "The daemon should stop once the database is rolled back")
+	}
+
+	_, err = client.GetUserByName(context.Background(), &authd.GetUserByNameRequest{Name: "user1"})
+	require.Error(t, err, "The daemon should not serve the users anymore")
+}
+
 func TestCanQuitTwice(t *testing.T) {
 	t.Parallel()
 
@@ -275,6 +321,44 @@ func TestConfigLoad(t *testing.T) {
 	require.Equal(t, wantUsersConfig, a.Config().UsersConfig, "Unexpected users config")
 }
 
+func TestConfigLoadAlerts(t *testing.T) {
+	wantPAMConfig := pam.DefaultConfig
+	wantPAMConfig.Alerts = alerts.Config{
+		WebhookURL:             "https://example.com/hook",
+		Email:                  "admin@example.com",
+		SendmailPath:           "/usr/bin/msmtp",
+		UserFailureThreshold:   3,
+		GlobalFailureThreshold: 50,
+		Window:                 time.Hour,
+	}
+	var config daemon.DaemonConfig
+	config.PAMConfig = &wantPAMConfig
+
+	a := daemon.New()
+	a.SetArgs("--check-config", "--config", daemon.GenerateTestConfig(t, &config))
+
+	err := a.Run()
+	require.NoError(t, err, "Run should not return an error")
+
+	require.Equal(t, &wantPAMConfig, a.Config().PAMConfig, "Unexpected PAM config")
+}
+
+func TestConfigFIPSMode(t *testing.T) {
+	var config daemon.DaemonConfig
+	config.FIPSMode = true
+
+	a := daemon.New()
+	a.SetArgs("--check-config", "--config", daemon.GenerateTestConfig(t, &config))
+
+	err := a.Run()
+	if !fips140.Enabled() {
+		require.Error(t, err, "Run should fail if the Go cryptographic module is not in FIPS 140 mode")
+		return
+	}
+	require.NoError(t, err, "Run should not return an error")
+	require.True(t, a.Config().FIPSMode, "FIPS mode is set from config")
+}
+
 func TestAutoDetectConfig(t *testing.T) {
 	var config daemon.DaemonConfig
 	config.Verbosity = 1
@@ -312,6 +396,7 @@ func TestNoConfigSetDefaults(t *testing.T) {
 	require.Equal(t, consts.DefaultDatabaseDir, a.Config().Paths.Database, "Default database directory")
 	require.Equal(t, &users.DefaultConfig, a.Config().UsersConfig, "Default Users Config")
 	require.Equal(t, "", a.Config().Paths.Socket, "No socket address as default")
+	require.Equal(t, consts.DefaultUserDBSocketPath, a.Config().Paths.UserDBSocket, "Default userdb socket path")
 }
 
 func TestBadConfigReturnsError(t *testing.T) {
@@ -404,5 +489,8 @@ func TestMain(m *testing.M) {
 	userslocking.Z_ForTests_OverrideLocking()
 	defer userslocking.Z_ForTests_RestoreLocking()
 
+	// The database can only be rolled back by root.
+	permissions.Z_ForTests_DefaultCurrentUserAsRoot()
+
 	m.Run()
 }
@@ -26,6 +26,7 @@ type app interface {
 	Run() error
 	UsageError() bool
 	Hup() bool
+	Handover() error
 	Quit()
 }
 
@@ -46,7 +47,7 @@ func run(a app) int {
 
 func installSignalHandler(a app) func() {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR2)
 
 	wg := sync.WaitGroup{}
 	wg.Add(1)
@@ -65,6 +66,12 @@ func installSignalHandler(a app) func() {
 					return
 				}
 				log.Info(context.Background(), "Received SIGHUP, but nothing to do")
+			case syscall.SIGUSR2:
+				// We keep handling the signals after a handover, as we still serve the open connections.
+				log.Info(context.Background(), "Received SIGUSR2, handing the socket over to a new daemon...")
+				if err := a.Handover(); err != nil {
+					log.Errorf(context.Background(), "Handover failed, continuing to serve: %v", err)
+				}
 			default:
 				// channel was closed: we exited
 				if !ok {
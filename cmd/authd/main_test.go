@@ -15,6 +15,9 @@ type myApp struct {
 	runError         bool
 	usageErrorReturn bool
 	hupReturn        bool
+	handoverError    bool
+
+	handedOver chan struct{}
 }
 
 func (a *myApp) Run() error {
@@ -33,6 +36,14 @@ func (a myApp) Hup() bool {
 	return a.hupReturn
 }
 
+func (a *myApp) Handover() error {
+	close(a.handedOver)
+	if a.handoverError {
+		return errors.New("Error requested")
+	}
+	return nil
+}
+
 func (a *myApp) Quit() {
 	close(a.done)
 }
@@ -45,6 +56,7 @@ func TestRun(t *testing.T) {
 		runError         bool
 		usageErrorReturn bool
 		hupReturn        bool
+		handoverError    bool
 		sendSig          syscall.Signal
 
 		wantReturnCode int
@@ -55,10 +67,12 @@ func TestRun(t *testing.T) {
 		"Run_and_usage_error_only_does_not_fail": {usageErrorReturn: true, runError: false, wantReturnCode: 0},
 
 		// Signals handling
-		"Send_SIGINT_exits":           {sendSig: syscall.SIGINT},
-		"Send_SIGTERM_exits":          {sendSig: syscall.SIGTERM},
-		"Send_SIGHUP_without_exiting": {sendSig: syscall.SIGHUP},
-		"Send_SIGHUP_with_exit":       {sendSig: syscall.SIGHUP, hupReturn: true},
+		"Send_SIGINT_exits":                              {sendSig: syscall.SIGINT},
+		"Send_SIGTERM_exits":                             {sendSig: syscall.SIGTERM},
+		"Send_SIGHUP_without_exiting":                    {sendSig: syscall.SIGHUP},
+		"Send_SIGHUP_with_exit":                          {sendSig: syscall.SIGHUP, hupReturn: true},
+		"Send_SIGUSR2_hands_over_without_exiting":        {sendSig: syscall.SIGUSR2},
+		"Send_SIGUSR2_without_exiting_on_handover_error": {sendSig: syscall.SIGUSR2, handoverError: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -67,6 +81,8 @@ func TestRun(t *testing.T) {
 				runError:         tc.runError,
 				usageErrorReturn: tc.usageErrorReturn,
 				hupReturn:        tc.hupReturn,
+				handoverError:    tc.handoverError,
+				handedOver:       make(chan struct{}),
 			}
 
 			var rc int
@@ -104,6 +120,22 @@ func TestRun(t *testing.T) {
 				// if SIGHUP returns false: do nothing and still wait.
 				// Otherwise, it means that we wanted to stop
 				require.Equal(t, tc.hupReturn, exited, "Expect to exit only on SIGHUP returning True")
+			case syscall.SIGUSR2:
+				err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+				require.NoError(t, err, "Teardown: kill should return no error")
+				select {
+				case <-a.handedOver:
+				case <-time.After(time.Second):
+					t.Fatal("Expect to hand over on SIGUSR2")
+				}
+				select {
+				case <-time.After(50 * time.Millisecond):
+					exited = false
+				case <-wait:
+					exited = true
+				}
+				// The app quits by itself once its connections are closed after a handover.
+				require.False(t, exited, "Expect to not exit on SIGUSR2")
 			}
 
 			if !exited {
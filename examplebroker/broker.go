@@ -829,7 +829,7 @@ func (b *Broker) handleIsAuthenticated(ctx context.Context, sessionInfo sessionI
 			return auth.Retry, fmt.Sprintf(`{"message": "new password does not match criteria: must be '%s'"}`, expectedSecret)
 		}
 		exampleUsersMu.Lock()
-		log.Debugf(context.TODO(), "Password for user %q changed to %q", sessionInfo.username, secret)
+		log.Debugf(context.TODO(), "Password for user %q changed to %q", sessionInfo.username, log.Secret(secret))
 		exampleUsers[sessionInfo.username] = userInfoBroker{Password: secret}
 		exampleUsersMu.Unlock()
 
@@ -0,0 +1,116 @@
+// Package accountsservice publishes the users of authd to AccountsService, so that the greeter of the display manager
+// lists them with their picture.
+package accountsservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/canonical/authd/log"
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	dbusName      = "org.freedesktop.Accounts"
+	accountsPath  = dbus.ObjectPath("/org/freedesktop/Accounts")
+	accountsIface = "org.freedesktop.Accounts"
+	userIface     = "org.freedesktop.Accounts.User"
+	// errNoService is returned by the bus when AccountsService is not installed.
+	errNoService = "org.freedesktop.DBus.Error.ServiceUnknown"
+)
+
+// Client publishes the users to AccountsService on the system bus.
+type Client struct {
+	picturesDir string
+
+	conn   *dbus.Conn
+	connMu sync.Mutex
+}
+
+// New returns a Client storing the pictures of the users in picturesDir, from which AccountsService copies them. It
+// only connects to the system bus on first use.
+func New(picturesDir string) *Client {
+	return &Client{picturesDir: picturesDir}
+}
+
+// Publish makes AccountsService list the user, whose real name is their gecos, and sets their picture if they have
+// one. Nothing is done if AccountsService is not installed.
+func (c *Client) Publish(ctx context.Context, username string, picture []byte) error {
+	if username == "" || filepath.Base(username) != username {
+		return fmt.Errorf("invalid username %q", username)
+	}
+
+	user, err := c.cacheUser(ctx, username)
+	if isDBusError(err, errNoService) {
+		log.Debugf(ctx, "Not publishing user %q: AccountsService is not installed", username)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if picture == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(c.picturesDir, 0700); err != nil {
+		return fmt.Errorf("could not create the directory of the pictures: %v", err)
+	}
+	// The picture is written atomically, as AccountsService may be copying the previous one.
+	path := filepath.Join(c.picturesDir, username)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, picture, 0600); err != nil {
+		return fmt.Errorf("could not store the picture of %q: %v", username, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("could not store the picture of %q: %v", username, err)
+	}
+	if err := user.CallWithContext(ctx, userIface+".SetIconFile", 0, path).Err; err != nil {
+		return fmt.Errorf("could not set the picture of %q: %v", username, err)
+	}
+	log.Debugf(ctx, "Published the picture of user %q to AccountsService", username)
+	return nil
+}
+
+// cacheUser makes AccountsService remember the user, who is not listed in /etc/passwd, and returns their object.
+func (c *Client) cacheUser(ctx context.Context, username string) (dbus.BusObject, error) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	if c.conn == nil {
+		// Don't call dbus.SystemBus which caches globally system dbus (issues in tests)
+		conn, err := dbus.ConnectSystemBus()
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to the system bus: %v", err)
+		}
+		c.conn = conn
+	}
+
+	var path dbus.ObjectPath
+	if err := c.conn.Object(dbusName, accountsPath).CallWithContext(ctx, accountsIface+".CacheUser", 0, username).Store(&path); err != nil {
+		return nil, fmt.Errorf("could not publish user %q to AccountsService: %w", username, err)
+	}
+	return c.conn.Object(dbusName, path), nil
+}
+
+// Close closes the connection to the system bus, if any.
+func (c *Client) Close() error {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func isDBusError(err error, name string) bool {
+	var dbusErr dbus.Error
+	return errors.As(err, &dbusErr) && dbusErr.Name == name
+}
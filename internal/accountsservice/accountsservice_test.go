@@ -0,0 +1,141 @@
+package accountsservice_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/canonical/authd/internal/accountsservice"
+	"github.com/canonical/authd/internal/testutils"
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// accountsServiceMock records the users cached and the pictures set through the AccountsService interfaces.
+type accountsServiceMock struct {
+	mu       sync.Mutex
+	cached   []string
+	pictures map[string]string
+	conn     *dbus.Conn
+}
+
+func (a *accountsServiceMock) CacheUser(name string) (dbus.ObjectPath, *dbus.Error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if name == "unknown-user" {
+		return "", dbus.NewError("org.freedesktop.Accounts.Error.UserDoesNotExist", []any{"no such user"})
+	}
+	a.cached = append(a.cached, name)
+	path := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/Accounts/User%d", len(a.cached)))
+	if err := a.conn.Export(accountsServiceUserMock{a, name}, path, "org.freedesktop.Accounts.User"); err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return path, nil
+}
+
+type accountsServiceUserMock struct {
+	a    *accountsServiceMock
+	name string
+}
+
+func (u accountsServiceUserMock) SetIconFile(path string) *dbus.Error {
+	u.a.mu.Lock()
+	defer u.a.mu.Unlock()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	u.a.pictures[u.name] = string(content)
+	return nil
+}
+
+func TestPublishWithoutAccountsService(t *testing.T) {
+	c := accountsservice.New(t.TempDir())
+	t.Cleanup(func() { _ = c.Close() })
+
+	err := c.Publish(context.Background(), "user@example.com", []byte("picture"))
+	require.NoError(t, err, "Publish should not fail if AccountsService is not installed")
+}
+
+func TestPublish(t *testing.T) {
+	a := startAccountsServiceMock(t)
+
+	tests := map[string]struct {
+		username string
+		picture  string
+
+		wantPicture bool
+		wantErr     bool
+	}{
+		"Publish_user_with_picture":    {username: "user1@example.com", picture: "picture1", wantPicture: true},
+		"Publish_user_without_picture": {username: "user2@example.com"},
+
+		"Error_when_username_is_a_path":               {username: "../user3", picture: "picture3", wantErr: true},
+		"Error_when_AccountsService_does_not_know_it": {username: "unknown-user", picture: "picture4", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			picturesDir := filepath.Join(t.TempDir(), "pictures")
+			c := accountsservice.New(picturesDir)
+			t.Cleanup(func() { _ = c.Close() })
+
+			var picture []byte
+			if tc.picture != "" {
+				picture = []byte(tc.picture)
+			}
+			err := c.Publish(context.Background(), tc.username, picture)
+			if tc.wantErr {
+				require.Error(t, err, "Publish should return an error")
+				require.NoDirExists(t, picturesDir, "Publish should not store the picture on error")
+				return
+			}
+			require.NoError(t, err, "Publish should not return an error")
+
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			require.Contains(t, a.cached, tc.username, "User should be cached by AccountsService")
+			if !tc.wantPicture {
+				require.NotContains(t, a.pictures, tc.username, "Picture should not be set")
+				return
+			}
+			require.Equal(t, tc.picture, a.pictures[tc.username], "Picture should be set")
+			got, err := os.ReadFile(filepath.Join(picturesDir, tc.username))
+			require.NoError(t, err, "Picture should be stored")
+			require.Equal(t, tc.picture, string(got), "Stored picture should be the published one")
+		})
+	}
+}
+
+// startAccountsServiceMock serves a mock of AccountsService on the system bus mock until the test ends.
+func startAccountsServiceMock(t *testing.T) *accountsServiceMock {
+	t.Helper()
+
+	conn, err := testutils.GetSystemBusConnection(t)
+	require.NoError(t, err, "Setup: could not connect to the system bus")
+	t.Cleanup(func() { _ = conn.Close() })
+
+	a := &accountsServiceMock{conn: conn, pictures: make(map[string]string)}
+	err = conn.Export(a, "/org/freedesktop/Accounts", "org.freedesktop.Accounts")
+	require.NoError(t, err, "Setup: could not export the AccountsService mock")
+	reply, err := conn.RequestName("org.freedesktop.Accounts", dbus.NameFlagDoNotQueue)
+	require.NoError(t, err, "Setup: could not request the AccountsService name")
+	require.Equal(t, dbus.RequestNameReplyPrimaryOwner, reply, "Setup: AccountsService name should be free")
+
+	return a
+}
+
+func TestMain(m *testing.M) {
+	cleanup, err := testutils.StartSystemBusMock()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	defer cleanup()
+
+	m.Run()
+}
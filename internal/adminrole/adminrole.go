@@ -0,0 +1,247 @@
+// Package adminrole grants administrator rights on the machine to the members of some groups of the identity
+// provider, and revokes them once the users are no longer members of these groups.
+package adminrole
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+const (
+	// GrantGroup grants the administrator rights by adding the users to a local group, such as sudo.
+	GrantGroup = "group"
+	// GrantSudoers grants the administrator rights with a sudoers drop-in for each user.
+	GrantSudoers = "sudoers"
+
+	// sudoersFilePrefix is the prefix of the sudoers drop-ins written by authd, followed by the UID of the user. sudo
+	// ignores the files whose name contains a dot, so the drop-ins are written in a temporary file with a suffix first.
+	sudoersFilePrefix = "authd-admin-"
+)
+
+// Config holds how the administrators of the machine are determined and granted their rights.
+type Config struct {
+	// Groups are the groups of the identity provider whose members are administrators of the machine. If empty, no
+	// user is granted administrator rights, and the rights granted before are revoked on the next login of the users.
+	Groups []string `mapstructure:"admin_groups" yaml:"admin_groups,omitempty"`
+	// Grant is how the administrator rights are granted, either GrantGroup or GrantSudoers.
+	Grant string `mapstructure:"admin_grant" yaml:"admin_grant,omitempty"`
+	// LocalGroup is the local group which the administrators are added to with GrantGroup.
+	LocalGroup string `mapstructure:"admin_local_group" yaml:"admin_local_group,omitempty"`
+}
+
+// DefaultConfig is the default configuration of the administrator rights, granted with the sudo group.
+var DefaultConfig = Config{
+	Grant:      GrantGroup,
+	LocalGroup: "sudo",
+}
+
+// Validate returns an error if the configuration is invalid.
+func (c Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	switch c.Grant {
+	case GrantGroup:
+		if c.LocalGroup == "" || strings.ContainsAny(c.LocalGroup, ":, ") {
+			return fmt.Errorf("invalid admin_local_group %q", c.LocalGroup)
+		}
+	case GrantSudoers:
+	default:
+		return fmt.Errorf("invalid admin_grant %q, must be %s or %s", c.Grant, GrantGroup, GrantSudoers)
+	}
+	return nil
+}
+
+// Enabled returns true if the members of some groups are administrators.
+func (c Config) Enabled() bool {
+	return len(c.Groups) > 0
+}
+
+// AdminGroups returns the groups of the identity provider which make a user with the given groups an administrator.
+//
+// Like for the login policy, the local groups which the broker adds the user to are not taken into account.
+func (c Config) AdminGroups(groups []types.GroupInfo) []string {
+	var matched []string
+	for _, g := range groups {
+		if g.UGID == "" {
+			continue
+		}
+		// authd uses lowercase group names.
+		if slices.ContainsFunc(c.Groups, func(admin string) bool { return strings.EqualFold(admin, g.Name) }) {
+			matched = append(matched, g.Name)
+		}
+	}
+	return matched
+}
+
+// ChangeType is the type of a change of the administrator rights of a user.
+type ChangeType string
+
+const (
+	// Granted is reported when a user became an administrator.
+	Granted ChangeType = "granted"
+	// Revoked is reported when a user is no longer an administrator.
+	Revoked ChangeType = "revoked"
+)
+
+// Change describes a grant or a revocation of the administrator rights of a user.
+type Change struct {
+	Type     ChangeType
+	Username string
+	UID      uint32
+	BrokerID string
+	// Groups are the groups of the identity provider which made the user an administrator, empty when revoked.
+	Groups []string
+	// Grant is how the rights were granted or revoked.
+	Grant string
+}
+
+// Summary returns a one line description of the change.
+func (c Change) Summary() string {
+	s := fmt.Sprintf("administrator rights %s for user %q (UID %d, broker %q, %s", c.Type, c.Username, c.UID, c.BrokerID, c.Grant)
+	if len(c.Groups) > 0 {
+		s += fmt.Sprintf(", member of %s", strings.Join(c.Groups, ", "))
+	}
+	return s + ")"
+}
+
+// Role grants and revokes the administrator rights of the users when they log in.
+type Role struct {
+	cfg        Config
+	sudoersDir string
+}
+
+// New returns a new Role granting the rights as configured in cfg.
+func New(cfg Config) *Role {
+	return &Role{cfg: cfg, sudoersDir: "/etc/sudoers.d"}
+}
+
+// Prepare adds the local group of the administrators to the groups of the user before they are stored, if the user
+// is an administrator and the rights are granted with a group. When the user is no longer an administrator, the group
+// is not added, so that the user is removed from it along with the other local groups they're no longer a member of.
+func (r *Role) Prepare(u types.UserInfo) types.UserInfo {
+	if r.cfg.Grant != GrantGroup || len(r.cfg.AdminGroups(u.Groups)) == 0 {
+		return u
+	}
+	if hasLocalGroup(u.Groups, r.cfg.LocalGroup) {
+		return u
+	}
+
+	u.Groups = append(slices.Clone(u.Groups), types.GroupInfo{Name: r.cfg.LocalGroup})
+	return u
+}
+
+// Sync brings the administrator rights of the user in line with their groups once they are stored, and logs the
+// changes for auditing. previous is the information stored on their previous login, or nil for a new user, and
+// current the information returned by Prepare.
+func (r *Role) Sync(ctx context.Context, uid uint32, previous *types.UserInfo, current types.UserInfo) ([]Change, error) {
+	adminGroups := r.cfg.AdminGroups(current.Groups)
+	newChange := func(granted bool, grant string) Change {
+		c := Change{Type: Revoked, Username: current.Name, UID: uid, BrokerID: current.BrokerID, Grant: grant}
+		if granted {
+			c.Type = Granted
+			c.Groups = adminGroups
+		}
+		return c
+	}
+
+	var changes []Change
+
+	// The drop-in is also removed when the rights are now granted with a group, so that switching to the group
+	// doesn't leave the drop-ins of the users who are no longer administrators behind.
+	hadDropIn, err := fileExists(r.sudoersPath(uid))
+	if err != nil {
+		return nil, err
+	}
+	wantDropIn := r.cfg.Grant == GrantSudoers && len(adminGroups) > 0
+	if wantDropIn && !hadDropIn {
+		if err := r.writeSudoers(current.Name, uid); err != nil {
+			return nil, err
+		}
+		changes = append(changes, newChange(true, GrantSudoers))
+	}
+	if !wantDropIn && hadDropIn {
+		if err := os.Remove(r.sudoersPath(uid)); err != nil {
+			return nil, fmt.Errorf("could not remove sudoers drop-in of user %q: %w", current.Name, err)
+		}
+		changes = append(changes, newChange(false, GrantSudoers))
+	}
+
+	// The membership of the local group was already updated along with the user. The broker may add the user to
+	// the same group, in which case they stay a member and nothing is reported.
+	if r.cfg.Grant == GrantGroup && r.cfg.Enabled() {
+		was := previous != nil && hasLocalGroup(previous.Groups, r.cfg.LocalGroup)
+		is := hasLocalGroup(current.Groups, r.cfg.LocalGroup)
+		if was != is {
+			changes = append(changes, newChange(is, GrantGroup))
+		}
+	}
+
+	for _, c := range changes {
+		log.Noticef(ctx, "Audit: %s", c.Summary())
+	}
+	return changes, nil
+}
+
+func (r *Role) sudoersPath(uid uint32) string {
+	return filepath.Join(r.sudoersDir, fmt.Sprintf("%s%d", sudoersFilePrefix, uid))
+}
+
+// writeSudoers writes the sudoers drop-in of the user, which identifies them by UID as their name may contain
+// characters which would need to be escaped.
+func (r *Role) writeSudoers(username string, uid uint32) (err error) {
+	content := fmt.Sprintf(`# Written by authd: %q is a member of the administrator groups of the identity provider.
+# This file is removed when they are no longer a member, don't edit it.
+#%d ALL=(ALL:ALL) ALL
+`, username, uid)
+
+	path := r.sudoersPath(uid)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0440); err != nil {
+		return fmt.Errorf("could not write sudoers drop-in of user %q: %w", username, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	// An invalid drop-in would break sudo for all the users, so check it when visudo is available.
+	if visudo, err := exec.LookPath("visudo"); err == nil {
+		// #nosec:G204 - the path is the temporary drop-in written above.
+		if out, err := exec.Command(visudo, "-cf", tmp).CombinedOutput(); err != nil {
+			return fmt.Errorf("invalid sudoers drop-in for user %q: %w: %s", username, err, out)
+		}
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("could not write sudoers drop-in of user %q: %w", username, err)
+	}
+	return nil
+}
+
+func hasLocalGroup(groups []types.GroupInfo, name string) bool {
+	return slices.ContainsFunc(groups, func(g types.GroupInfo) bool { return g.UGID == "" && g.Name == name })
+}
+
+func fileExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,184 @@
+package adminrole_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/adminrole"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cfg adminrole.Config
+
+		wantErr bool
+	}{
+		"Default_configuration":     {cfg: adminrole.DefaultConfig},
+		"Grant_with_group":          {cfg: adminrole.Config{Groups: []string{"admins"}, Grant: adminrole.GrantGroup, LocalGroup: "sudo"}},
+		"Grant_with_sudoers":        {cfg: adminrole.Config{Groups: []string{"admins"}, Grant: adminrole.GrantSudoers}},
+		"Disabled_with_empty_grant": {cfg: adminrole.Config{}},
+
+		"Error_when_grant_is_invalid":       {cfg: adminrole.Config{Groups: []string{"admins"}, Grant: "wheel"}, wantErr: true},
+		"Error_when_local_group_is_empty":   {cfg: adminrole.Config{Groups: []string{"admins"}, Grant: adminrole.GrantGroup}, wantErr: true},
+		"Error_when_local_group_is_invalid": {cfg: adminrole.Config{Groups: []string{"admins"}, Grant: adminrole.GrantGroup, LocalGroup: "su:do"}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.cfg.Validate()
+			if tc.wantErr {
+				require.Error(t, err, "Validate should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "Validate should not return an error, but did")
+		})
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		grant  string
+		groups []types.GroupInfo
+
+		wantGroups []types.GroupInfo
+	}{
+		"Add_local_group_to_administrators": {
+			groups:     []types.GroupInfo{{Name: "admins", UGID: "1"}},
+			wantGroups: []types.GroupInfo{{Name: "admins", UGID: "1"}, {Name: "sudo"}},
+		},
+		"Do_not_add_local_group_twice": {
+			groups:     []types.GroupInfo{{Name: "admins", UGID: "1"}, {Name: "sudo"}},
+			wantGroups: []types.GroupInfo{{Name: "admins", UGID: "1"}, {Name: "sudo"}},
+		},
+		"Do_not_add_local_group_to_other_users": {
+			groups:     []types.GroupInfo{{Name: "employees", UGID: "2"}},
+			wantGroups: []types.GroupInfo{{Name: "employees", UGID: "2"}},
+		},
+		"Do_not_add_local_group_to_members_of_a_local_group_named_like_the_administrator_groups": {
+			groups:     []types.GroupInfo{{Name: "admins"}},
+			wantGroups: []types.GroupInfo{{Name: "admins"}},
+		},
+		"Do_not_add_local_group_with_sudoers": {
+			grant:      adminrole.GrantSudoers,
+			groups:     []types.GroupInfo{{Name: "admins", UGID: "1"}},
+			wantGroups: []types.GroupInfo{{Name: "admins", UGID: "1"}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.grant == "" {
+				tc.grant = adminrole.GrantGroup
+			}
+			cfg := adminrole.Config{Groups: []string{"Admins"}, Grant: tc.grant, LocalGroup: "sudo"}
+
+			got := adminrole.New(cfg).Prepare(types.UserInfo{Name: "user1@example.com", Groups: tc.groups})
+			require.Equal(t, tc.wantGroups, got.Groups, "Prepare should return the expected groups")
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	t.Parallel()
+
+	admin := []types.GroupInfo{{Name: "admins", UGID: "1"}}
+	adminInGroup := []types.GroupInfo{{Name: "admins", UGID: "1"}, {Name: "sudo"}}
+	employee := []types.GroupInfo{{Name: "employees", UGID: "2"}}
+	employeeInGroup := []types.GroupInfo{{Name: "employees", UGID: "2"}, {Name: "sudo"}}
+
+	tests := map[string]struct {
+		grant         string
+		disabled      bool
+		previous      []types.GroupInfo
+		newUser       bool
+		groups        []types.GroupInfo
+		existingFile  bool
+		sudoersDirErr bool
+
+		wantChanges []adminrole.ChangeType
+		wantFile    bool
+		wantErr     bool
+	}{
+		"Grant_new_administrator_with_group":            {newUser: true, groups: adminInGroup, wantChanges: []adminrole.ChangeType{adminrole.Granted}},
+		"Grant_existing_user_with_group":                {previous: employee, groups: adminInGroup, wantChanges: []adminrole.ChangeType{adminrole.Granted}},
+		"Revoke_former_administrator_with_group":        {previous: adminInGroup, groups: employee, wantChanges: []adminrole.ChangeType{adminrole.Revoked}},
+		"Keep_administrator_with_group":                 {previous: adminInGroup, groups: adminInGroup},
+		"Keep_local_group_added_by_the_broker":          {previous: employeeInGroup, groups: employeeInGroup},
+		"Grant_new_administrator_with_sudoers":          {grant: adminrole.GrantSudoers, newUser: true, groups: admin, wantChanges: []adminrole.ChangeType{adminrole.Granted}, wantFile: true},
+		"Keep_administrator_with_sudoers":               {grant: adminrole.GrantSudoers, previous: admin, groups: admin, existingFile: true, wantFile: true},
+		"Revoke_former_administrator_with_sudoers":      {grant: adminrole.GrantSudoers, previous: admin, groups: employee, existingFile: true, wantChanges: []adminrole.ChangeType{adminrole.Revoked}},
+		"Revoke_sudoers_when_switching_to_group":        {previous: admin, groups: adminInGroup, existingFile: true, wantChanges: []adminrole.ChangeType{adminrole.Revoked, adminrole.Granted}},
+		"Revoke_sudoers_when_disabled":                  {grant: adminrole.GrantSudoers, disabled: true, previous: admin, groups: admin, existingFile: true, wantChanges: []adminrole.ChangeType{adminrole.Revoked}},
+		"Do_not_report_local_group_when_disabled":       {disabled: true, previous: adminInGroup, groups: admin},
+		"Do_not_grant_other_users_with_sudoers":         {grant: adminrole.GrantSudoers, newUser: true, groups: employee},
+		"Do_not_grant_other_users_with_group":           {newUser: true, groups: employee},
+		"Error_when_sudoers_drop_in_can_not_be_written": {grant: adminrole.GrantSudoers, newUser: true, groups: admin, sudoersDirErr: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.grant == "" {
+				tc.grant = adminrole.GrantGroup
+			}
+			cfg := adminrole.Config{Groups: []string{"admins"}, Grant: tc.grant, LocalGroup: "sudo"}
+			if tc.disabled {
+				cfg.Groups = nil
+			}
+
+			dir := t.TempDir()
+			sudoersPath := filepath.Join(dir, "authd-admin-1111")
+			if tc.existingFile {
+				require.NoError(t, os.WriteFile(sudoersPath, []byte("# Written by authd.\n#1111 ALL=(ALL:ALL) ALL\n"), 0440), "Setup: could not write drop-in")
+			}
+			if tc.sudoersDirErr {
+				dir = filepath.Join(dir, "does-not-exist")
+			}
+
+			var previous *types.UserInfo
+			if !tc.newUser {
+				previous = &types.UserInfo{Name: "user1@example.com", BrokerID: "broker-id", Groups: tc.previous}
+			}
+			current := types.UserInfo{Name: "user1@example.com", BrokerID: "broker-id", Groups: tc.groups}
+
+			changes, err := adminrole.NewWithSudoersDir(cfg, dir).Sync(context.Background(), 1111, previous, current)
+			if tc.wantErr {
+				require.Error(t, err, "Sync should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "Sync should not return an error, but did")
+
+			var gotChanges []adminrole.ChangeType
+			for _, c := range changes {
+				require.Equal(t, "user1@example.com", c.Username, "Change should be for the user")
+				require.Equal(t, uint32(1111), c.UID, "Change should have the UID of the user")
+				if c.Type == adminrole.Granted {
+					require.Equal(t, []string{"admins"}, c.Groups, "Grant should have the administrator groups of the user")
+				}
+				gotChanges = append(gotChanges, c.Type)
+			}
+			require.Equal(t, tc.wantChanges, gotChanges, "Sync should return the expected changes")
+
+			if !tc.wantFile {
+				require.NoFileExists(t, sudoersPath, "Sync should not leave a sudoers drop-in")
+				return
+			}
+			content, err := os.ReadFile(sudoersPath)
+			require.NoError(t, err, "Sync should write a sudoers drop-in")
+			require.Contains(t, string(content), "\n#1111 ALL=(ALL:ALL) ALL\n", "Drop-in should grant the rights to the UID of the user")
+			info, err := os.Stat(sudoersPath)
+			require.NoError(t, err, "Sync should write a sudoers drop-in")
+			require.Equal(t, os.FileMode(0440), info.Mode().Perm(), "Drop-in should only be readable")
+		})
+	}
+}
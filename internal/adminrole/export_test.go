@@ -0,0 +1,8 @@
+package adminrole
+
+// NewWithSudoersDir returns a new Role writing the sudoers drop-ins to dir.
+func NewWithSudoersDir(cfg Config, dir string) *Role {
+	r := New(cfg)
+	r.sudoersDir = dir
+	return r
+}
@@ -0,0 +1,363 @@
+// Package alerts notifies administrators about suspicious authentication activity.
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+// maxTrackedUsers is the maximum number of distinct usernames tracked simultaneously
+// to bound memory usage.
+var maxTrackedUsers = 10000
+
+// sendTimeout is the maximum duration for delivering a single alert.
+var sendTimeout = 10 * time.Second
+
+// Config holds the configurable parameters for alerting.
+type Config struct {
+	// WebhookURL is the URL to which alerts are POSTed as JSON.
+	WebhookURL string `mapstructure:"alert_webhook_url" yaml:"alert_webhook_url"`
+	// Email is the address to which alerts are mailed using SendmailPath.
+	Email string `mapstructure:"alert_email" yaml:"alert_email"`
+	// SendmailPath is the path of the sendmail compatible command used to send alert emails.
+	SendmailPath string `mapstructure:"alert_sendmail_path" yaml:"alert_sendmail_path"`
+	// UserFailureThreshold is the number of authentication failures of a single user within
+	// Window which triggers an alert. 0 disables per-user alerts.
+	UserFailureThreshold int `mapstructure:"alert_user_failure_threshold" yaml:"alert_user_failure_threshold"`
+	// GlobalFailureThreshold is the number of authentication failures of all users within
+	// Window which triggers an alert. 0 disables global alerts.
+	GlobalFailureThreshold int `mapstructure:"alert_global_failure_threshold" yaml:"alert_global_failure_threshold"`
+	// Window is the duration over which failures are counted. After an alert is sent, no
+	// similar alert is sent for the same user (or globally) until Window has elapsed.
+	Window time.Duration `mapstructure:"alert_window" yaml:"alert_window"`
+}
+
+// DefaultConfig is the default configuration for alerting. No alert is sent unless a
+// webhook URL or an email address is configured.
+var DefaultConfig = Config{
+	SendmailPath:           "/usr/sbin/sendmail",
+	UserFailureThreshold:   5,
+	GlobalFailureThreshold: 20,
+	Window:                 10 * time.Minute,
+}
+
+// Enabled returns true if a destination for the alerts is configured.
+func (c Config) Enabled() bool {
+	return c.WebhookURL != "" || c.Email != ""
+}
+
+// Kind is the kind of event which triggered an alert.
+type Kind string
+
+const (
+	// KindUserFailures is sent when a user exceeded the per-user failure threshold.
+	KindUserFailures Kind = "user_failures"
+	// KindGlobalFailures is sent when all users together exceeded the global failure threshold.
+	KindGlobalFailures Kind = "global_failures"
+	// KindLockedUser is sent when a locked user successfully authenticated with the broker.
+	KindLockedUser Kind = "locked_user"
+	// KindAdminGranted is sent when a user was granted administrator rights.
+	KindAdminGranted Kind = "admin_granted"
+	// KindAdminRevoked is sent when the administrator rights of a user were revoked.
+	KindAdminRevoked Kind = "admin_revoked"
+)
+
+// Alert is the payload sent to the webhook or by email.
+type Alert struct {
+	Kind     Kind      `json:"kind"`
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname"`
+	Username string    `json:"username,omitempty"`
+	BrokerID string    `json:"broker_id,omitempty"`
+	Service  string    `json:"service,omitempty"`
+	Failures int       `json:"failures,omitempty"`
+	Window   string    `json:"window,omitempty"`
+	// Groups are the groups of the identity provider which made the user an administrator.
+	Groups []string `json:"groups,omitempty"`
+}
+
+// Summary returns a one line description of the alert.
+func (a Alert) Summary() string {
+	switch a.Kind {
+	case KindUserFailures:
+		return fmt.Sprintf("%d authentication failures for user %q in %s", a.Failures, a.Username, a.Window)
+	case KindGlobalFailures:
+		return fmt.Sprintf("%d authentication failures in %s", a.Failures, a.Window)
+	case KindLockedUser:
+		return fmt.Sprintf("authentication attempt for locked user %q", a.Username)
+	case KindAdminGranted:
+		return fmt.Sprintf("administrator rights granted to user %q", a.Username)
+	case KindAdminRevoked:
+		return fmt.Sprintf("administrator rights revoked from user %q", a.Username)
+	}
+	return string(a.Kind)
+}
+
+// Event describes an authentication attempt reported to the Notifier.
+type Event struct {
+	Username string
+	BrokerID string
+	Service  string
+}
+
+type failures struct {
+	times     []time.Time
+	lastAlert time.Time
+}
+
+// record adds a failure at now, drops the failures older than window and returns whether an alert
+// should be sent, because threshold is reached and no alert was sent in the last window.
+func (f *failures) record(now time.Time, window time.Duration, threshold int) bool {
+	f.times = append(f.times, now)
+	i := 0
+	for i < len(f.times) && now.Sub(f.times[i]) >= window {
+		i++
+	}
+	f.times = f.times[i:]
+
+	if len(f.times) < threshold || (!f.lastAlert.IsZero() && now.Sub(f.lastAlert) < window) {
+		return false
+	}
+	f.lastAlert = now
+	return true
+}
+
+// Notifier counts authentication failures and sends alerts when the configured thresholds are reached.
+type Notifier struct {
+	cfg Config
+
+	mu           sync.Mutex
+	users        map[string]*failures
+	global       failures
+	lockedAlerts map[string]time.Time
+
+	send func(context.Context, Alert) error
+	wg   sync.WaitGroup
+}
+
+// New returns a new Notifier. If neither a webhook URL nor an email address is configured, the
+// returned Notifier is disabled and all its methods are no-ops.
+func New(cfg Config) *Notifier {
+	n := &Notifier{
+		cfg:          cfg,
+		users:        make(map[string]*failures),
+		lockedAlerts: make(map[string]time.Time),
+	}
+	n.send = n.deliver
+	return n
+}
+
+// Enabled returns true if alerts are sent.
+func (n *Notifier) Enabled() bool {
+	return n != nil && n.cfg.Enabled()
+}
+
+// RecordFailure records an authentication failure and sends an alert if the per-user or global
+// failure threshold is reached.
+func (n *Notifier) RecordFailure(ctx context.Context, e Event) {
+	if !n.Enabled() {
+		return
+	}
+
+	now := time.Now()
+	var alerts []Alert
+
+	n.mu.Lock()
+	if n.cfg.UserFailureThreshold > 0 && e.Username != "" {
+		f, ok := n.users[e.Username]
+		if !ok && len(n.users) >= maxTrackedUsers {
+			// Drop the users without failures in the last window.
+			for u, uf := range n.users {
+				if len(uf.times) == 0 || now.Sub(uf.times[len(uf.times)-1]) >= n.cfg.Window {
+					delete(n.users, u)
+				}
+			}
+		}
+		if !ok && len(n.users) < maxTrackedUsers {
+			f = &failures{}
+			n.users[e.Username] = f
+		}
+		if f != nil && f.record(now, n.cfg.Window, n.cfg.UserFailureThreshold) {
+			alerts = append(alerts, n.newAlert(KindUserFailures, now, e, len(f.times)))
+		}
+	}
+	if n.cfg.GlobalFailureThreshold > 0 && n.global.record(now, n.cfg.Window, n.cfg.GlobalFailureThreshold) {
+		alerts = append(alerts, n.newAlert(KindGlobalFailures, now, Event{}, len(n.global.times)))
+	}
+	n.mu.Unlock()
+
+	for _, a := range alerts {
+		n.dispatch(ctx, a)
+	}
+}
+
+// RecordSuccess forgets the failures recorded for the user.
+func (n *Notifier) RecordSuccess(username string) {
+	if !n.Enabled() {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.users, username)
+}
+
+// RecordLockedUser sends an alert because a locked user tried to log in. Only one alert
+// per user is sent per window.
+func (n *Notifier) RecordLockedUser(ctx context.Context, e Event) {
+	if !n.Enabled() {
+		return
+	}
+
+	now := time.Now()
+
+	n.mu.Lock()
+	if last, ok := n.lockedAlerts[e.Username]; ok && now.Sub(last) < n.cfg.Window {
+		n.mu.Unlock()
+		return
+	}
+	if len(n.lockedAlerts) >= maxTrackedUsers {
+		// Drop the entries which don't suppress alerts anymore.
+		for u, last := range n.lockedAlerts {
+			if now.Sub(last) >= n.cfg.Window {
+				delete(n.lockedAlerts, u)
+			}
+		}
+	}
+	if len(n.lockedAlerts) < maxTrackedUsers {
+		n.lockedAlerts[e.Username] = now
+	}
+	n.mu.Unlock()
+
+	n.dispatch(ctx, n.newAlert(KindLockedUser, now, e, 0))
+}
+
+// RecordAdminChange sends an alert because the administrator rights of a user were granted, as a member of groups,
+// or revoked. Unlike the other alerts, every change is sent, as they form the audit trail of the administrators.
+func (n *Notifier) RecordAdminChange(ctx context.Context, e Event, granted bool, groups []string) {
+	if !n.Enabled() {
+		return
+	}
+
+	kind := KindAdminRevoked
+	if granted {
+		kind = KindAdminGranted
+	}
+	a := n.newAlert(kind, time.Now(), e, 0)
+	a.Groups = groups
+	n.dispatch(ctx, a)
+}
+
+// Wait waits for all pending alerts to be delivered.
+func (n *Notifier) Wait() {
+	if n == nil {
+		return
+	}
+	n.wg.Wait()
+}
+
+func (n *Notifier) newAlert(kind Kind, now time.Time, e Event, count int) Alert {
+	hostname, _ := os.Hostname()
+	a := Alert{
+		Kind:     kind,
+		Time:     now.UTC(),
+		Hostname: hostname,
+		Username: e.Username,
+		BrokerID: e.BrokerID,
+		Service:  e.Service,
+		Failures: count,
+	}
+	if kind == KindUserFailures || kind == KindGlobalFailures {
+		a.Window = n.cfg.Window.String()
+	}
+	return a
+}
+
+// dispatch delivers the alert in the background, so that authentication is not slowed down by
+// an unresponsive endpoint.
+func (n *Notifier) dispatch(ctx context.Context, a Alert) {
+	log.Noticef(ctx, "Sending alert: %s", a.Summary())
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+		defer cancel()
+		if err := n.send(ctx, a); err != nil {
+			log.Warningf(ctx, "Could not send alert %q: %v", a.Summary(), err)
+		}
+	}()
+}
+
+// deliver sends the alert to all configured destinations.
+func (n *Notifier) deliver(ctx context.Context, a Alert) error {
+	var errs []error
+	if n.cfg.WebhookURL != "" {
+		if err := postWebhook(ctx, n.cfg.WebhookURL, a); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		}
+	}
+	if n.cfg.Email != "" {
+		if err := sendMail(ctx, n.cfg.SendmailPath, n.cfg.Email, a); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func postWebhook(ctx context.Context, url string, a Alert) error {
+	payload, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %q", resp.Status)
+	}
+	return nil
+}
+
+func sendMail(ctx context.Context, sendmailPath, to string, a Alert) error {
+	payload, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: [authd] %s: %s\r\n", a.Hostname, a.Summary())
+	fmt.Fprintf(&msg, "Content-Type: application/json; charset=utf-8\r\n\r\n")
+	msg.Write(payload)
+	msg.WriteString("\r\n")
+
+	// #nosec:G204 - the sendmail path and recipient are set by the administrator in the configuration.
+	cmd := exec.CommandContext(ctx, sendmailPath, "-i", "--", to)
+	cmd.Stdin = &msg
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", sendmailPath, err, out)
+	}
+	return nil
+}
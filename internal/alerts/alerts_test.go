@@ -0,0 +1,258 @@
+package alerts_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/alerts"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordFailure(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		userThreshold   int
+		globalThreshold int
+		window          time.Duration
+		usernames       []string
+		noDestination   bool
+
+		wantKinds []alerts.Kind
+	}{
+		"No_alert_below_the_thresholds": {
+			userThreshold: 3, globalThreshold: 5, usernames: []string{"user1", "user1"},
+		},
+		"Alert_when_user_threshold_is_reached": {
+			userThreshold: 3, usernames: []string{"user1", "user1", "user1"},
+			wantKinds: []alerts.Kind{alerts.KindUserFailures},
+		},
+		"Alert_only_once_per_window_for_a_user": {
+			userThreshold: 2, usernames: []string{"user1", "user1", "user1", "user1", "user1"},
+			wantKinds: []alerts.Kind{alerts.KindUserFailures},
+		},
+		"Alert_when_global_threshold_is_reached": {
+			userThreshold: 3, globalThreshold: 3, usernames: []string{"user1", "user2", "user3"},
+			wantKinds: []alerts.Kind{alerts.KindGlobalFailures},
+		},
+		"Alert_for_user_and_global_thresholds": {
+			userThreshold: 2, globalThreshold: 2, usernames: []string{"user1", "user1"},
+			wantKinds: []alerts.Kind{alerts.KindUserFailures, alerts.KindGlobalFailures},
+		},
+		"Failures_outside_of_the_window_are_not_counted": {
+			userThreshold: 2, window: time.Nanosecond, usernames: []string{"user1", "user1", "user1"},
+		},
+
+		"No_alert_when_thresholds_are_disabled": {usernames: []string{"user1", "user1", "user1"}},
+		"No_alert_without_destination": {
+			userThreshold: 1, globalThreshold: 1, noDestination: true, usernames: []string{"user1"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.window == 0 {
+				tc.window = time.Hour
+			}
+
+			srv, received := newWebhookServer(t, http.StatusOK)
+			cfg := alerts.Config{
+				WebhookURL:             srv.URL,
+				UserFailureThreshold:   tc.userThreshold,
+				GlobalFailureThreshold: tc.globalThreshold,
+				Window:                 tc.window,
+			}
+			if tc.noDestination {
+				cfg.WebhookURL = ""
+			}
+			n := alerts.New(cfg)
+			require.Equal(t, !tc.noDestination, n.Enabled(), "Enabled should return the expected value")
+
+			for _, u := range tc.usernames {
+				n.RecordFailure(context.Background(), alerts.Event{Username: u, BrokerID: "broker-id", Service: "sshd"})
+				// Make sure failures have distinct timestamps, so that the window is respected.
+				time.Sleep(time.Millisecond)
+			}
+			n.Wait()
+
+			var gotKinds []alerts.Kind
+			for _, a := range received() {
+				gotKinds = append(gotKinds, a.Kind)
+				require.NotEmpty(t, a.Window, "Failure alerts should contain the window")
+				if a.Kind == alerts.KindUserFailures {
+					require.Equal(t, "user1", a.Username, "User alert should contain the username")
+					require.Equal(t, "broker-id", a.BrokerID, "User alert should contain the broker ID")
+					require.Equal(t, "sshd", a.Service, "User alert should contain the PAM service")
+					require.Equal(t, tc.userThreshold, a.Failures, "User alert should contain the number of failures")
+				}
+			}
+			require.ElementsMatch(t, tc.wantKinds, gotKinds, "Unexpected alerts sent")
+		})
+	}
+}
+
+func TestRecordSuccess(t *testing.T) {
+	t.Parallel()
+
+	srv, received := newWebhookServer(t, http.StatusOK)
+	n := alerts.New(alerts.Config{WebhookURL: srv.URL, UserFailureThreshold: 2, Window: time.Hour})
+
+	n.RecordFailure(context.Background(), alerts.Event{Username: "user1"})
+	n.RecordSuccess("user1")
+	n.RecordFailure(context.Background(), alerts.Event{Username: "user1"})
+	n.Wait()
+
+	require.Empty(t, received(), "Failures before a successful login should not be counted")
+}
+
+func TestRecordLockedUser(t *testing.T) {
+	t.Parallel()
+
+	srv, received := newWebhookServer(t, http.StatusOK)
+	n := alerts.New(alerts.Config{WebhookURL: srv.URL, Window: time.Hour})
+
+	n.RecordLockedUser(context.Background(), alerts.Event{Username: "user1", BrokerID: "broker-id"})
+	n.RecordLockedUser(context.Background(), alerts.Event{Username: "user1", BrokerID: "broker-id"})
+	n.RecordLockedUser(context.Background(), alerts.Event{Username: "user2", BrokerID: "broker-id"})
+	n.Wait()
+
+	var gotUsers []string
+	for _, a := range received() {
+		require.Equal(t, alerts.KindLockedUser, a.Kind, "Alert should be for a locked user")
+		gotUsers = append(gotUsers, a.Username)
+	}
+	require.ElementsMatch(t, []string{"user1", "user2"}, gotUsers, "Only one alert per user should be sent per window")
+}
+
+func TestRecordAdminChange(t *testing.T) {
+	t.Parallel()
+
+	srv, received := newWebhookServer(t, http.StatusOK)
+	n := alerts.New(alerts.Config{WebhookURL: srv.URL, Window: time.Hour})
+
+	n.RecordAdminChange(context.Background(), alerts.Event{Username: "user1", BrokerID: "broker-id"}, true, []string{"admins"})
+	n.Wait()
+	n.RecordAdminChange(context.Background(), alerts.Event{Username: "user1", BrokerID: "broker-id"}, false, nil)
+	n.Wait()
+
+	got := received()
+	require.Len(t, got, 2, "Every change of the administrator rights should be sent")
+	require.Equal(t, alerts.KindAdminGranted, got[0].Kind, "First alert should be for the grant")
+	require.Equal(t, []string{"admins"}, got[0].Groups, "Grant alert should contain the administrator groups")
+	require.Equal(t, alerts.KindAdminRevoked, got[1].Kind, "Second alert should be for the revocation")
+	require.Empty(t, got[1].Window, "Administrator alerts should not have a window")
+}
+
+func TestWebhookErrorDoesNotBlock(t *testing.T) {
+	t.Parallel()
+
+	srv, received := newWebhookServer(t, http.StatusInternalServerError)
+	n := alerts.New(alerts.Config{WebhookURL: srv.URL, UserFailureThreshold: 1, Window: time.Hour})
+
+	n.RecordFailure(context.Background(), alerts.Event{Username: "user1"})
+	n.Wait()
+
+	require.Len(t, received(), 1, "Webhook should have been called once")
+}
+
+func TestEmail(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		failingSendmail bool
+	}{
+		"Send_alert_by_email": {},
+
+		"Error_does_not_block_when_sendmail_fails": {failingSendmail: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			outFile := filepath.Join(tmpDir, "mail")
+			exitCode := 0
+			if tc.failingSendmail {
+				exitCode = 1
+			}
+			sendmail := filepath.Join(tmpDir, "sendmail")
+			script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %[1]s.args\ncat > %[1]s\nexit %d\n", outFile, exitCode)
+			err := os.WriteFile(sendmail, []byte(script), 0700)
+			require.NoError(t, err, "Setup: could not write sendmail script")
+
+			n := alerts.New(alerts.Config{
+				Email:                "admin@example.com",
+				SendmailPath:         sendmail,
+				UserFailureThreshold: 1,
+				Window:               time.Hour,
+			})
+			n.RecordFailure(context.Background(), alerts.Event{Username: "user1"})
+			n.Wait()
+
+			args, err := os.ReadFile(outFile + ".args")
+			require.NoError(t, err, "sendmail should have been called")
+			require.Equal(t, "-i -- admin@example.com\n", string(args), "sendmail should be called with the recipient")
+
+			mail, err := os.ReadFile(outFile)
+			require.NoError(t, err, "sendmail should have received the message")
+			headers, body, found := strings.Cut(string(mail), "\r\n\r\n")
+			require.True(t, found, "Message should contain headers and a body")
+			require.Contains(t, headers, "To: admin@example.com\r\n", "Message should be addressed to the recipient")
+			require.Contains(t, headers, `authentication failures for user "user1"`, "Subject should describe the alert")
+
+			var a alerts.Alert
+			err = json.Unmarshal([]byte(body), &a)
+			require.NoError(t, err, "Message body should be the JSON alert")
+			require.Equal(t, alerts.KindUserFailures, a.Kind, "Unexpected alert kind")
+		})
+	}
+}
+
+// newWebhookServer starts a webhook receiving alerts, answering with the given status code.
+// It returns the server and a function returning the alerts received so far.
+func newWebhookServer(t *testing.T, statusCode int) (*httptest.Server, func() []alerts.Alert) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var received []alerts.Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var a alerts.Alert
+		if err := json.Unmarshal(body, &a); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		received = append(received, a)
+		mu.Unlock()
+
+		w.WriteHeader(statusCode)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() []alerts.Alert {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]alerts.Alert(nil), received...)
+	}
+}
@@ -27,3 +27,26 @@ const (
 	// TODO: We can change this to "change-password" once all broker installations are updated to use the new name.
 	SessionModeChangePassword = "passwd"
 )
+
+const (
+	// UserActive is the verification status of a user whose account is enabled at the provider.
+	UserActive = "active"
+	// UserDisabled is the verification status of a user whose account is disabled or deleted at the provider.
+	UserDisabled = "disabled"
+	// UserUnverified is the verification status of a user whose account could not be checked at the provider, for
+	// example because it is not reachable or because the user needs to authenticate again.
+	UserUnverified = "unknown"
+)
+
+// UserStatuses is the list of all possible verification statuses returned by the brokers.
+var UserStatuses = []string{UserActive, UserDisabled, UserUnverified}
+
+// TOTPMode is the ID of the authentication mode handled by authd itself, in which the users who enrolled a TOTP
+// secret enter one of its codes once their broker granted them access offline. The client sends the code as the TOTP
+// code of the authentication data, not as an encrypted secret, as authd verifies it.
+const TOTPMode = "authd_totp"
+
+// MachineTokenMode is the ID of the authentication mode handled by authd itself, in which automation authenticates
+// as a user with the machine token enrolled for them, without interaction with their identity provider. The client
+// sends the token as the machine token of the authentication data, not as an encrypted secret, as authd verifies it.
+const MachineTokenMode = "authd_machine_token"
@@ -2,13 +2,17 @@ package brokers
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"hash/fnv"
+	"net/http"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/canonical/authd/internal/brokers/auth"
 	"github.com/canonical/authd/internal/brokers/layouts"
@@ -22,13 +26,32 @@ import (
 // LocalBrokerName is the name of the local broker.
 const LocalBrokerName = "local"
 
+// maxPictureSize is the maximum size of the pictures of the users returned by the brokers. AccountsService refuses
+// the pictures larger than 1 MiB.
+const maxPictureSize = 1 << 20
+
+var (
+	// sessionEnvNameRegex matches the names of the environment variables which brokers can export in the sessions.
+	sessionEnvNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// reservedSessionEnv are the environment variables which brokers can't export in the sessions, as they are set
+	// by the login programs or change how the programs of the session are run. The LD_* variables are reserved too.
+	reservedSessionEnv = map[string]struct{}{
+		"BASH_ENV": {}, "ENV": {}, "HOME": {}, "IFS": {}, "LOGNAME": {}, "MAIL": {}, "PATH": {}, "PWD": {},
+		"SHELL": {}, "TERM": {}, "TZ": {}, "USER": {},
+	}
+	// sshKeyTypeRegex matches the types of the SSH keys which brokers can return.
+	sshKeyTypeRegex = regexp.MustCompile(`^(ssh-(rsa|dss|ed25519)|ecdsa-sha2-nistp(256|384|521)|sk-(ssh-ed25519|ecdsa-sha2-nistp256)@openssh\.com)$`)
+)
+
 // grantedData is the canonical envelope used to carry a granted authentication
 // result between the broker layer and the PAM service. The optional message is
 // an authd-controlled, user-facing notice (for example, a caching indicator)
-// that the broker may attach on a successful login.
+// that the broker may attach on a successful login. Offline is set when the
+// broker authenticated the user without their identity provider.
 type grantedData struct {
 	UserInfo types.UserInfo `json:"userinfo"`
 	Message  string         `json:"message,omitempty"`
+	Offline  bool           `json:"offline,omitempty"`
 }
 
 type brokerer interface {
@@ -44,25 +67,67 @@ type brokerer interface {
 	// identifier and is used by v3 brokers to locate the provider ID-keyed cache directory.
 	// v2 brokers ignore the providerID parameter.
 	DeleteUser(ctx context.Context, username, providerID string) error
+	// VerifyUser asks the provider whether the user account is still enabled, without authenticating the user.
+	// It is only supported by v4 brokers.
+	VerifyUser(ctx context.Context, username, providerID string) (userStatus, data string, err error)
+	// SessionToken returns, as JSON, an access token of the user for applications running in their session.
+	SessionToken(ctx context.Context, username string, scopes []string) (sessionToken string, err error)
+	// TokenStatus returns, as JSON, the status of the token cached for the user.
+	TokenStatus(ctx context.Context, username string) (tokenStatus string, err error)
+	// RefreshToken refreshes the token cached for the user and returns its new status as JSON.
+	RefreshToken(ctx context.Context, username string) (tokenStatus string, err error)
+	// SetSessionHints gives the broker information about the session which it can use to adapt its interactive flows
+	// to the user, like their time zone.
+	SetSessionHints(ctx context.Context, sessionID string, hints map[string]string) error
+	// CheckProvider checks the connection of the broker to its provider and returns the checks as JSON.
+	CheckProvider(ctx context.Context) (checks string, err error)
+	// Capabilities returns the optional features supported by the broker.
+	Capabilities() []string
+	// Ping checks that the broker answers.
+	Ping(ctx context.Context) error
+	// APIVersion returns the version of the broker API used with the broker.
+	APIVersion() uint
 }
 
 // Broker represents a broker object that can be used for authentication.
 type Broker struct {
-	ID                    string
-	Name                  string
-	BrandIconPath         string
-	layoutValidators      map[string]map[string]layoutValidator
-	layoutValidatorsMu    *sync.Mutex
-	ongoingUserRequests   map[string]string
-	ongoingUserRequestsMu *sync.Mutex
-
-	// isAuthMu serialises IsAuthenticated calls per session. A new call for
-	// the same session must wait until the previous one — including any broker-
-	// side cancellation and cleanup — has fully returned.
-	isAuthMu   map[string]*sync.Mutex
-	isAuthMuMu *sync.Mutex
+	ID            string
+	Name          string
+	BrandIconPath string
+	// UserDefaults are the attributes given to the users of the broker when their provider doesn't supply them.
+	UserDefaults UserDefaults
+	// ConfigFile is the configuration file the broker was loaded from, empty for the local broker.
+	ConfigFile string
+	// DBusName and DBusObject are where the broker is exported on the bus, empty for the local broker.
+	DBusName   string
+	DBusObject string
+
+	// sessions holds the state of the ongoing sessions, by session ID without the broker ID prefix.
+	// sessionsMu is only held to access the map and never while calling the broker, so that a session waiting for
+	// the broker (e.g. for the user to complete a device authentication) doesn't delay the other ones.
+	sessions   map[string]*brokerSession
+	sessionsMu *sync.Mutex
 
 	brokerer brokerer
+	// latencyObserver is notified of the duration of the calls to the broker, if set.
+	latencyObserver LatencyObserver
+}
+
+// LatencyObserver is notified of the duration of the calls to the brokers, e.g. to expose them as metrics.
+type LatencyObserver interface {
+	ObserveBrokerCall(brokerID, method string, d time.Duration)
+}
+
+// brokerSession is the state of a session started with the broker.
+type brokerSession struct {
+	mu               sync.Mutex
+	username         string
+	layoutValidators map[string]layoutValidator
+
+	// isAuthMu serialises IsAuthenticated calls of the session. A new call must
+	// wait until the previous one — including any broker-side cancellation and
+	// cleanup — has fully returned.
+	isAuthMu sync.Mutex
 }
 
 type layoutValidator map[string]fieldValidator
@@ -80,13 +145,21 @@ func newBroker(ctx context.Context, configFile string, bus *dbus.Conn) (b Broker
 	id := LocalBrokerName
 	var brandIcon string
 	var broker brokerer
+	var userDefaults UserDefaults
+	var dbusName, dbusObject string
 
 	if configFile != "" {
 		log.Debugf(ctx, "Loading broker from %q", configFile)
-		broker, name, brandIcon, err = newDbusBroker(ctx, bus, configFile)
+		var dBroker dbusBroker
+		dBroker, name, brandIcon, err = newDbusBroker(ctx, bus, configFile)
 		if err != nil {
 			return Broker{}, err
 		}
+		broker = dBroker
+		dbusName, dbusObject = dBroker.dbusObject.Destination(), string(dBroker.dbusObject.Path())
+		if userDefaults, err = loadUserDefaults(configFile); err != nil {
+			return Broker{}, err
+		}
 		h := fnv.New32a()
 		// This can’t error out in Hash32 implementation.
 		_, _ = h.Write([]byte(name))
@@ -94,22 +167,57 @@ func newBroker(ctx context.Context, configFile string, bus *dbus.Conn) (b Broker
 	}
 
 	return Broker{
-		ID:                    id,
-		Name:                  name,
-		BrandIconPath:         brandIcon,
-		brokerer:              broker,
-		layoutValidators:      make(map[string]map[string]layoutValidator),
-		layoutValidatorsMu:    &sync.Mutex{},
-		ongoingUserRequests:   make(map[string]string),
-		ongoingUserRequestsMu: &sync.Mutex{},
-		isAuthMu:              make(map[string]*sync.Mutex),
-		isAuthMuMu:            &sync.Mutex{},
+		ID:            id,
+		Name:          name,
+		BrandIconPath: brandIcon,
+		UserDefaults:  userDefaults,
+		ConfigFile:    configFile,
+		DBusName:      dbusName,
+		DBusObject:    dbusObject,
+		brokerer:      broker,
+		sessions:      make(map[string]*brokerSession),
+		sessionsMu:    &sync.Mutex{},
 	}, nil
 }
 
+// addSession stores the state of a session started with the broker, which is freed when the session ends.
+func (b Broker) addSession(sessionID, username string) {
+	b.sessionsMu.Lock()
+	defer b.sessionsMu.Unlock()
+
+	b.sessions[sessionID] = &brokerSession{username: username}
+}
+
+// session returns the state of the session, or an error if the session was not started or has ended.
+func (b Broker) session(sessionID string) (*brokerSession, error) {
+	b.sessionsMu.Lock()
+	defer b.sessionsMu.Unlock()
+
+	s, ok := b.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("no ongoing session %q for broker %q", sessionID, b.Name)
+	}
+	return s, nil
+}
+
+// removeSession frees the state of the session, or returns an error if the session was not started or has ended.
+func (b Broker) removeSession(sessionID string) error {
+	b.sessionsMu.Lock()
+	defer b.sessionsMu.Unlock()
+
+	if _, ok := b.sessions[sessionID]; !ok {
+		return fmt.Errorf("no ongoing session %q for broker %q", sessionID, b.Name)
+	}
+	delete(b.sessions, sessionID)
+	return nil
+}
+
 // newSession calls the broker corresponding method, expanding sessionID with the broker ID prefix.
-func (b Broker) newSession(ctx context.Context, username, lang, mode, providerID string) (sessionID, encryptionKey string, err error) {
+// The timezone of the user, if known, is passed to the brokers supporting session hints.
+func (b Broker) newSession(ctx context.Context, username, lang, timezone, mode, providerID string) (sessionID, encryptionKey string, err error) {
+	start := time.Now()
 	sessionID, encryptionKey, err = b.brokerer.NewSession(ctx, username, lang, mode, providerID)
+	b.observeCall("NewSession", start)
 	if err != nil {
 		return "", "", err
 	}
@@ -118,9 +226,17 @@ func (b Broker) newSession(ctx context.Context, username, lang, mode, providerID
 		return "", "", errors.New("no session ID provided by broker")
 	}
 
-	b.ongoingUserRequestsMu.Lock()
-	b.ongoingUserRequests[sessionID] = username
-	b.ongoingUserRequestsMu.Unlock()
+	// The hints only refine the messages of the broker, so the session can go on without them.
+	if timezone != "" {
+		err := b.brokerer.SetSessionHints(ctx, sessionID, map[string]string{SessionHintTimezone: timezone})
+		if errors.Is(err, ErrSessionHintsNotSupported) {
+			log.Debugf(ctx, "Broker %q does not support session hints", b.Name)
+		} else if err != nil {
+			log.Warningf(ctx, "Could not pass the session hints to broker %q: %v", b.Name, err)
+		}
+	}
+
+	b.addSession(sessionID, username)
 
 	return fmt.Sprintf("%s-%s", b.ID, sessionID), encryptionKey, nil
 }
@@ -129,11 +245,17 @@ func (b Broker) newSession(ctx context.Context, username, lang, mode, providerID
 func (b *Broker) GetAuthenticationModes(ctx context.Context, sessionID string, supportedUILayouts []map[string]string) (authenticationModes []map[string]string, err error) {
 	sessionID = b.parseSessionID(sessionID)
 
-	b.layoutValidatorsMu.Lock()
-	b.layoutValidators[sessionID] = generateValidators(ctx, sessionID, supportedUILayouts)
-	b.layoutValidatorsMu.Unlock()
+	s, err := b.session(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.layoutValidators = generateValidators(ctx, sessionID, supportedUILayouts)
+	s.mu.Unlock()
 
+	start := time.Now()
 	authenticationModes, err = b.brokerer.GetAuthenticationModes(ctx, sessionID, supportedUILayouts)
+	b.observeCall("GetAuthenticationModes", start)
 	if err != nil {
 		return nil, err
 	}
@@ -152,7 +274,9 @@ func (b *Broker) GetAuthenticationModes(ctx context.Context, sessionID string, s
 // SelectAuthenticationMode calls the broker corresponding method, stripping broker ID prefix from sessionID.
 func (b Broker) SelectAuthenticationMode(ctx context.Context, sessionID, authenticationModeName string) (uiLayoutInfo map[string]string, err error) {
 	sessionID = b.parseSessionID(sessionID)
+	start := time.Now()
 	uiLayoutInfo, err = b.brokerer.SelectAuthenticationMode(ctx, sessionID, authenticationModeName)
+	b.observeCall("SelectAuthenticationMode", start)
 	if err != nil {
 		return nil, err
 	}
@@ -170,21 +294,20 @@ func (b Broker) IsAuthenticated(ctx context.Context, sessionID, authenticationDa
 	// Because broker.IsAuthenticated already does <-done in the ctx.Done()
 	// branch, this mutex is only released after the broker goroutine has
 	// exited — so the second call is guaranteed to see a clean broker state.
-	b.isAuthMuMu.Lock()
-	mu, ok := b.isAuthMu[sessionID]
-	if !ok {
-		mu = &sync.Mutex{}
-		b.isAuthMu[sessionID] = mu
+	// Calls for other sessions are not affected.
+	s, err := b.session(sessionID)
+	if err != nil {
+		return "", "", err
 	}
-	b.isAuthMuMu.Unlock()
-
-	mu.Lock()
-	defer mu.Unlock()
+	s.isAuthMu.Lock()
+	defer s.isAuthMu.Unlock()
 
 	// monitor ctx in goroutine to call cancel
 	done := make(chan struct{})
 	go func() {
+		start := time.Now()
 		access, data, err = b.brokerer.IsAuthenticated(ctx, sessionID, authenticationData)
+		b.observeCall("IsAuthenticated", start)
 		close(done)
 	}()
 
@@ -233,6 +356,7 @@ func (b Broker) IsAuthenticated(ctx context.Context, sessionID, authenticationDa
 		if err != nil {
 			return "", "", err
 		}
+		info = b.UserDefaults.Apply(info)
 
 		if err = validateUserInfo(info); err != nil {
 			return "", "", err
@@ -246,10 +370,17 @@ func (b Broker) IsAuthenticated(ctx context.Context, sessionID, authenticationDa
 			}
 		}
 
+		var offline bool
+		if rawOffline := rawData["offline"]; rawOffline != nil {
+			if err := json.Unmarshal(rawOffline, &offline); err != nil {
+				return "", "", fmt.Errorf("invalid value for key %q in returned message: %v", "offline", err)
+			}
+		}
+
 		// Always forward a consistent {"userinfo": ..., "message": ...} envelope
 		// (message omitted when empty) so the consumer always parses the same
 		// shape regardless of whether the broker attached a success message.
-		d, err := json.Marshal(grantedData{UserInfo: info, Message: message})
+		d, err := json.Marshal(grantedData{UserInfo: info, Message: message, Offline: offline})
 		if err != nil {
 			return "", "", fmt.Errorf("can't marshal UserInfo: %v", err)
 		}
@@ -281,14 +412,11 @@ func (b Broker) IsAuthenticated(ctx context.Context, sessionID, authenticationDa
 func (b Broker) endSession(ctx context.Context, sessionID string) (err error) {
 	sessionID = b.parseSessionID(sessionID)
 
-	b.ongoingUserRequestsMu.Lock()
-	defer b.ongoingUserRequestsMu.Unlock()
-	delete(b.ongoingUserRequests, sessionID)
-
-	b.isAuthMuMu.Lock()
-	delete(b.isAuthMu, sessionID)
-	b.isAuthMuMu.Unlock()
+	if err := b.removeSession(sessionID); err != nil {
+		return err
+	}
 
+	defer b.observeCall("EndSession", time.Now())
 	return b.brokerer.EndSession(ctx, sessionID)
 }
 
@@ -303,6 +431,7 @@ func (b Broker) cancelIsAuthenticated(ctx context.Context, sessionID string) {
 // UserPreCheck calls the broker corresponding method.
 func (b Broker) UserPreCheck(ctx context.Context, username string) (userinfo string, err error) {
 	log.Debugf(context.TODO(), "Pre-checking user %q", username)
+	defer b.observeCall("UserPreCheck", time.Now())
 	return b.brokerer.UserPreCheck(ctx, username)
 }
 
@@ -314,6 +443,192 @@ func (b Broker) DeleteUser(ctx context.Context, username, providerID string) err
 	return b.brokerer.DeleteUser(ctx, username, providerID)
 }
 
+// Ping checks that the broker answers. The local broker always does.
+func (b Broker) Ping(ctx context.Context) error {
+	if b.ID == LocalBrokerName {
+		return nil
+	}
+	return b.brokerer.Ping(ctx)
+}
+
+// APIVersion returns the version of the broker API used with the broker, or 0 for the local broker.
+func (b Broker) APIVersion() uint {
+	if b.ID == LocalBrokerName {
+		return 0
+	}
+	return b.brokerer.APIVersion()
+}
+
+// Capabilities returns the optional features supported by the broker, none for the local broker.
+func (b Broker) Capabilities() []string {
+	if b.ID == LocalBrokerName {
+		return nil
+	}
+	return b.brokerer.Capabilities()
+}
+
+// ProviderCheck is the result of a check of the connection of the broker to its provider.
+type ProviderCheck struct {
+	Name string `json:"name"`
+	// Status is one of "ok", "failed" or "skipped".
+	Status  string `json:"status"`
+	Details string `json:"details"`
+}
+
+// providerCheckStatuses are the valid statuses of the checks of the provider.
+var providerCheckStatuses = []string{"ok", "failed", "skipped"}
+
+// CheckProvider calls the broker to check its connection to its provider, without any user logging in.
+func (b Broker) CheckProvider(ctx context.Context) (checks []ProviderCheck, err error) {
+	// The users of the local broker are provided by another NSS service.
+	if b.ID == LocalBrokerName {
+		return nil, ErrProviderCheckNotSupported
+	}
+
+	start := time.Now()
+	data, err := b.brokerer.CheckProvider(ctx)
+	b.observeCall("CheckProvider", start)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(data), &checks); err != nil {
+		return nil, fmt.Errorf("response returned by the broker is not a valid json: %v", err)
+	}
+	for _, c := range checks {
+		if c.Name == "" || !slices.Contains(providerCheckStatuses, c.Status) {
+			return nil, fmt.Errorf("invalid check returned by the broker: %+v", c)
+		}
+	}
+
+	return checks, nil
+}
+
+// UserVerification is the state of a user account at the provider, as reported by the broker.
+type UserVerification struct {
+	// Status is one of auth.UserStatuses.
+	Status string
+	// UserInfo is the current information of the user at the provider, only set for active users.
+	UserInfo types.UserInfo
+	// Message explains the status, if the broker gave any details.
+	Message string
+}
+
+// VerifyUser calls the broker to check online whether the user account is still enabled.
+func (b Broker) VerifyUser(ctx context.Context, username, providerID string) (v UserVerification, err error) {
+	log.Debugf(ctx, "Verifying user %q", username)
+
+	start := time.Now()
+	userStatus, data, err := b.brokerer.VerifyUser(ctx, username, providerID)
+	b.observeCall("VerifyUser", start)
+	if err != nil {
+		return v, err
+	}
+	if !slices.Contains(auth.UserStatuses, userStatus) {
+		return v, fmt.Errorf("invalid broker response: %q", userStatus)
+	}
+	v.Status = userStatus
+
+	if data == "" {
+		data = "{}"
+	}
+	var rawData map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &rawData); err != nil {
+		return v, fmt.Errorf("response returned by the broker is not a valid json: %v\nBroker returned: %v", err, data)
+	}
+	if rawMessage := rawData["message"]; rawMessage != nil {
+		if err := json.Unmarshal(rawMessage, &v.Message); err != nil {
+			return v, fmt.Errorf("invalid message returned by the broker: %v", err)
+		}
+	}
+
+	if userStatus != auth.UserActive {
+		return v, nil
+	}
+	rawUserInfo, ok := rawData["userinfo"]
+	if !ok {
+		return v, fmt.Errorf("missing key %q in returned message, got: %v", "userinfo", data)
+	}
+	if v.UserInfo, err = unmarshalUserInfo(rawUserInfo); err != nil {
+		return v, err
+	}
+	v.UserInfo = b.UserDefaults.Apply(v.UserInfo)
+	if err := validateUserInfo(v.UserInfo); err != nil {
+		return v, err
+	}
+
+	return v, nil
+}
+
+// SessionToken is an access token of a user which the broker hands to the applications running in their session.
+type SessionToken struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	Expiry      time.Time `json:"expiry"`
+	Scopes      []string  `json:"scopes"`
+}
+
+// SessionToken calls the broker to get an access token of the user for the given scopes.
+func (b Broker) SessionToken(ctx context.Context, username string, scopes []string) (t SessionToken, err error) {
+	log.Debugf(ctx, "Requesting a session token of user %q for scopes %v", username, scopes)
+
+	start := time.Now()
+	data, err := b.brokerer.SessionToken(ctx, username, scopes)
+	b.observeCall("SessionToken", start)
+	if err != nil {
+		return t, err
+	}
+	if err := json.Unmarshal([]byte(data), &t); err != nil {
+		return t, fmt.Errorf("response returned by the broker is not a valid json: %v", err)
+	}
+	if t.AccessToken == "" {
+		return t, errors.New("the broker returned an empty access token")
+	}
+
+	return t, nil
+}
+
+// TokenStatus is the status of the token which the broker cached for a user when they last authenticated with their
+// identity provider.
+type TokenStatus struct {
+	Expiry time.Time `json:"expiry"`
+	// HasRefreshToken is true if the broker can get a new token without the user authenticating again.
+	HasRefreshToken bool `json:"has_refresh_token"`
+	// HasLocalPassword is true if the user set the local password used for the logins without the provider.
+	HasLocalPassword bool `json:"has_local_password"`
+	UserIsDisabled   bool `json:"user_is_disabled"`
+}
+
+// TokenStatus calls the broker to get the status of the token cached for the user.
+func (b Broker) TokenStatus(ctx context.Context, username string) (s TokenStatus, err error) {
+	data, err := b.brokerer.TokenStatus(ctx, username)
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return s, fmt.Errorf("response returned by the broker is not a valid json: %v", err)
+	}
+
+	return s, nil
+}
+
+// RefreshToken calls the broker to refresh the token cached for the user, without them authenticating again, and
+// returns the status of the refreshed token.
+func (b Broker) RefreshToken(ctx context.Context, username string) (s TokenStatus, err error) {
+	log.Debugf(ctx, "Refreshing the token of user %q", username)
+
+	start := time.Now()
+	data, err := b.brokerer.RefreshToken(ctx, username)
+	b.observeCall("RefreshToken", start)
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return s, fmt.Errorf("response returned by the broker is not a valid json: %v", err)
+	}
+
+	return s, nil
+}
+
 // generateValidators generates layout validators based on what is supported by the system.
 //
 // The layout validators are in the form:
@@ -359,11 +674,15 @@ func generateValidators(ctx context.Context, sessionID string, supportedUILayout
 func (b Broker) validateUILayout(sessionID string, layout map[string]string) (r map[string]string, err error) {
 	defer decorate.OnError(&err, "could not validate UI layout")
 
-	b.layoutValidatorsMu.Lock()
-	defer b.layoutValidatorsMu.Unlock()
+	s, err := b.session(sessionID)
+	if err != nil {
+		return nil, err
+	}
 
-	layoutValidators, exists := b.layoutValidators[sessionID]
-	if !exists {
+	s.mu.Lock()
+	layoutValidators := s.layoutValidators
+	s.mu.Unlock()
+	if layoutValidators == nil {
 		return nil, fmt.Errorf("session %q does not have any layout validator", sessionID)
 	}
 
@@ -398,6 +717,14 @@ func (b Broker) validateUILayout(sessionID string, layout map[string]string) (r
 	return layout, nil
 }
 
+// observeCall reports the duration of the call to the broker made since start to the latency observer, if any.
+func (b Broker) observeCall(method string, start time.Time) {
+	if b.latencyObserver == nil {
+		return
+	}
+	b.latencyObserver.ObserveBrokerCall(b.ID, method, time.Since(start))
+}
+
 // parseSessionID strips broker ID prefix from sessionID.
 func (b Broker) parseSessionID(sessionID string) string {
 	return strings.TrimPrefix(sessionID, fmt.Sprintf("%s-", b.ID))
@@ -438,6 +765,52 @@ func validateUserInfo(uInfo types.UserInfo) (err error) {
 		}
 	}
 
+	// Validate session environment variables
+	for name, value := range uInfo.Env {
+		if !sessionEnvNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid session environment variable name %q", name)
+		}
+		if _, ok := reservedSessionEnv[name]; ok || strings.HasPrefix(name, "LD_") {
+			return fmt.Errorf("session environment variable %q is reserved", name)
+		}
+		if strings.ContainsAny(value, "\x00\n") {
+			return fmt.Errorf("session environment variable %q has an invalid value", name)
+		}
+	}
+
+	// Validate SSH keys: sshd would apply the options of the keys, which are for the administrators to decide.
+	for _, key := range uInfo.SSHKeys {
+		if err := validateSSHKey(key); err != nil {
+			return fmt.Errorf("invalid SSH key %q: %v", key, err)
+		}
+	}
+
+	// Validate the picture, which AccountsService would refuse or the greeter fail to show otherwise.
+	if len(uInfo.Picture) > maxPictureSize {
+		return fmt.Errorf("picture is larger than %d bytes", maxPictureSize)
+	}
+	if t := http.DetectContentType(uInfo.Picture); len(uInfo.Picture) > 0 && t != "image/png" && t != "image/jpeg" {
+		return fmt.Errorf("picture is of unsupported type %s", t)
+	}
+
+	return nil
+}
+
+// validateSSHKey checks that the key is a line of an authorized_keys file without options.
+func validateSSHKey(key string) error {
+	if strings.ContainsAny(key, "\x00\r\n") {
+		return errors.New("it has several lines")
+	}
+	fields := strings.Fields(key)
+	if len(fields) < 2 {
+		return errors.New("it is not of the form <type> <base64 key> [comment]")
+	}
+	if !sshKeyTypeRegex.MatchString(fields[0]) {
+		return fmt.Errorf("unsupported key type %q", fields[0])
+	}
+	if _, err := base64.StdEncoding.DecodeString(fields[1]); err != nil {
+		return fmt.Errorf("key is not valid base64: %v", err)
+	}
 	return nil
 }
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -91,6 +92,148 @@ func TestNewBroker(t *testing.T) {
 	}
 }
 
+func TestNewBrokerUserDefaults(t *testing.T) {
+	t.Parallel()
+
+	const authdSection = `[authd]
+name = Broker
+brand_icon = some_icon.png
+dbus_name = com.ubuntu.authd.Broker
+dbus_object = /com/ubuntu/authd/Broker
+`
+	sharedGID, unknownGID, usersGID := uint32(100), uint32(4242424), uint32(100)
+
+	tests := map[string]struct {
+		usersSection string
+
+		want    brokers.UserDefaults
+		wantErr bool
+	}{
+		"No_user_defaults":                           {},
+		"Default_shell":                              {usersSection: "default_shell = /bin/zsh", want: brokers.UserDefaults{Shell: "/bin/zsh"}},
+		"Private_primary_group":                      {usersSection: "primary_group = private"},
+		"Shared_primary_group":                       {usersSection: "primary_group = shared\nprimary_group_gid = 100", want: brokers.UserDefaults{SharedPrimaryGID: &sharedGID}},
+		"Default_shell_and_shared_primary_group":     {usersSection: "default_shell = /bin/sh\nprimary_group = shared\nprimary_group_gid = 100", want: brokers.UserDefaults{Shell: "/bin/sh", SharedPrimaryGID: &sharedGID}},
+		"Shared_primary_group_missing_on_the_system": {usersSection: "primary_group = shared\nprimary_group_gid = 4242424", want: brokers.UserDefaults{SharedPrimaryGID: &unknownGID}},
+		"Default_groups":                             {usersSection: "default_groups = users, plugdev,lpadmin", want: brokers.UserDefaults{Groups: []string{"users", "plugdev", "lpadmin"}}},
+		"Home_template":                              {usersSection: "home_template = /home/%d/%u", want: brokers.UserDefaults{Home: brokers.HomeSettings{Template: "/home/%d/%u"}}},
+		"Home_creation_settings": {
+			usersSection: "home_skel = /etc/skel.employees\nhome_mode = 0700\nhome_subdirs = Documents, work/projects\nhome_group = users",
+			want: brokers.UserDefaults{Home: brokers.HomeSettings{
+				Skel: "/etc/skel.employees", Mode: 0700, Subdirs: []string{"Documents", "work/projects"}, GID: &usersGID,
+			}},
+		},
+		"Default_home_mode_with_home_creation_settings": {usersSection: "home_subdirs = Documents", want: brokers.UserDefaults{Home: brokers.HomeSettings{Mode: 0750, Subdirs: []string{"Documents"}}}},
+		"Home_quotas": {
+			usersSection: "home_quota = 10G\nhome_quota_groups = Students:500M, staff: 1T",
+			want: brokers.UserDefaults{Home: brokers.HomeSettings{
+				Mode: 0750, Quota: 10 << 30, GroupQuotas: map[string]uint64{"students": 500 << 20, "staff": 1 << 40}, QuotaType: brokers.UserQuota,
+			}},
+		},
+		"Home_project_quota": {
+			usersSection: "home_quota = 1048576\nhome_quota_type = project",
+			want:         brokers.UserDefaults{Home: brokers.HomeSettings{Mode: 0750, Quota: 1 << 20, QuotaType: brokers.ProjectQuota}},
+		},
+
+		"Error_when_default_shell_is_relative":           {usersSection: "default_shell = zsh", wantErr: true},
+		"Error_when_primary_group_is_invalid":            {usersSection: "primary_group = other", wantErr: true},
+		"Error_when_shared_group_has_no_gid":             {usersSection: "primary_group = shared", wantErr: true},
+		"Error_when_shared_group_gid_is_invalid":         {usersSection: "primary_group = shared\nprimary_group_gid = users", wantErr: true},
+		"Error_when_shared_group_gid_is_zero":            {usersSection: "primary_group = shared\nprimary_group_gid = 0", wantErr: true},
+		"Error_when_shared_group_gid_is_too_large":       {usersSection: "primary_group = shared\nprimary_group_gid = 4294967296", wantErr: true},
+		"Error_when_private_group_has_a_gid":             {usersSection: "primary_group = private\nprimary_group_gid = 100", wantErr: true},
+		"Error_when_default_primary_group_has_a_gid":     {usersSection: "primary_group_gid = 100", wantErr: true},
+		"Error_when_default_group_is_invalid":            {usersSection: "default_groups = users,plug dev", wantErr: true},
+		"Error_when_default_group_is_empty":              {usersSection: "default_groups = users,,plugdev", wantErr: true},
+		"Error_when_home_template_is_relative":           {usersSection: "home_template = home/%u", wantErr: true},
+		"Error_when_home_template_has_no_user":           {usersSection: "home_template = /home/%d", wantErr: true},
+		"Error_when_home_template_has_unknown_directive": {usersSection: "home_template = /home/%g/%u", wantErr: true},
+		"Error_when_home_skel_is_relative":               {usersSection: "home_skel = skel", wantErr: true},
+		"Error_when_home_mode_is_not_octal":              {usersSection: "home_mode = rwx", wantErr: true},
+		"Error_when_home_mode_is_too_large":              {usersSection: "home_mode = 01777", wantErr: true},
+		"Error_when_home_subdir_is_absolute":             {usersSection: "home_subdirs = /tmp", wantErr: true},
+		"Error_when_home_subdir_is_outside_the_home":     {usersSection: "home_subdirs = ../other", wantErr: true},
+		"Error_when_home_group_does_not_exist":           {usersSection: "home_group = doesnotexist", wantErr: true},
+		"Error_when_home_quota_is_not_a_size":            {usersSection: "home_quota = 10GB", wantErr: true},
+		"Error_when_home_quota_is_smaller_than_1K":       {usersSection: "home_quota = 512", wantErr: true},
+		"Error_when_home_quota_is_too_large":             {usersSection: "home_quota = 99999999999T", wantErr: true},
+		"Error_when_home_quota_group_has_no_size":        {usersSection: "home_quota_groups = students", wantErr: true},
+		"Error_when_home_quota_group_size_is_invalid":    {usersSection: "home_quota_groups = students:lots", wantErr: true},
+		"Error_when_home_quota_type_is_invalid":          {usersSection: "home_quota = 10G\nhome_quota_type = group", wantErr: true},
+		"Error_when_home_quota_type_has_no_quota":        {usersSection: "home_quota_type = project", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			conn, err := testutils.GetSystemBusConnection(t)
+			require.NoError(t, err, "Setup: could not connect to system bus")
+
+			configFile := filepath.Join(t.TempDir(), "broker.conf")
+			err = os.WriteFile(configFile, []byte(authdSection+"[users]\n"+tc.usersSection+"\n"), 0600)
+			require.NoError(t, err, "Setup: could not write broker configuration")
+
+			got, err := brokers.NewBroker(context.Background(), configFile, conn)
+			if tc.wantErr {
+				require.Error(t, err, "NewBroker should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "NewBroker should not return an error, but did")
+			require.Equal(t, tc.want, got.UserDefaults, "NewBroker should load the expected user defaults")
+		})
+	}
+}
+
+func TestHomeSettingsPath(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		template string
+		username string
+
+		want string
+	}{
+		"User_name":                {template: "/home/%u", username: "user1@example.com", want: "/home/user1@example.com"},
+		"Domain_and_local_part":    {template: "/home/%d/%l", username: "user1@example.com", want: "/home/example.com/user1"},
+		"Escaped_percent":          {template: "/home/%%/%l", username: "user1@example.com", want: "/home/%/user1"},
+		"User_name_without_domain": {template: "/home/%d/%l", username: "user1", want: "/home/user1"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := brokers.HomeSettings{Template: tc.template}.Path(tc.username)
+			require.Equal(t, tc.want, got, "Path should return the home of the user from the template")
+		})
+	}
+}
+
+func TestHomeSettingsQuotaFor(t *testing.T) {
+	t.Parallel()
+
+	settings := brokers.HomeSettings{Quota: 10 << 30, GroupQuotas: map[string]uint64{"students": 5 << 30, "staff": 50 << 30}}
+
+	tests := map[string]struct {
+		groups []string
+
+		want uint64
+	}{
+		"Quota_of_the_broker_without_groups":     {want: 10 << 30},
+		"Quota_of_the_broker_for_other_group":    {groups: []string{"users"}, want: 10 << 30},
+		"Quota_of_the_group":                     {groups: []string{"users", "students"}, want: 5 << 30},
+		"Quota_of_the_group_is_case_insensitive": {groups: []string{"Students"}, want: 5 << 30},
+		"Largest_quota_of_the_groups":            {groups: []string{"students", "staff"}, want: 50 << 30},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := settings.QuotaFor(tc.groups)
+			require.Equal(t, tc.want, got, "QuotaFor should return the quota of the member of the groups")
+		})
+	}
+}
+
 func TestGetAuthenticationModes(t *testing.T) {
 	t.Parallel()
 
@@ -99,6 +242,7 @@ func TestGetAuthenticationModes(t *testing.T) {
 	tests := map[string]struct {
 		sessionID          string
 		supportedUILayouts []string
+		noSession          bool
 
 		wantErr bool
 	}{
@@ -112,6 +256,7 @@ func TestGetAuthenticationModes(t *testing.T) {
 		// broker errors
 		"Error_when_getting_authentication_modes": {sessionID: "gam_error", wantErr: true},
 		"Error_when_broker_returns_invalid_modes": {sessionID: "gam_invalid", wantErr: true},
+		"Error_when_the_session_was_not_started":  {sessionID: "success", noSession: true, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -126,6 +271,10 @@ func TestGetAuthenticationModes(t *testing.T) {
 				supportedUILayouts = append(supportedUILayouts, supportedLayouts[layout])
 			}
 
+			if !tc.noSession {
+				b.AddOngoingUserRequest(prefixID(t, tc.sessionID), t.Name()+testutils.IDSeparator+tc.sessionID)
+			}
+
 			gotModes, err := b.GetAuthenticationModes(context.Background(), prefixID(t, tc.sessionID), supportedUILayouts)
 			if tc.wantErr {
 				require.Error(t, err, "GetAuthenticationModes should return an error, but did not")
@@ -215,6 +364,7 @@ func TestIsAuthenticated(t *testing.T) {
 		"Successfully_authenticate":                                        {sessionID: "success"},
 		"Successfully_authenticate_with_granted_message":                   {sessionID: "ia_granted_with_data"},
 		"Ignores_non_string_message_in_granted_response":                   {sessionID: "ia_granted_with_non_string_message"},
+		"Successfully_authenticate_offline":                                {sessionID: "ia_granted_offline"},
 		"Successfully_authenticate_after_cancelling_first_call":            {sessionID: "ia_second_call", secondCall: true},
 		"Denies_authentication_when_broker_times_out":                      {sessionID: "ia_timeout"},
 		"Adds_default_groups_even_if_broker_did_not_set_them":              {sessionID: "ia_info_empty_groups"},
@@ -223,22 +373,30 @@ func TestIsAuthenticated(t *testing.T) {
 		"No_error_when_broker_returns_userinfo_with_empty_gecos":           {sessionID: "ia_info_empty_gecos"},
 		"No_error_when_broker_returns_userinfo_with_group_with_empty_UGID": {sessionID: "ia_info_empty_ugid"},
 		"No_error_when_broker_returns_userinfo_with_mismatching_username":  {sessionID: "ia_info_mismatching_user_name"},
+		"Successfully_authenticate_with_session_environment":               {sessionID: "ia_info_with_env"},
+		"Successfully_authenticate_with_SSH_keys":                          {sessionID: "ia_info_with_ssh_keys"},
+		"Successfully_authenticate_with_picture":                           {sessionID: "ia_info_with_picture"},
 
 		// broker errors
-		"Error_when_authenticating":                                      {sessionID: "ia_error"},
-		"Error_on_empty_data_even_if_granted":                            {sessionID: "ia_empty_data"},
-		"Error_when_broker_returns_invalid_data":                         {sessionID: "ia_invalid_data"},
-		"Error_when_broker_returns_invalid_access":                       {sessionID: "ia_invalid_access"},
-		"Error_when_broker_returns_invalid_userinfo":                     {sessionID: "ia_invalid_userinfo"},
-		"Error_when_broker_returns_userinfo_with_empty_username":         {sessionID: "ia_info_empty_user_name"},
-		"Error_when_broker_returns_userinfo_with_empty_group_name":       {sessionID: "ia_info_empty_group_name"},
-		"Error_when_broker_returns_userinfo_with_invalid_homedir":        {sessionID: "ia_info_invalid_home"},
-		"Error_when_broker_returns_userinfo_with_invalid_shell":          {sessionID: "ia_info_invalid_shell"},
-		"Error_when_broker_returns_invalid_data_on_auth.Next":            {sessionID: "ia_next_with_invalid_data"},
-		"Error_when_broker_returns_data_on_auth.Cancelled":               {sessionID: "ia_cancelled_with_data"},
-		"Error_when_broker_returns_no_data_on_auth.Denied":               {sessionID: "ia_denied_without_data"},
-		"Error_when_broker_returns_no_data_on_auth.Retry":                {sessionID: "ia_retry_without_data"},
-		"Successfully_authenticate_after_second_call_without_cancelling": {sessionID: "ia_second_call", secondCall: true, cancelFirstCall: true},
+		"Error_when_authenticating":                                           {sessionID: "ia_error"},
+		"Error_on_empty_data_even_if_granted":                                 {sessionID: "ia_empty_data"},
+		"Error_when_broker_returns_invalid_data":                              {sessionID: "ia_invalid_data"},
+		"Error_when_broker_returns_invalid_access":                            {sessionID: "ia_invalid_access"},
+		"Error_when_broker_returns_invalid_userinfo":                          {sessionID: "ia_invalid_userinfo"},
+		"Error_when_broker_returns_invalid_offline_value":                     {sessionID: "ia_invalid_offline"},
+		"Error_when_broker_returns_userinfo_with_empty_username":              {sessionID: "ia_info_empty_user_name"},
+		"Error_when_broker_returns_userinfo_with_empty_group_name":            {sessionID: "ia_info_empty_group_name"},
+		"Error_when_broker_returns_userinfo_with_invalid_homedir":             {sessionID: "ia_info_invalid_home"},
+		"Error_when_broker_returns_userinfo_with_invalid_shell":               {sessionID: "ia_info_invalid_shell"},
+		"Error_when_broker_returns_userinfo_with_invalid_env_name":            {sessionID: "ia_info_invalid_env_name"},
+		"Error_when_broker_returns_userinfo_with_reserved_env":                {sessionID: "ia_info_reserved_env"},
+		"Error_when_broker_returns_userinfo_with_SSH_key_with_options":        {sessionID: "ia_info_ssh_key_with_options"},
+		"Error_when_broker_returns_userinfo_with_picture_of_unsupported_type": {sessionID: "ia_info_picture_of_unsupported_type"},
+		"Error_when_broker_returns_invalid_data_on_auth.Next":                 {sessionID: "ia_next_with_invalid_data"},
+		"Error_when_broker_returns_data_on_auth.Cancelled":                    {sessionID: "ia_cancelled_with_data"},
+		"Error_when_broker_returns_no_data_on_auth.Denied":                    {sessionID: "ia_denied_without_data"},
+		"Error_when_broker_returns_no_data_on_auth.Retry":                     {sessionID: "ia_retry_without_data"},
+		"Successfully_authenticate_after_second_call_without_cancelling":      {sessionID: "ia_second_call", secondCall: true, cancelFirstCall: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -298,6 +456,8 @@ func TestCancelIsAuthenticated(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
+			b.AddOngoingUserRequest(prefixID(t, tc.sessionID), t.Name()+testutils.IDSeparator+tc.sessionID)
+
 			var access string
 			ctx, cancel := context.WithCancel(context.Background())
 			done := make(chan struct{})
@@ -375,6 +535,203 @@ func TestDeleteUser(t *testing.T) {
 	}
 }
 
+func TestPing(t *testing.T) {
+	t.Parallel()
+
+	cfgPath, stopBroker, err := testutils.StartBusBrokerMock(t.TempDir(), t.Name())
+	require.NoError(t, err, "Setup: could not start bus broker mock")
+	conn, err := testutils.GetSystemBusConnection(t)
+	require.NoError(t, err, "Setup: could not connect to system bus")
+	t.Cleanup(func() { _ = conn.Close() })
+	b, err := brokers.NewBroker(context.Background(), cfgPath, conn)
+	require.NoError(t, err, "Setup: could not create broker")
+
+	require.NoError(t, b.Ping(context.Background()), "Ping should not return an error when the broker is running")
+	require.EqualValues(t, brokers.LatestAPIVersion, b.APIVersion(), "APIVersion should return the version of the broker interface")
+
+	stopBroker()
+	require.Error(t, b.Ping(context.Background()), "Ping should return an error when the broker is not running")
+}
+
+func TestVerifyUser(t *testing.T) {
+	t.Parallel()
+
+	b := newBrokerForTests(t, "", "")
+
+	tests := map[string]struct {
+		username string
+
+		wantErr bool
+	}{
+		"Successfully_verify_active_user":   {username: "user1@example.com"},
+		"Successfully_verify_disabled_user": {username: "verify_disabled@example.com"},
+		"Successfully_verify_unknown_user":  {username: "verify_unknown@example.com"},
+
+		"Error_when_broker_returns_error":          {username: "verify_error@example.com", wantErr: true},
+		"Error_when_broker_returns_invalid_status": {username: "verify_invalid_status@example.com", wantErr: true},
+		"Error_when_broker_returns_invalid_data":   {username: "verify_invalid_data@example.com", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := b.VerifyUser(context.Background(), tc.username, "providerid-"+tc.username)
+			if tc.wantErr {
+				require.Error(t, err, "VerifyUser should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "VerifyUser should not return an error, but did")
+
+			golden.CheckOrUpdateYAML(t, got)
+		})
+	}
+}
+
+func TestSessionToken(t *testing.T) {
+	t.Parallel()
+
+	b := newBrokerForTests(t, "", "")
+
+	tests := map[string]struct {
+		username string
+		scopes   []string
+
+		wantErr bool
+	}{
+		"Successfully_get_session_token":                 {username: "user1@example.com", scopes: []string{"openid"}},
+		"Successfully_get_session_token_for_many_scopes": {username: "user1@example.com", scopes: []string{"openid", "email"}},
+
+		"Error_when_broker_returns_error":        {username: "token_error@example.com", wantErr: true},
+		"Error_when_broker_returns_invalid_data": {username: "token_invalid@example.com", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := b.SessionToken(context.Background(), tc.username, tc.scopes)
+			if tc.wantErr {
+				require.Error(t, err, "SessionToken should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "SessionToken should not return an error, but did")
+
+			golden.CheckOrUpdateYAML(t, got)
+		})
+	}
+}
+
+func TestTokenStatus(t *testing.T) {
+	t.Parallel()
+
+	b := newBrokerForTests(t, "", "")
+
+	tests := map[string]struct {
+		username string
+
+		wantErr bool
+	}{
+		"Successfully_get_token_status": {username: "user1@example.com"},
+
+		"Error_when_broker_returns_error":        {username: "token_error@example.com", wantErr: true},
+		"Error_when_broker_returns_invalid_data": {username: "token_invalid@example.com", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := b.TokenStatus(context.Background(), tc.username)
+			if tc.wantErr {
+				require.Error(t, err, "TokenStatus should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "TokenStatus should not return an error, but did")
+
+			golden.CheckOrUpdateYAML(t, got)
+		})
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	b := newBrokerForTests(t, "", "")
+
+	tests := map[string]struct {
+		username string
+
+		wantErr bool
+	}{
+		"Successfully_refresh_token": {username: "user1@example.com"},
+
+		"Error_when_broker_returns_error":        {username: "refresh_error@example.com", wantErr: true},
+		"Error_when_broker_returns_invalid_data": {username: "refresh_invalid@example.com", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := b.RefreshToken(context.Background(), tc.username)
+			if tc.wantErr {
+				require.Error(t, err, "RefreshToken should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "RefreshToken should not return an error, but did")
+
+			golden.CheckOrUpdateYAML(t, got)
+		})
+	}
+}
+
+func TestCheckProvider(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		wantErr bool
+	}{
+		"Successfully_check_the_provider":             {},
+		"Successfully_report_a_provider_check_failed": {},
+
+		"Error_when_broker_returns_provider_check_error":   {wantErr: true},
+		"Error_when_broker_returns_provider_check_invalid": {wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// The broker mock answers depending on its name, which is the name of the test.
+			b := newBrokerForTests(t, "", "")
+
+			got, err := b.CheckProvider(context.Background())
+			if tc.wantErr {
+				require.Error(t, err, "CheckProvider should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "CheckProvider should not return an error, but did")
+
+			golden.CheckOrUpdateYAML(t, got)
+		})
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	t.Parallel()
+
+	b := newBrokerForTests(t, "", "")
+
+	want := []string{
+		brokers.CapabilityProviderCheck,
+		brokers.CapabilitySessionHints,
+		brokers.CapabilitySessionTokens,
+		brokers.CapabilityTokenRefresh,
+		brokers.CapabilityTokenStatus,
+		brokers.CapabilityVerifyUser,
+	}
+	require.Equal(t, want, b.Capabilities(), "Capabilities should return the features of the broker mock")
+	require.NotEmpty(t, b.ConfigFile, "The configuration file of the broker should be set")
+	require.NotEmpty(t, b.DBusName, "The D-Bus name of the broker should be set")
+	require.NotEmpty(t, b.DBusObject, "The D-Bus object of the broker should be set")
+}
+
 func newBrokerForTests(t *testing.T, cfgDir, brokerCfg string) (b brokers.Broker) {
 	t.Helper()
 
@@ -20,10 +20,51 @@ const (
 	// DbusBaseInterface is the expected interface that should be implemented by the brokers.
 	DbusBaseInterface string = "com.ubuntu.authd.Broker"
 
+	// DbusControlInterface is the interface through which the brokers can optionally be managed by authd, exported on
+	// the same object as the broker interface.
+	DbusControlInterface string = "com.ubuntu.authd.BrokerControl"
+
 	// LatestAPIVersion is the latest API version supported by authd.
-	LatestAPIVersion = 3
+	LatestAPIVersion = 4
+)
+
+var (
+	// ErrVerifyUserNotSupported is returned when the broker implements an API version without VerifyUser.
+	ErrVerifyUserNotSupported = errors.New("the broker does not support verifying users")
+	// ErrSessionTokenNotSupported is returned when the broker does not hand tokens to the sessions of its users.
+	ErrSessionTokenNotSupported = errors.New("the broker does not support session tokens")
+	// ErrTokenStatusNotSupported is returned when the broker does not report the status of the tokens it caches.
+	ErrTokenStatusNotSupported = errors.New("the broker does not report the status of its tokens")
+	// ErrTokenRefreshNotSupported is returned when the broker can't refresh the tokens it caches on request.
+	ErrTokenRefreshNotSupported = errors.New("the broker does not support refreshing its tokens")
+	// ErrSessionHintsNotSupported is returned when the broker does not accept hints about the sessions.
+	ErrSessionHintsNotSupported = errors.New("the broker does not support session hints")
+	// ErrProviderCheckNotSupported is returned when the broker can't check its connection to its provider.
+	ErrProviderCheckNotSupported = errors.New("the broker does not support checking its provider")
 )
 
+// SessionHintTimezone is the session hint with the IANA name of the time zone of the user.
+const SessionHintTimezone = "timezone"
+
+// The optional features which the brokers can support.
+const (
+	CapabilityVerifyUser    = "verify_user"
+	CapabilitySessionTokens = "session_tokens"
+	CapabilityTokenStatus   = "token_status"
+	CapabilityTokenRefresh  = "token_refresh"
+	CapabilitySessionHints  = "session_hints"
+	CapabilityProviderCheck = "provider_check"
+)
+
+// controlCapabilities are the features provided by the methods of the control interface.
+var controlCapabilities = map[string]string{
+	"SessionToken":    CapabilitySessionTokens,
+	"TokenStatus":     CapabilityTokenStatus,
+	"RefreshToken":    CapabilityTokenRefresh,
+	"SetSessionHints": CapabilitySessionHints,
+	"CheckProvider":   CapabilityProviderCheck,
+}
+
 type dbusInterface struct {
 	name    string
 	version uint
@@ -32,6 +73,8 @@ type dbusInterface struct {
 type dbusBroker struct {
 	name  string
 	iface dbusInterface
+	// controlMethods are the methods of the control interface exported by the broker.
+	controlMethods []string
 
 	dbusObject dbus.BusObject
 }
@@ -72,7 +115,7 @@ func newDbusBroker(ctx context.Context, bus *dbus.Conn, configFile string) (b db
 		dbusObject: bus.Object(dbusName.String(), dbus.ObjectPath(objectName.String())),
 	}
 
-	dBroker.iface, err = getInterface(dBroker.dbusObject)
+	dBroker.iface, dBroker.controlMethods, err = getInterface(dBroker.dbusObject)
 	if err != nil {
 		return b, "", "", fmt.Errorf("could not detect broker interfaces: %v", err)
 	}
@@ -81,15 +124,22 @@ func newDbusBroker(ctx context.Context, bus *dbus.Conn, configFile string) (b db
 }
 
 // getInterface introspects the broker's D-Bus object and returns the interface with the highest version supported both
-// by the broker and authd.
-func getInterface(obj dbus.BusObject) (dbusInterface, error) {
+// by the broker and authd, and the methods of the control interface if the broker exports it.
+func getInterface(obj dbus.BusObject) (_ dbusInterface, controlMethods []string, err error) {
 	node, err := introspect.Call(obj)
 	if err != nil {
-		return dbusInterface{}, fmt.Errorf("could not introspect broker: %v", err)
+		return dbusInterface{}, nil, fmt.Errorf("could not introspect broker: %v", err)
 	}
 
 	var supportedInterfaces []dbusInterface
 	for _, iface := range node.Interfaces {
+		// The control interface is not versioned and is optional.
+		if iface.Name == DbusControlInterface {
+			for _, m := range iface.Methods {
+				controlMethods = append(controlMethods, m.Name)
+			}
+			continue
+		}
 		// Ignore interfaces that do not satisfy the expected format, as they are not relevant for selecting the broker
 		// interface version.
 		// The expected format is com.ubuntu.authd.BrokerX, where X is the version number (or empty for the first
@@ -118,10 +168,10 @@ func getInterface(obj dbus.BusObject) (dbusInterface, error) {
 	})
 
 	if len(supportedInterfaces) == 0 {
-		return dbusInterface{}, errors.New("no supported interfaces found")
+		return dbusInterface{}, nil, errors.New("no supported interfaces found")
 	}
 
-	return supportedInterfaces[len(supportedInterfaces)-1], nil
+	return supportedInterfaces[len(supportedInterfaces)-1], controlMethods, nil
 }
 
 // interfaceVersion extracts the version number from the broker interface name.
@@ -252,6 +302,158 @@ func (b dbusBroker) DeleteUser(ctx context.Context, username, providerID string)
 	return nil
 }
 
+// VerifyUser calls the corresponding method on the broker bus. It was added in API v4.
+func (b dbusBroker) VerifyUser(ctx context.Context, username, providerID string) (userStatus, data string, err error) {
+	if b.iface.version < 4 {
+		return "", "", ErrVerifyUserNotSupported
+	}
+
+	call, err := b.call(ctx, "VerifyUser", username, providerID)
+	if err != nil {
+		return "", "", err
+	}
+	if err = call.Store(&userStatus, &data); err != nil {
+		return "", "", err
+	}
+
+	return userStatus, data, nil
+}
+
+// SessionToken calls the corresponding method on the control interface of the broker and returns the token as JSON.
+func (b dbusBroker) SessionToken(ctx context.Context, username string, scopes []string) (sessionToken string, err error) {
+	call := b.dbusObject.CallWithContext(ctx, DbusControlInterface+".SessionToken", 0, username, scopes)
+	if err := call.Err; err != nil {
+		var dbusError dbus.Error
+		if errors.As(err, &dbusError) && slices.Contains([]string{
+			"org.freedesktop.DBus.Error.UnknownMethod",
+			"org.freedesktop.DBus.Error.UnknownInterface",
+		}, dbusError.Name) {
+			return "", ErrSessionTokenNotSupported
+		}
+		if errors.As(err, &dbusError) && dbusError.Name == "org.freedesktop.DBus.Error.ServiceUnknown" {
+			return "", fmt.Errorf("couldn't connect to broker %q. Is it running?", b.name)
+		}
+		return "", err
+	}
+	if err = call.Store(&sessionToken); err != nil {
+		return "", err
+	}
+
+	return sessionToken, nil
+}
+
+// TokenStatus calls the corresponding method on the control interface of the broker and returns the status as JSON.
+func (b dbusBroker) TokenStatus(ctx context.Context, username string) (tokenStatus string, err error) {
+	call := b.dbusObject.CallWithContext(ctx, DbusControlInterface+".TokenStatus", 0, username)
+	if err := call.Err; err != nil {
+		var dbusError dbus.Error
+		if errors.As(err, &dbusError) && slices.Contains([]string{
+			"org.freedesktop.DBus.Error.UnknownMethod",
+			"org.freedesktop.DBus.Error.UnknownInterface",
+		}, dbusError.Name) {
+			return "", ErrTokenStatusNotSupported
+		}
+		if errors.As(err, &dbusError) && dbusError.Name == "org.freedesktop.DBus.Error.ServiceUnknown" {
+			return "", fmt.Errorf("couldn't connect to broker %q. Is it running?", b.name)
+		}
+		return "", err
+	}
+	if err = call.Store(&tokenStatus); err != nil {
+		return "", err
+	}
+
+	return tokenStatus, nil
+}
+
+// RefreshToken calls the corresponding method on the control interface of the broker and returns the status of the
+// refreshed token as JSON.
+func (b dbusBroker) RefreshToken(ctx context.Context, username string) (tokenStatus string, err error) {
+	call := b.dbusObject.CallWithContext(ctx, DbusControlInterface+".RefreshToken", 0, username)
+	if err := call.Err; err != nil {
+		var dbusError dbus.Error
+		if errors.As(err, &dbusError) && slices.Contains([]string{
+			"org.freedesktop.DBus.Error.UnknownMethod",
+			"org.freedesktop.DBus.Error.UnknownInterface",
+		}, dbusError.Name) {
+			return "", ErrTokenRefreshNotSupported
+		}
+		if errors.As(err, &dbusError) && dbusError.Name == "org.freedesktop.DBus.Error.ServiceUnknown" {
+			return "", fmt.Errorf("couldn't connect to broker %q. Is it running?", b.name)
+		}
+		return "", err
+	}
+	if err = call.Store(&tokenStatus); err != nil {
+		return "", err
+	}
+
+	return tokenStatus, nil
+}
+
+// SetSessionHints calls the corresponding method on the control interface of the broker.
+func (b dbusBroker) SetSessionHints(ctx context.Context, sessionID string, hints map[string]string) error {
+	call := b.dbusObject.CallWithContext(ctx, DbusControlInterface+".SetSessionHints", 0, sessionID, hints)
+	if err := call.Err; err != nil {
+		var dbusError dbus.Error
+		if errors.As(err, &dbusError) && slices.Contains([]string{
+			"org.freedesktop.DBus.Error.UnknownMethod",
+			"org.freedesktop.DBus.Error.UnknownInterface",
+		}, dbusError.Name) {
+			return ErrSessionHintsNotSupported
+		}
+		return err
+	}
+
+	return nil
+}
+
+// CheckProvider calls the corresponding method on the control interface of the broker and returns the checks as JSON.
+func (b dbusBroker) CheckProvider(ctx context.Context) (checks string, err error) {
+	call := b.dbusObject.CallWithContext(ctx, DbusControlInterface+".CheckProvider", 0)
+	if err := call.Err; err != nil {
+		var dbusError dbus.Error
+		if errors.As(err, &dbusError) && slices.Contains([]string{
+			"org.freedesktop.DBus.Error.UnknownMethod",
+			"org.freedesktop.DBus.Error.UnknownInterface",
+		}, dbusError.Name) {
+			return "", ErrProviderCheckNotSupported
+		}
+		if errors.As(err, &dbusError) && dbusError.Name == "org.freedesktop.DBus.Error.ServiceUnknown" {
+			return "", fmt.Errorf("couldn't connect to broker %q. Is it running?", b.name)
+		}
+		return "", err
+	}
+	if err = call.Store(&checks); err != nil {
+		return "", err
+	}
+
+	return checks, nil
+}
+
+// Capabilities returns the optional features supported by the broker, from the version of its interface and the
+// methods of its control interface.
+func (b dbusBroker) Capabilities() (capabilities []string) {
+	if b.iface.version >= 4 {
+		capabilities = append(capabilities, CapabilityVerifyUser)
+	}
+	for _, m := range b.controlMethods {
+		if c, ok := controlCapabilities[m]; ok {
+			capabilities = append(capabilities, c)
+		}
+	}
+	slices.Sort(capabilities)
+	return capabilities
+}
+
+// Ping checks that the broker answers on the bus.
+func (b dbusBroker) Ping(ctx context.Context) error {
+	return b.dbusObject.CallWithContext(ctx, "org.freedesktop.DBus.Peer.Ping", 0).Err
+}
+
+// APIVersion returns the version of the interface used to call the broker.
+func (b dbusBroker) APIVersion() uint {
+	return b.iface.version
+}
+
 // call is an abstraction over dbus calls to ensure we wrap the returned error to an ErrorToDisplay.
 // All wrapped errors will be logged, but not returned to the UI.
 func (b dbusBroker) call(ctx context.Context, method string, args ...interface{}) (*dbus.Call, error) {
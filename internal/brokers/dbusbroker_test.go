@@ -77,8 +77,9 @@ func TestGetInterface(t *testing.T) {
 		},
 		"Returns_highest_supported_version": {
 			interfaces: []string{"com.ubuntu.authd.Broker1", "com.ubuntu.authd.Broker2", "com.ubuntu.authd.Broker3",
+				"com.ubuntu.authd.Broker4",
 				"com.ubuntu.authd.Broker999"}, // This one should be ignored as it's above the latest supported API version.
-			wantInterface: dbusInterface{name: "com.ubuntu.authd.Broker3", version: 3},
+			wantInterface: dbusInterface{name: "com.ubuntu.authd.Broker4", version: 4},
 		},
 		"Versioned_interfaces_with_unversioned": {
 			interfaces:    []string{"com.ubuntu.authd.Broker2", "com.ubuntu.authd.Broker", "com.ubuntu.authd.Broker1"},
@@ -99,7 +100,7 @@ func TestGetInterface(t *testing.T) {
 			wantErr:    true,
 		},
 		"Error_when_all_interfaces_above_latest_version": {
-			interfaces: []string{"com.ubuntu.authd.Broker4", "com.ubuntu.authd.Broker5"},
+			interfaces: []string{"com.ubuntu.authd.Broker5", "com.ubuntu.authd.Broker6"},
 			wantErr:    true,
 		},
 		"Error_when_introspect_fails": {
@@ -116,7 +117,7 @@ func TestGetInterface(t *testing.T) {
 				mock.introspectXML = introspectionXML(tc.interfaces...)
 			}
 
-			got, err := getInterface(mock)
+			got, _, err := getInterface(mock)
 			if tc.wantErr {
 				require.Error(t, err, "getInterface should return an error, but did not")
 				return
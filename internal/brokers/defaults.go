@@ -0,0 +1,136 @@
+package brokers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/canonical/authd/log"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultBrokersFileName is the name of the file of the brokers configuration directory which stores the brokers
+// selected for the users who never logged in.
+const DefaultBrokersFileName = "defaults.yaml"
+
+const defaultBrokersHeader = "# This file is managed by \"authctl broker set-default\".\n"
+
+// defaultBrokers are the brokers, by name, selected for the users who never logged in.
+type defaultBrokers struct {
+	// Default is the broker used by all the PAM services without a broker of their own.
+	Default string `yaml:"default,omitempty"`
+	// Services are the brokers used by specific PAM services.
+	Services map[string]string `yaml:"services,omitempty"`
+}
+
+// loadDefaultBrokers reads the default brokers from path. A missing file means that no default broker is set.
+func loadDefaultBrokers(path string) (d defaultBrokers, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return d, err
+	}
+	if err := yaml.Unmarshal(data, &d); err != nil {
+		return defaultBrokers{}, fmt.Errorf("invalid default brokers file %q: %w", path, err)
+	}
+	return d, nil
+}
+
+// save writes the default brokers to path, replacing the previous file at once.
+func (d defaultBrokers) save(path string) error {
+	data, err := yaml.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	//nolint:gosec // G306 The brokers configuration files are readable by everyone.
+	if err := os.WriteFile(tmp, append([]byte(defaultBrokersHeader), data...), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// DefaultBroker returns the broker selected for the users who never logged in through the PAM service, if any and if
+// it is still available.
+func (m *Manager) DefaultBroker(service string) *Broker {
+	m.defaultsMu.RLock()
+	name, ok := m.defaults.Services[service]
+	if !ok {
+		name = m.defaults.Default
+	}
+	m.defaultsMu.RUnlock()
+
+	if name == "" {
+		return nil
+	}
+	b := m.BrokerFromName(name)
+	if b == nil {
+		log.Warningf(context.Background(), "Default broker %q is not available", name)
+	}
+	return b
+}
+
+// DefaultFor returns whether the broker is the default one of all the PAM services without a broker of their own, and
+// the PAM services which it is the default broker of.
+func (m *Manager) DefaultFor(b *Broker) (all bool, services []string) {
+	m.defaultsMu.RLock()
+	defer m.defaultsMu.RUnlock()
+
+	all = strings.EqualFold(m.defaults.Default, b.Name)
+	for s, name := range m.defaults.Services {
+		if strings.EqualFold(name, b.Name) {
+			services = append(services, s)
+		}
+	}
+	slices.Sort(services)
+	return all, services
+}
+
+// SetDefaultBroker selects the broker for the users who never logged in through the PAM service, or through all the
+// services without a broker of their own if service is empty, and stores it in the brokers configuration directory.
+func (m *Manager) SetDefaultBroker(service, brokerID string) error {
+	b, err := m.BrokerFromID(brokerID)
+	if err != nil {
+		return err
+	}
+
+	m.defaultsMu.Lock()
+	defer m.defaultsMu.Unlock()
+
+	d := defaultBrokers{Default: m.defaults.Default, Services: make(map[string]string)}
+	for s, name := range m.defaults.Services {
+		d.Services[s] = name
+	}
+	if service == "" {
+		d.Default = b.Name
+	} else {
+		d.Services[service] = b.Name
+	}
+
+	if err := d.save(m.defaultBrokersPath); err != nil {
+		return fmt.Errorf("could not store default broker: %w", err)
+	}
+	m.defaults = d
+	return nil
+}
+
+// BrokerFromName returns the available broker with the given name, ignoring case, or nil if there is none.
+func (m *Manager) BrokerFromName(name string) *Broker {
+	for _, id := range m.brokersOrder {
+		if strings.EqualFold(m.brokers[id].Name, name) {
+			return m.brokers[id]
+		}
+	}
+	return nil
+}
@@ -20,23 +20,34 @@ func (m *Manager) SetBrokerForSession(b *Broker, sessionID string) {
 	m.transactionsToBrokerMu.Lock()
 	m.transactionsToBroker[sessionID] = b
 	m.transactionsToBrokerMu.Unlock()
+
+	b.addSession(b.parseSessionID(sessionID), "")
 }
 
 // GenerateLayoutValidators generates the layout validators and assign them to the specified broker.
 func GenerateLayoutValidators(b *Broker, sessionID string, supportedUILayouts []map[string]string) {
-	b.layoutValidatorsMu.Lock()
-	defer b.layoutValidatorsMu.Unlock()
-	b.layoutValidators[sessionID] = generateValidators(context.Background(), sessionID, supportedUILayouts)
+	s, err := b.session(sessionID)
+	if err != nil {
+		b.addSession(sessionID, "")
+		s, _ = b.session(sessionID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.layoutValidators = generateValidators(context.Background(), sessionID, supportedUILayouts)
 }
 
 // LayoutValidatorsString returns a string representation of the layout validators.
 func (b *Broker) LayoutValidatorsString(sessionID string) string {
 	// Gets the map keys and sort them
-	b.layoutValidatorsMu.Lock()
-	defer b.layoutValidatorsMu.Unlock()
+	session, err := b.session(sessionID)
+	if err != nil {
+		return ""
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
 
 	var keys []string
-	for k := range b.layoutValidators[sessionID] {
+	for k := range session.layoutValidators {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
@@ -45,7 +56,7 @@ func (b *Broker) LayoutValidatorsString(sessionID string) string {
 	for _, k := range keys {
 		layoutStr := fmt.Sprintf("\t%s:\n", k)
 
-		validator := b.layoutValidators[sessionID][k]
+		validator := session.layoutValidators[k]
 
 		// Same thing for sorting the keys of the validator map
 		var vKeys []string
@@ -65,7 +76,38 @@ func (b *Broker) LayoutValidatorsString(sessionID string) string {
 
 // AddOngoingUserRequest adds an ongoing user request to the broker for tests.
 func (b *Broker) AddOngoingUserRequest(sessionID, username string) {
-	b.ongoingUserRequestsMu.Lock()
-	defer b.ongoingUserRequestsMu.Unlock()
-	b.ongoingUserRequests[sessionID] = username
+	b.addSession(sessionID, username)
+}
+
+// EndSession ends the session with the broker directly, without going through the manager.
+func (b *Broker) EndSession(sessionID string) error {
+	return b.endSession(context.Background(), sessionID)
+}
+
+// OngoingSessions returns the number of sessions whose state is kept by the broker.
+func (b *Broker) OngoingSessions() int {
+	b.sessionsMu.Lock()
+	defer b.sessionsMu.Unlock()
+	return len(b.sessions)
+}
+
+// NotifyCalls sends the name of the methods of the broker called for the sessions to calls, once they are called.
+func (b *Broker) NotifyCalls(calls chan<- string) {
+	b.brokerer = callsNotifier{brokerer: b.brokerer, calls: calls}
+}
+
+// callsNotifier is a brokerer which notifies the calls of the methods of the sessions.
+type callsNotifier struct {
+	brokerer
+	calls chan<- string
+}
+
+func (n callsNotifier) IsAuthenticated(ctx context.Context, sessionID, authenticationData string) (string, string, error) {
+	n.calls <- "IsAuthenticated"
+	return n.brokerer.IsAuthenticated(ctx, sessionID, authenticationData)
+}
+
+func (n callsNotifier) EndSession(ctx context.Context, sessionID string) error {
+	n.calls <- "EndSession"
+	return n.brokerer.EndSession(ctx, sessionID)
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/canonical/authd/internal/testutils/golden"
+	"github.com/canonical/authd/internal/users/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -93,3 +94,26 @@ func TestUnmarshalUserInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyUserDefaults(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		defaults UserDefaults
+		info     types.UserInfo
+
+		want types.UserInfo
+	}{
+		"Keep_attributes_of_the_provider":      {defaults: UserDefaults{Shell: "/bin/zsh"}, info: types.UserInfo{Name: "user1@example.com", Dir: "/home/user1@example.com", Shell: "/bin/sh"}, want: types.UserInfo{Name: "user1@example.com", Dir: "/home/user1@example.com", Shell: "/bin/sh"}},
+		"Default_shell_when_provider_has_none": {defaults: UserDefaults{Shell: "/bin/zsh"}, info: types.UserInfo{Name: "user1@example.com", Dir: "/home/user1@example.com"}, want: types.UserInfo{Name: "user1@example.com", Dir: "/home/user1@example.com", Shell: "/bin/zsh"}},
+		"Home_from_template":                   {defaults: UserDefaults{Home: HomeSettings{Template: "/home/%d/%l"}}, info: types.UserInfo{Name: "user1@example.com", Dir: "/home/user1@example.com", Shell: "/bin/sh"}, want: types.UserInfo{Name: "user1@example.com", Dir: "/home/example.com/user1", Shell: "/bin/sh"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := tc.defaults.Apply(tc.info)
+			require.Equal(t, tc.want, got, "apply should return the expected user info")
+		})
+	}
+}
@@ -8,6 +8,10 @@ const (
 	QrCode = "qrcode"
 	// NewPassword the layout used by new password UI layouts.
 	NewPassword = "newpassword"
+	// Browser is the layout used by the login in a web browser on the same machine, which opens the content URL.
+	Browser = "browser"
+	// WebAuthn is the layout used to sign with a security key the WebAuthn request in the content.
+	WebAuthn = "webauthn"
 )
 
 const (
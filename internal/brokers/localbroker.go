@@ -47,3 +47,48 @@ func (b localBroker) UserPreCheck(ctx context.Context, username string) (string,
 func (b localBroker) DeleteUser(ctx context.Context, username, providerID string) error {
 	return errors.New("DeleteUser should never be called on local broker")
 }
+
+//nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
+func (b localBroker) VerifyUser(ctx context.Context, username, providerID string) (string, string, error) {
+	return "", "", errors.New("VerifyUser should never be called on local broker")
+}
+
+//nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
+func (b localBroker) SessionToken(ctx context.Context, username string, scopes []string) (string, error) {
+	return "", errors.New("SessionToken should never be called on local broker")
+}
+
+//nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
+func (b localBroker) TokenStatus(ctx context.Context, username string) (string, error) {
+	return "", errors.New("TokenStatus should never be called on local broker")
+}
+
+//nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
+func (b localBroker) RefreshToken(ctx context.Context, username string) (string, error) {
+	return "", errors.New("RefreshToken should never be called on local broker")
+}
+
+//nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
+func (b localBroker) SetSessionHints(ctx context.Context, sessionID string, hints map[string]string) error {
+	return errors.New("SetSessionHints should never be called on local broker")
+}
+
+//nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
+func (b localBroker) CheckProvider(ctx context.Context) (string, error) {
+	return "", errors.New("CheckProvider should never be called on local broker")
+}
+
+//nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
+func (b localBroker) Capabilities() []string {
+	return nil
+}
+
+//nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
+func (b localBroker) Ping(ctx context.Context) error {
+	return errors.New("Ping should never be called on local broker")
+}
+
+//nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
+func (b localBroker) APIVersion() uint {
+	return 0
+}
@@ -29,6 +29,10 @@ type Manager struct {
 	sessionsToUsername     map[string]string
 	transactionsToBrokerMu sync.RWMutex
 
+	defaultBrokersPath string
+	defaults           defaultBrokers
+	defaultsMu         sync.RWMutex
+
 	cleanup func()
 }
 
@@ -71,6 +75,9 @@ func NewManager(ctx context.Context, brokersConfPath string, configuredBrokers [
 		}
 
 		for _, e := range entries {
+			if e.Name() == DefaultBrokersFileName {
+				continue
+			}
 			if !e.Type().IsRegular() {
 				log.Noticef(ctx, "Skipping non-regular file %q in brokers configuration directory", e.Name())
 				continue
@@ -107,6 +114,13 @@ func NewManager(ctx context.Context, brokersConfPath string, configuredBrokers [
 		brokers[b.ID] = &b
 	}
 
+	defaultBrokersPath := filepath.Join(brokersConfPath, DefaultBrokersFileName)
+	defaults, err := loadDefaultBrokers(defaultBrokersPath)
+	if err != nil {
+		// The users can still select their broker.
+		log.Warningf(ctx, "Ignoring default brokers: %v", err)
+	}
+
 	return &Manager{
 		brokers:      brokers,
 		brokersOrder: brokersOrder,
@@ -115,6 +129,9 @@ func NewManager(ctx context.Context, brokersConfPath string, configuredBrokers [
 		transactionsToBroker: make(map[string]*Broker),
 		sessionsToUsername:   make(map[string]string),
 
+		defaultBrokersPath: defaultBrokersPath,
+		defaults:           defaults,
+
 		cleanup: cleanup,
 	}, nil
 }
@@ -127,6 +144,14 @@ func (m *Manager) AvailableBrokers() (r []*Broker) {
 	return r
 }
 
+// SetLatencyObserver sets the observer notified of the duration of the calls to the brokers. It must be called before
+// the brokers are used.
+func (m *Manager) SetLatencyObserver(o LatencyObserver) {
+	for _, b := range m.brokers {
+		b.latencyObserver = o
+	}
+}
+
 // SetBroker memorizes which broker was used for which user.
 func (m *Manager) SetBroker(brokerID, username string) error {
 	broker, err := m.BrokerFromID(brokerID)
@@ -166,13 +191,13 @@ func (m *Manager) BrokerFromSessionID(id string) (broker *Broker, err error) {
 }
 
 // NewSession create a new session for the broker and store the sessionID on the manager.
-func (m *Manager) NewSession(brokerID, username, lang, mode, providerID string) (sessionID string, encryptionKey string, err error) {
+func (m *Manager) NewSession(brokerID, username, lang, timezone, mode, providerID string) (sessionID string, encryptionKey string, err error) {
 	broker, err := m.BrokerFromID(brokerID)
 	if err != nil {
 		return "", "", fmt.Errorf("invalid broker: %v", err)
 	}
 
-	sessionID, encryptionKey, err = broker.newSession(context.Background(), username, lang, mode, providerID)
+	sessionID, encryptionKey, err = broker.newSession(context.Background(), username, lang, timezone, mode, providerID)
 	if err != nil {
 		return "", "", err
 	}
@@ -214,6 +239,18 @@ func (m *Manager) UsernameFromSessionID(sessionID string) string {
 	return m.sessionsToUsername[sessionID]
 }
 
+// PendingSessions returns the number of sessions started with the broker which did not end yet.
+func (m *Manager) PendingSessions(brokerID string) (n int) {
+	m.transactionsToBrokerMu.RLock()
+	defer m.transactionsToBrokerMu.RUnlock()
+	for _, b := range m.transactionsToBroker {
+		if b.ID == brokerID {
+			n++
+		}
+	}
+	return n
+}
+
 // BrokerExists returns true if the brokerID is known by the manager.
 func (m *Manager) BrokerExists(brokerID string) bool {
 	_, exists := m.brokers[brokerID]
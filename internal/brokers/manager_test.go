@@ -8,9 +8,11 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/canonical/authd/internal/brokers"
 	"github.com/canonical/authd/internal/brokers/auth"
+	"github.com/canonical/authd/internal/fileutils"
 	"github.com/canonical/authd/internal/testutils"
 	"github.com/canonical/authd/internal/testutils/golden"
 	"github.com/canonical/authd/log"
@@ -103,6 +105,63 @@ func TestSetDefaultBrokerForUser(t *testing.T) {
 	}
 }
 
+func TestDefaultBroker(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	for _, f := range []string{"valid.conf", "valid_2.conf"} {
+		err := fileutils.CopyFile(filepath.Join(brokerConfFixtures, "valid_brokers", f), filepath.Join(brokersConfPath, f))
+		require.NoError(t, err, "Setup: could not copy broker configuration")
+	}
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, nil)
+	require.NoError(t, err, "Setup: could not create manager")
+	require.Nil(t, m.DefaultBroker("login"), "DefaultBroker should return nil when no default broker is set")
+
+	b1, b2 := m.BrokerFromName("broker"), m.BrokerFromName("Broker2")
+	require.NotNil(t, b1, "BrokerFromName should find the broker ignoring case")
+	require.NotNil(t, b2, "BrokerFromName should find the broker")
+	require.Nil(t, m.BrokerFromName("does not exist"), "BrokerFromName should return nil for an unknown broker")
+
+	err = m.SetDefaultBroker("", b1.ID)
+	require.NoError(t, err, "SetDefaultBroker should not return an error for all services")
+	err = m.SetDefaultBroker("sshd", b2.ID)
+	require.NoError(t, err, "SetDefaultBroker should not return an error for a service")
+	err = m.SetDefaultBroker("sshd", "does not exist")
+	require.Error(t, err, "SetDefaultBroker should return an error for an unknown broker")
+
+	require.Equal(t, b1.ID, m.DefaultBroker("login").ID, "DefaultBroker should return the broker set for all services")
+	require.Equal(t, b2.ID, m.DefaultBroker("sshd").ID, "DefaultBroker should return the broker set for the service")
+
+	all, services := m.DefaultFor(b1)
+	require.True(t, all, "DefaultFor should report the broker set for all services")
+	require.Empty(t, services, "DefaultFor should not report services for the broker set for all services")
+	all, services = m.DefaultFor(b2)
+	require.False(t, all, "DefaultFor should not report the broker set for a service as set for all services")
+	require.Equal(t, []string{"sshd"}, services, "DefaultFor should report the services of the broker")
+
+	// The default brokers are loaded again by the next daemon, and the file storing them is not taken for a broker.
+	m, err = brokers.NewManager(context.Background(), brokersConfPath, nil)
+	require.NoError(t, err, "Setup: could not create manager")
+	require.Len(t, m.AvailableBrokers(), 3, "The default brokers file should not be loaded as a broker")
+	require.Equal(t, b1.ID, m.DefaultBroker("login").ID, "DefaultBroker should return the stored broker for all services")
+	require.Equal(t, b2.ID, m.DefaultBroker("sshd").ID, "DefaultBroker should return the stored broker for the service")
+
+	// A default broker which is not available anymore is ignored.
+	err = os.Remove(filepath.Join(brokersConfPath, "valid_2.conf"))
+	require.NoError(t, err, "Setup: could not remove broker configuration")
+	m, err = brokers.NewManager(context.Background(), brokersConfPath, nil)
+	require.NoError(t, err, "Setup: could not create manager")
+	require.Nil(t, m.DefaultBroker("sshd"), "DefaultBroker should return nil when the broker is not available")
+
+	// An invalid file doesn't prevent the brokers from being loaded.
+	err = os.WriteFile(filepath.Join(brokersConfPath, brokers.DefaultBrokersFileName), []byte("services: [invalid"), 0600)
+	require.NoError(t, err, "Setup: could not write invalid default brokers file")
+	m, err = brokers.NewManager(context.Background(), brokersConfPath, nil)
+	require.NoError(t, err, "NewManager should not return an error when the default brokers file is invalid")
+	require.Nil(t, m.DefaultBroker("login"), "DefaultBroker should return nil when the default brokers file is invalid")
+}
+
 func TestBrokerForUser(t *testing.T) {
 	t.Parallel()
 
@@ -174,6 +233,7 @@ func TestNewSession(t *testing.T) {
 	tests := map[string]struct {
 		brokerID    string
 		username    string
+		timezone    string
 		sessionMode string
 
 		configuredBrokers []string
@@ -183,6 +243,8 @@ func TestNewSession(t *testing.T) {
 		"Successfully_start_a_new_auth_session":                    {username: "success"},
 		"Successfully_start_a_new_passwd_session":                  {username: "success", sessionMode: auth.SessionModeChangePassword},
 		"Successfully_start_a_new_session_with_the_correct_broker": {username: "success", configuredBrokers: []string{t.Name() + "_Broker1.conf", t.Name() + "_Broker2.conf"}},
+		"Successfully_start_a_new_session_with_a_timezone":         {username: "success", timezone: "Europe/Paris"},
+		"Successfully_start_a_new_session_if_hints_are_rejected":   {username: "success", timezone: "hints_error"},
 
 		"Error_when_broker_does_not_exist":         {brokerID: "does_not_exist", wantErr: true},
 		"Error_when_broker_does_not_provide_an_ID": {username: "ns_no_id", wantErr: true},
@@ -225,7 +287,7 @@ func TestNewSession(t *testing.T) {
 				tc.sessionMode = "auth"
 			}
 
-			gotID, gotEKey, err := m.NewSession(tc.brokerID, tc.username, "some_lang", tc.sessionMode, "")
+			gotID, gotEKey, err := m.NewSession(tc.brokerID, tc.username, "some_lang", tc.timezone, tc.sessionMode, "")
 			if tc.wantErr {
 				require.Error(t, err, "NewSession should return an error, but did not")
 				return
@@ -322,13 +384,13 @@ func TestStartAndEndSession(t *testing.T) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		id, key, err := m.NewSession(b1.ID, "user1@example.com", "some_lang", "auth", "")
+		id, key, err := m.NewSession(b1.ID, "user1@example.com", "some_lang", "", "auth", "")
 		firstID, firstKey, firstErr = &id, &key, &err
 	}()
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		id, key, err := m.NewSession(b2.ID, "user2", "some_lang", "auth", "")
+		id, key, err := m.NewSession(b2.ID, "user2", "some_lang", "", "auth", "")
 		secondID, secondKey, secondErr = &id, &key, &err
 	}()
 	wg.Wait()
@@ -351,6 +413,8 @@ func TestStartAndEndSession(t *testing.T) {
 	assignedBroker, err = m.BrokerFromSessionID(*secondID)
 	require.NoError(t, err, "Second NewSession should have assigned a broker for the session, but did not")
 	require.Equal(t, b2.Name, assignedBroker.Name, "Second NewSession should have assigned the expected broker for the session, but did not")
+	require.Equal(t, 1, m.PendingSessions(b1.ID), "First broker should have one pending session")
+	require.Equal(t, 1, m.PendingSessions(b2.ID), "Second broker should have one pending session")
 
 	/* Ending the sessions */
 	wg.Add(1)
@@ -373,6 +437,139 @@ func TestStartAndEndSession(t *testing.T) {
 
 	_, err = m.BrokerFromSessionID(*secondID)
 	require.Error(t, err, "Second EndSession should have removed the broker for the session, but did not")
+
+	require.Zero(t, m.PendingSessions(b1.ID), "First broker should not have any pending session")
+	require.Zero(t, m.PendingSessions(b2.ID), "Second broker should not have any pending session")
+}
+
+func TestSessionsDoNotWaitForEachOther(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b := newBrokerForTests(t, brokersConfPath, strings.ReplaceAll(t.Name(), "/", "_")+".conf")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"})
+	require.NoError(t, err, "Setup: could not create manager")
+
+	var broker *brokers.Broker
+	for _, ab := range m.AvailableBrokers() {
+		if ab.Name == b.Name {
+			broker = ab
+		}
+	}
+	require.NotNil(t, broker, "Setup: could not find the test broker in the manager")
+	calls := make(chan string, 4)
+	broker.NotifyCalls(calls)
+
+	// A session waiting for the user to authenticate with the broker, e.g. with a device code.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	waitingID := prefixID(t, "ia_wait")
+	broker.AddOngoingUserRequest(waitingID, "ia_wait")
+	authDone := make(chan struct{})
+	go func() {
+		defer close(authDone)
+		_, _, _ = broker.IsAuthenticated(ctx, waitingID, "password")
+	}()
+	require.Equal(t, "IsAuthenticated", <-calls, "Setup: the waiting authentication should reach the broker")
+
+	// A session whose end takes a while on the broker side.
+	endingID := prefixID(t, "es_timeout")
+	m.SetBrokerForSession(broker, endingID)
+	endDone := make(chan struct{})
+	go func() {
+		defer close(endDone)
+		_ = m.EndSession(endingID)
+	}()
+	require.Equal(t, "EndSession", <-calls, "Setup: the slow EndSession should reach the broker")
+
+	sessionID, _, err := m.NewSession(broker.ID, "success", "some_lang", "", "auth", "")
+	require.NoError(t, err, "NewSession should not return an error, but did")
+	access, _, err := broker.IsAuthenticated(context.Background(), sessionID, "password")
+	require.NoError(t, err, "IsAuthenticated should not return an error, but did")
+	require.Equal(t, auth.Granted, access, "IsAuthenticated should grant access, but did not")
+	require.NoError(t, m.EndSession(sessionID), "EndSession should not return an error, but did")
+
+	select {
+	case <-authDone:
+		require.Fail(t, "The waiting authentication should still be running")
+	case <-endDone:
+		require.Fail(t, "The slow EndSession should still be running: the other session waited for it")
+	default:
+	}
+
+	cancel()
+	<-authDone
+	<-endDone
+}
+
+func TestEndedSessionsCanNotBeUsed(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b := newBrokerForTests(t, brokersConfPath, strings.ReplaceAll(t.Name(), "/", "_")+".conf")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"})
+	require.NoError(t, err, "Setup: could not create manager")
+
+	var broker *brokers.Broker
+	for _, ab := range m.AvailableBrokers() {
+		if ab.Name == b.Name {
+			broker = ab
+		}
+	}
+	require.NotNil(t, broker, "Setup: could not find the test broker in the manager")
+
+	sessionID, _, err := m.NewSession(broker.ID, "success", "some_lang", "", "auth", "")
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+	require.NoError(t, m.EndSession(sessionID), "Setup: EndSession should not return an error, but did")
+
+	_, err = broker.GetAuthenticationModes(context.Background(), sessionID, []map[string]string{supportedLayouts["required-entry"]})
+	require.Error(t, err, "GetAuthenticationModes should return an error for an ended session, but did not")
+	_, _, err = broker.IsAuthenticated(context.Background(), sessionID, "password")
+	require.Error(t, err, "IsAuthenticated should return an error for an ended session, but did not")
+	require.Error(t, broker.EndSession(sessionID), "EndSession should return an error for an ended session, but did not")
+	require.Zero(t, broker.OngoingSessions(), "The state of the ended session should be freed")
+}
+
+func TestLatencyObserver(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b := newBrokerForTests(t, brokersConfPath, strings.ReplaceAll(t.Name(), "/", "_")+".conf")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"})
+	require.NoError(t, err, "Setup: could not create manager")
+
+	var broker *brokers.Broker
+	for _, ab := range m.AvailableBrokers() {
+		if ab.Name == b.Name {
+			broker = ab
+		}
+	}
+	require.NotNil(t, broker, "Setup: could not find the test broker in the manager")
+
+	observer := &latencyObserver{}
+	m.SetLatencyObserver(observer)
+
+	sessionID, _, err := m.NewSession(broker.ID, "success", "some_lang", "", "auth", "")
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+	require.NoError(t, m.EndSession(sessionID), "Setup: EndSession should not return an error, but did")
+
+	require.Equal(t, []string{broker.ID + " NewSession", broker.ID + " EndSession"}, observer.calls,
+		"The calls to the broker should be observed")
+}
+
+// latencyObserver records the calls to the brokers.
+type latencyObserver struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (o *latencyObserver) ObserveBrokerCall(brokerID, method string, _ time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls = append(o.calls, brokerID+" "+method)
 }
 
 func TestMain(m *testing.M) {
@@ -0,0 +1,289 @@
+package brokers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+	"gopkg.in/ini.v1"
+)
+
+const (
+	// userDefaultsSection is the section of the broker configuration file with the user defaults.
+	userDefaultsSection = "users"
+
+	// PrivatePrimaryGroup gives each new user a primary group named after them.
+	PrivatePrimaryGroup = "private"
+	// SharedPrimaryGroup gives all the new users the same primary group.
+	SharedPrimaryGroup = "shared"
+
+	// UserQuota limits the disk space used by the files of each user on the file system of their home.
+	UserQuota = "user"
+	// ProjectQuota limits the disk space used by the home of each user, whose project ID is the UID of the user.
+	ProjectQuota = "project"
+)
+
+// homeTemplateDirective matches the directives of home_template.
+var homeTemplateDirective = regexp.MustCompile(`%[uld%]`)
+
+// sizeRegex matches the sizes of the quotas, in bytes or with a K, M, G or T suffix.
+var sizeRegex = regexp.MustCompile(`^([0-9]+)([KMGT]?)$`)
+
+// UserDefaults are the attributes given to the users of a broker when their provider doesn't supply them.
+type UserDefaults struct {
+	// Shell is the shell of the users whose provider doesn't supply one.
+	Shell string
+	// SharedPrimaryGID is the GID of the primary group of the new users, or nil if each of them gets a private group.
+	SharedPrimaryGID *uint32
+	// Groups are the local groups which the new users are added to, instead of the default groups of authd.
+	Groups []string
+	// Home sets how the homes of the users are created.
+	Home HomeSettings
+}
+
+// HomeSettings set where the homes of the users of a broker are and how authd creates them, instead of pam_mkhomedir.
+type HomeSettings struct {
+	// Template is the path of the homes, in which %u is replaced by the user name, %l by the part of the user name
+	// before the "@" and %d by the part after it. If empty, the home given by the broker is used.
+	Template string
+	// Skel is the directory whose content is copied to the new homes.
+	Skel string
+	// Mode is the permissions of the new homes and of their subdirectories.
+	Mode fs.FileMode
+	// Subdirs are the directories created in the new homes, relative to them.
+	Subdirs []string
+	// GID is the GID of the group owning the new homes, or nil if they are owned by the primary group of the user.
+	GID *uint32
+	// Quota is the limit of the disk space of the users, in bytes, set when their home is created. 0 means no limit.
+	Quota uint64
+	// GroupQuotas are the limits of the disk space of the members of the groups, in bytes, which take precedence over
+	// Quota.
+	GroupQuotas map[string]uint64
+	// QuotaType is UserQuota or ProjectQuota.
+	QuotaType string
+}
+
+// defaultHomeMode is the permissions of the homes created by authd, like the HOME_MODE of login.defs on Ubuntu.
+const defaultHomeMode = 0750
+
+// CreatesHomes returns true if authd creates the homes of the users with these settings, instead of pam_mkhomedir.
+func (h HomeSettings) CreatesHomes() bool {
+	return h.Skel != "" || h.Mode != 0 || len(h.Subdirs) > 0 || h.GID != nil || h.Quota != 0 || len(h.GroupQuotas) > 0
+}
+
+// QuotaFor returns the limit of the disk space of a member of groups, in bytes, or 0 if they have no limit. The
+// largest limit of their groups is used, if any of them has one.
+func (h HomeSettings) QuotaFor(groups []string) uint64 {
+	var quota uint64
+	for _, g := range groups {
+		quota = max(quota, h.GroupQuotas[strings.ToLower(g)])
+	}
+	if quota != 0 {
+		return quota
+	}
+	return h.Quota
+}
+
+// Path returns the path of the home of the user from the template.
+func (h HomeSettings) Path(username string) string {
+	local, domain, _ := strings.Cut(username, "@")
+	replacer := strings.NewReplacer("%u", username, "%l", local, "%d", domain, "%%", "%")
+	return filepath.Clean(replacer.Replace(h.Template))
+}
+
+// loadUserDefaults reads the user defaults from the users section of the broker configuration file.
+func loadUserDefaults(configFile string) (d UserDefaults, err error) {
+	cfg, err := ini.Load(configFile)
+	if err != nil {
+		return d, fmt.Errorf("could not read ini configuration for broker %v", err)
+	}
+	section := cfg.Section(userDefaultsSection)
+
+	d.Shell = section.Key("default_shell").String()
+	if d.Shell != "" && !filepath.IsAbs(d.Shell) {
+		return UserDefaults{}, fmt.Errorf("default_shell is not an absolute path: %s", d.Shell)
+	}
+
+	for _, g := range section.Key("default_groups").Strings(",") {
+		if g == "" || strings.ContainsAny(g, ": ") {
+			return UserDefaults{}, fmt.Errorf("invalid group %q in default_groups", g)
+		}
+		d.Groups = append(d.Groups, g)
+	}
+
+	if d.Home, err = loadHomeSettings(section); err != nil {
+		return UserDefaults{}, err
+	}
+
+	switch policy := section.Key("primary_group").MustString(PrivatePrimaryGroup); policy {
+	case PrivatePrimaryGroup:
+		if section.HasKey("primary_group_gid") {
+			return UserDefaults{}, fmt.Errorf("primary_group_gid can only be set with primary_group = %s", SharedPrimaryGroup)
+		}
+	case SharedPrimaryGroup:
+		if !section.HasKey("primary_group_gid") {
+			return UserDefaults{}, fmt.Errorf("primary_group = %s requires primary_group_gid", SharedPrimaryGroup)
+		}
+		value := section.Key("primary_group_gid").String()
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || parsed == 0 {
+			return UserDefaults{}, fmt.Errorf("invalid primary_group_gid %q", value)
+		}
+		gid := uint32(parsed)
+		d.SharedPrimaryGID = &gid
+		if _, err := user.LookupGroupId(value); err != nil {
+			var unknownGroupErr user.UnknownGroupIdError
+			if !errors.As(err, &unknownGroupErr) {
+				return UserDefaults{}, fmt.Errorf("could not look up group with primary_group_gid %d: %v", gid, err)
+			}
+			log.Warningf(context.Background(), "No group with primary_group_gid %d exists on the system", gid)
+		}
+	default:
+		return UserDefaults{}, fmt.Errorf("invalid primary_group %q, must be %s or %s", policy, PrivatePrimaryGroup, SharedPrimaryGroup)
+	}
+
+	return d, nil
+}
+
+// loadHomeSettings reads the settings of the homes from the users section of the broker configuration file.
+func loadHomeSettings(section *ini.Section) (h HomeSettings, err error) {
+	h.Template = section.Key("home_template").String()
+	if h.Template != "" {
+		if !filepath.IsAbs(h.Template) {
+			return HomeSettings{}, fmt.Errorf("home_template is not an absolute path: %s", h.Template)
+		}
+		// Each user must get their own home.
+		if !strings.Contains(h.Template, "%u") && !strings.Contains(h.Template, "%l") {
+			return HomeSettings{}, fmt.Errorf("home_template must contain %%u or %%l: %s", h.Template)
+		}
+		if unknown := homeTemplateDirective.ReplaceAllString(h.Template, ""); strings.Contains(unknown, "%") {
+			return HomeSettings{}, fmt.Errorf("home_template contains an unknown directive, only %%u, %%l, %%d and %%%% are supported: %s", h.Template)
+		}
+	}
+
+	h.Skel = section.Key("home_skel").String()
+	if h.Skel != "" && !filepath.IsAbs(h.Skel) {
+		return HomeSettings{}, fmt.Errorf("home_skel is not an absolute path: %s", h.Skel)
+	}
+
+	if section.HasKey("home_mode") {
+		value := section.Key("home_mode").String()
+		mode, err := strconv.ParseUint(value, 8, 32)
+		if err != nil || mode == 0 || mode > 0777 {
+			return HomeSettings{}, fmt.Errorf("invalid home_mode %q, must be octal permissions such as 0750", value)
+		}
+		h.Mode = fs.FileMode(mode)
+	}
+
+	for _, dir := range section.Key("home_subdirs").Strings(",") {
+		if dir == "" || filepath.IsAbs(dir) || !filepath.IsLocal(dir) {
+			return HomeSettings{}, fmt.Errorf("invalid directory %q in home_subdirs, must be relative to the home", dir)
+		}
+		h.Subdirs = append(h.Subdirs, filepath.Clean(dir))
+	}
+
+	if name := section.Key("home_group").String(); name != "" {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return HomeSettings{}, fmt.Errorf("could not look up home_group %q: %v", name, err)
+		}
+		gid, err := strconv.ParseUint(g.Gid, 10, 32)
+		if err != nil {
+			return HomeSettings{}, fmt.Errorf("invalid GID %q of home_group %q", g.Gid, name)
+		}
+		homeGID := uint32(gid)
+		h.GID = &homeGID
+	}
+
+	if h, err = loadQuotaSettings(section, h); err != nil {
+		return HomeSettings{}, err
+	}
+
+	if h.CreatesHomes() && h.Mode == 0 {
+		h.Mode = defaultHomeMode
+	}
+	return h, nil
+}
+
+// loadQuotaSettings reads the quotas of the homes from the users section of the broker configuration file.
+func loadQuotaSettings(section *ini.Section, h HomeSettings) (HomeSettings, error) {
+	if value := section.Key("home_quota").String(); value != "" {
+		quota, err := parseSize(value)
+		if err != nil {
+			return HomeSettings{}, fmt.Errorf("invalid home_quota: %v", err)
+		}
+		h.Quota = quota
+	}
+
+	for _, entry := range section.Key("home_quota_groups").Strings(",") {
+		group, value, found := strings.Cut(entry, ":")
+		group = strings.TrimSpace(group)
+		if !found || group == "" {
+			return HomeSettings{}, fmt.Errorf("invalid entry %q in home_quota_groups, must be group:size", entry)
+		}
+		quota, err := parseSize(strings.TrimSpace(value))
+		if err != nil {
+			return HomeSettings{}, fmt.Errorf("invalid size of group %q in home_quota_groups: %v", group, err)
+		}
+		if h.GroupQuotas == nil {
+			h.GroupQuotas = make(map[string]uint64)
+		}
+		// The names of the groups of the users are lowercased.
+		h.GroupQuotas[strings.ToLower(group)] = quota
+	}
+
+	if h.Quota == 0 && len(h.GroupQuotas) == 0 {
+		if section.HasKey("home_quota_type") {
+			return HomeSettings{}, errors.New("home_quota_type requires home_quota or home_quota_groups")
+		}
+		return h, nil
+	}
+	h.QuotaType = section.Key("home_quota_type").MustString(UserQuota)
+	if h.QuotaType != UserQuota && h.QuotaType != ProjectQuota {
+		return HomeSettings{}, fmt.Errorf("invalid home_quota_type %q, must be %s or %s", h.QuotaType, UserQuota, ProjectQuota)
+	}
+	return h, nil
+}
+
+// parseSize parses a size in bytes, or in KiB, MiB, GiB or TiB with a K, M, G or T suffix. The quotas are set in KiB,
+// so the size must be at least 1K.
+func parseSize(value string) (uint64, error) {
+	m := sizeRegex.FindStringSubmatch(value)
+	if m == nil {
+		return 0, fmt.Errorf("%q is not a size such as 500M or 10G", value)
+	}
+	size, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a size such as 500M or 10G: %v", value, err)
+	}
+	// The position of the suffix is the power of 1024 of its unit, no suffix being at the start.
+	shift := 10 * strings.Index(" KMGT", m[2])
+	if size > (1<<63)>>shift {
+		return 0, fmt.Errorf("size %q is too large", value)
+	}
+	size <<= shift
+	if size < 1024 {
+		return 0, fmt.Errorf("size %q is smaller than 1K", value)
+	}
+	return size, nil
+}
+
+// Apply fills in the attributes the provider didn't supply, and sets the home of the user from the template of the
+// broker, if any.
+func (d UserDefaults) Apply(u types.UserInfo) types.UserInfo {
+	if u.Shell == "" {
+		u.Shell = d.Shell
+	}
+	if d.Home.Template != "" {
+		u.Dir = d.Home.Path(u.Name)
+	}
+	return u
+}
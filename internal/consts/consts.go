@@ -15,6 +15,9 @@ const (
 	// DefaultSocketPath is the default socket path.
 	DefaultSocketPath = "/run/authd.sock"
 
+	// DefaultUserDBSocketPath is the default socket path of the systemd user database service.
+	DefaultUserDBSocketPath = "/run/systemd/userdb/" + ServiceName
+
 	// DefaultBrokersConfPath is the default configuration directory for the brokers.
 	DefaultBrokersConfPath = "/etc/authd/brokers.d/"
 
@@ -0,0 +1,203 @@
+// Package credentialhooks runs the hooks acquiring credentials for the users when their session starts, for example a
+// Kerberos ticket obtained with kinit, and returns the environment variables pointing the session to them.
+package credentialhooks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+// hookTimeout is the maximum duration of a hook. The PAM session is only opened once all the hooks returned.
+var hookTimeout = 30 * time.Second
+
+// envNameRegex matches the names of the environment variables which the hooks can export in the sessions.
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Hook is a program run as the user when a session of a user matching Groups starts.
+type Hook struct {
+	// Name identifies the hook in the logs. If empty, Exec is used.
+	Name string `mapstructure:"name" yaml:"name,omitempty"`
+	// Groups are the groups whose members trigger the hook. If empty, the hook is run for all authd users.
+	Groups []string `mapstructure:"groups" yaml:"groups,omitempty"`
+	// Exec is the path of the program to run. It is run as the user, with the session as JSON on its standard input
+	// and its fields in AUTHD_SESSION_* environment variables. Each NAME=VALUE line of its standard output is exported
+	// in the session.
+	Exec string `mapstructure:"exec" yaml:"exec"`
+	// TokenScopes are the scopes of the access token of the user which the broker hands to the hook, along with the
+	// session. If empty, no token is requested.
+	TokenScopes []string `mapstructure:"token_scopes" yaml:"token_scopes,omitempty"`
+}
+
+// Config holds the hooks acquiring credentials for the sessions.
+type Config struct {
+	Hooks []Hook `mapstructure:"credential_hooks" yaml:"credential_hooks,omitempty"`
+}
+
+// Validate returns an error if the configuration is invalid.
+func (c Config) Validate() error {
+	for _, h := range c.Hooks {
+		if h.Exec == "" {
+			return fmt.Errorf("credential hook %q has no exec", h.Name)
+		}
+	}
+	return nil
+}
+
+// Session describes the session which the credentials are acquired for.
+type Session struct {
+	Username string   `json:"username"`
+	UID      uint32   `json:"uid"`
+	GID      uint32   `json:"gid"`
+	Home     string   `json:"home"`
+	Groups   []string `json:"groups"`
+	BrokerID string   `json:"broker_id"`
+	Service  string   `json:"service,omitempty"`
+}
+
+// Token is an access token of the user handed to the hooks.
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+// TokenFunc returns an access token of the user for the given scopes.
+type TokenFunc func(ctx context.Context, scopes []string) (Token, error)
+
+// input is what the hooks receive on their standard input.
+type input struct {
+	Session
+	Token *Token `json:"token,omitempty"`
+}
+
+// Runner runs the hooks acquiring credentials.
+type Runner struct {
+	cfg Config
+}
+
+// New returns a new Runner running the hooks of cfg.
+func New(cfg Config) *Runner {
+	return &Runner{cfg: cfg}
+}
+
+// Run runs the hooks matching the groups of the user, one after the other, and returns the environment variables
+// they export, the ones of the later hooks taking precedence. Failing hooks are only logged, as the user must still be
+// able to use their session without the credentials.
+func (r *Runner) Run(ctx context.Context, s Session, token TokenFunc) map[string]string {
+	env := make(map[string]string)
+	for _, h := range r.cfg.Hooks {
+		if !h.matches(s.Groups) {
+			continue
+		}
+
+		hookEnv, err := h.run(ctx, s, token)
+		if err != nil {
+			log.Warningf(ctx, "Credential hook %q failed for user %q: %v", h.name(), s.Username, err)
+			continue
+		}
+		log.Noticef(ctx, "Credential hook %q succeeded for user %q", h.name(), s.Username)
+		for name, value := range hookEnv {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// matches returns true if the hook must be run for a member of groups.
+func (h Hook) matches(groups []string) bool {
+	if len(h.Groups) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(h.Groups, func(g string) bool { return slices.Contains(groups, g) })
+}
+
+func (h Hook) name() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	return h.Exec
+}
+
+func (h Hook) run(ctx context.Context, s Session, token TokenFunc) (map[string]string, error) {
+	in := input{Session: s}
+	if len(h.TokenScopes) > 0 {
+		t, err := token(ctx, h.TokenScopes)
+		if err != nil {
+			return nil, fmt.Errorf("could not get a token for scopes %v: %w", h.TokenScopes, err)
+		}
+		in.Token = &t
+	}
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), hookTimeout)
+	defer cancel()
+
+	// #nosec:G204 - the hook path is set by the administrator in the configuration.
+	cmd := exec.CommandContext(ctx, h.Exec)
+	cmd.Dir = "/"
+	cmd.Env = []string{
+		"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		"HOME=" + s.Home,
+		"USER=" + s.Username,
+		"LOGNAME=" + s.Username,
+		"AUTHD_SESSION_USER=" + s.Username,
+		"AUTHD_SESSION_UID=" + strconv.FormatUint(uint64(s.UID), 10),
+		"AUTHD_SESSION_GID=" + strconv.FormatUint(uint64(s.GID), 10),
+		"AUTHD_SESSION_GROUPS=" + strings.Join(s.Groups, ","),
+		"AUTHD_SESSION_BROKER=" + s.BrokerID,
+		"AUTHD_SESSION_SERVICE=" + s.Service,
+	}
+	// The credentials must belong to the user, so the hook doesn't run with the privileges of authd.
+	if int(s.UID) != os.Getuid() {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: s.UID, Gid: s.GID, Groups: []uint32{}}}
+	}
+	cmd.Stdin = bytes.NewReader(payload)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseEnv(stdout.Bytes())
+}
+
+// parseEnv returns the environment variables of the NAME=VALUE lines of the output of a hook. Empty lines are ignored.
+func parseEnv(out []byte) (map[string]string, error) {
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid output line %q, expected NAME=VALUE", line)
+		}
+		if !envNameRegex.MatchString(name) || strings.HasPrefix(name, "LD_") {
+			return nil, fmt.Errorf("invalid environment variable name %q", name)
+		}
+		env[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read the output of the hook: %w", err)
+	}
+	return env, nil
+}
@@ -0,0 +1,114 @@
+package credentialhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/credentialhooks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, credentialhooks.Config{}.Validate(), "Validate should accept an empty configuration")
+	require.NoError(t, credentialhooks.Config{Hooks: []credentialhooks.Hook{{Exec: "/usr/bin/true"}}}.Validate(),
+		"Validate should accept a hook with an exec")
+	require.Error(t, credentialhooks.Config{Hooks: []credentialhooks.Hook{{Name: "kerberos"}}}.Validate(),
+		"Validate should refuse a hook without exec")
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	token := credentialhooks.Token{
+		AccessToken: "access-token",
+		TokenType:   "Bearer",
+		Expiry:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	tests := map[string]struct {
+		hookGroups  []string
+		userGroups  []string
+		tokenScopes []string
+		tokenErr    error
+		output      string
+		failing     bool
+
+		wantRun   bool
+		wantToken bool
+		wantEnv   map[string]string
+	}{
+		"Run_hook_without_groups_for_all_users": {output: "KRB5CCNAME=KCM:\n", wantRun: true, wantEnv: map[string]string{"KRB5CCNAME": "KCM:"}},
+		"Run_hook_for_members_of_its_groups":    {hookGroups: []string{"admins", "kerberos"}, userGroups: []string{"kerberos"}, wantRun: true, wantEnv: map[string]string{}},
+		"Pass_token_to_hook":                    {tokenScopes: []string{"api://kerberos/.default"}, wantRun: true, wantToken: true, wantEnv: map[string]string{}},
+		"Ignore_empty_output_lines":             {output: "\nA=1\n\nB=two=2\n", wantRun: true, wantEnv: map[string]string{"A": "1", "B": "two=2"}},
+		"Failing_hook_does_not_stop_other_hooks": {
+			failing: true, output: "A=1\n", wantRun: true, wantEnv: map[string]string{"A": "1"},
+		},
+
+		"Do_not_run_hook_for_other_users":         {hookGroups: []string{"kerberos"}, userGroups: []string{"employees"}, wantEnv: map[string]string{}},
+		"Do_not_run_hook_if_token_can_not_be_got": {tokenScopes: []string{"api://kerberos/.default"}, tokenErr: errors.New("offline"), wantEnv: map[string]string{}},
+		"Ignore_env_of_hook_with_invalid_output":  {output: "A=1\nnot an assignment\n", wantRun: true, wantEnv: map[string]string{}},
+		"Ignore_env_of_hook_with_invalid_name":    {output: "LD_PRELOAD=/tmp/lib.so\n", wantRun: true, wantEnv: map[string]string{}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			inputPath := filepath.Join(dir, "input.json")
+			outputPath := filepath.Join(dir, "output")
+			require.NoError(t, os.WriteFile(outputPath, []byte(tc.output), 0600), "Setup: could not write hook output")
+			hookPath := filepath.Join(dir, "hook")
+			script := fmt.Sprintf("#!/bin/sh\ncat > %q\ncat %q\n", inputPath, outputPath)
+			require.NoError(t, os.WriteFile(hookPath, []byte(script), 0700), "Setup: could not write hook")
+
+			hooks := []credentialhooks.Hook{{Name: "test", Groups: tc.hookGroups, Exec: hookPath, TokenScopes: tc.tokenScopes}}
+			if tc.failing {
+				hooks = append([]credentialhooks.Hook{{Exec: "/bin/false"}}, hooks...)
+			}
+
+			s := credentialhooks.Session{
+				Username: "user1@example.com",
+				UID:      uint32(os.Getuid()),
+				GID:      uint32(os.Getgid()),
+				Home:     dir,
+				Groups:   tc.userGroups,
+				BrokerID: "broker-id",
+				Service:  "sshd",
+			}
+			tokenFunc := func(_ context.Context, scopes []string) (credentialhooks.Token, error) {
+				require.Equal(t, tc.tokenScopes, scopes, "The token should be requested for the scopes of the hook")
+				return token, tc.tokenErr
+			}
+			env := credentialhooks.New(credentialhooks.Config{Hooks: hooks}).Run(context.Background(), s, tokenFunc)
+			require.Equal(t, tc.wantEnv, env, "Run should return the environment exported by the hooks")
+
+			if !tc.wantRun {
+				require.NoFileExists(t, inputPath, "Hook should not have been run")
+				return
+			}
+			data, err := os.ReadFile(inputPath)
+			require.NoError(t, err, "Hook should have been run")
+
+			var got struct {
+				credentialhooks.Session
+				Token *credentialhooks.Token `json:"token"`
+			}
+			require.NoError(t, json.Unmarshal(data, &got), "Hook should receive the session as JSON")
+			require.Equal(t, s, got.Session, "Hook should receive the session")
+			if !tc.wantToken {
+				require.Nil(t, got.Token, "Hook should not receive a token")
+				return
+			}
+			require.Equal(t, &token, got.Token, "Hook should receive the token")
+		})
+	}
+}
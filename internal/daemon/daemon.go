@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"sync"
 
 	"github.com/canonical/authd/internal/decorate"
 	"github.com/canonical/authd/log"
@@ -17,13 +18,24 @@ import (
 // Daemon is a grpc daemon with systemd support.
 type Daemon struct {
 	grpcServer *grpc.Server
-	lis        net.Listener
+	lis        *trackingListener
+	// cancelServices cancels the context of the services, which ends the streams they serve.
+	cancelServices context.CancelFunc
+
+	// handoverReady is the pipe on which we signal the previous daemon that we're ready, if it handed its socket
+	// over to us.
+	handoverReady *os.File
+	handoverArgs  []string
+	handoverMu    sync.Mutex
+	// handedOver is closed once the socket was handed over to a new daemon.
+	handedOver chan struct{}
 
 	systemdSdNotifier systemdSdNotifier
 }
 
 type options struct {
-	socketPath string
+	socketPath   string
+	handoverArgs []string
 
 	// private member that we export for tests.
 	systemdActivationListener func() ([]net.Listener, error)
@@ -43,10 +55,13 @@ func WithSocketPath(p string) func(o *options) {
 }
 
 // GRPCServiceRegisterer is a function that the daemon will call everytime we want to build a new GRPC object.
+// The context is cancelled when the daemon quits or hands its socket over, so that the streams which only end with
+// the daemon, like the ones of the events, don't keep it from stopping.
 type GRPCServiceRegisterer func(context.Context) *grpc.Server
 
 // New returns an new, initialized daemon server, which handles systemd activation.
 // If systemd activation is used, it will override any socket passed here.
+// If the previous daemon handed its socket over to us, that socket is used instead.
 func New(ctx context.Context, registerGRPCService GRPCServiceRegisterer, args ...Option) (d *Daemon, err error) {
 	defer decorate.OnError(&err /*i18n.G(*/, "can't create daemon") //)
 
@@ -54,7 +69,8 @@ func New(ctx context.Context, registerGRPCService GRPCServiceRegisterer, args ..
 
 	// Set default options.
 	opts := options{
-		socketPath: "",
+		socketPath:   "",
+		handoverArgs: os.Args[1:],
 
 		systemdActivationListener: activation.Listeners,
 		systemdSdNotifier:         daemon.SdNotify,
@@ -64,10 +80,18 @@ func New(ctx context.Context, registerGRPCService GRPCServiceRegisterer, args ..
 		f(&opts)
 	}
 
-	// systemd socket activation or local creation
+	// handed over socket, systemd socket activation or local creation
 	var lis net.Listener
+	var handoverReady *os.File
 
-	if opts.socketPath != "" {
+	if fd, ok := os.LookupEnv(handoverListenFDEnv); ok {
+		log.Debug(ctx, "Use socket handed over by the previous daemon")
+
+		lis, handoverReady, err = handedOverListener(fd, os.Getenv(handoverReadyFDEnv))
+		if err != nil {
+			return nil, err
+		}
+	} else if opts.socketPath != "" {
 		log.Debugf(ctx, "Listening on %s", opts.socketPath)
 
 		// manual socket
@@ -101,9 +125,16 @@ func New(ctx context.Context, registerGRPCService GRPCServiceRegisterer, args ..
 		return nil, fmt.Errorf("%s can’t be accessed: %v", lis.Addr().String(), err)
 	}
 
+	servicesCtx, cancelServices := context.WithCancel(ctx)
+
 	return &Daemon{
-		grpcServer: registerGRPCService(ctx),
-		lis:        lis,
+		grpcServer:     registerGRPCService(servicesCtx),
+		lis:            &trackingListener{Listener: lis},
+		cancelServices: cancelServices,
+
+		handoverReady: handoverReady,
+		handoverArgs:  opts.handoverArgs,
+		handedOver:    make(chan struct{}),
 
 		systemdSdNotifier: opts.systemdSdNotifier,
 	}, nil
@@ -115,15 +146,33 @@ func (d *Daemon) Serve(ctx context.Context) (err error) {
 
 	log.Debugf(ctx, "Starting to serve requests on %s", d.lis.Addr())
 
-	// Signal to systemd that we are ready.
-	if sent, err := d.systemdSdNotifier(false, "READY=1"); err != nil {
+	if d.handoverReady != nil {
+		// Signal to the previous daemon that we are ready. It then tells systemd that we are the main process.
+		_, err := d.handoverReady.Write([]byte{1})
+		_ = d.handoverReady.Close()
+		if err != nil {
+			return fmt.Errorf( /*i18n.G(*/ "couldn't send ready notification to the previous daemon: %v" /*)*/, err)
+		}
+		log.Debug(context.Background(), "Ready state sent to the previous daemon")
+	} else if sent, err := d.systemdSdNotifier(false, "READY=1"); err != nil {
+		// Signal to systemd that we are ready.
 		return fmt.Errorf( /*i18n.G(*/ "couldn't send ready notification to systemd: %v" /*)*/, err)
 	} else if sent {
 		log.Debug(context.Background(), "Ready state sent to systemd")
 	}
 
 	log.Infof(ctx, "Serving gRPC requests on %v", d.lis.Addr())
-	if err := d.grpcServer.Serve(d.lis); err != nil {
+	err = d.grpcServer.Serve(d.lis)
+
+	select {
+	case <-d.handedOver:
+		// The listener was closed by Handover, keep serving the open connections.
+		d.drain(ctx)
+		return nil
+	default:
+	}
+
+	if err != nil {
 		return fmt.Errorf("gRPC error: %v", err)
 	}
 	return nil
@@ -131,7 +180,8 @@ func (d *Daemon) Serve(ctx context.Context) (err error) {
 
 // Quit gracefully quits listening loop and stops the grpc server.
 // It can drops any existing connexion is force is true.
-func (d Daemon) Quit(ctx context.Context, force bool) {
+func (d *Daemon) Quit(ctx context.Context, force bool) {
+	d.cancelServices()
 	if force {
 		d.grpcServer.Stop()
 		return
@@ -3,10 +3,15 @@ package daemon_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io/fs"
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -306,6 +311,153 @@ func TestQuit(t *testing.T) {
 	}
 }
 
+func TestHandover(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		newDaemonFails bool
+
+		wantErr bool
+	}{
+		"Socket_is_handed_over_to_new_daemon": {},
+
+		"Error_and_keep_serving_when_new_daemon_fails": {newDaemonFails: true, wantErr: true},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			registerGRPC := func(context.Context) *grpc.Server {
+				grpcServer := grpc.NewServer(grpc.UnaryInterceptor(errmessages.RedactErrorInterceptor))
+				var service testGRPCService
+				grpctestservice.RegisterTestServiceServer(grpcServer, service)
+				hc := health.NewServer()
+				hc.SetServingStatus(consts.ServiceName, healthgrpc.HealthCheckResponse_SERVING)
+				healthgrpc.RegisterHealthServer(grpcServer, hc)
+				return grpcServer
+			}
+			notifications := make(chan string, 10)
+			systemdNotifier := func(unsetEnvironment bool, state string) (bool, error) {
+				notifications <- state
+				return true, nil
+			}
+
+			var handoverArgs []string
+			if tc.newDaemonFails {
+				handoverArgs = append(handoverArgs, "fail")
+			}
+
+			socketPath := filepath.Join(t.TempDir(), "manual.socket")
+			d, err := daemon.New(context.Background(), registerGRPC,
+				daemon.WithSystemdSdNotifier(systemdNotifier),
+				daemon.WithSocketPath(socketPath),
+				daemon.WithHandoverArgs(handoverArgs...))
+			require.NoError(t, err, "Setup: New() should not return an error")
+
+			served := make(chan error, 1)
+			go func() { served <- d.Serve(context.Background()) }()
+			require.Equal(t, "READY=1", <-notifications, "Setup: daemon should notify systemd that it's ready")
+
+			// This connection has a request in progress during the handover.
+			conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			require.NoError(t, err, "Setup: could not connect to grpc server")
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			require.NoError(t, grpcutils.WaitForConnection(ctx, conn, 5*time.Second), "Setup: connection should be allowed")
+			go func() { _, _ = grpctestservice.NewTestServiceClient(conn).Blocking(ctx, &grpctestservice.Empty{}) }()
+			disconnectClient := func() {
+				cancel()
+				_ = conn.Close()
+			}
+
+			err = d.Handover(context.Background())
+			if tc.wantErr {
+				require.Error(t, err, "Handover() should return an error")
+				_, err := checkHealth(t, socketPath, handedOverService)
+				require.Error(t, err, "New connections should still be served by the daemon")
+				_, err = checkHealth(t, socketPath, "")
+				require.NoError(t, err, "New connections should still be served by the daemon")
+
+				d.Quit(context.Background(), true)
+				require.NoError(t, <-served, "Serve() should not return an error")
+				return
+			}
+			require.NoError(t, err, "Handover() should not return an error")
+
+			mainPID, ok := strings.CutPrefix(<-notifications, "MAINPID=")
+			require.True(t, ok, "Daemon should notify systemd of the new main process")
+			pid, err := strconv.Atoi(mainPID)
+			require.NoError(t, err, "Main process notification should contain a PID")
+			t.Cleanup(func() { _ = syscall.Kill(pid, syscall.SIGKILL) })
+
+			status, err := checkHealth(t, socketPath, handedOverService)
+			require.NoError(t, err, "New connections should be served by the new daemon")
+			require.Equal(t, healthgrpc.HealthCheckResponse_SERVING, status, "New daemon should be serving")
+
+			select {
+			case <-served:
+				t.Fatal("Serve() should not return while a connection is still open")
+			case <-time.After(testutils.MultipliedSleepDuration(100 * time.Millisecond)):
+			}
+
+			disconnectClient()
+			select {
+			case err := <-served:
+				require.NoError(t, err, "Serve() should not return an error after a handover")
+			case <-time.After(testutils.MultipliedSleepDuration(5 * time.Second)):
+				t.Fatal("Serve() should return once the open connections are closed")
+			}
+			require.FileExists(t, socketPath, "Socket served by the new daemon should not be removed")
+
+			_, err = checkHealth(t, socketPath, handedOverService)
+			require.NoError(t, err, "New daemon should still be serving")
+		})
+	}
+}
+
+// handedOverService is the health service which is only served by the daemon started by Handover.
+const handedOverService = "handed-over"
+
+// runHandedOverDaemon runs the test binary as the daemon started by Handover.
+func runHandedOverDaemon() error {
+	if slices.Contains(os.Args[1:], "fail") {
+		return errors.New("requested failure")
+	}
+
+	registerGRPC := func(context.Context) *grpc.Server {
+		grpcServer := grpc.NewServer(grpc.UnaryInterceptor(errmessages.RedactErrorInterceptor))
+		hc := health.NewServer()
+		hc.SetServingStatus(consts.ServiceName, healthgrpc.HealthCheckResponse_SERVING)
+		hc.SetServingStatus(handedOverService, healthgrpc.HealthCheckResponse_SERVING)
+		healthgrpc.RegisterHealthServer(grpcServer, hc)
+		return grpcServer
+	}
+	d, err := daemon.New(context.Background(), registerGRPC, daemon.WithSystemdActivationListener(
+		func() ([]net.Listener, error) {
+			return nil, errors.New("the handed over socket should be used")
+		}))
+	if err != nil {
+		return err
+	}
+	return d.Serve(context.Background())
+}
+
+func checkHealth(t *testing.T, socketPath, service string) (healthgrpc.HealthCheckResponse_ServingStatus, error) {
+	t.Helper()
+
+	conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err, "Could not connect to grpc server")
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := healthgrpc.NewHealthClient(conn).Check(ctx, &healthgrpc.HealthCheckRequest{Service: service})
+	if err != nil {
+		return healthgrpc.HealthCheckResponse_UNKNOWN, err
+	}
+	return resp.GetStatus(), nil
+}
+
 func createClientConnection(t *testing.T, socketPath string) (success bool, disconnect func()) {
 	t.Helper()
 
@@ -337,3 +489,16 @@ func (testGRPCService) Blocking(ctx context.Context, e *grpctestservice.Empty) (
 	<-ctx.Done()
 	return &grpctestservice.Empty{}, nil
 }
+
+func TestMain(m *testing.M) {
+	// Handover runs the test binary as the new daemon.
+	if os.Getenv(daemon.HandoverListenFDEnv) != "" {
+		if err := runHandedOverDaemon(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	m.Run()
+}
@@ -14,6 +14,16 @@ func WithSystemdSdNotifier(f func(unsetEnvironment bool, state string) (bool, er
 	}
 }
 
-func (d Daemon) SelectedSocketAddr() string {
+func WithHandoverArgs(args ...string) func(o *options) {
+	return func(o *options) {
+		o.handoverArgs = args
+	}
+}
+
+func (d *Daemon) SelectedSocketAddr() string {
 	return d.lis.Addr().String()
 }
+
+const (
+	HandoverListenFDEnv = handoverListenFDEnv
+)
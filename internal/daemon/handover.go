@@ -0,0 +1,253 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/canonical/authd/internal/decorate"
+	"github.com/canonical/authd/log"
+)
+
+const (
+	// handoverListenFDEnv is the environment variable with the file descriptor of the listening socket handed over
+	// to the new daemon.
+	handoverListenFDEnv = "AUTHD_HANDOVER_LISTEN_FD"
+	// handoverReadyFDEnv is the environment variable with the file descriptor of the pipe on which the new daemon
+	// signals that it's ready to serve.
+	handoverReadyFDEnv = "AUTHD_HANDOVER_READY_FD"
+)
+
+var (
+	// handoverReadyTimeout is how long we wait for the new daemon to be ready before giving up the handover.
+	handoverReadyTimeout = time.Minute
+	// handoverDrainTimeout is how long the connections which were open when the socket was handed over are still
+	// served, so that the authentications in progress can complete.
+	handoverDrainTimeout = 5 * time.Minute
+)
+
+// Handover starts a new daemon from the current executable and hands the listening socket over to it. This replaces
+// the daemon, e.g. after a package upgrade, without any window during which the socket isn't served.
+//
+// Once the new daemon is ready, we stop accepting connections and systemd is told to track the new daemon as the
+// main process of the service. The connections which are still open keep being served until the clients close
+// them, as the new daemon doesn't know about their broker sessions, then Serve returns.
+// If the new daemon fails to start, we keep serving the socket.
+func (d *Daemon) Handover(ctx context.Context) (err error) {
+	defer decorate.OnError(&err /*i18n.G(*/, "can't hand the socket over to a new daemon") //)
+
+	d.handoverMu.Lock()
+	defer d.handoverMu.Unlock()
+
+	select {
+	case <-d.handedOver:
+		return errors.New("the socket was already handed over")
+	default:
+	}
+
+	ul, ok := d.lis.Listener.(*net.UnixListener)
+	if !ok {
+		return fmt.Errorf("unsupported listener type %T", d.lis.Listener)
+	}
+	lisFile, err := ul.File()
+	if err != nil {
+		return fmt.Errorf("could not get the listening socket: %w", err)
+	}
+	defer lisFile.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not get the daemon executable: %w", err)
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("could not create pipe: %w", err)
+	}
+	defer readyR.Close()
+
+	//nolint:gosec // G204 We run our own executable, with our own arguments.
+	cmd := exec.Command(exe, d.handoverArgs...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// The file descriptors of ExtraFiles start at 3 in the new process.
+	cmd.Env = append(os.Environ(), handoverListenFDEnv+"=3", handoverReadyFDEnv+"=4")
+	cmd.ExtraFiles = []*os.File{lisFile, readyW}
+	err = cmd.Start()
+	// Only the new daemon must have the write end open, so that we read EOF if it exits before being ready.
+	_ = readyW.Close()
+	// Starting the process puts the socket, which is shared with our listener, in blocking mode. Our listener
+	// couldn't be closed while it waits for a connection then.
+	if nonblockErr := setNonblock(ul); nonblockErr != nil {
+		log.Warningf(ctx, "Could not put the listening socket back in non-blocking mode: %v", nonblockErr)
+	}
+	if err != nil {
+		return fmt.Errorf("could not start the new daemon: %w", err)
+	}
+
+	log.Infof(ctx, "Started new daemon (PID %d), waiting for it to be ready", cmd.Process.Pid)
+	if err := waitForHandoverReady(readyR); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+	// Reap the new daemon if it exits before us.
+	go func() { _ = cmd.Wait() }()
+
+	if _, err := d.systemdSdNotifier(false, fmt.Sprintf("MAINPID=%d", cmd.Process.Pid)); err != nil {
+		log.Warningf(ctx, "Could not notify systemd that the new daemon is the main process: %v", err)
+	}
+
+	log.Infof(ctx, "New daemon (PID %d) is ready, not accepting connections anymore", cmd.Process.Pid)
+	close(d.handedOver)
+	// The clients of the streams of the services reconnect to the new daemon.
+	d.cancelServices()
+	// The socket is served by the new daemon now, so it must not be removed when we close our listener.
+	ul.SetUnlinkOnClose(false)
+	if err := d.lis.Close(); err != nil {
+		log.Warningf(ctx, "Could not close the listening socket: %v", err)
+	}
+
+	return nil
+}
+
+// setNonblock puts the socket of the listener in non-blocking mode.
+func setNonblock(ul *net.UnixListener) error {
+	raw, err := ul.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errNonblock error
+	err = raw.Control(func(fd uintptr) {
+		//nolint:gosec // G115 The file descriptor comes from the kernel, it fits in an int.
+		errNonblock = syscall.SetNonblock(int(fd), true)
+	})
+	if err != nil {
+		return err
+	}
+	return errNonblock
+}
+
+// waitForHandoverReady waits for the new daemon to signal on the pipe that it's ready to serve.
+func waitForHandoverReady(ready *os.File) error {
+	if err := ready.SetReadDeadline(time.Now().Add(handoverReadyTimeout)); err != nil {
+		return fmt.Errorf("could not set timeout: %w", err)
+	}
+
+	_, err := ready.Read(make([]byte, 1))
+	if errors.Is(err, io.EOF) {
+		return errors.New("the new daemon exited before being ready")
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return fmt.Errorf("the new daemon was not ready after %s", handoverReadyTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("could not wait for the new daemon: %w", err)
+	}
+	return nil
+}
+
+// drain waits for the connections which were open when the socket was handed over to be closed, then stops the
+// gRPC server. After handoverDrainTimeout, the remaining connections are dropped.
+func (d *Daemon) drain(ctx context.Context) {
+	closed := make(chan struct{})
+	go func() {
+		d.lis.conns.Wait()
+		close(closed)
+	}()
+
+	log.Info(ctx, "Waiting for the open connections to be closed")
+	select {
+	case <-closed:
+		d.grpcServer.GracefulStop()
+		log.Info(ctx, "All connections were closed")
+	case <-time.After(handoverDrainTimeout):
+		log.Warningf(ctx, "Connections are still open after %s, closing them", handoverDrainTimeout)
+		d.grpcServer.Stop()
+	}
+}
+
+// handedOverListener returns the listening socket handed over by the previous daemon, and the pipe on which we
+// signal that we're ready to serve it.
+func handedOverListener(listenFD, readyFD string) (net.Listener, *os.File, error) {
+	// The processes we start must not take the handover for them.
+	_ = os.Unsetenv(handoverListenFDEnv)
+	_ = os.Unsetenv(handoverReadyFDEnv)
+
+	lisFile, err := inheritedFile(listenFD, "handed over socket")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer lisFile.Close()
+
+	lis, err := net.FileListener(lisFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid handed over socket: %w", err)
+	}
+
+	ready, err := inheritedFile(readyFD, "handover pipe")
+	if err != nil {
+		_ = lis.Close()
+		return nil, nil, err
+	}
+
+	return lis, ready, nil
+}
+
+// inheritedFile returns the file of a file descriptor inherited from the parent process.
+func inheritedFile(fd, name string) (*os.File, error) {
+	n, err := strconv.Atoi(fd)
+	// File descriptors 0 to 2 are the standard streams.
+	if err != nil || n < 3 {
+		return nil, fmt.Errorf("invalid file descriptor %q for the %s", fd, name)
+	}
+	// Don't leak the file descriptor to the processes we start.
+	syscall.CloseOnExec(n)
+	return os.NewFile(uintptr(n), name), nil
+}
+
+// trackingListener is a listener keeping track of the connections it accepted which are still open.
+type trackingListener struct {
+	net.Listener
+	conns sync.WaitGroup
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.conns.Add(1)
+	return &trackedConn{Conn: conn, done: l.conns.Done}, nil
+}
+
+// trackedConn is a connection accepted by a trackingListener.
+type trackedConn struct {
+	net.Conn
+	closeOnce sync.Once
+	done      func()
+}
+
+func (c *trackedConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(c.done)
+	return err
+}
+
+// SyscallConn returns the raw connection, which is needed to get the credentials of the peer.
+func (c *trackedConn) SyscallConn() (syscall.RawConn, error) {
+	sc, ok := c.Conn.(syscall.Conn)
+	if !ok {
+		return nil, fmt.Errorf("unsupported connection type %T", c.Conn)
+	}
+	return sc.SyscallConn()
+}
@@ -0,0 +1,63 @@
+// Package emergencyaccess keeps the administrators able to log in on the machine during an outage of the brokers, with
+// break-glass local users and a grace window for the logins granted with the information cached by the brokers.
+package emergencyaccess
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Config holds the emergency access to the machine.
+type Config struct {
+	// Users are local users of the machine which always log in with their local password, through the local broker,
+	// even if a broker provides a user with the same name.
+	Users []string `mapstructure:"emergency_users" yaml:"emergency_users,omitempty"`
+	// GraceThreshold is how long all the brokers must have been unable to reach their identity provider before the
+	// offline limits of the login policy are lifted, until one of them reaches it again. If 0, they are never lifted.
+	GraceThreshold time.Duration `mapstructure:"emergency_grace_threshold" yaml:"emergency_grace_threshold,omitempty"`
+}
+
+// Validate returns an error if the configuration is invalid.
+func (c Config) Validate() error {
+	for _, u := range c.Users {
+		if u == "" || strings.ContainsAny(u, ":, ") {
+			return fmt.Errorf("invalid user %q in emergency_users", u)
+		}
+	}
+	if c.GraceThreshold < 0 {
+		return errors.New("emergency_grace_threshold must not be negative")
+	}
+	return nil
+}
+
+// IsEmergencyUser returns true if the user is one of the emergency users.
+func (c Config) IsEmergencyUser(username string) bool {
+	// authd compares the user names case insensitively, so no user of a broker can be named like an emergency user.
+	return slices.ContainsFunc(c.Users, func(u string) bool { return strings.EqualFold(u, username) })
+}
+
+// GraceActive returns true if all the brokers have been unable to reach their identity provider for longer than the
+// grace threshold at the given time, given the time of the last logins they granted online and offline, by broker ID.
+//
+// A broker is unable to reach its identity provider if the last login it granted was an offline one, since its last
+// online login. The brokers which never granted a login are not taken into account.
+func (c Config) GraceActive(lastOnline, lastOffline map[string]time.Time, now time.Time) bool {
+	if c.GraceThreshold == 0 || len(lastOffline) == 0 {
+		return false
+	}
+
+	var reachedAt time.Time
+	for brokerID, online := range lastOnline {
+		offline, ok := lastOffline[brokerID]
+		if !ok || !offline.After(online) {
+			return false
+		}
+		if online.After(reachedAt) {
+			reachedAt = online
+		}
+	}
+	return now.Sub(reachedAt) > c.GraceThreshold
+}
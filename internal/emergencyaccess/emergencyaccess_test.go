@@ -0,0 +1,123 @@
+package emergencyaccess_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/emergencyaccess"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cfg emergencyaccess.Config
+
+		wantErr bool
+	}{
+		"Empty_configuration":        {},
+		"Users_and_grace_threshold":  {cfg: emergencyaccess.Config{Users: []string{"root", "fleet-admin"}, GraceThreshold: 4 * time.Hour}},
+		"Grace_threshold_only":       {cfg: emergencyaccess.Config{GraceThreshold: time.Hour}},
+		"Users_without_grace_window": {cfg: emergencyaccess.Config{Users: []string{"fleet-admin"}}},
+
+		"Error_when_user_is_empty":               {cfg: emergencyaccess.Config{Users: []string{""}}, wantErr: true},
+		"Error_when_user_is_invalid":             {cfg: emergencyaccess.Config{Users: []string{"fleet admin"}}, wantErr: true},
+		"Error_when_grace_threshold_is_negative": {cfg: emergencyaccess.Config{GraceThreshold: -time.Hour}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.cfg.Validate()
+			if tc.wantErr {
+				require.Error(t, err, "Validate should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "Validate should not return an error, but did")
+		})
+	}
+}
+
+func TestIsEmergencyUser(t *testing.T) {
+	t.Parallel()
+
+	cfg := emergencyaccess.Config{Users: []string{"fleet-admin"}}
+
+	require.True(t, cfg.IsEmergencyUser("fleet-admin"), "The configured user should be an emergency user")
+	require.True(t, cfg.IsEmergencyUser("Fleet-Admin"), "The user name should be compared case insensitively")
+	require.False(t, cfg.IsEmergencyUser("alice@example.com"), "Other users should not be emergency users")
+	require.False(t, emergencyaccess.Config{}.IsEmergencyUser("fleet-admin"), "There should be no emergency user by default")
+}
+
+func TestGraceActive(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	hoursAgo := func(h int) time.Time { return now.Add(-time.Duration(h) * time.Hour) }
+
+	tests := map[string]struct {
+		threshold   time.Duration
+		lastOnline  map[string]time.Time
+		lastOffline map[string]time.Time
+
+		wantActive bool
+	}{
+		"Active_when_the_only_broker_is_unreachable_for_longer_than_threshold": {
+			lastOnline:  map[string]time.Time{"broker": hoursAgo(6)},
+			lastOffline: map[string]time.Time{"broker": hoursAgo(1)},
+			wantActive:  true,
+		},
+		"Active_when_all_brokers_are_unreachable_for_longer_than_threshold": {
+			lastOnline:  map[string]time.Time{"broker1": hoursAgo(8), "broker2": hoursAgo(5)},
+			lastOffline: map[string]time.Time{"broker1": hoursAgo(1), "broker2": hoursAgo(2)},
+			wantActive:  true,
+		},
+		"Active_when_broker_never_reached_its_provider": {
+			lastOffline: map[string]time.Time{"broker": hoursAgo(1)},
+			wantActive:  true,
+		},
+
+		"Inactive_without_threshold": {
+			threshold:   -1,
+			lastOnline:  map[string]time.Time{"broker": hoursAgo(6)},
+			lastOffline: map[string]time.Time{"broker": hoursAgo(1)},
+		},
+		"Inactive_without_logins": {},
+		"Inactive_when_broker_is_reachable": {
+			lastOnline:  map[string]time.Time{"broker": hoursAgo(1)},
+			lastOffline: map[string]time.Time{"broker": hoursAgo(6)},
+		},
+		"Inactive_when_broker_never_granted_offline_login": {
+			lastOnline: map[string]time.Time{"broker": hoursAgo(6)},
+		},
+		"Inactive_when_broker_is_unreachable_for_less_than_threshold": {
+			lastOnline:  map[string]time.Time{"broker": hoursAgo(2)},
+			lastOffline: map[string]time.Time{"broker": hoursAgo(1)},
+		},
+		"Inactive_when_another_broker_is_reachable": {
+			lastOnline:  map[string]time.Time{"broker1": hoursAgo(8), "broker2": hoursAgo(1)},
+			lastOffline: map[string]time.Time{"broker1": hoursAgo(1)},
+		},
+		"Inactive_when_another_broker_is_unreachable_for_less_than_threshold": {
+			lastOnline:  map[string]time.Time{"broker1": hoursAgo(8), "broker2": hoursAgo(2)},
+			lastOffline: map[string]time.Time{"broker1": hoursAgo(1), "broker2": hoursAgo(1)},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			switch tc.threshold {
+			case 0:
+				tc.threshold = 4 * time.Hour
+			case -1:
+				tc.threshold = 0
+			}
+			cfg := emergencyaccess.Config{GraceThreshold: tc.threshold}
+
+			got := cfg.GraceActive(tc.lastOnline, tc.lastOffline, now)
+			require.Equal(t, tc.wantActive, got, "GraceActive returned an unexpected result")
+		})
+	}
+}
@@ -0,0 +1,4 @@
+package faillock
+
+// MaxTrackedUsers allows tests to override the tracker capacity.
+var MaxTrackedUsers = &maxTrackedUsers
@@ -0,0 +1,186 @@
+// Package faillock locks the users out after too many consecutive authentication failures, like pam_faillock, so that
+// their password can't be guessed by trying many of them.
+package faillock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxTrackedUsers is the maximum number of distinct usernames tracked simultaneously
+// to bound memory usage.
+var maxTrackedUsers = 10000
+
+// Config holds the configurable parameters for the lockout of the users.
+type Config struct {
+	// Deny is the number of consecutive authentication failures after which the user is locked out. 0 disables the
+	// lockout.
+	Deny int `mapstructure:"faillock_deny" yaml:"faillock_deny"`
+	// FailInterval is the duration after the last failure after which the failures are forgotten.
+	FailInterval time.Duration `mapstructure:"faillock_fail_interval" yaml:"faillock_fail_interval"`
+	// UnlockTime is the duration after which a locked out user can authenticate again. 0 keeps the user locked out
+	// until unlocked with authctl user unlock.
+	UnlockTime time.Duration `mapstructure:"faillock_unlock_time" yaml:"faillock_unlock_time"`
+}
+
+// DefaultConfig is the default configuration for the lockout of the users.
+var DefaultConfig = Config{
+	Deny:         10,
+	FailInterval: 15 * time.Minute,
+	UnlockTime:   10 * time.Minute,
+}
+
+// Enabled returns true if the users are locked out after too many failures.
+func (c Config) Enabled() bool {
+	return c.Deny > 0
+}
+
+// Validate returns an error if the configuration is invalid.
+func (c Config) Validate() error {
+	if c.Deny < 0 {
+		return fmt.Errorf("invalid faillock_deny %d, must not be negative", c.Deny)
+	}
+	if c.FailInterval < 0 {
+		return fmt.Errorf("invalid faillock_fail_interval %s, must not be negative", c.FailInterval)
+	}
+	if c.UnlockTime < 0 {
+		return fmt.Errorf("invalid faillock_unlock_time %s, must not be negative", c.UnlockTime)
+	}
+	return nil
+}
+
+type failures struct {
+	count    int
+	lastFail time.Time
+	// lockedAt is when the user was locked out, zero if they are not.
+	lockedAt time.Time
+}
+
+// Tracker counts the consecutive authentication failures of the users and locks them out. A nil Tracker never locks
+// anyone out.
+type Tracker struct {
+	cfg Config
+
+	mu       sync.Mutex
+	failures map[string]*failures
+}
+
+// New returns a tracker locking out the users as configured, or nil if the lockout is disabled.
+func New(cfg Config) *Tracker {
+	if !cfg.Enabled() {
+		return nil
+	}
+	return &Tracker{cfg: cfg, failures: make(map[string]*failures)}
+}
+
+// LockedOut returns whether the user is locked out and until when. The time is zero if the user stays locked out until
+// unlocked.
+func (t *Tracker) LockedOut(username string) (until time.Time, locked bool) {
+	if t == nil {
+		return time.Time{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lockedOut(username, time.Now())
+}
+
+// lockedOut must be called with the lock held.
+func (t *Tracker) lockedOut(username string, now time.Time) (until time.Time, locked bool) {
+	f, ok := t.failures[username]
+	if !ok || f.lockedAt.IsZero() {
+		return time.Time{}, false
+	}
+	if t.cfg.UnlockTime == 0 {
+		return time.Time{}, true
+	}
+
+	until = f.lockedAt.Add(t.cfg.UnlockTime)
+	if !now.Before(until) {
+		// The lockout expired: the user starts again with no failure.
+		delete(t.failures, username)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// RecordFailure records an authentication failure of the user and returns true if it locked the user out.
+func (t *Tracker) RecordFailure(username string) (lockedOut bool) {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if _, locked := t.lockedOut(username, now); locked {
+		return false
+	}
+
+	f, ok := t.failures[username]
+	if ok && t.cfg.FailInterval > 0 && now.Sub(f.lastFail) >= t.cfg.FailInterval {
+		ok = false
+	}
+	if !ok {
+		if len(t.failures) >= maxTrackedUsers {
+			// Forget the failures which are too old to count, rather than not tracking the user, which would let a
+			// flood of bogus usernames disable the lockout for the real target.
+			t.removeStale(now)
+		}
+		f = &failures{}
+		t.failures[username] = f
+	}
+
+	f.count++
+	f.lastFail = now
+	if f.count < t.cfg.Deny {
+		return false
+	}
+	f.lockedAt = now
+	return true
+}
+
+// removeStale removes the users who are not locked out and whose failures are older than the fail interval, and the
+// one who failed the longest ago if none is. It must be called with the lock held.
+func (t *Tracker) removeStale(now time.Time) {
+	var oldest string
+	for name, f := range t.failures {
+		if f.lockedAt.IsZero() && t.cfg.FailInterval > 0 && now.Sub(f.lastFail) >= t.cfg.FailInterval {
+			delete(t.failures, name)
+			continue
+		}
+		if oldest == "" || f.lastFail.Before(t.failures[oldest].lastFail) {
+			oldest = name
+		}
+	}
+	if len(t.failures) >= maxTrackedUsers {
+		delete(t.failures, oldest)
+	}
+}
+
+// RecordSuccess forgets the failures of the user.
+func (t *Tracker) RecordSuccess(username string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.failures, username)
+}
+
+// Unlock forgets the failures of the user, so that a locked out user can authenticate again. It returns true if the
+// user was locked out.
+func (t *Tracker) Unlock(username string) (wasLockedOut bool) {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, wasLockedOut = t.lockedOut(username, time.Now())
+	delete(t.failures, username)
+	return wasLockedOut
+}
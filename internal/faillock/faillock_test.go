@@ -0,0 +1,147 @@
+package faillock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/faillock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordFailure(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cfg           faillock.Config
+		failures      int
+		succeedBefore bool
+		sleep         time.Duration
+
+		wantLockedOut bool
+		wantForever   bool
+	}{
+		"Not_locked_out_below_the_threshold": {cfg: faillock.Config{Deny: 3, UnlockTime: time.Hour}, failures: 2},
+		"Locked_out_when_the_threshold_is_reached": {
+			cfg: faillock.Config{Deny: 3, UnlockTime: time.Hour}, failures: 3, wantLockedOut: true,
+		},
+		"Locked_out_until_unlocked_without_unlock_time": {
+			cfg: faillock.Config{Deny: 3}, failures: 3, wantLockedOut: true, wantForever: true,
+		},
+		"Failures_before_a_success_are_not_counted": {
+			cfg: faillock.Config{Deny: 3, UnlockTime: time.Hour}, failures: 3, succeedBefore: true,
+		},
+		"Failures_outside_of_the_fail_interval_are_not_counted": {
+			cfg: faillock.Config{Deny: 2, FailInterval: time.Nanosecond, UnlockTime: time.Hour}, failures: 3,
+		},
+		"Not_locked_out_after_the_unlock_time": {
+			cfg: faillock.Config{Deny: 1, UnlockTime: time.Millisecond}, failures: 1, sleep: 10 * time.Millisecond,
+		},
+
+		"Never_locked_out_when_disabled": {cfg: faillock.Config{UnlockTime: time.Hour}, failures: 100},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tracker := faillock.New(tc.cfg)
+			var lockedOut bool
+			for i := range tc.failures {
+				if tc.succeedBefore && i == tc.failures-1 {
+					tracker.RecordSuccess("user1")
+				}
+				if tracker.RecordFailure("user1") {
+					require.False(t, lockedOut, "RecordFailure should report the lockout only once")
+					lockedOut = true
+				}
+			}
+			time.Sleep(tc.sleep)
+
+			until, locked := tracker.LockedOut("user1")
+			require.Equal(t, tc.wantLockedOut, locked, "LockedOut should return whether the user is locked out")
+			if tc.sleep == 0 {
+				require.Equal(t, tc.wantLockedOut, lockedOut, "RecordFailure should return whether it locked the user out")
+			}
+			if tc.wantLockedOut && !tc.wantForever {
+				require.WithinDuration(t, time.Now().Add(tc.cfg.UnlockTime), until, time.Minute, "LockedOut should return the end of the lockout")
+			} else {
+				require.True(t, until.IsZero(), "LockedOut should return no end of the lockout")
+			}
+
+			_, locked = tracker.LockedOut("user2")
+			require.False(t, locked, "Other users should not be locked out")
+		})
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	t.Parallel()
+
+	tracker := faillock.New(faillock.Config{Deny: 2})
+	require.False(t, tracker.Unlock("user1"), "Unlock should return false for a user who is not locked out")
+
+	tracker.RecordFailure("user1")
+	tracker.RecordFailure("user1")
+	require.True(t, tracker.Unlock("user1"), "Unlock should return true for a user who was locked out")
+
+	_, locked := tracker.LockedOut("user1")
+	require.False(t, locked, "The user should not be locked out after being unlocked")
+	require.False(t, tracker.RecordFailure("user1"), "The failures before the unlock should be forgotten")
+}
+
+func TestNilTrackerLocksOutNobody(t *testing.T) {
+	t.Parallel()
+
+	var tracker *faillock.Tracker
+	require.False(t, tracker.RecordFailure("user1"), "RecordFailure should not lock out the user")
+	tracker.RecordSuccess("user1")
+	_, locked := tracker.LockedOut("user1")
+	require.False(t, locked, "LockedOut should return false")
+	require.False(t, tracker.Unlock("user1"), "Unlock should return false")
+}
+
+func TestRecordFailureWhenTrackerIsFull(t *testing.T) {
+	// Cannot be parallel: temporarily overrides the package-level maxTrackedUsers.
+	//nolint:paralleltest // modifies package-level maxTrackedUsers, cannot run in parallel
+
+	orig := *faillock.MaxTrackedUsers
+	*faillock.MaxTrackedUsers = 2
+	t.Cleanup(func() { *faillock.MaxTrackedUsers = orig })
+
+	tracker := faillock.New(faillock.Config{Deny: 2})
+	tracker.RecordFailure("bogus1")
+	tracker.RecordFailure("bogus2")
+
+	// The target is still tracked, the bogus user who failed the longest ago is forgotten instead.
+	tracker.RecordFailure("target")
+	require.True(t, tracker.RecordFailure("target"), "The target should be locked out even if the tracker is full")
+	require.False(t, tracker.RecordFailure("bogus1"), "The failures of the oldest user should be forgotten")
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cfg faillock.Config
+
+		wantErr bool
+	}{
+		"Valid_default_config":  {cfg: faillock.DefaultConfig},
+		"Valid_disabled_config": {},
+
+		"Error_on_negative_deny":          {cfg: faillock.Config{Deny: -1}, wantErr: true},
+		"Error_on_negative_fail_interval": {cfg: faillock.Config{Deny: 3, FailInterval: -time.Second}, wantErr: true},
+		"Error_on_negative_unlock_time":   {cfg: faillock.Config{Deny: 3, UnlockTime: -time.Second}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.cfg.Validate()
+			if tc.wantErr {
+				require.Error(t, err, "Validate should have failed")
+				return
+			}
+			require.NoError(t, err, "Validate should not have failed")
+		})
+	}
+}
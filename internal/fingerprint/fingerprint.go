@@ -0,0 +1,194 @@
+// Package fingerprint verifies the fingerprints of the users with fprintd.
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/canonical/authd/log"
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	dbusName        = "net.reactivated.Fprint"
+	managerPath     = dbus.ObjectPath("/net/reactivated/Fprint/Manager")
+	managerIface    = "net.reactivated.Fprint.Manager"
+	deviceIface     = "net.reactivated.Fprint.Device"
+	verifyStatus    = deviceIface + ".VerifyStatus"
+	errNoSuchDevice = "net.reactivated.Fprint.Error.NoSuchDevice"
+	errNoPrints     = "net.reactivated.Fprint.Error.NoEnrolledPrints"
+	// errNoService is returned by the bus when fprintd is not installed.
+	errNoService = "org.freedesktop.DBus.Error.ServiceUnknown"
+)
+
+// ErrNoMatch is returned when the scanned finger doesn't match any of the fingers enrolled by the user.
+var ErrNoMatch = errors.New("the fingerprint did not match")
+
+// Verifier checks the fingerprints of the users.
+type Verifier interface {
+	// Enrolled returns true if the user enrolled a finger on the default fingerprint reader.
+	Enrolled(ctx context.Context, username string) (bool, error)
+	// Verify waits for the user to scan a finger and returns ErrNoMatch if it's not one of their enrolled fingers.
+	Verify(ctx context.Context, username string) error
+}
+
+// FprintD is the Verifier using the fprintd service on the system bus.
+type FprintD struct {
+	conn   *dbus.Conn
+	connMu sync.Mutex
+}
+
+// NewFprintD returns a Verifier using fprintd. It only connects to the system bus on first use, as most machines
+// don't have a fingerprint reader.
+func NewFprintD() *FprintD {
+	return &FprintD{}
+}
+
+// Enrolled returns true if the user enrolled a finger on the default fingerprint reader. It returns false if there is
+// no fingerprint reader or if fprintd is not installed.
+func (f *FprintD) Enrolled(ctx context.Context, username string) (bool, error) {
+	device, err := f.defaultDevice()
+	if isDBusError(err, errNoSuchDevice, errNoService) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var fingers []string
+	err = device.CallWithContext(ctx, deviceIface+".ListEnrolledFingers", 0, username).Store(&fingers)
+	if isDBusError(err, errNoPrints) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not list the enrolled fingers of %q: %v", username, err)
+	}
+	return len(fingers) > 0, nil
+}
+
+// Verify waits for the user to scan a finger on the default fingerprint reader and returns ErrNoMatch if it's not one
+// of their enrolled fingers.
+func (f *FprintD) Verify(ctx context.Context, username string) error {
+	device, err := f.defaultDevice()
+	if err != nil {
+		return err
+	}
+
+	// Subscribe to the status before starting the verification, to not miss the result of a fast match.
+	matchOptions := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(device.Path()),
+		dbus.WithMatchInterface(deviceIface),
+		dbus.WithMatchMember("VerifyStatus"),
+	}
+	if err := f.conn.AddMatchSignal(matchOptions...); err != nil {
+		return fmt.Errorf("could not subscribe to the verification status: %v", err)
+	}
+	defer func() { _ = f.conn.RemoveMatchSignal(matchOptions...) }()
+	signals := make(chan *dbus.Signal, 10)
+	f.conn.Signal(signals)
+	defer f.conn.RemoveSignal(signals)
+
+	if err := device.CallWithContext(ctx, deviceIface+".Claim", 0, username).Err; err != nil {
+		return fmt.Errorf("could not claim the fingerprint reader: %v", err)
+	}
+	defer func() {
+		// The device must be released even if the request was cancelled, so that it can be used by other sessions.
+		if err := device.Call(deviceIface+".Release", 0).Err; err != nil {
+			log.Warningf(context.Background(), "Could not release the fingerprint reader: %v", err)
+		}
+	}()
+
+	if err := device.CallWithContext(ctx, deviceIface+".VerifyStart", 0, "any").Err; err != nil {
+		return fmt.Errorf("could not start the fingerprint verification: %v", err)
+	}
+	defer func() {
+		if err := device.Call(deviceIface+".VerifyStop", 0).Err; err != nil {
+			log.Debugf(context.Background(), "Could not stop the fingerprint verification: %v", err)
+		}
+	}()
+
+	for {
+		var s *dbus.Signal
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case s, ok = <-signals:
+		}
+		if !ok {
+			return errors.New("the connection to the system bus was closed")
+		}
+		if s.Path != device.Path() || s.Name != verifyStatus {
+			continue
+		}
+
+		var result string
+		var done bool
+		if err := dbus.Store(s.Body, &result, &done); err != nil {
+			return fmt.Errorf("invalid verification status: %v", err)
+		}
+		log.Debugf(ctx, "Fingerprint verification status for %q: %s", username, result)
+		// The other statuses (e.g. verify-retry-scan) are sent when the scan must be retried.
+		if !done {
+			continue
+		}
+
+		switch result {
+		case "verify-match":
+			return nil
+		case "verify-no-match":
+			return ErrNoMatch
+		default:
+			return fmt.Errorf("fingerprint verification failed: %s", result)
+		}
+	}
+}
+
+// defaultDevice returns the default fingerprint reader.
+func (f *FprintD) defaultDevice() (dbus.BusObject, error) {
+	f.connMu.Lock()
+	defer f.connMu.Unlock()
+
+	if f.conn == nil {
+		// Don't call dbus.SystemBus which caches globally system dbus (issues in tests)
+		conn, err := dbus.ConnectSystemBus()
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to the system bus: %v", err)
+		}
+		f.conn = conn
+	}
+
+	var path dbus.ObjectPath
+	if err := f.conn.Object(dbusName, managerPath).Call(managerIface+".GetDefaultDevice", 0).Store(&path); err != nil {
+		return nil, fmt.Errorf("could not get the default fingerprint reader: %w", err)
+	}
+	return f.conn.Object(dbusName, path), nil
+}
+
+// Close closes the connection to the system bus, if any.
+func (f *FprintD) Close() error {
+	f.connMu.Lock()
+	defer f.connMu.Unlock()
+
+	if f.conn == nil {
+		return nil
+	}
+	err := f.conn.Close()
+	f.conn = nil
+	return err
+}
+
+func isDBusError(err error, names ...string) bool {
+	var dbusErr dbus.Error
+	if !errors.As(err, &dbusErr) {
+		return false
+	}
+	for _, name := range names {
+		if dbusErr.Name == name {
+			return true
+		}
+	}
+	return false
+}
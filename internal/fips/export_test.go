@@ -0,0 +1,6 @@
+package fips
+
+// SetKernelFIPSPath overrides the file reporting whether the kernel runs in FIPS mode.
+func SetKernelFIPSPath(path string) {
+	kernelFIPSPath = path
+}
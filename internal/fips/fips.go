@@ -0,0 +1,51 @@
+// Package fips implements the checks of the FIPS 140 compliance mode.
+//
+// In this mode, authd refuses to start if the Go cryptographic module is not running in FIPS 140 mode, and refuses
+// cryptographic parameters which are not approved, like RSA keys smaller than 2048 bits.
+package fips
+
+import (
+	"crypto"
+	"crypto/fips140"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MinRSAKeySize is the minimum size in bits of the RSA keys approved for encryption.
+const MinRSAKeySize = 2048
+
+// kernelFIPSPath is the file reporting whether the kernel runs in FIPS mode. It is overridden in tests.
+var kernelFIPSPath = "/proc/sys/crypto/fips_enabled"
+
+// KernelEnabled returns true if the kernel runs in FIPS mode, like on Ubuntu Pro FIPS.
+func KernelEnabled() bool {
+	data, err := os.ReadFile(kernelFIPSPath)
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(data)) == "1"
+}
+
+// Check returns an error if the Go cryptographic module is not running in FIPS 140 mode.
+func Check() error {
+	if !fips140.Enabled() {
+		return errors.New("FIPS mode is enabled, but the Go cryptographic module is not running in FIPS 140 mode: " +
+			"start the service with GODEBUG=fips140=on")
+	}
+	return nil
+}
+
+// CheckPublicKey returns an error if key can't be used for encryption in FIPS mode.
+func CheckPublicKey(key crypto.PublicKey) error {
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%T keys are not approved for encryption in FIPS mode", key)
+	}
+	if size := rsaKey.Size() * 8; size < MinRSAKeySize {
+		return fmt.Errorf("RSA keys of %d bits are not approved in FIPS mode, at least %d bits are required", size, MinRSAKeySize)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package fips_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/fips140"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/fips"
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:tparallel // This test overrides the global kernel FIPS path, so its subtests can't run in parallel.
+func TestKernelEnabled(t *testing.T) {
+	tests := map[string]struct {
+		content  string
+		noFile   bool
+		wantFIPS bool
+	}{
+		"Enabled_when_kernel_is_in_FIPS_mode": {content: "1\n", wantFIPS: true},
+
+		"Disabled_when_kernel_is_not_in_FIPS_mode": {content: "0\n"},
+		"Disabled_when_file_does_not_exist":        {noFile: true},
+		"Disabled_when_file_has_unexpected_value":  {content: "yes\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fips_enabled")
+			if !tc.noFile {
+				require.NoError(t, os.WriteFile(path, []byte(tc.content), 0600), "Setup: could not write FIPS file")
+			}
+			fips.SetKernelFIPSPath(path)
+
+			require.Equal(t, tc.wantFIPS, fips.KernelEnabled(), "Unexpected kernel FIPS mode")
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	err := fips.Check()
+	if fips140.Enabled() {
+		require.NoError(t, err, "Check should not fail when the Go cryptographic module is in FIPS 140 mode")
+		return
+	}
+	require.Error(t, err, "Check should fail when the Go cryptographic module is not in FIPS 140 mode")
+}
+
+func TestCheckPublicKey(t *testing.T) {
+	t.Parallel()
+
+	rsa2048, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err, "Setup: could not generate RSA key")
+	rsa3072, err := rsa.GenerateKey(rand.Reader, 3072)
+	require.NoError(t, err, "Setup: could not generate RSA key")
+	//nolint:gosec // We need a weak key to check that it's refused.
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		// The Go cryptographic module refuses to generate weak keys in FIPS 140 only mode.
+		t.Logf("Could not generate a weak RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Setup: could not generate ECDSA key")
+
+	tests := map[string]struct {
+		key any
+
+		wantErr bool
+	}{
+		"Accept_RSA_2048_key": {key: &rsa2048.PublicKey},
+		"Accept_RSA_3072_key": {key: &rsa3072.PublicKey},
+
+		"Error_on_RSA_1024_key": {key: rsa1024, wantErr: true},
+		"Error_on_ECDSA_key":    {key: &ecKey.PublicKey, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if k, ok := tc.key.(*rsa.PrivateKey); ok {
+				if k == nil {
+					t.Skip("Weak RSA key could not be generated")
+				}
+				tc.key = &k.PublicKey
+			}
+
+			err := fips.CheckPublicKey(tc.key)
+			if tc.wantErr {
+				require.Error(t, err, "CheckPublicKey should have failed")
+				return
+			}
+			require.NoError(t, err, "CheckPublicKey should not have failed")
+		})
+	}
+}
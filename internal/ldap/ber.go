@@ -0,0 +1,220 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The classes of the BER tags.
+const (
+	classUniversal   byte = 0x00
+	classApplication byte = 0x40
+	classContext     byte = 0x80
+)
+
+// The universal tags used by LDAP.
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x10
+	tagSet         = 0x11
+)
+
+// maxPacketSize is the maximum size of a message read from the server, which is much more than the entries of users.
+const maxPacketSize = 1 << 20
+
+// maxDepth is the maximum nesting of the elements of a message. The messages of LDAP, filters included, are nested
+// less deeply, and the limit prevents crafted messages from exhausting the stack.
+const maxDepth = 10
+
+// packet is an element encoded with the Basic Encoding Rules, restricted to what LDAP uses: tags with a number below
+// 31 and lengths in the definite form.
+type packet struct {
+	class       byte
+	constructed bool
+	tag         byte
+	// value is the content of a primitive element.
+	value []byte
+	// children are the elements of a constructed element.
+	children []*packet
+}
+
+func primitive(class, tag byte, value []byte) *packet {
+	return &packet{class: class, tag: tag, value: value}
+}
+
+func constructed(class, tag byte, children ...*packet) *packet {
+	return &packet{class: class, constructed: true, tag: tag, children: children}
+}
+
+func sequence(children ...*packet) *packet {
+	return constructed(classUniversal, tagSequence, children...)
+}
+
+func octetString(s string) *packet {
+	return primitive(classUniversal, tagOctetString, []byte(s))
+}
+
+func integer(tag byte, n int64) *packet {
+	// Big-endian two's complement, with as few bytes as possible.
+	var b []byte
+	for {
+		b = append([]byte{byte(n)}, b...)
+		if (n < 128 && n >= -128) || len(b) == 8 {
+			break
+		}
+		n >>= 8
+	}
+	return primitive(classUniversal, tag, b)
+}
+
+func boolean(v bool) *packet {
+	if v {
+		return primitive(classUniversal, tagBoolean, []byte{0xff})
+	}
+	return primitive(classUniversal, tagBoolean, []byte{0x00})
+}
+
+// is returns true if the packet has the class and the tag.
+func (p *packet) is(class, tag byte) bool {
+	return p.class == class && p.tag == tag
+}
+
+// int returns the value of an INTEGER or ENUMERATED element.
+func (p *packet) int() (int64, error) {
+	if p.constructed || len(p.value) == 0 || len(p.value) > 8 {
+		return 0, errors.New("invalid integer")
+	}
+	n := int64(int8(p.value[0]))
+	for _, b := range p.value[1:] {
+		n = n<<8 | int64(b)
+	}
+	return n, nil
+}
+
+// bytes returns the encoding of the packet.
+func (p *packet) bytes() []byte {
+	content := p.value
+	if p.constructed {
+		content = nil
+		for _, c := range p.children {
+			content = append(content, c.bytes()...)
+		}
+	}
+
+	id := p.class | p.tag
+	if p.constructed {
+		id |= 0x20
+	}
+	return append(append([]byte{id}, encodeLength(len(content))...), content...)
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+// readPacket reads a packet from r.
+func readPacket(r *bufio.Reader) (*packet, error) {
+	id, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length, err := readLength(r)
+	if errors.Is(err, io.EOF) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	if length > maxPacketSize {
+		return nil, fmt.Errorf("message of %d bytes is too large", length)
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); errors.Is(err, io.EOF) {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+	return parsePacket(id, content, 1)
+}
+
+func readLength(r io.ByteReader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b < 0x80 {
+		return int(b), nil
+	}
+
+	// The long form, which servers may use even for short lengths.
+	n := int(b & 0x7f)
+	if n == 0 {
+		return 0, errors.New("indefinite lengths are not allowed in LDAP")
+	}
+	if n > 4 {
+		return 0, fmt.Errorf("length on %d bytes is too large", n)
+	}
+	length := 0
+	for range n {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	return length, nil
+}
+
+// parsePacket parses the content of the element with the identifier id, which is nested at depth.
+func parsePacket(id byte, content []byte, depth int) (*packet, error) {
+	if id&0x1f == 0x1f {
+		return nil, errors.New("tags with a high number are not used in LDAP")
+	}
+	if depth > maxDepth {
+		return nil, fmt.Errorf("elements are nested deeper than %d levels", maxDepth)
+	}
+
+	p := &packet{class: id & 0xc0, constructed: id&0x20 != 0, tag: id & 0x1f}
+	if !p.constructed {
+		p.value = content
+		return p, nil
+	}
+
+	// The children are sliced from the content, which was already read in full.
+	for len(content) > 0 {
+		r := bytes.NewReader(content[1:])
+		length, err := readLength(r)
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		start := len(content) - r.Len()
+		if length > r.Len() {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		child, err := parsePacket(content[0], content[start:start+length], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, child)
+		content = content[start+length:]
+	}
+	return p, nil
+}
@@ -0,0 +1,490 @@
+// Package ldap looks up the POSIX attributes of the users in an LDAP directory, for the sites keeping them there while
+// authenticating the users with an identity provider.
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// startTLSOID is the name of the extended operation upgrading the connection to TLS.
+	startTLSOID = "1.3.6.1.4.1.1466.20037"
+	// timeout is the maximum duration of a lookup, as it delays the login of the user.
+	timeout = 10 * time.Second
+)
+
+// The LDAP operations, which are tags of the application class.
+const (
+	opBindRequest       = 0
+	opBindResponse      = 1
+	opUnbindRequest     = 2
+	opSearchRequest     = 3
+	opSearchResultEntry = 4
+	opSearchResultDone  = 5
+	opSearchResultRef   = 19
+	opExtendedRequest   = 23
+	opExtendedResponse  = 24
+)
+
+// The result codes of the operations.
+const (
+	resultSuccess           = 0
+	resultSizeLimitExceeded = 4
+)
+
+// ErrNoSuchUser is returned when the user has no POSIX account in the directory.
+var ErrNoSuchUser = errors.New("no POSIX account found for the user")
+
+// Config configures the directory in which the POSIX attributes of the users are looked up.
+type Config struct {
+	// URL is the address of the server, either "ldaps://host[:port]", or "ldap://host[:port]" in which case the
+	// connection is upgraded with StartTLS. If empty, the attributes are not looked up.
+	URL string `mapstructure:"ldap_url" yaml:"ldap_url,omitempty"`
+	// CAFile is the path of the PEM encoded certificates of the authorities which the certificate of the server must
+	// be signed by. If empty, the authorities of the system are used.
+	CAFile string `mapstructure:"ldap_ca_file" yaml:"ldap_ca_file,omitempty"`
+	// BindDN is the DN which authd binds as. If empty, the lookups are anonymous.
+	BindDN string `mapstructure:"ldap_bind_dn" yaml:"ldap_bind_dn,omitempty"`
+	// BindPasswordFile is the path of the file containing the password of BindDN.
+	BindPasswordFile string `mapstructure:"ldap_bind_password_file" yaml:"ldap_bind_password_file,omitempty"`
+	// BaseDN is the DN under which the accounts are searched.
+	BaseDN string `mapstructure:"ldap_base_dn" yaml:"ldap_base_dn,omitempty"`
+	// UserAttribute is the attribute of the accounts which matches the name of the user in authd.
+	UserAttribute string `mapstructure:"ldap_user_attribute" yaml:"ldap_user_attribute,omitempty"`
+}
+
+// DefaultConfig is the default configuration of the directory, which is disabled.
+var DefaultConfig = Config{UserAttribute: "uid"}
+
+// Enabled returns true if the attributes of the users are looked up in a directory.
+func (c Config) Enabled() bool {
+	return c.URL != ""
+}
+
+// Validate returns an error if the configuration is invalid. It's valid if the lookups are disabled.
+func (c Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	if _, _, err := c.address(); err != nil {
+		return err
+	}
+	if c.BaseDN == "" {
+		return errors.New("ldap_base_dn is required with ldap_url")
+	}
+	if c.UserAttribute == "" {
+		return errors.New("ldap_user_attribute can't be empty")
+	}
+	if c.BindDN != "" && c.BindPasswordFile == "" {
+		return errors.New("ldap_bind_password_file is required with ldap_bind_dn")
+	}
+	return nil
+}
+
+// address returns the address of the server and whether the connection starts with TLS.
+func (c Config) address() (addr string, implicitTLS bool, err error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid ldap_url: %w", err)
+	}
+	if u.Hostname() == "" || (u.Path != "" && u.Path != "/") {
+		return "", false, fmt.Errorf("invalid ldap_url %q: expected ldaps://host[:port] or ldap://host[:port]", c.URL)
+	}
+
+	port := u.Port()
+	switch u.Scheme {
+	case "ldaps":
+		implicitTLS = true
+		if port == "" {
+			port = "636"
+		}
+	case "ldap":
+		if port == "" {
+			port = "389"
+		}
+	default:
+		return "", false, fmt.Errorf("invalid ldap_url %q: unsupported scheme %q", c.URL, u.Scheme)
+	}
+	return net.JoinHostPort(u.Hostname(), port), implicitTLS, nil
+}
+
+// Account holds the POSIX attributes of a user.
+type Account struct {
+	UID  uint32
+	GID  uint32
+	Home string
+}
+
+// Directory looks up the POSIX attributes of the users.
+type Directory interface {
+	// LookupUser returns the account of the user, or ErrNoSuchUser if they don't have one.
+	LookupUser(ctx context.Context, name string) (Account, error)
+}
+
+// Client is the Directory looking up the accounts on an LDAP server.
+type Client struct {
+	cfg Config
+}
+
+// NewClient returns a client of the directory configured in cfg, which must be valid. The certificates and the
+// password are read on each lookup, so that they can be renewed without restarting authd.
+func NewClient(cfg Config) *Client {
+	return &Client{cfg: cfg}
+}
+
+// LookupUser connects to the server and returns the posixAccount whose user attribute is the name of the user.
+func (c *Client) LookupUser(ctx context.Context, name string) (Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return Account{}, err
+	}
+	defer conn.close()
+
+	if c.cfg.BindDN != "" {
+		password, err := os.ReadFile(c.cfg.BindPasswordFile)
+		if err != nil {
+			return Account{}, fmt.Errorf("could not read ldap_bind_password_file: %w", err)
+		}
+		if err := conn.bind(c.cfg.BindDN, strings.TrimRight(string(password), "\r\n")); err != nil {
+			return Account{}, err
+		}
+	}
+
+	entries, err := conn.search(c.cfg.BaseDN,
+		and(equal("objectClass", "posixAccount"), equal(c.cfg.UserAttribute, name)),
+		"uidNumber", "gidNumber", "homeDirectory")
+	if err != nil {
+		return Account{}, err
+	}
+	switch len(entries) {
+	case 0:
+		return Account{}, ErrNoSuchUser
+	case 1:
+	default:
+		return Account{}, fmt.Errorf("several POSIX accounts have %s=%s", c.cfg.UserAttribute, name)
+	}
+
+	return parseAccount(entries[0])
+}
+
+func parseAccount(e entry) (a Account, err error) {
+	if a.UID, err = e.id("uidNumber"); err != nil {
+		return a, err
+	}
+	if a.GID, err = e.id("gidNumber"); err != nil {
+		return a, err
+	}
+	// A home directory is required by posixAccount, but empty values are seen in the wild.
+	if home := e.first("homeDirectory"); home != "" {
+		if !filepath.IsAbs(home) {
+			return a, fmt.Errorf("invalid homeDirectory %q of %s: not an absolute path", home, e.dn)
+		}
+		a.Home = filepath.Clean(home)
+	}
+	return a, nil
+}
+
+// entry is an entry returned by a search.
+type entry struct {
+	dn         string
+	attributes map[string][]string
+}
+
+// first returns the first value of the attribute, or an empty string if it's not set.
+func (e entry) first(attr string) string {
+	for name, values := range e.attributes {
+		// The names of the attributes are case insensitive.
+		if strings.EqualFold(name, attr) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// id returns the value of a uidNumber or gidNumber attribute, which can't be the one of root.
+func (e entry) id(attr string) (uint32, error) {
+	v := e.first(attr)
+	if v == "" {
+		return 0, fmt.Errorf("%s has no %s", e.dn, attr)
+	}
+	id, err := strconv.ParseUint(v, 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid %s %q of %s", attr, v, e.dn)
+	}
+	return uint32(id), nil
+}
+
+// conn is a connection to the server. The operations are done one at a time.
+type conn struct {
+	net.Conn
+	r  *bufio.Reader
+	id int64
+}
+
+func (c *Client) connect(ctx context.Context) (*conn, error) {
+	addr, implicitTLS, err := c.cfg.address()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := c.tlsConfig(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	netConn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to the LDAP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := netConn.SetDeadline(deadline); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	// The IDs of the messages are unique for the whole session, including the StartTLS request.
+	var id int64
+	if !implicitTLS {
+		plain := &conn{Conn: netConn, r: bufio.NewReader(netConn)}
+		if err := plain.startTLS(); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+		id = plain.id
+	}
+
+	tlsConn := tls.Client(netConn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("TLS handshake with the LDAP server failed: %w", err)
+	}
+	return &conn{Conn: tlsConn, r: bufio.NewReader(tlsConn), id: id}, nil
+}
+
+func (c *Client) tlsConfig(addr string) (*tls.Config, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if c.cfg.CAFile == "" {
+		return cfg, nil
+	}
+
+	pem, err := os.ReadFile(c.cfg.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read ldap_ca_file: %w", err)
+	}
+	cfg.RootCAs = x509.NewCertPool()
+	if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in ldap_ca_file %q", c.cfg.CAFile)
+	}
+	return cfg, nil
+}
+
+func (c *conn) close() {
+	// The server closes the connection on unbind, without a response.
+	//nolint:errcheck // The lookup is done, the connection is closed anyway.
+	c.send(primitive(classApplication, opUnbindRequest, nil))
+	c.Close()
+}
+
+// send sends the request in a new message and returns the ID of the message.
+func (c *conn) send(op *packet) (int64, error) {
+	c.id++
+	if _, err := c.Write(sequence(integer(tagInteger, c.id), op).bytes()); err != nil {
+		return 0, fmt.Errorf("could not send the request to the LDAP server: %w", err)
+	}
+	return c.id, nil
+}
+
+// receive returns the operation of the next response to the message.
+func (c *conn) receive(id int64) (*packet, error) {
+	for {
+		msg, err := readPacket(c.r)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the response of the LDAP server: %w", err)
+		}
+		if !msg.is(classUniversal, tagSequence) || len(msg.children) < 2 {
+			return nil, errors.New("invalid message from the LDAP server")
+		}
+		msgID, err := msg.children[0].int()
+		if err != nil {
+			return nil, fmt.Errorf("invalid message from the LDAP server: %w", err)
+		}
+		// Unsolicited notifications have the ID 0, like the notice of disconnection.
+		if msgID == 0 {
+			return nil, fmt.Errorf("the LDAP server closed the connection: %w", checkResult(msg.children[1]))
+		}
+		if msgID == id {
+			return msg.children[1], nil
+		}
+	}
+}
+
+// request sends the request and returns its response, which must be the expected operation.
+func (c *conn) request(op *packet, responseOp byte) (*packet, error) {
+	id, err := c.send(op)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.receive(id)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.is(classApplication, responseOp) {
+		return nil, fmt.Errorf("unexpected response %d from the LDAP server", resp.tag)
+	}
+	return resp, checkResult(resp)
+}
+
+// checkResult returns an error if the LDAPResult of the response isn't a success.
+func checkResult(resp *packet) error {
+	code, msg, err := result(resp)
+	if err != nil {
+		return err
+	}
+	if code != resultSuccess {
+		return resultError(code, msg)
+	}
+	return nil
+}
+
+func result(resp *packet) (code int64, msg string, err error) {
+	if !resp.constructed || len(resp.children) < 3 {
+		return 0, "", errors.New("invalid result from the LDAP server")
+	}
+	code, err = resp.children[0].int()
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid result from the LDAP server: %w", err)
+	}
+	return code, string(resp.children[2].value), nil
+}
+
+func resultError(code int64, msg string) error {
+	if msg == "" {
+		return fmt.Errorf("the LDAP server returned the error %d", code)
+	}
+	return fmt.Errorf("the LDAP server returned the error %d: %s", code, msg)
+}
+
+func (c *conn) startTLS() error {
+	_, err := c.request(constructed(classApplication, opExtendedRequest,
+		primitive(classContext, 0, []byte(startTLSOID))), opExtendedResponse)
+	if err != nil {
+		return fmt.Errorf("StartTLS failed: %w", err)
+	}
+	return nil
+}
+
+func (c *conn) bind(dn, password string) error {
+	_, err := c.request(constructed(classApplication, opBindRequest,
+		integer(tagInteger, 3),
+		octetString(dn),
+		primitive(classContext, 0, []byte(password))), opBindResponse)
+	if err != nil {
+		return fmt.Errorf("could not bind to the LDAP server as %q: %w", dn, err)
+	}
+	return nil
+}
+
+// search returns the entries matching the filter under the base DN, with only the attributes requested. It returns
+// at most 2 entries, which is enough to tell whether a user has several accounts.
+func (c *conn) search(baseDN string, filter *packet, attributes ...string) ([]entry, error) {
+	var attrs []*packet
+	for _, a := range attributes {
+		attrs = append(attrs, octetString(a))
+	}
+
+	id, err := c.send(constructed(classApplication, opSearchRequest,
+		octetString(baseDN),
+		// The whole subtree, without dereferencing the aliases.
+		integer(tagEnumerated, 2),
+		integer(tagEnumerated, 0),
+		// The size limit and the time limit, in seconds.
+		integer(tagInteger, 2),
+		integer(tagInteger, int64(timeout/time.Second)),
+		boolean(false),
+		filter,
+		sequence(attrs...)))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	for {
+		resp, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case resp.is(classApplication, opSearchResultEntry):
+			e, err := parseEntry(resp)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		case resp.is(classApplication, opSearchResultRef):
+			// Referrals to other servers are not followed.
+		case resp.is(classApplication, opSearchResultDone):
+			code, msg, err := result(resp)
+			if err != nil {
+				return nil, err
+			}
+			// The limit is exceeded if there are several entries, which is handled by the caller.
+			if code != resultSuccess && code != resultSizeLimitExceeded {
+				return nil, fmt.Errorf("search failed: %w", resultError(code, msg))
+			}
+			return entries, nil
+		default:
+			return nil, fmt.Errorf("unexpected response %d from the LDAP server", resp.tag)
+		}
+	}
+}
+
+func parseEntry(resp *packet) (entry, error) {
+	if len(resp.children) != 2 {
+		return entry{}, errors.New("invalid entry from the LDAP server")
+	}
+
+	e := entry{dn: string(resp.children[0].value), attributes: make(map[string][]string)}
+	for _, attr := range resp.children[1].children {
+		if len(attr.children) != 2 || !attr.children[1].is(classUniversal, tagSet) {
+			return entry{}, errors.New("invalid attribute from the LDAP server")
+		}
+		name := string(attr.children[0].value)
+		for _, v := range attr.children[1].children {
+			e.attributes[name] = append(e.attributes[name], string(v.value))
+		}
+	}
+	return e, nil
+}
+
+// and returns a filter matching the entries matching all the filters.
+func and(filters ...*packet) *packet {
+	return constructed(classContext, 0, filters...)
+}
+
+// equal returns a filter matching the entries whose attribute has the value. As the filter is encoded, the value
+// needs no escaping.
+func equal(attr, value string) *packet {
+	return constructed(classContext, 3, octetString(attr), octetString(value))
+}
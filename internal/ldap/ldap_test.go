@@ -0,0 +1,342 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBaseDN   = "ou=people,dc=example,dc=com"
+	testBindDN   = "cn=authd,dc=example,dc=com"
+	testPassword = "secret"
+)
+
+func TestLookupUser(t *testing.T) {
+	t.Parallel()
+
+	account := func(uid, gid, home string) map[string][]string {
+		a := map[string][]string{"uidNumber": {uid}, "gidNumber": {gid}}
+		if home != "" {
+			a["homeDirectory"] = []string{home}
+		}
+		return a
+	}
+	accounts := map[string][]map[string][]string{
+		"alice":        {account("15000", "15000", "/home/alice")},
+		"bob":          {{"UIDNumber": {"15001"}, "GIDNumber": {"100"}, "HomeDirectory": {"/srv/home//bob/"}}},
+		"no-home":      {account("15002", "15002", "")},
+		"twice":        {account("15003", "15003", "/home/twice"), account("15004", "15004", "/home/twice")},
+		"invalid-uid":  {account("-1", "15005", "/home/invalid-uid")},
+		"root-uid":     {account("0", "15005", "/home/root-uid")},
+		"no-gid":       {{"uidNumber": {"15006"}, "homeDirectory": {"/home/no-gid"}}},
+		"relative-dir": {account("15007", "15007", "home/relative-dir")},
+	}
+
+	tests := map[string]struct {
+		name          string
+		startTLS      bool
+		bindDN        string
+		password      string
+		userAttribute string
+		noCAFile      bool
+		noServer      bool
+
+		want        Account
+		wantErr     bool
+		wantNotUser bool
+	}{
+		"Successfully_look_up_user":                   {name: "alice", want: Account{UID: 15000, GID: 15000, Home: "/home/alice"}},
+		"Successfully_look_up_user_with_StartTLS":     {name: "alice", startTLS: true, want: Account{UID: 15000, GID: 15000, Home: "/home/alice"}},
+		"Successfully_look_up_user_with_bind":         {name: "alice", bindDN: testBindDN, password: testPassword + "\n", want: Account{UID: 15000, GID: 15000, Home: "/home/alice"}},
+		"Successfully_look_up_user_with_other_attr":   {name: "alice", userAttribute: "mail", want: Account{UID: 15000, GID: 15000, Home: "/home/alice"}},
+		"Attribute_names_are_case_insensitive":        {name: "bob", want: Account{UID: 15001, GID: 100, Home: "/srv/home/bob"}},
+		"Home_directory_is_optional":                  {name: "no-home", want: Account{UID: 15002, GID: 15002}},
+		"Error_when_user_has_no_account":              {name: "carol", wantErr: true, wantNotUser: true},
+		"Error_when_user_has_several_accounts":        {name: "twice", wantErr: true},
+		"Error_when_uidNumber_is_invalid":             {name: "invalid-uid", wantErr: true},
+		"Error_when_uidNumber_is_the_one_of_root":     {name: "root-uid", wantErr: true},
+		"Error_when_gidNumber_is_missing":             {name: "no-gid", wantErr: true},
+		"Error_when_home_directory_is_relative":       {name: "relative-dir", wantErr: true},
+		"Error_when_bind_password_is_wrong":           {name: "alice", bindDN: testBindDN, password: "wrong", wantErr: true},
+		"Error_when_bind_password_file_is_missing":    {name: "alice", bindDN: testBindDN, wantErr: true},
+		"Error_when_server_certificate_is_not_signed": {name: "alice", noCAFile: true, wantErr: true},
+		"Error_when_server_is_unreachable":            {name: "alice", noServer: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			url, caFile := startServer(t, accounts, tc.startTLS)
+			cfg := Config{URL: url, CAFile: caFile, BaseDN: testBaseDN, BindDN: tc.bindDN, UserAttribute: "uid"}
+			if tc.userAttribute != "" {
+				cfg.UserAttribute = tc.userAttribute
+			}
+			if tc.bindDN != "" {
+				cfg.BindPasswordFile = filepath.Join(t.TempDir(), "password")
+				if tc.password != "" {
+					require.NoError(t, os.WriteFile(cfg.BindPasswordFile, []byte(tc.password), 0600), "Setup: could not write password file")
+				}
+			}
+			if tc.noCAFile {
+				cfg.CAFile = ""
+			}
+			if tc.noServer {
+				cfg.URL = "ldaps://127.0.0.1:1"
+			}
+			require.NoError(t, cfg.Validate(), "Setup: configuration should be valid")
+
+			got, err := NewClient(cfg).LookupUser(context.Background(), tc.name)
+			if tc.wantErr {
+				require.Error(t, err, "LookupUser should have failed")
+				require.Equal(t, tc.wantNotUser, err == ErrNoSuchUser, "LookupUser should return ErrNoSuchUser only if the user has no account")
+				return
+			}
+			require.NoError(t, err, "LookupUser should not have failed")
+			require.Equal(t, tc.want, got, "LookupUser should return the account of the user")
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := Config{URL: "ldaps://ldap.example.com", BaseDN: testBaseDN, UserAttribute: "uid"}
+
+	tests := map[string]struct {
+		modify func(*Config)
+
+		wantErr bool
+	}{
+		"Valid_configuration":          {},
+		"Valid_with_StartTLS_and_port": {modify: func(c *Config) { c.URL = "ldap://ldap.example.com:1389" }},
+		"Valid_with_bind":              {modify: func(c *Config) { c.BindDN, c.BindPasswordFile = testBindDN, "/etc/authd/ldap.password" }},
+		"Valid_when_disabled":          {modify: func(c *Config) { *c = DefaultConfig }},
+
+		"Error_on_unsupported_scheme":         {modify: func(c *Config) { c.URL = "https://ldap.example.com" }, wantErr: true},
+		"Error_on_URL_without_host":           {modify: func(c *Config) { c.URL = "ldaps:///dc=example,dc=com" }, wantErr: true},
+		"Error_on_URL_with_DN":                {modify: func(c *Config) { c.URL = "ldaps://ldap.example.com/dc=example,dc=com" }, wantErr: true},
+		"Error_on_invalid_URL":                {modify: func(c *Config) { c.URL = "ldaps://ldap.example.com:port" }, wantErr: true},
+		"Error_without_base_DN":               {modify: func(c *Config) { c.BaseDN = "" }, wantErr: true},
+		"Error_without_user_attribute":        {modify: func(c *Config) { c.UserAttribute = "" }, wantErr: true},
+		"Error_on_bind_without_password_file": {modify: func(c *Config) { c.BindDN = testBindDN }, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := valid
+			if tc.modify != nil {
+				tc.modify(&cfg)
+			}
+			err := cfg.Validate()
+			if tc.wantErr {
+				require.Error(t, err, "Validate should have failed")
+				return
+			}
+			require.NoError(t, err, "Validate should not have failed")
+		})
+	}
+}
+
+func TestReadPacket(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		data []byte
+
+		want    *packet
+		wantErr bool
+	}{
+		"Short_length": {data: []byte{0x04, 0x02, 'h', 'i'}, want: octetString("hi")},
+		// Some servers always use lengths on 4 bytes.
+		"Non_minimal_long_length": {data: []byte{0x30, 0x84, 0, 0, 0, 3, 0x02, 0x01, 0x05}, want: sequence(integer(tagInteger, 5))},
+		"Long_length": {
+			data: append([]byte{0x04, 0x81, 0x80}, bytes.Repeat([]byte{'a'}, 0x80)...),
+			want: octetString(string(bytes.Repeat([]byte{'a'}, 0x80))),
+		},
+		"Nested_packet": {data: nestedSequences(maxDepth).bytes(), want: nestedSequences(maxDepth)},
+
+		"Error_on_too_deeply_nested_packet": {data: nestedSequences(maxDepth + 1).bytes(), wantErr: true},
+		"Error_on_indefinite_length":        {data: []byte{0x30, 0x80, 0x00, 0x00}, wantErr: true},
+		"Error_on_too_large_packet":         {data: []byte{0x04, 0x84, 0x10, 0, 0, 0}, wantErr: true},
+		"Error_on_truncated_content":        {data: []byte{0x04, 0x05, 'h', 'i'}, wantErr: true},
+		"Error_on_truncated_length":         {data: []byte{0x04, 0x82, 0x01}, wantErr: true},
+		"Error_on_truncated_child":          {data: []byte{0x30, 0x02, 0x04, 0x05}, wantErr: true},
+		"Error_on_high_tag_number":          {data: []byte{0x1f, 0x01, 0x00}, wantErr: true},
+		"Error_on_length_on_many_bytes":     {data: []byte{0x04, 0x85, 0, 0, 0, 0, 1, 'a'}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := readPacket(bufio.NewReader(bytes.NewReader(tc.data)))
+			if tc.wantErr {
+				require.Error(t, err, "readPacket should have failed")
+				return
+			}
+			require.NoError(t, err, "readPacket should not have failed")
+			require.Equal(t, tc.want.bytes(), got.bytes(), "readPacket should return the encoded packet")
+		})
+	}
+}
+
+// nestedSequences returns depth sequences nested in each other.
+func nestedSequences(depth int) *packet {
+	p := octetString("leaf")
+	for range depth - 1 {
+		p = sequence(p)
+	}
+	return p
+}
+
+func TestInteger(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range []int64{0, 1, 127, 128, 255, 256, 15000, -1, -128, -129, 1 << 40} {
+		got, err := integer(tagInteger, n).int()
+		require.NoError(t, err, "int should not have failed for %d", n)
+		require.Equal(t, n, got, "int should return the encoded integer")
+	}
+	require.Equal(t, []byte{0x02, 0x02, 0x00, 0x80}, integer(tagInteger, 128).bytes(), "128 should be encoded on 2 bytes")
+}
+
+// startServer starts an LDAP server serving the accounts, indexed by the value of the user attribute. It returns its
+// URL and the path of the certificate of its authority.
+func startServer(t *testing.T, accounts map[string][]map[string][]string, startTLS bool) (url, caFile string) {
+	t.Helper()
+
+	cert, caFile := newCertificate(t)
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Setup: could not listen")
+	t.Cleanup(func() { _ = lis.Close() })
+
+	go func() {
+		for {
+			c, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go serve(c, tlsConfig, accounts, startTLS)
+		}
+	}()
+
+	scheme := "ldaps"
+	if startTLS {
+		scheme = "ldap"
+	}
+	return scheme + "://" + lis.Addr().String(), caFile
+}
+
+func serve(c net.Conn, tlsConfig *tls.Config, accounts map[string][]map[string][]string, startTLS bool) {
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var rw io.ReadWriter = c
+	if !startTLS {
+		rw = tls.Server(c, tlsConfig)
+	}
+	r := bufio.NewReader(rw)
+
+	reply := func(id int64, op *packet) {
+		_, _ = rw.Write(sequence(integer(tagInteger, id), op).bytes())
+	}
+	result := func(op byte, code int64) *packet {
+		return constructed(classApplication, op, integer(tagEnumerated, code), octetString(""), octetString(""))
+	}
+
+	for {
+		msg, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		id, _ := msg.children[0].int()
+		op := msg.children[1]
+
+		switch op.tag {
+		case opExtendedRequest:
+			reply(id, result(opExtendedResponse, resultSuccess))
+			tlsConn := tls.Server(c, tlsConfig)
+			rw, r = tlsConn, bufio.NewReader(tlsConn)
+
+		case opBindRequest:
+			code := int64(resultSuccess)
+			if string(op.children[1].value) != testBindDN || string(op.children[2].value) != testPassword {
+				// invalidCredentials
+				code = 49
+			}
+			reply(id, result(opBindResponse, code))
+
+		case opSearchRequest:
+			if string(op.children[0].value) != testBaseDN {
+				// noSuchObject
+				reply(id, result(opSearchResultDone, 32))
+				continue
+			}
+			// The filter is (&(objectClass=posixAccount)(<user attribute>=<name>)).
+			name := string(op.children[6].children[1].children[1].value)
+			code := int64(resultSuccess)
+			entries := accounts[name]
+			if len(entries) > 2 {
+				entries, code = entries[:2], resultSizeLimitExceeded
+			}
+			for _, e := range entries {
+				var attrs []*packet
+				for attr, values := range e {
+					var vals []*packet
+					for _, v := range values {
+						vals = append(vals, octetString(v))
+					}
+					attrs = append(attrs, sequence(octetString(attr), constructed(classUniversal, tagSet, vals...)))
+				}
+				reply(id, constructed(classApplication, opSearchResultEntry, octetString("uid="+name+","+testBaseDN), sequence(attrs...)))
+			}
+			reply(id, result(opSearchResultDone, code))
+
+		case opUnbindRequest:
+			return
+		}
+	}
+}
+
+// newCertificate returns a certificate for 127.0.0.1, signed by itself, and the path of the file containing it.
+func newCertificate(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Setup: could not generate key")
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "Setup: could not create certificate")
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600),
+		"Setup: could not write certificate")
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, path
+}
@@ -0,0 +1,538 @@
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/canonical/authd/internal/decorate"
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+// The operations which the server rejects, as it's read-only. Their responses are the next tags.
+const (
+	opModifyRequest  = 6
+	opAddRequest     = 8
+	opDelRequest     = 10
+	opModDNRequest   = 12
+	opCompareRequest = 14
+	opAbandonRequest = 16
+)
+
+// The result codes returned by the server.
+const (
+	resultOperationsError             = 1
+	resultProtocolError               = 2
+	resultNoSuchObject                = 32
+	resultInappropriateAuthentication = 48
+	resultUnwillingToPerform          = 53
+)
+
+// The filters of the search requests, which are tags of the context-specific class.
+const (
+	filterAnd            = 0
+	filterOr             = 1
+	filterNot            = 2
+	filterEqualityMatch  = 3
+	filterSubstrings     = 4
+	filterGreaterOrEqual = 5
+	filterLessOrEqual    = 6
+	filterPresent        = 7
+	filterApproxMatch    = 8
+)
+
+// The scopes of the search requests.
+const (
+	scopeBaseObject   = 0
+	scopeSingleLevel  = 1
+	scopeWholeSubtree = 2
+)
+
+// idleTimeout is the duration after which the connections without requests are closed.
+const idleTimeout = 5 * time.Minute
+
+// UserDatabase is the database of the users and groups served by the LDAP server.
+type UserDatabase interface {
+	AllUsers() ([]types.UserEntry, error)
+	AllGroups() ([]types.GroupEntry, error)
+}
+
+// Server is a read-only LDAP server serving the users of the database as posixAccount entries under
+// ou=people,<base DN> and its groups as posixGroup entries under ou=groups,<base DN>. Only anonymous binds are
+// accepted, as the users and groups can be looked up by anyone on the machine anyway.
+type Server struct {
+	db     UserDatabase
+	baseDN string
+
+	conns   map[net.Conn]struct{}
+	connsMu sync.Mutex
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewServer returns a server of the users and groups of the database under the base DN.
+func NewServer(db UserDatabase, baseDN string) *Server {
+	return &Server{db: db, baseDN: baseDN, conns: make(map[net.Conn]struct{})}
+}
+
+// Serve serves LDAP, or LDAPS if a certificate is configured, on the TCP address of the configuration, until the
+// returned function is called.
+func Serve(ctx context.Context, db UserDatabase, cfg users.LDAPServerConfig) (stop func(), err error) {
+	defer decorate.OnError(&err, "could not serve LDAP on %q", cfg.Listen)
+
+	var lis net.Listener
+	if cfg.TLS() {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load the certificate of the LDAP server: %w", err)
+		}
+		lis, err = tls.Listen("tcp", cfg.Listen, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+		if err != nil {
+			return nil, err
+		}
+	} else if lis, err = net.Listen("tcp", cfg.Listen); err != nil {
+		return nil, err
+	}
+
+	s := NewServer(db, cfg.BaseDN)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := s.Serve(ctx, lis); err != nil {
+			log.Errorf(ctx, "Stopped serving LDAP: %v", err)
+		}
+	}()
+
+	log.Infof(ctx, "Serving the users and groups over LDAP on %s under %q", lis.Addr(), cfg.BaseDN)
+	return func() {
+		s.Close()
+		_ = lis.Close()
+		<-done
+	}, nil
+}
+
+// Serve accepts the connections on lis and serves them, until the server is closed.
+func (s *Server) Serve(ctx context.Context, lis net.Listener) error {
+	for {
+		c, err := lis.Accept()
+		if err != nil {
+			s.connsMu.Lock()
+			closed := s.closed
+			s.connsMu.Unlock()
+			if closed || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		s.connsMu.Lock()
+		if s.closed {
+			s.connsMu.Unlock()
+			c.Close()
+			return nil
+		}
+		s.conns[c] = struct{}{}
+		s.wg.Add(1)
+		s.connsMu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.connsMu.Lock()
+				delete(s.conns, c)
+				s.connsMu.Unlock()
+				c.Close()
+			}()
+			if err := s.serveConn(c); err != nil {
+				log.Debugf(ctx, "Closed LDAP connection from %s: %v", c.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// Close closes the connections and waits for them to be done. The listener must be closed by the caller.
+func (s *Server) Close() {
+	s.connsMu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.connsMu.Unlock()
+	s.wg.Wait()
+}
+
+// serveConn serves the requests of the connection one at a time, until it's closed or unbound.
+func (s *Server) serveConn(c net.Conn) error {
+	r := bufio.NewReader(c)
+	for {
+		if err := c.SetReadDeadline(time.Now().Add(idleTimeout)); err != nil {
+			return err
+		}
+		msg, err := readPacket(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !msg.is(classUniversal, tagSequence) || len(msg.children) < 2 {
+			return errors.New("invalid message")
+		}
+		id, err := msg.children[0].int()
+		if err != nil {
+			return fmt.Errorf("invalid message: %w", err)
+		}
+
+		op := msg.children[1]
+		if op.class != classApplication {
+			return errors.New("invalid operation")
+		}
+		var responses []*packet
+		switch op.tag {
+		case opUnbindRequest:
+			return nil
+		case opAbandonRequest:
+			// The requests are answered before the next one is read, so there is nothing to abandon.
+			continue
+		case opBindRequest:
+			responses = []*packet{s.bind(op)}
+		case opSearchRequest:
+			responses = s.search(op)
+		case opModifyRequest, opAddRequest, opDelRequest, opModDNRequest, opCompareRequest:
+			responses = []*packet{ldapResult(op.tag+1, resultUnwillingToPerform, "the directory is read-only")}
+		case opExtendedRequest:
+			responses = []*packet{ldapResult(opExtendedResponse, resultProtocolError, "extended operations are not supported")}
+		default:
+			return fmt.Errorf("unsupported operation %d", op.tag)
+		}
+
+		for _, resp := range responses {
+			if _, err := c.Write(sequence(integer(tagInteger, id), resp).bytes()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ldapResult returns a response of the operation with the result code and the diagnostic message.
+func ldapResult(op byte, code int64, msg string) *packet {
+	return constructed(classApplication, op, integer(tagEnumerated, code), octetString(""), octetString(msg))
+}
+
+func (s *Server) bind(op *packet) *packet {
+	if len(op.children) != 3 {
+		return ldapResult(opBindResponse, resultProtocolError, "invalid bind request")
+	}
+	// Only the anonymous simple binds are accepted, with or without a name.
+	if auth := op.children[2]; !auth.is(classContext, 0) || len(auth.value) > 0 {
+		return ldapResult(opBindResponse, resultInappropriateAuthentication, "only anonymous binds are supported")
+	}
+	return ldapResult(opBindResponse, resultSuccess, "")
+}
+
+// search returns the entries matching the search request, followed by the result of the search.
+func (s *Server) search(op *packet) []*packet {
+	if len(op.children) != 8 {
+		return []*packet{ldapResult(opSearchResultDone, resultProtocolError, "invalid search request")}
+	}
+	base := normalizeDN(string(op.children[0].value))
+	scope, err := op.children[1].int()
+	if err != nil {
+		return []*packet{ldapResult(opSearchResultDone, resultProtocolError, "invalid scope")}
+	}
+	sizeLimit, err := op.children[3].int()
+	if err != nil {
+		return []*packet{ldapResult(opSearchResultDone, resultProtocolError, "invalid size limit")}
+	}
+	typesOnly := len(op.children[5].value) == 1 && op.children[5].value[0] != 0
+	filter := op.children[6]
+	var attributes []string
+	for _, a := range op.children[7].children {
+		attributes = append(attributes, string(a.value))
+	}
+
+	var entries []entry
+	if base == "" && scope == scopeBaseObject {
+		entries = []entry{s.rootDSE()}
+	} else {
+		all, err := s.entries()
+		if err != nil {
+			log.Errorf(context.Background(), "Could not list the entries of the LDAP server: %v", err)
+			return []*packet{ldapResult(opSearchResultDone, resultOperationsError, "could not list the entries")}
+		}
+		if !slices.ContainsFunc(all, func(e entry) bool { return normalizeDN(e.dn) == base }) {
+			return []*packet{ldapResult(opSearchResultDone, resultNoSuchObject, "")}
+		}
+		for _, e := range all {
+			if inScope(normalizeDN(e.dn), base, scope) {
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	var responses []*packet
+	for _, e := range entries {
+		ok, err := matches(filter, e)
+		if err != nil {
+			return []*packet{ldapResult(opSearchResultDone, resultProtocolError, err.Error())}
+		}
+		if !ok {
+			continue
+		}
+		if sizeLimit > 0 && int64(len(responses)) == sizeLimit {
+			return append(responses, ldapResult(opSearchResultDone, resultSizeLimitExceeded, ""))
+		}
+		responses = append(responses, searchResultEntry(e, attributes, typesOnly))
+	}
+	return append(responses, ldapResult(opSearchResultDone, resultSuccess, ""))
+}
+
+// rootDSE returns the entry describing the server.
+func (s *Server) rootDSE() entry {
+	return entry{attributes: map[string][]string{
+		"objectClass":          {"top"},
+		"namingContexts":       {s.baseDN},
+		"supportedLDAPVersion": {"3"},
+	}}
+}
+
+// entries returns all the entries of the directory: the base, the organizational units of the users and groups, and
+// the users and groups themselves.
+func (s *Server) entries() ([]entry, error) {
+	usrs, err := s.db.AllUsers()
+	if err != nil {
+		return nil, err
+	}
+	grps, err := s.db.AllGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	rdnAttr, rdnValue, _ := strings.Cut(strings.Split(s.baseDN, ",")[0], "=")
+	peopleDN, groupsDN := "ou=people,"+s.baseDN, "ou=groups,"+s.baseDN
+	entries := []entry{
+		{dn: s.baseDN, attributes: map[string][]string{"objectClass": {"top"}, strings.TrimSpace(rdnAttr): {strings.TrimSpace(rdnValue)}}},
+		{dn: peopleDN, attributes: map[string][]string{"objectClass": {"top", "organizationalUnit"}, "ou": {"people"}}},
+		{dn: groupsDN, attributes: map[string][]string{"objectClass": {"top", "organizationalUnit"}, "ou": {"groups"}}},
+	}
+	for _, u := range usrs {
+		entries = append(entries, entry{
+			dn: "uid=" + escapeDNValue(u.Name) + "," + peopleDN,
+			attributes: map[string][]string{
+				"objectClass":   {"top", "account", "posixAccount"},
+				"uid":           {u.Name},
+				"cn":            {u.Name},
+				"uidNumber":     {strconv.FormatUint(uint64(u.UID), 10)},
+				"gidNumber":     {strconv.FormatUint(uint64(u.GID), 10)},
+				"homeDirectory": {u.Dir},
+				"loginShell":    {u.Shell},
+				"gecos":         {u.Gecos},
+			},
+		})
+	}
+	for _, g := range grps {
+		attributes := map[string][]string{
+			"objectClass": {"top", "posixGroup"},
+			"cn":          {g.Name},
+			"gidNumber":   {strconv.FormatUint(uint64(g.GID), 10)},
+		}
+		if len(g.Users) > 0 {
+			attributes["memberUid"] = g.Users
+		}
+		entries = append(entries, entry{dn: "cn=" + escapeDNValue(g.Name) + "," + groupsDN, attributes: attributes})
+	}
+	return entries, nil
+}
+
+// searchResultEntry returns the response with the entry and the requested attributes, all of them if none is
+// requested or if "*" is.
+func searchResultEntry(e entry, requested []string, typesOnly bool) *packet {
+	all := len(requested) == 0 || slices.Contains(requested, "*")
+
+	var names []string
+	for name := range e.attributes {
+		if all || slices.ContainsFunc(requested, func(r string) bool { return strings.EqualFold(r, name) }) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var attrs []*packet
+	for _, name := range names {
+		var values []*packet
+		if !typesOnly {
+			for _, v := range e.attributes[name] {
+				values = append(values, octetString(v))
+			}
+		}
+		attrs = append(attrs, sequence(octetString(name), constructed(classUniversal, tagSet, values...)))
+	}
+	return constructed(classApplication, opSearchResultEntry, octetString(e.dn), sequence(attrs...))
+}
+
+// values returns the values of the attribute, whose name is case insensitive.
+func (e entry) values(attr string) []string {
+	for name, values := range e.attributes {
+		if strings.EqualFold(name, attr) {
+			return values
+		}
+	}
+	return nil
+}
+
+// matches returns true if the entry matches the filter. The values are compared case insensitively, and as numbers
+// when ordered.
+func matches(f *packet, e entry) (bool, error) {
+	if f.class != classContext {
+		return false, errors.New("invalid filter")
+	}
+
+	switch f.tag {
+	case filterAnd, filterOr:
+		for _, child := range f.children {
+			ok, err := matches(child, e)
+			if err != nil {
+				return false, err
+			}
+			if ok == (f.tag == filterOr) {
+				return ok, nil
+			}
+		}
+		return f.tag == filterAnd, nil
+	case filterNot:
+		if len(f.children) != 1 {
+			return false, errors.New("invalid not filter")
+		}
+		ok, err := matches(f.children[0], e)
+		return !ok, err
+	case filterPresent:
+		return len(e.values(string(f.value))) > 0, nil
+	case filterEqualityMatch, filterApproxMatch, filterGreaterOrEqual, filterLessOrEqual:
+		if len(f.children) != 2 {
+			return false, errors.New("invalid attribute value assertion")
+		}
+		want := string(f.children[1].value)
+		return slices.ContainsFunc(e.values(string(f.children[0].value)), func(v string) bool {
+			c := compareValues(v, want)
+			switch f.tag {
+			case filterGreaterOrEqual:
+				return c >= 0
+			case filterLessOrEqual:
+				return c <= 0
+			default:
+				return c == 0
+			}
+		}), nil
+	case filterSubstrings:
+		if len(f.children) != 2 {
+			return false, errors.New("invalid substrings filter")
+		}
+		return slices.ContainsFunc(e.values(string(f.children[0].value)), func(v string) bool {
+			return matchesSubstrings(strings.ToLower(v), f.children[1].children)
+		}), nil
+	default:
+		// The extensible matches are not supported, so they match nothing.
+		return false, nil
+	}
+}
+
+// compareValues compares the values as numbers if both are, or as case insensitive strings otherwise.
+func compareValues(a, b string) int {
+	x, errA := strconv.ParseInt(a, 10, 64)
+	y, errB := strconv.ParseInt(b, 10, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// matchesSubstrings returns true if the lowercase value matches the initial, any and final substrings.
+func matchesSubstrings(v string, substrings []*packet) bool {
+	for i, sub := range substrings {
+		s := strings.ToLower(string(sub.value))
+		switch {
+		case sub.tag == 0 && i == 0:
+			if !strings.HasPrefix(v, s) {
+				return false
+			}
+			v = v[len(s):]
+		case sub.tag == 2 && i == len(substrings)-1:
+			return strings.HasSuffix(v, s)
+		default:
+			idx := strings.Index(v, s)
+			if idx < 0 {
+				return false
+			}
+			v = v[idx+len(s):]
+		}
+	}
+	return true
+}
+
+// inScope returns true if the normalized DN is in the scope of the search from the normalized base DN.
+func inScope(dn, base string, scope int64) bool {
+	switch scope {
+	case scopeBaseObject:
+		return dn == base
+	case scopeSingleLevel:
+		_, parent, ok := cutRDN(dn)
+		return ok && parent == base
+	case scopeWholeSubtree:
+		return dn == base || strings.HasSuffix(dn, ","+base)
+	}
+	return false
+}
+
+// cutRDN returns the first RDN of the DN and the DN of its parent.
+func cutRDN(dn string) (rdn, parent string, ok bool) {
+	for i := 0; i < len(dn); i++ {
+		switch dn[i] {
+		case '\\':
+			i++
+		case ',':
+			return dn[:i], dn[i+1:], true
+		}
+	}
+	return dn, "", false
+}
+
+// normalizeDN returns the DN in lowercase, without the spaces around its separators, so that it can be compared.
+func normalizeDN(dn string) string {
+	var rdns []string
+	for dn != "" {
+		rdn, parent, _ := cutRDN(dn)
+		attr, value, _ := strings.Cut(rdn, "=")
+		rdns = append(rdns, strings.TrimSpace(attr)+"="+strings.TrimSpace(value))
+		dn = parent
+	}
+	return strings.ToLower(strings.Join(rdns, ","))
+}
+
+// escapeDNValue escapes the special characters of a value of a DN.
+func escapeDNValue(v string) string {
+	var b strings.Builder
+	for i, r := range v {
+		if strings.ContainsRune(`,+"\<>;=`, r) || (i == 0 && (r == ' ' || r == '#')) || (i == len(v)-1 && r == ' ') {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,266 @@
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/stretchr/testify/require"
+)
+
+const testServerBaseDN = "dc=example,dc=com"
+
+type testUserDatabase struct{}
+
+func (testUserDatabase) AllUsers() ([]types.UserEntry, error) {
+	return []types.UserEntry{
+		{Name: "alice@example.com", UID: 15000, GID: 15000, Gecos: "Alice", Dir: "/home/alice@example.com", Shell: "/bin/bash"},
+		{Name: "bob@example.com", UID: 15001, GID: 15001, Gecos: "Bob", Dir: "/home/bob@example.com", Shell: "/bin/zsh"},
+	}, nil
+}
+
+func (testUserDatabase) AllGroups() ([]types.GroupEntry, error) {
+	return []types.GroupEntry{
+		{Name: "alice@example.com", GID: 15000, Users: []string{"alice@example.com"}},
+		{Name: "bob@example.com", GID: 15001, Users: []string{"bob@example.com"}},
+		{Name: "developers", GID: 16000, Users: []string{"alice@example.com", "bob@example.com"}},
+	}, nil
+}
+
+func TestServerSearch(t *testing.T) {
+	t.Parallel()
+
+	present := func(attr string) *packet { return primitive(classContext, filterPresent, []byte(attr)) }
+	or := func(filters ...*packet) *packet { return constructed(classContext, filterOr, filters...) }
+	not := func(filter *packet) *packet { return constructed(classContext, filterNot, filter) }
+	greaterOrEqual := func(attr, value string) *packet {
+		return constructed(classContext, filterGreaterOrEqual, octetString(attr), octetString(value))
+	}
+	substrings := func(attr string, subs ...*packet) *packet {
+		return constructed(classContext, filterSubstrings, octetString(attr), sequence(subs...))
+	}
+	initial := func(s string) *packet { return primitive(classContext, 0, []byte(s)) }
+	final := func(s string) *packet { return primitive(classContext, 2, []byte(s)) }
+
+	people := "ou=people," + testServerBaseDN
+	groups := "ou=groups," + testServerBaseDN
+
+	tests := map[string]struct {
+		base       string
+		scope      int64
+		filter     *packet
+		attributes []string
+		sizeLimit  int64
+
+		wantDNs        []string
+		wantAttributes map[string][]string
+		wantCode       int64
+	}{
+		"Search_user_by_uid": {
+			base: testServerBaseDN, scope: scopeWholeSubtree,
+			filter:  and(equal("objectClass", "posixAccount"), equal("uid", "Alice@Example.com")),
+			wantDNs: []string{"uid=alice@example.com," + people},
+			wantAttributes: map[string][]string{
+				"objectClass": {"top", "account", "posixAccount"}, "uid": {"alice@example.com"}, "cn": {"alice@example.com"},
+				"uidNumber": {"15000"}, "gidNumber": {"15000"}, "homeDirectory": {"/home/alice@example.com"},
+				"loginShell": {"/bin/bash"}, "gecos": {"Alice"},
+			},
+		},
+		"Search_user_by_uidNumber_with_requested_attributes": {
+			base: people, scope: scopeSingleLevel,
+			filter: equal("uidNumber", "15001"), attributes: []string{"UID", "loginShell"},
+			wantDNs:        []string{"uid=bob@example.com," + people},
+			wantAttributes: map[string][]string{"uid": {"bob@example.com"}, "loginShell": {"/bin/zsh"}},
+		},
+		"Search_groups_of_member": {
+			base: groups, scope: scopeSingleLevel,
+			filter:         and(equal("objectClass", "posixGroup"), equal("memberUid", "bob@example.com")),
+			attributes:     []string{"cn"},
+			wantDNs:        []string{"cn=bob@example.com," + groups, "cn=developers," + groups},
+			wantAttributes: map[string][]string{"cn": {"bob@example.com"}},
+		},
+		"Search_all_entries": {
+			base: testServerBaseDN, scope: scopeWholeSubtree, filter: present("objectClass"),
+			wantDNs: []string{
+				testServerBaseDN, people, groups,
+				"uid=alice@example.com," + people, "uid=bob@example.com," + people,
+				"cn=alice@example.com," + groups, "cn=bob@example.com," + groups, "cn=developers," + groups,
+			},
+			wantAttributes: map[string][]string{"objectClass": {"top"}, "dc": {"example"}},
+		},
+		"Search_base_object_with_spaces_and_uppercase_in_DN": {
+			base: "OU=People, DC=Example, DC=Com", scope: scopeBaseObject, filter: present("objectClass"),
+			wantDNs: []string{people},
+		},
+		"Search_with_or_and_not_filters": {
+			base: testServerBaseDN, scope: scopeWholeSubtree,
+			filter:  and(equal("objectClass", "posixGroup"), or(equal("cn", "developers"), equal("cn", "alice@example.com")), not(equal("gidNumber", "15000"))),
+			wantDNs: []string{"cn=developers," + groups},
+		},
+		"Search_with_ordering_filter_comparing_numbers": {
+			base: groups, scope: scopeSingleLevel, filter: greaterOrEqual("gidNumber", "15001"), attributes: []string{"1.1"},
+			wantDNs: []string{"cn=bob@example.com," + groups, "cn=developers," + groups},
+		},
+		"Search_with_substrings_filter": {
+			base: people, scope: scopeSingleLevel, filter: substrings("uid", initial("ALI"), final("@example.com")),
+			wantDNs: []string{"uid=alice@example.com," + people},
+		},
+		"Search_root_DSE": {
+			scope: scopeBaseObject, filter: present("objectClass"), attributes: []string{"namingContexts"},
+			wantDNs:        []string{""},
+			wantAttributes: map[string][]string{"namingContexts": {testServerBaseDN}},
+		},
+		"Search_with_size_limit": {
+			base: people, scope: scopeSingleLevel, filter: present("uid"), sizeLimit: 1,
+			wantDNs: []string{"uid=alice@example.com," + people}, wantCode: resultSizeLimitExceeded,
+		},
+
+		"Error_when_base_does_not_exist": {
+			base: "dc=other,dc=com", scope: scopeWholeSubtree, filter: present("objectClass"),
+			wantCode: resultNoSuchObject,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := connectToServer(t)
+			attrs := []*packet{}
+			for _, a := range tc.attributes {
+				attrs = append(attrs, octetString(a))
+			}
+			id, err := c.send(constructed(classApplication, opSearchRequest,
+				octetString(tc.base), integer(tagEnumerated, tc.scope), integer(tagEnumerated, 0),
+				integer(tagInteger, tc.sizeLimit), integer(tagInteger, 0), boolean(false), tc.filter, sequence(attrs...)))
+			require.NoError(t, err, "Sending the search request should not fail")
+
+			var entries []entry
+			for {
+				resp, err := c.receive(id)
+				require.NoError(t, err, "Receiving the search responses should not fail")
+				if resp.is(classApplication, opSearchResultEntry) {
+					e, err := parseEntry(resp)
+					require.NoError(t, err, "Search result entry should be valid")
+					entries = append(entries, e)
+					continue
+				}
+				require.True(t, resp.is(classApplication, opSearchResultDone), "Search should end with a result")
+				code, _, err := result(resp)
+				require.NoError(t, err, "Search result should be valid")
+				require.Equal(t, tc.wantCode, code, "Search should return the expected result code")
+				break
+			}
+
+			var dns []string
+			for _, e := range entries {
+				dns = append(dns, e.dn)
+			}
+			require.Equal(t, tc.wantDNs, dns, "Search should return the expected entries")
+			if tc.wantAttributes != nil {
+				require.Equal(t, tc.wantAttributes, entries[0].attributes, "Search should return the expected attributes")
+			}
+			if slices.Equal(tc.attributes, []string{"1.1"}) {
+				require.Empty(t, entries[0].attributes, "Search should return no attribute if 1.1 is requested")
+			}
+		})
+	}
+}
+
+func TestServerOperations(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		op         *packet
+		responseOp byte
+
+		wantCode int64
+	}{
+		"Bind_anonymously": {
+			op:         constructed(classApplication, opBindRequest, integer(tagInteger, 3), octetString(""), primitive(classContext, 0, nil)),
+			responseOp: opBindResponse,
+		},
+		"Bind_anonymously_with_name": {
+			op:         constructed(classApplication, opBindRequest, integer(tagInteger, 3), octetString("cn=app,dc=example,dc=com"), primitive(classContext, 0, nil)),
+			responseOp: opBindResponse,
+		},
+
+		"Error_when_binding_with_password": {
+			op:         constructed(classApplication, opBindRequest, integer(tagInteger, 3), octetString("cn=app,dc=example,dc=com"), primitive(classContext, 0, []byte("secret"))),
+			responseOp: opBindResponse, wantCode: resultInappropriateAuthentication,
+		},
+		"Error_when_modifying_entries": {
+			op:         constructed(classApplication, opDelRequest, octetString("uid=alice@example.com,ou=people,"+testServerBaseDN)),
+			responseOp: opDelRequest + 1, wantCode: resultUnwillingToPerform,
+		},
+		"Error_when_starting_TLS": {
+			op:         constructed(classApplication, opExtendedRequest, primitive(classContext, 0, []byte(startTLSOID))),
+			responseOp: opExtendedResponse, wantCode: resultProtocolError,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := connectToServer(t)
+			id, err := c.send(tc.op)
+			require.NoError(t, err, "Sending the request should not fail")
+			resp, err := c.receive(id)
+			require.NoError(t, err, "Receiving the response should not fail")
+			require.True(t, resp.is(classApplication, tc.responseOp), "Response should be the one of the operation")
+			code, _, err := result(resp)
+			require.NoError(t, err, "Result should be valid")
+			require.Equal(t, tc.wantCode, code, "Request should return the expected result code")
+		})
+	}
+}
+
+func TestServerWithClient(t *testing.T) {
+	t.Parallel()
+
+	cert, caFile := newCertificate(t)
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	require.NoError(t, err, "Setup: could not listen")
+	startTestServer(t, lis)
+
+	cfg := Config{URL: "ldaps://" + lis.Addr().String(), CAFile: caFile, BaseDN: testServerBaseDN, UserAttribute: "uid"}
+	got, err := NewClient(cfg).LookupUser(context.Background(), "bob@example.com")
+	require.NoError(t, err, "LookupUser should not have failed")
+	require.Equal(t, Account{UID: 15001, GID: 15001, Home: "/home/bob@example.com"}, got, "LookupUser should return the account served")
+
+	_, err = NewClient(cfg).LookupUser(context.Background(), "carol@example.com")
+	require.ErrorIs(t, err, ErrNoSuchUser, "LookupUser should not find a user which is not served")
+}
+
+// connectToServer starts a server of the test database and returns a connection to it.
+func connectToServer(t *testing.T) *conn {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Setup: could not listen")
+	startTestServer(t, lis)
+
+	c, err := net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err, "Setup: could not connect to the server")
+	t.Cleanup(func() { _ = c.Close() })
+	return &conn{Conn: c, r: bufio.NewReader(c)}
+}
+
+func startTestServer(t *testing.T, lis net.Listener) {
+	t.Helper()
+
+	s := NewServer(testUserDatabase{}, testServerBaseDN)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = s.Serve(context.Background(), lis)
+	}()
+	t.Cleanup(func() {
+		s.Close()
+		_ = lis.Close()
+		<-done
+	})
+}
@@ -0,0 +1,355 @@
+package loginpolicy
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+const (
+	// centralPolicyCacheFile is the name of the file, in the database directory, in which the last valid document of
+	// the central policy is stored, so that it still applies when the server can't be reached.
+	centralPolicyCacheFile = "central-policy.json"
+	// maxDocumentSize is the maximum size of the signed document served by the central server.
+	maxDocumentSize = 1 << 20
+	fetchTimeout    = 30 * time.Second
+)
+
+// CentralConfig configures the fetch of the login policy from a central server, so that the policy of a fleet of
+// machines can be changed without touching their configuration files.
+type CentralConfig struct {
+	// URL is the HTTPS URL serving the signed document of the policy. If empty, only the local policy applies.
+	URL string `mapstructure:"policy_url" yaml:"policy_url,omitempty"`
+	// PublicKey is the path of the PEM encoded Ed25519 public key which the document must be signed with.
+	PublicKey string `mapstructure:"policy_public_key" yaml:"policy_public_key,omitempty"`
+	// RefreshInterval is how often the document is fetched again.
+	RefreshInterval time.Duration `mapstructure:"policy_refresh_interval" yaml:"policy_refresh_interval,omitempty"`
+}
+
+// DefaultCentralConfig is the default configuration of the central policy, which is disabled.
+var DefaultCentralConfig = CentralConfig{RefreshInterval: time.Hour}
+
+// Enabled returns true if the policy is fetched from a central server.
+func (c CentralConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// Document is the login policy served by the central server. The settings which are set override the ones of the
+// local configuration.
+type Document struct {
+	// Serial identifies the version of the document. A document with a lower serial than the one applied is refused,
+	// so that an older document can't be replayed to restore a more permissive policy.
+	Serial        uint64    `json:"serial"`
+	AllowedGroups *[]string `json:"allowed_groups,omitempty"`
+	DeniedGroups  *[]string `json:"denied_groups,omitempty"`
+	LoginHours    *[]string `json:"login_hours,omitempty"`
+	// OfflineMaxAge is a duration like "72h".
+	OfflineMaxAge    *string `json:"offline_max_age,omitempty"`
+	OfflineMaxLogins *int    `json:"offline_max_logins,omitempty"`
+}
+
+// signedDocument is the format served by the central server: the JSON encoded Document and its Ed25519 signature,
+// both encoded in base64.
+type signedDocument struct {
+	Policy    []byte `json:"policy"`
+	Signature []byte `json:"signature"`
+}
+
+// apply returns the configuration with the settings of the document.
+func (d Document) apply(cfg Config) (Config, error) {
+	if d.AllowedGroups != nil {
+		cfg.AllowedGroups = *d.AllowedGroups
+	}
+	if d.DeniedGroups != nil {
+		cfg.DeniedGroups = *d.DeniedGroups
+	}
+	if d.LoginHours != nil {
+		cfg.LoginHours = *d.LoginHours
+	}
+	if d.OfflineMaxAge != nil {
+		maxAge, err := time.ParseDuration(*d.OfflineMaxAge)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid offline_max_age: %w", err)
+		}
+		cfg.OfflineMaxAge = maxAge
+	}
+	if d.OfflineMaxLogins != nil {
+		cfg.OfflineMaxLogins = *d.OfflineMaxLogins
+	}
+	return cfg, cfg.Validate()
+}
+
+// CentralStatus is the state of the central policy.
+type CentralStatus struct {
+	URL string
+	// Serial is the serial of the document applied. It's only meaningful if Applied is true.
+	Serial  uint64
+	Applied bool
+	// FetchedAt is the time of the last successful fetch, which is zero if the document applied is the cached one.
+	FetchedAt time.Time
+	// Error is the error of the last fetch, if it failed.
+	Error string
+}
+
+// Central fetches the login policy from a central server and keeps the last valid document.
+type Central struct {
+	cfg       CentralConfig
+	key       ed25519.PublicKey
+	cachePath string
+	client    *http.Client
+
+	mu        sync.RWMutex
+	doc       *Document
+	fetchedAt time.Time
+	fetchErr  error
+}
+
+// NewCentral returns a Central fetching the policy configured in cfg. The last valid document, stored in cacheDir,
+// applies until a newer one is fetched.
+func NewCentral(cfg CentralConfig, cacheDir string) (*Central, error) {
+	return newCentral(cfg, cacheDir, &http.Client{Timeout: fetchTimeout})
+}
+
+func newCentral(cfg CentralConfig, cacheDir string, client *http.Client) (*Central, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy_url: %w", err)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid policy_url %q: the policy must be served over HTTPS", cfg.URL)
+	}
+	if cfg.RefreshInterval <= 0 {
+		return nil, errors.New("policy_refresh_interval must be positive")
+	}
+
+	key, err := loadPublicKey(cfg.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Central{
+		cfg:       cfg,
+		key:       key,
+		cachePath: filepath.Join(cacheDir, centralPolicyCacheFile),
+		client:    client,
+	}
+
+	data, err := os.ReadFile(c.cachePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("could not read the cached central policy: %w", err)
+	}
+	if err == nil {
+		doc, err := c.verify(data)
+		if err != nil {
+			// The next fetch replaces it, until then the local policy applies.
+			log.Warningf(context.Background(), "Ignoring the cached central policy: %v", err)
+		} else {
+			c.doc = doc
+		}
+	}
+
+	return c, nil
+}
+
+func loadPublicKey(path string) (ed25519.PublicKey, error) {
+	if path == "" {
+		return nil, errors.New("policy_public_key is required with policy_url")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read policy_public_key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid policy_public_key %q: no PEM data found", path)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy_public_key %q: %w", path, err)
+	}
+	key, ok := pub.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid policy_public_key %q: not an Ed25519 key", path)
+	}
+	return key, nil
+}
+
+// verify checks the signature of the document and that its settings are valid.
+func (c *Central) verify(data []byte) (*Document, error) {
+	var signed signedDocument
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, fmt.Errorf("invalid signed document: %w", err)
+	}
+	if !ed25519.Verify(c.key, signed.Policy, signed.Signature) {
+		return nil, errors.New("invalid signature")
+	}
+
+	var doc Document
+	if err := json.Unmarshal(signed.Policy, &doc); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	if _, err := doc.apply(Config{}); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	return &doc, nil
+}
+
+// Run fetches the policy now and then every refresh interval, until the context is cancelled.
+func (c *Central) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Fetch(ctx); err != nil {
+			log.Warningf(ctx, "Could not fetch the central login policy from %s: %v", c.cfg.URL, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Fetch fetches the policy from the central server and applies it if it's newer than the one applied.
+func (c *Central) Fetch(ctx context.Context) (err error) {
+	defer func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.fetchErr = err
+	}()
+
+	data, err := c.download(ctx)
+	if err != nil {
+		return err
+	}
+	doc, err := c.verify(data)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.doc != nil && doc.Serial < c.doc.Serial {
+		return fmt.Errorf("refusing policy with serial %d, older than the applied one with serial %d", doc.Serial, c.doc.Serial)
+	}
+	c.fetchedAt = time.Now()
+	if c.doc != nil && doc.Serial == c.doc.Serial {
+		return nil
+	}
+
+	if err := writeCache(c.cachePath, data); err != nil {
+		// The policy still applies until authd stops.
+		log.Warningf(ctx, "Could not store the central login policy: %v", err)
+	}
+	c.doc = doc
+	log.Noticef(ctx, "Applied the central login policy with serial %d from %s", doc.Serial, c.cfg.URL)
+
+	return nil
+}
+
+func (c *Central) download(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDocumentSize {
+		return nil, fmt.Errorf("document larger than %d bytes", maxDocumentSize)
+	}
+	return data, nil
+}
+
+func writeCache(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// document returns the document applied, or nil if none was fetched yet.
+func (c *Central) document() *Document {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.doc
+}
+
+// Status returns the state of the central policy.
+func (c *Central) Status() CentralStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s := CentralStatus{URL: c.cfg.URL, FetchedAt: c.fetchedAt}
+	if c.doc != nil {
+		s.Serial, s.Applied = c.doc.Serial, true
+	}
+	if c.fetchErr != nil {
+		s.Error = c.fetchErr.Error()
+	}
+	return s
+}
+
+// Policy is the login policy of the machine: the local configuration, overridden by the document of the central
+// policy if any.
+type Policy struct {
+	local   Config
+	central *Central
+}
+
+// NewPolicy returns the login policy made of the local configuration and of the central policy, which can be nil.
+func NewPolicy(local Config, central *Central) *Policy {
+	return &Policy{local: local, central: central}
+}
+
+// Current returns the login policy which applies now.
+func (p *Policy) Current() Config {
+	if p.central == nil {
+		return p.local
+	}
+	doc := p.central.document()
+	if doc == nil {
+		return p.local
+	}
+
+	// The document was validated when it was fetched.
+	cfg, err := doc.apply(p.local)
+	if err != nil {
+		return p.local
+	}
+	return cfg
+}
+
+// Central returns the central policy or nil if the policy is only configured locally.
+func (p *Policy) Central() *Central {
+	return p.central
+}
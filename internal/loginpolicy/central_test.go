@@ -0,0 +1,319 @@
+package loginpolicy_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/loginpolicy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCentral(t *testing.T) {
+	t.Parallel()
+
+	pub, _ := newKey(t)
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Setup: could not generate ECDSA key")
+
+	tests := map[string]struct {
+		url             string
+		publicKey       []byte
+		noPublicKey     bool
+		refreshInterval time.Duration
+		cache           string
+		cacheIsDir      bool
+
+		wantErr bool
+	}{
+		"Success":                         {},
+		"Success_ignoring_invalid_cache":  {cache: "not a signed document"},
+		"Success_ignoring_unsigned_cache": {cache: `{"policy": "e30=", "signature": "e30="}`},
+
+		"Error_on_non_HTTPS_URL":          {url: "http://policy.example.com/policy.json", wantErr: true},
+		"Error_on_invalid_URL":            {url: "https://policy.example.com/%zz", wantErr: true},
+		"Error_on_missing_public_key":     {noPublicKey: true, wantErr: true},
+		"Error_on_public_key_not_PEM":     {publicKey: []byte("not a key"), wantErr: true},
+		"Error_on_invalid_public_key":     {publicKey: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("not a key")}), wantErr: true},
+		"Error_on_public_key_not_Ed25519": {publicKey: marshalPublicKey(t, &ecdsaKey.PublicKey), wantErr: true},
+		"Error_on_non_positive_refresh":   {refreshInterval: -1, wantErr: true},
+		"Error_on_unreadable_cache":       {cacheIsDir: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := loginpolicy.CentralConfig{URL: "https://policy.example.com/policy.json", RefreshInterval: time.Hour}
+			if tc.url != "" {
+				cfg.URL = tc.url
+			}
+			if tc.refreshInterval != 0 {
+				cfg.RefreshInterval = tc.refreshInterval
+			}
+			if !tc.noPublicKey {
+				if tc.publicKey == nil {
+					tc.publicKey = marshalPublicKey(t, pub)
+				}
+				cfg.PublicKey = writeFile(t, t.TempDir(), "key.pem", tc.publicKey)
+			}
+
+			cacheDir := t.TempDir()
+			if tc.cache != "" {
+				writeFile(t, cacheDir, loginpolicy.CentralPolicyCacheFile, []byte(tc.cache))
+			}
+			if tc.cacheIsDir {
+				require.NoError(t, os.Mkdir(filepath.Join(cacheDir, loginpolicy.CentralPolicyCacheFile), 0700), "Setup: could not create directory")
+			}
+
+			c, err := loginpolicy.NewCentral(cfg, cacheDir)
+			if tc.wantErr {
+				require.Error(t, err, "NewCentral should have failed")
+				return
+			}
+			require.NoError(t, err, "NewCentral should not have failed")
+			require.False(t, c.Status().Applied, "No policy should be applied before the first fetch")
+		})
+	}
+}
+
+func TestCentralFetch(t *testing.T) {
+	t.Parallel()
+
+	local := loginpolicy.Config{AllowedGroups: []string{"local-group"}, LoginHours: []string{"Mon-Fri 08:00-18:00"}}
+
+	tests := map[string]struct {
+		cached string
+		served string
+		// signedByOther signs the served document with another key.
+		signedByOther bool
+		httpStatus    int
+		tooLarge      bool
+
+		wantErr      bool
+		wantApplied  bool
+		wantSerial   uint64
+		wantPolicy   loginpolicy.Config
+		wantFetched  bool
+		wantNewCache bool
+	}{
+		"Apply_fetched_policy": {
+			served:       `{"serial": 1, "allowed_groups": ["admins"], "offline_max_age": "72h"}`,
+			wantApplied:  true,
+			wantSerial:   1,
+			wantPolicy:   loginpolicy.Config{AllowedGroups: []string{"admins"}, LoginHours: local.LoginHours, OfflineMaxAge: 72 * time.Hour},
+			wantFetched:  true,
+			wantNewCache: true,
+		},
+		"Apply_fetched_offline_logins_limit": {
+			served:       `{"serial": 1, "offline_max_logins": 5}`,
+			wantApplied:  true,
+			wantSerial:   1,
+			wantPolicy:   loginpolicy.Config{AllowedGroups: local.AllowedGroups, LoginHours: local.LoginHours, OfflineMaxLogins: 5},
+			wantFetched:  true,
+			wantNewCache: true,
+		},
+		"Apply_fetched_denied_groups": {
+			served:       `{"serial": 1, "denied_groups": ["contractors"]}`,
+			wantApplied:  true,
+			wantSerial:   1,
+			wantPolicy:   loginpolicy.Config{AllowedGroups: local.AllowedGroups, DeniedGroups: []string{"contractors"}, LoginHours: local.LoginHours},
+			wantFetched:  true,
+			wantNewCache: true,
+		},
+		"Apply_policy_clearing_local_settings": {
+			served:       `{"serial": 1, "allowed_groups": [], "login_hours": []}`,
+			wantApplied:  true,
+			wantSerial:   1,
+			wantPolicy:   loginpolicy.Config{AllowedGroups: []string{}, LoginHours: []string{}},
+			wantFetched:  true,
+			wantNewCache: true,
+		},
+		"Apply_newer_policy_than_cached": {
+			cached:       `{"serial": 1, "allowed_groups": ["admins"]}`,
+			served:       `{"serial": 2, "allowed_groups": ["devs"]}`,
+			wantApplied:  true,
+			wantSerial:   2,
+			wantPolicy:   loginpolicy.Config{AllowedGroups: []string{"devs"}, LoginHours: local.LoginHours},
+			wantFetched:  true,
+			wantNewCache: true,
+		},
+		"Keep_cached_policy_with_same_serial": {
+			cached:      `{"serial": 2, "allowed_groups": ["admins"]}`,
+			served:      `{"serial": 2, "allowed_groups": ["admins"]}`,
+			wantApplied: true,
+			wantSerial:  2,
+			wantPolicy:  loginpolicy.Config{AllowedGroups: []string{"admins"}, LoginHours: local.LoginHours},
+			wantFetched: true,
+		},
+
+		"Error_on_older_policy_than_cached": {
+			cached:      `{"serial": 3, "allowed_groups": ["admins"]}`,
+			served:      `{"serial": 2, "allowed_groups": ["everyone"]}`,
+			wantErr:     true,
+			wantApplied: true,
+			wantSerial:  3,
+			wantPolicy:  loginpolicy.Config{AllowedGroups: []string{"admins"}, LoginHours: local.LoginHours},
+		},
+		"Error_on_policy_signed_by_another_key": {
+			served:        `{"serial": 1, "allowed_groups": ["everyone"]}`,
+			signedByOther: true,
+			wantErr:       true,
+			wantPolicy:    local,
+		},
+		"Error_on_invalid_policy": {
+			served:     `{"serial": 1, "login_hours": ["whenever"]}`,
+			wantErr:    true,
+			wantPolicy: local,
+		},
+		"Error_on_invalid_offline_max_age": {
+			served:     `{"serial": 1, "offline_max_age": "3 days"}`,
+			wantErr:    true,
+			wantPolicy: local,
+		},
+		"Error_on_policy_not_JSON": {
+			served:     `not JSON`,
+			wantErr:    true,
+			wantPolicy: local,
+		},
+		"Error_on_HTTP_error_keeps_cached_policy": {
+			cached:      `{"serial": 1, "allowed_groups": ["admins"]}`,
+			httpStatus:  http.StatusInternalServerError,
+			wantErr:     true,
+			wantApplied: true,
+			wantSerial:  1,
+			wantPolicy:  loginpolicy.Config{AllowedGroups: []string{"admins"}, LoginHours: local.LoginHours},
+		},
+		"Error_on_too_large_document": {
+			served:     `{"serial": 1}`,
+			tooLarge:   true,
+			wantErr:    true,
+			wantPolicy: local,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			pub, priv := newKey(t)
+			_, otherPriv := newKey(t)
+
+			var body []byte
+			if tc.served != "" {
+				signer := priv
+				if tc.signedByOther {
+					signer = otherPriv
+				}
+				body = sign(t, signer, tc.served)
+			}
+			if tc.tooLarge {
+				body = append(body, []byte(strings.Repeat(" ", 1<<20))...)
+			}
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.httpStatus != 0 {
+					w.WriteHeader(tc.httpStatus)
+					return
+				}
+				_, _ = w.Write(body)
+			}))
+			t.Cleanup(server.Close)
+
+			cacheDir := t.TempDir()
+			var cached []byte
+			if tc.cached != "" {
+				cached = sign(t, priv, tc.cached)
+				writeFile(t, cacheDir, loginpolicy.CentralPolicyCacheFile, cached)
+			}
+
+			cfg := loginpolicy.CentralConfig{
+				URL:             server.URL + "/policy.json",
+				PublicKey:       writeFile(t, t.TempDir(), "key.pem", marshalPublicKey(t, pub)),
+				RefreshInterval: time.Hour,
+			}
+			c, err := loginpolicy.NewCentralWithClient(cfg, cacheDir, server.Client())
+			require.NoError(t, err, "Setup: NewCentral should not have failed")
+			policy := loginpolicy.NewPolicy(local, c)
+
+			err = c.Fetch(context.Background())
+			if tc.wantErr {
+				require.Error(t, err, "Fetch should have failed")
+			} else {
+				require.NoError(t, err, "Fetch should not have failed")
+			}
+
+			require.Equal(t, tc.wantPolicy, policy.Current(), "Current should return the expected policy")
+
+			status := c.Status()
+			require.Equal(t, cfg.URL, status.URL, "Status should report the URL of the policy")
+			require.Equal(t, tc.wantApplied, status.Applied, "Status should report whether a policy is applied")
+			require.Equal(t, tc.wantSerial, status.Serial, "Status should report the serial of the applied policy")
+			require.Equal(t, tc.wantFetched, !status.FetchedAt.IsZero(), "Status should report whether the policy was fetched")
+			require.Equal(t, tc.wantErr, status.Error != "", "Status should report the error of the last fetch")
+
+			gotCache, err := os.ReadFile(filepath.Join(cacheDir, loginpolicy.CentralPolicyCacheFile))
+			if !tc.wantNewCache {
+				if cached == nil {
+					require.ErrorIs(t, err, os.ErrNotExist, "No policy should be cached")
+				} else {
+					require.Equal(t, cached, gotCache, "The cached policy should not have changed")
+				}
+				return
+			}
+			require.NoError(t, err, "The fetched policy should be cached")
+			require.Equal(t, body, gotCache, "The fetched policy should be cached as is")
+
+			// The cached policy applies when authd starts again.
+			c, err = loginpolicy.NewCentralWithClient(cfg, cacheDir, server.Client())
+			require.NoError(t, err, "NewCentral should not have failed with the cached policy")
+			require.Equal(t, tc.wantPolicy, loginpolicy.NewPolicy(local, c).Current(), "The cached policy should apply")
+		})
+	}
+}
+
+func newKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err, "Setup: could not generate key")
+	return pub, priv
+}
+
+func marshalPublicKey(t *testing.T, key any) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err, "Setup: could not marshal public key")
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func sign(t *testing.T, key ed25519.PrivateKey, policy string) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string][]byte{
+		"policy":    []byte(policy),
+		"signature": ed25519.Sign(key, []byte(policy)),
+	})
+	require.NoError(t, err, "Setup: could not marshal signed document")
+	return data
+}
+
+func writeFile(t *testing.T, dir, name string, content []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, content, 0600), "Setup: could not write %s", name)
+	return path
+}
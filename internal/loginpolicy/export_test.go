@@ -0,0 +1,11 @@
+package loginpolicy
+
+import "net/http"
+
+// NewCentralWithClient is like NewCentral, but the policy is fetched with the given HTTP client.
+func NewCentralWithClient(cfg CentralConfig, cacheDir string, client *http.Client) (*Central, error) {
+	return newCentral(cfg, cacheDir, client)
+}
+
+// CentralPolicyCacheFile is the name of the file in which the central policy is cached.
+const CentralPolicyCacheFile = centralPolicyCacheFile
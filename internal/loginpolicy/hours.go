@@ -0,0 +1,101 @@
+package loginpolicy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// loginHours is a period of the week during which the users can log in, parsed from an entry of login_hours like
+// "Mon-Fri 08:00-18:00".
+type loginHours struct {
+	days [7]bool
+	// start and end are minutes since midnight. If end is not after start, the period ends on the next day.
+	start, end int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseLoginHours parses an entry of login_hours. It is made of an optional comma-separated list of days or ranges of
+// days, like "Mon,Wed-Fri", followed by a range of hours, like "08:00-18:00". Without days, the range applies to every
+// day.
+func parseLoginHours(s string) (h loginHours, err error) {
+	fields := strings.Fields(s)
+	var days, hours string
+	switch len(fields) {
+	case 1:
+		days, hours = "mon-sun", fields[0]
+	case 2:
+		days, hours = fields[0], fields[1]
+	default:
+		return h, fmt.Errorf("invalid login hours %q: expected days and hours like \"Mon-Fri 08:00-18:00\"", s)
+	}
+
+	for _, d := range strings.Split(strings.ToLower(days), ",") {
+		from, to, isRange := strings.Cut(d, "-")
+		first, ok := weekdays[from]
+		if !ok {
+			return h, fmt.Errorf("invalid login hours %q: unknown day %q", s, from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdays[to]; !ok {
+				return h, fmt.Errorf("invalid login hours %q: unknown day %q", s, to)
+			}
+		}
+		// Ranges can wrap around the end of the week, like "Sat-Mon".
+		for day := first; ; day = (day + 1) % 7 {
+			h.days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+
+	from, to, ok := strings.Cut(hours, "-")
+	if !ok {
+		return h, fmt.Errorf("invalid login hours %q: expected a range of hours like \"08:00-18:00\"", s)
+	}
+	if h.start, err = parseTimeOfDay(from); err != nil {
+		return h, fmt.Errorf("invalid login hours %q: %w", s, err)
+	}
+	if h.end, err = parseTimeOfDay(to); err != nil {
+		return h, fmt.Errorf("invalid login hours %q: %w", s, err)
+	}
+
+	return h, nil
+}
+
+// parseTimeOfDay returns the number of minutes since midnight of a time like "08:30". "24:00" is the end of the day.
+func parseTimeOfDay(s string) (int, error) {
+	var hour, minute int
+	if n, err := fmt.Sscanf(s, "%d:%d", &hour, &minute); err != nil || n != 2 || len(s) != len("00:00") {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return hour*60 + minute, nil
+}
+
+// contains returns true if the time is within the period.
+func (h loginHours) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+
+	if h.start < h.end {
+		return h.days[day] && minute >= h.start && minute < h.end
+	}
+
+	// The period starts on one of the days and ends on the next one.
+	previous := (day + 6) % 7
+	return (h.days[day] && minute >= h.start) || (h.days[previous] && minute < h.end)
+}
@@ -0,0 +1,138 @@
+// Package loginpolicy restricts which authd users can log in on the machine, independently of the configuration of
+// the brokers, which is often shared by all the machines of a tenant.
+package loginpolicy
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/canonical/authd/internal/users/types"
+)
+
+// Config holds the login policy of the machine.
+type Config struct {
+	// AllowedGroups are the groups of the identity provider whose members can log in. If empty, all the users
+	// granted access by their broker can log in.
+	AllowedGroups []string `mapstructure:"allowed_groups" yaml:"allowed_groups,omitempty"`
+	// DeniedGroups are the groups of the identity provider whose members can't log in, even if they are members of
+	// one of the allowed groups.
+	DeniedGroups []string `mapstructure:"denied_groups" yaml:"denied_groups,omitempty"`
+	// Lockdown refuses to provision new users: only the users already stored by authd can log in.
+	Lockdown bool `mapstructure:"lockdown" yaml:"lockdown,omitempty"`
+	// LoginHours are the periods of the week during which the users can log in, in the local time of the machine,
+	// like "Mon-Fri 08:00-18:00". If empty, the users can log in at any time.
+	LoginHours []string `mapstructure:"login_hours" yaml:"login_hours,omitempty"`
+	// OfflineMaxAge is how long after their last authentication with their identity provider the users can still log
+	// in offline, e.g. with the password cached by their broker. If 0, offline logins are not limited in time.
+	OfflineMaxAge time.Duration `mapstructure:"offline_max_age" yaml:"offline_max_age,omitempty"`
+	// OfflineMaxLogins is how many times the users can log in offline since their last authentication with their
+	// identity provider. If 0, offline logins are not limited in number.
+	OfflineMaxLogins int `mapstructure:"offline_max_logins" yaml:"offline_max_logins,omitempty"`
+}
+
+// Validate returns an error if the login hours or the offline limits are invalid.
+func (c Config) Validate() error {
+	for _, h := range c.LoginHours {
+		if _, err := parseLoginHours(h); err != nil {
+			return err
+		}
+	}
+	if c.OfflineMaxAge < 0 {
+		return errors.New("offline_max_age must not be negative")
+	}
+	// The offline logins are counted in the login history, which only keeps the last logins of the users.
+	if c.OfflineMaxLogins < 0 || c.OfflineMaxLogins >= db.MaxLoginHistoryEntries {
+		return fmt.Errorf("offline_max_logins must be between 0 and %d", db.MaxLoginHistoryEntries-1)
+	}
+	return nil
+}
+
+// Restricted returns true if only the members of some groups can log in.
+func (c Config) Restricted() bool {
+	return len(c.AllowedGroups) > 0
+}
+
+// DeniedGroup returns the name of the first of the given groups whose members can't log in, or an empty string if
+// there is none.
+//
+// As in Allows, only the groups of the identity provider are taken into account.
+func (c Config) DeniedGroup(groups []types.GroupInfo) string {
+	for _, g := range groups {
+		if g.UGID == "" {
+			continue
+		}
+		if containsGroup(c.DeniedGroups, g.Name) {
+			return g.Name
+		}
+	}
+	return ""
+}
+
+// AllowsNewUsers returns true if users who never logged in on the machine can be provisioned.
+func (c Config) AllowsNewUsers() bool {
+	return !c.Lockdown
+}
+
+// Allows returns true if a user with the given groups can log in: they must not be a member of any of the denied
+// groups and, if the login is restricted, they must be a member of one of the allowed groups.
+//
+// Only the groups of the identity provider are taken into account: the local groups which the broker adds the user
+// to are configured on the broker side, so they may be the same for all users.
+func (c Config) Allows(groups []types.GroupInfo) bool {
+	if c.DeniedGroup(groups) != "" {
+		return false
+	}
+	if !c.Restricted() {
+		return true
+	}
+
+	for _, g := range groups {
+		if g.UGID == "" {
+			continue
+		}
+		if containsGroup(c.AllowedGroups, g.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsGroup returns true if the name is one of the configured groups.
+func containsGroup(groups []string, name string) bool {
+	// authd uses lowercase group names.
+	return slices.ContainsFunc(groups, func(g string) bool { return strings.EqualFold(g, name) })
+}
+
+// AllowsAt returns true if the users can log in at the given time.
+func (c Config) AllowsAt(t time.Time) bool {
+	if len(c.LoginHours) == 0 {
+		return true
+	}
+	for _, entry := range c.LoginHours {
+		// The entries are validated when the configuration is loaded.
+		h, err := parseLoginHours(entry)
+		if err == nil && h.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsOffline returns true if a user who last authenticated with their identity provider at lastOnline can log in
+// offline at the given time. The zero time means that the user never authenticated online, as far as authd knows.
+func (c Config) AllowsOffline(lastOnline, now time.Time) bool {
+	if c.OfflineMaxAge == 0 {
+		return true
+	}
+	return !lastOnline.IsZero() && now.Sub(lastOnline) <= c.OfflineMaxAge
+}
+
+// AllowsOfflineLogins returns true if a user who logged in offline n times since they last authenticated with their
+// identity provider can log in offline again.
+func (c Config) AllowsOfflineLogins(n int) bool {
+	return c.OfflineMaxLogins == 0 || n < c.OfflineMaxLogins
+}
@@ -0,0 +1,223 @@
+package loginpolicy_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/loginpolicy"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllows(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		allowedGroups []string
+		deniedGroups  []string
+		userGroups    []types.GroupInfo
+
+		want bool
+	}{
+		"Allow_all_users_without_allowed_groups":            {userGroups: []types.GroupInfo{{Name: "employees", UGID: "employees-id"}}, want: true},
+		"Allow_users_without_groups_without_allowed_groups": {want: true},
+		"Allow_members_of_an_allowed_group": {
+			allowedGroups: []string{"admins", "employees"},
+			userGroups:    []types.GroupInfo{{Name: "contractors", UGID: "contractors-id"}, {Name: "employees", UGID: "employees-id"}},
+			want:          true,
+		},
+		"Allow_users_not_member_of_a_denied_group": {
+			deniedGroups: []string{"contractors"},
+			userGroups:   []types.GroupInfo{{Name: "employees", UGID: "employees-id"}},
+			want:         true,
+		},
+		"Allow_users_only_in_a_denied_local_group": {
+			deniedGroups: []string{"sudo"},
+			userGroups:   []types.GroupInfo{{Name: "sudo"}, {Name: "employees", UGID: "employees-id"}},
+			want:         true,
+		},
+		"Allow_members_of_an_allowed_group_with_different_case": {
+			allowedGroups: []string{"Employees"},
+			userGroups:    []types.GroupInfo{{Name: "employees", UGID: "employees-id"}},
+			want:          true,
+		},
+
+		"Deny_users_not_member_of_an_allowed_group": {
+			allowedGroups: []string{"admins"},
+			userGroups:    []types.GroupInfo{{Name: "employees", UGID: "employees-id"}},
+		},
+		"Deny_users_without_groups": {allowedGroups: []string{"admins"}},
+		"Deny_users_only_in_an_allowed_local_group": {
+			allowedGroups: []string{"sudo"},
+			userGroups:    []types.GroupInfo{{Name: "sudo"}, {Name: "employees", UGID: "employees-id"}},
+		},
+		"Deny_members_of_a_denied_group": {
+			deniedGroups: []string{"contractors"},
+			userGroups:   []types.GroupInfo{{Name: "contractors", UGID: "contractors-id"}},
+		},
+		"Deny_members_of_a_denied_group_with_different_case": {
+			deniedGroups: []string{"Contractors"},
+			userGroups:   []types.GroupInfo{{Name: "contractors", UGID: "contractors-id"}},
+		},
+		"Deny_members_of_a_denied_group_even_if_member_of_an_allowed_group": {
+			allowedGroups: []string{"employees"},
+			deniedGroups:  []string{"contractors"},
+			userGroups:    []types.GroupInfo{{Name: "employees", UGID: "employees-id"}, {Name: "contractors", UGID: "contractors-id"}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := loginpolicy.Config{AllowedGroups: tc.allowedGroups, DeniedGroups: tc.deniedGroups}
+			require.Equal(t, tc.want, cfg.Allows(tc.userGroups), "Allows should return the expected result")
+		})
+	}
+}
+
+func TestAllowsNewUsers(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, loginpolicy.Config{}.AllowsNewUsers(), "New users should be allowed by default")
+	require.True(t, loginpolicy.Config{AllowedGroups: []string{"admins"}}.AllowsNewUsers(), "New users should be allowed with allowed groups")
+	require.False(t, loginpolicy.Config{Lockdown: true}.AllowsNewUsers(), "New users should not be allowed in lockdown")
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cfg loginpolicy.Config
+
+		wantErr bool
+	}{
+		"Valid_empty_config":                {},
+		"Valid_login_hours_with_days":       {cfg: loginpolicy.Config{LoginHours: []string{"Mon-Fri 08:00-18:00", "sat,SUN 10:00-12:30"}}},
+		"Valid_login_hours_without_days":    {cfg: loginpolicy.Config{LoginHours: []string{"00:00-24:00"}}},
+		"Valid_login_hours_over_midnight":   {cfg: loginpolicy.Config{LoginHours: []string{"Fri-Mon 22:00-06:00"}}},
+		"Valid_offline_max_age":             {cfg: loginpolicy.Config{OfflineMaxAge: 72 * time.Hour}},
+		"Valid_login_hours_with_day_ranges": {cfg: loginpolicy.Config{LoginHours: []string{"Mon,Wed-Fri 08:00-18:00"}}},
+		"Valid_offline_max_logins":          {cfg: loginpolicy.Config{OfflineMaxLogins: 10}},
+
+		"Error_on_unknown_day":                 {cfg: loginpolicy.Config{LoginHours: []string{"Monday 08:00-18:00"}}, wantErr: true},
+		"Error_on_unknown_day_in_range":        {cfg: loginpolicy.Config{LoginHours: []string{"Mon-Xyz 08:00-18:00"}}, wantErr: true},
+		"Error_on_missing_end_of_hours":        {cfg: loginpolicy.Config{LoginHours: []string{"Mon 08:00"}}, wantErr: true},
+		"Error_on_invalid_hour":                {cfg: loginpolicy.Config{LoginHours: []string{"Mon 08:00-25:00"}}, wantErr: true},
+		"Error_on_invalid_minute":              {cfg: loginpolicy.Config{LoginHours: []string{"Mon 08:60-18:00"}}, wantErr: true},
+		"Error_on_time_without_leading_zero":   {cfg: loginpolicy.Config{LoginHours: []string{"Mon 8:00-18:00"}}, wantErr: true},
+		"Error_on_too_many_fields":             {cfg: loginpolicy.Config{LoginHours: []string{"Mon 08:00 18:00"}}, wantErr: true},
+		"Error_on_negative_offline_max_age":    {cfg: loginpolicy.Config{OfflineMaxAge: -time.Hour}, wantErr: true},
+		"Error_on_negative_offline_max_logins": {cfg: loginpolicy.Config{OfflineMaxLogins: -1}, wantErr: true},
+		"Error_on_too_high_offline_max_logins": {cfg: loginpolicy.Config{OfflineMaxLogins: 100}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.cfg.Validate()
+			if tc.wantErr {
+				require.Error(t, err, "Validate should have failed")
+				return
+			}
+			require.NoError(t, err, "Validate should not have failed")
+		})
+	}
+}
+
+func TestAllowsAt(t *testing.T) {
+	t.Parallel()
+
+	// 2024-01-01 is a Monday.
+	monday := func(hour, minute int) time.Time { return time.Date(2024, 1, 1, hour, minute, 0, 0, time.Local) }
+
+	tests := map[string]struct {
+		loginHours []string
+		at         time.Time
+
+		want bool
+	}{
+		"Allow_at_any_time_without_login_hours":   {at: monday(3, 0), want: true},
+		"Allow_within_login_hours":                {loginHours: []string{"Mon-Fri 08:00-18:00"}, at: monday(8, 0), want: true},
+		"Allow_within_any_of_the_login_hours":     {loginHours: []string{"Sat 08:00-18:00", "Mon 12:00-13:00"}, at: monday(12, 30), want: true},
+		"Allow_every_day_without_days":            {loginHours: []string{"08:00-18:00"}, at: monday(17, 59), want: true},
+		"Allow_after_midnight_of_overnight_hours": {loginHours: []string{"Sun 22:00-06:00"}, at: monday(5, 59), want: true},
+		"Allow_before_midnight_of_overnight_hours": {
+			loginHours: []string{"Mon 22:00-06:00"},
+			at:         monday(23, 0),
+			want:       true,
+		},
+		"Allow_in_day_range_wrapping_around_the_week": {loginHours: []string{"Sat-Mon 08:00-18:00"}, at: monday(9, 0), want: true},
+
+		"Deny_before_login_hours":                 {loginHours: []string{"Mon-Fri 08:00-18:00"}, at: monday(7, 59)},
+		"Deny_at_end_of_login_hours":              {loginHours: []string{"Mon-Fri 08:00-18:00"}, at: monday(18, 0)},
+		"Deny_on_other_days":                      {loginHours: []string{"Tue-Fri 08:00-18:00"}, at: monday(12, 0)},
+		"Deny_after_end_of_overnight_hours":       {loginHours: []string{"Sun 22:00-06:00"}, at: monday(6, 0)},
+		"Deny_after_midnight_of_other_day_period": {loginHours: []string{"Mon 22:00-06:00"}, at: monday(1, 0)},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := loginpolicy.Config{LoginHours: tc.loginHours}
+			require.NoError(t, cfg.Validate(), "Setup: login hours should be valid")
+			require.Equal(t, tc.want, cfg.AllowsAt(tc.at), "AllowsAt should return the expected result")
+		})
+	}
+}
+
+func TestAllowsOffline(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	tests := map[string]struct {
+		offlineMaxAge time.Duration
+		lastOnline    time.Time
+
+		want bool
+	}{
+		"Allow_without_offline_limit":                        {lastOnline: now.Add(-365 * 24 * time.Hour), want: true},
+		"Allow_users_who_never_logged_in_online_if_no_limit": {want: true},
+		"Allow_within_offline_limit":                         {offlineMaxAge: 72 * time.Hour, lastOnline: now.Add(-71 * time.Hour), want: true},
+
+		"Deny_after_offline_limit":                         {offlineMaxAge: 72 * time.Hour, lastOnline: now.Add(-73 * time.Hour)},
+		"Deny_users_who_never_logged_in_online_if_limited": {offlineMaxAge: 72 * time.Hour},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := loginpolicy.Config{OfflineMaxAge: tc.offlineMaxAge}
+			require.Equal(t, tc.want, cfg.AllowsOffline(tc.lastOnline, now), "AllowsOffline should return the expected result")
+		})
+	}
+}
+
+func TestAllowsOfflineLogins(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		offlineMaxLogins int
+		offlineLogins    int
+
+		want bool
+	}{
+		"Allow_without_offline_logins_limit": {offlineLogins: 50, want: true},
+		"Allow_below_offline_logins_limit":   {offlineMaxLogins: 3, offlineLogins: 2, want: true},
+
+		"Deny_at_offline_logins_limit":    {offlineMaxLogins: 3, offlineLogins: 3},
+		"Deny_above_offline_logins_limit": {offlineMaxLogins: 3, offlineLogins: 4},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := loginpolicy.Config{OfflineMaxLogins: tc.offlineMaxLogins}
+			require.Equal(t, tc.want, cfg.AllowsOfflineLogins(tc.offlineLogins), "AllowsOfflineLogins should return the expected result")
+		})
+	}
+}
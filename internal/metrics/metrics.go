@@ -0,0 +1,230 @@
+// Package metrics exposes counters and histograms of the authentications, of the calls to the brokers and of the NSS
+// lookups in the Prometheus text format, so that fleets of machines running authd can be monitored.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/canonical/authd/internal/decorate"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/log"
+	"google.golang.org/grpc"
+)
+
+// brokerBuckets are the upper bounds of the buckets of the durations of the calls to the brokers, which reach the
+// identity providers over the network.
+var brokerBuckets = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+// nssBuckets are the upper bounds of the buckets of the durations of the NSS lookups, which usually take less than a
+// millisecond.
+var nssBuckets = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// nssMethods are the methods of the user service called by the NSS module.
+var nssMethods = map[string]bool{
+	authd.UserService_GetUserByName_FullMethodName:    true,
+	authd.UserService_GetUserByID_FullMethodName:      true,
+	authd.UserService_ListUsers_FullMethodName:        true,
+	authd.UserService_GetGroupByName_FullMethodName:   true,
+	authd.UserService_GetGroupByID_FullMethodName:     true,
+	authd.UserService_ListGroups_FullMethodName:       true,
+	authd.UserService_GetGroupsForUser_FullMethodName: true,
+}
+
+// StatsGetter returns statistics about the users and the database, like [users.Manager].
+type StatsGetter interface {
+	Stats() (users.Stats, error)
+}
+
+// Metrics are the metrics of the daemon. A nil Metrics records nothing.
+type Metrics struct {
+	stats StatsGetter
+
+	logins               *counter
+	tokenRefreshFailures *counter
+	brokerCalls          *histogram
+	nssLookups           *histogram
+}
+
+// New returns metrics which report the size of the database and the number of users from stats.
+func New(stats StatsGetter) *Metrics {
+	return &Metrics{
+		stats: stats,
+		logins: newCounter("authd_authentications_total",
+			"Number of authentications by broker and result.", "broker", "result"),
+		tokenRefreshFailures: newCounter("authd_token_refresh_failures_total",
+			"Number of times a broker could not refresh the token of a user from the identity provider.", "broker"),
+		brokerCalls: newHistogram("authd_broker_call_duration_seconds",
+			"Duration of the calls to the brokers by method.", brokerBuckets, "broker", "method"),
+		nssLookups: newHistogram("authd_nss_lookup_duration_seconds",
+			"Duration of the lookups of users and groups made by the NSS module, by method.", nssBuckets, "method"),
+	}
+}
+
+// Authenticated records the result of an authentication with the broker. A successful authentication with the
+// information cached by the broker is also recorded as a failure to refresh the token of the user.
+func (m *Metrics) Authenticated(brokerID, result string, offline bool) {
+	if m == nil {
+		return
+	}
+	m.logins.inc(brokerID, result)
+	if offline {
+		m.tokenRefreshFailures.inc(brokerID)
+	}
+}
+
+// TokenRefreshFailed records that the broker could not refresh the token of a user.
+func (m *Metrics) TokenRefreshFailed(brokerID string) {
+	if m == nil {
+		return
+	}
+	m.tokenRefreshFailures.inc(brokerID)
+}
+
+// ObserveBrokerCall records the duration of a call to the broker. It implements [brokers.LatencyObserver].
+func (m *Metrics) ObserveBrokerCall(brokerID, method string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.brokerCalls.observe(d, brokerID, method)
+}
+
+// UnaryInterceptor records the duration of the NSS lookups.
+func (m *Metrics) UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if m == nil || !nssMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	start := time.Now()
+	defer func() { m.nssLookups.observe(time.Since(start), path.Base(info.FullMethod)) }()
+	return handler(ctx, req)
+}
+
+// Write writes the metrics in the Prometheus text format.
+func (m *Metrics) Write(w io.Writer) error {
+	for _, c := range []*counter{m.logins, m.tokenRefreshFailures} {
+		if err := c.write(w); err != nil {
+			return err
+		}
+	}
+	for _, h := range []*histogram{m.brokerCalls, m.nssLookups} {
+		if err := h.write(w); err != nil {
+			return err
+		}
+	}
+
+	if m.stats == nil {
+		return nil
+	}
+	s, err := m.stats.Stats()
+	if err != nil {
+		// The other metrics are still useful, so only the ones of the database are skipped.
+		log.Warningf(context.Background(), "Could not get the database metrics: %v", err)
+		return nil
+	}
+	if err := writeGauge(w, "authd_database_size_bytes", "Size of the database and of its write-ahead log.", float64(s.Size)); err != nil {
+		return err
+	}
+	return writeGauge(w, "authd_users", "Number of users stored in the database.", float64(s.Users))
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := m.Write(w); err != nil {
+		log.Warningf(r.Context(), "Could not write the metrics: %v", err)
+	}
+}
+
+// Serve serves the metrics on /metrics over HTTP until stop is called. The address is either the absolute path of a
+// unix socket, which only root can connect to, or a host and a port on the loopback interface, e.g. 127.0.0.1:9469.
+func (m *Metrics) Serve(ctx context.Context, address string) (stop func(), err error) {
+	defer decorate.OnError(&err, "could not serve the metrics on %q", address)
+
+	lis, err := listen(address)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Warningf(ctx, "Stopped serving the metrics: %v", err)
+		}
+	}()
+
+	log.Infof(ctx, "Serving the metrics on %s", address)
+	return func() {
+		_ = srv.Close()
+		<-done
+	}, nil
+}
+
+// listen listens on the unix socket or on the loopback TCP address.
+func listen(address string) (net.Listener, error) {
+	if filepath.IsAbs(address) {
+		// Remove the socket left behind if authd was not stopped cleanly.
+		if err := os.Remove(address); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		lis, err := net.Listen("unix", address)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(address, 0600); err != nil {
+			_ = lis.Close()
+			return nil, err
+		}
+		return lis, nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("%q is not a loopback address: the metrics are only served locally", host)
+	}
+	return net.Listen("tcp", address)
+}
@@ -0,0 +1,177 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/metrics"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/testutils/golden"
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		statsErr error
+		noStats  bool
+	}{
+		"Write_all_metrics": {},
+
+		"Write_the_other_metrics_if_the_stats_can_not_be_read": {statsErr: errors.New("some error")},
+		"Write_the_other_metrics_without_stats":                {noStats: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var stats metrics.StatsGetter = statsGetter{
+				stats: users.Stats{Stats: db.Stats{Size: 8192, Users: 3}},
+				err:   tc.statsErr,
+			}
+			if tc.noStats {
+				stats = nil
+			}
+			m := metrics.New(stats)
+
+			m.Authenticated("broker-id", "granted", false)
+			m.Authenticated("broker-id", "granted", true)
+			m.Authenticated("broker-id", "denied", false)
+			m.Authenticated("other-broker-id", "granted", false)
+			m.TokenRefreshFailed("other-broker-id")
+			m.ObserveBrokerCall("broker-id", "IsAuthenticated", 300*time.Millisecond)
+			m.ObserveBrokerCall("broker-id", "IsAuthenticated", 40*time.Second)
+			m.ObserveBrokerCall("broker-id", "NewSession", 20*time.Millisecond)
+
+			var got bytes.Buffer
+			require.NoError(t, m.Write(&got), "Write should not return an error")
+			golden.CheckOrUpdate(t, got.String())
+		})
+	}
+}
+
+func TestUnaryInterceptor(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.New(nil)
+	handler := func(context.Context, any) (any, error) { return "reply", nil }
+
+	for _, method := range []string{authd.UserService_GetUserByName_FullMethodName, authd.PAM_IsAuthenticated_FullMethodName} {
+		reply, err := m.UnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		require.NoError(t, err, "UnaryInterceptor should not return an error")
+		require.Equal(t, "reply", reply, "UnaryInterceptor should return the reply of the handler")
+	}
+
+	var got bytes.Buffer
+	require.NoError(t, m.Write(&got), "Write should not return an error")
+	require.Contains(t, got.String(), `authd_nss_lookup_duration_seconds_count{method="GetUserByName"} 1`,
+		"The NSS lookups should be recorded")
+	require.NotContains(t, got.String(), "IsAuthenticated", "The other methods should not be recorded")
+}
+
+func TestNilMetricsRecordNothing(t *testing.T) {
+	t.Parallel()
+
+	var m *metrics.Metrics
+	m.Authenticated("broker-id", "granted", true)
+	m.TokenRefreshFailed("broker-id")
+	m.ObserveBrokerCall("broker-id", "NewSession", time.Second)
+
+	reply, err := m.UnaryInterceptor(context.Background(), nil,
+		&grpc.UnaryServerInfo{FullMethod: authd.UserService_GetUserByName_FullMethodName},
+		func(context.Context, any) (any, error) { return "reply", nil })
+	require.NoError(t, err, "UnaryInterceptor should not return an error")
+	require.Equal(t, "reply", reply, "UnaryInterceptor should return the reply of the handler")
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+
+	socketPath := filepath.Join(t.TempDir(), "metrics.sock")
+
+	tests := map[string]struct {
+		address string
+
+		wantErr bool
+	}{
+		"Serve_on_a_unix_socket":                     {address: socketPath},
+		"Serve_on_a_loopback_address":                {address: "127.0.0.1:0"},
+		"Serve_on_localhost":                         {address: "localhost:0"},
+		"Serve_on_the_IPv6_loopback":                 {address: "[::1]:0"},
+		"Error_if_the_address_is_not_a_loopback_one": {address: "0.0.0.0:0", wantErr: true},
+		"Error_if_the_address_has_no_port":           {address: "127.0.0.1", wantErr: true},
+		"Error_if_the_address_is_a_relative_path":    {address: "metrics.sock", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := metrics.New(nil)
+			m.Authenticated("broker-id", "granted", false)
+
+			stop, err := m.Serve(context.Background(), tc.address)
+			if tc.wantErr {
+				require.Error(t, err, "Serve should return an error")
+				return
+			}
+			if err != nil && tc.address == "[::1]:0" {
+				t.Skipf("IPv6 is not available: %v", err)
+			}
+			require.NoError(t, err, "Serve should not return an error")
+			t.Cleanup(stop)
+		})
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	socketPath := filepath.Join(t.TempDir(), "metrics.sock")
+	m := metrics.New(nil)
+	m.Authenticated("broker-id", "granted", false)
+
+	stop, err := m.Serve(context.Background(), socketPath)
+	require.NoError(t, err, "Setup: Serve should not return an error")
+	defer stop()
+
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+	}}
+
+	resp, err := client.Get("http://authd/metrics")
+	require.NoError(t, err, "GET should not return an error")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "GET should succeed")
+	require.Contains(t, resp.Header.Get("Content-Type"), "text/plain", "The metrics should be in the text format")
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err, "Reading the body should not return an error")
+	require.Contains(t, string(body), `authd_authentications_total{broker="broker-id",result="granted"} 1`,
+		"The metrics should be served")
+
+	resp, err = client.Post("http://authd/metrics", "text/plain", nil)
+	require.NoError(t, err, "POST should not return an error")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode, "POST should not be allowed")
+}
+
+type statsGetter struct {
+	stats users.Stats
+	err   error
+}
+
+func (s statsGetter) Stats() (users.Stats, error) {
+	return s.stats, s.err
+}
@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// labelSeparator separates the values of the labels in the keys of the series, as it can't be in a valid label value.
+const labelSeparator = "\xff"
+
+// counter is a counter with labels, in the Prometheus text format.
+type counter struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+func newCounter(name, help string, labels ...string) *counter {
+	return &counter{name: name, help: help, labels: labels, values: make(map[string]uint64)}
+}
+
+// inc increments the counter of the series with the given label values, in the order of the labels.
+func (c *counter) inc(labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[strings.Join(labelValues, labelSeparator)]++
+}
+
+func (c *counter) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name); err != nil {
+		return err
+	}
+	for _, key := range slices.Sorted(maps.Keys(c.values)) {
+		if _, err := fmt.Fprintf(w, "%s%s %d\n", c.name, formatLabels(c.labels, key), c.values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// histogram is a histogram of durations with labels, in the Prometheus text format.
+type histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []time.Duration
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	// counts are the number of observations in each bucket, not cumulative.
+	counts []uint64
+	count  uint64
+	sum    time.Duration
+}
+
+func newHistogram(name, help string, buckets []time.Duration, labels ...string) *histogram {
+	return &histogram{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogramSeries)}
+}
+
+// observe adds the duration to the series with the given label values, in the order of the labels.
+func (h *histogram) observe(d time.Duration, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := strings.Join(labelValues, labelSeparator)
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	s.count++
+	s.sum += d
+	if i, _ := slices.BinarySearch(h.buckets, d); i < len(h.buckets) {
+		s.counts[i]++
+	}
+}
+
+func (h *histogram) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name); err != nil {
+		return err
+	}
+	labels := append(slices.Clone(h.labels), "le")
+	for _, key := range slices.Sorted(maps.Keys(h.series)) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			le := formatFloat(bound.Seconds())
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(labels, h.withLabel(key, le)), cumulative); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(labels, h.withLabel(key, "+Inf")), s.count); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key), formatFloat(s.sum.Seconds())); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, key), s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeGauge writes a gauge without labels.
+func writeGauge(w io.Writer, name, help string, value float64) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, formatFloat(value))
+	return err
+}
+
+// withLabel appends the value of an additional label to the key of a series.
+func (h *histogram) withLabel(key, value string) string {
+	if len(h.labels) == 0 {
+		return value
+	}
+	return key + labelSeparator + value
+}
+
+// formatLabels returns the labels of the series with the given key, e.g. {broker="Google",result="granted"}.
+func formatLabels(labels []string, key string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	values := strings.Split(key, labelSeparator)
+	pairs := make([]string, len(labels))
+	for i, l := range labels {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", l, labelValueEscaper.Replace(values[i]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelValueEscaper escapes the label values as the Prometheus text format expects.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -70,6 +70,55 @@ func (SessionMode) EnumDescriptor() ([]byte, []int) {
 	return file_authd_proto_rawDescGZIP(), []int{0}
 }
 
+type NSRequest_Event int32
+
+const (
+	NSRequest_UNSPECIFIED NSRequest_Event = 0
+	NSRequest_START       NSRequest_Event = 1
+	NSRequest_STOP        NSRequest_Event = 2
+)
+
+// Enum value maps for NSRequest_Event.
+var (
+	NSRequest_Event_name = map[int32]string{
+		0: "UNSPECIFIED",
+		1: "START",
+		2: "STOP",
+	}
+	NSRequest_Event_value = map[string]int32{
+		"UNSPECIFIED": 0,
+		"START":       1,
+		"STOP":        2,
+	}
+)
+
+func (x NSRequest_Event) Enum() *NSRequest_Event {
+	p := new(NSRequest_Event)
+	*p = x
+	return p
+}
+
+func (x NSRequest_Event) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (NSRequest_Event) Descriptor() protoreflect.EnumDescriptor {
+	return file_authd_proto_enumTypes[1].Descriptor()
+}
+
+func (NSRequest_Event) Type() protoreflect.EnumType {
+	return &file_authd_proto_enumTypes[1]
+}
+
+func (x NSRequest_Event) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use NSRequest_Event.Descriptor instead.
+func (NSRequest_Event) EnumDescriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{17, 0}
+}
+
 type Empty struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -107,8 +156,10 @@ func (*Empty) Descriptor() ([]byte, []int) {
 }
 
 type GBRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Username string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	// The PAM service, to select its default broker for users who never logged in.
+	Service       string `protobuf:"bytes,2,opt,name=service,proto3" json:"service,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -150,6 +201,13 @@ func (x *GBRequest) GetUsername() string {
 	return ""
 }
 
+func (x *GBRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
 type GBResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Broker        string                 `protobuf:"bytes,1,opt,name=broker,proto3" json:"broker,omitempty"`
@@ -283,11 +341,15 @@ func (x *StringResponse) GetMsg() string {
 }
 
 type SBRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	BrokerId      string                 `protobuf:"bytes,1,opt,name=broker_id,json=brokerId,proto3" json:"broker_id,omitempty"`
-	Username      string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
-	Lang          string                 `protobuf:"bytes,3,opt,name=lang,proto3" json:"lang,omitempty"`
-	Mode          SessionMode            `protobuf:"varint,4,opt,name=mode,proto3,enum=authd.SessionMode" json:"mode,omitempty"`
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	BrokerId string                 `protobuf:"bytes,1,opt,name=broker_id,json=brokerId,proto3" json:"broker_id,omitempty"`
+	Username string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	Lang     string                 `protobuf:"bytes,3,opt,name=lang,proto3" json:"lang,omitempty"`
+	Mode     SessionMode            `protobuf:"varint,4,opt,name=mode,proto3,enum=authd.SessionMode" json:"mode,omitempty"`
+	// The PAM service the session is started for (e.g. "sshd" or "gdm-authd").
+	Service string `protobuf:"bytes,5,opt,name=service,proto3" json:"service,omitempty"`
+	// The time zone of the user, as an IANA time zone name (e.g. "Europe/Paris"), if known.
+	Timezone      string `protobuf:"bytes,6,opt,name=timezone,proto3" json:"timezone,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -350,6 +412,20 @@ func (x *SBRequest) GetMode() SessionMode {
 	return SessionMode_UNDEFINED
 }
 
+func (x *SBRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *SBRequest) GetTimezone() string {
+	if x != nil {
+		return x.Timezone
+	}
+	return ""
+}
+
 type SBResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
@@ -801,27 +877,40 @@ func (x *IAResponse) GetMsg() string {
 	return ""
 }
 
-type ESRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+type ConversationRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The identifier of the request, chosen by the client. It's set in the matching response. A cancel request uses
+	// the identifier of the request to cancel.
+	Id uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Types that are valid to be assigned to Request:
+	//
+	//	*ConversationRequest_AvailableBrokers
+	//	*ConversationRequest_GetBroker
+	//	*ConversationRequest_SelectBroker
+	//	*ConversationRequest_GetAuthenticationModes
+	//	*ConversationRequest_SelectAuthenticationMode
+	//	*ConversationRequest_IsAuthenticated
+	//	*ConversationRequest_EndSession
+	//	*ConversationRequest_Cancel
+	Request       isConversationRequest_Request `protobuf_oneof:"request"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ESRequest) Reset() {
-	*x = ESRequest{}
+func (x *ConversationRequest) Reset() {
+	*x = ConversationRequest{}
 	mi := &file_authd_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ESRequest) String() string {
+func (x *ConversationRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ESRequest) ProtoMessage() {}
+func (*ConversationRequest) ProtoMessage() {}
 
-func (x *ESRequest) ProtoReflect() protoreflect.Message {
+func (x *ConversationRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_authd_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -833,180 +922,186 @@ func (x *ESRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ESRequest.ProtoReflect.Descriptor instead.
-func (*ESRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use ConversationRequest.ProtoReflect.Descriptor instead.
+func (*ConversationRequest) Descriptor() ([]byte, []int) {
 	return file_authd_proto_rawDescGZIP(), []int{14}
 }
 
-func (x *ESRequest) GetSessionId() string {
+func (x *ConversationRequest) GetId() uint64 {
 	if x != nil {
-		return x.SessionId
+		return x.Id
 	}
-	return ""
-}
-
-type GetUserByNameRequest struct {
-	state          protoimpl.MessageState `protogen:"open.v1"`
-	Name           string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	ShouldPreCheck bool                   `protobuf:"varint,2,opt,name=shouldPreCheck,proto3" json:"shouldPreCheck,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
-}
-
-func (x *GetUserByNameRequest) Reset() {
-	*x = GetUserByNameRequest{}
-	mi := &file_authd_proto_msgTypes[15]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+	return 0
 }
 
-func (x *GetUserByNameRequest) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *ConversationRequest) GetRequest() isConversationRequest_Request {
+	if x != nil {
+		return x.Request
+	}
+	return nil
 }
 
-func (*GetUserByNameRequest) ProtoMessage() {}
-
-func (x *GetUserByNameRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[15]
+func (x *ConversationRequest) GetAvailableBrokers() *Empty {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
+		if x, ok := x.Request.(*ConversationRequest_AvailableBrokers); ok {
+			return x.AvailableBrokers
 		}
-		return ms
 	}
-	return mi.MessageOf(x)
+	return nil
 }
 
-// Deprecated: Use GetUserByNameRequest.ProtoReflect.Descriptor instead.
-func (*GetUserByNameRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{15}
+func (x *ConversationRequest) GetGetBroker() *GBRequest {
+	if x != nil {
+		if x, ok := x.Request.(*ConversationRequest_GetBroker); ok {
+			return x.GetBroker
+		}
+	}
+	return nil
 }
 
-func (x *GetUserByNameRequest) GetName() string {
+func (x *ConversationRequest) GetSelectBroker() *SBRequest {
 	if x != nil {
-		return x.Name
+		if x, ok := x.Request.(*ConversationRequest_SelectBroker); ok {
+			return x.SelectBroker
+		}
 	}
-	return ""
+	return nil
 }
 
-func (x *GetUserByNameRequest) GetShouldPreCheck() bool {
+func (x *ConversationRequest) GetGetAuthenticationModes() *GAMRequest {
 	if x != nil {
-		return x.ShouldPreCheck
+		if x, ok := x.Request.(*ConversationRequest_GetAuthenticationModes); ok {
+			return x.GetAuthenticationModes
+		}
 	}
-	return false
+	return nil
 }
 
-type GetUserByIDRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *ConversationRequest) GetSelectAuthenticationMode() *SAMRequest {
+	if x != nil {
+		if x, ok := x.Request.(*ConversationRequest_SelectAuthenticationMode); ok {
+			return x.SelectAuthenticationMode
+		}
+	}
+	return nil
 }
 
-func (x *GetUserByIDRequest) Reset() {
-	*x = GetUserByIDRequest{}
-	mi := &file_authd_proto_msgTypes[16]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *ConversationRequest) GetIsAuthenticated() *IARequest {
+	if x != nil {
+		if x, ok := x.Request.(*ConversationRequest_IsAuthenticated); ok {
+			return x.IsAuthenticated
+		}
+	}
+	return nil
 }
 
-func (x *GetUserByIDRequest) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *ConversationRequest) GetEndSession() *ESRequest {
+	if x != nil {
+		if x, ok := x.Request.(*ConversationRequest_EndSession); ok {
+			return x.EndSession
+		}
+	}
+	return nil
 }
 
-func (*GetUserByIDRequest) ProtoMessage() {}
-
-func (x *GetUserByIDRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[16]
+func (x *ConversationRequest) GetCancel() *Empty {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
+		if x, ok := x.Request.(*ConversationRequest_Cancel); ok {
+			return x.Cancel
 		}
-		return ms
 	}
-	return mi.MessageOf(x)
+	return nil
 }
 
-// Deprecated: Use GetUserByIDRequest.ProtoReflect.Descriptor instead.
-func (*GetUserByIDRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{16}
+type isConversationRequest_Request interface {
+	isConversationRequest_Request()
 }
 
-func (x *GetUserByIDRequest) GetId() uint32 {
-	if x != nil {
-		return x.Id
-	}
-	return 0
+type ConversationRequest_AvailableBrokers struct {
+	AvailableBrokers *Empty `protobuf:"bytes,2,opt,name=available_brokers,json=availableBrokers,proto3,oneof"`
 }
 
-type LockUserRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+type ConversationRequest_GetBroker struct {
+	GetBroker *GBRequest `protobuf:"bytes,3,opt,name=get_broker,json=getBroker,proto3,oneof"`
 }
 
-func (x *LockUserRequest) Reset() {
-	*x = LockUserRequest{}
-	mi := &file_authd_proto_msgTypes[17]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+type ConversationRequest_SelectBroker struct {
+	SelectBroker *SBRequest `protobuf:"bytes,4,opt,name=select_broker,json=selectBroker,proto3,oneof"`
 }
 
-func (x *LockUserRequest) String() string {
-	return protoimpl.X.MessageStringOf(x)
+type ConversationRequest_GetAuthenticationModes struct {
+	GetAuthenticationModes *GAMRequest `protobuf:"bytes,5,opt,name=get_authentication_modes,json=getAuthenticationModes,proto3,oneof"`
 }
 
-func (*LockUserRequest) ProtoMessage() {}
+type ConversationRequest_SelectAuthenticationMode struct {
+	SelectAuthenticationMode *SAMRequest `protobuf:"bytes,6,opt,name=select_authentication_mode,json=selectAuthenticationMode,proto3,oneof"`
+}
 
-func (x *LockUserRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[17]
-	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
+type ConversationRequest_IsAuthenticated struct {
+	IsAuthenticated *IARequest `protobuf:"bytes,7,opt,name=is_authenticated,json=isAuthenticated,proto3,oneof"`
 }
 
-// Deprecated: Use LockUserRequest.ProtoReflect.Descriptor instead.
-func (*LockUserRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{17}
+type ConversationRequest_EndSession struct {
+	EndSession *ESRequest `protobuf:"bytes,8,opt,name=end_session,json=endSession,proto3,oneof"`
 }
 
-func (x *LockUserRequest) GetName() string {
-	if x != nil {
-		return x.Name
-	}
-	return ""
+type ConversationRequest_Cancel struct {
+	// Cancels the request with the same identifier, if it's still running.
+	Cancel *Empty `protobuf:"bytes,9,opt,name=cancel,proto3,oneof"`
 }
 
-type UnlockUserRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+func (*ConversationRequest_AvailableBrokers) isConversationRequest_Request() {}
+
+func (*ConversationRequest_GetBroker) isConversationRequest_Request() {}
+
+func (*ConversationRequest_SelectBroker) isConversationRequest_Request() {}
+
+func (*ConversationRequest_GetAuthenticationModes) isConversationRequest_Request() {}
+
+func (*ConversationRequest_SelectAuthenticationMode) isConversationRequest_Request() {}
+
+func (*ConversationRequest_IsAuthenticated) isConversationRequest_Request() {}
+
+func (*ConversationRequest_EndSession) isConversationRequest_Request() {}
+
+func (*ConversationRequest_Cancel) isConversationRequest_Request() {}
+
+type ConversationResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The identifier of the request this is a response to.
+	Id uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Types that are valid to be assigned to Response:
+	//
+	//	*ConversationResponse_AvailableBrokers
+	//	*ConversationResponse_GetBroker
+	//	*ConversationResponse_SelectBroker
+	//	*ConversationResponse_GetAuthenticationModes
+	//	*ConversationResponse_SelectAuthenticationMode
+	//	*ConversationResponse_IsAuthenticated
+	//	*ConversationResponse_EndSession
+	Response isConversationResponse_Response `protobuf_oneof:"response"`
+	// The gRPC status code and message of the error, if the request failed.
+	ErrorCode     uint32 `protobuf:"varint,10,opt,name=error_code,json=errorCode,proto3" json:"error_code,omitempty"`
+	ErrorMessage  string `protobuf:"bytes,11,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *UnlockUserRequest) Reset() {
-	*x = UnlockUserRequest{}
-	mi := &file_authd_proto_msgTypes[18]
+func (x *ConversationResponse) Reset() {
+	*x = ConversationResponse{}
+	mi := &file_authd_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *UnlockUserRequest) String() string {
+func (x *ConversationResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*UnlockUserRequest) ProtoMessage() {}
+func (*ConversationResponse) ProtoMessage() {}
 
-func (x *UnlockUserRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[18]
+func (x *ConversationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1017,93 +1112,170 @@ func (x *UnlockUserRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use UnlockUserRequest.ProtoReflect.Descriptor instead.
-func (*UnlockUserRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{18}
+// Deprecated: Use ConversationResponse.ProtoReflect.Descriptor instead.
+func (*ConversationResponse) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{15}
 }
 
-func (x *UnlockUserRequest) GetName() string {
+func (x *ConversationResponse) GetId() uint64 {
 	if x != nil {
-		return x.Name
+		return x.Id
 	}
-	return ""
-}
-
-type DeleteUserRequest struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	// If true, remove the user's home directory.
-	RemoveHome    bool `protobuf:"varint,2,opt,name=remove_home,json=removeHome,proto3" json:"remove_home,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	return 0
 }
 
-func (x *DeleteUserRequest) Reset() {
-	*x = DeleteUserRequest{}
-	mi := &file_authd_proto_msgTypes[19]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *ConversationResponse) GetResponse() isConversationResponse_Response {
+	if x != nil {
+		return x.Response
+	}
+	return nil
 }
 
-func (x *DeleteUserRequest) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *ConversationResponse) GetAvailableBrokers() *ABResponse {
+	if x != nil {
+		if x, ok := x.Response.(*ConversationResponse_AvailableBrokers); ok {
+			return x.AvailableBrokers
+		}
+	}
+	return nil
 }
 
-func (*DeleteUserRequest) ProtoMessage() {}
-
-func (x *DeleteUserRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[19]
+func (x *ConversationResponse) GetGetBroker() *GBResponse {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
+		if x, ok := x.Response.(*ConversationResponse_GetBroker); ok {
+			return x.GetBroker
 		}
-		return ms
 	}
-	return mi.MessageOf(x)
+	return nil
 }
 
-// Deprecated: Use DeleteUserRequest.ProtoReflect.Descriptor instead.
-func (*DeleteUserRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{19}
+func (x *ConversationResponse) GetSelectBroker() *SBResponse {
+	if x != nil {
+		if x, ok := x.Response.(*ConversationResponse_SelectBroker); ok {
+			return x.SelectBroker
+		}
+	}
+	return nil
 }
 
-func (x *DeleteUserRequest) GetName() string {
+func (x *ConversationResponse) GetGetAuthenticationModes() *GAMResponse {
 	if x != nil {
-		return x.Name
+		if x, ok := x.Response.(*ConversationResponse_GetAuthenticationModes); ok {
+			return x.GetAuthenticationModes
+		}
 	}
-	return ""
+	return nil
 }
 
-func (x *DeleteUserRequest) GetRemoveHome() bool {
+func (x *ConversationResponse) GetSelectAuthenticationMode() *SAMResponse {
 	if x != nil {
-		return x.RemoveHome
+		if x, ok := x.Response.(*ConversationResponse_SelectAuthenticationMode); ok {
+			return x.SelectAuthenticationMode
+		}
 	}
-	return false
+	return nil
 }
 
-type DeleteGroupRequest struct {
+func (x *ConversationResponse) GetIsAuthenticated() *IAResponse {
+	if x != nil {
+		if x, ok := x.Response.(*ConversationResponse_IsAuthenticated); ok {
+			return x.IsAuthenticated
+		}
+	}
+	return nil
+}
+
+func (x *ConversationResponse) GetEndSession() *Empty {
+	if x != nil {
+		if x, ok := x.Response.(*ConversationResponse_EndSession); ok {
+			return x.EndSession
+		}
+	}
+	return nil
+}
+
+func (x *ConversationResponse) GetErrorCode() uint32 {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return 0
+}
+
+func (x *ConversationResponse) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
+type isConversationResponse_Response interface {
+	isConversationResponse_Response()
+}
+
+type ConversationResponse_AvailableBrokers struct {
+	AvailableBrokers *ABResponse `protobuf:"bytes,2,opt,name=available_brokers,json=availableBrokers,proto3,oneof"`
+}
+
+type ConversationResponse_GetBroker struct {
+	GetBroker *GBResponse `protobuf:"bytes,3,opt,name=get_broker,json=getBroker,proto3,oneof"`
+}
+
+type ConversationResponse_SelectBroker struct {
+	SelectBroker *SBResponse `protobuf:"bytes,4,opt,name=select_broker,json=selectBroker,proto3,oneof"`
+}
+
+type ConversationResponse_GetAuthenticationModes struct {
+	GetAuthenticationModes *GAMResponse `protobuf:"bytes,5,opt,name=get_authentication_modes,json=getAuthenticationModes,proto3,oneof"`
+}
+
+type ConversationResponse_SelectAuthenticationMode struct {
+	SelectAuthenticationMode *SAMResponse `protobuf:"bytes,6,opt,name=select_authentication_mode,json=selectAuthenticationMode,proto3,oneof"`
+}
+
+type ConversationResponse_IsAuthenticated struct {
+	IsAuthenticated *IAResponse `protobuf:"bytes,7,opt,name=is_authenticated,json=isAuthenticated,proto3,oneof"`
+}
+
+type ConversationResponse_EndSession struct {
+	EndSession *Empty `protobuf:"bytes,8,opt,name=end_session,json=endSession,proto3,oneof"`
+}
+
+func (*ConversationResponse_AvailableBrokers) isConversationResponse_Response() {}
+
+func (*ConversationResponse_GetBroker) isConversationResponse_Response() {}
+
+func (*ConversationResponse_SelectBroker) isConversationResponse_Response() {}
+
+func (*ConversationResponse_GetAuthenticationModes) isConversationResponse_Response() {}
+
+func (*ConversationResponse_SelectAuthenticationMode) isConversationResponse_Response() {}
+
+func (*ConversationResponse_IsAuthenticated) isConversationResponse_Response() {}
+
+func (*ConversationResponse_EndSession) isConversationResponse_Response() {}
+
+type ESRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *DeleteGroupRequest) Reset() {
-	*x = DeleteGroupRequest{}
-	mi := &file_authd_proto_msgTypes[20]
+func (x *ESRequest) Reset() {
+	*x = ESRequest{}
+	mi := &file_authd_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *DeleteGroupRequest) String() string {
+func (x *ESRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*DeleteGroupRequest) ProtoMessage() {}
+func (*ESRequest) ProtoMessage() {}
 
-func (x *DeleteGroupRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[20]
+func (x *ESRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1114,40 +1286,45 @@ func (x *DeleteGroupRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use DeleteGroupRequest.ProtoReflect.Descriptor instead.
-func (*DeleteGroupRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{20}
+// Deprecated: Use ESRequest.ProtoReflect.Descriptor instead.
+func (*ESRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{16}
 }
 
-func (x *DeleteGroupRequest) GetName() string {
+func (x *ESRequest) GetSessionId() string {
 	if x != nil {
-		return x.Name
+		return x.SessionId
 	}
 	return ""
 }
 
-type GetGroupByNameRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+type NSRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Event    NSRequest_Event        `protobuf:"varint,1,opt,name=event,proto3,enum=authd.NSRequest_Event" json:"event,omitempty"`
+	Username string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	// The PAM service the session is opened for (e.g. "sshd" or "gdm-authd").
+	Service       string `protobuf:"bytes,3,opt,name=service,proto3" json:"service,omitempty"`
+	Tty           string `protobuf:"bytes,4,opt,name=tty,proto3" json:"tty,omitempty"`
+	Rhost         string `protobuf:"bytes,5,opt,name=rhost,proto3" json:"rhost,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetGroupByNameRequest) Reset() {
-	*x = GetGroupByNameRequest{}
-	mi := &file_authd_proto_msgTypes[21]
+func (x *NSRequest) Reset() {
+	*x = NSRequest{}
+	mi := &file_authd_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetGroupByNameRequest) String() string {
+func (x *NSRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetGroupByNameRequest) ProtoMessage() {}
+func (*NSRequest) ProtoMessage() {}
 
-func (x *GetGroupByNameRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[21]
+func (x *NSRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1158,40 +1335,84 @@ func (x *GetGroupByNameRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetGroupByNameRequest.ProtoReflect.Descriptor instead.
-func (*GetGroupByNameRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{21}
+// Deprecated: Use NSRequest.ProtoReflect.Descriptor instead.
+func (*NSRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{17}
 }
 
-func (x *GetGroupByNameRequest) GetName() string {
+func (x *NSRequest) GetEvent() NSRequest_Event {
 	if x != nil {
-		return x.Name
+		return x.Event
+	}
+	return NSRequest_UNSPECIFIED
+}
+
+func (x *NSRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
 	}
 	return ""
 }
 
-type GetGroupByIDRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+func (x *NSRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *NSRequest) GetTty() string {
+	if x != nil {
+		return x.Tty
+	}
+	return ""
+}
+
+func (x *NSRequest) GetRhost() string {
+	if x != nil {
+		return x.Rhost
+	}
+	return ""
+}
+
+type NSResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The environment variables to export in the session, only set when the session starts.
+	Env map[string]string `protobuf:"bytes,1,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The temporary home of an ephemeral user, only set when the first session of the user starts, so that it's
+	// mounted, and when the last one stops, so that it's destroyed.
+	EphemeralHome *EphemeralHome `protobuf:"bytes,2,opt,name=ephemeral_home,json=ephemeralHome,proto3" json:"ephemeral_home,omitempty"`
+	// The home of a user whose home is provided by a network file system, only set when the session starts, so that
+	// it's mounted and checked instead of creating an empty local home.
+	NetworkHome *NetworkHome `protobuf:"bytes,3,opt,name=network_home,json=networkHome,proto3" json:"network_home,omitempty"`
+	// The home of a user created with systemd-homed, only set when the first session of the user starts, so that it's
+	// activated, and when the last one stops, so that it's deactivated.
+	HomedHome *HomedHome `protobuf:"bytes,4,opt,name=homed_home,json=homedHome,proto3" json:"homed_home,omitempty"`
+	// The home of a user whose home is encrypted with fscrypt, only set when the session starts, so that it's created
+	// and encrypted on the first login of the user, or unlocked with their local password.
+	FscryptHome *FscryptHome `protobuf:"bytes,5,opt,name=fscrypt_home,json=fscryptHome,proto3" json:"fscrypt_home,omitempty"`
+	// The home of a user whose broker sets how the homes are created, only set when the session starts, so that it's
+	// created if it doesn't exist yet.
+	LocalHome     *LocalHome `protobuf:"bytes,6,opt,name=local_home,json=localHome,proto3" json:"local_home,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetGroupByIDRequest) Reset() {
-	*x = GetGroupByIDRequest{}
-	mi := &file_authd_proto_msgTypes[22]
+func (x *NSResponse) Reset() {
+	*x = NSResponse{}
+	mi := &file_authd_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetGroupByIDRequest) String() string {
+func (x *NSResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetGroupByIDRequest) ProtoMessage() {}
+func (*NSResponse) ProtoMessage() {}
 
-func (x *GetGroupByIDRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[22]
+func (x *NSResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1202,44 +1423,77 @@ func (x *GetGroupByIDRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetGroupByIDRequest.ProtoReflect.Descriptor instead.
-func (*GetGroupByIDRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{22}
+// Deprecated: Use NSResponse.ProtoReflect.Descriptor instead.
+func (*NSResponse) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{18}
 }
 
-func (x *GetGroupByIDRequest) GetId() uint32 {
+func (x *NSResponse) GetEnv() map[string]string {
 	if x != nil {
-		return x.Id
+		return x.Env
 	}
-	return 0
+	return nil
 }
 
-type SetUserIDRequest struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Id    uint32                 `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
-	// The language to use for any warnings returned.
-	// Note: This is currently not implemented and warnings are always in English.
-	Lang          string `protobuf:"bytes,3,opt,name=lang,proto3" json:"lang,omitempty"`
+func (x *NSResponse) GetEphemeralHome() *EphemeralHome {
+	if x != nil {
+		return x.EphemeralHome
+	}
+	return nil
+}
+
+func (x *NSResponse) GetNetworkHome() *NetworkHome {
+	if x != nil {
+		return x.NetworkHome
+	}
+	return nil
+}
+
+func (x *NSResponse) GetHomedHome() *HomedHome {
+	if x != nil {
+		return x.HomedHome
+	}
+	return nil
+}
+
+func (x *NSResponse) GetFscryptHome() *FscryptHome {
+	if x != nil {
+		return x.FscryptHome
+	}
+	return nil
+}
+
+func (x *NSResponse) GetLocalHome() *LocalHome {
+	if x != nil {
+		return x.LocalHome
+	}
+	return nil
+}
+
+type EphemeralHome struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Uid           uint32                 `protobuf:"varint,2,opt,name=uid,proto3" json:"uid,omitempty"`
+	Gid           uint32                 `protobuf:"varint,3,opt,name=gid,proto3" json:"gid,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SetUserIDRequest) Reset() {
-	*x = SetUserIDRequest{}
-	mi := &file_authd_proto_msgTypes[23]
+func (x *EphemeralHome) Reset() {
+	*x = EphemeralHome{}
+	mi := &file_authd_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SetUserIDRequest) String() string {
+func (x *EphemeralHome) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SetUserIDRequest) ProtoMessage() {}
+func (*EphemeralHome) ProtoMessage() {}
 
-func (x *SetUserIDRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[23]
+func (x *EphemeralHome) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1250,56 +1504,59 @@ func (x *SetUserIDRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SetUserIDRequest.ProtoReflect.Descriptor instead.
-func (*SetUserIDRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{23}
+// Deprecated: Use EphemeralHome.ProtoReflect.Descriptor instead.
+func (*EphemeralHome) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{19}
 }
 
-func (x *SetUserIDRequest) GetName() string {
+func (x *EphemeralHome) GetPath() string {
 	if x != nil {
-		return x.Name
+		return x.Path
 	}
 	return ""
 }
 
-func (x *SetUserIDRequest) GetId() uint32 {
+func (x *EphemeralHome) GetUid() uint32 {
 	if x != nil {
-		return x.Id
+		return x.Uid
 	}
 	return 0
 }
 
-func (x *SetUserIDRequest) GetLang() string {
+func (x *EphemeralHome) GetGid() uint32 {
 	if x != nil {
-		return x.Lang
+		return x.Gid
 	}
-	return ""
+	return 0
 }
 
-type SetUserIDResponse struct {
-	state               protoimpl.MessageState `protogen:"open.v1"`
-	IdChanged           bool                   `protobuf:"varint,1,opt,name=id_changed,json=idChanged,proto3" json:"id_changed,omitempty"`
-	HomeDirOwnerChanged bool                   `protobuf:"varint,2,opt,name=home_dir_owner_changed,json=homeDirOwnerChanged,proto3" json:"home_dir_owner_changed,omitempty"`
-	Warnings            []string               `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
-	unknownFields       protoimpl.UnknownFields
-	sizeCache           protoimpl.SizeCache
+type NetworkHome struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Path  string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// The UID which must own the home.
+	Uid uint32 `protobuf:"varint,2,opt,name=uid,proto3" json:"uid,omitempty"`
+	// The systemd mount unit to start before checking the home, if any. Without it, accessing the home triggers the
+	// automount if there is one.
+	MountUnit     string `protobuf:"bytes,3,opt,name=mount_unit,json=mountUnit,proto3" json:"mount_unit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SetUserIDResponse) Reset() {
-	*x = SetUserIDResponse{}
-	mi := &file_authd_proto_msgTypes[24]
+func (x *NetworkHome) Reset() {
+	*x = NetworkHome{}
+	mi := &file_authd_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SetUserIDResponse) String() string {
+func (x *NetworkHome) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SetUserIDResponse) ProtoMessage() {}
+func (*NetworkHome) ProtoMessage() {}
 
-func (x *SetUserIDResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[24]
+func (x *NetworkHome) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1310,58 +1567,56 @@ func (x *SetUserIDResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SetUserIDResponse.ProtoReflect.Descriptor instead.
-func (*SetUserIDResponse) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{24}
+// Deprecated: Use NetworkHome.ProtoReflect.Descriptor instead.
+func (*NetworkHome) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{20}
 }
 
-func (x *SetUserIDResponse) GetIdChanged() bool {
+func (x *NetworkHome) GetPath() string {
 	if x != nil {
-		return x.IdChanged
+		return x.Path
 	}
-	return false
+	return ""
 }
 
-func (x *SetUserIDResponse) GetHomeDirOwnerChanged() bool {
+func (x *NetworkHome) GetUid() uint32 {
 	if x != nil {
-		return x.HomeDirOwnerChanged
+		return x.Uid
 	}
-	return false
+	return 0
 }
 
-func (x *SetUserIDResponse) GetWarnings() []string {
+func (x *NetworkHome) GetMountUnit() string {
 	if x != nil {
-		return x.Warnings
+		return x.MountUnit
 	}
-	return nil
+	return ""
 }
 
-type SetGroupIDRequest struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Id    uint32                 `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
-	// The language to use for any warnings returned.
-	// Note: This is currently not implemented and warnings are always in English.
-	Lang          string `protobuf:"bytes,3,opt,name=lang,proto3" json:"lang,omitempty"`
+type HomedHome struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	UserName string                 `protobuf:"bytes,1,opt,name=user_name,json=userName,proto3" json:"user_name,omitempty"`
+	// The secret activating the home, only set when the session starts.
+	Secret        string `protobuf:"bytes,2,opt,name=secret,proto3" json:"secret,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SetGroupIDRequest) Reset() {
-	*x = SetGroupIDRequest{}
-	mi := &file_authd_proto_msgTypes[25]
+func (x *HomedHome) Reset() {
+	*x = HomedHome{}
+	mi := &file_authd_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SetGroupIDRequest) String() string {
+func (x *HomedHome) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SetGroupIDRequest) ProtoMessage() {}
+func (*HomedHome) ProtoMessage() {}
 
-func (x *SetGroupIDRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[25]
+func (x *HomedHome) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1372,56 +1627,50 @@ func (x *SetGroupIDRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SetGroupIDRequest.ProtoReflect.Descriptor instead.
-func (*SetGroupIDRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{25}
+// Deprecated: Use HomedHome.ProtoReflect.Descriptor instead.
+func (*HomedHome) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{21}
 }
 
-func (x *SetGroupIDRequest) GetName() string {
+func (x *HomedHome) GetUserName() string {
 	if x != nil {
-		return x.Name
+		return x.UserName
 	}
 	return ""
 }
 
-func (x *SetGroupIDRequest) GetId() uint32 {
+func (x *HomedHome) GetSecret() string {
 	if x != nil {
-		return x.Id
+		return x.Secret
 	}
-	return 0
+	return ""
 }
 
-func (x *SetGroupIDRequest) GetLang() string {
-	if x != nil {
-		return x.Lang
-	}
-	return ""
-}
-
-type SetGroupIDResponse struct {
-	state               protoimpl.MessageState `protogen:"open.v1"`
-	IdChanged           bool                   `protobuf:"varint,1,opt,name=id_changed,json=idChanged,proto3" json:"id_changed,omitempty"`
-	HomeDirOwnerChanged bool                   `protobuf:"varint,2,opt,name=home_dir_owner_changed,json=homeDirOwnerChanged,proto3" json:"home_dir_owner_changed,omitempty"`
-	Warnings            []string               `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
-	unknownFields       protoimpl.UnknownFields
-	sizeCache           protoimpl.SizeCache
+type FscryptHome struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	UserName      string                 `protobuf:"bytes,2,opt,name=user_name,json=userName,proto3" json:"user_name,omitempty"`
+	Uid           uint32                 `protobuf:"varint,3,opt,name=uid,proto3" json:"uid,omitempty"`
+	Gid           uint32                 `protobuf:"varint,4,opt,name=gid,proto3" json:"gid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SetGroupIDResponse) Reset() {
-	*x = SetGroupIDResponse{}
-	mi := &file_authd_proto_msgTypes[26]
+func (x *FscryptHome) Reset() {
+	*x = FscryptHome{}
+	mi := &file_authd_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SetGroupIDResponse) String() string {
+func (x *FscryptHome) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SetGroupIDResponse) ProtoMessage() {}
+func (*FscryptHome) ProtoMessage() {}
 
-func (x *SetGroupIDResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[26]
+func (x *FscryptHome) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1432,55 +1681,77 @@ func (x *SetGroupIDResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SetGroupIDResponse.ProtoReflect.Descriptor instead.
-func (*SetGroupIDResponse) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{26}
+// Deprecated: Use FscryptHome.ProtoReflect.Descriptor instead.
+func (*FscryptHome) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{22}
 }
 
-func (x *SetGroupIDResponse) GetIdChanged() bool {
+func (x *FscryptHome) GetPath() string {
 	if x != nil {
-		return x.IdChanged
+		return x.Path
 	}
-	return false
+	return ""
 }
 
-func (x *SetGroupIDResponse) GetHomeDirOwnerChanged() bool {
+func (x *FscryptHome) GetUserName() string {
 	if x != nil {
-		return x.HomeDirOwnerChanged
+		return x.UserName
 	}
-	return false
+	return ""
 }
 
-func (x *SetGroupIDResponse) GetWarnings() []string {
+func (x *FscryptHome) GetUid() uint32 {
 	if x != nil {
-		return x.Warnings
+		return x.Uid
 	}
-	return nil
+	return 0
 }
 
-type SetShellRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Shell         string                 `protobuf:"bytes,2,opt,name=shell,proto3" json:"shell,omitempty"`
+func (x *FscryptHome) GetGid() uint32 {
+	if x != nil {
+		return x.Gid
+	}
+	return 0
+}
+
+type LocalHome struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Path  string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Uid   uint32                 `protobuf:"varint,2,opt,name=uid,proto3" json:"uid,omitempty"`
+	// The primary group of the user, which owns the files of the home.
+	Gid uint32 `protobuf:"varint,3,opt,name=gid,proto3" json:"gid,omitempty"`
+	// The group owning the home itself.
+	HomeGid uint32 `protobuf:"varint,4,opt,name=home_gid,json=homeGid,proto3" json:"home_gid,omitempty"`
+	// The permissions of the home and of its subdirectories.
+	Mode uint32 `protobuf:"varint,5,opt,name=mode,proto3" json:"mode,omitempty"`
+	// The directory whose content is copied to the home.
+	Skel string `protobuf:"bytes,6,opt,name=skel,proto3" json:"skel,omitempty"`
+	// The directories created in the home, relative to it.
+	Subdirs []string `protobuf:"bytes,7,rep,name=subdirs,proto3" json:"subdirs,omitempty"`
+	// The limit of the disk space of the user, in bytes, or 0 for no limit.
+	Quota uint64 `protobuf:"varint,8,opt,name=quota,proto3" json:"quota,omitempty"`
+	// Whether the limit is a project quota on the home, whose project ID is the UID of the user, rather than a user
+	// quota.
+	ProjectQuota  bool `protobuf:"varint,9,opt,name=project_quota,json=projectQuota,proto3" json:"project_quota,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SetShellRequest) Reset() {
-	*x = SetShellRequest{}
-	mi := &file_authd_proto_msgTypes[27]
+func (x *LocalHome) Reset() {
+	*x = LocalHome{}
+	mi := &file_authd_proto_msgTypes[23]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SetShellRequest) String() string {
+func (x *LocalHome) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SetShellRequest) ProtoMessage() {}
+func (*LocalHome) ProtoMessage() {}
 
-func (x *SetShellRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[27]
+func (x *LocalHome) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[23]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1491,47 +1762,97 @@ func (x *SetShellRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SetShellRequest.ProtoReflect.Descriptor instead.
-func (*SetShellRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{27}
+// Deprecated: Use LocalHome.ProtoReflect.Descriptor instead.
+func (*LocalHome) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{23}
 }
 
-func (x *SetShellRequest) GetName() string {
+func (x *LocalHome) GetPath() string {
 	if x != nil {
-		return x.Name
+		return x.Path
 	}
 	return ""
 }
 
-func (x *SetShellRequest) GetShell() string {
+func (x *LocalHome) GetUid() uint32 {
 	if x != nil {
-		return x.Shell
+		return x.Uid
+	}
+	return 0
+}
+
+func (x *LocalHome) GetGid() uint32 {
+	if x != nil {
+		return x.Gid
+	}
+	return 0
+}
+
+func (x *LocalHome) GetHomeGid() uint32 {
+	if x != nil {
+		return x.HomeGid
+	}
+	return 0
+}
+
+func (x *LocalHome) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+func (x *LocalHome) GetSkel() string {
+	if x != nil {
+		return x.Skel
 	}
 	return ""
 }
 
-type SetShellResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Warnings      []string               `protobuf:"bytes,1,rep,name=warnings,proto3" json:"warnings,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *LocalHome) GetSubdirs() []string {
+	if x != nil {
+		return x.Subdirs
+	}
+	return nil
 }
 
-func (x *SetShellResponse) Reset() {
-	*x = SetShellResponse{}
-	mi := &file_authd_proto_msgTypes[28]
+func (x *LocalHome) GetQuota() uint64 {
+	if x != nil {
+		return x.Quota
+	}
+	return 0
+}
+
+func (x *LocalHome) GetProjectQuota() bool {
+	if x != nil {
+		return x.ProjectQuota
+	}
+	return false
+}
+
+type GetUserByNameRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Name           string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	ShouldPreCheck bool                   `protobuf:"varint,2,opt,name=shouldPreCheck,proto3" json:"shouldPreCheck,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetUserByNameRequest) Reset() {
+	*x = GetUserByNameRequest{}
+	mi := &file_authd_proto_msgTypes[24]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SetShellResponse) String() string {
+func (x *GetUserByNameRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SetShellResponse) ProtoMessage() {}
+func (*GetUserByNameRequest) ProtoMessage() {}
 
-func (x *SetShellResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[28]
+func (x *GetUserByNameRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[24]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1542,41 +1863,47 @@ func (x *SetShellResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SetShellResponse.ProtoReflect.Descriptor instead.
-func (*SetShellResponse) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{28}
+// Deprecated: Use GetUserByNameRequest.ProtoReflect.Descriptor instead.
+func (*GetUserByNameRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{24}
 }
 
-func (x *SetShellResponse) GetWarnings() []string {
+func (x *GetUserByNameRequest) GetName() string {
 	if x != nil {
-		return x.Warnings
+		return x.Name
 	}
-	return nil
+	return ""
 }
 
-type SetHomeDirRequest struct {
+func (x *GetUserByNameRequest) GetShouldPreCheck() bool {
+	if x != nil {
+		return x.ShouldPreCheck
+	}
+	return false
+}
+
+type GetUserByIDRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Home          string                 `protobuf:"bytes,2,opt,name=home,proto3" json:"home,omitempty"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SetHomeDirRequest) Reset() {
-	*x = SetHomeDirRequest{}
-	mi := &file_authd_proto_msgTypes[29]
+func (x *GetUserByIDRequest) Reset() {
+	*x = GetUserByIDRequest{}
+	mi := &file_authd_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SetHomeDirRequest) String() string {
+func (x *GetUserByIDRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SetHomeDirRequest) ProtoMessage() {}
+func (*GetUserByIDRequest) ProtoMessage() {}
 
-func (x *SetHomeDirRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[29]
+func (x *GetUserByIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1587,49 +1914,43 @@ func (x *SetHomeDirRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SetHomeDirRequest.ProtoReflect.Descriptor instead.
-func (*SetHomeDirRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{29}
-}
-
-func (x *SetHomeDirRequest) GetName() string {
-	if x != nil {
-		return x.Name
-	}
-	return ""
+// Deprecated: Use GetUserByIDRequest.ProtoReflect.Descriptor instead.
+func (*GetUserByIDRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{25}
 }
 
-func (x *SetHomeDirRequest) GetHome() string {
+func (x *GetUserByIDRequest) GetId() uint32 {
 	if x != nil {
-		return x.Home
+		return x.Id
 	}
-	return ""
+	return 0
 }
 
-type SetHomeDirResponse struct {
-	state          protoimpl.MessageState `protogen:"open.v1"`
-	HomeDirChanged bool                   `protobuf:"varint,1,opt,name=home_dir_changed,json=homeDirChanged,proto3" json:"home_dir_changed,omitempty"`
-	HomeDirMoved   bool                   `protobuf:"varint,2,opt,name=home_dir_moved,json=homeDirMoved,proto3" json:"home_dir_moved,omitempty"`
-	Warnings       []string               `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+type ListUsersRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The maximum number of users to return. 0 means all users.
+	PageSize uint32 `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// The next_page_token of the previous response, to get the next page.
+	PageToken     string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SetHomeDirResponse) Reset() {
-	*x = SetHomeDirResponse{}
-	mi := &file_authd_proto_msgTypes[30]
+func (x *ListUsersRequest) Reset() {
+	*x = ListUsersRequest{}
+	mi := &file_authd_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SetHomeDirResponse) String() string {
+func (x *ListUsersRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SetHomeDirResponse) ProtoMessage() {}
+func (*ListUsersRequest) ProtoMessage() {}
 
-func (x *SetHomeDirResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[30]
+func (x *ListUsersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1640,54 +1961,50 @@ func (x *SetHomeDirResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SetHomeDirResponse.ProtoReflect.Descriptor instead.
-func (*SetHomeDirResponse) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{30}
-}
-
-func (x *SetHomeDirResponse) GetHomeDirChanged() bool {
-	if x != nil {
-		return x.HomeDirChanged
-	}
-	return false
+// Deprecated: Use ListUsersRequest.ProtoReflect.Descriptor instead.
+func (*ListUsersRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{26}
 }
 
-func (x *SetHomeDirResponse) GetHomeDirMoved() bool {
+func (x *ListUsersRequest) GetPageSize() uint32 {
 	if x != nil {
-		return x.HomeDirMoved
+		return x.PageSize
 	}
-	return false
+	return 0
 }
 
-func (x *SetHomeDirResponse) GetWarnings() []string {
+func (x *ListUsersRequest) GetPageToken() string {
 	if x != nil {
-		return x.Warnings
+		return x.PageToken
 	}
-	return nil
+	return ""
 }
 
-type DeleteUserResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Warnings      []string               `protobuf:"bytes,1,rep,name=warnings,proto3" json:"warnings,omitempty"`
+type ListGroupsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The maximum number of groups to return. 0 means all groups.
+	PageSize uint32 `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// The next_page_token of the previous response, to get the next page.
+	PageToken     string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *DeleteUserResponse) Reset() {
-	*x = DeleteUserResponse{}
-	mi := &file_authd_proto_msgTypes[31]
+func (x *ListGroupsRequest) Reset() {
+	*x = ListGroupsRequest{}
+	mi := &file_authd_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *DeleteUserResponse) String() string {
+func (x *ListGroupsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*DeleteUserResponse) ProtoMessage() {}
+func (*ListGroupsRequest) ProtoMessage() {}
 
-func (x *DeleteUserResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[31]
+func (x *ListGroupsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1698,45 +2015,47 @@ func (x *DeleteUserResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use DeleteUserResponse.ProtoReflect.Descriptor instead.
-func (*DeleteUserResponse) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{31}
+// Deprecated: Use ListGroupsRequest.ProtoReflect.Descriptor instead.
+func (*ListGroupsRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{27}
 }
 
-func (x *DeleteUserResponse) GetWarnings() []string {
+func (x *ListGroupsRequest) GetPageSize() uint32 {
 	if x != nil {
-		return x.Warnings
+		return x.PageSize
 	}
-	return nil
+	return 0
 }
 
-type User struct {
+func (x *ListGroupsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type GetGroupsForUserRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Uid           uint32                 `protobuf:"varint,2,opt,name=uid,proto3" json:"uid,omitempty"`
-	Gid           uint32                 `protobuf:"varint,3,opt,name=gid,proto3" json:"gid,omitempty"`
-	Gecos         string                 `protobuf:"bytes,4,opt,name=gecos,proto3" json:"gecos,omitempty"`
-	Homedir       string                 `protobuf:"bytes,5,opt,name=homedir,proto3" json:"homedir,omitempty"`
-	Shell         string                 `protobuf:"bytes,6,opt,name=shell,proto3" json:"shell,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *User) Reset() {
-	*x = User{}
-	mi := &file_authd_proto_msgTypes[32]
+func (x *GetGroupsForUserRequest) Reset() {
+	*x = GetGroupsForUserRequest{}
+	mi := &file_authd_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *User) String() string {
+func (x *GetGroupsForUserRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*User) ProtoMessage() {}
+func (*GetGroupsForUserRequest) ProtoMessage() {}
 
-func (x *User) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[32]
+func (x *GetGroupsForUserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1747,74 +2066,233 @@ func (x *User) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use User.ProtoReflect.Descriptor instead.
-func (*User) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{32}
+// Deprecated: Use GetGroupsForUserRequest.ProtoReflect.Descriptor instead.
+func (*GetGroupsForUserRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{28}
 }
 
-func (x *User) GetName() string {
+func (x *GetGroupsForUserRequest) GetName() string {
 	if x != nil {
 		return x.Name
 	}
 	return ""
 }
 
-func (x *User) GetUid() uint32 {
+type LockUserRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LockUserRequest) Reset() {
+	*x = LockUserRequest{}
+	mi := &file_authd_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LockUserRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LockUserRequest) ProtoMessage() {}
+
+func (x *LockUserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[29]
 	if x != nil {
-		return x.Uid
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return 0
+	return mi.MessageOf(x)
 }
 
-func (x *User) GetGid() uint32 {
+// Deprecated: Use LockUserRequest.ProtoReflect.Descriptor instead.
+func (*LockUserRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *LockUserRequest) GetName() string {
 	if x != nil {
-		return x.Gid
+		return x.Name
 	}
-	return 0
+	return ""
 }
 
-func (x *User) GetGecos() string {
+type UnlockUserRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnlockUserRequest) Reset() {
+	*x = UnlockUserRequest{}
+	mi := &file_authd_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnlockUserRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnlockUserRequest) ProtoMessage() {}
+
+func (x *UnlockUserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[30]
 	if x != nil {
-		return x.Gecos
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnlockUserRequest.ProtoReflect.Descriptor instead.
+func (*UnlockUserRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *UnlockUserRequest) GetName() string {
+	if x != nil {
+		return x.Name
 	}
 	return ""
 }
 
-func (x *User) GetHomedir() string {
+type DeleteUserRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// If true, remove the user's home directory.
+	RemoveHome bool `protobuf:"varint,2,opt,name=remove_home,json=removeHome,proto3" json:"remove_home,omitempty"`
+	// If true, move the user's home directory to the archives of authd. Can't be combined with remove_home.
+	ArchiveHome   bool `protobuf:"varint,3,opt,name=archive_home,json=archiveHome,proto3" json:"archive_home,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteUserRequest) Reset() {
+	*x = DeleteUserRequest{}
+	mi := &file_authd_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteUserRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteUserRequest) ProtoMessage() {}
+
+func (x *DeleteUserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[31]
 	if x != nil {
-		return x.Homedir
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteUserRequest.ProtoReflect.Descriptor instead.
+func (*DeleteUserRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *DeleteUserRequest) GetName() string {
+	if x != nil {
+		return x.Name
 	}
 	return ""
 }
 
-func (x *User) GetShell() string {
+func (x *DeleteUserRequest) GetRemoveHome() bool {
 	if x != nil {
-		return x.Shell
+		return x.RemoveHome
+	}
+	return false
+}
+
+func (x *DeleteUserRequest) GetArchiveHome() bool {
+	if x != nil {
+		return x.ArchiveHome
+	}
+	return false
+}
+
+type DisableUserRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DisableUserRequest) Reset() {
+	*x = DisableUserRequest{}
+	mi := &file_authd_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DisableUserRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DisableUserRequest) ProtoMessage() {}
+
+func (x *DisableUserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DisableUserRequest.ProtoReflect.Descriptor instead.
+func (*DisableUserRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *DisableUserRequest) GetName() string {
+	if x != nil {
+		return x.Name
 	}
 	return ""
 }
 
-type Users struct {
+type ArchiveUserRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Users         []*User                `protobuf:"bytes,1,rep,name=users,proto3" json:"users,omitempty"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Users) Reset() {
-	*x = Users{}
+func (x *ArchiveUserRequest) Reset() {
+	*x = ArchiveUserRequest{}
 	mi := &file_authd_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Users) String() string {
+func (x *ArchiveUserRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Users) ProtoMessage() {}
+func (*ArchiveUserRequest) ProtoMessage() {}
 
-func (x *Users) ProtoReflect() protoreflect.Message {
+func (x *ArchiveUserRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_authd_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1826,43 +2304,39 @@ func (x *Users) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Users.ProtoReflect.Descriptor instead.
-func (*Users) Descriptor() ([]byte, []int) {
+// Deprecated: Use ArchiveUserRequest.ProtoReflect.Descriptor instead.
+func (*ArchiveUserRequest) Descriptor() ([]byte, []int) {
 	return file_authd_proto_rawDescGZIP(), []int{33}
 }
 
-func (x *Users) GetUsers() []*User {
+func (x *ArchiveUserRequest) GetName() string {
 	if x != nil {
-		return x.Users
+		return x.Name
 	}
-	return nil
+	return ""
 }
 
-type Group struct {
-	state   protoimpl.MessageState `protogen:"open.v1"`
-	Name    string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Gid     uint32                 `protobuf:"varint,2,opt,name=gid,proto3" json:"gid,omitempty"`
-	Members []string               `protobuf:"bytes,3,rep,name=members,proto3" json:"members,omitempty"`
-	// TODO: Rename this to tmpID
-	Passwd        string `protobuf:"bytes,4,opt,name=passwd,proto3" json:"passwd,omitempty"`
+type RestoreUserRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Group) Reset() {
-	*x = Group{}
+func (x *RestoreUserRequest) Reset() {
+	*x = RestoreUserRequest{}
 	mi := &file_authd_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Group) String() string {
+func (x *RestoreUserRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Group) ProtoMessage() {}
+func (*RestoreUserRequest) ProtoMessage() {}
 
-func (x *Group) ProtoReflect() protoreflect.Message {
+func (x *RestoreUserRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_authd_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -1874,61 +2348,4613 @@ func (x *Group) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Group.ProtoReflect.Descriptor instead.
-func (*Group) Descriptor() ([]byte, []int) {
+// Deprecated: Use RestoreUserRequest.ProtoReflect.Descriptor instead.
+func (*RestoreUserRequest) Descriptor() ([]byte, []int) {
 	return file_authd_proto_rawDescGZIP(), []int{34}
 }
 
-func (x *Group) GetName() string {
+func (x *RestoreUserRequest) GetName() string {
 	if x != nil {
 		return x.Name
 	}
 	return ""
 }
 
-func (x *Group) GetGid() uint32 {
+type GetUserLifecycleRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserLifecycleRequest) Reset() {
+	*x = GetUserLifecycleRequest{}
+	mi := &file_authd_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserLifecycleRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserLifecycleRequest) ProtoMessage() {}
+
+func (x *GetUserLifecycleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[35]
 	if x != nil {
-		return x.Gid
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return 0
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserLifecycleRequest.ProtoReflect.Descriptor instead.
+func (*GetUserLifecycleRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *GetUserLifecycleRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
 }
 
-func (x *Group) GetMembers() []string {
-	if x != nil {
-		return x.Members
-	}
-	return nil
-}
+type GetLoginHistoryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// The maximum number of records to return. 0 means all stored records.
+	Limit         uint32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetLoginHistoryRequest) Reset() {
+	*x = GetLoginHistoryRequest{}
+	mi := &file_authd_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetLoginHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLoginHistoryRequest) ProtoMessage() {}
+
+func (x *GetLoginHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLoginHistoryRequest.ProtoReflect.Descriptor instead.
+func (*GetLoginHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *GetLoginHistoryRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *GetLoginHistoryRequest) GetLimit() uint32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type DeleteGroupRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteGroupRequest) Reset() {
+	*x = DeleteGroupRequest{}
+	mi := &file_authd_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteGroupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteGroupRequest) ProtoMessage() {}
+
+func (x *DeleteGroupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteGroupRequest.ProtoReflect.Descriptor instead.
+func (*DeleteGroupRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *DeleteGroupRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type SetDefaultBrokerRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The name or the ID of the broker.
+	Broker string `protobuf:"bytes,1,opt,name=broker,proto3" json:"broker,omitempty"`
+	// The PAM service using the broker for users who never logged in. If empty, the broker is used by all the services
+	// without a default broker of their own.
+	Service       string `protobuf:"bytes,2,opt,name=service,proto3" json:"service,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetDefaultBrokerRequest) Reset() {
+	*x = SetDefaultBrokerRequest{}
+	mi := &file_authd_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetDefaultBrokerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetDefaultBrokerRequest) ProtoMessage() {}
+
+func (x *SetDefaultBrokerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetDefaultBrokerRequest.ProtoReflect.Descriptor instead.
+func (*SetDefaultBrokerRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *SetDefaultBrokerRequest) GetBroker() string {
+	if x != nil {
+		return x.Broker
+	}
+	return ""
+}
+
+func (x *SetDefaultBrokerRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+type GetBrokerDetailsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The name or the ID of the broker.
+	Broker        string `protobuf:"bytes,1,opt,name=broker,proto3" json:"broker,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetBrokerDetailsRequest) Reset() {
+	*x = GetBrokerDetailsRequest{}
+	mi := &file_authd_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBrokerDetailsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBrokerDetailsRequest) ProtoMessage() {}
+
+func (x *GetBrokerDetailsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBrokerDetailsRequest.ProtoReflect.Descriptor instead.
+func (*GetBrokerDetailsRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *GetBrokerDetailsRequest) GetBroker() string {
+	if x != nil {
+		return x.Broker
+	}
+	return ""
+}
+
+type CheckBrokerRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The name or the ID of the broker.
+	Broker        string `protobuf:"bytes,1,opt,name=broker,proto3" json:"broker,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckBrokerRequest) Reset() {
+	*x = CheckBrokerRequest{}
+	mi := &file_authd_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckBrokerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckBrokerRequest) ProtoMessage() {}
+
+func (x *CheckBrokerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckBrokerRequest.ProtoReflect.Descriptor instead.
+func (*CheckBrokerRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *CheckBrokerRequest) GetBroker() string {
+	if x != nil {
+		return x.Broker
+	}
+	return ""
+}
+
+type VerifyUserRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyUserRequest) Reset() {
+	*x = VerifyUserRequest{}
+	mi := &file_authd_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyUserRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyUserRequest) ProtoMessage() {}
+
+func (x *VerifyUserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyUserRequest.ProtoReflect.Descriptor instead.
+func (*VerifyUserRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *VerifyUserRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// UserVerification is the state of the account of a user at their identity provider, checked online by their broker.
+type UserVerification struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// One of "active", "disabled", "not_allowed" or "unknown". Users who are active at the provider but not members of
+	// any group allowed by the login policy of the machine are "not_allowed".
+	Status string `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	// Details about the status, from the broker or from authd.
+	Message string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// The name of the broker which verified the user.
+	Broker string `protobuf:"bytes,3,opt,name=broker,proto3" json:"broker,omitempty"`
+	// Whether the user is locked in authd, which denies their logins whatever their status at the provider.
+	Locked        bool `protobuf:"varint,4,opt,name=locked,proto3" json:"locked,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserVerification) Reset() {
+	*x = UserVerification{}
+	mi := &file_authd_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserVerification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserVerification) ProtoMessage() {}
+
+func (x *UserVerification) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserVerification.ProtoReflect.Descriptor instead.
+func (*UserVerification) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *UserVerification) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *UserVerification) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *UserVerification) GetBroker() string {
+	if x != nil {
+		return x.Broker
+	}
+	return ""
+}
+
+func (x *UserVerification) GetLocked() bool {
+	if x != nil {
+		return x.Locked
+	}
+	return false
+}
+
+// GetSessionTokenRequest asks for an access token of the calling user, who is identified by the credentials of the
+// connection.
+type GetSessionTokenRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The scopes for which the token is requested. They must all be approved in the configuration of the broker.
+	Scopes        []string `protobuf:"bytes,1,rep,name=scopes,proto3" json:"scopes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSessionTokenRequest) Reset() {
+	*x = GetSessionTokenRequest{}
+	mi := &file_authd_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSessionTokenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSessionTokenRequest) ProtoMessage() {}
+
+func (x *GetSessionTokenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSessionTokenRequest.ProtoReflect.Descriptor instead.
+func (*GetSessionTokenRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *GetSessionTokenRequest) GetScopes() []string {
+	if x != nil {
+		return x.Scopes
+	}
+	return nil
+}
+
+// UserStatus is what authd knows about the calling user, who is identified by the credentials of the connection, so
+// that they can understand why they have to authenticate with their identity provider.
+type UserStatus struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Uid   uint32                 `protobuf:"varint,2,opt,name=uid,proto3" json:"uid,omitempty"`
+	// The name of the broker of the user, "local" for the users which are not provided by a broker.
+	Broker string   `protobuf:"bytes,3,opt,name=broker,proto3" json:"broker,omitempty"`
+	Groups []string `protobuf:"bytes,4,rep,name=groups,proto3" json:"groups,omitempty"`
+	Locked bool     `protobuf:"varint,5,opt,name=locked,proto3" json:"locked,omitempty"`
+	// The token cached by the broker, unset if the broker doesn't report it.
+	Token *CachedToken `protobuf:"bytes,6,opt,name=token,proto3" json:"token,omitempty"`
+	// Why the status of the token could not be got from the broker, if it couldn't.
+	TokenError string `protobuf:"bytes,7,opt,name=token_error,json=tokenError,proto3" json:"token_error,omitempty"`
+	// Unix time in seconds of the last successful login with the identity provider, 0 if there is none.
+	LastOnlineLogin int64 `protobuf:"varint,8,opt,name=last_online_login,json=lastOnlineLogin,proto3" json:"last_online_login,omitempty"`
+	// How long after last_online_login the user can log in offline, in seconds. If 0, offline logins are not limited
+	// in time.
+	OfflineMaxAge int64 `protobuf:"varint,9,opt,name=offline_max_age,json=offlineMaxAge,proto3" json:"offline_max_age,omitempty"`
+	// The most recent logins of the user, newest first.
+	LastLogins []*LoginRecord `protobuf:"bytes,10,rep,name=last_logins,json=lastLogins,proto3" json:"last_logins,omitempty"`
+	// How many times the user can log in offline since last_online_login. If 0, offline logins are not limited in
+	// number.
+	OfflineMaxLogins uint32 `protobuf:"varint,11,opt,name=offline_max_logins,json=offlineMaxLogins,proto3" json:"offline_max_logins,omitempty"`
+	// How many times the user logged in offline since last_online_login.
+	OfflineLogins uint32 `protobuf:"varint,12,opt,name=offline_logins,json=offlineLogins,proto3" json:"offline_logins,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserStatus) Reset() {
+	*x = UserStatus{}
+	mi := &file_authd_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserStatus) ProtoMessage() {}
+
+func (x *UserStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserStatus.ProtoReflect.Descriptor instead.
+func (*UserStatus) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *UserStatus) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UserStatus) GetUid() uint32 {
+	if x != nil {
+		return x.Uid
+	}
+	return 0
+}
+
+func (x *UserStatus) GetBroker() string {
+	if x != nil {
+		return x.Broker
+	}
+	return ""
+}
+
+func (x *UserStatus) GetGroups() []string {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+func (x *UserStatus) GetLocked() bool {
+	if x != nil {
+		return x.Locked
+	}
+	return false
+}
+
+func (x *UserStatus) GetToken() *CachedToken {
+	if x != nil {
+		return x.Token
+	}
+	return nil
+}
+
+func (x *UserStatus) GetTokenError() string {
+	if x != nil {
+		return x.TokenError
+	}
+	return ""
+}
+
+func (x *UserStatus) GetLastOnlineLogin() int64 {
+	if x != nil {
+		return x.LastOnlineLogin
+	}
+	return 0
+}
+
+func (x *UserStatus) GetOfflineMaxAge() int64 {
+	if x != nil {
+		return x.OfflineMaxAge
+	}
+	return 0
+}
+
+func (x *UserStatus) GetLastLogins() []*LoginRecord {
+	if x != nil {
+		return x.LastLogins
+	}
+	return nil
+}
+
+func (x *UserStatus) GetOfflineMaxLogins() uint32 {
+	if x != nil {
+		return x.OfflineMaxLogins
+	}
+	return 0
+}
+
+func (x *UserStatus) GetOfflineLogins() uint32 {
+	if x != nil {
+		return x.OfflineLogins
+	}
+	return 0
+}
+
+// CachedToken is the status of the token which the broker cached when the user last authenticated with their identity
+// provider.
+type CachedToken struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Unix time in seconds at which the access token expires.
+	Expiry int64 `protobuf:"varint,1,opt,name=expiry,proto3" json:"expiry,omitempty"`
+	// Whether the broker can get a new token without the user authenticating again.
+	HasRefreshToken bool `protobuf:"varint,2,opt,name=has_refresh_token,json=hasRefreshToken,proto3" json:"has_refresh_token,omitempty"`
+	// Whether the user set the local password used for the logins without the identity provider.
+	HasLocalPassword bool `protobuf:"varint,3,opt,name=has_local_password,json=hasLocalPassword,proto3" json:"has_local_password,omitempty"`
+	// Whether the identity provider reported the user as disabled.
+	UserIsDisabled bool `protobuf:"varint,4,opt,name=user_is_disabled,json=userIsDisabled,proto3" json:"user_is_disabled,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *CachedToken) Reset() {
+	*x = CachedToken{}
+	mi := &file_authd_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CachedToken) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CachedToken) ProtoMessage() {}
+
+func (x *CachedToken) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CachedToken.ProtoReflect.Descriptor instead.
+func (*CachedToken) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *CachedToken) GetExpiry() int64 {
+	if x != nil {
+		return x.Expiry
+	}
+	return 0
+}
+
+func (x *CachedToken) GetHasRefreshToken() bool {
+	if x != nil {
+		return x.HasRefreshToken
+	}
+	return false
+}
+
+func (x *CachedToken) GetHasLocalPassword() bool {
+	if x != nil {
+		return x.HasLocalPassword
+	}
+	return false
+}
+
+func (x *CachedToken) GetUserIsDisabled() bool {
+	if x != nil {
+		return x.UserIsDisabled
+	}
+	return false
+}
+
+// SessionToken is an access token of the calling user, obtained when they logged in with their broker.
+type SessionToken struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	AccessToken string                 `protobuf:"bytes,1,opt,name=access_token,json=accessToken,proto3" json:"access_token,omitempty"`
+	TokenType   string                 `protobuf:"bytes,2,opt,name=token_type,json=tokenType,proto3" json:"token_type,omitempty"`
+	// Unix time in seconds at which the token expires.
+	Expiry        int64    `protobuf:"varint,3,opt,name=expiry,proto3" json:"expiry,omitempty"`
+	Scopes        []string `protobuf:"bytes,4,rep,name=scopes,proto3" json:"scopes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionToken) Reset() {
+	*x = SessionToken{}
+	mi := &file_authd_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionToken) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionToken) ProtoMessage() {}
+
+func (x *SessionToken) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionToken.ProtoReflect.Descriptor instead.
+func (*SessionToken) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *SessionToken) GetAccessToken() string {
+	if x != nil {
+		return x.AccessToken
+	}
+	return ""
+}
+
+func (x *SessionToken) GetTokenType() string {
+	if x != nil {
+		return x.TokenType
+	}
+	return ""
+}
+
+func (x *SessionToken) GetExpiry() int64 {
+	if x != nil {
+		return x.Expiry
+	}
+	return 0
+}
+
+func (x *SessionToken) GetScopes() []string {
+	if x != nil {
+		return x.Scopes
+	}
+	return nil
+}
+
+type GetGroupByNameRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetGroupByNameRequest) Reset() {
+	*x = GetGroupByNameRequest{}
+	mi := &file_authd_proto_msgTypes[47]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetGroupByNameRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetGroupByNameRequest) ProtoMessage() {}
+
+func (x *GetGroupByNameRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[47]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetGroupByNameRequest.ProtoReflect.Descriptor instead.
+func (*GetGroupByNameRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *GetGroupByNameRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type GetGroupByIDRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetGroupByIDRequest) Reset() {
+	*x = GetGroupByIDRequest{}
+	mi := &file_authd_proto_msgTypes[48]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetGroupByIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetGroupByIDRequest) ProtoMessage() {}
+
+func (x *GetGroupByIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[48]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetGroupByIDRequest.ProtoReflect.Descriptor instead.
+func (*GetGroupByIDRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *GetGroupByIDRequest) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type SetUserIDRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Id    uint32                 `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	// The language to use for any warnings returned.
+	// Note: This is currently not implemented and warnings are always in English.
+	Lang          string `protobuf:"bytes,3,opt,name=lang,proto3" json:"lang,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetUserIDRequest) Reset() {
+	*x = SetUserIDRequest{}
+	mi := &file_authd_proto_msgTypes[49]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetUserIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetUserIDRequest) ProtoMessage() {}
+
+func (x *SetUserIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[49]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetUserIDRequest.ProtoReflect.Descriptor instead.
+func (*SetUserIDRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{49}
+}
+
+func (x *SetUserIDRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SetUserIDRequest) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *SetUserIDRequest) GetLang() string {
+	if x != nil {
+		return x.Lang
+	}
+	return ""
+}
+
+type SetUserIDResponse struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	IdChanged           bool                   `protobuf:"varint,1,opt,name=id_changed,json=idChanged,proto3" json:"id_changed,omitempty"`
+	HomeDirOwnerChanged bool                   `protobuf:"varint,2,opt,name=home_dir_owner_changed,json=homeDirOwnerChanged,proto3" json:"home_dir_owner_changed,omitempty"`
+	Warnings            []string               `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *SetUserIDResponse) Reset() {
+	*x = SetUserIDResponse{}
+	mi := &file_authd_proto_msgTypes[50]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetUserIDResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetUserIDResponse) ProtoMessage() {}
+
+func (x *SetUserIDResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[50]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetUserIDResponse.ProtoReflect.Descriptor instead.
+func (*SetUserIDResponse) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *SetUserIDResponse) GetIdChanged() bool {
+	if x != nil {
+		return x.IdChanged
+	}
+	return false
+}
+
+func (x *SetUserIDResponse) GetHomeDirOwnerChanged() bool {
+	if x != nil {
+		return x.HomeDirOwnerChanged
+	}
+	return false
+}
+
+func (x *SetUserIDResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+type SetGroupIDRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Id    uint32                 `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	// The language to use for any warnings returned.
+	// Note: This is currently not implemented and warnings are always in English.
+	Lang          string `protobuf:"bytes,3,opt,name=lang,proto3" json:"lang,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetGroupIDRequest) Reset() {
+	*x = SetGroupIDRequest{}
+	mi := &file_authd_proto_msgTypes[51]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetGroupIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetGroupIDRequest) ProtoMessage() {}
+
+func (x *SetGroupIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[51]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetGroupIDRequest.ProtoReflect.Descriptor instead.
+func (*SetGroupIDRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *SetGroupIDRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SetGroupIDRequest) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *SetGroupIDRequest) GetLang() string {
+	if x != nil {
+		return x.Lang
+	}
+	return ""
+}
+
+type SetGroupIDResponse struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	IdChanged           bool                   `protobuf:"varint,1,opt,name=id_changed,json=idChanged,proto3" json:"id_changed,omitempty"`
+	HomeDirOwnerChanged bool                   `protobuf:"varint,2,opt,name=home_dir_owner_changed,json=homeDirOwnerChanged,proto3" json:"home_dir_owner_changed,omitempty"`
+	Warnings            []string               `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *SetGroupIDResponse) Reset() {
+	*x = SetGroupIDResponse{}
+	mi := &file_authd_proto_msgTypes[52]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetGroupIDResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetGroupIDResponse) ProtoMessage() {}
+
+func (x *SetGroupIDResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[52]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetGroupIDResponse.ProtoReflect.Descriptor instead.
+func (*SetGroupIDResponse) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{52}
+}
+
+func (x *SetGroupIDResponse) GetIdChanged() bool {
+	if x != nil {
+		return x.IdChanged
+	}
+	return false
+}
+
+func (x *SetGroupIDResponse) GetHomeDirOwnerChanged() bool {
+	if x != nil {
+		return x.HomeDirOwnerChanged
+	}
+	return false
+}
+
+func (x *SetGroupIDResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+type SetShellRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Shell         string                 `protobuf:"bytes,2,opt,name=shell,proto3" json:"shell,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetShellRequest) Reset() {
+	*x = SetShellRequest{}
+	mi := &file_authd_proto_msgTypes[53]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetShellRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetShellRequest) ProtoMessage() {}
+
+func (x *SetShellRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[53]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetShellRequest.ProtoReflect.Descriptor instead.
+func (*SetShellRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{53}
+}
+
+func (x *SetShellRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SetShellRequest) GetShell() string {
+	if x != nil {
+		return x.Shell
+	}
+	return ""
+}
+
+type SetShellResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Warnings      []string               `protobuf:"bytes,1,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetShellResponse) Reset() {
+	*x = SetShellResponse{}
+	mi := &file_authd_proto_msgTypes[54]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetShellResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetShellResponse) ProtoMessage() {}
+
+func (x *SetShellResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[54]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetShellResponse.ProtoReflect.Descriptor instead.
+func (*SetShellResponse) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{54}
+}
+
+func (x *SetShellResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+type SetHomeDirRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Home          string                 `protobuf:"bytes,2,opt,name=home,proto3" json:"home,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetHomeDirRequest) Reset() {
+	*x = SetHomeDirRequest{}
+	mi := &file_authd_proto_msgTypes[55]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetHomeDirRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetHomeDirRequest) ProtoMessage() {}
+
+func (x *SetHomeDirRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[55]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetHomeDirRequest.ProtoReflect.Descriptor instead.
+func (*SetHomeDirRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{55}
+}
+
+func (x *SetHomeDirRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SetHomeDirRequest) GetHome() string {
+	if x != nil {
+		return x.Home
+	}
+	return ""
+}
+
+type SetHomeDirResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	HomeDirChanged bool                   `protobuf:"varint,1,opt,name=home_dir_changed,json=homeDirChanged,proto3" json:"home_dir_changed,omitempty"`
+	HomeDirMoved   bool                   `protobuf:"varint,2,opt,name=home_dir_moved,json=homeDirMoved,proto3" json:"home_dir_moved,omitempty"`
+	Warnings       []string               `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *SetHomeDirResponse) Reset() {
+	*x = SetHomeDirResponse{}
+	mi := &file_authd_proto_msgTypes[56]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetHomeDirResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetHomeDirResponse) ProtoMessage() {}
+
+func (x *SetHomeDirResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[56]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetHomeDirResponse.ProtoReflect.Descriptor instead.
+func (*SetHomeDirResponse) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{56}
+}
+
+func (x *SetHomeDirResponse) GetHomeDirChanged() bool {
+	if x != nil {
+		return x.HomeDirChanged
+	}
+	return false
+}
+
+func (x *SetHomeDirResponse) GetHomeDirMoved() bool {
+	if x != nil {
+		return x.HomeDirMoved
+	}
+	return false
+}
+
+func (x *SetHomeDirResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+type DeleteUserResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Warnings []string               `protobuf:"bytes,1,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	// The path of the archive of the user's home directory, if it was archived.
+	HomeArchive   string `protobuf:"bytes,2,opt,name=home_archive,json=homeArchive,proto3" json:"home_archive,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteUserResponse) Reset() {
+	*x = DeleteUserResponse{}
+	mi := &file_authd_proto_msgTypes[57]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteUserResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteUserResponse) ProtoMessage() {}
+
+func (x *DeleteUserResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[57]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteUserResponse.ProtoReflect.Descriptor instead.
+func (*DeleteUserResponse) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{57}
+}
+
+func (x *DeleteUserResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *DeleteUserResponse) GetHomeArchive() string {
+	if x != nil {
+		return x.HomeArchive
+	}
+	return ""
+}
+
+type User struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Uid           uint32                 `protobuf:"varint,2,opt,name=uid,proto3" json:"uid,omitempty"`
+	Gid           uint32                 `protobuf:"varint,3,opt,name=gid,proto3" json:"gid,omitempty"`
+	Gecos         string                 `protobuf:"bytes,4,opt,name=gecos,proto3" json:"gecos,omitempty"`
+	Homedir       string                 `protobuf:"bytes,5,opt,name=homedir,proto3" json:"homedir,omitempty"`
+	Shell         string                 `protobuf:"bytes,6,opt,name=shell,proto3" json:"shell,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *User) Reset() {
+	*x = User{}
+	mi := &file_authd_proto_msgTypes[58]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *User) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*User) ProtoMessage() {}
+
+func (x *User) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[58]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use User.ProtoReflect.Descriptor instead.
+func (*User) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{58}
+}
+
+func (x *User) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *User) GetUid() uint32 {
+	if x != nil {
+		return x.Uid
+	}
+	return 0
+}
+
+func (x *User) GetGid() uint32 {
+	if x != nil {
+		return x.Gid
+	}
+	return 0
+}
+
+func (x *User) GetGecos() string {
+	if x != nil {
+		return x.Gecos
+	}
+	return ""
+}
+
+func (x *User) GetHomedir() string {
+	if x != nil {
+		return x.Homedir
+	}
+	return ""
+}
+
+func (x *User) GetShell() string {
+	if x != nil {
+		return x.Shell
+	}
+	return ""
+}
+
+type Users struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Users []*User                `protobuf:"bytes,1,rep,name=users,proto3" json:"users,omitempty"`
+	// Set if there are more users to get, to be passed as page_token of the next request.
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Users) Reset() {
+	*x = Users{}
+	mi := &file_authd_proto_msgTypes[59]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Users) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Users) ProtoMessage() {}
+
+func (x *Users) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[59]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Users.ProtoReflect.Descriptor instead.
+func (*Users) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{59}
+}
+
+func (x *Users) GetUsers() []*User {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+func (x *Users) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type Group struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Name    string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Gid     uint32                 `protobuf:"varint,2,opt,name=gid,proto3" json:"gid,omitempty"`
+	Members []string               `protobuf:"bytes,3,rep,name=members,proto3" json:"members,omitempty"`
+	// TODO: Rename this to tmpID
+	Passwd        string `protobuf:"bytes,4,opt,name=passwd,proto3" json:"passwd,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Group) Reset() {
+	*x = Group{}
+	mi := &file_authd_proto_msgTypes[60]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Group) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Group) ProtoMessage() {}
+
+func (x *Group) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[60]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Group.ProtoReflect.Descriptor instead.
+func (*Group) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{60}
+}
+
+func (x *Group) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Group) GetGid() uint32 {
+	if x != nil {
+		return x.Gid
+	}
+	return 0
+}
+
+func (x *Group) GetMembers() []string {
+	if x != nil {
+		return x.Members
+	}
+	return nil
+}
+
+func (x *Group) GetPasswd() string {
+	if x != nil {
+		return x.Passwd
+	}
+	return ""
+}
+
+type Groups struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Groups []*Group               `protobuf:"bytes,1,rep,name=groups,proto3" json:"groups,omitempty"`
+	// Set if there are more groups to get, to be passed as page_token of the next request.
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Groups) Reset() {
+	*x = Groups{}
+	mi := &file_authd_proto_msgTypes[61]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Groups) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Groups) ProtoMessage() {}
+
+func (x *Groups) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[61]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Groups.ProtoReflect.Descriptor instead.
+func (*Groups) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{61}
+}
+
+func (x *Groups) GetGroups() []*Group {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+func (x *Groups) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type LoginRecord struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Unix time in seconds.
+	Time     int64  `protobuf:"varint,1,opt,name=time,proto3" json:"time,omitempty"`
+	Service  string `protobuf:"bytes,2,opt,name=service,proto3" json:"service,omitempty"`
+	BrokerId string `protobuf:"bytes,3,opt,name=broker_id,json=brokerId,proto3" json:"broker_id,omitempty"`
+	Offline  bool   `protobuf:"varint,4,opt,name=offline,proto3" json:"offline,omitempty"`
+	Result   string `protobuf:"bytes,5,opt,name=result,proto3" json:"result,omitempty"`
+	// Whether the user authenticated with a machine token, without their broker.
+	MachineToken  bool `protobuf:"varint,6,opt,name=machine_token,json=machineToken,proto3" json:"machine_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoginRecord) Reset() {
+	*x = LoginRecord{}
+	mi := &file_authd_proto_msgTypes[62]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoginRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoginRecord) ProtoMessage() {}
+
+func (x *LoginRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[62]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoginRecord.ProtoReflect.Descriptor instead.
+func (*LoginRecord) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{62}
+}
+
+func (x *LoginRecord) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *LoginRecord) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *LoginRecord) GetBrokerId() string {
+	if x != nil {
+		return x.BrokerId
+	}
+	return ""
+}
+
+func (x *LoginRecord) GetOffline() bool {
+	if x != nil {
+		return x.Offline
+	}
+	return false
+}
+
+func (x *LoginRecord) GetResult() string {
+	if x != nil {
+		return x.Result
+	}
+	return ""
+}
+
+func (x *LoginRecord) GetMachineToken() bool {
+	if x != nil {
+		return x.MachineToken
+	}
+	return false
+}
+
+type LoginHistory struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Records       []*LoginRecord         `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoginHistory) Reset() {
+	*x = LoginHistory{}
+	mi := &file_authd_proto_msgTypes[63]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoginHistory) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoginHistory) ProtoMessage() {}
+
+func (x *LoginHistory) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[63]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoginHistory.ProtoReflect.Descriptor instead.
+func (*LoginHistory) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{63}
+}
+
+func (x *LoginHistory) GetRecords() []*LoginRecord {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+type SecurityCheck struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Description   string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Details       string                 `protobuf:"bytes,4,opt,name=details,proto3" json:"details,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SecurityCheck) Reset() {
+	*x = SecurityCheck{}
+	mi := &file_authd_proto_msgTypes[64]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SecurityCheck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SecurityCheck) ProtoMessage() {}
+
+func (x *SecurityCheck) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[64]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SecurityCheck.ProtoReflect.Descriptor instead.
+func (*SecurityCheck) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{64}
+}
+
+func (x *SecurityCheck) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *SecurityCheck) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *SecurityCheck) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *SecurityCheck) GetDetails() string {
+	if x != nil {
+		return x.Details
+	}
+	return ""
+}
+
+type SecurityReport struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Checks        []*SecurityCheck       `protobuf:"bytes,1,rep,name=checks,proto3" json:"checks,omitempty"`
+	Score         int32                  `protobuf:"varint,2,opt,name=score,proto3" json:"score,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SecurityReport) Reset() {
+	*x = SecurityReport{}
+	mi := &file_authd_proto_msgTypes[65]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SecurityReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SecurityReport) ProtoMessage() {}
+
+func (x *SecurityReport) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[65]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SecurityReport.ProtoReflect.Descriptor instead.
+func (*SecurityReport) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{65}
+}
+
+func (x *SecurityReport) GetChecks() []*SecurityCheck {
+	if x != nil {
+		return x.Checks
+	}
+	return nil
+}
+
+func (x *SecurityReport) GetScore() int32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+// DaemonStatus is the state of the running daemon.
+type DaemonStatus struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Version string                 `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	// Unix time in seconds at which the daemon started.
+	StartTime     int64           `protobuf:"varint,2,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	Database      *DatabaseStatus `protobuf:"bytes,3,opt,name=database,proto3" json:"database,omitempty"`
+	Brokers       []*BrokerStatus `protobuf:"bytes,4,rep,name=brokers,proto3" json:"brokers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DaemonStatus) Reset() {
+	*x = DaemonStatus{}
+	mi := &file_authd_proto_msgTypes[66]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DaemonStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DaemonStatus) ProtoMessage() {}
+
+func (x *DaemonStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[66]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DaemonStatus.ProtoReflect.Descriptor instead.
+func (*DaemonStatus) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{66}
+}
+
+func (x *DaemonStatus) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *DaemonStatus) GetStartTime() int64 {
+	if x != nil {
+		return x.StartTime
+	}
+	return 0
+}
+
+func (x *DaemonStatus) GetDatabase() *DatabaseStatus {
+	if x != nil {
+		return x.Database
+	}
+	return nil
+}
+
+func (x *DaemonStatus) GetBrokers() []*BrokerStatus {
+	if x != nil {
+		return x.Brokers
+	}
+	return nil
+}
+
+type DatabaseStatus struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Path  string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// Size in bytes of the database file and of its write-ahead log.
+	Size          int64  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	SchemaVersion uint32 `protobuf:"varint,3,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"`
+	Users         uint32 `protobuf:"varint,4,opt,name=users,proto3" json:"users,omitempty"`
+	LockedUsers   uint32 `protobuf:"varint,5,opt,name=locked_users,json=lockedUsers,proto3" json:"locked_users,omitempty"`
+	Groups        uint32 `protobuf:"varint,6,opt,name=groups,proto3" json:"groups,omitempty"`
+	// Users authenticating for the first time, who are not stored in the database yet.
+	PendingUsers uint32 `protobuf:"varint,7,opt,name=pending_users,json=pendingUsers,proto3" json:"pending_users,omitempty"`
+	// The durations of the operations run since the daemon started, sorted by operation.
+	Operations    []*OperationLatency `protobuf:"bytes,8,rep,name=operations,proto3" json:"operations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DatabaseStatus) Reset() {
+	*x = DatabaseStatus{}
+	mi := &file_authd_proto_msgTypes[67]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DatabaseStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DatabaseStatus) ProtoMessage() {}
+
+func (x *DatabaseStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[67]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DatabaseStatus.ProtoReflect.Descriptor instead.
+func (*DatabaseStatus) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{67}
+}
+
+func (x *DatabaseStatus) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *DatabaseStatus) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *DatabaseStatus) GetSchemaVersion() uint32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *DatabaseStatus) GetUsers() uint32 {
+	if x != nil {
+		return x.Users
+	}
+	return 0
+}
+
+func (x *DatabaseStatus) GetLockedUsers() uint32 {
+	if x != nil {
+		return x.LockedUsers
+	}
+	return 0
+}
+
+func (x *DatabaseStatus) GetGroups() uint32 {
+	if x != nil {
+		return x.Groups
+	}
+	return 0
+}
+
+func (x *DatabaseStatus) GetPendingUsers() uint32 {
+	if x != nil {
+		return x.PendingUsers
+	}
+	return 0
+}
+
+func (x *DatabaseStatus) GetOperations() []*OperationLatency {
+	if x != nil {
+		return x.Operations
+	}
+	return nil
+}
+
+// OperationLatency is the histogram of the durations of a database operation.
+type OperationLatency struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Operation string                 `protobuf:"bytes,1,opt,name=operation,proto3" json:"operation,omitempty"`
+	Count     uint64                 `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	// Total duration in seconds of the operations.
+	SumSeconds float64 `protobuf:"fixed64,3,opt,name=sum_seconds,json=sumSeconds,proto3" json:"sum_seconds,omitempty"`
+	// Cumulative buckets, sorted by upper bound.
+	Buckets       []*LatencyBucket `protobuf:"bytes,4,rep,name=buckets,proto3" json:"buckets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OperationLatency) Reset() {
+	*x = OperationLatency{}
+	mi := &file_authd_proto_msgTypes[68]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OperationLatency) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OperationLatency) ProtoMessage() {}
+
+func (x *OperationLatency) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[68]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OperationLatency.ProtoReflect.Descriptor instead.
+func (*OperationLatency) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{68}
+}
+
+func (x *OperationLatency) GetOperation() string {
+	if x != nil {
+		return x.Operation
+	}
+	return ""
+}
+
+func (x *OperationLatency) GetCount() uint64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *OperationLatency) GetSumSeconds() float64 {
+	if x != nil {
+		return x.SumSeconds
+	}
+	return 0
+}
+
+func (x *OperationLatency) GetBuckets() []*LatencyBucket {
+	if x != nil {
+		return x.Buckets
+	}
+	return nil
+}
+
+type LatencyBucket struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	UpperBoundSeconds float64                `protobuf:"fixed64,1,opt,name=upper_bound_seconds,json=upperBoundSeconds,proto3" json:"upper_bound_seconds,omitempty"`
+	// Number of operations which took at most upper_bound_seconds.
+	Count         uint64 `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LatencyBucket) Reset() {
+	*x = LatencyBucket{}
+	mi := &file_authd_proto_msgTypes[69]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LatencyBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LatencyBucket) ProtoMessage() {}
+
+func (x *LatencyBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[69]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LatencyBucket.ProtoReflect.Descriptor instead.
+func (*LatencyBucket) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{69}
+}
+
+func (x *LatencyBucket) GetUpperBoundSeconds() float64 {
+	if x != nil {
+		return x.UpperBoundSeconds
+	}
+	return 0
+}
+
+func (x *LatencyBucket) GetCount() uint64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+// DatabaseRollback is the result of reverting the schema migrations applied to the database since its last backup.
+type DatabaseRollback struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The schema version of the database before the rollback.
+	FromVersion uint32 `protobuf:"varint,1,opt,name=from_version,json=fromVersion,proto3" json:"from_version,omitempty"`
+	// The schema version of the database after the rollback, which the previous version of authd expects.
+	ToVersion     uint32 `protobuf:"varint,2,opt,name=to_version,json=toVersion,proto3" json:"to_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DatabaseRollback) Reset() {
+	*x = DatabaseRollback{}
+	mi := &file_authd_proto_msgTypes[70]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DatabaseRollback) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DatabaseRollback) ProtoMessage() {}
+
+func (x *DatabaseRollback) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[70]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DatabaseRollback.ProtoReflect.Descriptor instead.
+func (*DatabaseRollback) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{70}
+}
+
+func (x *DatabaseRollback) GetFromVersion() uint32 {
+	if x != nil {
+		return x.FromVersion
+	}
+	return 0
+}
+
+func (x *DatabaseRollback) GetToVersion() uint32 {
+	if x != nil {
+		return x.ToVersion
+	}
+	return 0
+}
+
+type LinkIdentityRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// The name or the ID of the broker with which the user can authenticate, in addition to the broker they're bound to.
+	Broker        string `protobuf:"bytes,2,opt,name=broker,proto3" json:"broker,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LinkIdentityRequest) Reset() {
+	*x = LinkIdentityRequest{}
+	mi := &file_authd_proto_msgTypes[71]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LinkIdentityRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinkIdentityRequest) ProtoMessage() {}
+
+func (x *LinkIdentityRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[71]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinkIdentityRequest.ProtoReflect.Descriptor instead.
+func (*LinkIdentityRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{71}
+}
+
+func (x *LinkIdentityRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *LinkIdentityRequest) GetBroker() string {
+	if x != nil {
+		return x.Broker
+	}
+	return ""
+}
+
+type UnlinkIdentityRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// The name or the ID of the broker.
+	Broker        string `protobuf:"bytes,2,opt,name=broker,proto3" json:"broker,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnlinkIdentityRequest) Reset() {
+	*x = UnlinkIdentityRequest{}
+	mi := &file_authd_proto_msgTypes[72]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnlinkIdentityRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnlinkIdentityRequest) ProtoMessage() {}
+
+func (x *UnlinkIdentityRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[72]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnlinkIdentityRequest.ProtoReflect.Descriptor instead.
+func (*UnlinkIdentityRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{72}
+}
+
+func (x *UnlinkIdentityRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UnlinkIdentityRequest) GetBroker() string {
+	if x != nil {
+		return x.Broker
+	}
+	return ""
+}
+
+type GetLinkedIdentitiesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetLinkedIdentitiesRequest) Reset() {
+	*x = GetLinkedIdentitiesRequest{}
+	mi := &file_authd_proto_msgTypes[73]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetLinkedIdentitiesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLinkedIdentitiesRequest) ProtoMessage() {}
+
+func (x *GetLinkedIdentitiesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[73]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLinkedIdentitiesRequest.ProtoReflect.Descriptor instead.
+func (*GetLinkedIdentitiesRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{73}
+}
+
+func (x *GetLinkedIdentitiesRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// LinkedIdentities are the identities of a user at the providers of other brokers than the one they're bound to.
+type LinkedIdentities struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The ID of the broker which the user is bound to.
+	BrokerId      string                       `protobuf:"bytes,1,opt,name=broker_id,json=brokerId,proto3" json:"broker_id,omitempty"`
+	Identities    []*LinkedIdentities_Identity `protobuf:"bytes,2,rep,name=identities,proto3" json:"identities,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LinkedIdentities) Reset() {
+	*x = LinkedIdentities{}
+	mi := &file_authd_proto_msgTypes[74]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LinkedIdentities) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinkedIdentities) ProtoMessage() {}
+
+func (x *LinkedIdentities) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[74]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinkedIdentities.ProtoReflect.Descriptor instead.
+func (*LinkedIdentities) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{74}
+}
+
+func (x *LinkedIdentities) GetBrokerId() string {
+	if x != nil {
+		return x.BrokerId
+	}
+	return ""
+}
+
+func (x *LinkedIdentities) GetIdentities() []*LinkedIdentities_Identity {
+	if x != nil {
+		return x.Identities
+	}
+	return nil
+}
+
+type GetAuthorizedKeysRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAuthorizedKeysRequest) Reset() {
+	*x = GetAuthorizedKeysRequest{}
+	mi := &file_authd_proto_msgTypes[75]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAuthorizedKeysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAuthorizedKeysRequest) ProtoMessage() {}
+
+func (x *GetAuthorizedKeysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[75]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAuthorizedKeysRequest.ProtoReflect.Descriptor instead.
+func (*GetAuthorizedKeysRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{75}
+}
+
+func (x *GetAuthorizedKeysRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// AuthorizedKeys are the SSH public keys which a user can log in with, as lines of an authorized_keys file.
+type AuthorizedKeys struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Keys          []string               `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuthorizedKeys) Reset() {
+	*x = AuthorizedKeys{}
+	mi := &file_authd_proto_msgTypes[76]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuthorizedKeys) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthorizedKeys) ProtoMessage() {}
+
+func (x *AuthorizedKeys) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[76]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthorizedKeys.ProtoReflect.Descriptor instead.
+func (*AuthorizedKeys) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{76}
+}
+
+func (x *AuthorizedKeys) GetKeys() []string {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+type EnrollTOTPRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EnrollTOTPRequest) Reset() {
+	*x = EnrollTOTPRequest{}
+	mi := &file_authd_proto_msgTypes[77]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EnrollTOTPRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EnrollTOTPRequest) ProtoMessage() {}
+
+func (x *EnrollTOTPRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[77]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EnrollTOTPRequest.ProtoReflect.Descriptor instead.
+func (*EnrollTOTPRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{77}
+}
+
+func (x *EnrollTOTPRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// TOTPEnrollment is the TOTP secret generated for a user, which they import in their authenticator app.
+type TOTPEnrollment struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The otpauth URI of the secret.
+	Uri           string `protobuf:"bytes,1,opt,name=uri,proto3" json:"uri,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TOTPEnrollment) Reset() {
+	*x = TOTPEnrollment{}
+	mi := &file_authd_proto_msgTypes[78]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TOTPEnrollment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TOTPEnrollment) ProtoMessage() {}
+
+func (x *TOTPEnrollment) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[78]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TOTPEnrollment.ProtoReflect.Descriptor instead.
+func (*TOTPEnrollment) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{78}
+}
+
+func (x *TOTPEnrollment) GetUri() string {
+	if x != nil {
+		return x.Uri
+	}
+	return ""
+}
+
+type DisableTOTPRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DisableTOTPRequest) Reset() {
+	*x = DisableTOTPRequest{}
+	mi := &file_authd_proto_msgTypes[79]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DisableTOTPRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DisableTOTPRequest) ProtoMessage() {}
+
+func (x *DisableTOTPRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[79]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DisableTOTPRequest.ProtoReflect.Descriptor instead.
+func (*DisableTOTPRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{79}
+}
+
+func (x *DisableTOTPRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type EnrollMachineTokenRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// How long the token can be used, in seconds. If 0, it never expires.
+	Validity      int64 `protobuf:"varint,2,opt,name=validity,proto3" json:"validity,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EnrollMachineTokenRequest) Reset() {
+	*x = EnrollMachineTokenRequest{}
+	mi := &file_authd_proto_msgTypes[80]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EnrollMachineTokenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EnrollMachineTokenRequest) ProtoMessage() {}
+
+func (x *EnrollMachineTokenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[80]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EnrollMachineTokenRequest.ProtoReflect.Descriptor instead.
+func (*EnrollMachineTokenRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{80}
+}
+
+func (x *EnrollMachineTokenRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *EnrollMachineTokenRequest) GetValidity() int64 {
+	if x != nil {
+		return x.Validity
+	}
+	return 0
+}
+
+// MachineToken is the token generated for a user, with which automation authenticates as them.
+type MachineToken struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Token string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	// Unix time in seconds at which the token expires, 0 if it never does.
+	ExpiresAt     int64 `protobuf:"varint,2,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MachineToken) Reset() {
+	*x = MachineToken{}
+	mi := &file_authd_proto_msgTypes[81]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MachineToken) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MachineToken) ProtoMessage() {}
+
+func (x *MachineToken) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[81]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MachineToken.ProtoReflect.Descriptor instead.
+func (*MachineToken) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{81}
+}
+
+func (x *MachineToken) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *MachineToken) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+type RevokeMachineTokenRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RevokeMachineTokenRequest) Reset() {
+	*x = RevokeMachineTokenRequest{}
+	mi := &file_authd_proto_msgTypes[82]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RevokeMachineTokenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevokeMachineTokenRequest) ProtoMessage() {}
+
+func (x *RevokeMachineTokenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[82]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevokeMachineTokenRequest.ProtoReflect.Descriptor instead.
+func (*RevokeMachineTokenRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{82}
+}
+
+func (x *RevokeMachineTokenRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type BrokerStatus struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// The version of the broker API used with the broker, 0 for the local broker.
+	ApiVersion uint32 `protobuf:"varint,3,opt,name=api_version,json=apiVersion,proto3" json:"api_version,omitempty"`
+	// Why the broker does not answer, empty if it is healthy.
+	Error string `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	// Sessions started with the broker which did not end yet.
+	PendingSessions uint32 `protobuf:"varint,5,opt,name=pending_sessions,json=pendingSessions,proto3" json:"pending_sessions,omitempty"`
+	// Unix time in seconds of the last login of a user with the identity provider, when the broker last got a new
+	// token for a user. 0 if none is recorded.
+	LastOnlineLogin int64 `protobuf:"varint,6,opt,name=last_online_login,json=lastOnlineLogin,proto3" json:"last_online_login,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *BrokerStatus) Reset() {
+	*x = BrokerStatus{}
+	mi := &file_authd_proto_msgTypes[83]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BrokerStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BrokerStatus) ProtoMessage() {}
+
+func (x *BrokerStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[83]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BrokerStatus.ProtoReflect.Descriptor instead.
+func (*BrokerStatus) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{83}
+}
+
+func (x *BrokerStatus) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *BrokerStatus) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *BrokerStatus) GetApiVersion() uint32 {
+	if x != nil {
+		return x.ApiVersion
+	}
+	return 0
+}
+
+func (x *BrokerStatus) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *BrokerStatus) GetPendingSessions() uint32 {
+	if x != nil {
+		return x.PendingSessions
+	}
+	return 0
+}
+
+func (x *BrokerStatus) GetLastOnlineLogin() int64 {
+	if x != nil {
+		return x.LastOnlineLogin
+	}
+	return 0
+}
+
+// Brokers are the brokers registered in authd, in the order they are offered to the users.
+type Brokers struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Brokers       []*BrokerDetails       `protobuf:"bytes,1,rep,name=brokers,proto3" json:"brokers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Brokers) Reset() {
+	*x = Brokers{}
+	mi := &file_authd_proto_msgTypes[84]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Brokers) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Brokers) ProtoMessage() {}
+
+func (x *Brokers) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[84]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Brokers.ProtoReflect.Descriptor instead.
+func (*Brokers) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{84}
+}
+
+func (x *Brokers) GetBrokers() []*BrokerDetails {
+	if x != nil {
+		return x.Brokers
+	}
+	return nil
+}
+
+// BrokerDetails is the configuration of a broker and the optional features it supports.
+type BrokerDetails struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name      string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	BrandIcon string                 `protobuf:"bytes,3,opt,name=brand_icon,json=brandIcon,proto3" json:"brand_icon,omitempty"`
+	// The configuration file the broker was loaded from, empty for the local broker.
+	ConfigFile string `protobuf:"bytes,4,opt,name=config_file,json=configFile,proto3" json:"config_file,omitempty"`
+	// Where the broker is exported on the bus, empty for the local broker.
+	DbusName   string `protobuf:"bytes,5,opt,name=dbus_name,json=dbusName,proto3" json:"dbus_name,omitempty"`
+	DbusObject string `protobuf:"bytes,6,opt,name=dbus_object,json=dbusObject,proto3" json:"dbus_object,omitempty"`
+	// The version of the broker API used with the broker, 0 for the local broker.
+	ApiVersion uint32 `protobuf:"varint,7,opt,name=api_version,json=apiVersion,proto3" json:"api_version,omitempty"`
+	// The optional features supported by the broker, like "session_tokens" or "provider_check".
+	Capabilities []string `protobuf:"bytes,8,rep,name=capabilities,proto3" json:"capabilities,omitempty"`
+	// Whether the broker is the default one of all the PAM services without a broker of their own.
+	Default bool `protobuf:"varint,9,opt,name=default,proto3" json:"default,omitempty"`
+	// The PAM services which the broker is the default one of.
+	DefaultServices []string `protobuf:"bytes,10,rep,name=default_services,json=defaultServices,proto3" json:"default_services,omitempty"`
+	// The attributes given to the users of the broker when their provider doesn't supply them.
+	DefaultShell           string   `protobuf:"bytes,11,opt,name=default_shell,json=defaultShell,proto3" json:"default_shell,omitempty"`
+	DefaultGroups          []string `protobuf:"bytes,12,rep,name=default_groups,json=defaultGroups,proto3" json:"default_groups,omitempty"`
+	DefaultPrimaryGroupGid *uint32  `protobuf:"varint,13,opt,name=default_primary_group_gid,json=defaultPrimaryGroupGid,proto3,oneof" json:"default_primary_group_gid,omitempty"`
+	unknownFields          protoimpl.UnknownFields
+	sizeCache              protoimpl.SizeCache
+}
+
+func (x *BrokerDetails) Reset() {
+	*x = BrokerDetails{}
+	mi := &file_authd_proto_msgTypes[85]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BrokerDetails) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BrokerDetails) ProtoMessage() {}
+
+func (x *BrokerDetails) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[85]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BrokerDetails.ProtoReflect.Descriptor instead.
+func (*BrokerDetails) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{85}
+}
+
+func (x *BrokerDetails) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *BrokerDetails) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *BrokerDetails) GetBrandIcon() string {
+	if x != nil {
+		return x.BrandIcon
+	}
+	return ""
+}
+
+func (x *BrokerDetails) GetConfigFile() string {
+	if x != nil {
+		return x.ConfigFile
+	}
+	return ""
+}
+
+func (x *BrokerDetails) GetDbusName() string {
+	if x != nil {
+		return x.DbusName
+	}
+	return ""
+}
+
+func (x *BrokerDetails) GetDbusObject() string {
+	if x != nil {
+		return x.DbusObject
+	}
+	return ""
+}
+
+func (x *BrokerDetails) GetApiVersion() uint32 {
+	if x != nil {
+		return x.ApiVersion
+	}
+	return 0
+}
+
+func (x *BrokerDetails) GetCapabilities() []string {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+func (x *BrokerDetails) GetDefault() bool {
+	if x != nil {
+		return x.Default
+	}
+	return false
+}
+
+func (x *BrokerDetails) GetDefaultServices() []string {
+	if x != nil {
+		return x.DefaultServices
+	}
+	return nil
+}
+
+func (x *BrokerDetails) GetDefaultShell() string {
+	if x != nil {
+		return x.DefaultShell
+	}
+	return ""
+}
+
+func (x *BrokerDetails) GetDefaultGroups() []string {
+	if x != nil {
+		return x.DefaultGroups
+	}
+	return nil
+}
+
+func (x *BrokerDetails) GetDefaultPrimaryGroupGid() uint32 {
+	if x != nil && x.DefaultPrimaryGroupGid != nil {
+		return *x.DefaultPrimaryGroupGid
+	}
+	return 0
+}
+
+// BrokerCheck is the result of the checks of the connection of a broker to its identity provider.
+type BrokerCheck struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Checks        []*BrokerCheck_Check   `protobuf:"bytes,1,rep,name=checks,proto3" json:"checks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BrokerCheck) Reset() {
+	*x = BrokerCheck{}
+	mi := &file_authd_proto_msgTypes[86]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BrokerCheck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BrokerCheck) ProtoMessage() {}
+
+func (x *BrokerCheck) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[86]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BrokerCheck.ProtoReflect.Descriptor instead.
+func (*BrokerCheck) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{86}
+}
+
+func (x *BrokerCheck) GetChecks() []*BrokerCheck_Check {
+	if x != nil {
+		return x.Checks
+	}
+	return nil
+}
+
+// LoginPolicy restricts which users can log in on the machine.
+type LoginPolicy struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only the members of these groups of the identity provider can log in. If empty, all the users granted access by
+	// their broker can log in.
+	AllowedGroups []string `protobuf:"bytes,1,rep,name=allowed_groups,json=allowedGroups,proto3" json:"allowed_groups,omitempty"`
+	// Whether only the users already known by authd can log in, no new user being provisioned.
+	Lockdown bool `protobuf:"varint,2,opt,name=lockdown,proto3" json:"lockdown,omitempty"`
+	// The periods of the week during which the users can log in, like "Mon-Fri 08:00-18:00". If empty, the users can
+	// log in at any time.
+	LoginHours []string `protobuf:"bytes,3,rep,name=login_hours,json=loginHours,proto3" json:"login_hours,omitempty"`
+	// How long after their last authentication with their identity provider the users can log in offline, in seconds.
+	// If 0, offline logins are not limited in time.
+	OfflineMaxAge int64 `protobuf:"varint,4,opt,name=offline_max_age,json=offlineMaxAge,proto3" json:"offline_max_age,omitempty"`
+	// How many times the users can log in offline since their last authentication with their identity provider. If 0,
+	// offline logins are not limited in number.
+	OfflineMaxLogins uint32 `protobuf:"varint,6,opt,name=offline_max_logins,json=offlineMaxLogins,proto3" json:"offline_max_logins,omitempty"`
+	// The state of the central policy, if the policy is fetched from a central server.
+	Central *CentralPolicy `protobuf:"bytes,5,opt,name=central,proto3" json:"central,omitempty"`
+	// The members of these groups of the identity provider can't log in, even if they are members of one of the allowed
+	// groups.
+	DeniedGroups  []string `protobuf:"bytes,7,rep,name=denied_groups,json=deniedGroups,proto3" json:"denied_groups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoginPolicy) Reset() {
+	*x = LoginPolicy{}
+	mi := &file_authd_proto_msgTypes[87]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoginPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoginPolicy) ProtoMessage() {}
+
+func (x *LoginPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[87]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoginPolicy.ProtoReflect.Descriptor instead.
+func (*LoginPolicy) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{87}
+}
+
+func (x *LoginPolicy) GetAllowedGroups() []string {
+	if x != nil {
+		return x.AllowedGroups
+	}
+	return nil
+}
+
+func (x *LoginPolicy) GetLockdown() bool {
+	if x != nil {
+		return x.Lockdown
+	}
+	return false
+}
+
+func (x *LoginPolicy) GetLoginHours() []string {
+	if x != nil {
+		return x.LoginHours
+	}
+	return nil
+}
+
+func (x *LoginPolicy) GetOfflineMaxAge() int64 {
+	if x != nil {
+		return x.OfflineMaxAge
+	}
+	return 0
+}
+
+func (x *LoginPolicy) GetOfflineMaxLogins() uint32 {
+	if x != nil {
+		return x.OfflineMaxLogins
+	}
+	return 0
+}
+
+func (x *LoginPolicy) GetCentral() *CentralPolicy {
+	if x != nil {
+		return x.Central
+	}
+	return nil
+}
+
+func (x *LoginPolicy) GetDeniedGroups() []string {
+	if x != nil {
+		return x.DeniedGroups
+	}
+	return nil
+}
+
+// CentralPolicy is the state of the login policy fetched from a central server.
+type CentralPolicy struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The URL the policy is fetched from.
+	Url string `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	// Whether a valid document of the policy was fetched, now or before authd started.
+	Applied bool `protobuf:"varint,2,opt,name=applied,proto3" json:"applied,omitempty"`
+	// The serial of the document applied.
+	Serial uint64 `protobuf:"varint,3,opt,name=serial,proto3" json:"serial,omitempty"`
+	// The Unix time of the last successful fetch, 0 if none since authd started.
+	FetchedAt int64 `protobuf:"varint,4,opt,name=fetched_at,json=fetchedAt,proto3" json:"fetched_at,omitempty"`
+	// The error of the last fetch, if it failed.
+	Error         string `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CentralPolicy) Reset() {
+	*x = CentralPolicy{}
+	mi := &file_authd_proto_msgTypes[88]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CentralPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CentralPolicy) ProtoMessage() {}
+
+func (x *CentralPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[88]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CentralPolicy.ProtoReflect.Descriptor instead.
+func (*CentralPolicy) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{88}
+}
+
+func (x *CentralPolicy) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *CentralPolicy) GetApplied() bool {
+	if x != nil {
+		return x.Applied
+	}
+	return false
+}
+
+func (x *CentralPolicy) GetSerial() uint64 {
+	if x != nil {
+		return x.Serial
+	}
+	return 0
+}
+
+func (x *CentralPolicy) GetFetchedAt() int64 {
+	if x != nil {
+		return x.FetchedAt
+	}
+	return 0
+}
+
+func (x *CentralPolicy) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// UserLifecycle is the deprovisioning state of a user.
+type UserLifecycle struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// One of "active", "disabled" or "archived".
+	State string `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	// Unix time in seconds at which the user entered the state, 0 for active users.
+	Since int64 `protobuf:"varint,2,opt,name=since,proto3" json:"since,omitempty"`
+	// The state which the user is moved to at next_time, empty if the user stays in the state until an administrator
+	// changes it.
+	NextState string `protobuf:"bytes,3,opt,name=next_state,json=nextState,proto3" json:"next_state,omitempty"`
+	// Unix time in seconds.
+	NextTime int64 `protobuf:"varint,4,opt,name=next_time,json=nextTime,proto3" json:"next_time,omitempty"`
+	// The path of the archive of the home of archived users.
+	Archive       string `protobuf:"bytes,5,opt,name=archive,proto3" json:"archive,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserLifecycle) Reset() {
+	*x = UserLifecycle{}
+	mi := &file_authd_proto_msgTypes[89]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserLifecycle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserLifecycle) ProtoMessage() {}
+
+func (x *UserLifecycle) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[89]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserLifecycle.ProtoReflect.Descriptor instead.
+func (*UserLifecycle) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{89}
+}
+
+func (x *UserLifecycle) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *UserLifecycle) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+func (x *UserLifecycle) GetNextState() string {
+	if x != nil {
+		return x.NextState
+	}
+	return ""
+}
+
+func (x *UserLifecycle) GetNextTime() int64 {
+	if x != nil {
+		return x.NextTime
+	}
+	return 0
+}
+
+func (x *UserLifecycle) GetArchive() string {
+	if x != nil {
+		return x.Archive
+	}
+	return ""
+}
+
+type CleanupHomesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only report what would be done, without changing anything.
+	DryRun        bool `protobuf:"varint,1,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CleanupHomesRequest) Reset() {
+	*x = CleanupHomesRequest{}
+	mi := &file_authd_proto_msgTypes[90]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CleanupHomesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CleanupHomesRequest) ProtoMessage() {}
+
+func (x *CleanupHomesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[90]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CleanupHomesRequest.ProtoReflect.Descriptor instead.
+func (*CleanupHomesRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{90}
+}
+
+func (x *CleanupHomesRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type HomeCleanup struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Name      string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Uid       uint32                 `protobuf:"varint,2,opt,name=uid,proto3" json:"uid,omitempty"`
+	Home      string                 `protobuf:"bytes,3,opt,name=home,proto3" json:"home,omitempty"`
+	MailSpool string                 `protobuf:"bytes,4,opt,name=mail_spool,json=mailSpool,proto3" json:"mail_spool,omitempty"`
+	// Unix time in seconds at which the user was deleted.
+	DeletedAt int64 `protobuf:"varint,5,opt,name=deleted_at,json=deletedAt,proto3" json:"deleted_at,omitempty"`
+	// Unix time in seconds at which the home is cleaned up, 0 if it's kept.
+	DueAt int64 `protobuf:"varint,6,opt,name=due_at,json=dueAt,proto3" json:"due_at,omitempty"`
+	// One of "keep", "archive" or "remove".
+	Action string `protobuf:"bytes,7,opt,name=action,proto3" json:"action,omitempty"`
+	// Why the home is left untouched, if it is.
+	Skipped string `protobuf:"bytes,8,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	// Whether the home was cleaned up.
+	Done bool `protobuf:"varint,9,opt,name=done,proto3" json:"done,omitempty"`
+	// The path of the archive of the home, if it was archived.
+	Archive       string `protobuf:"bytes,10,opt,name=archive,proto3" json:"archive,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HomeCleanup) Reset() {
+	*x = HomeCleanup{}
+	mi := &file_authd_proto_msgTypes[91]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HomeCleanup) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HomeCleanup) ProtoMessage() {}
+
+func (x *HomeCleanup) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[91]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HomeCleanup.ProtoReflect.Descriptor instead.
+func (*HomeCleanup) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{91}
+}
+
+func (x *HomeCleanup) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *HomeCleanup) GetUid() uint32 {
+	if x != nil {
+		return x.Uid
+	}
+	return 0
+}
+
+func (x *HomeCleanup) GetHome() string {
+	if x != nil {
+		return x.Home
+	}
+	return ""
+}
+
+func (x *HomeCleanup) GetMailSpool() string {
+	if x != nil {
+		return x.MailSpool
+	}
+	return ""
+}
+
+func (x *HomeCleanup) GetDeletedAt() int64 {
+	if x != nil {
+		return x.DeletedAt
+	}
+	return 0
+}
+
+func (x *HomeCleanup) GetDueAt() int64 {
+	if x != nil {
+		return x.DueAt
+	}
+	return 0
+}
+
+func (x *HomeCleanup) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *HomeCleanup) GetSkipped() string {
+	if x != nil {
+		return x.Skipped
+	}
+	return ""
+}
+
+func (x *HomeCleanup) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+func (x *HomeCleanup) GetArchive() string {
+	if x != nil {
+		return x.Archive
+	}
+	return ""
+}
+
+type HomeCleanupReport struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       []*HomeCleanup         `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HomeCleanupReport) Reset() {
+	*x = HomeCleanupReport{}
+	mi := &file_authd_proto_msgTypes[92]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HomeCleanupReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HomeCleanupReport) ProtoMessage() {}
+
+func (x *HomeCleanupReport) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[92]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HomeCleanupReport.ProtoReflect.Descriptor instead.
+func (*HomeCleanupReport) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{92}
+}
+
+func (x *HomeCleanupReport) GetEntries() []*HomeCleanup {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type CleanupStaleUsersRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only report what would be done, without changing anything.
+	DryRun        bool `protobuf:"varint,1,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CleanupStaleUsersRequest) Reset() {
+	*x = CleanupStaleUsersRequest{}
+	mi := &file_authd_proto_msgTypes[93]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CleanupStaleUsersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CleanupStaleUsersRequest) ProtoMessage() {}
+
+func (x *CleanupStaleUsersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[93]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CleanupStaleUsersRequest.ProtoReflect.Descriptor instead.
+func (*CleanupStaleUsersRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{93}
+}
+
+func (x *CleanupStaleUsersRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type StaleUser struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Uid   uint32                 `protobuf:"varint,2,opt,name=uid,proto3" json:"uid,omitempty"`
+	Home  string                 `protobuf:"bytes,3,opt,name=home,proto3" json:"home,omitempty"`
+	// Unix time in seconds of the last successful login of the user, 0 if none is recorded.
+	LastLogin int64 `protobuf:"varint,4,opt,name=last_login,json=lastLogin,proto3" json:"last_login,omitempty"`
+	// One of "disable", "archive" or "delete".
+	Action string `protobuf:"bytes,5,opt,name=action,proto3" json:"action,omitempty"`
+	// Why the user is left untouched, if they are.
+	Skipped string `protobuf:"bytes,6,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	// Whether the action was done.
+	Done bool `protobuf:"varint,7,opt,name=done,proto3" json:"done,omitempty"`
+	// The path of the archive of the home, if it was archived.
+	Archive       string `protobuf:"bytes,8,opt,name=archive,proto3" json:"archive,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StaleUser) Reset() {
+	*x = StaleUser{}
+	mi := &file_authd_proto_msgTypes[94]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StaleUser) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StaleUser) ProtoMessage() {}
+
+func (x *StaleUser) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[94]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StaleUser.ProtoReflect.Descriptor instead.
+func (*StaleUser) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{94}
+}
+
+func (x *StaleUser) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *StaleUser) GetUid() uint32 {
+	if x != nil {
+		return x.Uid
+	}
+	return 0
+}
+
+func (x *StaleUser) GetHome() string {
+	if x != nil {
+		return x.Home
+	}
+	return ""
+}
+
+func (x *StaleUser) GetLastLogin() int64 {
+	if x != nil {
+		return x.LastLogin
+	}
+	return 0
+}
+
+func (x *StaleUser) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *StaleUser) GetSkipped() string {
+	if x != nil {
+		return x.Skipped
+	}
+	return ""
+}
+
+func (x *StaleUser) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+func (x *StaleUser) GetArchive() string {
+	if x != nil {
+		return x.Archive
+	}
+	return ""
+}
+
+type StaleUserReport struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Entries []*StaleUser           `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	// The duration in seconds without a successful login after which the users are stale, 0 if they are never.
+	StaleAfter    int64 `protobuf:"varint,2,opt,name=stale_after,json=staleAfter,proto3" json:"stale_after,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StaleUserReport) Reset() {
+	*x = StaleUserReport{}
+	mi := &file_authd_proto_msgTypes[95]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StaleUserReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StaleUserReport) ProtoMessage() {}
+
+func (x *StaleUserReport) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[95]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StaleUserReport.ProtoReflect.Descriptor instead.
+func (*StaleUserReport) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{95}
+}
+
+func (x *StaleUserReport) GetEntries() []*StaleUser {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *StaleUserReport) GetStaleAfter() int64 {
+	if x != nil {
+		return x.StaleAfter
+	}
+	return 0
+}
+
+type ListOrphanedHomesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The directories in which to look for orphaned homes. If empty, the parent directories of the homes of the current
+	// and of the deleted users are used.
+	Prefixes      []string `protobuf:"bytes,1,rep,name=prefixes,proto3" json:"prefixes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListOrphanedHomesRequest) Reset() {
+	*x = ListOrphanedHomesRequest{}
+	mi := &file_authd_proto_msgTypes[96]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListOrphanedHomesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListOrphanedHomesRequest) ProtoMessage() {}
+
+func (x *ListOrphanedHomesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[96]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListOrphanedHomesRequest.ProtoReflect.Descriptor instead.
+func (*ListOrphanedHomesRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{96}
+}
+
+func (x *ListOrphanedHomesRequest) GetPrefixes() []string {
+	if x != nil {
+		return x.Prefixes
+	}
+	return nil
+}
+
+type OrphanedHome struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Path  string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Uid   uint32                 `protobuf:"varint,2,opt,name=uid,proto3" json:"uid,omitempty"`
+	// The name of the deleted user who owned the home, if it's known.
+	DeletedUser string `protobuf:"bytes,3,opt,name=deleted_user,json=deletedUser,proto3" json:"deleted_user,omitempty"`
+	// Unix time in seconds of the deletion of deleted_user.
+	DeletedAt     int64 `protobuf:"varint,4,opt,name=deleted_at,json=deletedAt,proto3" json:"deleted_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrphanedHome) Reset() {
+	*x = OrphanedHome{}
+	mi := &file_authd_proto_msgTypes[97]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrphanedHome) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrphanedHome) ProtoMessage() {}
+
+func (x *OrphanedHome) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[97]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrphanedHome.ProtoReflect.Descriptor instead.
+func (*OrphanedHome) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{97}
+}
+
+func (x *OrphanedHome) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *OrphanedHome) GetUid() uint32 {
+	if x != nil {
+		return x.Uid
+	}
+	return 0
+}
+
+func (x *OrphanedHome) GetDeletedUser() string {
+	if x != nil {
+		return x.DeletedUser
+	}
+	return ""
+}
+
+func (x *OrphanedHome) GetDeletedAt() int64 {
+	if x != nil {
+		return x.DeletedAt
+	}
+	return 0
+}
+
+type OrphanedHomes struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Entries []*OrphanedHome        `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	// The directories which were searched.
+	Prefixes      []string `protobuf:"bytes,2,rep,name=prefixes,proto3" json:"prefixes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrphanedHomes) Reset() {
+	*x = OrphanedHomes{}
+	mi := &file_authd_proto_msgTypes[98]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrphanedHomes) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrphanedHomes) ProtoMessage() {}
+
+func (x *OrphanedHomes) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[98]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrphanedHomes.ProtoReflect.Descriptor instead.
+func (*OrphanedHomes) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{98}
+}
+
+func (x *OrphanedHomes) GetEntries() []*OrphanedHome {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *OrphanedHomes) GetPrefixes() []string {
+	if x != nil {
+		return x.Prefixes
+	}
+	return nil
+}
+
+// UserRecords are the users and groups of the database, exported to provision other machines with them.
+type UserRecords struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Users         []*UserRecords_User    `protobuf:"bytes,1,rep,name=users,proto3" json:"users,omitempty"`
+	Groups        []*UserRecords_Group   `protobuf:"bytes,2,rep,name=groups,proto3" json:"groups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserRecords) Reset() {
+	*x = UserRecords{}
+	mi := &file_authd_proto_msgTypes[99]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserRecords) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserRecords) ProtoMessage() {}
+
+func (x *UserRecords) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[99]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserRecords.ProtoReflect.Descriptor instead.
+func (*UserRecords) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{99}
+}
+
+func (x *UserRecords) GetUsers() []*UserRecords_User {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+func (x *UserRecords) GetGroups() []*UserRecords_Group {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+type AllocateUserIDRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AllocateUserIDRequest) Reset() {
+	*x = AllocateUserIDRequest{}
+	mi := &file_authd_proto_msgTypes[100]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AllocateUserIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AllocateUserIDRequest) ProtoMessage() {}
+
+func (x *AllocateUserIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[100]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AllocateUserIDRequest.ProtoReflect.Descriptor instead.
+func (*AllocateUserIDRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{100}
+}
+
+func (x *AllocateUserIDRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type AllocateGroupIDRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ugid          string                 `protobuf:"bytes,1,opt,name=ugid,proto3" json:"ugid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AllocateGroupIDRequest) Reset() {
+	*x = AllocateGroupIDRequest{}
+	mi := &file_authd_proto_msgTypes[101]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AllocateGroupIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AllocateGroupIDRequest) ProtoMessage() {}
+
+func (x *AllocateGroupIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[101]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AllocateGroupIDRequest.ProtoReflect.Descriptor instead.
+func (*AllocateGroupIDRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{101}
+}
+
+func (x *AllocateGroupIDRequest) GetUgid() string {
+	if x != nil {
+		return x.Ugid
+	}
+	return ""
+}
+
+type AllocatedID struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AllocatedID) Reset() {
+	*x = AllocatedID{}
+	mi := &file_authd_proto_msgTypes[102]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AllocatedID) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AllocatedID) ProtoMessage() {}
+
+func (x *AllocatedID) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[102]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AllocatedID.ProtoReflect.Descriptor instead.
+func (*AllocatedID) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{102}
+}
+
+func (x *AllocatedID) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type SubscribeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The kinds of events to stream, all of them if empty.
+	Kinds         []string `protobuf:"bytes,1,rep,name=kinds,proto3" json:"kinds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_authd_proto_msgTypes[103]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[103]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{103}
+}
+
+func (x *SubscribeRequest) GetKinds() []string {
+	if x != nil {
+		return x.Kinds
+	}
+	return nil
+}
+
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// One of login_succeeded, login_failed, user_added, user_updated, user_disabled, user_removed, token_issued or
+	// broker_offline.
+	Kind string `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	// Unix time in milliseconds.
+	Time     int64  `protobuf:"varint,2,opt,name=time,proto3" json:"time,omitempty"`
+	Username string `protobuf:"bytes,3,opt,name=username,proto3" json:"username,omitempty"`
+	// Set for the changes of users.
+	Uid      uint32 `protobuf:"varint,4,opt,name=uid,proto3" json:"uid,omitempty"`
+	BrokerId string `protobuf:"bytes,5,opt,name=broker_id,json=brokerId,proto3" json:"broker_id,omitempty"`
+	// The PAM service of the authentications.
+	Service string `protobuf:"bytes,6,opt,name=service,proto3" json:"service,omitempty"`
+	// Set if the authentication only relied on the information cached by the broker.
+	Offline bool `protobuf:"varint,7,opt,name=offline,proto3" json:"offline,omitempty"`
+	// The reason of the failures, as in the login history.
+	Result        string `protobuf:"bytes,8,opt,name=result,proto3" json:"result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_authd_proto_msgTypes[104]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[104]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{104}
+}
+
+func (x *Event) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Event) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *Event) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *Event) GetUid() uint32 {
+	if x != nil {
+		return x.Uid
+	}
+	return 0
+}
+
+func (x *Event) GetBrokerId() string {
+	if x != nil {
+		return x.BrokerId
+	}
+	return ""
+}
+
+func (x *Event) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *Event) GetOffline() bool {
+	if x != nil {
+		return x.Offline
+	}
+	return false
+}
+
+func (x *Event) GetResult() string {
+	if x != nil {
+		return x.Result
+	}
+	return ""
+}
+
+type ABResponse_BrokerInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	BrandIcon     *string                `protobuf:"bytes,3,opt,name=brand_icon,json=brandIcon,proto3,oneof" json:"brand_icon,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ABResponse_BrokerInfo) Reset() {
+	*x = ABResponse_BrokerInfo{}
+	mi := &file_authd_proto_msgTypes[105]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ABResponse_BrokerInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ABResponse_BrokerInfo) ProtoMessage() {}
+
+func (x *ABResponse_BrokerInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[105]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ABResponse_BrokerInfo.ProtoReflect.Descriptor instead.
+func (*ABResponse_BrokerInfo) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{3, 0}
+}
+
+func (x *ABResponse_BrokerInfo) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ABResponse_BrokerInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ABResponse_BrokerInfo) GetBrandIcon() string {
+	if x != nil && x.BrandIcon != nil {
+		return *x.BrandIcon
+	}
+	return ""
+}
+
+type GAMResponse_AuthenticationMode struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Label         string                 `protobuf:"bytes,2,opt,name=label,proto3" json:"label,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GAMResponse_AuthenticationMode) Reset() {
+	*x = GAMResponse_AuthenticationMode{}
+	mi := &file_authd_proto_msgTypes[106]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GAMResponse_AuthenticationMode) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GAMResponse_AuthenticationMode) ProtoMessage() {}
+
+func (x *GAMResponse_AuthenticationMode) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[106]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GAMResponse_AuthenticationMode.ProtoReflect.Descriptor instead.
+func (*GAMResponse_AuthenticationMode) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{9, 0}
+}
+
+func (x *GAMResponse_AuthenticationMode) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GAMResponse_AuthenticationMode) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+type IARequest_AuthenticationData struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Item:
+	//
+	//	*IARequest_AuthenticationData_Secret
+	//	*IARequest_AuthenticationData_Wait
+	//	*IARequest_AuthenticationData_Skip
+	//	*IARequest_AuthenticationData_WebauthnAssertion
+	//	*IARequest_AuthenticationData_TotpCode
+	//	*IARequest_AuthenticationData_MachineToken
+	//	*IARequest_AuthenticationData_Challenge
+	Item          isIARequest_AuthenticationData_Item `protobuf_oneof:"item"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IARequest_AuthenticationData) Reset() {
+	*x = IARequest_AuthenticationData{}
+	mi := &file_authd_proto_msgTypes[107]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IARequest_AuthenticationData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IARequest_AuthenticationData) ProtoMessage() {}
+
+func (x *IARequest_AuthenticationData) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[107]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IARequest_AuthenticationData.ProtoReflect.Descriptor instead.
+func (*IARequest_AuthenticationData) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{12, 0}
+}
+
+func (x *IARequest_AuthenticationData) GetItem() isIARequest_AuthenticationData_Item {
+	if x != nil {
+		return x.Item
+	}
+	return nil
+}
+
+func (x *IARequest_AuthenticationData) GetSecret() string {
+	if x != nil {
+		if x, ok := x.Item.(*IARequest_AuthenticationData_Secret); ok {
+			return x.Secret
+		}
+	}
+	return ""
+}
+
+func (x *IARequest_AuthenticationData) GetWait() string {
+	if x != nil {
+		if x, ok := x.Item.(*IARequest_AuthenticationData_Wait); ok {
+			return x.Wait
+		}
+	}
+	return ""
+}
+
+func (x *IARequest_AuthenticationData) GetSkip() string {
+	if x != nil {
+		if x, ok := x.Item.(*IARequest_AuthenticationData_Skip); ok {
+			return x.Skip
+		}
+	}
+	return ""
+}
+
+func (x *IARequest_AuthenticationData) GetWebauthnAssertion() string {
+	if x != nil {
+		if x, ok := x.Item.(*IARequest_AuthenticationData_WebauthnAssertion); ok {
+			return x.WebauthnAssertion
+		}
+	}
+	return ""
+}
+
+func (x *IARequest_AuthenticationData) GetTotpCode() string {
+	if x != nil {
+		if x, ok := x.Item.(*IARequest_AuthenticationData_TotpCode); ok {
+			return x.TotpCode
+		}
+	}
+	return ""
+}
+
+func (x *IARequest_AuthenticationData) GetMachineToken() string {
+	if x != nil {
+		if x, ok := x.Item.(*IARequest_AuthenticationData_MachineToken); ok {
+			return x.MachineToken
+		}
+	}
+	return ""
+}
+
+func (x *IARequest_AuthenticationData) GetChallenge() string {
+	if x != nil {
+		if x, ok := x.Item.(*IARequest_AuthenticationData_Challenge); ok {
+			return x.Challenge
+		}
+	}
+	return ""
+}
+
+type isIARequest_AuthenticationData_Item interface {
+	isIARequest_AuthenticationData_Item()
+}
+
+type IARequest_AuthenticationData_Secret struct {
+	Secret string `protobuf:"bytes,1,opt,name=secret,proto3,oneof"`
+}
+
+type IARequest_AuthenticationData_Wait struct {
+	Wait string `protobuf:"bytes,2,opt,name=wait,proto3,oneof"`
+}
+
+type IARequest_AuthenticationData_Skip struct {
+	Skip string `protobuf:"bytes,3,opt,name=skip,proto3,oneof"`
+}
+
+type IARequest_AuthenticationData_WebauthnAssertion struct {
+	// The WebAuthn assertion signed by a security key, as a JSON PublicKeyCredential. It's not secret, so it's not
+	// encrypted.
+	WebauthnAssertion string `protobuf:"bytes,4,opt,name=webauthn_assertion,json=webauthnAssertion,proto3,oneof"`
+}
+
+type IARequest_AuthenticationData_TotpCode struct {
+	// The code of the TOTP secret enrolled by the user, verified by authd itself. It's not encrypted, as it can only
+	// be used once.
+	TotpCode string `protobuf:"bytes,5,opt,name=totp_code,json=totpCode,proto3,oneof"`
+}
+
+type IARequest_AuthenticationData_MachineToken struct {
+	// The machine token enrolled for the user, verified by authd itself. It's not encrypted, as authd can't decrypt
+	// the secrets encrypted for the broker.
+	MachineToken string `protobuf:"bytes,6,opt,name=machine_token,json=machineToken,proto3,oneof"`
+}
+
+type IARequest_AuthenticationData_Challenge struct {
+	// FIXME: Drop this when gdm side is ready to update.
+	Challenge string `protobuf:"bytes,999,opt,name=challenge,proto3,oneof"`
+}
+
+func (*IARequest_AuthenticationData_Secret) isIARequest_AuthenticationData_Item() {}
+
+func (*IARequest_AuthenticationData_Wait) isIARequest_AuthenticationData_Item() {}
+
+func (*IARequest_AuthenticationData_Skip) isIARequest_AuthenticationData_Item() {}
+
+func (*IARequest_AuthenticationData_WebauthnAssertion) isIARequest_AuthenticationData_Item() {}
 
-func (x *Group) GetPasswd() string {
-	if x != nil {
-		return x.Passwd
-	}
-	return ""
-}
+func (*IARequest_AuthenticationData_TotpCode) isIARequest_AuthenticationData_Item() {}
 
-type Groups struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Groups        []*Group               `protobuf:"bytes,1,rep,name=groups,proto3" json:"groups,omitempty"`
+func (*IARequest_AuthenticationData_MachineToken) isIARequest_AuthenticationData_Item() {}
+
+func (*IARequest_AuthenticationData_Challenge) isIARequest_AuthenticationData_Item() {}
+
+type LinkedIdentities_Identity struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	BrokerId string                 `protobuf:"bytes,1,opt,name=broker_id,json=brokerId,proto3" json:"broker_id,omitempty"`
+	// The name of the broker, empty if it's not registered anymore.
+	BrokerName string `protobuf:"bytes,2,opt,name=broker_name,json=brokerName,proto3" json:"broker_name,omitempty"`
+	// The ID of the user at the provider, empty until they authenticated with the broker.
+	ProviderId    string `protobuf:"bytes,3,opt,name=provider_id,json=providerId,proto3" json:"provider_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Groups) Reset() {
-	*x = Groups{}
-	mi := &file_authd_proto_msgTypes[35]
+func (x *LinkedIdentities_Identity) Reset() {
+	*x = LinkedIdentities_Identity{}
+	mi := &file_authd_proto_msgTypes[109]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Groups) String() string {
+func (x *LinkedIdentities_Identity) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Groups) ProtoMessage() {}
+func (*LinkedIdentities_Identity) ProtoMessage() {}
 
-func (x *Groups) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[35]
+func (x *LinkedIdentities_Identity) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[109]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1939,42 +6965,57 @@ func (x *Groups) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Groups.ProtoReflect.Descriptor instead.
-func (*Groups) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{35}
+// Deprecated: Use LinkedIdentities_Identity.ProtoReflect.Descriptor instead.
+func (*LinkedIdentities_Identity) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{74, 0}
 }
 
-func (x *Groups) GetGroups() []*Group {
+func (x *LinkedIdentities_Identity) GetBrokerId() string {
 	if x != nil {
-		return x.Groups
+		return x.BrokerId
 	}
-	return nil
+	return ""
 }
 
-type ABResponse_BrokerInfo struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	BrandIcon     *string                `protobuf:"bytes,3,opt,name=brand_icon,json=brandIcon,proto3,oneof" json:"brand_icon,omitempty"`
+func (x *LinkedIdentities_Identity) GetBrokerName() string {
+	if x != nil {
+		return x.BrokerName
+	}
+	return ""
+}
+
+func (x *LinkedIdentities_Identity) GetProviderId() string {
+	if x != nil {
+		return x.ProviderId
+	}
+	return ""
+}
+
+type BrokerCheck_Check struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// One of "ok", "failed" or "skipped".
+	Status        string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Details       string `protobuf:"bytes,3,opt,name=details,proto3" json:"details,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ABResponse_BrokerInfo) Reset() {
-	*x = ABResponse_BrokerInfo{}
-	mi := &file_authd_proto_msgTypes[36]
+func (x *BrokerCheck_Check) Reset() {
+	*x = BrokerCheck_Check{}
+	mi := &file_authd_proto_msgTypes[110]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ABResponse_BrokerInfo) String() string {
+func (x *BrokerCheck_Check) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ABResponse_BrokerInfo) ProtoMessage() {}
+func (*BrokerCheck_Check) ProtoMessage() {}
 
-func (x *ABResponse_BrokerInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[36]
+func (x *BrokerCheck_Check) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[110]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1985,55 +7026,66 @@ func (x *ABResponse_BrokerInfo) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ABResponse_BrokerInfo.ProtoReflect.Descriptor instead.
-func (*ABResponse_BrokerInfo) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{3, 0}
+// Deprecated: Use BrokerCheck_Check.ProtoReflect.Descriptor instead.
+func (*BrokerCheck_Check) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{86, 0}
 }
 
-func (x *ABResponse_BrokerInfo) GetId() string {
+func (x *BrokerCheck_Check) GetName() string {
 	if x != nil {
-		return x.Id
+		return x.Name
 	}
 	return ""
 }
 
-func (x *ABResponse_BrokerInfo) GetName() string {
+func (x *BrokerCheck_Check) GetStatus() string {
 	if x != nil {
-		return x.Name
+		return x.Status
 	}
 	return ""
 }
 
-func (x *ABResponse_BrokerInfo) GetBrandIcon() string {
-	if x != nil && x.BrandIcon != nil {
-		return *x.BrandIcon
+func (x *BrokerCheck_Check) GetDetails() string {
+	if x != nil {
+		return x.Details
 	}
 	return ""
 }
 
-type GAMResponse_AuthenticationMode struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Label         string                 `protobuf:"bytes,2,opt,name=label,proto3" json:"label,omitempty"`
+type UserRecords_User struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Name       string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Uid        uint32                 `protobuf:"varint,2,opt,name=uid,proto3" json:"uid,omitempty"`
+	Gid        uint32                 `protobuf:"varint,3,opt,name=gid,proto3" json:"gid,omitempty"`
+	Gecos      string                 `protobuf:"bytes,4,opt,name=gecos,proto3" json:"gecos,omitempty"`
+	Homedir    string                 `protobuf:"bytes,5,opt,name=homedir,proto3" json:"homedir,omitempty"`
+	Shell      string                 `protobuf:"bytes,6,opt,name=shell,proto3" json:"shell,omitempty"`
+	BrokerId   string                 `protobuf:"bytes,7,opt,name=broker_id,json=brokerId,proto3" json:"broker_id,omitempty"`
+	ProviderId string                 `protobuf:"bytes,8,opt,name=provider_id,json=providerId,proto3" json:"provider_id,omitempty"`
+	Locked     bool                   `protobuf:"varint,9,opt,name=locked,proto3" json:"locked,omitempty"`
+	// The names of the authd groups of the user, which are in the groups of the records.
+	Groups []string `protobuf:"bytes,10,rep,name=groups,proto3" json:"groups,omitempty"`
+	// The names of the groups of the user in /etc/group.
+	LocalGroups   []string `protobuf:"bytes,11,rep,name=local_groups,json=localGroups,proto3" json:"local_groups,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GAMResponse_AuthenticationMode) Reset() {
-	*x = GAMResponse_AuthenticationMode{}
-	mi := &file_authd_proto_msgTypes[37]
+func (x *UserRecords_User) Reset() {
+	*x = UserRecords_User{}
+	mi := &file_authd_proto_msgTypes[111]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GAMResponse_AuthenticationMode) String() string {
+func (x *UserRecords_User) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GAMResponse_AuthenticationMode) ProtoMessage() {}
+func (*UserRecords_User) ProtoMessage() {}
 
-func (x *GAMResponse_AuthenticationMode) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[37]
+func (x *UserRecords_User) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[111]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2044,148 +7096,157 @@ func (x *GAMResponse_AuthenticationMode) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GAMResponse_AuthenticationMode.ProtoReflect.Descriptor instead.
-func (*GAMResponse_AuthenticationMode) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{9, 0}
+// Deprecated: Use UserRecords_User.ProtoReflect.Descriptor instead.
+func (*UserRecords_User) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{99, 0}
 }
 
-func (x *GAMResponse_AuthenticationMode) GetId() string {
+func (x *UserRecords_User) GetName() string {
 	if x != nil {
-		return x.Id
+		return x.Name
 	}
 	return ""
 }
 
-func (x *GAMResponse_AuthenticationMode) GetLabel() string {
+func (x *UserRecords_User) GetUid() uint32 {
 	if x != nil {
-		return x.Label
+		return x.Uid
 	}
-	return ""
-}
-
-type IARequest_AuthenticationData struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	// Types that are valid to be assigned to Item:
-	//
-	//	*IARequest_AuthenticationData_Secret
-	//	*IARequest_AuthenticationData_Wait
-	//	*IARequest_AuthenticationData_Skip
-	//	*IARequest_AuthenticationData_Challenge
-	Item          isIARequest_AuthenticationData_Item `protobuf_oneof:"item"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
-}
-
-func (x *IARequest_AuthenticationData) Reset() {
-	*x = IARequest_AuthenticationData{}
-	mi := &file_authd_proto_msgTypes[38]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+	return 0
 }
 
-func (x *IARequest_AuthenticationData) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *UserRecords_User) GetGid() uint32 {
+	if x != nil {
+		return x.Gid
+	}
+	return 0
 }
 
-func (*IARequest_AuthenticationData) ProtoMessage() {}
-
-func (x *IARequest_AuthenticationData) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[38]
+func (x *UserRecords_User) GetGecos() string {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.Gecos
 	}
-	return mi.MessageOf(x)
+	return ""
 }
 
-// Deprecated: Use IARequest_AuthenticationData.ProtoReflect.Descriptor instead.
-func (*IARequest_AuthenticationData) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{12, 0}
+func (x *UserRecords_User) GetHomedir() string {
+	if x != nil {
+		return x.Homedir
+	}
+	return ""
 }
 
-func (x *IARequest_AuthenticationData) GetItem() isIARequest_AuthenticationData_Item {
+func (x *UserRecords_User) GetShell() string {
 	if x != nil {
-		return x.Item
+		return x.Shell
 	}
-	return nil
+	return ""
 }
 
-func (x *IARequest_AuthenticationData) GetSecret() string {
+func (x *UserRecords_User) GetBrokerId() string {
 	if x != nil {
-		if x, ok := x.Item.(*IARequest_AuthenticationData_Secret); ok {
-			return x.Secret
-		}
+		return x.BrokerId
 	}
 	return ""
 }
 
-func (x *IARequest_AuthenticationData) GetWait() string {
+func (x *UserRecords_User) GetProviderId() string {
 	if x != nil {
-		if x, ok := x.Item.(*IARequest_AuthenticationData_Wait); ok {
-			return x.Wait
-		}
+		return x.ProviderId
 	}
 	return ""
 }
 
-func (x *IARequest_AuthenticationData) GetSkip() string {
+func (x *UserRecords_User) GetLocked() bool {
 	if x != nil {
-		if x, ok := x.Item.(*IARequest_AuthenticationData_Skip); ok {
-			return x.Skip
-		}
+		return x.Locked
 	}
-	return ""
+	return false
 }
 
-func (x *IARequest_AuthenticationData) GetChallenge() string {
+func (x *UserRecords_User) GetGroups() []string {
 	if x != nil {
-		if x, ok := x.Item.(*IARequest_AuthenticationData_Challenge); ok {
-			return x.Challenge
-		}
+		return x.Groups
 	}
-	return ""
+	return nil
 }
 
-type isIARequest_AuthenticationData_Item interface {
-	isIARequest_AuthenticationData_Item()
+func (x *UserRecords_User) GetLocalGroups() []string {
+	if x != nil {
+		return x.LocalGroups
+	}
+	return nil
 }
 
-type IARequest_AuthenticationData_Secret struct {
-	Secret string `protobuf:"bytes,1,opt,name=secret,proto3,oneof"`
+type UserRecords_Group struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Gid           uint32                 `protobuf:"varint,2,opt,name=gid,proto3" json:"gid,omitempty"`
+	Ugid          string                 `protobuf:"bytes,3,opt,name=ugid,proto3" json:"ugid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-type IARequest_AuthenticationData_Wait struct {
-	Wait string `protobuf:"bytes,2,opt,name=wait,proto3,oneof"`
+func (x *UserRecords_Group) Reset() {
+	*x = UserRecords_Group{}
+	mi := &file_authd_proto_msgTypes[112]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-type IARequest_AuthenticationData_Skip struct {
-	Skip string `protobuf:"bytes,3,opt,name=skip,proto3,oneof"`
+func (x *UserRecords_Group) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-type IARequest_AuthenticationData_Challenge struct {
-	// FIXME: Drop this when gdm side is ready to update.
-	Challenge string `protobuf:"bytes,999,opt,name=challenge,proto3,oneof"`
+func (*UserRecords_Group) ProtoMessage() {}
+
+func (x *UserRecords_Group) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[112]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-func (*IARequest_AuthenticationData_Secret) isIARequest_AuthenticationData_Item() {}
+// Deprecated: Use UserRecords_Group.ProtoReflect.Descriptor instead.
+func (*UserRecords_Group) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{99, 1}
+}
 
-func (*IARequest_AuthenticationData_Wait) isIARequest_AuthenticationData_Item() {}
+func (x *UserRecords_Group) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
 
-func (*IARequest_AuthenticationData_Skip) isIARequest_AuthenticationData_Item() {}
+func (x *UserRecords_Group) GetGid() uint32 {
+	if x != nil {
+		return x.Gid
+	}
+	return 0
+}
 
-func (*IARequest_AuthenticationData_Challenge) isIARequest_AuthenticationData_Item() {}
+func (x *UserRecords_Group) GetUgid() string {
+	if x != nil {
+		return x.Ugid
+	}
+	return ""
+}
 
 var File_authd_proto protoreflect.FileDescriptor
 
 const file_authd_proto_rawDesc = "" +
 	"\n" +
 	"\vauthd.proto\x12\x05authd\"\a\n" +
-	"\x05Empty\"'\n" +
+	"\x05Empty\"A\n" +
 	"\tGBRequest\x12\x1a\n" +
-	"\busername\x18\x01 \x01(\tR\busername\"$\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x18\n" +
+	"\aservice\x18\x02 \x01(\tR\aservice\"$\n" +
 	"\n" +
 	"GBResponse\x12\x16\n" +
 	"\x06broker\x18\x01 \x01(\tR\x06broker\"\xb4\x01\n" +
@@ -2200,12 +7261,14 @@ const file_authd_proto_rawDesc = "" +
 	"brand_icon\x18\x03 \x01(\tH\x00R\tbrandIcon\x88\x01\x01B\r\n" +
 	"\v_brand_icon\"\"\n" +
 	"\x0eStringResponse\x12\x10\n" +
-	"\x03msg\x18\x01 \x01(\tR\x03msg\"\x80\x01\n" +
+	"\x03msg\x18\x01 \x01(\tR\x03msg\"\xb6\x01\n" +
 	"\tSBRequest\x12\x1b\n" +
 	"\tbroker_id\x18\x01 \x01(\tR\bbrokerId\x12\x1a\n" +
 	"\busername\x18\x02 \x01(\tR\busername\x12\x12\n" +
 	"\x04lang\x18\x03 \x01(\tR\x04lang\x12&\n" +
-	"\x04mode\x18\x04 \x01(\x0e2\x12.authd.SessionModeR\x04mode\"R\n" +
+	"\x04mode\x18\x04 \x01(\x0e2\x12.authd.SessionModeR\x04mode\x12\x18\n" +
+	"\aservice\x18\x05 \x01(\tR\aservice\x12\x1a\n" +
+	"\btimezone\x18\x06 \x01(\tR\btimezone\"R\n" +
 	"\n" +
 	"SBResponse\x12\x1d\n" +
 	"\n" +
@@ -2244,39 +7307,188 @@ const file_authd_proto_rawDesc = "" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x124\n" +
 	"\x16authentication_mode_id\x18\x02 \x01(\tR\x14authenticationModeId\"D\n" +
 	"\vSAMResponse\x125\n" +
-	"\x0eui_layout_info\x18\x01 \x01(\v2\x0f.authd.UILayoutR\fuiLayoutInfo\"\x86\x02\n" +
+	"\x0eui_layout_info\x18\x01 \x01(\v2\x0f.authd.UILayoutR\fuiLayoutInfo\"\xfd\x02\n" +
 	"\tIARequest\x12\x1d\n" +
 	"\n" +
 	"session_id\x18\x01 \x01(\tR\tsessionId\x12T\n" +
-	"\x13authentication_data\x18\x02 \x01(\v2#.authd.IARequest.AuthenticationDataR\x12authenticationData\x1a\x83\x01\n" +
+	"\x13authentication_data\x18\x02 \x01(\v2#.authd.IARequest.AuthenticationDataR\x12authenticationData\x1a\xfa\x01\n" +
 	"\x12AuthenticationData\x12\x18\n" +
 	"\x06secret\x18\x01 \x01(\tH\x00R\x06secret\x12\x14\n" +
 	"\x04wait\x18\x02 \x01(\tH\x00R\x04wait\x12\x14\n" +
-	"\x04skip\x18\x03 \x01(\tH\x00R\x04skip\x12\x1f\n" +
+	"\x04skip\x18\x03 \x01(\tH\x00R\x04skip\x12/\n" +
+	"\x12webauthn_assertion\x18\x04 \x01(\tH\x00R\x11webauthnAssertion\x12\x1d\n" +
+	"\ttotp_code\x18\x05 \x01(\tH\x00R\btotpCode\x12%\n" +
+	"\rmachine_token\x18\x06 \x01(\tH\x00R\fmachineToken\x12\x1f\n" +
 	"\tchallenge\x18\xe7\a \x01(\tH\x00R\tchallengeB\x06\n" +
 	"\x04item\"6\n" +
 	"\n" +
 	"IAResponse\x12\x16\n" +
 	"\x06access\x18\x01 \x01(\tR\x06access\x12\x10\n" +
-	"\x03msg\x18\x02 \x01(\tR\x03msg\"*\n" +
+	"\x03msg\x18\x02 \x01(\tR\x03msg\"\x97\x04\n" +
+	"\x13ConversationRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12;\n" +
+	"\x11available_brokers\x18\x02 \x01(\v2\f.authd.EmptyH\x00R\x10availableBrokers\x121\n" +
+	"\n" +
+	"get_broker\x18\x03 \x01(\v2\x10.authd.GBRequestH\x00R\tgetBroker\x127\n" +
+	"\rselect_broker\x18\x04 \x01(\v2\x10.authd.SBRequestH\x00R\fselectBroker\x12M\n" +
+	"\x18get_authentication_modes\x18\x05 \x01(\v2\x11.authd.GAMRequestH\x00R\x16getAuthenticationModes\x12Q\n" +
+	"\x1aselect_authentication_mode\x18\x06 \x01(\v2\x11.authd.SAMRequestH\x00R\x18selectAuthenticationMode\x12=\n" +
+	"\x10is_authenticated\x18\a \x01(\v2\x10.authd.IARequestH\x00R\x0fisAuthenticated\x123\n" +
+	"\vend_session\x18\b \x01(\v2\x10.authd.ESRequestH\x00R\n" +
+	"endSession\x12&\n" +
+	"\x06cancel\x18\t \x01(\v2\f.authd.EmptyH\x00R\x06cancelB\t\n" +
+	"\arequest\"\xbb\x04\n" +
+	"\x14ConversationResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12@\n" +
+	"\x11available_brokers\x18\x02 \x01(\v2\x11.authd.ABResponseH\x00R\x10availableBrokers\x122\n" +
+	"\n" +
+	"get_broker\x18\x03 \x01(\v2\x11.authd.GBResponseH\x00R\tgetBroker\x128\n" +
+	"\rselect_broker\x18\x04 \x01(\v2\x11.authd.SBResponseH\x00R\fselectBroker\x12N\n" +
+	"\x18get_authentication_modes\x18\x05 \x01(\v2\x12.authd.GAMResponseH\x00R\x16getAuthenticationModes\x12R\n" +
+	"\x1aselect_authentication_mode\x18\x06 \x01(\v2\x12.authd.SAMResponseH\x00R\x18selectAuthenticationMode\x12>\n" +
+	"\x10is_authenticated\x18\a \x01(\v2\x11.authd.IAResponseH\x00R\x0fisAuthenticated\x12/\n" +
+	"\vend_session\x18\b \x01(\v2\f.authd.EmptyH\x00R\n" +
+	"endSession\x12\x1d\n" +
+	"\n" +
+	"error_code\x18\n" +
+	" \x01(\rR\terrorCode\x12#\n" +
+	"\rerror_message\x18\v \x01(\tR\ferrorMessageB\n" +
+	"\n" +
+	"\bresponse\"*\n" +
 	"\tESRequest\x12\x1d\n" +
 	"\n" +
-	"session_id\x18\x01 \x01(\tR\tsessionId\"R\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"\xc6\x01\n" +
+	"\tNSRequest\x12,\n" +
+	"\x05event\x18\x01 \x01(\x0e2\x16.authd.NSRequest.EventR\x05event\x12\x1a\n" +
+	"\busername\x18\x02 \x01(\tR\busername\x12\x18\n" +
+	"\aservice\x18\x03 \x01(\tR\aservice\x12\x10\n" +
+	"\x03tty\x18\x04 \x01(\tR\x03tty\x12\x14\n" +
+	"\x05rhost\x18\x05 \x01(\tR\x05rhost\"-\n" +
+	"\x05Event\x12\x0f\n" +
+	"\vUNSPECIFIED\x10\x00\x12\t\n" +
+	"\x05START\x10\x01\x12\b\n" +
+	"\x04STOP\x10\x02\"\xff\x02\n" +
+	"\n" +
+	"NSResponse\x12,\n" +
+	"\x03env\x18\x01 \x03(\v2\x1a.authd.NSResponse.EnvEntryR\x03env\x12;\n" +
+	"\x0eephemeral_home\x18\x02 \x01(\v2\x14.authd.EphemeralHomeR\rephemeralHome\x125\n" +
+	"\fnetwork_home\x18\x03 \x01(\v2\x12.authd.NetworkHomeR\vnetworkHome\x12/\n" +
+	"\n" +
+	"homed_home\x18\x04 \x01(\v2\x10.authd.HomedHomeR\thomedHome\x125\n" +
+	"\ffscrypt_home\x18\x05 \x01(\v2\x12.authd.FscryptHomeR\vfscryptHome\x12/\n" +
+	"\n" +
+	"local_home\x18\x06 \x01(\v2\x10.authd.LocalHomeR\tlocalHome\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"G\n" +
+	"\rEphemeralHome\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x10\n" +
+	"\x03uid\x18\x02 \x01(\rR\x03uid\x12\x10\n" +
+	"\x03gid\x18\x03 \x01(\rR\x03gid\"R\n" +
+	"\vNetworkHome\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x10\n" +
+	"\x03uid\x18\x02 \x01(\rR\x03uid\x12\x1d\n" +
+	"\n" +
+	"mount_unit\x18\x03 \x01(\tR\tmountUnit\"@\n" +
+	"\tHomedHome\x12\x1b\n" +
+	"\tuser_name\x18\x01 \x01(\tR\buserName\x12\x16\n" +
+	"\x06secret\x18\x02 \x01(\tR\x06secret\"b\n" +
+	"\vFscryptHome\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x1b\n" +
+	"\tuser_name\x18\x02 \x01(\tR\buserName\x12\x10\n" +
+	"\x03uid\x18\x03 \x01(\rR\x03uid\x12\x10\n" +
+	"\x03gid\x18\x04 \x01(\rR\x03gid\"\xdb\x01\n" +
+	"\tLocalHome\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x10\n" +
+	"\x03uid\x18\x02 \x01(\rR\x03uid\x12\x10\n" +
+	"\x03gid\x18\x03 \x01(\rR\x03gid\x12\x19\n" +
+	"\bhome_gid\x18\x04 \x01(\rR\ahomeGid\x12\x12\n" +
+	"\x04mode\x18\x05 \x01(\rR\x04mode\x12\x12\n" +
+	"\x04skel\x18\x06 \x01(\tR\x04skel\x12\x18\n" +
+	"\asubdirs\x18\a \x03(\tR\asubdirs\x12\x14\n" +
+	"\x05quota\x18\b \x01(\x04R\x05quota\x12#\n" +
+	"\rproject_quota\x18\t \x01(\bR\fprojectQuota\"R\n" +
 	"\x14GetUserByNameRequest\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12&\n" +
 	"\x0eshouldPreCheck\x18\x02 \x01(\bR\x0eshouldPreCheck\"$\n" +
 	"\x12GetUserByIDRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\rR\x02id\"%\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\"N\n" +
+	"\x10ListUsersRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\rR\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\"O\n" +
+	"\x11ListGroupsRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\rR\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\"-\n" +
+	"\x17GetGroupsForUserRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"%\n" +
 	"\x0fLockUserRequest\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\"'\n" +
 	"\x11UnlockUserRequest\x12\x12\n" +
-	"\x04name\x18\x01 \x01(\tR\x04name\"H\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"k\n" +
 	"\x11DeleteUserRequest\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1f\n" +
 	"\vremove_home\x18\x02 \x01(\bR\n" +
-	"removeHome\"(\n" +
+	"removeHome\x12!\n" +
+	"\farchive_home\x18\x03 \x01(\bR\varchiveHome\"(\n" +
+	"\x12DisableUserRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"(\n" +
+	"\x12ArchiveUserRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"(\n" +
+	"\x12RestoreUserRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"-\n" +
+	"\x17GetUserLifecycleRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"B\n" +
+	"\x16GetLoginHistoryRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\rR\x05limit\"(\n" +
 	"\x12DeleteGroupRequest\x12\x12\n" +
-	"\x04name\x18\x01 \x01(\tR\x04name\"+\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"K\n" +
+	"\x17SetDefaultBrokerRequest\x12\x16\n" +
+	"\x06broker\x18\x01 \x01(\tR\x06broker\x12\x18\n" +
+	"\aservice\x18\x02 \x01(\tR\aservice\"1\n" +
+	"\x17GetBrokerDetailsRequest\x12\x16\n" +
+	"\x06broker\x18\x01 \x01(\tR\x06broker\",\n" +
+	"\x12CheckBrokerRequest\x12\x16\n" +
+	"\x06broker\x18\x01 \x01(\tR\x06broker\"'\n" +
+	"\x11VerifyUserRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"t\n" +
+	"\x10UserVerification\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x16\n" +
+	"\x06broker\x18\x03 \x01(\tR\x06broker\x12\x16\n" +
+	"\x06locked\x18\x04 \x01(\bR\x06locked\"0\n" +
+	"\x16GetSessionTokenRequest\x12\x16\n" +
+	"\x06scopes\x18\x01 \x03(\tR\x06scopes\"\xa3\x03\n" +
+	"\n" +
+	"UserStatus\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
+	"\x03uid\x18\x02 \x01(\rR\x03uid\x12\x16\n" +
+	"\x06broker\x18\x03 \x01(\tR\x06broker\x12\x16\n" +
+	"\x06groups\x18\x04 \x03(\tR\x06groups\x12\x16\n" +
+	"\x06locked\x18\x05 \x01(\bR\x06locked\x12(\n" +
+	"\x05token\x18\x06 \x01(\v2\x12.authd.CachedTokenR\x05token\x12\x1f\n" +
+	"\vtoken_error\x18\a \x01(\tR\n" +
+	"tokenError\x12*\n" +
+	"\x11last_online_login\x18\b \x01(\x03R\x0flastOnlineLogin\x12&\n" +
+	"\x0foffline_max_age\x18\t \x01(\x03R\rofflineMaxAge\x123\n" +
+	"\vlast_logins\x18\n" +
+	" \x03(\v2\x12.authd.LoginRecordR\n" +
+	"lastLogins\x12,\n" +
+	"\x12offline_max_logins\x18\v \x01(\rR\x10offlineMaxLogins\x12%\n" +
+	"\x0eoffline_logins\x18\f \x01(\rR\rofflineLogins\"\xa9\x01\n" +
+	"\vCachedToken\x12\x16\n" +
+	"\x06expiry\x18\x01 \x01(\x03R\x06expiry\x12*\n" +
+	"\x11has_refresh_token\x18\x02 \x01(\bR\x0fhasRefreshToken\x12,\n" +
+	"\x12has_local_password\x18\x03 \x01(\bR\x10hasLocalPassword\x12(\n" +
+	"\x10user_is_disabled\x18\x04 \x01(\bR\x0euserIsDisabled\"\x80\x01\n" +
+	"\fSessionToken\x12!\n" +
+	"\faccess_token\x18\x01 \x01(\tR\vaccessToken\x12\x1d\n" +
+	"\n" +
+	"token_type\x18\x02 \x01(\tR\ttokenType\x12\x16\n" +
+	"\x06expiry\x18\x03 \x01(\x03R\x06expiry\x12\x16\n" +
+	"\x06scopes\x18\x04 \x03(\tR\x06scopes\"+\n" +
 	"\x15GetGroupByNameRequest\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\"%\n" +
 	"\x13GetGroupByIDRequest\x12\x0e\n" +
@@ -2310,29 +7522,259 @@ const file_authd_proto_rawDesc = "" +
 	"\x12SetHomeDirResponse\x12(\n" +
 	"\x10home_dir_changed\x18\x01 \x01(\bR\x0ehomeDirChanged\x12$\n" +
 	"\x0ehome_dir_moved\x18\x02 \x01(\bR\fhomeDirMoved\x12\x1a\n" +
-	"\bwarnings\x18\x03 \x03(\tR\bwarnings\"0\n" +
+	"\bwarnings\x18\x03 \x03(\tR\bwarnings\"S\n" +
 	"\x12DeleteUserResponse\x12\x1a\n" +
-	"\bwarnings\x18\x01 \x03(\tR\bwarnings\"\x84\x01\n" +
+	"\bwarnings\x18\x01 \x03(\tR\bwarnings\x12!\n" +
+	"\fhome_archive\x18\x02 \x01(\tR\vhomeArchive\"\x84\x01\n" +
 	"\x04User\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
 	"\x03uid\x18\x02 \x01(\rR\x03uid\x12\x10\n" +
 	"\x03gid\x18\x03 \x01(\rR\x03gid\x12\x14\n" +
 	"\x05gecos\x18\x04 \x01(\tR\x05gecos\x12\x18\n" +
 	"\ahomedir\x18\x05 \x01(\tR\ahomedir\x12\x14\n" +
-	"\x05shell\x18\x06 \x01(\tR\x05shell\"*\n" +
+	"\x05shell\x18\x06 \x01(\tR\x05shell\"R\n" +
 	"\x05Users\x12!\n" +
-	"\x05users\x18\x01 \x03(\v2\v.authd.UserR\x05users\"_\n" +
+	"\x05users\x18\x01 \x03(\v2\v.authd.UserR\x05users\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"_\n" +
 	"\x05Group\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
 	"\x03gid\x18\x02 \x01(\rR\x03gid\x12\x18\n" +
 	"\amembers\x18\x03 \x03(\tR\amembers\x12\x16\n" +
-	"\x06passwd\x18\x04 \x01(\tR\x06passwd\".\n" +
+	"\x06passwd\x18\x04 \x01(\tR\x06passwd\"V\n" +
 	"\x06Groups\x12$\n" +
-	"\x06groups\x18\x01 \x03(\v2\f.authd.GroupR\x06groups*<\n" +
+	"\x06groups\x18\x01 \x03(\v2\f.authd.GroupR\x06groups\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"\xaf\x01\n" +
+	"\vLoginRecord\x12\x12\n" +
+	"\x04time\x18\x01 \x01(\x03R\x04time\x12\x18\n" +
+	"\aservice\x18\x02 \x01(\tR\aservice\x12\x1b\n" +
+	"\tbroker_id\x18\x03 \x01(\tR\bbrokerId\x12\x18\n" +
+	"\aoffline\x18\x04 \x01(\bR\aoffline\x12\x16\n" +
+	"\x06result\x18\x05 \x01(\tR\x06result\x12#\n" +
+	"\rmachine_token\x18\x06 \x01(\bR\fmachineToken\"<\n" +
+	"\fLoginHistory\x12,\n" +
+	"\arecords\x18\x01 \x03(\v2\x12.authd.LoginRecordR\arecords\"s\n" +
+	"\rSecurityCheck\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12 \n" +
+	"\vdescription\x18\x02 \x01(\tR\vdescription\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x18\n" +
+	"\adetails\x18\x04 \x01(\tR\adetails\"T\n" +
+	"\x0eSecurityReport\x12,\n" +
+	"\x06checks\x18\x01 \x03(\v2\x14.authd.SecurityCheckR\x06checks\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x05R\x05score\"\xa9\x01\n" +
+	"\fDaemonStatus\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\tR\aversion\x12\x1d\n" +
+	"\n" +
+	"start_time\x18\x02 \x01(\x03R\tstartTime\x121\n" +
+	"\bdatabase\x18\x03 \x01(\v2\x15.authd.DatabaseStatusR\bdatabase\x12-\n" +
+	"\abrokers\x18\x04 \x03(\v2\x13.authd.BrokerStatusR\abrokers\"\x8e\x02\n" +
+	"\x0eDatabaseStatus\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\x12%\n" +
+	"\x0eschema_version\x18\x03 \x01(\rR\rschemaVersion\x12\x14\n" +
+	"\x05users\x18\x04 \x01(\rR\x05users\x12!\n" +
+	"\flocked_users\x18\x05 \x01(\rR\vlockedUsers\x12\x16\n" +
+	"\x06groups\x18\x06 \x01(\rR\x06groups\x12#\n" +
+	"\rpending_users\x18\a \x01(\rR\fpendingUsers\x127\n" +
+	"\n" +
+	"operations\x18\b \x03(\v2\x17.authd.OperationLatencyR\n" +
+	"operations\"\x97\x01\n" +
+	"\x10OperationLatency\x12\x1c\n" +
+	"\toperation\x18\x01 \x01(\tR\toperation\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x04R\x05count\x12\x1f\n" +
+	"\vsum_seconds\x18\x03 \x01(\x01R\n" +
+	"sumSeconds\x12.\n" +
+	"\abuckets\x18\x04 \x03(\v2\x14.authd.LatencyBucketR\abuckets\"U\n" +
+	"\rLatencyBucket\x12.\n" +
+	"\x13upper_bound_seconds\x18\x01 \x01(\x01R\x11upperBoundSeconds\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x04R\x05count\"T\n" +
+	"\x10DatabaseRollback\x12!\n" +
+	"\ffrom_version\x18\x01 \x01(\rR\vfromVersion\x12\x1d\n" +
+	"\n" +
+	"to_version\x18\x02 \x01(\rR\ttoVersion\"A\n" +
+	"\x13LinkIdentityRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06broker\x18\x02 \x01(\tR\x06broker\"C\n" +
+	"\x15UnlinkIdentityRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06broker\x18\x02 \x01(\tR\x06broker\"0\n" +
+	"\x1aGetLinkedIdentitiesRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"\xdc\x01\n" +
+	"\x10LinkedIdentities\x12\x1b\n" +
+	"\tbroker_id\x18\x01 \x01(\tR\bbrokerId\x12@\n" +
+	"\n" +
+	"identities\x18\x02 \x03(\v2 .authd.LinkedIdentities.IdentityR\n" +
+	"identities\x1ai\n" +
+	"\bIdentity\x12\x1b\n" +
+	"\tbroker_id\x18\x01 \x01(\tR\bbrokerId\x12\x1f\n" +
+	"\vbroker_name\x18\x02 \x01(\tR\n" +
+	"brokerName\x12\x1f\n" +
+	"\vprovider_id\x18\x03 \x01(\tR\n" +
+	"providerId\".\n" +
+	"\x18GetAuthorizedKeysRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"$\n" +
+	"\x0eAuthorizedKeys\x12\x12\n" +
+	"\x04keys\x18\x01 \x03(\tR\x04keys\"'\n" +
+	"\x11EnrollTOTPRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"\"\n" +
+	"\x0eTOTPEnrollment\x12\x10\n" +
+	"\x03uri\x18\x01 \x01(\tR\x03uri\"(\n" +
+	"\x12DisableTOTPRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"K\n" +
+	"\x19EnrollMachineTokenRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1a\n" +
+	"\bvalidity\x18\x02 \x01(\x03R\bvalidity\"C\n" +
+	"\fMachineToken\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x02 \x01(\x03R\texpiresAt\"/\n" +
+	"\x19RevokeMachineTokenRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"\xc0\x01\n" +
+	"\fBrokerStatus\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1f\n" +
+	"\vapi_version\x18\x03 \x01(\rR\n" +
+	"apiVersion\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12)\n" +
+	"\x10pending_sessions\x18\x05 \x01(\rR\x0fpendingSessions\x12*\n" +
+	"\x11last_online_login\x18\x06 \x01(\x03R\x0flastOnlineLogin\"9\n" +
+	"\aBrokers\x12.\n" +
+	"\abrokers\x18\x01 \x03(\v2\x14.authd.BrokerDetailsR\abrokers\"\xe5\x03\n" +
+	"\rBrokerDetails\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1d\n" +
+	"\n" +
+	"brand_icon\x18\x03 \x01(\tR\tbrandIcon\x12\x1f\n" +
+	"\vconfig_file\x18\x04 \x01(\tR\n" +
+	"configFile\x12\x1b\n" +
+	"\tdbus_name\x18\x05 \x01(\tR\bdbusName\x12\x1f\n" +
+	"\vdbus_object\x18\x06 \x01(\tR\n" +
+	"dbusObject\x12\x1f\n" +
+	"\vapi_version\x18\a \x01(\rR\n" +
+	"apiVersion\x12\"\n" +
+	"\fcapabilities\x18\b \x03(\tR\fcapabilities\x12\x18\n" +
+	"\adefault\x18\t \x01(\bR\adefault\x12)\n" +
+	"\x10default_services\x18\n" +
+	" \x03(\tR\x0fdefaultServices\x12#\n" +
+	"\rdefault_shell\x18\v \x01(\tR\fdefaultShell\x12%\n" +
+	"\x0edefault_groups\x18\f \x03(\tR\rdefaultGroups\x12>\n" +
+	"\x19default_primary_group_gid\x18\r \x01(\rH\x00R\x16defaultPrimaryGroupGid\x88\x01\x01B\x1c\n" +
+	"\x1a_default_primary_group_gid\"\x8e\x01\n" +
+	"\vBrokerCheck\x120\n" +
+	"\x06checks\x18\x01 \x03(\v2\x18.authd.BrokerCheck.CheckR\x06checks\x1aM\n" +
+	"\x05Check\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x18\n" +
+	"\adetails\x18\x03 \x01(\tR\adetails\"\x9c\x02\n" +
+	"\vLoginPolicy\x12%\n" +
+	"\x0eallowed_groups\x18\x01 \x03(\tR\rallowedGroups\x12\x1a\n" +
+	"\blockdown\x18\x02 \x01(\bR\blockdown\x12\x1f\n" +
+	"\vlogin_hours\x18\x03 \x03(\tR\n" +
+	"loginHours\x12&\n" +
+	"\x0foffline_max_age\x18\x04 \x01(\x03R\rofflineMaxAge\x12,\n" +
+	"\x12offline_max_logins\x18\x06 \x01(\rR\x10offlineMaxLogins\x12.\n" +
+	"\acentral\x18\x05 \x01(\v2\x14.authd.CentralPolicyR\acentral\x12#\n" +
+	"\rdenied_groups\x18\a \x03(\tR\fdeniedGroups\"\x88\x01\n" +
+	"\rCentralPolicy\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x18\n" +
+	"\aapplied\x18\x02 \x01(\bR\aapplied\x12\x16\n" +
+	"\x06serial\x18\x03 \x01(\x04R\x06serial\x12\x1d\n" +
+	"\n" +
+	"fetched_at\x18\x04 \x01(\x03R\tfetchedAt\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\"\x91\x01\n" +
+	"\rUserLifecycle\x12\x14\n" +
+	"\x05state\x18\x01 \x01(\tR\x05state\x12\x14\n" +
+	"\x05since\x18\x02 \x01(\x03R\x05since\x12\x1d\n" +
+	"\n" +
+	"next_state\x18\x03 \x01(\tR\tnextState\x12\x1b\n" +
+	"\tnext_time\x18\x04 \x01(\x03R\bnextTime\x12\x18\n" +
+	"\aarchive\x18\x05 \x01(\tR\aarchive\".\n" +
+	"\x13CleanupHomesRequest\x12\x17\n" +
+	"\adry_run\x18\x01 \x01(\bR\x06dryRun\"\xfc\x01\n" +
+	"\vHomeCleanup\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
+	"\x03uid\x18\x02 \x01(\rR\x03uid\x12\x12\n" +
+	"\x04home\x18\x03 \x01(\tR\x04home\x12\x1d\n" +
+	"\n" +
+	"mail_spool\x18\x04 \x01(\tR\tmailSpool\x12\x1d\n" +
+	"\n" +
+	"deleted_at\x18\x05 \x01(\x03R\tdeletedAt\x12\x15\n" +
+	"\x06due_at\x18\x06 \x01(\x03R\x05dueAt\x12\x16\n" +
+	"\x06action\x18\a \x01(\tR\x06action\x12\x18\n" +
+	"\askipped\x18\b \x01(\tR\askipped\x12\x12\n" +
+	"\x04done\x18\t \x01(\bR\x04done\x12\x18\n" +
+	"\aarchive\x18\n" +
+	" \x01(\tR\aarchive\"A\n" +
+	"\x11HomeCleanupReport\x12,\n" +
+	"\aentries\x18\x01 \x03(\v2\x12.authd.HomeCleanupR\aentries\"3\n" +
+	"\x18CleanupStaleUsersRequest\x12\x17\n" +
+	"\adry_run\x18\x01 \x01(\bR\x06dryRun\"\xc4\x01\n" +
+	"\tStaleUser\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
+	"\x03uid\x18\x02 \x01(\rR\x03uid\x12\x12\n" +
+	"\x04home\x18\x03 \x01(\tR\x04home\x12\x1d\n" +
+	"\n" +
+	"last_login\x18\x04 \x01(\x03R\tlastLogin\x12\x16\n" +
+	"\x06action\x18\x05 \x01(\tR\x06action\x12\x18\n" +
+	"\askipped\x18\x06 \x01(\tR\askipped\x12\x12\n" +
+	"\x04done\x18\a \x01(\bR\x04done\x12\x18\n" +
+	"\aarchive\x18\b \x01(\tR\aarchive\"^\n" +
+	"\x0fStaleUserReport\x12*\n" +
+	"\aentries\x18\x01 \x03(\v2\x10.authd.StaleUserR\aentries\x12\x1f\n" +
+	"\vstale_after\x18\x02 \x01(\x03R\n" +
+	"staleAfter\"6\n" +
+	"\x18ListOrphanedHomesRequest\x12\x1a\n" +
+	"\bprefixes\x18\x01 \x03(\tR\bprefixes\"v\n" +
+	"\fOrphanedHome\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x10\n" +
+	"\x03uid\x18\x02 \x01(\rR\x03uid\x12!\n" +
+	"\fdeleted_user\x18\x03 \x01(\tR\vdeletedUser\x12\x1d\n" +
+	"\n" +
+	"deleted_at\x18\x04 \x01(\x03R\tdeletedAt\"Z\n" +
+	"\rOrphanedHomes\x12-\n" +
+	"\aentries\x18\x01 \x03(\v2\x13.authd.OrphanedHomeR\aentries\x12\x1a\n" +
+	"\bprefixes\x18\x02 \x03(\tR\bprefixes\"\xc9\x03\n" +
+	"\vUserRecords\x12-\n" +
+	"\x05users\x18\x01 \x03(\v2\x17.authd.UserRecords.UserR\x05users\x120\n" +
+	"\x06groups\x18\x02 \x03(\v2\x18.authd.UserRecords.GroupR\x06groups\x1a\x95\x02\n" +
+	"\x04User\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
+	"\x03uid\x18\x02 \x01(\rR\x03uid\x12\x10\n" +
+	"\x03gid\x18\x03 \x01(\rR\x03gid\x12\x14\n" +
+	"\x05gecos\x18\x04 \x01(\tR\x05gecos\x12\x18\n" +
+	"\ahomedir\x18\x05 \x01(\tR\ahomedir\x12\x14\n" +
+	"\x05shell\x18\x06 \x01(\tR\x05shell\x12\x1b\n" +
+	"\tbroker_id\x18\a \x01(\tR\bbrokerId\x12\x1f\n" +
+	"\vprovider_id\x18\b \x01(\tR\n" +
+	"providerId\x12\x16\n" +
+	"\x06locked\x18\t \x01(\bR\x06locked\x12\x16\n" +
+	"\x06groups\x18\n" +
+	" \x03(\tR\x06groups\x12!\n" +
+	"\flocal_groups\x18\v \x03(\tR\vlocalGroups\x1aA\n" +
+	"\x05Group\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
+	"\x03gid\x18\x02 \x01(\rR\x03gid\x12\x12\n" +
+	"\x04ugid\x18\x03 \x01(\tR\x04ugid\"+\n" +
+	"\x15AllocateUserIDRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\",\n" +
+	"\x16AllocateGroupIDRequest\x12\x12\n" +
+	"\x04ugid\x18\x01 \x01(\tR\x04ugid\"\x1d\n" +
+	"\vAllocatedID\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\"(\n" +
+	"\x10SubscribeRequest\x12\x14\n" +
+	"\x05kinds\x18\x01 \x03(\tR\x05kinds\"\xc6\x01\n" +
+	"\x05Event\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x12\n" +
+	"\x04time\x18\x02 \x01(\x03R\x04time\x12\x1a\n" +
+	"\busername\x18\x03 \x01(\tR\busername\x12\x10\n" +
+	"\x03uid\x18\x04 \x01(\rR\x03uid\x12\x1b\n" +
+	"\tbroker_id\x18\x05 \x01(\tR\bbrokerId\x12\x18\n" +
+	"\aservice\x18\x06 \x01(\tR\aservice\x12\x18\n" +
+	"\aoffline\x18\a \x01(\bR\aoffline\x12\x16\n" +
+	"\x06result\x18\b \x01(\tR\x06result*<\n" +
 	"\vSessionMode\x12\r\n" +
 	"\tUNDEFINED\x10\x00\x12\t\n" +
 	"\x05LOGIN\x10\x01\x12\x13\n" +
-	"\x0fCHANGE_PASSWORD\x10\x022\x8b\x03\n" +
+	"\x0fCHANGE_PASSWORD\x10\x022\x8a\x04\n" +
 	"\x03PAM\x123\n" +
 	"\x10AvailableBrokers\x12\f.authd.Empty\x1a\x11.authd.ABResponse\x120\n" +
 	"\tGetBroker\x12\x10.authd.GBRequest\x1a\x11.authd.GBResponse\x123\n" +
@@ -2341,11 +7783,13 @@ const file_authd_proto_rawDesc = "" +
 	"\x18SelectAuthenticationMode\x12\x11.authd.SAMRequest\x1a\x12.authd.SAMResponse\x126\n" +
 	"\x0fIsAuthenticated\x12\x10.authd.IARequest\x1a\x11.authd.IAResponse\x12,\n" +
 	"\n" +
-	"EndSession\x12\x10.authd.ESRequest\x1a\f.authd.Empty2\xb1\x06\n" +
+	"EndSession\x12\x10.authd.ESRequest\x1a\f.authd.Empty\x124\n" +
+	"\rNotifySession\x12\x10.authd.NSRequest\x1a\x11.authd.NSResponse\x12G\n" +
+	"\bConverse\x12\x1a.authd.ConversationRequest\x1a\x1b.authd.ConversationResponse(\x010\x012\xb2\x15\n" +
 	"\vUserService\x129\n" +
 	"\rGetUserByName\x12\x1b.authd.GetUserByNameRequest\x1a\v.authd.User\x125\n" +
-	"\vGetUserByID\x12\x19.authd.GetUserByIDRequest\x1a\v.authd.User\x12'\n" +
-	"\tListUsers\x12\f.authd.Empty\x1a\f.authd.Users\x120\n" +
+	"\vGetUserByID\x12\x19.authd.GetUserByIDRequest\x1a\v.authd.User\x122\n" +
+	"\tListUsers\x12\x17.authd.ListUsersRequest\x1a\f.authd.Users\x120\n" +
 	"\bLockUser\x12\x16.authd.LockUserRequest\x1a\f.authd.Empty\x124\n" +
 	"\n" +
 	"UnlockUser\x12\x18.authd.UnlockUserRequest\x1a\f.authd.Empty\x12>\n" +
@@ -2357,11 +7801,48 @@ const file_authd_proto_rawDesc = "" +
 	"SetHomeDir\x12\x18.authd.SetHomeDirRequest\x1a\x19.authd.SetHomeDirResponse\x12A\n" +
 	"\n" +
 	"DeleteUser\x12\x18.authd.DeleteUserRequest\x1a\x19.authd.DeleteUserResponse\x126\n" +
-	"\vDeleteGroup\x12\x19.authd.DeleteGroupRequest\x1a\f.authd.Empty\x12<\n" +
+	"\vDeleteGroup\x12\x19.authd.DeleteGroupRequest\x1a\f.authd.Empty\x12E\n" +
+	"\x0fGetLoginHistory\x12\x1d.authd.GetLoginHistoryRequest\x1a\x13.authd.LoginHistory\x128\n" +
+	"\x11GetSecurityReport\x12\f.authd.Empty\x1a\x15.authd.SecurityReport\x122\n" +
+	"\x0eGetLoginPolicy\x12\f.authd.Empty\x1a\x12.authd.LoginPolicy\x126\n" +
+	"\vDisableUser\x12\x19.authd.DisableUserRequest\x1a\f.authd.Empty\x126\n" +
+	"\vArchiveUser\x12\x19.authd.ArchiveUserRequest\x1a\f.authd.Empty\x126\n" +
+	"\vRestoreUser\x12\x19.authd.RestoreUserRequest\x1a\f.authd.Empty\x12H\n" +
+	"\x10GetUserLifecycle\x12\x1e.authd.GetUserLifecycleRequest\x1a\x14.authd.UserLifecycle\x12D\n" +
+	"\fCleanupHomes\x12\x1a.authd.CleanupHomesRequest\x1a\x18.authd.HomeCleanupReport\x12L\n" +
+	"\x11CleanupStaleUsers\x12\x1f.authd.CleanupStaleUsersRequest\x1a\x16.authd.StaleUserReport\x12J\n" +
+	"\x11ListOrphanedHomes\x12\x1f.authd.ListOrphanedHomesRequest\x1a\x14.authd.OrphanedHomes\x12@\n" +
+	"\x10SetDefaultBroker\x12\x1e.authd.SetDefaultBrokerRequest\x1a\f.authd.Empty\x12+\n" +
+	"\vListBrokers\x12\f.authd.Empty\x1a\x0e.authd.Brokers\x12H\n" +
+	"\x10GetBrokerDetails\x12\x1e.authd.GetBrokerDetailsRequest\x1a\x14.authd.BrokerDetails\x12<\n" +
+	"\vCheckBroker\x12\x19.authd.CheckBrokerRequest\x1a\x12.authd.BrokerCheck\x12?\n" +
+	"\n" +
+	"VerifyUser\x12\x18.authd.VerifyUserRequest\x1a\x17.authd.UserVerification\x12E\n" +
+	"\x0fGetSessionToken\x12\x1d.authd.GetSessionTokenRequest\x1a\x13.authd.SessionToken\x12.\n" +
+	"\tGetStatus\x12\f.authd.Empty\x1a\x13.authd.DaemonStatus\x129\n" +
+	"\x10RollBackDatabase\x12\f.authd.Empty\x1a\x17.authd.DatabaseRollback\x128\n" +
+	"\fLinkIdentity\x12\x1a.authd.LinkIdentityRequest\x1a\f.authd.Empty\x12<\n" +
+	"\x0eUnlinkIdentity\x12\x1c.authd.UnlinkIdentityRequest\x1a\f.authd.Empty\x12Q\n" +
+	"\x13GetLinkedIdentities\x12!.authd.GetLinkedIdentitiesRequest\x1a\x17.authd.LinkedIdentities\x120\n" +
+	"\rGetUserStatus\x12\f.authd.Empty\x1a\x11.authd.UserStatus\x12/\n" +
+	"\vExportUsers\x12\f.authd.Empty\x1a\x12.authd.UserRecords\x12/\n" +
+	"\vImportUsers\x12\x12.authd.UserRecords\x1a\f.authd.Empty\x12K\n" +
+	"\x11GetAuthorizedKeys\x12\x1f.authd.GetAuthorizedKeysRequest\x1a\x15.authd.AuthorizedKeys\x12=\n" +
+	"\n" +
+	"EnrollTOTP\x12\x18.authd.EnrollTOTPRequest\x1a\x15.authd.TOTPEnrollment\x126\n" +
+	"\vDisableTOTP\x12\x19.authd.DisableTOTPRequest\x1a\f.authd.Empty\x12K\n" +
+	"\x12EnrollMachineToken\x12 .authd.EnrollMachineTokenRequest\x1a\x13.authd.MachineToken\x12D\n" +
+	"\x12RevokeMachineToken\x12 .authd.RevokeMachineTokenRequest\x1a\f.authd.Empty\x12<\n" +
 	"\x0eGetGroupByName\x12\x1c.authd.GetGroupByNameRequest\x1a\f.authd.Group\x128\n" +
-	"\fGetGroupByID\x12\x1a.authd.GetGroupByIDRequest\x1a\f.authd.Group\x12)\n" +
+	"\fGetGroupByID\x12\x1a.authd.GetGroupByIDRequest\x1a\f.authd.Group\x125\n" +
 	"\n" +
-	"ListGroups\x12\f.authd.Empty\x1a\r.authd.GroupsB1Z/github.com/canonical/authd/internal/proto/authdb\x06proto3"
+	"ListGroups\x12\x18.authd.ListGroupsRequest\x1a\r.authd.Groups\x12A\n" +
+	"\x10GetGroupsForUser\x12\x1e.authd.GetGroupsForUserRequest\x1a\r.authd.Groups2\x98\x01\n" +
+	"\fIDAllocation\x12B\n" +
+	"\x0eAllocateUserID\x12\x1c.authd.AllocateUserIDRequest\x1a\x12.authd.AllocatedID\x12D\n" +
+	"\x0fAllocateGroupID\x12\x1d.authd.AllocateGroupIDRequest\x1a\x12.authd.AllocatedID2>\n" +
+	"\x06Events\x124\n" +
+	"\tSubscribe\x12\x17.authd.SubscribeRequest\x1a\f.authd.Event0\x01B1Z/github.com/canonical/authd/internal/proto/authdb\x06proto3"
 
 var (
 	file_authd_proto_rawDescOnce sync.Once
@@ -2375,106 +7856,290 @@ func file_authd_proto_rawDescGZIP() []byte {
 	return file_authd_proto_rawDescData
 }
 
-var file_authd_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_authd_proto_msgTypes = make([]protoimpl.MessageInfo, 39)
+var file_authd_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_authd_proto_msgTypes = make([]protoimpl.MessageInfo, 113)
 var file_authd_proto_goTypes = []any{
 	(SessionMode)(0),                       // 0: authd.SessionMode
-	(*Empty)(nil),                          // 1: authd.Empty
-	(*GBRequest)(nil),                      // 2: authd.GBRequest
-	(*GBResponse)(nil),                     // 3: authd.GBResponse
-	(*ABResponse)(nil),                     // 4: authd.ABResponse
-	(*StringResponse)(nil),                 // 5: authd.StringResponse
-	(*SBRequest)(nil),                      // 6: authd.SBRequest
-	(*SBResponse)(nil),                     // 7: authd.SBResponse
-	(*GAMRequest)(nil),                     // 8: authd.GAMRequest
-	(*UILayout)(nil),                       // 9: authd.UILayout
-	(*GAMResponse)(nil),                    // 10: authd.GAMResponse
-	(*SAMRequest)(nil),                     // 11: authd.SAMRequest
-	(*SAMResponse)(nil),                    // 12: authd.SAMResponse
-	(*IARequest)(nil),                      // 13: authd.IARequest
-	(*IAResponse)(nil),                     // 14: authd.IAResponse
-	(*ESRequest)(nil),                      // 15: authd.ESRequest
-	(*GetUserByNameRequest)(nil),           // 16: authd.GetUserByNameRequest
-	(*GetUserByIDRequest)(nil),             // 17: authd.GetUserByIDRequest
-	(*LockUserRequest)(nil),                // 18: authd.LockUserRequest
-	(*UnlockUserRequest)(nil),              // 19: authd.UnlockUserRequest
-	(*DeleteUserRequest)(nil),              // 20: authd.DeleteUserRequest
-	(*DeleteGroupRequest)(nil),             // 21: authd.DeleteGroupRequest
-	(*GetGroupByNameRequest)(nil),          // 22: authd.GetGroupByNameRequest
-	(*GetGroupByIDRequest)(nil),            // 23: authd.GetGroupByIDRequest
-	(*SetUserIDRequest)(nil),               // 24: authd.SetUserIDRequest
-	(*SetUserIDResponse)(nil),              // 25: authd.SetUserIDResponse
-	(*SetGroupIDRequest)(nil),              // 26: authd.SetGroupIDRequest
-	(*SetGroupIDResponse)(nil),             // 27: authd.SetGroupIDResponse
-	(*SetShellRequest)(nil),                // 28: authd.SetShellRequest
-	(*SetShellResponse)(nil),               // 29: authd.SetShellResponse
-	(*SetHomeDirRequest)(nil),              // 30: authd.SetHomeDirRequest
-	(*SetHomeDirResponse)(nil),             // 31: authd.SetHomeDirResponse
-	(*DeleteUserResponse)(nil),             // 32: authd.DeleteUserResponse
-	(*User)(nil),                           // 33: authd.User
-	(*Users)(nil),                          // 34: authd.Users
-	(*Group)(nil),                          // 35: authd.Group
-	(*Groups)(nil),                         // 36: authd.Groups
-	(*ABResponse_BrokerInfo)(nil),          // 37: authd.ABResponse.BrokerInfo
-	(*GAMResponse_AuthenticationMode)(nil), // 38: authd.GAMResponse.AuthenticationMode
-	(*IARequest_AuthenticationData)(nil),   // 39: authd.IARequest.AuthenticationData
+	(NSRequest_Event)(0),                   // 1: authd.NSRequest.Event
+	(*Empty)(nil),                          // 2: authd.Empty
+	(*GBRequest)(nil),                      // 3: authd.GBRequest
+	(*GBResponse)(nil),                     // 4: authd.GBResponse
+	(*ABResponse)(nil),                     // 5: authd.ABResponse
+	(*StringResponse)(nil),                 // 6: authd.StringResponse
+	(*SBRequest)(nil),                      // 7: authd.SBRequest
+	(*SBResponse)(nil),                     // 8: authd.SBResponse
+	(*GAMRequest)(nil),                     // 9: authd.GAMRequest
+	(*UILayout)(nil),                       // 10: authd.UILayout
+	(*GAMResponse)(nil),                    // 11: authd.GAMResponse
+	(*SAMRequest)(nil),                     // 12: authd.SAMRequest
+	(*SAMResponse)(nil),                    // 13: authd.SAMResponse
+	(*IARequest)(nil),                      // 14: authd.IARequest
+	(*IAResponse)(nil),                     // 15: authd.IAResponse
+	(*ConversationRequest)(nil),            // 16: authd.ConversationRequest
+	(*ConversationResponse)(nil),           // 17: authd.ConversationResponse
+	(*ESRequest)(nil),                      // 18: authd.ESRequest
+	(*NSRequest)(nil),                      // 19: authd.NSRequest
+	(*NSResponse)(nil),                     // 20: authd.NSResponse
+	(*EphemeralHome)(nil),                  // 21: authd.EphemeralHome
+	(*NetworkHome)(nil),                    // 22: authd.NetworkHome
+	(*HomedHome)(nil),                      // 23: authd.HomedHome
+	(*FscryptHome)(nil),                    // 24: authd.FscryptHome
+	(*LocalHome)(nil),                      // 25: authd.LocalHome
+	(*GetUserByNameRequest)(nil),           // 26: authd.GetUserByNameRequest
+	(*GetUserByIDRequest)(nil),             // 27: authd.GetUserByIDRequest
+	(*ListUsersRequest)(nil),               // 28: authd.ListUsersRequest
+	(*ListGroupsRequest)(nil),              // 29: authd.ListGroupsRequest
+	(*GetGroupsForUserRequest)(nil),        // 30: authd.GetGroupsForUserRequest
+	(*LockUserRequest)(nil),                // 31: authd.LockUserRequest
+	(*UnlockUserRequest)(nil),              // 32: authd.UnlockUserRequest
+	(*DeleteUserRequest)(nil),              // 33: authd.DeleteUserRequest
+	(*DisableUserRequest)(nil),             // 34: authd.DisableUserRequest
+	(*ArchiveUserRequest)(nil),             // 35: authd.ArchiveUserRequest
+	(*RestoreUserRequest)(nil),             // 36: authd.RestoreUserRequest
+	(*GetUserLifecycleRequest)(nil),        // 37: authd.GetUserLifecycleRequest
+	(*GetLoginHistoryRequest)(nil),         // 38: authd.GetLoginHistoryRequest
+	(*DeleteGroupRequest)(nil),             // 39: authd.DeleteGroupRequest
+	(*SetDefaultBrokerRequest)(nil),        // 40: authd.SetDefaultBrokerRequest
+	(*GetBrokerDetailsRequest)(nil),        // 41: authd.GetBrokerDetailsRequest
+	(*CheckBrokerRequest)(nil),             // 42: authd.CheckBrokerRequest
+	(*VerifyUserRequest)(nil),              // 43: authd.VerifyUserRequest
+	(*UserVerification)(nil),               // 44: authd.UserVerification
+	(*GetSessionTokenRequest)(nil),         // 45: authd.GetSessionTokenRequest
+	(*UserStatus)(nil),                     // 46: authd.UserStatus
+	(*CachedToken)(nil),                    // 47: authd.CachedToken
+	(*SessionToken)(nil),                   // 48: authd.SessionToken
+	(*GetGroupByNameRequest)(nil),          // 49: authd.GetGroupByNameRequest
+	(*GetGroupByIDRequest)(nil),            // 50: authd.GetGroupByIDRequest
+	(*SetUserIDRequest)(nil),               // 51: authd.SetUserIDRequest
+	(*SetUserIDResponse)(nil),              // 52: authd.SetUserIDResponse
+	(*SetGroupIDRequest)(nil),              // 53: authd.SetGroupIDRequest
+	(*SetGroupIDResponse)(nil),             // 54: authd.SetGroupIDResponse
+	(*SetShellRequest)(nil),                // 55: authd.SetShellRequest
+	(*SetShellResponse)(nil),               // 56: authd.SetShellResponse
+	(*SetHomeDirRequest)(nil),              // 57: authd.SetHomeDirRequest
+	(*SetHomeDirResponse)(nil),             // 58: authd.SetHomeDirResponse
+	(*DeleteUserResponse)(nil),             // 59: authd.DeleteUserResponse
+	(*User)(nil),                           // 60: authd.User
+	(*Users)(nil),                          // 61: authd.Users
+	(*Group)(nil),                          // 62: authd.Group
+	(*Groups)(nil),                         // 63: authd.Groups
+	(*LoginRecord)(nil),                    // 64: authd.LoginRecord
+	(*LoginHistory)(nil),                   // 65: authd.LoginHistory
+	(*SecurityCheck)(nil),                  // 66: authd.SecurityCheck
+	(*SecurityReport)(nil),                 // 67: authd.SecurityReport
+	(*DaemonStatus)(nil),                   // 68: authd.DaemonStatus
+	(*DatabaseStatus)(nil),                 // 69: authd.DatabaseStatus
+	(*OperationLatency)(nil),               // 70: authd.OperationLatency
+	(*LatencyBucket)(nil),                  // 71: authd.LatencyBucket
+	(*DatabaseRollback)(nil),               // 72: authd.DatabaseRollback
+	(*LinkIdentityRequest)(nil),            // 73: authd.LinkIdentityRequest
+	(*UnlinkIdentityRequest)(nil),          // 74: authd.UnlinkIdentityRequest
+	(*GetLinkedIdentitiesRequest)(nil),     // 75: authd.GetLinkedIdentitiesRequest
+	(*LinkedIdentities)(nil),               // 76: authd.LinkedIdentities
+	(*GetAuthorizedKeysRequest)(nil),       // 77: authd.GetAuthorizedKeysRequest
+	(*AuthorizedKeys)(nil),                 // 78: authd.AuthorizedKeys
+	(*EnrollTOTPRequest)(nil),              // 79: authd.EnrollTOTPRequest
+	(*TOTPEnrollment)(nil),                 // 80: authd.TOTPEnrollment
+	(*DisableTOTPRequest)(nil),             // 81: authd.DisableTOTPRequest
+	(*EnrollMachineTokenRequest)(nil),      // 82: authd.EnrollMachineTokenRequest
+	(*MachineToken)(nil),                   // 83: authd.MachineToken
+	(*RevokeMachineTokenRequest)(nil),      // 84: authd.RevokeMachineTokenRequest
+	(*BrokerStatus)(nil),                   // 85: authd.BrokerStatus
+	(*Brokers)(nil),                        // 86: authd.Brokers
+	(*BrokerDetails)(nil),                  // 87: authd.BrokerDetails
+	(*BrokerCheck)(nil),                    // 88: authd.BrokerCheck
+	(*LoginPolicy)(nil),                    // 89: authd.LoginPolicy
+	(*CentralPolicy)(nil),                  // 90: authd.CentralPolicy
+	(*UserLifecycle)(nil),                  // 91: authd.UserLifecycle
+	(*CleanupHomesRequest)(nil),            // 92: authd.CleanupHomesRequest
+	(*HomeCleanup)(nil),                    // 93: authd.HomeCleanup
+	(*HomeCleanupReport)(nil),              // 94: authd.HomeCleanupReport
+	(*CleanupStaleUsersRequest)(nil),       // 95: authd.CleanupStaleUsersRequest
+	(*StaleUser)(nil),                      // 96: authd.StaleUser
+	(*StaleUserReport)(nil),                // 97: authd.StaleUserReport
+	(*ListOrphanedHomesRequest)(nil),       // 98: authd.ListOrphanedHomesRequest
+	(*OrphanedHome)(nil),                   // 99: authd.OrphanedHome
+	(*OrphanedHomes)(nil),                  // 100: authd.OrphanedHomes
+	(*UserRecords)(nil),                    // 101: authd.UserRecords
+	(*AllocateUserIDRequest)(nil),          // 102: authd.AllocateUserIDRequest
+	(*AllocateGroupIDRequest)(nil),         // 103: authd.AllocateGroupIDRequest
+	(*AllocatedID)(nil),                    // 104: authd.AllocatedID
+	(*SubscribeRequest)(nil),               // 105: authd.SubscribeRequest
+	(*Event)(nil),                          // 106: authd.Event
+	(*ABResponse_BrokerInfo)(nil),          // 107: authd.ABResponse.BrokerInfo
+	(*GAMResponse_AuthenticationMode)(nil), // 108: authd.GAMResponse.AuthenticationMode
+	(*IARequest_AuthenticationData)(nil),   // 109: authd.IARequest.AuthenticationData
+	nil,                                    // 110: authd.NSResponse.EnvEntry
+	(*LinkedIdentities_Identity)(nil),      // 111: authd.LinkedIdentities.Identity
+	(*BrokerCheck_Check)(nil),              // 112: authd.BrokerCheck.Check
+	(*UserRecords_User)(nil),               // 113: authd.UserRecords.User
+	(*UserRecords_Group)(nil),              // 114: authd.UserRecords.Group
 }
 var file_authd_proto_depIdxs = []int32{
-	37, // 0: authd.ABResponse.brokers_infos:type_name -> authd.ABResponse.BrokerInfo
-	0,  // 1: authd.SBRequest.mode:type_name -> authd.SessionMode
-	9,  // 2: authd.GAMRequest.supported_ui_layouts:type_name -> authd.UILayout
-	38, // 3: authd.GAMResponse.authentication_modes:type_name -> authd.GAMResponse.AuthenticationMode
-	9,  // 4: authd.SAMResponse.ui_layout_info:type_name -> authd.UILayout
-	39, // 5: authd.IARequest.authentication_data:type_name -> authd.IARequest.AuthenticationData
-	33, // 6: authd.Users.users:type_name -> authd.User
-	35, // 7: authd.Groups.groups:type_name -> authd.Group
-	1,  // 8: authd.PAM.AvailableBrokers:input_type -> authd.Empty
-	2,  // 9: authd.PAM.GetBroker:input_type -> authd.GBRequest
-	6,  // 10: authd.PAM.SelectBroker:input_type -> authd.SBRequest
-	8,  // 11: authd.PAM.GetAuthenticationModes:input_type -> authd.GAMRequest
-	11, // 12: authd.PAM.SelectAuthenticationMode:input_type -> authd.SAMRequest
-	13, // 13: authd.PAM.IsAuthenticated:input_type -> authd.IARequest
-	15, // 14: authd.PAM.EndSession:input_type -> authd.ESRequest
-	16, // 15: authd.UserService.GetUserByName:input_type -> authd.GetUserByNameRequest
-	17, // 16: authd.UserService.GetUserByID:input_type -> authd.GetUserByIDRequest
-	1,  // 17: authd.UserService.ListUsers:input_type -> authd.Empty
-	18, // 18: authd.UserService.LockUser:input_type -> authd.LockUserRequest
-	19, // 19: authd.UserService.UnlockUser:input_type -> authd.UnlockUserRequest
-	24, // 20: authd.UserService.SetUserID:input_type -> authd.SetUserIDRequest
-	26, // 21: authd.UserService.SetGroupID:input_type -> authd.SetGroupIDRequest
-	28, // 22: authd.UserService.SetShell:input_type -> authd.SetShellRequest
-	30, // 23: authd.UserService.SetHomeDir:input_type -> authd.SetHomeDirRequest
-	20, // 24: authd.UserService.DeleteUser:input_type -> authd.DeleteUserRequest
-	21, // 25: authd.UserService.DeleteGroup:input_type -> authd.DeleteGroupRequest
-	22, // 26: authd.UserService.GetGroupByName:input_type -> authd.GetGroupByNameRequest
-	23, // 27: authd.UserService.GetGroupByID:input_type -> authd.GetGroupByIDRequest
-	1,  // 28: authd.UserService.ListGroups:input_type -> authd.Empty
-	4,  // 29: authd.PAM.AvailableBrokers:output_type -> authd.ABResponse
-	3,  // 30: authd.PAM.GetBroker:output_type -> authd.GBResponse
-	7,  // 31: authd.PAM.SelectBroker:output_type -> authd.SBResponse
-	10, // 32: authd.PAM.GetAuthenticationModes:output_type -> authd.GAMResponse
-	12, // 33: authd.PAM.SelectAuthenticationMode:output_type -> authd.SAMResponse
-	14, // 34: authd.PAM.IsAuthenticated:output_type -> authd.IAResponse
-	1,  // 35: authd.PAM.EndSession:output_type -> authd.Empty
-	33, // 36: authd.UserService.GetUserByName:output_type -> authd.User
-	33, // 37: authd.UserService.GetUserByID:output_type -> authd.User
-	34, // 38: authd.UserService.ListUsers:output_type -> authd.Users
-	1,  // 39: authd.UserService.LockUser:output_type -> authd.Empty
-	1,  // 40: authd.UserService.UnlockUser:output_type -> authd.Empty
-	25, // 41: authd.UserService.SetUserID:output_type -> authd.SetUserIDResponse
-	27, // 42: authd.UserService.SetGroupID:output_type -> authd.SetGroupIDResponse
-	29, // 43: authd.UserService.SetShell:output_type -> authd.SetShellResponse
-	31, // 44: authd.UserService.SetHomeDir:output_type -> authd.SetHomeDirResponse
-	32, // 45: authd.UserService.DeleteUser:output_type -> authd.DeleteUserResponse
-	1,  // 46: authd.UserService.DeleteGroup:output_type -> authd.Empty
-	35, // 47: authd.UserService.GetGroupByName:output_type -> authd.Group
-	35, // 48: authd.UserService.GetGroupByID:output_type -> authd.Group
-	36, // 49: authd.UserService.ListGroups:output_type -> authd.Groups
-	29, // [29:50] is the sub-list for method output_type
-	8,  // [8:29] is the sub-list for method input_type
-	8,  // [8:8] is the sub-list for extension type_name
-	8,  // [8:8] is the sub-list for extension extendee
-	0,  // [0:8] is the sub-list for field type_name
+	107, // 0: authd.ABResponse.brokers_infos:type_name -> authd.ABResponse.BrokerInfo
+	0,   // 1: authd.SBRequest.mode:type_name -> authd.SessionMode
+	10,  // 2: authd.GAMRequest.supported_ui_layouts:type_name -> authd.UILayout
+	108, // 3: authd.GAMResponse.authentication_modes:type_name -> authd.GAMResponse.AuthenticationMode
+	10,  // 4: authd.SAMResponse.ui_layout_info:type_name -> authd.UILayout
+	109, // 5: authd.IARequest.authentication_data:type_name -> authd.IARequest.AuthenticationData
+	2,   // 6: authd.ConversationRequest.available_brokers:type_name -> authd.Empty
+	3,   // 7: authd.ConversationRequest.get_broker:type_name -> authd.GBRequest
+	7,   // 8: authd.ConversationRequest.select_broker:type_name -> authd.SBRequest
+	9,   // 9: authd.ConversationRequest.get_authentication_modes:type_name -> authd.GAMRequest
+	12,  // 10: authd.ConversationRequest.select_authentication_mode:type_name -> authd.SAMRequest
+	14,  // 11: authd.ConversationRequest.is_authenticated:type_name -> authd.IARequest
+	18,  // 12: authd.ConversationRequest.end_session:type_name -> authd.ESRequest
+	2,   // 13: authd.ConversationRequest.cancel:type_name -> authd.Empty
+	5,   // 14: authd.ConversationResponse.available_brokers:type_name -> authd.ABResponse
+	4,   // 15: authd.ConversationResponse.get_broker:type_name -> authd.GBResponse
+	8,   // 16: authd.ConversationResponse.select_broker:type_name -> authd.SBResponse
+	11,  // 17: authd.ConversationResponse.get_authentication_modes:type_name -> authd.GAMResponse
+	13,  // 18: authd.ConversationResponse.select_authentication_mode:type_name -> authd.SAMResponse
+	15,  // 19: authd.ConversationResponse.is_authenticated:type_name -> authd.IAResponse
+	2,   // 20: authd.ConversationResponse.end_session:type_name -> authd.Empty
+	1,   // 21: authd.NSRequest.event:type_name -> authd.NSRequest.Event
+	110, // 22: authd.NSResponse.env:type_name -> authd.NSResponse.EnvEntry
+	21,  // 23: authd.NSResponse.ephemeral_home:type_name -> authd.EphemeralHome
+	22,  // 24: authd.NSResponse.network_home:type_name -> authd.NetworkHome
+	23,  // 25: authd.NSResponse.homed_home:type_name -> authd.HomedHome
+	24,  // 26: authd.NSResponse.fscrypt_home:type_name -> authd.FscryptHome
+	25,  // 27: authd.NSResponse.local_home:type_name -> authd.LocalHome
+	47,  // 28: authd.UserStatus.token:type_name -> authd.CachedToken
+	64,  // 29: authd.UserStatus.last_logins:type_name -> authd.LoginRecord
+	60,  // 30: authd.Users.users:type_name -> authd.User
+	62,  // 31: authd.Groups.groups:type_name -> authd.Group
+	64,  // 32: authd.LoginHistory.records:type_name -> authd.LoginRecord
+	66,  // 33: authd.SecurityReport.checks:type_name -> authd.SecurityCheck
+	69,  // 34: authd.DaemonStatus.database:type_name -> authd.DatabaseStatus
+	85,  // 35: authd.DaemonStatus.brokers:type_name -> authd.BrokerStatus
+	70,  // 36: authd.DatabaseStatus.operations:type_name -> authd.OperationLatency
+	71,  // 37: authd.OperationLatency.buckets:type_name -> authd.LatencyBucket
+	111, // 38: authd.LinkedIdentities.identities:type_name -> authd.LinkedIdentities.Identity
+	87,  // 39: authd.Brokers.brokers:type_name -> authd.BrokerDetails
+	112, // 40: authd.BrokerCheck.checks:type_name -> authd.BrokerCheck.Check
+	90,  // 41: authd.LoginPolicy.central:type_name -> authd.CentralPolicy
+	93,  // 42: authd.HomeCleanupReport.entries:type_name -> authd.HomeCleanup
+	96,  // 43: authd.StaleUserReport.entries:type_name -> authd.StaleUser
+	99,  // 44: authd.OrphanedHomes.entries:type_name -> authd.OrphanedHome
+	113, // 45: authd.UserRecords.users:type_name -> authd.UserRecords.User
+	114, // 46: authd.UserRecords.groups:type_name -> authd.UserRecords.Group
+	2,   // 47: authd.PAM.AvailableBrokers:input_type -> authd.Empty
+	3,   // 48: authd.PAM.GetBroker:input_type -> authd.GBRequest
+	7,   // 49: authd.PAM.SelectBroker:input_type -> authd.SBRequest
+	9,   // 50: authd.PAM.GetAuthenticationModes:input_type -> authd.GAMRequest
+	12,  // 51: authd.PAM.SelectAuthenticationMode:input_type -> authd.SAMRequest
+	14,  // 52: authd.PAM.IsAuthenticated:input_type -> authd.IARequest
+	18,  // 53: authd.PAM.EndSession:input_type -> authd.ESRequest
+	19,  // 54: authd.PAM.NotifySession:input_type -> authd.NSRequest
+	16,  // 55: authd.PAM.Converse:input_type -> authd.ConversationRequest
+	26,  // 56: authd.UserService.GetUserByName:input_type -> authd.GetUserByNameRequest
+	27,  // 57: authd.UserService.GetUserByID:input_type -> authd.GetUserByIDRequest
+	28,  // 58: authd.UserService.ListUsers:input_type -> authd.ListUsersRequest
+	31,  // 59: authd.UserService.LockUser:input_type -> authd.LockUserRequest
+	32,  // 60: authd.UserService.UnlockUser:input_type -> authd.UnlockUserRequest
+	51,  // 61: authd.UserService.SetUserID:input_type -> authd.SetUserIDRequest
+	53,  // 62: authd.UserService.SetGroupID:input_type -> authd.SetGroupIDRequest
+	55,  // 63: authd.UserService.SetShell:input_type -> authd.SetShellRequest
+	57,  // 64: authd.UserService.SetHomeDir:input_type -> authd.SetHomeDirRequest
+	33,  // 65: authd.UserService.DeleteUser:input_type -> authd.DeleteUserRequest
+	39,  // 66: authd.UserService.DeleteGroup:input_type -> authd.DeleteGroupRequest
+	38,  // 67: authd.UserService.GetLoginHistory:input_type -> authd.GetLoginHistoryRequest
+	2,   // 68: authd.UserService.GetSecurityReport:input_type -> authd.Empty
+	2,   // 69: authd.UserService.GetLoginPolicy:input_type -> authd.Empty
+	34,  // 70: authd.UserService.DisableUser:input_type -> authd.DisableUserRequest
+	35,  // 71: authd.UserService.ArchiveUser:input_type -> authd.ArchiveUserRequest
+	36,  // 72: authd.UserService.RestoreUser:input_type -> authd.RestoreUserRequest
+	37,  // 73: authd.UserService.GetUserLifecycle:input_type -> authd.GetUserLifecycleRequest
+	92,  // 74: authd.UserService.CleanupHomes:input_type -> authd.CleanupHomesRequest
+	95,  // 75: authd.UserService.CleanupStaleUsers:input_type -> authd.CleanupStaleUsersRequest
+	98,  // 76: authd.UserService.ListOrphanedHomes:input_type -> authd.ListOrphanedHomesRequest
+	40,  // 77: authd.UserService.SetDefaultBroker:input_type -> authd.SetDefaultBrokerRequest
+	2,   // 78: authd.UserService.ListBrokers:input_type -> authd.Empty
+	41,  // 79: authd.UserService.GetBrokerDetails:input_type -> authd.GetBrokerDetailsRequest
+	42,  // 80: authd.UserService.CheckBroker:input_type -> authd.CheckBrokerRequest
+	43,  // 81: authd.UserService.VerifyUser:input_type -> authd.VerifyUserRequest
+	45,  // 82: authd.UserService.GetSessionToken:input_type -> authd.GetSessionTokenRequest
+	2,   // 83: authd.UserService.GetStatus:input_type -> authd.Empty
+	2,   // 84: authd.UserService.RollBackDatabase:input_type -> authd.Empty
+	73,  // 85: authd.UserService.LinkIdentity:input_type -> authd.LinkIdentityRequest
+	74,  // 86: authd.UserService.UnlinkIdentity:input_type -> authd.UnlinkIdentityRequest
+	75,  // 87: authd.UserService.GetLinkedIdentities:input_type -> authd.GetLinkedIdentitiesRequest
+	2,   // 88: authd.UserService.GetUserStatus:input_type -> authd.Empty
+	2,   // 89: authd.UserService.ExportUsers:input_type -> authd.Empty
+	101, // 90: authd.UserService.ImportUsers:input_type -> authd.UserRecords
+	77,  // 91: authd.UserService.GetAuthorizedKeys:input_type -> authd.GetAuthorizedKeysRequest
+	79,  // 92: authd.UserService.EnrollTOTP:input_type -> authd.EnrollTOTPRequest
+	81,  // 93: authd.UserService.DisableTOTP:input_type -> authd.DisableTOTPRequest
+	82,  // 94: authd.UserService.EnrollMachineToken:input_type -> authd.EnrollMachineTokenRequest
+	84,  // 95: authd.UserService.RevokeMachineToken:input_type -> authd.RevokeMachineTokenRequest
+	49,  // 96: authd.UserService.GetGroupByName:input_type -> authd.GetGroupByNameRequest
+	50,  // 97: authd.UserService.GetGroupByID:input_type -> authd.GetGroupByIDRequest
+	29,  // 98: authd.UserService.ListGroups:input_type -> authd.ListGroupsRequest
+	30,  // 99: authd.UserService.GetGroupsForUser:input_type -> authd.GetGroupsForUserRequest
+	102, // 100: authd.IDAllocation.AllocateUserID:input_type -> authd.AllocateUserIDRequest
+	103, // 101: authd.IDAllocation.AllocateGroupID:input_type -> authd.AllocateGroupIDRequest
+	105, // 102: authd.Events.Subscribe:input_type -> authd.SubscribeRequest
+	5,   // 103: authd.PAM.AvailableBrokers:output_type -> authd.ABResponse
+	4,   // 104: authd.PAM.GetBroker:output_type -> authd.GBResponse
+	8,   // 105: authd.PAM.SelectBroker:output_type -> authd.SBResponse
+	11,  // 106: authd.PAM.GetAuthenticationModes:output_type -> authd.GAMResponse
+	13,  // 107: authd.PAM.SelectAuthenticationMode:output_type -> authd.SAMResponse
+	15,  // 108: authd.PAM.IsAuthenticated:output_type -> authd.IAResponse
+	2,   // 109: authd.PAM.EndSession:output_type -> authd.Empty
+	20,  // 110: authd.PAM.NotifySession:output_type -> authd.NSResponse
+	17,  // 111: authd.PAM.Converse:output_type -> authd.ConversationResponse
+	60,  // 112: authd.UserService.GetUserByName:output_type -> authd.User
+	60,  // 113: authd.UserService.GetUserByID:output_type -> authd.User
+	61,  // 114: authd.UserService.ListUsers:output_type -> authd.Users
+	2,   // 115: authd.UserService.LockUser:output_type -> authd.Empty
+	2,   // 116: authd.UserService.UnlockUser:output_type -> authd.Empty
+	52,  // 117: authd.UserService.SetUserID:output_type -> authd.SetUserIDResponse
+	54,  // 118: authd.UserService.SetGroupID:output_type -> authd.SetGroupIDResponse
+	56,  // 119: authd.UserService.SetShell:output_type -> authd.SetShellResponse
+	58,  // 120: authd.UserService.SetHomeDir:output_type -> authd.SetHomeDirResponse
+	59,  // 121: authd.UserService.DeleteUser:output_type -> authd.DeleteUserResponse
+	2,   // 122: authd.UserService.DeleteGroup:output_type -> authd.Empty
+	65,  // 123: authd.UserService.GetLoginHistory:output_type -> authd.LoginHistory
+	67,  // 124: authd.UserService.GetSecurityReport:output_type -> authd.SecurityReport
+	89,  // 125: authd.UserService.GetLoginPolicy:output_type -> authd.LoginPolicy
+	2,   // 126: authd.UserService.DisableUser:output_type -> authd.Empty
+	2,   // 127: authd.UserService.ArchiveUser:output_type -> authd.Empty
+	2,   // 128: authd.UserService.RestoreUser:output_type -> authd.Empty
+	91,  // 129: authd.UserService.GetUserLifecycle:output_type -> authd.UserLifecycle
+	94,  // 130: authd.UserService.CleanupHomes:output_type -> authd.HomeCleanupReport
+	97,  // 131: authd.UserService.CleanupStaleUsers:output_type -> authd.StaleUserReport
+	100, // 132: authd.UserService.ListOrphanedHomes:output_type -> authd.OrphanedHomes
+	2,   // 133: authd.UserService.SetDefaultBroker:output_type -> authd.Empty
+	86,  // 134: authd.UserService.ListBrokers:output_type -> authd.Brokers
+	87,  // 135: authd.UserService.GetBrokerDetails:output_type -> authd.BrokerDetails
+	88,  // 136: authd.UserService.CheckBroker:output_type -> authd.BrokerCheck
+	44,  // 137: authd.UserService.VerifyUser:output_type -> authd.UserVerification
+	48,  // 138: authd.UserService.GetSessionToken:output_type -> authd.SessionToken
+	68,  // 139: authd.UserService.GetStatus:output_type -> authd.DaemonStatus
+	72,  // 140: authd.UserService.RollBackDatabase:output_type -> authd.DatabaseRollback
+	2,   // 141: authd.UserService.LinkIdentity:output_type -> authd.Empty
+	2,   // 142: authd.UserService.UnlinkIdentity:output_type -> authd.Empty
+	76,  // 143: authd.UserService.GetLinkedIdentities:output_type -> authd.LinkedIdentities
+	46,  // 144: authd.UserService.GetUserStatus:output_type -> authd.UserStatus
+	101, // 145: authd.UserService.ExportUsers:output_type -> authd.UserRecords
+	2,   // 146: authd.UserService.ImportUsers:output_type -> authd.Empty
+	78,  // 147: authd.UserService.GetAuthorizedKeys:output_type -> authd.AuthorizedKeys
+	80,  // 148: authd.UserService.EnrollTOTP:output_type -> authd.TOTPEnrollment
+	2,   // 149: authd.UserService.DisableTOTP:output_type -> authd.Empty
+	83,  // 150: authd.UserService.EnrollMachineToken:output_type -> authd.MachineToken
+	2,   // 151: authd.UserService.RevokeMachineToken:output_type -> authd.Empty
+	62,  // 152: authd.UserService.GetGroupByName:output_type -> authd.Group
+	62,  // 153: authd.UserService.GetGroupByID:output_type -> authd.Group
+	63,  // 154: authd.UserService.ListGroups:output_type -> authd.Groups
+	63,  // 155: authd.UserService.GetGroupsForUser:output_type -> authd.Groups
+	104, // 156: authd.IDAllocation.AllocateUserID:output_type -> authd.AllocatedID
+	104, // 157: authd.IDAllocation.AllocateGroupID:output_type -> authd.AllocatedID
+	106, // 158: authd.Events.Subscribe:output_type -> authd.Event
+	103, // [103:159] is the sub-list for method output_type
+	47,  // [47:103] is the sub-list for method input_type
+	47,  // [47:47] is the sub-list for extension type_name
+	47,  // [47:47] is the sub-list for extension extendee
+	0,   // [0:47] is the sub-list for field type_name
 }
 
 func init() { file_authd_proto_init() }
@@ -2483,11 +8148,34 @@ func file_authd_proto_init() {
 		return
 	}
 	file_authd_proto_msgTypes[8].OneofWrappers = []any{}
-	file_authd_proto_msgTypes[36].OneofWrappers = []any{}
-	file_authd_proto_msgTypes[38].OneofWrappers = []any{
+	file_authd_proto_msgTypes[14].OneofWrappers = []any{
+		(*ConversationRequest_AvailableBrokers)(nil),
+		(*ConversationRequest_GetBroker)(nil),
+		(*ConversationRequest_SelectBroker)(nil),
+		(*ConversationRequest_GetAuthenticationModes)(nil),
+		(*ConversationRequest_SelectAuthenticationMode)(nil),
+		(*ConversationRequest_IsAuthenticated)(nil),
+		(*ConversationRequest_EndSession)(nil),
+		(*ConversationRequest_Cancel)(nil),
+	}
+	file_authd_proto_msgTypes[15].OneofWrappers = []any{
+		(*ConversationResponse_AvailableBrokers)(nil),
+		(*ConversationResponse_GetBroker)(nil),
+		(*ConversationResponse_SelectBroker)(nil),
+		(*ConversationResponse_GetAuthenticationModes)(nil),
+		(*ConversationResponse_SelectAuthenticationMode)(nil),
+		(*ConversationResponse_IsAuthenticated)(nil),
+		(*ConversationResponse_EndSession)(nil),
+	}
+	file_authd_proto_msgTypes[85].OneofWrappers = []any{}
+	file_authd_proto_msgTypes[105].OneofWrappers = []any{}
+	file_authd_proto_msgTypes[107].OneofWrappers = []any{
 		(*IARequest_AuthenticationData_Secret)(nil),
 		(*IARequest_AuthenticationData_Wait)(nil),
 		(*IARequest_AuthenticationData_Skip)(nil),
+		(*IARequest_AuthenticationData_WebauthnAssertion)(nil),
+		(*IARequest_AuthenticationData_TotpCode)(nil),
+		(*IARequest_AuthenticationData_MachineToken)(nil),
 		(*IARequest_AuthenticationData_Challenge)(nil),
 	}
 	type x struct{}
@@ -2495,10 +8183,10 @@ func file_authd_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_authd_proto_rawDesc), len(file_authd_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   39,
+			NumEnums:      2,
+			NumMessages:   113,
 			NumExtensions: 0,
-			NumServices:   2,
+			NumServices:   4,
 		},
 		GoTypes:           file_authd_proto_goTypes,
 		DependencyIndexes: file_authd_proto_depIdxs,
@@ -26,6 +26,8 @@ const (
 	PAM_SelectAuthenticationMode_FullMethodName = "/authd.PAM/SelectAuthenticationMode"
 	PAM_IsAuthenticated_FullMethodName          = "/authd.PAM/IsAuthenticated"
 	PAM_EndSession_FullMethodName               = "/authd.PAM/EndSession"
+	PAM_NotifySession_FullMethodName            = "/authd.PAM/NotifySession"
+	PAM_Converse_FullMethodName                 = "/authd.PAM/Converse"
 )
 
 // PAMClient is the client API for PAM service.
@@ -39,6 +41,11 @@ type PAMClient interface {
 	SelectAuthenticationMode(ctx context.Context, in *SAMRequest, opts ...grpc.CallOption) (*SAMResponse, error)
 	IsAuthenticated(ctx context.Context, in *IARequest, opts ...grpc.CallOption) (*IAResponse, error)
 	EndSession(ctx context.Context, in *ESRequest, opts ...grpc.CallOption) (*Empty, error)
+	NotifySession(ctx context.Context, in *NSRequest, opts ...grpc.CallOption) (*NSResponse, error)
+	// Converse carries the requests of an authentication conversation over a single stream. The responses are sent as
+	// soon as they are available, in any order, so that the client doesn't have to wait for a slow request (e.g. an
+	// IsAuthenticated waiting on a device code) to be notified of the others.
+	Converse(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ConversationRequest, ConversationResponse], error)
 }
 
 type pAMClient struct {
@@ -119,6 +126,29 @@ func (c *pAMClient) EndSession(ctx context.Context, in *ESRequest, opts ...grpc.
 	return out, nil
 }
 
+func (c *pAMClient) NotifySession(ctx context.Context, in *NSRequest, opts ...grpc.CallOption) (*NSResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NSResponse)
+	err := c.cc.Invoke(ctx, PAM_NotifySession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pAMClient) Converse(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ConversationRequest, ConversationResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PAM_ServiceDesc.Streams[0], PAM_Converse_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ConversationRequest, ConversationResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PAM_ConverseClient = grpc.BidiStreamingClient[ConversationRequest, ConversationResponse]
+
 // PAMServer is the server API for PAM service.
 // All implementations must embed UnimplementedPAMServer
 // for forward compatibility.
@@ -130,6 +160,11 @@ type PAMServer interface {
 	SelectAuthenticationMode(context.Context, *SAMRequest) (*SAMResponse, error)
 	IsAuthenticated(context.Context, *IARequest) (*IAResponse, error)
 	EndSession(context.Context, *ESRequest) (*Empty, error)
+	NotifySession(context.Context, *NSRequest) (*NSResponse, error)
+	// Converse carries the requests of an authentication conversation over a single stream. The responses are sent as
+	// soon as they are available, in any order, so that the client doesn't have to wait for a slow request (e.g. an
+	// IsAuthenticated waiting on a device code) to be notified of the others.
+	Converse(grpc.BidiStreamingServer[ConversationRequest, ConversationResponse]) error
 	mustEmbedUnimplementedPAMServer()
 }
 
@@ -161,6 +196,12 @@ func (UnimplementedPAMServer) IsAuthenticated(context.Context, *IARequest) (*IAR
 func (UnimplementedPAMServer) EndSession(context.Context, *ESRequest) (*Empty, error) {
 	return nil, status.Error(codes.Unimplemented, "method EndSession not implemented")
 }
+func (UnimplementedPAMServer) NotifySession(context.Context, *NSRequest) (*NSResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method NotifySession not implemented")
+}
+func (UnimplementedPAMServer) Converse(grpc.BidiStreamingServer[ConversationRequest, ConversationResponse]) error {
+	return status.Error(codes.Unimplemented, "method Converse not implemented")
+}
 func (UnimplementedPAMServer) mustEmbedUnimplementedPAMServer() {}
 func (UnimplementedPAMServer) testEmbeddedByValue()             {}
 
@@ -308,6 +349,31 @@ func _PAM_EndSession_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _PAM_NotifySession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NSRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PAMServer).NotifySession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PAM_NotifySession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PAMServer).NotifySession(ctx, req.(*NSRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PAM_Converse_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PAMServer).Converse(&grpc.GenericServerStream[ConversationRequest, ConversationResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PAM_ConverseServer = grpc.BidiStreamingServer[ConversationRequest, ConversationResponse]
+
 // PAM_ServiceDesc is the grpc.ServiceDesc for PAM service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -343,26 +409,67 @@ var PAM_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "EndSession",
 			Handler:    _PAM_EndSession_Handler,
 		},
+		{
+			MethodName: "NotifySession",
+			Handler:    _PAM_NotifySession_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Converse",
+			Handler:       _PAM_Converse_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "authd.proto",
 }
 
 const (
-	UserService_GetUserByName_FullMethodName  = "/authd.UserService/GetUserByName"
-	UserService_GetUserByID_FullMethodName    = "/authd.UserService/GetUserByID"
-	UserService_ListUsers_FullMethodName      = "/authd.UserService/ListUsers"
-	UserService_LockUser_FullMethodName       = "/authd.UserService/LockUser"
-	UserService_UnlockUser_FullMethodName     = "/authd.UserService/UnlockUser"
-	UserService_SetUserID_FullMethodName      = "/authd.UserService/SetUserID"
-	UserService_SetGroupID_FullMethodName     = "/authd.UserService/SetGroupID"
-	UserService_SetShell_FullMethodName       = "/authd.UserService/SetShell"
-	UserService_SetHomeDir_FullMethodName     = "/authd.UserService/SetHomeDir"
-	UserService_DeleteUser_FullMethodName     = "/authd.UserService/DeleteUser"
-	UserService_DeleteGroup_FullMethodName    = "/authd.UserService/DeleteGroup"
-	UserService_GetGroupByName_FullMethodName = "/authd.UserService/GetGroupByName"
-	UserService_GetGroupByID_FullMethodName   = "/authd.UserService/GetGroupByID"
-	UserService_ListGroups_FullMethodName     = "/authd.UserService/ListGroups"
+	UserService_GetUserByName_FullMethodName       = "/authd.UserService/GetUserByName"
+	UserService_GetUserByID_FullMethodName         = "/authd.UserService/GetUserByID"
+	UserService_ListUsers_FullMethodName           = "/authd.UserService/ListUsers"
+	UserService_LockUser_FullMethodName            = "/authd.UserService/LockUser"
+	UserService_UnlockUser_FullMethodName          = "/authd.UserService/UnlockUser"
+	UserService_SetUserID_FullMethodName           = "/authd.UserService/SetUserID"
+	UserService_SetGroupID_FullMethodName          = "/authd.UserService/SetGroupID"
+	UserService_SetShell_FullMethodName            = "/authd.UserService/SetShell"
+	UserService_SetHomeDir_FullMethodName          = "/authd.UserService/SetHomeDir"
+	UserService_DeleteUser_FullMethodName          = "/authd.UserService/DeleteUser"
+	UserService_DeleteGroup_FullMethodName         = "/authd.UserService/DeleteGroup"
+	UserService_GetLoginHistory_FullMethodName     = "/authd.UserService/GetLoginHistory"
+	UserService_GetSecurityReport_FullMethodName   = "/authd.UserService/GetSecurityReport"
+	UserService_GetLoginPolicy_FullMethodName      = "/authd.UserService/GetLoginPolicy"
+	UserService_DisableUser_FullMethodName         = "/authd.UserService/DisableUser"
+	UserService_ArchiveUser_FullMethodName         = "/authd.UserService/ArchiveUser"
+	UserService_RestoreUser_FullMethodName         = "/authd.UserService/RestoreUser"
+	UserService_GetUserLifecycle_FullMethodName    = "/authd.UserService/GetUserLifecycle"
+	UserService_CleanupHomes_FullMethodName        = "/authd.UserService/CleanupHomes"
+	UserService_CleanupStaleUsers_FullMethodName   = "/authd.UserService/CleanupStaleUsers"
+	UserService_ListOrphanedHomes_FullMethodName   = "/authd.UserService/ListOrphanedHomes"
+	UserService_SetDefaultBroker_FullMethodName    = "/authd.UserService/SetDefaultBroker"
+	UserService_ListBrokers_FullMethodName         = "/authd.UserService/ListBrokers"
+	UserService_GetBrokerDetails_FullMethodName    = "/authd.UserService/GetBrokerDetails"
+	UserService_CheckBroker_FullMethodName         = "/authd.UserService/CheckBroker"
+	UserService_VerifyUser_FullMethodName          = "/authd.UserService/VerifyUser"
+	UserService_GetSessionToken_FullMethodName     = "/authd.UserService/GetSessionToken"
+	UserService_GetStatus_FullMethodName           = "/authd.UserService/GetStatus"
+	UserService_RollBackDatabase_FullMethodName    = "/authd.UserService/RollBackDatabase"
+	UserService_LinkIdentity_FullMethodName        = "/authd.UserService/LinkIdentity"
+	UserService_UnlinkIdentity_FullMethodName      = "/authd.UserService/UnlinkIdentity"
+	UserService_GetLinkedIdentities_FullMethodName = "/authd.UserService/GetLinkedIdentities"
+	UserService_GetUserStatus_FullMethodName       = "/authd.UserService/GetUserStatus"
+	UserService_ExportUsers_FullMethodName         = "/authd.UserService/ExportUsers"
+	UserService_ImportUsers_FullMethodName         = "/authd.UserService/ImportUsers"
+	UserService_GetAuthorizedKeys_FullMethodName   = "/authd.UserService/GetAuthorizedKeys"
+	UserService_EnrollTOTP_FullMethodName          = "/authd.UserService/EnrollTOTP"
+	UserService_DisableTOTP_FullMethodName         = "/authd.UserService/DisableTOTP"
+	UserService_EnrollMachineToken_FullMethodName  = "/authd.UserService/EnrollMachineToken"
+	UserService_RevokeMachineToken_FullMethodName  = "/authd.UserService/RevokeMachineToken"
+	UserService_GetGroupByName_FullMethodName      = "/authd.UserService/GetGroupByName"
+	UserService_GetGroupByID_FullMethodName        = "/authd.UserService/GetGroupByID"
+	UserService_ListGroups_FullMethodName          = "/authd.UserService/ListGroups"
+	UserService_GetGroupsForUser_FullMethodName    = "/authd.UserService/GetGroupsForUser"
 )
 
 // UserServiceClient is the client API for UserService service.
@@ -371,7 +478,7 @@ const (
 type UserServiceClient interface {
 	GetUserByName(ctx context.Context, in *GetUserByNameRequest, opts ...grpc.CallOption) (*User, error)
 	GetUserByID(ctx context.Context, in *GetUserByIDRequest, opts ...grpc.CallOption) (*User, error)
-	ListUsers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Users, error)
+	ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*Users, error)
 	LockUser(ctx context.Context, in *LockUserRequest, opts ...grpc.CallOption) (*Empty, error)
 	UnlockUser(ctx context.Context, in *UnlockUserRequest, opts ...grpc.CallOption) (*Empty, error)
 	SetUserID(ctx context.Context, in *SetUserIDRequest, opts ...grpc.CallOption) (*SetUserIDResponse, error)
@@ -380,9 +487,39 @@ type UserServiceClient interface {
 	SetHomeDir(ctx context.Context, in *SetHomeDirRequest, opts ...grpc.CallOption) (*SetHomeDirResponse, error)
 	DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*DeleteUserResponse, error)
 	DeleteGroup(ctx context.Context, in *DeleteGroupRequest, opts ...grpc.CallOption) (*Empty, error)
+	GetLoginHistory(ctx context.Context, in *GetLoginHistoryRequest, opts ...grpc.CallOption) (*LoginHistory, error)
+	GetSecurityReport(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*SecurityReport, error)
+	GetLoginPolicy(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*LoginPolicy, error)
+	DisableUser(ctx context.Context, in *DisableUserRequest, opts ...grpc.CallOption) (*Empty, error)
+	ArchiveUser(ctx context.Context, in *ArchiveUserRequest, opts ...grpc.CallOption) (*Empty, error)
+	RestoreUser(ctx context.Context, in *RestoreUserRequest, opts ...grpc.CallOption) (*Empty, error)
+	GetUserLifecycle(ctx context.Context, in *GetUserLifecycleRequest, opts ...grpc.CallOption) (*UserLifecycle, error)
+	CleanupHomes(ctx context.Context, in *CleanupHomesRequest, opts ...grpc.CallOption) (*HomeCleanupReport, error)
+	CleanupStaleUsers(ctx context.Context, in *CleanupStaleUsersRequest, opts ...grpc.CallOption) (*StaleUserReport, error)
+	ListOrphanedHomes(ctx context.Context, in *ListOrphanedHomesRequest, opts ...grpc.CallOption) (*OrphanedHomes, error)
+	SetDefaultBroker(ctx context.Context, in *SetDefaultBrokerRequest, opts ...grpc.CallOption) (*Empty, error)
+	ListBrokers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Brokers, error)
+	GetBrokerDetails(ctx context.Context, in *GetBrokerDetailsRequest, opts ...grpc.CallOption) (*BrokerDetails, error)
+	CheckBroker(ctx context.Context, in *CheckBrokerRequest, opts ...grpc.CallOption) (*BrokerCheck, error)
+	VerifyUser(ctx context.Context, in *VerifyUserRequest, opts ...grpc.CallOption) (*UserVerification, error)
+	GetSessionToken(ctx context.Context, in *GetSessionTokenRequest, opts ...grpc.CallOption) (*SessionToken, error)
+	GetStatus(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*DaemonStatus, error)
+	RollBackDatabase(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*DatabaseRollback, error)
+	LinkIdentity(ctx context.Context, in *LinkIdentityRequest, opts ...grpc.CallOption) (*Empty, error)
+	UnlinkIdentity(ctx context.Context, in *UnlinkIdentityRequest, opts ...grpc.CallOption) (*Empty, error)
+	GetLinkedIdentities(ctx context.Context, in *GetLinkedIdentitiesRequest, opts ...grpc.CallOption) (*LinkedIdentities, error)
+	GetUserStatus(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*UserStatus, error)
+	ExportUsers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*UserRecords, error)
+	ImportUsers(ctx context.Context, in *UserRecords, opts ...grpc.CallOption) (*Empty, error)
+	GetAuthorizedKeys(ctx context.Context, in *GetAuthorizedKeysRequest, opts ...grpc.CallOption) (*AuthorizedKeys, error)
+	EnrollTOTP(ctx context.Context, in *EnrollTOTPRequest, opts ...grpc.CallOption) (*TOTPEnrollment, error)
+	DisableTOTP(ctx context.Context, in *DisableTOTPRequest, opts ...grpc.CallOption) (*Empty, error)
+	EnrollMachineToken(ctx context.Context, in *EnrollMachineTokenRequest, opts ...grpc.CallOption) (*MachineToken, error)
+	RevokeMachineToken(ctx context.Context, in *RevokeMachineTokenRequest, opts ...grpc.CallOption) (*Empty, error)
 	GetGroupByName(ctx context.Context, in *GetGroupByNameRequest, opts ...grpc.CallOption) (*Group, error)
 	GetGroupByID(ctx context.Context, in *GetGroupByIDRequest, opts ...grpc.CallOption) (*Group, error)
-	ListGroups(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Groups, error)
+	ListGroups(ctx context.Context, in *ListGroupsRequest, opts ...grpc.CallOption) (*Groups, error)
+	GetGroupsForUser(ctx context.Context, in *GetGroupsForUserRequest, opts ...grpc.CallOption) (*Groups, error)
 }
 
 type userServiceClient struct {
@@ -413,7 +550,7 @@ func (c *userServiceClient) GetUserByID(ctx context.Context, in *GetUserByIDRequ
 	return out, nil
 }
 
-func (c *userServiceClient) ListUsers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Users, error) {
+func (c *userServiceClient) ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*Users, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(Users)
 	err := c.cc.Invoke(ctx, UserService_ListUsers_FullMethodName, in, out, cOpts...)
@@ -503,321 +640,1263 @@ func (c *userServiceClient) DeleteGroup(ctx context.Context, in *DeleteGroupRequ
 	return out, nil
 }
 
-func (c *userServiceClient) GetGroupByName(ctx context.Context, in *GetGroupByNameRequest, opts ...grpc.CallOption) (*Group, error) {
+func (c *userServiceClient) GetLoginHistory(ctx context.Context, in *GetLoginHistoryRequest, opts ...grpc.CallOption) (*LoginHistory, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(Group)
-	err := c.cc.Invoke(ctx, UserService_GetGroupByName_FullMethodName, in, out, cOpts...)
+	out := new(LoginHistory)
+	err := c.cc.Invoke(ctx, UserService_GetLoginHistory_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *userServiceClient) GetGroupByID(ctx context.Context, in *GetGroupByIDRequest, opts ...grpc.CallOption) (*Group, error) {
+func (c *userServiceClient) GetSecurityReport(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*SecurityReport, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(Group)
-	err := c.cc.Invoke(ctx, UserService_GetGroupByID_FullMethodName, in, out, cOpts...)
+	out := new(SecurityReport)
+	err := c.cc.Invoke(ctx, UserService_GetSecurityReport_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *userServiceClient) ListGroups(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Groups, error) {
+func (c *userServiceClient) GetLoginPolicy(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*LoginPolicy, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(Groups)
-	err := c.cc.Invoke(ctx, UserService_ListGroups_FullMethodName, in, out, cOpts...)
+	out := new(LoginPolicy)
+	err := c.cc.Invoke(ctx, UserService_GetLoginPolicy_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-// UserServiceServer is the server API for UserService service.
-// All implementations must embed UnimplementedUserServiceServer
-// for forward compatibility.
-type UserServiceServer interface {
-	GetUserByName(context.Context, *GetUserByNameRequest) (*User, error)
-	GetUserByID(context.Context, *GetUserByIDRequest) (*User, error)
-	ListUsers(context.Context, *Empty) (*Users, error)
-	LockUser(context.Context, *LockUserRequest) (*Empty, error)
-	UnlockUser(context.Context, *UnlockUserRequest) (*Empty, error)
-	SetUserID(context.Context, *SetUserIDRequest) (*SetUserIDResponse, error)
-	SetGroupID(context.Context, *SetGroupIDRequest) (*SetGroupIDResponse, error)
-	SetShell(context.Context, *SetShellRequest) (*SetShellResponse, error)
-	SetHomeDir(context.Context, *SetHomeDirRequest) (*SetHomeDirResponse, error)
-	DeleteUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error)
-	DeleteGroup(context.Context, *DeleteGroupRequest) (*Empty, error)
-	GetGroupByName(context.Context, *GetGroupByNameRequest) (*Group, error)
-	GetGroupByID(context.Context, *GetGroupByIDRequest) (*Group, error)
-	ListGroups(context.Context, *Empty) (*Groups, error)
-	mustEmbedUnimplementedUserServiceServer()
+func (c *userServiceClient) DisableUser(ctx context.Context, in *DisableUserRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserService_DisableUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-// UnimplementedUserServiceServer must be embedded to have
-// forward compatible implementations.
-//
-// NOTE: this should be embedded by value instead of pointer to avoid a nil
-// pointer dereference when methods are called.
-type UnimplementedUserServiceServer struct{}
-
-func (UnimplementedUserServiceServer) GetUserByName(context.Context, *GetUserByNameRequest) (*User, error) {
-	return nil, status.Error(codes.Unimplemented, "method GetUserByName not implemented")
-}
-func (UnimplementedUserServiceServer) GetUserByID(context.Context, *GetUserByIDRequest) (*User, error) {
-	return nil, status.Error(codes.Unimplemented, "method GetUserByID not implemented")
-}
-func (UnimplementedUserServiceServer) ListUsers(context.Context, *Empty) (*Users, error) {
-	return nil, status.Error(codes.Unimplemented, "method ListUsers not implemented")
-}
-func (UnimplementedUserServiceServer) LockUser(context.Context, *LockUserRequest) (*Empty, error) {
-	return nil, status.Error(codes.Unimplemented, "method LockUser not implemented")
-}
-func (UnimplementedUserServiceServer) UnlockUser(context.Context, *UnlockUserRequest) (*Empty, error) {
-	return nil, status.Error(codes.Unimplemented, "method UnlockUser not implemented")
-}
-func (UnimplementedUserServiceServer) SetUserID(context.Context, *SetUserIDRequest) (*SetUserIDResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "method SetUserID not implemented")
-}
-func (UnimplementedUserServiceServer) SetGroupID(context.Context, *SetGroupIDRequest) (*SetGroupIDResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "method SetGroupID not implemented")
-}
-func (UnimplementedUserServiceServer) SetShell(context.Context, *SetShellRequest) (*SetShellResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "method SetShell not implemented")
-}
-func (UnimplementedUserServiceServer) SetHomeDir(context.Context, *SetHomeDirRequest) (*SetHomeDirResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "method SetHomeDir not implemented")
-}
-func (UnimplementedUserServiceServer) DeleteUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "method DeleteUser not implemented")
-}
-func (UnimplementedUserServiceServer) DeleteGroup(context.Context, *DeleteGroupRequest) (*Empty, error) {
-	return nil, status.Error(codes.Unimplemented, "method DeleteGroup not implemented")
-}
-func (UnimplementedUserServiceServer) GetGroupByName(context.Context, *GetGroupByNameRequest) (*Group, error) {
-	return nil, status.Error(codes.Unimplemented, "method GetGroupByName not implemented")
-}
-func (UnimplementedUserServiceServer) GetGroupByID(context.Context, *GetGroupByIDRequest) (*Group, error) {
-	return nil, status.Error(codes.Unimplemented, "method GetGroupByID not implemented")
-}
-func (UnimplementedUserServiceServer) ListGroups(context.Context, *Empty) (*Groups, error) {
-	return nil, status.Error(codes.Unimplemented, "method ListGroups not implemented")
+func (c *userServiceClient) ArchiveUser(ctx context.Context, in *ArchiveUserRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserService_ArchiveUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
-func (UnimplementedUserServiceServer) mustEmbedUnimplementedUserServiceServer() {}
-func (UnimplementedUserServiceServer) testEmbeddedByValue()                     {}
 
-// UnsafeUserServiceServer may be embedded to opt out of forward compatibility for this service.
-// Use of this interface is not recommended, as added methods to UserServiceServer will
-// result in compilation errors.
-type UnsafeUserServiceServer interface {
-	mustEmbedUnimplementedUserServiceServer()
+func (c *userServiceClient) RestoreUser(ctx context.Context, in *RestoreUserRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserService_RestoreUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func RegisterUserServiceServer(s grpc.ServiceRegistrar, srv UserServiceServer) {
-	// If the following call panics, it indicates UnimplementedUserServiceServer was
-	// embedded by pointer and is nil.  This will cause panics if an
-	// unimplemented method is ever invoked, so we test this at initialization
-	// time to prevent it from happening at runtime later due to I/O.
-	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
-		t.testEmbeddedByValue()
+func (c *userServiceClient) GetUserLifecycle(ctx context.Context, in *GetUserLifecycleRequest, opts ...grpc.CallOption) (*UserLifecycle, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserLifecycle)
+	err := c.cc.Invoke(ctx, UserService_GetUserLifecycle_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
 	}
-	s.RegisterService(&UserService_ServiceDesc, srv)
+	return out, nil
 }
 
-func _UserService_GetUserByName_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetUserByNameRequest)
-	if err := dec(in); err != nil {
+func (c *userServiceClient) CleanupHomes(ctx context.Context, in *CleanupHomesRequest, opts ...grpc.CallOption) (*HomeCleanupReport, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HomeCleanupReport)
+	err := c.cc.Invoke(ctx, UserService_CleanupHomes_FullMethodName, in, out, cOpts...)
+	if err != nil {
 		return nil, err
 	}
-	if interceptor == nil {
-		return srv.(UserServiceServer).GetUserByName(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: UserService_GetUserByName_FullMethodName,
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(UserServiceServer).GetUserByName(ctx, req.(*GetUserByNameRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+	return out, nil
 }
 
-func _UserService_GetUserByID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetUserByIDRequest)
-	if err := dec(in); err != nil {
+func (c *userServiceClient) CleanupStaleUsers(ctx context.Context, in *CleanupStaleUsersRequest, opts ...grpc.CallOption) (*StaleUserReport, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StaleUserReport)
+	err := c.cc.Invoke(ctx, UserService_CleanupStaleUsers_FullMethodName, in, out, cOpts...)
+	if err != nil {
 		return nil, err
 	}
-	if interceptor == nil {
-		return srv.(UserServiceServer).GetUserByID(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: UserService_GetUserByID_FullMethodName,
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(UserServiceServer).GetUserByID(ctx, req.(*GetUserByIDRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+	return out, nil
 }
 
-func _UserService_ListUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Empty)
-	if err := dec(in); err != nil {
+func (c *userServiceClient) ListOrphanedHomes(ctx context.Context, in *ListOrphanedHomesRequest, opts ...grpc.CallOption) (*OrphanedHomes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OrphanedHomes)
+	err := c.cc.Invoke(ctx, UserService_ListOrphanedHomes_FullMethodName, in, out, cOpts...)
+	if err != nil {
 		return nil, err
 	}
-	if interceptor == nil {
-		return srv.(UserServiceServer).ListUsers(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: UserService_ListUsers_FullMethodName,
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(UserServiceServer).ListUsers(ctx, req.(*Empty))
-	}
-	return interceptor(ctx, in, info, handler)
+	return out, nil
 }
 
-func _UserService_LockUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(LockUserRequest)
-	if err := dec(in); err != nil {
+func (c *userServiceClient) SetDefaultBroker(ctx context.Context, in *SetDefaultBrokerRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserService_SetDefaultBroker_FullMethodName, in, out, cOpts...)
+	if err != nil {
 		return nil, err
 	}
-	if interceptor == nil {
-		return srv.(UserServiceServer).LockUser(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: UserService_LockUser_FullMethodName,
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(UserServiceServer).LockUser(ctx, req.(*LockUserRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+	return out, nil
 }
 
-func _UserService_UnlockUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(UnlockUserRequest)
-	if err := dec(in); err != nil {
+func (c *userServiceClient) ListBrokers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Brokers, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Brokers)
+	err := c.cc.Invoke(ctx, UserService_ListBrokers_FullMethodName, in, out, cOpts...)
+	if err != nil {
 		return nil, err
 	}
-	if interceptor == nil {
-		return srv.(UserServiceServer).UnlockUser(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: UserService_UnlockUser_FullMethodName,
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(UserServiceServer).UnlockUser(ctx, req.(*UnlockUserRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+	return out, nil
 }
 
-func _UserService_SetUserID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(SetUserIDRequest)
-	if err := dec(in); err != nil {
+func (c *userServiceClient) GetBrokerDetails(ctx context.Context, in *GetBrokerDetailsRequest, opts ...grpc.CallOption) (*BrokerDetails, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BrokerDetails)
+	err := c.cc.Invoke(ctx, UserService_GetBrokerDetails_FullMethodName, in, out, cOpts...)
+	if err != nil {
 		return nil, err
 	}
-	if interceptor == nil {
-		return srv.(UserServiceServer).SetUserID(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: UserService_SetUserID_FullMethodName,
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(UserServiceServer).SetUserID(ctx, req.(*SetUserIDRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+	return out, nil
 }
 
-func _UserService_SetGroupID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(SetGroupIDRequest)
-	if err := dec(in); err != nil {
+func (c *userServiceClient) CheckBroker(ctx context.Context, in *CheckBrokerRequest, opts ...grpc.CallOption) (*BrokerCheck, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BrokerCheck)
+	err := c.cc.Invoke(ctx, UserService_CheckBroker_FullMethodName, in, out, cOpts...)
+	if err != nil {
 		return nil, err
 	}
-	if interceptor == nil {
-		return srv.(UserServiceServer).SetGroupID(ctx, in)
-	}
+	return out, nil
+}
+
+func (c *userServiceClient) VerifyUser(ctx context.Context, in *VerifyUserRequest, opts ...grpc.CallOption) (*UserVerification, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserVerification)
+	err := c.cc.Invoke(ctx, UserService_VerifyUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) GetSessionToken(ctx context.Context, in *GetSessionTokenRequest, opts ...grpc.CallOption) (*SessionToken, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SessionToken)
+	err := c.cc.Invoke(ctx, UserService_GetSessionToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) GetStatus(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*DaemonStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DaemonStatus)
+	err := c.cc.Invoke(ctx, UserService_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) RollBackDatabase(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*DatabaseRollback, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DatabaseRollback)
+	err := c.cc.Invoke(ctx, UserService_RollBackDatabase_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) LinkIdentity(ctx context.Context, in *LinkIdentityRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserService_LinkIdentity_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) UnlinkIdentity(ctx context.Context, in *UnlinkIdentityRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserService_UnlinkIdentity_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) GetLinkedIdentities(ctx context.Context, in *GetLinkedIdentitiesRequest, opts ...grpc.CallOption) (*LinkedIdentities, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LinkedIdentities)
+	err := c.cc.Invoke(ctx, UserService_GetLinkedIdentities_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) GetUserStatus(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*UserStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserStatus)
+	err := c.cc.Invoke(ctx, UserService_GetUserStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) ExportUsers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*UserRecords, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserRecords)
+	err := c.cc.Invoke(ctx, UserService_ExportUsers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) ImportUsers(ctx context.Context, in *UserRecords, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserService_ImportUsers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) GetAuthorizedKeys(ctx context.Context, in *GetAuthorizedKeysRequest, opts ...grpc.CallOption) (*AuthorizedKeys, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AuthorizedKeys)
+	err := c.cc.Invoke(ctx, UserService_GetAuthorizedKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) EnrollTOTP(ctx context.Context, in *EnrollTOTPRequest, opts ...grpc.CallOption) (*TOTPEnrollment, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TOTPEnrollment)
+	err := c.cc.Invoke(ctx, UserService_EnrollTOTP_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) DisableTOTP(ctx context.Context, in *DisableTOTPRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserService_DisableTOTP_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) EnrollMachineToken(ctx context.Context, in *EnrollMachineTokenRequest, opts ...grpc.CallOption) (*MachineToken, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MachineToken)
+	err := c.cc.Invoke(ctx, UserService_EnrollMachineToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) RevokeMachineToken(ctx context.Context, in *RevokeMachineTokenRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserService_RevokeMachineToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) GetGroupByName(ctx context.Context, in *GetGroupByNameRequest, opts ...grpc.CallOption) (*Group, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Group)
+	err := c.cc.Invoke(ctx, UserService_GetGroupByName_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) GetGroupByID(ctx context.Context, in *GetGroupByIDRequest, opts ...grpc.CallOption) (*Group, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Group)
+	err := c.cc.Invoke(ctx, UserService_GetGroupByID_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) ListGroups(ctx context.Context, in *ListGroupsRequest, opts ...grpc.CallOption) (*Groups, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Groups)
+	err := c.cc.Invoke(ctx, UserService_ListGroups_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) GetGroupsForUser(ctx context.Context, in *GetGroupsForUserRequest, opts ...grpc.CallOption) (*Groups, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Groups)
+	err := c.cc.Invoke(ctx, UserService_GetGroupsForUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UserServiceServer is the server API for UserService service.
+// All implementations must embed UnimplementedUserServiceServer
+// for forward compatibility.
+type UserServiceServer interface {
+	GetUserByName(context.Context, *GetUserByNameRequest) (*User, error)
+	GetUserByID(context.Context, *GetUserByIDRequest) (*User, error)
+	ListUsers(context.Context, *ListUsersRequest) (*Users, error)
+	LockUser(context.Context, *LockUserRequest) (*Empty, error)
+	UnlockUser(context.Context, *UnlockUserRequest) (*Empty, error)
+	SetUserID(context.Context, *SetUserIDRequest) (*SetUserIDResponse, error)
+	SetGroupID(context.Context, *SetGroupIDRequest) (*SetGroupIDResponse, error)
+	SetShell(context.Context, *SetShellRequest) (*SetShellResponse, error)
+	SetHomeDir(context.Context, *SetHomeDirRequest) (*SetHomeDirResponse, error)
+	DeleteUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error)
+	DeleteGroup(context.Context, *DeleteGroupRequest) (*Empty, error)
+	GetLoginHistory(context.Context, *GetLoginHistoryRequest) (*LoginHistory, error)
+	GetSecurityReport(context.Context, *Empty) (*SecurityReport, error)
+	GetLoginPolicy(context.Context, *Empty) (*LoginPolicy, error)
+	DisableUser(context.Context, *DisableUserRequest) (*Empty, error)
+	ArchiveUser(context.Context, *ArchiveUserRequest) (*Empty, error)
+	RestoreUser(context.Context, *RestoreUserRequest) (*Empty, error)
+	GetUserLifecycle(context.Context, *GetUserLifecycleRequest) (*UserLifecycle, error)
+	CleanupHomes(context.Context, *CleanupHomesRequest) (*HomeCleanupReport, error)
+	CleanupStaleUsers(context.Context, *CleanupStaleUsersRequest) (*StaleUserReport, error)
+	ListOrphanedHomes(context.Context, *ListOrphanedHomesRequest) (*OrphanedHomes, error)
+	SetDefaultBroker(context.Context, *SetDefaultBrokerRequest) (*Empty, error)
+	ListBrokers(context.Context, *Empty) (*Brokers, error)
+	GetBrokerDetails(context.Context, *GetBrokerDetailsRequest) (*BrokerDetails, error)
+	CheckBroker(context.Context, *CheckBrokerRequest) (*BrokerCheck, error)
+	VerifyUser(context.Context, *VerifyUserRequest) (*UserVerification, error)
+	GetSessionToken(context.Context, *GetSessionTokenRequest) (*SessionToken, error)
+	GetStatus(context.Context, *Empty) (*DaemonStatus, error)
+	RollBackDatabase(context.Context, *Empty) (*DatabaseRollback, error)
+	LinkIdentity(context.Context, *LinkIdentityRequest) (*Empty, error)
+	UnlinkIdentity(context.Context, *UnlinkIdentityRequest) (*Empty, error)
+	GetLinkedIdentities(context.Context, *GetLinkedIdentitiesRequest) (*LinkedIdentities, error)
+	GetUserStatus(context.Context, *Empty) (*UserStatus, error)
+	ExportUsers(context.Context, *Empty) (*UserRecords, error)
+	ImportUsers(context.Context, *UserRecords) (*Empty, error)
+	GetAuthorizedKeys(context.Context, *GetAuthorizedKeysRequest) (*AuthorizedKeys, error)
+	EnrollTOTP(context.Context, *EnrollTOTPRequest) (*TOTPEnrollment, error)
+	DisableTOTP(context.Context, *DisableTOTPRequest) (*Empty, error)
+	EnrollMachineToken(context.Context, *EnrollMachineTokenRequest) (*MachineToken, error)
+	RevokeMachineToken(context.Context, *RevokeMachineTokenRequest) (*Empty, error)
+	GetGroupByName(context.Context, *GetGroupByNameRequest) (*Group, error)
+	GetGroupByID(context.Context, *GetGroupByIDRequest) (*Group, error)
+	ListGroups(context.Context, *ListGroupsRequest) (*Groups, error)
+	GetGroupsForUser(context.Context, *GetGroupsForUserRequest) (*Groups, error)
+	mustEmbedUnimplementedUserServiceServer()
+}
+
+// UnimplementedUserServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedUserServiceServer struct{}
+
+func (UnimplementedUserServiceServer) GetUserByName(context.Context, *GetUserByNameRequest) (*User, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetUserByName not implemented")
+}
+func (UnimplementedUserServiceServer) GetUserByID(context.Context, *GetUserByIDRequest) (*User, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetUserByID not implemented")
+}
+func (UnimplementedUserServiceServer) ListUsers(context.Context, *ListUsersRequest) (*Users, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListUsers not implemented")
+}
+func (UnimplementedUserServiceServer) LockUser(context.Context, *LockUserRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method LockUser not implemented")
+}
+func (UnimplementedUserServiceServer) UnlockUser(context.Context, *UnlockUserRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method UnlockUser not implemented")
+}
+func (UnimplementedUserServiceServer) SetUserID(context.Context, *SetUserIDRequest) (*SetUserIDResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SetUserID not implemented")
+}
+func (UnimplementedUserServiceServer) SetGroupID(context.Context, *SetGroupIDRequest) (*SetGroupIDResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SetGroupID not implemented")
+}
+func (UnimplementedUserServiceServer) SetShell(context.Context, *SetShellRequest) (*SetShellResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SetShell not implemented")
+}
+func (UnimplementedUserServiceServer) SetHomeDir(context.Context, *SetHomeDirRequest) (*SetHomeDirResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SetHomeDir not implemented")
+}
+func (UnimplementedUserServiceServer) DeleteUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeleteUser not implemented")
+}
+func (UnimplementedUserServiceServer) DeleteGroup(context.Context, *DeleteGroupRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeleteGroup not implemented")
+}
+func (UnimplementedUserServiceServer) GetLoginHistory(context.Context, *GetLoginHistoryRequest) (*LoginHistory, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetLoginHistory not implemented")
+}
+func (UnimplementedUserServiceServer) GetSecurityReport(context.Context, *Empty) (*SecurityReport, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetSecurityReport not implemented")
+}
+func (UnimplementedUserServiceServer) GetLoginPolicy(context.Context, *Empty) (*LoginPolicy, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetLoginPolicy not implemented")
+}
+func (UnimplementedUserServiceServer) DisableUser(context.Context, *DisableUserRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method DisableUser not implemented")
+}
+func (UnimplementedUserServiceServer) ArchiveUser(context.Context, *ArchiveUserRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method ArchiveUser not implemented")
+}
+func (UnimplementedUserServiceServer) RestoreUser(context.Context, *RestoreUserRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method RestoreUser not implemented")
+}
+func (UnimplementedUserServiceServer) GetUserLifecycle(context.Context, *GetUserLifecycleRequest) (*UserLifecycle, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetUserLifecycle not implemented")
+}
+func (UnimplementedUserServiceServer) CleanupHomes(context.Context, *CleanupHomesRequest) (*HomeCleanupReport, error) {
+	return nil, status.Error(codes.Unimplemented, "method CleanupHomes not implemented")
+}
+func (UnimplementedUserServiceServer) CleanupStaleUsers(context.Context, *CleanupStaleUsersRequest) (*StaleUserReport, error) {
+	return nil, status.Error(codes.Unimplemented, "method CleanupStaleUsers not implemented")
+}
+func (UnimplementedUserServiceServer) ListOrphanedHomes(context.Context, *ListOrphanedHomesRequest) (*OrphanedHomes, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListOrphanedHomes not implemented")
+}
+func (UnimplementedUserServiceServer) SetDefaultBroker(context.Context, *SetDefaultBrokerRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method SetDefaultBroker not implemented")
+}
+func (UnimplementedUserServiceServer) ListBrokers(context.Context, *Empty) (*Brokers, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListBrokers not implemented")
+}
+func (UnimplementedUserServiceServer) GetBrokerDetails(context.Context, *GetBrokerDetailsRequest) (*BrokerDetails, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetBrokerDetails not implemented")
+}
+func (UnimplementedUserServiceServer) CheckBroker(context.Context, *CheckBrokerRequest) (*BrokerCheck, error) {
+	return nil, status.Error(codes.Unimplemented, "method CheckBroker not implemented")
+}
+func (UnimplementedUserServiceServer) VerifyUser(context.Context, *VerifyUserRequest) (*UserVerification, error) {
+	return nil, status.Error(codes.Unimplemented, "method VerifyUser not implemented")
+}
+func (UnimplementedUserServiceServer) GetSessionToken(context.Context, *GetSessionTokenRequest) (*SessionToken, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetSessionToken not implemented")
+}
+func (UnimplementedUserServiceServer) GetStatus(context.Context, *Empty) (*DaemonStatus, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedUserServiceServer) RollBackDatabase(context.Context, *Empty) (*DatabaseRollback, error) {
+	return nil, status.Error(codes.Unimplemented, "method RollBackDatabase not implemented")
+}
+func (UnimplementedUserServiceServer) LinkIdentity(context.Context, *LinkIdentityRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method LinkIdentity not implemented")
+}
+func (UnimplementedUserServiceServer) UnlinkIdentity(context.Context, *UnlinkIdentityRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method UnlinkIdentity not implemented")
+}
+func (UnimplementedUserServiceServer) GetLinkedIdentities(context.Context, *GetLinkedIdentitiesRequest) (*LinkedIdentities, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetLinkedIdentities not implemented")
+}
+func (UnimplementedUserServiceServer) GetUserStatus(context.Context, *Empty) (*UserStatus, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetUserStatus not implemented")
+}
+func (UnimplementedUserServiceServer) ExportUsers(context.Context, *Empty) (*UserRecords, error) {
+	return nil, status.Error(codes.Unimplemented, "method ExportUsers not implemented")
+}
+func (UnimplementedUserServiceServer) ImportUsers(context.Context, *UserRecords) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method ImportUsers not implemented")
+}
+func (UnimplementedUserServiceServer) GetAuthorizedKeys(context.Context, *GetAuthorizedKeysRequest) (*AuthorizedKeys, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetAuthorizedKeys not implemented")
+}
+func (UnimplementedUserServiceServer) EnrollTOTP(context.Context, *EnrollTOTPRequest) (*TOTPEnrollment, error) {
+	return nil, status.Error(codes.Unimplemented, "method EnrollTOTP not implemented")
+}
+func (UnimplementedUserServiceServer) DisableTOTP(context.Context, *DisableTOTPRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method DisableTOTP not implemented")
+}
+func (UnimplementedUserServiceServer) EnrollMachineToken(context.Context, *EnrollMachineTokenRequest) (*MachineToken, error) {
+	return nil, status.Error(codes.Unimplemented, "method EnrollMachineToken not implemented")
+}
+func (UnimplementedUserServiceServer) RevokeMachineToken(context.Context, *RevokeMachineTokenRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method RevokeMachineToken not implemented")
+}
+func (UnimplementedUserServiceServer) GetGroupByName(context.Context, *GetGroupByNameRequest) (*Group, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetGroupByName not implemented")
+}
+func (UnimplementedUserServiceServer) GetGroupByID(context.Context, *GetGroupByIDRequest) (*Group, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetGroupByID not implemented")
+}
+func (UnimplementedUserServiceServer) ListGroups(context.Context, *ListGroupsRequest) (*Groups, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListGroups not implemented")
+}
+func (UnimplementedUserServiceServer) GetGroupsForUser(context.Context, *GetGroupsForUserRequest) (*Groups, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetGroupsForUser not implemented")
+}
+func (UnimplementedUserServiceServer) mustEmbedUnimplementedUserServiceServer() {}
+func (UnimplementedUserServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeUserServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UserServiceServer will
+// result in compilation errors.
+type UnsafeUserServiceServer interface {
+	mustEmbedUnimplementedUserServiceServer()
+}
+
+func RegisterUserServiceServer(s grpc.ServiceRegistrar, srv UserServiceServer) {
+	// If the following call panics, it indicates UnimplementedUserServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&UserService_ServiceDesc, srv)
+}
+
+func _UserService_GetUserByName_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserByNameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetUserByName(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetUserByName_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetUserByName(ctx, req.(*GetUserByNameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_GetUserByID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserByIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetUserByID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetUserByID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetUserByID(ctx, req.(*GetUserByIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_ListUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).ListUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_ListUsers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).ListUsers(ctx, req.(*ListUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_LockUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LockUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).LockUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_LockUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).LockUser(ctx, req.(*LockUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_UnlockUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnlockUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).UnlockUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_UnlockUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).UnlockUser(ctx, req.(*UnlockUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_SetUserID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetUserIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).SetUserID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_SetUserID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).SetUserID(ctx, req.(*SetUserIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_SetGroupID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetGroupIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).SetGroupID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_SetGroupID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).SetGroupID(ctx, req.(*SetGroupIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_SetShell_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetShellRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).SetShell(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_SetShell_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).SetShell(ctx, req.(*SetShellRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_SetHomeDir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetHomeDirRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).SetHomeDir(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_SetHomeDir_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).SetHomeDir(ctx, req.(*SetHomeDirRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_DeleteUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).DeleteUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_DeleteUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).DeleteUser(ctx, req.(*DeleteUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_DeleteGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).DeleteGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_DeleteGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).DeleteGroup(ctx, req.(*DeleteGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_GetLoginHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLoginHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetLoginHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetLoginHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetLoginHistory(ctx, req.(*GetLoginHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_GetSecurityReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetSecurityReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetSecurityReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetSecurityReport(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_GetLoginPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetLoginPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetLoginPolicy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetLoginPolicy(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_DisableUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisableUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).DisableUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_DisableUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).DisableUser(ctx, req.(*DisableUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_ArchiveUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ArchiveUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).ArchiveUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_ArchiveUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).ArchiveUser(ctx, req.(*ArchiveUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_RestoreUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).RestoreUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_RestoreUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).RestoreUser(ctx, req.(*RestoreUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_GetUserLifecycle_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserLifecycleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetUserLifecycle(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetUserLifecycle_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetUserLifecycle(ctx, req.(*GetUserLifecycleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_CleanupHomes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CleanupHomesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).CleanupHomes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_CleanupHomes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).CleanupHomes(ctx, req.(*CleanupHomesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_CleanupStaleUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CleanupStaleUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).CleanupStaleUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_CleanupStaleUsers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).CleanupStaleUsers(ctx, req.(*CleanupStaleUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_ListOrphanedHomes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListOrphanedHomesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).ListOrphanedHomes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_ListOrphanedHomes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).ListOrphanedHomes(ctx, req.(*ListOrphanedHomesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_SetDefaultBroker_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetDefaultBrokerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).SetDefaultBroker(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_SetDefaultBroker_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).SetDefaultBroker(ctx, req.(*SetDefaultBrokerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_ListBrokers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).ListBrokers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_ListBrokers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).ListBrokers(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_GetBrokerDetails_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBrokerDetailsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetBrokerDetails(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetBrokerDetails_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetBrokerDetails(ctx, req.(*GetBrokerDetailsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_CheckBroker_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckBrokerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).CheckBroker(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_CheckBroker_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).CheckBroker(ctx, req.(*CheckBrokerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_VerifyUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).VerifyUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_VerifyUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).VerifyUser(ctx, req.(*VerifyUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_GetSessionToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSessionTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetSessionToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetSessionToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetSessionToken(ctx, req.(*GetSessionTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetStatus(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_RollBackDatabase_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).RollBackDatabase(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_RollBackDatabase_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).RollBackDatabase(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_LinkIdentity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LinkIdentityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).LinkIdentity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_LinkIdentity_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).LinkIdentity(ctx, req.(*LinkIdentityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_UnlinkIdentity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnlinkIdentityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).UnlinkIdentity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_UnlinkIdentity_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).UnlinkIdentity(ctx, req.(*UnlinkIdentityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_GetLinkedIdentities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLinkedIdentitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetLinkedIdentities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetLinkedIdentities_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetLinkedIdentities(ctx, req.(*GetLinkedIdentitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_GetUserStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetUserStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetUserStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetUserStatus(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_ExportUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).ExportUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_ExportUsers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).ExportUsers(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_ImportUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRecords)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).ImportUsers(ctx, in)
+	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: UserService_SetGroupID_FullMethodName,
+		FullMethod: UserService_ImportUsers_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(UserServiceServer).SetGroupID(ctx, req.(*SetGroupIDRequest))
+		return srv.(UserServiceServer).ImportUsers(ctx, req.(*UserRecords))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _UserService_SetShell_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(SetShellRequest)
+func _UserService_GetAuthorizedKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAuthorizedKeysRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(UserServiceServer).SetShell(ctx, in)
+		return srv.(UserServiceServer).GetAuthorizedKeys(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: UserService_SetShell_FullMethodName,
+		FullMethod: UserService_GetAuthorizedKeys_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(UserServiceServer).SetShell(ctx, req.(*SetShellRequest))
+		return srv.(UserServiceServer).GetAuthorizedKeys(ctx, req.(*GetAuthorizedKeysRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _UserService_SetHomeDir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(SetHomeDirRequest)
+func _UserService_EnrollTOTP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnrollTOTPRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(UserServiceServer).SetHomeDir(ctx, in)
+		return srv.(UserServiceServer).EnrollTOTP(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: UserService_SetHomeDir_FullMethodName,
+		FullMethod: UserService_EnrollTOTP_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(UserServiceServer).SetHomeDir(ctx, req.(*SetHomeDirRequest))
+		return srv.(UserServiceServer).EnrollTOTP(ctx, req.(*EnrollTOTPRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _UserService_DeleteUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteUserRequest)
+func _UserService_DisableTOTP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisableTOTPRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(UserServiceServer).DeleteUser(ctx, in)
+		return srv.(UserServiceServer).DisableTOTP(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: UserService_DeleteUser_FullMethodName,
+		FullMethod: UserService_DisableTOTP_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(UserServiceServer).DeleteUser(ctx, req.(*DeleteUserRequest))
+		return srv.(UserServiceServer).DisableTOTP(ctx, req.(*DisableTOTPRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _UserService_DeleteGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteGroupRequest)
+func _UserService_EnrollMachineToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnrollMachineTokenRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(UserServiceServer).DeleteGroup(ctx, in)
+		return srv.(UserServiceServer).EnrollMachineToken(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: UserService_DeleteGroup_FullMethodName,
+		FullMethod: UserService_EnrollMachineToken_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(UserServiceServer).DeleteGroup(ctx, req.(*DeleteGroupRequest))
+		return srv.(UserServiceServer).EnrollMachineToken(ctx, req.(*EnrollMachineTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_RevokeMachineToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeMachineTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).RevokeMachineToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_RevokeMachineToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).RevokeMachineToken(ctx, req.(*RevokeMachineTokenRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -859,7 +1938,7 @@ func _UserService_GetGroupByID_Handler(srv interface{}, ctx context.Context, dec
 }
 
 func _UserService_ListGroups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Empty)
+	in := new(ListGroupsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -871,7 +1950,25 @@ func _UserService_ListGroups_Handler(srv interface{}, ctx context.Context, dec f
 		FullMethod: UserService_ListGroups_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(UserServiceServer).ListGroups(ctx, req.(*Empty))
+		return srv.(UserServiceServer).ListGroups(ctx, req.(*ListGroupsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_GetGroupsForUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGroupsForUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetGroupsForUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetGroupsForUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetGroupsForUser(ctx, req.(*GetGroupsForUserRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -927,6 +2024,122 @@ var UserService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteGroup",
 			Handler:    _UserService_DeleteGroup_Handler,
 		},
+		{
+			MethodName: "GetLoginHistory",
+			Handler:    _UserService_GetLoginHistory_Handler,
+		},
+		{
+			MethodName: "GetSecurityReport",
+			Handler:    _UserService_GetSecurityReport_Handler,
+		},
+		{
+			MethodName: "GetLoginPolicy",
+			Handler:    _UserService_GetLoginPolicy_Handler,
+		},
+		{
+			MethodName: "DisableUser",
+			Handler:    _UserService_DisableUser_Handler,
+		},
+		{
+			MethodName: "ArchiveUser",
+			Handler:    _UserService_ArchiveUser_Handler,
+		},
+		{
+			MethodName: "RestoreUser",
+			Handler:    _UserService_RestoreUser_Handler,
+		},
+		{
+			MethodName: "GetUserLifecycle",
+			Handler:    _UserService_GetUserLifecycle_Handler,
+		},
+		{
+			MethodName: "CleanupHomes",
+			Handler:    _UserService_CleanupHomes_Handler,
+		},
+		{
+			MethodName: "CleanupStaleUsers",
+			Handler:    _UserService_CleanupStaleUsers_Handler,
+		},
+		{
+			MethodName: "ListOrphanedHomes",
+			Handler:    _UserService_ListOrphanedHomes_Handler,
+		},
+		{
+			MethodName: "SetDefaultBroker",
+			Handler:    _UserService_SetDefaultBroker_Handler,
+		},
+		{
+			MethodName: "ListBrokers",
+			Handler:    _UserService_ListBrokers_Handler,
+		},
+		{
+			MethodName: "GetBrokerDetails",
+			Handler:    _UserService_GetBrokerDetails_Handler,
+		},
+		{
+			MethodName: "CheckBroker",
+			Handler:    _UserService_CheckBroker_Handler,
+		},
+		{
+			MethodName: "VerifyUser",
+			Handler:    _UserService_VerifyUser_Handler,
+		},
+		{
+			MethodName: "GetSessionToken",
+			Handler:    _UserService_GetSessionToken_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _UserService_GetStatus_Handler,
+		},
+		{
+			MethodName: "RollBackDatabase",
+			Handler:    _UserService_RollBackDatabase_Handler,
+		},
+		{
+			MethodName: "LinkIdentity",
+			Handler:    _UserService_LinkIdentity_Handler,
+		},
+		{
+			MethodName: "UnlinkIdentity",
+			Handler:    _UserService_UnlinkIdentity_Handler,
+		},
+		{
+			MethodName: "GetLinkedIdentities",
+			Handler:    _UserService_GetLinkedIdentities_Handler,
+		},
+		{
+			MethodName: "GetUserStatus",
+			Handler:    _UserService_GetUserStatus_Handler,
+		},
+		{
+			MethodName: "ExportUsers",
+			Handler:    _UserService_ExportUsers_Handler,
+		},
+		{
+			MethodName: "ImportUsers",
+			Handler:    _UserService_ImportUsers_Handler,
+		},
+		{
+			MethodName: "GetAuthorizedKeys",
+			Handler:    _UserService_GetAuthorizedKeys_Handler,
+		},
+		{
+			MethodName: "EnrollTOTP",
+			Handler:    _UserService_EnrollTOTP_Handler,
+		},
+		{
+			MethodName: "DisableTOTP",
+			Handler:    _UserService_DisableTOTP_Handler,
+		},
+		{
+			MethodName: "EnrollMachineToken",
+			Handler:    _UserService_EnrollMachineToken_Handler,
+		},
+		{
+			MethodName: "RevokeMachineToken",
+			Handler:    _UserService_RevokeMachineToken_Handler,
+		},
 		{
 			MethodName: "GetGroupByName",
 			Handler:    _UserService_GetGroupByName_Handler,
@@ -939,7 +2152,270 @@ var UserService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListGroups",
 			Handler:    _UserService_ListGroups_Handler,
 		},
+		{
+			MethodName: "GetGroupsForUser",
+			Handler:    _UserService_GetGroupsForUser_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "authd.proto",
+}
+
+const (
+	IDAllocation_AllocateUserID_FullMethodName  = "/authd.IDAllocation/AllocateUserID"
+	IDAllocation_AllocateGroupID_FullMethodName = "/authd.IDAllocation/AllocateGroupID"
+)
+
+// IDAllocationClient is the client API for IDAllocation service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// IDAllocation is served over TCP by the ID allocation authority, so that roaming users get the same UID and GIDs on
+// all the machines.
+type IDAllocationClient interface {
+	AllocateUserID(ctx context.Context, in *AllocateUserIDRequest, opts ...grpc.CallOption) (*AllocatedID, error)
+	AllocateGroupID(ctx context.Context, in *AllocateGroupIDRequest, opts ...grpc.CallOption) (*AllocatedID, error)
+}
+
+type iDAllocationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewIDAllocationClient(cc grpc.ClientConnInterface) IDAllocationClient {
+	return &iDAllocationClient{cc}
+}
+
+func (c *iDAllocationClient) AllocateUserID(ctx context.Context, in *AllocateUserIDRequest, opts ...grpc.CallOption) (*AllocatedID, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AllocatedID)
+	err := c.cc.Invoke(ctx, IDAllocation_AllocateUserID_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iDAllocationClient) AllocateGroupID(ctx context.Context, in *AllocateGroupIDRequest, opts ...grpc.CallOption) (*AllocatedID, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AllocatedID)
+	err := c.cc.Invoke(ctx, IDAllocation_AllocateGroupID_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// IDAllocationServer is the server API for IDAllocation service.
+// All implementations must embed UnimplementedIDAllocationServer
+// for forward compatibility.
+//
+// IDAllocation is served over TCP by the ID allocation authority, so that roaming users get the same UID and GIDs on
+// all the machines.
+type IDAllocationServer interface {
+	AllocateUserID(context.Context, *AllocateUserIDRequest) (*AllocatedID, error)
+	AllocateGroupID(context.Context, *AllocateGroupIDRequest) (*AllocatedID, error)
+	mustEmbedUnimplementedIDAllocationServer()
+}
+
+// UnimplementedIDAllocationServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedIDAllocationServer struct{}
+
+func (UnimplementedIDAllocationServer) AllocateUserID(context.Context, *AllocateUserIDRequest) (*AllocatedID, error) {
+	return nil, status.Error(codes.Unimplemented, "method AllocateUserID not implemented")
+}
+func (UnimplementedIDAllocationServer) AllocateGroupID(context.Context, *AllocateGroupIDRequest) (*AllocatedID, error) {
+	return nil, status.Error(codes.Unimplemented, "method AllocateGroupID not implemented")
+}
+func (UnimplementedIDAllocationServer) mustEmbedUnimplementedIDAllocationServer() {}
+func (UnimplementedIDAllocationServer) testEmbeddedByValue()                      {}
+
+// UnsafeIDAllocationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to IDAllocationServer will
+// result in compilation errors.
+type UnsafeIDAllocationServer interface {
+	mustEmbedUnimplementedIDAllocationServer()
+}
+
+func RegisterIDAllocationServer(s grpc.ServiceRegistrar, srv IDAllocationServer) {
+	// If the following call panics, it indicates UnimplementedIDAllocationServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&IDAllocation_ServiceDesc, srv)
+}
+
+func _IDAllocation_AllocateUserID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AllocateUserIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IDAllocationServer).AllocateUserID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IDAllocation_AllocateUserID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IDAllocationServer).AllocateUserID(ctx, req.(*AllocateUserIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IDAllocation_AllocateGroupID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AllocateGroupIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IDAllocationServer).AllocateGroupID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IDAllocation_AllocateGroupID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IDAllocationServer).AllocateGroupID(ctx, req.(*AllocateGroupIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// IDAllocation_ServiceDesc is the grpc.ServiceDesc for IDAllocation service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var IDAllocation_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "authd.IDAllocation",
+	HandlerType: (*IDAllocationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AllocateUserID",
+			Handler:    _IDAllocation_AllocateUserID_Handler,
+		},
+		{
+			MethodName: "AllocateGroupID",
+			Handler:    _IDAllocation_AllocateGroupID_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "authd.proto",
 }
+
+const (
+	Events_Subscribe_FullMethodName = "/authd.Events/Subscribe"
+)
+
+// EventsClient is the client API for Events service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Events streams the authentications and the changes of the users of authd, so that monitoring agents don't have to
+// parse the journal.
+type EventsClient interface {
+	// Subscribe streams the events which happen after the subscription, until the client cancels it.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+}
+
+type eventsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEventsClient(cc grpc.ClientConnInterface) EventsClient {
+	return &eventsClient{cc}
+}
+
+func (c *eventsClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Events_ServiceDesc.Streams[0], Events_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Events_SubscribeClient = grpc.ServerStreamingClient[Event]
+
+// EventsServer is the server API for Events service.
+// All implementations must embed UnimplementedEventsServer
+// for forward compatibility.
+//
+// Events streams the authentications and the changes of the users of authd, so that monitoring agents don't have to
+// parse the journal.
+type EventsServer interface {
+	// Subscribe streams the events which happen after the subscription, until the client cancels it.
+	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Event]) error
+	mustEmbedUnimplementedEventsServer()
+}
+
+// UnimplementedEventsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEventsServer struct{}
+
+func (UnimplementedEventsServer) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Error(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedEventsServer) mustEmbedUnimplementedEventsServer() {}
+func (UnimplementedEventsServer) testEmbeddedByValue()                {}
+
+// UnsafeEventsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EventsServer will
+// result in compilation errors.
+type UnsafeEventsServer interface {
+	mustEmbedUnimplementedEventsServer()
+}
+
+func RegisterEventsServer(s grpc.ServiceRegistrar, srv EventsServer) {
+	// If the following call panics, it indicates UnimplementedEventsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Events_ServiceDesc, srv)
+}
+
+func _Events_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventsServer).Subscribe(m, &grpc.GenericServerStream[SubscribeRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Events_SubscribeServer = grpc.ServerStreamingServer[Event]
+
+// Events_ServiceDesc is the grpc.ServiceDesc for Events service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Events_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "authd.Events",
+	HandlerType: (*EventsServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _Events_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "authd.proto",
+}
@@ -0,0 +1,289 @@
+// Package securityreport evaluates the authd deployment against security best practices.
+package securityreport
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/canonical/authd/internal/consts"
+)
+
+// Status is the outcome of a security check.
+type Status string
+
+const (
+	// Pass means that the deployment follows the best practice.
+	Pass Status = "pass"
+	// Warn means that the deployment could be hardened.
+	Warn Status = "warn"
+	// Fail means that the deployment is exposed to a known risk.
+	Fail Status = "fail"
+	// Skip means that the check does not apply to this deployment.
+	Skip Status = "skip"
+)
+
+// Check is the result of a single security check.
+type Check struct {
+	ID          string
+	Description string
+	Status      Status
+	Details     string
+}
+
+// Report is the result of all security checks.
+type Report struct {
+	Checks []Check
+	// Score is the percentage of the maximum score reached by the checks which were not skipped.
+	// Passed checks count fully, checks with warnings count half.
+	Score int
+}
+
+// Config is the deployment configuration to be evaluated.
+type Config struct {
+	// DBDir is the directory containing the authd database.
+	DBDir string
+	// BrokersConfDir is the directory containing the broker configuration files.
+	BrokersConfDir string
+
+	AuthFailDelayThreshold int
+	AuthFailDelay          time.Duration
+	FaillockDeny           int
+	AlertsEnabled          bool
+	OfflineMaxAge          time.Duration
+
+	UIDMin uint32
+	GIDMin uint32
+}
+
+// systemIDMax is the highest UID and GID reserved for system users and groups.
+// See https://systemd.io/UIDS-GIDS/#summary
+const systemIDMax = 999
+
+// Evaluate runs all security checks against the given configuration.
+func Evaluate(cfg Config) Report {
+	checks := []Check{
+		checkBruteForceProtection(cfg),
+		checkLockout(cfg),
+		checkFailureAlerts(cfg),
+		checkDatabasePermissions(cfg),
+		checkBrokersConfPermissions(cfg),
+		checkIDRanges(cfg),
+		checkOfflineLimits(cfg),
+		{
+			ID:          "tpm_sealing",
+			Description: "Cached credentials are sealed with the TPM",
+			Status:      Skip,
+			Details:     "authd does not support sealing cached credentials with the TPM",
+		},
+		{
+			ID:          "broker_pkce",
+			Description: "Brokers use PKCE for OAuth 2.0 authorization code flows",
+			Status:      Skip,
+			Details:     "brokers do not report the OAuth 2.0 flows they use to authd",
+		},
+	}
+
+	return Report{Checks: checks, Score: score(checks)}
+}
+
+func score(checks []Check) int {
+	var points, maxPoints int
+	for _, c := range checks {
+		switch c.Status {
+		case Pass:
+			points += 2
+		case Warn:
+			points++
+		case Skip:
+			continue
+		}
+		maxPoints += 2
+	}
+	if maxPoints == 0 {
+		return 100
+	}
+	return points * 100 / maxPoints
+}
+
+func checkBruteForceProtection(cfg Config) Check {
+	c := Check{
+		ID:          "brute_force_protection",
+		Description: "Repeated authentication failures are delayed",
+		Status:      Pass,
+	}
+	if cfg.AuthFailDelay <= 0 || cfg.AuthFailDelayThreshold <= 0 {
+		c.Status = Fail
+		c.Details = "set auth_fail_delay and auth_fail_delay_threshold to a positive value"
+		return c
+	}
+	c.Details = fmt.Sprintf("%s delay after %d failures", cfg.AuthFailDelay, cfg.AuthFailDelayThreshold)
+	return c
+}
+
+func checkLockout(cfg Config) Check {
+	c := Check{
+		ID:          "lockout",
+		Description: "Users are locked out after repeated authentication failures",
+		Status:      Pass,
+	}
+	if cfg.FaillockDeny <= 0 {
+		c.Status = Warn
+		c.Details = "set faillock_deny to a positive value"
+		return c
+	}
+	c.Details = fmt.Sprintf("locked out after %d failures", cfg.FaillockDeny)
+	return c
+}
+
+func checkFailureAlerts(cfg Config) Check {
+	c := Check{
+		ID:          "failure_alerts",
+		Description: "Administrators are alerted about repeated authentication failures",
+		Status:      Pass,
+	}
+	if !cfg.AlertsEnabled {
+		c.Status = Warn
+		c.Details = "set alert_webhook_url or alert_email to be notified"
+	}
+	return c
+}
+
+func checkOfflineLimits(cfg Config) Check {
+	c := Check{
+		ID:          "offline_limits",
+		Description: "Offline authentication is limited in time",
+		Status:      Pass,
+	}
+	if cfg.OfflineMaxAge <= 0 {
+		c.Status = Warn
+		c.Details = "set offline_max_age to require users to authenticate with their provider regularly"
+		return c
+	}
+	c.Details = fmt.Sprintf("users must authenticate online every %s", cfg.OfflineMaxAge)
+	return c
+}
+
+func checkDatabasePermissions(cfg Config) Check {
+	c := Check{
+		ID:          "database_permissions",
+		Description: "The database is only accessible by root",
+		Status:      Pass,
+	}
+
+	var problems []string
+	for _, p := range []string{cfg.DBDir, filepath.Join(cfg.DBDir, consts.DefaultDatabaseFileName)} {
+		if problem := checkOnlyAccessibleByOwner(p); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	if len(problems) > 0 {
+		c.Status = Fail
+		c.Details = strings.Join(problems, "; ")
+	}
+	return c
+}
+
+func checkBrokersConfPermissions(cfg Config) Check {
+	c := Check{
+		ID:          "brokers_config_permissions",
+		Description: "Broker configuration files can only be modified by root",
+		Status:      Pass,
+	}
+
+	entries, err := os.ReadDir(cfg.BrokersConfDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		c.Status = Skip
+		c.Details = "no broker is configured"
+		return c
+	}
+	if err != nil {
+		c.Status = Fail
+		c.Details = fmt.Sprintf("could not read %s: %v", cfg.BrokersConfDir, err)
+		return c
+	}
+
+	var problems []string
+	if problem := checkNotWritableByOthers(cfg.BrokersConfDir); problem != "" {
+		problems = append(problems, problem)
+	}
+	for _, e := range entries {
+		if problem := checkNotWritableByOthers(filepath.Join(cfg.BrokersConfDir, e.Name())); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	if len(problems) > 0 {
+		c.Status = Fail
+		c.Details = strings.Join(problems, "; ")
+	}
+	return c
+}
+
+func checkIDRanges(cfg Config) Check {
+	c := Check{
+		ID:          "id_ranges",
+		Description: "UIDs and GIDs of authd users and groups do not overlap with system ones",
+		Status:      Pass,
+	}
+	var problems []string
+	if cfg.UIDMin <= systemIDMax {
+		problems = append(problems, fmt.Sprintf("UID_MIN %d is in the system range", cfg.UIDMin))
+	}
+	if cfg.GIDMin <= systemIDMax {
+		problems = append(problems, fmt.Sprintf("GID_MIN %d is in the system range", cfg.GIDMin))
+	}
+	if len(problems) > 0 {
+		c.Status = Fail
+		c.Details = strings.Join(problems, "; ")
+	}
+	return c
+}
+
+// checkOnlyAccessibleByOwner returns a description of the problem if path is accessible by other users
+// than the owner or not owned by the current user.
+func checkOnlyAccessibleByOwner(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Sprintf("could not check %s: %v", path, err)
+	}
+	if problem := checkOwner(path, fi); problem != "" {
+		return problem
+	}
+	if fi.Mode().Perm()&0o077 != 0 {
+		return fmt.Sprintf("%s is accessible by other users (mode %#o)", path, fi.Mode().Perm())
+	}
+	return ""
+}
+
+// checkNotWritableByOthers returns a description of the problem if path is writable by other users
+// than the owner or not owned by the current user.
+func checkNotWritableByOthers(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Sprintf("could not check %s: %v", path, err)
+	}
+	if problem := checkOwner(path, fi); problem != "" {
+		return problem
+	}
+	if fi.Mode().Perm()&0o022 != 0 {
+		return fmt.Sprintf("%s is writable by other users (mode %#o)", path, fi.Mode().Perm())
+	}
+	return ""
+}
+
+func checkOwner(path string, fi fs.FileInfo) string {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	//nolint:gosec // The effective UID is never negative.
+	if euid := uint32(os.Geteuid()); st.Uid != euid {
+		return fmt.Sprintf("%s is owned by UID %d instead of %d", path, st.Uid, euid)
+	}
+	return ""
+}
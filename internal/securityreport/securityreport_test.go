@@ -0,0 +1,183 @@
+package securityreport_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/consts"
+	"github.com/canonical/authd/internal/securityreport"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		noBruteForceProtection bool
+		noLockout              bool
+		alertsEnabled          bool
+		noOfflineLimit         bool
+		dbDirMode              os.FileMode
+		dbFileMode             os.FileMode
+		noDBFile               bool
+		brokersConfDirMode     os.FileMode
+		brokerConfMode         os.FileMode
+		noBrokersConfDir       bool
+		uidMin                 uint32
+
+		wantStatuses map[string]securityreport.Status
+		wantScore    int
+	}{
+		"Hardened_deployment": {
+			alertsEnabled: true,
+			wantStatuses: map[string]securityreport.Status{
+				"brute_force_protection":     securityreport.Pass,
+				"lockout":                    securityreport.Pass,
+				"failure_alerts":             securityreport.Pass,
+				"database_permissions":       securityreport.Pass,
+				"brokers_config_permissions": securityreport.Pass,
+				"id_ranges":                  securityreport.Pass,
+				"offline_limits":             securityreport.Pass,
+				"tpm_sealing":                securityreport.Skip,
+				"broker_pkce":                securityreport.Skip,
+			},
+			wantScore: 100,
+		},
+		"Default_deployment_warns_about_missing_alerts_and_offline_limit": {
+			noOfflineLimit: true,
+			wantStatuses:   map[string]securityreport.Status{"failure_alerts": securityreport.Warn, "offline_limits": securityreport.Warn},
+			wantScore:      85,
+		},
+		"Skip_brokers_config_check_without_brokers_config_dir": {
+			alertsEnabled: true, noBrokersConfDir: true,
+			wantStatuses: map[string]securityreport.Status{"brokers_config_permissions": securityreport.Skip},
+			wantScore:    100,
+		},
+
+		"Warn_without_offline_limit": {
+			alertsEnabled: true, noOfflineLimit: true,
+			wantStatuses: map[string]securityreport.Status{"offline_limits": securityreport.Warn},
+			wantScore:    92,
+		},
+		"Warn_without_lockout": {
+			alertsEnabled: true, noLockout: true,
+			wantStatuses: map[string]securityreport.Status{"lockout": securityreport.Warn},
+			wantScore:    92,
+		},
+
+		"Fail_without_brute_force_protection": {
+			alertsEnabled: true, noBruteForceProtection: true,
+			wantStatuses: map[string]securityreport.Status{"brute_force_protection": securityreport.Fail},
+			wantScore:    85,
+		},
+		"Fail_if_database_dir_is_accessible_by_others": {
+			alertsEnabled: true, dbDirMode: 0755,
+			wantStatuses: map[string]securityreport.Status{"database_permissions": securityreport.Fail},
+			wantScore:    85,
+		},
+		"Fail_if_database_file_is_accessible_by_others": {
+			alertsEnabled: true, dbFileMode: 0640,
+			wantStatuses: map[string]securityreport.Status{"database_permissions": securityreport.Fail},
+			wantScore:    85,
+		},
+		"Fail_if_database_file_does_not_exist": {
+			alertsEnabled: true, noDBFile: true,
+			wantStatuses: map[string]securityreport.Status{"database_permissions": securityreport.Fail},
+			wantScore:    85,
+		},
+		"Fail_if_brokers_config_dir_is_writable_by_others": {
+			alertsEnabled: true, brokersConfDirMode: 0777,
+			wantStatuses: map[string]securityreport.Status{"brokers_config_permissions": securityreport.Fail},
+			wantScore:    85,
+		},
+		"Fail_if_broker_config_is_writable_by_others": {
+			alertsEnabled: true, brokerConfMode: 0666,
+			wantStatuses: map[string]securityreport.Status{"brokers_config_permissions": securityreport.Fail},
+			wantScore:    85,
+		},
+		"Fail_if_UIDs_overlap_with_system_range": {
+			alertsEnabled: true, uidMin: 500,
+			wantStatuses: map[string]securityreport.Status{"id_ranges": securityreport.Fail},
+			wantScore:    85,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.dbDirMode == 0 {
+				tc.dbDirMode = 0700
+			}
+			if tc.dbFileMode == 0 {
+				tc.dbFileMode = 0600
+			}
+			if tc.brokersConfDirMode == 0 {
+				tc.brokersConfDirMode = 0755
+			}
+			if tc.brokerConfMode == 0 {
+				tc.brokerConfMode = 0644
+			}
+			if tc.uidMin == 0 {
+				tc.uidMin = 10000
+			}
+
+			dbDir := filepath.Join(t.TempDir(), "db")
+			require.NoError(t, os.Mkdir(dbDir, 0700), "Setup: could not create database dir")
+			if !tc.noDBFile {
+				dbFile := filepath.Join(dbDir, consts.DefaultDatabaseFileName)
+				require.NoError(t, os.WriteFile(dbFile, nil, 0600), "Setup: could not create database file")
+				require.NoError(t, os.Chmod(dbFile, tc.dbFileMode), "Setup: could not change database file mode")
+			}
+			//nolint:gosec // This is a temporary directory for tests.
+			require.NoError(t, os.Chmod(dbDir, tc.dbDirMode), "Setup: could not change database dir mode")
+
+			brokersConfDir := filepath.Join(t.TempDir(), "brokers.d")
+			if !tc.noBrokersConfDir {
+				require.NoError(t, os.Mkdir(brokersConfDir, 0700), "Setup: could not create brokers config dir")
+				brokerConf := filepath.Join(brokersConfDir, "broker.conf")
+				require.NoError(t, os.WriteFile(brokerConf, nil, 0600), "Setup: could not create broker config")
+				require.NoError(t, os.Chmod(brokerConf, tc.brokerConfMode), "Setup: could not change broker config mode")
+				//nolint:gosec // This is a temporary directory for tests.
+				require.NoError(t, os.Chmod(brokersConfDir, tc.brokersConfDirMode), "Setup: could not change brokers config dir mode")
+			}
+
+			cfg := securityreport.Config{
+				DBDir:                  dbDir,
+				BrokersConfDir:         brokersConfDir,
+				AuthFailDelayThreshold: 3,
+				AuthFailDelay:          2 * time.Second,
+				FaillockDeny:           10,
+				AlertsEnabled:          tc.alertsEnabled,
+				UIDMin:                 tc.uidMin,
+				GIDMin:                 10000,
+				OfflineMaxAge:          72 * time.Hour,
+			}
+			if tc.noOfflineLimit {
+				cfg.OfflineMaxAge = 0
+			}
+			if tc.noBruteForceProtection {
+				cfg.AuthFailDelay = 0
+			}
+			if tc.noLockout {
+				cfg.FaillockDeny = 0
+			}
+
+			report := securityreport.Evaluate(cfg)
+
+			got := make(map[string]securityreport.Status)
+			for _, c := range report.Checks {
+				require.NotEmpty(t, c.Description, "Check %q should have a description", c.ID)
+				got[c.ID] = c.Status
+				if c.Status == securityreport.Fail || c.Status == securityreport.Warn {
+					require.NotEmpty(t, c.Details, "Check %q should explain why it did not pass", c.ID)
+				}
+			}
+			for id, want := range tc.wantStatuses {
+				require.Equal(t, want, got[id], "Unexpected status for check %q", id)
+			}
+			require.Equal(t, tc.wantScore, report.Score, "Unexpected score")
+		})
+	}
+}
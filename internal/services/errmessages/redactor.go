@@ -16,21 +16,30 @@ import (
 // It unwraps the error up to the first ErrToDisplay and sends it to the client. If none is found, it sends the original error.
 func RedactErrorInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
 	m, err := handler(ctx, req)
-	if err != nil {
-		var redactedError ToDisplayError
-		if !errors.As(err, &redactedError) {
-			return m, err
-		}
-		return m, redactedError
+	return m, Redact(err)
+}
+
+// Redact returns the first ErrToDisplay wrapped by err, or err itself if there is none.
+func Redact(err error) error {
+	if err == nil {
+		return nil
 	}
-	return m, nil
+	var redactedError ToDisplayError
+	if !errors.As(err, &redactedError) {
+		return err
+	}
+	return redactedError
 }
 
 // FormatErrorMessage formats the error message received by the client to avoid printing useless information.
 //
 // It converts the gRPC error to a more human-readable error with a better message.
 func FormatErrorMessage(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-	err := invoker(ctx, method, req, reply, cc, opts...)
+	return Format(invoker(ctx, method, req, reply, cc, opts...))
+}
+
+// Format converts the gRPC error received by the client to a more human-readable error with a better message.
+func Format(err error) error {
 	if err == nil {
 		return nil
 	}
@@ -0,0 +1,223 @@
+// Package events streams the authentications and the changes of the users of authd to the subscribers of the gRPC
+// Events service, so that monitoring agents don't have to parse the journal.
+package events
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/services/permissions"
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Kind is the kind of an event.
+type Kind string
+
+const (
+	// LoginSucceeded is sent when a user authenticated and is allowed to log in.
+	LoginSucceeded Kind = "login_succeeded"
+	// LoginFailed is sent when the authentication of a user failed or when they're not allowed to log in.
+	LoginFailed Kind = "login_failed"
+	// UserAdded is sent when a user logged in for the first time.
+	UserAdded Kind = "user_added"
+	// UserUpdated is sent when the information of a user changed.
+	UserUpdated Kind = "user_updated"
+	// UserDisabled is sent when a user was locked or deprovisioned.
+	UserDisabled Kind = "user_disabled"
+	// UserRemoved is sent when a user was deleted.
+	UserRemoved Kind = "user_removed"
+	// TokenIssued is sent when the broker of a user issued a session token for them.
+	TokenIssued Kind = "token_issued"
+	// BrokerOffline is sent when a user logged in with the information cached by their broker, which couldn't reach
+	// the identity provider.
+	BrokerOffline Kind = "broker_offline"
+)
+
+// kinds are all the kinds of events, which can be subscribed to.
+var kinds = []Kind{LoginSucceeded, LoginFailed, UserAdded, UserUpdated, UserDisabled, UserRemoved, TokenIssued, BrokerOffline}
+
+// userKinds are the kinds of the events of the changes of users.
+var userKinds = map[users.ChangeKind]Kind{
+	users.Added:    UserAdded,
+	users.Updated:  UserUpdated,
+	users.Disabled: UserDisabled,
+	users.Removed:  UserRemoved,
+}
+
+// Event is an authentication or a change of a user.
+type Event struct {
+	Kind     Kind
+	Time     time.Time
+	Username string
+	UID      uint32
+	BrokerID string
+	// Service is the PAM service of the authentications.
+	Service string
+	Offline bool
+	// Result is the reason of the failures, as in the login history.
+	Result string
+}
+
+// subscriberBufferSize is the number of events kept for a subscriber which doesn't read them as fast as they happen.
+// The next events are dropped for it.
+const subscriberBufferSize = 256
+
+// Broadcaster sends the events to the subscribers. A nil Broadcaster drops all events.
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	kinds  []Kind
+	events chan Event
+	// dropping is true while the events are dropped because the buffer is full, so that it's only logged once.
+	dropping bool
+}
+
+// NewBroadcaster returns a broadcaster without subscribers.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: make(map[*subscriber]struct{})}
+}
+
+// Publish sends the event to the subscribers of its kind. It never blocks: the event is dropped for the subscribers
+// which didn't read the previous ones yet.
+func (b *Broadcaster) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscribers {
+		if len(s.kinds) > 0 && !slices.Contains(s.kinds, e.Kind) {
+			continue
+		}
+		select {
+		case s.events <- e:
+			s.dropping = false
+		default:
+			if !s.dropping {
+				log.Warningf(context.Background(), "Dropping events for a subscriber which doesn't read them fast enough")
+			}
+			s.dropping = true
+		}
+	}
+}
+
+// HasSubscribers returns true if at least one client is subscribed to the events, so that the publishers can skip
+// gathering their information otherwise.
+func (b *Broadcaster) HasSubscribers() bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers) > 0
+}
+
+// UserChanged publishes the change of the user. It implements [users.ChangeNotifier].
+func (b *Broadcaster) UserChanged(kind users.ChangeKind, name string, uid uint32) {
+	k, ok := userKinds[kind]
+	if !ok {
+		return
+	}
+	b.Publish(Event{Kind: k, Username: name, UID: uid})
+}
+
+// GroupChanged implements [users.ChangeNotifier]. The changes of groups are not published.
+func (b *Broadcaster) GroupChanged(users.ChangeKind, string, uint32) {}
+
+func (b *Broadcaster) subscribe(kinds []Kind) *subscriber {
+	s := &subscriber{kinds: kinds, events: make(chan Event, subscriberBufferSize)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[s] = struct{}{}
+	return s
+}
+
+func (b *Broadcaster) unsubscribe(s *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, s)
+}
+
+// Service is the implementation of the gRPC Events service.
+type Service struct {
+	// ctx is cancelled when the daemon stops, which ends the subscriptions.
+	ctx               context.Context
+	broadcaster       *Broadcaster
+	permissionManager *permissions.Manager
+
+	authd.UnimplementedEventsServer
+}
+
+// NewService returns a new gRPC Events service, streaming the events of the broadcaster until ctx is cancelled.
+func NewService(ctx context.Context, broadcaster *Broadcaster, permissionManager *permissions.Manager) Service {
+	log.Debug(ctx, "Building new gRPC Events service")
+
+	return Service{
+		ctx:               ctx,
+		broadcaster:       broadcaster,
+		permissionManager: permissionManager,
+	}
+}
+
+// Subscribe streams the events of the requested kinds, or of all kinds if none is requested, which happen after the
+// subscription. Only root can subscribe, as the events reveal who logs in.
+func (s Service) Subscribe(req *authd.SubscribeRequest, stream authd.Events_SubscribeServer) error {
+	ctx := stream.Context()
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	var subscribed []Kind
+	for _, k := range req.GetKinds() {
+		if !slices.Contains(kinds, Kind(k)) {
+			return status.Errorf(codes.InvalidArgument, "unknown event kind %q", k)
+		}
+		subscribed = append(subscribed, Kind(k))
+	}
+
+	sub := s.broadcaster.subscribe(subscribed)
+	defer s.broadcaster.unsubscribe(sub)
+	log.Debugf(ctx, "New subscription to events %v", subscribed)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.ctx.Done():
+			return status.Error(codes.Unavailable, "authd is stopping")
+		case e := <-sub.events:
+			if err := stream.Send(eventToProto(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func eventToProto(e Event) *authd.Event {
+	return &authd.Event{
+		Kind:     string(e.Kind),
+		Time:     e.Time.UnixMilli(),
+		Username: e.Username,
+		Uid:      e.UID,
+		BrokerId: e.BrokerID,
+		Service:  e.Service,
+		Offline:  e.Offline,
+		Result:   e.Result,
+	}
+}
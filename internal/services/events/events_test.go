@@ -0,0 +1,178 @@
+package events_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/services/events"
+	"github.com/canonical/authd/internal/services/permissions"
+	"github.com/canonical/authd/internal/users"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+
+	eventTime := time.Unix(1700000000, 0)
+	login := events.Event{Kind: events.LoginSucceeded, Time: eventTime, Username: "user1@example.com", UID: 1111,
+		BrokerID: "broker-id", Service: "sshd"}
+	failure := events.Event{Kind: events.LoginFailed, Time: eventTime, Username: "user1@example.com",
+		BrokerID: "broker-id", Service: "sshd", Result: "denied"}
+
+	tests := map[string]struct {
+		kinds              []string
+		currentUserNotRoot bool
+
+		wantKinds []string
+		wantCode  codes.Code
+	}{
+		"Stream_all_events":            {wantKinds: []string{"login_succeeded", "login_failed", "user_added"}},
+		"Stream_the_subscribed_events": {kinds: []string{"login_failed", "user_added"}, wantKinds: []string{"login_failed", "user_added"}},
+
+		"Error_if_the_kind_is_unknown":    {kinds: []string{"unknown"}, wantCode: codes.InvalidArgument},
+		"Error_if_the_caller_is_not_root": {currentUserNotRoot: true, wantCode: codes.PermissionDenied},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := events.NewBroadcaster()
+			client := newEventsClient(t, context.Background(), b, tc.currentUserNotRoot)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			stream, err := client.Subscribe(ctx, &authd.SubscribeRequest{Kinds: tc.kinds})
+			require.NoError(t, err, "Subscribe should not return an error")
+
+			if tc.wantCode != codes.OK {
+				_, err := stream.Recv()
+				require.Equal(t, tc.wantCode, status.Code(err), "Recv should return the expected error")
+				return
+			}
+
+			require.Eventually(t, func() bool { return b.HasSubscribers() }, 5*time.Second, 10*time.Millisecond,
+				"Setup: the client should be subscribed")
+			b.Publish(login)
+			b.Publish(failure)
+			b.UserChanged(users.Added, "user2@example.com", 2222)
+			b.GroupChanged(users.Added, "group2@example.com", 2222)
+
+			for _, want := range tc.wantKinds {
+				got, err := stream.Recv()
+				require.NoError(t, err, "Recv should not return an error")
+				require.Equal(t, want, got.GetKind(), "Recv should return the published events in order")
+
+				switch want {
+				case "login_succeeded":
+					require.Equal(t, eventTime.UnixMilli(), got.GetTime(), "The event should have the time of the login")
+					require.Equal(t, "user1@example.com", got.GetUsername(), "The event should have the user")
+					require.Equal(t, uint32(1111), got.GetUid(), "The event should have the UID")
+					require.Equal(t, "broker-id", got.GetBrokerId(), "The event should have the broker")
+					require.Equal(t, "sshd", got.GetService(), "The event should have the service")
+				case "login_failed":
+					require.Equal(t, "denied", got.GetResult(), "The event should have the result")
+				case "user_added":
+					require.Equal(t, "user2@example.com", got.GetUsername(), "The event should have the user")
+					require.Equal(t, uint32(2222), got.GetUid(), "The event should have the UID")
+					require.NotZero(t, got.GetTime(), "The event should have a time")
+				}
+			}
+		})
+	}
+}
+
+func TestSubscribeEndsWithTheService(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := events.NewBroadcaster()
+	client := newEventsClient(t, ctx, b, false)
+
+	stream, err := client.Subscribe(context.Background(), &authd.SubscribeRequest{})
+	require.NoError(t, err, "Subscribe should not return an error")
+	require.Eventually(t, func() bool { return b.HasSubscribers() }, 5*time.Second, 10*time.Millisecond,
+		"Setup: the client should be subscribed")
+
+	cancel()
+	_, err = stream.Recv()
+	require.Equal(t, codes.Unavailable, status.Code(err), "Recv should fail once the service is stopped")
+	require.Eventually(t, func() bool { return !b.HasSubscribers() }, 5*time.Second, 10*time.Millisecond,
+		"The client should be unsubscribed")
+}
+
+func TestPublishDoesNotBlock(t *testing.T) {
+	t.Parallel()
+
+	var nilBroadcaster *events.Broadcaster
+	nilBroadcaster.Publish(events.Event{Kind: events.LoginSucceeded})
+
+	b := events.NewBroadcaster()
+	client := newEventsClient(t, context.Background(), b, false)
+
+	// The subscriber never reads the events.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := client.Subscribe(ctx, &authd.SubscribeRequest{})
+	require.NoError(t, err, "Subscribe should not return an error")
+	require.Eventually(t, func() bool { return b.HasSubscribers() }, 5*time.Second, 10*time.Millisecond,
+		"Setup: the client should be subscribed")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 10000 {
+			b.Publish(events.Event{Kind: events.LoginSucceeded, Username: "user1@example.com"})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Publish should not block when a subscriber doesn't read the events")
+	}
+}
+
+func newEventsClient(t *testing.T, ctx context.Context, b *events.Broadcaster, currentUserNotRoot bool) authd.EventsClient {
+	t.Helper()
+
+	tmpDir, err := os.MkdirTemp("", "authd-socket-dir")
+	require.NoError(t, err, "Setup: could not setup temporary socket dir path")
+	t.Cleanup(func() { _ = os.RemoveAll(tmpDir) })
+	socketPath := filepath.Join(tmpDir, "authd.sock")
+
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err, "Setup: could not create unix socket")
+
+	var permissionsManager permissions.Manager
+	if currentUserNotRoot {
+		permissionsManager = permissions.New()
+	} else {
+		permissionsManager = permissions.New(permissions.Z_ForTests_WithCurrentUserAsRoot())
+	}
+
+	grpcServer := grpc.NewServer(permissions.WithUnixPeerCreds())
+	authd.RegisterEventsServer(grpcServer, events.NewService(ctx, b, &permissionsManager))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = grpcServer.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		grpcServer.Stop()
+		<-done
+	})
+
+	conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err, "Setup: Could not connect to gRPC server")
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return authd.NewEventsClient(conn)
+}
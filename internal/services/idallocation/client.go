@@ -0,0 +1,64 @@
+package idallocation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/users"
+	"google.golang.org/grpc"
+)
+
+// requestTimeout is how long a login waits for the ID allocation authority.
+const requestTimeout = 10 * time.Second
+
+// Client requests the IDs of new users and groups from the ID allocation authority.
+type Client struct {
+	conn   *grpc.ClientConn
+	client authd.IDAllocationClient
+}
+
+// NewClient returns a client of the ID allocation authority at the endpoint of the configuration.
+func NewClient(cfg users.IDAllocationConfig) (*Client, error) {
+	creds, err := clientCredentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := grpc.NewClient(cfg.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to the ID allocation authority at %q: %w", cfg.Endpoint, err)
+	}
+
+	return &Client{conn: conn, client: authd.NewIDAllocationClient(conn)}, nil
+}
+
+// AllocateUID returns the UID of the user with the given name.
+func (c *Client) AllocateUID(ctx context.Context, name string) (uint32, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	id, err := c.client.AllocateUserID(ctx, &authd.AllocateUserIDRequest{Name: name})
+	if err != nil {
+		return 0, err
+	}
+	return id.GetId(), nil
+}
+
+// AllocateGID returns the GID of the group with the given UGID.
+func (c *Client) AllocateGID(ctx context.Context, ugid string) (uint32, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	id, err := c.client.AllocateGroupID(ctx, &authd.AllocateGroupIDRequest{Ugid: ugid})
+	if err != nil {
+		return 0, err
+	}
+	return id.GetId(), nil
+}
+
+// Close closes the connection to the ID allocation authority.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
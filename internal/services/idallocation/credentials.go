@@ -0,0 +1,61 @@
+package idallocation
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/canonical/authd/internal/users"
+	"google.golang.org/grpc/credentials"
+)
+
+// serverCredentials returns the credentials of the ID allocation authority, which only accepts the instances with a
+// certificate signed by the CA.
+func serverCredentials(cfg users.IDAllocationConfig) (credentials.TransportCredentials, error) {
+	cert, pool, err := loadCertificates(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS13,
+	}), nil
+}
+
+// clientCredentials returns the credentials of the instances, which only accept an ID allocation authority with a
+// certificate signed by the CA.
+func clientCredentials(cfg users.IDAllocationConfig) (credentials.TransportCredentials, error) {
+	cert, pool, err := loadCertificates(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS13,
+	}), nil
+}
+
+func loadCertificates(cfg users.IDAllocationConfig) (tls.Certificate, *x509.CertPool, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("could not load the ID allocation certificate: %w", err)
+	}
+
+	ca, err := os.ReadFile(cfg.CAFile)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("could not read the ID allocation CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return tls.Certificate{}, nil, errors.New("no certificate found in the ID allocation CA")
+	}
+
+	return cert, pool, nil
+}
@@ -0,0 +1,17 @@
+package idallocation
+
+import (
+	"context"
+	"net"
+
+	"github.com/canonical/authd/internal/users"
+)
+
+// ServeOnListener serves the ID allocation service on lis with the credentials of the configuration.
+func ServeOnListener(ctx context.Context, userManager *users.Manager, lis net.Listener, cfg users.IDAllocationConfig) (stop func(), err error) {
+	creds, err := serverCredentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return serve(ctx, userManager, lis, creds), nil
+}
@@ -0,0 +1,109 @@
+// Package idallocation implements the central allocation of UIDs and GIDs, which gives roaming users the same IDs on
+// all the machines: the ID allocation authority serves it, and the other instances request the IDs of their new users
+// and groups from it.
+package idallocation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/canonical/authd/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// Service is the implementation of the gRPC ID allocation service.
+type Service struct {
+	userManager *users.Manager
+
+	authd.UnimplementedIDAllocationServer
+}
+
+// NewService returns a new gRPC ID allocation service.
+func NewService(ctx context.Context, userManager *users.Manager) Service {
+	log.Debug(ctx, "Building new gRPC ID allocation service")
+
+	return Service{userManager: userManager}
+}
+
+// AllocateUserID returns the UID of the user with the given name, allocating one on first request.
+func (s Service) AllocateUserID(ctx context.Context, req *authd.AllocateUserIDRequest) (*authd.AllocatedID, error) {
+	// The instances may request the ID of a user by any spelling of their name, so it's normalized like the names of
+	// the users of the authority.
+	name := s.userManager.NormalizeName(req.GetName())
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+
+	uid, err := s.userManager.AllocateUserID(name)
+	if err != nil {
+		log.Errorf(ctx, "Could not allocate a UID to user %q: %v", name, err)
+		return nil, grpcError(err)
+	}
+	return &authd.AllocatedID{Id: uid}, nil
+}
+
+// AllocateGroupID returns the GID of the group with the given UGID, allocating one on first request.
+func (s Service) AllocateGroupID(ctx context.Context, req *authd.AllocateGroupIDRequest) (*authd.AllocatedID, error) {
+	ugid := req.GetUgid()
+	if ugid == "" {
+		return nil, status.Error(codes.InvalidArgument, "no group UGID provided")
+	}
+
+	gid, err := s.userManager.AllocateGroupID(ugid)
+	if err != nil {
+		log.Errorf(ctx, "Could not allocate a GID to group %q: %v", ugid, err)
+		return nil, grpcError(err)
+	}
+	return &authd.AllocatedID{Id: gid}, nil
+}
+
+// Serve serves the ID allocation service on the TCP address of the configuration, until the returned function is
+// called.
+func Serve(ctx context.Context, userManager *users.Manager, cfg users.IDAllocationConfig) (stop func(), err error) {
+	creds, err := serverCredentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	lis, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on %q: %w", cfg.Listen, err)
+	}
+
+	return serve(ctx, userManager, lis, creds), nil
+}
+
+func serve(ctx context.Context, userManager *users.Manager, lis net.Listener, creds credentials.TransportCredentials) (stop func()) {
+	grpcServer := grpc.NewServer(grpc.Creds(creds))
+	authd.RegisterIDAllocationServer(grpcServer, NewService(ctx, userManager))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		log.Infof(ctx, "Serving ID allocation on %s", lis.Addr())
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Errorf(ctx, "Could not serve ID allocation: %v", err)
+		}
+	}()
+
+	return func() {
+		grpcServer.GracefulStop()
+		<-done
+	}
+}
+
+// grpcError converts well-known user manager errors to their proper gRPC status codes.
+func grpcError(err error) error {
+	if errors.Is(err, db.NoDataFoundError{}) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
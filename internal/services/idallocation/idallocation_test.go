@@ -0,0 +1,193 @@
+package idallocation_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/services/idallocation"
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	localgroupstestutils "github.com/canonical/authd/internal/users/localentries/testutils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIDAllocation(t *testing.T) {
+	// We don't care about the output of gpasswd in this test, but we still need to mock it.
+	_ = localgroupstestutils.SetupGroupMock(t, filepath.Join("testdata", "empty.group"))
+
+	certsDir := t.TempDir()
+	ca := newTestCA(t, certsDir, "ca")
+	untrustedCA := newTestCA(t, certsDir, "untrusted-ca")
+	serverCfg := ca.issue(t, "authority")
+	serverCfg.Listen = "127.0.0.1:0"
+
+	dbDir := t.TempDir()
+	err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "one_user_and_group.db.yaml"), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+	usersCfg := users.DefaultConfig
+	usersCfg.IDAllocation = serverCfg
+	usersCfg.Normalization.Aliases = map[string]string{"roamer": "roaming@example.com"}
+	m, err := users.NewManager(usersCfg, dbDir)
+	require.NoError(t, err, "Setup: could not create user manager")
+	t.Cleanup(func() { _ = m.Stop() })
+
+	lis, err := net.Listen("tcp", serverCfg.Listen)
+	require.NoError(t, err, "Setup: could not listen")
+	stop, err := idallocation.ServeOnListener(context.Background(), m, lis, serverCfg)
+	require.NoError(t, err, "Setup: could not serve ID allocation")
+	t.Cleanup(stop)
+
+	newClient := func(t *testing.T, cfg users.IDAllocationConfig) *idallocation.Client {
+		t.Helper()
+
+		cfg.Endpoint = lis.Addr().String()
+		c, err := idallocation.NewClient(cfg)
+		require.NoError(t, err, "Setup: could not create client")
+		t.Cleanup(func() { _ = c.Close() })
+		return c
+	}
+
+	t.Run("Successfully_allocate_the_same_IDs_to_all_instances", func(t *testing.T) {
+		first := newClient(t, ca.issue(t, "first-instance"))
+		second := newClient(t, ca.issue(t, "second-instance"))
+
+		uid, err := first.AllocateUID(context.Background(), "Roaming@example.com")
+		require.NoError(t, err, "AllocateUID should not return an error")
+		require.GreaterOrEqual(t, uid, usersCfg.UIDMin, "Allocated UID should be in the UID range of the authority")
+		got, err := second.AllocateUID(context.Background(), "roaming@example.com")
+		require.NoError(t, err, "AllocateUID should not return an error")
+		require.Equal(t, uid, got, "All instances should get the same UID for the user")
+		got, err = second.AllocateUID(context.Background(), "Roamer")
+		require.NoError(t, err, "AllocateUID should not return an error")
+		require.Equal(t, uid, got, "All instances should get the same UID for the alias of the user")
+
+		gid, err := first.AllocateGID(context.Background(), "roaming-group")
+		require.NoError(t, err, "AllocateGID should not return an error")
+		got, err = second.AllocateGID(context.Background(), "roaming-group")
+		require.NoError(t, err, "AllocateGID should not return an error")
+		require.Equal(t, gid, got, "All instances should get the same GID for the group")
+
+		got, err = first.AllocateUID(context.Background(), "user1@example.com")
+		require.NoError(t, err, "AllocateUID should not return an error")
+		require.Equal(t, uint32(1111), got, "Users of the authority should keep their UID")
+	})
+
+	t.Run("Error_when_name_is_empty", func(t *testing.T) {
+		c := newClient(t, ca.issue(t, "instance"))
+
+		_, err := c.AllocateUID(context.Background(), "")
+		require.Equal(t, codes.InvalidArgument, status.Code(err), "AllocateUID should return the expected error code")
+		_, err = c.AllocateGID(context.Background(), "")
+		require.Equal(t, codes.InvalidArgument, status.Code(err), "AllocateGID should return the expected error code")
+	})
+
+	t.Run("Error_when_instance_certificate_is_not_trusted", func(t *testing.T) {
+		cfg := untrustedCA.issue(t, "untrusted-instance")
+		// The instance trusts the authority, but the authority doesn't trust the instance.
+		cfg.CAFile = ca.certFile
+		c := newClient(t, cfg)
+
+		_, err := c.AllocateUID(context.Background(), "intruder@example.com")
+		require.Error(t, err, "AllocateUID should return an error")
+	})
+
+	t.Run("Error_when_authority_certificate_is_not_trusted", func(t *testing.T) {
+		cfg := ca.issue(t, "instance")
+		cfg.CAFile = untrustedCA.certFile
+		c := newClient(t, cfg)
+
+		_, err := c.AllocateUID(context.Background(), "user@example.com")
+		require.Error(t, err, "AllocateUID should return an error")
+	})
+
+	t.Run("Error_when_certificates_can_not_be_loaded", func(t *testing.T) {
+		cfg := ca.issue(t, "instance")
+		cfg.KeyFile = filepath.Join(certsDir, "does-not-exist.key")
+		cfg.Endpoint = lis.Addr().String()
+
+		_, err := idallocation.NewClient(cfg)
+		require.Error(t, err, "NewClient should return an error")
+	})
+}
+
+type testCA struct {
+	dir      string
+	certFile string
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+}
+
+// newTestCA creates a self-signed CA in dir.
+func newTestCA(t *testing.T, dir, name string) testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Setup: could not generate CA key")
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err, "Setup: could not create CA certificate")
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err, "Setup: could not parse CA certificate")
+
+	certFile := filepath.Join(dir, name+".pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	return testCA{dir: dir, certFile: certFile, cert: cert, key: key}
+}
+
+// issue returns the configuration of an instance with a certificate for localhost signed by the CA.
+func (ca testCA) issue(t *testing.T, name string) users.IDAllocationConfig {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Setup: could not generate key")
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err, "Setup: could not create certificate")
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err, "Setup: could not marshal key")
+
+	cfg := users.IDAllocationConfig{
+		CertFile: filepath.Join(ca.dir, name+".pem"),
+		KeyFile:  filepath.Join(ca.dir, name+".key"),
+		CAFile:   ca.certFile,
+	}
+	writePEM(t, cfg.CertFile, "CERTIFICATE", der)
+	writePEM(t, cfg.KeyFile, "EC PRIVATE KEY", keyDER)
+	return cfg
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0600), "Setup: could not write %s", path)
+}
@@ -3,14 +3,29 @@ package services
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
 
+	"github.com/canonical/authd/internal/accountsservice"
 	"github.com/canonical/authd/internal/brokers"
 	"github.com/canonical/authd/internal/consts"
+	"github.com/canonical/authd/internal/faillock"
+	"github.com/canonical/authd/internal/ldap"
+	"github.com/canonical/authd/internal/loginpolicy"
+	"github.com/canonical/authd/internal/metrics"
 	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/securityreport"
 	"github.com/canonical/authd/internal/services/errmessages"
+	"github.com/canonical/authd/internal/services/events"
+	"github.com/canonical/authd/internal/services/idallocation"
 	"github.com/canonical/authd/internal/services/pam"
 	"github.com/canonical/authd/internal/services/permissions"
+	"github.com/canonical/authd/internal/services/scim"
+	"github.com/canonical/authd/internal/services/signals"
 	"github.com/canonical/authd/internal/services/user"
+	"github.com/canonical/authd/internal/services/userdb"
 	"github.com/canonical/authd/internal/users"
 	"github.com/canonical/authd/log"
 	"google.golang.org/grpc"
@@ -24,40 +39,193 @@ type Manager struct {
 	brokerManager *brokers.Manager
 	pamService    pam.Service
 	userService   user.Service
+
+	stopScheduledTasks func()
+	stopCentralPolicy  func()
+	stopIDAllocation   func()
+	idAllocationClient *idallocation.Client
+	stopSCIM           func()
+	stopLDAPServer     func()
+	stopUserDB         func()
+	metrics            *metrics.Metrics
+	stopMetrics        func()
+	signals            *signals.Emitter
+	accountsService    *accountsservice.Client
+	events             *events.Broadcaster
+	permissionManager  *permissions.Manager
 }
 
 // NewManager returns a new manager after creating all necessary items for our business logic.
 func NewManager(ctx context.Context, dbDir, brokersConfPath string, configuredBrokers []string, usersConfig users.Config, pamConfig pam.Config) (m Manager, err error) {
 	log.Debug(ctx, "Building authd object")
 
+	if err := pamConfig.AdminRole.Validate(); err != nil {
+		return m, err
+	}
+	if err := pamConfig.LoginPolicy.Validate(); err != nil {
+		return m, err
+	}
+	if err := pamConfig.Faillock.Validate(); err != nil {
+		return m, err
+	}
+	if err := pamConfig.LDAP.Validate(); err != nil {
+		return m, err
+	}
+	if err := pamConfig.SSHCert.Validate(); err != nil {
+		return m, err
+	}
+	if err := pamConfig.CredentialHooks.Validate(); err != nil {
+		return m, err
+	}
+	if err := pamConfig.EmergencyAccess.Validate(); err != nil {
+		return m, err
+	}
+	if err := pamConfig.ValidateNetworkHome(); err != nil {
+		return m, err
+	}
+	if err := pamConfig.ValidateFscryptHomes(); err != nil {
+		return m, err
+	}
+
+	var centralPolicy *loginpolicy.Central
+	if pamConfig.CentralPolicy.Enabled() {
+		centralPolicy, err = loginpolicy.NewCentral(pamConfig.CentralPolicy, dbDir)
+		if err != nil {
+			return m, err
+		}
+	}
+	loginPolicy := loginpolicy.NewPolicy(pamConfig.LoginPolicy, centralPolicy)
+
 	brokerManager, err := brokers.NewManager(ctx, brokersConfPath, configuredBrokers)
 	if err != nil {
 		return m, err
 	}
 
-	userManager, err := users.NewManager(usersConfig, dbDir)
+	m.signals, err = signals.New(ctx)
+	if err != nil {
+		return m, err
+	}
+	brokerIDs := make(map[string]string)
+	for _, b := range brokerManager.AvailableBrokers() {
+		brokerIDs[b.Name] = b.ID
+	}
+	m.events = events.NewBroadcaster()
+	replies := user.NewReplyCache()
+	userOpts := []users.Option{
+		users.WithChangeNotifier(m.signals),
+		users.WithChangeNotifier(m.events),
+		users.WithChangeNotifier(replies),
+		users.WithBrokerIDs(brokerIDs),
+	}
+
+	if usersConfig.IDAllocation.Remote() {
+		m.idAllocationClient, err = idallocation.NewClient(usersConfig.IDAllocation)
+		if err != nil {
+			return m, err
+		}
+		userOpts = append(userOpts, users.WithIDAllocator(m.idAllocationClient))
+	}
+
+	userManager, err := users.NewManager(usersConfig, dbDir, userOpts...)
 	if err != nil {
 		return m, err
 	}
 
 	permissionManager := permissions.New()
+	failLock := faillock.New(pamConfig.Faillock)
+
+	m.metrics = metrics.New(userManager)
+	brokerManager.SetLatencyObserver(m.metrics)
+
+	userService := user.NewService(ctx, userManager, brokerManager, &permissionManager,
+		user.WithSecurityReportConfig(securityreport.Config{
+			DBDir:                  dbDir,
+			BrokersConfDir:         brokersConfPath,
+			AuthFailDelayThreshold: pamConfig.AuthFailDelayThreshold,
+			AuthFailDelay:          pamConfig.AuthFailDelay,
+			FaillockDeny:           pamConfig.Faillock.Deny,
+			AlertsEnabled:          pamConfig.Alerts.Enabled(),
+			UIDMin:                 usersConfig.UIDMin,
+			GIDMin:                 usersConfig.GIDMin,
+		}),
+		user.WithLoginPolicy(loginPolicy),
+		user.WithFaillock(failLock),
+		user.WithEvents(m.events),
+		user.WithReplyCache(replies),
+		user.WithMetrics(m.metrics),
+		user.WithEnumeration(!usersConfig.DisableEnumeration),
+	)
+	if err := m.signals.ExportSessions(userService); err != nil {
+		return m, err
+	}
+	m.accountsService = accountsservice.New(filepath.Join(dbDir, "pictures"))
+	pamService := pam.NewService(ctx, userManager, brokerManager, &permissionManager, pamConfig,
+		pam.WithLoginPolicy(loginPolicy), pam.WithFaillock(failLock), pam.WithAccountsService(m.accountsService),
+		pam.WithEvents(m.events), pam.WithMetrics(m.metrics))
 
-	userService := user.NewService(ctx, userManager, brokerManager, &permissionManager)
-	pamService := pam.NewService(ctx, userManager, brokerManager, pamConfig)
+	m.userManager = userManager
+	m.brokerManager = brokerManager
+	m.userService = userService
+	m.pamService = pamService
+	m.permissionManager = &permissionManager
 
-	return Manager{
-		userManager:   userManager,
-		brokerManager: brokerManager,
-		userService:   userService,
-		pamService:    pamService,
-	}, nil
+	if usersConfig.IDAllocation.Authority() {
+		m.stopIDAllocation, err = idallocation.Serve(ctx, userManager, usersConfig.IDAllocation)
+		if err != nil {
+			return m, err
+		}
+	}
+
+	if usersConfig.SCIM.Enabled() {
+		m.stopSCIM, err = scim.Serve(ctx, userManager, brokerManager, usersConfig.SCIM)
+		if err != nil {
+			return m, err
+		}
+	}
+
+	if usersConfig.LDAPServer.Enabled() {
+		m.stopLDAPServer, err = ldap.Serve(ctx, userManager, usersConfig.LDAPServer)
+		if err != nil {
+			return m, err
+		}
+	}
+
+	if usersConfig.Lifecycle.Automatic() || usersConfig.HomeCleanup.Scheduled() || usersConfig.Snapshot.Scheduled() ||
+		usersConfig.StaleUsers.Scheduled() || usersConfig.Revalidation.Scheduled() {
+		scheduledTasksCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			userService.RunScheduledTasks(scheduledTasksCtx)
+		}()
+		// The database must only be closed once the tasks in progress are done.
+		m.stopScheduledTasks = sync.OnceFunc(func() {
+			cancel()
+			<-done
+		})
+	}
+
+	if centralPolicy != nil {
+		centralPolicyCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			centralPolicy.Run(centralPolicyCtx)
+		}()
+		m.stopCentralPolicy = sync.OnceFunc(func() {
+			cancel()
+			<-done
+		})
+	}
+
+	return m, nil
 }
 
 // RegisterGRPCServices returns a new grpc Server after registering both NSS and PAM services.
 func (m Manager) RegisterGRPCServices(ctx context.Context) *grpc.Server {
 	log.Debug(ctx, "Registering gRPC services")
 
-	opts := []grpc.ServerOption{permissions.WithUnixPeerCreds(), grpc.ChainUnaryInterceptor(errmessages.RedactErrorInterceptor)}
+	opts := []grpc.ServerOption{permissions.WithUnixPeerCreds(), grpc.ChainUnaryInterceptor(errmessages.RedactErrorInterceptor, m.metrics.UnaryInterceptor)}
 	grpcServer := grpc.NewServer(opts...)
 
 	healthCheck := health.NewServer()
@@ -70,13 +238,88 @@ func (m Manager) RegisterGRPCServices(ctx context.Context) *grpc.Server {
 
 	authd.RegisterUserServiceServer(grpcServer, m.userService)
 	authd.RegisterPAMServer(grpcServer, m.pamService)
+	authd.RegisterEventsServer(grpcServer, events.NewService(ctx, m.events, m.permissionManager))
 
 	return grpcServer
 }
 
+// StopScheduledTasks stops the tasks which the manager runs periodically, waiting for the ones in progress. This is
+// used once the socket is handed over to a new daemon, which runs them instead.
+func (m Manager) StopScheduledTasks() {
+	if m.stopScheduledTasks != nil {
+		m.stopScheduledTasks()
+	}
+	if m.stopCentralPolicy != nil {
+		m.stopCentralPolicy()
+	}
+}
+
+// DatabaseRolledBack returns a channel which is closed once the database was rolled back. The daemon must stop then,
+// as it can't use the database anymore.
+func (m Manager) DatabaseRolledBack() <-chan struct{} {
+	return m.userManager.DatabaseRolledBack()
+}
+
+// ServeUserDB serves the users and groups to the systemd user database on the socket at socketPath, until the manager
+// is stopped. It does nothing if the systemd user database is not available.
+func (m *Manager) ServeUserDB(ctx context.Context, socketPath string) (err error) {
+	// The directory of the sockets is created by systemd-userdbd.
+	if _, err := os.Stat(filepath.Dir(socketPath)); errors.Is(err, os.ErrNotExist) {
+		log.Infof(ctx, "Not serving the systemd user database: %q does not exist", filepath.Dir(socketPath))
+		return nil
+	}
+
+	m.stopUserDB, err = userdb.Serve(ctx, m.userManager, socketPath)
+	return err
+}
+
+// ServeMetrics serves the metrics of the daemon in the Prometheus text format on the address, until the manager is
+// stopped. The address is either the path of a unix socket or a loopback address and port.
+func (m *Manager) ServeMetrics(ctx context.Context, address string) (err error) {
+	m.stopMetrics, err = m.metrics.Serve(ctx, address)
+	return err
+}
+
 // stop stops the underlying database.
 func (m *Manager) stop() error {
 	log.Debug(context.TODO(), "Closing gRPC manager and database")
 
+	if m.stopScheduledTasks != nil {
+		m.stopScheduledTasks()
+	}
+	if m.stopCentralPolicy != nil {
+		m.stopCentralPolicy()
+	}
+	if m.stopUserDB != nil {
+		m.stopUserDB()
+	}
+	if m.stopMetrics != nil {
+		m.stopMetrics()
+	}
+	if m.stopIDAllocation != nil {
+		m.stopIDAllocation()
+	}
+	if m.stopSCIM != nil {
+		m.stopSCIM()
+	}
+	if m.stopLDAPServer != nil {
+		m.stopLDAPServer()
+	}
+	if m.idAllocationClient != nil {
+		if err := m.idAllocationClient.Close(); err != nil {
+			log.Warningf(context.TODO(), "Could not close the connection to the ID allocation authority: %v", err)
+		}
+	}
+	if m.accountsService != nil {
+		if err := m.accountsService.Close(); err != nil {
+			log.Warningf(context.TODO(), "Could not close the connection to AccountsService: %v", err)
+		}
+	}
+	if m.signals != nil {
+		if err := m.signals.Close(); err != nil {
+			log.Warningf(context.TODO(), "Could not close the connection to the system bus: %v", err)
+		}
+	}
+
 	return m.userManager.Stop()
 }
@@ -0,0 +1,171 @@
+package pam
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/services/errmessages"
+	"github.com/canonical/authd/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// conversation holds the state of a Converse stream.
+type conversation struct {
+	stream authd.PAM_ConverseServer
+	// sendMu serializes the responses, as a gRPC stream doesn't support concurrent sends.
+	sendMu sync.Mutex
+
+	mu sync.Mutex
+	// running holds the functions cancelling the requests which are still running, by request ID.
+	running map[uint64]context.CancelFunc
+	// sessions holds the broker sessions started in this conversation and not ended yet.
+	sessions map[string]struct{}
+}
+
+// Converse handles the requests of an authentication conversation sent over a single stream.
+//
+// Each request is handled concurrently by the matching unary method and its response is sent as soon as it's
+// available. When the client closes the stream, the running requests are completed before returning. If the stream
+// breaks instead, they are cancelled. In both cases, the broker sessions which were not ended by the client are
+// ended.
+func (s Service) Converse(stream authd.PAM_ConverseServer) error {
+	c := &conversation{
+		stream:   stream,
+		running:  make(map[uint64]context.CancelFunc),
+		sessions: make(map[string]struct{}),
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		cancel()
+		s.endConversationSessions(c)
+	}()
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			cancel()
+			return err
+		}
+
+		if req.GetCancel() != nil {
+			c.cancel(req.GetId())
+			continue
+		}
+
+		reqCtx, reqCancel := context.WithCancel(ctx)
+		c.mu.Lock()
+		c.running[req.GetId()] = reqCancel
+		c.mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer c.cancel(req.GetId())
+
+			resp := s.handleConversationRequest(reqCtx, c, req)
+			if err := c.send(resp); err != nil {
+				log.Debugf(ctx, "Converse: could not send response to request %d: %v", req.GetId(), err)
+			}
+		}()
+	}
+}
+
+// handleConversationRequest runs the request with the matching unary method and returns its response.
+func (s Service) handleConversationRequest(ctx context.Context, c *conversation, req *authd.ConversationRequest) *authd.ConversationResponse {
+	resp := &authd.ConversationResponse{Id: req.GetId()}
+
+	var err error
+	switch r := req.GetRequest().(type) {
+	case *authd.ConversationRequest_AvailableBrokers:
+		var res *authd.ABResponse
+		res, err = s.AvailableBrokers(ctx, r.AvailableBrokers)
+		resp.Response = &authd.ConversationResponse_AvailableBrokers{AvailableBrokers: res}
+	case *authd.ConversationRequest_GetBroker:
+		var res *authd.GBResponse
+		res, err = s.GetBroker(ctx, r.GetBroker)
+		resp.Response = &authd.ConversationResponse_GetBroker{GetBroker: res}
+	case *authd.ConversationRequest_SelectBroker:
+		var res *authd.SBResponse
+		res, err = s.SelectBroker(ctx, r.SelectBroker)
+		if err == nil {
+			c.mu.Lock()
+			c.sessions[res.GetSessionId()] = struct{}{}
+			c.mu.Unlock()
+		}
+		resp.Response = &authd.ConversationResponse_SelectBroker{SelectBroker: res}
+	case *authd.ConversationRequest_GetAuthenticationModes:
+		var res *authd.GAMResponse
+		res, err = s.GetAuthenticationModes(ctx, r.GetAuthenticationModes)
+		resp.Response = &authd.ConversationResponse_GetAuthenticationModes{GetAuthenticationModes: res}
+	case *authd.ConversationRequest_SelectAuthenticationMode:
+		var res *authd.SAMResponse
+		res, err = s.SelectAuthenticationMode(ctx, r.SelectAuthenticationMode)
+		resp.Response = &authd.ConversationResponse_SelectAuthenticationMode{SelectAuthenticationMode: res}
+	case *authd.ConversationRequest_IsAuthenticated:
+		var res *authd.IAResponse
+		res, err = s.IsAuthenticated(ctx, r.IsAuthenticated)
+		resp.Response = &authd.ConversationResponse_IsAuthenticated{IsAuthenticated: res}
+	case *authd.ConversationRequest_EndSession:
+		var res *authd.Empty
+		res, err = s.EndSession(ctx, r.EndSession)
+		c.mu.Lock()
+		delete(c.sessions, r.EndSession.GetSessionId())
+		c.mu.Unlock()
+		resp.Response = &authd.ConversationResponse_EndSession{EndSession: res}
+	default:
+		err = status.Errorf(codes.InvalidArgument, "unsupported conversation request %T", r)
+	}
+
+	if err != nil {
+		// The errors of the unary methods are redacted by an interceptor, which is not run for stream messages.
+		st := status.Convert(errmessages.Redact(err))
+		return &authd.ConversationResponse{
+			Id:           req.GetId(),
+			ErrorCode:    uint32(st.Code()),
+			ErrorMessage: st.Message(),
+		}
+	}
+	return resp
+}
+
+// send sends the response on the stream.
+func (c *conversation) send(resp *authd.ConversationResponse) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.stream.Send(resp)
+}
+
+// cancel cancels the request with the given ID, if it's still running.
+func (c *conversation) cancel(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cancel, ok := c.running[id]; ok {
+		cancel()
+		delete(c.running, id)
+	}
+}
+
+// endConversationSessions ends the broker sessions which the client didn't end before leaving the conversation.
+func (s Service) endConversationSessions(c *conversation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for sessionID := range c.sessions {
+		log.Debugf(context.Background(), "Converse: ending session %q left open by the client", sessionID)
+		if _, err := s.EndSession(context.Background(), &authd.ESRequest{SessionId: sessionID}); err != nil {
+			log.Warningf(context.Background(), "Converse: could not end session %q: %v", sessionID, err)
+		}
+		delete(c.sessions, sessionID)
+	}
+}
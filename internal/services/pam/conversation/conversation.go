@@ -0,0 +1,277 @@
+// Package conversation provides a PAM client sending the requests of an authentication conversation over a single
+// Converse stream, instead of one unary call per request.
+package conversation
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/services/errmessages"
+	"github.com/canonical/authd/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errUnsupported is returned when the daemon doesn't implement the Converse stream.
+var errUnsupported = errors.New("the daemon does not support conversation streams")
+
+// Client is a [authd.PAMClient] sending the conversation requests over a Converse stream. The requests are sent
+// without waiting for the previous ones to complete and their responses are dispatched as soon as they are
+// received.
+//
+// If the daemon doesn't support the stream, the requests are sent as unary calls.
+// The methods which are not part of a conversation always use unary calls.
+type Client struct {
+	authd.PAMClient
+
+	mu sync.Mutex
+	// stream is the current Converse stream, nil if none is open.
+	stream      authd.PAM_ConverseClient
+	closeStream context.CancelFunc
+	unsupported bool
+	nextID      uint64
+	pending     map[uint64]chan result
+
+	// sendMu serializes the requests, as a gRPC stream doesn't support concurrent sends.
+	sendMu sync.Mutex
+}
+
+type result struct {
+	resp *authd.ConversationResponse
+	err  error
+}
+
+// New returns a new Client using conn.
+func New(conn grpc.ClientConnInterface) *Client {
+	return &Client{
+		PAMClient: authd.NewPAMClient(conn),
+		pending:   make(map[uint64]chan result),
+	}
+}
+
+// Close closes the stream, if any. Running requests return an error.
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closeStream != nil {
+		c.closeStream()
+	}
+}
+
+// AvailableBrokers returns the list of all brokers with their details.
+func (c *Client) AvailableBrokers(ctx context.Context, in *authd.Empty, opts ...grpc.CallOption) (*authd.ABResponse, error) {
+	resp, err := c.call(ctx, &authd.ConversationRequest{
+		Request: &authd.ConversationRequest_AvailableBrokers{AvailableBrokers: in},
+	})
+	if errors.Is(err, errUnsupported) {
+		return c.PAMClient.AvailableBrokers(ctx, in, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetAvailableBrokers(), nil
+}
+
+// GetBroker returns the broker of the user.
+func (c *Client) GetBroker(ctx context.Context, in *authd.GBRequest, opts ...grpc.CallOption) (*authd.GBResponse, error) {
+	resp, err := c.call(ctx, &authd.ConversationRequest{
+		Request: &authd.ConversationRequest_GetBroker{GetBroker: in},
+	})
+	if errors.Is(err, errUnsupported) {
+		return c.PAMClient.GetBroker(ctx, in, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetGetBroker(), nil
+}
+
+// SelectBroker starts a session with the broker.
+func (c *Client) SelectBroker(ctx context.Context, in *authd.SBRequest, opts ...grpc.CallOption) (*authd.SBResponse, error) {
+	resp, err := c.call(ctx, &authd.ConversationRequest{
+		Request: &authd.ConversationRequest_SelectBroker{SelectBroker: in},
+	})
+	if errors.Is(err, errUnsupported) {
+		return c.PAMClient.SelectBroker(ctx, in, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetSelectBroker(), nil
+}
+
+// GetAuthenticationModes returns the authentication modes available in the session.
+func (c *Client) GetAuthenticationModes(ctx context.Context, in *authd.GAMRequest, opts ...grpc.CallOption) (*authd.GAMResponse, error) {
+	resp, err := c.call(ctx, &authd.ConversationRequest{
+		Request: &authd.ConversationRequest_GetAuthenticationModes{GetAuthenticationModes: in},
+	})
+	if errors.Is(err, errUnsupported) {
+		return c.PAMClient.GetAuthenticationModes(ctx, in, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetGetAuthenticationModes(), nil
+}
+
+// SelectAuthenticationMode selects the authentication mode of the session.
+func (c *Client) SelectAuthenticationMode(ctx context.Context, in *authd.SAMRequest, opts ...grpc.CallOption) (*authd.SAMResponse, error) {
+	resp, err := c.call(ctx, &authd.ConversationRequest{
+		Request: &authd.ConversationRequest_SelectAuthenticationMode{SelectAuthenticationMode: in},
+	})
+	if errors.Is(err, errUnsupported) {
+		return c.PAMClient.SelectAuthenticationMode(ctx, in, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetSelectAuthenticationMode(), nil
+}
+
+// IsAuthenticated sends the authentication data of the session to the broker.
+func (c *Client) IsAuthenticated(ctx context.Context, in *authd.IARequest, opts ...grpc.CallOption) (*authd.IAResponse, error) {
+	resp, err := c.call(ctx, &authd.ConversationRequest{
+		Request: &authd.ConversationRequest_IsAuthenticated{IsAuthenticated: in},
+	})
+	if errors.Is(err, errUnsupported) {
+		return c.PAMClient.IsAuthenticated(ctx, in, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetIsAuthenticated(), nil
+}
+
+// EndSession ends the session.
+func (c *Client) EndSession(ctx context.Context, in *authd.ESRequest, opts ...grpc.CallOption) (*authd.Empty, error) {
+	resp, err := c.call(ctx, &authd.ConversationRequest{
+		Request: &authd.ConversationRequest_EndSession{EndSession: in},
+	})
+	if errors.Is(err, errUnsupported) {
+		return c.PAMClient.EndSession(ctx, in, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetEndSession(), nil
+}
+
+// call sends the request on the stream and waits for its response. If ctx is done before, the request is cancelled.
+func (c *Client) call(ctx context.Context, req *authd.ConversationRequest) (*authd.ConversationResponse, error) {
+	stream, id, ch, err := c.register()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Id = id
+	if err := c.send(stream, req); err != nil {
+		// The stream is broken, the actual error is returned by the receiving goroutine.
+		log.Debugf(ctx, "Could not send conversation request %d: %v", id, err)
+	}
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return nil, r.err
+		}
+		if r.resp.GetErrorCode() != uint32(codes.OK) {
+			//nolint:gosec // The error code is a gRPC code sent by the daemon.
+			return nil, errmessages.Format(status.Error(codes.Code(r.resp.GetErrorCode()), r.resp.GetErrorMessage()))
+		}
+		return r.resp, nil
+	case <-ctx.Done():
+		c.unregister(id)
+		if err := c.send(stream, &authd.ConversationRequest{Id: id, Request: &authd.ConversationRequest_Cancel{Cancel: &authd.Empty{}}}); err != nil {
+			log.Debugf(ctx, "Could not cancel conversation request %d: %v", id, err)
+		}
+		return nil, errmessages.Format(status.FromContextError(ctx.Err()).Err())
+	}
+}
+
+// register returns the stream, opening it if needed, and a new request ID with the channel its result is sent to.
+func (c *Client) register() (authd.PAM_ConverseClient, uint64, chan result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.unsupported {
+		return nil, 0, nil, errUnsupported
+	}
+
+	if c.stream == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err := c.PAMClient.Converse(ctx)
+		if err != nil {
+			cancel()
+			return nil, 0, nil, c.streamError(err)
+		}
+		c.stream = stream
+		c.closeStream = cancel
+		go c.receive(stream)
+	}
+
+	c.nextID++
+	ch := make(chan result, 1)
+	c.pending[c.nextID] = ch
+	return c.stream, c.nextID, ch, nil
+}
+
+func (c *Client) unregister(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, id)
+}
+
+func (c *Client) send(stream authd.PAM_ConverseClient, req *authd.ConversationRequest) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return stream.Send(req)
+}
+
+// receive dispatches the responses received on the stream until it's closed. Then, the pending requests fail with
+// the stream error and the next request opens a new stream.
+func (c *Client) receive(stream authd.PAM_ConverseClient) {
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			err = c.streamError(err)
+			if errors.Is(err, errUnsupported) {
+				c.unsupported = true
+				log.Debug(context.Background(), "The daemon does not support conversation streams, using unary calls")
+			}
+			for id, ch := range c.pending {
+				ch <- result{err: err}
+				delete(c.pending, id)
+			}
+			if c.stream == stream {
+				c.stream = nil
+				c.closeStream()
+			}
+			return
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[resp.GetId()]
+		delete(c.pending, resp.GetId())
+		c.mu.Unlock()
+		if !ok {
+			// The request was cancelled.
+			continue
+		}
+		ch <- result{resp: resp}
+	}
+}
+
+// streamError converts the errors of the stream to the errors returned to the caller.
+func (c *Client) streamError(err error) error {
+	if status.Code(err) == codes.Unimplemented {
+		return errUnsupported
+	}
+	return errmessages.Format(err)
+}
@@ -0,0 +1,96 @@
+package pam
+
+import (
+	"context"
+	"sync"
+
+	"github.com/canonical/authd/internal/brokers"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+// sessionCounts counts the open sessions of the users, so that their home is only set up when their first session
+// starts and torn down when their last session stops.
+type sessionCounts struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// start records a new session of the user. It returns true if it's the first one.
+func (s *sessionCounts) start(username string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counts[username]++
+	return s.counts[username] == 1
+}
+
+// stop records the end of a session of the user. It returns true if it was the last one.
+//
+// The sessions opened before the daemon started are not known, so the end of a session of a user without known
+// sessions is considered to be the last one: nothing must be left behind.
+func (s *sessionCounts) stop(username string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counts[username]--
+	if s.counts[username] > 0 {
+		return false
+	}
+	delete(s.counts, username)
+	return true
+}
+
+// ephemeralHome returns the home to mount or destroy for the session event of an ephemeral user, or nil if there is
+// nothing to do for this event.
+func (s Service) ephemeralHome(ctx context.Context, start bool, u types.UserEntry) *authd.EphemeralHome {
+	if !s.ephemeralUsers {
+		return nil
+	}
+
+	if start {
+		if !s.ephemeralSessions.start(u.Name) {
+			return nil
+		}
+		log.Infof(ctx, "Mounting the temporary home of ephemeral user %q", u.Name)
+		return &authd.EphemeralHome{Path: u.Dir, Uid: u.UID, Gid: u.GID}
+	}
+
+	if !s.ephemeralSessions.stop(u.Name) {
+		return nil
+	}
+	log.Infof(ctx, "Last session of ephemeral user %q stopped, removing the user", u.Name)
+	s.deleteEphemeralUser(ctx, u.Name)
+	return &authd.EphemeralHome{Path: u.Dir, Uid: u.UID, Gid: u.GID}
+}
+
+// deleteEphemeralUser removes the user from the database and from its broker, so that nothing is kept for the next
+// login, which gets a new UID. Failures are only logged, as the session is closed anyway.
+func (s Service) deleteEphemeralUser(ctx context.Context, username string) {
+	brokerID, providerID, err := s.userManager.BrokerAndProviderIDForUser(username)
+	if err != nil {
+		log.Warningf(ctx, "Could not get the broker of ephemeral user %q: %v", username, err)
+	}
+
+	// The home is a temporary file system, which is unmounted by the PAM module.
+	if err := s.userManager.DeleteUser(username, false); err != nil {
+		log.Errorf(ctx, "Could not delete ephemeral user %q: %v", username, err)
+		return
+	}
+	if err := s.userManager.ForgetDeletedUser(username); err != nil {
+		log.Warningf(ctx, "Could not forget home directory of ephemeral user %q: %v", username, err)
+	}
+
+	if brokerID == "" || brokerID == brokers.LocalBrokerName {
+		return
+	}
+	broker, err := s.brokerManager.BrokerFromID(brokerID)
+	if err != nil {
+		log.Warningf(ctx, "Could not get broker %q of ephemeral user %q: %v", brokerID, username, err)
+		return
+	}
+	if err := broker.DeleteUser(ctx, username, providerID); err != nil {
+		log.Warningf(ctx, "Could not delete ephemeral user %q from broker %q: %v", username, brokerID, err)
+	}
+}
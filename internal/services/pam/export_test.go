@@ -1,5 +1,10 @@
 package pam
 
+import (
+	"github.com/canonical/authd/internal/fingerprint"
+	"github.com/canonical/authd/internal/ldap"
+)
+
 // Re-export DefaultConfig fields for use in tests.
 var (
 	AuthFailDelayThreshold = DefaultConfig.AuthFailDelayThreshold
@@ -8,3 +13,16 @@ var (
 	// AuthFailMaxTracked allows tests to override the tracker capacity.
 	AuthFailMaxTracked = &authFailMaxTracked
 )
+
+// FingerprintMode is the ID of the authentication mode using the fingerprint.
+const FingerprintMode = fingerprintMode
+
+// SetFingerprintVerifier makes the service created with cfg use v instead of fprintd.
+func SetFingerprintVerifier(cfg *Config, v fingerprint.Verifier) {
+	cfg.fingerprintVerifier = v
+}
+
+// SetDirectory makes the service created with cfg look up the users in d instead of the LDAP server.
+func SetDirectory(cfg *Config, d ldap.Directory) {
+	cfg.directory = d
+}
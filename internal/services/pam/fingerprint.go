@@ -0,0 +1,222 @@
+package pam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/canonical/authd/internal/brokers/auth"
+	"github.com/canonical/authd/internal/brokers/layouts"
+	"github.com/canonical/authd/internal/fingerprint"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+const (
+	// fingerprintMode is the ID of the authentication mode handled by authd itself, which verifies the fingerprint of
+	// the user with fprintd instead of authenticating them with their broker.
+	fingerprintMode = "fingerprint"
+
+	// maxFingerprintAttempts is the number of fingerprints which don't match after which the user has to use one of
+	// the modes of their broker.
+	maxFingerprintAttempts = 3
+)
+
+// fingerprintSession is the state of the fingerprint authentication of a session.
+type fingerprintSession struct {
+	// username is the name of the user, as stored in the database.
+	username string
+	// offered is set once the fingerprint mode was returned to the client, which can then select it.
+	offered  bool
+	selected bool
+	attempts int
+	// unavailable is set once the fingerprint can't be used anymore in the session, e.g. because the broker
+	// continues with another step of the authentication.
+	unavailable bool
+}
+
+// fingerprintSessions tracks the sessions in which the fingerprint can be used. Only the users who already logged in
+// with the broker of the session can use it, so that their first login always goes through the broker.
+type fingerprintSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*fingerprintSession
+}
+
+func (s *fingerprintSessions) start(sessionID, username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionID] = &fingerprintSession{username: username}
+}
+
+// setOffered records whether the fingerprint mode is in the authentication modes last returned to the client.
+func (s *fingerprintSessions) setOffered(sessionID string, offered bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if fs, ok := s.sessions[sessionID]; ok {
+		fs.offered = offered
+	}
+}
+
+// available returns the name of the user of the session if the fingerprint can be used in it.
+func (s *fingerprintSessions) available(sessionID string) (username string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fs, ok := s.sessions[sessionID]
+	if !ok || fs.unavailable {
+		return "", false
+	}
+	return fs.username, true
+}
+
+// selectMode records whether the fingerprint mode is the selected one. It returns false if the fingerprint can't be
+// used in the session or wasn't offered to the client.
+func (s *fingerprintSessions) selectMode(sessionID string, selected bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fs, ok := s.sessions[sessionID]
+	if !ok || fs.unavailable || !fs.offered {
+		return !selected
+	}
+	fs.selected = selected
+	return true
+}
+
+func (s *fingerprintSessions) isSelected(sessionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fs, ok := s.sessions[sessionID]
+	return ok && fs.selected && !fs.unavailable
+}
+
+// failed records a fingerprint which didn't match. It returns true if the maximum number of attempts is reached.
+func (s *fingerprintSessions) failed(sessionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fs, ok := s.sessions[sessionID]
+	if !ok {
+		return true
+	}
+	fs.attempts++
+	return fs.attempts >= maxFingerprintAttempts
+}
+
+// disable prevents the fingerprint from being used for the rest of the session.
+func (s *fingerprintSessions) disable(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if fs, ok := s.sessions[sessionID]; ok {
+		fs.unavailable = true
+		fs.selected = false
+	}
+}
+
+func (s *fingerprintSessions) remove(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+}
+
+// startFingerprintSession makes the fingerprint available in a login session of a user who already logged in with
+// the broker of the session.
+func (s Service) startFingerprintSession(sessionID, username, mode string, knownUser bool) {
+	if s.fingerprint == nil || mode != auth.SessionModeLogin || !knownUser {
+		return
+	}
+	s.fingerprintSessions.start(sessionID, username)
+}
+
+// fingerprintAuthMode returns the fingerprint authentication mode if the UI supports it and the user of the session
+// enrolled a finger, or nil otherwise.
+func (s Service) fingerprintAuthMode(ctx context.Context, sessionID string, supportedLayouts []map[string]string) *authd.GAMResponse_AuthenticationMode {
+	username, ok := s.fingerprintSessions.available(sessionID)
+	if !ok {
+		return nil
+	}
+
+	// The user waits for the fingerprint to be scanned in a form without entry.
+	if !slices.ContainsFunc(supportedLayouts, func(l map[string]string) bool {
+		return l[layouts.Type] == layouts.Form && strings.Contains(l[layouts.Wait], layouts.True)
+	}) {
+		return nil
+	}
+
+	enrolled, err := s.fingerprint.Enrolled(ctx, username)
+	if err != nil {
+		log.Warningf(ctx, "Could not check whether user %q enrolled a fingerprint: %v", username, err)
+		return nil
+	}
+	if !enrolled {
+		return nil
+	}
+
+	return &authd.GAMResponse_AuthenticationMode{Id: fingerprintMode, Label: "Fingerprint"}
+}
+
+// fingerprintUILayout returns the layout displayed while waiting for the fingerprint.
+func fingerprintUILayout() map[string]string {
+	return map[string]string{
+		layouts.Type:  layouts.Form,
+		layouts.Label: "Scan your finger on the fingerprint reader",
+		layouts.Wait:  layouts.True,
+	}
+}
+
+// fingerprintAuthenticate verifies the fingerprint of the user of the session. It returns the access and the data
+// in the same format as the brokers, with the information stored for the user when access is granted.
+func (s Service) fingerprintAuthenticate(ctx context.Context, sessionID string) (access, data string, err error) {
+	username, ok := s.fingerprintSessions.available(sessionID)
+	if !ok {
+		return "", "", errors.New("fingerprint authentication is not available in this session")
+	}
+
+	err = s.fingerprint.Verify(ctx, username)
+	if ctx.Err() != nil {
+		return auth.Cancelled, "{}", nil
+	}
+	if errors.Is(err, fingerprint.ErrNoMatch) {
+		log.Noticef(ctx, "%s: Fingerprint of user %q did not match", sessionID, username)
+		if s.fingerprintSessions.failed(sessionID) {
+			s.fingerprintSessions.disable(sessionID)
+			return auth.Next, messageData(ctx, "Fingerprint not recognized. Please use another authentication method."), nil
+		}
+		return auth.Retry, messageData(ctx, "Fingerprint not recognized, please try again."), nil
+	}
+	if err != nil {
+		log.Errorf(ctx, "%s: Could not verify the fingerprint of user %q: %v", sessionID, username, err)
+		s.fingerprintSessions.disable(sessionID)
+		return auth.Next, messageData(ctx, "Fingerprint verification failed. Please use another authentication method."), nil
+	}
+
+	uInfo, err := s.userManager.CachedUserInfo(username)
+	if err != nil {
+		return "", "", err
+	}
+	encoded, err := json.Marshal(struct {
+		UserInfo types.UserInfo `json:"userinfo"`
+		Offline  bool           `json:"offline"`
+	}{UserInfo: uInfo, Offline: true})
+	if err != nil {
+		return "", "", err
+	}
+	return auth.Granted, string(encoded), nil
+}
+
+// messageData returns the message in the JSON format expected by the PAM client.
+func messageData(ctx context.Context, msg string) string {
+	encoded, err := json.Marshal(map[string]string{"message": msg})
+	if err != nil {
+		log.Warningf(ctx, "Could not marshal message %q: %v", msg, err)
+		return "{}"
+	}
+	return string(encoded)
+}
@@ -0,0 +1,38 @@
+package pam
+
+import (
+	"context"
+	"errors"
+
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+// ValidateFscryptHomes returns an error if the homes encrypted with fscrypt can't be used with the rest of the
+// configuration.
+func (c Config) ValidateFscryptHomes() error {
+	if c.EphemeralUsers && c.FscryptHomes {
+		return errors.New("fscrypt_homes can't be used with ephemeral_users, whose homes are temporary")
+	}
+	return nil
+}
+
+// fscryptHome returns the home to create encrypted or to unlock when a session of the user starts, or nil if the
+// home of the user is not encrypted by authd.
+func (s Service) fscryptHome(ctx context.Context, u types.UserEntry, brokerID string) *authd.FscryptHome {
+	if !s.fscryptHomes {
+		return nil
+	}
+
+	// The network homes are not created locally and the homes created with systemd-homed are encrypted by it.
+	if s.hasNetworkHome(ctx, u.Name, brokerID) {
+		return nil
+	}
+	if secret, err := s.userManager.HomedSecret(u.Name); err != nil || secret != "" {
+		return nil
+	}
+
+	log.Debugf(ctx, "Home %q of user %q is encrypted with fscrypt", u.Dir, u.Name)
+	return &authd.FscryptHome{Path: u.Dir, UserName: u.Name, Uid: u.UID, Gid: u.GID}
+}
@@ -0,0 +1,36 @@
+package pam
+
+import (
+	"context"
+
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+// homedHome returns the home to activate or deactivate for the session event of a user whose home was created with
+// systemd-homed, or nil if there is nothing to do for this event.
+func (s Service) homedHome(ctx context.Context, start bool, u types.UserEntry) *authd.HomedHome {
+	secret, err := s.userManager.HomedSecret(u.Name)
+	if err != nil {
+		log.Warningf(ctx, "Could not get the systemd-homed home of user %q: %v", u.Name, err)
+		return nil
+	}
+	if secret == "" {
+		return nil
+	}
+
+	if start {
+		if !s.homedSessions.start(u.Name) {
+			return nil
+		}
+		log.Infof(ctx, "Activating the systemd-homed home of user %q", u.Name)
+		return &authd.HomedHome{UserName: u.Name, Secret: secret}
+	}
+
+	if !s.homedSessions.stop(u.Name) {
+		return nil
+	}
+	log.Infof(ctx, "Last session of user %q stopped, deactivating their systemd-homed home", u.Name)
+	return &authd.HomedHome{UserName: u.Name}
+}
@@ -0,0 +1,112 @@
+package pam
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/log"
+)
+
+// authModeSession is the state of the first step of the authentication of a login session.
+type authModeSession struct {
+	// mode is the authentication mode the user selected for the first step.
+	mode string
+	// nextStep is set once the broker continues with another step of the authentication.
+	nextStep bool
+}
+
+// authModeSessions tracks the authentication mode the users select for the first step of their login, which is stored
+// once they are granted access and comes first on their next login, so that it's their default mode.
+type authModeSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*authModeSession
+}
+
+func (s *authModeSessions) start(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionID] = &authModeSession{}
+}
+
+// firstStep returns true if the session is a login session which didn't go past the first step of the authentication.
+func (s *authModeSessions) firstStep(sessionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	as, ok := s.sessions[sessionID]
+	return ok && !as.nextStep
+}
+
+// selectMode records the authentication mode selected by the user, unless the session went past the first step.
+func (s *authModeSessions) selectMode(sessionID, mode string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if as, ok := s.sessions[sessionID]; ok && !as.nextStep {
+		as.mode = mode
+	}
+}
+
+// next records that the broker continues with another step of the authentication.
+func (s *authModeSessions) next(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if as, ok := s.sessions[sessionID]; ok {
+		as.nextStep = true
+	}
+}
+
+// firstMode returns the authentication mode selected for the first step of the session, if any.
+func (s *authModeSessions) firstMode(sessionID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if as, ok := s.sessions[sessionID]; ok {
+		return as.mode
+	}
+	return ""
+}
+
+func (s *authModeSessions) remove(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+}
+
+// preselectLastAuthMode moves the authentication mode with which the user last logged in with the broker first, so
+// that it's their default mode, if it's still available.
+func (s Service) preselectLastAuthMode(ctx context.Context, sessionID, brokerID string, authModes []*authd.GAMResponse_AuthenticationMode) []*authd.GAMResponse_AuthenticationMode {
+	if !s.authModeSessions.firstStep(sessionID) {
+		return authModes
+	}
+
+	username := s.userManager.NormalizeName(s.brokerManager.UsernameFromSessionID(sessionID))
+	lastMode, err := s.userManager.LastAuthMode(username, brokerID)
+	if err != nil {
+		log.Warningf(ctx, "Could not get the last authentication mode of user %q: %v", username, err)
+		return authModes
+	}
+
+	i := slices.IndexFunc(authModes, func(a *authd.GAMResponse_AuthenticationMode) bool { return a.GetId() == lastMode })
+	if i <= 0 {
+		return authModes
+	}
+	lastAuthMode := authModes[i]
+	return append([]*authd.GAMResponse_AuthenticationMode{lastAuthMode}, slices.Delete(authModes, i, i+1)...)
+}
+
+// storeLastAuthMode stores the authentication mode the user selected for the first step of the session once they are
+// granted access.
+func (s Service) storeLastAuthMode(ctx context.Context, sessionID, username, brokerID string) {
+	mode := s.authModeSessions.firstMode(sessionID)
+	if mode == "" {
+		return
+	}
+	if err := s.userManager.SetLastAuthMode(username, brokerID, mode); err != nil {
+		// The user can still log in, they will just select their mode again next time.
+		log.Warningf(ctx, "Could not store the authentication mode of user %q: %v", username, err)
+	}
+}
@@ -0,0 +1,50 @@
+package pam
+
+import (
+	"context"
+
+	"github.com/canonical/authd/internal/brokers"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+// localHome returns the home to create when a session of the user starts, or nil if the broker of the user doesn't
+// set how the homes are created, in which case pam_mkhomedir creates it. The quota of the home depends on the groups of
+// the user.
+func (s Service) localHome(ctx context.Context, u types.UserEntry, brokerID string, groups []string) *authd.LocalHome {
+	// These homes are not created by pam_mkhomedir either.
+	if s.ephemeralUsers || s.fscryptHomes || s.hasNetworkHome(ctx, u.Name, brokerID) {
+		return nil
+	}
+	if secret, err := s.userManager.HomedSecret(u.Name); err != nil || secret != "" {
+		return nil
+	}
+
+	broker, err := s.brokerManager.BrokerFromID(brokerID)
+	if err != nil {
+		log.Debugf(ctx, "Could not get broker %q of user %q, leaving the creation of their home to pam_mkhomedir: %v", brokerID, u.Name, err)
+		return nil
+	}
+	settings := broker.UserDefaults.Home
+	if !settings.CreatesHomes() {
+		return nil
+	}
+
+	homeGID := u.GID
+	if settings.GID != nil {
+		homeGID = *settings.GID
+	}
+	return &authd.LocalHome{
+		Path:    u.Dir,
+		Uid:     u.UID,
+		Gid:     u.GID,
+		HomeGid: homeGID,
+		Mode:    uint32(settings.Mode),
+		Skel:    settings.Skel,
+		Subdirs: settings.Subdirs,
+		// The quota is only set when the home is created, so that the administrator can change it later.
+		Quota:        settings.QuotaFor(groups),
+		ProjectQuota: settings.QuotaType == brokers.ProjectQuota,
+	}
+}
@@ -0,0 +1,179 @@
+package pam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/canonical/authd/internal/brokers/auth"
+	"github.com/canonical/authd/internal/brokers/layouts"
+	"github.com/canonical/authd/internal/brokers/layouts/entries"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+// maxMachineTokenAttempts is the number of invalid machine tokens after which the authentication is denied.
+const maxMachineTokenAttempts = 3
+
+// machineTokenSession is the state of the machine token authentication of a session.
+type machineTokenSession struct {
+	// username is the name of the user, as stored in the database.
+	username string
+	// offered is set once the machine token mode was returned to the client, which can then select it.
+	offered  bool
+	selected bool
+	attempts int
+}
+
+// machineTokenSessions tracks the sessions in which a machine token can be used. Only the users who already logged
+// in with the broker of the session can use one, as the token grants access with the information stored on that
+// login.
+type machineTokenSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*machineTokenSession
+}
+
+func (s *machineTokenSessions) start(sessionID, username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionID] = &machineTokenSession{username: username}
+}
+
+// setOffered records whether the machine token mode is in the authentication modes last returned to the client.
+func (s *machineTokenSessions) setOffered(sessionID string, offered bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ms, ok := s.sessions[sessionID]; ok {
+		ms.offered = offered
+	}
+}
+
+// username returns the name of the user of the session if a machine token can be used in it.
+func (s *machineTokenSessions) username(sessionID string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ms, ok := s.sessions[sessionID]
+	if !ok {
+		return "", false
+	}
+	return ms.username, true
+}
+
+// selectMode records whether the machine token mode is the selected one. It returns false if it wasn't offered to
+// the client.
+func (s *machineTokenSessions) selectMode(sessionID string, selected bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ms, ok := s.sessions[sessionID]
+	if !ok || !ms.offered {
+		return !selected
+	}
+	ms.selected = selected
+	return true
+}
+
+func (s *machineTokenSessions) isSelected(sessionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ms, ok := s.sessions[sessionID]
+	return ok && ms.selected
+}
+
+// failed records an invalid token. It returns true if the maximum number of attempts is reached.
+func (s *machineTokenSessions) failed(sessionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ms, ok := s.sessions[sessionID]
+	if !ok {
+		return true
+	}
+	ms.attempts++
+	return ms.attempts >= maxMachineTokenAttempts
+}
+
+func (s *machineTokenSessions) remove(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+}
+
+// startMachineTokenSession makes the machine token available in a login session of a user who already logged in
+// with the broker of the session.
+func (s Service) startMachineTokenSession(sessionID, username, mode string, knownUser bool) {
+	if mode != auth.SessionModeLogin || !knownUser {
+		return
+	}
+	s.machineTokenSessions.start(sessionID, username)
+}
+
+// machineTokenAuthMode returns the machine token authentication mode if the user of the session has a machine token
+// which didn't expire, or nil otherwise.
+func (s Service) machineTokenAuthMode(ctx context.Context, sessionID string) *authd.GAMResponse_AuthenticationMode {
+	username, ok := s.machineTokenSessions.username(sessionID)
+	if !ok {
+		return nil
+	}
+
+	enrolled, err := s.userManager.HasMachineToken(username)
+	if err != nil {
+		log.Warningf(ctx, "Could not check whether user %q has a machine token: %v", username, err)
+		return nil
+	}
+	if !enrolled {
+		return nil
+	}
+
+	return &authd.GAMResponse_AuthenticationMode{Id: auth.MachineTokenMode, Label: "Machine token"}
+}
+
+// machineTokenUILayout returns the layout in which the machine token is entered.
+func machineTokenUILayout() map[string]string {
+	return map[string]string{
+		layouts.Type:  layouts.Form,
+		layouts.Label: "Enter the machine token",
+		layouts.Entry: entries.CharsPassword,
+	}
+}
+
+// machineTokenAuthenticate verifies the machine token sent for the user of the session. It returns the access and
+// the data in the same format as the brokers, with the information stored for the user when access is granted.
+// The token is verified against its hash stored by authd, without contacting the broker.
+func (s Service) machineTokenAuthenticate(ctx context.Context, sessionID, token string) (access, data string, err error) {
+	username, ok := s.machineTokenSessions.username(sessionID)
+	if !ok {
+		return "", "", errors.New("machine token authentication is not available in this session")
+	}
+
+	valid, err := s.userManager.VerifyMachineToken(username, token)
+	if err != nil {
+		return "", "", err
+	}
+	if !valid {
+		log.Noticef(ctx, "%s: Invalid machine token for user %q", sessionID, username)
+		if s.machineTokenSessions.failed(sessionID) {
+			return auth.Denied, messageData(ctx, "Invalid machine token."), nil
+		}
+		return auth.Retry, messageData(ctx, "Invalid machine token, please try again."), nil
+	}
+
+	uInfo, err := s.userManager.CachedUserInfo(username)
+	if err != nil {
+		return "", "", err
+	}
+	// The login is not an offline login of the broker, which isn't involved, so the offline limits of the login policy
+	// don't apply to it.
+	encoded, err := json.Marshal(struct {
+		UserInfo types.UserInfo `json:"userinfo"`
+	}{UserInfo: uInfo})
+	if err != nil {
+		return "", "", err
+	}
+	return auth.Granted, string(encoded), nil
+}
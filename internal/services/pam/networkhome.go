@@ -0,0 +1,85 @@
+package pam
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+	"github.com/coreos/go-systemd/v22/unit"
+)
+
+// NetworkHomeConfig declares the users whose home is provided by a network file system, such as NFS. Their home is
+// not created locally: it must be mounted when their session starts and owned by their UID.
+type NetworkHomeConfig struct {
+	// Brokers are the names of the brokers whose users have a network home.
+	Brokers []string `mapstructure:"network_home_brokers" yaml:"network_home_brokers,omitempty"`
+	// Users are the names of the users who have a network home, whatever their broker.
+	Users []string `mapstructure:"network_home_users" yaml:"network_home_users,omitempty"`
+	// MountUnit starts the systemd mount unit of the home before checking it, for the homes which are mounted on
+	// demand by a mount unit rather than by an automount.
+	MountUnit bool `mapstructure:"network_home_mount_unit" yaml:"network_home_mount_unit,omitempty"`
+}
+
+// Enabled returns true if some users have a network home.
+func (c NetworkHomeConfig) Enabled() bool {
+	return len(c.Brokers) > 0 || len(c.Users) > 0
+}
+
+// Validate returns an error if the configuration is invalid.
+func (c NetworkHomeConfig) Validate() error {
+	if slices.Contains(c.Brokers, "") {
+		return errors.New("invalid network_home_brokers: empty broker name")
+	}
+	if slices.Contains(c.Users, "") {
+		return errors.New("invalid network_home_users: empty user name")
+	}
+	return nil
+}
+
+// ValidateNetworkHome returns an error if the network homes can't be used with the rest of the configuration.
+func (c Config) ValidateNetworkHome() error {
+	if err := c.NetworkHome.Validate(); err != nil {
+		return err
+	}
+	if c.EphemeralUsers && c.NetworkHome.Enabled() {
+		return errors.New("network homes can't be used with ephemeral_users, whose homes are temporary")
+	}
+	return nil
+}
+
+// networkHome returns the home to mount and check when a session of the user starts, or nil if the home of the user
+// is local.
+func (s Service) networkHome(ctx context.Context, u types.UserEntry, brokerID string) *authd.NetworkHome {
+	if !s.hasNetworkHome(ctx, u.Name, brokerID) {
+		return nil
+	}
+
+	home := &authd.NetworkHome{Path: u.Dir, Uid: u.UID}
+	if s.networkHomeConfig.MountUnit {
+		home.MountUnit = unit.UnitNamePathEscape(u.Dir) + ".mount"
+	}
+	log.Debugf(ctx, "Home %q of user %q is provided by the network", u.Dir, u.Name)
+	return home
+}
+
+func (s Service) hasNetworkHome(ctx context.Context, username, brokerID string) bool {
+	// authd user names are lowercase.
+	if slices.ContainsFunc(s.networkHomeConfig.Users, func(name string) bool { return strings.ToLower(name) == username }) {
+		return true
+	}
+	if len(s.networkHomeConfig.Brokers) == 0 {
+		return false
+	}
+
+	broker, err := s.brokerManager.BrokerFromID(brokerID)
+	if err != nil {
+		// The home of the user is unknown, creating it locally would hide the network one.
+		log.Warningf(ctx, "Could not get broker %q of user %q, considering their home provided by the network: %v", brokerID, username, err)
+		return true
+	}
+	return slices.Contains(s.networkHomeConfig.Brokers, broker.Name)
+}
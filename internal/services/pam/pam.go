@@ -6,17 +6,32 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"math"
 	"os/user"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/canonical/authd/internal/accountsservice"
+	"github.com/canonical/authd/internal/adminrole"
+	"github.com/canonical/authd/internal/alerts"
 	"github.com/canonical/authd/internal/brokers"
 	"github.com/canonical/authd/internal/brokers/auth"
 	"github.com/canonical/authd/internal/brokers/layouts"
+	"github.com/canonical/authd/internal/credentialhooks"
 	"github.com/canonical/authd/internal/decorate"
+	"github.com/canonical/authd/internal/emergencyaccess"
+	"github.com/canonical/authd/internal/faillock"
+	"github.com/canonical/authd/internal/fingerprint"
+	"github.com/canonical/authd/internal/ldap"
+	"github.com/canonical/authd/internal/loginpolicy"
+	"github.com/canonical/authd/internal/metrics"
 	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/services/events"
+	"github.com/canonical/authd/internal/services/permissions"
+	"github.com/canonical/authd/internal/sessionhooks"
+	"github.com/canonical/authd/internal/sshcert"
 	"github.com/canonical/authd/internal/users"
 	"github.com/canonical/authd/internal/users/types"
 	"github.com/canonical/authd/log"
@@ -41,6 +56,47 @@ type Config struct {
 	// AuthFailResetWindow is the duration after the last failure before the failure count
 	// is automatically reset, to avoid penalizing users indefinitely.
 	AuthFailResetWindow time.Duration `mapstructure:"auth_fail_reset_window" yaml:"auth_fail_reset_window"`
+	// Faillock locks the users out after too many consecutive authentication failures.
+	Faillock faillock.Config `mapstructure:",squash" yaml:",inline"`
+	// Alerts configures the alerts sent on repeated authentication failures.
+	Alerts alerts.Config `mapstructure:",squash" yaml:",inline"`
+	// SessionHooks configures the hooks run when the sessions of authd users start and stop.
+	SessionHooks sessionhooks.Config `mapstructure:",squash" yaml:",inline"`
+	// CredentialHooks configures the hooks acquiring credentials, such as Kerberos tickets, when the sessions of authd
+	// users start.
+	CredentialHooks credentialhooks.Config `mapstructure:",squash" yaml:",inline"`
+	// LoginPolicy restricts the users who can log in on the machine.
+	LoginPolicy loginpolicy.Config `mapstructure:",squash" yaml:",inline"`
+	// CentralPolicy fetches the login policy from a central server, overriding LoginPolicy.
+	CentralPolicy loginpolicy.CentralConfig `mapstructure:",squash" yaml:",inline"`
+	// AdminRole grants administrator rights to the members of some groups of the identity provider.
+	AdminRole adminrole.Config `mapstructure:",squash" yaml:",inline"`
+	// SSHCert issues short-lived SSH certificates to the users once they authenticated with their identity provider.
+	SSHCert sshcert.Config `mapstructure:",squash" yaml:",inline"`
+	// EphemeralUsers makes the users throwaway: their home is a temporary file system destroyed when their last
+	// session stops, and they are then removed, so that they get a new UID on their next login.
+	EphemeralUsers bool `mapstructure:"ephemeral_users" yaml:"ephemeral_users"`
+	// NetworkHome declares the users whose home is mounted from the network rather than created locally.
+	NetworkHome NetworkHomeConfig `mapstructure:",squash" yaml:",inline"`
+	// FscryptHomes encrypts the homes created on the first login of the users with fscrypt, with their local password,
+	// and unlocks them when their sessions start.
+	FscryptHomes bool `mapstructure:"fscrypt_homes" yaml:"fscrypt_homes"`
+	// Fingerprint offers the users who already logged in to authenticate with a fingerprint enrolled in fprintd,
+	// along with the authentication modes of their broker.
+	Fingerprint bool `mapstructure:"fingerprint" yaml:"fingerprint"`
+	// LDAP looks up the POSIX attributes of the users in an LDAP directory, which take precedence over the ones of
+	// the broker.
+	LDAP ldap.Config `mapstructure:",squash" yaml:",inline"`
+	// EmergencyAccess keeps the administrators able to log in during an outage of the brokers.
+	EmergencyAccess emergencyaccess.Config `mapstructure:",squash" yaml:",inline"`
+	// SkipBrokerSelection selects the only broker available, besides the local one, for the users who never logged in
+	// on the machine, instead of letting them choose it.
+	SkipBrokerSelection bool `mapstructure:"skip_broker_selection" yaml:"skip_broker_selection"`
+
+	// fingerprintVerifier replaces fprintd in tests.
+	fingerprintVerifier fingerprint.Verifier
+	// directory replaces the LDAP server in tests.
+	directory ldap.Directory
 }
 
 // DefaultConfig is the default configuration for the PAM service.
@@ -48,6 +104,12 @@ var DefaultConfig = Config{
 	AuthFailDelayThreshold: 3,
 	AuthFailDelay:          2 * time.Second,
 	AuthFailResetWindow:    15 * time.Minute,
+	Faillock:               faillock.DefaultConfig,
+	Alerts:                 alerts.DefaultConfig,
+	AdminRole:              adminrole.DefaultConfig,
+	SSHCert:                sshcert.DefaultConfig,
+	CentralPolicy:          loginpolicy.DefaultCentralConfig,
+	LDAP:                   ldap.DefaultConfig,
 }
 
 // authFailEntry holds the failure count and the time of the most recent failure for one user.
@@ -107,25 +169,192 @@ func (t *authFailTracker) recordSuccess(username string) {
 	delete(t.entries, username)
 }
 
+// sessionServiceMaxAge is how long the PAM service of a session is remembered. The sessions whose client went away
+// without ending them are forgotten after it.
+const sessionServiceMaxAge = 24 * time.Hour
+
+// sessionService is the PAM service which started a session, and when it started.
+type sessionService struct {
+	service string
+	started time.Time
+}
+
+// sessionServices maps session IDs to the PAM service which started them.
+type sessionServices struct {
+	mu       sync.RWMutex
+	services map[string]sessionService
+}
+
+func (s *sessionServices) set(sessionID, service string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The sessions abandoned by their client are never ended, so they are pruned when a new one starts.
+	for id, e := range s.services {
+		if time.Since(e.started) >= sessionServiceMaxAge {
+			delete(s.services, id)
+		}
+	}
+	s.services[sessionID] = sessionService{service: service, started: time.Now()}
+}
+
+func (s *sessionServices) get(sessionID string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.services[sessionID].service
+}
+
+func (s *sessionServices) remove(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.services, sessionID)
+}
+
 // Service is the implementation of the PAM module service.
 type Service struct {
-	userManager    *users.Manager
-	brokerManager  *brokers.Manager
-	failedAuths    *authFailTracker
-	authFailConfig Config
+	userManager       *users.Manager
+	brokerManager     *brokers.Manager
+	permissionManager *permissions.Manager
+	failedAuths       *authFailTracker
+	authFailConfig    Config
+	faillock          *faillock.Tracker
+	sessionServices   *sessionServices
+	alerts            *alerts.Notifier
+	sessionHooks      *sessionhooks.Runner
+	credentialHooks   *credentialhooks.Runner
+	loginPolicy       *loginpolicy.Policy
+	adminRole         *adminrole.Role
+	sshCerts          *sshcert.Issuer
+	ephemeralUsers    bool
+	ephemeralSessions *sessionCounts
+	homedSessions     *sessionCounts
+	networkHomeConfig NetworkHomeConfig
+	fscryptHomes      bool
+	emergencyAccess   emergencyaccess.Config
+	// fingerprint is nil if the users can't authenticate with their fingerprint.
+	fingerprint          fingerprint.Verifier
+	fingerprintSessions  *fingerprintSessions
+	totpSessions         *totpSessions
+	machineTokenSessions *machineTokenSessions
+	authModeSessions     *authModeSessions
+	skipBrokerSelection  bool
+	// directory is nil if the attributes of the users are not looked up in an LDAP directory.
+	directory ldap.Directory
+	// accountsService is nil if the users are not published to AccountsService.
+	accountsService *accountsservice.Client
+	events          *events.Broadcaster
+	metrics         *metrics.Metrics
 
 	authd.UnimplementedPAMServer
 }
 
+type options struct {
+	loginPolicy     *loginpolicy.Policy
+	faillock        *faillock.Tracker
+	accountsService *accountsservice.Client
+	events          *events.Broadcaster
+	metrics         *metrics.Metrics
+}
+
+// Option represents an optional function to override Service default values.
+type Option func(*options)
+
+// WithLoginPolicy sets the login policy of the machine, which may be changed by the central policy, instead of the
+// one of the configuration.
+func WithLoginPolicy(p *loginpolicy.Policy) Option {
+	return func(o *options) {
+		o.loginPolicy = p
+	}
+}
+
+// WithFaillock sets the tracker locking out the users, which is shared with the service unlocking them, instead of
+// one created from the configuration.
+func WithFaillock(t *faillock.Tracker) Option {
+	return func(o *options) {
+		o.faillock = t
+	}
+}
+
+// WithAccountsService sets the client publishing the users to AccountsService when they authenticate with their
+// broker, so that the greeter lists them with their picture.
+func WithAccountsService(c *accountsservice.Client) Option {
+	return func(o *options) {
+		o.accountsService = c
+	}
+}
+
+// WithEvents sets the broadcaster the logins are published to.
+func WithEvents(b *events.Broadcaster) Option {
+	return func(o *options) {
+		o.events = b
+	}
+}
+
+// WithMetrics sets the metrics the authentications are recorded to.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
 // NewService returns a new PAM GRPC service.
-func NewService(ctx context.Context, userManager *users.Manager, brokerManager *brokers.Manager, cfg Config) Service {
+func NewService(ctx context.Context, userManager *users.Manager, brokerManager *brokers.Manager, permissionManager *permissions.Manager, cfg Config, args ...Option) Service {
 	log.Debug(ctx, "Building new gRPC PAM service")
 
+	opts := options{
+		loginPolicy: loginpolicy.NewPolicy(cfg.LoginPolicy, nil),
+		faillock:    faillock.New(cfg.Faillock),
+	}
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	var fingerprintVerifier fingerprint.Verifier
+	if cfg.Fingerprint {
+		fingerprintVerifier = cfg.fingerprintVerifier
+		if fingerprintVerifier == nil {
+			fingerprintVerifier = fingerprint.NewFprintD()
+		}
+	}
+
+	var directory ldap.Directory
+	if cfg.LDAP.Enabled() {
+		directory = cfg.directory
+		if directory == nil {
+			directory = ldap.NewClient(cfg.LDAP)
+		}
+	}
+
 	return Service{
-		userManager:    userManager,
-		brokerManager:  brokerManager,
-		failedAuths:    newAuthFailTracker(cfg),
-		authFailConfig: cfg,
+		userManager:       userManager,
+		brokerManager:     brokerManager,
+		permissionManager: permissionManager,
+		failedAuths:       newAuthFailTracker(cfg),
+		authFailConfig:    cfg,
+		faillock:          opts.faillock,
+		sessionServices:   &sessionServices{services: make(map[string]sessionService)},
+		alerts:            alerts.New(cfg.Alerts),
+		sessionHooks:      sessionhooks.New(cfg.SessionHooks),
+		credentialHooks:   credentialhooks.New(cfg.CredentialHooks),
+		loginPolicy:       opts.loginPolicy,
+		adminRole:         adminrole.New(cfg.AdminRole),
+		sshCerts:          sshcert.New(cfg.SSHCert),
+		ephemeralUsers:    cfg.EphemeralUsers,
+		ephemeralSessions: &sessionCounts{counts: make(map[string]int)},
+		homedSessions:     &sessionCounts{counts: make(map[string]int)},
+		networkHomeConfig: cfg.NetworkHome,
+		fscryptHomes:      cfg.FscryptHomes,
+		emergencyAccess:   cfg.EmergencyAccess,
+
+		fingerprint:          fingerprintVerifier,
+		fingerprintSessions:  &fingerprintSessions{sessions: make(map[string]*fingerprintSession)},
+		totpSessions:         &totpSessions{sessions: make(map[string]*totpSession)},
+		machineTokenSessions: &machineTokenSessions{sessions: make(map[string]*machineTokenSession)},
+		authModeSessions:     &authModeSessions{sessions: make(map[string]*authModeSession)},
+		skipBrokerSelection:  cfg.SkipBrokerSelection,
+		directory:            directory,
+		accountsService:      opts.accountsService,
+		events:               opts.events,
+		metrics:              opts.metrics,
 	}
 }
 
@@ -147,8 +376,13 @@ func (s Service) AvailableBrokers(ctx context.Context, _ *authd.Empty) (*authd.A
 // GetBroker returns the previous broker set for a given user, if any.
 // If the user is not in our cache/database, it will try to check if it’s on the system, and return then "local".
 func (s Service) GetBroker(ctx context.Context, req *authd.GBRequest) (*authd.GBResponse, error) {
-	// authd usernames are lowercase
-	username := strings.ToLower(req.GetUsername())
+	username := s.userManager.NormalizeName(req.GetUsername())
+
+	// The emergency users must be able to log in when the brokers are down, so they always use their local password.
+	if s.emergencyAccess.IsEmergencyUser(req.GetUsername()) {
+		log.Debugf(ctx, "GetBroker: User %q is an emergency user, selecting local broker", req.GetUsername())
+		return &authd.GBResponse{Broker: brokers.LocalBrokerName}, nil
+	}
 
 	// Use in memory cache first
 	if b := s.brokerManager.BrokerForUser(username); b != nil {
@@ -167,9 +401,18 @@ func (s Service) GetBroker(ctx context.Context, req *authd.GBRequest) (*authd.GB
 			return &authd.GBResponse{Broker: brokers.LocalBrokerName}, nil
 		}
 
-		// User not accessible through NSS, first time login or no valid user. Anyway, no broker selected.
+		// User not accessible through NSS, first time login or no valid user. Anyway, no broker selected, except the
+		// default one of the service, if any.
 		if _, err := user.Lookup(username); err != nil {
 			log.Debugf(ctx, "GetBroker: User %q not found", username)
+			if b := s.brokerManager.DefaultBroker(req.GetService()); b != nil {
+				log.Debugf(ctx, "GetBroker: Selecting default broker %q for user %q", b.Name, username)
+				return &authd.GBResponse{Broker: b.ID}, nil
+			}
+			if b := s.onlyBroker(); b != nil {
+				log.Debugf(ctx, "GetBroker: Selecting broker %q for user %q, as it's the only one", b.Name, username)
+				return &authd.GBResponse{Broker: b.ID}, nil
+			}
 			return &authd.GBResponse{}, nil
 		}
 
@@ -210,10 +453,30 @@ func (s Service) GetBroker(ctx context.Context, req *authd.GBRequest) (*authd.GB
 	}, nil
 }
 
+// onlyBroker returns the only broker available besides the local one if the broker selection is skipped, or nil
+// otherwise.
+func (s Service) onlyBroker() *brokers.Broker {
+	if !s.skipBrokerSelection {
+		return nil
+	}
+
+	var only *brokers.Broker
+	for _, b := range s.brokerManager.AvailableBrokers() {
+		if b.ID == brokers.LocalBrokerName {
+			continue
+		}
+		if only != nil {
+			return nil
+		}
+		only = b
+	}
+	return only
+}
+
 // SelectBroker starts a new session and selects the requested broker for the user.
 func (s Service) SelectBroker(ctx context.Context, req *authd.SBRequest) (resp *authd.SBResponse, err error) {
-	// authd usernames are lowercase
-	username := strings.ToLower(req.GetUsername())
+	// The broker authenticates the account at the provider which the name typed by the user is an alias of.
+	username := s.userManager.ResolveAlias(req.GetUsername())
 	brokerID := req.GetBrokerId()
 	lang := req.GetLang()
 
@@ -228,6 +491,10 @@ func (s Service) SelectBroker(ctx context.Context, req *authd.SBRequest) (resp *
 	if lang == "" {
 		lang = "C"
 	}
+	if brokerID != brokers.LocalBrokerName && s.emergencyAccess.IsEmergencyUser(req.GetUsername()) {
+		log.Errorf(ctx, "SelectBroker: Emergency user %q can only log in with the local broker", req.GetUsername())
+		return nil, status.Errorf(codes.PermissionDenied, "user %q is an emergency user and can only log in with their local password", req.GetUsername())
+	}
 
 	var mode string
 	switch req.GetMode() {
@@ -243,27 +510,43 @@ func (s Service) SelectBroker(ctx context.Context, req *authd.SBRequest) (resp *
 	// Look up the user's stored broker and stable provider identifier. If the
 	// user is already bound to a different broker, reject early before opening
 	// a session that would inevitably fail after authentication completes.
-	storedBrokerID, userProviderID, err := s.userManager.BrokerAndProviderIDForUser(username)
+	// The broker is given the name of the account at the provider, authd stores it normalized.
+	storedBrokerID, userProviderID, err := s.userManager.BrokerAndProviderIDForUser(s.userManager.NormalizeName(username))
 	if err != nil && !errors.Is(err, users.NoDataFoundError{}) {
 		log.Errorf(ctx, "SelectBroker: Could not look up broker and provider ID for user %q: %v", username, err)
 		return nil, fmt.Errorf("could not look up broker for user %q: %w", username, err)
 	}
 	if storedBrokerID != "" && storedBrokerID != brokerID {
-		log.Errorf(ctx, "SelectBroker: User %q is bound to broker %q and cannot authenticate with broker %q", username, storedBrokerID, brokerID)
-		return nil, status.Errorf(codes.PermissionDenied, "user %q is already bound to broker %q and cannot authenticate with broker %q", username, storedBrokerID, brokerID)
-	}
-	// If the requested broker doesn't match the stored one, the provider ID
-	// from the stored broker is not applicable.
-	if storedBrokerID != brokerID {
+		// The user can still authenticate with the broker if an identity at its provider was linked to them.
+		identity, err := s.userManager.LinkedIdentity(s.userManager.NormalizeName(username), brokerID)
+		if errors.Is(err, users.NoDataFoundError{}) {
+			log.Errorf(ctx, "SelectBroker: User %q is bound to broker %q and cannot authenticate with broker %q", username, storedBrokerID, brokerID)
+			return nil, status.Errorf(codes.PermissionDenied, "user %q is already bound to broker %q and cannot authenticate with broker %q", username, storedBrokerID, brokerID)
+		}
+		if err != nil {
+			log.Errorf(ctx, "SelectBroker: Could not look up the identity of user %q at broker %q: %v", username, brokerID, err)
+			return nil, fmt.Errorf("could not look up the identity of user %q at broker %q: %w", username, brokerID, err)
+		}
+		log.Debugf(ctx, "SelectBroker: User %q is authenticating with the identity linked at broker %q", username, brokerID)
+		userProviderID = identity.ProviderID
+	} else if storedBrokerID != brokerID {
+		// If the requested broker doesn't match the stored one, the provider ID
+		// from the stored broker is not applicable.
 		userProviderID = ""
 	}
 
 	// Create a session and Memorize selected broker for it.
-	sessionID, encryptionKey, err := s.brokerManager.NewSession(brokerID, username, lang, mode, userProviderID)
+	sessionID, encryptionKey, err := s.brokerManager.NewSession(brokerID, username, lang, req.GetTimezone(), mode, userProviderID)
 	if err != nil {
 		log.Errorf(ctx, "SelectBroker: Could not create session for user %q with broker %q: %v", username, brokerID, err)
 		return nil, err
 	}
+	s.sessionServices.set(sessionID, req.GetService())
+	s.startFingerprintSession(sessionID, s.userManager.NormalizeName(username), mode, storedBrokerID == brokerID)
+	s.startMachineTokenSession(sessionID, s.userManager.NormalizeName(username), mode, storedBrokerID == brokerID)
+	if mode == auth.SessionModeLogin {
+		s.authModeSessions.start(sessionID)
+	}
 
 	return &authd.SBResponse{
 		SessionId:     sessionID,
@@ -297,6 +580,13 @@ func (s Service) GetAuthenticationModes(ctx context.Context, req *authd.GAMReque
 		supportedLayouts = append(supportedLayouts, layout)
 	}
 
+	// Once the broker granted access, the TOTP code is the only thing left to enter.
+	if s.totpSessions.pending(sessionID) {
+		return &authd.GAMResponse{
+			AuthenticationModes: []*authd.GAMResponse_AuthenticationMode{totpAuthMode()},
+		}, nil
+	}
+
 	authenticationModes, err := broker.GetAuthenticationModes(ctx, sessionID, supportedLayouts)
 	if err != nil {
 		log.Errorf(ctx, "GetAuthenticationModes: Could not get authentication modes for session %q: %v", sessionID, err)
@@ -304,12 +594,26 @@ func (s Service) GetAuthenticationModes(ctx context.Context, req *authd.GAMReque
 	}
 
 	var authModes []*authd.GAMResponse_AuthenticationMode
+	// The fingerprint comes first, so that it's the default mode of the returning users who enrolled one.
+	fingerprintAuthMode := s.fingerprintAuthMode(ctx, sessionID, supportedLayouts)
+	s.fingerprintSessions.setOffered(sessionID, fingerprintAuthMode != nil)
+	if fingerprintAuthMode != nil {
+		authModes = append(authModes, fingerprintAuthMode)
+	}
 	for _, a := range authenticationModes {
 		authModes = append(authModes, &authd.GAMResponse_AuthenticationMode{
 			Id:    a[layouts.ID],
 			Label: a[layouts.Label],
 		})
 	}
+	// The machine token comes last, as it's meant for automation rather than for the users themselves.
+	machineTokenAuthMode := s.machineTokenAuthMode(ctx, sessionID)
+	s.machineTokenSessions.setOffered(sessionID, machineTokenAuthMode != nil)
+	if machineTokenAuthMode != nil {
+		authModes = append(authModes, machineTokenAuthMode)
+	}
+	// The mode the user last logged in with comes before the fingerprint, as it's the one they chose.
+	authModes = s.preselectLastAuthMode(ctx, sessionID, broker.ID, authModes)
 
 	return &authd.GAMResponse{
 		AuthenticationModes: authModes,
@@ -338,11 +642,45 @@ func (s Service) SelectAuthenticationMode(ctx context.Context, req *authd.SAMReq
 		return nil, err
 	}
 
+	if s.totpSessions.pending(sessionID) {
+		if authenticationModeID != auth.TOTPMode {
+			log.Errorf(ctx, "SelectAuthenticationMode: Session %q waits for a TOTP code, not for mode %q", sessionID, authenticationModeID)
+			return nil, status.Error(codes.InvalidArgument, "a TOTP code is required")
+		}
+		return &authd.SAMResponse{
+			UiLayoutInfo: mapToUILayout(totpUILayout()),
+		}, nil
+	}
+
+	isFingerprint := authenticationModeID == fingerprintMode
+	if !s.fingerprintSessions.selectMode(sessionID, isFingerprint) {
+		log.Errorf(ctx, "SelectAuthenticationMode: Fingerprint authentication is not available for session %q", sessionID)
+		return nil, status.Error(codes.InvalidArgument, "fingerprint authentication is not available")
+	}
+	isMachineToken := authenticationModeID == auth.MachineTokenMode
+	if !s.machineTokenSessions.selectMode(sessionID, isMachineToken) {
+		log.Errorf(ctx, "SelectAuthenticationMode: Machine token authentication is not available for session %q", sessionID)
+		return nil, status.Error(codes.InvalidArgument, "machine token authentication is not available")
+	}
+	// The machine token is not remembered as the last mode of the user, which is the one they choose themselves.
+	if isMachineToken {
+		return &authd.SAMResponse{
+			UiLayoutInfo: mapToUILayout(machineTokenUILayout()),
+		}, nil
+	}
+	if isFingerprint {
+		s.authModeSessions.selectMode(sessionID, authenticationModeID)
+		return &authd.SAMResponse{
+			UiLayoutInfo: mapToUILayout(fingerprintUILayout()),
+		}, nil
+	}
+
 	uiLayoutInfo, err := broker.SelectAuthenticationMode(ctx, sessionID, authenticationModeID)
 	if err != nil {
 		log.Errorf(ctx, "SelectAuthenticationMode: Could not select authentication mode %q for session %q: %v", authenticationModeID, sessionID, err)
 		return nil, err
 	}
+	s.authModeSessions.selectMode(sessionID, authenticationModeID)
 
 	return &authd.SAMResponse{
 		UiLayoutInfo: mapToUILayout(uiLayoutInfo),
@@ -356,6 +694,17 @@ func (s Service) IsAuthenticated(ctx context.Context, req *authd.IARequest) (res
 		log.Errorf(ctx, "IsAuthenticated: No session ID provided")
 		return nil, status.Error(codes.InvalidArgument, "no session ID provided")
 	}
+	// The service is only needed until the authentication is over, which the clients failing to authenticate don't
+	// always tell by ending the session.
+	defer func() {
+		switch resp.GetAccess() {
+		case auth.Next, auth.Retry, auth.Cancelled:
+			if err == nil {
+				return
+			}
+		}
+		s.sessionServices.remove(sessionID)
+	}()
 
 	broker, err := s.brokerManager.BrokerFromSessionID(sessionID)
 	if err != nil {
@@ -363,24 +712,76 @@ func (s Service) IsAuthenticated(ctx context.Context, req *authd.IARequest) (res
 		return nil, err
 	}
 
+	username := s.brokerManager.UsernameFromSessionID(sessionID)
+	// The broker isn't even asked while the user is locked out, so that no more passwords can be tried.
+	if until, locked := s.faillock.LockedOut(s.userManager.NormalizeName(username)); locked {
+		log.Noticef(ctx, "Authentication failure: user %q is locked out after too many failed authentication attempts", username)
+		s.recordLogin(ctx, sessionID, username, broker.ID, false, loginResultLockedOut)
+		return nil, lockedOutError(username, until)
+	}
+
 	authenticationDataJSON, err := protojson.Marshal(req.GetAuthenticationData())
 	if err != nil {
 		log.Errorf(ctx, "IsAuthenticated: Could not marshal authentication data for session %q: %v", sessionID, err)
 		return nil, err
 	}
 
-	access, data, err := broker.IsAuthenticated(ctx, sessionID, string(authenticationDataJSON))
+	var access, data string
+	// The fingerprint, the TOTP codes and the machine tokens are verified by authd, the broker isn't involved.
+	fingerprintAuth := s.fingerprintSessions.isSelected(sessionID)
+	machineTokenAuth := s.machineTokenSessions.isSelected(sessionID)
+	totpAuth := s.totpSessions.pending(sessionID)
+	switch {
+	case totpAuth:
+		access, data, err = s.totpAuthenticate(ctx, sessionID, req.GetAuthenticationData().GetTotpCode())
+	case fingerprintAuth:
+		access, data, err = s.fingerprintAuthenticate(ctx, sessionID)
+	case machineTokenAuth:
+		access, data, err = s.machineTokenAuthenticate(ctx, sessionID, req.GetAuthenticationData().GetMachineToken())
+	default:
+		access, data, err = broker.IsAuthenticated(ctx, sessionID, string(authenticationDataJSON))
+		if access == auth.Next {
+			// The broker continues with another step of the authentication, which the fingerprint can't replace.
+			s.fingerprintSessions.disable(sessionID)
+		}
+	}
 	if err != nil {
 		log.Errorf(ctx, "IsAuthenticated: Could not check authentication for session %q: %v", sessionID, err)
 		return nil, err
 	}
 
+	// The users who enrolled a TOTP secret enter one of its codes when their broker granted access offline, before
+	// the access is processed.
+	if access == auth.Granted && !fingerprintAuth && !machineTokenAuth && !totpAuth {
+		required, err := s.requireTOTP(ctx, sessionID, data)
+		if err != nil {
+			log.Errorf(ctx, "IsAuthenticated: Could not check whether user %q enrolled a TOTP secret: %v", username, err)
+			return nil, err
+		}
+		if required {
+			access, data = auth.Next, "{}"
+		}
+	}
+
 	log.Debugf(ctx, "%s: Authentication result: %s", sessionID, access)
 
-	username := s.brokerManager.UsernameFromSessionID(sessionID)
+	if access == auth.Next {
+		s.authModeSessions.next(sessionID)
+	}
 
 	if access != auth.Granted {
+		if access != auth.Next && access != auth.Cancelled {
+			s.recordLogin(ctx, sessionID, username, broker.ID, false, access)
+		}
 		if access == auth.Denied || access == auth.DeniedMaxTries || access == auth.Retry {
+			s.alerts.RecordFailure(ctx, alerts.Event{
+				Username: username,
+				BrokerID: broker.ID,
+				Service:  s.sessionServices.get(sessionID),
+			})
+			if s.faillock.RecordFailure(s.userManager.NormalizeName(username)) {
+				log.Noticef(ctx, "User %q is locked out after too many failed authentication attempts", username)
+			}
 			if count := s.failedAuths.recordFailure(username); count > s.authFailConfig.AuthFailDelayThreshold {
 				log.Debugf(ctx, "%s: Delaying response after %d consecutive authentication failures for %q", sessionID, count, username)
 				timer := time.NewTimer(s.authFailConfig.AuthFailDelay)
@@ -400,15 +801,19 @@ func (s Service) IsAuthenticated(ctx context.Context, req *authd.IARequest) (res
 	var grantedData struct {
 		UserInfo types.UserInfo `json:"userinfo"`
 		Message  string         `json:"message"`
+		Offline  bool           `json:"offline"`
 	}
 	if err := json.Unmarshal([]byte(data), &grantedData); err != nil {
 		log.Errorf(ctx, "IsAuthenticated: Could not unmarshal user data for session %q: %v", sessionID, err)
 		return nil, fmt.Errorf("user data from broker invalid: %v", err)
 	}
 	uInfo := grantedData.UserInfo
-	// authd uses lowercase user and group names
-	uInfo.Name = strings.ToLower(uInfo.Name)
+	// authd uses normalized user names and lowercase group names
+	uInfo.OriginalName = uInfo.Name
+	uInfo.Name = s.userManager.NormalizeName(uInfo.Name)
 	uInfo.BrokerID = broker.ID
+	uInfo.SharedPrimaryGID = broker.UserDefaults.SharedPrimaryGID
+	uInfo.DefaultGroups = broker.UserDefaults.Groups
 	for i, g := range uInfo.Groups {
 		uInfo.Groups[i].Name = strings.ToLower(g.Name)
 	}
@@ -431,15 +836,96 @@ func (s Service) IsAuthenticated(ctx context.Context, req *authd.IARequest) (res
 			return nil, fmt.Errorf("could not check if user %q is locked: %w", uInfo.Name, err)
 		}
 	}
+	// The user is known if they were found by either their name or their provider ID.
+	userIsKnown := !errors.Is(err, users.NoDataFoundError{})
 	// Throw an error if the user trying to authenticate already exists in the database and is locked.
 	if userIsLocked {
 		log.Noticef(ctx, "Authentication failure: user %q is locked", uInfo.Name)
+		s.recordLogin(ctx, sessionID, uInfo.Name, broker.ID, grantedData.Offline, loginResultLocked)
+		s.alerts.RecordLockedUser(ctx, alerts.Event{
+			Username: uInfo.Name,
+			BrokerID: broker.ID,
+			Service:  s.sessionServices.get(sessionID),
+		})
 		return nil, status.Error(codes.PermissionDenied, fmt.Sprintf("user %s is locked", uInfo.Name))
 	}
-	// Update database and local groups on granted auth.
-	if err := s.userManager.UpdateUser(uInfo); err != nil {
-		log.Errorf(ctx, "IsAuthenticated: Could not update user %q in database: %v", uInfo.Name, err)
-		return nil, err
+	// The login policy of the machine is enforced by authd, as the configuration of the broker is often shared by
+	// all the machines.
+	loginPolicy := s.loginPolicy.Current()
+	if g := loginPolicy.DeniedGroup(uInfo.Groups); g != "" {
+		log.Noticef(ctx, "Authentication failure: user %q is a member of the group %q denied to log in on this machine", uInfo.Name, g)
+		s.recordLogin(ctx, sessionID, uInfo.Name, broker.ID, grantedData.Offline, loginResultNotAllowed)
+		return nil, status.Error(codes.PermissionDenied, fmt.Sprintf("user %s is not allowed to log in on this machine: members of the group %s can't log in", uInfo.Name, g))
+	}
+	if !loginPolicy.Allows(uInfo.Groups) {
+		log.Noticef(ctx, "Authentication failure: user %q is not a member of any group allowed to log in on this machine", uInfo.Name)
+		s.recordLogin(ctx, sessionID, uInfo.Name, broker.ID, grantedData.Offline, loginResultNotAllowed)
+		return nil, status.Error(codes.PermissionDenied, fmt.Sprintf("user %s is not allowed to log in on this machine", uInfo.Name))
+	}
+	// In lockdown, only the users already in the database can log in. Like for locked users, this is only checked
+	// once the broker granted access, to not tell unauthenticated users whether a user exists.
+	if !userIsKnown && !loginPolicy.AllowsNewUsers() {
+		log.Noticef(ctx, "Authentication failure: user %q is not known and new users can't be provisioned in lockdown", uInfo.Name)
+		s.recordLogin(ctx, sessionID, uInfo.Name, broker.ID, grantedData.Offline, loginResultNotAllowed)
+		return nil, status.Error(codes.PermissionDenied, fmt.Sprintf("user %s is not allowed to log in on this machine: new users can't be added in lockdown", uInfo.Name))
+	}
+	if !loginPolicy.AllowsAt(time.Now()) {
+		log.Noticef(ctx, "Authentication failure: user %q is not allowed to log in outside of the login hours", uInfo.Name)
+		s.recordLogin(ctx, sessionID, uInfo.Name, broker.ID, grantedData.Offline, loginResultNotAllowed)
+		return nil, status.Error(codes.PermissionDenied, fmt.Sprintf("user %s is not allowed to log in on this machine at this time", uInfo.Name))
+	}
+	// Offline logins only rely on what was cached on the last online authentication, which may be outdated: e.g. the
+	// account may have been disabled at the provider since then.
+	// During an outage of all the brokers, the emergency grace window lifts these limits, so that the users who logged
+	// in on the machine before aren't locked out.
+	// The logins with a machine token are not offline logins: they are limited by the expiry of the token instead, so
+	// that the automation isn't locked out by the limits meant for the users.
+	if grantedData.Offline && !loginPolicy.AllowsOffline(s.lastOnlineLogin(ctx, uInfo.Name), time.Now()) && !s.emergencyGraceActive(ctx) {
+		log.Noticef(ctx, "Authentication failure: user %q did not authenticate online within %s", uInfo.Name, loginPolicy.OfflineMaxAge)
+		s.recordLogin(ctx, sessionID, uInfo.Name, broker.ID, grantedData.Offline, loginResultNotAllowed)
+		return nil, status.Error(codes.PermissionDenied, fmt.Sprintf("user %s must authenticate with their identity provider while online to log in", uInfo.Name))
+	}
+	if grantedData.Offline && !loginPolicy.AllowsOfflineLogins(s.offlineLogins(ctx, uInfo.Name)) && !s.emergencyGraceActive(ctx) {
+		log.Noticef(ctx, "Authentication failure: user %q logged in offline %d times since they last authenticated online", uInfo.Name, loginPolicy.OfflineMaxLogins)
+		s.recordLogin(ctx, sessionID, uInfo.Name, broker.ID, grantedData.Offline, loginResultNotAllowed)
+		return nil, status.Error(codes.PermissionDenied, fmt.Sprintf("user %s must authenticate with their identity provider while online to log in", uInfo.Name))
+	}
+	// Update database and local groups on granted auth. With the fingerprint and the machine token, the user
+	// information is the one stored on their last login with the broker, so there is nothing to update.
+	if !fingerprintAuth && !machineTokenAuth {
+		var previousInfo *types.UserInfo
+		if info, err := s.userManager.CachedUserInfo(uInfo.Name); err == nil {
+			previousInfo = &info
+		}
+		if uInfo, err = s.augmentFromDirectory(ctx, uInfo, previousInfo); err != nil {
+			log.Errorf(ctx, "IsAuthenticated: %v", err)
+			return nil, err
+		}
+		uInfo = s.adminRole.Prepare(uInfo)
+		if err := s.userManager.UpdateUser(uInfo); err != nil {
+			log.Errorf(ctx, "IsAuthenticated: Could not update user %q in database: %v", uInfo.Name, err)
+			return nil, err
+		}
+		if err := s.syncAdminRole(ctx, previousInfo, uInfo); err != nil {
+			// Denying the login wouldn't revoke the rights either, they are synced again on the next login.
+			log.Errorf(ctx, "IsAuthenticated: Could not update the administrator rights of user %q: %v", uInfo.Name, err)
+		}
+		if err := s.userManager.SetSessionEnv(uInfo.Name, uInfo.Env); err != nil {
+			// A write failure must not prevent a successfully authenticated user from logging in.
+			log.Errorf(ctx, "IsAuthenticated: Could not update session environment of user %q in database: %v", uInfo.Name, err)
+		}
+		if err := s.userManager.SetSSHKeys(uInfo.Name, uInfo.SSHKeys); err != nil {
+			log.Errorf(ctx, "IsAuthenticated: Could not update SSH keys of user %q in database: %v", uInfo.Name, err)
+		}
+		s.publishToAccountsService(ctx, uInfo)
+	}
+	// The certificates are only issued when the identity provider vouched for the user, not with the information
+	// cached on their last login.
+	if !fingerprintAuth && !machineTokenAuth && !grantedData.Offline {
+		if err := s.issueSSHCert(ctx, uInfo.Name); err != nil {
+			// The user can still log in and authenticate again on the other machines.
+			log.Errorf(ctx, "IsAuthenticated: Could not issue SSH certificate for user %q: %v", uInfo.Name, err)
+		}
 	}
 	// IAResponse.Msg carries a JSON {"message": ...} envelope (or an empty
 	// string when there is no message), matching the format expected by the
@@ -466,9 +952,15 @@ func (s Service) IsAuthenticated(ctx context.Context, req *authd.IARequest) (res
 			// successfully authenticated user from logging in.
 			log.Errorf(ctx, "IsAuthenticated: Could not update broker for user %q in database: %v", uInfo.Name, err)
 		}
+		if !machineTokenAuth {
+			s.storeLastAuthMode(ctx, sessionID, uInfo.Name, broker.ID)
+		}
 	}
 
 	s.failedAuths.recordSuccess(username)
+	s.faillock.RecordSuccess(s.userManager.NormalizeName(username))
+	s.alerts.RecordSuccess(username)
+	s.recordLogin(ctx, sessionID, uInfo.Name, broker.ID, grantedData.Offline, access)
 
 	return &authd.IAResponse{
 		Access: access,
@@ -486,9 +978,337 @@ func (s Service) EndSession(ctx context.Context, req *authd.ESRequest) (empty *a
 		return nil, status.Error(codes.InvalidArgument, "no session id given")
 	}
 
+	s.sessionServices.remove(sessionID)
+	s.fingerprintSessions.remove(sessionID)
+	s.totpSessions.remove(sessionID)
+	s.machineTokenSessions.remove(sessionID)
+	s.authModeSessions.remove(sessionID)
 	return &authd.Empty{}, s.brokerManager.EndSession(sessionID)
 }
 
+// NotifySession reports the start or the stop of a session of an authd user and runs the matching session hooks.
+// When the session starts, it runs the matching credential hooks and returns the environment variables to export in
+// it. For ephemeral users, it returns
+// the temporary home to mount when their first session starts and to destroy when their last session stops. For
+// the users with a network home, it returns the home to mount and check when the session starts. For the users whose
+// home was created with systemd-homed, it returns the home to activate when their first session starts and to
+// deactivate when their last session stops. For the users whose home is encrypted with fscrypt, it returns the home to
+// create encrypted or to unlock when the session starts. For the other users, it returns the home to create with the
+// settings of their broker, if it sets them.
+func (s Service) NotifySession(ctx context.Context, req *authd.NSRequest) (*authd.NSResponse, error) {
+	// Only the PAM module running as root can report sessions, as the hooks are run as root.
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	var eventType sessionhooks.EventType
+	switch req.GetEvent() {
+	case authd.NSRequest_START:
+		eventType = sessionhooks.EventStart
+	case authd.NSRequest_STOP:
+		eventType = sessionhooks.EventStop
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid session event %q", req.GetEvent())
+	}
+
+	username := s.userManager.NormalizeName(req.GetUsername())
+	if username == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name given")
+	}
+
+	u, err := s.userManager.UserByName(username)
+	if errors.Is(err, users.NoDataFoundError{}) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not get user %q: %v", username, err)
+	}
+	brokerID, err := s.userManager.BrokerForUser(username)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not get broker of user %q: %v", username, err)
+	}
+	groups, err := s.userManager.UserGroups(username)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not get groups of user %q: %v", username, err)
+	}
+
+	s.sessionHooks.Run(ctx, sessionhooks.Event{
+		Type:       eventType,
+		Time:       time.Now().UTC(),
+		Username:   u.Name,
+		UID:        u.UID,
+		Groups:     groups,
+		BrokerID:   brokerID,
+		Service:    req.GetService(),
+		TTY:        req.GetTty(),
+		RemoteHost: req.GetRhost(),
+	})
+
+	if eventType != sessionhooks.EventStart {
+		return &authd.NSResponse{EphemeralHome: s.ephemeralHome(ctx, false, u), HomedHome: s.homedHome(ctx, false, u)}, nil
+	}
+	env, err := s.userManager.SessionEnv(username)
+	if err != nil {
+		// The environment must not prevent the session from being opened.
+		log.Warningf(ctx, "NotifySession: Could not get session environment of user %q: %v", username, err)
+	}
+	credentialEnv := s.credentialHooks.Run(ctx, credentialhooks.Session{
+		Username: u.Name,
+		UID:      u.UID,
+		GID:      u.GID,
+		Home:     u.Dir,
+		Groups:   groups,
+		BrokerID: brokerID,
+		Service:  req.GetService(),
+	}, s.credentialHooksToken(u.Name, brokerID))
+	// The hooks point the session to the credentials they acquired, which takes precedence over the broker.
+	if len(credentialEnv) > 0 && env == nil {
+		env = make(map[string]string)
+	}
+	maps.Copy(env, credentialEnv)
+	return &authd.NSResponse{
+		Env:           env,
+		EphemeralHome: s.ephemeralHome(ctx, true, u),
+		NetworkHome:   s.networkHome(ctx, u, brokerID),
+		HomedHome:     s.homedHome(ctx, true, u),
+		FscryptHome:   s.fscryptHome(ctx, u, brokerID),
+		LocalHome:     s.localHome(ctx, u, brokerID, groups),
+	}, nil
+}
+
+// credentialHooksToken returns the function getting the access tokens of the user from their broker for the
+// credential hooks.
+func (s Service) credentialHooksToken(username, brokerID string) credentialhooks.TokenFunc {
+	return func(ctx context.Context, scopes []string) (credentialhooks.Token, error) {
+		if brokerID == "" || brokerID == brokers.LocalBrokerName {
+			return credentialhooks.Token{}, fmt.Errorf("user %q is not provided by a broker", username)
+		}
+		broker, err := s.brokerManager.BrokerFromID(brokerID)
+		if err != nil {
+			return credentialhooks.Token{}, err
+		}
+		t, err := broker.SessionToken(ctx, username, scopes)
+		if err != nil {
+			return credentialhooks.Token{}, err
+		}
+		log.Infof(ctx, "Handed a token of user %q for scopes %v to the credential hooks", username, t.Scopes)
+		return credentialhooks.Token{AccessToken: t.AccessToken, TokenType: t.TokenType, Expiry: t.Expiry}, nil
+	}
+}
+
+// loginResultLocked is the login history result for a user whose authentication was granted by the broker but who
+// is locked in authd.
+const loginResultLocked = "locked"
+
+// loginResultNotAllowed is the login history result for a user whose authentication was granted by the broker but
+// who is not allowed to log in by the login policy of the machine.
+const loginResultNotAllowed = "not allowed"
+
+// loginResultLockedOut is the login history result for a user who is locked out after too many failed authentication
+// attempts, and whose authentication was not even checked by the broker.
+const loginResultLockedOut = "locked out"
+
+// lockedOutError returns the error telling the user that they are locked out until the given time, or until they are
+// unlocked by an administrator if it's zero.
+func lockedOutError(username string, until time.Time) error {
+	if until.IsZero() {
+		return status.Errorf(codes.PermissionDenied, "user %s is locked out after too many failed authentication attempts, contact your administrator", username)
+	}
+	return status.Errorf(codes.PermissionDenied, "user %s is locked out after too many failed authentication attempts, try again in %s",
+		username, time.Until(until).Round(time.Second))
+}
+
+// syncAdminRole grants or revokes the administrator rights of the user once their information is stored, and sends an
+// alert for each change.
+func (s Service) syncAdminRole(ctx context.Context, previous *types.UserInfo, current types.UserInfo) error {
+	u, err := s.userManager.UserByName(current.Name)
+	if err != nil {
+		return err
+	}
+
+	changes, err := s.adminRole.Sync(ctx, u.UID, previous, current)
+	for _, c := range changes {
+		s.alerts.RecordAdminChange(ctx, alerts.Event{Username: c.Username, BrokerID: c.BrokerID}, c.Type == adminrole.Granted, c.Groups)
+	}
+	return err
+}
+
+// accountsServiceTimeout is how long publishing a user to AccountsService can take.
+const accountsServiceTimeout = 30 * time.Second
+
+// publishToAccountsService publishes the user to AccountsService in the background: AccountsService looks them up with
+// NSS, which is served by authd, and a failure must not prevent them from logging in.
+func (s Service) publishToAccountsService(ctx context.Context, u types.UserInfo) {
+	if s.accountsService == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), accountsServiceTimeout)
+		defer cancel()
+		if err := s.accountsService.Publish(ctx, u.Name, u.Picture); err != nil {
+			log.Warningf(ctx, "Could not publish user %q to AccountsService: %v", u.Name, err)
+		}
+	}()
+}
+
+// issueSSHCert issues a short-lived SSH certificate for the user once their information is stored.
+func (s Service) issueSSHCert(ctx context.Context, username string) error {
+	if !s.authFailConfig.SSHCert.Enabled() {
+		return nil
+	}
+
+	u, err := s.userManager.UserByName(username)
+	if err != nil {
+		return err
+	}
+	return s.sshCerts.Issue(ctx, u.Name, u.UID, u.GID)
+}
+
+// augmentFromDirectory sets the POSIX attributes of the user found in the LDAP directory: the home directory, and for
+// a new user their UID and primary group. Users without an account in the directory keep the attributes of the broker.
+func (s Service) augmentFromDirectory(ctx context.Context, u types.UserInfo, previous *types.UserInfo) (types.UserInfo, error) {
+	if s.directory == nil {
+		return u, nil
+	}
+
+	account, err := s.directory.LookupUser(ctx, u.Name)
+	if errors.Is(err, ldap.ErrNoSuchUser) {
+		log.Debugf(ctx, "User %q has no POSIX account in the LDAP directory", u.Name)
+		return u, nil
+	}
+	if err != nil && previous == nil {
+		// Creating the user with a generated UID would conflict with the one of the directory.
+		return u, fmt.Errorf("could not look up new user %q in the LDAP directory: %w", u.Name, err)
+	}
+	if err != nil {
+		log.Warningf(ctx, "Could not look up user %q in the LDAP directory, keeping their stored attributes: %v", u.Name, err)
+		u.Dir = previous.Dir
+		return u, nil
+	}
+
+	u.DirectoryUID = &account.UID
+	// The primary group is a group of the system, like the shared primary group of a broker.
+	u.SharedPrimaryGID = &account.GID
+	if account.Home != "" {
+		u.Dir = account.Home
+	}
+	return u, nil
+}
+
+// lastOnlineLogin returns the time of the last successful login of the user with their identity provider, or the
+// zero time if there is none in their login history.
+func (s Service) lastOnlineLogin(ctx context.Context, username string) time.Time {
+	t, err := s.userManager.LastOnlineLogin(username)
+	if err != nil && !errors.Is(err, users.NoDataFoundError{}) {
+		log.Warningf(ctx, "Could not get the login history of user %q: %v", username, err)
+	}
+	return t
+}
+
+// emergencyGraceActive returns true if all the brokers have been unable to reach their identity provider for longer
+// than the grace threshold of the emergency access, so that the offline limits of the login policy are lifted.
+func (s Service) emergencyGraceActive(ctx context.Context) bool {
+	if s.emergencyAccess.GraceThreshold == 0 {
+		return false
+	}
+
+	lastOnline, err := s.userManager.LastOnlineLoginsByBroker()
+	if err != nil {
+		log.Warningf(ctx, "Could not get the last online logins of the brokers: %v", err)
+		return false
+	}
+	lastOffline, err := s.userManager.LastOfflineLoginsByBroker()
+	if err != nil {
+		log.Warningf(ctx, "Could not get the last offline logins of the brokers: %v", err)
+		return false
+	}
+	// The brokers which were removed from the configuration can't be reached anymore.
+	for _, logins := range []map[string]time.Time{lastOnline, lastOffline} {
+		maps.DeleteFunc(logins, func(brokerID string, _ time.Time) bool { return !s.brokerManager.BrokerExists(brokerID) })
+	}
+
+	if !s.emergencyAccess.GraceActive(lastOnline, lastOffline, time.Now()) {
+		return false
+	}
+	log.Warningf(ctx, "All brokers have been unable to reach their identity provider for longer than %s, lifting the offline limits of the login policy", s.emergencyAccess.GraceThreshold)
+	return true
+}
+
+// offlineLogins returns the number of offline logins of the user since their last successful login with their identity
+// provider, or 0 if it can't be got.
+func (s Service) offlineLogins(ctx context.Context, username string) int {
+	n, err := s.userManager.OfflineLoginsSinceOnlineLogin(username)
+	if err != nil && !errors.Is(err, users.NoDataFoundError{}) {
+		log.Warningf(ctx, "Could not get the login history of user %q: %v", username, err)
+	}
+	return n
+}
+
+// recordLogin adds an entry to the login history of the user and publishes the login. Failures are only logged, as
+// they must not prevent the user from logging in.
+func (s Service) recordLogin(ctx context.Context, sessionID, username, brokerID string, offline bool, result string) {
+	if username == "" {
+		return
+	}
+	s.metrics.Authenticated(brokerID, result, offline)
+
+	record := types.LoginRecord{
+		Time:         time.Now(),
+		Service:      s.sessionServices.get(sessionID),
+		BrokerID:     brokerID,
+		Offline:      offline,
+		Result:       result,
+		MachineToken: s.machineTokenSessions.isSelected(sessionID),
+	}
+	s.publishLogin(username, record)
+
+	err := s.userManager.RecordLogin(username, record)
+	if errors.Is(err, users.NoDataFoundError{}) {
+		// Users that are not in the database (yet) don't have a login history.
+		log.Debugf(ctx, "%s: Not recording login of user %q: %v", sessionID, username, err)
+		return
+	}
+	if err != nil {
+		log.Warningf(ctx, "%s: Could not record login of user %q: %v", sessionID, username, err)
+	}
+}
+
+// publishLogin publishes the login of the user, and that their broker is offline if they logged in with the
+// information it cached.
+func (s Service) publishLogin(username string, record types.LoginRecord) {
+	// Looking up the UID of the user is not worth it when nobody is listening.
+	if !s.events.HasSubscribers() {
+		return
+	}
+
+	e := events.Event{
+		Kind:     events.LoginFailed,
+		Time:     record.Time,
+		Username: username,
+		BrokerID: record.BrokerID,
+		Service:  record.Service,
+		Offline:  record.Offline,
+		Result:   record.Result,
+	}
+	// The users who never logged in don't have a UID yet.
+	if u, err := s.userManager.UserByName(username); err == nil {
+		e.UID = u.UID
+	}
+
+	if record.Result != auth.Granted {
+		s.events.Publish(e)
+		return
+	}
+	e.Kind = events.LoginSucceeded
+	e.Result = ""
+	s.events.Publish(e)
+	if record.Offline {
+		e.Kind = events.BrokerOffline
+		s.events.Publish(e)
+	}
+}
+
 func uiLayoutToMap(layout *authd.UILayout) (mapLayout map[string]string, err error) {
 	if layout.GetType() == "" {
 		return nil, fmt.Errorf("invalid layout option: type is required, got: %v", layout)
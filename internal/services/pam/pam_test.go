@@ -3,26 +3,40 @@ package pam_test
 import (
 	"bytes"
 	"context"
+	"encoding/base32"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
+	"net/url"
 	"os"
+	"os/exec"
 	"os/user"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/canonical/authd/internal/brokers"
 	"github.com/canonical/authd/internal/brokers/auth"
 	"github.com/canonical/authd/internal/brokers/layouts"
+	"github.com/canonical/authd/internal/brokers/layouts/entries"
+	"github.com/canonical/authd/internal/credentialhooks"
+	"github.com/canonical/authd/internal/faillock"
+	"github.com/canonical/authd/internal/fileutils"
+	"github.com/canonical/authd/internal/fingerprint"
+	"github.com/canonical/authd/internal/ldap"
 	"github.com/canonical/authd/internal/proto/authd"
 	"github.com/canonical/authd/internal/services/errmessages"
 	"github.com/canonical/authd/internal/services/pam"
+	"github.com/canonical/authd/internal/services/pam/conversation"
 	"github.com/canonical/authd/internal/services/permissions"
+	"github.com/canonical/authd/internal/sessionhooks"
 	"github.com/canonical/authd/internal/testutils"
 	"github.com/canonical/authd/internal/testutils/golden"
+	"github.com/canonical/authd/internal/totp"
 	"github.com/canonical/authd/internal/users"
 	"github.com/canonical/authd/internal/users/db"
 	localgroupstestutils "github.com/canonical/authd/internal/users/localentries/testutils"
@@ -31,11 +45,14 @@ import (
 	"github.com/canonical/authd/log"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 var (
 	globalBrokerManager   *brokers.Manager
+	globalBrokersConfPath string
 	mockBrokerGeneratedID string
 )
 
@@ -73,7 +90,8 @@ func TestNewService(t *testing.T) {
 	m, err := users.NewManager(users.DefaultConfig, t.TempDir())
 	require.NoError(t, err, "Setup: could not create user manager")
 
-	service := pam.NewService(context.Background(), m, globalBrokerManager, pam.DefaultConfig)
+	pm := permissions.New()
+	service := pam.NewService(context.Background(), m, globalBrokerManager, &pm, pam.DefaultConfig)
 
 	brokers, err := service.AvailableBrokers(context.Background(), &authd.Empty{})
 	require.NoError(t, err, "can’t create the service directly")
@@ -122,7 +140,12 @@ func TestGetBroker(t *testing.T) {
 	tests := map[string]struct {
 		user string
 
-		onlyLocalBroker bool
+		onlyLocalBroker  bool
+		service          string
+		defaultBrokerFor []string
+		aliases          map[string]string
+		emergencyUsers   []string
+		skipSelection    bool
 
 		wantBroker string
 		wantErr    bool
@@ -131,10 +154,23 @@ func TestGetBroker(t *testing.T) {
 		"For_local_user,_get_local_broker":                         {user: currentUsername, wantBroker: brokers.LocalBrokerName},
 		"For_unmanaged_user_and_only_one_broker,_get_local_broker": {user: "nonexistent@example.com", onlyLocalBroker: true, wantBroker: brokers.LocalBrokerName},
 		"Username_is_case_insensitive":                             {user: "UserWithBroker@example.com", wantBroker: mockBrokerGeneratedID},
-
-		"Returns_empty_when_user_does_not_exist":         {user: "nonexistent@example.com", wantBroker: ""},
-		"Returns_empty_when_user_does_not_have_a_broker": {user: "userwithoutbroker@example.com", wantBroker: ""},
-		"Returns_empty_when_broker_is_not_available":     {user: "userwithinactivebroker@example.com", wantBroker: ""},
+		"Username_can_be_an_alias":                                 {user: "ShortName", aliases: map[string]string{"shortname": "userwithbroker@example.com"}, wantBroker: mockBrokerGeneratedID},
+		"For_emergency_user,_get_local_broker":                     {user: "userwithbroker@example.com", emergencyUsers: []string{"userwithbroker@example.com"}, wantBroker: brokers.LocalBrokerName},
+
+		"Default_broker_for_new_user":                {user: "nonexistent@example.com", defaultBrokerFor: []string{""}, wantBroker: mockBrokerGeneratedID},
+		"Default_broker_of_the_service_for_new_user": {user: "nonexistent@example.com", service: "sshd", defaultBrokerFor: []string{"sshd"}, wantBroker: mockBrokerGeneratedID},
+		"Default_broker_is_not_used_for_known_user":  {user: "userwithoutbroker@example.com", defaultBrokerFor: []string{""}, wantBroker: ""},
+		"Default_broker_is_not_used_for_local_user":  {user: currentUsername, defaultBrokerFor: []string{""}, wantBroker: brokers.LocalBrokerName},
+
+		"Only_broker_for_new_user_when_selection_is_skipped": {user: "nonexistent@example.com", skipSelection: true, wantBroker: mockBrokerGeneratedID},
+		"Only_broker_is_not_used_for_known_user":             {user: "userwithoutbroker@example.com", skipSelection: true, wantBroker: ""},
+		"Only_broker_is_not_used_for_local_user":             {user: currentUsername, skipSelection: true, wantBroker: brokers.LocalBrokerName},
+		"Only_broker_does_not_override_the_stored_one":       {user: "userwithinactivebroker@example.com", skipSelection: true, wantBroker: ""},
+
+		"Returns_empty_when_user_does_not_exist":                   {user: "nonexistent@example.com", wantBroker: ""},
+		"Returns_empty_when_default_broker_is_for_another_service": {user: "nonexistent@example.com", service: "login", defaultBrokerFor: []string{"sshd"}, wantBroker: ""},
+		"Returns_empty_when_user_does_not_have_a_broker":           {user: "userwithoutbroker@example.com", wantBroker: ""},
+		"Returns_empty_when_broker_is_not_available":               {user: "userwithinactivebroker@example.com", wantBroker: ""},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -152,7 +188,9 @@ func TestGetBroker(t *testing.T) {
 			err = db.Z_ForTests_CreateDBFromYAMLReader(bytes.NewBuffer(d), dbDir)
 			require.NoError(t, err, "Setup: could not create database from testdata")
 
-			m, err := users.NewManager(users.DefaultConfig, dbDir)
+			cfg := users.DefaultConfig
+			cfg.Normalization.Aliases = tc.aliases
+			m, err := users.NewManager(cfg, dbDir)
 			require.NoError(t, err, "Setup: could not create user manager")
 			t.Cleanup(func() { _ = m.Stop() })
 
@@ -161,10 +199,25 @@ func TestGetBroker(t *testing.T) {
 				brokerManager, err = brokers.NewManager(context.Background(), "", nil)
 				require.NoError(t, err, "Setup: could not create broker manager with only local broker")
 			}
-			client := newPamClient(t, m, brokerManager)
+			if tc.defaultBrokerFor != nil {
+				// The default brokers are stored in the brokers configuration directory.
+				brokersConfPath := t.TempDir()
+				err := fileutils.CopyFile(filepath.Join(globalBrokersConfPath, "BrokerMock.conf"), filepath.Join(brokersConfPath, "BrokerMock.conf"))
+				require.NoError(t, err, "Setup: could not copy broker configuration")
+				brokerManager, err = brokers.NewManager(context.Background(), brokersConfPath, nil)
+				require.NoError(t, err, "Setup: could not create broker manager")
+				for _, service := range tc.defaultBrokerFor {
+					err = brokerManager.SetDefaultBroker(service, mockBrokerGeneratedID)
+					require.NoError(t, err, "Setup: could not set default broker")
+				}
+			}
+			pamCfg := pam.DefaultConfig
+			pamCfg.EmergencyAccess.Users = tc.emergencyUsers
+			pamCfg.SkipBrokerSelection = tc.skipSelection
+			client := newPamClientWithConfig(t, m, brokerManager, pamCfg)
 
 			// Get existing entry
-			gotResp, err := client.GetBroker(context.Background(), &authd.GBRequest{Username: tc.user})
+			gotResp, err := client.GetBroker(context.Background(), &authd.GBRequest{Username: tc.user, Service: tc.service})
 
 			if tc.wantErr {
 				require.Error(t, err, "GetBroker should return an error, but did not")
@@ -181,10 +234,11 @@ func TestSelectBroker(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
-		brokerID    string
-		username    string
-		sessionMode string
-		existingDB  string
+		brokerID      string
+		username      string
+		sessionMode   string
+		existingDB    string
+		emergencyUser bool
 
 		wantErr bool
 	}{
@@ -200,6 +254,7 @@ func TestSelectBroker(t *testing.T) {
 		"Error_when_starting_the_session":                            {username: "ns_error@example.com", wantErr: true},
 		"Error_when_user_is_bound_to_a_different_broker":             {username: "bound@example.com", existingDB: "bound-to-other-broker.db", wantErr: true},
 		"Error_when_user_is_bound_to_non-local_broker_selects_local": {username: "bound@example.com", brokerID: brokers.LocalBrokerName, existingDB: "bound-to-other-broker.db", wantErr: true},
+		"Error_when_emergency_user_selects_non-local_broker":         {username: "emergency@example.com", emergencyUser: true, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -215,8 +270,6 @@ func TestSelectBroker(t *testing.T) {
 			require.NoError(t, err, "Setup: could not create user manager")
 			t.Cleanup(func() { _ = m.Stop() })
 
-			client := newPamClient(t, m, globalBrokerManager)
-
 			switch tc.brokerID {
 			case "":
 				tc.brokerID = mockBrokerGeneratedID
@@ -228,6 +281,12 @@ func TestSelectBroker(t *testing.T) {
 				tc.username = t.Name() + testutils.IDSeparator + tc.username
 			}
 
+			cfg := pam.DefaultConfig
+			if tc.emergencyUser {
+				cfg.EmergencyAccess.Users = []string{tc.username}
+			}
+			client := newPamClientWithConfig(t, m, globalBrokerManager, cfg)
+
 			var sessionMode authd.SessionMode
 			switch tc.sessionMode {
 			case auth.SessionModeLogin, "":
@@ -400,6 +459,9 @@ func TestSelectAuthenticationMode(t *testing.T) {
 }
 
 func TestIsAuthenticated(t *testing.T) {
+	// A day on which nobody can be logging in while the tests run.
+	otherDay := time.Now().AddDate(0, 0, 2).Weekday().String()[:3]
+
 	tests := map[string]struct {
 		sessionID  string
 		existingDB string
@@ -409,6 +471,13 @@ func TestIsAuthenticated(t *testing.T) {
 		secondCall      bool
 		cancelFirstCall bool
 		localGroupsFile string
+		allowedGroups   []string
+		deniedGroups    []string
+		lockdown        bool
+		loginHours      []string
+		offlineMaxAge   time.Duration
+		graceThreshold  time.Duration
+		directory       *directoryMock
 
 		// There is no wantErr as it's stored in the golden file.
 	}{
@@ -421,6 +490,31 @@ func TestIsAuthenticated(t *testing.T) {
 		"Update_local_groups":                                  {username: "success_with_local_groups@example.com", localGroupsFile: "valid.group"},
 		"Successfully_authenticate_user_with_uppercase":        {username: "SUCCESS@example.com"},
 		"Successfully_authenticate_with_groups_with_uppercase": {username: "success_with_uppercase_groups@example.com"},
+		"Successfully_authenticate_with_session_environment":   {username: "ia_info_with_env@example.com"},
+		"Successfully_authenticate_with_SSH_keys":              {username: "ia_info_with_ssh_keys@example.com"},
+		"Successfully_authenticate_member_of_allowed_group": {
+			username:      "success@example.com",
+			allowedGroups: []string{"admins", "Group-Success@example.com"},
+		},
+		"Successfully_authenticate_existing_user_in_lockdown": {username: "success@example.com", existingDB: "cache-with-uptodate-user.db", lockdown: true},
+		"Successfully_authenticate_during_login_hours":        {username: "success@example.com", loginHours: []string{otherDay + " 08:00-18:00", "00:00-24:00"}},
+		"Successfully_authenticate_offline_without_limit":     {username: "ia_granted_offline@example.com"},
+		"Successfully_authenticate_offline_with_limit_during_broker_outage": {
+			username:       "ia_granted_offline@example.com",
+			existingDB:     "cache-with-broker-outage.db",
+			offlineMaxAge:  72 * time.Hour,
+			graceThreshold: 4 * time.Hour,
+		},
+		"Successfully_authenticate_with_attributes_of_the_LDAP_directory": {
+			username:  "success@example.com",
+			directory: &directoryMock{accounts: map[string]ldap.Account{"success@example.com": {UID: 15000, GID: 100, Home: "/srv/home/success"}}},
+		},
+		"Successfully_authenticate_user_without_account_in_the_LDAP_directory": {username: "success@example.com", directory: &directoryMock{}},
+		"Successfully_authenticate_existing_user_when_the_LDAP_directory_fails": {
+			username:   "success@example.com",
+			existingDB: "cache-with-user-from-directory.db",
+			directory:  &directoryMock{err: errors.New("connection refused")},
+		},
 
 		// DB write failure: UpdateBrokerForUser fails (read-only filesystem) but auth still succeeds.
 		// UpdateUser is a no-op because the DB already has up-to-date user info; the first actual
@@ -432,6 +526,34 @@ func TestIsAuthenticated(t *testing.T) {
 		"Error_when_sessionID_is_empty": {sessionID: "-"},
 		"Error_when_there_is_no_broker": {sessionID: "invalid-session"},
 		"Error_when_user_is_locked":     {username: "locked@example.com", existingDB: "cache-with-locked-user.db"},
+		"Error_when_user_is_not_member_of_allowed_groups": {
+			username:      "success@example.com",
+			existingDB:    "cache-with-uptodate-user.db",
+			allowedGroups: []string{"admins"},
+		},
+		"Error_when_user_is_member_of_denied_groups": {
+			username:      "success@example.com",
+			existingDB:    "cache-with-uptodate-user.db",
+			allowedGroups: []string{"Group-Success@example.com"},
+			deniedGroups:  []string{"contractors", "Group-Success@example.com"},
+		},
+		"Error_when_user_is_new_in_lockdown": {username: "success@example.com", lockdown: true},
+		"Error_when_user_logs_in_outside_of_login_hours": {
+			username:   "success@example.com",
+			existingDB: "cache-with-uptodate-user.db",
+			loginHours: []string{otherDay + " 00:00-24:00"},
+		},
+		"Error_when_user_never_authenticated_online_with_offline_limit": {username: "ia_granted_offline@example.com", offlineMaxAge: 72 * time.Hour},
+		"Error_when_user_never_authenticated_online_with_offline_limit_and_brokers_are_reachable": {
+			username:       "ia_granted_offline@example.com",
+			existingDB:     "cache-with-reachable-broker.db",
+			offlineMaxAge:  72 * time.Hour,
+			graceThreshold: 4 * time.Hour,
+		},
+		"Error_when_new_user_can_not_be_looked_up_in_the_LDAP_directory": {
+			username:  "success@example.com",
+			directory: &directoryMock{err: errors.New("connection refused")},
+		},
 
 		// broker errors
 		"Error_when_authenticating":                                              {username: "ia_error@example.com"},
@@ -482,7 +604,18 @@ func TestIsAuthenticated(t *testing.T) {
 				t.Cleanup(func() { _ = os.Chmod(dbDir, 0o700) })                                                 //nolint:gosec // test-only cleanup
 			}
 
-			client := newPamClient(t, m, globalBrokerManager)
+			cfg := pam.DefaultConfig
+			cfg.LoginPolicy.AllowedGroups = tc.allowedGroups
+			cfg.LoginPolicy.DeniedGroups = tc.deniedGroups
+			cfg.LoginPolicy.Lockdown = tc.lockdown
+			cfg.LoginPolicy.LoginHours = tc.loginHours
+			cfg.LoginPolicy.OfflineMaxAge = tc.offlineMaxAge
+			cfg.EmergencyAccess.GraceThreshold = tc.graceThreshold
+			if tc.directory != nil {
+				cfg.LDAP.URL = "ldaps://ldap.example.com"
+				pam.SetDirectory(&cfg, tc.directory)
+			}
+			client := newPamClientWithConfig(t, m, globalBrokerManager, cfg)
 
 			switch tc.sessionID {
 			case "invalid-session":
@@ -628,6 +761,41 @@ func TestIsAuthenticated_FailDelay_Retry(t *testing.T) {
 		"retry attempt after threshold should be delayed")
 }
 
+func TestIsAuthenticated_Lockout(t *testing.T) {
+	t.Parallel()
+
+	cfg := pam.DefaultConfig
+	cfg.AuthFailDelayThreshold = math.MaxInt
+	cfg.Faillock = faillock.Config{Deny: 2, UnlockTime: time.Hour}
+	client := newPamClientWithConfig(t, nil, globalBrokerManager, cfg)
+
+	// Each failure uses a fresh session, like an attacker resetting the per-session retry counter.
+	for range cfg.Faillock.Deny {
+		sessionID := startSession(t, client, "ia_retry@example.com")
+		resp, err := client.IsAuthenticated(context.Background(), &authd.IARequest{
+			SessionId:          sessionID,
+			AuthenticationData: &authd.IARequest_AuthenticationData{},
+		})
+		require.NoError(t, err, "IsAuthenticated should not return an error before the user is locked out")
+		require.Equal(t, auth.Retry, resp.GetAccess(), "IsAuthenticated should ask to retry before the user is locked out")
+	}
+
+	sessionID := startSession(t, client, "ia_retry@example.com")
+	_, err := client.IsAuthenticated(context.Background(), &authd.IARequest{
+		SessionId:          sessionID,
+		AuthenticationData: &authd.IARequest_AuthenticationData{},
+	})
+	require.ErrorContains(t, err, "locked out", "IsAuthenticated should deny the locked out user")
+
+	// Other users are not locked out.
+	sessionID = startSession(t, client, "ia_retry_second@example.com")
+	_, err = client.IsAuthenticated(context.Background(), &authd.IARequest{
+		SessionId:          sessionID,
+		AuthenticationData: &authd.IARequest_AuthenticationData{},
+	})
+	require.NoError(t, err, "IsAuthenticated should not lock out the other users")
+}
+
 func TestIsAuthenticated_FailDelayTrackerFull(t *testing.T) {
 	// Cannot be parallel: temporarily overrides the package-level authFailMaxTracked.
 	//nolint:paralleltest // modifies package-level authFailMaxTracked, cannot run in parallel
@@ -662,6 +830,451 @@ func TestIsAuthenticated_FailDelayTrackerFull(t *testing.T) {
 		"first failure for new user should be delayed when tracker is full")
 }
 
+func TestIsAuthenticated_SSHCert(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is not available")
+	}
+	if os.Getuid() != 0 && os.Getuid() != os.Getgid() {
+		t.Skip("the certificate can't be given to the private group of the user")
+	}
+
+	tests := map[string]struct {
+		username string
+
+		wantCert bool
+	}{
+		"Issue_certificate_on_online_authentication":         {username: "success@example.com", wantCert: true},
+		"Do_not_issue_certificate_on_offline_authentication": {username: "ia_granted_offline@example.com"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// The user gets the UID of the test process, and their private group the same GID, so that the
+			// certificate can be given to them.
+			uid := uint32(os.Getuid())
+			m, err := users.NewManager(users.DefaultConfig, t.TempDir(), users.WithIDGenerator(&users.IDGeneratorMock{
+				UIDsToGenerate: []uint32{uid},
+				GIDsToGenerate: []uint32{22222},
+			}))
+			require.NoError(t, err, "Setup: could not create user manager")
+			t.Cleanup(func() { _ = m.Stop() })
+
+			dir := t.TempDir()
+			caKey := filepath.Join(dir, "ca")
+			out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", caKey).CombinedOutput()
+			require.NoError(t, err, "Setup: could not generate CA key: %s", out)
+
+			cfg := pam.DefaultConfig
+			cfg.SSHCert.CAKey = caKey
+			cfg.SSHCert.Dir = filepath.Join(dir, "certs")
+			client := newPamClientWithConfig(t, m, globalBrokerManager, cfg)
+
+			resp, err := client.IsAuthenticated(context.Background(), &authd.IARequest{
+				SessionId:          startSession(t, client, tc.username),
+				AuthenticationData: &authd.IARequest_AuthenticationData{},
+			})
+			require.NoError(t, err, "IsAuthenticated should not return an error")
+			require.Equal(t, auth.Granted, resp.GetAccess(), "IsAuthenticated should grant access")
+
+			certPath := filepath.Join(cfg.SSHCert.Dir, fmt.Sprint(uid), "id_ed25519-cert.pub")
+			if !tc.wantCert {
+				require.NoFileExists(t, certPath, "No certificate should have been issued")
+				return
+			}
+			require.FileExists(t, certPath, "A certificate should have been issued")
+		})
+	}
+}
+
+func TestFingerprintAuthentication(t *testing.T) {
+	t.Parallel()
+
+	waitForm := &authd.UILayout{
+		Type:  layouts.Form,
+		Label: &optional,
+		Wait:  &layouts.OptionalWithBooleans,
+	}
+
+	tests := map[string]struct {
+		noFingerprint      bool
+		newUser            bool
+		notEnrolled        bool
+		enrolledErr        error
+		supportedUILayouts []*authd.UILayout
+		verifyErrs         []error
+
+		wantNotOffered bool
+		wantAccess     []string
+	}{
+		"Grants_access_with_a_matching_fingerprint":          {verifyErrs: []error{nil}, wantAccess: []string{auth.Granted}},
+		"Retries_when_the_fingerprint_does_not_match":        {verifyErrs: []error{fingerprint.ErrNoMatch, nil}, wantAccess: []string{auth.Retry, auth.Granted}},
+		"Continues_with_the_broker_after_too_many_attempts":  {verifyErrs: []error{fingerprint.ErrNoMatch, fingerprint.ErrNoMatch, fingerprint.ErrNoMatch}, wantAccess: []string{auth.Retry, auth.Retry, auth.Next}},
+		"Continues_with_the_broker_if_verification_fails":    {verifyErrs: []error{errors.New("device disconnected")}, wantAccess: []string{auth.Next}},
+		"Is_not_offered_if_disabled":                         {noFingerprint: true, wantNotOffered: true},
+		"Is_not_offered_to_new_users":                        {newUser: true, wantNotOffered: true},
+		"Is_not_offered_without_enrolled_finger":             {notEnrolled: true, wantNotOffered: true},
+		"Is_not_offered_if_enrolled_fingers_can_not_be_read": {enrolledErr: errors.New("fprintd error"), wantNotOffered: true},
+		"Is_not_offered_if_the_client_can_not_wait":          {supportedUILayouts: []*authd.UILayout{requiredEntry}, wantNotOffered: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dbDir := t.TempDir()
+			if !tc.newUser {
+				err := db.Z_ForTests_CreateDBFromYAML(filepath.Join(testutils.TestFamilyPath(t), "cache-with-uptodate-user.db"), dbDir)
+				require.NoError(t, err, "Setup: could not create database from testdata")
+			}
+			m, err := users.NewManager(users.DefaultConfig, dbDir)
+			require.NoError(t, err, "Setup: could not create user manager")
+			t.Cleanup(func() { _ = m.Stop() })
+
+			verifier := &fingerprintVerifierMock{enrolled: !tc.notEnrolled, enrolledErr: tc.enrolledErr, verifyErrs: tc.verifyErrs}
+			cfg := pam.DefaultConfig
+			cfg.Fingerprint = !tc.noFingerprint
+			pam.SetFingerprintVerifier(&cfg, verifier)
+			client := newPamClientWithConfig(t, m, globalBrokerManager, cfg)
+
+			// The user is the one stored in the database, so its name is not prefixed with the test name.
+			sbResp, err := client.SelectBroker(context.Background(), &authd.SBRequest{
+				BrokerId: mockBrokerGeneratedID,
+				Username: "success@example.com",
+				Mode:     authd.SessionMode_LOGIN,
+			})
+			require.NoError(t, err, "Setup: failed to create session for tests")
+			sessionID := sbResp.GetSessionId()
+
+			if tc.supportedUILayouts == nil {
+				tc.supportedUILayouts = []*authd.UILayout{requiredEntry, waitForm}
+			}
+			gamResp, err := client.GetAuthenticationModes(context.Background(), &authd.GAMRequest{
+				SessionId:          sessionID,
+				SupportedUiLayouts: tc.supportedUILayouts,
+			})
+			require.NoError(t, err, "GetAuthenticationModes should not return an error, but did")
+			modes := gamResp.GetAuthenticationModes()
+			require.NotEmpty(t, modes, "GetAuthenticationModes should return the modes of the broker")
+
+			samReq := &authd.SAMRequest{SessionId: sessionID, AuthenticationModeId: pam.FingerprintMode}
+			if tc.wantNotOffered {
+				for _, mode := range modes {
+					require.NotEqual(t, pam.FingerprintMode, mode.GetId(), "Fingerprint mode should not be offered")
+				}
+				_, err = client.SelectAuthenticationMode(context.Background(), samReq)
+				require.Error(t, err, "SelectAuthenticationMode should not allow to select the fingerprint mode")
+				return
+			}
+			require.Equal(t, pam.FingerprintMode, modes[0].GetId(), "Fingerprint mode should be offered first")
+
+			samResp, err := client.SelectAuthenticationMode(context.Background(), samReq)
+			require.NoError(t, err, "SelectAuthenticationMode should not return an error, but did")
+			require.Equal(t, layouts.Form, samResp.GetUiLayoutInfo().GetType(), "Fingerprint layout should be a form")
+			require.Equal(t, layouts.True, samResp.GetUiLayoutInfo().GetWait(), "Fingerprint layout should wait for the finger")
+
+			wantUsers, err := m.AllUsers()
+			require.NoError(t, err, "Setup: failed to get users from manager")
+			wantGroups, err := m.AllGroups()
+			require.NoError(t, err, "Setup: failed to get groups from manager")
+
+			for i, wantAccess := range tc.wantAccess {
+				iaResp, err := client.IsAuthenticated(context.Background(), &authd.IARequest{
+					SessionId:          sessionID,
+					AuthenticationData: &authd.IARequest_AuthenticationData{},
+				})
+				require.NoError(t, err, "IsAuthenticated should not return an error, but did")
+				require.Equal(t, wantAccess, iaResp.GetAccess(), "IsAuthenticated #%d should return the expected access", i)
+			}
+			require.Equal(t, len(tc.wantAccess), verifier.verifyCalls(), "Fingerprint should be verified on each authentication")
+
+			// The login is recorded, but the user information stays the one of their last login with the broker.
+			gotUsers, err := m.AllUsers()
+			require.NoError(t, err, "Setup: failed to get users from manager")
+			require.Equal(t, wantUsers, gotUsers, "Fingerprint authentication should not update the users")
+			gotGroups, err := m.AllGroups()
+			require.NoError(t, err, "Setup: failed to get groups from manager")
+			require.Equal(t, wantGroups, gotGroups, "Fingerprint authentication should not update the groups")
+
+			if tc.wantAccess[len(tc.wantAccess)-1] == auth.Next {
+				_, err = client.SelectAuthenticationMode(context.Background(), samReq)
+				require.Error(t, err, "Fingerprint mode should not be selectable once the user continued with the broker")
+			}
+		})
+	}
+}
+
+func TestMachineTokenAuthentication(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		newUser     bool
+		notEnrolled bool
+		validity    time.Duration
+		tokens      []string
+
+		wantNotOffered bool
+		wantAccess     []string
+	}{
+		"Grants_access_with_a_valid_token":            {tokens: []string{"valid"}, wantAccess: []string{auth.Granted}},
+		"Retries_with_an_invalid_token":               {tokens: []string{"invalid", "valid"}, wantAccess: []string{auth.Retry, auth.Granted}},
+		"Denies_access_after_too_many_invalid_tokens": {tokens: []string{"invalid", "invalid", "invalid"}, wantAccess: []string{auth.Retry, auth.Retry, auth.Denied}},
+		"Is_not_offered_without_enrolled_token":       {notEnrolled: true, wantNotOffered: true},
+		"Is_not_offered_to_new_users":                 {newUser: true, wantNotOffered: true},
+		"Is_not_offered_if_the_token_expired":         {validity: -time.Hour, wantNotOffered: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dbDir := t.TempDir()
+			if !tc.newUser {
+				err := db.Z_ForTests_CreateDBFromYAML(filepath.Join(testutils.TestFamilyPath(t), "cache-with-uptodate-user.db"), dbDir)
+				require.NoError(t, err, "Setup: could not create database from testdata")
+			}
+			m, err := users.NewManager(users.DefaultConfig, dbDir)
+			require.NoError(t, err, "Setup: could not create user manager")
+			t.Cleanup(func() { _ = m.Stop() })
+
+			var token string
+			if !tc.newUser && !tc.notEnrolled {
+				token, _, err = m.EnrollMachineToken("success@example.com", tc.validity)
+				require.NoError(t, err, "Setup: could not enroll machine token")
+			}
+			// The user never logged in online, which the offline limits of the login policy would deny.
+			cfg := pam.DefaultConfig
+			cfg.LoginPolicy.OfflineMaxAge = time.Hour
+			cfg.LoginPolicy.OfflineMaxLogins = 1
+			client := newPamClientWithConfig(t, m, globalBrokerManager, cfg)
+
+			// The user is the one stored in the database, so its name is not prefixed with the test name.
+			sbResp, err := client.SelectBroker(context.Background(), &authd.SBRequest{
+				BrokerId: mockBrokerGeneratedID,
+				Username: "success@example.com",
+				Mode:     authd.SessionMode_LOGIN,
+			})
+			require.NoError(t, err, "Setup: failed to create session for tests")
+			sessionID := sbResp.GetSessionId()
+
+			gamResp, err := client.GetAuthenticationModes(context.Background(), &authd.GAMRequest{
+				SessionId:          sessionID,
+				SupportedUiLayouts: []*authd.UILayout{requiredEntry},
+			})
+			require.NoError(t, err, "GetAuthenticationModes should not return an error, but did")
+			modes := gamResp.GetAuthenticationModes()
+			require.NotEmpty(t, modes, "GetAuthenticationModes should return the modes of the broker")
+
+			samReq := &authd.SAMRequest{SessionId: sessionID, AuthenticationModeId: auth.MachineTokenMode}
+			if tc.wantNotOffered {
+				for _, mode := range modes {
+					require.NotEqual(t, auth.MachineTokenMode, mode.GetId(), "Machine token mode should not be offered")
+				}
+				_, err = client.SelectAuthenticationMode(context.Background(), samReq)
+				require.Error(t, err, "SelectAuthenticationMode should not allow to select the machine token mode")
+				return
+			}
+			require.Equal(t, auth.MachineTokenMode, modes[len(modes)-1].GetId(), "Machine token mode should be offered last")
+
+			samResp, err := client.SelectAuthenticationMode(context.Background(), samReq)
+			require.NoError(t, err, "SelectAuthenticationMode should not return an error, but did")
+			require.Equal(t, layouts.Form, samResp.GetUiLayoutInfo().GetType(), "Machine token layout should be a form")
+			require.Equal(t, entries.CharsPassword, samResp.GetUiLayoutInfo().GetEntry(), "Machine token layout should hide the token")
+
+			wantUsers, err := m.AllUsers()
+			require.NoError(t, err, "Setup: failed to get users from manager")
+
+			for i, wantAccess := range tc.wantAccess {
+				sent := token
+				if tc.tokens[i] == "invalid" {
+					sent = "not-the-token"
+				}
+				iaResp, err := client.IsAuthenticated(context.Background(), &authd.IARequest{
+					SessionId: sessionID,
+					AuthenticationData: &authd.IARequest_AuthenticationData{
+						Item: &authd.IARequest_AuthenticationData_MachineToken{MachineToken: sent},
+					},
+				})
+				require.NoError(t, err, "IsAuthenticated should not return an error, but did")
+				require.Equal(t, wantAccess, iaResp.GetAccess(), "IsAuthenticated #%d should return the expected access", i)
+			}
+
+			// The user information stays the one of their last login with the broker.
+			gotUsers, err := m.AllUsers()
+			require.NoError(t, err, "Setup: failed to get users from manager")
+			require.Equal(t, wantUsers, gotUsers, "Machine token authentication should not update the users")
+
+			history, err := m.LoginHistory("success@example.com", 1)
+			require.NoError(t, err, "Setup: failed to get the login history")
+			require.Len(t, history, 1, "Machine token authentication should be recorded")
+			require.True(t, history[0].MachineToken, "Machine token authentication should be recorded as such")
+			require.False(t, history[0].Offline, "Machine token authentication should not be recorded as an offline login")
+			lastOnline, err := m.LastOnlineLogin("success@example.com")
+			require.NoError(t, err, "Setup: failed to get the last online login")
+			require.Zero(t, lastOnline, "Machine token authentication should not be recorded as an online login")
+		})
+	}
+}
+
+func TestTOTPAuthentication(t *testing.T) {
+	t.Parallel()
+
+	validCode := func(secret []byte) string { return totp.Code(secret, totp.Step(time.Now())) }
+	invalidCode := func(secret []byte) string { return totp.Code(secret, totp.Step(time.Now())+5) }
+
+	tests := map[string]struct {
+		username    string
+		notEnrolled bool
+		codes       []func(secret []byte) string
+
+		wantNotRequired bool
+		wantAccess      []string
+	}{
+		"Grants_access_with_a_valid_code":            {codes: []func([]byte) string{validCode}, wantAccess: []string{auth.Granted}},
+		"Retries_with_an_invalid_code":               {codes: []func([]byte) string{invalidCode, validCode}, wantAccess: []string{auth.Retry, auth.Granted}},
+		"Denies_access_after_too_many_invalid_codes": {codes: []func([]byte) string{invalidCode, invalidCode, invalidCode}, wantAccess: []string{auth.Retry, auth.Retry, auth.Denied}},
+		"Is_not_required_without_enrolled_secret":    {notEnrolled: true, wantNotRequired: true},
+		"Is_not_required_when_authenticating_online": {username: "totp_online@example.com", wantNotRequired: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.username == "" {
+				tc.username = "ia_granted_offline@example.com"
+			}
+
+			m, err := users.NewManager(users.DefaultConfig, t.TempDir())
+			require.NoError(t, err, "Setup: could not create user manager")
+			t.Cleanup(func() { _ = m.Stop() })
+			client := newPamClient(t, m, globalBrokerManager)
+
+			// The user is added to the database on their first login.
+			iaReq := &authd.IARequest{SessionId: startSession(t, client, tc.username), AuthenticationData: &authd.IARequest_AuthenticationData{}}
+			iaResp, err := client.IsAuthenticated(context.Background(), iaReq)
+			require.NoError(t, err, "Setup: IsAuthenticated should not return an error, but did")
+			require.Equal(t, auth.Granted, iaResp.GetAccess(), "Setup: First login should be granted")
+
+			var secret []byte
+			if !tc.notEnrolled {
+				uri, err := m.EnrollTOTP(tc.username)
+				require.NoError(t, err, "Setup: could not enroll TOTP secret")
+				u, err := url.Parse(uri)
+				require.NoError(t, err, "Setup: EnrollTOTP should return a valid URI")
+				secret, err = base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(u.Query().Get("secret"))
+				require.NoError(t, err, "Setup: EnrollTOTP should return the secret in base32")
+			}
+
+			sessionID := startSession(t, client, tc.username)
+			iaResp, err = client.IsAuthenticated(context.Background(), &authd.IARequest{
+				SessionId:          sessionID,
+				AuthenticationData: &authd.IARequest_AuthenticationData{},
+			})
+			require.NoError(t, err, "IsAuthenticated should not return an error, but did")
+			if tc.wantNotRequired {
+				require.Equal(t, auth.Granted, iaResp.GetAccess(), "IsAuthenticated should grant access without TOTP code")
+				return
+			}
+			require.Equal(t, auth.Next, iaResp.GetAccess(), "IsAuthenticated should ask for a TOTP code")
+
+			gamResp, err := client.GetAuthenticationModes(context.Background(), &authd.GAMRequest{
+				SessionId:          sessionID,
+				SupportedUiLayouts: []*authd.UILayout{requiredEntry},
+			})
+			require.NoError(t, err, "GetAuthenticationModes should not return an error, but did")
+			require.Len(t, gamResp.GetAuthenticationModes(), 1, "Only the TOTP mode should be offered")
+			require.Equal(t, auth.TOTPMode, gamResp.GetAuthenticationModes()[0].GetId(), "Only the TOTP mode should be offered")
+
+			_, err = client.SelectAuthenticationMode(context.Background(), &authd.SAMRequest{SessionId: sessionID, AuthenticationModeId: "password"})
+			require.Error(t, err, "SelectAuthenticationMode should not allow to select another mode")
+			samResp, err := client.SelectAuthenticationMode(context.Background(), &authd.SAMRequest{SessionId: sessionID, AuthenticationModeId: auth.TOTPMode})
+			require.NoError(t, err, "SelectAuthenticationMode should not return an error, but did")
+			require.Equal(t, layouts.Form, samResp.GetUiLayoutInfo().GetType(), "TOTP layout should be a form")
+			require.Equal(t, entries.Digits, samResp.GetUiLayoutInfo().GetEntry(), "TOTP layout should have an entry for digits")
+
+			for i, code := range tc.codes {
+				iaResp, err := client.IsAuthenticated(context.Background(), &authd.IARequest{
+					SessionId: sessionID,
+					AuthenticationData: &authd.IARequest_AuthenticationData{
+						Item: &authd.IARequest_AuthenticationData_TotpCode{TotpCode: code(secret)},
+					},
+				})
+				require.NoError(t, err, "IsAuthenticated should not return an error, but did")
+				require.Equal(t, tc.wantAccess[i], iaResp.GetAccess(), "IsAuthenticated #%d should return the expected access", i)
+			}
+		})
+	}
+}
+
+//nolint:tparallel // The sessions of the subtests share the same ID, so they can't run in parallel.
+func TestLastAuthMode(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		selectedMode string
+		sessionMode  authd.SessionMode
+		denied       bool
+
+		wantFirstMode string
+	}{
+		"Preselect_the_mode_of_the_last_login":           {selectedMode: "mode2", wantFirstMode: "mode2"},
+		"Keep_the_order_when_the_first_mode_was_used":    {selectedMode: "mode1", wantFirstMode: "mode1"},
+		"Do_not_preselect_the_mode_of_a_password_change": {selectedMode: "mode2", sessionMode: authd.SessionMode_CHANGE_PASSWORD, wantFirstMode: "mode1"},
+		"Do_not_preselect_the_mode_without_a_login":      {selectedMode: "mode2", denied: true, wantFirstMode: "mode1"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := newPamClient(t, nil, globalBrokerManager)
+			// The user returned by the broker is not prefixed with the test name, so neither is the one of the sessions.
+			username := "last_auth_mode@example.com"
+			if tc.sessionMode == authd.SessionMode_UNDEFINED {
+				tc.sessionMode = authd.SessionMode_LOGIN
+			}
+
+			sbResp, err := client.SelectBroker(context.Background(), &authd.SBRequest{
+				BrokerId: mockBrokerGeneratedID,
+				Username: username,
+				Mode:     tc.sessionMode,
+			})
+			require.NoError(t, err, "Setup: failed to create session for tests")
+			sessionID := sbResp.GetSessionId()
+
+			gamReq := &authd.GAMRequest{SessionId: sessionID, SupportedUiLayouts: []*authd.UILayout{requiredEntry}}
+			_, err = client.GetAuthenticationModes(context.Background(), gamReq)
+			require.NoError(t, err, "Setup: GetAuthenticationModes should not return an error")
+			_, err = client.SelectAuthenticationMode(context.Background(), &authd.SAMRequest{
+				SessionId:            sessionID,
+				AuthenticationModeId: tc.selectedMode,
+			})
+			require.NoError(t, err, "Setup: SelectAuthenticationMode should not return an error")
+			if !tc.denied {
+				iaResp, err := client.IsAuthenticated(context.Background(), &authd.IARequest{
+					SessionId:          sessionID,
+					AuthenticationData: &authd.IARequest_AuthenticationData{},
+				})
+				require.NoError(t, err, "Setup: IsAuthenticated should not return an error")
+				require.Equal(t, auth.Granted, iaResp.GetAccess(), "Setup: IsAuthenticated should grant access")
+			}
+			_, err = client.EndSession(context.Background(), &authd.ESRequest{SessionId: sessionID})
+			require.NoError(t, err, "Setup: EndSession should not return an error")
+
+			sbResp, err = client.SelectBroker(context.Background(), &authd.SBRequest{
+				BrokerId: mockBrokerGeneratedID,
+				Username: username,
+				Mode:     authd.SessionMode_LOGIN,
+			})
+			require.NoError(t, err, "Setup: failed to create session for tests")
+
+			gamReq.SessionId = sbResp.GetSessionId()
+			gamResp, err := client.GetAuthenticationModes(context.Background(), gamReq)
+			require.NoError(t, err, "GetAuthenticationModes should not return an error, but did")
+			modes := gamResp.GetAuthenticationModes()
+			require.Len(t, modes, 2, "GetAuthenticationModes should return all the modes of the broker")
+			require.Equal(t, tc.wantFirstMode, modes[0].GetId(), "GetAuthenticationModes should return the expected mode first")
+		})
+	}
+}
+
 func TestIDGeneration(t *testing.T) {
 	t.Parallel()
 	usernamePrefix := t.Name()
@@ -751,6 +1364,535 @@ func TestEndSession(t *testing.T) {
 	}
 }
 
+func TestConverse(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		sessionID        string
+		username         string
+		cancelFirstCall  bool
+		noStreamSupport  bool
+		leaveSessionOpen bool
+	}{
+		"Successfully_authenticate":                            {username: "success@example.com"},
+		"Successfully_authenticate_if_first_call_is_canceled":  {username: "ia_second_call@example.com", cancelFirstCall: true},
+		"Successfully_authenticate_if_streams_are_unsupported": {username: "success@example.com", noStreamSupport: true},
+		"End_sessions_left_open_when_the_conversation_ends":    {username: "success@example.com", leaveSessionOpen: true},
+
+		"Error_when_there_is_no_broker": {sessionID: "invalid-session"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var wrap func(pam.Service) authd.PAMServer
+			if tc.noStreamSupport {
+				wrap = func(s pam.Service) authd.PAMServer { return noConverseService{s} }
+			}
+			client := conversation.New(newPamConn(t, nil, globalBrokerManager, pam.DefaultConfig, wrap))
+			t.Cleanup(client.Close)
+
+			if tc.sessionID == "" {
+				tc.sessionID = startSession(t, client, tc.username)
+			}
+
+			isAuthenticated := func(ctx context.Context, call string) string {
+				iaResp, err := client.IsAuthenticated(ctx, &authd.IARequest{
+					SessionId:          tc.sessionID,
+					AuthenticationData: &authd.IARequest_AuthenticationData{},
+				})
+				return fmt.Sprintf("%s CALL:\n\taccess: %s\n\tmsg: %s\n\terr: %v\n",
+					call, iaResp.GetAccess(), iaResp.GetMsg(), err)
+			}
+
+			var got string
+			if tc.cancelFirstCall {
+				ctx, cancel := context.WithCancel(context.Background())
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					got = isAuthenticated(ctx, "FIRST")
+				}()
+				// Give some time for the first call to block
+				time.Sleep(time.Second)
+				cancel()
+				<-done
+			}
+			got += isAuthenticated(context.Background(), "LAST")
+			golden.CheckOrUpdate(t, got)
+
+			if tc.sessionID == "invalid-session" {
+				return
+			}
+
+			if tc.leaveSessionOpen {
+				client.Close()
+				require.Eventually(t, func() bool {
+					_, err := globalBrokerManager.BrokerFromSessionID(tc.sessionID)
+					return err != nil
+				}, 5*time.Second, 10*time.Millisecond, "The session should be ended when the conversation ends")
+				return
+			}
+
+			_, err := client.EndSession(context.Background(), &authd.ESRequest{SessionId: tc.sessionID})
+			require.NoError(t, err, "EndSession should not return an error, but did")
+			_, err = globalBrokerManager.BrokerFromSessionID(tc.sessionID)
+			require.Error(t, err, "The session should be ended")
+		})
+	}
+}
+
+// noConverseService is a PAM service of a daemon which doesn't support the Converse stream.
+type noConverseService struct {
+	pam.Service
+}
+
+func (noConverseService) Converse(authd.PAM_ConverseServer) error {
+	return status.Error(codes.Unimplemented, "method Converse not implemented")
+}
+
+func TestNotifySession(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		event    authd.NSRequest_Event
+		username string
+
+		wantHookOutput string
+		wantEnv        map[string]string
+		wantErr        bool
+	}{
+		"Run_hooks_when_session_of_group_member_starts": {
+			event: authd.NSRequest_START, username: "contractor@example.com",
+			wantHookOutput: "all start contractor@example.com 1111 broker-id sshd pts/1 192.0.2.1\n" +
+				"contractors start contractor@example.com 1111 broker-id sshd pts/1 192.0.2.1\n",
+			wantEnv: map[string]string{"EMPLOYEE_ID": "1234", "IDP_TENANT": "example-tenant"},
+		},
+		"Run_hooks_when_session_of_group_member_stops": {
+			event: authd.NSRequest_STOP, username: "contractor@example.com",
+			wantHookOutput: "all stop contractor@example.com 1111 broker-id sshd pts/1 192.0.2.1\n" +
+				"contractors stop contractor@example.com 1111 broker-id sshd pts/1 192.0.2.1\n",
+		},
+		"Only_run_hooks_for_all_users_when_user_is_not_in_group": {
+			event: authd.NSRequest_START, username: "employee@example.com",
+			wantHookOutput: "all start employee@example.com 2222 broker-id sshd pts/1 192.0.2.1\n",
+		},
+		"Username_is_case_insensitive": {
+			event: authd.NSRequest_START, username: "Employee@example.com",
+			wantHookOutput: "all start employee@example.com 2222 broker-id sshd pts/1 192.0.2.1\n",
+		},
+
+		"Error_when_user_is_not_an_authd_user": {event: authd.NSRequest_START, username: "nonexistent@example.com", wantErr: true},
+		"Error_when_username_is_empty":         {event: authd.NSRequest_START, wantErr: true},
+		"Error_when_event_is_unspecified":      {username: "contractor@example.com", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join(testutils.TestFamilyPath(t), "notify-session.db"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m, err := users.NewManager(users.DefaultConfig, dbDir)
+			require.NoError(t, err, "Setup: could not create user manager")
+			t.Cleanup(func() { _ = m.Stop() })
+
+			outputPath := filepath.Join(t.TempDir(), "hooks-output")
+			writeHook := func(name string) string {
+				path := filepath.Join(t.TempDir(), name)
+				script := fmt.Sprintf("#!/bin/sh\necho %s \"$1\" \"$AUTHD_SESSION_USER\" \"$AUTHD_SESSION_UID\" "+
+					"\"$AUTHD_SESSION_BROKER\" \"$AUTHD_SESSION_SERVICE\" \"$AUTHD_SESSION_TTY\" \"$AUTHD_SESSION_RHOST\" >> %q\n",
+					name, outputPath)
+				require.NoError(t, os.WriteFile(path, []byte(script), 0700), "Setup: could not write hook")
+				return path
+			}
+
+			cfg := pam.DefaultConfig
+			cfg.SessionHooks.Hooks = []sessionhooks.Hook{
+				{Exec: writeHook("all")},
+				{Groups: []string{"contractors"}, Exec: writeHook("contractors")},
+			}
+			client := newPamClientWithConfig(t, m, globalBrokerManager, cfg)
+
+			resp, err := client.NotifySession(context.Background(), &authd.NSRequest{
+				Event:    tc.event,
+				Username: tc.username,
+				Service:  "sshd",
+				Tty:      "pts/1",
+				Rhost:    "192.0.2.1",
+			})
+			if tc.wantErr {
+				require.Error(t, err, "NotifySession should return an error, but did not")
+				require.NoFileExists(t, outputPath, "No hook should have been run")
+				return
+			}
+			require.NoError(t, err, "NotifySession should not return an error, but did")
+
+			got, err := os.ReadFile(outputPath)
+			require.NoError(t, err, "Hooks should have been run")
+			require.Equal(t, tc.wantHookOutput, string(got), "Unexpected hooks output")
+			require.Equal(t, tc.wantEnv, resp.GetEnv(), "Unexpected session environment")
+		})
+	}
+}
+
+func TestNotifySessionCredentialHooks(t *testing.T) {
+	t.Parallel()
+
+	// The user gets the UID of the test process, so that the hooks can be run as them.
+	m, err := users.NewManager(users.DefaultConfig, t.TempDir(), users.WithIDGenerator(&users.IDGeneratorMock{
+		UIDsToGenerate: []uint32{uint32(os.Getuid())},
+		GIDsToGenerate: []uint32{22222},
+	}))
+	require.NoError(t, err, "Setup: could not create user manager")
+	t.Cleanup(func() { _ = m.Stop() })
+
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.json")
+	hookPath := filepath.Join(dir, "kinit")
+	script := fmt.Sprintf("#!/bin/sh\ncat > %q\necho KRB5CCNAME=KCM:\n", inputPath)
+	require.NoError(t, os.WriteFile(hookPath, []byte(script), 0700), "Setup: could not write hook")
+
+	cfg := pam.DefaultConfig
+	cfg.CredentialHooks.Hooks = []credentialhooks.Hook{
+		{Name: "kerberos", Exec: hookPath, TokenScopes: []string{"api://kerberos/.default"}},
+		{Name: "failing", Exec: "/bin/false"},
+	}
+	client := newPamClientWithConfig(t, m, globalBrokerManager, cfg)
+
+	resp, err := client.IsAuthenticated(context.Background(), &authd.IARequest{
+		SessionId:          startSession(t, client, "success@example.com"),
+		AuthenticationData: &authd.IARequest_AuthenticationData{},
+	})
+	require.NoError(t, err, "Setup: IsAuthenticated should not return an error")
+	require.Equal(t, auth.Granted, resp.GetAccess(), "Setup: IsAuthenticated should grant access")
+
+	nsResp, err := client.NotifySession(context.Background(), &authd.NSRequest{
+		Event:    authd.NSRequest_START,
+		Username: "success@example.com",
+		Service:  "sshd",
+	})
+	require.NoError(t, err, "NotifySession should not return an error, but did")
+	require.Equal(t, map[string]string{"KRB5CCNAME": "KCM:"}, nsResp.GetEnv(), "The session should be pointed to the credentials")
+
+	input, err := os.ReadFile(inputPath)
+	require.NoError(t, err, "The credential hook should have been run")
+	require.Contains(t, string(input), `"access_token":"access-token-success@example.com"`, "The hook should receive the token of the user")
+
+	require.NoError(t, os.Remove(inputPath), "Setup: could not remove hook input")
+	_, err = client.NotifySession(context.Background(), &authd.NSRequest{
+		Event:    authd.NSRequest_STOP,
+		Username: "success@example.com",
+	})
+	require.NoError(t, err, "NotifySession should not return an error, but did")
+	require.NoFileExists(t, inputPath, "The credential hooks should only be run when the session starts")
+}
+
+func TestNotifySessionEphemeralUsers(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		ephemeralUsers bool
+		events         []authd.NSRequest_Event
+
+		wantHomes       []bool
+		wantUserDeleted bool
+	}{
+		"Mount_home_on_first_session_and_destroy_it_on_last_session": {
+			ephemeralUsers:  true,
+			events:          []authd.NSRequest_Event{authd.NSRequest_START, authd.NSRequest_START, authd.NSRequest_STOP, authd.NSRequest_STOP},
+			wantHomes:       []bool{true, false, false, true},
+			wantUserDeleted: true,
+		},
+		"Destroy_home_on_stop_of_unknown_session": {
+			ephemeralUsers:  true,
+			events:          []authd.NSRequest_Event{authd.NSRequest_STOP},
+			wantHomes:       []bool{true},
+			wantUserDeleted: true,
+		},
+
+		"No_temporary_home_without_ephemeral_users": {
+			events:    []authd.NSRequest_Event{authd.NSRequest_START, authd.NSRequest_STOP},
+			wantHomes: []bool{false, false},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join(testutils.TestFamilyPath(t), "notify-session.db"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m, err := users.NewManager(users.DefaultConfig, dbDir)
+			require.NoError(t, err, "Setup: could not create user manager")
+			t.Cleanup(func() { _ = m.Stop() })
+
+			cfg := pam.DefaultConfig
+			cfg.EphemeralUsers = tc.ephemeralUsers
+			client := newPamClientWithConfig(t, m, globalBrokerManager, cfg)
+
+			for i, event := range tc.events {
+				resp, err := client.NotifySession(context.Background(), &authd.NSRequest{
+					Event:    event,
+					Username: "employee@example.com",
+				})
+				require.NoError(t, err, "NotifySession should not return an error, but did")
+
+				if !tc.wantHomes[i] {
+					require.Nil(t, resp.GetEphemeralHome(), "Event %d should not return a temporary home", i)
+					continue
+				}
+				home := resp.GetEphemeralHome()
+				require.NotNil(t, home, "Event %d should return the temporary home", i)
+				require.Equal(t, "/home/employee@example.com", home.GetPath(), "Unexpected temporary home path")
+				require.Equal(t, uint32(2222), home.GetUid(), "Unexpected temporary home owner")
+				require.Equal(t, uint32(22222), home.GetGid(), "Unexpected temporary home group")
+			}
+
+			_, err = m.UserByName("employee@example.com")
+			if tc.wantUserDeleted {
+				require.ErrorIs(t, err, users.NoDataFoundError{}, "Ephemeral user should have been deleted")
+				return
+			}
+			require.NoError(t, err, "User should not have been deleted")
+		})
+	}
+}
+
+func TestNotifySessionHomedHome(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		homed  bool
+		events []authd.NSRequest_Event
+
+		wantHomes []bool
+	}{
+		"Activate_home_on_first_session_and_deactivate_it_on_last_session": {
+			homed:     true,
+			events:    []authd.NSRequest_Event{authd.NSRequest_START, authd.NSRequest_START, authd.NSRequest_STOP, authd.NSRequest_STOP},
+			wantHomes: []bool{true, false, false, true},
+		},
+		"Deactivate_home_on_stop_of_unknown_session": {
+			homed:     true,
+			events:    []authd.NSRequest_Event{authd.NSRequest_STOP},
+			wantHomes: []bool{true},
+		},
+
+		"No_homed_home_for_user_with_plain_home": {
+			events:    []authd.NSRequest_Event{authd.NSRequest_START, authd.NSRequest_STOP},
+			wantHomes: []bool{false, false},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join(testutils.TestFamilyPath(t), "notify-session.db"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			if tc.homed {
+				// The secrets of the homes created with systemd-homed are stored next to the database.
+				secretsDir := filepath.Join(dbDir, "homed-secrets")
+				require.NoError(t, os.Mkdir(secretsDir, 0700), "Setup: could not create secrets directory")
+				err := os.WriteFile(filepath.Join(secretsDir, "employee@example.com"), []byte("s3cr3t"), 0600)
+				require.NoError(t, err, "Setup: could not write secret")
+			}
+			m, err := users.NewManager(users.DefaultConfig, dbDir)
+			require.NoError(t, err, "Setup: could not create user manager")
+			t.Cleanup(func() { _ = m.Stop() })
+
+			client := newPamClientWithConfig(t, m, globalBrokerManager, pam.DefaultConfig)
+
+			for i, event := range tc.events {
+				resp, err := client.NotifySession(context.Background(), &authd.NSRequest{
+					Event:    event,
+					Username: "employee@example.com",
+				})
+				require.NoError(t, err, "NotifySession should not return an error, but did")
+
+				if !tc.wantHomes[i] {
+					require.Nil(t, resp.GetHomedHome(), "Event %d should not return a systemd-homed home", i)
+					continue
+				}
+				home := resp.GetHomedHome()
+				require.NotNil(t, home, "Event %d should return the systemd-homed home", i)
+				require.Equal(t, "employee@example.com", home.GetUserName(), "Unexpected systemd-homed home user")
+				if event == authd.NSRequest_START {
+					require.Equal(t, "s3cr3t", home.GetSecret(), "The secret should be returned to activate the home")
+					continue
+				}
+				require.Empty(t, home.GetSecret(), "The secret should not be returned to deactivate the home")
+			}
+		})
+	}
+}
+
+func TestNotifySessionNetworkHome(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		brokers          []string
+		users            []string
+		mountUnit        bool
+		userOfMockBroker bool
+		event            authd.NSRequest_Event
+
+		wantHome      bool
+		wantMountUnit string
+	}{
+		"Network_home_of_user_of_listed_broker": {brokers: []string{"BrokerMock"}, userOfMockBroker: true, wantHome: true},
+		"Network_home_of_listed_user":           {users: []string{"employee@example.com"}, wantHome: true},
+		"User_names_are_case_insensitive":       {users: []string{"Employee@example.com"}, wantHome: true},
+		"Network_home_when_broker_is_unknown":   {brokers: []string{"BrokerMock"}, wantHome: true},
+		"Network_home_with_mount_unit": {
+			users: []string{"employee@example.com"}, mountUnit: true,
+			wantHome: true, wantMountUnit: `home-employee\x40example.com.mount`,
+		},
+
+		"No_network_home_for_user_of_other_broker": {brokers: []string{"OtherBroker"}, userOfMockBroker: true},
+		"No_network_home_for_other_users":          {users: []string{"contractor@example.com"}},
+		"No_network_home_when_session_stops":       {users: []string{"employee@example.com"}, event: authd.NSRequest_STOP},
+		"No_network_home_without_configuration":    {},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join(testutils.TestFamilyPath(t), "notify-session.db"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m, err := users.NewManager(users.DefaultConfig, dbDir)
+			require.NoError(t, err, "Setup: could not create user manager")
+			t.Cleanup(func() { _ = m.Stop() })
+			if tc.userOfMockBroker {
+				err := m.UpdateBrokerForUser("employee@example.com", mockBrokerGeneratedID)
+				require.NoError(t, err, "Setup: could not set the broker of the user")
+			}
+			if tc.event == authd.NSRequest_UNSPECIFIED {
+				tc.event = authd.NSRequest_START
+			}
+
+			cfg := pam.DefaultConfig
+			cfg.NetworkHome = pam.NetworkHomeConfig{Brokers: tc.brokers, Users: tc.users, MountUnit: tc.mountUnit}
+			client := newPamClientWithConfig(t, m, globalBrokerManager, cfg)
+
+			resp, err := client.NotifySession(context.Background(), &authd.NSRequest{
+				Event:    tc.event,
+				Username: "employee@example.com",
+			})
+			require.NoError(t, err, "NotifySession should not return an error, but did")
+
+			home := resp.GetNetworkHome()
+			if !tc.wantHome {
+				require.Nil(t, home, "NotifySession should not return a network home")
+				return
+			}
+			require.NotNil(t, home, "NotifySession should return the network home")
+			require.Equal(t, "/home/employee@example.com", home.GetPath(), "Unexpected network home path")
+			require.Equal(t, uint32(2222), home.GetUid(), "Unexpected network home owner")
+			require.Equal(t, tc.wantMountUnit, home.GetMountUnit(), "Unexpected network home mount unit")
+		})
+	}
+}
+
+func TestNotifySessionFscryptHome(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		fscryptHomes bool
+		networkHome  bool
+		event        authd.NSRequest_Event
+
+		wantHome bool
+	}{
+		"Encrypted_home_when_session_starts": {fscryptHomes: true, wantHome: true},
+
+		"No_encrypted_home_when_session_stops":    {fscryptHomes: true, event: authd.NSRequest_STOP},
+		"No_encrypted_home_for_network_home":      {fscryptHomes: true, networkHome: true},
+		"No_encrypted_home_without_fscrypt_homes": {},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join(testutils.TestFamilyPath(t), "notify-session.db"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m, err := users.NewManager(users.DefaultConfig, dbDir)
+			require.NoError(t, err, "Setup: could not create user manager")
+			t.Cleanup(func() { _ = m.Stop() })
+			if tc.event == authd.NSRequest_UNSPECIFIED {
+				tc.event = authd.NSRequest_START
+			}
+
+			cfg := pam.DefaultConfig
+			cfg.FscryptHomes = tc.fscryptHomes
+			if tc.networkHome {
+				cfg.NetworkHome = pam.NetworkHomeConfig{Users: []string{"employee@example.com"}}
+			}
+			client := newPamClientWithConfig(t, m, globalBrokerManager, cfg)
+
+			resp, err := client.NotifySession(context.Background(), &authd.NSRequest{
+				Event:    tc.event,
+				Username: "employee@example.com",
+			})
+			require.NoError(t, err, "NotifySession should not return an error, but did")
+
+			home := resp.GetFscryptHome()
+			if !tc.wantHome {
+				require.Nil(t, home, "NotifySession should not return an encrypted home")
+				return
+			}
+			require.NotNil(t, home, "NotifySession should return the encrypted home")
+			require.Equal(t, "/home/employee@example.com", home.GetPath(), "Unexpected encrypted home path")
+			require.Equal(t, "employee@example.com", home.GetUserName(), "Unexpected encrypted home user")
+			require.Equal(t, uint32(2222), home.GetUid(), "Unexpected encrypted home owner")
+			require.Equal(t, uint32(22222), home.GetGid(), "Unexpected encrypted home group")
+		})
+	}
+}
+
+func TestValidateFscryptHomes(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, pam.Config{FscryptHomes: true}.ValidateFscryptHomes(), "ValidateFscryptHomes should accept fscrypt homes")
+	require.Error(t, pam.Config{FscryptHomes: true, EphemeralUsers: true}.ValidateFscryptHomes(),
+		"ValidateFscryptHomes should refuse fscrypt homes with ephemeral users")
+}
+
+func TestValidateNetworkHome(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cfg pam.Config
+
+		wantErr bool
+	}{
+		"Valid_without_network_homes": {},
+		"Valid_with_network_homes":    {cfg: pam.Config{NetworkHome: pam.NetworkHomeConfig{Brokers: []string{"Google"}, Users: []string{"user@example.com"}}}},
+
+		"Error_with_empty_broker_name": {cfg: pam.Config{NetworkHome: pam.NetworkHomeConfig{Brokers: []string{""}}}, wantErr: true},
+		"Error_with_empty_user_name":   {cfg: pam.Config{NetworkHome: pam.NetworkHomeConfig{Users: []string{""}}}, wantErr: true},
+		"Error_with_ephemeral_users": {
+			cfg:     pam.Config{EphemeralUsers: true, NetworkHome: pam.NetworkHomeConfig{Users: []string{"user@example.com"}}},
+			wantErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.cfg.ValidateNetworkHome()
+			if tc.wantErr {
+				require.Error(t, err, "ValidateNetworkHome should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "ValidateNetworkHome should not return an error, but did")
+		})
+	}
+}
+
 // initBrokers starts dbus mock brokers on the system bus. It returns its config path.
 func initBrokers() (brokerConfigPath string, cleanup func(), err error) {
 	tmpDir, err := os.MkdirTemp("", "authd-internal-pam-tests-")
@@ -780,6 +1922,21 @@ func initBrokers() (brokerConfigPath string, cleanup func(), err error) {
 func newPamClient(t *testing.T, m *users.Manager, brokerManager *brokers.Manager) (client authd.PAMClient) {
 	t.Helper()
 
+	return newPamClientWithConfig(t, m, brokerManager, pam.DefaultConfig)
+}
+
+// newPamClientWithConfig is like newPamClient, but the PAM service is created with cfg.
+func newPamClientWithConfig(t *testing.T, m *users.Manager, brokerManager *brokers.Manager, cfg pam.Config) (client authd.PAMClient) {
+	t.Helper()
+
+	return authd.NewPAMClient(newPamConn(t, m, brokerManager, cfg, nil))
+}
+
+// newPamConn returns a new GRPC connection to a PAM service created with cfg. If wrap is not nil, the service
+// registered on the server is the one it returns.
+func newPamConn(t *testing.T, m *users.Manager, brokerManager *brokers.Manager, cfg pam.Config, wrap func(pam.Service) authd.PAMServer) *grpc.ClientConn {
+	t.Helper()
+
 	// socket path is limited in length.
 	tmpDir, err := os.MkdirTemp("", "authd-socket-dir")
 	require.NoError(t, err, "Setup: could not setup temporary socket dir path")
@@ -795,10 +1952,15 @@ func newPamClient(t *testing.T, m *users.Manager, brokerManager *brokers.Manager
 		t.Cleanup(func() { _ = m.Stop() })
 	}
 
-	service := pam.NewService(context.Background(), m, brokerManager, pam.DefaultConfig)
+	pm := permissions.New(permissions.Z_ForTests_WithCurrentUserAsRoot())
+	service := pam.NewService(context.Background(), m, brokerManager, &pm, cfg)
 
 	grpcServer := grpc.NewServer(permissions.WithUnixPeerCreds(), grpc.ChainUnaryInterceptor(errmessages.RedactErrorInterceptor))
-	authd.RegisterPAMServer(grpcServer, service)
+	var server authd.PAMServer = service
+	if wrap != nil {
+		server = wrap(service)
+	}
+	authd.RegisterPAMServer(grpcServer, server)
 	done := make(chan struct{})
 	go func() {
 		defer close(done)
@@ -814,7 +1976,7 @@ func newPamClient(t *testing.T, m *users.Manager, brokerManager *brokers.Manager
 
 	t.Cleanup(func() { _ = conn.Close() }) // We don't care about the error on cleanup
 
-	return authd.NewPAMClient(conn)
+	return conn
 }
 
 // getMockBrokerGeneratedID returns the generated ID for the mock broker.
@@ -828,6 +1990,55 @@ func getMockBrokerGeneratedID(brokerManager *brokers.Manager) (string, error) {
 	return "", errors.New("Setup: could not find generated broker mock ID in the broker manager list")
 }
 
+// fingerprintVerifierMock is a fingerprint.Verifier returning the errors of verifyErrs in turn.
+type fingerprintVerifierMock struct {
+	enrolled    bool
+	enrolledErr error
+	verifyErrs  []error
+
+	calls int
+	mu    sync.Mutex
+}
+
+func (v *fingerprintVerifierMock) Enrolled(context.Context, string) (bool, error) {
+	return v.enrolled, v.enrolledErr
+}
+
+func (v *fingerprintVerifierMock) Verify(context.Context, string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.calls >= len(v.verifyErrs) {
+		return errors.New("unexpected fingerprint verification")
+	}
+	err := v.verifyErrs[v.calls]
+	v.calls++
+	return err
+}
+
+func (v *fingerprintVerifierMock) verifyCalls() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.calls
+}
+
+// directoryMock is an ldap.Directory returning the accounts, or err if it's set.
+type directoryMock struct {
+	accounts map[string]ldap.Account
+	err      error
+}
+
+func (d *directoryMock) LookupUser(_ context.Context, name string) (ldap.Account, error) {
+	if d.err != nil {
+		return ldap.Account{}, d.err
+	}
+	a, ok := d.accounts[name]
+	if !ok {
+		return ldap.Account{}, ldap.ErrNoSuchUser
+	}
+	return a, nil
+}
+
 // startSession is a helper that starts a session on the mock broker.
 func startSession(t *testing.T, client authd.PAMClient, username string) string {
 	t.Helper()
@@ -871,6 +2082,7 @@ func setupGlobalBrokerMock() (cleanup func(), err error) {
 	}
 
 	// Get manager shared across grpc services.
+	globalBrokersConfPath = brokersConfPath
 	globalBrokerManager, err = brokers.NewManager(context.Background(), brokersConfPath, nil)
 	if err != nil {
 		return cleanup, err
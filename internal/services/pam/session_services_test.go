@@ -0,0 +1,35 @@
+package pam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionServices(t *testing.T) {
+	t.Parallel()
+
+	s := &sessionServices{services: make(map[string]sessionService)}
+
+	s.set("session1", "sshd")
+	require.Equal(t, "sshd", s.get("session1"), "The service of the session should be remembered")
+
+	s.remove("session1")
+	require.Empty(t, s.get("session1"), "The service of a removed session should be forgotten")
+	require.Empty(t, s.services, "No session should be tracked after the removal")
+}
+
+func TestSessionServices_PrunesAbandonedSessions(t *testing.T) {
+	t.Parallel()
+
+	s := &sessionServices{services: make(map[string]sessionService)}
+	s.services["abandoned"] = sessionService{service: "sshd", started: time.Now().Add(-sessionServiceMaxAge)}
+	s.services["ongoing"] = sessionService{service: "gdm-authd", started: time.Now().Add(-time.Minute)}
+
+	s.set("new", "login")
+
+	require.Empty(t, s.get("abandoned"), "The abandoned session should be pruned")
+	require.Equal(t, "gdm-authd", s.get("ongoing"), "The ongoing session should be kept")
+	require.Equal(t, "login", s.get("new"), "The new session should be tracked")
+}
@@ -0,0 +1,144 @@
+package pam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/canonical/authd/internal/brokers/auth"
+	"github.com/canonical/authd/internal/brokers/layouts"
+	"github.com/canonical/authd/internal/brokers/layouts/entries"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+// maxTOTPAttempts is the number of invalid TOTP codes after which the authentication is denied.
+const maxTOTPAttempts = 3
+
+// totpSession is the state of a session in which the broker granted access offline to a user who enrolled a TOTP
+// secret, who now has to enter one of its codes.
+type totpSession struct {
+	// username is the name of the user, as stored in the database.
+	username string
+	// grantedData is the data returned by the broker when it granted access, used once the code is verified.
+	grantedData string
+	attempts    int
+}
+
+// totpSessions tracks the sessions waiting for a TOTP code.
+type totpSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*totpSession
+}
+
+func (s *totpSessions) start(sessionID, username, grantedData string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionID] = &totpSession{username: username, grantedData: grantedData}
+}
+
+// pending returns true if the session waits for a TOTP code.
+func (s *totpSessions) pending(sessionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.sessions[sessionID]
+	return ok
+}
+
+func (s *totpSessions) get(sessionID string) (totpSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ts, ok := s.sessions[sessionID]
+	if !ok {
+		return totpSession{}, false
+	}
+	return *ts, true
+}
+
+// failed records an invalid code. It returns true if the maximum number of attempts is reached.
+func (s *totpSessions) failed(sessionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ts, ok := s.sessions[sessionID]
+	if !ok {
+		return true
+	}
+	ts.attempts++
+	return ts.attempts >= maxTOTPAttempts
+}
+
+func (s *totpSessions) remove(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+}
+
+// requireTOTP makes the session wait for a TOTP code if the broker granted access offline to a user who enrolled a
+// TOTP secret, as their identity provider couldn't enforce its own second factor. It returns true if it does.
+func (s Service) requireTOTP(ctx context.Context, sessionID, grantedData string) (bool, error) {
+	var granted struct {
+		UserInfo types.UserInfo `json:"userinfo"`
+		Offline  bool           `json:"offline"`
+	}
+	if err := json.Unmarshal([]byte(grantedData), &granted); err != nil || !granted.Offline {
+		// Invalid data is reported when the granted access is processed.
+		return false, nil
+	}
+
+	username := s.userManager.NormalizeName(granted.UserInfo.Name)
+	enrolled, err := s.userManager.HasTOTP(username)
+	if err != nil {
+		return false, err
+	}
+	if !enrolled {
+		return false, nil
+	}
+
+	log.Debugf(ctx, "%s: User %q has to enter a TOTP code", sessionID, username)
+	s.totpSessions.start(sessionID, username, grantedData)
+	return true, nil
+}
+
+// totpAuthMode returns the TOTP authentication mode, the only one available once a session waits for a TOTP code.
+func totpAuthMode() *authd.GAMResponse_AuthenticationMode {
+	return &authd.GAMResponse_AuthenticationMode{Id: auth.TOTPMode, Label: "Authenticator app code"}
+}
+
+// totpUILayout returns the layout in which the user enters the TOTP code.
+func totpUILayout() map[string]string {
+	return map[string]string{
+		layouts.Type:  layouts.Form,
+		layouts.Label: "Enter the code of your authenticator app",
+		layouts.Entry: entries.Digits,
+	}
+}
+
+// totpAuthenticate verifies the TOTP code entered by the user of the session. It returns the access and the data in
+// the same format as the brokers, with the data of the broker when access is granted.
+func (s Service) totpAuthenticate(ctx context.Context, sessionID, code string) (access, data string, err error) {
+	ts, ok := s.totpSessions.get(sessionID)
+	if !ok {
+		return "", "", errors.New("no TOTP code is expected in this session")
+	}
+
+	valid, err := s.userManager.VerifyTOTP(ts.username, code)
+	if err != nil {
+		return "", "", err
+	}
+	if !valid {
+		log.Noticef(ctx, "%s: Invalid TOTP code for user %q", sessionID, ts.username)
+		if s.totpSessions.failed(sessionID) {
+			s.totpSessions.remove(sessionID)
+			return auth.Denied, messageData(ctx, "Invalid code."), nil
+		}
+		return auth.Retry, messageData(ctx, "Invalid code, please try again."), nil
+	}
+
+	s.totpSessions.remove(sessionID)
+	return auth.Granted, ts.grantedData, nil
+}
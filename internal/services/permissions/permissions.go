@@ -40,18 +40,48 @@ func New(args ...Option) Manager {
 // CheckRequestIsFromRoot checks if the current gRPC request is from a root user and returns an error if not.
 // The pid and uid are extracted from peerAuthInfo in the gRPC context.
 func (m Manager) CheckRequestIsFromRoot(ctx context.Context) (err error) {
+	uid, err := peerUID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if uid != m.rootUID {
+		return errors.New("only root can perform this operation")
+	}
+
+	return nil
+}
+
+// CheckRequestIsFromRootOrUID checks if the current gRPC request is from a root user or from the user with the given
+// UID and returns an error if not.
+func (m Manager) CheckRequestIsFromRootOrUID(ctx context.Context, uid uint32) (err error) {
+	peerUID, err := peerUID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if peerUID != m.rootUID && peerUID != uid {
+		return errors.New("only root or the user themselves can perform this operation")
+	}
+
+	return nil
+}
+
+// PeerUID returns the UID of the user who sent the current gRPC request.
+func (m Manager) PeerUID(ctx context.Context) (uint32, error) {
+	return peerUID(ctx)
+}
+
+// peerUID returns the uid extracted from peerAuthInfo in the gRPC context.
+func peerUID(ctx context.Context) (uint32, error) {
 	p, ok := peer.FromContext(ctx)
 	if !ok {
-		return errors.New("context request doesn't have gRPC peer information")
+		return 0, errors.New("context request doesn't have gRPC peer information")
 	}
 	pci, ok := p.AuthInfo.(peerAuthInfo)
 	if !ok {
-		return errors.New("context request doesn't have valid gRPC peer credential information")
+		return 0, errors.New("context request doesn't have valid gRPC peer credential information")
 	}
 
-	if pci.uid != m.rootUID {
-		return errors.New("only root can perform this operation")
-	}
-
-	return nil
+	return pci.uid, nil
 }
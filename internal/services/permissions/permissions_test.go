@@ -60,6 +60,53 @@ func TestCheckRequestIsFromRoot(t *testing.T) {
 	}
 }
 
+func TestCheckRequestIsFromRootOrUID(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		currentUserNotRoot bool
+		otherUID           bool
+		noPeerInfo         bool
+		noPeerAuthInfo     bool
+
+		wantErr bool
+	}{
+		"Granted_if_current_user_considered_as_root":       {otherUID: true},
+		"Granted_if_current_user_is_the_requested_user":    {currentUserNotRoot: true},
+		"Granted_if_current_user_is_root_and_the_same_uid": {},
+
+		"Error_if_current_user_is_neither_root_nor_the_user": {currentUserNotRoot: true, otherUID: true, wantErr: true},
+		"Error_if_missing_peer_info":                         {noPeerInfo: true, wantErr: true},
+		"Error_if_missing_peer_auth_info":                    {noPeerAuthInfo: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := setupPermissionTestContext(t, tc.noPeerInfo, tc.noPeerAuthInfo)
+
+			var opts []permissions.Option
+			if !tc.currentUserNotRoot {
+				opts = append(opts, permissions.Z_ForTests_WithCurrentUserAsRoot())
+			}
+			pm := permissions.New(opts...)
+
+			uid := permissions.CurrentUserUID()
+			if tc.otherUID {
+				uid++
+			}
+
+			err := pm.CheckRequestIsFromRootOrUID(ctx, uid)
+
+			if tc.wantErr {
+				require.Error(t, err, "CheckRequestIsFromRootOrUID should deny access but didn't")
+				return
+			}
+			require.NoError(t, err, "CheckRequestIsFromRootOrUID should allow access but didn't")
+		})
+	}
+}
+
 func TestWithUnixPeerCreds(t *testing.T) {
 	t.Parallel()
 
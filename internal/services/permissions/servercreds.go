@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"syscall"
 
 	"github.com/canonical/authd/internal/decorate"
 	"golang.org/x/sys/unix"
@@ -25,14 +26,14 @@ func (serverPeerCreds) ServerHandshake(conn net.Conn) (n net.Conn, c credentials
 	defer decorate.OnError(&err, "server handshake failed")
 
 	var cred *unix.Ucred
-	// net.Conn is an interface. Expect only *net.UnixConn types
-	uc, ok := conn.(*net.UnixConn)
-	if !ok {
+	// net.Conn is an interface. Expect only Unix socket connections, which the daemon may wrap to track them.
+	sc, ok := conn.(syscall.Conn)
+	if !ok || conn.LocalAddr().Network() != "unix" {
 		return nil, nil, errors.New("unexpected socket type")
 	}
 
-	// Fetches raw network connection from UnixConn
-	raw, err := uc.SyscallConn()
+	// Fetches raw network connection from the Unix socket connection
+	raw, err := sc.SyscallConn()
 	if err != nil {
 		return nil, nil, fmt.Errorf("error opening raw connection: %v", err)
 	}
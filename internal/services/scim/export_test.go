@@ -0,0 +1,13 @@
+package scim
+
+import (
+	"net/http"
+
+	"github.com/canonical/authd/internal/brokers"
+	"github.com/canonical/authd/internal/users"
+)
+
+// NewHandler returns the handler of the SCIM resources of the users of the broker.
+func NewHandler(userManager *users.Manager, broker *brokers.Broker, token string) http.Handler {
+	return newServer(userManager, broker, []byte(token)).handler()
+}
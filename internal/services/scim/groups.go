@@ -0,0 +1,386 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+// groupResource is the SCIM representation of a group. Its ID is the UGID of the group, which is the external ID of
+// the group if the identity provider sets one, or its name otherwise.
+type groupResource struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Members     []memberRef `json:"members,omitempty"`
+	Meta        *meta       `json:"meta,omitempty"`
+}
+
+// groupChanges are the attributes of a group changed by a request.
+type groupChanges struct {
+	name    *string
+	members []string
+}
+
+// apply records the change of the attribute at path made by a PATCH operation.
+func (c *groupChanges) apply(op, path string, value json.RawMessage) error {
+	op = strings.ToLower(op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return fmt.Errorf("unsupported operation %q", op)
+	}
+
+	if path == "" {
+		// The attributes are in the value.
+		if op == "remove" {
+			return errors.New("remove operations require a path")
+		}
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(value, &attrs); err != nil {
+			return fmt.Errorf("invalid value of operation without path: %v", err)
+		}
+		for attr, v := range attrs {
+			if err := c.apply(op, attr, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	lowerPath := strings.ToLower(path)
+	switch {
+	case lowerPath == "displayname":
+		var name string
+		if op == "remove" || json.Unmarshal(value, &name) != nil || name == "" {
+			return errors.New("displayName must be a non-empty string")
+		}
+		c.name = &name
+	case lowerPath == "members":
+		var refs []memberRef
+		if len(value) > 0 {
+			if err := json.Unmarshal(value, &refs); err != nil {
+				return fmt.Errorf("invalid value of members: %v", err)
+			}
+		}
+		ids := memberIDs(refs)
+		switch {
+		case op == "replace":
+			c.members = ids
+		case op == "add":
+			for _, id := range ids {
+				if !slices.Contains(c.members, id) {
+					c.members = append(c.members, id)
+				}
+			}
+		case len(value) == 0:
+			c.members = nil
+		default:
+			c.members = slices.DeleteFunc(c.members, func(id string) bool { return slices.Contains(ids, id) })
+		}
+	case strings.HasPrefix(lowerPath, "members[") && op == "remove":
+		// Only the filters on the ID of the member are supported, e.g. members[value eq "1234"].
+		attribute, id, err := parseFilter(strings.TrimSuffix(path[len("members["):], "]"))
+		if err != nil || attribute != "value" {
+			return fmt.Errorf("unsupported path %q", path)
+		}
+		c.members = slices.DeleteFunc(c.members, func(m string) bool { return m == id })
+	default:
+		return fmt.Errorf("unsupported path %q", path)
+	}
+	return nil
+}
+
+func (s *server) listGroups(w http.ResponseWriter, r *http.Request) {
+	var attribute, value string
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		var err error
+		if attribute, value, err = parseFilter(filter); err != nil {
+			writeError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+		if attribute != "displayname" && attribute != "externalid" {
+			writeError(w, http.StatusBadRequest, "invalidFilter", "only displayName and externalId can be filtered")
+			return
+		}
+	}
+
+	groups, _, err := s.groups()
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	var resources []any
+	for _, ugid := range slices.Sorted(maps.Keys(groups)) {
+		g := groups[ugid]
+		if attribute == "displayname" && g.DisplayName != strings.ToLower(value) {
+			continue
+		}
+		if attribute == "externalid" && g.ID != value {
+			continue
+		}
+		resources = append(resources, g)
+	}
+
+	paginate(w, r, resources)
+}
+
+func (s *server) getGroup(w http.ResponseWriter, r *http.Request) {
+	groups, _, err := s.groups()
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	g, ok := groups[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "", fmt.Sprintf("group %q not found", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, g)
+}
+
+func (s *server) createGroup(w http.ResponseWriter, r *http.Request) {
+	var req groupResource
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.DisplayName == "" {
+		writeError(w, http.StatusBadRequest, "invalidValue", "displayName is required")
+		return
+	}
+	// authd uses lowercase group names.
+	name := strings.ToLower(req.DisplayName)
+	ugid := req.ExternalID
+	if ugid == "" {
+		ugid = name
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	groups, _, err := s.groups()
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	if _, ok := groups[ugid]; ok {
+		writeError(w, http.StatusConflict, "uniqueness", fmt.Sprintf("group %q already exists", ugid))
+		return
+	}
+
+	if !s.setGroup(w, r, ugid, name, memberIDs(req.Members)) {
+		return
+	}
+	log.Noticef(r.Context(), "Group %q provisioned with SCIM", name)
+
+	s.writeGroup(w, r, http.StatusCreated, ugid)
+}
+
+func (s *server) replaceGroup(w http.ResponseWriter, r *http.Request) {
+	var req groupResource
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	c := groupChanges{members: memberIDs(req.Members)}
+	if req.DisplayName != "" {
+		c.name = &req.DisplayName
+	}
+	s.changeGroup(w, r, c, nil)
+}
+
+func (s *server) patchGroup(w http.ResponseWriter, r *http.Request) {
+	var req patchRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	s.changeGroup(w, r, groupChanges{}, req.Operations)
+}
+
+// changeGroup applies the changes to the group of the request, and then the PATCH operations to the result. The
+// members are the current members of the group if the changes don't set them.
+func (s *server) changeGroup(w http.ResponseWriter, r *http.Request, c groupChanges, ops []patchOperation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	groups, _, err := s.groups()
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	ugid := r.PathValue("id")
+	g, ok := groups[ugid]
+	if !ok {
+		writeError(w, http.StatusNotFound, "", fmt.Sprintf("group %q not found", ugid))
+		return
+	}
+
+	if c.members == nil {
+		c.members = memberIDs(g.Members)
+	}
+	for _, op := range ops {
+		if err := c.apply(op.Op, op.Path, op.Value); err != nil {
+			writeError(w, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+	}
+	name := g.DisplayName
+	if c.name != nil {
+		name = strings.ToLower(*c.name)
+	}
+
+	if !s.setGroup(w, r, ugid, name, c.members) {
+		return
+	}
+	s.writeGroup(w, r, http.StatusOK, ugid)
+}
+
+// deleteGroup removes all the members from the group, which authd then deletes.
+func (s *server) deleteGroup(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	groups, _, err := s.groups()
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	ugid := r.PathValue("id")
+	g, ok := groups[ugid]
+	if !ok {
+		writeError(w, http.StatusNotFound, "", fmt.Sprintf("group %q not found", ugid))
+		return
+	}
+
+	if !s.setGroup(w, r, ugid, g.DisplayName, nil) {
+		return
+	}
+	delete(s.pendingGroups, ugid)
+	log.Noticef(r.Context(), "Group %q deprovisioned with SCIM", g.DisplayName)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setGroup makes the users with the given IDs the only members of the group, writing the error response if it fails.
+func (s *server) setGroup(w http.ResponseWriter, r *http.Request, ugid, name string, members []string) bool {
+	_, usersByID, err := s.groups()
+	if err != nil {
+		writeInternalError(w, r, err)
+		return false
+	}
+	for _, id := range members {
+		if _, ok := usersByID[id]; !ok {
+			writeError(w, http.StatusBadRequest, "invalidValue", fmt.Sprintf("member %q is not a user", id))
+			return false
+		}
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(usersByID)) {
+		info := usersByID[id]
+		i := slices.IndexFunc(info.Groups, func(g types.GroupInfo) bool { return g.UGID == ugid })
+		isMember := slices.Contains(members, id)
+		if (i < 0 && !isMember) || (i >= 0 && isMember && info.Groups[i].Name == name) {
+			continue
+		}
+
+		updated := s.storedUserInfo(info)
+		updated.Groups = slices.DeleteFunc(updated.Groups, func(g types.GroupInfo) bool { return g.UGID == ugid })
+		if isMember {
+			updated.Groups = append(updated.Groups, types.GroupInfo{Name: name, UGID: ugid})
+		}
+		if !s.updateUser(w, r, updated) {
+			return false
+		}
+	}
+
+	if len(members) == 0 {
+		s.pendingGroups[ugid] = name
+	} else {
+		delete(s.pendingGroups, ugid)
+	}
+	return true
+}
+
+// groups returns the groups of the users of the broker, including the ones without members, by UGID, and the users
+// of the broker by UID.
+func (s *server) groups() (map[string]*groupResource, map[string]types.UserInfo, error) {
+	entries, err := s.userManager.AllUsers()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	groups := make(map[string]*groupResource)
+	usersByID := make(map[string]types.UserInfo)
+	for _, e := range entries {
+		info, err := s.userManager.CachedUserInfo(e.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if info.BrokerID != s.broker.ID {
+			continue
+		}
+		id := strconv.FormatUint(uint64(info.UID), 10)
+		usersByID[id] = info
+
+		for _, g := range info.Groups {
+			// The private group of the user and the local groups are not managed by the identity provider.
+			if g.UGID == "" || g.UGID == info.Name {
+				continue
+			}
+			if groups[g.UGID] == nil {
+				groups[g.UGID] = newGroupResource(g.UGID, g.Name)
+			}
+			groups[g.UGID].Members = append(groups[g.UGID].Members, memberRef{Value: id, Display: info.Name})
+		}
+	}
+	for ugid, name := range s.pendingGroups {
+		if groups[ugid] == nil {
+			groups[ugid] = newGroupResource(ugid, name)
+		}
+	}
+
+	return groups, usersByID, nil
+}
+
+func newGroupResource(ugid, name string) *groupResource {
+	return &groupResource{
+		Schemas:     []string{groupSchema},
+		ID:          ugid,
+		DisplayName: name,
+		Meta:        &meta{ResourceType: "Group", Location: basePath + "/Groups/" + ugid},
+	}
+}
+
+// writeGroup writes the group with the given UGID.
+func (s *server) writeGroup(w http.ResponseWriter, r *http.Request, status int, ugid string) {
+	groups, _, err := s.groups()
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	g, ok := groups[ugid]
+	if !ok {
+		writeInternalError(w, r, fmt.Errorf("group %q not found after update", ugid))
+		return
+	}
+	if status == http.StatusCreated {
+		w.Header().Set("Location", g.Meta.Location)
+	}
+	writeJSON(w, status, g)
+}
+
+// memberIDs returns the IDs of the members.
+func memberIDs(members []memberRef) []string {
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.Value)
+	}
+	return ids
+}
@@ -0,0 +1,267 @@
+// Package scim implements a SCIM 2.0 server (RFC 7643 and RFC 7644), which the identity provider pushes the users
+// and their groups to, so that they are provisioned before they first log in and locked as soon as they are
+// deactivated.
+//
+// Only the users of the configured broker are managed. Their home is set from the configuration of the broker, but,
+// like for the users created on login, it's only created when they open their first session. Their groups are the
+// ones pushed by the identity provider until they log in, when they are replaced by the ones returned by the broker.
+package scim
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/canonical/authd/internal/brokers"
+	"github.com/canonical/authd/internal/decorate"
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/log"
+)
+
+const (
+	userSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	groupSchema = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	listSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	errorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	contentType = "application/scim+json"
+
+	// basePath is the path under which the resources are served.
+	basePath = "/scim/v2"
+	// maxRequestSize is the maximum size of the body of the requests.
+	maxRequestSize = 1 << 20
+)
+
+// server serves the users and groups of a broker to the identity provider.
+type server struct {
+	userManager *users.Manager
+	broker      *brokers.Broker
+	token       []byte
+
+	// mu serializes the changes, as they update the users from their stored information.
+	mu sync.Mutex
+	// pendingGroups holds the names of the groups without members, by UGID, as authd only stores the groups of its
+	// users.
+	pendingGroups map[string]string
+}
+
+// Serve serves SCIM over HTTPS on the TCP address of the configuration, until the returned function is called.
+func Serve(ctx context.Context, userManager *users.Manager, brokerManager *brokers.Manager, cfg users.SCIMConfig) (stop func(), err error) {
+	defer decorate.OnError(&err, "could not serve SCIM on %q", cfg.Listen)
+
+	var broker *brokers.Broker
+	for _, b := range brokerManager.AvailableBrokers() {
+		if b.Name == cfg.Broker {
+			broker = b
+			break
+		}
+	}
+	if broker == nil {
+		return nil, fmt.Errorf("no broker named %q", cfg.Broker)
+	}
+
+	lis, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return nil, err
+	}
+	stop, err = serve(ctx, userManager, broker, lis, cfg)
+	if err != nil {
+		_ = lis.Close()
+		return nil, err
+	}
+	return stop, nil
+}
+
+func serve(ctx context.Context, userManager *users.Manager, broker *brokers.Broker, lis net.Listener, cfg users.SCIMConfig) (stop func(), err error) {
+	token, err := readToken(cfg.TokenFile)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &http.Server{
+		Handler:           newServer(userManager, broker, token).handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := srv.ServeTLS(lis, cfg.CertFile, cfg.KeyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf(ctx, "Stopped serving SCIM: %v", err)
+		}
+	}()
+
+	log.Infof(ctx, "Serving SCIM for the users of broker %q on %s", broker.Name, lis.Addr())
+	return func() {
+		_ = srv.Close()
+		<-done
+	}, nil
+}
+
+// readToken reads the bearer token which the identity provider authenticates itself with.
+func readToken(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the SCIM token: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return nil, fmt.Errorf("the SCIM token file %q is empty", path)
+	}
+	return []byte(token), nil
+}
+
+func newServer(userManager *users.Manager, broker *brokers.Broker, token []byte) *server {
+	return &server{
+		userManager:   userManager,
+		broker:        broker,
+		token:         token,
+		pendingGroups: make(map[string]string),
+	}
+}
+
+// handler returns the handler of the SCIM resources, which only accepts the requests with the bearer token.
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+basePath+"/Users", s.listUsers)
+	mux.HandleFunc("POST "+basePath+"/Users", s.createUser)
+	mux.HandleFunc("GET "+basePath+"/Users/{id}", s.getUser)
+	mux.HandleFunc("PUT "+basePath+"/Users/{id}", s.replaceUser)
+	mux.HandleFunc("PATCH "+basePath+"/Users/{id}", s.patchUser)
+	mux.HandleFunc("DELETE "+basePath+"/Users/{id}", s.deleteUser)
+	mux.HandleFunc("GET "+basePath+"/Groups", s.listGroups)
+	mux.HandleFunc("POST "+basePath+"/Groups", s.createGroup)
+	mux.HandleFunc("GET "+basePath+"/Groups/{id}", s.getGroup)
+	mux.HandleFunc("PUT "+basePath+"/Groups/{id}", s.replaceGroup)
+	mux.HandleFunc("PATCH "+basePath+"/Groups/{id}", s.patchGroup)
+	mux.HandleFunc("DELETE "+basePath+"/Groups/{id}", s.deleteGroup)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), s.token) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="authd"`)
+			writeError(w, http.StatusUnauthorized, "", "invalid bearer token")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// scimError is the body of the error responses.
+type scimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// listResponse is the body of the responses to the queries.
+type listResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+// patchRequest is the body of the PATCH requests.
+type patchRequest struct {
+	Operations []patchOperation `json:"Operations"`
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// meta holds the metadata of the resources.
+type meta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, scimType, detail string) {
+	writeJSON(w, status, scimError{
+		Schemas:  []string{errorSchema},
+		Status:   fmt.Sprint(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	})
+}
+
+// writeInternalError logs the error and returns a generic error to the identity provider.
+func writeInternalError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Errorf(r.Context(), "SCIM %s %s: %v", r.Method, r.URL.Path, err)
+	writeError(w, http.StatusInternalServerError, "", "internal error")
+}
+
+// decodeBody decodes the JSON body of the request into v, writing the error response if it can't.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidSyntax", fmt.Sprintf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// paginate returns the page of the resources requested with the startIndex and count parameters.
+func paginate(w http.ResponseWriter, r *http.Request, resources []any) {
+	startIndex, count := 1, len(resources)
+	if v := r.URL.Query().Get("startIndex"); v != "" {
+		if _, err := fmt.Sscan(v, &startIndex); err != nil {
+			writeError(w, http.StatusBadRequest, "invalidValue", fmt.Sprintf("invalid startIndex %q", v))
+			return
+		}
+		startIndex = max(startIndex, 1)
+	}
+	if v := r.URL.Query().Get("count"); v != "" {
+		if _, err := fmt.Sscan(v, &count); err != nil {
+			writeError(w, http.StatusBadRequest, "invalidValue", fmt.Sprintf("invalid count %q", v))
+			return
+		}
+		count = max(count, 0)
+	}
+
+	// The count is clamped to the number of resources first, so that adding it to the start doesn't overflow.
+	total := len(resources)
+	count = min(count, total)
+	start := min(startIndex-1, total)
+	page := resources[start:min(start+count, total)]
+	writeJSON(w, http.StatusOK, listResponse{
+		Schemas:      []string{listSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(page),
+		Resources:    append([]any{}, page...),
+	})
+}
+
+// parseFilter parses the filters of the form `attribute eq "value"`, which are the only ones supported. The
+// attribute is returned in lowercase, as the attribute names are case-insensitive.
+func parseFilter(filter string) (attribute, value string, err error) {
+	attribute, rest, ok := strings.Cut(strings.TrimSpace(filter), " ")
+	op, value, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	value = strings.TrimSpace(value)
+	if !ok || !strings.EqualFold(op, "eq") || len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return "", "", fmt.Errorf("unsupported filter %q, only `attribute eq \"value\"` is supported", filter)
+	}
+	if err := json.Unmarshal([]byte(value), &value); err != nil {
+		return "", "", fmt.Errorf("invalid value in filter %q: %v", filter, err)
+	}
+	return strings.ToLower(attribute), value, nil
+}
@@ -0,0 +1,275 @@
+package scim_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/internal/brokers"
+	"github.com/canonical/authd/internal/services/scim"
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	localgroupstestutils "github.com/canonical/authd/internal/users/localentries/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "secret-token"
+
+func TestSCIM(t *testing.T) {
+	tests := map[string]struct {
+		method string
+		path   string
+		body   string
+		token  string
+
+		wantStatus   int
+		wantIDs      []string
+		wantGecos    map[string]string
+		wantLocked   map[string]bool
+		wantGroups   map[string][]string
+		wantDisabled []string
+	}{
+		"List_users_of_the_broker": {
+			method: http.MethodGet, path: "/scim/v2/Users",
+			wantStatus: http.StatusOK, wantIDs: []string{"1111", "3333"},
+		},
+		"List_users_by_userName": {
+			method: http.MethodGet, path: `/scim/v2/Users?filter=userName+eq+"User1@Example.com"`,
+			wantStatus: http.StatusOK, wantIDs: []string{"1111"},
+		},
+		"List_users_by_externalId": {
+			method: http.MethodGet, path: `/scim/v2/Users?filter=externalId+eq+"providerid-locked"`,
+			wantStatus: http.StatusOK, wantIDs: []string{"3333"},
+		},
+		"List_a_page_of_users": {
+			method: http.MethodGet, path: "/scim/v2/Users?startIndex=2&count=1",
+			wantStatus: http.StatusOK, wantIDs: []string{"3333"},
+		},
+		"List_a_page_of_users_with_the_largest_count": {
+			method: http.MethodGet, path: "/scim/v2/Users?startIndex=2&count=9223372036854775807",
+			wantStatus: http.StatusOK, wantIDs: []string{"3333"},
+		},
+		"Get_user": {
+			method: http.MethodGet, path: "/scim/v2/Users/1111",
+			wantStatus: http.StatusOK, wantIDs: []string{"1111"},
+		},
+		"Create_user": {
+			method: http.MethodPost, path: "/scim/v2/Users",
+			body:       `{"userName": "New@Example.com", "externalId": "providerid-new", "displayName": "New user"}`,
+			wantStatus: http.StatusCreated,
+			wantGecos:  map[string]string{"new@example.com": "New user"},
+			wantLocked: map[string]bool{"new@example.com": false},
+		},
+		"Create_inactive_user": {
+			method: http.MethodPost, path: "/scim/v2/Users",
+			body:       `{"userName": "new@example.com", "externalId": "providerid-new", "name": {"formatted": "New user"}, "active": false}`,
+			wantStatus: http.StatusCreated,
+			wantGecos:  map[string]string{"new@example.com": "New user"},
+			wantLocked: map[string]bool{"new@example.com": true},
+		},
+		"Deactivate_user": {
+			method: http.MethodPatch, path: "/scim/v2/Users/1111",
+			body:       `{"Operations": [{"op": "Replace", "path": "active", "value": "False"}]}`,
+			wantStatus: http.StatusOK, wantLocked: map[string]bool{"user1@example.com": true},
+		},
+		"Deactivate_user_without_path": {
+			method: http.MethodPatch, path: "/scim/v2/Users/1111",
+			body:       `{"Operations": [{"op": "replace", "value": {"active": false}}]}`,
+			wantStatus: http.StatusOK, wantLocked: map[string]bool{"user1@example.com": true},
+		},
+		"Update_display_name": {
+			method: http.MethodPatch, path: "/scim/v2/Users/1111",
+			body:       `{"Operations": [{"op": "replace", "path": "displayName", "value": "Renamed"}, {"op": "add", "path": "emails", "value": []}]}`,
+			wantStatus: http.StatusOK,
+			wantGecos:  map[string]string{"user1@example.com": "Renamed"},
+			wantGroups: map[string][]string{"user1@example.com": {"group1"}},
+		},
+		"Activate_user": {
+			method: http.MethodPut, path: "/scim/v2/Users/3333",
+			body:       `{"userName": "locked@example.com", "displayName": "Locked", "active": true}`,
+			wantStatus: http.StatusOK, wantLocked: map[string]bool{"locked@example.com": false},
+		},
+		"Deprovision_user": {
+			method: http.MethodDelete, path: "/scim/v2/Users/1111",
+			wantStatus: http.StatusNoContent, wantDisabled: []string{"user1@example.com"},
+		},
+
+		"List_groups_of_the_users_of_the_broker": {
+			method: http.MethodGet, path: "/scim/v2/Groups",
+			wantStatus: http.StatusOK, wantIDs: []string{"12345678"},
+		},
+		"List_groups_by_displayName": {
+			method: http.MethodGet, path: `/scim/v2/Groups?filter=displayName+eq+"Group1"`,
+			wantStatus: http.StatusOK, wantIDs: []string{"12345678"},
+		},
+		"Create_group_with_members": {
+			method: http.MethodPost, path: "/scim/v2/Groups",
+			body:       `{"displayName": "Group2", "externalId": "group2-id", "members": [{"value": "1111"}, {"value": "3333"}]}`,
+			wantStatus: http.StatusCreated, wantIDs: []string{"group2-id"},
+			wantGroups: map[string][]string{"user1@example.com": {"group1", "group2"}, "locked@example.com": {"group2"}},
+		},
+		"Create_group_without_members": {
+			method: http.MethodPost, path: "/scim/v2/Groups",
+			body:       `{"displayName": "Group2"}`,
+			wantStatus: http.StatusCreated, wantIDs: []string{"group2"},
+		},
+		"Add_member_to_group": {
+			method: http.MethodPatch, path: "/scim/v2/Groups/12345678",
+			body:       `{"Operations": [{"op": "add", "path": "members", "value": [{"value": "3333"}]}]}`,
+			wantStatus: http.StatusOK,
+			wantGroups: map[string][]string{"user1@example.com": {"group1"}, "locked@example.com": {"group1"}},
+		},
+		"Remove_member_from_group": {
+			method: http.MethodPatch, path: "/scim/v2/Groups/12345678",
+			body:       `{"Operations": [{"op": "remove", "path": "members[value eq \"1111\"]"}]}`,
+			wantStatus: http.StatusOK,
+			wantGroups: map[string][]string{"user1@example.com": nil},
+		},
+		"Rename_group": {
+			method: http.MethodPatch, path: "/scim/v2/Groups/12345678",
+			body:       `{"Operations": [{"op": "replace", "path": "displayName", "value": "Renamed"}]}`,
+			wantStatus: http.StatusOK,
+			wantGroups: map[string][]string{"user1@example.com": {"renamed"}},
+		},
+		"Replace_group": {
+			method: http.MethodPut, path: "/scim/v2/Groups/12345678",
+			body:       `{"displayName": "group1", "members": [{"value": "3333"}]}`,
+			wantStatus: http.StatusOK,
+			wantGroups: map[string][]string{"user1@example.com": nil, "locked@example.com": {"group1"}},
+		},
+		"Delete_group": {
+			method: http.MethodDelete, path: "/scim/v2/Groups/12345678",
+			wantStatus: http.StatusNoContent,
+			wantGroups: map[string][]string{"user1@example.com": nil},
+		},
+
+		"Error_with_invalid_token": {
+			method: http.MethodGet, path: "/scim/v2/Users", token: "wrong-token",
+			wantStatus: http.StatusUnauthorized,
+		},
+		"Error_with_unsupported_filter": {
+			method: http.MethodGet, path: `/scim/v2/Users?filter=emails+co+"example.com"`,
+			wantStatus: http.StatusBadRequest,
+		},
+		"Error_getting_user_of_another_broker": {
+			method: http.MethodGet, path: "/scim/v2/Users/2222",
+			wantStatus: http.StatusNotFound,
+		},
+		"Error_getting_unknown_user": {
+			method: http.MethodGet, path: "/scim/v2/Users/not-a-uid",
+			wantStatus: http.StatusNotFound,
+		},
+		"Error_creating_user_without_externalId": {
+			method: http.MethodPost, path: "/scim/v2/Users",
+			body:       `{"userName": "new@example.com"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		"Error_creating_existing_user": {
+			method: http.MethodPost, path: "/scim/v2/Users",
+			body:       `{"userName": "user1@example.com", "externalId": "providerid-user1"}`,
+			wantStatus: http.StatusConflict,
+		},
+		"Error_with_invalid_body": {
+			method: http.MethodPost, path: "/scim/v2/Users",
+			body:       `{"userName":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		"Error_with_unsupported_operation": {
+			method: http.MethodPatch, path: "/scim/v2/Users/1111",
+			body:       `{"Operations": [{"op": "move", "path": "active", "value": false}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		"Error_creating_existing_group": {
+			method: http.MethodPost, path: "/scim/v2/Groups",
+			body:       `{"displayName": "group1", "externalId": "12345678"}`,
+			wantStatus: http.StatusConflict,
+		},
+		"Error_adding_unknown_member_to_group": {
+			method: http.MethodPatch, path: "/scim/v2/Groups/12345678",
+			body:       `{"Operations": [{"op": "add", "path": "members", "value": [{"value": "2222"}]}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		"Error_getting_private_group": {
+			method: http.MethodGet, path: "/scim/v2/Groups/user1@example.com",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// We don't care about the output of gpasswd in this test, but we still need to mock it.
+			_ = localgroupstestutils.SetupGroupMock(t, filepath.Join("testdata", "empty.group"))
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "users.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m, err := users.NewManager(users.DefaultConfig, dbDir)
+			require.NoError(t, err, "Setup: could not create user manager")
+			t.Cleanup(func() { _ = m.Stop() })
+
+			h := scim.NewHandler(m, &brokers.Broker{ID: "broker-id", Name: "Broker"}, testToken)
+
+			if tc.token == "" {
+				tc.token = testToken
+			}
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, tc.wantStatus, rec.Code, "Request should return the expected status, got body: %s", rec.Body)
+			if rec.Code != http.StatusNoContent {
+				require.Equal(t, "application/scim+json", rec.Header().Get("Content-Type"), "Response should be SCIM JSON")
+			}
+
+			if tc.wantIDs != nil {
+				var resp struct {
+					ID           string `json:"id"`
+					TotalResults int    `json:"totalResults"`
+					Resources    []struct {
+						ID string `json:"id"`
+					} `json:"Resources"`
+				}
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp), "Response should be valid JSON")
+				got := []string{resp.ID}
+				if resp.ID == "" {
+					got = nil
+					for _, r := range resp.Resources {
+						got = append(got, r.ID)
+					}
+				}
+				require.Equal(t, tc.wantIDs, got, "Response should return the expected resources")
+			}
+
+			for name, want := range tc.wantGecos {
+				info, err := m.CachedUserInfo(name)
+				require.NoError(t, err, "User %q should exist", name)
+				require.Equal(t, want, info.Gecos, "User %q should have the expected full name", name)
+				require.Equal(t, "broker-id", info.BrokerID, "User %q should be a user of the broker", name)
+			}
+			for name, want := range tc.wantLocked {
+				locked, err := m.IsUserLocked(name)
+				require.NoError(t, err, "IsUserLocked should not return an error")
+				require.Equal(t, want, locked, "User %q should have the expected locked state", name)
+			}
+			for name, want := range tc.wantGroups {
+				info, err := m.CachedUserInfo(name)
+				require.NoError(t, err, "User %q should exist", name)
+				var got []string
+				for _, g := range info.Groups {
+					if g.UGID != "" && g.UGID != name {
+						got = append(got, g.Name)
+					}
+				}
+				require.ElementsMatch(t, want, got, "User %q should be in the expected groups", name)
+			}
+			for _, name := range tc.wantDisabled {
+				lc, err := m.UserLifecycle(name)
+				require.NoError(t, err, "UserLifecycle should not return an error")
+				require.Equal(t, users.LifecycleDisabled, lc.State, "User %q should be disabled", name)
+			}
+		})
+	}
+}
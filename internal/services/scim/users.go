@@ -0,0 +1,479 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+// defaultShell is the shell of the provisioned users, unless their broker sets its own.
+const defaultShell = "/bin/bash"
+
+// userResource is the SCIM representation of a user. Its ID is the UID of the user and its external ID is the
+// identifier of the user at the provider, which their broker returns when they log in.
+type userResource struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	UserName    string      `json:"userName"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Name        *nameAttr   `json:"name,omitempty"`
+	Active      *bool       `json:"active,omitempty"`
+	Groups      []memberRef `json:"groups,omitempty"`
+	Meta        *meta       `json:"meta,omitempty"`
+}
+
+type nameAttr struct {
+	Formatted string `json:"formatted,omitempty"`
+}
+
+// memberRef references a user from a group, or a group from a user.
+type memberRef struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+// gecos returns the full name of the user.
+func (u userResource) gecos() string {
+	if u.DisplayName != "" || u.Name == nil {
+		return u.DisplayName
+	}
+	return u.Name.Formatted
+}
+
+// userChanges are the attributes of a user changed by a request.
+type userChanges struct {
+	userName *string
+	gecos    *string
+	active   *bool
+}
+
+// apply records the change of the attribute at path made by a PATCH operation. The changes of attributes which authd
+// doesn't store are ignored.
+func (c *userChanges) apply(op, path string, value json.RawMessage) error {
+	op = strings.ToLower(op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return fmt.Errorf("unsupported operation %q", op)
+	}
+
+	if path == "" {
+		// The attributes are in the value.
+		if op == "remove" {
+			return errors.New("remove operations require a path")
+		}
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(value, &attrs); err != nil {
+			return fmt.Errorf("invalid value of operation without path: %v", err)
+		}
+		for attr, v := range attrs {
+			if err := c.apply(op, attr, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch strings.ToLower(path) {
+	case "active":
+		if op == "remove" {
+			return errors.New("active can't be removed")
+		}
+		active, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		c.active = &active
+	case "displayname", "name.formatted":
+		var gecos string
+		if op != "remove" {
+			if err := json.Unmarshal(value, &gecos); err != nil {
+				return fmt.Errorf("invalid value of %s: %v", path, err)
+			}
+		}
+		c.gecos = &gecos
+	case "name":
+		var name nameAttr
+		if op != "remove" {
+			if err := json.Unmarshal(value, &name); err != nil {
+				return fmt.Errorf("invalid value of name: %v", err)
+			}
+		}
+		c.gecos = &name.Formatted
+	case "username":
+		var userName string
+		if op == "remove" || json.Unmarshal(value, &userName) != nil || userName == "" {
+			return errors.New("userName must be a non-empty string")
+		}
+		c.userName = &userName
+	}
+	return nil
+}
+
+// parseBool parses a boolean value, which some identity providers send as a string.
+func parseBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return false, fmt.Errorf("invalid boolean %s", value)
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean %q", s)
+	}
+	return b, nil
+}
+
+func (s *server) listUsers(w http.ResponseWriter, r *http.Request) {
+	var attribute, value string
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		var err error
+		if attribute, value, err = parseFilter(filter); err != nil {
+			writeError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+		if attribute != "username" && attribute != "externalid" {
+			writeError(w, http.StatusBadRequest, "invalidFilter", "only userName and externalId can be filtered")
+			return
+		}
+	}
+
+	entries, err := s.userManager.AllUsers()
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	var resources []any
+	for _, e := range entries {
+		info, err := s.userManager.CachedUserInfo(e.Name)
+		if err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+		if info.BrokerID != s.broker.ID {
+			continue
+		}
+		if attribute == "username" && info.Name != s.userManager.NormalizeName(value) {
+			continue
+		}
+		if attribute == "externalid" && info.ProviderID != value {
+			continue
+		}
+		u, err := s.userResource(info)
+		if err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+		resources = append(resources, u)
+	}
+
+	paginate(w, r, resources)
+}
+
+func (s *server) getUser(w http.ResponseWriter, r *http.Request) {
+	info, ok := s.lookupUser(w, r)
+	if !ok {
+		return
+	}
+	s.writeUser(w, r, http.StatusOK, info.Name)
+}
+
+func (s *server) createUser(w http.ResponseWriter, r *http.Request) {
+	var req userResource
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.UserName == "" {
+		writeError(w, http.StatusBadRequest, "invalidValue", "userName is required")
+		return
+	}
+	// authd identifies the users of the brokers by their identifier at the provider.
+	if req.ExternalID == "" {
+		writeError(w, http.StatusBadRequest, "invalidValue", "externalId is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := s.userManager.NormalizeName(req.UserName)
+	if _, err := s.userManager.CachedUserInfo(name); err == nil {
+		writeError(w, http.StatusConflict, "uniqueness", fmt.Sprintf("user %q already exists", name))
+		return
+	} else if !errors.Is(err, users.NoDataFoundError{}) {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	info := s.broker.UserDefaults.Apply(types.UserInfo{
+		Name:             name,
+		Gecos:            req.gecos(),
+		Dir:              filepath.Join("/home", name),
+		BrokerID:         s.broker.ID,
+		ProviderID:       req.ExternalID,
+		SharedPrimaryGID: s.broker.UserDefaults.SharedPrimaryGID,
+		DefaultGroups:    s.broker.UserDefaults.Groups,
+	})
+	if name != req.UserName {
+		info.OriginalName = req.UserName
+	}
+	if info.Shell == "" {
+		info.Shell = defaultShell
+	}
+	if !s.updateUser(w, r, info) {
+		return
+	}
+	log.Noticef(r.Context(), "User %q provisioned with SCIM", name)
+
+	if req.Active != nil && !*req.Active {
+		if err := s.setActive(r.Context(), name, false); err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+	}
+
+	s.writeUser(w, r, http.StatusCreated, name)
+}
+
+func (s *server) replaceUser(w http.ResponseWriter, r *http.Request) {
+	var req userResource
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	gecos := req.gecos()
+	c := userChanges{gecos: &gecos, active: req.Active}
+	if req.UserName != "" {
+		c.userName = &req.UserName
+	}
+	s.changeUser(w, r, c)
+}
+
+func (s *server) patchUser(w http.ResponseWriter, r *http.Request) {
+	var req patchRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	var c userChanges
+	for _, op := range req.Operations {
+		if err := c.apply(op.Op, op.Path, op.Value); err != nil {
+			writeError(w, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+	}
+	s.changeUser(w, r, c)
+}
+
+// changeUser applies the changes to the user of the request.
+func (s *server) changeUser(w http.ResponseWriter, r *http.Request, c userChanges) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, ok := s.lookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	updated := s.storedUserInfo(info)
+	if c.gecos != nil {
+		updated.Gecos = *c.gecos
+	}
+	if c.userName != nil {
+		// The user is renamed by the update, as they are identified by their provider ID.
+		updated.Name = s.userManager.NormalizeName(*c.userName)
+		if updated.Name != *c.userName {
+			updated.OriginalName = *c.userName
+		}
+	}
+	if updated.Gecos != info.Gecos || updated.Name != info.Name {
+		if !s.updateUser(w, r, updated) {
+			return
+		}
+	}
+
+	if c.active != nil {
+		if err := s.setActive(r.Context(), updated.Name, *c.active); err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+	}
+
+	s.writeUser(w, r, http.StatusOK, updated.Name)
+}
+
+// deleteUser disables the user, whose home is then archived and who is then deleted as configured by the lifecycle
+// policy.
+func (s *server) deleteUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, ok := s.lookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	// The user may already be deprovisioned.
+	if err := s.userManager.DisableUser(r.Context(), info.Name); err != nil && !errors.Is(err, users.LifecycleError{}) {
+		writeInternalError(w, r, err)
+		return
+	}
+	log.Noticef(r.Context(), "User %q deprovisioned with SCIM", info.Name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookupUser returns the information of the user whose UID is the ID of the request, writing the error response if
+// it's not a user of the broker.
+func (s *server) lookupUser(w http.ResponseWriter, r *http.Request) (types.UserInfo, bool) {
+	id := r.PathValue("id")
+	uid, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "", fmt.Sprintf("user %q not found", id))
+		return types.UserInfo{}, false
+	}
+
+	info, err := s.userInfoByID(uint32(uid))
+	if errors.Is(err, users.NoDataFoundError{}) {
+		writeError(w, http.StatusNotFound, "", fmt.Sprintf("user %q not found", id))
+		return types.UserInfo{}, false
+	}
+	if err != nil {
+		writeInternalError(w, r, err)
+		return types.UserInfo{}, false
+	}
+	return info, true
+}
+
+// userInfoByID returns the information of the user of the broker with the given UID.
+func (s *server) userInfoByID(uid uint32) (types.UserInfo, error) {
+	entry, err := s.userManager.UserByID(uid)
+	if err != nil {
+		return types.UserInfo{}, err
+	}
+	info, err := s.userManager.CachedUserInfo(entry.Name)
+	if err != nil {
+		return types.UserInfo{}, err
+	}
+	if info.BrokerID != s.broker.ID {
+		return types.UserInfo{}, users.NoDataFoundError{}
+	}
+	return info, nil
+}
+
+// storedUserInfo returns the stored information of the user, as it must be passed to update them.
+func (s *server) storedUserInfo(info types.UserInfo) types.UserInfo {
+	var groups []types.GroupInfo
+	for _, g := range info.Groups {
+		// The private group of the user is added by the update.
+		if g.UGID == info.Name {
+			continue
+		}
+		groups = append(groups, types.GroupInfo{Name: g.Name, UGID: g.UGID})
+	}
+	info.Groups = groups
+	info.SharedPrimaryGID = s.broker.UserDefaults.SharedPrimaryGID
+	info.DefaultGroups = s.broker.UserDefaults.Groups
+	return info
+}
+
+// updateUser stores the user, writing the error response if it fails.
+func (s *server) updateUser(w http.ResponseWriter, r *http.Request, info types.UserInfo) bool {
+	err := s.userManager.UpdateUser(info)
+	var collision users.NameCollisionError
+	if errors.As(err, &collision) {
+		writeError(w, http.StatusConflict, "uniqueness", err.Error())
+		return false
+	}
+	if err != nil {
+		writeInternalError(w, r, err)
+		return false
+	}
+	return true
+}
+
+// setActive locks or unlocks the user. Users being deprovisioned are restored when they are activated.
+func (s *server) setActive(ctx context.Context, name string, active bool) error {
+	locked, err := s.userManager.IsUserLocked(name)
+	if err != nil {
+		return err
+	}
+
+	if !active {
+		if locked {
+			return nil
+		}
+		log.Noticef(ctx, "User %q deactivated with SCIM", name)
+		return s.userManager.LockUser(name)
+	}
+
+	lc, err := s.userManager.UserLifecycle(name)
+	if err != nil {
+		return err
+	}
+	if lc.State != users.LifecycleActive {
+		return s.userManager.RestoreUser(ctx, name)
+	}
+	if !locked {
+		return nil
+	}
+	log.Noticef(ctx, "User %q activated with SCIM", name)
+	return s.userManager.UnlockUser(name)
+}
+
+// writeUser writes the user with the given name.
+func (s *server) writeUser(w http.ResponseWriter, r *http.Request, status int, name string) {
+	info, err := s.userManager.CachedUserInfo(name)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	u, err := s.userResource(info)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	if status == http.StatusCreated {
+		w.Header().Set("Location", u.Meta.Location)
+	}
+	writeJSON(w, status, u)
+}
+
+// userResource returns the SCIM representation of the user.
+func (s *server) userResource(info types.UserInfo) (userResource, error) {
+	locked, err := s.userManager.IsUserLocked(info.Name)
+	if err != nil {
+		return userResource{}, err
+	}
+	active := !locked
+
+	id := strconv.FormatUint(uint64(info.UID), 10)
+	u := userResource{
+		Schemas:     []string{userSchema},
+		ID:          id,
+		ExternalID:  info.ProviderID,
+		UserName:    info.Name,
+		DisplayName: info.Gecos,
+		Active:      &active,
+		Meta:        &meta{ResourceType: "User", Location: basePath + "/Users/" + id},
+	}
+	for _, g := range info.Groups {
+		// Only the groups of the provider are reported, not the private group of the user or the local groups.
+		if g.UGID == "" || g.UGID == info.Name {
+			continue
+		}
+		u.Groups = append(u.Groups, memberRef{Value: g.UGID, Display: g.Name})
+	}
+	return u, nil
+}
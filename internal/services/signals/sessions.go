@@ -0,0 +1,107 @@
+package signals
+
+import (
+	"context"
+	"errors"
+
+	"github.com/canonical/authd/internal/consts"
+	"github.com/canonical/authd/internal/services/user"
+	"github.com/canonical/authd/log"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+const (
+	// sessionInterface is the interface through which the sessions of the users get the status of their token.
+	sessionInterface = consts.ServiceName + ".Session"
+	// errReauthenticationRequired is the D-Bus error returned when the user has to authenticate again to extend their
+	// session.
+	errReauthenticationRequired = consts.ServiceName + ".Error.ReauthenticationRequired"
+)
+
+// SessionService gives the status of the sessions of the users and extends them.
+type SessionService interface {
+	SessionStatus(ctx context.Context, uid uint32) (user.SessionStatus, error)
+	RefreshSession(ctx context.Context, uid uint32) (user.SessionStatus, error)
+}
+
+// sessions is the object implementing the session interface. The calls always concern the user making them.
+type sessions struct {
+	service   SessionService
+	callerUID func(sender dbus.Sender) (uint32, error)
+}
+
+// ExportSessions serves the session interface on the system bus, so that the desktop components can show to the user
+// when their session expires and extend it.
+func (e *Emitter) ExportSessions(service SessionService) error {
+	s := sessions{
+		service: service,
+		callerUID: func(sender dbus.Sender) (uid uint32, err error) {
+			err = e.conn.BusObject().Call("org.freedesktop.DBus.GetConnectionUnixUser", 0, string(sender)).Store(&uid)
+			return uid, err
+		},
+	}
+	if err := e.conn.Export(s, objectPath, sessionInterface); err != nil {
+		return err
+	}
+	return e.exportIntrospection(introspect.Interface{Name: sessionInterface, Methods: introspectSessionMethods()})
+}
+
+// GetSession returns the name of the broker of the calling user, when the token it cached for them expires as a Unix
+// time, 0 if unknown, and whether it can be refreshed without the user authenticating again.
+func (s sessions) GetSession(sender dbus.Sender) (broker string, expiry int64, canRefresh bool, dbusErr *dbus.Error) {
+	uid, err := s.callerUID(sender)
+	if err != nil {
+		return "", 0, false, dbus.MakeFailedError(err)
+	}
+
+	st, err := s.service.SessionStatus(context.Background(), uid)
+	if err != nil {
+		return "", 0, false, sessionError(err)
+	}
+	return st.Broker, unixTime(st), st.CanRefresh, nil
+}
+
+// Refresh refreshes the token of the calling user and returns when it expires as a Unix time. The
+// ReauthenticationRequired error is returned if the user has to authenticate again instead.
+func (s sessions) Refresh(sender dbus.Sender) (expiry int64, dbusErr *dbus.Error) {
+	uid, err := s.callerUID(sender)
+	if err != nil {
+		return 0, dbus.MakeFailedError(err)
+	}
+
+	st, err := s.service.RefreshSession(context.Background(), uid)
+	if err != nil {
+		return 0, sessionError(err)
+	}
+	return unixTime(st), nil
+}
+
+func sessionError(err error) *dbus.Error {
+	log.Warningf(context.Background(), "Session request failed: %v", err)
+	if errors.Is(err, user.ErrReauthenticationRequired) {
+		return dbus.NewError(errReauthenticationRequired, []any{err.Error()})
+	}
+	return dbus.MakeFailedError(err)
+}
+
+func unixTime(st user.SessionStatus) int64 {
+	if st.Expiry.IsZero() {
+		return 0
+	}
+	return st.Expiry.Unix()
+}
+
+// introspectSessionMethods returns the description of the methods of the session interface.
+func introspectSessionMethods() []introspect.Method {
+	return []introspect.Method{
+		{Name: "GetSession", Args: []introspect.Arg{
+			{Name: "broker", Type: "s", Direction: "out"},
+			{Name: "expiry", Type: "x", Direction: "out"},
+			{Name: "can_refresh", Type: "b", Direction: "out"},
+		}},
+		{Name: "Refresh", Args: []introspect.Arg{
+			{Name: "expiry", Type: "x", Direction: "out"},
+		}},
+	}
+}
@@ -0,0 +1,123 @@
+// Package signals emits signals on the system bus when authd users and groups change, so that desktop components can
+// refresh their views without polling. It also lets the sessions of the users know when their token expires and
+// extend it.
+package signals
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/canonical/authd/internal/consts"
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/log"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+const (
+	// busName is the name owned by authd on the system bus.
+	busName = consts.ServiceName
+	// objectPath is the path of the object emitting the signals.
+	objectPath = dbus.ObjectPath("/com/ubuntu/authd")
+	// dbusInterface is the interface of the signals.
+	dbusInterface = consts.ServiceName + ".Accounts"
+)
+
+// The names of the signals of users and groups for each kind of change.
+var (
+	userSignals = map[users.ChangeKind]string{
+		users.Added:    "UserAdded",
+		users.Updated:  "UserChanged",
+		users.Disabled: "UserDisabled",
+		users.Removed:  "UserRemoved",
+	}
+	groupSignals = map[users.ChangeKind]string{
+		users.Added:   "GroupAdded",
+		users.Updated: "GroupChanged",
+		users.Removed: "GroupRemoved",
+	}
+)
+
+// Emitter emits the signals of the changes of users and groups.
+type Emitter struct {
+	conn *dbus.Conn
+}
+
+// New connects to the system bus and returns an emitter of the signals of the changes of users and groups.
+func New(ctx context.Context) (*Emitter, error) {
+	// Don't call dbus.SystemBus which caches globally system dbus (issues in tests)
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to the system bus: %w", err)
+	}
+
+	e := &Emitter{conn: conn}
+	if err := e.exportIntrospection(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	// A new daemon started for a handover replaces the previous one.
+	reply, err := conn.RequestName(busName, dbus.NameFlagAllowReplacement|dbus.NameFlagReplaceExisting|dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		// The signals are still emitted from the unique name of the connection.
+		log.Warningf(ctx, "Could not own %s on the system bus (reply %d): %v", busName, reply, err)
+	}
+
+	return e, nil
+}
+
+// UserChanged emits the signal of the change of the user.
+func (e *Emitter) UserChanged(kind users.ChangeKind, name string, uid uint32) {
+	e.emit(userSignals[kind], name, uid)
+}
+
+// GroupChanged emits the signal of the change of the group.
+func (e *Emitter) GroupChanged(kind users.ChangeKind, name string, gid uint32) {
+	e.emit(groupSignals[kind], name, gid)
+}
+
+func (e *Emitter) emit(signal, name string, id uint32) {
+	if signal == "" {
+		return
+	}
+	// Failing to emit a signal must not fail the change, which is already stored.
+	if err := e.conn.Emit(objectPath, dbusInterface+"."+signal, name, id); err != nil {
+		log.Warningf(context.Background(), "Could not emit %s signal for %q: %v", signal, name, err)
+	}
+}
+
+// Close closes the connection to the system bus.
+func (e *Emitter) Close() error {
+	return e.conn.Close()
+}
+
+// exportIntrospection exports the description of the signals and of the other interfaces served on the object.
+func (e *Emitter) exportIntrospection(others ...introspect.Interface) error {
+	node := &introspect.Node{
+		Name: string(objectPath),
+		Interfaces: append([]introspect.Interface{
+			introspect.IntrospectData,
+			{Name: dbusInterface, Signals: introspectSignals()},
+		}, others...),
+	}
+	return e.conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+}
+
+// introspectSignals returns the description of the signals, in the order of the change kinds.
+func introspectSignals() []introspect.Signal {
+	var signals []introspect.Signal
+	add := func(names map[users.ChangeKind]string, idArg string) {
+		for kind := users.Added; kind <= users.Removed; kind++ {
+			if name, ok := names[kind]; ok {
+				signals = append(signals, introspect.Signal{
+					Name: name,
+					Args: []introspect.Arg{{Name: "name", Type: "s"}, {Name: idArg, Type: "u"}},
+				})
+			}
+		}
+	}
+	add(userSignals, "uid")
+	add(groupSignals, "gid")
+	return signals
+}
@@ -0,0 +1,184 @@
+package signals_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/services/signals"
+	"github.com/canonical/authd/internal/services/user"
+	"github.com/canonical/authd/internal/testutils"
+	"github.com/canonical/authd/internal/users"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmitter(t *testing.T) {
+	e, err := signals.New(context.Background())
+	require.NoError(t, err, "Setup: New should not return an error")
+	t.Cleanup(func() { _ = e.Close() })
+
+	conn, err := testutils.GetSystemBusConnection(t)
+	require.NoError(t, err, "Setup: could not connect to the system bus")
+	t.Cleanup(func() { _ = conn.Close() })
+
+	err = conn.AddMatchSignal(dbus.WithMatchSender("com.ubuntu.authd"), dbus.WithMatchInterface("com.ubuntu.authd.Accounts"))
+	require.NoError(t, err, "Setup: could not subscribe to the signals")
+	ch := make(chan *dbus.Signal, 10)
+	conn.Signal(ch)
+
+	tests := []struct {
+		emit func()
+
+		wantSignal string
+		wantName   string
+		wantID     uint32
+	}{
+		{emit: func() { e.UserChanged(users.Added, "user1@example.com", 1111) }, wantSignal: "UserAdded", wantName: "user1@example.com", wantID: 1111},
+		{emit: func() { e.UserChanged(users.Updated, "user1@example.com", 1111) }, wantSignal: "UserChanged", wantName: "user1@example.com", wantID: 1111},
+		{emit: func() { e.UserChanged(users.Disabled, "user1@example.com", 1111) }, wantSignal: "UserDisabled", wantName: "user1@example.com", wantID: 1111},
+		{emit: func() { e.UserChanged(users.Removed, "user1@example.com", 1111) }, wantSignal: "UserRemoved", wantName: "user1@example.com", wantID: 1111},
+		{emit: func() { e.GroupChanged(users.Added, "group1", 11111) }, wantSignal: "GroupAdded", wantName: "group1", wantID: 11111},
+		{emit: func() { e.GroupChanged(users.Updated, "group1", 11111) }, wantSignal: "GroupChanged", wantName: "group1", wantID: 11111},
+		{emit: func() { e.GroupChanged(users.Removed, "group1", 11111) }, wantSignal: "GroupRemoved", wantName: "group1", wantID: 11111},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("Emit_%s", tc.wantSignal), func(t *testing.T) {
+			tc.emit()
+
+			select {
+			case s := <-ch:
+				require.Equal(t, "com.ubuntu.authd.Accounts."+tc.wantSignal, s.Name, "Signal name is not the expected one")
+				require.Equal(t, dbus.ObjectPath("/com/ubuntu/authd"), s.Path, "Signal path is not the expected one")
+				require.Equal(t, []any{tc.wantName, tc.wantID}, s.Body, "Signal arguments are not the expected ones")
+			case <-time.After(5 * time.Second):
+				require.Fail(t, "Signal was not received", tc.wantSignal)
+			}
+		})
+	}
+
+	t.Run("Signals_are_introspectable", func(t *testing.T) {
+		node, err := introspect.Call(conn.Object("com.ubuntu.authd", "/com/ubuntu/authd"))
+		require.NoError(t, err, "Introspect should not return an error")
+
+		var got []string
+		for _, iface := range node.Interfaces {
+			if iface.Name != "com.ubuntu.authd.Accounts" {
+				continue
+			}
+			for _, s := range iface.Signals {
+				got = append(got, s.Name)
+			}
+		}
+		require.Equal(t, []string{"UserAdded", "UserChanged", "UserDisabled", "UserRemoved", "GroupAdded", "GroupChanged", "GroupRemoved"}, got,
+			"Introspected signals are not the expected ones")
+	})
+}
+
+type sessionServiceMock struct {
+	status    user.SessionStatus
+	refreshed time.Time
+	err       error
+}
+
+func (s sessionServiceMock) SessionStatus(_ context.Context, uid uint32) (user.SessionStatus, error) {
+	if uid != uint32(os.Geteuid()) {
+		return user.SessionStatus{}, errors.New("the session of another user was requested")
+	}
+	return s.status, s.err
+}
+
+func (s sessionServiceMock) RefreshSession(ctx context.Context, uid uint32) (user.SessionStatus, error) {
+	st, err := s.SessionStatus(ctx, uid)
+	st.Expiry = s.refreshed
+	return st, err
+}
+
+func TestSessions(t *testing.T) {
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		service sessionServiceMock
+
+		wantSession []any
+		wantRefresh []any
+		wantErr     string
+	}{
+		"Get_and_refresh_session": {
+			service: sessionServiceMock{
+				status:    user.SessionStatus{Broker: "Broker", Expiry: expiry, CanRefresh: true},
+				refreshed: expiry.Add(time.Hour),
+			},
+			wantSession: []any{"Broker", expiry.Unix(), true},
+			wantRefresh: []any{expiry.Add(time.Hour).Unix()},
+		},
+		"Get_session_with_unknown_expiry": {
+			service:     sessionServiceMock{status: user.SessionStatus{Broker: "Broker"}},
+			wantSession: []any{"Broker", int64(0), false},
+			wantRefresh: []any{int64(0)},
+		},
+
+		"Error_when_reauthentication_is_required": {
+			service: sessionServiceMock{err: fmt.Errorf("%w: no refresh token", user.ErrReauthenticationRequired)},
+			wantErr: "com.ubuntu.authd.Error.ReauthenticationRequired",
+		},
+		"Error_when_service_fails": {
+			service: sessionServiceMock{err: errors.New("broker is not available")},
+			wantErr: "org.freedesktop.DBus.Error.Failed",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := signals.New(context.Background())
+			require.NoError(t, err, "Setup: New should not return an error")
+			t.Cleanup(func() { _ = e.Close() })
+			require.NoError(t, e.ExportSessions(tc.service), "ExportSessions should not return an error")
+
+			conn, err := testutils.GetSystemBusConnection(t)
+			require.NoError(t, err, "Setup: could not connect to the system bus")
+			t.Cleanup(func() { _ = conn.Close() })
+			obj := conn.Object("com.ubuntu.authd", "/com/ubuntu/authd")
+
+			for method, want := range map[string][]any{"GetSession": tc.wantSession, "Refresh": tc.wantRefresh} {
+				call := obj.Call("com.ubuntu.authd.Session."+method, 0)
+				if tc.wantErr != "" {
+					var dbusErr dbus.Error
+					require.ErrorAs(t, call.Err, &dbusErr, "%s should return a D-Bus error", method)
+					require.Equal(t, tc.wantErr, dbusErr.Name, "%s should return the expected D-Bus error", method)
+					continue
+				}
+				require.NoError(t, call.Err, "%s should not return an error", method)
+				require.Equal(t, want, call.Body, "%s should return the expected values", method)
+			}
+
+			node, err := introspect.Call(obj)
+			require.NoError(t, err, "Introspect should not return an error")
+			var got []string
+			for _, iface := range node.Interfaces {
+				if iface.Name != "com.ubuntu.authd.Session" {
+					continue
+				}
+				for _, m := range iface.Methods {
+					got = append(got, m.Name)
+				}
+			}
+			require.Equal(t, []string{"GetSession", "Refresh"}, got, "Introspected methods are not the expected ones")
+		})
+	}
+}
+
+func TestMain(m *testing.M) {
+	// Start system bus mock.
+	cleanup, err := testutils.StartSystemBusMock()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	defer cleanup()
+
+	m.Run()
+}
@@ -0,0 +1,133 @@
+package user
+
+import (
+	"sync"
+	"time"
+
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/users"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxCachedReplies is the number of replies of each kind which a ReplyCache keeps at most.
+	maxCachedReplies = 4096
+	// replyCacheTTL is how long a ReplyCache keeps its replies. The changes made by this daemon reset the cache right
+	// away, this only bounds how long the changes made by another daemon, while the socket is handed over, are missed.
+	replyCacheTTL = 5 * time.Second
+)
+
+var (
+	// errUserIDNotFound and errGroupIDNotFound are the replies to the lookups of unknown IDs. They are built once, as
+	// they are the most common replies: the NSS lookups of the IDs of local users and groups also reach authd.
+	errUserIDNotFound  = status.Error(codes.NotFound, "user not found")
+	errGroupIDNotFound = status.Error(codes.NotFound, "group not found")
+)
+
+// ReplyCache keeps the replies to the lookups of users and groups by ID, so that the storms of NSS lookups of the same
+// IDs, e.g. by the builds of containers, don't query the database and build a new reply each time.
+//
+// The replies are shared between the requests and must not be modified. The cache is reset whenever a user or group
+// changes, see [users.WithChangeNotifier].
+type ReplyCache struct {
+	mu      sync.RWMutex
+	users   map[uint32]*authd.User
+	groups  map[uint32]*authd.Group
+	expires time.Time
+}
+
+// NewReplyCache returns an empty ReplyCache.
+func NewReplyCache() *ReplyCache {
+	return &ReplyCache{
+		users:  make(map[uint32]*authd.User),
+		groups: make(map[uint32]*authd.Group),
+	}
+}
+
+// user returns the cached reply for the user with the given UID, if any.
+func (c *ReplyCache) user(uid uint32) (*authd.User, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if time.Now().After(c.expires) {
+		return nil, false
+	}
+	u, ok := c.users[uid]
+	return u, ok
+}
+
+// group returns the cached reply for the group with the given GID, if any.
+func (c *ReplyCache) group(gid uint32) (*authd.Group, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if time.Now().After(c.expires) {
+		return nil, false
+	}
+	g, ok := c.groups[gid]
+	return g, ok
+}
+
+// addUser caches the reply for the user.
+func (c *ReplyCache) addUser(u *authd.User) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.renewIfNeeded(len(c.users))
+	c.users[u.GetUid()] = u
+}
+
+// addGroup caches the reply for the group.
+func (c *ReplyCache) addGroup(g *authd.Group) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.renewIfNeeded(len(c.groups))
+	c.groups[g.GetGid()] = g
+}
+
+// renewIfNeeded empties the cache if it expired or if it's full. It must be called with the lock held.
+func (c *ReplyCache) renewIfNeeded(n int) {
+	if n < maxCachedReplies && !time.Now().After(c.expires) {
+		return
+	}
+	c.resetLocked()
+	c.expires = time.Now().Add(replyCacheTTL)
+}
+
+// resetLocked empties the cache. It must be called with the lock held.
+func (c *ReplyCache) resetLocked() {
+	clear(c.users)
+	clear(c.groups)
+}
+
+// UserChanged resets the cache, as the user can also be a member of the cached groups.
+func (c *ReplyCache) UserChanged(users.ChangeKind, string, uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resetLocked()
+}
+
+// GroupChanged resets the cache, as the group can also be the primary group of the cached users.
+func (c *ReplyCache) GroupChanged(users.ChangeKind, string, uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resetLocked()
+}
@@ -0,0 +1,123 @@
+package user_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/services/permissions"
+	"github.com/canonical/authd/internal/services/user"
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/canonical/authd/log"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestReplyCache(t *testing.T) {
+	t.Parallel()
+
+	s, m := newServiceWithReplyCache(t, user.NewReplyCache())
+
+	got, err := s.GetUserByID(context.Background(), &authd.GetUserByIDRequest{Id: 1111})
+	require.NoError(t, err, "GetUserByID should not return an error")
+	require.Equal(t, "/bin/bash", got.GetShell(), "Setup: unexpected shell")
+	cached, err := s.GetUserByID(context.Background(), &authd.GetUserByIDRequest{Id: 1111})
+	require.NoError(t, err, "GetUserByID should not return an error")
+	require.Same(t, got, cached, "The reply should be reused")
+
+	group, err := s.GetGroupByID(context.Background(), &authd.GetGroupByIDRequest{Id: 11111})
+	require.NoError(t, err, "GetGroupByID should not return an error")
+
+	_, err = m.SetShell("user1@example.com", "/bin/sh")
+	require.NoError(t, err, "Setup: could not change the shell of the user")
+
+	got, err = s.GetUserByID(context.Background(), &authd.GetUserByIDRequest{Id: 1111})
+	require.NoError(t, err, "GetUserByID should not return an error")
+	require.Equal(t, "/bin/sh", got.GetShell(), "The reply should be updated once the user changed")
+	gotGroup, err := s.GetGroupByID(context.Background(), &authd.GetGroupByIDRequest{Id: 11111})
+	require.NoError(t, err, "GetGroupByID should not return an error")
+	require.NotSame(t, group, gotGroup, "The replies of the groups should be dropped once a user changed")
+
+	_, err = s.GetUserByID(context.Background(), &authd.GetUserByIDRequest{Id: 4242})
+	require.Equal(t, codes.NotFound, status.Code(err), "GetUserByID should return a NotFound error for an unknown user")
+}
+
+func BenchmarkGetUserByID(b *testing.B) {
+	log.SetLevel(log.InfoLevel)
+	b.Cleanup(func() { log.SetLevel(log.DebugLevel) })
+
+	for _, withCache := range []bool{false, true} {
+		var cache *user.ReplyCache
+		name := "Without_reply_cache"
+		if withCache {
+			cache = user.NewReplyCache()
+			name = "With_reply_cache"
+		}
+		s, _ := newServiceWithReplyCache(b, cache)
+
+		b.Run(name+"/Found", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := s.GetUserByID(context.Background(), &authd.GetUserByIDRequest{Id: 1111}); err != nil {
+					b.Fatalf("GetUserByID should not return an error: %v", err)
+				}
+			}
+		})
+		b.Run(name+"/Not_found", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := s.GetUserByID(context.Background(), &authd.GetUserByIDRequest{Id: 4242}); err == nil {
+					b.Fatal("GetUserByID should return an error")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetGroupByID(b *testing.B) {
+	log.SetLevel(log.InfoLevel)
+	b.Cleanup(func() { log.SetLevel(log.DebugLevel) })
+
+	for _, withCache := range []bool{false, true} {
+		var cache *user.ReplyCache
+		name := "Without_reply_cache"
+		if withCache {
+			cache = user.NewReplyCache()
+			name = "With_reply_cache"
+		}
+		s, _ := newServiceWithReplyCache(b, cache)
+
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := s.GetGroupByID(context.Background(), &authd.GetGroupByIDRequest{Id: 11111}); err != nil {
+					b.Fatalf("GetGroupByID should not return an error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// newServiceWithReplyCache returns a user service using the default database and the given reply cache, if any,
+// which is notified of the changes of the users and groups.
+func newServiceWithReplyCache(tb testing.TB, cache *user.ReplyCache) (user.Service, *users.Manager) {
+	tb.Helper()
+
+	dbDir := tb.TempDir()
+	err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "default.db.yaml"), dbDir)
+	require.NoError(tb, err, "Setup: could not create database from testdata")
+
+	var opts []users.Option
+	if cache != nil {
+		opts = append(opts, users.WithChangeNotifier(cache))
+	}
+	m, err := users.NewManager(users.DefaultConfig, dbDir, opts...)
+	require.NoError(tb, err, "Setup: could not create user manager")
+	tb.Cleanup(func() { _ = m.Stop() })
+
+	permissionManager := permissions.New()
+	return user.NewService(context.Background(), m, nil, &permissionManager, user.WithReplyCache(cache)), m
+}
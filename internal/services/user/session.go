@@ -0,0 +1,107 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/canonical/authd/internal/brokers"
+	"github.com/canonical/authd/log"
+)
+
+// ErrReauthenticationRequired is returned when the token of a user can't be refreshed without them authenticating again
+// with their broker, for example by unlocking their screen.
+var ErrReauthenticationRequired = errors.New("the user has to authenticate again with their broker")
+
+// SessionStatus is the status of the session of a user, as shown to them by the desktop components.
+type SessionStatus struct {
+	// Broker is the name of the broker of the user.
+	Broker string
+	// Expiry is when the token cached by the broker for the user expires, zero if the broker doesn't report it.
+	Expiry time.Time
+	// CanRefresh is true if the broker can refresh the token without the user authenticating again.
+	CanRefresh bool
+}
+
+// SessionStatus returns the status of the session of the user with the given UID: their broker and the validity of the
+// token which it cached when they last authenticated.
+func (s Service) SessionStatus(ctx context.Context, uid uint32) (SessionStatus, error) {
+	name, broker, err := s.sessionBroker(uid)
+	if err != nil {
+		return SessionStatus{}, err
+	}
+
+	t, err := broker.TokenStatus(ctx, name)
+	if errors.Is(err, brokers.ErrTokenStatusNotSupported) {
+		return SessionStatus{Broker: broker.Name}, nil
+	}
+	if err != nil {
+		return SessionStatus{}, fmt.Errorf("could not get the status of the token of user %q from broker %q: %w", name, broker.Name, err)
+	}
+
+	return sessionStatus(broker, t), nil
+}
+
+// RefreshSession asks the broker of the user with the given UID to refresh their token, so that their session is
+// extended without them logging out. ErrReauthenticationRequired is returned if the broker can't refresh it.
+func (s Service) RefreshSession(ctx context.Context, uid uint32) (SessionStatus, error) {
+	name, broker, err := s.sessionBroker(uid)
+	if err != nil {
+		return SessionStatus{}, err
+	}
+	locked, err := s.userManager.IsUserLocked(name)
+	if err != nil {
+		return SessionStatus{}, err
+	}
+	if locked {
+		return SessionStatus{}, fmt.Errorf("user %q is locked", name)
+	}
+
+	current, err := s.SessionStatus(ctx, uid)
+	if err != nil {
+		return SessionStatus{}, err
+	}
+	if !current.CanRefresh {
+		return SessionStatus{}, fmt.Errorf("%w: broker %q can't refresh the token of user %q", ErrReauthenticationRequired, broker.Name, name)
+	}
+
+	t, err := broker.RefreshToken(ctx, name)
+	if err != nil {
+		s.metrics.TokenRefreshFailed(broker.ID)
+		return SessionStatus{}, fmt.Errorf("could not refresh the token of user %q with broker %q: %w", name, broker.Name, err)
+	}
+	log.Infof(ctx, "Refreshed the token of user %q on request of their session", name)
+
+	return sessionStatus(broker, t), nil
+}
+
+// sessionBroker returns the name of the user with the given UID and their broker, which must not be the local one.
+func (s Service) sessionBroker(uid uint32) (name string, broker *brokers.Broker, err error) {
+	u, err := s.userManager.UserByID(uid)
+	if err != nil {
+		return "", nil, err
+	}
+	brokerID, err := s.userManager.BrokerForUser(u.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	if brokerID == "" || brokerID == brokers.LocalBrokerName {
+		return "", nil, fmt.Errorf("user %q is not provided by a broker", u.Name)
+	}
+	broker, err = s.brokerManager.BrokerFromID(brokerID)
+	if err != nil {
+		return "", nil, fmt.Errorf("the broker of user %q is not available: %w", u.Name, err)
+	}
+
+	return u.Name, broker, nil
+}
+
+func sessionStatus(broker *brokers.Broker, t brokers.TokenStatus) SessionStatus {
+	return SessionStatus{
+		Broker:     broker.Name,
+		Expiry:     t.Expiry,
+		CanRefresh: t.HasRefreshToken && !t.UserIsDisabled && slices.Contains(broker.Capabilities(), brokers.CapabilityTokenRefresh),
+	}
+}
@@ -6,42 +6,154 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/canonical/authd/internal/brokers"
+	"github.com/canonical/authd/internal/brokers/auth"
+	"github.com/canonical/authd/internal/consts"
+	"github.com/canonical/authd/internal/faillock"
+	"github.com/canonical/authd/internal/loginpolicy"
+	"github.com/canonical/authd/internal/metrics"
 	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/securityreport"
+	"github.com/canonical/authd/internal/services/events"
 	"github.com/canonical/authd/internal/services/permissions"
 	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
 	"github.com/canonical/authd/internal/users/types"
 	"github.com/canonical/authd/log"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// brokerPingTimeout is how long GetStatus waits for each broker to answer.
+const brokerPingTimeout = 5 * time.Second
+
+// userStatusLogins is the number of recent logins returned by GetUserStatus.
+const userStatusLogins = 5
+
+// scheduledTasksInterval is how often the users whose dwell time in their deprovisioning state elapsed are moved to
+// their next state, the home directories of deleted users are cleaned up, and the users are checked with their broker
+// and a snapshot of the database is made if they are due.
+var scheduledTasksInterval = time.Hour
+
 // Service is the implementation of the gRPC user service.
 type Service struct {
 	userManager       *users.Manager
 	brokerManager     *brokers.Manager
 	permissionManager *permissions.Manager
 
+	securityReportConfig securityreport.Config
+	loginPolicy          *loginpolicy.Policy
+	faillock             *faillock.Tracker
+	events               *events.Broadcaster
+	replies              *ReplyCache
+	metrics              *metrics.Metrics
+	enumerate            bool
+
+	// startTime is when the daemon started, reported by GetStatus.
+	startTime time.Time
+
 	authd.UnimplementedUserServiceServer
 }
 
+type options struct {
+	securityReportConfig securityreport.Config
+	loginPolicy          *loginpolicy.Policy
+	faillock             *faillock.Tracker
+	events               *events.Broadcaster
+	replies              *ReplyCache
+	metrics              *metrics.Metrics
+	enumerate            bool
+}
+
+// Option represents an optional function to override Service default values.
+type Option func(*options)
+
+// WithSecurityReportConfig sets the deployment configuration evaluated by GetSecurityReport.
+func WithSecurityReportConfig(cfg securityreport.Config) Option {
+	return func(o *options) {
+		o.securityReportConfig = cfg
+	}
+}
+
+// WithLoginPolicy sets the login policy of the machine returned by GetLoginPolicy.
+func WithLoginPolicy(p *loginpolicy.Policy) Option {
+	return func(o *options) {
+		o.loginPolicy = p
+	}
+}
+
+// WithFaillock sets the tracker of the users locked out after too many failed authentication attempts, which are
+// unlocked along with the locked users.
+func WithFaillock(t *faillock.Tracker) Option {
+	return func(o *options) {
+		o.faillock = t
+	}
+}
+
+// WithEvents sets the broadcaster the session tokens handed to the users are published to.
+func WithEvents(b *events.Broadcaster) Option {
+	return func(o *options) {
+		o.events = b
+	}
+}
+
+// WithReplyCache sets the cache of the replies to the lookups of users and groups by ID. The cache must be notified of
+// the changes of the users and groups, see [users.WithChangeNotifier].
+func WithReplyCache(c *ReplyCache) Option {
+	return func(o *options) {
+		o.replies = c
+	}
+}
+
+// WithMetrics sets the metrics the failures to refresh the session tokens are recorded to.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// WithEnumeration sets whether all the users and groups are listed to the unprivileged callers, like the enumerations
+// of the NSS module ("getent passwd"). If disabled, they only get the users and groups they look up by name or ID,
+// which saves listing all of them on machines with many users. It's enabled by default.
+func WithEnumeration(enabled bool) Option {
+	return func(o *options) {
+		o.enumerate = enabled
+	}
+}
+
 // NewService returns a new gRPC user service.
-func NewService(ctx context.Context, userManager *users.Manager, brokerManager *brokers.Manager, permissionManager *permissions.Manager) Service {
+func NewService(ctx context.Context, userManager *users.Manager, brokerManager *brokers.Manager, permissionManager *permissions.Manager, args ...Option) Service {
 	log.Debug(ctx, "Building new gRPC user service")
 
+	opts := options{loginPolicy: loginpolicy.NewPolicy(loginpolicy.Config{}, nil), enumerate: true}
+	for _, arg := range args {
+		arg(&opts)
+	}
+
 	return Service{
-		userManager:       userManager,
-		brokerManager:     brokerManager,
-		permissionManager: permissionManager,
+		userManager:          userManager,
+		brokerManager:        brokerManager,
+		permissionManager:    permissionManager,
+		securityReportConfig: opts.securityReportConfig,
+		loginPolicy:          opts.loginPolicy,
+		faillock:             opts.faillock,
+		events:               opts.events,
+		replies:              opts.replies,
+		metrics:              opts.metrics,
+		enumerate:            opts.enumerate,
+		startTime:            time.Now(),
 	}
 }
 
 // GetUserByName returns the user entry for the given username.
 func (s Service) GetUserByName(ctx context.Context, req *authd.GetUserByNameRequest) (*authd.User, error) {
-	// authd usernames are lowercase
-	name := strings.ToLower(req.GetName())
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
 	if name == "" {
 		log.Warningf(ctx, "GetUserByName: no user name provided")
 		return nil, status.Error(codes.InvalidArgument, "no user name provided")
@@ -65,6 +177,13 @@ func (s Service) GetUserByName(ctx context.Context, req *authd.GetUserByNameRequ
 		return nil, grpcError(err)
 	}
 
+	// In lockdown, users who never logged in are not provisioned, so there is no need to ask the brokers.
+	if !s.loginPolicy.Current().AllowsNewUsers() {
+		err := fmt.Errorf("user %q is unknown and new users can't log in in lockdown", name)
+		log.Warningf(context.Background(), "GetUserByName: %v", err)
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
 	// If the user is not found in the database, we check if it exists in at least one broker.
 	user, err = s.userPreCheck(ctx, name)
 	if errors.Is(err, errUserNotPermitted) {
@@ -87,30 +206,51 @@ func (s Service) GetUserByID(ctx context.Context, req *authd.GetUserByIDRequest)
 		return nil, status.Error(codes.InvalidArgument, "no user ID provided")
 	}
 
+	if u, ok := s.replies.user(req.GetId()); ok {
+		return u, nil
+	}
+
 	u, err := s.userManager.UserByID(req.Id)
 	if errors.Is(err, users.NoDataFoundError{}) {
 		// Only log this at debug level, see GetUserByName for details.
 		log.Debugf(context.Background(), "GetUserByID: %v", err)
-		return nil, grpcError(err)
+		return nil, errUserIDNotFound
 	}
 	if err != nil {
 		log.Errorf(context.Background(), "GetUserByID: %v", err)
 		return nil, grpcError(err)
 	}
 
-	return userToProtobuf(u), nil
+	reply := userToProtobuf(u)
+	s.replies.addUser(reply)
+	return reply, nil
 }
 
-// ListUsers returns all authd users.
-func (s Service) ListUsers(ctx context.Context, req *authd.Empty) (*authd.Users, error) {
-	allUsers, err := s.userManager.AllUsers()
+// ListUsers returns the authd users, ordered by UID. If a page size is set, only returns this number of users and a
+// token to get the next page. If the enumeration is disabled, no user is returned to the callers which are not root.
+func (s Service) ListUsers(ctx context.Context, req *authd.ListUsersRequest) (*authd.Users, error) {
+	if !s.enumerationAllowed(ctx) {
+		return &authd.Users{}, nil
+	}
+
+	afterUID, err := parsePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Request one more user than the page size to know if there is a next page.
+	usrs, err := s.userManager.UsersPage(afterUID, pageLimit(req.GetPageSize()))
 	if err != nil {
 		log.Errorf(context.Background(), "ListUsers: %v", err)
 		return nil, grpcError(err)
 	}
 
 	var res authd.Users
-	for _, u := range allUsers {
+	if req.GetPageSize() > 0 && len(usrs) > int(req.GetPageSize()) {
+		usrs = usrs[:req.GetPageSize()]
+		res.NextPageToken = strconv.FormatUint(uint64(usrs[len(usrs)-1].UID), 10)
+	}
+	for _, u := range usrs {
 		res.Users = append(res.Users, userToProtobuf(u))
 	}
 
@@ -123,8 +263,8 @@ func (s Service) LockUser(ctx context.Context, req *authd.LockUserRequest) (*aut
 		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
 
-	// authd uses lowercase usernames.
-	name := strings.ToLower(req.GetName())
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
 
 	if name == "" {
 		return nil, status.Error(codes.InvalidArgument, "no user name provided")
@@ -143,14 +283,22 @@ func (s Service) UnlockUser(ctx context.Context, req *authd.UnlockUserRequest) (
 		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
 
-	// authd uses lowercase usernames.
-	name := strings.ToLower(req.GetName())
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
 
 	if name == "" {
 		return nil, status.Error(codes.InvalidArgument, "no user name provided")
 	}
 
+	wasLockedOut := s.faillock.Unlock(name)
+	if wasLockedOut {
+		log.Noticef(ctx, "User %q is no longer locked out after too many failed authentication attempts", name)
+	}
 	if err := s.userManager.UnlockUser(name); err != nil {
+		if wasLockedOut && errors.Is(err, users.NoDataFoundError{}) {
+			// The user was locked out before they could log in for the first time.
+			return &authd.Empty{}, nil
+		}
 		return nil, grpcError(err)
 	}
 
@@ -188,44 +336,94 @@ func (s Service) GetGroupByID(ctx context.Context, req *authd.GetGroupByIDReques
 		return nil, status.Error(codes.InvalidArgument, "no group ID provided")
 	}
 
+	if g, ok := s.replies.group(req.GetId()); ok {
+		return g, nil
+	}
+
 	g, err := s.userManager.GroupByID(req.GetId())
 	if errors.Is(err, users.NoDataFoundError{}) {
 		// Only log this at debug level, see GetUserByName for details
 		log.Debugf(context.Background(), "GetGroupByID: %v", err)
-		return nil, grpcError(err)
+		return nil, errGroupIDNotFound
 	}
 	if err != nil {
 		log.Errorf(context.Background(), "GetGroupByID: %v", err)
 		return nil, grpcError(err)
 	}
 
-	return groupToProtobuf(g), nil
+	reply := groupToProtobuf(g)
+	s.replies.addGroup(reply)
+	return reply, nil
 }
 
-// ListGroups returns all authd groups.
-func (s Service) ListGroups(ctx context.Context, req *authd.Empty) (*authd.Groups, error) {
-	allGroups, err := s.userManager.AllGroups()
+// ListGroups returns the authd groups, ordered by GID. If a page size is set, only returns this number of groups and
+// a token to get the next page. If the enumeration is disabled, no group is returned to the callers which are not
+// root.
+func (s Service) ListGroups(ctx context.Context, req *authd.ListGroupsRequest) (*authd.Groups, error) {
+	if !s.enumerationAllowed(ctx) {
+		return &authd.Groups{}, nil
+	}
+
+	afterGID, err := parsePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Request one more group than the page size to know if there is a next page.
+	grps, err := s.userManager.GroupsPage(afterGID, pageLimit(req.GetPageSize()))
 	if err != nil {
 		log.Errorf(context.Background(), "ListGroups: %v", err)
 		return nil, grpcError(err)
 	}
 
 	var res authd.Groups
-	for _, g := range allGroups {
+	if req.GetPageSize() > 0 && len(grps) > int(req.GetPageSize()) {
+		grps = grps[:req.GetPageSize()]
+		res.NextPageToken = strconv.FormatUint(uint64(grps[len(grps)-1].GID), 10)
+	}
+	for _, g := range grps {
 		res.Groups = append(res.Groups, groupToProtobuf(g))
 	}
 
 	return &res, nil
 }
 
+// GetGroupsForUser returns the authd groups of the user, so that the NSS module can set the supplementary groups of
+// their processes (initgroups) without listing all the groups. The members of the groups are not returned.
+func (s Service) GetGroupsForUser(ctx context.Context, req *authd.GetGroupsForUserRequest) (*authd.Groups, error) {
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
+	if name == "" {
+		log.Warningf(ctx, "GetGroupsForUser: no user name provided")
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+
+	grps, err := s.userManager.GroupsForUser(name)
+	if errors.Is(err, users.NoDataFoundError{}) {
+		// Only log this at debug level, see GetUserByName for details.
+		log.Debugf(context.Background(), "GetGroupsForUser: %v", err)
+		return nil, grpcError(err)
+	}
+	if err != nil {
+		log.Errorf(context.Background(), "GetGroupsForUser: %v", err)
+		return nil, grpcError(err)
+	}
+
+	var res authd.Groups
+	for _, g := range grps {
+		res.Groups = append(res.Groups, groupToProtobuf(g))
+	}
+	return &res, nil
+}
+
 // SetUserID sets the UID of a user.
 func (s Service) SetUserID(ctx context.Context, req *authd.SetUserIDRequest) (*authd.SetUserIDResponse, error) {
 	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
 		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
 
-	// authd uses lowercase usernames.
-	name := strings.ToLower(req.GetName())
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
 
 	if name == "" {
 		return nil, status.Error(codes.InvalidArgument, "no user name provided")
@@ -272,8 +470,8 @@ func (s Service) SetGroupID(ctx context.Context, req *authd.SetGroupIDRequest) (
 
 // SetShell sets the shell of a user.
 func (s Service) SetShell(ctx context.Context, req *authd.SetShellRequest) (*authd.SetShellResponse, error) {
-	// authd uses lowercase group names.
-	name := strings.ToLower(req.GetName())
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
 
 	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
 		return nil, status.Error(codes.PermissionDenied, err.Error())
@@ -296,8 +494,8 @@ func (s Service) SetHomeDir(ctx context.Context, req *authd.SetHomeDirRequest) (
 		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
 
-	// authd uses lowercase usernames.
-	name := strings.ToLower(req.GetName())
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
 
 	if name == "" {
 		return nil, status.Error(codes.InvalidArgument, "no user name provided")
@@ -322,8 +520,8 @@ func (s Service) DeleteUser(ctx context.Context, req *authd.DeleteUserRequest) (
 		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
 
-	// authd uses lowercase usernames.
-	name := strings.ToLower(req.GetName())
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
 	if name == "" {
 		return nil, status.Error(codes.InvalidArgument, "no user name provided")
 	}
@@ -340,7 +538,17 @@ func (s Service) DeleteUser(ctx context.Context, req *authd.DeleteUserRequest) (
 		log.Errorf(context.Background(), "failed to look up broker and provider ID for user %q: %v", name, err)
 	}
 
-	if err := s.userManager.DeleteUser(name, req.GetRemoveHome()); err != nil {
+	if req.GetRemoveHome() && req.GetArchiveHome() {
+		return nil, status.Error(codes.InvalidArgument, "the home directory can't be both removed and archived")
+	}
+
+	var archive string
+	if req.GetArchiveHome() {
+		archive, err = s.userManager.DeleteUserArchivingHome(ctx, name)
+	} else {
+		err = s.userManager.DeleteUser(name, req.GetRemoveHome())
+	}
+	if err != nil {
 		log.Errorf(ctx, "DeleteUser: %v", err)
 		return nil, grpcError(err)
 	}
@@ -348,23 +556,34 @@ func (s Service) DeleteUser(ctx context.Context, req *authd.DeleteUserRequest) (
 	// Notify the broker so it can clean up any broker side data (tokens, cached
 	// passwords, etc.) stored for this user. Failures here are non-fatal. The
 	// user has already been removed from the authd DB, so we return a warning and
-	// still return success. The local broker has no remote data, so skip it.
-	if brokerID != "" && brokerID != brokers.LocalBrokerName {
-		broker, err := s.brokerManager.BrokerFromID(brokerID)
-		if err != nil {
-			brokerCleanupFailedOrSkipped = true
-			log.Errorf(context.Background(), "failed to get broker %q for user %q: %v", brokerID, name, err)
-		} else if err := broker.DeleteUser(ctx, name, providerID); err != nil {
-			brokerCleanupFailedOrSkipped = true
-			log.Errorf(context.Background(), "failed to delete user %q from broker %q: %v", name, brokerID, err)
-		}
+	// still return success.
+	if err := s.deleteUserFromBroker(ctx, name, brokerID, providerID); err != nil {
+		brokerCleanupFailedOrSkipped = true
+		log.Errorf(context.Background(), "%v", err)
 	}
 
 	if brokerCleanupFailedOrSkipped {
 		warnings = append(warnings, fmt.Sprintf("Failed to remove locally cached authentication data for user %q from the broker; residual data may remain on disk. Check the system logs for details.", name))
 	}
 
-	return &authd.DeleteUserResponse{Warnings: warnings}, nil
+	return &authd.DeleteUserResponse{Warnings: warnings, HomeArchive: archive}, nil
+}
+
+// deleteUserFromBroker removes the data stored by the broker for the user. The local broker has no data, so it is
+// skipped.
+func (s Service) deleteUserFromBroker(ctx context.Context, name, brokerID, providerID string) error {
+	if brokerID == "" || brokerID == brokers.LocalBrokerName {
+		return nil
+	}
+
+	broker, err := s.brokerManager.BrokerFromID(brokerID)
+	if err != nil {
+		return fmt.Errorf("failed to get broker %q for user %q: %w", brokerID, name, err)
+	}
+	if err := broker.DeleteUser(ctx, name, providerID); err != nil {
+		return fmt.Errorf("failed to delete user %q from broker %q: %w", name, brokerID, err)
+	}
+	return nil
 }
 
 // DeleteGroup removes the group with the given name from the authd database.
@@ -387,89 +606,1242 @@ func (s Service) DeleteGroup(ctx context.Context, req *authd.DeleteGroupRequest)
 	return &authd.Empty{}, nil
 }
 
-// userToProtobuf converts a types.UserEntry to authd.User.
-func userToProtobuf(u types.UserEntry) *authd.User {
-	return &authd.User{
-		Name:    u.Name,
-		Uid:     u.UID,
-		Gid:     u.GID,
-		Gecos:   u.Gecos,
-		Homedir: u.Dir,
-		Shell:   u.Shell,
+// GetLoginHistory returns the most recent logins of the user with the given name.
+func (s Service) GetLoginHistory(ctx context.Context, req *authd.GetLoginHistoryRequest) (*authd.LoginHistory, error) {
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+
+	u, err := s.userManager.UserByName(name)
+	if err != nil {
+		log.Debugf(ctx, "GetLoginHistory: %v", err)
+		return nil, grpcError(err)
+	}
+
+	// Users can review their own login history, root can review the history of all users.
+	if err := s.permissionManager.CheckRequestIsFromRootOrUID(ctx, u.UID); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	records, err := s.userManager.LoginHistory(name, req.GetLimit())
+	if err != nil {
+		log.Errorf(ctx, "GetLoginHistory: %v", err)
+		return nil, grpcError(err)
+	}
+
+	var res authd.LoginHistory
+	for _, r := range records {
+		res.Records = append(res.Records, &authd.LoginRecord{
+			Time:         r.Time.Unix(),
+			Service:      r.Service,
+			BrokerId:     r.BrokerID,
+			Offline:      r.Offline,
+			Result:       r.Result,
+			MachineToken: r.MachineToken,
+		})
 	}
+
+	return &res, nil
 }
 
-// groupToProtobuf converts a types.GroupEntry to authd.Group.
-func groupToProtobuf(g types.GroupEntry) *authd.Group {
-	return &authd.Group{
-		Name:    g.Name,
-		Gid:     g.GID,
-		Members: g.Users,
-		Passwd:  g.Passwd,
+// GetSecurityReport evaluates the deployment against security best practices.
+func (s Service) GetSecurityReport(ctx context.Context, _ *authd.Empty) (*authd.SecurityReport, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// The offline limit can be changed by the central login policy.
+	cfg := s.securityReportConfig
+	cfg.OfflineMaxAge = s.loginPolicy.Current().OfflineMaxAge
+	report := securityreport.Evaluate(cfg)
+
+	res := authd.SecurityReport{
+		//nolint:gosec // The score is a percentage.
+		Score: int32(report.Score),
 	}
+	for _, c := range report.Checks {
+		res.Checks = append(res.Checks, &authd.SecurityCheck{
+			Id:          c.ID,
+			Description: c.Description,
+			Status:      string(c.Status),
+			Details:     c.Details,
+		})
+	}
+
+	return &res, nil
 }
 
-var errUserNotPermitted = errors.New("user not permitted to log in via SSH for the first time")
+// GetStatus returns the state of the daemon, of its database and of its brokers.
+func (s Service) GetStatus(ctx context.Context, _ *authd.Empty) (*authd.DaemonStatus, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	stats, err := s.userManager.Stats()
+	if err != nil {
+		log.Errorf(ctx, "GetStatus: %v", err)
+		return nil, err
+	}
+
+	res := &authd.DaemonStatus{
+		Version:   consts.Version,
+		StartTime: s.startTime.Unix(),
+		Database: &authd.DatabaseStatus{
+			Path:          stats.Path,
+			Size:          stats.Size,
+			SchemaVersion: stats.SchemaVersion,
+			Users:         stats.Users,
+			LockedUsers:   stats.LockedUsers,
+			Groups:        stats.Groups,
+			//nolint:gosec // There are at most tempentries.MaxPreAuthUsers pending users.
+			PendingUsers: uint32(stats.PendingUsers),
+		},
+	}
+	for _, l := range stats.Latencies {
+		op := &authd.OperationLatency{Operation: l.Operation, Count: l.Count, SumSeconds: l.Sum.Seconds()}
+		for i, bound := range db.LatencyBuckets {
+			op.Buckets = append(op.Buckets, &authd.LatencyBucket{UpperBoundSeconds: bound.Seconds(), Count: l.Buckets[i]})
+		}
+		res.Database.Operations = append(res.Database.Operations, op)
+	}
+
+	lastOnlineLogins, err := s.userManager.LastOnlineLoginsByBroker()
+	if err != nil {
+		log.Errorf(ctx, "GetStatus: %v", err)
+		return nil, err
+	}
 
-// userPreCheck checks if the user is permitted to log in via SSH for the first time.
-// It returns a types.UserEntry with a unique UID if the user is permitted to log in.
-// If the user is not permitted to log in by any broker, errUserNotPermitted is returned.
-func (s Service) userPreCheck(ctx context.Context, username string) (types.UserEntry, error) {
-	// Check if any broker permits the user to log in via SSH for the first time.
-	var userinfo string
-	var err error
 	for _, b := range s.brokerManager.AvailableBrokers() {
-		// The local broker is not a real broker, so we skip it.
-		if b.ID == brokers.LocalBrokerName {
-			continue
+		bs := &authd.BrokerStatus{
+			Id:   b.ID,
+			Name: b.Name,
+			//nolint:gosec // The API version is a small positive number.
+			ApiVersion: uint32(b.APIVersion()),
+			//nolint:gosec // The number of sessions is a small positive number.
+			PendingSessions: uint32(s.brokerManager.PendingSessions(b.ID)),
 		}
+		if t, ok := lastOnlineLogins[b.ID]; ok {
+			bs.LastOnlineLogin = t.Unix()
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, brokerPingTimeout)
+		if err := b.Ping(pingCtx); err != nil {
+			log.Warningf(ctx, "Broker %q does not answer: %v", b.Name, err)
+			bs.Error = err.Error()
+		}
+		cancel()
+		res.Brokers = append(res.Brokers, bs)
+	}
 
-		userinfo, err = b.UserPreCheck(ctx, username)
-		if err != nil {
-			// An unexpected error occurred while checking the user.
-			log.Errorf(ctx, "UserPreCheck: %v", err)
-			continue
+	return res, nil
+}
+
+// RollBackDatabase reverts the schema migrations applied to the database since its last backup.
+func (s Service) RollBackDatabase(ctx context.Context, _ *authd.Empty) (*authd.DatabaseRollback, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	from, to, err := s.userManager.RollBackDatabase()
+	if err != nil {
+		log.Errorf(ctx, "RollBackDatabase: %v", err)
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	log.Noticef(ctx, "Rolled back the database from schema version %d to %d", from, to)
+
+	//nolint:gosec // The schema versions are small positive numbers.
+	return &authd.DatabaseRollback{FromVersion: uint32(from), ToVersion: uint32(to)}, nil
+}
+
+// GetLoginPolicy returns the login policy of the machine.
+func (s Service) GetLoginPolicy(ctx context.Context, _ *authd.Empty) (*authd.LoginPolicy, error) {
+	cfg := s.loginPolicy.Current()
+	res := &authd.LoginPolicy{
+		AllowedGroups: cfg.AllowedGroups,
+		DeniedGroups:  cfg.DeniedGroups,
+		Lockdown:      cfg.Lockdown,
+		LoginHours:    cfg.LoginHours,
+		OfflineMaxAge: int64(cfg.OfflineMaxAge / time.Second),
+		//nolint:gosec // The limit is validated to be lower than the number of logins kept in the history.
+		OfflineMaxLogins: uint32(cfg.OfflineMaxLogins),
+	}
+
+	if central := s.loginPolicy.Central(); central != nil {
+		st := central.Status()
+		res.Central = &authd.CentralPolicy{
+			Url:     st.URL,
+			Applied: st.Applied,
+			Serial:  st.Serial,
+			Error:   st.Error,
 		}
-		if userinfo == "" {
-			// The broker does not permit the user to log in via SSH for the first time.
-			// This is an expected error, so we only log it at debug level.
-			log.Debugf(ctx, "UserPreCheck: %v", err)
-			continue
+		if !st.FetchedAt.IsZero() {
+			res.Central.FetchedAt = st.FetchedAt.Unix()
 		}
-		break
 	}
 
-	if err != nil || userinfo == "" {
-		// No broker permits the user to log in via SSH for the first time.
-		return types.UserEntry{}, errUserNotPermitted
+	return res, nil
+}
+
+// SetDefaultBroker selects the broker for the users who never logged in through a PAM service, or through all of them.
+func (s Service) SetDefaultBroker(ctx context.Context, req *authd.SetDefaultBrokerRequest) (*authd.Empty, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
 
-	var u types.UserEntry
-	if err := json.Unmarshal([]byte(userinfo), &u); err != nil {
-		return types.UserEntry{}, fmt.Errorf("user data from broker invalid: %v", err)
+	if req.GetBroker() == "" {
+		return nil, status.Error(codes.InvalidArgument, "no broker provided")
 	}
 
-	// Register a temporary user with a unique UID. If the user authenticates successfully, the user will be added to
-	// the database with the same UID.
-	u.UID, err = s.userManager.RegisterUserPreAuth(u.Name)
+	b, err := s.brokerByNameOrID(req.GetBroker())
 	if err != nil {
-		return types.UserEntry{}, fmt.Errorf("failed to add temporary record for user %q: %v", username, err)
+		return nil, err
+	}
+	// The local broker is selected when the user is provided by another NSS service, not by default.
+	if b.ID == brokers.LocalBrokerName {
+		return nil, status.Errorf(codes.InvalidArgument, "the local broker can't be the default broker")
 	}
-	// The UID is also the GID of the user private group (see https://wiki.debian.org/UserPrivateGroups#UPGs)
-	u.GID = u.UID
 
-	return u, nil
+	if err := s.brokerManager.SetDefaultBroker(req.GetService(), b.ID); err != nil {
+		log.Errorf(ctx, "SetDefaultBroker: %v", err)
+		return nil, err
+	}
+	if req.GetService() == "" {
+		log.Infof(ctx, "Default broker set to %q", b.Name)
+	} else {
+		log.Infof(ctx, "Default broker of %s set to %q", req.GetService(), b.Name)
+	}
+
+	return &authd.Empty{}, nil
 }
 
-// grpcError converts well-known user manager errors to their proper gRPC status codes.
-// The NSS module uses these status codes to determine the NSS status it should return.
-func grpcError(err error) error {
-	if errors.Is(err, users.NoDataFoundError{}) {
-		return status.Error(codes.NotFound, err.Error())
+// ListBrokers returns the brokers registered in authd, in the order they are offered to the users.
+func (s Service) ListBrokers(ctx context.Context, _ *authd.Empty) (*authd.Brokers, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
 
-	var primaryErr users.GroupIsPrimaryError
-	if errors.As(err, &primaryErr) {
+	res := &authd.Brokers{}
+	for _, b := range s.brokerManager.AvailableBrokers() {
+		res.Brokers = append(res.Brokers, s.brokerDetails(b))
+	}
+	return res, nil
+}
+
+// GetBrokerDetails returns the configuration of a broker and the optional features it supports.
+func (s Service) GetBrokerDetails(ctx context.Context, req *authd.GetBrokerDetailsRequest) (*authd.BrokerDetails, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if req.GetBroker() == "" {
+		return nil, status.Error(codes.InvalidArgument, "no broker provided")
+	}
+
+	b, err := s.brokerByNameOrID(req.GetBroker())
+	if err != nil {
+		return nil, err
+	}
+	return s.brokerDetails(b), nil
+}
+
+// brokerDetails returns the configuration of the broker and the optional features it supports.
+func (s Service) brokerDetails(b *brokers.Broker) *authd.BrokerDetails {
+	isDefault, defaultServices := s.brokerManager.DefaultFor(b)
+	return &authd.BrokerDetails{
+		Id:         b.ID,
+		Name:       b.Name,
+		BrandIcon:  b.BrandIconPath,
+		ConfigFile: b.ConfigFile,
+		DbusName:   b.DBusName,
+		DbusObject: b.DBusObject,
+		//nolint:gosec // The API version is a small positive number.
+		ApiVersion:             uint32(b.APIVersion()),
+		Capabilities:           b.Capabilities(),
+		Default:                isDefault,
+		DefaultServices:        defaultServices,
+		DefaultShell:           b.UserDefaults.Shell,
+		DefaultGroups:          b.UserDefaults.Groups,
+		DefaultPrimaryGroupGid: b.UserDefaults.SharedPrimaryGID,
+	}
+}
+
+// CheckBroker checks that the broker answers and that it can reach its identity provider, without any user logging
+// in. The checks which failed are reported in the response, not as an error.
+func (s Service) CheckBroker(ctx context.Context, req *authd.CheckBrokerRequest) (*authd.BrokerCheck, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if req.GetBroker() == "" {
+		return nil, status.Error(codes.InvalidArgument, "no broker provided")
+	}
+
+	b, err := s.brokerByNameOrID(req.GetBroker())
+	if err != nil {
+		return nil, err
+	}
+	// The users of the local broker are provided by another NSS service.
+	if b.ID == brokers.LocalBrokerName {
+		return nil, status.Error(codes.InvalidArgument, "the local broker has no identity provider to check")
+	}
+
+	res := &authd.BrokerCheck{}
+	pingCtx, cancel := context.WithTimeout(ctx, brokerPingTimeout)
+	err = b.Ping(pingCtx)
+	cancel()
+	if err != nil {
+		log.Warningf(ctx, "Broker %q does not answer: %v", b.Name, err)
+		res.Checks = append(res.Checks,
+			&authd.BrokerCheck_Check{Name: "dbus", Status: "failed", Details: err.Error()},
+			&authd.BrokerCheck_Check{Name: "provider", Status: "skipped", Details: "the broker does not answer"},
+		)
+		return res, nil
+	}
+	res.Checks = append(res.Checks, &authd.BrokerCheck_Check{
+		Name:    "dbus",
+		Status:  "ok",
+		Details: fmt.Sprintf("the broker answers on %s", b.DBusName),
+	})
+
+	checks, err := b.CheckProvider(ctx)
+	if errors.Is(err, brokers.ErrProviderCheckNotSupported) {
+		res.Checks = append(res.Checks, &authd.BrokerCheck_Check{Name: "provider", Status: "skipped", Details: err.Error()})
+		return res, nil
+	}
+	if err != nil {
+		log.Warningf(ctx, "CheckBroker: could not check the provider of broker %q: %v", b.Name, err)
+		res.Checks = append(res.Checks, &authd.BrokerCheck_Check{Name: "provider", Status: "failed", Details: err.Error()})
+		return res, nil
+	}
+	for _, c := range checks {
+		res.Checks = append(res.Checks, &authd.BrokerCheck_Check{Name: c.Name, Status: c.Status, Details: c.Details})
+	}
+
+	return res, nil
+}
+
+// LinkIdentity allows a user to authenticate with another broker than the one they're bound to, keeping their UID,
+// home directory and groups, e.g. when their organization migrates to another identity provider.
+func (s Service) LinkIdentity(ctx context.Context, req *authd.LinkIdentityRequest) (*authd.Empty, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+	if req.GetBroker() == "" {
+		return nil, status.Error(codes.InvalidArgument, "no broker provided")
+	}
+
+	b, err := s.brokerByNameOrID(req.GetBroker())
+	if err != nil {
+		return nil, err
+	}
+	// The users of the local broker are provided by another NSS service.
+	if b.ID == brokers.LocalBrokerName {
+		return nil, status.Errorf(codes.InvalidArgument, "an identity can't be linked at the local broker")
+	}
+
+	if err := s.userManager.LinkIdentity(name, b.ID); err != nil {
+		return nil, grpcError(err)
+	}
+	log.Infof(ctx, "Linked the identity of user %q at broker %q", name, b.Name)
+
+	return &authd.Empty{}, nil
+}
+
+// UnlinkIdentity removes the identity of a user at a broker which was linked to them.
+func (s Service) UnlinkIdentity(ctx context.Context, req *authd.UnlinkIdentityRequest) (*authd.Empty, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+	if req.GetBroker() == "" {
+		return nil, status.Error(codes.InvalidArgument, "no broker provided")
+	}
+
+	// The identities of a broker which is not registered anymore can still be unlinked with its ID.
+	brokerID := req.GetBroker()
+	if b := s.brokerManager.BrokerFromName(brokerID); b != nil {
+		brokerID = b.ID
+	}
+
+	if err := s.userManager.UnlinkIdentity(name, brokerID); err != nil {
+		return nil, grpcError(err)
+	}
+	log.Infof(ctx, "Unlinked the identity of user %q at broker %q", name, req.GetBroker())
+
+	return &authd.Empty{}, nil
+}
+
+// GetLinkedIdentities returns the identities linked to a user.
+func (s Service) GetLinkedIdentities(ctx context.Context, req *authd.GetLinkedIdentitiesRequest) (*authd.LinkedIdentities, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+
+	brokerID, err := s.userManager.BrokerForUser(name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	identities, err := s.userManager.LinkedIdentities(name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &authd.LinkedIdentities{BrokerId: brokerID}
+	for _, i := range identities {
+		identity := &authd.LinkedIdentities_Identity{BrokerId: i.BrokerID, ProviderId: i.ProviderID}
+		if b, err := s.brokerManager.BrokerFromID(i.BrokerID); err == nil {
+			identity.BrokerName = b.Name
+		}
+		res.Identities = append(res.Identities, identity)
+	}
+
+	return res, nil
+}
+
+// brokerByNameOrID returns the registered broker with this name or ID.
+func (s Service) brokerByNameOrID(nameOrID string) (*brokers.Broker, error) {
+	if b := s.brokerManager.BrokerFromName(nameOrID); b != nil {
+		return b, nil
+	}
+	if b, err := s.brokerManager.BrokerFromID(nameOrID); err == nil {
+		return b, nil
+	}
+
+	var names []string
+	for _, b := range s.brokerManager.AvailableBrokers() {
+		if b.ID != brokers.LocalBrokerName {
+			names = append(names, strconv.Quote(b.Name))
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "broker %q is not registered, available brokers: %s", nameOrID, strings.Join(names, ", "))
+}
+
+// VerifyUser asks the broker of the user whether their account is still enabled at the provider and checks that they
+// are still allowed to log in on this machine, without authenticating them.
+func (s Service) VerifyUser(ctx context.Context, req *authd.VerifyUserRequest) (*authd.UserVerification, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+
+	brokerID, providerID, err := s.userManager.BrokerAndProviderIDForUser(name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if brokerID == "" || brokerID == brokers.LocalBrokerName {
+		return nil, status.Errorf(codes.FailedPrecondition, "user %q is not provided by a broker", name)
+	}
+	broker, err := s.brokerManager.BrokerFromID(brokerID)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "the broker of user %q is not available: %v", name, err)
+	}
+
+	locked, err := s.userManager.IsUserLocked(name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	v, err := broker.VerifyUser(ctx, name, providerID)
+	if errors.Is(err, brokers.ErrVerifyUserNotSupported) {
+		return nil, status.Errorf(codes.Unimplemented, "broker %q does not support verifying users", broker.Name)
+	}
+	if err != nil {
+		log.Errorf(ctx, "VerifyUser: %v", err)
+		return nil, status.Errorf(codes.Unavailable, "could not verify user %q with broker %q: %v", name, broker.Name, err)
+	}
+
+	res := &authd.UserVerification{
+		Status:  v.Status,
+		Message: v.Message,
+		Broker:  broker.Name,
+		Locked:  locked,
+	}
+	// The groups of the user may have changed at the provider since they last logged in.
+	if v.Status == auth.UserActive {
+		policy := s.loginPolicy.Current()
+		if g := policy.DeniedGroup(v.UserInfo.Groups); g != "" {
+			res.Status = "not_allowed"
+			res.Message = fmt.Sprintf("The user is a member of the group %s, which is denied to log in on this machine.", g)
+		} else if !policy.Allows(v.UserInfo.Groups) {
+			res.Status = "not_allowed"
+			res.Message = "The user is not a member of any group allowed to log in on this machine."
+		}
+	}
+	log.Infof(ctx, "User %q verified by broker %q: %s", name, broker.Name, res.Status)
+
+	return res, nil
+}
+
+// GetSessionToken returns an access token of the calling user from their broker, so that the applications running in
+// their session can reuse their login instead of authenticating them again with the provider.
+func (s Service) GetSessionToken(ctx context.Context, req *authd.GetSessionTokenRequest) (*authd.SessionToken, error) {
+	if len(req.GetScopes()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no scope provided")
+	}
+
+	uid, err := s.permissionManager.PeerUID(ctx)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	u, err := s.userManager.UserByID(uid)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	brokerID, err := s.userManager.BrokerForUser(u.Name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if brokerID == "" || brokerID == brokers.LocalBrokerName {
+		return nil, status.Errorf(codes.FailedPrecondition, "user %q is not provided by a broker", u.Name)
+	}
+	locked, err := s.userManager.IsUserLocked(u.Name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if locked {
+		return nil, status.Errorf(codes.PermissionDenied, "user %q is locked", u.Name)
+	}
+	broker, err := s.brokerManager.BrokerFromID(brokerID)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "the broker of user %q is not available: %v", u.Name, err)
+	}
+
+	t, err := broker.SessionToken(ctx, u.Name, req.GetScopes())
+	if errors.Is(err, brokers.ErrSessionTokenNotSupported) {
+		return nil, status.Errorf(codes.Unimplemented, "broker %q does not support session tokens", broker.Name)
+	}
+	if err != nil {
+		log.Errorf(ctx, "GetSessionToken: %v", err)
+		s.metrics.TokenRefreshFailed(broker.ID)
+		return nil, status.Errorf(codes.Unavailable, "could not get a token of user %q from broker %q: %v", u.Name, broker.Name, err)
+	}
+	log.Infof(ctx, "Handed a token of user %q for scopes %v to their session", u.Name, t.Scopes)
+	s.events.Publish(events.Event{Kind: events.TokenIssued, Username: u.Name, UID: u.UID, BrokerID: broker.ID})
+
+	return &authd.SessionToken{
+		AccessToken: t.AccessToken,
+		TokenType:   t.TokenType,
+		Expiry:      t.Expiry.Unix(),
+		Scopes:      t.Scopes,
+	}, nil
+}
+
+// GetUserStatus returns the status of the calling user: their broker, the token it cached for them, how long they can
+// still log in offline, their groups and their last logins.
+func (s Service) GetUserStatus(ctx context.Context, _ *authd.Empty) (*authd.UserStatus, error) {
+	uid, err := s.permissionManager.PeerUID(ctx)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	u, err := s.userManager.UserByID(uid)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	groups, err := s.userManager.UserGroups(u.Name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	locked, err := s.userManager.IsUserLocked(u.Name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	lastOnline, err := s.userManager.LastOnlineLogin(u.Name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	offlineLogins, err := s.userManager.OfflineLoginsSinceOnlineLogin(u.Name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	records, err := s.userManager.LoginHistory(u.Name, userStatusLogins)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &authd.UserStatus{
+		Name:          u.Name,
+		Uid:           u.UID,
+		Groups:        groups,
+		Locked:        locked,
+		OfflineMaxAge: int64(s.loginPolicy.Current().OfflineMaxAge / time.Second),
+		//nolint:gosec // The limit is validated to be lower than the number of logins kept in the history.
+		OfflineMaxLogins: uint32(s.loginPolicy.Current().OfflineMaxLogins),
+		//nolint:gosec // The logins are counted in the history, which is limited in size.
+		OfflineLogins: uint32(offlineLogins),
+	}
+	if !lastOnline.IsZero() {
+		res.LastOnlineLogin = lastOnline.Unix()
+	}
+	for _, r := range records {
+		res.LastLogins = append(res.LastLogins, &authd.LoginRecord{
+			Time:         r.Time.Unix(),
+			Service:      r.Service,
+			BrokerId:     r.BrokerID,
+			Offline:      r.Offline,
+			Result:       r.Result,
+			MachineToken: r.MachineToken,
+		})
+	}
+
+	brokerID, err := s.userManager.BrokerForUser(u.Name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if brokerID == "" || brokerID == brokers.LocalBrokerName {
+		res.Broker = brokers.LocalBrokerName
+		return res, nil
+	}
+	broker, err := s.brokerManager.BrokerFromID(brokerID)
+	if err != nil {
+		// The rest of the status is still useful to the user.
+		res.Broker = brokerID
+		res.TokenError = fmt.Sprintf("the broker is not available: %v", err)
+		return res, nil
+	}
+	res.Broker = broker.Name
+
+	t, err := broker.TokenStatus(ctx, u.Name)
+	if errors.Is(err, brokers.ErrTokenStatusNotSupported) {
+		return res, nil
+	}
+	if err != nil {
+		log.Warningf(ctx, "GetUserStatus: could not get the token status of user %q from broker %q: %v", u.Name, broker.Name, err)
+		res.TokenError = err.Error()
+		return res, nil
+	}
+	res.Token = &authd.CachedToken{
+		HasRefreshToken:  t.HasRefreshToken,
+		HasLocalPassword: t.HasLocalPassword,
+		UserIsDisabled:   t.UserIsDisabled,
+	}
+	if !t.Expiry.IsZero() {
+		res.Token.Expiry = t.Expiry.Unix()
+	}
+
+	return res, nil
+}
+
+// ExportUsers returns all the users and groups of the database, to provision other machines with them.
+func (s Service) ExportUsers(ctx context.Context, _ *authd.Empty) (*authd.UserRecords, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	r, err := s.userManager.ExportRecords()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &authd.UserRecords{}
+	for _, u := range r.Users {
+		res.Users = append(res.Users, &authd.UserRecords_User{
+			Name:        u.Name,
+			Uid:         u.UID,
+			Gid:         u.GID,
+			Gecos:       u.Gecos,
+			Homedir:     u.Dir,
+			Shell:       u.Shell,
+			BrokerId:    u.BrokerID,
+			ProviderId:  u.ProviderID,
+			Locked:      u.Locked,
+			Groups:      u.Groups,
+			LocalGroups: u.LocalGroups,
+		})
+	}
+	for _, g := range r.Groups {
+		res.Groups = append(res.Groups, &authd.UserRecords_Group{Name: g.Name, Gid: g.GID, Ugid: g.UGID})
+	}
+
+	return res, nil
+}
+
+// ImportUsers adds the users and groups to the database, all of them or none if one of their IDs is already used.
+func (s Service) ImportUsers(ctx context.Context, req *authd.UserRecords) (*authd.Empty, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	var r users.Records
+	for _, u := range req.GetUsers() {
+		r.Users = append(r.Users, users.UserRecord{
+			// authd uses normalized usernames.
+			Name:        s.userManager.NormalizeName(u.GetName()),
+			UID:         u.GetUid(),
+			GID:         u.GetGid(),
+			Gecos:       u.GetGecos(),
+			Dir:         u.GetHomedir(),
+			Shell:       u.GetShell(),
+			BrokerID:    u.GetBrokerId(),
+			ProviderID:  u.GetProviderId(),
+			Locked:      u.GetLocked(),
+			Groups:      u.GetGroups(),
+			LocalGroups: u.GetLocalGroups(),
+		})
+	}
+	for _, g := range req.GetGroups() {
+		r.Groups = append(r.Groups, users.GroupRecord{Name: g.GetName(), GID: g.GetGid(), UGID: g.GetUgid()})
+	}
+
+	if err := s.userManager.ImportRecords(r); err != nil {
+		return nil, grpcError(err)
+	}
+	log.Infof(ctx, "Imported %d users and %d groups", len(r.Users), len(r.Groups))
+
+	return &authd.Empty{}, nil
+}
+
+// GetAuthorizedKeys returns the SSH public keys of the user, so that sshd can authenticate them with their keys
+// through its AuthorizedKeysCommand. The keys are public, so any user can get them, like with the authorized_keys
+// files.
+func (s Service) GetAuthorizedKeys(ctx context.Context, req *authd.GetAuthorizedKeysRequest) (*authd.AuthorizedKeys, error) {
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
+	if name == "" {
+		log.Warningf(ctx, "GetAuthorizedKeys: no user name provided")
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+
+	keys, err := s.userManager.SSHKeys(name)
+	if errors.Is(err, users.NoDataFoundError{}) {
+		// sshd looks up the keys of the local users too.
+		log.Debugf(ctx, "GetAuthorizedKeys: %v", err)
+		return nil, grpcError(err)
+	}
+	if err != nil {
+		log.Errorf(ctx, "GetAuthorizedKeys: %v", err)
+		return nil, grpcError(err)
+	}
+
+	return &authd.AuthorizedKeys{Keys: keys}, nil
+}
+
+// EnrollTOTP generates a new TOTP secret for the user, which they then have to enter a code of when they log in
+// offline, and returns its otpauth URI.
+func (s Service) EnrollTOTP(ctx context.Context, req *authd.EnrollTOTPRequest) (*authd.TOTPEnrollment, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+
+	uri, err := s.userManager.EnrollTOTP(name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	log.Infof(ctx, "Enrolled a TOTP secret for user %q", name)
+
+	return &authd.TOTPEnrollment{Uri: uri}, nil
+}
+
+// DisableTOTP removes the TOTP secret of the user.
+func (s Service) DisableTOTP(ctx context.Context, req *authd.DisableTOTPRequest) (*authd.Empty, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+
+	if err := s.userManager.DisableTOTP(name); err != nil {
+		return nil, grpcError(err)
+	}
+	log.Infof(ctx, "Removed the TOTP secret of user %q", name)
+
+	return &authd.Empty{}, nil
+}
+
+// EnrollMachineToken generates a new machine token for the user, with which automation can authenticate as them
+// without interaction, and returns it.
+func (s Service) EnrollMachineToken(ctx context.Context, req *authd.EnrollMachineTokenRequest) (*authd.MachineToken, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+	if req.GetValidity() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "the validity of the token must not be negative")
+	}
+
+	token, expiresAt, err := s.userManager.EnrollMachineToken(name, time.Duration(req.GetValidity())*time.Second)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	log.Infof(ctx, "Enrolled a machine token for user %q", name)
+
+	var expiry int64
+	if !expiresAt.IsZero() {
+		expiry = expiresAt.Unix()
+	}
+	return &authd.MachineToken{Token: token, ExpiresAt: expiry}, nil
+}
+
+// RevokeMachineToken removes the machine token of the user.
+func (s Service) RevokeMachineToken(ctx context.Context, req *authd.RevokeMachineTokenRequest) (*authd.Empty, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+
+	if err := s.userManager.RevokeMachineToken(name); err != nil {
+		return nil, grpcError(err)
+	}
+	log.Infof(ctx, "Revoked the machine token of user %q", name)
+
+	return &authd.Empty{}, nil
+}
+
+// DisableUser locks a user and starts their deprovisioning.
+func (s Service) DisableUser(ctx context.Context, req *authd.DisableUserRequest) (*authd.Empty, error) {
+	return s.changeUserLifecycle(ctx, req.GetName(), s.userManager.DisableUser)
+}
+
+// ArchiveUser moves the home of a disabled user to an archive.
+func (s Service) ArchiveUser(ctx context.Context, req *authd.ArchiveUserRequest) (*authd.Empty, error) {
+	return s.changeUserLifecycle(ctx, req.GetName(), s.userManager.ArchiveUser)
+}
+
+// RestoreUser makes a disabled or archived user active again.
+func (s Service) RestoreUser(ctx context.Context, req *authd.RestoreUserRequest) (*authd.Empty, error) {
+	return s.changeUserLifecycle(ctx, req.GetName(), s.userManager.RestoreUser)
+}
+
+func (s Service) changeUserLifecycle(ctx context.Context, name string, change func(context.Context, string) error) (*authd.Empty, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// authd uses normalized usernames.
+	name = s.userManager.NormalizeName(name)
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+
+	if err := change(ctx, name); err != nil {
+		log.Errorf(ctx, "Could not change the deprovisioning state of user %q: %v", name, err)
+		return nil, grpcError(err)
+	}
+
+	return &authd.Empty{}, nil
+}
+
+// GetUserLifecycle returns the deprovisioning state of the user with the given name.
+func (s Service) GetUserLifecycle(ctx context.Context, req *authd.GetUserLifecycleRequest) (*authd.UserLifecycle, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// authd uses normalized usernames.
+	name := s.userManager.NormalizeName(req.GetName())
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+
+	lc, err := s.userManager.UserLifecycle(name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &authd.UserLifecycle{
+		State:     string(lc.State),
+		NextState: string(lc.Next),
+		Archive:   lc.Archive,
+	}
+	if !lc.Since.IsZero() {
+		res.Since = lc.Since.Unix()
+	}
+	if !lc.NextAt.IsZero() {
+		res.NextTime = lc.NextAt.Unix()
+	}
+	return res, nil
+}
+
+// RunScheduledTasks checks the users with their broker, cleans up the stale users, moves the users to their next
+// deprovisioning state once their dwell time elapsed, cleans up the home directories of deleted users and makes the
+// snapshots of the database, until ctx is cancelled.
+func (s Service) RunScheduledTasks(ctx context.Context) {
+	// Check on startup that the database can be recovered from its latest snapshot, rather than finding out when it's
+	// needed.
+	if _, err := s.userManager.CheckLatestSnapshot(ctx); err != nil {
+		log.Warningf(ctx, "The database can't be recovered from its latest snapshot: %v", err)
+	}
+
+	// The users are checked when authd starts, as their accounts may have changed at the provider in the meantime.
+	var lastRevalidation time.Time
+	for {
+		if interval := s.userManager.RevalidationPolicy().Interval; interval > 0 && time.Since(lastRevalidation) >= interval {
+			s.revalidateUsers(ctx)
+			lastRevalidation = time.Now()
+		}
+		s.cleanupStaleUsers(ctx)
+		s.advanceLifecycles(ctx)
+		s.cleanupHomes(ctx)
+		s.snapshotDatabase(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(scheduledTasksInterval):
+		}
+	}
+}
+
+// revalidateUsers checks the accounts of the users with their broker, and locks the users whose account was disabled
+// or deleted at the provider, so that they can't log in anymore, not even offline. The users whose account can't be
+// checked are left untouched.
+func (s Service) revalidateUsers(ctx context.Context) {
+	toRevalidate, err := s.userManager.UsersToRevalidate()
+	if err != nil {
+		log.Errorf(ctx, "Could not get the users to check with their broker: %v", err)
+		return
+	}
+
+	for _, u := range toRevalidate {
+		if ctx.Err() != nil {
+			return
+		}
+		if u.BrokerID == brokers.LocalBrokerName {
+			continue
+		}
+		broker, err := s.brokerManager.BrokerFromID(u.BrokerID)
+		if err != nil {
+			log.Debugf(ctx, "Not checking user %q, their broker is not available: %v", u.Name, err)
+			continue
+		}
+
+		v, err := broker.VerifyUser(ctx, u.Name, u.ProviderID)
+		if errors.Is(err, brokers.ErrVerifyUserNotSupported) {
+			continue
+		}
+		if err != nil {
+			log.Warningf(ctx, "Could not check user %q with broker %q: %v", u.Name, broker.Name, err)
+			continue
+		}
+		if v.Status != auth.UserDisabled {
+			continue
+		}
+
+		if err := s.userManager.LockUser(u.Name); err != nil {
+			log.Errorf(ctx, "Could not lock user %q disabled at the provider: %v", u.Name, err)
+			continue
+		}
+		log.Noticef(ctx, "User %q locked, their account is disabled or deleted at the provider of broker %q", u.Name, broker.Name)
+	}
+}
+
+func (s Service) cleanupStaleUsers(ctx context.Context) {
+	stale, err := s.userManager.CleanupStaleUsers(ctx, time.Now(), false)
+	if err != nil {
+		log.Errorf(ctx, "Could not clean up the stale users: %v", err)
+	}
+	s.deleteStaleUsersFromBroker(ctx, stale)
+}
+
+// deleteStaleUsersFromBroker removes the broker data of the stale users which were deleted.
+func (s Service) deleteStaleUsersFromBroker(ctx context.Context, stale []users.StaleUser) {
+	for _, u := range stale {
+		if !u.Done || u.Action != users.StaleUserDelete {
+			continue
+		}
+		if err := s.deleteUserFromBroker(ctx, u.Name, u.BrokerID, u.ProviderID); err != nil {
+			log.Warningf(ctx, "Failed to remove the broker data of stale user %q: %v", u.Name, err)
+		}
+	}
+}
+
+func (s Service) advanceLifecycles(ctx context.Context) {
+	deleted, err := s.userManager.AdvanceLifecycles(ctx, time.Now())
+	if err != nil {
+		log.Errorf(ctx, "Could not advance the deprovisioning of users: %v", err)
+	}
+
+	for _, u := range deleted {
+		if err := s.deleteUserFromBroker(ctx, u.Name, u.BrokerID, u.ProviderID); err != nil {
+			log.Warningf(ctx, "Failed to remove the broker data of deprovisioned user %q: %v", u.Name, err)
+		}
+	}
+}
+
+func (s Service) cleanupHomes(ctx context.Context) {
+	if _, err := s.userManager.CleanupHomes(ctx, time.Now(), false); err != nil {
+		log.Errorf(ctx, "Could not clean up the home directories of deleted users: %v", err)
+	}
+}
+
+func (s Service) snapshotDatabase(ctx context.Context) {
+	if _, err := s.userManager.SnapshotDatabase(ctx, time.Now()); err != nil {
+		log.Errorf(ctx, "Could not make a snapshot of the database: %v", err)
+	}
+}
+
+// CleanupHomes cleans up the home directories of the users deleted for longer than the retention window, and returns
+// what was done for each deleted user. With dry_run, it only reports what would be done.
+func (s Service) CleanupHomes(ctx context.Context, req *authd.CleanupHomesRequest) (*authd.HomeCleanupReport, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	cleanups, err := s.userManager.CleanupHomes(ctx, time.Now(), req.GetDryRun())
+	if err != nil {
+		log.Errorf(ctx, "Could not clean up the home directories of deleted users: %v", err)
+		return nil, grpcError(err)
+	}
+
+	res := &authd.HomeCleanupReport{}
+	for _, c := range cleanups {
+		e := &authd.HomeCleanup{
+			Name:      c.Name,
+			Uid:       c.UID,
+			Home:      c.Home,
+			MailSpool: c.MailSpool,
+			DeletedAt: c.DeletedAt.Unix(),
+			Action:    string(c.Action),
+			Skipped:   c.Skipped,
+			Done:      c.Done,
+			Archive:   c.Archive,
+		}
+		if !c.DueAt.IsZero() {
+			e.DueAt = c.DueAt.Unix()
+		}
+		res.Entries = append(res.Entries, e)
+	}
+	return res, nil
+}
+
+// CleanupStaleUsers disables, archives or deletes the users who haven't logged in for longer than the retention
+// period, and returns what was done for each of them. With dry_run, it only reports what would be done.
+func (s Service) CleanupStaleUsers(ctx context.Context, req *authd.CleanupStaleUsersRequest) (*authd.StaleUserReport, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	stale, err := s.userManager.CleanupStaleUsers(ctx, time.Now(), req.GetDryRun())
+	// The users deleted before an error are returned too.
+	s.deleteStaleUsersFromBroker(ctx, stale)
+	if err != nil {
+		log.Errorf(ctx, "Could not clean up the stale users: %v", err)
+		return nil, grpcError(err)
+	}
+
+	res := &authd.StaleUserReport{StaleAfter: int64(s.userManager.StaleUsersPolicy().After.Seconds())}
+	for _, u := range stale {
+		e := &authd.StaleUser{
+			Name:    u.Name,
+			Uid:     u.UID,
+			Home:    u.Home,
+			Action:  string(u.Action),
+			Skipped: u.Skipped,
+			Done:    u.Done,
+			Archive: u.Archive,
+		}
+		if !u.LastLogin.IsZero() {
+			e.LastLogin = u.LastLogin.Unix()
+		}
+		res.Entries = append(res.Entries, e)
+	}
+	return res, nil
+}
+
+// ListOrphanedHomes returns the home directories whose owner is neither a user of authd nor a local user.
+func (s Service) ListOrphanedHomes(ctx context.Context, req *authd.ListOrphanedHomesRequest) (*authd.OrphanedHomes, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	prefixes := req.GetPrefixes()
+	for _, p := range prefixes {
+		if !filepath.IsAbs(p) {
+			return nil, status.Errorf(codes.InvalidArgument, "prefix %q is not an absolute path", p)
+		}
+	}
+	if len(prefixes) == 0 {
+		var err error
+		if prefixes, err = s.userManager.HomePrefixes(); err != nil {
+			log.Errorf(ctx, "ListOrphanedHomes: %v", err)
+			return nil, grpcError(err)
+		}
+	}
+
+	orphans, err := s.userManager.OrphanedHomes(prefixes)
+	if err != nil {
+		log.Errorf(ctx, "ListOrphanedHomes: %v", err)
+		return nil, grpcError(err)
+	}
+
+	res := &authd.OrphanedHomes{Prefixes: prefixes}
+	for _, o := range orphans {
+		e := &authd.OrphanedHome{Path: o.Path, Uid: o.UID, DeletedUser: o.DeletedUser}
+		if !o.DeletedAt.IsZero() {
+			e.DeletedAt = o.DeletedAt.Unix()
+		}
+		res.Entries = append(res.Entries, e)
+	}
+	return res, nil
+}
+
+// enumerationAllowed returns true if the caller can list all the users and groups.
+func (s Service) enumerationAllowed(ctx context.Context) bool {
+	return s.enumerate || s.permissionManager.CheckRequestIsFromRoot(ctx) == nil
+}
+
+// parsePageToken returns the ID after which the requested page starts. The token is the ID of the last entry of the
+// previous page, which is stable when entries are added or removed between requests.
+func parsePageToken(token string) (uint32, error) {
+	if token == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(token, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid page token %q", token)
+	}
+	return uint32(id), nil
+}
+
+// pageLimit returns the number of entries to get from the database for the page size: one more than the page size
+// to detect if there is a next page, or all of them if the page size is 0.
+func pageLimit(pageSize uint32) int {
+	if pageSize == 0 {
+		return -1
+	}
+	return int(pageSize) + 1
+}
+
+// userToProtobuf converts a types.UserEntry to authd.User.
+func userToProtobuf(u types.UserEntry) *authd.User {
+	return &authd.User{
+		Name:    u.Name,
+		Uid:     u.UID,
+		Gid:     u.GID,
+		Gecos:   u.Gecos,
+		Homedir: u.Dir,
+		Shell:   u.Shell,
+	}
+}
+
+// groupToProtobuf converts a types.GroupEntry to authd.Group.
+func groupToProtobuf(g types.GroupEntry) *authd.Group {
+	return &authd.Group{
+		Name:    g.Name,
+		Gid:     g.GID,
+		Members: g.Users,
+		Passwd:  g.Passwd,
+	}
+}
+
+var errUserNotPermitted = errors.New("user not permitted to log in via SSH for the first time")
+
+// userPreCheck checks if the user is permitted to log in via SSH for the first time.
+// It returns a types.UserEntry with a unique UID if the user is permitted to log in.
+// If the user is not permitted to log in by any broker, errUserNotPermitted is returned.
+func (s Service) userPreCheck(ctx context.Context, username string) (types.UserEntry, error) {
+	// Check if any broker permits the user to log in via SSH for the first time.
+	var userinfo, brokerID string
+	var err error
+	for _, b := range s.brokerManager.AvailableBrokers() {
+		// The local broker is not a real broker, so we skip it.
+		if b.ID == brokers.LocalBrokerName {
+			continue
+		}
+
+		userinfo, err = b.UserPreCheck(ctx, username)
+		if err != nil {
+			// An unexpected error occurred while checking the user.
+			log.Errorf(ctx, "UserPreCheck: %v", err)
+			continue
+		}
+		if userinfo == "" {
+			// The broker does not permit the user to log in via SSH for the first time.
+			// This is an expected error, so we only log it at debug level.
+			log.Debugf(ctx, "UserPreCheck: %v", err)
+			continue
+		}
+		brokerID = b.ID
+		break
+	}
+
+	if err != nil || userinfo == "" {
+		// No broker permits the user to log in via SSH for the first time.
+		return types.UserEntry{}, errUserNotPermitted
+	}
+
+	var u types.UserEntry
+	if err := json.Unmarshal([]byte(userinfo), &u); err != nil {
+		return types.UserEntry{}, fmt.Errorf("user data from broker invalid: %v", err)
+	}
+
+	// Register a temporary user with a unique UID. If the user authenticates successfully, the user will be added to
+	// the database with the same UID.
+	u.UID, err = s.userManager.RegisterUserPreAuth(u.Name, brokerID)
+	if err != nil {
+		return types.UserEntry{}, fmt.Errorf("failed to add temporary record for user %q: %v", username, err)
+	}
+	// The UID is also the GID of the user private group (see https://wiki.debian.org/UserPrivateGroups#UPGs)
+	u.GID = u.UID
+
+	return u, nil
+}
+
+// grpcError converts well-known user manager errors to their proper gRPC status codes.
+// The NSS module uses these status codes to determine the NSS status it should return.
+func grpcError(err error) error {
+	if errors.Is(err, users.NoDataFoundError{}) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+
+	var primaryErr users.GroupIsPrimaryError
+	if errors.As(err, &primaryErr) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if errors.Is(err, users.LifecycleError{}) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if errors.Is(err, users.IdentityLinkError{}) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if errors.Is(err, users.ImportError{}) {
 		return status.Error(codes.FailedPrecondition, err.Error())
 	}
 
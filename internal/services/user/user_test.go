@@ -8,8 +8,12 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/canonical/authd/internal/brokers"
+	"github.com/canonical/authd/internal/consts"
+	"github.com/canonical/authd/internal/faillock"
+	"github.com/canonical/authd/internal/loginpolicy"
 	"github.com/canonical/authd/internal/proto/authd"
 	"github.com/canonical/authd/internal/services/errmessages"
 	"github.com/canonical/authd/internal/services/permissions"
@@ -101,6 +105,74 @@ func TestGetUserByName(t *testing.T) {
 	}
 }
 
+func TestGetUserByNameInLockdown(t *testing.T) {
+	userslocking.Z_ForTests_OverrideLockingWithCleanup(t)
+
+	pm := permissions.New(permissions.Z_ForTests_WithCurrentUserAsRoot())
+	s := user.NewService(context.Background(), newUserManagerForTests(t, ""), newBrokersManagerForTests(t), &pm,
+		user.WithLoginPolicy(loginpolicy.NewPolicy(loginpolicy.Config{Lockdown: true}, nil)))
+
+	u, err := s.GetUserByName(context.Background(), &authd.GetUserByNameRequest{Name: "user1@example.com", ShouldPreCheck: true})
+	require.NoError(t, err, "GetUserByName should return a user already in the database")
+	require.Equal(t, "user1@example.com", u.GetName(), "GetUserByName should return the requested user")
+
+	_, err = s.GetUserByName(context.Background(), &authd.GetUserByNameRequest{Name: "user-pre-check@example.com", ShouldPreCheck: true})
+	require.Error(t, err, "GetUserByName should not pre-check a new user in lockdown")
+	require.Equal(t, codes.NotFound, status.Code(err), "GetUserByName should return a not found error")
+}
+
+func TestGetUserByNameWithAlias(t *testing.T) {
+	dbDir := t.TempDir()
+	err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "default.db.yaml"), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+
+	cfg := users.DefaultConfig
+	cfg.Normalization.Aliases = map[string]string{"user1": "User1@example.com"}
+	m, err := users.NewManager(cfg, dbDir)
+	require.NoError(t, err, "Setup: could not create user manager")
+	t.Cleanup(func() { _ = m.Stop() })
+
+	pm := permissions.New()
+	s := user.NewService(context.Background(), m, newBrokersManagerForTests(t), &pm)
+
+	want, err := s.GetUserByName(context.Background(), &authd.GetUserByNameRequest{Name: "user1@example.com"})
+	require.NoError(t, err, "GetUserByName should return the user by their name")
+	got, err := s.GetUserByName(context.Background(), &authd.GetUserByNameRequest{Name: "User1"})
+	require.NoError(t, err, "GetUserByName should return the user by their alias")
+	require.Equal(t, want.GetUid(), got.GetUid(), "The alias should resolve to the same UID")
+	require.Equal(t, "user1@example.com", got.GetName(), "GetUserByName should return the name of the user")
+}
+
+func TestGetUserByNameWithStrippedDomain(t *testing.T) {
+	// The names of the users are stored without the domain once it's stripped.
+	d, err := os.ReadFile(filepath.Join("testdata", "default.db.yaml"))
+	require.NoError(t, err, "Setup: could not read database testdata")
+	dbDir := t.TempDir()
+	err = db.Z_ForTests_CreateDBFromYAMLReader(strings.NewReader(strings.ReplaceAll(string(d), "@example.com", "")), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+
+	cfg := users.DefaultConfig
+	cfg.Normalization.StripDomains = []string{"example.com"}
+	m, err := users.NewManager(cfg, dbDir)
+	require.NoError(t, err, "Setup: could not create user manager")
+	t.Cleanup(func() { _ = m.Stop() })
+
+	pm := permissions.New()
+	s := user.NewService(context.Background(), m, newBrokersManagerForTests(t), &pm)
+
+	want, err := s.GetUserByName(context.Background(), &authd.GetUserByNameRequest{Name: "user1"})
+	require.NoError(t, err, "GetUserByName should return the user by their stored name")
+	for _, name := range []string{"User1", "user1@example.com", "USER1@Example.COM"} {
+		got, err := s.GetUserByName(context.Background(), &authd.GetUserByNameRequest{Name: name})
+		require.NoError(t, err, "GetUserByName should return the user by %q", name)
+		require.Equal(t, want.GetUid(), got.GetUid(), "%q should resolve to the same UID", name)
+		require.Equal(t, "user1", got.GetName(), "GetUserByName should return the stored name of the user")
+	}
+
+	_, err = s.GetUserByName(context.Background(), &authd.GetUserByNameRequest{Name: "user1@other.com"})
+	require.Error(t, err, "GetUserByName should not strip the domains which are not configured")
+}
+
 //nolint:dupl // This is not a duplicate test
 func TestGetUserByID(t *testing.T) {
 	tests := map[string]struct {
@@ -207,14 +279,28 @@ func TestGetGroupByID(t *testing.T) {
 
 func TestListUsers(t *testing.T) {
 	tests := map[string]struct {
-		dbFile  string
-		closeDB bool
-
-		wantErr bool
+		dbFile              string
+		closeDB             bool
+		pageSize            uint32
+		pageToken           string
+		enumerationDisabled bool
+		currentUserNotRoot  bool
+
+		wantNextPageToken string
+		wantErr           bool
 	}{
-		"Return_all_users":        {},
-		"Return_no_users":         {dbFile: "empty.db.yaml"},
-		"Error_on_database_error": {closeDB: true, wantErr: true},
+		"Return_all_users":                                       {},
+		"Return_no_users":                                        {dbFile: "empty.db.yaml"},
+		"Return_first_page_of_users":                             {pageSize: 2, wantNextPageToken: "2222"},
+		"Return_next_page_of_users":                              {pageSize: 2, pageToken: "2222"},
+		"Return_last_page_of_exact_size":                         {pageSize: 1, pageToken: "2222"},
+		"Return_all_users_after_the_token":                       {pageToken: "1111"},
+		"Return_all_users_to_root_if_enumeration_is_disabled":    {enumerationDisabled: true},
+		"Return_no_users_to_non_root_if_enumeration_is_disabled": {enumerationDisabled: true, currentUserNotRoot: true},
+		"Return_all_users_to_non_root_if_enumeration_is_enabled": {currentUserNotRoot: true},
+
+		"Error_on_invalid_page_token": {pageToken: "not-a-uid", wantErr: true},
+		"Error_on_database_error":     {closeDB: true, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -222,7 +308,8 @@ func TestListUsers(t *testing.T) {
 				tc.dbFile = "default.db.yaml"
 			}
 
-			client, m := newUserServiceClient(t, tc.dbFile)
+			client, m := newUserServiceClientWithBrokers(t, tc.dbFile, newBrokersManagerForTests(t), tc.currentUserNotRoot,
+				user.WithEnumeration(!tc.enumerationDisabled))
 
 			if tc.closeDB {
 				// Close the database to trigger a database error
@@ -230,22 +317,37 @@ func TestListUsers(t *testing.T) {
 				require.NoError(t, err, "Setup: failed to close database")
 			}
 
-			resp, err := client.ListUsers(context.Background(), &authd.Empty{})
+			resp, err := client.ListUsers(context.Background(), &authd.ListUsersRequest{
+				PageSize:  tc.pageSize,
+				PageToken: tc.pageToken,
+			})
 			requireExpectedListResult(t, "ListUsers", resp.GetUsers(), err, tc.wantErr)
+			require.Equal(t, tc.wantNextPageToken, resp.GetNextPageToken(), "ListUsers should return the expected next page token")
 		})
 	}
 }
 
 func TestListGroups(t *testing.T) {
 	tests := map[string]struct {
-		dbFile  string
-		closeDB bool
-
-		wantErr bool
+		dbFile              string
+		closeDB             bool
+		pageSize            uint32
+		pageToken           string
+		enumerationDisabled bool
+		currentUserNotRoot  bool
+
+		wantNextPageToken string
+		wantErr           bool
 	}{
-		"Return_all_groups":       {},
-		"Return_no_groups":        {dbFile: "empty.db.yaml"},
-		"Error_on_database_error": {closeDB: true, wantErr: true},
+		"Return_all_groups":                                       {},
+		"Return_no_groups":                                        {dbFile: "empty.db.yaml"},
+		"Return_first_page_of_groups":                             {pageSize: 2, wantNextPageToken: "22222"},
+		"Return_next_page_of_groups":                              {pageSize: 2, pageToken: "22222"},
+		"Return_all_groups_to_root_if_enumeration_is_disabled":    {enumerationDisabled: true},
+		"Return_no_groups_to_non_root_if_enumeration_is_disabled": {enumerationDisabled: true, currentUserNotRoot: true},
+
+		"Error_on_invalid_page_token": {pageToken: "not-a-gid", wantErr: true},
+		"Error_on_database_error":     {closeDB: true, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -253,7 +355,8 @@ func TestListGroups(t *testing.T) {
 				tc.dbFile = "default.db.yaml"
 			}
 
-			client, m := newUserServiceClient(t, tc.dbFile)
+			client, m := newUserServiceClientWithBrokers(t, tc.dbFile, newBrokersManagerForTests(t), tc.currentUserNotRoot,
+				user.WithEnumeration(!tc.enumerationDisabled))
 
 			if tc.closeDB {
 				// Close the database to trigger a database error
@@ -261,7 +364,10 @@ func TestListGroups(t *testing.T) {
 				require.NoError(t, err, "Setup: failed to close database")
 			}
 
-			resp, err := client.ListGroups(context.Background(), &authd.Empty{})
+			resp, err := client.ListGroups(context.Background(), &authd.ListGroupsRequest{
+				PageSize:  tc.pageSize,
+				PageToken: tc.pageToken,
+			})
 			if tc.wantErr {
 				require.Error(t, err, "ListGroups should return an error")
 				s, ok := status.FromError(err)
@@ -275,6 +381,213 @@ func TestListGroups(t *testing.T) {
 	}
 }
 
+func TestGetGroupsForUser(t *testing.T) {
+	tests := map[string]struct {
+		username           string
+		currentUserNotRoot bool
+
+		dbFile  string
+		closeDB bool
+
+		wantErr          bool
+		wantErrNotExists bool
+	}{
+		"Return_the_groups_of_the_user":                  {username: "user2@example.com"},
+		"Return_the_groups_of_the_user_with_uppercase":   {username: "USER2@EXAMPLE.COM"},
+		"Return_the_groups_of_the_user_to_non_root_user": {username: "user2@example.com", currentUserNotRoot: true},
+
+		"Error_with_typed_GRPC_notfound_code_on_unexisting_user": {username: "does-not-exist@example.com", wantErr: true, wantErrNotExists: true},
+		"Error_on_missing_name":                                  {wantErr: true},
+		"Error_on_database_error":                                {username: "user2@example.com", closeDB: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, m := newUserServiceClient(t, tc.dbFile, tc.currentUserNotRoot)
+
+			if tc.closeDB {
+				// Close the database to trigger a database error
+				err := userstestutils.DBManager(m).Close()
+				require.NoError(t, err, "Setup: failed to close database")
+			}
+
+			groups, err := client.GetGroupsForUser(context.Background(), &authd.GetGroupsForUserRequest{Name: tc.username})
+			requireExpectedResult(t, "GetGroupsForUser", groups, err, tc.wantErr, tc.wantErrNotExists)
+		})
+	}
+}
+
+func TestGetAuthorizedKeys(t *testing.T) {
+	tests := map[string]struct {
+		username           string
+		currentUserNotRoot bool
+
+		closeDB bool
+
+		wantErr          bool
+		wantErrNotExists bool
+	}{
+		"Return_the_keys_of_the_user":                  {username: "user2@example.com"},
+		"Return_the_keys_of_the_user_with_uppercase":   {username: "USER2@EXAMPLE.COM"},
+		"Return_the_keys_of_the_user_to_non_root_user": {username: "user2@example.com", currentUserNotRoot: true},
+		"Return_no_keys_for_user_without_keys":         {username: "user3@example.com"},
+		"Return_no_keys_for_locked_user":               {username: "user1@example.com"},
+
+		"Error_with_typed_GRPC_notfound_code_on_unexisting_user": {username: "does-not-exist@example.com", wantErr: true, wantErrNotExists: true},
+		"Error_on_missing_name":                                  {wantErr: true},
+		"Error_on_database_error":                                {username: "user2@example.com", closeDB: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, m := newUserServiceClient(t, "ssh-keys.db.yaml", tc.currentUserNotRoot)
+
+			if tc.closeDB {
+				// Close the database to trigger a database error
+				err := userstestutils.DBManager(m).Close()
+				require.NoError(t, err, "Setup: failed to close database")
+			}
+
+			keys, err := client.GetAuthorizedKeys(context.Background(), &authd.GetAuthorizedKeysRequest{Name: tc.username})
+			requireExpectedResult(t, "GetAuthorizedKeys", keys, err, tc.wantErr, tc.wantErrNotExists)
+		})
+	}
+}
+
+func TestEnrollTOTP(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		username           string
+		disable            bool
+		notEnrolled        bool
+		currentUserNotRoot bool
+
+		wantErrCode        codes.Code
+		wantDisableErrCode codes.Code
+	}{
+		"Enroll_TOTP_secret":                   {},
+		"Enroll_TOTP_secret_of_user_uppercase": {username: "USER1@EXAMPLE.COM"},
+		"Disable_TOTP":                         {disable: true},
+
+		"Error_when_username_is_empty":        {username: "-", wantErrCode: codes.InvalidArgument},
+		"Error_when_user_does_not_exist":      {username: "doesnotexist@example.com", wantErrCode: codes.NotFound},
+		"Error_when_not_root":                 {currentUserNotRoot: true, wantErrCode: codes.PermissionDenied},
+		"Error_when_disabling_without_secret": {notEnrolled: true, disable: true, wantDisableErrCode: codes.NotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			switch tc.username {
+			case "":
+				tc.username = "user1@example.com"
+			case "-":
+				tc.username = ""
+			}
+
+			client, m := newUserServiceClient(t, "default.db.yaml", tc.currentUserNotRoot)
+
+			if !tc.notEnrolled {
+				got, err := client.EnrollTOTP(context.Background(), &authd.EnrollTOTPRequest{Name: tc.username})
+				if tc.wantErrCode != codes.OK {
+					require.Error(t, err, "EnrollTOTP should return an error, but did not")
+					require.Equal(t, tc.wantErrCode, status.Code(err), "EnrollTOTP should return the expected error code")
+					return
+				}
+				require.NoError(t, err, "EnrollTOTP should not return an error, but did")
+				require.True(t, strings.HasPrefix(got.GetUri(), "otpauth://totp/authd:user1@example.com?"),
+					"EnrollTOTP should return the otpauth URI of the secret, got %q", got.GetUri())
+			}
+
+			wantEnrolled := !tc.notEnrolled
+			if tc.disable {
+				_, err := client.DisableTOTP(context.Background(), &authd.DisableTOTPRequest{Name: tc.username})
+				if tc.wantDisableErrCode != codes.OK {
+					require.Error(t, err, "DisableTOTP should return an error, but did not")
+					require.Equal(t, tc.wantDisableErrCode, status.Code(err), "DisableTOTP should return the expected error code")
+					return
+				}
+				require.NoError(t, err, "DisableTOTP should not return an error, but did")
+				wantEnrolled = false
+			}
+
+			enrolled, err := m.HasTOTP("user1@example.com")
+			require.NoError(t, err, "HasTOTP should not return an error")
+			require.Equal(t, wantEnrolled, enrolled, "User should only have a TOTP secret if enrolled")
+		})
+	}
+}
+
+func TestEnrollMachineToken(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		username           string
+		validity           int64
+		revoke             bool
+		notEnrolled        bool
+		currentUserNotRoot bool
+
+		wantErrCode       codes.Code
+		wantRevokeErrCode codes.Code
+	}{
+		"Enroll_machine_token":                   {},
+		"Enroll_machine_token_with_validity":     {validity: 3600},
+		"Enroll_machine_token_of_user_uppercase": {username: "USER1@EXAMPLE.COM"},
+		"Revoke_machine_token":                   {revoke: true},
+
+		"Error_when_username_is_empty":      {username: "-", wantErrCode: codes.InvalidArgument},
+		"Error_when_validity_is_negative":   {validity: -1, wantErrCode: codes.InvalidArgument},
+		"Error_when_user_does_not_exist":    {username: "doesnotexist@example.com", wantErrCode: codes.NotFound},
+		"Error_when_not_root":               {currentUserNotRoot: true, wantErrCode: codes.PermissionDenied},
+		"Error_when_revoking_without_token": {notEnrolled: true, revoke: true, wantRevokeErrCode: codes.NotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			switch tc.username {
+			case "":
+				tc.username = "user1@example.com"
+			case "-":
+				tc.username = ""
+			}
+
+			client, m := newUserServiceClient(t, "default.db.yaml", tc.currentUserNotRoot)
+
+			if !tc.notEnrolled {
+				got, err := client.EnrollMachineToken(context.Background(), &authd.EnrollMachineTokenRequest{Name: tc.username, Validity: tc.validity})
+				if tc.wantErrCode != codes.OK {
+					require.Error(t, err, "EnrollMachineToken should return an error, but did not")
+					require.Equal(t, tc.wantErrCode, status.Code(err), "EnrollMachineToken should return the expected error code")
+					return
+				}
+				require.NoError(t, err, "EnrollMachineToken should not return an error, but did")
+				require.Equal(t, tc.validity != 0, got.GetExpiresAt() != 0, "EnrollMachineToken should only return an expiry date with a validity")
+
+				ok, err := m.VerifyMachineToken("user1@example.com", got.GetToken())
+				require.NoError(t, err, "VerifyMachineToken should not return an error")
+				require.True(t, ok, "EnrollMachineToken should return the token of the user")
+			}
+
+			wantEnrolled := !tc.notEnrolled
+			if tc.revoke {
+				_, err := client.RevokeMachineToken(context.Background(), &authd.RevokeMachineTokenRequest{Name: tc.username})
+				if tc.wantRevokeErrCode != codes.OK {
+					require.Error(t, err, "RevokeMachineToken should return an error, but did not")
+					require.Equal(t, tc.wantRevokeErrCode, status.Code(err), "RevokeMachineToken should return the expected error code")
+					return
+				}
+				require.NoError(t, err, "RevokeMachineToken should not return an error, but did")
+				wantEnrolled = false
+			}
+
+			enrolled, err := m.HasMachineToken("user1@example.com")
+			require.NoError(t, err, "HasMachineToken should not return an error")
+			require.Equal(t, wantEnrolled, enrolled, "User should only have a machine token if enrolled")
+		})
+	}
+}
+
 func TestLockUser(t *testing.T) {
 	tests := map[string]struct {
 		sourceDB string
@@ -314,12 +627,15 @@ func TestUnlockUser(t *testing.T) {
 		sourceDB string
 
 		username           string
+		lockedOut          bool
 		currentUserNotRoot bool
 
 		wantErr bool
 	}{
-		"Successfully_unlock_user":                {username: "user1@example.com"},
-		"Successfully_unlock_user_with_uppercase": {username: "user1@example.com"},
+		"Successfully_unlock_user":                                {username: "user1@example.com"},
+		"Successfully_unlock_user_with_uppercase":                 {username: "user1@example.com"},
+		"Successfully_unlock_locked_out_user":                     {username: "user1@example.com", lockedOut: true},
+		"Successfully_unlock_locked_out_user_who_never_logged_in": {username: "doesnotexist@example.com", lockedOut: true},
 
 		"Error_when_username_is_empty":   {wantErr: true},
 		"Error_when_user_does_not_exist": {username: "doesnotexist@example.com", wantErr: true},
@@ -331,7 +647,12 @@ func TestUnlockUser(t *testing.T) {
 				tc.sourceDB = "locked-user.db.yaml"
 			}
 
-			client, m := newUserServiceClient(t, tc.sourceDB, tc.currentUserNotRoot)
+			tracker := faillock.New(faillock.Config{Deny: 1})
+			if tc.lockedOut {
+				tracker.RecordFailure(tc.username)
+			}
+			client, m := newUserServiceClientWithBrokers(t, tc.sourceDB, newBrokersManagerForTests(t), tc.currentUserNotRoot,
+				user.WithFaillock(tracker))
 
 			_, err := client.UnlockUser(context.Background(), &authd.UnlockUserRequest{Name: tc.username})
 			if tc.wantErr {
@@ -339,6 +660,8 @@ func TestUnlockUser(t *testing.T) {
 				return
 			}
 			require.NoError(t, err, "UnlockUser should not return an error, but did")
+			_, lockedOut := tracker.LockedOut(tc.username)
+			require.False(t, lockedOut, "UnlockUser should unlock the locked out user")
 
 			dbContent, err := db.Z_ForTests_DumpNormalizedYAML(userstestutils.DBManager(m))
 			require.NoError(t, err, "Setup: failed to dump database for comparing")
@@ -513,6 +836,8 @@ func TestDeleteUser(t *testing.T) {
 		sourceDB           string
 		username           string
 		currentUserNotRoot bool
+		removeHome         bool
+		archiveHome        bool
 
 		wantErr      bool
 		wantWarnings int
@@ -523,6 +848,9 @@ func TestDeleteUser(t *testing.T) {
 		"Error_when_username_is_empty":   {wantErr: true},
 		"Error_when_user_does_not_exist": {username: "doesnotexist@example.com", wantErr: true},
 		"Error_when_not_root":            {username: "user1@example.com", currentUserNotRoot: true, wantErr: true},
+		"Error_when_removing_and_archiving_home": {
+			username: "user1@example.com", removeHome: true, archiveHome: true, wantErr: true,
+		},
 
 		"Warning_when_broker_fails_to_delete": {username: "delete_error@example.com", wantWarnings: 1},
 		"Warning_when_broker_not_found":       {sourceDB: "default.db.yaml", username: "user1@example.com", wantWarnings: 1},
@@ -540,7 +868,11 @@ func TestDeleteUser(t *testing.T) {
 
 			client, m := newUserServiceClient(t, dbFile, tc.currentUserNotRoot)
 
-			resp, err := client.DeleteUser(context.Background(), &authd.DeleteUserRequest{Name: tc.username})
+			resp, err := client.DeleteUser(context.Background(), &authd.DeleteUserRequest{
+				Name:        tc.username,
+				RemoveHome:  tc.removeHome,
+				ArchiveHome: tc.archiveHome,
+			})
 			if tc.wantErr {
 				require.Error(t, err, "DeleteUser should return an error, but did not")
 				return
@@ -592,9 +924,874 @@ func TestDeleteGroup(t *testing.T) {
 }
 
 // newUserServiceClient returns a new gRPC client for the CLI service.
+func TestGetLoginHistory(t *testing.T) {
+	tests := map[string]struct {
+		username           string
+		limit              uint32
+		currentUserNotRoot bool
+
+		wantErr bool
+	}{
+		"Successfully_get_login_history":                        {username: "user1@example.com"},
+		"Successfully_get_login_history_with_uppercase":         {username: "USER1@example.com"},
+		"Successfully_get_limited_login_history":                {username: "user1@example.com", limit: 2},
+		"Successfully_get_empty_history_of_user_without_logins": {username: "user3@example.com"},
+
+		"Error_when_username_is_empty":         {wantErr: true},
+		"Error_when_user_does_not_exist":       {username: "doesnotexist@example.com", wantErr: true},
+		"Error_when_neither_root_nor_the_user": {username: "user1@example.com", currentUserNotRoot: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, _ := newUserServiceClient(t, "login-history.db.yaml", tc.currentUserNotRoot)
+
+			got, err := client.GetLoginHistory(context.Background(), &authd.GetLoginHistoryRequest{Name: tc.username, Limit: tc.limit})
+			if tc.wantErr {
+				require.Error(t, err, "GetLoginHistory should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "GetLoginHistory should not return an error, but did")
+
+			golden.CheckOrUpdateYAML(t, got.GetRecords())
+		})
+	}
+}
+
+func TestGetSecurityReport(t *testing.T) {
+	tests := map[string]struct {
+		currentUserNotRoot bool
+
+		wantErr bool
+	}{
+		"Successfully_get_security_report": {},
+
+		"Error_when_not_root": {currentUserNotRoot: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, _ := newUserServiceClient(t, "", tc.currentUserNotRoot)
+
+			got, err := client.GetSecurityReport(context.Background(), &authd.Empty{})
+			if tc.wantErr {
+				require.Error(t, err, "GetSecurityReport should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "GetSecurityReport should not return an error, but did")
+
+			require.NotEmpty(t, got.GetChecks(), "GetSecurityReport should return the checks")
+			for _, c := range got.GetChecks() {
+				require.NotEmpty(t, c.GetId(), "Security check should have an ID")
+				require.NotEmpty(t, c.GetStatus(), "Security check %q should have a status", c.GetId())
+			}
+			require.LessOrEqual(t, got.GetScore(), int32(100), "Score should be a percentage")
+		})
+	}
+}
+
+func TestGetLoginPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		allowedGroups []string
+		deniedGroups  []string
+		lockdown      bool
+		loginHours    []string
+		offlineMaxAge time.Duration
+	}{
+		"Get_unrestricted_login_policy":         {},
+		"Get_login_policy_with_allowed_groups":  {allowedGroups: []string{"admins", "dev-team"}},
+		"Get_login_policy_with_denied_groups":   {deniedGroups: []string{"contractors"}},
+		"Get_login_policy_in_lockdown":          {lockdown: true},
+		"Get_login_policy_with_login_hours":     {loginHours: []string{"Mon-Fri 08:00-18:00"}},
+		"Get_login_policy_with_offline_max_age": {offlineMaxAge: 72 * time.Hour},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := users.NewManager(users.DefaultConfig, t.TempDir())
+			require.NoError(t, err, "Setup: could not create user manager")
+			t.Cleanup(func() { _ = m.Stop() })
+
+			b, err := brokers.NewManager(context.Background(), t.TempDir(), nil)
+			require.NoError(t, err, "Setup: could not create broker manager")
+
+			pm := permissions.New()
+			s := user.NewService(context.Background(), m, b, &pm,
+				user.WithLoginPolicy(loginpolicy.NewPolicy(loginpolicy.Config{
+					AllowedGroups: tc.allowedGroups,
+					DeniedGroups:  tc.deniedGroups,
+					Lockdown:      tc.lockdown,
+					LoginHours:    tc.loginHours,
+					OfflineMaxAge: tc.offlineMaxAge,
+				}, nil)))
+
+			got, err := s.GetLoginPolicy(context.Background(), &authd.Empty{})
+			require.NoError(t, err, "GetLoginPolicy should not return an error, but did")
+			require.Equal(t, tc.allowedGroups, got.GetAllowedGroups(), "GetLoginPolicy should return the allowed groups")
+			require.Equal(t, tc.deniedGroups, got.GetDeniedGroups(), "GetLoginPolicy should return the denied groups")
+			require.Equal(t, tc.lockdown, got.GetLockdown(), "GetLoginPolicy should return whether lockdown is enabled")
+			require.Equal(t, tc.loginHours, got.GetLoginHours(), "GetLoginPolicy should return the login hours")
+			require.Equal(t, int64(tc.offlineMaxAge.Seconds()), got.GetOfflineMaxAge(), "GetLoginPolicy should return the offline max age")
+			require.Nil(t, got.GetCentral(), "GetLoginPolicy should not return a central policy when none is configured")
+		})
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	t.Parallel()
+
+	before := time.Now().Unix()
+	client, _ := newUserServiceClient(t, "locked-user.db.yaml")
+	_, err := client.ListUsers(context.Background(), &authd.ListUsersRequest{})
+	require.NoError(t, err, "Setup: ListUsers should not return an error")
+
+	got, err := client.GetStatus(context.Background(), &authd.Empty{})
+	require.NoError(t, err, "GetStatus should not return an error, but did")
+
+	require.Equal(t, consts.Version, got.GetVersion(), "GetStatus should return the version of the daemon")
+	require.GreaterOrEqual(t, got.GetStartTime(), before, "GetStatus should return when the daemon started")
+	require.LessOrEqual(t, got.GetStartTime(), time.Now().Unix(), "GetStatus should return when the daemon started")
+
+	gotDB := got.GetDatabase()
+	require.Equal(t, consts.DefaultDatabaseFileName, filepath.Base(gotDB.GetPath()), "GetStatus should return the path of the database")
+	require.Positive(t, gotDB.GetSize(), "GetStatus should return the size of the database")
+	require.Positive(t, gotDB.GetSchemaVersion(), "GetStatus should return the schema version of the database")
+	require.EqualValues(t, 3, gotDB.GetUsers(), "GetStatus should return the number of users")
+	require.EqualValues(t, 1, gotDB.GetLockedUsers(), "GetStatus should return the number of locked users")
+	require.EqualValues(t, 4, gotDB.GetGroups(), "GetStatus should return the number of groups")
+	require.Zero(t, gotDB.GetPendingUsers(), "GetStatus should return the number of pending users")
+	require.NotEmpty(t, gotDB.GetOperations(), "GetStatus should return the latencies of the database operations")
+	for _, op := range gotDB.GetOperations() {
+		require.Positive(t, op.GetCount(), "GetStatus should only return the operations which were run")
+		require.Len(t, op.GetBuckets(), len(db.LatencyBuckets), "GetStatus should return all the buckets of the operations")
+	}
+
+	require.Len(t, got.GetBrokers(), 2, "GetStatus should return all the brokers")
+	local, mock := got.GetBrokers()[0], got.GetBrokers()[1]
+	require.Equal(t, brokers.LocalBrokerName, local.GetName(), "The local broker should be listed first")
+	require.Zero(t, local.GetApiVersion(), "The local broker does not use the broker API")
+	require.Empty(t, local.GetError(), "The local broker should be healthy")
+	require.Equal(t, "BrokerMock", mock.GetName(), "The broker mock should be listed")
+	require.EqualValues(t, brokers.LatestAPIVersion, mock.GetApiVersion(), "GetStatus should return the API version of the broker")
+	require.Empty(t, mock.GetError(), "The broker mock should be healthy")
+	require.Zero(t, mock.GetPendingSessions(), "The broker mock should not have pending sessions")
+	require.Zero(t, mock.GetLastOnlineLogin(), "No login with the broker mock should be recorded")
+}
+
+func TestRollBackDatabase(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		currentUserNotRoot bool
+
+		wantErrCode codes.Code
+	}{
+		"Error_when_the_database_was_not_migrated": {wantErrCode: codes.FailedPrecondition},
+		"Error_when_not_root":                      {currentUserNotRoot: true, wantErrCode: codes.PermissionDenied},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			client, _ := newUserServiceClient(t, "default.db.yaml", tc.currentUserNotRoot)
+
+			_, err := client.RollBackDatabase(context.Background(), &authd.Empty{})
+			require.Error(t, err, "RollBackDatabase should return an error, but did not")
+			require.Equal(t, tc.wantErrCode, status.Code(err), "RollBackDatabase should return the expected error code")
+		})
+	}
+}
+
+func TestSetDefaultBroker(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		broker  string
+		service string
+
+		wantErrCode codes.Code
+	}{
+		"Set_default_broker":                    {broker: "BrokerMock"},
+		"Set_default_broker_by_ID":              {broker: "mockID"},
+		"Set_default_broker_of_a_service":       {broker: "brokermock", service: "sshd"},
+		"Error_when_no_broker_is_provided":      {wantErrCode: codes.InvalidArgument},
+		"Error_when_broker_is_not_registered":   {broker: "DoesNotExist", wantErrCode: codes.NotFound},
+		"Error_when_broker_is_the_local_broker": {broker: brokers.LocalBrokerName, wantErrCode: codes.InvalidArgument},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newBrokersManagerForTests(t)
+			if tc.broker == "mockID" {
+				tc.broker = b.BrokerFromName("BrokerMock").ID
+			}
+
+			client, _ := newUserServiceClientWithBrokers(t, "", b, false)
+
+			_, err := client.SetDefaultBroker(context.Background(), &authd.SetDefaultBrokerRequest{Broker: tc.broker, Service: tc.service})
+			if tc.wantErrCode != codes.OK {
+				require.Error(t, err, "SetDefaultBroker should return an error, but did not")
+				require.Equal(t, tc.wantErrCode, status.Code(err), "SetDefaultBroker should return the expected error code")
+				return
+			}
+			require.NoError(t, err, "SetDefaultBroker should not return an error, but did")
+			require.Equal(t, "BrokerMock", b.DefaultBroker(tc.service).Name, "SetDefaultBroker should set the default broker")
+		})
+	}
+}
+
+func TestListBrokers(t *testing.T) {
+	t.Parallel()
+
+	b := newBrokersManagerForTests(t)
+	require.NoError(t, b.SetDefaultBroker("sshd", b.BrokerFromName("BrokerMock").ID), "Setup: could not set default broker")
+	client, _ := newUserServiceClientWithBrokers(t, "", b, false)
+
+	got, err := client.ListBrokers(context.Background(), &authd.Empty{})
+	require.NoError(t, err, "ListBrokers should not return an error, but did")
+
+	require.Len(t, got.GetBrokers(), 2, "ListBrokers should return all the brokers")
+	local, mock := got.GetBrokers()[0], got.GetBrokers()[1]
+	require.Equal(t, brokers.LocalBrokerName, local.GetName(), "The local broker should be listed first")
+	require.Empty(t, local.GetConfigFile(), "The local broker has no configuration file")
+	require.Empty(t, local.GetCapabilities(), "The local broker has no optional features")
+	require.Equal(t, "BrokerMock", mock.GetName(), "The broker mock should be listed")
+	require.NotEmpty(t, mock.GetConfigFile(), "ListBrokers should return the configuration file of the broker")
+	require.NotEmpty(t, mock.GetDbusName(), "ListBrokers should return the D-Bus name of the broker")
+	require.EqualValues(t, brokers.LatestAPIVersion, mock.GetApiVersion(), "ListBrokers should return the API version of the broker")
+	require.Contains(t, mock.GetCapabilities(), brokers.CapabilityProviderCheck, "ListBrokers should return the features of the broker")
+	require.False(t, mock.GetDefault(), "The broker mock is not the default broker of all the services")
+	require.Equal(t, []string{"sshd"}, mock.GetDefaultServices(), "ListBrokers should return the services the broker is the default of")
+
+	client, _ = newUserServiceClientWithBrokers(t, "", b, true)
+	_, err = client.ListBrokers(context.Background(), &authd.Empty{})
+	require.Equal(t, codes.PermissionDenied, status.Code(err), "ListBrokers should be denied to non-root users")
+}
+
+func TestGetBrokerDetails(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		broker             string
+		currentUserNotRoot bool
+
+		wantErrCode codes.Code
+	}{
+		"Get_broker_details":       {broker: "brokermock"},
+		"Get_broker_details_by_ID": {broker: "mockID"},
+
+		"Error_when_no_broker_is_provided":    {wantErrCode: codes.InvalidArgument},
+		"Error_when_broker_is_not_registered": {broker: "DoesNotExist", wantErrCode: codes.NotFound},
+		"Error_when_not_root":                 {broker: "BrokerMock", currentUserNotRoot: true, wantErrCode: codes.PermissionDenied},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newBrokersManagerForTests(t)
+			brokerID := b.BrokerFromName("BrokerMock").ID
+			if tc.broker == "mockID" {
+				tc.broker = brokerID
+			}
+
+			client, _ := newUserServiceClientWithBrokers(t, "", b, tc.currentUserNotRoot)
+
+			got, err := client.GetBrokerDetails(context.Background(), &authd.GetBrokerDetailsRequest{Broker: tc.broker})
+			if tc.wantErrCode != codes.OK {
+				require.Error(t, err, "GetBrokerDetails should return an error, but did not")
+				require.Equal(t, tc.wantErrCode, status.Code(err), "GetBrokerDetails should return the expected error code")
+				return
+			}
+			require.NoError(t, err, "GetBrokerDetails should not return an error, but did")
+			require.Equal(t, brokerID, got.GetId(), "GetBrokerDetails should return the requested broker")
+			require.Equal(t, "BrokerMock", got.GetName(), "GetBrokerDetails should return the name of the broker")
+			require.NotEmpty(t, got.GetCapabilities(), "GetBrokerDetails should return the features of the broker")
+		})
+	}
+}
+
+func TestCheckBroker(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		broker             string
+		currentUserNotRoot bool
+
+		wantErrCode codes.Code
+	}{
+		"Check_broker":       {broker: "BrokerMock"},
+		"Check_broker_by_ID": {broker: "mockID"},
+
+		"Error_when_no_broker_is_provided":      {wantErrCode: codes.InvalidArgument},
+		"Error_when_broker_is_not_registered":   {broker: "DoesNotExist", wantErrCode: codes.NotFound},
+		"Error_when_broker_is_the_local_broker": {broker: brokers.LocalBrokerName, wantErrCode: codes.InvalidArgument},
+		"Error_when_not_root":                   {broker: "BrokerMock", currentUserNotRoot: true, wantErrCode: codes.PermissionDenied},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newBrokersManagerForTests(t)
+			if tc.broker == "mockID" {
+				tc.broker = b.BrokerFromName("BrokerMock").ID
+			}
+
+			client, _ := newUserServiceClientWithBrokers(t, "", b, tc.currentUserNotRoot)
+
+			got, err := client.CheckBroker(context.Background(), &authd.CheckBrokerRequest{Broker: tc.broker})
+			if tc.wantErrCode != codes.OK {
+				require.Error(t, err, "CheckBroker should return an error, but did not")
+				require.Equal(t, tc.wantErrCode, status.Code(err), "CheckBroker should return the expected error code")
+				return
+			}
+			require.NoError(t, err, "CheckBroker should not return an error, but did")
+
+			var names []string
+			for _, c := range got.GetChecks() {
+				names = append(names, c.GetName())
+				require.Equal(t, "ok", c.GetStatus(), "Check %q should succeed: %s", c.GetName(), c.GetDetails())
+			}
+			require.Equal(t, []string{"dbus", "discovery", "device_code"}, names, "CheckBroker should return the checks of the broker and of its provider")
+		})
+	}
+}
+
+func TestLinkIdentity(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		username           string
+		broker             string
+		unlink             string
+		currentUserNotRoot bool
+
+		wantErrCode       codes.Code
+		wantUnlinkErrCode codes.Code
+	}{
+		"Link_identity":                      {broker: "BrokerMock"},
+		"Link_identity_by_broker_ID":         {broker: "mockID"},
+		"Link_identity_of_user_in_uppercase": {username: "USER1@EXAMPLE.COM", broker: "BrokerMock"},
+		"Unlink_identity":                    {broker: "BrokerMock", unlink: "BrokerMock"},
+		"Unlink_identity_by_broker_ID":       {broker: "BrokerMock", unlink: "mockID"},
+
+		"Error_when_username_is_empty":          {username: "-", broker: "BrokerMock", wantErrCode: codes.InvalidArgument},
+		"Error_when_no_broker_is_provided":      {wantErrCode: codes.InvalidArgument},
+		"Error_when_user_does_not_exist":        {username: "doesnotexist@example.com", broker: "BrokerMock", wantErrCode: codes.NotFound},
+		"Error_when_broker_is_not_registered":   {broker: "DoesNotExist", wantErrCode: codes.NotFound},
+		"Error_when_broker_is_the_local_broker": {broker: brokers.LocalBrokerName, wantErrCode: codes.InvalidArgument},
+		"Error_when_user_is_bound_to_broker":    {broker: "mockID", username: "bound", wantErrCode: codes.FailedPrecondition},
+		"Error_when_not_root":                   {broker: "BrokerMock", currentUserNotRoot: true, wantErrCode: codes.PermissionDenied},
+
+		"Error_when_unlinking_identity_which_is_not_linked": {broker: "BrokerMock", unlink: "DoesNotExist", wantUnlinkErrCode: codes.NotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newBrokersManagerForTests(t)
+			brokerID := b.BrokerFromName("BrokerMock").ID
+			if tc.broker == "mockID" {
+				tc.broker = brokerID
+			}
+			if tc.unlink == "mockID" {
+				tc.unlink = brokerID
+			}
+			switch tc.username {
+			case "":
+				tc.username = "user1@example.com"
+			case "-":
+				tc.username = ""
+			}
+
+			client, m := newUserServiceClientWithBrokers(t, "default.db.yaml", b, tc.currentUserNotRoot)
+			if tc.username == "bound" {
+				tc.username = "user1@example.com"
+				err := m.UpdateBrokerForUser(tc.username, brokerID)
+				require.NoError(t, err, "Setup: could not update the broker of the user")
+			}
+
+			_, err := client.LinkIdentity(context.Background(), &authd.LinkIdentityRequest{Name: tc.username, Broker: tc.broker})
+			if tc.wantErrCode != codes.OK {
+				require.Error(t, err, "LinkIdentity should return an error, but did not")
+				require.Equal(t, tc.wantErrCode, status.Code(err), "LinkIdentity should return the expected error code")
+				return
+			}
+			require.NoError(t, err, "LinkIdentity should not return an error, but did")
+
+			wantIdentities := []*authd.LinkedIdentities_Identity{{BrokerId: brokerID, BrokerName: "BrokerMock"}}
+			if tc.unlink != "" {
+				_, err = client.UnlinkIdentity(context.Background(), &authd.UnlinkIdentityRequest{Name: tc.username, Broker: tc.unlink})
+				if tc.wantUnlinkErrCode != codes.OK {
+					require.Error(t, err, "UnlinkIdentity should return an error, but did not")
+					require.Equal(t, tc.wantUnlinkErrCode, status.Code(err), "UnlinkIdentity should return the expected error code")
+					return
+				}
+				require.NoError(t, err, "UnlinkIdentity should not return an error, but did")
+				wantIdentities = nil
+			}
+
+			got, err := client.GetLinkedIdentities(context.Background(), &authd.GetLinkedIdentitiesRequest{Name: tc.username})
+			require.NoError(t, err, "GetLinkedIdentities should not return an error, but did")
+			require.Equal(t, "broker-id", got.GetBrokerId(), "GetLinkedIdentities should return the broker the user is bound to")
+			require.Len(t, got.GetIdentities(), len(wantIdentities), "GetLinkedIdentities should return the linked identities")
+			for i, want := range wantIdentities {
+				require.Equal(t, want.GetBrokerId(), got.GetIdentities()[i].GetBrokerId(), "GetLinkedIdentities should return the broker ID of the identity")
+				require.Equal(t, want.GetBrokerName(), got.GetIdentities()[i].GetBrokerName(), "GetLinkedIdentities should return the broker name of the identity")
+				require.Empty(t, got.GetIdentities()[i].GetProviderId(), "The linked identity should be pending")
+			}
+		})
+	}
+}
+
+func TestVerifyUser(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		username      string
+		allowedGroups []string
+		deniedGroups  []string
+
+		wantErrCode codes.Code
+	}{
+		"Verify_active_user":                          {username: "user1@example.com"},
+		"Verify_active_user_with_uppercase":           {username: "USER1@EXAMPLE.COM"},
+		"Verify_active_user_member_of_allowed_group":  {username: "user1@example.com", allowedGroups: []string{"group-user1@example.com"}},
+		"Verify_active_user_not_member_of_any_group":  {username: "user1@example.com", allowedGroups: []string{"admins"}},
+		"Verify_active_user_member_of_denied_group":   {username: "user1@example.com", deniedGroups: []string{"group-user1@example.com"}},
+		"Verify_locked_user":                          {username: "locked@example.com"},
+		"Verify_user_disabled_at_the_provider":        {username: "verify_disabled@example.com"},
+		"Verify_user_which_can_not_be_checked_online": {username: "verify_unknown@example.com"},
+
+		"Error_when_username_is_empty":       {wantErrCode: codes.InvalidArgument},
+		"Error_when_user_does_not_exist":     {username: "doesnotexist@example.com", wantErrCode: codes.NotFound},
+		"Error_when_user_is_local":           {username: "local-user", wantErrCode: codes.FailedPrecondition},
+		"Error_when_broker_is_not_available": {username: "unavailable-broker@example.com", wantErrCode: codes.FailedPrecondition},
+		"Error_when_broker_returns_an_error": {username: "verify_error@example.com", wantErrCode: codes.Unavailable},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			client, _ := newUserServiceClientWithBrokers(t, "verify-user.db.yaml", newBrokersManagerForTests(t), false,
+				user.WithLoginPolicy(loginpolicy.NewPolicy(loginpolicy.Config{AllowedGroups: tc.allowedGroups, DeniedGroups: tc.deniedGroups}, nil)))
+
+			got, err := client.VerifyUser(context.Background(), &authd.VerifyUserRequest{Name: tc.username})
+			if tc.wantErrCode != codes.OK {
+				require.Error(t, err, "VerifyUser should return an error, but did not")
+				require.Equal(t, tc.wantErrCode, status.Code(err), "VerifyUser should return the expected error code")
+				return
+			}
+			require.NoError(t, err, "VerifyUser should not return an error, but did")
+
+			golden.CheckOrUpdateYAML(t, got)
+		})
+	}
+}
+
+func TestRevalidateUsers(t *testing.T) {
+	t.Parallel()
+
+	dbDir := t.TempDir()
+	err := db.Z_ForTests_CreateDBFromYAML(testDBPath("verify-user.db.yaml"), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+	cfg := users.DefaultConfig
+	cfg.Revalidation.Interval = 24 * time.Hour
+	m, err := users.NewManager(cfg, dbDir)
+	require.NoError(t, err, "Setup: could not create user manager")
+	t.Cleanup(func() { _ = m.Stop() })
+
+	pm := permissions.New(permissions.Z_ForTests_WithCurrentUserAsRoot())
+	service := user.NewService(context.Background(), m, newBrokersManagerForTests(t), &pm)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.RunScheduledTasks(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		locked, err := m.IsUserLocked("verify_disabled@example.com")
+		return err == nil && locked
+	}, 10*time.Second, 10*time.Millisecond, "User disabled at the provider should be locked")
+	cancel()
+	<-done
+
+	for _, name := range []string{"user1@example.com", "verify_unknown@example.com", "verify_error@example.com", "local-user", "unavailable-broker@example.com"} {
+		locked, err := m.IsUserLocked(name)
+		require.NoError(t, err, "IsUserLocked should not return an error")
+		require.False(t, locked, "User %q should not be locked", name)
+	}
+}
+
+func TestGetSessionToken(t *testing.T) {
+	t.Parallel()
+
+	// The fixture users are identified by their UID, which is replaced with the one of the current user, as the token
+	// is always requested for the calling user.
+	uids := map[string]string{
+		"user1@example.com":              "1111",
+		"locked@example.com":             "2222",
+		"token_error@example.com":        "3333",
+		"local-user":                     "4444",
+		"unavailable-broker@example.com": "5555",
+	}
+
+	tests := map[string]struct {
+		caller string
+		scopes []string
+
+		wantErrCode codes.Code
+	}{
+		"Get_session_token":                 {caller: "user1@example.com"},
+		"Get_session_token_for_many_scopes": {caller: "user1@example.com", scopes: []string{"openid", "email"}},
+
+		"Error_when_no_scope_is_requested":   {caller: "user1@example.com", scopes: []string{}, wantErrCode: codes.InvalidArgument},
+		"Error_when_caller_is_not_in_authd":  {wantErrCode: codes.NotFound},
+		"Error_when_caller_is_locked":        {caller: "locked@example.com", wantErrCode: codes.PermissionDenied},
+		"Error_when_caller_is_local":         {caller: "local-user", wantErrCode: codes.FailedPrecondition},
+		"Error_when_broker_is_not_available": {caller: "unavailable-broker@example.com", wantErrCode: codes.FailedPrecondition},
+		"Error_when_broker_returns_an_error": {caller: "token_error@example.com", wantErrCode: codes.Unavailable},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.scopes == nil {
+				tc.scopes = []string{"openid"}
+			}
+
+			d, err := os.ReadFile(filepath.Join("testdata", "session-token.db.yaml"))
+			require.NoError(t, err, "Setup: could not read fixture database file")
+			if uid, ok := uids[tc.caller]; ok {
+				d = []byte(strings.ReplaceAll(string(d), "uid: "+uid+"\n", fmt.Sprintf("uid: %d\n", os.Geteuid())))
+			}
+			dbFile := filepath.Join(t.TempDir(), "session-token.db.yaml")
+			require.NoError(t, os.WriteFile(dbFile, d, 0600), "Setup: could not write database file")
+
+			client, _ := newUserServiceClientWithBrokers(t, dbFile, newBrokersManagerForTests(t), false)
+
+			got, err := client.GetSessionToken(context.Background(), &authd.GetSessionTokenRequest{Scopes: tc.scopes})
+			if tc.wantErrCode != codes.OK {
+				require.Error(t, err, "GetSessionToken should return an error, but did not")
+				require.Equal(t, tc.wantErrCode, status.Code(err), "GetSessionToken should return the expected error code")
+				return
+			}
+			require.NoError(t, err, "GetSessionToken should not return an error, but did")
+
+			golden.CheckOrUpdateYAML(t, got)
+		})
+	}
+}
+
+func TestGetUserStatus(t *testing.T) {
+	t.Parallel()
+
+	// The status is always the one of the calling user, so the UID of the fixture user is replaced with the one of
+	// the current user.
+	uids := map[string]string{
+		"user1@example.com":              "1111",
+		"locked@example.com":             "2222",
+		"token_error@example.com":        "3333",
+		"local-user":                     "4444",
+		"unavailable-broker@example.com": "5555",
+	}
+
+	tests := map[string]struct {
+		caller string
+
+		wantErrCode codes.Code
+	}{
+		"Get_status_of_user":                      {caller: "user1@example.com"},
+		"Get_status_of_locked_user":               {caller: "locked@example.com"},
+		"Get_status_of_local_user":                {caller: "local-user"},
+		"Get_status_when_broker_is_not_available": {caller: "unavailable-broker@example.com"},
+		"Get_status_when_broker_returns_an_error": {caller: "token_error@example.com"},
+		"Error_when_caller_is_not_in_authd":       {wantErrCode: codes.NotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d, err := os.ReadFile(filepath.Join("testdata", "user-status.db.yaml"))
+			require.NoError(t, err, "Setup: could not read fixture database file")
+			if uid, ok := uids[tc.caller]; ok {
+				d = []byte(strings.ReplaceAll(string(d), "uid: "+uid+"\n", fmt.Sprintf("uid: %d\n", os.Geteuid())))
+			}
+			dbFile := filepath.Join(t.TempDir(), "user-status.db.yaml")
+			require.NoError(t, os.WriteFile(dbFile, d, 0600), "Setup: could not write database file")
+
+			client, _ := newUserServiceClientWithBrokers(t, dbFile, newBrokersManagerForTests(t), false)
+
+			got, err := client.GetUserStatus(context.Background(), &authd.Empty{})
+			if tc.wantErrCode != codes.OK {
+				require.Error(t, err, "GetUserStatus should return an error, but did not")
+				require.Equal(t, tc.wantErrCode, status.Code(err), "GetUserStatus should return the expected error code")
+				return
+			}
+			require.NoError(t, err, "GetUserStatus should not return an error, but did")
+
+			// The UID is the one of the user running the tests.
+			got.Uid = 0
+			golden.CheckOrUpdateYAML(t, got)
+		})
+	}
+}
+
+func TestSessionStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		uid uint32
+
+		want    user.SessionStatus
+		wantErr bool
+	}{
+		"Get_session_of_user": {
+			uid:  1111,
+			want: user.SessionStatus{Broker: "BrokerMock", Expiry: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), CanRefresh: true},
+		},
+		"Get_session_of_user_without_refresh_token": {
+			uid:  7777,
+			want: user.SessionStatus{Broker: "BrokerMock", Expiry: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+
+		"Error_when_user_is_not_in_authd":    {uid: 9999, wantErr: true},
+		"Error_when_user_is_local":           {uid: 4444, wantErr: true},
+		"Error_when_broker_is_not_available": {uid: 5555, wantErr: true},
+		"Error_when_broker_returns_an_error": {uid: 3333, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newUserServiceForTests(t, "user-status.db.yaml")
+
+			got, err := s.SessionStatus(context.Background(), tc.uid)
+			if tc.wantErr {
+				require.Error(t, err, "SessionStatus should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "SessionStatus should not return an error, but did")
+			require.True(t, tc.want.Expiry.Equal(got.Expiry), "SessionStatus should return the expiry of the token")
+			got.Expiry = tc.want.Expiry
+			require.Equal(t, tc.want, got, "SessionStatus should return the status of the session")
+		})
+	}
+}
+
+func TestRefreshSession(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		uid uint32
+
+		wantErr                   bool
+		wantReauthenticationError bool
+	}{
+		"Refresh_session_of_user": {uid: 1111},
+
+		"Error_when_user_is_locked":               {uid: 2222, wantErr: true},
+		"Error_when_user_is_local":                {uid: 4444, wantErr: true},
+		"Error_when_broker_fails_to_refresh":      {uid: 6666, wantErr: true},
+		"Error_when_user_has_no_refresh_token":    {uid: 7777, wantReauthenticationError: true},
+		"Error_when_broker_fails_to_report_token": {uid: 3333, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newUserServiceForTests(t, "user-status.db.yaml")
+
+			got, err := s.RefreshSession(context.Background(), tc.uid)
+			if tc.wantReauthenticationError {
+				require.ErrorIs(t, err, user.ErrReauthenticationRequired, "RefreshSession should ask the user to authenticate again")
+				return
+			}
+			if tc.wantErr {
+				require.Error(t, err, "RefreshSession should return an error, but did not")
+				require.NotErrorIs(t, err, user.ErrReauthenticationRequired, "RefreshSession should not ask the user to authenticate again")
+				return
+			}
+			require.NoError(t, err, "RefreshSession should not return an error, but did")
+			require.True(t, time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC).Equal(got.Expiry), "RefreshSession should return the expiry of the refreshed token")
+			require.Equal(t, "BrokerMock", got.Broker, "RefreshSession should return the broker of the user")
+		})
+	}
+}
+
+func TestUserLifecycle(t *testing.T) {
+	tests := map[string]struct {
+		username           string
+		operations         []string
+		currentUserNotRoot bool
+
+		wantState   string
+		wantNext    string
+		wantErrCode codes.Code
+	}{
+		"Successfully_disable_user":                {username: "user1@example.com", operations: []string{"disable"}, wantState: "disabled"},
+		"Successfully_disable_user_with_uppercase": {username: "USER1@example.com", operations: []string{"disable"}, wantState: "disabled"},
+		"Successfully_archive_user":                {username: "user1@example.com", operations: []string{"disable", "archive"}, wantState: "archived"},
+		"Successfully_restore_user":                {username: "user1@example.com", operations: []string{"disable", "archive", "restore"}, wantState: "active"},
+		"Successfully_get_state_of_active_user":    {username: "user1@example.com", wantState: "active"},
+
+		"Error_when_username_is_empty":      {operations: []string{"disable"}, wantErrCode: codes.InvalidArgument},
+		"Error_when_user_does_not_exist":    {username: "doesnotexist@example.com", operations: []string{"disable"}, wantErrCode: codes.NotFound},
+		"Error_when_archiving_active_user":  {username: "user1@example.com", operations: []string{"archive"}, wantErrCode: codes.FailedPrecondition},
+		"Error_when_restoring_active_user":  {username: "user1@example.com", operations: []string{"restore"}, wantErrCode: codes.FailedPrecondition},
+		"Error_when_not_root":               {username: "user1@example.com", operations: []string{"disable"}, currentUserNotRoot: true, wantErrCode: codes.PermissionDenied},
+		"Error_getting_state_when_not_root": {username: "user1@example.com", currentUserNotRoot: true, wantErrCode: codes.PermissionDenied},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, m := newUserServiceClient(t, "", tc.currentUserNotRoot)
+
+			var err error
+			for _, op := range tc.operations {
+				switch op {
+				case "disable":
+					_, err = client.DisableUser(context.Background(), &authd.DisableUserRequest{Name: tc.username})
+				case "archive":
+					_, err = client.ArchiveUser(context.Background(), &authd.ArchiveUserRequest{Name: tc.username})
+				case "restore":
+					_, err = client.RestoreUser(context.Background(), &authd.RestoreUserRequest{Name: tc.username})
+				}
+				if err != nil {
+					break
+				}
+			}
+			var lc *authd.UserLifecycle
+			if err == nil {
+				lc, err = client.GetUserLifecycle(context.Background(), &authd.GetUserLifecycleRequest{Name: tc.username})
+			}
+			if tc.wantErrCode != codes.OK {
+				require.Equal(t, tc.wantErrCode, status.Code(err), "Changing the lifecycle state should return the expected error code")
+				return
+			}
+			require.NoError(t, err, "Changing the lifecycle state should not return an error, but did")
+			require.Equal(t, tc.wantState, lc.GetState(), "GetUserLifecycle should return the expected state")
+			require.Equal(t, tc.wantState == "active", lc.GetSince() == 0, "Only active users should have no state change time")
+
+			dbContent, err := db.Z_ForTests_DumpNormalizedYAML(userstestutils.DBManager(m))
+			require.NoError(t, err, "Setup: failed to dump database for comparing")
+			golden.CheckOrUpdate(t, dbContent)
+		})
+	}
+}
+
+func TestCleanupHomes(t *testing.T) {
+	tests := map[string]struct {
+		dryRun             bool
+		currentUserNotRoot bool
+
+		wantEntries int
+		wantErrCode codes.Code
+	}{
+		"Successfully_report_deleted_users_in_dry_run":   {dryRun: true, wantEntries: 1},
+		"Successfully_forget_deleted_users_without_home": {},
+
+		"Error_when_not_root": {dryRun: true, currentUserNotRoot: true, wantErrCode: codes.PermissionDenied},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, m := newUserServiceClient(t, "", tc.currentUserNotRoot)
+
+			// The home of the user does not exist, so there is nothing to clean up.
+			err := m.DeleteUser("user1@example.com", false)
+			require.NoError(t, err, "Setup: could not delete user")
+
+			report, err := client.CleanupHomes(context.Background(), &authd.CleanupHomesRequest{DryRun: tc.dryRun})
+			if tc.wantErrCode != codes.OK {
+				require.Equal(t, tc.wantErrCode, status.Code(err), "CleanupHomes should return the expected error code")
+				return
+			}
+			require.NoError(t, err, "CleanupHomes should not return an error, but did")
+			require.Len(t, report.GetEntries(), 1, "CleanupHomes should report the deleted user")
+			require.Equal(t, "user1@example.com", report.GetEntries()[0].GetName(), "CleanupHomes should report the deleted user")
+			require.Equal(t, "nothing left on disk", report.GetEntries()[0].GetSkipped(), "CleanupHomes should report why nothing is done")
+
+			report, err = client.CleanupHomes(context.Background(), &authd.CleanupHomesRequest{DryRun: true})
+			require.NoError(t, err, "CleanupHomes should not return an error, but did")
+			require.Len(t, report.GetEntries(), tc.wantEntries, "Only a dry run should keep track of the deleted user")
+		})
+	}
+}
+
+func TestCleanupStaleUsers(t *testing.T) {
+	tests := map[string]struct {
+		currentUserNotRoot bool
+
+		wantErrCode codes.Code
+	}{
+		"Successfully_report_that_stale_users_are_not_cleaned_up_by_default": {},
+
+		"Error_when_not_root": {currentUserNotRoot: true, wantErrCode: codes.PermissionDenied},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, _ := newUserServiceClient(t, "", tc.currentUserNotRoot)
+
+			report, err := client.CleanupStaleUsers(context.Background(), &authd.CleanupStaleUsersRequest{DryRun: true})
+			if tc.wantErrCode != codes.OK {
+				require.Equal(t, tc.wantErrCode, status.Code(err), "CleanupStaleUsers should return the expected error code")
+				return
+			}
+			require.NoError(t, err, "CleanupStaleUsers should not return an error, but did")
+			require.Zero(t, report.GetStaleAfter(), "CleanupStaleUsers should report that stale users are not cleaned up")
+			require.Empty(t, report.GetEntries(), "CleanupStaleUsers should not report any user")
+		})
+	}
+}
+
+func TestListOrphanedHomes(t *testing.T) {
+	tests := map[string]struct {
+		prefixes           []string
+		currentUserNotRoot bool
+
+		wantPrefixes []string
+		wantErrCode  codes.Code
+	}{
+		"Successfully_list_orphaned_homes_of_default_prefixes": {wantPrefixes: []string{"/home"}},
+		"Successfully_list_orphaned_homes_of_given_prefixes":   {prefixes: []string{"/nonexistent/home"}, wantPrefixes: []string{"/nonexistent/home"}},
+
+		"Error_when_prefix_is_not_absolute": {prefixes: []string{"home"}, wantErrCode: codes.InvalidArgument},
+		"Error_when_not_root":               {currentUserNotRoot: true, wantErrCode: codes.PermissionDenied},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.wantErrCode == codes.OK {
+				userslocking.Z_ForTests_OverrideLockingWithCleanup(t)
+			}
+
+			client, _ := newUserServiceClient(t, "default.db.yaml", tc.currentUserNotRoot)
+
+			resp, err := client.ListOrphanedHomes(context.Background(), &authd.ListOrphanedHomesRequest{Prefixes: tc.prefixes})
+			if tc.wantErrCode != codes.OK {
+				require.Equal(t, tc.wantErrCode, status.Code(err), "ListOrphanedHomes should return the expected error code")
+				return
+			}
+			require.NoError(t, err, "ListOrphanedHomes should not return an error, but did")
+			require.Equal(t, tc.wantPrefixes, resp.GetPrefixes(), "ListOrphanedHomes should report the searched prefixes")
+			if tc.prefixes != nil {
+				require.Empty(t, resp.GetEntries(), "ListOrphanedHomes should not report homes in missing prefixes")
+			}
+		})
+	}
+}
+
 func newUserServiceClient(t *testing.T, dbFile string, currentUserNotRoot ...bool) (client authd.UserServiceClient, userManager *users.Manager) {
 	t.Helper()
 
+	notRoot := len(currentUserNotRoot) > 0 && currentUserNotRoot[0]
+	return newUserServiceClientWithBrokers(t, dbFile, newBrokersManagerForTests(t), notRoot)
+}
+
+// newUserServiceClientWithBrokers returns a new gRPC client for the user service using the given broker manager.
+func newUserServiceClientWithBrokers(t *testing.T, dbFile string, brokerManager *brokers.Manager, currentUserNotRoot bool, args ...user.Option) (client authd.UserServiceClient, userManager *users.Manager) {
+	t.Helper()
+
 	tmpDir, err := os.MkdirTemp("", "authd-socket-dir")
 	require.NoError(t, err, "Setup: could not setup temporary socket dir path")
 	t.Cleanup(func() { _ = os.RemoveAll(tmpDir) })
@@ -605,20 +1802,19 @@ func newUserServiceClient(t *testing.T, dbFile string, currentUserNotRoot ...boo
 
 	dbDir := t.TempDir()
 	if dbFile != "" {
-		err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", dbFile), dbDir)
+		err := db.Z_ForTests_CreateDBFromYAML(testDBPath(dbFile), dbDir)
 		require.NoError(t, err, "Setup: could not create database from testdata")
 	}
 
 	userManager = newUserManagerForTests(t, dbFile)
-	brokerManager := newBrokersManagerForTests(t)
 
 	var permissionsManager permissions.Manager
-	if len(currentUserNotRoot) > 0 && currentUserNotRoot[0] {
+	if currentUserNotRoot {
 		permissionsManager = permissions.New()
 	} else {
 		permissionsManager = permissions.New(permissions.Z_ForTests_WithCurrentUserAsRoot())
 	}
-	service := user.NewService(context.Background(), userManager, brokerManager, &permissionsManager)
+	service := user.NewService(context.Background(), userManager, brokerManager, &permissionsManager, args...)
 
 	grpcServer := grpc.NewServer(permissions.WithUnixPeerCreds(), grpc.ChainUnaryInterceptor(errmessages.RedactErrorInterceptor))
 	authd.RegisterUserServiceServer(grpcServer, service)
@@ -640,6 +1836,14 @@ func newUserServiceClient(t *testing.T, dbFile string, currentUserNotRoot ...boo
 	return authd.NewUserServiceClient(conn), userManager
 }
 
+// newUserServiceForTests returns a user service with the broker mock, to call the methods which are not served over gRPC.
+func newUserServiceForTests(t *testing.T, dbFile string) user.Service {
+	t.Helper()
+
+	permissionsManager := permissions.New(permissions.Z_ForTests_WithCurrentUserAsRoot())
+	return user.NewService(context.Background(), newUserManagerForTests(t, dbFile), newBrokersManagerForTests(t), &permissionsManager)
+}
+
 // newUserManagerForTests returns a user manager object cleaned up with the test ends.
 func newUserManagerForTests(t *testing.T, dbFile string) *users.Manager {
 	t.Helper()
@@ -648,7 +1852,7 @@ func newUserManagerForTests(t *testing.T, dbFile string) *users.Manager {
 	if dbFile == "" {
 		dbFile = "default.db.yaml"
 	}
-	err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", dbFile), dbDir)
+	err := db.Z_ForTests_CreateDBFromYAML(testDBPath(dbFile), dbDir)
 	require.NoError(t, err, "Setup: could not create database from testdata")
 
 	managerOpts := []users.Option{
@@ -664,6 +1868,14 @@ func newUserManagerForTests(t *testing.T, dbFile string) *users.Manager {
 	return m
 }
 
+// testDBPath returns the path of a database fixture, which is in testdata unless the path is absolute.
+func testDBPath(dbFile string) string {
+	if filepath.IsAbs(dbFile) {
+		return dbFile
+	}
+	return filepath.Join("testdata", dbFile)
+}
+
 // newBrokersManagerForTests returns a new broker manager with a broker mock for tests, it's cleaned when the test ends.
 func newBrokersManagerForTests(t *testing.T) *brokers.Manager {
 	t.Helper()
@@ -680,7 +1892,7 @@ func newBrokersManagerForTests(t *testing.T) *brokers.Manager {
 }
 
 // requireExpectedResult asserts expected results from a get request and checks or updates the golden file.
-func requireExpectedResult[T authd.User | authd.Group](t *testing.T, funcName string, got *T, err error, wantErr, wantErrNotExists bool) {
+func requireExpectedResult[T authd.User | authd.Group | authd.Groups | authd.AuthorizedKeys](t *testing.T, funcName string, got *T, err error, wantErr, wantErrNotExists bool) {
 	t.Helper()
 
 	if wantErr {
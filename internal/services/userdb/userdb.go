@@ -0,0 +1,359 @@
+// Package userdb serves the authd users and groups over the io.systemd.UserDatabase varlink interface, so that
+// systemd and the tools using the systemd user database, like userdbctl, see them as native records.
+package userdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/canonical/authd/internal/consts"
+	"github.com/canonical/authd/internal/decorate"
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+// disposition is the disposition of all the authd users and groups in the systemd user database.
+const disposition = "regular"
+
+// Errors of the io.systemd.UserDatabase interface.
+var (
+	errNoRecordFound          = varlinkError{name: "io.systemd.UserDatabase.NoRecordFound"}
+	errBadService             = varlinkError{name: "io.systemd.UserDatabase.BadService"}
+	errServiceNotAvailable    = varlinkError{name: "io.systemd.UserDatabase.ServiceNotAvailable"}
+	errConflictingRecordFound = varlinkError{name: "io.systemd.UserDatabase.ConflictingRecordFound"}
+)
+
+// interfaceDescription is the description of the io.systemd.UserDatabase interface.
+const interfaceDescription = `interface io.systemd.UserDatabase
+
+method GetUserRecord(uid: ?int, userName: ?string, service: string) -> (record: object, incomplete: bool)
+method GetGroupRecord(gid: ?int, groupName: ?string, service: string) -> (record: object, incomplete: bool)
+method GetMemberships(userName: ?string, groupName: ?string, service: string) -> (userName: string, groupName: string)
+
+error NoRecordFound()
+error BadService()
+error ServiceNotAvailable()
+error ConflictingRecordFound()
+`
+
+// Service is the implementation of the io.systemd.UserDatabase varlink interface.
+type Service struct {
+	userManager *users.Manager
+	// name is the name of the service in the systemd user database, which is the name of its socket.
+	name string
+}
+
+// NewService returns a new io.systemd.UserDatabase service with the given name.
+func NewService(ctx context.Context, userManager *users.Manager, name string) Service {
+	log.Debug(ctx, "Building new userdb service")
+
+	return Service{userManager: userManager, name: name}
+}
+
+// Serve serves the users and groups on the unix socket at socketPath until the returned function is called.
+func Serve(ctx context.Context, userManager *users.Manager, socketPath string) (stop func(), err error) {
+	defer decorate.OnError(&err, "could not serve the users and groups on %q", socketPath)
+
+	// Remove the socket left behind if authd was not stopped cleanly.
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	lis, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	// Anyone can query the user database, like NSS.
+	//nolint:gosec // The records don't contain any secret.
+	if err := os.Chmod(socketPath, 0666); err != nil {
+		_ = lis.Close()
+		return nil, err
+	}
+
+	log.Infof(ctx, "Serving the systemd user database on %s", socketPath)
+	return serve(ctx, NewService(ctx, userManager, filepath.Base(socketPath)), lis), nil
+}
+
+func serve(ctx context.Context, s Service, lis net.Listener) (stop func()) {
+	return serveVarlink(ctx, lis, map[string]method{
+		"io.systemd.UserDatabase.GetUserRecord":       s.getUserRecord,
+		"io.systemd.UserDatabase.GetGroupRecord":      s.getGroupRecord,
+		"io.systemd.UserDatabase.GetMemberships":      s.getMemberships,
+		"org.varlink.service.GetInfo":                 getInfo,
+		"org.varlink.service.GetInterfaceDescription": getInterfaceDescription,
+	})
+}
+
+type userRecordParameters struct {
+	UID      *uint32 `json:"uid"`
+	UserName string  `json:"userName"`
+	Service  string  `json:"service"`
+}
+
+type groupRecordParameters struct {
+	GID       *uint32 `json:"gid"`
+	GroupName string  `json:"groupName"`
+	Service   string  `json:"service"`
+}
+
+type membershipsParameters struct {
+	UserName  string `json:"userName"`
+	GroupName string `json:"groupName"`
+	Service   string `json:"service"`
+}
+
+// recordReply is the reply of GetUserRecord and GetGroupRecord.
+type recordReply struct {
+	Record     any  `json:"record"`
+	Incomplete bool `json:"incomplete"`
+}
+
+// userRecord is a JSON user record, as described in https://systemd.io/USER_RECORD.
+type userRecord struct {
+	UserName      string `json:"userName"`
+	RealName      string `json:"realName,omitempty"`
+	UID           uint32 `json:"uid"`
+	GID           uint32 `json:"gid"`
+	HomeDirectory string `json:"homeDirectory"`
+	Shell         string `json:"shell"`
+	Locked        bool   `json:"locked,omitempty"`
+	Disposition   string `json:"disposition"`
+	Service       string `json:"service"`
+}
+
+// groupRecord is a JSON group record, as described in https://systemd.io/GROUP_RECORD.
+type groupRecord struct {
+	GroupName   string   `json:"groupName"`
+	GID         uint32   `json:"gid"`
+	Members     []string `json:"members,omitempty"`
+	Disposition string   `json:"disposition"`
+	Service     string   `json:"service"`
+}
+
+// membership is the reply of GetMemberships.
+type membership struct {
+	UserName  string `json:"userName"`
+	GroupName string `json:"groupName"`
+}
+
+// getUserRecord returns the user with the given UID or name, or all the users if none is given.
+func (s Service) getUserRecord(_ context.Context, rawParams json.RawMessage) ([]any, error) {
+	var p userRecordParameters
+	if err := parseParameters(rawParams, &p); err != nil {
+		return nil, err
+	}
+	if err := s.checkService(p.Service); err != nil {
+		return nil, err
+	}
+
+	var entries []types.UserEntry
+	switch {
+	case p.UserName != "":
+		u, err := s.userManager.UserByName(p.UserName)
+		if err != nil {
+			return nil, lookupError(err)
+		}
+		if p.UID != nil && u.UID != *p.UID {
+			return nil, errConflictingRecordFound
+		}
+		entries = []types.UserEntry{u}
+	case p.UID != nil:
+		u, err := s.userManager.UserByID(*p.UID)
+		if err != nil {
+			return nil, lookupError(err)
+		}
+		entries = []types.UserEntry{u}
+	default:
+		var err error
+		if entries, err = s.userManager.AllUsers(); err != nil {
+			return nil, err
+		}
+	}
+	if len(entries) == 0 {
+		return nil, errNoRecordFound
+	}
+
+	var replies []any
+	for _, u := range entries {
+		locked, err := s.userManager.IsUserLocked(u.Name)
+		// Temporary pre-auth users are not in the database and can't be locked.
+		if err != nil && !errors.Is(err, users.NoDataFoundError{}) {
+			return nil, err
+		}
+		replies = append(replies, recordReply{Record: userRecord{
+			UserName:      u.Name,
+			RealName:      u.Gecos,
+			UID:           u.UID,
+			GID:           u.GID,
+			HomeDirectory: u.Dir,
+			Shell:         u.Shell,
+			Locked:        locked,
+			Disposition:   disposition,
+			Service:       s.name,
+		}})
+	}
+	return replies, nil
+}
+
+// getGroupRecord returns the group with the given GID or name, or all the groups if none is given.
+func (s Service) getGroupRecord(_ context.Context, rawParams json.RawMessage) ([]any, error) {
+	var p groupRecordParameters
+	if err := parseParameters(rawParams, &p); err != nil {
+		return nil, err
+	}
+	if err := s.checkService(p.Service); err != nil {
+		return nil, err
+	}
+
+	var entries []types.GroupEntry
+	switch {
+	case p.GroupName != "":
+		g, err := s.userManager.GroupByName(p.GroupName)
+		if err != nil {
+			return nil, lookupError(err)
+		}
+		if p.GID != nil && g.GID != *p.GID {
+			return nil, errConflictingRecordFound
+		}
+		entries = []types.GroupEntry{g}
+	case p.GID != nil:
+		g, err := s.userManager.GroupByID(*p.GID)
+		if err != nil {
+			return nil, lookupError(err)
+		}
+		entries = []types.GroupEntry{g}
+	default:
+		var err error
+		if entries, err = s.userManager.AllGroups(); err != nil {
+			return nil, err
+		}
+	}
+	if len(entries) == 0 {
+		return nil, errNoRecordFound
+	}
+
+	var replies []any
+	for _, g := range entries {
+		replies = append(replies, recordReply{Record: groupRecord{
+			GroupName:   g.Name,
+			GID:         g.GID,
+			Members:     g.Users,
+			Disposition: disposition,
+			Service:     s.name,
+		}})
+	}
+	return replies, nil
+}
+
+// getMemberships returns the memberships of the given user or group, or all the memberships if none is given.
+func (s Service) getMemberships(_ context.Context, rawParams json.RawMessage) ([]any, error) {
+	var p membershipsParameters
+	if err := parseParameters(rawParams, &p); err != nil {
+		return nil, err
+	}
+	if err := s.checkService(p.Service); err != nil {
+		return nil, err
+	}
+
+	var memberships []any
+	switch {
+	case p.UserName != "":
+		groups, err := s.userManager.UserGroups(p.UserName)
+		if err != nil {
+			return nil, lookupError(err)
+		}
+		for _, g := range groups {
+			if p.GroupName != "" && g != p.GroupName {
+				continue
+			}
+			memberships = append(memberships, membership{UserName: p.UserName, GroupName: g})
+		}
+	case p.GroupName != "":
+		g, err := s.userManager.GroupByName(p.GroupName)
+		if err != nil {
+			return nil, lookupError(err)
+		}
+		for _, u := range g.Users {
+			memberships = append(memberships, membership{UserName: u, GroupName: g.Name})
+		}
+	default:
+		groups, err := s.userManager.AllGroups()
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range groups {
+			for _, u := range g.Users {
+				memberships = append(memberships, membership{UserName: u, GroupName: g.Name})
+			}
+		}
+	}
+	if len(memberships) == 0 {
+		return nil, errNoRecordFound
+	}
+	return memberships, nil
+}
+
+// parseParameters decodes the parameters of a call. Missing parameters are left empty.
+func parseParameters(rawParams json.RawMessage, p any) error {
+	if len(rawParams) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(rawParams, p); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return errInvalidParameter(typeErr.Field)
+		}
+		return fmt.Errorf("invalid parameters: %w", err)
+	}
+	return nil
+}
+
+// checkService returns an error if the call is not for this service, as the multiplexer of the systemd user database
+// sends the name of the service it expects an answer from.
+func (s Service) checkService(service string) error {
+	if service != s.name {
+		return errBadService
+	}
+	return nil
+}
+
+// lookupError returns the varlink error of a failed lookup.
+func lookupError(err error) error {
+	if errors.Is(err, users.NoDataFoundError{}) {
+		return errNoRecordFound
+	}
+	return err
+}
+
+type infoReply struct {
+	Vendor     string   `json:"vendor"`
+	Product    string   `json:"product"`
+	Version    string   `json:"version"`
+	URL        string   `json:"url"`
+	Interfaces []string `json:"interfaces"`
+}
+
+func getInfo(context.Context, json.RawMessage) ([]any, error) {
+	return []any{infoReply{
+		Vendor:     "Canonical",
+		Product:    "authd",
+		Version:    consts.Version,
+		URL:        "https://github.com/canonical/authd",
+		Interfaces: []string{"io.systemd.UserDatabase", "org.varlink.service"},
+	}}, nil
+}
+
+func getInterfaceDescription(_ context.Context, rawParams json.RawMessage) ([]any, error) {
+	var p struct {
+		Interface string `json:"interface"`
+	}
+	if err := parseParameters(rawParams, &p); err != nil || p.Interface != "io.systemd.UserDatabase" {
+		return nil, errInvalidParameter("interface")
+	}
+	return []any{map[string]string{"description": interfaceDescription}}, nil
+}
@@ -0,0 +1,161 @@
+package userdb_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/internal/services/userdb"
+	"github.com/canonical/authd/internal/testutils/golden"
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/stretchr/testify/require"
+)
+
+const serviceName = "com.ubuntu.authd"
+
+func TestUserDB(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		method     string
+		parameters string
+		more       bool
+		oneway     bool
+	}{
+		"Get_user_by_name":          {method: "io.systemd.UserDatabase.GetUserRecord", parameters: `{"userName": "user1@example.com"}`},
+		"Get_user_by_UID":           {method: "io.systemd.UserDatabase.GetUserRecord", parameters: `{"uid": 2222}`},
+		"Get_user_by_name_and_UID":  {method: "io.systemd.UserDatabase.GetUserRecord", parameters: `{"userName": "user2@example.com", "uid": 2222}`},
+		"Get_locked_user":           {method: "io.systemd.UserDatabase.GetUserRecord", parameters: `{"userName": "user3@example.com"}`},
+		"Enumerate_users":           {method: "io.systemd.UserDatabase.GetUserRecord", more: true},
+		"Get_group_by_name":         {method: "io.systemd.UserDatabase.GetGroupRecord", parameters: `{"groupName": "commongroup"}`},
+		"Get_group_by_GID":          {method: "io.systemd.UserDatabase.GetGroupRecord", parameters: `{"gid": 11111}`},
+		"Enumerate_groups":          {method: "io.systemd.UserDatabase.GetGroupRecord", more: true},
+		"Get_memberships_of_user":   {method: "io.systemd.UserDatabase.GetMemberships", parameters: `{"userName": "user2@example.com"}`, more: true},
+		"Get_memberships_of_group":  {method: "io.systemd.UserDatabase.GetMemberships", parameters: `{"groupName": "commongroup"}`, more: true},
+		"Get_membership":            {method: "io.systemd.UserDatabase.GetMemberships", parameters: `{"userName": "user2@example.com", "groupName": "commongroup"}`},
+		"Enumerate_memberships":     {method: "io.systemd.UserDatabase.GetMemberships", more: true},
+		"Get_info":                  {method: "org.varlink.service.GetInfo"},
+		"Get_interface_description": {method: "org.varlink.service.GetInterfaceDescription", parameters: `{"interface": "io.systemd.UserDatabase"}`},
+
+		"Error_when_user_does_not_exist":                  {method: "io.systemd.UserDatabase.GetUserRecord", parameters: `{"userName": "doesnotexist@example.com"}`},
+		"Error_when_UID_does_not_exist":                   {method: "io.systemd.UserDatabase.GetUserRecord", parameters: `{"uid": 4242}`},
+		"Error_when_user_name_and_UID_do_not_match":       {method: "io.systemd.UserDatabase.GetUserRecord", parameters: `{"userName": "user1@example.com", "uid": 2222}`},
+		"Error_when_group_does_not_exist":                 {method: "io.systemd.UserDatabase.GetGroupRecord", parameters: `{"groupName": "doesnotexist"}`},
+		"Error_when_group_name_and_GID_do_not_match":      {method: "io.systemd.UserDatabase.GetGroupRecord", parameters: `{"groupName": "group1", "gid": 22222}`},
+		"Error_when_user_is_not_member_of_group":          {method: "io.systemd.UserDatabase.GetMemberships", parameters: `{"userName": "user1@example.com", "groupName": "commongroup"}`},
+		"Error_when_enumerating_without_more":             {method: "io.systemd.UserDatabase.GetUserRecord"},
+		"Error_when_service_is_not_authd":                 {method: "io.systemd.UserDatabase.GetUserRecord", parameters: `{"userName": "user1@example.com", "service": "io.systemd.Multiplexer"}`},
+		"Error_when_parameter_has_wrong_type":             {method: "io.systemd.UserDatabase.GetUserRecord", parameters: `{"uid": "user1@example.com"}`},
+		"Error_when_method_does_not_exist":                {method: "io.systemd.UserDatabase.DoesNotExist"},
+		"Error_when_interface_description_does_not_exist": {method: "org.varlink.service.GetInterfaceDescription", parameters: `{"interface": "io.systemd.DoesNotExist"}`},
+
+		"No_reply_to_oneway_call": {method: "io.systemd.UserDatabase.GetUserRecord", parameters: `{"uid": 1111}`, oneway: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			conn := newUserDBConn(t)
+
+			params := map[string]any{"service": serviceName}
+			if tc.parameters != "" {
+				require.NoError(t, json.Unmarshal([]byte(tc.parameters), &params), "Setup: invalid parameters")
+			}
+			got := conn.call(t, map[string]any{"method": tc.method, "parameters": params, "more": tc.more, "oneway": tc.oneway})
+			if tc.oneway {
+				// The connection is still usable after a oneway call, and nothing was sent back for it.
+				got = conn.call(t, map[string]any{"method": "org.varlink.service.GetInfo"})
+				require.Contains(t, got, "vendor", "The reply should be the one of the second call")
+				return
+			}
+
+			golden.CheckOrUpdate(t, got)
+		})
+	}
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+
+	m, err := users.NewManager(users.DefaultConfig, t.TempDir())
+	require.NoError(t, err, "Setup: could not create user manager")
+	t.Cleanup(func() { _ = m.Stop() })
+
+	_, err = userdb.Serve(context.Background(), m, filepath.Join(t.TempDir(), "does-not-exist", serviceName))
+	require.Error(t, err, "Serve should return an error when the socket directory does not exist")
+
+	// A socket left behind is replaced.
+	socketPath := filepath.Join(t.TempDir(), serviceName)
+	stop, err := userdb.Serve(context.Background(), m, socketPath)
+	require.NoError(t, err, "Setup: Serve should not return an error")
+	_, err = net.Dial("unix", socketPath)
+	require.NoError(t, err, "Setup: could not connect to the socket")
+	stop()
+
+	stop, err = userdb.Serve(context.Background(), m, socketPath)
+	require.NoError(t, err, "Serve should replace a socket left behind")
+	stop()
+}
+
+type userDBConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// newUserDBConn serves the test database on a temporary socket and connects to it.
+func newUserDBConn(t *testing.T) userDBConn {
+	t.Helper()
+
+	dbDir := t.TempDir()
+	err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "default.db.yaml"), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+	m, err := users.NewManager(users.DefaultConfig, dbDir)
+	require.NoError(t, err, "Setup: could not create user manager")
+	t.Cleanup(func() { _ = m.Stop() })
+
+	socketPath := filepath.Join(t.TempDir(), serviceName)
+	stop, err := userdb.Serve(context.Background(), m, socketPath)
+	require.NoError(t, err, "Setup: could not serve the user database")
+	t.Cleanup(stop)
+
+	conn, err := net.Dial("unix", socketPath)
+	require.NoError(t, err, "Setup: could not connect to the user database")
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return userDBConn{Conn: conn, r: bufio.NewReader(conn)}
+}
+
+// call sends the call and returns its replies, one per line.
+func (c userDBConn) call(t *testing.T, call map[string]any) string {
+	t.Helper()
+
+	data, err := json.Marshal(call)
+	require.NoError(t, err, "Setup: could not marshal call")
+	_, err = c.Write(append(data, 0))
+	require.NoError(t, err, "Could not send call")
+
+	if oneway, _ := call["oneway"].(bool); oneway {
+		return ""
+	}
+
+	var replies []string
+	for {
+		msg, err := c.r.ReadBytes(0)
+		require.NoError(t, err, "Could not read reply")
+		msg = msg[:len(msg)-1]
+		replies = append(replies, string(msg))
+
+		var r struct {
+			Continues bool `json:"continues"`
+		}
+		require.NoError(t, json.Unmarshal(msg, &r), "Reply should be valid JSON")
+		if !r.Continues {
+			return strings.Join(replies, "\n") + "\n"
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package userdb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/canonical/authd/log"
+)
+
+// call is a varlink method call.
+type call struct {
+	Method     string          `json:"method"`
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+	More       bool            `json:"more,omitempty"`
+	Oneway     bool            `json:"oneway,omitempty"`
+}
+
+// reply is a varlink reply. Parameters is always set, as an empty object if there are none.
+type reply struct {
+	Parameters any    `json:"parameters"`
+	Continues  bool   `json:"continues,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// varlinkError is an error returned to the varlink client.
+type varlinkError struct {
+	name       string
+	parameters any
+}
+
+func (e varlinkError) Error() string {
+	return e.name
+}
+
+// Errors of the org.varlink.service interface.
+var (
+	errExpectedMore = varlinkError{name: "org.varlink.service.ExpectedMore"}
+)
+
+func errMethodNotFound(method string) varlinkError {
+	return varlinkError{name: "org.varlink.service.MethodNotFound", parameters: map[string]string{"method": method}}
+}
+
+func errInvalidParameter(parameter string) varlinkError {
+	return varlinkError{name: "org.varlink.service.InvalidParameter", parameters: map[string]string{"parameter": parameter}}
+}
+
+// method handles a varlink method call and returns the parameters of each of its replies.
+type method func(ctx context.Context, parameters json.RawMessage) ([]any, error)
+
+// serveVarlink serves the methods on the listener until the returned function is called.
+func serveVarlink(ctx context.Context, lis net.Listener, methods map[string]method) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := lis.Accept()
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if err != nil {
+				log.Errorf(ctx, "Could not accept userdb connection: %v", err)
+				return
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// Stopping the server closes the connections in progress.
+				stopClosing := context.AfterFunc(ctx, func() { _ = conn.Close() })
+				defer stopClosing()
+				defer conn.Close()
+
+				if err := handleConnection(ctx, conn, methods); err != nil && ctx.Err() == nil {
+					log.Warningf(ctx, "Could not handle userdb connection: %v", err)
+				}
+			}()
+		}
+	}()
+
+	return func() {
+		cancel()
+		_ = lis.Close()
+		wg.Wait()
+	}
+}
+
+// handleConnection handles the calls of a client, one after the other, until it closes the connection.
+func handleConnection(ctx context.Context, conn net.Conn, methods map[string]method) error {
+	r := bufio.NewReader(conn)
+	for {
+		// Each varlink message is a JSON object terminated by a NUL byte.
+		msg, err := r.ReadBytes(0)
+		if errors.Is(err, io.EOF) && len(msg) == 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var c call
+		if err := json.Unmarshal(msg[:len(msg)-1], &c); err != nil {
+			return fmt.Errorf("invalid varlink message: %w", err)
+		}
+
+		replies := handleCall(ctx, c, methods)
+		if c.Oneway {
+			continue
+		}
+		for _, rep := range replies {
+			data, err := json.Marshal(rep)
+			if err != nil {
+				return err
+			}
+			if _, err := conn.Write(append(data, 0)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handleCall returns the replies to the call.
+func handleCall(ctx context.Context, c call, methods map[string]method) []reply {
+	m, ok := methods[c.Method]
+	if !ok {
+		return errorReply(errMethodNotFound(c.Method))
+	}
+
+	params, err := m(ctx, c.Parameters)
+	if err != nil {
+		var vErr varlinkError
+		if !errors.As(err, &vErr) {
+			log.Errorf(ctx, "userdb: %s: %v", c.Method, err)
+			vErr = errServiceNotAvailable
+		}
+		return errorReply(vErr)
+	}
+	if len(params) > 1 && !c.More {
+		return errorReply(errExpectedMore)
+	}
+
+	replies := make([]reply, 0, len(params))
+	for i, p := range params {
+		replies = append(replies, reply{Parameters: p, Continues: i < len(params)-1})
+	}
+	return replies
+}
+
+func errorReply(err varlinkError) []reply {
+	params := err.parameters
+	if params == nil {
+		params = struct{}{}
+	}
+	return []reply{{Parameters: params, Error: err.name}}
+}
@@ -0,0 +1,136 @@
+// Package sessionhooks reports the start and the stop of the sessions of authd users and runs the hooks configured
+// for them, for example to attach a session recorder to the sessions of the members of some groups.
+package sessionhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+// hookTimeout is the maximum duration of a hook. The PAM session is only opened or closed once all the hooks
+// returned.
+var hookTimeout = 10 * time.Second
+
+// Hook is a program run when a session of a user matching Groups starts or stops.
+type Hook struct {
+	// Groups are the groups whose members trigger the hook. If empty, the hook is run for all authd users.
+	Groups []string `mapstructure:"groups" yaml:"groups,omitempty"`
+	// Exec is the path of the program to run. It is called with the event type ("start" or "stop") as argument,
+	// the event as JSON on its standard input and the event fields in AUTHD_SESSION_* environment variables.
+	Exec string `mapstructure:"exec" yaml:"exec"`
+}
+
+// Config holds the hooks run on session events.
+type Config struct {
+	Hooks []Hook `mapstructure:"session_hooks" yaml:"session_hooks,omitempty"`
+}
+
+// EventType is the type of a session event.
+type EventType string
+
+const (
+	// EventStart is sent when a session is opened.
+	EventStart EventType = "start"
+	// EventStop is sent when a session is closed.
+	EventStop EventType = "stop"
+)
+
+// Event describes the start or the stop of a session.
+type Event struct {
+	Type       EventType `json:"event"`
+	Time       time.Time `json:"time"`
+	Username   string    `json:"username"`
+	UID        uint32    `json:"uid"`
+	Groups     []string  `json:"groups"`
+	BrokerID   string    `json:"broker_id"`
+	Service    string    `json:"service,omitempty"`
+	TTY        string    `json:"tty,omitempty"`
+	RemoteHost string    `json:"remote_host,omitempty"`
+}
+
+// Summary returns a one line description of the event.
+func (e Event) Summary() string {
+	s := fmt.Sprintf("session %s for user %q (broker %q, service %q", e.Type, e.Username, e.BrokerID, e.Service)
+	if e.TTY != "" {
+		s += fmt.Sprintf(", tty %q", e.TTY)
+	}
+	if e.RemoteHost != "" {
+		s += fmt.Sprintf(", remote host %q", e.RemoteHost)
+	}
+	return s + ")"
+}
+
+// env returns the environment variables describing the event to the hooks.
+func (e Event) env() []string {
+	return []string{
+		"AUTHD_SESSION_EVENT=" + string(e.Type),
+		"AUTHD_SESSION_USER=" + e.Username,
+		"AUTHD_SESSION_UID=" + strconv.FormatUint(uint64(e.UID), 10),
+		"AUTHD_SESSION_GROUPS=" + strings.Join(e.Groups, ","),
+		"AUTHD_SESSION_BROKER=" + e.BrokerID,
+		"AUTHD_SESSION_SERVICE=" + e.Service,
+		"AUTHD_SESSION_TTY=" + e.TTY,
+		"AUTHD_SESSION_RHOST=" + e.RemoteHost,
+	}
+}
+
+// Runner reports session events and runs the matching hooks.
+type Runner struct {
+	cfg Config
+}
+
+// New returns a new Runner running the hooks of cfg.
+func New(cfg Config) *Runner {
+	return &Runner{cfg: cfg}
+}
+
+// Run reports the event and runs the hooks matching the groups of the user, one after the other. Failing hooks
+// are only logged, as they must not prevent the user from using the session.
+func (r *Runner) Run(ctx context.Context, e Event) {
+	log.Noticef(ctx, "Session event: %s", e.Summary())
+
+	for _, h := range r.cfg.Hooks {
+		if !h.matches(e.Groups) {
+			continue
+		}
+		if err := runHook(ctx, h.Exec, e); err != nil {
+			log.Warningf(ctx, "Session hook %q failed for %s: %v", h.Exec, e.Summary(), err)
+		}
+	}
+}
+
+// matches returns true if the hook must be run for a member of groups.
+func (h Hook) matches(groups []string) bool {
+	if len(h.Groups) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(h.Groups, func(g string) bool { return slices.Contains(groups, g) })
+}
+
+func runHook(ctx context.Context, path string, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), hookTimeout)
+	defer cancel()
+
+	// #nosec:G204 - the hook path is set by the administrator in the configuration.
+	cmd := exec.CommandContext(ctx, path, string(e.Type))
+	cmd.Env = append(cmd.Environ(), e.env()...)
+	cmd.Stdin = bytes.NewReader(payload)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, out)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package sessionhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/sessionhooks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		hookGroups []string
+		userGroups []string
+		failing    bool
+
+		wantRun bool
+	}{
+		"Run_hook_without_groups_for_all_users":  {userGroups: []string{"employees"}, wantRun: true},
+		"Run_hook_for_members_of_its_groups":     {hookGroups: []string{"admins", "contractors"}, userGroups: []string{"contractors"}, wantRun: true},
+		"Failing_hook_does_not_stop_other_hooks": {failing: true, wantRun: true},
+
+		"Do_not_run_hook_for_other_users": {hookGroups: []string{"contractors"}, userGroups: []string{"employees"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			outputPath := filepath.Join(dir, "event.json")
+			hookPath := filepath.Join(dir, "hook")
+			script := fmt.Sprintf("#!/bin/sh\ncat > %q\n", outputPath)
+			require.NoError(t, os.WriteFile(hookPath, []byte(script), 0700), "Setup: could not write hook")
+
+			hooks := []sessionhooks.Hook{{Groups: tc.hookGroups, Exec: hookPath}}
+			if tc.failing {
+				hooks = append([]sessionhooks.Hook{{Exec: "/bin/false"}}, hooks...)
+			}
+
+			e := sessionhooks.Event{
+				Type:     sessionhooks.EventStart,
+				Time:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				Username: "user1@example.com",
+				UID:      1111,
+				Groups:   tc.userGroups,
+				BrokerID: "broker-id",
+				Service:  "sshd",
+				TTY:      "pts/1",
+			}
+			sessionhooks.New(sessionhooks.Config{Hooks: hooks}).Run(context.Background(), e)
+
+			if !tc.wantRun {
+				require.NoFileExists(t, outputPath, "Hook should not have been run")
+				return
+			}
+			data, err := os.ReadFile(outputPath)
+			require.NoError(t, err, "Hook should have been run")
+			var got sessionhooks.Event
+			require.NoError(t, json.Unmarshal(data, &got), "Hook should receive the event as JSON")
+			require.Equal(t, e, got, "Hook should receive the event")
+		})
+	}
+}
+
+func TestSummary(t *testing.T) {
+	t.Parallel()
+
+	e := sessionhooks.Event{
+		Type:       sessionhooks.EventStop,
+		Username:   "user1@example.com",
+		BrokerID:   "broker-id",
+		Service:    "sshd",
+		TTY:        "pts/1",
+		RemoteHost: "192.0.2.1",
+	}
+	require.Equal(t, `session stop for user "user1@example.com" (broker "broker-id", service "sshd", tty "pts/1", remote host "192.0.2.1")`,
+		e.Summary(), "Unexpected summary")
+}
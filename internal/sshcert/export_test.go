@@ -0,0 +1,14 @@
+package sshcert
+
+import "net/http"
+
+// NewWithClient is like New, but the certificates are requested to the external certificate authority with the given
+// HTTP client.
+func NewWithClient(cfg Config, client *http.Client) *Issuer {
+	i := New(cfg)
+	i.client = client
+	return i
+}
+
+// SignRequest is the request sent to the external certificate authority.
+type SignRequest = signRequest
@@ -0,0 +1,230 @@
+// Package sshcert issues short-lived SSH user certificates to the users once they authenticated with their identity
+// provider, so that they can log in over SSH on the other machines of the fleet trusting the same certificate
+// authority without authenticating again.
+package sshcert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+const (
+	// keyName is the name of the key of the user in their directory. ssh loads the certificate of an identity from
+	// the file with the same name followed by "-cert.pub".
+	keyName = "id_ed25519"
+	// certType is the type of the certificates of the Ed25519 keys.
+	certType = "ssh-ed25519-cert-v01@openssh.com"
+	// maxResponseSize is the maximum size of the response of the certificate authority.
+	maxResponseSize = 64 << 10
+	// clockSkew is how long before its issuance a certificate is valid, so that it can be used right away on the
+	// machines whose clock is slightly behind.
+	clockSkew   = time.Minute
+	signTimeout = 30 * time.Second
+)
+
+// Config holds how the SSH certificates of the users are issued.
+type Config struct {
+	// CAKey is the path of the private key of the certificate authority signing the certificates on the machine.
+	CAKey string `mapstructure:"ssh_cert_ca_key" yaml:"ssh_cert_ca_key,omitempty"`
+	// CAURL is the HTTPS URL of an external certificate authority signing the certificates, used instead of CAKey.
+	CAURL string `mapstructure:"ssh_cert_ca_url" yaml:"ssh_cert_ca_url,omitempty"`
+	// CATokenFile is the path of a file containing the bearer token sent to the external certificate authority.
+	CATokenFile string `mapstructure:"ssh_cert_ca_token_file" yaml:"ssh_cert_ca_token_file,omitempty"`
+	// Validity is how long the certificates are valid after they are issued.
+	Validity time.Duration `mapstructure:"ssh_cert_validity" yaml:"ssh_cert_validity,omitempty"`
+	// Dir is the directory in which the key and the certificate of each user are written, in a subdirectory named
+	// after their UID.
+	Dir string `mapstructure:"ssh_cert_dir" yaml:"ssh_cert_dir,omitempty"`
+}
+
+// DefaultConfig is the default configuration of the SSH certificates, which are not issued.
+var DefaultConfig = Config{
+	Validity: time.Hour,
+	Dir:      "/run/authd/ssh",
+}
+
+// Validate returns an error if the configuration is invalid.
+func (c Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	if c.CAKey != "" && c.CAURL != "" {
+		return errors.New("ssh_cert_ca_key and ssh_cert_ca_url can't be used together")
+	}
+	if c.CAURL != "" {
+		u, err := url.Parse(c.CAURL)
+		if err != nil {
+			return fmt.Errorf("invalid ssh_cert_ca_url: %w", err)
+		}
+		if u.Scheme != "https" {
+			return fmt.Errorf("invalid ssh_cert_ca_url %q: the certificate authority must be reached over HTTPS", c.CAURL)
+		}
+	}
+	if c.Validity <= 0 {
+		return errors.New("ssh_cert_validity must be positive")
+	}
+	if !filepath.IsAbs(c.Dir) {
+		return fmt.Errorf("invalid ssh_cert_dir %q: must be an absolute path", c.Dir)
+	}
+	return nil
+}
+
+// Enabled returns true if the certificates are issued.
+func (c Config) Enabled() bool {
+	return c.CAKey != "" || c.CAURL != ""
+}
+
+// signRequest is the request sent to the external certificate authority.
+type signRequest struct {
+	PublicKey  string   `json:"public_key"`
+	KeyID      string   `json:"key_id"`
+	Principals []string `json:"principals"`
+	// Validity is in seconds.
+	Validity int64 `json:"validity"`
+}
+
+// signResponse is the response of the external certificate authority.
+type signResponse struct {
+	Certificate string `json:"certificate"`
+}
+
+// Issuer issues the SSH certificates of the users.
+type Issuer struct {
+	cfg    Config
+	client *http.Client
+}
+
+// New returns a new Issuer issuing the certificates as configured in cfg.
+func New(cfg Config) *Issuer {
+	return &Issuer{cfg: cfg, client: &http.Client{Timeout: signTimeout}}
+}
+
+// Issue generates a new key for the user and has it signed for their user name, replacing the previous one. The key
+// and the certificate are owned by the user, in a directory of root named after their UID, so that the user can't
+// make authd write elsewhere.
+func (i *Issuer) Issue(ctx context.Context, username string, uid, gid uint32) error {
+	if !i.cfg.Enabled() {
+		return nil
+	}
+
+	userDir := filepath.Join(i.cfg.Dir, strconv.FormatUint(uint64(uid), 10))
+	if err := os.MkdirAll(userDir, 0755); err != nil {
+		return fmt.Errorf("could not create SSH certificate directory of user %q: %w", username, err)
+	}
+	// The files are generated next to their destination, so that they can be moved there.
+	tmpDir, err := os.MkdirTemp(i.cfg.Dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	keyPath := filepath.Join(tmpDir, keyName)
+	keyID := "authd:" + username
+	// #nosec:G204 - the arguments are not controlled by the user, the user name is only used as the comment.
+	if out, err := exec.CommandContext(ctx, "ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", keyID, "-f", keyPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("could not generate SSH key of user %q: %w: %s", username, err, out)
+	}
+	publicKey, err := os.ReadFile(keyPath + ".pub")
+	if err != nil {
+		return err
+	}
+
+	var cert []byte
+	if i.cfg.CAURL != "" {
+		cert, err = i.signRemotely(ctx, username, keyID, publicKey)
+	} else {
+		cert, err = i.signLocally(ctx, username, keyID, keyPath)
+	}
+	if err != nil {
+		return fmt.Errorf("could not sign SSH key of user %q: %w", username, err)
+	}
+	if err := os.WriteFile(keyPath+"-cert.pub", cert, 0600); err != nil {
+		return err
+	}
+
+	// The certificate is written last, so that ssh never uses a new certificate with the previous key.
+	for _, name := range []string{keyName, keyName + ".pub", keyName + "-cert.pub"} {
+		src := filepath.Join(tmpDir, name)
+		if err := os.Chown(src, int(uid), int(gid)); err != nil {
+			return fmt.Errorf("could not write SSH certificate of user %q: %w", username, err)
+		}
+		if err := os.Rename(src, filepath.Join(userDir, name)); err != nil {
+			return fmt.Errorf("could not write SSH certificate of user %q: %w", username, err)
+		}
+	}
+
+	log.Noticef(ctx, "Issued SSH certificate for user %q valid for %s", username, i.cfg.Validity)
+	return nil
+}
+
+// signLocally signs the key with the private key of the certificate authority.
+func (i *Issuer) signLocally(ctx context.Context, username, keyID, keyPath string) ([]byte, error) {
+	validity := fmt.Sprintf("-%ds:+%ds", int64(clockSkew.Seconds()), int64(i.cfg.Validity.Seconds()))
+	// #nosec:G204 - the key of the certificate authority is set by the administrator in the configuration.
+	cmd := exec.CommandContext(ctx, "ssh-keygen", "-q", "-s", i.cfg.CAKey, "-I", keyID, "-n", username, "-V", validity, keyPath+".pub")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, out)
+	}
+	return os.ReadFile(keyPath + "-cert.pub")
+}
+
+// signRemotely sends the public key to the external certificate authority, which returns the certificate.
+func (i *Issuer) signRemotely(ctx context.Context, username, keyID string, publicKey []byte) ([]byte, error) {
+	payload, err := json.Marshal(signRequest{
+		PublicKey:  strings.TrimSpace(string(publicKey)),
+		KeyID:      keyID,
+		Principals: []string{username},
+		Validity:   int64(i.cfg.Validity.Seconds()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.cfg.CAURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if i.cfg.CATokenFile != "" {
+		token, err := os.ReadFile(i.cfg.CATokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read ssh_cert_ca_token_file: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+
+	var r signResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&r); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	cert := strings.TrimSpace(r.Certificate)
+	if !strings.HasPrefix(cert, certType+" ") || strings.ContainsAny(cert, "\r\n") {
+		return nil, fmt.Errorf("invalid certificate %q", cert)
+	}
+	return []byte(cert + "\n"), nil
+}
@@ -0,0 +1,171 @@
+package sshcert_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/sshcert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	withCAKey := sshcert.DefaultConfig
+	withCAKey.CAKey = "/etc/authd/ssh_ca"
+	withCAURL := sshcert.DefaultConfig
+	withCAURL.CAURL = "https://ca.example.com/sign"
+
+	tests := map[string]struct {
+		cfg sshcert.Config
+
+		wantErr bool
+	}{
+		"Default_configuration": {cfg: sshcert.DefaultConfig},
+		"Sign_with_local_key":   {cfg: withCAKey},
+		"Sign_with_external_ca": {cfg: withCAURL},
+
+		"Error_when_both_key_and_url_are_set": {cfg: sshcert.Config{CAKey: "/etc/authd/ssh_ca", CAURL: "https://ca.example.com", Validity: time.Hour, Dir: "/run/authd/ssh"}, wantErr: true},
+		"Error_when_url_is_not_https":         {cfg: sshcert.Config{CAURL: "http://ca.example.com", Validity: time.Hour, Dir: "/run/authd/ssh"}, wantErr: true},
+		"Error_when_validity_is_not_positive": {cfg: sshcert.Config{CAKey: "/etc/authd/ssh_ca", Dir: "/run/authd/ssh"}, wantErr: true},
+		"Error_when_dir_is_relative":          {cfg: sshcert.Config{CAKey: "/etc/authd/ssh_ca", Validity: time.Hour, Dir: "ssh"}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.cfg.Validate()
+			if tc.wantErr {
+				require.Error(t, err, "Validate should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "Validate should not return an error, but did")
+		})
+	}
+}
+
+func TestIssue(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is not available")
+	}
+
+	tests := map[string]struct {
+		remote      bool
+		caStatus    int
+		caCert      string
+		missingKey  bool
+		disabled    bool
+		withToken   bool
+		wantErr     bool
+		wantNoFiles bool
+	}{
+		"Issue_certificate_signed_with_local_key":   {},
+		"Issue_certificate_signed_by_external_ca":   {remote: true},
+		"Send_token_to_external_ca":                 {remote: true, withToken: true},
+		"Do_not_issue_certificate_when_not_enabled": {disabled: true, wantNoFiles: true},
+
+		"Error_when_local_key_does_not_exist":         {missingKey: true, wantErr: true},
+		"Error_when_external_ca_fails":                {remote: true, caStatus: http.StatusForbidden, wantErr: true},
+		"Error_when_external_ca_returns_invalid_cert": {remote: true, caCert: "ssh-ed25519 AAAA", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			caKey := filepath.Join(dir, "ca")
+			out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", caKey).CombinedOutput()
+			require.NoError(t, err, "Setup: could not generate CA key: %s", out)
+
+			cfg := sshcert.Config{Validity: time.Hour, Dir: filepath.Join(dir, "certs")}
+			client := http.DefaultClient
+			switch {
+			case tc.disabled:
+			case tc.remote:
+				tokenFile := filepath.Join(dir, "token")
+				require.NoError(t, os.WriteFile(tokenFile, []byte("secret\n"), 0600), "Setup: could not write token")
+				server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if tc.withToken && r.Header.Get("Authorization") != "Bearer secret" {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					if tc.caStatus != 0 {
+						w.WriteHeader(tc.caStatus)
+						return
+					}
+					var req sshcert.SignRequest
+					if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					cert := tc.caCert
+					if cert == "" {
+						cert = signWithKeygen(t, caKey, req.PublicKey, req.Principals[0])
+					}
+					_ = json.NewEncoder(w).Encode(map[string]string{"certificate": cert})
+				}))
+				t.Cleanup(server.Close)
+				cfg.CAURL = server.URL
+				if tc.withToken {
+					cfg.CATokenFile = tokenFile
+				}
+				client = server.Client()
+			case tc.missingKey:
+				cfg.CAKey = filepath.Join(dir, "does-not-exist")
+			default:
+				cfg.CAKey = caKey
+			}
+
+			uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+			err = sshcert.NewWithClient(cfg, client).Issue(context.Background(), "user1@example.com", uid, gid)
+			if tc.wantErr {
+				require.Error(t, err, "Issue should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "Issue should not return an error, but did")
+
+			userDir := filepath.Join(cfg.Dir, strconv.FormatUint(uint64(uid), 10))
+			if tc.wantNoFiles {
+				require.NoDirExists(t, userDir, "No certificate should have been issued")
+				return
+			}
+
+			info, err := os.Stat(filepath.Join(userDir, "id_ed25519"))
+			require.NoError(t, err, "The private key should have been written")
+			require.Equal(t, os.FileMode(0600), info.Mode().Perm(), "The private key should only be readable by the user")
+
+			out, err = exec.Command("ssh-keygen", "-L", "-f", filepath.Join(userDir, "id_ed25519-cert.pub")).CombinedOutput()
+			require.NoError(t, err, "The certificate should be valid: %s", out)
+			require.Contains(t, string(out), "user1@example.com", "The certificate should be issued for the user")
+
+			entries, err := os.ReadDir(cfg.Dir)
+			require.NoError(t, err, "Could not read the certificate directory")
+			require.Len(t, entries, 1, "The temporary files should have been removed")
+		})
+	}
+}
+
+func signWithKeygen(t *testing.T, caKey, publicKey, principal string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key.pub")
+	require.NoError(t, os.WriteFile(keyPath, []byte(publicKey+"\n"), 0600), "Setup: could not write public key")
+	out, err := exec.Command("ssh-keygen", "-q", "-s", caKey, "-I", "test", "-n", principal, "-V", "+1h", keyPath).CombinedOutput()
+	require.NoError(t, err, "Setup: could not sign public key: %s", out)
+
+	cert, err := os.ReadFile(filepath.Join(dir, "key-cert.pub"))
+	require.NoError(t, err, "Setup: could not read certificate")
+	return strings.TrimSpace(string(cert))
+}
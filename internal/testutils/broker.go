@@ -3,6 +3,8 @@ package testutils
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -18,11 +20,12 @@ import (
 )
 
 const (
-	latestAPIVersion = 3
+	latestAPIVersion = 4
 
-	dbusInterface = "com.ubuntu.authd.Broker"
-	objectPathFmt = "/com/ubuntu/authd/%s"
-	nameFmt       = "com.ubuntu.authd.%s"
+	dbusInterface        = "com.ubuntu.authd.Broker"
+	dbusControlInterface = "com.ubuntu.authd.BrokerControl"
+	objectPathFmt        = "/com/ubuntu/authd/%s"
+	nameFmt              = "com.ubuntu.authd.%s"
 
 	// IDSeparator is the value used to append values to the sessionID in the broker mock.
 	IDSeparator = "_separator_"
@@ -60,6 +63,11 @@ type BrokerBusMock struct {
 	isAuthenticatedCallsMu sync.RWMutex
 }
 
+// brokerControlMock is the D-Bus object that will answer calls on the control interface of the broker mock.
+type brokerControlMock struct {
+	name string
+}
+
 // StartBusBrokerMock starts the D-Bus service and exports it on the system bus.
 // It returns the configuration file path for the exported broker.
 func StartBusBrokerMock(cfgDir string, brokerName string) (string, func(), error) {
@@ -82,6 +90,12 @@ func StartBusBrokerMock(cfgDir string, brokerName string) (string, func(), error
 		return "", nil, err
 	}
 
+	control := brokerControlMock{name: brokerName}
+	if err = conn.Export(&control, dbus.ObjectPath(busObjectPath), dbusControlInterface); err != nil {
+		conn.Close()
+		return "", nil, err
+	}
+
 	err = conn.Export(introspect.NewIntrospectable(&introspect.Node{
 		Name: busObjectPath,
 		Interfaces: []introspect.Interface{
@@ -90,6 +104,10 @@ func StartBusBrokerMock(cfgDir string, brokerName string) (string, func(), error
 				Name:    fmt.Sprintf("%s%d", dbusInterface, latestAPIVersion),
 				Methods: introspect.Methods(&bus),
 			},
+			{
+				Name:    dbusControlInterface,
+				Methods: introspect.Methods(&control),
+			},
 		},
 	}), dbus.ObjectPath(busObjectPath), introspect.IntrospectData.Name)
 	if err != nil {
@@ -151,7 +169,7 @@ func (b *BrokerBusMock) GetAuthenticationModes(sessionID string, supportedUILayo
 		return nil, nil
 	case "gam_error":
 		return nil, dbus.MakeFailedError(fmt.Errorf("broker %q: GetAuthenticationModes errored out", b.name))
-	case "gam_multiple_modes":
+	case "gam_multiple_modes", "last_auth_mode":
 		return []map[string]string{
 			{layouts.ID: "mode1", layouts.Label: "Mode 1"},
 			{layouts.ID: "mode2", layouts.Label: "Mode 2"},
@@ -167,7 +185,7 @@ func (b *BrokerBusMock) GetAuthenticationModes(sessionID string, supportedUILayo
 func (b *BrokerBusMock) SelectAuthenticationMode(sessionID, authenticationModeName string) (uiLayoutInfo map[string]string, dbusErr *dbus.Error) {
 	sessionID = parseSessionID(sessionID)
 	switch sessionID {
-	case "sam_success_required_entry":
+	case "sam_success_required_entry", "last_auth_mode":
 		return map[string]string{
 			layouts.Type:  "required-entry",
 			layouts.Entry: "entry_type",
@@ -322,6 +340,13 @@ func (b *BrokerBusMock) IsAuthenticated(sessionID, authenticationData string) (a
 		access = authGranted
 		data = fmt.Sprintf(`{"userinfo": %s, "message": "Offline login is enabled with your Entra password"}`, userInfoFromName(sessionID, nil))
 
+	case "ia_granted_offline":
+		access = authGranted
+		data = fmt.Sprintf(`{"userinfo": %s, "offline": true}`, userInfoFromName(sessionID, nil))
+
+	case "ia_invalid_offline":
+		data = fmt.Sprintf(`{"userinfo": %s, "offline": "yes"}`, userInfoFromName(sessionID, nil))
+
 	case "ia_granted_with_non_string_message":
 		access = authGranted
 		data = fmt.Sprintf(`{"userinfo": %s, "message": 42}`, userInfoFromName(sessionID, nil))
@@ -341,8 +366,11 @@ func (b *BrokerBusMock) IsAuthenticated(sessionID, authenticationData string) (a
 // EndSession returns default values to be used in tests or an error if requested.
 func (b *BrokerBusMock) EndSession(sessionID string) (dbusErr *dbus.Error) {
 	sessionID = parseSessionID(sessionID)
-	if sessionID == "es_error" {
+	switch sessionID {
+	case "es_error":
 		return dbus.MakeFailedError(fmt.Errorf("broker %q: EndSession errored out", b.name))
+	case "es_timeout":
+		time.Sleep(3 * time.Second)
 	}
 	return nil
 }
@@ -375,6 +403,115 @@ func (b *BrokerBusMock) DeleteUser(username, providerID string) (dbusErr *dbus.E
 	return nil
 }
 
+// VerifyUser returns the status of the user account depending on its name, or an error if requested.
+func (b *BrokerBusMock) VerifyUser(username, providerID string) (userStatus, data string, dbusErr *dbus.Error) {
+	switch {
+	case strings.Contains(username, "verify_error"):
+		return "", "", dbus.MakeFailedError(fmt.Errorf("broker %q: VerifyUser errored out", b.name))
+	case strings.Contains(username, "verify_disabled"):
+		return "disabled", `{"message": "The account is disabled at the provider"}`, nil
+	case strings.Contains(username, "verify_unknown"):
+		return "unknown", `{"message": "The provider can't be reached"}`, nil
+	case strings.Contains(username, "verify_invalid_status"):
+		return "invalid", "{}", nil
+	case strings.Contains(username, "verify_invalid_data"):
+		return "active", "not json", nil
+	}
+	return "active", fmt.Sprintf(`{"userinfo": %s}`, userInfoFromName(username, nil)), nil
+}
+
+// SessionToken returns an access token of the user for the scopes, or an error depending on the user name.
+func (c *brokerControlMock) SessionToken(username string, scopes []string) (sessionToken string, dbusErr *dbus.Error) {
+	switch {
+	case strings.Contains(username, "token_error"):
+		return "", dbus.MakeFailedError(fmt.Errorf("broker %q: SessionToken errored out", c.name))
+	case strings.Contains(username, "token_invalid"):
+		return "not json", nil
+	}
+	token, err := json.Marshal(map[string]any{
+		"access_token": "access-token-" + username,
+		"token_type":   "Bearer",
+		"expiry":       "2030-01-01T00:00:00Z",
+		"scopes":       scopes,
+	})
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return string(token), nil
+}
+
+// TokenStatus returns the status of the token cached for the user, or an error depending on the user name.
+func (c *brokerControlMock) TokenStatus(username string) (tokenStatus string, dbusErr *dbus.Error) {
+	switch {
+	case strings.Contains(username, "token_error"):
+		return "", dbus.MakeFailedError(fmt.Errorf("broker %q: TokenStatus errored out", c.name))
+	case strings.Contains(username, "token_invalid"):
+		return "not json", nil
+	}
+	s, err := json.Marshal(map[string]any{
+		"username":           username,
+		"expiry":             "2030-01-01T00:00:00Z",
+		"has_refresh_token":  !strings.Contains(username, "no_refresh_token"),
+		"has_local_password": true,
+		"user_is_disabled":   false,
+	})
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return string(s), nil
+}
+
+// RefreshToken returns the status of the refreshed token of the user, or an error depending on the user name.
+func (c *brokerControlMock) RefreshToken(username string) (tokenStatus string, dbusErr *dbus.Error) {
+	switch {
+	case strings.Contains(username, "refresh_error"):
+		return "", dbus.MakeFailedError(fmt.Errorf("broker %q: RefreshToken errored out", c.name))
+	case strings.Contains(username, "refresh_invalid"):
+		return "not json", nil
+	}
+	s, err := json.Marshal(map[string]any{
+		"username":           username,
+		"expiry":             "2031-01-01T00:00:00Z",
+		"has_refresh_token":  true,
+		"has_local_password": true,
+		"user_is_disabled":   false,
+	})
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return string(s), nil
+}
+
+// CheckProvider returns the checks of the provider, or an error depending on the broker name.
+func (c *brokerControlMock) CheckProvider() (checks string, dbusErr *dbus.Error) {
+	switch {
+	case strings.Contains(c.name, "provider_check_error"):
+		return "", dbus.MakeFailedError(fmt.Errorf("broker %q: CheckProvider errored out", c.name))
+	case strings.Contains(c.name, "provider_check_invalid"):
+		return "not json", nil
+	}
+	deviceCode := map[string]string{"name": "device_code", "status": "ok", "details": "got a device code"}
+	if strings.Contains(c.name, "provider_check_failed") {
+		deviceCode = map[string]string{"name": "device_code", "status": "failed", "details": "no device code was issued"}
+	}
+	s, err := json.Marshal([]map[string]string{
+		{"name": "discovery", "status": "ok", "details": "fetched the discovery document"},
+		deviceCode,
+	})
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return string(s), nil
+}
+
+// SetSessionHints accepts the hints of the session, or returns an error if requested.
+func (c *brokerControlMock) SetSessionHints(sessionID string, hints map[string]string) (dbusErr *dbus.Error) {
+	if hints["timezone"] == "hints_error" {
+		return dbus.MakeFailedError(fmt.Errorf("broker %q: SetSessionHints errored out", c.name))
+	}
+	return nil
+}
+
 // parseSessionID is wrapper around the sessionID to remove some values appended during the tests.
 //
 // The sessionID can have multiple values appended to differentiate between subtests and avoid concurrency conflicts,
@@ -405,6 +542,9 @@ func userInfoFromName(sessionID string, extraGroups []groupJSONInfo) string {
 	shell := "/bin/sh/" + name
 	gecos := "gecos for " + name
 	ugid := "ugid-" + name
+	var env map[string]string
+	var sshKeys []string
+	var picture []byte
 
 	switch parsedID {
 	case "ia_info_empty_user_name":
@@ -423,6 +563,20 @@ func userInfoFromName(sessionID string, extraGroups []groupJSONInfo) string {
 		home = "this is not a homedir"
 	case "ia_info_invalid_shell":
 		shell = "this is not a valid shell"
+	case "ia_info_with_env":
+		env = map[string]string{"EMPLOYEE_ID": "1234", "IDP_TENANT": "tenant for " + name}
+	case "ia_info_invalid_env_name":
+		env = map[string]string{"NOT-VALID": "value"}
+	case "ia_info_reserved_env":
+		env = map[string]string{"LD_PRELOAD": "/tmp/library.so"}
+	case "ia_info_with_ssh_keys":
+		sshKeys = []string{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAICAjYInYNym6SDqrfs4E1smDBXnnCmcKOISOubs679Cy key of " + name}
+	case "ia_info_ssh_key_with_options":
+		sshKeys = []string{`command="/bin/sh" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAICAjYInYNym6SDqrfs4E1smDBXnnCmcKOISOubs679Cy`}
+	case "ia_info_with_picture":
+		picture = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR picture of " + name)
+	case "ia_info_picture_of_unsupported_type":
+		picture = []byte("GIF89a\x01\x00\x01\x00")
 	case "local-pre-check":
 		name = "root"
 		home = "/root"
@@ -441,6 +595,19 @@ func userInfoFromName(sessionID string, extraGroups []groupJSONInfo) string {
 		groups = []groupJSONInfo{}
 	}
 
+	var envJSON string
+	if env != nil {
+		// only used for tests, marshalling a map of strings can't fail.
+		b, _ := json.Marshal(env)
+		envJSON = string(b)
+	}
+	var sshKeysJSON string
+	if sshKeys != nil {
+		// only used for tests, marshalling a slice of strings can't fail.
+		b, _ := json.Marshal(sshKeys)
+		sshKeysJSON = string(b)
+	}
+
 	user := struct {
 		Name       string
 		ProviderID string
@@ -448,7 +615,11 @@ func userInfoFromName(sessionID string, extraGroups []groupJSONInfo) string {
 		Shell      string
 		Groups     []groupJSONInfo
 		Gecos      string
-	}{Name: name, ProviderID: "providerid-" + name, Dir: home, Shell: shell, Groups: groups, Gecos: gecos}
+		Env        string
+		SSHKeys    string
+		Picture    string
+	}{Name: name, ProviderID: "providerid-" + name, Dir: home, Shell: shell, Groups: groups, Gecos: gecos, Env: envJSON, SSHKeys: sshKeysJSON,
+		Picture: base64.StdEncoding.EncodeToString(picture)}
 
 	// only used for tests, we can ignore the template execution error as the returned data will be failing.
 	var buf bytes.Buffer
@@ -462,7 +633,10 @@ func userInfoFromName(sessionID string, extraGroups []groupJSONInfo) string {
 		"groups": [ {{range $index, $g := .Groups}}
 			{{- if $index}}, {{end -}}
 			{"name": "{{.Name}}", "ugid": "{{.UGID}}"}
-		{{- end}} ]
+		{{- end}} ]{{if .Env}},
+		"env": {{.Env}}{{end}}{{if .SSHKeys}},
+		"ssh_keys": {{.SSHKeys}}{{end}}{{if .Picture}},
+		"picture": "{{.Picture}}"{{end}}
 	}`)).Execute(&buf, user)
 
 	return buf.String()
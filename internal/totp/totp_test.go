@@ -0,0 +1,87 @@
+package totp_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/totp"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the secret of the SHA1 test vectors of RFC 6238.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+	t.Parallel()
+
+	// The test vectors of RFC 6238, truncated to 6 digits.
+	tests := map[string]struct {
+		time int64
+		want string
+	}{
+		"At_59":          {time: 59, want: "287082"},
+		"At_1111111109":  {time: 1111111109, want: "081804"},
+		"At_1111111111":  {time: 1111111111, want: "050471"},
+		"At_1234567890":  {time: 1234567890, want: "005924"},
+		"At_2000000000":  {time: 2000000000, want: "279037"},
+		"At_20000000000": {time: 20000000000, want: "353130"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := totp.Code(rfcSecret, totp.Step(time.Unix(tc.time, 0)))
+			require.Equal(t, tc.want, got, "Code should return the expected code")
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1111111111, 0)
+	step := totp.Step(now)
+
+	tests := map[string]struct {
+		code string
+
+		wantStep int64
+		wantOK   bool
+	}{
+		"Accept_the_current_code":       {code: totp.Code(rfcSecret, step), wantStep: step, wantOK: true},
+		"Accept_the_previous_code":      {code: totp.Code(rfcSecret, step-1), wantStep: step - 1, wantOK: true},
+		"Accept_the_next_code":          {code: totp.Code(rfcSecret, step+1), wantStep: step + 1, wantOK: true},
+		"Reject_an_older_code":          {code: totp.Code(rfcSecret, step-2)},
+		"Reject_a_later_code":           {code: totp.Code(rfcSecret, step+2)},
+		"Reject_a_code_of_another_size": {code: totp.Code(rfcSecret, step)[:5]},
+		"Reject_an_empty_code":          {code: ""},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			gotStep, gotOK := totp.Validate(rfcSecret, tc.code, now)
+			require.Equal(t, tc.wantOK, gotOK, "Validate should accept the expected codes")
+			require.Equal(t, tc.wantStep, gotStep, "Validate should return the time step of the code")
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	t.Parallel()
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err, "GenerateSecret should not return an error")
+	require.Len(t, secret, totp.SecretSize, "GenerateSecret should return a secret of the expected size")
+
+	u, err := url.Parse(totp.URI(rfcSecret, "authd", "user@example.com"))
+	require.NoError(t, err, "URI should return a valid URI")
+	require.Equal(t, "otpauth", u.Scheme, "URI should be an otpauth one")
+	require.Equal(t, "totp", u.Host, "URI should be for TOTP")
+	require.Equal(t, "/authd:user@example.com", u.Path, "URI should have the label of the account")
+	require.Equal(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", u.Query().Get("secret"), "URI should have the base32 secret")
+	require.Equal(t, "authd", u.Query().Get("issuer"), "URI should have the issuer")
+	require.Equal(t, "6", u.Query().Get("digits"), "URI should have the number of digits")
+	require.Equal(t, "30", u.Query().Get("period"), "URI should have the period")
+}
@@ -0,0 +1,110 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/canonical/authd/internal/users/tempentries"
+	"github.com/canonical/authd/log"
+)
+
+// IDRange is a range of UIDs and GIDs dedicated to the users and groups of a broker.
+type IDRange struct {
+	UIDMin uint32 `mapstructure:"uid_min" yaml:"uid_min"`
+	UIDMax uint32 `mapstructure:"uid_max" yaml:"uid_max"`
+	GIDMin uint32 `mapstructure:"gid_min" yaml:"gid_min"`
+	GIDMax uint32 `mapstructure:"gid_max" yaml:"gid_max"`
+}
+
+// WithBrokerIDs gives the IDs of the available brokers by name, which the ranges of broker_id_ranges are configured
+// with.
+func WithBrokerIDs(ids map[string]string) Option {
+	return func(o *options) {
+		o.brokerIDs = ids
+	}
+}
+
+// overlaps returns true if an ID can be in both ranges, as a UID or as a GID, since the UID of a user is also the GID
+// of their private group.
+func (r IDRange) overlaps(other IDRange) bool {
+	for _, a := range [][2]uint32{{r.UIDMin, r.UIDMax}, {r.GIDMin, r.GIDMax}} {
+		for _, b := range [][2]uint32{{other.UIDMin, other.UIDMax}, {other.GIDMin, other.GIDMax}} {
+			if rangesOverlap(a[0], a[1], b[0], b[1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateBrokerIDRanges checks that the ranges of the brokers are valid and that they don't overlap with each other
+// or with the default range, so that the users of different brokers can't get the same IDs.
+func (c Config) validateBrokerIDRanges() error {
+	defaultRange := IDRange{UIDMin: c.UIDMin, UIDMax: c.UIDMax, GIDMin: c.GIDMin, GIDMax: c.GIDMax}
+
+	names := slices.Sorted(maps.Keys(c.BrokerIDRanges))
+	for i, name := range names {
+		if name == "" {
+			return errors.New("invalid broker_id_ranges: empty broker name")
+		}
+
+		r := c.BrokerIDRanges[name]
+		if r.UIDMin >= r.UIDMax {
+			return fmt.Errorf("uid_min (%d) of broker %q must be less than uid_max (%d)", r.UIDMin, name, r.UIDMax)
+		}
+		if r.GIDMin >= r.GIDMax {
+			return fmt.Errorf("gid_min (%d) of broker %q must be less than gid_max (%d)", r.GIDMin, name, r.GIDMax)
+		}
+		if r.UIDMax > math.MaxInt32 || r.GIDMax > math.MaxInt32 {
+			return fmt.Errorf("uid_max and gid_max of broker %q must be less than or equal to %d", name, math.MaxInt32)
+		}
+		if r.overlaps(IDRange{UIDMin: systemdDynamicUIDMin, UIDMax: systemdDynamicUIDMax, GIDMin: systemdDynamicUIDMin, GIDMax: systemdDynamicUIDMax}) {
+			return fmt.Errorf("ID ranges of broker %q overlap with systemd dynamic service users range (%d-%d)", name, systemdDynamicUIDMin, systemdDynamicUIDMax)
+		}
+
+		// The users of the broker get a GID for each of their groups, as well as a UID.
+		minNumIDs := uint32(tempentries.MaxPreAuthUsers * 2)
+		if numUIDs := r.UIDMax - r.UIDMin + 1; numUIDs < minNumIDs {
+			return fmt.Errorf("UID range of broker %q is too small (%d), must be at least %d", name, numUIDs, minNumIDs)
+		}
+		if numGIDs := r.GIDMax - r.GIDMin + 1; numGIDs < minNumIDs {
+			return fmt.Errorf("GID range of broker %q is too small (%d), must be at least %d", name, numGIDs, minNumIDs)
+		}
+
+		if r.overlaps(defaultRange) {
+			return fmt.Errorf("ID ranges of broker %q overlap with the ranges of UID_MIN, UID_MAX, GID_MIN and GID_MAX", name)
+		}
+		for _, other := range names[:i] {
+			if r.overlaps(c.BrokerIDRanges[other]) {
+				return fmt.Errorf("ID ranges of brokers %q and %q overlap", other, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// brokerIDRanges returns the ranges of the brokers by broker ID.
+func (c Config) brokerIDRanges(brokerIDs map[string]string) map[string]IDRange {
+	// The keys of the configuration file are lowercased, so the names of the brokers are compared case-insensitively.
+	ids := make(map[string]string)
+	for name, id := range brokerIDs {
+		ids[strings.ToLower(name)] = id
+	}
+
+	ranges := make(map[string]IDRange)
+	for name, r := range c.BrokerIDRanges {
+		id, ok := ids[strings.ToLower(name)]
+		if !ok {
+			log.Warningf(context.Background(), "Ignoring the ID ranges of broker %q, which is not available", name)
+			continue
+		}
+		ranges[id] = r
+	}
+	return ranges
+}
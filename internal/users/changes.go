@@ -0,0 +1,147 @@
+package users
+
+import (
+	"context"
+	"slices"
+
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+// ChangeKind is the kind of change of a user or a group.
+type ChangeKind int
+
+const (
+	// Added is the change of a user or group which was created.
+	Added ChangeKind = iota
+	// Updated is the change of a user or group whose information or members changed.
+	Updated
+	// Disabled is the change of a user which can't log in anymore, either locked or deprovisioned.
+	Disabled
+	// Removed is the change of a user or group which was deleted.
+	Removed
+)
+
+// String returns the name of the change kind.
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Updated:
+		return "updated"
+	case Disabled:
+		return "disabled"
+	case Removed:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// ChangeNotifier is the interface that must be implemented to be notified of the changes of users and groups.
+type ChangeNotifier interface {
+	// UserChanged is called once the change of the user is stored.
+	UserChanged(kind ChangeKind, name string, uid uint32)
+	// GroupChanged is called once the change of the group is stored.
+	GroupChanged(kind ChangeKind, name string, gid uint32)
+}
+
+// WithChangeNotifier makes the manager notify the changes of users and groups. It can be given several times to
+// notify several notifiers.
+func WithChangeNotifier(n ChangeNotifier) Option {
+	return func(o *options) {
+		switch notifiers := o.changeNotifier.(type) {
+		case nil:
+			o.changeNotifier = n
+		case changeNotifiers:
+			o.changeNotifier = append(notifiers, n)
+		default:
+			o.changeNotifier = changeNotifiers{notifiers, n}
+		}
+	}
+}
+
+// changeNotifiers notifies the changes to all of its notifiers, in order.
+type changeNotifiers []ChangeNotifier
+
+func (ns changeNotifiers) UserChanged(kind ChangeKind, name string, uid uint32) {
+	for _, n := range ns {
+		n.UserChanged(kind, name, uid)
+	}
+}
+
+func (ns changeNotifiers) GroupChanged(kind ChangeKind, name string, gid uint32) {
+	for _, n := range ns {
+		n.GroupChanged(kind, name, gid)
+	}
+}
+
+func (m *Manager) notifyUserChanged(kind ChangeKind, name string, uid uint32) {
+	if m.changeNotifier == nil {
+		return
+	}
+	m.changeNotifier.UserChanged(kind, name, uid)
+}
+
+func (m *Manager) notifyGroupChanged(kind ChangeKind, name string, gid uint32) {
+	if m.changeNotifier == nil {
+		return
+	}
+	m.changeNotifier.GroupChanged(kind, name, gid)
+}
+
+// notifyUserChangedByName notifies the change of the user with the given name, which is still in the database.
+func (m *Manager) notifyUserChangedByName(kind ChangeKind, name string) {
+	if m.changeNotifier == nil {
+		return
+	}
+	u, err := m.db.UserByName(name)
+	if err != nil {
+		log.Warningf(context.Background(), "Could not notify the change of user %q: %v", name, err)
+		return
+	}
+	m.changeNotifier.UserChanged(kind, name, u.UID)
+}
+
+// notifyUserUpdated notifies the changes stored by UpdateUser: the user itself, the groups which were created for them
+// and the groups they joined or left.
+func (m *Manager) notifyUserUpdated(oldUserInfo *types.UserInfo, u types.UserInfo, groupRows []db.GroupRow, createdGIDs []uint32) {
+	if m.changeNotifier == nil {
+		return
+	}
+
+	if oldUserInfo == nil {
+		m.changeNotifier.UserChanged(Added, u.Name, u.UID)
+	} else {
+		m.changeNotifier.UserChanged(Updated, u.Name, u.UID)
+	}
+
+	// The names of the groups of the user before the update, by GID. Local groups are not managed by authd.
+	oldGroups := make(map[uint32]string)
+	renamed := false
+	if oldUserInfo != nil {
+		for _, g := range oldUserInfo.Groups {
+			if g.GID != nil {
+				oldGroups[*g.GID] = g.Name
+			}
+		}
+		renamed = oldUserInfo.Name != u.Name
+	}
+
+	for _, g := range groupRows {
+		oldName, wasMember := oldGroups[g.GID]
+		delete(oldGroups, g.GID)
+
+		switch {
+		case slices.Contains(createdGIDs, g.GID):
+			m.changeNotifier.GroupChanged(Added, g.Name, g.GID)
+		case !wasMember || oldName != g.Name || renamed:
+			// The user joined the group, the group was renamed or the name of its member changed.
+			m.changeNotifier.GroupChanged(Updated, g.Name, g.GID)
+		}
+	}
+	// The user left the remaining groups.
+	for gid, name := range oldGroups {
+		m.changeNotifier.GroupChanged(Updated, name, gid)
+	}
+}
@@ -0,0 +1,124 @@
+package users_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	localgroupstestutils "github.com/canonical/authd/internal/users/localentries/testutils"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeNotifications(t *testing.T) {
+	// We don't care about the output of gpasswd in this test, but we still need to mock it.
+	_ = localgroupstestutils.SetupGroupMock(t, filepath.Join("testdata", "groups", "empty.group"))
+
+	dbDir := t.TempDir()
+	err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups.db.yaml"), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+
+	n := &changeRecorder{}
+	m, err := users.NewManager(users.DefaultConfig, dbDir, users.WithChangeNotifier(n))
+	require.NoError(t, err, "Setup: could not create manager")
+
+	newUser := types.UserInfo{
+		Name:       "newuser@example.com",
+		Dir:        "/home/newuser@example.com",
+		Shell:      "/bin/bash",
+		BrokerID:   "broker-id",
+		ProviderID: "providerid-newuser",
+		Groups:     []types.GroupInfo{{Name: "newgroup", UGID: "newgroup"}, {Name: "commongroup", UGID: "87654321"}},
+	}
+
+	// A new user, their private group and their new group are added, and the existing group they joined changed.
+	err = m.UpdateUser(newUser)
+	require.NoError(t, err, "UpdateUser should not return an error")
+	u, err := m.UserByName("newuser@example.com")
+	require.NoError(t, err, "Setup: could not get new user")
+	g, err := m.GroupByName("newgroup")
+	require.NoError(t, err, "Setup: could not get new group")
+	n.requireChanges(t,
+		fmt.Sprintf("user added newuser@example.com %d", u.UID),
+		fmt.Sprintf("group added newuser@example.com %d", u.UID),
+		fmt.Sprintf("group added newgroup %d", g.GID),
+		"group updated commongroup 99999",
+	)
+
+	// Logging in again without any change doesn't notify anything.
+	err = m.UpdateUser(newUser)
+	require.NoError(t, err, "UpdateUser should not return an error")
+	n.requireChanges(t)
+
+	// Leaving a group changes the user and the group.
+	newUser.Groups = newUser.Groups[:1]
+	newUser.Shell = "/bin/zsh"
+	err = m.UpdateUser(newUser)
+	require.NoError(t, err, "UpdateUser should not return an error")
+	n.requireChanges(t,
+		fmt.Sprintf("user updated newuser@example.com %d", u.UID),
+		"group updated commongroup 99999",
+	)
+
+	err = m.LockUser("user1@example.com")
+	require.NoError(t, err, "LockUser should not return an error")
+	n.requireChanges(t, "user disabled user1@example.com 1111")
+
+	err = m.UnlockUser("user1@example.com")
+	require.NoError(t, err, "UnlockUser should not return an error")
+	n.requireChanges(t, "user updated user1@example.com 1111")
+
+	err = m.DisableUser(context.Background(), "user3@example.com")
+	require.NoError(t, err, "DisableUser should not return an error")
+	n.requireChanges(t, "user disabled user3@example.com 3333")
+
+	err = m.RestoreUser(context.Background(), "user3@example.com")
+	require.NoError(t, err, "RestoreUser should not return an error")
+	n.requireChanges(t, "user updated user3@example.com 3333")
+
+	_, err = m.SetShell("user1@example.com", "/bin/sh")
+	require.NoError(t, err, "SetShell should not return an error")
+	n.requireChanges(t, "user updated user1@example.com 1111")
+
+	err = m.DeleteGroup("newgroup")
+	require.NoError(t, err, "DeleteGroup should not return an error")
+	n.requireChanges(t, fmt.Sprintf("group removed newgroup %d", g.GID))
+
+	// Deleting a user removes their private group and changes the groups they were member of.
+	err = m.DeleteUser("user2@example.com", false)
+	require.NoError(t, err, "DeleteUser should not return an error")
+	n.requireChanges(t,
+		"user removed user2@example.com 2222",
+		"group removed group2withoutugid 22222",
+		"group updated commongroup 99999",
+	)
+
+	// Failed changes are not notified.
+	err = m.LockUser("doesnotexist@example.com")
+	require.Error(t, err, "LockUser should return an error for a user which does not exist")
+	n.requireChanges(t)
+}
+
+// changeRecorder records the notified changes.
+type changeRecorder struct {
+	changes []string
+}
+
+func (r *changeRecorder) UserChanged(kind users.ChangeKind, name string, uid uint32) {
+	r.changes = append(r.changes, fmt.Sprintf("user %s %s %d", kind, name, uid))
+}
+
+func (r *changeRecorder) GroupChanged(kind users.ChangeKind, name string, gid uint32) {
+	r.changes = append(r.changes, fmt.Sprintf("group %s %s %d", kind, name, gid))
+}
+
+// requireChanges checks the changes notified since the last call, in any order.
+func (r *changeRecorder) requireChanges(t *testing.T, want ...string) {
+	t.Helper()
+
+	require.ElementsMatch(t, want, r.changes, "Notified changes are not the expected ones")
+	r.changes = nil
+}
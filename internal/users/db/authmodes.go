@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+// LastAuthModeRow represents the authentication mode a user selected on their last login in the database.
+type LastAuthModeRow struct {
+	UID      uint32 `yaml:"uid"`
+	BrokerID string `yaml:"broker_id"`
+	AuthMode string `yaml:"auth_mode"`
+}
+
+// SetLastAuthMode sets the broker and the authentication mode with which the user last logged in, replacing the
+// previous ones.
+func (m *Manager) SetLastAuthMode(uid uint32, brokerID, authMode string) error {
+	defer m.observe("SetLastAuthMode", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	log.Debugf(context.Background(), "Setting last authentication mode of UID %d to %q of broker %q", uid, authMode, brokerID)
+	query := `INSERT INTO auth_modes (uid, broker_id, auth_mode) VALUES (?, ?, ?)
+		ON CONFLICT(uid) DO UPDATE SET broker_id = excluded.broker_id, auth_mode = excluded.auth_mode`
+	if _, err := m.db.Exec(query, uid, brokerID, authMode); err != nil {
+		return fmt.Errorf("failed to set last authentication mode: %w", err)
+	}
+	return nil
+}
+
+// LastAuthMode returns the broker and the authentication mode with which the user last logged in. It returns a
+// NoDataFoundError if none was stored for the user.
+func (m *Manager) LastAuthMode(uid uint32) (LastAuthModeRow, error) {
+	defer m.observe("LastAuthMode", time.Now())
+
+	var r LastAuthModeRow
+	row := m.db.QueryRow(`SELECT uid, broker_id, auth_mode FROM auth_modes WHERE uid = ?`, uid)
+	err := row.Scan(&r.UID, &r.BrokerID, &r.AuthMode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return LastAuthModeRow{}, NoDataFoundError{fmt.Sprintf("no last authentication mode for UID %d", uid)}
+	}
+	if err != nil {
+		return LastAuthModeRow{}, fmt.Errorf("query error: %w", err)
+	}
+	return r, nil
+}
+
+func allLastAuthModes(db queryable) ([]LastAuthModeRow, error) {
+	rows, err := db.Query(`SELECT uid, broker_id, auth_mode FROM auth_modes ORDER BY uid`)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	var modeRows []LastAuthModeRow
+	for rows.Next() {
+		var r LastAuthModeRow
+		if err := rows.Scan(&r.UID, &r.BrokerID, &r.AuthMode); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		modeRows = append(modeRows, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return modeRows, nil
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/authd/internal/fileutils"
+	"github.com/canonical/authd/log"
+)
+
+// backupSuffix is the suffix of the backups of the database made before the schema migrations, after the schema
+// version of the backed up database.
+const backupSuffix = ".backup"
+
+// backupPath returns the path of the backup of the database with the given schema version.
+func (m *Manager) backupPath(version int) string {
+	return fmt.Sprintf("%s.v%d%s", m.path, version, backupSuffix)
+}
+
+// backUp copies the database, which has the given schema version, next to it. Only the latest backup is kept.
+func (m *Manager) backUp(version int) error {
+	path := m.backupPath(version)
+	log.Infof(context.Background(), "Backing up the database to %s", path)
+
+	// VACUUM INTO only writes to a new or empty file, which is created first so that it's only readable by root. A
+	// backup of the same version can be left over by a failed migration.
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := fileutils.Touch(path); err != nil {
+		return err
+	}
+	if _, err := m.db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return err
+	}
+
+	backups, err := m.backups()
+	if err != nil {
+		return err
+	}
+	for v, p := range backups {
+		if v == version {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			log.Warningf(context.Background(), "Could not remove old database backup: %v", err)
+		}
+	}
+	return nil
+}
+
+// latestBackup returns the path and the schema version of the most recent backup made before migrating the database
+// to the given schema version.
+func (m *Manager) latestBackup(version int) (path string, backupVersion int, err error) {
+	backups, err := m.backups()
+	if err != nil {
+		return "", 0, err
+	}
+
+	backupVersion = -1
+	for v, p := range backups {
+		if v < version && v > backupVersion {
+			path, backupVersion = p, v
+		}
+	}
+	if backupVersion < 0 {
+		return "", 0, fmt.Errorf("no backup of the database made before a schema migration to version %d", version)
+	}
+	return path, backupVersion, nil
+}
+
+// backups returns the paths of the backups of the database by schema version.
+func (m *Manager) backups() (map[int]string, error) {
+	paths, err := filepath.Glob(m.path + ".v*" + backupSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make(map[int]string)
+	for _, p := range paths {
+		v := strings.TrimSuffix(strings.TrimPrefix(p, m.path+".v"), backupSuffix)
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			continue
+		}
+		backups[version] = p
+	}
+	return backups, nil
+}
@@ -9,8 +9,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/canonical/authd/internal/consts"
 	"github.com/canonical/authd/internal/fileutils"
@@ -27,14 +30,25 @@ var (
 
 // Manager is an abstraction to interact with the database.
 type Manager struct {
-	db   *sql.DB
-	path string
+	db *sql.DB
+	// stmts holds the prepared statements of the frequent read queries.
+	stmts *statementCache
+	// readDB and reads run the NSS lookups. They are db and stmts, unless the lookups have their own read-only
+	// connections, see [WithReadConnections].
+	readDB *sql.DB
+	reads  *statementCache
+	path   string
+	// latency records the durations of the operations.
+	latency *latencyRecorder
 	// mu protects concurrent updates of the database. It's needed because some
 	// operations require temporarily disabling the foreign key constraints
 	// during which concurrent updates to the database could violate them.
 	mu sync.Mutex
 }
 
+// busyTimeout is how long the connections wait for the database to be unlocked by the connections of another process.
+const busyTimeout = 5 * time.Second
+
 // queryable is an interface to execute SQL queries. Both sql.DB and sql.Tx implement this interface.
 type queryable interface {
 	Exec(query string, args ...interface{}) (sql.Result, error)
@@ -42,8 +56,66 @@ type queryable interface {
 	Query(query string, args ...any) (*sql.Rows, error)
 }
 
+// DefaultSynchronous is the default synchronous level of the database. With FULL, a transaction is written to disk
+// before its commit returns, so it's not lost on power loss. See https://www.sqlite.org/pragma.html#pragma_synchronous.
+const DefaultSynchronous = "FULL"
+
+// synchronousLevels are the supported synchronous levels. OFF is not supported, as the database can be corrupted by
+// a power loss with it, even in WAL mode.
+var synchronousLevels = []string{"NORMAL", "FULL", "EXTRA"}
+
+type options struct {
+	synchronous        string
+	slowQueryThreshold time.Duration
+	readConnections    int
+}
+
+// Option is a function that allows changing some of the default behaviors of the manager.
+type Option func(*options)
+
+// WithSynchronous sets the synchronous level of the database, which is one of NORMAL, FULL and EXTRA.
+//
+// The database is always in WAL mode, so it can't be corrupted by a crash or a power loss with any of these levels,
+// but transactions committed with NORMAL can be lost on power loss.
+func WithSynchronous(level string) Option {
+	return func(o *options) {
+		o.synchronous = strings.ToUpper(level)
+	}
+}
+
+// WithSlowQueryThreshold sets the duration above which the database operations are logged as slow, 0 disabling the
+// log.
+func WithSlowQueryThreshold(threshold time.Duration) Option {
+	return func(o *options) {
+		o.slowQueryThreshold = threshold
+	}
+}
+
+// WithReadConnections makes the NSS lookups use up to n read-only connections of their own, instead of the
+// connection shared with the updates of the database. 0 disables them.
+//
+// The database is always in WAL mode, in which reading doesn't wait for a write transaction to finish. The lookups
+// made while users log in are therefore not queued behind the updates of the logins.
+func WithReadConnections(n int) Option {
+	return func(o *options) {
+		o.readConnections = n
+	}
+}
+
 // New creates a new database manager by creating or opening the underlying database.
-func New(dbDir string) (*Manager, error) {
+func New(dbDir string, args ...Option) (*Manager, error) {
+	opts := &options{synchronous: DefaultSynchronous, slowQueryThreshold: DefaultSlowQueryThreshold}
+	for _, arg := range args {
+		arg(opts)
+	}
+	if !slices.Contains(synchronousLevels, opts.synchronous) {
+		return nil, fmt.Errorf("invalid database synchronous level %q, must be one of %s", opts.synchronous,
+			strings.Join(synchronousLevels, ", "))
+	}
+	if opts.readConnections < 0 {
+		return nil, fmt.Errorf("invalid number of database read connections %d, must not be negative", opts.readConnections)
+	}
+
 	dbPath := filepath.Join(dbDir, consts.DefaultDatabaseFileName)
 
 	exists, err := fileutils.FileExists(dbPath)
@@ -63,7 +135,16 @@ func New(dbDir string) (*Manager, error) {
 
 	// Use cache=shared to avoid the "database is locked" error as documented in the FAQ:
 	// https://github.com/mattn/go-sqlite3?tab=readme-ov-file#faq
-	dataSourceName := fmt.Sprintf("file:%s?cache=shared", dbPath)
+	//
+	// The journal mode and synchronous level are set by the driver on each connection. In WAL mode, the changes are
+	// appended to a separate log which is merged into the database later, so an interrupted write never leaves the
+	// database itself half-updated.
+	//
+	// Another daemon can write to the database at the same time while the socket is handed over to it, so we wait
+	// for its writes to complete instead of failing with "database is locked". The transactions take the write lock
+	// when they begin, where SQLite waits for it, instead of when they first write, where it can't.
+	dataSourceName := fmt.Sprintf("file:%s?cache=shared&_journal_mode=WAL&_synchronous=%s&_busy_timeout=%d&_txlock=immediate",
+		dbPath, opts.synchronous, busyTimeout.Milliseconds())
 	db, err := sql.Open("sqlite3", dataSourceName)
 	if err != nil {
 		return nil, err
@@ -79,27 +160,88 @@ func New(dbDir string) (*Manager, error) {
 		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
 	}
 
+	if err := checkJournalMode(db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
 	if !exists {
 		log.Debugf(context.Background(), "Creating new SQLite database at %v", dbPath)
 		if err := createSchema(db); err != nil {
 			// Remove the database file if we failed to create the schema, to avoid that authd tries to use a broken
 			// database on the next start.
-			if removeErr := os.Remove(dbPath); removeErr != nil {
+			_ = db.Close()
+			if removeErr := removeDBFiles(dbPath); removeErr != nil {
 				log.Warningf(context.Background(), "Failed to remove database file after failed schema creation: %v", removeErr)
 			}
 			return nil, fmt.Errorf("failed to create schema: %w", err)
 		}
 	}
 
-	m := &Manager{db: db, path: dbPath, mu: sync.Mutex{}}
+	m := &Manager{
+		db:      db,
+		stmts:   newStatementCache(db),
+		path:    dbPath,
+		latency: newLatencyRecorder(opts.slowQueryThreshold),
+		mu:      sync.Mutex{},
+	}
 	err = m.maybeApplyMigrations()
 	if err != nil {
 		return nil, err
 	}
 
+	// Prepare the queries which are run in transactions, as they can't be prepared once a transaction started.
+	err = m.stmts.prepareAll(groupByIDQuery, groupByNameQuery, groupMembersQuery, userByNameQuery, userGroupsQuery,
+		userLocalGroupsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	m.readDB, m.reads = m.db, m.stmts
+	if opts.readConnections > 0 {
+		// The read-only connections are only opened once the schema is up to date.
+		if err := m.openReadConnections(opts.readConnections); err != nil {
+			_ = m.Close()
+			return nil, err
+		}
+	}
+
 	return m, nil
 }
 
+// openReadConnections opens the read-only connections which run the NSS lookups.
+func (m *Manager) openReadConnections(n int) error {
+	// The connections don't use the shared cache of the main connection, in which readers wait for the writers to
+	// release the locks of the tables.
+	readDB, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro&_busy_timeout=%d", m.path, busyTimeout.Milliseconds()))
+	if err != nil {
+		return err
+	}
+	readDB.SetMaxOpenConns(n)
+	readDB.SetMaxIdleConns(n)
+
+	m.readDB, m.reads = readDB, newStatementCache(readDB)
+	err = m.reads.prepareAll(groupByIDQuery, groupByNameQuery, groupMembersQuery, userByNameQuery, userGroupsQuery,
+		userLocalGroupsQuery)
+	if err != nil {
+		return fmt.Errorf("failed to open the read connections of the database: %w", err)
+	}
+	return nil
+}
+
+// checkJournalMode returns an error if the database is not in WAL mode, which happens if the driver couldn't switch
+// to it, e.g. because the file system doesn't support the shared memory used by WAL.
+func checkJournalMode(db *sql.DB) error {
+	var mode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		return fmt.Errorf("failed to get database journal mode: %w", err)
+	}
+	if !strings.EqualFold(mode, "wal") {
+		return fmt.Errorf("could not enable WAL mode on the database, journal mode is %q", mode)
+	}
+	return nil
+}
+
 func createSchema(db *sql.DB) error {
 	// Start a transaction to create the schema and set the schema version in a single transaction
 	tx, err := db.Begin()
@@ -170,12 +312,29 @@ func checkOwnerAndPermissions(path string) error {
 // Close closes the db and signal the monitoring goroutine to stop.
 func (m *Manager) Close() error {
 	log.Debugf(context.Background(), "Closing database")
-	return m.db.Close()
+	var errs []error
+	if m.readDB != nil && m.readDB != m.db {
+		m.reads.close()
+		errs = append(errs, m.readDB.Close())
+	}
+	m.stmts.close()
+	errs = append(errs, m.db.Close())
+	return errors.Join(errs...)
 }
 
-// RemoveDB removes the database file.
+// RemoveDB removes the database file, with its WAL files.
 func RemoveDB(dbDir string) error {
-	return os.Remove(filepath.Join(dbDir, consts.DefaultDatabaseFileName))
+	return removeDBFiles(filepath.Join(dbDir, consts.DefaultDatabaseFileName))
+}
+
+// removeDBFiles removes the database file and the WAL files next to it, if any.
+func removeDBFiles(dbPath string) error {
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Remove(dbPath)
 }
 
 // NewUIDNotFoundError returns a NoDataFoundError for the given user ID.
@@ -1,12 +1,17 @@
 package db_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"fmt"
 	"io/fs"
 	"os"
+	"os/exec"
 	"os/user"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/canonical/authd/internal/consts"
 	"github.com/canonical/authd/internal/fileutils"
@@ -115,6 +120,112 @@ func TestDatabaseRemovedWhenSchemaCreationFails(t *testing.T) {
 	require.False(t, exists, "Database file should not exist after failed schema creation")
 }
 
+func TestNewWithSynchronous(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		synchronous string
+
+		wantSynchronous string
+		wantErr         bool
+	}{
+		"Default_synchronous_level_is_full":     {wantSynchronous: "2"},
+		"Synchronous_level_normal":              {synchronous: "NORMAL", wantSynchronous: "1"},
+		"Synchronous_level_is_case_insensitive": {synchronous: "extra", wantSynchronous: "3"},
+
+		"Error_on_synchronous_level_off":     {synchronous: "OFF", wantErr: true},
+		"Error_on_invalid_synchronous_level": {synchronous: "invalid", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dbDir := t.TempDir()
+			var opts []db.Option
+			if tc.synchronous != "" {
+				opts = append(opts, db.WithSynchronous(tc.synchronous))
+			}
+
+			m, err := db.New(dbDir, opts...)
+			if tc.wantErr {
+				require.Error(t, err, "New should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
+			defer m.Close()
+
+			journalMode, err := m.Pragma("journal_mode")
+			require.NoError(t, err, "Failed to get journal mode")
+			require.Equal(t, "wal", journalMode, "Database should be in WAL mode")
+
+			synchronous, err := m.Pragma("synchronous")
+			require.NoError(t, err, "Failed to get synchronous level")
+			require.Equal(t, tc.wantSynchronous, synchronous, "Unexpected synchronous level")
+
+			// Changes must be visible after reopening the database, even if they were not checkpointed yet.
+			require.NoError(t, m.UpdateUserEntry(db.NewUserRow("user1", 1111, 11111, "", "/home/user1", "/bin/bash", "broker-id", ""),
+				[]db.GroupRow{db.NewGroupRow("group1", 11111, "12345678")}, nil), "UpdateUserEntry should not fail")
+			require.NoError(t, m.Close(), "Close should not fail")
+
+			m, err = db.New(dbDir, opts...)
+			require.NoError(t, err, "New should not fail when reopening the database")
+			_, err = m.UserByName("user1")
+			require.NoError(t, err, "User should be in the reopened database")
+		})
+	}
+}
+
+func TestNewWithReadConnections(t *testing.T) {
+	t.Parallel()
+
+	_, err := db.New(t.TempDir(), db.WithReadConnections(-1))
+	require.Error(t, err, "New should return an error with a negative number of read connections")
+
+	dbDir := t.TempDir()
+	m, err := db.New(dbDir, db.WithReadConnections(4))
+	require.NoError(t, err)
+	defer m.Close()
+
+	user := db.NewUserRow("user1", 1111, 11111, "", "/home/user1", "/bin/bash", "broker-id", "")
+	require.NoError(t, m.UpdateUserEntry(user, []db.GroupRow{db.NewGroupRow("group1", 11111, "12345678")}, nil),
+		"UpdateUserEntry should not fail")
+
+	// The lookups must not wait for a write transaction to finish.
+	rollBack, err := m.BeginWrite()
+	require.NoError(t, err, "Setup: could not start write transaction")
+	defer func() { require.NoError(t, rollBack(), "Teardown: could not roll back write transaction") }()
+
+	done := make(chan error)
+	go func() {
+		_, err := m.UserByName("user1")
+		if err == nil {
+			_, err = m.GroupWithMembersByID(11111)
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err, "The lookups should see the committed changes")
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "The lookups should not wait for the write transaction to finish")
+	}
+}
+
+func TestRemoveDbRemovesWALFiles(t *testing.T) {
+	t.Parallel()
+
+	c := initDB(t, "multiple_users_and_groups")
+	dbPath := c.Path()
+	for _, suffix := range []string{"-wal", "-shm"} {
+		require.FileExists(t, dbPath+suffix, "Setup: WAL file should exist while the database is open")
+	}
+
+	require.NoError(t, db.RemoveDB(filepath.Dir(dbPath)), "RemoveDB should not return an error")
+	for _, path := range []string{dbPath, dbPath + "-wal", dbPath + "-shm"} {
+		require.NoFileExists(t, path, "RemoveDB should remove the database and its WAL files")
+	}
+}
+
 func TestMigrationToLowercaseUserAndGroupNames(t *testing.T) {
 	// Create a database from the testdata
 	dbDir := t.TempDir()
@@ -517,6 +628,240 @@ func TestMigrationAddProviderIDColumnIsIdempotent(t *testing.T) {
 	require.Equal(t, want, got, "Re-running migrations should not change the database")
 }
 
+func TestMigrationAddGIDIndexes(t *testing.T) {
+	// Create a database from the testdata, which predates the GID indexes.
+	dbDir := t.TempDir()
+	sqlDump := "TestMigrationAddProviderIDColumnToUsersTable/two_users_without_provider_id_column.sql"
+	err := db.Z_ForTests_CreateDBFromDump(filepath.Join("testdata", sqlDump), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+
+	// Run the migrations
+	m, err := db.New(dbDir)
+	require.NoError(t, err)
+	defer m.Close()
+
+	indexes, err := m.IndexNames()
+	require.NoError(t, err)
+	require.Contains(t, indexes, "idx_user_gid", "The index on the GID of users should have been created")
+	require.Contains(t, indexes, "idx_users_to_groups_gid", "The index on the GID of group memberships should have been created")
+
+	plan, err := m.QueryPlan(`SELECT uid FROM users_to_groups WHERE gid = 11111`)
+	require.NoError(t, err)
+	require.Contains(t, plan, "idx_users_to_groups_gid", "Looking up the members of a group should use the index")
+}
+
+func TestSchemaMigrationsAreVersioned(t *testing.T) {
+	t.Parallel()
+
+	versions := db.SchemaMigrationVersions()
+	for i, v := range versions {
+		require.Equal(t, i+1, v, "The schema migrations should have consecutive versions, starting from 1")
+	}
+	require.Equal(t, len(versions), db.SchemaVersion(), "The schema version should be the one of the last migration")
+}
+
+func TestMigrationBacksUpDatabase(t *testing.T) {
+	// Create a database from the testdata, with schema version 2.
+	dbDir := t.TempDir()
+	sqlDump := "TestMigrationAddProviderIDColumnToUsersTable/two_users_without_provider_id_column.sql"
+	err := db.Z_ForTests_CreateDBFromDump(filepath.Join("testdata", sqlDump), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+	// A backup left over by an older migration.
+	dbPath := filepath.Join(dbDir, consts.DefaultDatabaseFileName)
+	err = os.WriteFile(dbPath+".v1.backup", []byte("old backup"), 0600)
+	require.NoError(t, err, "Setup: could not create old backup")
+
+	m, err := db.New(dbDir)
+	require.NoError(t, err)
+	defer m.Close()
+
+	backups, err := filepath.Glob(filepath.Join(dbDir, "*.backup"))
+	require.NoError(t, err)
+	require.Equal(t, []string{dbPath + ".v2.backup"}, backups, "Only the backup made before the migrations should be kept")
+
+	fi, err := os.Stat(backups[0])
+	require.NoError(t, err)
+	require.Equal(t, fs.FileMode(0600), fi.Mode().Perm(), "The backup should only be accessible by its owner")
+
+	schema, err := db.SchemaOf(backups[0])
+	require.NoError(t, err)
+	require.NotContains(t, schema["table users"], "provider_id", "The backup should have the schema before the migrations")
+	require.Contains(t, schema, "table schema_version", "The backup should be a copy of the database")
+}
+
+func TestNoBackupWithoutMigration(t *testing.T) {
+	dbDir := t.TempDir()
+	m, err := db.New(dbDir)
+	require.NoError(t, err)
+	m.Close()
+
+	// Open the database again, which is already up to date.
+	m, err = db.New(dbDir)
+	require.NoError(t, err)
+	defer m.Close()
+
+	backups, err := filepath.Glob(filepath.Join(dbDir, "*.backup"))
+	require.NoError(t, err)
+	require.Empty(t, backups, "No backup should be made if the database is not migrated")
+}
+
+func TestRollBack(t *testing.T) {
+	// Create a database from the testdata, with schema version 1.
+	dbDir := t.TempDir()
+	sqlDump := "TestMigrationAddLockedColumnToUsersTable/one_user_and_group_without_locked_column.sql"
+	err := db.Z_ForTests_CreateDBFromDump(filepath.Join("testdata", sqlDump), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+	dbPath := filepath.Join(dbDir, consts.DefaultDatabaseFileName)
+
+	m, err := db.New(dbDir)
+	require.NoError(t, err)
+	usersBefore, err := m.AllUsers()
+	require.NoError(t, err)
+	// A user added after the upgrade.
+	newUser := db.NewUserRow("newuser", 2222, 11111, "", "/home/newuser", "/bin/bash", "broker-id", "")
+	err = m.UpdateUserEntry(newUser, []db.GroupRow{db.NewGroupRow("group1", 11111, "12345678")}, nil)
+	require.NoError(t, err, "Setup: could not add user")
+
+	from, to, err := m.RollBack()
+	require.NoError(t, err, "RollBack should not return an error")
+	require.Equal(t, db.SchemaVersion(), from, "RollBack should revert the migrations from the current schema version")
+	require.Equal(t, 1, to, "RollBack should revert the migrations down to the schema version of the backup")
+	_, err = m.AllUsers()
+	require.Error(t, err, "The rolled back database should not be used anymore")
+	m.Close()
+
+	got, err := db.SchemaOf(dbPath)
+	require.NoError(t, err)
+	want, err := db.SchemaOf(dbPath + ".v1.backup")
+	require.NoError(t, err)
+	require.Equal(t, want, got, "The rolled back database should have the schema of the backup")
+
+	// Opening the database applies the migrations again, keeping all the users.
+	m, err = db.New(dbDir)
+	require.NoError(t, err)
+	defer m.Close()
+	users, err := m.AllUsers()
+	require.NoError(t, err)
+	require.Len(t, users, len(usersBefore)+1, "The users added since the upgrade should be kept")
+	require.Contains(t, users, newUser, "The users added since the upgrade should keep their UID")
+
+	_, _, err = m.RollBack()
+	require.NoError(t, err, "The migrations applied again should be rolled back too")
+}
+
+func TestRollBackFails(t *testing.T) {
+	tests := map[string]struct {
+		sqlDump string
+	}{
+		"Error_when_the_database_was_not_migrated": {},
+		"Error_when_a_migration_can_not_be_reverted": {
+			sqlDump: "TestMigrationToLowercaseUserAndGroupNames/one_users_multiple_groups_with_uppercase.sql",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dbDir := t.TempDir()
+			if tc.sqlDump != "" {
+				err := db.Z_ForTests_CreateDBFromDump(filepath.Join("testdata", tc.sqlDump), dbDir)
+				require.NoError(t, err, "Setup: could not create database from testdata")
+
+				// The migration to lowercase names renames the users in the group file.
+				groupsFilePath := filepath.Join(t.TempDir(), "groups")
+				err = os.WriteFile(groupsFilePath, []byte("root:x:0:\n"), 0600)
+				require.NoError(t, err, "Setup: could not create group file")
+				t.Cleanup(localentries.Z_ForTests_RestoreDefaultOptions)
+				localentries.Z_ForTests_SetGroupPath(groupsFilePath, groupsFilePath)
+				userslocking.Z_ForTests_OverrideLockingWithCleanup(t)
+			}
+
+			m, err := db.New(dbDir)
+			require.NoError(t, err)
+			defer m.Close()
+			want, err := db.Z_ForTests_DumpNormalizedYAML(m)
+			require.NoError(t, err)
+
+			_, _, err = m.RollBack()
+			require.Error(t, err, "RollBack should return an error")
+
+			got, err := db.Z_ForTests_DumpNormalizedYAML(m)
+			require.NoError(t, err)
+			require.Equal(t, want, got, "The database should not be changed")
+		})
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dbDir := t.TempDir()
+	err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "multiple_users_and_groups.db.yaml"), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+	m, err := db.New(dbDir)
+	require.NoError(t, err)
+	defer m.Close()
+
+	snapshotsDir := filepath.Join(t.TempDir(), "snapshots")
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		_, err := m.Snapshot(snapshotsDir, now.Add(time.Duration(i)*time.Hour))
+		require.NoError(t, err, "Snapshot should not return an error")
+	}
+	// Files which are not snapshots are ignored.
+	err = os.WriteFile(filepath.Join(snapshotsDir, consts.DefaultDatabaseFileName+".notatime.gz"), nil, 0600)
+	require.NoError(t, err, "Setup: could not create file")
+
+	snapshots, err := db.Snapshots(snapshotsDir)
+	require.NoError(t, err, "Snapshots should not return an error")
+	require.Len(t, snapshots, 3, "All the snapshots should be listed")
+	require.Equal(t, now.Add(2*time.Hour), snapshots[0].Time, "The most recent snapshot should be first")
+
+	fi, err := os.Stat(snapshots[0].Path)
+	require.NoError(t, err)
+	require.Equal(t, fs.FileMode(0600), fi.Mode().Perm(), "The snapshot should only be accessible by its owner")
+
+	version, err := db.CheckSnapshot(snapshots[0].Path)
+	require.NoError(t, err, "CheckSnapshot should not return an error")
+	require.Equal(t, db.SchemaVersion(), version, "The snapshot should have the schema version of the database")
+
+	err = db.RemoveOldSnapshots(snapshotsDir, 2)
+	require.NoError(t, err, "RemoveOldSnapshots should not return an error")
+	kept, err := db.Snapshots(snapshotsDir)
+	require.NoError(t, err)
+	require.Equal(t, snapshots[:2], kept, "Only the most recent snapshots should be kept")
+
+	leftovers, err := filepath.Glob(filepath.Join(snapshotsDir, ".*"))
+	require.NoError(t, err)
+	require.Empty(t, leftovers, "No temporary files should be left behind")
+}
+
+func TestCheckSnapshotFails(t *testing.T) {
+	tests := map[string]struct {
+		content []byte
+	}{
+		"Error_when_the_snapshot_is_not_compressed": {content: []byte("not compressed")},
+		"Error_when_the_snapshot_is_not_a_database": {content: gzipped(t, []byte("not a database"))},
+		"Error_when_the_snapshot_is_truncated":      {content: gzipped(t, []byte("SQLite format 3\x00"))},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), consts.DefaultDatabaseFileName+".20261014T120000Z.gz")
+			require.NoError(t, os.WriteFile(path, tc.content, 0600), "Setup: could not create snapshot")
+
+			_, err := db.CheckSnapshot(path)
+			require.Error(t, err, "CheckSnapshot should return an error")
+		})
+	}
+}
+
+func gzipped(t *testing.T, content []byte) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	_, err := w.Write(content)
+	require.NoError(t, err, "Setup: could not compress content")
+	require.NoError(t, w.Close(), "Setup: could not compress content")
+	return b.Bytes()
+}
+
 // TestProviderIDUniquenessEnforcedAfterMigration ensures that the partial unique index created by
 // the provider_id migration is actually active for rows inserted after the migration: it enforces
 // uniqueness on (broker_id, provider_id) when both are non-empty, while still allowing multiple
@@ -778,6 +1123,121 @@ func TestUserByName(t *testing.T) {
 	}
 }
 
+func TestUserBySecondaryName(t *testing.T) {
+	t.Parallel()
+
+	c := initDB(t, "")
+
+	group := db.GroupRow{Name: "group1", GID: 11111, UGID: "12345678"}
+	newUser := func(name string, uid uint32, providerID string) db.UserRow {
+		return db.UserRow{
+			Name:       name,
+			UID:        uid,
+			GID:        group.GID,
+			Dir:        "/home/user" + providerID,
+			Shell:      "/bin/bash",
+			BrokerID:   "broker-id",
+			ProviderID: providerID,
+		}
+	}
+
+	err := c.UpdateUserEntry(newUser("user1@example.com", 1111, "1"), []db.GroupRow{group}, nil)
+	require.NoError(t, err, "Setup: could not insert user")
+	_, err = c.UserBySecondaryName("user1@example.com")
+	require.ErrorIs(t, err, db.NoDataFoundError{}, "The name of a user should not be one of their secondary names")
+
+	// Renaming the user keeps the previous names as secondary names.
+	err = c.UpdateUserEntry(newUser("user1@example.org", 1111, "1"), []db.GroupRow{group}, nil)
+	require.NoError(t, err, "Renaming the user should not return an error")
+	err = c.UpdateUserEntry(newUser("user1@example.net", 1111, "1"), []db.GroupRow{group}, nil)
+	require.NoError(t, err, "Renaming the user again should not return an error")
+	for _, name := range []string{"user1@example.com", "user1@example.org"} {
+		u, err := c.UserBySecondaryName(name)
+		require.NoError(t, err, "UserBySecondaryName should return the user for their previous name %q", name)
+		require.Equal(t, "user1@example.net", u.Name, "UserBySecondaryName should return the renamed user")
+	}
+
+	// Renaming the user back to a previous name makes it their name again.
+	err = c.UpdateUserEntry(newUser("user1@example.org", 1111, "1"), []db.GroupRow{group}, nil)
+	require.NoError(t, err, "Renaming the user back should not return an error")
+	_, err = c.UserBySecondaryName("user1@example.org")
+	require.ErrorIs(t, err, db.NoDataFoundError{}, "The name of a user should not be one of their secondary names")
+
+	// A new user whose name is a secondary name of another user takes it over.
+	err = c.UpdateUserEntry(newUser("user1@example.com", 2222, "2"), []db.GroupRow{group}, nil)
+	require.NoError(t, err, "Inserting a user with a secondary name of another user should not return an error")
+	_, err = c.UserBySecondaryName("user1@example.com")
+	require.ErrorIs(t, err, db.NoDataFoundError{}, "The name of a user should not be a secondary name of another user")
+
+	got, err := db.Z_ForTests_DumpNormalizedYAML(c)
+	require.NoError(t, err, "Created database should be valid yaml content")
+	golden.CheckOrUpdate(t, got)
+}
+
+func TestLinkIdentity(t *testing.T) {
+	t.Parallel()
+
+	c := initDB(t, "multiple_users_and_groups")
+
+	err := c.LinkIdentity(1111, "other-broker-id")
+	require.NoError(t, err, "LinkIdentity should not return an error")
+	err = c.LinkIdentity(1111, "other-broker-id")
+	require.NoError(t, err, "Linking the same identity again should not return an error")
+
+	// The identity is pending until the user authenticated with the broker.
+	got, err := c.LinkedIdentity(1111, "other-broker-id")
+	require.NoError(t, err, "LinkedIdentity should return the linked identity")
+	require.Equal(t, db.LinkedIdentityRow{UID: 1111, BrokerID: "other-broker-id"}, got, "The linked identity should be pending")
+	_, err = c.UserByProviderID("other-broker-id", "")
+	require.ErrorIs(t, err, db.NoDataFoundError{}, "A pending identity should not match any provider ID")
+
+	err = c.ConfirmLinkedIdentity(1111, "other-broker-id", "provider-id-1")
+	require.NoError(t, err, "ConfirmLinkedIdentity should not return an error")
+	err = c.ConfirmLinkedIdentity(1111, "other-broker-id", "provider-id-2")
+	require.NoError(t, err, "Confirming a confirmed identity again should not return an error")
+	got, err = c.LinkedIdentity(1111, "other-broker-id")
+	require.NoError(t, err, "LinkedIdentity should return the linked identity")
+	require.Equal(t, "provider-id-1", got.ProviderID, "The provider ID of a confirmed identity should not change")
+
+	u, err := c.UserByProviderID("other-broker-id", "provider-id-1")
+	require.NoError(t, err, "UserByProviderID should return the user of a linked identity")
+	require.Equal(t, "user1", u.Name, "UserByProviderID should return the user the identity is linked to")
+
+	// The provider ID of an identity can only be linked to one user.
+	err = c.LinkIdentity(2222, "other-broker-id")
+	require.NoError(t, err, "Setup: could not link identity")
+	err = c.ConfirmLinkedIdentity(2222, "other-broker-id", "provider-id-1")
+	require.Error(t, err, "Confirming an identity whose provider ID is linked to another user should return an error")
+	err = c.LinkIdentity(2222, "third-broker-id")
+	require.NoError(t, err, "Setup: could not link identity")
+
+	identities, err := c.LinkedIdentities(2222)
+	require.NoError(t, err, "LinkedIdentities should not return an error")
+	require.Equal(t, []db.LinkedIdentityRow{
+		{UID: 2222, BrokerID: "other-broker-id"},
+		{UID: 2222, BrokerID: "third-broker-id"},
+	}, identities, "LinkedIdentities should return the identities ordered by broker ID")
+
+	err = c.UnlinkIdentity(2222, "third-broker-id")
+	require.NoError(t, err, "UnlinkIdentity should not return an error")
+	err = c.UnlinkIdentity(2222, "third-broker-id")
+	require.ErrorIs(t, err, db.NoDataFoundError{}, "Unlinking an identity which is not linked should return NoDataFoundError")
+	_, err = c.LinkedIdentity(2222, "third-broker-id")
+	require.ErrorIs(t, err, db.NoDataFoundError{}, "LinkedIdentity should return NoDataFoundError for an unlinked identity")
+
+	// The identities of a user are moved along with their UID and removed with them.
+	err = c.SetUserID("user1", 5555)
+	require.NoError(t, err, "Setup: could not change the UID of the user")
+	_, err = c.LinkedIdentity(5555, "other-broker-id")
+	require.NoError(t, err, "The linked identity should follow the UID of the user")
+	err = c.DeleteUser(3333)
+	require.NoError(t, err, "Setup: could not delete user")
+
+	dump, err := db.Z_ForTests_DumpNormalizedYAML(c)
+	require.NoError(t, err)
+	golden.CheckOrUpdate(t, dump)
+}
+
 func TestAllUsers(t *testing.T) {
 	t.Parallel()
 
@@ -801,6 +1261,30 @@ func TestAllUsers(t *testing.T) {
 	}
 }
 
+func TestUsersPage(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		afterUID uint32
+		limit    int
+	}{
+		"Get_first_page":                  {limit: 2},
+		"Get_next_page":                   {afterUID: 2222, limit: 2},
+		"Get_all_remaining_without_limit": {afterUID: 1111, limit: -1},
+		"Get_no_users_after_the_last_one": {afterUID: 4444, limit: 2},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initDB(t, "multiple_users_and_groups")
+
+			got, err := c.UsersPage(tc.afterUID, tc.limit)
+			requireGetAssertions(t, got, false, nil, err)
+		})
+	}
+}
+
 func TestGroupByID(t *testing.T) {
 	t.Parallel()
 
@@ -950,6 +1434,30 @@ func TestAllGroupsWithMembers(t *testing.T) {
 	}
 }
 
+func TestGroupsWithMembersPage(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		afterGID uint32
+		limit    int
+	}{
+		"Get_first_page":                   {limit: 2},
+		"Get_next_page":                    {afterGID: 22222, limit: 2},
+		"Get_all_remaining_without_limit":  {afterGID: 33333, limit: -1},
+		"Get_no_groups_after_the_last_one": {afterGID: 99999, limit: 2},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initDB(t, "multiple_users_and_groups")
+
+			got, err := c.GroupsWithMembersPage(tc.afterGID, tc.limit)
+			requireGetAssertions(t, got, false, nil, err)
+		})
+	}
+}
+
 func TestUpdateBrokerForUser(t *testing.T) {
 	t.Parallel()
 
@@ -1302,33 +1810,535 @@ func TestDeleteGroup(t *testing.T) {
 	}
 }
 
-// TestBackwardCompatibilityAndMigrations covers loading legacy schemas (e.g., v2 with INT ugid)
-// and migrating older schemas (e.g., v1 without 'locked' column) to the latest schema.
-func TestBackwardCompatibilityAndMigrations(t *testing.T) {
+func TestAddLoginRecord(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
-		dump string
+		dbFile     string
+		uid        uint32
+		numRecords int
+
+		wantErr bool
 	}{
-		"SchemaV2_IntUGID":        {dump: filepath.Join("testdata", "TestLoadSchemaV2WithIntUGID", "one_user_and_group_v2.sql")},
-		"SchemaV1_NoLockedColumn": {dump: filepath.Join("testdata", "TestMigrationAddLockedColumnToUsersTable", "one_user_and_group_without_locked_column.sql")},
-	}
+		"Add_login_record_for_existing_user":           {dbFile: "one_user_and_group", uid: 1111, numRecords: 1},
+		"Add_multiple_login_records_for_existing_user": {dbFile: "multiple_users_and_groups", uid: 1111, numRecords: 3},
+		"Oldest_login_records_are_pruned":              {dbFile: "one_user_and_group", uid: 1111, numRecords: db.MaxLoginHistoryEntries + 2},
 
+		"Error_on_missing_user": {dbFile: "one_user_and_group", uid: 4242, numRecords: 1, wantErr: true},
+	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			tempDir := t.TempDir()
+			c := initDB(t, tc.dbFile)
 
-			err := db.Z_ForTests_CreateDBFromDump(tc.dump, tempDir)
-			require.NoError(t, err, "Setup: could not create database from dump")
+			var err error
+			for i := range tc.numRecords {
+				result := "granted"
+				if i%2 == 1 {
+					result = "denied"
+				}
+				r := db.NewLoginRecordRow(tc.uid, time.Unix(int64(1700000000+i), 0), "sshd", "broker-id", i%3 == 2, result)
+				if err = c.AddLoginRecord(r); err != nil {
+					break
+				}
+			}
+			if tc.wantErr {
+				require.Error(t, err, "AddLoginRecord should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
 
-			// Open using current manager, it'll trigger a migration depending on schema_version.
-			m, err := db.New(tempDir)
-			require.NoError(t, err, "Setup: could not open manager for database")
-			t.Cleanup(func() { _ = m.Close() })
+			records, err := c.LoginHistory(tc.uid, 0)
+			require.NoError(t, err)
+			require.Len(t, records, min(tc.numRecords, db.MaxLoginHistoryEntries), "LoginHistory should return the stored records")
 
-			// Validate user can be read and that locked is false (either set or default).
+			if tc.numRecords > db.MaxLoginHistoryEntries {
+				// Only check the number of records, the dump would be too long.
+				require.Equal(t, int64(1700000000+tc.numRecords-1), records[0].Timestamp, "Newest record should be kept")
+				require.Equal(t, int64(1700000000+tc.numRecords-db.MaxLoginHistoryEntries), records[len(records)-1].Timestamp,
+					"Oldest records should be pruned")
+				return
+			}
+
+			got, err := db.Z_ForTests_DumpNormalizedYAML(c)
+			require.NoError(t, err)
+			golden.CheckOrUpdate(t, got)
+		})
+	}
+}
+
+func TestLoginHistory(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		uid   uint32
+		limit uint32
+
+		wantCount int
+	}{
+		"Get_all_login_records_newest_first": {uid: 1111, wantCount: 3},
+		"Get_limited_login_records":          {uid: 1111, limit: 2, wantCount: 2},
+		"Get_records_with_limit_above_count": {uid: 1111, limit: 10, wantCount: 3},
+
+		"Empty_history_for_user_without_records": {uid: 2222},
+		"Empty_history_for_missing_user":         {uid: 4242},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initDB(t, "multiple_users_and_groups")
+			for i := range 3 {
+				r := db.NewLoginRecordRow(1111, time.Unix(int64(1700000000+i), 0), "gdm-authd", "broker-id", false, "granted")
+				require.NoError(t, c.AddLoginRecord(r), "Setup: could not add login record")
+			}
+
+			got, err := c.LoginHistory(tc.uid, tc.limit)
+			require.NoError(t, err)
+			require.Len(t, got, tc.wantCount, "LoginHistory should return the expected number of records")
+			for i := 1; i < len(got); i++ {
+				require.Greater(t, got[i-1].Timestamp, got[i].Timestamp, "LoginHistory should return newest records first")
+			}
+		})
+	}
+}
+
+func TestLastLoginsByBroker(t *testing.T) {
+	t.Parallel()
+
+	c := initDB(t, "multiple_users_and_groups")
+	records := []db.LoginRecordRow{
+		db.NewLoginRecordRow(1111, time.Unix(1700000000, 0), "sshd", "broker-id", false, "granted"),
+		db.NewLoginRecordRow(2222, time.Unix(1700000100, 0), "sshd", "broker-id", false, "granted"),
+		db.NewLoginRecordRow(1111, time.Unix(1700000200, 0), "sshd", "broker-id", true, "granted"),
+		db.NewLoginRecordRow(1111, time.Unix(1700000300, 0), "sshd", "broker-id", false, "denied"),
+		db.NewLoginRecordRow(2222, time.Unix(1700000050, 0), "sshd", "other-broker-id", false, "granted"),
+		db.NewLoginRecordRow(2222, time.Unix(1700000400, 0), "sshd", "offline-broker-id", true, "granted"),
+	}
+	// The logins with a machine token don't tell whether the broker can reach its identity provider.
+	machineTokenLogin := db.NewLoginRecordRow(1111, time.Unix(1700000500, 0), "cron", "broker-id", false, "granted")
+	machineTokenLogin.MachineToken = true
+	records = append(records, machineTokenLogin)
+	for _, r := range records {
+		require.NoError(t, c.AddLoginRecord(r), "Setup: could not add login record")
+	}
+
+	got, err := c.LastLoginsByBroker(false, "granted")
+	require.NoError(t, err, "LastLoginsByBroker should not return an error")
+	require.Equal(t, map[string]time.Time{
+		"broker-id":       time.Unix(1700000100, 0),
+		"other-broker-id": time.Unix(1700000050, 0),
+	}, got, "LastLoginsByBroker should return the last login with the mode and result of each broker")
+}
+
+func TestLastLogins(t *testing.T) {
+	t.Parallel()
+
+	c := initDB(t, "multiple_users_and_groups")
+	records := []db.LoginRecordRow{
+		db.NewLoginRecordRow(1111, time.Unix(1700000000, 0), "sshd", "broker-id", false, "granted"),
+		db.NewLoginRecordRow(1111, time.Unix(1700000200, 0), "sshd", "broker-id", true, "granted"),
+		db.NewLoginRecordRow(1111, time.Unix(1700000300, 0), "sshd", "broker-id", false, "denied"),
+		db.NewLoginRecordRow(2222, time.Unix(1700000100, 0), "sshd", "broker-id", false, "granted"),
+		db.NewLoginRecordRow(3333, time.Unix(1700000400, 0), "sshd", "broker-id", false, "denied"),
+	}
+	for _, r := range records {
+		require.NoError(t, c.AddLoginRecord(r), "Setup: could not add login record")
+	}
+
+	got, err := c.LastLogins("granted")
+	require.NoError(t, err, "LastLogins should not return an error")
+	require.Equal(t, map[uint32]time.Time{
+		1111: time.Unix(1700000200, 0),
+		2222: time.Unix(1700000100, 0),
+	}, got, "LastLogins should return the last login with the result of each user")
+}
+
+func TestSetSessionEnv(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		uid uint32
+		env map[string]string
+
+		wantErr bool
+	}{
+		"Set_session_environment":                   {uid: 1111, env: map[string]string{"EMPLOYEE_ID": "1234", "IDP_TENANT": "tenant"}},
+		"Replace_session_environment":               {uid: 1111, env: map[string]string{"EMPLOYEE_ID": "5678"}},
+		"Remove_session_environment_when_empty":     {uid: 1111},
+		"Other_users_session_environment_untouched": {uid: 2222, env: map[string]string{"EMPLOYEE_ID": "5678"}},
+
+		"Error_on_missing_user": {uid: 4242, env: map[string]string{"EMPLOYEE_ID": "1234"}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initDB(t, "multiple_users_and_groups")
+			err := c.SetSessionEnv(1111, map[string]string{"EMPLOYEE_ID": "1234", "DEPARTMENT": "engineering"})
+			require.NoError(t, err, "Setup: could not set session environment")
+
+			err = c.SetSessionEnv(tc.uid, tc.env)
+			if tc.wantErr {
+				require.Error(t, err, "SetSessionEnv should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := c.SessionEnv(tc.uid)
+			require.NoError(t, err)
+			if tc.env == nil {
+				tc.env = map[string]string{}
+			}
+			require.Equal(t, tc.env, got, "SessionEnv should return the environment which was set")
+
+			dump, err := db.Z_ForTests_DumpNormalizedYAML(c)
+			require.NoError(t, err)
+			golden.CheckOrUpdate(t, dump)
+		})
+	}
+}
+
+func TestSetSSHKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		uid  uint32
+		keys []string
+
+		wantKeys []string
+		wantErr  bool
+	}{
+		"Set_SSH_keys":                   {uid: 1111, keys: []string{"ssh-ed25519 AAAA2 work", "ssh-ed25519 AAAA1 laptop"}, wantKeys: []string{"ssh-ed25519 AAAA1 laptop", "ssh-ed25519 AAAA2 work"}},
+		"Replace_SSH_keys":               {uid: 1111, keys: []string{"ssh-ed25519 AAAA3"}, wantKeys: []string{"ssh-ed25519 AAAA3"}},
+		"Ignore_duplicated_SSH_keys":     {uid: 1111, keys: []string{"ssh-ed25519 AAAA3", "ssh-ed25519 AAAA3"}, wantKeys: []string{"ssh-ed25519 AAAA3"}},
+		"Remove_SSH_keys_when_empty":     {uid: 1111},
+		"Other_users_SSH_keys_untouched": {uid: 2222, keys: []string{"ssh-ed25519 AAAA3"}, wantKeys: []string{"ssh-ed25519 AAAA3"}},
+
+		"Error_on_missing_user": {uid: 4242, keys: []string{"ssh-ed25519 AAAA3"}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initDB(t, "multiple_users_and_groups")
+			err := c.SetSSHKeys(1111, []string{"ssh-ed25519 AAAA1 laptop", "ssh-rsa AAAA0"})
+			require.NoError(t, err, "Setup: could not set SSH keys")
+
+			err = c.SetSSHKeys(tc.uid, tc.keys)
+			if tc.wantErr {
+				require.Error(t, err, "SetSSHKeys should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := c.SSHKeys(tc.uid)
+			require.NoError(t, err)
+			require.Equal(t, tc.wantKeys, got, "SSHKeys should return the sorted keys which were set")
+
+			dump, err := db.Z_ForTests_DumpNormalizedYAML(c)
+			require.NoError(t, err)
+			golden.CheckOrUpdate(t, dump)
+		})
+	}
+}
+
+func TestTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		uid    uint32
+		secret string
+		remove bool
+		steps  []int64
+
+		wantUsed     []bool
+		wantLastStep int64
+		wantErr      bool
+		wantGetErr   bool
+	}{
+		"Set_TOTP_secret":                       {uid: 1111, secret: "c2VjcmV0"},
+		"Replace_TOTP_secret_and_reset_steps":   {uid: 2222, secret: "bmV3LXNlY3JldA=="},
+		"Accept_each_time_step_once":            {uid: 1111, secret: "c2VjcmV0", steps: []int64{10, 10, 11}, wantUsed: []bool{true, false, true}, wantLastStep: 11},
+		"Reject_time_steps_before_the_last_one": {uid: 1111, secret: "c2VjcmV0", steps: []int64{10, 9}, wantUsed: []bool{true, false}, wantLastStep: 10},
+		"Remove_TOTP_secret":                    {uid: 2222, remove: true, wantGetErr: true},
+
+		"Error_on_missing_user":                {uid: 4242, secret: "c2VjcmV0", wantErr: true},
+		"Error_when_removing_a_missing_secret": {uid: 1111, remove: true, wantErr: true},
+		"Error_when_getting_a_missing_secret":  {uid: 3333, wantGetErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initDB(t, "multiple_users_and_groups")
+			err := c.SetTOTPSecret(2222, "b2xkLXNlY3JldA==")
+			require.NoError(t, err, "Setup: could not set TOTP secret")
+			used, err := c.UseTOTPStep(2222, 5)
+			require.NoError(t, err, "Setup: could not use TOTP time step")
+			require.True(t, used, "Setup: TOTP time step should be used")
+
+			switch {
+			case tc.remove:
+				err = c.DeleteTOTPSecret(tc.uid)
+			case tc.secret != "":
+				err = c.SetTOTPSecret(tc.uid, tc.secret)
+			}
+			if tc.wantErr {
+				require.Error(t, err, "Setting or removing the TOTP secret should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
+
+			for i, step := range tc.steps {
+				used, err := c.UseTOTPStep(tc.uid, step)
+				require.NoError(t, err, "UseTOTPStep should not return an error")
+				require.Equal(t, tc.wantUsed[i], used, "UseTOTPStep should accept the time step %d only once", step)
+			}
+
+			got, err := c.TOTPSecret(tc.uid)
+			if tc.wantGetErr {
+				require.ErrorIs(t, err, db.NoDataFoundError{}, "TOTPSecret should return a NoDataFoundError")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.secret, got.Secret, "TOTPSecret should return the secret which was set")
+			require.Equal(t, tc.wantLastStep, got.LastStep, "TOTPSecret should return the last used time step")
+
+			dump, err := db.Z_ForTests_DumpNormalizedYAML(c)
+			require.NoError(t, err)
+			golden.CheckOrUpdate(t, dump)
+		})
+	}
+}
+
+func TestMachineToken(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		uid       uint32
+		hash      string
+		expiresAt int64
+		remove    bool
+
+		wantErr    bool
+		wantGetErr bool
+	}{
+		"Set_machine_token":                   {uid: 1111, hash: "aGFzaA=="},
+		"Set_machine_token_with_expiry":       {uid: 1111, hash: "aGFzaA==", expiresAt: 1700000000},
+		"Replace_machine_token":               {uid: 2222, hash: "bmV3LWhhc2g="},
+		"Remove_machine_token":                {uid: 2222, remove: true, wantGetErr: true},
+		"Error_on_missing_user":               {uid: 4242, hash: "aGFzaA==", wantErr: true},
+		"Error_when_removing_a_missing_token": {uid: 1111, remove: true, wantErr: true},
+		"Error_when_getting_a_missing_token":  {uid: 3333, wantGetErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initDB(t, "multiple_users_and_groups")
+			err := c.SetMachineToken(2222, "b2xkLWhhc2g=", 1600000000)
+			require.NoError(t, err, "Setup: could not set machine token")
+
+			switch {
+			case tc.remove:
+				err = c.DeleteMachineToken(tc.uid)
+			case tc.hash != "":
+				err = c.SetMachineToken(tc.uid, tc.hash, tc.expiresAt)
+			}
+			if tc.wantErr {
+				require.Error(t, err, "Setting or removing the machine token should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := c.MachineToken(tc.uid)
+			if tc.wantGetErr {
+				require.ErrorIs(t, err, db.NoDataFoundError{}, "MachineToken should return a NoDataFoundError")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.hash, got.Hash, "MachineToken should return the hash which was set")
+			require.Equal(t, tc.expiresAt, got.ExpiresAt, "MachineToken should return the expiry which was set")
+
+			dump, err := db.Z_ForTests_DumpNormalizedYAML(c)
+			require.NoError(t, err)
+			golden.CheckOrUpdate(t, dump)
+		})
+	}
+}
+
+func TestLastAuthMode(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		uid      uint32
+		brokerID string
+		authMode string
+
+		wantErr    bool
+		wantGetErr bool
+	}{
+		"Set_last_authentication_mode":                     {uid: 1111, brokerID: "broker-id", authMode: "password"},
+		"Replace_last_authentication_mode":                 {uid: 2222, brokerID: "broker-id", authMode: "device_auth_qr"},
+		"Replace_last_authentication_mode_and_broker":      {uid: 2222, brokerID: "other-broker-id", authMode: "password"},
+		"Error_on_missing_user":                            {uid: 4242, brokerID: "broker-id", authMode: "password", wantErr: true},
+		"Error_when_getting_a_missing_authentication_mode": {uid: 3333, wantGetErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initDB(t, "multiple_users_and_groups")
+			err := c.SetLastAuthMode(2222, "broker-id", "password")
+			require.NoError(t, err, "Setup: could not set last authentication mode")
+
+			if tc.authMode != "" {
+				err = c.SetLastAuthMode(tc.uid, tc.brokerID, tc.authMode)
+			}
+			if tc.wantErr {
+				require.Error(t, err, "SetLastAuthMode should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := c.LastAuthMode(tc.uid)
+			if tc.wantGetErr {
+				require.ErrorIs(t, err, db.NoDataFoundError{}, "LastAuthMode should return a NoDataFoundError")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.brokerID, got.BrokerID, "LastAuthMode should return the broker which was set")
+			require.Equal(t, tc.authMode, got.AuthMode, "LastAuthMode should return the authentication mode which was set")
+
+			dump, err := db.Z_ForTests_DumpNormalizedYAML(c)
+			require.NoError(t, err)
+			golden.CheckOrUpdate(t, dump)
+		})
+	}
+}
+
+func TestSetUserLifecycle(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		uid    uint32
+		state  string
+		remove bool
+
+		wantErr bool
+	}{
+		"Set_lifecycle_state":            {uid: 2222, state: "disabled"},
+		"Replace_lifecycle_state":        {uid: 1111, state: "archived"},
+		"Remove_lifecycle_state":         {uid: 1111, remove: true},
+		"Remove_missing_lifecycle_state": {uid: 2222, remove: true},
+		"Error_on_missing_user":          {uid: 4242, state: "disabled", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initDB(t, "multiple_users_and_groups")
+			err := c.SetUserLifecycle(1111, "disabled", 1000)
+			require.NoError(t, err, "Setup: could not set lifecycle state")
+
+			if tc.remove {
+				err = c.DeleteUserLifecycle(tc.uid)
+			} else {
+				err = c.SetUserLifecycle(tc.uid, tc.state, 2000)
+			}
+			if tc.wantErr {
+				require.Error(t, err, "Setting the lifecycle state should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := c.UserLifecycle(tc.uid)
+			if tc.remove {
+				require.ErrorIs(t, err, db.NoDataFoundError{}, "UserLifecycle should return NoDataFoundError for active users")
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, db.LifecycleRow{UID: tc.uid, State: tc.state, ChangedAt: 2000}, got, "UserLifecycle should return the state which was set")
+			}
+
+			dump, err := db.Z_ForTests_DumpNormalizedYAML(c)
+			require.NoError(t, err)
+			golden.CheckOrUpdate(t, dump)
+		})
+	}
+}
+
+func TestAddDeletedUser(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		name   string
+		remove bool
+
+		want []db.DeletedUserRow
+	}{
+		"Add_deleted_user": {name: "user2@example.com", want: []db.DeletedUserRow{
+			{Name: "user1@example.com", UID: 1111, Dir: "/home/user1", DeletedAt: 1000},
+			{Name: "user2@example.com", UID: 2222, Dir: "/home/user2", DeletedAt: 2000},
+		}},
+		"Replace_deleted_user_with_same_name": {name: "user1@example.com", want: []db.DeletedUserRow{
+			{Name: "user1@example.com", UID: 2222, Dir: "/home/user2", DeletedAt: 2000},
+		}},
+		"Remove_deleted_user": {name: "user1@example.com", remove: true},
+		"Remove_missing_deleted_user": {name: "user2@example.com", remove: true, want: []db.DeletedUserRow{
+			{Name: "user1@example.com", UID: 1111, Dir: "/home/user1", DeletedAt: 1000},
+		}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initDB(t, "multiple_users_and_groups")
+			err := c.AddDeletedUser(db.DeletedUserRow{Name: "user1@example.com", UID: 1111, Dir: "/home/user1", DeletedAt: 1000})
+			require.NoError(t, err, "Setup: could not record deleted user")
+
+			if tc.remove {
+				err = c.RemoveDeletedUser(tc.name)
+			} else {
+				err = c.AddDeletedUser(db.DeletedUserRow{Name: tc.name, UID: 2222, Dir: "/home/user2", DeletedAt: 2000})
+			}
+			require.NoError(t, err)
+
+			got, err := c.DeletedUsers()
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got, "DeletedUsers should return the recorded users")
+		})
+	}
+}
+
+// TestBackwardCompatibilityAndMigrations covers loading legacy schemas (e.g., v2 with INT ugid)
+// and migrating older schemas (e.g., v1 without 'locked' column) to the latest schema.
+func TestBackwardCompatibilityAndMigrations(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dump string
+	}{
+		"SchemaV2_IntUGID":        {dump: filepath.Join("testdata", "TestLoadSchemaV2WithIntUGID", "one_user_and_group_v2.sql")},
+		"SchemaV1_NoLockedColumn": {dump: filepath.Join("testdata", "TestMigrationAddLockedColumnToUsersTable", "one_user_and_group_without_locked_column.sql")},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+
+			err := db.Z_ForTests_CreateDBFromDump(tc.dump, tempDir)
+			require.NoError(t, err, "Setup: could not create database from dump")
+
+			// Open using current manager, it'll trigger a migration depending on schema_version.
+			m, err := db.New(tempDir)
+			require.NoError(t, err, "Setup: could not open manager for database")
+			t.Cleanup(func() { _ = m.Close() })
+
+			// Validate user can be read and that locked is false (either set or default).
 			u, err := m.UserByID(1111)
 			require.NoError(t, err, "Should read user from DB")
 			require.Equal(t, "user1", u.Name)
@@ -1351,6 +2361,93 @@ func TestBackwardCompatibilityAndMigrations(t *testing.T) {
 	}
 }
 
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	c := initDB(t, "multiple_users_and_groups")
+	err := c.UpdateLockedFieldForUser("user1", true)
+	require.NoError(t, err, "Setup: could not lock user")
+
+	got, err := c.Stats()
+	require.NoError(t, err, "Stats should not return an error")
+	require.Equal(t, c.Path(), got.Path, "Stats should return the path of the database")
+	require.Positive(t, got.Size, "Stats should return the size of the database")
+	require.EqualValues(t, db.SchemaVersion(), got.SchemaVersion, "Stats should return the schema version of the database")
+	require.EqualValues(t, 4, got.Users, "Stats should return the number of users")
+	require.EqualValues(t, 1, got.LockedUsers, "Stats should return the number of locked users")
+	require.EqualValues(t, 5, got.Groups, "Stats should return the number of groups")
+}
+
+func TestLatencies(t *testing.T) {
+	t.Parallel()
+
+	c := initDB(t, "multiple_users_and_groups")
+	require.Empty(t, c.Latencies(), "Setup: no operation should be recorded yet")
+
+	for range 2 {
+		_, err := c.UserByName("user1")
+		require.NoError(t, err, "Setup: could not get user")
+	}
+	_, err := c.UserByName("doesnotexist")
+	require.Error(t, err, "Setup: getting a user which does not exist should fail")
+	_, err = c.GroupByID(11111)
+	require.NoError(t, err, "Setup: could not get group")
+
+	got := c.Latencies()
+	require.Len(t, got, 2, "Latencies should return the operations which were run")
+	require.Equal(t, "GroupByID", got[0].Operation, "Latencies should be sorted by operation")
+	require.EqualValues(t, 1, got[0].Count, "Latencies should count the operations")
+	require.Equal(t, "UserByName", got[1].Operation, "Latencies should be sorted by operation")
+	require.EqualValues(t, 3, got[1].Count, "Latencies should count the failed operations too")
+	require.Positive(t, got[1].Sum, "Latencies should sum the durations of the operations")
+
+	require.Len(t, got[1].Buckets, len(db.LatencyBuckets), "Latencies should return a count for each bucket")
+	for i := 1; i < len(got[1].Buckets); i++ {
+		require.GreaterOrEqual(t, got[1].Buckets[i], got[1].Buckets[i-1], "The buckets should be cumulative")
+	}
+	require.LessOrEqual(t, got[1].Buckets[len(db.LatencyBuckets)-1], got[1].Count, "The buckets should not count more operations than the total")
+
+	stats, err := c.Stats()
+	require.NoError(t, err, "Stats should not return an error")
+	require.Equal(t, got, stats.Latencies, "Stats should return the latencies of the operations")
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	tests := map[string]struct {
+		threshold time.Duration
+
+		wantLog bool
+	}{
+		"Log_operations_slower_than_the_threshold":    {threshold: time.Nanosecond, wantLog: true},
+		"No_log_for_operations_faster_than_threshold": {threshold: time.Hour},
+		"No_log_when_disabled":                        {threshold: 0},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The log handlers are global, so this test can't run in parallel.
+			var logged []string
+			log.SetLevelHandler(log.WarnLevel, func(_ context.Context, _ log.Level, format string, args ...interface{}) {
+				logged = append(logged, fmt.Sprintf(format, args...))
+			})
+			t.Cleanup(func() { log.SetLevelHandler(log.WarnLevel, nil) })
+
+			m, err := db.New(t.TempDir(), db.WithSlowQueryThreshold(tc.threshold))
+			require.NoError(t, err)
+			t.Cleanup(func() { m.Close() })
+
+			_, err = m.AllUsers()
+			require.NoError(t, err, "Setup: could not get users")
+
+			if !tc.wantLog {
+				require.Empty(t, logged, "Operations faster than the threshold should not be logged")
+				return
+			}
+			require.Len(t, logged, 1, "The slow operation should be logged")
+			require.Contains(t, logged[0], "AllUsers", "The log should name the slow operation")
+		})
+	}
+}
+
 // initDB returns a new database ready to be used alongside its database directory.
 func initDB(t *testing.T, dbFile string) *db.Manager {
 	t.Helper()
@@ -1393,6 +2490,60 @@ func requireGetAssertions[E any](t *testing.T, got E, wantErr bool, wantErrType,
 	golden.CheckOrUpdateYAML(t, got)
 }
 
+// concurrentWriterDirEnv is the environment variable with the database directory in which the process started by
+// TestConcurrentWritesFromTwoProcesses writes.
+const concurrentWriterDirEnv = "AUTHD_TESTS_CONCURRENT_WRITER_DB_DIR"
+
+func TestConcurrentWritesFromTwoProcesses(t *testing.T) {
+	// The old and the new daemon both write to the database while the socket is handed over.
+	const usersPerProcess = 100
+	writeUsers := func(dbDir string, firstUID uint32) error {
+		m, err := db.New(dbDir)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+
+		for uid := firstUID; uid < firstUID+usersPerProcess; uid++ {
+			name := fmt.Sprintf("user%d", uid)
+			u := db.NewUserRow(name, uid, uid, "", "/home/"+name, "/bin/bash", "broker-id", "")
+			if err := m.UpdateUserEntry(u, []db.GroupRow{db.NewGroupRow(name, uid, name)}, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if dbDir := os.Getenv(concurrentWriterDirEnv); dbDir != "" {
+		require.NoError(t, writeUsers(dbDir, 20000), "The other process could not write to the database")
+		return
+	}
+
+	dbDir := t.TempDir()
+	m, err := db.New(dbDir)
+	require.NoError(t, err, "Setup: could not create database")
+	require.NoError(t, m.Close(), "Setup: could not close database")
+
+	//nolint:gosec // G204 We run the test binary itself.
+	cmd := exec.Command(os.Args[0], "-test.run=^TestConcurrentWritesFromTwoProcesses$")
+	cmd.Env = append(os.Environ(), concurrentWriterDirEnv+"="+dbDir)
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	require.NoError(t, cmd.Start(), "Setup: could not start the other process")
+
+	err = writeUsers(dbDir, 10000)
+	waitErr := cmd.Wait()
+	require.NoError(t, err, "Writing to the database should not fail while the other process writes to it")
+	require.NoError(t, waitErr, "The other process should not fail to write to the database:\n%s", out.String())
+
+	m, err = db.New(dbDir)
+	require.NoError(t, err)
+	defer m.Close()
+	users, err := m.AllUsers()
+	require.NoError(t, err)
+	require.Len(t, users, 2*usersPerProcess, "All the users written by both processes should be stored")
+}
+
 func TestMain(m *testing.M) {
 	log.SetLevel(log.DebugLevel)
 
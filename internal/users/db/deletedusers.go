@@ -0,0 +1,72 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// DeletedUserRow represents a user deleted from the database whose home directory was left on disk.
+type DeletedUserRow struct {
+	Name      string
+	UID       uint32
+	Dir       string
+	DeletedAt int64 `yaml:"deleted_at,omitempty"` // Unix time in seconds.
+}
+
+// AddDeletedUser records a deleted user, replacing any previous record of a user with the same name.
+func (m *Manager) AddDeletedUser(u DeletedUserRow) error {
+	defer m.observe("AddDeletedUser", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `INSERT OR REPLACE INTO deleted_users (name, uid, dir, deleted_at) VALUES (?, ?, ?, ?)`
+	if _, err := m.db.Exec(query, u.Name, u.UID, u.Dir, u.DeletedAt); err != nil {
+		return fmt.Errorf("failed to record deleted user: %w", err)
+	}
+	return nil
+}
+
+// RemoveDeletedUser removes the record of the deleted user with the given name.
+func (m *Manager) RemoveDeletedUser(name string) error {
+	defer m.observe("RemoveDeletedUser", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.db.Exec(`DELETE FROM deleted_users WHERE name = ?`, name); err != nil {
+		return fmt.Errorf("failed to remove deleted user record: %w", err)
+	}
+	return nil
+}
+
+// DeletedUsers returns the records of the deleted users, oldest deletion first.
+func (m *Manager) DeletedUsers() ([]DeletedUserRow, error) {
+	defer m.observe("DeletedUsers", time.Now())
+
+	return allDeletedUsers(m.db)
+}
+
+func allDeletedUsers(db queryable) ([]DeletedUserRow, error) {
+	rows, err := db.Query(`SELECT name, uid, dir, deleted_at FROM deleted_users ORDER BY deleted_at, name`)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	var deleted []DeletedUserRow
+	for rows.Next() {
+		var r DeletedUserRow
+		if err := rows.Scan(&r.Name, &r.UID, &r.Dir, &r.DeletedAt); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		deleted = append(deleted, r)
+	}
+
+	// Check for errors from iteration
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// UserEntryRows holds a user with the groups they're a member of.
+type UserEntryRows struct {
+	User        UserRow
+	Groups      []GroupRow
+	LocalGroups []string
+}
+
+// ExportEntries returns all the users with their groups, ordered by UID, and all the groups, including the ones
+// without members, in a single transaction.
+func (m *Manager) ExportEntries() (users []UserEntryRows, groups []GroupRow, err error) {
+	defer m.observe("ExportEntries", time.Now())
+
+	tx, err := m.readDB.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	// Ensure the transaction is committed or rolled back
+	defer func() {
+		err = commitOrRollBackTransaction(err, tx)
+	}()
+
+	userRows, err := usersPage(tx, 0, -1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, u := range userRows {
+		userGroups, err := userGroups(tx, u.UID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get groups of user %q: %w", u.Name, err)
+		}
+		localGroups, err := userLocalGroups(tx, u.UID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get local groups of user %q: %w", u.Name, err)
+		}
+		users = append(users, UserEntryRows{User: u, Groups: userGroups, LocalGroups: localGroups})
+	}
+
+	groups, err = allGroups(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return users, groups, nil
+}
+
+// ImportEntries inserts or updates the users and the groups in a single transaction, so that nothing is imported if
+// one of them can't be. Unlike UpdateUserEntry, it sets the locked state of the users too.
+func (m *Manager) ImportEntries(users []UserEntryRows, groups []GroupRow) (err error) {
+	defer m.observe("ImportEntries", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	// Ensure the transaction is committed or rolled back
+	defer func() {
+		err = commitOrRollBackTransaction(err, tx)
+	}()
+
+	// The groups are imported first, so that the groups without members are too.
+	if err := handleGroupsUpdate(tx, groups); err != nil {
+		return err
+	}
+
+	for _, e := range users {
+		if err := handleUserUpdate(tx, e.User); err != nil {
+			return err
+		}
+		if err := handleGroupsUpdate(tx, e.Groups); err != nil {
+			return err
+		}
+		if err := handleUsersToGroupsUpdate(tx, e.User.UID, e.Groups); err != nil {
+			return err
+		}
+		if err := handleUsersToLocalGroupsUpdate(tx, e.User.UID, e.LocalGroups); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE users SET locked = ? WHERE uid = ?`, e.User.Locked, e.User.UID); err != nil {
+			return fmt.Errorf("failed to update locked field for user: %w", err)
+		}
+	}
+
+	return nil
+}
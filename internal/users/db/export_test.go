@@ -1,10 +1,29 @@
 package db
 
+import (
+	"database/sql"
+	"fmt"
+)
+
 // Path exposes the path to the database file for testing.
 func (m *Manager) Path() string {
 	return m.path
 }
 
+// BeginWrite starts a write transaction on the main connection of the database, which is held until the returned
+// function is called, for testing.
+func (m *Manager) BeginWrite() (rollBack func() error, err error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE users SET gecos = gecos`); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return tx.Rollback, nil
+}
+
 // GetCreateSchemaQuery exposes the query to create the schema for testing.
 func GetCreateSchemaQuery() string {
 	return createSchemaQuery
@@ -14,3 +33,112 @@ func GetCreateSchemaQuery() string {
 func SetCreateSchemaQuery(query string) {
 	createSchemaQuery = query
 }
+
+// IndexNames returns the names of the indexes of the database for testing.
+func (m *Manager) IndexNames() ([]string, error) {
+	rows, err := m.db.Query(`SELECT name FROM sqlite_master WHERE type = 'index'`)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// QueryPlan returns the details of the query plan of the query for testing.
+func (m *Manager) QueryPlan(query string) (string, error) {
+	rows, err := m.db.Query("EXPLAIN QUERY PLAN " + query)
+	if err != nil {
+		return "", err
+	}
+	defer closeRows(rows)
+
+	var plan string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return "", err
+		}
+		plan += detail + "\n"
+	}
+	return plan, rows.Err()
+}
+
+// Pragma returns the value of the pragma for testing.
+func (m *Manager) Pragma(name string) (string, error) {
+	var value string
+	err := m.db.QueryRow("PRAGMA " + name).Scan(&value)
+	return value, err
+}
+
+// SchemaVersion returns the version of the schema of new and migrated databases for testing.
+func SchemaVersion() int {
+	return schemaVersion
+}
+
+// SchemaMigrationVersions returns the versions of the schema migrations, in the order they are applied, for testing.
+func SchemaMigrationVersions() []int {
+	var versions []int
+	for _, m := range schemaMigrations {
+		versions = append(versions, m.version)
+	}
+	return versions
+}
+
+// SchemaOf returns the columns of the tables and the names of the indexes of the database at path, for testing.
+func SchemaOf(path string) (map[string][]string, error) {
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.Query(`SELECT type, name FROM sqlite_master WHERE name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	schema := make(map[string][]string)
+	var tables []string
+	for rows.Next() {
+		var kind, name string
+		if err := rows.Scan(&kind, &name); err != nil {
+			return nil, err
+		}
+		key := fmt.Sprintf("%s %s", kind, name)
+		schema[key] = nil
+		if kind == "table" {
+			tables = append(tables, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, table := range tables {
+		columns, err := conn.Query(`SELECT name FROM pragma_table_info(?)`, table)
+		if err != nil {
+			return nil, err
+		}
+		for columns.Next() {
+			var column string
+			if err := columns.Scan(&column); err != nil {
+				closeRows(columns)
+				return nil, err
+			}
+			schema["table "+table] = append(schema["table "+table], column)
+		}
+		closeRows(columns)
+	}
+	return schema, nil
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 )
 
 // GroupRow represents a group in the database.
@@ -19,6 +20,20 @@ type GroupWithMembers struct {
 	Users    []string
 }
 
+const (
+	groupByIDQuery   = `SELECT name, gid, ugid FROM groups WHERE gid = ?`
+	groupByNameQuery = `SELECT name, gid, ugid FROM groups WHERE name = ?`
+	// groupsWithMembersPageQuery returns the groups with a GID greater than the first parameter, at most the second
+	// parameter of them (or all of them if it's -1), with one row per member. Groups without members are returned
+	// in a single row with a NULL member name.
+	groupsWithMembersPageQuery = `
+		SELECT g.name, g.gid, g.ugid, u.name
+		FROM (SELECT name, gid, ugid FROM groups WHERE gid > ? ORDER BY gid LIMIT ?) g
+		LEFT JOIN users_to_groups ug ON ug.gid = g.gid
+		LEFT JOIN users u ON u.uid = ug.uid
+		ORDER BY g.gid, ug.uid`
+)
+
 type userToGroupRow struct {
 	UID uint32
 	GID uint32
@@ -35,12 +50,13 @@ func NewGroupRow(name string, gid uint32, ugid string) GroupRow {
 
 // GroupByID returns the group with the given group ID or a NoDataFoundError if no group was found.
 func (m *Manager) GroupByID(gid uint32) (GroupRow, error) {
-	return groupByID(m.db, gid)
+	defer m.observe("GroupByID", time.Now())
+
+	return groupByID(m.reads, gid)
 }
 
 func groupByID(db queryable, gid uint32) (GroupRow, error) {
-	query := `SELECT name, gid, ugid FROM groups WHERE gid = ?`
-	row := db.QueryRow(query, gid)
+	row := db.QueryRow(groupByIDQuery, gid)
 
 	var g GroupRow
 	err := row.Scan(&g.Name, &g.GID, &g.UGID)
@@ -56,8 +72,10 @@ func groupByID(db queryable, gid uint32) (GroupRow, error) {
 
 // GroupWithMembersByID returns the group with the given group ID with a list of users that are members of the group.
 func (m *Manager) GroupWithMembersByID(gid uint32) (_ GroupWithMembers, err error) {
+	defer m.observe("GroupWithMembersByID", time.Now())
+
 	// Start a transaction to receive the group row and its members in a single transaction
-	tx, err := m.db.Begin()
+	tx, err := m.readDB.Begin()
 	if err != nil {
 		return GroupWithMembers{}, fmt.Errorf("failed to start transaction: %w", err)
 	}
@@ -67,12 +85,12 @@ func (m *Manager) GroupWithMembersByID(gid uint32) (_ GroupWithMembers, err erro
 		err = commitOrRollBackTransaction(err, tx)
 	}()
 
-	group, err := groupByID(tx, gid)
+	group, err := groupByID(m.reads.inTx(tx), gid)
 	if err != nil {
 		return GroupWithMembers{}, err
 	}
 
-	users, err := getGroupMembers(tx, gid)
+	users, err := getGroupMembers(m.reads.inTx(tx), gid)
 	if err != nil {
 		return GroupWithMembers{}, err
 	}
@@ -82,12 +100,13 @@ func (m *Manager) GroupWithMembersByID(gid uint32) (_ GroupWithMembers, err erro
 
 // GroupByName returns the group with the given name or a NoDataFoundError if no group was found.
 func (m *Manager) GroupByName(name string) (GroupRow, error) {
-	return groupByName(m.db, name)
+	defer m.observe("GroupByName", time.Now())
+
+	return groupByName(m.reads, name)
 }
 
 func groupByName(db queryable, name string) (GroupRow, error) {
-	query := `SELECT name, gid, ugid FROM groups WHERE name = ?`
-	row := db.QueryRow(query, name)
+	row := db.QueryRow(groupByNameQuery, name)
 
 	var g GroupRow
 	err := row.Scan(&g.Name, &g.GID, &g.UGID)
@@ -103,8 +122,10 @@ func groupByName(db queryable, name string) (GroupRow, error) {
 
 // GroupWithMembersByName returns the group with the given name with a list of users that are members of the group.
 func (m *Manager) GroupWithMembersByName(name string) (_ GroupWithMembers, err error) {
+	defer m.observe("GroupWithMembersByName", time.Now())
+
 	// Start a transaction to receive the group row and its members in a single transaction
-	tx, err := m.db.Begin()
+	tx, err := m.readDB.Begin()
 	if err != nil {
 		return GroupWithMembers{}, fmt.Errorf("failed to start transaction: %w", err)
 	}
@@ -114,12 +135,12 @@ func (m *Manager) GroupWithMembersByName(name string) (_ GroupWithMembers, err e
 		err = commitOrRollBackTransaction(err, tx)
 	}()
 
-	group, err := groupByName(tx, name)
+	group, err := groupByName(m.reads.inTx(tx), name)
 	if err != nil {
 		return GroupWithMembers{}, err
 	}
 
-	users, err := getGroupMembers(tx, group.GID)
+	users, err := getGroupMembers(m.reads.inTx(tx), group.GID)
 	if err != nil {
 		return GroupWithMembers{}, err
 	}
@@ -129,6 +150,8 @@ func (m *Manager) GroupWithMembersByName(name string) (_ GroupWithMembers, err e
 
 // GroupByUGID returns the group with the given UGID or a NoDataFoundError if no group was found.
 func (m *Manager) GroupByUGID(ugid string) (GroupRow, error) {
+	defer m.observe("GroupByUGID", time.Now())
+
 	return groupByUGID(m.db, ugid)
 }
 
@@ -148,35 +171,53 @@ func groupByUGID(db queryable, ugid string) (GroupRow, error) {
 	return g, nil
 }
 
-// AllGroupsWithMembers returns all groups with their members.
-func (m *Manager) AllGroupsWithMembers() (_ []GroupWithMembers, err error) {
-	// Start a transaction to receive all groups and their members in a single transaction
-	tx, err := m.db.Begin()
-	if err != nil {
-		return nil, fmt.Errorf("failed to start transaction: %w", err)
-	}
+// AllGroupsWithMembers returns all groups with their members, ordered by GID.
+func (m *Manager) AllGroupsWithMembers() ([]GroupWithMembers, error) {
+	defer m.observe("AllGroupsWithMembers", time.Now())
 
-	// Ensure the transaction is committed or rolled back
-	defer func() {
-		err = commitOrRollBackTransaction(err, tx)
-	}()
+	return groupsWithMembersPage(m.reads, 0, -1)
+}
+
+// GroupsWithMembersPage returns at most limit groups with a GID greater than afterGID with their members, ordered
+// by GID.
+func (m *Manager) GroupsWithMembersPage(afterGID uint32, limit int) ([]GroupWithMembers, error) {
+	defer m.observe("GroupsWithMembersPage", time.Now())
+
+	return groupsWithMembersPage(m.reads, afterGID, limit)
+}
 
-	groups, err := allGroups(tx)
+// groupsWithMembersPage gets the groups and their members in a single query, as running one query per group to
+// get its members gets slow with thousands of groups.
+func groupsWithMembersPage(db queryable, afterGID uint32, limit int) ([]GroupWithMembers, error) {
+	rows, err := db.Query(groupsWithMembersPageQuery, afterGID, limit)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("query error: %w", err)
 	}
+	defer closeRows(rows)
 
-	var res []GroupWithMembers
-	for _, g := range groups {
-		users, err := getGroupMembers(tx, g.GID)
-		if err != nil {
-			return nil, err
+	var groups []GroupWithMembers
+	for rows.Next() {
+		var g GroupRow
+		var member sql.NullString
+		if err := rows.Scan(&g.Name, &g.GID, &g.UGID, &member); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+
+		if len(groups) == 0 || groups[len(groups)-1].GID != g.GID {
+			groups = append(groups, GroupWithMembers{GroupRow: g})
+		}
+		if member.Valid {
+			last := &groups[len(groups)-1]
+			last.Users = append(last.Users, member.String)
 		}
+	}
 
-		res = append(res, GroupWithMembers{GroupRow: g, Users: users})
+	// Check for errors from iteration
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
 	}
 
-	return res, nil
+	return groups, nil
 }
 
 // allGroups returns all groups from the database.
@@ -263,6 +304,8 @@ func updateGroupByID(db queryable, g GroupRow) error {
 
 // DeleteGroup removes the group from the database.
 func (m *Manager) DeleteGroup(gid uint32) error {
+	defer m.observe("DeleteGroup", time.Now())
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IDAllocationKind is the kind of entity an ID is allocated to.
+type IDAllocationKind string
+
+const (
+	// UserIDAllocation is the kind of the UIDs allocated to users, identified by their name.
+	UserIDAllocation IDAllocationKind = "user"
+	// GroupIDAllocation is the kind of the GIDs allocated to groups, identified by their UGID.
+	GroupIDAllocation IDAllocationKind = "group"
+)
+
+// IDAllocationRow represents an ID allocated by this instance to a user or a group.
+type IDAllocationRow struct {
+	Kind IDAllocationKind
+	Key  string
+	ID   uint32
+}
+
+// IDAllocation returns the ID allocated to the user or group with the given key. It returns a NoDataFoundError if no
+// ID is allocated to it.
+func (m *Manager) IDAllocation(kind IDAllocationKind, key string) (uint32, error) {
+	defer m.observe("IDAllocation", time.Now())
+
+	var id uint32
+	row := m.db.QueryRow(`SELECT id FROM id_allocations WHERE kind = ? AND key = ?`, kind, key)
+	err := row.Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, NoDataFoundError{fmt.Sprintf("no ID allocated to %s %q", kind, key)}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("query error: %w", err)
+	}
+	return id, nil
+}
+
+// AddIDAllocation records the ID allocated to the user or group with the given key.
+func (m *Manager) AddIDAllocation(kind IDAllocationKind, key string, id uint32) error {
+	defer m.observe("AddIDAllocation", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `INSERT INTO id_allocations (kind, key, id) VALUES (?, ?, ?)`
+	if _, err := m.db.Exec(query, kind, key, id); err != nil {
+		return fmt.Errorf("failed to record allocated ID: %w", err)
+	}
+	return nil
+}
+
+// AllocatedIDs returns all the IDs allocated to users and groups.
+func (m *Manager) AllocatedIDs() ([]uint32, error) {
+	defer m.observe("AllocatedIDs", time.Now())
+
+	rows, err := allIDAllocations(m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint32, 0, len(rows))
+	for _, r := range rows {
+		ids = append(ids, r.ID)
+	}
+	return ids, nil
+}
+
+func allIDAllocations(db queryable) ([]IDAllocationRow, error) {
+	rows, err := db.Query(`SELECT kind, key, id FROM id_allocations ORDER BY kind, key`)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	var allocations []IDAllocationRow
+	for rows.Next() {
+		var r IDAllocationRow
+		if err := rows.Scan(&r.Kind, &r.Key, &r.ID); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		allocations = append(allocations, r)
+	}
+
+	// Check for errors from iteration
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return allocations, nil
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+// DefaultSlowQueryThreshold is the default duration above which the database operations are logged.
+const DefaultSlowQueryThreshold = 250 * time.Millisecond
+
+// LatencyBuckets are the upper bounds of the buckets of the latency histograms. The NSS lookups usually take less than
+// a millisecond, while a login can time out if the database takes seconds to answer, e.g. on slow storage.
+var LatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// OperationLatency is the histogram of the durations of a database operation.
+type OperationLatency struct {
+	Operation string
+	Count     uint64
+	// Sum is the total duration of the operations.
+	Sum time.Duration
+	// Buckets are the number of operations which took at most the duration of the bucket of LatencyBuckets with the
+	// same index. The operations which took longer than the last bucket are only in Count.
+	Buckets []uint64
+}
+
+// latencyRecorder records the durations of the database operations and logs the slow ones.
+type latencyRecorder struct {
+	slowQueryThreshold time.Duration
+
+	mu         sync.Mutex
+	operations map[string]*OperationLatency
+}
+
+func newLatencyRecorder(slowQueryThreshold time.Duration) *latencyRecorder {
+	return &latencyRecorder{slowQueryThreshold: slowQueryThreshold, operations: make(map[string]*OperationLatency)}
+}
+
+// observe records the duration of the operation which started at start. It's meant to be deferred at the beginning
+// of the operation.
+func (r *latencyRecorder) observe(operation string, start time.Time) {
+	elapsed := time.Since(start)
+
+	r.mu.Lock()
+	l, ok := r.operations[operation]
+	if !ok {
+		l = &OperationLatency{Operation: operation, Buckets: make([]uint64, len(LatencyBuckets))}
+		r.operations[operation] = l
+	}
+	l.Count++
+	l.Sum += elapsed
+	for i, bound := range LatencyBuckets {
+		if elapsed <= bound {
+			l.Buckets[i]++
+		}
+	}
+	r.mu.Unlock()
+
+	if r.slowQueryThreshold > 0 && elapsed >= r.slowQueryThreshold {
+		log.Warningf(context.Background(), "Slow database operation: %s took %s", operation, elapsed.Round(time.Millisecond))
+	}
+}
+
+// latencies returns a copy of the histograms of the operations, sorted by operation.
+func (r *latencyRecorder) latencies() []OperationLatency {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := make([]OperationLatency, 0, len(r.operations))
+	for _, l := range r.operations {
+		c := *l
+		c.Buckets = slices.Clone(l.Buckets)
+		res = append(res, c)
+	}
+	slices.SortFunc(res, func(a, b OperationLatency) int { return strings.Compare(a.Operation, b.Operation) })
+	return res
+}
+
+// observe records the duration of the database operation which started at start.
+func (m *Manager) observe(operation string, start time.Time) {
+	m.latency.observe(operation, start)
+}
+
+// Latencies returns the histograms of the durations of the database operations run since the database was opened.
+func (m *Manager) Latencies() []OperationLatency {
+	return m.latency.latencies()
+}
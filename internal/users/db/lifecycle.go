@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+// LifecycleRow represents the deprovisioning state of a user in the database.
+// Users without a row are active.
+type LifecycleRow struct {
+	UID       uint32
+	State     string
+	ChangedAt int64 `yaml:"changed_at,omitempty"` // Unix time in seconds.
+}
+
+// SetUserLifecycle sets the deprovisioning state of the user.
+func (m *Manager) SetUserLifecycle(uid uint32, state string, changedAt int64) error {
+	defer m.observe("SetUserLifecycle", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	log.Debugf(context.Background(), "Setting lifecycle state of UID %d to %q", uid, state)
+	query := `INSERT INTO user_lifecycle (uid, state, changed_at) VALUES (?, ?, ?)
+		ON CONFLICT(uid) DO UPDATE SET state = excluded.state, changed_at = excluded.changed_at`
+	if _, err := m.db.Exec(query, uid, state, changedAt); err != nil {
+		return fmt.Errorf("failed to set lifecycle state: %w", err)
+	}
+	return nil
+}
+
+// DeleteUserLifecycle removes the deprovisioning state of the user, which makes it active again.
+func (m *Manager) DeleteUserLifecycle(uid uint32) error {
+	defer m.observe("DeleteUserLifecycle", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.db.Exec(`DELETE FROM user_lifecycle WHERE uid = ?`, uid); err != nil {
+		return fmt.Errorf("failed to remove lifecycle state: %w", err)
+	}
+	return nil
+}
+
+// UserLifecycle returns the deprovisioning state of the user. It returns a NoDataFoundError if the user is active.
+func (m *Manager) UserLifecycle(uid uint32) (LifecycleRow, error) {
+	defer m.observe("UserLifecycle", time.Now())
+
+	var r LifecycleRow
+	row := m.db.QueryRow(`SELECT uid, state, changed_at FROM user_lifecycle WHERE uid = ?`, uid)
+	err := row.Scan(&r.UID, &r.State, &r.ChangedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return LifecycleRow{}, NoDataFoundError{fmt.Sprintf("no lifecycle state for UID %d", uid)}
+	}
+	if err != nil {
+		return LifecycleRow{}, fmt.Errorf("query error: %w", err)
+	}
+	return r, nil
+}
+
+// AllUserLifecycles returns the deprovisioning states of all the users which are not active.
+func (m *Manager) AllUserLifecycles() ([]LifecycleRow, error) {
+	defer m.observe("AllUserLifecycles", time.Now())
+
+	return allUserLifecycles(m.db)
+}
+
+func allUserLifecycles(db queryable) ([]LifecycleRow, error) {
+	rows, err := db.Query(`SELECT uid, state, changed_at FROM user_lifecycle ORDER BY uid`)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	var lifecycles []LifecycleRow
+	for rows.Next() {
+		var r LifecycleRow
+		if err := rows.Scan(&r.UID, &r.State, &r.ChangedAt); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		lifecycles = append(lifecycles, r)
+	}
+
+	// Check for errors from iteration
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return lifecycles, nil
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+// LinkedIdentityRow represents an identity of a user at another broker than the one they're bound to, e.g. their
+// account at the provider they're migrating to. The provider ID is empty until they authenticate with the broker.
+type LinkedIdentityRow struct {
+	UID        uint32 `yaml:"uid"`
+	BrokerID   string `yaml:"broker_id"`
+	ProviderID string `yaml:"provider_id"`
+}
+
+// LinkIdentity allows the user to authenticate with the broker, in addition to the broker they're bound to.
+func (m *Manager) LinkIdentity(uid uint32, brokerID string) error {
+	defer m.observe("LinkIdentity", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	log.Debugf(context.Background(), "Linking the identity of UID %d at broker %q", uid, brokerID)
+	query := `INSERT INTO linked_identities (uid, broker_id, provider_id) VALUES (?, ?, '') ON CONFLICT DO NOTHING`
+	if _, err := m.db.Exec(query, uid, brokerID); err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	return nil
+}
+
+// UnlinkIdentity removes the identity of the user at the broker.
+func (m *Manager) UnlinkIdentity(uid uint32, brokerID string) error {
+	defer m.observe("UnlinkIdentity", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res, err := m.db.Exec(`DELETE FROM linked_identities WHERE uid = ? AND broker_id = ?`, uid, brokerID)
+	if err != nil {
+		return fmt.Errorf("failed to unlink identity: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return newLinkedIdentityNotFoundError(uid, brokerID)
+	}
+
+	return nil
+}
+
+// ConfirmLinkedIdentity records the provider ID of the identity of the user at the broker, once they authenticated
+// with it. The provider ID of a confirmed identity doesn't change.
+func (m *Manager) ConfirmLinkedIdentity(uid uint32, brokerID, providerID string) error {
+	defer m.observe("ConfirmLinkedIdentity", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `UPDATE linked_identities SET provider_id = ? WHERE uid = ? AND broker_id = ? AND provider_id = ''`
+	if _, err := m.db.Exec(query, providerID, uid, brokerID); err != nil {
+		return fmt.Errorf("failed to confirm linked identity: %w", err)
+	}
+
+	return nil
+}
+
+// LinkedIdentity returns the identity of the user at the broker or a NoDataFoundError if none is linked.
+func (m *Manager) LinkedIdentity(uid uint32, brokerID string) (LinkedIdentityRow, error) {
+	defer m.observe("LinkedIdentity", time.Now())
+
+	query := `SELECT uid, broker_id, provider_id FROM linked_identities WHERE uid = ? AND broker_id = ?`
+	var r LinkedIdentityRow
+	err := m.db.QueryRow(query, uid, brokerID).Scan(&r.UID, &r.BrokerID, &r.ProviderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return LinkedIdentityRow{}, newLinkedIdentityNotFoundError(uid, brokerID)
+	}
+	if err != nil {
+		return LinkedIdentityRow{}, fmt.Errorf("query error: %w", err)
+	}
+
+	return r, nil
+}
+
+// LinkedIdentities returns the identities linked to the user, ordered by broker ID.
+func (m *Manager) LinkedIdentities(uid uint32) ([]LinkedIdentityRow, error) {
+	defer m.observe("LinkedIdentities", time.Now())
+
+	rows, err := m.db.Query(`SELECT uid, broker_id, provider_id FROM linked_identities WHERE uid = ? ORDER BY broker_id`, uid)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	return scanLinkedIdentities(rows)
+}
+
+func newLinkedIdentityNotFoundError(uid uint32, brokerID string) NoDataFoundError {
+	return NoDataFoundError{fmt.Sprintf("no identity of UID %d linked at broker %q", uid, brokerID)}
+}
+
+func allLinkedIdentities(db queryable) ([]LinkedIdentityRow, error) {
+	rows, err := db.Query(`SELECT uid, broker_id, provider_id FROM linked_identities ORDER BY uid, broker_id`)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	return scanLinkedIdentities(rows)
+}
+
+func scanLinkedIdentities(rows *sql.Rows) ([]LinkedIdentityRow, error) {
+	var identities []LinkedIdentityRow
+	for rows.Next() {
+		var r LinkedIdentityRow
+		if err := rows.Scan(&r.UID, &r.BrokerID, &r.ProviderID); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		identities = append(identities, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return identities, nil
+}
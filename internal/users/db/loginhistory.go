@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+// MaxLoginHistoryEntries is the maximum number of login records kept per user.
+// Older records are pruned when a new one is added.
+const MaxLoginHistoryEntries = 100
+
+// LoginRecordRow represents a row of the login history of a user in the database.
+type LoginRecordRow struct {
+	UID       uint32
+	Timestamp int64 `yaml:"timestamp,omitempty"` // Unix time in seconds.
+	Service   string
+	BrokerID  string `yaml:"broker_id"`
+	Offline   bool   `yaml:"offline,omitempty"`
+	Result    string
+	// MachineToken is true if the user authenticated with a machine token, which is neither an online nor an offline
+	// login with their broker.
+	MachineToken bool `yaml:"machine_token,omitempty"`
+}
+
+// NewLoginRecordRow creates a new LoginRecordRow for the given user at the given time.
+func NewLoginRecordRow(uid uint32, t time.Time, service, brokerID string, offline bool, result string) LoginRecordRow {
+	return LoginRecordRow{
+		UID:       uid,
+		Timestamp: t.Unix(),
+		Service:   service,
+		BrokerID:  brokerID,
+		Offline:   offline,
+		Result:    result,
+	}
+}
+
+// AddLoginRecord stores a new login record for the user and prunes the oldest records
+// so that at most MaxLoginHistoryEntries are kept for that user.
+func (m *Manager) AddLoginRecord(r LoginRecordRow) (err error) {
+	defer m.observe("AddLoginRecord", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	// Ensure the transaction is committed or rolled back
+	defer func() {
+		err = commitOrRollBackTransaction(err, tx)
+	}()
+
+	log.Debugf(context.Background(), "Adding login record for UID %d: %s", r.UID, r.Result)
+	query := `INSERT INTO login_history (uid, timestamp, service, broker_id, offline, result, machine_token)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	if _, err := tx.Exec(query, r.UID, r.Timestamp, r.Service, r.BrokerID, r.Offline, r.Result, r.MachineToken); err != nil {
+		return fmt.Errorf("insert login record error: %w", err)
+	}
+
+	query = `DELETE FROM login_history WHERE uid = ? AND id NOT IN (
+		SELECT id FROM login_history WHERE uid = ? ORDER BY timestamp DESC, id DESC LIMIT ?
+	)`
+	if _, err := tx.Exec(query, r.UID, r.UID, MaxLoginHistoryEntries); err != nil {
+		return fmt.Errorf("failed to prune login history: %w", err)
+	}
+
+	return nil
+}
+
+// LoginHistory returns the most recent login records of the user, newest first.
+// If limit is 0, all stored records are returned.
+func (m *Manager) LoginHistory(uid uint32, limit uint32) ([]LoginRecordRow, error) {
+	defer m.observe("LoginHistory", time.Now())
+
+	return loginHistory(m.db, uid, limit)
+}
+
+func loginHistory(db queryable, uid uint32, limit uint32) ([]LoginRecordRow, error) {
+	query := `SELECT uid, timestamp, service, broker_id, offline, result, machine_token FROM login_history
+		WHERE uid = ? ORDER BY timestamp DESC, id DESC`
+	args := []any{uid}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	var records []LoginRecordRow
+	for rows.Next() {
+		var r LoginRecordRow
+		if err := rows.Scan(&r.UID, &r.Timestamp, &r.Service, &r.BrokerID, &r.Offline, &r.Result, &r.MachineToken); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		records = append(records, r)
+	}
+
+	// Check for errors from iteration
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return records, nil
+}
+
+// LastLoginsByBroker returns the time of the last login with the given mode and result, by broker ID. The brokers
+// without such a login are not in the map. The logins with a machine token are left out, as the brokers are not
+// involved in them.
+func (m *Manager) LastLoginsByBroker(offline bool, result string) (map[string]time.Time, error) {
+	defer m.observe("LastLoginsByBroker", time.Now())
+
+	rows, err := m.db.Query(`SELECT broker_id, MAX(timestamp) FROM login_history
+		WHERE offline = ? AND result = ? AND NOT machine_token GROUP BY broker_id`, offline, result)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	logins := make(map[string]time.Time)
+	for rows.Next() {
+		var brokerID string
+		var timestamp int64
+		if err := rows.Scan(&brokerID, &timestamp); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		logins[brokerID] = time.Unix(timestamp, 0)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return logins, nil
+}
+
+// LastLogins returns the time of the last login with the given result, by UID. The users without such a login are not
+// in the map.
+func (m *Manager) LastLogins(result string) (map[uint32]time.Time, error) {
+	defer m.observe("LastLogins", time.Now())
+
+	rows, err := m.db.Query(`SELECT uid, MAX(timestamp) FROM login_history WHERE result = ? GROUP BY uid`, result)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	logins := make(map[uint32]time.Time)
+	for rows.Next() {
+		var uid uint32
+		var timestamp int64
+		if err := rows.Scan(&uid, &timestamp); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		logins[uid] = time.Unix(timestamp, 0)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return logins, nil
+}
+
+func allLoginRecords(db queryable) ([]LoginRecordRow, error) {
+	rows, err := db.Query(`SELECT uid, timestamp, service, broker_id, offline, result, machine_token FROM login_history
+		ORDER BY uid, timestamp, id`)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	var records []LoginRecordRow
+	for rows.Next() {
+		var r LoginRecordRow
+		if err := rows.Scan(&r.UID, &r.Timestamp, &r.Service, &r.BrokerID, &r.Offline, &r.Result, &r.MachineToken); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		records = append(records, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return records, nil
+}
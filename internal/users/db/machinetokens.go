@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+// MachineTokenRow represents the token with which automation authenticates as a user in the database.
+type MachineTokenRow struct {
+	UID uint32 `yaml:"uid"`
+	// Hash is the SHA-256 hash of the token, encoded in base64.
+	Hash string `yaml:"hash"`
+	// ExpiresAt is the Unix time at which the token expires, 0 if it never does.
+	ExpiresAt int64 `yaml:"expires_at,omitempty"`
+}
+
+// SetMachineToken sets the hash of the machine token of the user, replacing the previous one.
+func (m *Manager) SetMachineToken(uid uint32, hash string, expiresAt int64) error {
+	defer m.observe("SetMachineToken", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	log.Debugf(context.Background(), "Setting machine token of UID %d", uid)
+	query := `INSERT INTO machine_tokens (uid, hash, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT(uid) DO UPDATE SET hash = excluded.hash, expires_at = excluded.expires_at`
+	if _, err := m.db.Exec(query, uid, hash, expiresAt); err != nil {
+		return fmt.Errorf("failed to set machine token: %w", err)
+	}
+	return nil
+}
+
+// DeleteMachineToken removes the machine token of the user. It returns a NoDataFoundError if the user has none.
+func (m *Manager) DeleteMachineToken(uid uint32) error {
+	defer m.observe("DeleteMachineToken", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res, err := m.db.Exec(`DELETE FROM machine_tokens WHERE uid = ?`, uid)
+	if err != nil {
+		return fmt.Errorf("failed to remove machine token: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return newMachineTokenNotFoundError(uid)
+	}
+	return nil
+}
+
+// MachineToken returns the machine token of the user. It returns a NoDataFoundError if the user has none.
+func (m *Manager) MachineToken(uid uint32) (MachineTokenRow, error) {
+	defer m.observe("MachineToken", time.Now())
+
+	var r MachineTokenRow
+	row := m.db.QueryRow(`SELECT uid, hash, expires_at FROM machine_tokens WHERE uid = ?`, uid)
+	err := row.Scan(&r.UID, &r.Hash, &r.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return MachineTokenRow{}, newMachineTokenNotFoundError(uid)
+	}
+	if err != nil {
+		return MachineTokenRow{}, fmt.Errorf("query error: %w", err)
+	}
+	return r, nil
+}
+
+func newMachineTokenNotFoundError(uid uint32) NoDataFoundError {
+	return NoDataFoundError{fmt.Sprintf("no machine token for UID %d", uid)}
+}
+
+func allMachineTokens(db queryable) ([]MachineTokenRow, error) {
+	rows, err := db.Query(`SELECT uid, hash, expires_at FROM machine_tokens ORDER BY uid`)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	var tokenRows []MachineTokenRow
+	for rows.Next() {
+		var r MachineTokenRow
+		if err := rows.Scan(&r.UID, &r.Hash, &r.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		tokenRows = append(tokenRows, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return tokenRows, nil
+}
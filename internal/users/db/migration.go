@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/canonical/authd/internal/decorate"
@@ -11,14 +12,22 @@ import (
 	"github.com/canonical/authd/log"
 )
 
+// schemaMigration is a step from the previous schema version to the next one.
 type schemaMigration struct {
+	// version is the schema version of the database once the migration is applied.
+	version     int
 	description string
 	migrate     func(*Manager) error
+	// rollback reverts the migration, keeping the data of the tables and columns which existed before it. It's nil if
+	// the migration can't be reverted, in which case the database can only be restored from the backup made before it.
+	rollback func(tx queryable) error
 }
 
 var schemaMigrations = []schemaMigration{
 	{
+		version:     1,
 		description: "Migrate to lowercase user and group names",
+		// The original case of the names is lost, so this migration can't be reverted.
 		migrate: func(m *Manager) (err error) {
 			// Start a transaction to ensure atomicity
 			tx, err := m.db.Begin()
@@ -65,6 +74,7 @@ var schemaMigrations = []schemaMigration{
 		},
 	},
 	{
+		version:     2,
 		description: "Add column 'locked' to users table",
 		migrate: func(m *Manager) (err error) {
 			// Start a transaction to ensure atomicity
@@ -97,8 +107,13 @@ var schemaMigrations = []schemaMigration{
 
 			return nil
 		},
+		rollback: func(tx queryable) error {
+			_, err := tx.Exec("ALTER TABLE users DROP COLUMN locked")
+			return err
+		},
 	},
 	{
+		version:     3,
 		description: "Add column 'provider_id' to users table for stable provider identifier",
 		migrate: func(m *Manager) (err error) {
 			tx, err := m.db.Begin()
@@ -135,6 +150,270 @@ var schemaMigrations = []schemaMigration{
 			}
 			return nil
 		},
+		rollback: func(tx queryable) error {
+			_, err := tx.Exec(`DROP INDEX IF EXISTS "idx_user_broker_provider_id";
+				ALTER TABLE users DROP COLUMN provider_id;`)
+			return err
+		},
+	},
+	{
+		version:     4,
+		description: "Add table 'login_history' to store the recent logins of users",
+		migrate: func(m *Manager) error {
+			_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS login_history (
+				id        INTEGER PRIMARY KEY AUTOINCREMENT,
+				uid       INT NOT NULL,
+				timestamp INT NOT NULL,
+				service   TEXT DEFAULT '',
+				broker_id TEXT DEFAULT '',
+				offline   BOOLEAN DEFAULT FALSE,
+				result    TEXT NOT NULL,
+				FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS "idx_login_history_uid" ON login_history ("uid", "timestamp");`)
+			if err != nil {
+				return fmt.Errorf("failed to create 'login_history' table: %w", err)
+			}
+			return nil
+		},
+		rollback: func(tx queryable) error {
+			_, err := tx.Exec("DROP TABLE IF EXISTS login_history")
+			return err
+		},
+	},
+	{
+		version:     5,
+		description: "Add indexes on the GIDs of users and group memberships",
+		migrate: func(m *Manager) error {
+			_, err := m.db.Exec(`CREATE INDEX IF NOT EXISTS "idx_user_gid" ON users ("gid");
+			CREATE INDEX IF NOT EXISTS "idx_users_to_groups_gid" ON users_to_groups ("gid");`)
+			if err != nil {
+				return fmt.Errorf("failed to create GID indexes: %w", err)
+			}
+			return nil
+		},
+		rollback: func(tx queryable) error {
+			_, err := tx.Exec(`DROP INDEX IF EXISTS "idx_user_gid";
+				DROP INDEX IF EXISTS "idx_users_to_groups_gid";`)
+			return err
+		},
+	},
+	{
+		version:     6,
+		description: "Add table 'session_env' to store the environment variables of the sessions of users",
+		migrate: func(m *Manager) error {
+			_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS session_env (
+				uid   INT NOT NULL,
+				name  TEXT NOT NULL,
+				value TEXT NOT NULL,
+				PRIMARY KEY (uid, name),
+				FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+			);`)
+			if err != nil {
+				return fmt.Errorf("failed to create 'session_env' table: %w", err)
+			}
+			return nil
+		},
+		rollback: func(tx queryable) error {
+			_, err := tx.Exec("DROP TABLE IF EXISTS session_env")
+			return err
+		},
+	},
+	{
+		version:     7,
+		description: "Add table 'user_lifecycle' to store the deprovisioning state of users",
+		migrate: func(m *Manager) error {
+			_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS user_lifecycle (
+				uid        INT PRIMARY KEY,
+				state      TEXT NOT NULL,
+				changed_at INT NOT NULL,
+				FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+			);`)
+			if err != nil {
+				return fmt.Errorf("failed to create 'user_lifecycle' table: %w", err)
+			}
+			return nil
+		},
+		rollback: func(tx queryable) error {
+			_, err := tx.Exec("DROP TABLE IF EXISTS user_lifecycle")
+			return err
+		},
+	},
+	{
+		version:     8,
+		description: "Add table 'deleted_users' to store the home directories left behind by deleted users",
+		migrate: func(m *Manager) error {
+			_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS deleted_users (
+				name       TEXT PRIMARY KEY,
+				uid        INT NOT NULL,
+				dir        TEXT NOT NULL,
+				deleted_at INT NOT NULL
+			);`)
+			if err != nil {
+				return fmt.Errorf("failed to create 'deleted_users' table: %w", err)
+			}
+			return nil
+		},
+		rollback: func(tx queryable) error {
+			_, err := tx.Exec("DROP TABLE IF EXISTS deleted_users")
+			return err
+		},
+	},
+	{
+		version:     9,
+		description: "Add table 'id_allocations' to store the IDs allocated to the users and groups of other instances",
+		migrate: func(m *Manager) error {
+			_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS id_allocations (
+				kind TEXT NOT NULL,
+				key  TEXT NOT NULL,
+				id   INT NOT NULL,
+				PRIMARY KEY (kind, key)
+			);`)
+			if err != nil {
+				return fmt.Errorf("failed to create 'id_allocations' table: %w", err)
+			}
+			return nil
+		},
+		rollback: func(tx queryable) error {
+			_, err := tx.Exec("DROP TABLE IF EXISTS id_allocations")
+			return err
+		},
+	},
+	{
+		version:     10,
+		description: "Add table 'user_names' to store the secondary names of users",
+		migrate: func(m *Manager) error {
+			_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS user_names (
+				name TEXT PRIMARY KEY,
+				uid  INT NOT NULL,
+				FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+			);`)
+			if err != nil {
+				return fmt.Errorf("failed to create 'user_names' table: %w", err)
+			}
+			return nil
+		},
+		rollback: func(tx queryable) error {
+			_, err := tx.Exec("DROP TABLE IF EXISTS user_names")
+			return err
+		},
+	},
+	{
+		version:     11,
+		description: "Add table 'linked_identities' to store the identities of users at other brokers",
+		migrate: func(m *Manager) error {
+			_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS linked_identities (
+				uid         INT NOT NULL,
+				broker_id   TEXT NOT NULL,
+				provider_id TEXT NOT NULL DEFAULT '',
+				PRIMARY KEY (uid, broker_id),
+				FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+			);
+			CREATE UNIQUE INDEX IF NOT EXISTS "idx_linked_identities_provider_id" ON linked_identities ("broker_id", "provider_id") WHERE provider_id != '';`)
+			if err != nil {
+				return fmt.Errorf("failed to create 'linked_identities' table: %w", err)
+			}
+			return nil
+		},
+		rollback: func(tx queryable) error {
+			_, err := tx.Exec("DROP TABLE IF EXISTS linked_identities")
+			return err
+		},
+	},
+	{
+		version:     12,
+		description: "Add table 'ssh_keys' to store the SSH public keys of users",
+		migrate: func(m *Manager) error {
+			_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS ssh_keys (
+				uid INT NOT NULL,
+				key TEXT NOT NULL,
+				PRIMARY KEY (uid, key),
+				FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+			);`)
+			if err != nil {
+				return fmt.Errorf("failed to create 'ssh_keys' table: %w", err)
+			}
+			return nil
+		},
+		rollback: func(tx queryable) error {
+			_, err := tx.Exec("DROP TABLE IF EXISTS ssh_keys")
+			return err
+		},
+	},
+	{
+		version:     13,
+		description: "Add table 'totp_secrets' to store the TOTP secrets of users",
+		migrate: func(m *Manager) error {
+			_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS totp_secrets (
+				uid       INT PRIMARY KEY,
+				secret    TEXT NOT NULL,
+				last_step INT NOT NULL DEFAULT 0,
+				FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+			);`)
+			if err != nil {
+				return fmt.Errorf("failed to create 'totp_secrets' table: %w", err)
+			}
+			return nil
+		},
+		rollback: func(tx queryable) error {
+			_, err := tx.Exec("DROP TABLE IF EXISTS totp_secrets")
+			return err
+		},
+	},
+	{
+		version:     14,
+		description: "Add table 'auth_modes' to store the last authentication mode of users",
+		migrate: func(m *Manager) error {
+			_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS auth_modes (
+				uid       INT PRIMARY KEY,
+				broker_id TEXT NOT NULL,
+				auth_mode TEXT NOT NULL,
+				FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+			);`)
+			if err != nil {
+				return fmt.Errorf("failed to create 'auth_modes' table: %w", err)
+			}
+			return nil
+		},
+		rollback: func(tx queryable) error {
+			_, err := tx.Exec("DROP TABLE IF EXISTS auth_modes")
+			return err
+		},
+	},
+	{
+		version:     15,
+		description: "Add table 'machine_tokens' to store the tokens with which automation authenticates as users",
+		migrate: func(m *Manager) error {
+			_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS machine_tokens (
+				uid        INT PRIMARY KEY,
+				hash       TEXT NOT NULL,
+				expires_at INT NOT NULL DEFAULT 0,
+				FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+			);`)
+			if err != nil {
+				return fmt.Errorf("failed to create 'machine_tokens' table: %w", err)
+			}
+			return nil
+		},
+		rollback: func(tx queryable) error {
+			_, err := tx.Exec("DROP TABLE IF EXISTS machine_tokens")
+			return err
+		},
+	},
+	{
+		version:     16,
+		description: "Add column 'machine_token' to table 'login_history'",
+		migrate: func(m *Manager) error {
+			_, err := m.db.Exec("ALTER TABLE login_history ADD COLUMN machine_token BOOLEAN DEFAULT FALSE")
+			if err != nil {
+				return fmt.Errorf("failed to add column 'machine_token' to 'login_history' table: %w", err)
+			}
+			return nil
+		},
+		rollback: func(tx queryable) error {
+			_, err := tx.Exec("ALTER TABLE login_history DROP COLUMN machine_token")
+			return err
+		},
 	},
 }
 
@@ -144,34 +423,100 @@ func (m *Manager) maybeApplyMigrations() error {
 		return err
 	}
 
-	if currentVersion >= len(schemaMigrations) {
+	if currentVersion >= schemaVersion {
 		return nil
 	}
 
 	log.Debugf(context.Background(), "Schema version before migrations: %d", currentVersion)
 
-	v := 0
+	// The backup lets the administrators revert the migrations if the new version of authd doesn't work for them.
+	if err := m.backUp(currentVersion); err != nil {
+		return fmt.Errorf("failed to back up database before applying schema migrations: %w", err)
+	}
+
 	for _, migration := range schemaMigrations {
-		v++
-		if currentVersion >= v {
+		if currentVersion >= migration.version {
 			continue
 		}
 
-		log.Infof(context.Background(), "Applying schema migration: %s", migration.description)
+		log.Infof(context.Background(), "Applying schema migration %d: %s", migration.version, migration.description)
 		if err := migration.migrate(m); err != nil {
 			return fmt.Errorf("error applying schema migration: %w", err)
 		}
 
-		if err := setSchemaVersion(m.db, v); err != nil {
+		if err := setSchemaVersion(m.db, migration.version); err != nil {
 			return fmt.Errorf("failed to update schema version: %w", err)
 		}
 	}
 
-	log.Debugf(context.Background(), "Schema version after migrations: %d", v)
+	log.Debugf(context.Background(), "Schema version after migrations: %d", schemaVersion)
 
 	return nil
 }
 
+// RollBack reverts the schema migrations applied by the last upgrade of authd, down to the schema version of the
+// database backed up before them, so that the previous version of authd can use the database. The users and groups
+// are kept with their IDs, including the ones added since the upgrade.
+//
+// The database can't be used by this version of authd anymore once it's rolled back, so it's closed.
+func (m *Manager) RollBack() (from, to int, err error) {
+	defer decorate.OnError(&err, "could not roll back the database")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	defer func() {
+		if err != nil {
+			return
+		}
+		if closeErr := m.Close(); closeErr != nil {
+			log.Warningf(context.Background(), "Could not close the rolled back database: %v", closeErr)
+		}
+	}()
+
+	from, err = getSchemaVersion(m.db)
+	if err != nil {
+		return 0, 0, err
+	}
+	backup, to, err := m.latestBackup(from)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Check that all the migrations can be reverted before reverting any of them.
+	var migrations []schemaMigration
+	for _, migration := range slices.Backward(schemaMigrations) {
+		if migration.version <= to || migration.version > from {
+			continue
+		}
+		if migration.rollback == nil {
+			return 0, 0, fmt.Errorf("schema migration %d (%s) can't be reverted, stop authd and restore the backup %s instead",
+				migration.version, migration.description, backup)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		err = commitOrRollBackTransaction(err, tx)
+	}()
+
+	for _, migration := range migrations {
+		log.Infof(context.Background(), "Reverting schema migration %d: %s", migration.version, migration.description)
+		if err := migration.rollback(tx); err != nil {
+			return 0, 0, fmt.Errorf("error reverting schema migration %d: %w", migration.version, err)
+		}
+	}
+	if _, err := tx.Exec(`UPDATE schema_version SET version = ?`, to); err != nil {
+		return 0, 0, fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return from, to, nil
+}
+
 // renameUsersInGroupFile renames users in the /etc/group file.
 func renameUsersInGroupFile(oldNames, newNames []string) (err error) {
 	decorate.OnError(&err, "failed to rename users in local groups: %v -> %v",
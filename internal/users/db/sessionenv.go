@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+// SessionEnvRow represents an environment variable of the sessions of a user in the database.
+type SessionEnvRow struct {
+	UID   uint32
+	Name  string
+	Value string
+}
+
+// SetSessionEnv replaces the environment variables of the sessions of the user.
+func (m *Manager) SetSessionEnv(uid uint32, env map[string]string) (err error) {
+	defer m.observe("SetSessionEnv", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	// Ensure the transaction is committed or rolled back
+	defer func() {
+		err = commitOrRollBackTransaction(err, tx)
+	}()
+
+	if _, err := tx.Exec(`DELETE FROM session_env WHERE uid = ?`, uid); err != nil {
+		return fmt.Errorf("failed to remove session environment: %w", err)
+	}
+
+	log.Debugf(context.Background(), "Setting %d session environment variables for UID %d", len(env), uid)
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		query := `INSERT INTO session_env (uid, name, value) VALUES (?, ?, ?)`
+		if _, err := tx.Exec(query, uid, name, env[name]); err != nil {
+			return fmt.Errorf("insert session environment error: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// SessionEnv returns the environment variables of the sessions of the user.
+func (m *Manager) SessionEnv(uid uint32) (map[string]string, error) {
+	defer m.observe("SessionEnv", time.Now())
+
+	rows, err := m.db.Query(`SELECT uid, name, value FROM session_env WHERE uid = ?`, uid)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	env := make(map[string]string)
+	for rows.Next() {
+		var r SessionEnvRow
+		if err := rows.Scan(&r.UID, &r.Name, &r.Value); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		env[r.Name] = r.Value
+	}
+
+	// Check for errors from iteration
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return env, nil
+}
+
+func allSessionEnv(db queryable) ([]SessionEnvRow, error) {
+	rows, err := db.Query(`SELECT uid, name, value FROM session_env ORDER BY uid, name`)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	var envRows []SessionEnvRow
+	for rows.Next() {
+		var r SessionEnvRow
+		if err := rows.Scan(&r.UID, &r.Name, &r.Value); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		envRows = append(envRows, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return envRows, nil
+}
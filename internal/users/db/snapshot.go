@@ -0,0 +1,203 @@
+package db
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/canonical/authd/internal/consts"
+	"github.com/canonical/authd/log"
+)
+
+// snapshotSuffix is the suffix of the compressed snapshots of the database.
+const snapshotSuffix = ".gz"
+
+// snapshotTimeFormat is the format of the time at which a snapshot was made, in its file name.
+const snapshotTimeFormat = "20060102T150405Z"
+
+// Snapshot is a compressed copy of the database.
+type Snapshot struct {
+	Path string
+	Time time.Time
+}
+
+// Snapshot writes a consistent and compressed copy of the database to dir, named after now.
+func (m *Manager) Snapshot(dir string, now time.Time) (s Snapshot, err error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return Snapshot{}, err
+	}
+
+	now = now.UTC().Truncate(time.Second)
+	s = Snapshot{
+		Path: filepath.Join(dir, consts.DefaultDatabaseFileName+"."+now.Format(snapshotTimeFormat)+snapshotSuffix),
+		Time: now,
+	}
+
+	// VACUUM INTO copies the database in a single read transaction, so the copy is consistent even if the database is
+	// updated meanwhile. It only writes to a new or empty file, which is created first so that it's only readable by
+	// root.
+	tmp, err := os.CreateTemp(dir, ".snapshot-*")
+	if err != nil {
+		return Snapshot{}, err
+	}
+	_ = tmp.Close()
+	defer os.Remove(tmp.Name())
+	if _, err := m.db.Exec(`VACUUM INTO ?`, tmp.Name()); err != nil {
+		return Snapshot{}, fmt.Errorf("could not copy the database: %w", err)
+	}
+
+	// Compress to a temporary file first, so that an interrupted snapshot does not leave a truncated one behind.
+	partial := s.Path + ".tmp"
+	if err := compressFile(partial, tmp.Name()); err != nil {
+		_ = os.Remove(partial)
+		return Snapshot{}, fmt.Errorf("could not compress the snapshot: %w", err)
+	}
+	if err := os.Rename(partial, s.Path); err != nil {
+		_ = os.Remove(partial)
+		return Snapshot{}, err
+	}
+	return s, nil
+}
+
+// Snapshots returns the snapshots of the database in dir, the most recent first.
+func Snapshots(dir string) ([]Snapshot, error) {
+	prefix := filepath.Join(dir, consts.DefaultDatabaseFileName+".")
+	paths, err := filepath.Glob(prefix + "*" + snapshotSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, p := range paths {
+		t, err := time.Parse(snapshotTimeFormat, strings.TrimSuffix(strings.TrimPrefix(p, prefix), snapshotSuffix))
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{Path: p, Time: t})
+	}
+	slices.SortFunc(snapshots, func(a, b Snapshot) int { return b.Time.Compare(a.Time) })
+	return snapshots, nil
+}
+
+// RemoveOldSnapshots removes the snapshots of the database in dir, except for the keep most recent ones.
+func RemoveOldSnapshots(dir string, keep int) error {
+	snapshots, err := Snapshots(dir)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) <= keep {
+		return nil
+	}
+
+	var errs []error
+	for _, s := range snapshots[keep:] {
+		log.Debugf(context.Background(), "Removing old database snapshot %s", s.Path)
+		if err := os.Remove(s.Path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckSnapshot returns the schema version of the database in the snapshot, or an error if the snapshot can't be
+// restored.
+func CheckSnapshot(path string) (version int, err error) {
+	// The snapshot is decompressed next to it, as the temporary directory may be too small for the database.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".check-*")
+	if err != nil {
+		return 0, err
+	}
+	_ = tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := decompressFile(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("could not decompress the snapshot: %w", err)
+	}
+
+	conn, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", tmp.Name()))
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var result string
+	if err := conn.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return 0, fmt.Errorf("could not check the integrity of the snapshot: %w", err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("the snapshot is corrupted: %s", result)
+	}
+
+	version, err = getSchemaVersion(conn)
+	if err != nil {
+		return 0, err
+	}
+	if version > schemaVersion {
+		return 0, fmt.Errorf("the snapshot has the schema version %d, which is newer than the one of this version of authd (%d)", version, schemaVersion)
+	}
+	return version, nil
+}
+
+// compressFile writes src compressed with gzip to dst.
+func compressFile(dst, src string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	gw := gzip.NewWriter(out)
+	if _, err := io.Copy(gw, in); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// decompressFile writes the gzip compressed src decompressed to dst.
+func decompressFile(dst, src string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	gr, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	_, err = io.Copy(out, gr)
+	return err
+}
@@ -11,6 +11,7 @@ CREATE TABLE IF NOT EXISTS users (
 );
 CREATE UNIQUE INDEX "idx_user_name" ON users ("name");
 CREATE UNIQUE INDEX "idx_user_broker_provider_id" ON users ("broker_id", "provider_id") WHERE broker_id != "" AND provider_id != "";
+CREATE INDEX "idx_user_gid" ON users ("gid");
 
 CREATE TABLE IF NOT EXISTS groups (
     name TEXT NOT NULL,  -- Uniqueness is enforced by the index below
@@ -27,6 +28,8 @@ CREATE TABLE IF NOT EXISTS users_to_groups (
     FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE,
     FOREIGN KEY (gid) REFERENCES groups (gid) ON DELETE CASCADE
 );
+-- The primary key only allows to look up the groups of a user, this index is used to look up the members of a group.
+CREATE INDEX "idx_users_to_groups_gid" ON users_to_groups ("gid");
 
 CREATE TABLE IF NOT EXISTS users_to_local_groups (
     uid        INT NOT NULL,
@@ -35,6 +38,91 @@ CREATE TABLE IF NOT EXISTS users_to_local_groups (
     FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
 );
 
+CREATE TABLE IF NOT EXISTS login_history (
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    uid       INT NOT NULL,
+    timestamp INT NOT NULL, -- Unix time in seconds
+    service   TEXT DEFAULT "",
+    broker_id TEXT DEFAULT "",
+    offline   BOOLEAN DEFAULT FALSE,
+    result    TEXT NOT NULL,
+    machine_token BOOLEAN DEFAULT FALSE,
+    FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+);
+CREATE INDEX "idx_login_history_uid" ON login_history ("uid", "timestamp");
+
+CREATE TABLE IF NOT EXISTS session_env (
+    uid   INT NOT NULL,
+    name  TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (uid, name),
+    FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS user_lifecycle (
+    uid        INT PRIMARY KEY,
+    state      TEXT NOT NULL,
+    changed_at INT NOT NULL,
+    FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS deleted_users (
+    name       TEXT PRIMARY KEY,
+    uid        INT NOT NULL,
+    dir        TEXT NOT NULL,
+    deleted_at INT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS id_allocations (
+    kind TEXT NOT NULL, -- "user" or "group"
+    key  TEXT NOT NULL, -- The name of the user or the UGID of the group
+    id   INT NOT NULL,
+    PRIMARY KEY (kind, key)
+);
+
+CREATE TABLE IF NOT EXISTS user_names (
+    name TEXT PRIMARY KEY, -- A secondary name of the user, e.g. their name before being renamed at the provider
+    uid  INT NOT NULL,
+    FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS linked_identities (
+    uid         INT NOT NULL,
+    broker_id   TEXT NOT NULL,            -- Another broker with which the user can authenticate
+    provider_id TEXT NOT NULL DEFAULT "", -- Empty until the user authenticated with the broker
+    PRIMARY KEY (uid, broker_id),
+    FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_linked_identities_provider_id" ON linked_identities ("broker_id", "provider_id") WHERE provider_id != "";
+
+CREATE TABLE IF NOT EXISTS ssh_keys (
+    uid INT NOT NULL,
+    key TEXT NOT NULL, -- A line of an authorized_keys file, without options
+    PRIMARY KEY (uid, key),
+    FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS totp_secrets (
+    uid       INT PRIMARY KEY,
+    secret    TEXT NOT NULL,          -- The TOTP secret of the user, encrypted by the users manager and base64 encoded
+    last_step INT NOT NULL DEFAULT 0, -- The time step of the last accepted code, which can't be used again
+    FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS auth_modes (
+    uid       INT PRIMARY KEY,
+    broker_id TEXT NOT NULL, -- The broker with which the user last logged in
+    auth_mode TEXT NOT NULL, -- The authentication mode the user selected first on their last login
+    FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS machine_tokens (
+    uid        INT PRIMARY KEY,
+    hash       TEXT NOT NULL,          -- The SHA-256 hash of the token, base64 encoded
+    expires_at INT NOT NULL DEFAULT 0, -- Unix time at which the token expires, 0 if it never does
+    FOREIGN KEY (uid) REFERENCES users (uid) ON DELETE CASCADE
+);
+
 CREATE TABLE IF NOT EXISTS schema_version (
     version INT PRIMARY KEY
 );
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+// SSHKeyRow represents an SSH public key of a user in the database.
+type SSHKeyRow struct {
+	UID uint32 `yaml:"uid"`
+	Key string `yaml:"key"`
+}
+
+// SetSSHKeys replaces the SSH public keys of the user.
+func (m *Manager) SetSSHKeys(uid uint32, keys []string) (err error) {
+	defer m.observe("SetSSHKeys", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	// Ensure the transaction is committed or rolled back
+	defer func() {
+		err = commitOrRollBackTransaction(err, tx)
+	}()
+
+	if _, err := tx.Exec(`DELETE FROM ssh_keys WHERE uid = ?`, uid); err != nil {
+		return fmt.Errorf("failed to remove SSH keys: %w", err)
+	}
+
+	log.Debugf(context.Background(), "Setting %d SSH keys for UID %d", len(keys), uid)
+	for _, key := range slices.Compact(slices.Sorted(slices.Values(keys))) {
+		if _, err := tx.Exec(`INSERT INTO ssh_keys (uid, key) VALUES (?, ?)`, uid, key); err != nil {
+			return fmt.Errorf("insert SSH key error: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// SSHKeys returns the SSH public keys of the user.
+func (m *Manager) SSHKeys(uid uint32) ([]string, error) {
+	defer m.observe("SSHKeys", time.Now())
+
+	rows, err := m.db.Query(`SELECT key FROM ssh_keys WHERE uid = ? ORDER BY key`, uid)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	// Check for errors from iteration
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return keys, nil
+}
+
+func allSSHKeys(db queryable) ([]SSHKeyRow, error) {
+	rows, err := db.Query(`SELECT uid, key FROM ssh_keys ORDER BY uid, key`)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	var keyRows []SSHKeyRow
+	for rows.Next() {
+		var r SSHKeyRow
+		if err := rows.Scan(&r.UID, &r.Key); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		keyRows = append(keyRows, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return keyRows, nil
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// statementCache prepares the queries it runs once and reuses the prepared statements afterwards, which avoids
+// parsing and planning the frequent NSS lookups again on each request. It implements queryable.
+//
+// As the database only has one connection, statements can't be prepared while a transaction is running on it.
+// Transactions therefore only use the statements which were already prepared, see inTx.
+type statementCache struct {
+	db    *sql.DB
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+func newStatementCache(db *sql.DB) *statementCache {
+	return &statementCache{db: db, stmts: make(map[string]*sql.Stmt)}
+}
+
+// prepare returns the prepared statement for the query, preparing it if needed.
+func (c *statementCache) prepare(query string) (*sql.Stmt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if stmt, ok := c.stmts[query]; ok {
+		return stmt, nil
+	}
+
+	stmt, err := c.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
+
+// prepareAll prepares the queries, so that they can be used in transactions.
+func (c *statementCache) prepareAll(queries ...string) error {
+	for _, query := range queries {
+		if _, err := c.prepare(query); err != nil {
+			return fmt.Errorf("failed to prepare query %q: %w", query, err)
+		}
+	}
+	return nil
+}
+
+// cached returns the prepared statement for the query or nil if it was not prepared yet.
+func (c *statementCache) cached(query string) *sql.Stmt {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stmts[query]
+}
+
+// Exec executes the prepared query with the given arguments.
+func (c *statementCache) Exec(query string, args ...any) (sql.Result, error) {
+	stmt, err := c.prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Exec(args...)
+}
+
+// QueryRow executes the prepared query, which is expected to return at most one row.
+func (c *statementCache) QueryRow(query string, args ...any) *sql.Row {
+	stmt, err := c.prepare(query)
+	if err != nil {
+		// *sql.Row can't be created with an error, so let the database return the preparation error on Scan.
+		return c.db.QueryRow(query, args...)
+	}
+	return stmt.QueryRow(args...)
+}
+
+// Query executes the prepared query with the given arguments.
+func (c *statementCache) Query(query string, args ...any) (*sql.Rows, error) {
+	stmt, err := c.prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Query(args...)
+}
+
+// inTx returns a queryable running the already prepared statements in the transaction. Other queries are run
+// without being prepared.
+func (c *statementCache) inTx(tx *sql.Tx) queryable {
+	return txStatements{tx: tx, cache: c}
+}
+
+// close closes all the prepared statements.
+func (c *statementCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for query, stmt := range c.stmts {
+		_ = stmt.Close()
+		delete(c.stmts, query)
+	}
+}
+
+// txStatements runs the statements of a statementCache in a transaction.
+type txStatements struct {
+	tx    *sql.Tx
+	cache *statementCache
+}
+
+func (t txStatements) Exec(query string, args ...any) (sql.Result, error) {
+	if stmt := t.cache.cached(query); stmt != nil {
+		return t.tx.Stmt(stmt).Exec(args...)
+	}
+	return t.tx.Exec(query, args...)
+}
+
+func (t txStatements) QueryRow(query string, args ...any) *sql.Row {
+	if stmt := t.cache.cached(query); stmt != nil {
+		return t.tx.Stmt(stmt).QueryRow(args...)
+	}
+	return t.tx.QueryRow(query, args...)
+}
+
+func (t txStatements) Query(query string, args ...any) (*sql.Rows, error) {
+	if stmt := t.cache.cached(query); stmt != nil {
+		return t.tx.Stmt(stmt).Query(args...)
+	}
+	return t.tx.Query(query, args...)
+}
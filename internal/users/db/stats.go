@@ -0,0 +1,51 @@
+package db
+
+import (
+	"errors"
+	"os"
+
+	"github.com/canonical/authd/internal/decorate"
+)
+
+// Stats are statistics about the database.
+type Stats struct {
+	Path string
+	// Size is the size in bytes of the database file and of its write-ahead log.
+	Size          int64
+	SchemaVersion uint32
+	Users         uint32
+	LockedUsers   uint32
+	Groups        uint32
+	// Latencies are the histograms of the durations of the operations run since the database was opened.
+	Latencies []OperationLatency
+}
+
+// Stats returns statistics about the database.
+func (m *Manager) Stats() (s Stats, err error) {
+	defer decorate.OnError(&err, "could not get database statistics")
+
+	s.Path = m.path
+	for _, suffix := range []string{"", "-wal"} {
+		fi, err := os.Stat(m.path + suffix)
+		if suffix != "" && errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return s, err
+		}
+		s.Size += fi.Size()
+	}
+
+	query := `SELECT
+		(SELECT version FROM schema_version ORDER BY version DESC LIMIT 1),
+		(SELECT COUNT(*) FROM users),
+		(SELECT COUNT(*) FROM users WHERE locked = TRUE),
+		(SELECT COUNT(*) FROM groups)`
+	if err := m.db.QueryRow(query).Scan(&s.SchemaVersion, &s.Users, &s.LockedUsers, &s.Groups); err != nil {
+		return s, err
+	}
+
+	s.Latencies = m.Latencies()
+
+	return s, nil
+}
@@ -28,7 +28,7 @@ func Z_ForTests_DumpNormalizedYAML(c *Manager) (string, error) {
 	testsdetection.MustBeTesting()
 
 	// Get all users
-	users, err := allUsers(c.db)
+	users, err := usersPage(c.db, 0, -1)
 	if err != nil {
 		return "", err
 	}
@@ -63,6 +63,90 @@ func Z_ForTests_DumpNormalizedYAML(c *Manager) (string, error) {
 		return userGroups[i].UID < userGroups[j].UID
 	})
 
+	// Get all login records, they are already sorted by UID and timestamp.
+	loginRecords, err := allLoginRecords(c.db)
+	if err != nil {
+		return "", err
+	}
+
+	// Remove the timestamps of the login records, as they are usually set
+	// to the current time and would change on each run.
+	for i := range loginRecords {
+		loginRecords[i].Timestamp = 0
+	}
+
+	// Get all session environment variables, they are already sorted by UID and name.
+	sessionEnv, err := allSessionEnv(c.db)
+	if err != nil {
+		return "", err
+	}
+
+	// Get all lifecycle states, they are already sorted by UID.
+	lifecycles, err := allUserLifecycles(c.db)
+	if err != nil {
+		return "", err
+	}
+
+	// Remove the timestamps of the state changes, as they are usually set
+	// to the current time and would change on each run.
+	for i := range lifecycles {
+		lifecycles[i].ChangedAt = 0
+	}
+
+	// Get all deleted users, they are already sorted by deletion time and name.
+	deletedUsers, err := allDeletedUsers(c.db)
+	if err != nil {
+		return "", err
+	}
+
+	// Remove the deletion times, as they are usually set to the current time
+	// and would change on each run.
+	for i := range deletedUsers {
+		deletedUsers[i].DeletedAt = 0
+	}
+
+	// Get all ID allocations, they are already sorted by kind and key.
+	idAllocations, err := allIDAllocations(c.db)
+	if err != nil {
+		return "", err
+	}
+
+	// Get all secondary names of users, they are already sorted by UID and name.
+	userNames, err := allUserNames(c.db)
+	if err != nil {
+		return "", err
+	}
+
+	// Get all linked identities, they are already sorted by UID and broker ID.
+	linkedIdentities, err := allLinkedIdentities(c.db)
+	if err != nil {
+		return "", err
+	}
+
+	// Get all SSH keys, they are already sorted by UID and key.
+	sshKeys, err := allSSHKeys(c.db)
+	if err != nil {
+		return "", err
+	}
+
+	// Get all TOTP secrets, they are already sorted by UID.
+	totpSecrets, err := allTOTPSecrets(c.db)
+	if err != nil {
+		return "", err
+	}
+
+	// Get all last authentication modes, they are already sorted by UID.
+	authModes, err := allLastAuthModes(c.db)
+	if err != nil {
+		return "", err
+	}
+
+	// Get all machine tokens, they are already sorted by UID.
+	machineTokens, err := allMachineTokens(c.db)
+	if err != nil {
+		return "", err
+	}
+
 	// Get the schema version
 	schemaVersion, err := getSchemaVersion(c.db)
 	if err != nil {
@@ -70,15 +154,37 @@ func Z_ForTests_DumpNormalizedYAML(c *Manager) (string, error) {
 	}
 
 	content := struct {
-		Users         []UserRow        `yaml:"users"`
-		Groups        []GroupRow       `yaml:"groups"`
-		UsersToGroups []userToGroupRow `yaml:"users_to_groups"`
-		SchemaVersion int              `yaml:"schema_version"`
+		Users            []UserRow           `yaml:"users"`
+		Groups           []GroupRow          `yaml:"groups"`
+		UsersToGroups    []userToGroupRow    `yaml:"users_to_groups"`
+		LoginHistory     []LoginRecordRow    `yaml:"login_history,omitempty"`
+		SessionEnv       []SessionEnvRow     `yaml:"session_env,omitempty"`
+		UserLifecycle    []LifecycleRow      `yaml:"user_lifecycle,omitempty"`
+		DeletedUsers     []DeletedUserRow    `yaml:"deleted_users,omitempty"`
+		IDAllocations    []IDAllocationRow   `yaml:"id_allocations,omitempty"`
+		UserNames        []UserNameRow       `yaml:"user_names,omitempty"`
+		LinkedIdentities []LinkedIdentityRow `yaml:"linked_identities,omitempty"`
+		SSHKeys          []SSHKeyRow         `yaml:"ssh_keys,omitempty"`
+		TOTPSecrets      []TOTPSecretRow     `yaml:"totp_secrets,omitempty"`
+		AuthModes        []LastAuthModeRow   `yaml:"auth_modes,omitempty"`
+		MachineTokens    []MachineTokenRow   `yaml:"machine_tokens,omitempty"`
+		SchemaVersion    int                 `yaml:"schema_version"`
 	}{
-		Users:         users,
-		Groups:        groups,
-		UsersToGroups: userGroups,
-		SchemaVersion: schemaVersion,
+		Users:            users,
+		Groups:           groups,
+		UsersToGroups:    userGroups,
+		LoginHistory:     loginRecords,
+		SessionEnv:       sessionEnv,
+		UserLifecycle:    lifecycles,
+		DeletedUsers:     deletedUsers,
+		IDAllocations:    idAllocations,
+		UserNames:        userNames,
+		LinkedIdentities: linkedIdentities,
+		SSHKeys:          sshKeys,
+		TOTPSecrets:      totpSecrets,
+		AuthModes:        authModes,
+		MachineTokens:    machineTokens,
+		SchemaVersion:    schemaVersion,
 	}
 
 	// Marshal the content into a YAML string.
@@ -141,7 +247,7 @@ func createDBFromYAMLReader(r io.Reader, destDir string) (err error) {
 		}
 	}()
 
-	tablesInOrder := []string{"users", "groups", "users_to_groups", "schema_version"}
+	tablesInOrder := []string{"users", "groups", "users_to_groups", "login_history", "session_env", "user_lifecycle", "deleted_users", "id_allocations", "user_names", "linked_identities", "ssh_keys", "totp_secrets", "auth_modes", "machine_tokens", "schema_version"}
 
 	// Insert data
 	for _, table := range tablesInOrder {
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+// TOTPSecretRow represents the TOTP secret of a user in the database.
+type TOTPSecretRow struct {
+	UID uint32 `yaml:"uid"`
+	// Secret is the encrypted secret, encoded in base64.
+	Secret string `yaml:"secret"`
+	// LastStep is the time step of the last code accepted for the user.
+	LastStep int64 `yaml:"last_step,omitempty"`
+}
+
+// SetTOTPSecret sets the encrypted TOTP secret of the user, replacing the previous one.
+func (m *Manager) SetTOTPSecret(uid uint32, secret string) error {
+	defer m.observe("SetTOTPSecret", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	log.Debugf(context.Background(), "Setting TOTP secret of UID %d", uid)
+	query := `INSERT INTO totp_secrets (uid, secret, last_step) VALUES (?, ?, 0)
+		ON CONFLICT(uid) DO UPDATE SET secret = excluded.secret, last_step = excluded.last_step`
+	if _, err := m.db.Exec(query, uid, secret); err != nil {
+		return fmt.Errorf("failed to set TOTP secret: %w", err)
+	}
+	return nil
+}
+
+// DeleteTOTPSecret removes the TOTP secret of the user. It returns a NoDataFoundError if the user has none.
+func (m *Manager) DeleteTOTPSecret(uid uint32) error {
+	defer m.observe("DeleteTOTPSecret", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res, err := m.db.Exec(`DELETE FROM totp_secrets WHERE uid = ?`, uid)
+	if err != nil {
+		return fmt.Errorf("failed to remove TOTP secret: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return newTOTPSecretNotFoundError(uid)
+	}
+	return nil
+}
+
+// TOTPSecret returns the TOTP secret of the user. It returns a NoDataFoundError if the user has none.
+func (m *Manager) TOTPSecret(uid uint32) (TOTPSecretRow, error) {
+	defer m.observe("TOTPSecret", time.Now())
+
+	var r TOTPSecretRow
+	row := m.db.QueryRow(`SELECT uid, secret, last_step FROM totp_secrets WHERE uid = ?`, uid)
+	err := row.Scan(&r.UID, &r.Secret, &r.LastStep)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TOTPSecretRow{}, newTOTPSecretNotFoundError(uid)
+	}
+	if err != nil {
+		return TOTPSecretRow{}, fmt.Errorf("query error: %w", err)
+	}
+	return r, nil
+}
+
+// UseTOTPStep records that the code of the time step was accepted for the user. It returns false if a code of this
+// time step or of a later one was already accepted, in which case the code must be rejected.
+func (m *Manager) UseTOTPStep(uid uint32, step int64) (bool, error) {
+	defer m.observe("UseTOTPStep", time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res, err := m.db.Exec(`UPDATE totp_secrets SET last_step = ? WHERE uid = ? AND last_step < ?`, step, uid, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP time step: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+func newTOTPSecretNotFoundError(uid uint32) NoDataFoundError {
+	return NoDataFoundError{fmt.Sprintf("no TOTP secret for UID %d", uid)}
+}
+
+func allTOTPSecrets(db queryable) ([]TOTPSecretRow, error) {
+	rows, err := db.Query(`SELECT uid, secret, last_step FROM totp_secrets ORDER BY uid`)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	var secretRows []TOTPSecretRow
+	for rows.Next() {
+		var r TOTPSecretRow
+		if err := rows.Scan(&r.UID, &r.Secret, &r.LastStep); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		secretRows = append(secretRows, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return secretRows, nil
+}
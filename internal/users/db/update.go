@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/canonical/authd/log"
 	"github.com/mattn/go-sqlite3"
@@ -11,6 +12,8 @@ import (
 
 // UpdateUserEntry inserts or updates user and group records from the user information.
 func (m *Manager) UpdateUserEntry(user UserRow, authdGroups []GroupRow, localGroups []string) (err error) {
+	defer m.observe("UpdateUserEntry", time.Now())
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -99,6 +102,10 @@ func handleUserUpdate(db queryable, u UserRow) error {
 	// disabled account.
 	u.Locked = existingUser.Locked
 
+	if err := handleUserNamesUpdate(db, u, existingUser.Name); err != nil {
+		return err
+	}
+
 	return insertOrUpdateUserByID(db, u)
 }
 
@@ -191,6 +198,8 @@ func handleUsersToLocalGroupsUpdate(db queryable, uid uint32, localGroups []stri
 
 // UpdateBrokerForUser updates the last broker the user successfully authenticated with.
 func (m *Manager) UpdateBrokerForUser(username, brokerID string) error {
+	defer m.observe("UpdateBrokerForUser", time.Now())
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -212,6 +221,8 @@ func (m *Manager) UpdateBrokerForUser(username, brokerID string) error {
 
 // UpdateLockedFieldForUser sets the "locked" field of a user record.
 func (m *Manager) UpdateLockedFieldForUser(username string, locked bool) error {
+	defer m.observe("UpdateLockedFieldForUser", time.Now())
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -233,6 +244,8 @@ func (m *Manager) UpdateLockedFieldForUser(username string, locked bool) error {
 
 // SetUserID updates the UID of a user.
 func (m *Manager) SetUserID(username string, newUID uint32) error {
+	defer m.observe("SetUserID", time.Now())
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -299,11 +312,63 @@ func (m *Manager) SetUserID(username string, newUID uint32) error {
 		return err
 	}
 
+	// Update the login_history table
+	if _, err := tx.Exec(`UPDATE login_history SET uid = ? WHERE uid = ?`, newUID, oldUID); err != nil {
+		return err
+	}
+
+	// Update the session_env table
+	if _, err := tx.Exec(`UPDATE session_env SET uid = ? WHERE uid = ?`, newUID, oldUID); err != nil {
+		return err
+	}
+
+	// Update the user_names table
+	if _, err := tx.Exec(`UPDATE user_names SET uid = ? WHERE uid = ?`, newUID, oldUID); err != nil {
+		return err
+	}
+
+	// Update the linked_identities table
+	if _, err := tx.Exec(`UPDATE linked_identities SET uid = ? WHERE uid = ?`, newUID, oldUID); err != nil {
+		return err
+	}
+
+	// Update the ssh_keys table
+	if _, err := tx.Exec(`UPDATE ssh_keys SET uid = ? WHERE uid = ?`, newUID, oldUID); err != nil {
+		return err
+	}
+
+	// Update the totp_secrets table
+	if _, err := tx.Exec(`UPDATE totp_secrets SET uid = ? WHERE uid = ?`, newUID, oldUID); err != nil {
+		return err
+	}
+
+	// Update the auth_modes table
+	if _, err := tx.Exec(`UPDATE auth_modes SET uid = ? WHERE uid = ?`, newUID, oldUID); err != nil {
+		return err
+	}
+
+	// Update the machine_tokens table
+	if _, err := tx.Exec(`UPDATE machine_tokens SET uid = ? WHERE uid = ?`, newUID, oldUID); err != nil {
+		return err
+	}
+
+	// Update the user_lifecycle table
+	if _, err := tx.Exec(`UPDATE user_lifecycle SET uid = ? WHERE uid = ?`, newUID, oldUID); err != nil {
+		return err
+	}
+
+	// Update the UID allocated to the user, if this instance is the ID allocation authority
+	if _, err := tx.Exec(`UPDATE id_allocations SET id = ? WHERE kind = ? AND key = ?`, newUID, UserIDAllocation, username); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // SetGroupID updates the GID of a group and returns the list of users whose primary group was updated.
 func (m *Manager) SetGroupID(groupName string, newGID uint32) ([]UserRow, error) {
+	defer m.observe("SetGroupID", time.Now())
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -399,11 +464,18 @@ func (m *Manager) SetGroupID(groupName string, newGID uint32) ([]UserRow, error)
 		return nil, fmt.Errorf("failed to update users_to_groups for GID change: %w", err)
 	}
 
+	// Update the GID allocated to the group, if this instance is the ID allocation authority
+	if _, err := tx.Exec(`UPDATE id_allocations SET id = ? WHERE kind = ? AND key = ?`, newGID, GroupIDAllocation, oldGroup.UGID); err != nil {
+		return nil, err
+	}
+
 	return users, nil
 }
 
 // SetShell updates the shell of a user.
 func (m *Manager) SetShell(username, shell string) error {
+	defer m.observe("SetShell", time.Now())
+
 	query := `UPDATE users SET shell = ? WHERE name = ?`
 	res, err := m.db.Exec(query, shell, username)
 	if err != nil {
@@ -421,6 +493,8 @@ func (m *Manager) SetShell(username, shell string) error {
 
 // SetHomeDir updates the home directory of a user.
 func (m *Manager) SetHomeDir(username, dir string) error {
+	defer m.observe("SetHomeDir", time.Now())
+
 	query := `UPDATE users SET dir = ? WHERE name = ?`
 	res, err := m.db.Exec(query, dir, username)
 	if err != nil {
@@ -3,23 +3,34 @@ package db
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/canonical/authd/log"
 )
 
+const (
+	userGroupsQuery = `
+		SELECT g.name, g.gid, g.ugid
+		FROM users_to_groups ug
+		JOIN groups g ON ug.gid = g.gid
+		WHERE ug.uid = ?`
+	groupMembersQuery = `
+		SELECT u.name
+		FROM users_to_groups ug
+		JOIN users u ON ug.uid = u.uid
+		WHERE ug.gid = ?
+		ORDER BY ug.uid`
+)
+
 // UserGroups returns all groups for a given user or an error if the database is corrupted or no entry was found.
 func (m *Manager) UserGroups(uid uint32) ([]GroupRow, error) {
-	return userGroups(m.db, uid)
+	defer m.observe("UserGroups", time.Now())
+
+	return userGroups(m.reads, uid)
 }
 
 func userGroups(db queryable, uid uint32) ([]GroupRow, error) {
-	query := `
-		SELECT g.name, g.gid, g.ugid 
-		FROM users_to_groups ug
-		JOIN groups g ON ug.gid = g.gid
-		WHERE ug.uid = ?
-	`
-	rows, err := db.Query(query, uid)
+	rows, err := db.Query(userGroupsQuery, uid)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %w", err)
 	}
@@ -50,6 +61,8 @@ func userGroups(db queryable, uid uint32) ([]GroupRow, error) {
 
 // RemoveUserFromGroup removes a user from a group.
 func (m *Manager) RemoveUserFromGroup(uid, gid uint32) error {
+	defer m.observe("RemoveUserFromGroup", time.Now())
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -86,13 +99,7 @@ func allUserGroupsInternal(db queryable) ([]userToGroupRow, error) {
 
 func getGroupMembers(db queryable, gid uint32) ([]string, error) {
 	var members []string
-	query := `
-			SELECT u.name 
-			FROM users_to_groups ug
-			JOIN users u ON ug.uid = u.uid
-			WHERE ug.gid = ?
-		`
-	rows, err := db.Query(query, gid)
+	rows, err := db.Query(groupMembersQuery, gid)
 	if err != nil {
 		return nil, fmt.Errorf("query error while fetching users: %w", err)
 	}
@@ -2,15 +2,20 @@ package db
 
 import (
 	"fmt"
+	"time"
 )
 
 // UserLocalGroups returns all local groups for a given user or an error if the database is corrupted or no entry was found.
 func (m *Manager) UserLocalGroups(uid uint32) ([]string, error) {
-	return userLocalGroups(m.db, uid)
+	defer m.observe("UserLocalGroups", time.Now())
+
+	return userLocalGroups(m.stmts, uid)
 }
 
+const userLocalGroupsQuery = `SELECT group_name FROM users_to_local_groups WHERE uid = ?`
+
 func userLocalGroups(db queryable, uid uint32) ([]string, error) {
-	rows, err := db.Query(`SELECT group_name FROM users_to_local_groups WHERE uid = ?`, uid)
+	rows, err := db.Query(userLocalGroupsQuery, uid)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %w", err)
 	}
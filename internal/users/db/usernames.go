@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+var userBySecondaryNameQuery = fmt.Sprintf(`SELECT %s FROM users WHERE uid = (SELECT uid FROM user_names WHERE name = ?)`, publicUserColumns)
+
+// UserNameRow represents a secondary name of a user in the database, e.g. the name they had at the provider before
+// being renamed there.
+type UserNameRow struct {
+	Name string
+	UID  uint32
+}
+
+// UserBySecondaryName returns the user who has this secondary name or an error if the database is corrupted or no
+// entry was found.
+func (m *Manager) UserBySecondaryName(name string) (UserRow, error) {
+	defer m.observe("UserBySecondaryName", time.Now())
+
+	row := m.reads.QueryRow(userBySecondaryNameQuery, name)
+
+	var u UserRow
+	err := row.Scan(&u.Name, &u.UID, &u.GID, &u.Gecos, &u.Dir, &u.Shell, &u.BrokerID, &u.Locked, &u.ProviderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return UserRow{}, NewUserNotFoundError(name)
+	}
+	if err != nil {
+		return UserRow{}, fmt.Errorf("query error: %w", err)
+	}
+
+	return u, nil
+}
+
+// handleUserNamesUpdate keeps the previous name of a renamed user as one of their secondary names. The name of a user
+// always takes precedence over the secondary names, so it is removed from them.
+func handleUserNamesUpdate(db queryable, u UserRow, previousName string) error {
+	if _, err := db.Exec(`DELETE FROM user_names WHERE name = ?`, u.Name); err != nil {
+		return fmt.Errorf("failed to remove secondary name %q: %w", u.Name, err)
+	}
+
+	if previousName == "" || previousName == u.Name {
+		return nil
+	}
+
+	log.Debugf(context.Background(), "Keeping %q as a secondary name of user %q (UID: %d)", previousName, u.Name, u.UID)
+	query := `INSERT OR REPLACE INTO user_names (name, uid) VALUES (?, ?)`
+	if _, err := db.Exec(query, previousName, u.UID); err != nil {
+		return fmt.Errorf("failed to add secondary name %q: %w", previousName, err)
+	}
+
+	return nil
+}
+
+func allUserNames(db queryable) ([]UserNameRow, error) {
+	rows, err := db.Query(`SELECT name, uid FROM user_names ORDER BY uid, name`)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer closeRows(rows)
+
+	var names []UserNameRow
+	for rows.Next() {
+		var r UserNameRow
+		if err := rows.Scan(&r.Name, &r.UID); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		names = append(names, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return names, nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/canonical/authd/log"
 )
@@ -13,6 +14,14 @@ const allUserColumns = "name, uid, gid, gecos, dir, shell, broker_id, locked, pr
 const publicUserColumns = "name, uid, gid, gecos, dir, shell, broker_id, locked, provider_id"
 const allUserColumnsWithPlaceholders = "name = ?, uid = ?, gid = ?, gecos = ?, dir = ?, shell = ?, broker_id = ?, locked = ?, provider_id = ?"
 
+var (
+	userByIDQuery   = fmt.Sprintf(`SELECT %s FROM users WHERE uid = ?`, publicUserColumns)
+	userByNameQuery = fmt.Sprintf(`SELECT %s FROM users WHERE name = ?`, publicUserColumns)
+	// usersPageQuery returns the users with a UID greater than the first parameter, at most the second parameter
+	// of them (or all of them if it's -1).
+	usersPageQuery = fmt.Sprintf(`SELECT %s FROM users WHERE uid > ? ORDER BY uid LIMIT ?`, allUserColumns)
+)
+
 // UserRow represents a user row in the database.
 type UserRow struct {
 	Name  string
@@ -50,12 +59,13 @@ func NewUserRow(name string, uid, gid uint32, gecos, dir, shell, brokerID, provi
 
 // UserByID returns a user matching this uid or an error if the database is corrupted or no entry was found.
 func (m *Manager) UserByID(uid uint32) (UserRow, error) {
-	return userByID(m.db, uid)
+	defer m.observe("UserByID", time.Now())
+
+	return userByID(m.reads, uid)
 }
 
 func userByID(db queryable, uid uint32) (UserRow, error) {
-	query := fmt.Sprintf(`SELECT %s FROM users WHERE uid = ?`, publicUserColumns)
-	row := db.QueryRow(query, uid)
+	row := db.QueryRow(userByIDQuery, uid)
 
 	var u UserRow
 	err := row.Scan(&u.Name, &u.UID, &u.GID, &u.Gecos, &u.Dir, &u.Shell, &u.BrokerID, &u.Locked, &u.ProviderID)
@@ -71,12 +81,13 @@ func userByID(db queryable, uid uint32) (UserRow, error) {
 
 // UserByName returns a user matching this name or an error if the database is corrupted or no entry was found.
 func (m *Manager) UserByName(name string) (UserRow, error) {
-	return userByName(m.db, name)
+	defer m.observe("UserByName", time.Now())
+
+	return userByName(m.reads, name)
 }
 
 func userByName(db queryable, name string) (UserRow, error) {
-	query := fmt.Sprintf(`SELECT %s FROM users WHERE name = ?`, publicUserColumns)
-	row := db.QueryRow(query, name)
+	row := db.QueryRow(userByNameQuery, name)
 
 	var u UserRow
 	err := row.Scan(&u.Name, &u.UID, &u.GID, &u.Gecos, &u.Dir, &u.Shell, &u.BrokerID, &u.Locked, &u.ProviderID)
@@ -90,14 +101,19 @@ func userByName(db queryable, name string) (UserRow, error) {
 	return u, nil
 }
 
-// UserByProviderID returns a user matching this broker-scoped provider ID or an error if no entry was found.
+// UserByProviderID returns a user matching this broker-scoped provider ID, either as the identity they're bound to or
+// as one of their linked identities, or an error if no entry was found.
 func (m *Manager) UserByProviderID(brokerID, providerID string) (UserRow, error) {
+	defer m.observe("UserByProviderID", time.Now())
+
 	return userByProviderID(m.db, brokerID, providerID)
 }
 
 func userByProviderID(db queryable, brokerID, providerID string) (UserRow, error) {
-	query := fmt.Sprintf(`SELECT %s FROM users WHERE broker_id = ? AND provider_id = ?`, publicUserColumns)
-	row := db.QueryRow(query, brokerID, providerID)
+	query := fmt.Sprintf(`SELECT %s FROM users WHERE broker_id = ? AND provider_id = ?
+		UNION ALL SELECT %[1]s FROM users WHERE uid = (SELECT uid FROM linked_identities WHERE broker_id = ? AND provider_id = ? AND provider_id != '')`,
+		publicUserColumns)
+	row := db.QueryRow(query, brokerID, providerID, brokerID, providerID)
 
 	var u UserRow
 	err := row.Scan(&u.Name, &u.UID, &u.GID, &u.Gecos, &u.Dir, &u.Shell, &u.BrokerID, &u.Locked, &u.ProviderID)
@@ -111,14 +127,22 @@ func userByProviderID(db queryable, brokerID, providerID string) (UserRow, error
 	return u, nil
 }
 
-// AllUsers returns all users or an error if the database is corrupted.
+// AllUsers returns all users ordered by UID or an error if the database is corrupted.
 func (m *Manager) AllUsers() ([]UserRow, error) {
-	return allUsers(m.db)
+	defer m.observe("AllUsers", time.Now())
+
+	return usersPage(m.reads, 0, -1)
 }
 
-func allUsers(db queryable) ([]UserRow, error) {
-	query := fmt.Sprintf(`SELECT %s FROM users`, allUserColumns)
-	rows, err := db.Query(query)
+// UsersPage returns at most limit users with a UID greater than afterUID, ordered by UID.
+func (m *Manager) UsersPage(afterUID uint32, limit int) ([]UserRow, error) {
+	defer m.observe("UsersPage", time.Now())
+
+	return usersPage(m.reads, afterUID, limit)
+}
+
+func usersPage(db queryable, afterUID uint32, limit int) ([]UserRow, error) {
+	rows, err := db.Query(usersPageQuery, afterUID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %w", err)
 	}
@@ -201,6 +225,8 @@ func updateUserByID(db queryable, u UserRow) error {
 
 // DeleteUser removes the user from the database.
 func (m *Manager) DeleteUser(uid uint32) error {
+	defer m.observe("DeleteUser", time.Now())
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -222,6 +248,8 @@ func (m *Manager) DeleteUser(uid uint32) error {
 
 // UsersWithPrimaryGroup returns all users whose primary GID matches the given GID.
 func (m *Manager) UsersWithPrimaryGroup(gid uint32) ([]UserRow, error) {
+	defer m.observe("UsersWithPrimaryGroup", time.Now())
+
 	return usersWithPrimaryGroup(m.db, gid)
 }
 
@@ -252,8 +280,10 @@ func usersWithPrimaryGroup(db queryable, gid uint32) ([]UserRow, error) {
 
 // UserWithGroups returns a user and their groups, including local groups, in a single transaction.
 func (m *Manager) UserWithGroups(name string) (u UserRow, groups []GroupRow, localGroups []string, err error) {
+	defer m.observe("UserWithGroups", time.Now())
+
 	// Start a transaction
-	tx, err := m.db.Begin()
+	tx, err := m.readDB.Begin()
 	if err != nil {
 		return UserRow{}, nil, nil, fmt.Errorf("failed to start transaction: %w", err)
 	}
@@ -263,17 +293,17 @@ func (m *Manager) UserWithGroups(name string) (u UserRow, groups []GroupRow, loc
 		err = commitOrRollBackTransaction(err, tx)
 	}()
 
-	u, err = userByName(tx, name)
+	u, err = userByName(m.reads.inTx(tx), name)
 	if err != nil {
 		return UserRow{}, nil, nil, err
 	}
 
-	groups, err = userGroups(tx, u.UID)
+	groups, err = userGroups(m.reads.inTx(tx), u.UID)
 	if err != nil {
 		return UserRow{}, nil, nil, fmt.Errorf("failed to get groups: %w", err)
 	}
 
-	localGroups, err = userLocalGroups(tx, u.UID)
+	localGroups, err = userLocalGroups(m.reads.inTx(tx), u.UID)
 	if err != nil {
 		return UserRow{}, nil, nil, fmt.Errorf("failed to get local groups: %w", err)
 	}
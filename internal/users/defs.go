@@ -3,6 +3,7 @@ package users
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/canonical/authd/internal/sliceutils"
 	"github.com/canonical/authd/internal/users/db"
@@ -85,3 +86,15 @@ type GroupIsPrimaryError struct {
 func (e GroupIsPrimaryError) Error() string {
 	return fmt.Sprintf("group %q is the primary group of user(s): %s", e.GroupName, strings.Join(e.Users, ", "))
 }
+
+// loginRecordFromRow returns a LoginRecord from a LoginRecordRow.
+func loginRecordFromRow(r db.LoginRecordRow) types.LoginRecord {
+	return types.LoginRecord{
+		Time:         time.Unix(r.Timestamp, 0),
+		Service:      r.Service,
+		BrokerID:     r.BrokerID,
+		Offline:      r.Offline,
+		Result:       r.Result,
+		MachineToken: r.MachineToken,
+	}
+}
@@ -1,6 +1,8 @@
 package users
 
 import (
+	"testing"
+
 	"github.com/canonical/authd/internal/users/db"
 	"github.com/canonical/authd/internal/users/types"
 )
@@ -30,6 +32,24 @@ func (m *Manager) UsersWithPrimaryGroup(gid uint32) ([]string, error) {
 	return m.usersWithPrimaryGroup(gid)
 }
 
+// SetMailSpoolDir sets the directory of the mail spools for the duration of the test.
+func SetMailSpoolDir(t *testing.T, dir string) {
+	t.Helper()
+
+	orig := mailSpoolDir
+	mailSpoolDir = dir
+	t.Cleanup(func() { mailSpoolDir = orig })
+}
+
+// SetHomectlPath sets the path of homectl for the duration of the test.
+func SetHomectlPath(t *testing.T, path string) {
+	t.Helper()
+
+	orig := homectlPath
+	homectlPath = path
+	t.Cleanup(func() { homectlPath = orig })
+}
+
 const (
 	SystemdDynamicUIDMin = systemdDynamicUIDMin
 	SystemdDynamicUIDMax = systemdDynamicUIDMax
@@ -0,0 +1,223 @@
+package users
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/canonical/authd/log"
+)
+
+// archiveHome writes the content of the home directory to a gzip compressed tarball at dst, preserving the
+// ownership, the permissions and the modification times of the files.
+func archiveHome(home, dst string) (err error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so that an interrupted archiving does not leave a truncated archive behind.
+	tmp := dst + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	err = filepath.WalkDir(home, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() && info.Mode()&fs.ModeSymlink == 0 {
+			log.Debugf(context.Background(), "Not archiving special file %q", path)
+			return nil
+		}
+
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(home, path)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		// Don't resolve the names of the owners, which may not exist anymore when the home is restored.
+		hdr.Uname, hdr.Gname = "", ""
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFileTo(tw, path)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// restoreHome extracts the archive created by archiveHome at src into the home directory, which must not exist.
+func restoreHome(src, home string) (err error) {
+	if _, err := os.Lstat(home); err == nil {
+		return fmt.Errorf("home directory %q already exists", home)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(home), 0755); err != nil {
+		return err
+	}
+	defer func() {
+		// The home did not exist before, so only what was partially restored is removed.
+		if err != nil {
+			_ = os.RemoveAll(home)
+		}
+	}()
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	// The permissions of the directories are only applied once their content is extracted, so that read-only
+	// directories can still be restored.
+	type dirModes struct {
+		path string
+		hdr  *tar.Header
+	}
+	var dirs []dirModes
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// The archives are created by authd and only writable by root, this is only a safeguard.
+		if !filepath.IsLocal(hdr.Name) && hdr.Name != "." {
+			return fmt.Errorf("invalid path %q in home archive", hdr.Name)
+		}
+		target := filepath.Join(home, hdr.Name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.Mkdir(target, 0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirModes{path: target, hdr: hdr})
+			continue
+		case tar.TypeSymlink:
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFileFrom(tr, target, hdr.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		default:
+			log.Debugf(context.Background(), "Not restoring %q of unsupported type %q", hdr.Name, hdr.Typeflag)
+			continue
+		}
+
+		if err := os.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeSymlink {
+			if err := os.Chtimes(target, hdr.ModTime, hdr.ModTime); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Apply the permissions of the deepest directories first, as changing the content of a directory updates its
+	// modification time.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if err := os.Lchown(d.path, d.hdr.Uid, d.hdr.Gid); err != nil {
+			return err
+		}
+		if err := os.Chmod(d.path, d.hdr.FileInfo().Mode()&(fs.ModePerm|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+			return err
+		}
+		if err := os.Chtimes(d.path, d.hdr.ModTime, d.hdr.ModTime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyFileTo(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func writeFileFrom(r io.Reader, path string, perm fs.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL|syscall.O_NOFOLLOW, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// The permissions passed to OpenFile are masked by the umask.
+	return os.Chmod(path, perm)
+}
@@ -0,0 +1,266 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/canonical/authd/log"
+	"golang.org/x/sys/unix"
+)
+
+// mailSpoolDir is the directory of the mail spools of the users.
+var mailSpoolDir = "/var/mail"
+
+// HomeCleanupPolicy is what is done with the home directories left behind by deleted users.
+type HomeCleanupPolicy string
+
+const (
+	// HomeCleanupKeep keeps the home directories, they are only reported.
+	HomeCleanupKeep HomeCleanupPolicy = "keep"
+	// HomeCleanupArchive moves the home directories and the mail spools to the archives of authd.
+	HomeCleanupArchive HomeCleanupPolicy = "archive"
+	// HomeCleanupRemove removes the home directories and the mail spools.
+	HomeCleanupRemove HomeCleanupPolicy = "remove"
+)
+
+// HomeCleanupConfig holds the settings of the cleanup of the home directories of deleted users.
+type HomeCleanupConfig struct {
+	// Policy is what is done with the home directories of deleted users once After elapsed.
+	Policy HomeCleanupPolicy `mapstructure:"home_cleanup_policy" yaml:"home_cleanup_policy"`
+	// After is the duration for which the home directories are kept after the deletion of their user.
+	After time.Duration `mapstructure:"home_cleanup_after" yaml:"home_cleanup_after"`
+}
+
+// Scheduled returns true if the home directories of deleted users are cleaned up.
+func (c HomeCleanupConfig) Scheduled() bool {
+	return c.Policy == HomeCleanupArchive || c.Policy == HomeCleanupRemove
+}
+
+func (c HomeCleanupConfig) validate() error {
+	switch c.Policy {
+	case "", HomeCleanupKeep, HomeCleanupArchive, HomeCleanupRemove:
+		return nil
+	}
+	return fmt.Errorf("invalid home cleanup policy %q, must be one of %q, %q or %q", c.Policy, HomeCleanupKeep, HomeCleanupArchive, HomeCleanupRemove)
+}
+
+// HomeCleanup describes the cleanup of the home directory of a deleted user.
+type HomeCleanup struct {
+	Name      string
+	UID       uint32
+	Home      string
+	MailSpool string
+	DeletedAt time.Time
+	// DueAt is when the home directory is cleaned up. It's zero if it's kept.
+	DueAt time.Time
+	// Action is what is done with the home directory and the mail spool.
+	Action HomeCleanupPolicy
+	// Skipped explains why the home directory is left untouched, if it is.
+	Skipped string
+	// Done is true if the cleanup was performed.
+	Done bool
+	// Archive is the path of the archive of the home directory, if it was archived.
+	Archive string
+}
+
+// recordDeletedUser keeps track of the home directory left behind by a deleted user, so that it can be cleaned up
+// later. Failures are only logged, as the user is already deleted.
+func (m *Manager) recordDeletedUser(u db.UserRow) {
+	r := db.DeletedUserRow{Name: u.Name, UID: u.UID, Dir: u.Dir, DeletedAt: time.Now().Unix()}
+	if err := m.db.AddDeletedUser(r); err != nil {
+		log.Warningf(context.Background(), "Could not record home directory %q of deleted user %q: %v", u.Dir, u.Name, err)
+	}
+}
+
+// ForgetDeletedUser stops tracking the home directory left behind by the deleted user with the given name. This is
+// used when there is nothing to clean up, such as for temporary homes.
+func (m *Manager) ForgetDeletedUser(username string) error {
+	return m.db.RemoveDeletedUser(username)
+}
+
+// CleanupHomes cleans up the home directories and the mail spools of the users deleted for longer than the retention
+// window at now, according to the policy. It returns the cleanups of all the deleted users which are tracked. If
+// dryRun is true, nothing is changed.
+func (m *Manager) CleanupHomes(ctx context.Context, now time.Time, dryRun bool) ([]HomeCleanup, error) {
+	m.userManagementMu.Lock()
+	defer m.userManagementMu.Unlock()
+
+	rows, err := m.db.DeletedUsers()
+	if err != nil {
+		return nil, err
+	}
+	// The homes of the current users must not be touched, even if they were used by a deleted user before.
+	currentUsers, err := m.db.AllUsers()
+	if err != nil {
+		return nil, err
+	}
+	homeUsers := make(map[string]string, len(currentUsers))
+	for _, u := range currentUsers {
+		homeUsers[u.Dir] = u.Name
+	}
+
+	policy := m.config.HomeCleanup.Policy
+	if policy == "" {
+		policy = HomeCleanupKeep
+	}
+
+	var cleanups []HomeCleanup
+	var errs []error
+	for _, r := range rows {
+		c := HomeCleanup{
+			Name:      r.Name,
+			UID:       r.UID,
+			Home:      r.Dir,
+			MailSpool: filepath.Join(mailSpoolDir, r.Name),
+			DeletedAt: time.Unix(r.DeletedAt, 0),
+			Action:    policy,
+		}
+		if policy != HomeCleanupKeep {
+			c.DueAt = c.DeletedAt.Add(m.config.HomeCleanup.After)
+		}
+
+		homeExists, spoolExists, skipped := m.checkLeftovers(c, homeUsers)
+		c.Skipped = skipped
+		if !homeExists && !spoolExists {
+			c.Skipped = "nothing left on disk"
+		}
+
+		// Stop tracking the users with nothing left to clean up, or whose home now belongs to another user.
+		forget := !homeExists && !spoolExists || homeUsers[c.Home] != ""
+		due := policy != HomeCleanupKeep && !now.Before(c.DueAt)
+
+		if !dryRun && due && c.Skipped == "" {
+			if err := m.cleanupHome(ctx, &c, homeExists, spoolExists); err != nil {
+				errs = append(errs, fmt.Errorf("could not clean up home directory %q of deleted user %q: %w", c.Home, c.Name, err))
+			} else {
+				c.Done = true
+				forget = true
+			}
+		}
+		if !dryRun && forget {
+			if err := m.db.RemoveDeletedUser(r.Name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		cleanups = append(cleanups, c)
+	}
+
+	return cleanups, errors.Join(errs...)
+}
+
+// checkLeftovers returns whether the home directory and the mail spool of the deleted user still exist, and why they
+// must be left untouched, if they must.
+func (m *Manager) checkLeftovers(c HomeCleanup, homeUsers map[string]string) (homeExists, spoolExists bool, skipped string) {
+	spoolExists = ownedBy(c.MailSpool, c.UID)
+
+	if c.Home == "" || !filepath.IsAbs(c.Home) || filepath.Clean(c.Home) == "/" {
+		return false, spoolExists, ""
+	}
+	if name := homeUsers[c.Home]; name != "" {
+		return true, spoolExists, fmt.Sprintf("home directory of user %q", name)
+	}
+
+	info, err := os.Lstat(c.Home)
+	if err != nil {
+		return false, spoolExists, ""
+	}
+	if !info.IsDir() {
+		return true, spoolExists, "not a directory"
+	}
+	if !ownedBy(c.Home, c.UID) {
+		return true, spoolExists, "not owned by the UID of the deleted user"
+	}
+	if onNetworkFileSystem(c.Home) {
+		// The home is shared with other machines, where the user may still exist.
+		return true, spoolExists, "on a network file system"
+	}
+	return true, spoolExists, ""
+}
+
+// onNetworkFileSystem returns true if the path is on a file system provided by the network, such as NFS.
+func onNetworkFileSystem(path string) bool {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return false
+	}
+	switch uint32(st.Type) {
+	case unix.NFS_SUPER_MAGIC, unix.SMB_SUPER_MAGIC, unix.SMB2_SUPER_MAGIC, unix.CIFS_SUPER_MAGIC,
+		unix.CEPH_SUPER_MAGIC, unix.AFS_SUPER_MAGIC, unix.AFS_FS_MAGIC:
+		return true
+	}
+	return false
+}
+
+// cleanupHome archives or removes the home directory and the mail spool of the deleted user.
+func (m *Manager) cleanupHome(ctx context.Context, c *HomeCleanup, homeExists, spoolExists bool) error {
+	// A user with the same name may be deleted again later, so the archives are named after the deletion time.
+	prefix := filepath.Join(m.homeArchivesDir, c.Name+".deleted-"+strconv.FormatInt(c.DeletedAt.Unix(), 10))
+
+	if homeExists {
+		if c.Action == HomeCleanupArchive {
+			c.Archive = prefix + ".tar.gz"
+			if err := archiveHome(c.Home, c.Archive); err != nil {
+				return err
+			}
+		}
+		if err := os.RemoveAll(c.Home); err != nil {
+			return err
+		}
+		log.Noticef(ctx, "Home directory %q of deleted user %q cleaned up (%s)", c.Home, c.Name, c.Action)
+	}
+
+	if spoolExists {
+		if c.Action == HomeCleanupArchive {
+			if err := copyFile(c.MailSpool, prefix+".mail"); err != nil {
+				return err
+			}
+		}
+		if err := os.Remove(c.MailSpool); err != nil {
+			return err
+		}
+		log.Noticef(ctx, "Mail spool %q of deleted user %q cleaned up (%s)", c.MailSpool, c.Name, c.Action)
+	}
+	return nil
+}
+
+// ownedBy returns true if the file at path exists and is owned by uid.
+func ownedBy(path string, uid uint32) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Uid == uid
+}
+
+// copyFile copies the regular file at src to dst, which is only readable by root.
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,186 @@
+package users_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	localgroupstestutils "github.com/canonical/authd/internal/users/localentries/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanupHomes(t *testing.T) {
+	const deletedUser = "deleted@example.com"
+	deletedHome := "/tmp/authd-delete-user-test/home/" + deletedUser
+	//nolint:gosec // G115 the UID of the current user fits in an uint32.
+	currentUID := uint32(os.Getuid())
+
+	tests := map[string]struct {
+		policy     users.HomeCleanupPolicy
+		deletedAgo time.Duration
+		dryRun     bool
+		uid        uint32
+		dir        string
+		noHome     bool
+		noSpool    bool
+
+		wantSkipped   string
+		wantDone      bool
+		wantArchived  bool
+		wantHomeKept  bool
+		wantForgotten bool
+	}{
+		"Keep_policy_only_reports": {policy: users.HomeCleanupKeep, deletedAgo: 48 * time.Hour, wantHomeKept: true},
+		"Archive_home_and_mail_spool_after_retention": {
+			policy: users.HomeCleanupArchive, deletedAgo: 2 * time.Hour, wantDone: true, wantArchived: true, wantForgotten: true,
+		},
+		"Remove_home_and_mail_spool_after_retention": {
+			policy: users.HomeCleanupRemove, deletedAgo: 2 * time.Hour, wantDone: true, wantForgotten: true,
+		},
+		"Remove_mail_spool_without_home": {
+			policy: users.HomeCleanupRemove, deletedAgo: 2 * time.Hour, noHome: true, wantDone: true, wantForgotten: true,
+		},
+		"Nothing_done_before_retention": {policy: users.HomeCleanupArchive, deletedAgo: 30 * time.Minute, wantHomeKept: true},
+		"Nothing_done_in_dry_run": {
+			policy: users.HomeCleanupRemove, deletedAgo: 2 * time.Hour, dryRun: true, wantHomeKept: true,
+		},
+		"Forget_user_with_nothing_left_on_disk": {
+			policy: users.HomeCleanupRemove, deletedAgo: 2 * time.Hour, noHome: true, noSpool: true,
+			wantSkipped: "nothing left on disk", wantForgotten: true,
+		},
+
+		"Skip_home_not_owned_by_deleted_user": {
+			policy: users.HomeCleanupRemove, deletedAgo: 2 * time.Hour, uid: 4242, noSpool: true,
+			wantSkipped: "not owned by the UID of the deleted user", wantHomeKept: true,
+		},
+		"Skip_and_forget_home_of_current_user": {
+			policy: users.HomeCleanupRemove, deletedAgo: 2 * time.Hour, dir: lifecycleTestHome, noSpool: true,
+			wantSkipped: `home directory of user "user1@example.com"`, wantHomeKept: true, wantForgotten: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.uid == 0 {
+				tc.uid = currentUID
+			}
+			if tc.dir == "" {
+				tc.dir = deletedHome
+			}
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups_with_tmp_home.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+
+			cfg := users.DefaultConfig
+			cfg.HomeCleanup = users.HomeCleanupConfig{Policy: tc.policy, After: time.Hour}
+			m, err := users.NewManager(cfg, dbDir)
+			require.NoError(t, err, "Setup: could not create manager")
+
+			now := time.Now()
+			deletedAt := now.Add(-tc.deletedAgo).Truncate(time.Second)
+			err = m.DB().AddDeletedUser(db.DeletedUserRow{Name: deletedUser, UID: tc.uid, Dir: tc.dir, DeletedAt: deletedAt.Unix()})
+			require.NoError(t, err, "Setup: could not record deleted user")
+
+			t.Cleanup(func() { _ = os.RemoveAll("/tmp/authd-delete-user-test/") })
+			if !tc.noHome {
+				createTestHome(t, tc.dir)
+			}
+			spoolDir := t.TempDir()
+			users.SetMailSpoolDir(t, spoolDir)
+			spool := filepath.Join(spoolDir, deletedUser)
+			if !tc.noSpool {
+				require.NoError(t, os.WriteFile(spool, []byte("some mail"), 0600), "Setup: could not create mail spool")
+			}
+
+			cleanups, err := m.CleanupHomes(context.Background(), now, tc.dryRun)
+			require.NoError(t, err, "CleanupHomes should not return an error")
+			require.Len(t, cleanups, 1, "CleanupHomes should report the deleted user")
+
+			c := cleanups[0]
+			require.Equal(t, deletedUser, c.Name, "Cleanup should be for the deleted user")
+			require.Equal(t, tc.uid, c.UID, "Cleanup should have the UID of the deleted user")
+			require.Equal(t, tc.dir, c.Home, "Cleanup should have the home of the deleted user")
+			require.Equal(t, spool, c.MailSpool, "Cleanup should have the mail spool of the deleted user")
+			require.Equal(t, deletedAt, c.DeletedAt, "Cleanup should have the deletion time")
+			require.Equal(t, tc.policy, c.Action, "Cleanup action should be the policy")
+			if tc.policy == users.HomeCleanupKeep {
+				require.Zero(t, c.DueAt, "Kept home should not be due")
+			} else {
+				require.Equal(t, deletedAt.Add(time.Hour), c.DueAt, "Cleanup should be due after the retention window")
+			}
+			require.Equal(t, tc.wantSkipped, c.Skipped, "Cleanup should be skipped for the expected reason")
+			require.Equal(t, tc.wantDone, c.Done, "Cleanup should be done as expected")
+
+			if tc.wantHomeKept {
+				requireTestHome(t, tc.dir)
+			} else {
+				require.NoDirExists(t, tc.dir, "Home should be cleaned up")
+			}
+			if tc.wantDone {
+				require.NoFileExists(t, spool, "Mail spool should be cleaned up")
+			} else if !tc.noSpool {
+				require.FileExists(t, spool, "Mail spool should be kept")
+			}
+
+			if tc.wantArchived {
+				require.FileExists(t, c.Archive, "Home archive should exist")
+				mail, err := os.ReadFile(strings.TrimSuffix(c.Archive, ".tar.gz") + ".mail")
+				require.NoError(t, err, "Archived mail spool should exist")
+				require.Equal(t, "some mail", string(mail), "Archived mail spool should have the same content")
+			} else {
+				require.Empty(t, c.Archive, "Home should not be archived")
+			}
+
+			remaining, err := m.DB().DeletedUsers()
+			require.NoError(t, err, "Setup: could not get deleted users")
+			if tc.wantForgotten {
+				require.Empty(t, remaining, "Deleted user should be forgotten")
+			} else {
+				require.Len(t, remaining, 1, "Deleted user should still be tracked")
+			}
+		})
+	}
+}
+
+func TestDeleteUserRecordsHomeForCleanup(t *testing.T) {
+	// We don't care about the output of gpasswd in this test, but we still need to mock it.
+	_ = localgroupstestutils.SetupGroupMock(t, filepath.Join("testdata", "groups", "empty.group"))
+
+	dbDir := t.TempDir()
+	err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups.db.yaml"), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+	m := newManagerForTests(t, dbDir)
+
+	u, err := m.UserByName("user1@example.com")
+	require.NoError(t, err, "Setup: could not get user")
+
+	err = m.DeleteUser("user1@example.com", false)
+	require.NoError(t, err, "DeleteUser should not return an error")
+	err = m.DeleteUser("user2@example.com", true)
+	require.NoError(t, err, "DeleteUser should not return an error")
+
+	deleted, err := m.DB().DeletedUsers()
+	require.NoError(t, err, "DeletedUsers should not return an error")
+	require.Len(t, deleted, 1, "Only the user whose home was kept should be recorded")
+	require.Equal(t, u.Name, deleted[0].Name, "Recorded user should be the deleted user")
+	require.Equal(t, u.UID, deleted[0].UID, "Recorded UID should be the UID of the deleted user")
+	require.Equal(t, u.Dir, deleted[0].Dir, "Recorded home should be the home of the deleted user")
+
+	err = m.ForgetDeletedUser(u.Name)
+	require.NoError(t, err, "ForgetDeletedUser should not return an error")
+	deleted, err = m.DB().DeletedUsers()
+	require.NoError(t, err, "DeletedUsers should not return an error")
+	require.Empty(t, deleted, "Forgotten user should not be recorded")
+}
+
+func TestNewManagerWithInvalidHomeCleanupPolicy(t *testing.T) {
+	cfg := users.DefaultConfig
+	cfg.HomeCleanup.Policy = "shred"
+	_, err := users.NewManager(cfg, t.TempDir())
+	require.Error(t, err, "NewManager should return an error for an invalid home cleanup policy")
+}
@@ -0,0 +1,147 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+// homedSecretsDirName is the directory of the state directory of authd where the secrets protecting the homes created
+// with systemd-homed are kept.
+const homedSecretsDirName = "homed-secrets"
+
+// deletedSecretSuffix is appended to the name of the secrets of the homes of the deleted users.
+const deletedSecretSuffix = ".deleted"
+
+// The storages of the homes created with systemd-homed.
+const (
+	// HomedStorageDirectory stores the home in a plain directory.
+	HomedStorageDirectory = "directory"
+	// HomedStorageSubvolume stores the home in a btrfs subvolume.
+	HomedStorageSubvolume = "subvolume"
+	// HomedStorageFscrypt stores the home in a directory encrypted with fscrypt.
+	HomedStorageFscrypt = "fscrypt"
+	// HomedStorageLUKS stores the home in a LUKS2 encrypted image.
+	HomedStorageLUKS = "luks"
+)
+
+// homectlPath is the path of homectl, which is replaced in tests.
+var homectlPath = "homectl"
+
+// HomedConfig holds the settings of the homes of the new users created with systemd-homed.
+type HomedConfig struct {
+	// Storage is the storage of the homes which systemd-homed creates for the new users. If empty, their home is a
+	// plain directory.
+	Storage string `mapstructure:"homed_storage" yaml:"homed_storage,omitempty"`
+}
+
+// Enabled returns true if the homes of the new users are created with systemd-homed.
+func (c HomedConfig) Enabled() bool {
+	return c.Storage != ""
+}
+
+func (c HomedConfig) validate() error {
+	switch c.Storage {
+	case "", HomedStorageDirectory, HomedStorageSubvolume, HomedStorageFscrypt, HomedStorageLUKS:
+		return nil
+	}
+	return fmt.Errorf("invalid homed_storage %q, must be %s, %s, %s or %s", c.Storage,
+		HomedStorageDirectory, HomedStorageSubvolume, HomedStorageFscrypt, HomedStorageLUKS)
+}
+
+// createHomedHome creates the home of the new user with systemd-homed. The home is protected by a secret generated
+// for the user, with which the PAM module activates it when their session starts, as the users authenticate with
+// their identity provider rather than with a password known by systemd-homed.
+func (m *Manager) createHomedHome(u types.UserInfo) error {
+	if err := os.MkdirAll(m.homedSecretsDir, 0700); err != nil {
+		return err
+	}
+	// The secret is only put in place once the home is created, so that the PAM module never activates a home which
+	// doesn't exist.
+	f, err := os.CreateTemp(m.homedSecretsDir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	secret := rand.Text()
+	_, err = f.WriteString(secret)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	// #nosec:G204 - the user name was validated when the user was authenticated.
+	cmd := exec.Command(homectlPath, "create", u.Name,
+		"--uid="+strconv.FormatUint(uint64(u.UID), 10),
+		"--real-name="+u.Gecos,
+		"--shell="+u.Shell,
+		"--home-dir="+u.Dir,
+		"--storage="+m.config.Homed.Storage,
+		// The secret is not chosen by the user.
+		"--enforce-password-policy=no",
+	)
+	cmd.Env = append(cmd.Environ(), "NEWPASSWORD="+secret)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("homectl create failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	if err := os.Rename(f.Name(), m.homedSecretPath(u.Name)); err != nil {
+		return err
+	}
+
+	log.Noticef(context.Background(), "Created the %s home of user %q with systemd-homed", m.config.Homed.Storage, u.Name)
+	return nil
+}
+
+// removeHomedHome removes the home of the user created with systemd-homed, if any, along with its secret.
+func (m *Manager) removeHomedHome(name string) error {
+	secretPath := m.homedSecretPath(name)
+	if _, err := os.Stat(secretPath); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	// #nosec:G204 - the user name is the one of a user of the database.
+	if out, err := exec.Command(homectlPath, "remove", name).CombinedOutput(); err != nil {
+		return fmt.Errorf("homectl remove failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return os.Remove(secretPath)
+}
+
+// keepHomedSecret moves aside the secret of the home of the user deleted without their home, so that the home can
+// still be activated with homectl but isn't used by a new user with the same name.
+func (m *Manager) keepHomedSecret(name string) error {
+	err := os.Rename(m.homedSecretPath(name), m.homedSecretPath(name)+deletedSecretSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// HomedSecret returns the secret activating the home of the user created with systemd-homed, or an empty string if
+// the home of the user is not managed by systemd-homed.
+func (m *Manager) HomedSecret(name string) (string, error) {
+	secret, err := os.ReadFile(m.homedSecretPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not read the secret of the home of user %q: %w", name, err)
+	}
+	return string(secret), nil
+}
+
+// homedSecretPath returns the path of the secret of the home of the user. The secrets are named after the users, like
+// the records of systemd-homed, which outlive the users deleted without their home.
+func (m *Manager) homedSecretPath(name string) string {
+	return filepath.Join(m.homedSecretsDir, name)
+}
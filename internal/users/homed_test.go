@@ -0,0 +1,106 @@
+package users_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewManagerWithInvalidHomedStorage(t *testing.T) {
+	t.Parallel()
+
+	config := users.DefaultConfig
+	config.Homed.Storage = "tmpfs"
+	_, err := users.NewManager(config, t.TempDir())
+	require.Error(t, err, "NewManager should return an error for an invalid homed storage")
+}
+
+func TestHomedHome(t *testing.T) {
+	tests := map[string]struct {
+		storage      string
+		homectlFails bool
+		removeHome   bool
+
+		wantHomed bool
+	}{
+		"Create_home_with_systemd_homed":            {storage: users.HomedStorageLUKS, wantHomed: true},
+		"Remove_home_with_systemd_homed":            {storage: users.HomedStorageDirectory, removeHome: true, wantHomed: true},
+		"Keep_home_when_user_is_deleted_without_it": {storage: users.HomedStorageDirectory, wantHomed: true},
+		"Do_not_use_systemd_homed_when_disabled":    {},
+		"Fall_back_to_plain_home_if_homectl_fails":  {storage: users.HomedStorageLUKS, homectlFails: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			callsPath := filepath.Join(dir, "calls")
+			exit := 0
+			if tc.homectlFails {
+				exit = 1
+			}
+			script := fmt.Sprintf("#!/bin/sh\necho \"$* $NEWPASSWORD\" >> %q\nexit %d\n", callsPath, exit)
+			homectl := filepath.Join(dir, "homectl")
+			//nolint:gosec // The script must be executable.
+			require.NoError(t, os.WriteFile(homectl, []byte(script), 0700), "Setup: could not write homectl")
+			users.SetHomectlPath(t, homectl)
+
+			config := users.DefaultConfig
+			config.Homed.Storage = tc.storage
+			dbDir := t.TempDir()
+			m, err := users.NewManager(config, dbDir)
+			require.NoError(t, err, "Setup: NewManager should not return an error, but did")
+
+			err = m.UpdateUser(types.UserInfo{
+				Name:       "user1",
+				Dir:        "/home/user1",
+				Shell:      "/bin/bash",
+				BrokerID:   "broker-id",
+				ProviderID: "providerid-user1",
+				Groups:     []types.GroupInfo{{Name: "group1", UGID: "ugid-group1"}},
+			})
+			require.NoError(t, err, "UpdateUser should not return an error, but did")
+
+			secret, err := m.HomedSecret("user1")
+			require.NoError(t, err, "HomedSecret should not return an error, but did")
+			if !tc.wantHomed {
+				require.Empty(t, secret, "The home of the user should not be managed by systemd-homed")
+				return
+			}
+			require.NotEmpty(t, secret, "The home of the user should be managed by systemd-homed")
+
+			u, err := m.UserByName("user1")
+			require.NoError(t, err, "UserByName should not return an error, but did")
+			calls, err := os.ReadFile(callsPath)
+			require.NoError(t, err, "homectl should have been called")
+			want := fmt.Sprintf("create user1 --uid=%d --real-name= --shell=/bin/bash --home-dir=/home/user1 --storage=%s --enforce-password-policy=no %s\n",
+				u.UID, tc.storage, secret)
+			require.Equal(t, want, string(calls), "homectl should create the home with the secret of the user")
+
+			err = m.DeleteUser("user1", tc.removeHome)
+			require.NoError(t, err, "DeleteUser should not return an error, but did")
+
+			calls, err = os.ReadFile(callsPath)
+			require.NoError(t, err, "Could not read the calls of homectl")
+			require.Equal(t, tc.removeHome, strings.Contains(string(calls), "remove user1"),
+				"homectl should only remove the home if the home of the user is removed")
+
+			got, err := m.HomedSecret("user1")
+			require.NoError(t, err, "HomedSecret should not return an error, but did")
+			require.Empty(t, got, "The secret should not be used by a new user with the same name")
+
+			kept := filepath.Join(dbDir, "homed-secrets", "user1.deleted")
+			if tc.removeHome {
+				require.NoFileExists(t, kept, "The secret should be removed with the home")
+				return
+			}
+			keptSecret, err := os.ReadFile(kept)
+			require.NoError(t, err, "The secret should be kept with the home")
+			require.Equal(t, secret, string(keptSecret), "The kept secret should be the one of the home")
+		})
+	}
+}
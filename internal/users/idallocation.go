@@ -0,0 +1,267 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/canonical/authd/internal/users/localentries"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+// IDAllocationConfig holds the settings of the central allocation of UIDs and GIDs, which gives roaming users the
+// same IDs on all the machines.
+type IDAllocationConfig struct {
+	// Listen is the TCP address on which this instance serves the allocation of IDs to other instances. It makes this
+	// instance the ID allocation authority.
+	Listen string `mapstructure:"id_allocation_listen" yaml:"id_allocation_listen"`
+	// Endpoint is the address of the ID allocation authority which this instance requests the IDs of new users and
+	// groups from.
+	Endpoint string `mapstructure:"id_allocation_endpoint" yaml:"id_allocation_endpoint"`
+
+	// CertFile and KeyFile are the TLS certificate and key which this instance authenticates itself with.
+	CertFile string `mapstructure:"id_allocation_cert" yaml:"id_allocation_cert"`
+	KeyFile  string `mapstructure:"id_allocation_key" yaml:"id_allocation_key"`
+	// CAFile is the certificate of the authority which the certificates of the other instances must be signed by.
+	CAFile string `mapstructure:"id_allocation_ca" yaml:"id_allocation_ca"`
+}
+
+// Authority returns true if this instance allocates the IDs of the other instances.
+func (c IDAllocationConfig) Authority() bool {
+	return c.Listen != ""
+}
+
+// Remote returns true if this instance requests the IDs of new users and groups from the ID allocation authority.
+func (c IDAllocationConfig) Remote() bool {
+	return c.Endpoint != ""
+}
+
+func (c IDAllocationConfig) validate() error {
+	if !c.Authority() && !c.Remote() {
+		return nil
+	}
+	if c.Authority() && c.Remote() {
+		return errors.New("id_allocation_listen and id_allocation_endpoint can't both be set")
+	}
+	if c.CertFile == "" || c.KeyFile == "" || c.CAFile == "" {
+		return errors.New("id_allocation_cert, id_allocation_key and id_allocation_ca must be set to allocate IDs centrally")
+	}
+	return nil
+}
+
+// IDAllocator is the interface that must be implemented to request IDs from the ID allocation authority.
+type IDAllocator interface {
+	// AllocateUID returns the UID of the user with the given name, allocating one if needed.
+	AllocateUID(ctx context.Context, name string) (uint32, error)
+	// AllocateGID returns the GID of the group with the given UGID, allocating one if needed.
+	AllocateGID(ctx context.Context, ugid string) (uint32, error)
+}
+
+// WithIDAllocator makes the manager request the IDs of new users and groups from the ID allocation authority.
+func WithIDAllocator(a IDAllocator) Option {
+	return func(o *options) {
+		o.idAllocator = a
+	}
+}
+
+// AllocateUserID returns the UID allocated to the user with the given name by this instance, allocating one if
+// needed.
+func (m *Manager) AllocateUserID(name string) (uid uint32, err error) {
+	m.userManagementMu.Lock()
+	defer m.userManagementMu.Unlock()
+
+	lockedEntries, unlockEntries, err := localentries.WithUserDBLock()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { err = errors.Join(err, unlockEntries()) }()
+
+	// The broker of the users of the other instances is unknown, so their UIDs are in the default range.
+	return m.allocateUserID(lockedEntries, name, "")
+}
+
+// AllocateGroupID returns the GID allocated to the group with the given UGID by this instance, allocating one if
+// needed.
+func (m *Manager) AllocateGroupID(ugid string) (gid uint32, err error) {
+	m.userManagementMu.Lock()
+	defer m.userManagementMu.Unlock()
+
+	lockedEntries, unlockEntries, err := localentries.WithUserDBLock()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { err = errors.Join(err, unlockEntries()) }()
+
+	return m.allocateGroupID(lockedEntries, ugid, "")
+}
+
+// allocateUserID must be called with userManagementMu and the user database locked.
+func (m *Manager) allocateUserID(lockedEntries *localentries.UserDBLocked, name, brokerID string) (uint32, error) {
+	uid, err := m.db.IDAllocation(db.UserIDAllocation, name)
+	if err == nil {
+		return uid, nil
+	}
+	if !errors.Is(err, db.NoDataFoundError{}) {
+		return 0, err
+	}
+
+	// The users which logged in on the authority before keep their UID.
+	u, err := m.db.UserByName(name)
+	if err != nil && !errors.Is(err, db.NoDataFoundError{}) {
+		return 0, err
+	}
+	if err == nil {
+		uid = u.UID
+	} else {
+		var cleanup func()
+		uid, cleanup, err = m.idGenerator.GenerateUID(lockedEntries, m, brokerID)
+		if err != nil {
+			return 0, err
+		}
+		defer cleanup()
+	}
+
+	if err := m.db.AddIDAllocation(db.UserIDAllocation, name, uid); err != nil {
+		return 0, err
+	}
+	log.Infof(context.Background(), "Allocated UID %d to user %q", uid, name)
+	return uid, nil
+}
+
+// allocateGroupID must be called with userManagementMu and the user database locked.
+func (m *Manager) allocateGroupID(lockedEntries *localentries.UserDBLocked, ugid, brokerID string) (uint32, error) {
+	gid, err := m.db.IDAllocation(db.GroupIDAllocation, ugid)
+	if err == nil {
+		return gid, nil
+	}
+	if !errors.Is(err, db.NoDataFoundError{}) {
+		return 0, err
+	}
+
+	// The groups of the users which logged in on the authority before keep their GID.
+	g, err := m.db.GroupByUGID(ugid)
+	if err != nil && !errors.Is(err, db.NoDataFoundError{}) {
+		return 0, err
+	}
+	if err == nil {
+		gid = g.GID
+	} else {
+		var cleanup func()
+		gid, cleanup, err = m.idGenerator.GenerateGID(lockedEntries, m, brokerID)
+		if err != nil {
+			return 0, err
+		}
+		defer cleanup()
+	}
+
+	if err := m.db.AddIDAllocation(db.GroupIDAllocation, ugid, gid); err != nil {
+		return 0, err
+	}
+	log.Infof(context.Background(), "Allocated GID %d to group %q", gid, ugid)
+	return gid, nil
+}
+
+// newUserID returns the UID of a new user: the one allocated by the ID allocation authority when IDs are allocated
+// centrally, or a random one in the range of its broker otherwise.
+func (m *Manager) newUserID(lockedEntries *localentries.UserDBLocked, name, brokerID string) (uint32, func(), error) {
+	noop := func() {}
+
+	if m.config.IDAllocation.Authority() {
+		uid, err := m.allocateUserID(lockedEntries, name, brokerID)
+		return uid, noop, err
+	}
+	if m.idAllocator == nil {
+		return m.idGenerator.GenerateUID(lockedEntries, m, brokerID)
+	}
+
+	uid, err := m.idAllocator.AllocateUID(context.Background(), name)
+	if err != nil {
+		return 0, nil, fmt.Errorf("could not get the UID of user %q from the ID allocation authority: %w", name, err)
+	}
+	// The user private group has the same ID as the user.
+	if err := m.checkAllocatedID(lockedEntries, uid, true); err != nil {
+		return 0, nil, fmt.Errorf("UID %d allocated to user %q can't be used: %w", uid, name, err)
+	}
+	return uid, noop, nil
+}
+
+// uidHint returns the UID suggested by the broker for the new user u, if there is one and they can get it: the IDs are
+// not allocated centrally, it is in the UID range of the broker and it's neither used as a UID nor as a GID. It must be
+// called with userManagementMu and the user database locked.
+func (m *Manager) uidHint(lockedEntries *localentries.UserDBLocked, u types.UserInfo) (uint32, bool) {
+	if u.UIDHint == nil || m.idAllocator != nil || m.config.IDAllocation.Authority() {
+		return 0, false
+	}
+	uid := *u.UIDHint
+
+	minID, maxID := m.config.UIDMin, m.config.UIDMax
+	if r, ok := m.brokerIDRanges[u.BrokerID]; ok {
+		minID, maxID = r.UIDMin, r.UIDMax
+	}
+	if uid < minID || uid > maxID {
+		log.Warningf(context.Background(), "Ignoring UID %d suggested by the broker for user %q: it is not in the range %d-%d",
+			uid, u.Name, minID, maxID)
+		return 0, false
+	}
+	if err := m.checkAllocatedID(lockedEntries, uid, true); err != nil {
+		log.Warningf(context.Background(), "Ignoring UID %d suggested by the broker for user %q: %v", uid, u.Name, err)
+		return 0, false
+	}
+	return uid, true
+}
+
+// newGroupID returns the GID of a new group, like newUserID.
+func (m *Manager) newGroupID(lockedEntries *localentries.UserDBLocked, ugid, brokerID string) (uint32, func(), error) {
+	noop := func() {}
+
+	if m.config.IDAllocation.Authority() {
+		gid, err := m.allocateGroupID(lockedEntries, ugid, brokerID)
+		return gid, noop, err
+	}
+	if m.idAllocator == nil {
+		return m.idGenerator.GenerateGID(lockedEntries, m, brokerID)
+	}
+
+	gid, err := m.idAllocator.AllocateGID(context.Background(), ugid)
+	if err != nil {
+		return 0, nil, fmt.Errorf("could not get the GID of group %q from the ID allocation authority: %w", ugid, err)
+	}
+	if err := m.checkAllocatedID(lockedEntries, gid, false); err != nil {
+		return 0, nil, fmt.Errorf("GID %d allocated to group %q can't be used: %w", gid, ugid, err)
+	}
+	return gid, noop, nil
+}
+
+// checkAllocatedID returns an error if the ID allocated by the ID allocation authority is already used on this
+// machine. If isUID is true, it's checked as both a UID and the GID of the user private group.
+func (m *Manager) checkAllocatedID(lockedEntries *localentries.UserDBLocked, id uint32, isUID bool) error {
+	if isUID {
+		uids, err := m.UsedUIDs()
+		if err != nil {
+			return err
+		}
+		unique, err := lockedEntries.IsUniqueUID(id)
+		if err != nil {
+			return err
+		}
+		if !unique || slices.Contains(uids, id) {
+			return errors.New("the UID is already used on this machine")
+		}
+	}
+
+	gids, err := m.UsedGIDs()
+	if err != nil {
+		return err
+	}
+	unique, err := lockedEntries.IsUniqueGID(id)
+	if err != nil {
+		return err
+	}
+	if !unique || slices.Contains(gids, id) {
+		return errors.New("the GID is already used on this machine")
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package users_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	localgroupstestutils "github.com/canonical/authd/internal/users/localentries/testutils"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/stretchr/testify/require"
+)
+
+var idAllocationTestConfig = users.IDAllocationConfig{CertFile: "cert.pem", KeyFile: "key.pem", CAFile: "ca.pem"}
+
+func TestNewManagerWithIDAllocation(t *testing.T) {
+	tests := map[string]struct {
+		listen      string
+		endpoint    string
+		noCerts     bool
+		idAllocator users.IDAllocator
+
+		wantErr bool
+	}{
+		"Successfully_create_authority":            {listen: "localhost:4242"},
+		"Successfully_create_roaming_instance":     {endpoint: "authority:4242", idAllocator: &fixedIDAllocator{}},
+		"Successfully_create_without_certificates": {noCerts: true},
+
+		"Error_when_both_authority_and_roaming":           {listen: "localhost:4242", endpoint: "authority:4242", wantErr: true},
+		"Error_when_authority_has_no_certificates":        {listen: "localhost:4242", noCerts: true, wantErr: true},
+		"Error_when_roaming_instance_has_no_certificates": {endpoint: "authority:4242", noCerts: true, wantErr: true},
+		"Error_when_allocator_is_set_without_endpoint":    {idAllocator: &fixedIDAllocator{}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := users.DefaultConfig
+			if !tc.noCerts {
+				cfg.IDAllocation = idAllocationTestConfig
+			}
+			cfg.IDAllocation.Listen = tc.listen
+			cfg.IDAllocation.Endpoint = tc.endpoint
+
+			var opts []users.Option
+			if tc.idAllocator != nil {
+				opts = append(opts, users.WithIDAllocator(tc.idAllocator))
+			}
+			_, err := users.NewManager(cfg, t.TempDir(), opts...)
+			if tc.wantErr {
+				require.Error(t, err, "NewManager should return an error")
+				return
+			}
+			require.NoError(t, err, "NewManager should not return an error")
+		})
+	}
+}
+
+func TestIDAllocationAuthority(t *testing.T) {
+	// We don't care about the output of gpasswd in this test, but we still need to mock it.
+	_ = localgroupstestutils.SetupGroupMock(t, filepath.Join("testdata", "groups", "empty.group"))
+
+	dbDir := t.TempDir()
+	err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups.db.yaml"), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+
+	cfg := users.DefaultConfig
+	cfg.IDAllocation = idAllocationTestConfig
+	cfg.IDAllocation.Listen = "localhost:4242"
+	m, err := users.NewManager(cfg, dbDir)
+	require.NoError(t, err, "Setup: could not create manager")
+
+	uid, err := m.AllocateUserID("user1@example.com")
+	require.NoError(t, err, "AllocateUserID should not return an error")
+	require.Equal(t, uint32(1111), uid, "Users of the authority should keep their UID")
+
+	gid, err := m.AllocateGroupID("12345678")
+	require.NoError(t, err, "AllocateGroupID should not return an error")
+	require.Equal(t, uint32(11111), gid, "Groups of the authority should keep their GID")
+
+	roamingUID, err := m.AllocateUserID("roaming@example.com")
+	require.NoError(t, err, "AllocateUserID should not return an error")
+	require.GreaterOrEqual(t, roamingUID, cfg.UIDMin, "Allocated UID should be in the UID range")
+	require.LessOrEqual(t, roamingUID, cfg.UIDMax, "Allocated UID should be in the UID range")
+
+	again, err := m.AllocateUserID("roaming@example.com")
+	require.NoError(t, err, "AllocateUserID should not return an error")
+	require.Equal(t, roamingUID, again, "The same UID should be returned on each request")
+
+	roamingGID, err := m.AllocateGroupID("roaming-group")
+	require.NoError(t, err, "AllocateGroupID should not return an error")
+	require.NotEqual(t, roamingUID, roamingGID, "Allocated GID should not be the GID of a user private group")
+
+	againGID, err := m.AllocateGroupID("roaming-group")
+	require.NoError(t, err, "AllocateGroupID should not return an error")
+	require.Equal(t, roamingGID, againGID, "The same GID should be returned on each request")
+
+	// A roaming user logging in on the authority gets the UID allocated to them.
+	err = m.UpdateUser(types.UserInfo{
+		Name:       "roaming@example.com",
+		Dir:        "/home/roaming@example.com",
+		Shell:      "/bin/bash",
+		BrokerID:   "broker-id",
+		ProviderID: "providerid-roaming",
+		Groups:     []types.GroupInfo{{Name: "roaming-group", UGID: "roaming-group"}},
+	})
+	require.NoError(t, err, "UpdateUser should not return an error")
+	u, err := m.UserByName("roaming@example.com")
+	require.NoError(t, err, "UserByName should not return an error")
+	require.Equal(t, roamingUID, u.UID, "User should get the UID allocated to them")
+	g, err := m.GroupByName("roaming-group")
+	require.NoError(t, err, "GroupByName should not return an error")
+	require.Equal(t, roamingGID, g.GID, "Group should get the GID allocated to it")
+
+	// New users of the authority get an allocated UID too.
+	err = m.UpdateUser(types.UserInfo{Name: "local@example.com", Dir: "/home/local@example.com", Shell: "/bin/bash", BrokerID: "broker-id", ProviderID: "providerid-local"})
+	require.NoError(t, err, "UpdateUser should not return an error")
+	u, err = m.UserByName("local@example.com")
+	require.NoError(t, err, "UserByName should not return an error")
+	uid, err = m.AllocateUserID("local@example.com")
+	require.NoError(t, err, "AllocateUserID should not return an error")
+	require.Equal(t, u.UID, uid, "New users of the authority should have an allocated UID")
+}
+
+func TestIDAllocationRoaming(t *testing.T) {
+	tests := map[string]struct {
+		uid      uint32
+		gid      uint32
+		allocErr error
+		noGroup  bool
+		preAuth  bool
+
+		wantUID uint32
+		wantGID uint32
+		wantErr bool
+	}{
+		"Successfully_use_allocated_IDs":              {uid: 42424, gid: 43434, wantUID: 42424, wantGID: 43434},
+		"Successfully_use_allocated_UID_for_pre_auth": {uid: 42424, gid: 43434, preAuth: true, noGroup: true, wantUID: 42424},
+
+		"Error_when_allocated_UID_is_used_locally":  {uid: 1111, gid: 43434, wantErr: true},
+		"Error_when_allocated_UID_is_a_local_GID":   {uid: 11111, gid: 43434, noGroup: true, wantErr: true},
+		"Error_when_allocated_GID_is_used_locally":  {uid: 42424, gid: 22222, wantErr: true},
+		"Error_when_authority_can_not_be_reached":   {allocErr: errors.New("connection refused"), wantErr: true},
+		"Error_when_pre_auth_UID_can_not_be_gotten": {allocErr: errors.New("connection refused"), preAuth: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// We don't care about the output of gpasswd in this test, but we still need to mock it.
+			_ = localgroupstestutils.SetupGroupMock(t, filepath.Join("testdata", "groups", "empty.group"))
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+
+			cfg := users.DefaultConfig
+			cfg.IDAllocation = idAllocationTestConfig
+			cfg.IDAllocation.Endpoint = "authority:4242"
+			allocator := &fixedIDAllocator{uid: tc.uid, gid: tc.gid, err: tc.allocErr}
+			m, err := users.NewManager(cfg, dbDir, users.WithIDAllocator(allocator))
+			require.NoError(t, err, "Setup: could not create manager")
+
+			if tc.preAuth {
+				var uid uint32
+				uid, err = m.RegisterUserPreAuth("roaming@example.com", "")
+				if !tc.wantErr {
+					require.Equal(t, tc.wantUID, uid, "Pre-auth user should get the allocated UID")
+				}
+			}
+			if err == nil {
+				info := types.UserInfo{Name: "roaming@example.com", Dir: "/home/roaming@example.com", Shell: "/bin/bash", BrokerID: "broker-id", ProviderID: "providerid-roaming"}
+				if !tc.noGroup {
+					info.Groups = []types.GroupInfo{{Name: "roaming-group", UGID: "roaming-group"}}
+				}
+				err = m.UpdateUser(info)
+			}
+			if tc.wantErr {
+				require.Error(t, err, "Allocating the IDs should return an error")
+				return
+			}
+			require.NoError(t, err, "UpdateUser should not return an error")
+
+			u, err := m.UserByName("roaming@example.com")
+			require.NoError(t, err, "UserByName should not return an error")
+			require.Equal(t, tc.wantUID, u.UID, "User should get the allocated UID")
+			require.Equal(t, []string{"roaming@example.com"}, allocator.users, "The UID should be requested once")
+			if tc.noGroup {
+				return
+			}
+			g, err := m.GroupByName("roaming-group")
+			require.NoError(t, err, "GroupByName should not return an error")
+			require.Equal(t, tc.wantGID, g.GID, "Group should get the allocated GID")
+			require.Equal(t, []string{"roaming-group"}, allocator.groups, "The GID should be requested by UGID")
+		})
+	}
+}
+
+type fixedIDAllocator struct {
+	uid, gid uint32
+	err      error
+
+	users  []string
+	groups []string
+}
+
+func (a *fixedIDAllocator) AllocateUID(_ context.Context, name string) (uint32, error) {
+	a.users = append(a.users, name)
+	return a.uid, a.err
+}
+
+func (a *fixedIDAllocator) AllocateGID(_ context.Context, ugid string) (uint32, error) {
+	a.groups = append(a.groups, ugid)
+	return a.gid, a.err
+}
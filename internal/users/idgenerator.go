@@ -14,8 +14,8 @@ import (
 
 // IDGeneratorIface is the interface that must be implemented by the ID generator.
 type IDGeneratorIface interface {
-	GenerateUID(lockedEntries *localentries.UserDBLocked, owner IDOwner) (uid uint32, cleanup func(), err error)
-	GenerateGID(lockedEntries *localentries.UserDBLocked, owner IDOwner) (gid uint32, cleanup func(), err error)
+	GenerateUID(lockedEntries *localentries.UserDBLocked, owner IDOwner, brokerID string) (uid uint32, cleanup func(), err error)
+	GenerateGID(lockedEntries *localentries.UserDBLocked, owner IDOwner, brokerID string) (gid uint32, cleanup func(), err error)
 }
 
 // IDOwner is the interface that must be implemented by the IDs owner to provide
@@ -32,6 +32,10 @@ type IDGenerator struct {
 	GIDMin uint32
 	GIDMax uint32
 
+	// BrokerRanges are the ranges of the IDs of the users and groups of some brokers, by broker ID. The IDs of the
+	// users and groups of the other brokers are generated in the ranges above.
+	BrokerRanges map[string]IDRange
+
 	// IDs generated but not saved to the database yet.
 	// This is used to avoid generating the same ID multiple times.
 	// We don't differentiate between UIDs and GIDs here, because:
@@ -56,23 +60,33 @@ const (
 	uidT16MinusOne uint32 = math.MaxUint16
 )
 
-// GenerateUID generates a random UID in the configured range.
-func (g *IDGenerator) GenerateUID(lockedEntries *localentries.UserDBLocked, owner IDOwner) (uint32, func(), error) {
+// GenerateUID generates a random UID in the range of the broker, or in the configured range if the broker has none.
+func (g *IDGenerator) GenerateUID(lockedEntries *localentries.UserDBLocked, owner IDOwner, brokerID string) (uint32, func(), error) {
+	minID, maxID := g.UIDMin, g.UIDMax
+	if r, ok := g.BrokerRanges[brokerID]; ok {
+		minID, maxID = r.UIDMin, r.UIDMax
+	}
+
 	return g.generateID(lockedEntries, owner, generateID{
 		idType:        "UID",
-		minID:         g.UIDMin,
-		maxID:         g.UIDMax,
+		minID:         minID,
+		maxID:         maxID,
 		getUsedIDs:    g.getUsedIDs,
 		isAvailableID: g.isUIDAvailable,
 	})
 }
 
-// GenerateGID generates a random GID in the configured range.
-func (g *IDGenerator) GenerateGID(lockedEntries *localentries.UserDBLocked, owner IDOwner) (uint32, func(), error) {
+// GenerateGID generates a random GID in the range of the broker, or in the configured range if the broker has none.
+func (g *IDGenerator) GenerateGID(lockedEntries *localentries.UserDBLocked, owner IDOwner, brokerID string) (uint32, func(), error) {
+	minID, maxID := g.GIDMin, g.GIDMax
+	if r, ok := g.BrokerRanges[brokerID]; ok {
+		minID, maxID = r.GIDMin, r.GIDMax
+	}
+
 	return g.generateID(lockedEntries, owner, generateID{
 		idType:        "GID",
-		minID:         g.GIDMin,
-		maxID:         g.GIDMax,
+		minID:         minID,
+		maxID:         maxID,
 		getUsedIDs:    g.getUsedGIDs,
 		isAvailableID: g.isGIDAvailable,
 	})
@@ -0,0 +1,57 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// LDAPServerConfig holds the settings of the read-only LDAP server, which serves the users and groups of authd to the
+// applications only looking up the users in LDAP.
+type LDAPServerConfig struct {
+	// Listen is the TCP address on which the LDAP server is served. If empty, there is no LDAP server.
+	Listen string `mapstructure:"ldap_server_listen" yaml:"ldap_server_listen"`
+	// CertFile and KeyFile are the TLS certificate and key of the LDAP server, which is then served over LDAPS. Without
+	// them, it can only be served on the loopback interface.
+	CertFile string `mapstructure:"ldap_server_cert" yaml:"ldap_server_cert"`
+	KeyFile  string `mapstructure:"ldap_server_key" yaml:"ldap_server_key"`
+	// BaseDN is the DN under which the users and groups are served.
+	BaseDN string `mapstructure:"ldap_server_base_dn" yaml:"ldap_server_base_dn"`
+}
+
+// Enabled returns true if the LDAP server is served.
+func (c LDAPServerConfig) Enabled() bool {
+	return c.Listen != ""
+}
+
+// TLS returns true if the LDAP server is served over LDAPS.
+func (c LDAPServerConfig) TLS() bool {
+	return c.CertFile != ""
+}
+
+func (c LDAPServerConfig) validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(c.Listen)
+	if err != nil {
+		return errors.New("invalid ldap_server_listen, must be a host and a port such as 127.0.0.1:389")
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("ldap_server_cert and ldap_server_key must be set together")
+	}
+	// The users and groups are not secret, but the applications must not be tricked into trusting another server.
+	if ip := net.ParseIP(host); !c.TLS() && host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("%q is not a loopback address: ldap_server_cert and ldap_server_key must be set to serve LDAP on the network", host)
+	}
+	if c.BaseDN == "" {
+		return errors.New("ldap_server_base_dn must be set to serve LDAP")
+	}
+	for _, rdn := range strings.Split(c.BaseDN, ",") {
+		if attr, value, ok := strings.Cut(rdn, "="); !ok || strings.TrimSpace(attr) == "" || strings.TrimSpace(value) == "" {
+			return fmt.Errorf("invalid ldap_server_base_dn %q", c.BaseDN)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package users_test
+
+import (
+	"testing"
+
+	"github.com/canonical/authd/internal/users"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLDAPServerConfigValidation(t *testing.T) {
+	tests := map[string]struct {
+		cfg users.LDAPServerConfig
+
+		wantErr bool
+	}{
+		"Disabled":                  {},
+		"Serve_on_loopback_address": {cfg: users.LDAPServerConfig{Listen: "127.0.0.1:389", BaseDN: "dc=example,dc=com"}},
+		"Serve_on_localhost":        {cfg: users.LDAPServerConfig{Listen: "localhost:389", BaseDN: "dc=example, dc=com"}},
+		"Serve_LDAPS_on_network": {cfg: users.LDAPServerConfig{
+			Listen: ":636", CertFile: "/etc/authd/ldap/cert.pem", KeyFile: "/etc/authd/ldap/key.pem", BaseDN: "dc=example,dc=com",
+		}},
+
+		"Error_on_listen_address_without_port": {cfg: users.LDAPServerConfig{Listen: "127.0.0.1", BaseDN: "dc=example,dc=com"}, wantErr: true},
+		"Error_on_network_address_without_certificate": {
+			cfg: users.LDAPServerConfig{Listen: ":389", BaseDN: "dc=example,dc=com"}, wantErr: true,
+		},
+		"Error_on_certificate_without_key": {
+			cfg:     users.LDAPServerConfig{Listen: ":636", CertFile: "/etc/authd/ldap/cert.pem", BaseDN: "dc=example,dc=com"},
+			wantErr: true,
+		},
+		"Error_on_missing_base_DN": {cfg: users.LDAPServerConfig{Listen: "127.0.0.1:389"}, wantErr: true},
+		"Error_on_invalid_base_DN": {cfg: users.LDAPServerConfig{Listen: "127.0.0.1:389", BaseDN: "example.com"}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := users.DefaultConfig
+			cfg.LDAPServer = tc.cfg
+			m, err := users.NewManager(cfg, t.TempDir())
+			if tc.wantErr {
+				require.Error(t, err, "NewManager should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "NewManager should not return an error, but did")
+			t.Cleanup(func() { _ = m.Stop() })
+		})
+	}
+}
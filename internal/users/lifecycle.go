@@ -0,0 +1,371 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/canonical/authd/log"
+)
+
+// homeArchivesDirName is the directory of the state directory of authd where the homes of archived users are kept.
+const homeArchivesDirName = "home-archives"
+
+// lifecycleHookTimeout is the maximum duration of a deprovisioning hook.
+var lifecycleHookTimeout = time.Minute
+
+// LifecycleConfig holds the settings of the staged deprovisioning of users.
+type LifecycleConfig struct {
+	// ArchiveAfter is the duration after which disabled users are archived. If 0, they are only archived on request.
+	ArchiveAfter time.Duration `mapstructure:"deprovision_archive_after" yaml:"deprovision_archive_after"`
+	// DeleteAfter is the duration after which archived users are deleted. If 0, they are only deleted on request.
+	DeleteAfter time.Duration `mapstructure:"deprovision_delete_after" yaml:"deprovision_delete_after"`
+	// Hooks are the programs run when a user changes state. They are called with the new state as argument, the
+	// transition as JSON on their standard input and its fields in AUTHD_LIFECYCLE_* environment variables.
+	Hooks []string `mapstructure:"deprovision_hooks" yaml:"deprovision_hooks,omitempty"`
+}
+
+// Automatic returns true if some users are moved to their next state once their dwell time elapsed.
+func (c LifecycleConfig) Automatic() bool {
+	return c.ArchiveAfter > 0 || c.DeleteAfter > 0
+}
+
+// LifecycleState is the deprovisioning state of a user.
+type LifecycleState string
+
+const (
+	// LifecycleActive is the state of the users which can log in.
+	LifecycleActive LifecycleState = "active"
+	// LifecycleDisabled is the state of the users which are locked, but whose home is kept as is.
+	LifecycleDisabled LifecycleState = "disabled"
+	// LifecycleArchived is the state of the users whose home was moved to an archive.
+	LifecycleArchived LifecycleState = "archived"
+	// LifecycleDeleted is the state of the users removed from the database, it's only reported to the hooks.
+	LifecycleDeleted LifecycleState = "deleted"
+)
+
+// Lifecycle describes the deprovisioning state of a user.
+type Lifecycle struct {
+	State LifecycleState
+	// Since is when the user entered the state. It's zero for active users.
+	Since time.Time
+	// Next is the state which the user is moved to at NextAt. It's empty if the user stays in the state until
+	// an administrator changes it.
+	Next   LifecycleState
+	NextAt time.Time
+	// Archive is the path of the archive of the home of archived users.
+	Archive string
+}
+
+// LifecycleError is returned when the requested operation is not allowed in the current state of the user.
+type LifecycleError struct {
+	Username  string
+	State     LifecycleState
+	Operation string
+}
+
+// Error implements the error interface for LifecycleError.
+func (e LifecycleError) Error() string {
+	if string(e.State) == e.Operation {
+		return fmt.Sprintf("user %q is already %s", e.Username, e.State)
+	}
+	return fmt.Sprintf("user %q is %s, it cannot be %s", e.Username, e.State, e.Operation)
+}
+
+// Is makes this error insensitive to the actual user and operation.
+func (LifecycleError) Is(target error) bool { return target == LifecycleError{} }
+
+// DeletedUser describes a user deleted at the end of their deprovisioning.
+type DeletedUser struct {
+	Name       string
+	BrokerID   string
+	ProviderID string
+}
+
+// lifecycleEvent describes a state change of a user to the hooks.
+type lifecycleEvent struct {
+	State         LifecycleState `json:"state"`
+	PreviousState LifecycleState `json:"previous_state"`
+	Time          time.Time      `json:"time"`
+	Username      string         `json:"username"`
+	UID           uint32         `json:"uid"`
+	Home          string         `json:"home"`
+	Archive       string         `json:"archive,omitempty"`
+}
+
+// env returns the environment variables describing the event to the hooks.
+func (e lifecycleEvent) env() []string {
+	return []string{
+		"AUTHD_LIFECYCLE_STATE=" + string(e.State),
+		"AUTHD_LIFECYCLE_PREVIOUS_STATE=" + string(e.PreviousState),
+		"AUTHD_LIFECYCLE_USER=" + e.Username,
+		"AUTHD_LIFECYCLE_UID=" + strconv.FormatUint(uint64(e.UID), 10),
+		"AUTHD_LIFECYCLE_HOME=" + e.Home,
+		"AUTHD_LIFECYCLE_ARCHIVE=" + e.Archive,
+	}
+}
+
+// UserLifecycle returns the deprovisioning state of the user.
+func (m *Manager) UserLifecycle(username string) (Lifecycle, error) {
+	u, err := m.db.UserByName(username)
+	if err != nil {
+		return Lifecycle{}, err
+	}
+	return m.userLifecycle(u)
+}
+
+// DisableUser locks an active user and starts their deprovisioning. Their home is kept until they are archived.
+func (m *Manager) DisableUser(ctx context.Context, username string) error {
+	m.lifecycleMu.Lock()
+	defer m.lifecycleMu.Unlock()
+
+	u, lc, err := m.userAndLifecycle(username)
+	if err != nil {
+		return err
+	}
+	if lc.State != LifecycleActive {
+		return LifecycleError{Username: username, State: lc.State, Operation: "disabled"}
+	}
+
+	return m.disableUser(ctx, u)
+}
+
+func (m *Manager) disableUser(ctx context.Context, u db.UserRow) error {
+	if err := m.db.UpdateLockedFieldForUser(u.Name, true); err != nil {
+		return err
+	}
+	if err := m.db.SetUserLifecycle(u.UID, string(LifecycleDisabled), time.Now().Unix()); err != nil {
+		return err
+	}
+
+	log.Noticef(ctx, "User %q disabled", u.Name)
+	m.notifyUserChanged(Disabled, u.Name, u.UID)
+	m.runLifecycleHooks(ctx, lifecycleEvent{State: LifecycleDisabled, PreviousState: LifecycleActive, Username: u.Name, UID: u.UID, Home: u.Dir})
+	return nil
+}
+
+// ArchiveUser moves the home of a disabled user to an archive in the authd state directory.
+func (m *Manager) ArchiveUser(ctx context.Context, username string) error {
+	m.lifecycleMu.Lock()
+	defer m.lifecycleMu.Unlock()
+
+	u, lc, err := m.userAndLifecycle(username)
+	if err != nil {
+		return err
+	}
+	if lc.State != LifecycleDisabled {
+		return LifecycleError{Username: username, State: lc.State, Operation: "archived"}
+	}
+
+	return m.archiveUser(ctx, u)
+}
+
+func (m *Manager) archiveUser(ctx context.Context, u db.UserRow) error {
+	archive := m.homeArchivePath(u.Name)
+	if _, err := os.Stat(u.Dir); err == nil {
+		if err := archiveHome(u.Dir, archive); err != nil {
+			return fmt.Errorf("could not archive home directory %q of user %q: %w", u.Dir, u.Name, err)
+		}
+		if err := os.RemoveAll(u.Dir); err != nil {
+			return fmt.Errorf("could not remove archived home directory %q of user %q: %w", u.Dir, u.Name, err)
+		}
+	} else if errors.Is(err, os.ErrNotExist) {
+		log.Infof(ctx, "Home directory %q of user %q does not exist, nothing to archive", u.Dir, u.Name)
+		archive = ""
+	} else {
+		return fmt.Errorf("could not check home directory %q of user %q: %w", u.Dir, u.Name, err)
+	}
+
+	if err := m.db.SetUserLifecycle(u.UID, string(LifecycleArchived), time.Now().Unix()); err != nil {
+		return err
+	}
+
+	log.Noticef(ctx, "User %q archived", u.Name)
+	m.notifyUserChanged(Updated, u.Name, u.UID)
+	m.runLifecycleHooks(ctx, lifecycleEvent{State: LifecycleArchived, PreviousState: LifecycleDisabled, Username: u.Name, UID: u.UID, Home: u.Dir, Archive: archive})
+	return nil
+}
+
+// RestoreUser makes a disabled or archived user active again, restoring their home from its archive if needed.
+func (m *Manager) RestoreUser(ctx context.Context, username string) error {
+	m.lifecycleMu.Lock()
+	defer m.lifecycleMu.Unlock()
+
+	u, lc, err := m.userAndLifecycle(username)
+	if err != nil {
+		return err
+	}
+	if lc.State == LifecycleActive {
+		return LifecycleError{Username: username, State: lc.State, Operation: "restored"}
+	}
+
+	if lc.Archive != "" {
+		if err := restoreHome(lc.Archive, u.Dir); err != nil {
+			return fmt.Errorf("could not restore home directory %q of user %q: %w", u.Dir, username, err)
+		}
+		if err := os.Remove(lc.Archive); err != nil {
+			log.Warningf(ctx, "Could not remove archive %q of restored user %q: %v", lc.Archive, username, err)
+		}
+	}
+
+	if err := m.db.DeleteUserLifecycle(u.UID); err != nil {
+		return err
+	}
+	if err := m.db.UpdateLockedFieldForUser(username, false); err != nil {
+		return err
+	}
+
+	log.Noticef(ctx, "User %q restored", username)
+	m.notifyUserChanged(Updated, username, u.UID)
+	m.runLifecycleHooks(ctx, lifecycleEvent{State: LifecycleActive, PreviousState: lc.State, Username: username, UID: u.UID, Home: u.Dir})
+	return nil
+}
+
+// AdvanceLifecycles moves the users whose dwell time in their state elapsed at now to their next state. It returns
+// the users which were deleted, so that the data of their broker can be removed too.
+func (m *Manager) AdvanceLifecycles(ctx context.Context, now time.Time) ([]DeletedUser, error) {
+	m.lifecycleMu.Lock()
+	defer m.lifecycleMu.Unlock()
+
+	rows, err := m.db.AllUserLifecycles()
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []DeletedUser
+	var errs []error
+	for _, r := range rows {
+		u, err := m.db.UserByID(r.UID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		lc, err := m.userLifecycle(u)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if lc.Next == "" || now.Before(lc.NextAt) {
+			continue
+		}
+
+		switch lc.Next {
+		case LifecycleArchived:
+			err = m.archiveUser(ctx, u)
+		case LifecycleDeleted:
+			err = m.deleteArchivedUser(ctx, u, lc.Archive)
+			if err == nil {
+				deleted = append(deleted, DeletedUser{Name: u.Name, BrokerID: u.BrokerID, ProviderID: u.ProviderID})
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not move user %q to state %s: %w", u.Name, lc.Next, err))
+		}
+	}
+
+	return deleted, errors.Join(errs...)
+}
+
+// deleteArchivedUser removes an archived user from the database, then removes the archive of their home once the
+// hooks had a chance to keep it elsewhere.
+func (m *Manager) deleteArchivedUser(ctx context.Context, u db.UserRow, archive string) error {
+	if err := m.DeleteUser(u.Name, false); err != nil {
+		return err
+	}
+
+	log.Noticef(ctx, "User %q deleted at the end of their deprovisioning", u.Name)
+	m.runLifecycleHooks(ctx, lifecycleEvent{State: LifecycleDeleted, PreviousState: LifecycleArchived, Username: u.Name, UID: u.UID, Home: u.Dir, Archive: archive})
+
+	if archive == "" {
+		return nil
+	}
+	if err := os.Remove(archive); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warningf(ctx, "Could not remove archive %q of deleted user %q: %v", archive, u.Name, err)
+	}
+	return nil
+}
+
+// userAndLifecycle returns the user with the given name and their deprovisioning state.
+func (m *Manager) userAndLifecycle(username string) (db.UserRow, Lifecycle, error) {
+	u, err := m.db.UserByName(username)
+	if err != nil {
+		return db.UserRow{}, Lifecycle{}, err
+	}
+	lc, err := m.userLifecycle(u)
+	return u, lc, err
+}
+
+func (m *Manager) userLifecycle(u db.UserRow) (Lifecycle, error) {
+	r, err := m.db.UserLifecycle(u.UID)
+	if errors.Is(err, db.NoDataFoundError{}) {
+		return Lifecycle{State: LifecycleActive}, nil
+	}
+	if err != nil {
+		return Lifecycle{}, err
+	}
+
+	lc := Lifecycle{State: LifecycleState(r.State), Since: time.Unix(r.ChangedAt, 0)}
+	switch lc.State {
+	case LifecycleDisabled:
+		if m.config.Lifecycle.ArchiveAfter > 0 {
+			lc.Next, lc.NextAt = LifecycleArchived, lc.Since.Add(m.config.Lifecycle.ArchiveAfter)
+		}
+	case LifecycleArchived:
+		if m.config.Lifecycle.DeleteAfter > 0 {
+			lc.Next, lc.NextAt = LifecycleDeleted, lc.Since.Add(m.config.Lifecycle.DeleteAfter)
+		}
+		// Users without a home when they were archived have no archive.
+		if archive := m.homeArchivePath(u.Name); fileExists(archive) {
+			lc.Archive = archive
+		}
+	}
+	return lc, nil
+}
+
+// homeArchivePath returns the path of the archive of the home of the user.
+func (m *Manager) homeArchivePath(username string) string {
+	return filepath.Join(m.homeArchivesDir, username+".tar.gz")
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// runLifecycleHooks runs the deprovisioning hooks one after the other. Failing hooks are only logged, as the state
+// of the user already changed.
+func (m *Manager) runLifecycleHooks(ctx context.Context, e lifecycleEvent) {
+	e.Time = time.Now()
+	for _, h := range m.config.Lifecycle.Hooks {
+		if err := runLifecycleHook(ctx, h, e); err != nil {
+			log.Warningf(ctx, "Deprovisioning hook %q failed for user %q entering state %s: %v", h, e.Username, e.State, err)
+		}
+	}
+}
+
+func runLifecycleHook(ctx context.Context, path string, e lifecycleEvent) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lifecycleHookTimeout)
+	defer cancel()
+
+	// #nosec:G204 - the hook path is set by the administrator in the configuration.
+	cmd := exec.CommandContext(ctx, path, string(e.State))
+	cmd.Env = append(cmd.Environ(), e.env()...)
+	cmd.Stdin = bytes.NewReader(payload)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, out)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package users_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/testutils/golden"
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	localgroupstestutils "github.com/canonical/authd/internal/users/localentries/testutils"
+	userstestutils "github.com/canonical/authd/internal/users/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+const lifecycleTestHome = "/tmp/authd-delete-user-test/home/user1@example.com"
+
+func TestUserLifecycle(t *testing.T) {
+	tests := map[string]struct {
+		username   string
+		operations []string
+		noHome     bool
+
+		wantState    users.LifecycleState
+		wantArchived bool
+		wantErr      bool
+		wantErrType  error
+	}{
+		"Disable_active_user":                      {operations: []string{"disable"}, wantState: users.LifecycleDisabled},
+		"Archive_disabled_user":                    {operations: []string{"disable", "archive"}, wantState: users.LifecycleArchived, wantArchived: true},
+		"Archive_disabled_user_without_home":       {operations: []string{"disable", "archive"}, noHome: true, wantState: users.LifecycleArchived},
+		"Restore_disabled_user":                    {operations: []string{"disable", "restore"}, wantState: users.LifecycleActive},
+		"Restore_archived_user_restores_home":      {operations: []string{"disable", "archive", "restore"}, wantState: users.LifecycleActive},
+		"Restore_archived_user_without_home":       {operations: []string{"disable", "archive", "restore"}, noHome: true, wantState: users.LifecycleActive},
+		"Disable_restored_user_again":              {operations: []string{"disable", "archive", "restore", "disable"}, wantState: users.LifecycleDisabled},
+		"Delete_archived_user_removes_the_archive": {operations: []string{"disable", "archive", "delete"}},
+
+		"Error_when_archiving_active_user":    {operations: []string{"archive"}, wantErrType: users.LifecycleError{}},
+		"Error_when_disabling_disabled_user":  {operations: []string{"disable", "disable"}, wantErrType: users.LifecycleError{}},
+		"Error_when_disabling_archived_user":  {operations: []string{"disable", "archive", "disable"}, wantErrType: users.LifecycleError{}},
+		"Error_when_restoring_active_user":    {operations: []string{"restore"}, wantErrType: users.LifecycleError{}},
+		"Error_when_unlocking_disabled_user":  {operations: []string{"disable", "unlock"}, wantErrType: users.LifecycleError{}},
+		"Error_when_home_exists_on_restore":   {operations: []string{"disable", "archive", "mkhome", "restore"}, wantErr: true},
+		"Error_if_user_does_not_exist":        {username: "doesnotexist@example.com", operations: []string{"disable"}, wantErrType: db.NoDataFoundError{}},
+		"Error_if_user_does_not_exist_status": {username: "doesnotexist@example.com", wantErrType: db.NoDataFoundError{}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// We don't care about the output of gpasswd in this test, but we still need to mock it.
+			_ = localgroupstestutils.SetupGroupMock(t, filepath.Join("testdata", "groups", "empty.group"))
+
+			if tc.username == "" {
+				tc.username = "user1@example.com"
+			}
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups_with_tmp_home.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m := newManagerForTests(t, dbDir)
+
+			t.Cleanup(func() { _ = os.RemoveAll("/tmp/authd-delete-user-test/") })
+			if !tc.noHome {
+				createTestHome(t, lifecycleTestHome)
+			}
+
+			for _, op := range tc.operations {
+				switch op {
+				case "disable":
+					err = m.DisableUser(context.Background(), tc.username)
+				case "archive":
+					err = m.ArchiveUser(context.Background(), tc.username)
+				case "restore":
+					err = m.RestoreUser(context.Background(), tc.username)
+				case "unlock":
+					err = m.UnlockUser(tc.username)
+				case "delete":
+					err = m.DeleteUser(tc.username, true)
+				case "mkhome":
+					err = os.MkdirAll(lifecycleTestHome, 0700)
+				}
+				if err != nil {
+					break
+				}
+			}
+			if err == nil {
+				var lc users.Lifecycle
+				lc, err = m.UserLifecycle(tc.username)
+				if slices.Contains(tc.operations, "delete") {
+					require.ErrorIs(t, err, db.NoDataFoundError{}, "Deleted user should not be found")
+					err = nil
+				} else if err == nil {
+					require.Equal(t, tc.wantState, lc.State, "UserLifecycle should return the expected state")
+				}
+			}
+
+			requireErrorAssertions(t, err, tc.wantErrType, tc.wantErr)
+			if tc.wantErrType != nil || tc.wantErr {
+				return
+			}
+
+			archive := filepath.Join(dbDir, "home-archives", tc.username+".tar.gz")
+			if tc.wantArchived {
+				require.FileExists(t, archive, "Home archive should exist")
+			} else {
+				require.NoFileExists(t, archive, "Home archive should not exist")
+			}
+			switch {
+			case tc.wantState == users.LifecycleActive && !tc.noHome:
+				requireTestHome(t, lifecycleTestHome)
+			case tc.wantState == users.LifecycleDisabled:
+				require.DirExists(t, lifecycleTestHome, "Home of disabled user should be kept")
+			default:
+				require.NoDirExists(t, lifecycleTestHome, "Home should not exist")
+			}
+
+			got, err := db.Z_ForTests_DumpNormalizedYAML(userstestutils.DBManager(m))
+			require.NoError(t, err, "Created database should be valid yaml content")
+
+			golden.CheckOrUpdate(t, got)
+		})
+	}
+}
+
+func TestAdvanceLifecycles(t *testing.T) {
+	// We don't care about the output of gpasswd in this test, but we still need to mock it.
+	_ = localgroupstestutils.SetupGroupMock(t, filepath.Join("testdata", "groups", "empty.group"))
+
+	dbDir := t.TempDir()
+	err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups_with_tmp_home.db.yaml"), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+
+	t.Cleanup(func() { _ = os.RemoveAll("/tmp/authd-delete-user-test/") })
+	createTestHome(t, lifecycleTestHome)
+
+	hookOutput := filepath.Join(t.TempDir(), "hook.log")
+	hook := filepath.Join(t.TempDir(), "hook")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$1 $AUTHD_LIFECYCLE_USER $AUTHD_LIFECYCLE_ARCHIVE\" >> %q\n", hookOutput)
+	require.NoError(t, os.WriteFile(hook, []byte(script), 0700), "Setup: could not write hook")
+
+	cfg := users.DefaultConfig
+	cfg.Lifecycle = users.LifecycleConfig{ArchiveAfter: time.Hour, DeleteAfter: 24 * time.Hour, Hooks: []string{hook}}
+	m, err := users.NewManager(cfg, dbDir)
+	require.NoError(t, err, "Setup: could not create manager")
+
+	start := time.Now()
+	err = m.DisableUser(context.Background(), "user1@example.com")
+	require.NoError(t, err, "Setup: could not disable user")
+
+	lc, err := m.UserLifecycle("user1@example.com")
+	require.NoError(t, err, "UserLifecycle should not return an error")
+	require.Equal(t, users.LifecycleArchived, lc.Next, "Disabled user should be archived next")
+	require.WithinDuration(t, start.Add(time.Hour), lc.NextAt, 2*time.Second, "Disabled user should be archived after the dwell time")
+
+	deleted, err := m.AdvanceLifecycles(context.Background(), start.Add(30*time.Minute))
+	require.NoError(t, err, "AdvanceLifecycles should not return an error")
+	require.Empty(t, deleted, "No user should be deleted")
+	lc, err = m.UserLifecycle("user1@example.com")
+	require.NoError(t, err, "UserLifecycle should not return an error")
+	require.Equal(t, users.LifecycleDisabled, lc.State, "User should stay disabled before the dwell time")
+
+	deleted, err = m.AdvanceLifecycles(context.Background(), start.Add(2*time.Hour))
+	require.NoError(t, err, "AdvanceLifecycles should not return an error")
+	require.Empty(t, deleted, "No user should be deleted")
+	lc, err = m.UserLifecycle("user1@example.com")
+	require.NoError(t, err, "UserLifecycle should not return an error")
+	require.Equal(t, users.LifecycleArchived, lc.State, "User should be archived after the dwell time")
+	require.Equal(t, users.LifecycleDeleted, lc.Next, "Archived user should be deleted next")
+	require.FileExists(t, lc.Archive, "Home archive should exist")
+	require.NoDirExists(t, lifecycleTestHome, "Home of archived user should be removed")
+
+	deleted, err = m.AdvanceLifecycles(context.Background(), time.Now().Add(25*time.Hour))
+	require.NoError(t, err, "AdvanceLifecycles should not return an error")
+	require.Equal(t, []users.DeletedUser{{Name: "user1@example.com", BrokerID: "broker-id", ProviderID: ""}}, deleted,
+		"Archived user should be deleted after the dwell time")
+	_, err = m.UserLifecycle("user1@example.com")
+	require.ErrorIs(t, err, db.NoDataFoundError{}, "Deleted user should not be found")
+	require.NoFileExists(t, lc.Archive, "Home archive should be removed with the user")
+
+	out, err := os.ReadFile(hookOutput)
+	require.NoError(t, err, "Hook should have been run")
+	want := fmt.Sprintf("disabled user1@example.com \narchived user1@example.com %[1]s\ndeleted user1@example.com %[1]s\n", lc.Archive)
+	require.Equal(t, want, string(out), "Hook should be run on each state change")
+}
+
+// createTestHome creates a home with some content owned by the current user.
+func createTestHome(t *testing.T, home string) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".config", "app"), 0700), "Setup: could not create home")
+	require.NoError(t, os.Chmod(filepath.Join(home, ".config"), 0500), "Setup: could not make directory read-only")
+	require.NoError(t, os.WriteFile(filepath.Join(home, "notes.txt"), []byte("some notes"), 0640), "Setup: could not create file")
+	require.NoError(t, os.Symlink("notes.txt", filepath.Join(home, "link")), "Setup: could not create symlink")
+	t.Cleanup(func() { _ = os.Chmod(filepath.Join(home, ".config"), 0700) })
+}
+
+// requireTestHome checks that the home has the content created by createTestHome.
+func requireTestHome(t *testing.T, home string) {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(home, "notes.txt"))
+	require.NoError(t, err, "Restored home should contain the file")
+	require.Equal(t, "some notes", string(data), "Restored file should have the same content")
+
+	info, err := os.Stat(filepath.Join(home, "notes.txt"))
+	require.NoError(t, err, "Restored file should exist")
+	require.Equal(t, os.FileMode(0640), info.Mode().Perm(), "Restored file should have the same permissions")
+
+	info, err = os.Stat(filepath.Join(home, ".config"))
+	require.NoError(t, err, "Restored directory should exist")
+	require.Equal(t, os.FileMode(0500), info.Mode().Perm(), "Restored directory should have the same permissions")
+	require.DirExists(t, filepath.Join(home, ".config", "app"), "Restored home should contain the subdirectory")
+
+	link, err := os.Readlink(filepath.Join(home, "link"))
+	require.NoError(t, err, "Restored home should contain the symlink")
+	require.Equal(t, "notes.txt", link, "Restored symlink should have the same target")
+}
@@ -0,0 +1,132 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+// LinkedIdentity is an identity of a user at another broker than the one they're bound to, with which they can
+// authenticate too, e.g. their account at the provider to which their organization is migrating.
+type LinkedIdentity struct {
+	BrokerID string
+	// ProviderID is the ID of the user at the provider. It's empty until the user authenticated with the broker,
+	// which confirms the link.
+	ProviderID string
+}
+
+// IdentityLinkError is returned when an identity can't be linked to a user.
+type IdentityLinkError struct {
+	Username string
+	BrokerID string
+	Reason   string
+}
+
+// Error implements the error interface for IdentityLinkError.
+func (e IdentityLinkError) Error() string {
+	return fmt.Sprintf("cannot link the identity of user %q at broker %q: %s", e.Username, e.BrokerID, e.Reason)
+}
+
+// Is makes this error insensitive to the actual user, broker and reason.
+func (IdentityLinkError) Is(target error) bool { return target == IdentityLinkError{} }
+
+// LinkIdentity allows the user to authenticate with the broker, in addition to the broker they're bound to. They keep
+// their UID, home directory and groups. The identity of the user at the provider is recorded once they authenticated
+// with the broker, and can't be replaced by another one afterwards.
+func (m *Manager) LinkIdentity(username, brokerID string) error {
+	u, err := m.db.UserByName(username)
+	if err != nil {
+		return err
+	}
+	if u.BrokerID == "" {
+		return IdentityLinkError{Username: username, BrokerID: brokerID, Reason: "the user is not bound to any broker yet"}
+	}
+	if u.BrokerID == brokerID {
+		return IdentityLinkError{Username: username, BrokerID: brokerID, Reason: "the user is already bound to this broker"}
+	}
+
+	if err := m.db.LinkIdentity(u.UID, brokerID); err != nil {
+		return err
+	}
+	log.Noticef(context.Background(), "User %q can now authenticate with broker %q", username, brokerID)
+
+	return nil
+}
+
+// UnlinkIdentity removes the identity of the user at the broker, so that they can't authenticate with it anymore.
+func (m *Manager) UnlinkIdentity(username, brokerID string) error {
+	u, err := m.db.UserByName(username)
+	if err != nil {
+		return err
+	}
+
+	if err := m.db.UnlinkIdentity(u.UID, brokerID); err != nil {
+		return err
+	}
+	log.Noticef(context.Background(), "User %q can no longer authenticate with broker %q", username, brokerID)
+
+	return nil
+}
+
+// LinkedIdentities returns the identities linked to the user, ordered by broker ID.
+func (m *Manager) LinkedIdentities(username string) ([]LinkedIdentity, error) {
+	u, err := m.db.UserByName(username)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.LinkedIdentities(u.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	var identities []LinkedIdentity
+	for _, r := range rows {
+		identities = append(identities, LinkedIdentity{BrokerID: r.BrokerID, ProviderID: r.ProviderID})
+	}
+	return identities, nil
+}
+
+// LinkedIdentity returns the identity of the user at the broker or a NoDataFoundError if none is linked.
+func (m *Manager) LinkedIdentity(username, brokerID string) (LinkedIdentity, error) {
+	u, err := m.userByAnyName(username)
+	if err != nil {
+		return LinkedIdentity{}, err
+	}
+
+	r, err := m.db.LinkedIdentity(u.UID, brokerID)
+	if err != nil {
+		return LinkedIdentity{}, err
+	}
+	return LinkedIdentity{BrokerID: r.BrokerID, ProviderID: r.ProviderID}, nil
+}
+
+// checkLinkedIdentity checks that the user can authenticate with the identity of another broker than the one they're
+// bound to, and confirms the link on their first authentication with it.
+func (m *Manager) checkLinkedIdentity(old *types.UserInfo, brokerID, providerID string) error {
+	r, err := m.db.LinkedIdentity(old.UID, brokerID)
+	if errors.Is(err, db.NoDataFoundError{}) {
+		// The broker ID scopes the stored provider ID and must not change once set: a user
+		// is bound to the broker they first authenticated with, unless another identity was linked to them.
+		return fmt.Errorf("user %q is already bound to broker %q and cannot authenticate with broker %q",
+			old.Name, old.BrokerID, brokerID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if r.ProviderID != "" && providerID != "" && r.ProviderID != providerID {
+		// Another account of the provider has this name: the link is for the account which first authenticated.
+		return NameCollisionError{Name: old.Name}
+	}
+	if r.ProviderID == "" && providerID != "" {
+		log.Noticef(context.Background(), "Confirming the identity of user %q at broker %q", old.Name, brokerID)
+		return m.db.ConfirmLinkedIdentity(old.UID, brokerID, providerID)
+	}
+
+	return nil
+}
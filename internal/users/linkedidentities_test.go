@@ -0,0 +1,116 @@
+package users_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	userstestutils "github.com/canonical/authd/internal/users/testutils"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkIdentity(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		username string
+		brokerID string
+
+		wantErrType error
+	}{
+		"Link_identity_at_another_broker": {},
+
+		"Error_if_user_is_already_bound_to_the_broker": {brokerID: "broker-id", wantErrType: users.IdentityLinkError{}},
+		"Error_if_user_does_not_exist":                 {username: "doesnotexist@example.com", wantErrType: db.NoDataFoundError{}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.username == "" {
+				tc.username = "user1@example.com"
+			}
+			if tc.brokerID == "" {
+				tc.brokerID = "other-broker-id"
+			}
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "one_user_and_group_with_providerid.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m := newManagerForTests(t, dbDir)
+
+			err = m.LinkIdentity(tc.username, tc.brokerID)
+			requireErrorAssertions(t, err, tc.wantErrType, false)
+			if tc.wantErrType != nil {
+				return
+			}
+
+			got, err := m.LinkedIdentities(tc.username)
+			require.NoError(t, err, "LinkedIdentities should not return an error")
+			require.Equal(t, []users.LinkedIdentity{{BrokerID: tc.brokerID}}, got, "The linked identity should be pending")
+		})
+	}
+}
+
+func TestUpdateUserWithLinkedIdentity(t *testing.T) {
+	t.Parallel()
+
+	newUser := func(brokerID, providerID string) types.UserInfo {
+		return types.UserInfo{
+			Name:       "user1@example.com",
+			Gecos:      "User1",
+			Dir:        "/home/user1@example.com",
+			Shell:      "/bin/bash",
+			BrokerID:   brokerID,
+			ProviderID: providerID,
+			Groups:     []types.GroupInfo{{Name: "group1@example.com", UGID: "12345678"}},
+		}
+	}
+
+	dbDir := t.TempDir()
+	err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "one_user_and_group_with_providerid.db.yaml"), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+	m := newManagerForTests(t, dbDir)
+
+	err = m.UpdateUser(newUser("other-broker-id", "other-providerid-user1"))
+	require.Error(t, err, "UpdateUser should return an error for a broker which is not linked to the user")
+
+	err = m.LinkIdentity("user1@example.com", "other-broker-id")
+	require.NoError(t, err, "Setup: LinkIdentity should not return an error")
+
+	// The first authentication with the linked broker confirms the identity.
+	err = m.UpdateUser(newUser("other-broker-id", "other-providerid-user1"))
+	require.NoError(t, err, "UpdateUser should not return an error for a linked broker")
+	identity, err := m.LinkedIdentity("user1@example.com", "other-broker-id")
+	require.NoError(t, err, "LinkedIdentity should not return an error")
+	require.Equal(t, "other-providerid-user1", identity.ProviderID, "The provider ID of the linked identity should be recorded")
+
+	u, err := userstestutils.DBManager(m).UserByName("user1@example.com")
+	require.NoError(t, err, "UserByName should not return an error")
+	require.Equal(t, uint32(1111), u.UID, "The user should keep their UID")
+	require.Equal(t, "broker-id", u.BrokerID, "The user should stay bound to their broker")
+	require.Equal(t, "providerid-user1", u.ProviderID, "The user should keep their provider ID")
+
+	// The user can still authenticate with the broker they're bound to.
+	err = m.UpdateUser(newUser("broker-id", "providerid-user1"))
+	require.NoError(t, err, "UpdateUser should not return an error for the broker of the user")
+
+	err = m.UpdateBrokerForUser("user1@example.com", "other-broker-id")
+	require.NoError(t, err, "UpdateBrokerForUser should not return an error")
+	brokerID, err := m.BrokerForUser("user1@example.com")
+	require.NoError(t, err, "BrokerForUser should not return an error")
+	require.Equal(t, "broker-id", brokerID, "Authenticating with a linked broker should not change the broker of the user")
+
+	// Another account of the provider of the linked broker can't take over the user.
+	err = m.UpdateUser(newUser("other-broker-id", "other-providerid-user2"))
+	require.ErrorAs(t, err, &users.NameCollisionError{}, "UpdateUser should return an error for another account of the linked broker")
+
+	err = m.UnlinkIdentity("user1@example.com", "other-broker-id")
+	require.NoError(t, err, "UnlinkIdentity should not return an error")
+	err = m.UpdateUser(newUser("other-broker-id", "other-providerid-user1"))
+	require.Error(t, err, "UpdateUser should return an error for an unlinked broker")
+	err = m.UnlinkIdentity("user1@example.com", "other-broker-id")
+	require.ErrorIs(t, err, db.NoDataFoundError{}, "UnlinkIdentity should return an error for an identity which is not linked")
+}
@@ -0,0 +1,81 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/canonical/authd/internal/users/localentries"
+	"github.com/canonical/authd/internal/users/types"
+)
+
+// LocalAccountsConfig links the users of the identity providers to the local users of the machine which they replace,
+// e.g. when a machine with local accounts moves to an identity provider.
+type LocalAccountsConfig struct {
+	// LocalAccounts maps the names of the users to the names of the local users whose UID, primary group and home
+	// directory they get on their first login, so that they keep owning the files of their local account.
+	LocalAccounts map[string]string `mapstructure:"local_accounts" yaml:"local_accounts,omitempty"`
+}
+
+func (c LocalAccountsConfig) validate() error {
+	linked := make(map[string]string)
+	for name, local := range c.LocalAccounts {
+		if name == "" || local == "" {
+			return fmt.Errorf("invalid link of %q to the local user %q in local_accounts", name, local)
+		}
+		if other, ok := linked[local]; ok {
+			return fmt.Errorf("local user %q is linked to both %q and %q in local_accounts", local, other, name)
+		}
+		linked[local] = name
+	}
+	return nil
+}
+
+// localAccount returns the name of the local user linked to the user with the given name, if any. The names of the
+// configuration are normalized like the names of the users.
+func (c Config) localAccount(name string) (string, bool) {
+	for n, local := range c.LocalAccounts.LocalAccounts {
+		if c.Normalization.Normalize(n) == name {
+			return local, true
+		}
+	}
+	return "", false
+}
+
+// linkedLocalAccount returns the entry of the local user linked to the new user with the given name, or nil if there
+// is none.
+func (m *Manager) linkedLocalAccount(lockedEntries *localentries.UserDBLocked, name string) (*types.UserEntry, error) {
+	local, ok := m.config.localAccount(name)
+	if !ok {
+		return nil, nil
+	}
+
+	entries, err := lockedEntries.GetLocalUserEntries()
+	if err != nil {
+		return nil, err
+	}
+	var account *types.UserEntry
+	for _, e := range entries {
+		if e.Name == local {
+			account = &e
+			break
+		}
+	}
+	if account == nil {
+		return nil, fmt.Errorf("local user %q linked to user %q does not exist", local, name)
+	}
+	if account.UID == 0 {
+		return nil, fmt.Errorf("user %q can't be linked to the local user %q, which has UID 0", name, local)
+	}
+
+	// Two authd users can't share the same UID.
+	u, err := m.db.UserByID(account.UID)
+	if err == nil {
+		return nil, fmt.Errorf("UID %d of the local user %q linked to user %q is already used by user %q", account.UID, local, name, u.Name)
+	}
+	if !errors.Is(err, db.NoDataFoundError{}) {
+		return nil, err
+	}
+
+	return account, nil
+}
@@ -1,6 +1,8 @@
 package localentries
 
 import (
+	"time"
+
 	userslocking "github.com/canonical/authd/internal/users/locking"
 	"github.com/canonical/authd/internal/users/types"
 )
@@ -34,6 +36,13 @@ func WithGroupOutputPath(p string) Option {
 	}
 }
 
+// WithGroupFileLockTimeout overrides how long we wait for the group file lock in tests.
+func WithGroupFileLockTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.groupFileLockTimeout = timeout
+	}
+}
+
 // WithMockUserDBLocking uses a mock implementation to lock the users database.
 func WithMockUserDBLocking() Option {
 	return func(o *options) {
@@ -0,0 +1,97 @@
+package localentries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/canonical/authd/log"
+)
+
+// defaultGroupFileLockTimeout is how long we wait for the group file lock held by another process. It's the time
+// the shadow tools wait for it too.
+const defaultGroupFileLockTimeout = 15 * time.Second
+
+// groupFileLockRetryDelay is the delay between two attempts to take the group file lock.
+var groupFileLockRetryDelay = 100 * time.Millisecond
+
+// ErrGroupFileLocked is returned when the group file lock is held by another process.
+var ErrGroupFileLocked = errors.New("the group file is locked by another process")
+
+func groupFileLockPath(groupPath string) string {
+	return groupPath + ".lock"
+}
+
+// takeGroupFileLock takes the lock which the shadow tools (gpasswd, usermod, groupadd...) take before updating
+// the group file: the <group file>.lock file, containing the PID of its owner, created by hard-linking a temporary
+// file so that it's atomic. A lock file left by a process which is not running anymore is removed.
+//
+// This is needed in addition to lckpwdf: the shadow tools take both, but they skip lckpwdf in some cases (e.g. when
+// run with --prefix) and other tools only rely on the lock file.
+func takeGroupFileLock(groupPath string, timeout time.Duration) (unlock func(), err error) {
+	lockPath := groupFileLockPath(groupPath)
+	pid := strconv.Itoa(os.Getpid())
+
+	tempPath := fmt.Sprintf("%s.%s", groupPath, pid)
+	//nolint:gosec // G306 The lock files of the shadow tools are world readable too.
+	if err := os.WriteFile(tempPath, []byte(pid), 0644); err != nil {
+		return nil, fmt.Errorf("could not create group file lock: %w", err)
+	}
+	defer os.Remove(tempPath)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := os.Link(tempPath, lockPath)
+		if err == nil {
+			log.Debugf(context.Background(), "Group file %q locked", groupPath)
+			return func() {
+				if err := os.Remove(lockPath); err != nil {
+					log.Warningf(context.Background(), "Could not remove group file lock %q: %v", lockPath, err)
+				}
+			}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("could not create group file lock: %w", err)
+		}
+
+		if removeStaleGroupFileLock(lockPath) {
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w (%s)", ErrGroupFileLocked, lockPath)
+		}
+		time.Sleep(groupFileLockRetryDelay)
+	}
+}
+
+// removeStaleGroupFileLock removes the lock file if the process which created it is not running anymore. It returns
+// true if the lock file doesn't exist anymore.
+func removeStaleGroupFileLock(lockPath string) bool {
+	content, err := os.ReadFile(lockPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid <= 0 {
+		// Like the shadow tools, don't touch a lock file we can't make sense of.
+		return false
+	}
+	if err := syscall.Kill(pid, 0); !errors.Is(err, syscall.ESRCH) {
+		return false
+	}
+
+	log.Noticef(context.Background(), "Removing stale group file lock %q of process %d", lockPath, pid)
+	if err := os.Remove(lockPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+	return true
+}
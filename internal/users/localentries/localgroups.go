@@ -3,6 +3,7 @@ package localentries
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -11,6 +12,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/canonical/authd/internal/decorate"
 	"github.com/canonical/authd/internal/fileutils"
@@ -46,7 +48,10 @@ func SaveGroupEntries(dbLocked *UserDBLocked, entries []types.GroupEntry) (err e
 
 	dbLocked.MustBeLocked()
 
-	unlock := dbLocked.lockGroupFile()
+	unlock, err := dbLocked.lockGroupFileForUpdate()
+	if err != nil {
+		return err
+	}
 	defer unlock()
 
 	return saveLocalGroups(dbLocked, entries)
@@ -55,20 +60,33 @@ func SaveGroupEntries(dbLocked *UserDBLocked, entries []types.GroupEntry) (err e
 // UpdateGroups updates the local groups for a user, adding them to the groups in
 // newGroups which they are not already part of, and removing them from the
 // groups in oldGroups which are not in newGroups.
+//
+// The group file may have been changed by other tools since we read it, so it's
+// read again and only the lines of the groups whose members changed are
+// updated, while holding the lock file of the shadow tools.
 func UpdateGroups(dbLocked *UserDBLocked, username string, newGroups []string, oldGroups []string) (err error) {
 	log.Debugf(context.Background(), "Updating local groups for user %q, new groups: %v, old groups: %v", username, newGroups, oldGroups)
 	defer decorate.OnError(&err, "could not update local groups for user %q", username)
 
 	dbLocked.MustBeLocked()
 
-	unlock := dbLocked.lockGroupFile()
-	defer unlock()
-
 	if len(newGroups) == 0 && len(oldGroups) == 0 {
 		return nil
 	}
 
-	allGroups, err := getGroupEntriesWithContext(dbLocked)
+	unlock, err := dbLocked.lockGroupFileForUpdate()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	inputPath := dbLocked.groupFileToUpdate()
+	log.Debugf(context.Background(), "Reading groups from %q", inputPath)
+	content, err := os.ReadFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("could not fetch existing local group: %w", err)
+	}
+	allGroups, invalidEntries, err := parseGroupEntries(inputPath, content)
 	if err != nil {
 		return err
 	}
@@ -86,15 +104,17 @@ func UpdateGroups(dbLocked *UserDBLocked, username string, newGroups []string, o
 	log.Debugf(context.Background(), "Removing %q from local groups: %v", username, groupsToRemove)
 
 	if len(groupsToRemove) == 0 && len(groupsToAdd) == 0 {
+		dbLocked.updateLocalGroupEntriesCache(allGroups, invalidEntries)
 		return nil
 	}
 
+	updatedGroups := types.DeepCopyGroupEntries(allGroups)
 	getGroupByName := func(name string) *types.GroupEntry {
-		idx := slices.IndexFunc(allGroups, func(g types.GroupEntry) bool { return g.Name == name })
+		idx := slices.IndexFunc(updatedGroups, func(g types.GroupEntry) bool { return g.Name == name })
 		if idx == -1 {
 			return nil
 		}
-		return &allGroups[idx]
+		return &updatedGroups[idx]
 	}
 
 	for _, g := range groupsToRemove {
@@ -114,7 +134,69 @@ func UpdateGroups(dbLocked *UserDBLocked, username string, newGroups []string, o
 		group.Users = append(group.Users, username)
 	}
 
-	return saveLocalGroups(dbLocked, allGroups)
+	if slices.EqualFunc(allGroups, updatedGroups, types.GroupEntry.Equals) {
+		log.Debugf(context.Background(), "Nothing to do, groups are equal")
+		dbLocked.updateLocalGroupEntriesCache(allGroups, invalidEntries)
+		return nil
+	}
+
+	if err := validateChangedGroups(allGroups, updatedGroups); err != nil {
+		log.Debugf(context.Background(), "New groups are not valid: %v", err)
+		return err
+	}
+
+	newContent := updateGroupMembers(string(content), username, groupsToAdd, groupsToRemove, invalidEntries)
+	if err := writeGroupFile(dbLocked, newContent); err != nil {
+		return err
+	}
+
+	dbLocked.updateLocalGroupEntriesCache(updatedGroups, invalidEntries)
+	return nil
+}
+
+// updateGroupMembers returns the content of the group file with username added
+// to the members of the groups in groupsToAdd and removed from the members of
+// the groups in groupsToRemove. The other lines, including the invalid ones,
+// are kept as they are.
+func updateGroupMembers(content, username string, groupsToAdd, groupsToRemove []string, invalidEntries []invalidEntry) string {
+	lines := strings.Split(content, "\n")
+	updated := make(map[string]bool)
+
+	for i, line := range lines {
+		if slices.ContainsFunc(invalidEntries, func(e invalidEntry) bool { return e.lineNum == i }) {
+			continue
+		}
+
+		elems := strings.SplitN(strings.TrimSpace(line), ":", 4)
+		if len(elems) < 4 {
+			continue
+		}
+
+		name := elems[0]
+		add := slices.Contains(groupsToAdd, name)
+		remove := slices.Contains(groupsToRemove, name)
+		if (!add && !remove) || updated[name] {
+			continue
+		}
+		// Like when parsing the file, only the first entry of a group is considered.
+		updated[name] = true
+
+		var users []string
+		if elems[3] != "" {
+			users = strings.Split(elems[3], ",")
+		}
+		if remove {
+			users = slices.DeleteFunc(users, func(u string) bool { return u == username })
+		}
+		if add && !slices.Contains(users, username) {
+			users = append(users, username)
+		}
+
+		elems[3] = strings.Join(users, ",")
+		lines[i] = strings.Join(elems, ":")
+	}
+
+	return strings.Join(lines, "\n")
 }
 
 func parseLocalGroups(groupPath string) (groups []types.GroupEntry, invalidEntries []invalidEntry, err error) {
@@ -122,15 +204,19 @@ func parseLocalGroups(groupPath string) (groups []types.GroupEntry, invalidEntri
 
 	log.Debugf(context.Background(), "Reading groups from %q", groupPath)
 
-	f, err := os.Open(groupPath)
+	content, err := os.ReadFile(groupPath)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer f.Close()
 
+	return parseGroupEntries(groupPath, content)
+}
+
+// parseGroupEntries parses the content of the group file at groupPath.
+func parseGroupEntries(groupPath string, content []byte) (groups []types.GroupEntry, invalidEntries []invalidEntry, err error) {
 	// Format of a line composing the group file is:
 	// group_name:password:group_id:user1,…,usern
-	scanner := bufio.NewScanner(f)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
 	for lineNum := 0; scanner.Scan(); lineNum++ {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" || line[0] == '#' {
@@ -211,9 +297,7 @@ func formatGroupEntries(dbLocked *UserDBLocked, groups []types.GroupEntry) strin
 }
 
 func saveLocalGroups(dbLocked *UserDBLocked, groups []types.GroupEntry) (err error) {
-	lockedEntries := dbLocked
-	inputPath := lockedEntries.options.inputGroupPath
-	groupPath := lockedEntries.options.outputGroupPath
+	groupPath := dbLocked.options.outputGroupPath
 
 	defer decorate.OnError(&err, "could not write local groups to %q", groupPath)
 
@@ -232,14 +316,30 @@ func saveLocalGroups(dbLocked *UserDBLocked, groups []types.GroupEntry) (err err
 		return err
 	}
 
-	backupPath := groupFileBackupPath(groupPath)
-	groupsEntries := formatGroupEntries(dbLocked, groups)
-
 	log.Debugf(context.Background(), "Saving group entries %#v to %q", groups, groupPath)
-	if len(groupsEntries) > 0 {
-		log.Debugf(context.Background(), "Group file content:\n%s", groupsEntries)
+	if err := writeGroupFile(dbLocked, formatGroupEntries(dbLocked, groups)); err != nil {
+		return err
+	}
+
+	dbLocked.updateLocalGroupEntriesCache(groups, dbLocked.localGroupInvalidEntries)
+	return nil
+}
+
+// writeGroupFile replaces the content of the group file, after backing up the
+// current one. The new content is written to a temporary file first, with the
+// permissions and the ownership of the current file, which is then renamed, so
+// that readers never see a partially written file.
+func writeGroupFile(dbLocked *UserDBLocked, content string) (err error) {
+	inputPath := dbLocked.groupFileToUpdate()
+	groupPath := dbLocked.options.outputGroupPath
+
+	defer decorate.OnError(&err, "could not write local groups to %q", groupPath)
+
+	if len(content) > 0 {
+		log.Debugf(context.Background(), "Group file content:\n%s", content)
 	}
 
+	backupPath := groupFileBackupPath(groupPath)
 	if err := os.Remove(backupPath); err != nil && !errors.Is(err, os.ErrNotExist) {
 		log.Warningf(context.Background(), "Failed to remove group file backup: %v", err)
 	}
@@ -249,20 +349,54 @@ func saveLocalGroups(dbLocked *UserDBLocked, groups []types.GroupEntry) (err err
 		log.Warningf(context.Background(), "Failed make a backup for the group file: %v", err)
 	}
 
+	fi, err := os.Stat(inputPath)
+	if err != nil {
+		return err
+	}
+
 	tempPath := groupFileTemporaryPath(groupPath)
-	//nolint:gosec // G306 /etc/group should indeed have 0644 permissions
-	if err := os.WriteFile(tempPath, []byte(groupsEntries), 0644); err != nil {
+	if err := writeFileSync(tempPath, content, fi); err != nil {
+		_ = os.Remove(tempPath)
 		return fmt.Errorf("error writing %s: %w", tempPath, err)
 	}
 
 	if err := fileutils.Lrename(tempPath, groupPath); err != nil {
+		_ = os.Remove(tempPath)
 		return fmt.Errorf("error renaming %s to %s: %w", tempPath, groupPath, err)
 	}
 
-	lockedEntries.updateLocalGroupEntriesCache(groups)
 	return nil
 }
 
+// writeFileSync writes content to path, with the permissions and the ownership
+// of fi, and flushes it to the disk.
+func writeFileSync(path, content string, fi os.FileInfo) (err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	// The mode passed to OpenFile is filtered by the umask.
+	if err := f.Chmod(fi.Mode().Perm()); err != nil {
+		return err
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		if err := f.Chown(int(st.Uid), int(st.Gid)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := f.WriteString(content); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
 func validateChangedGroups(currentGroups, newGroups []types.GroupEntry) error {
 	changedGroups := sliceutils.DifferenceFunc(newGroups, currentGroups,
 		types.GroupEntry.Equals)
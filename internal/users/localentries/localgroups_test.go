@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"slices"
 	"testing"
+	"time"
 
 	"github.com/canonical/authd/internal/fileutils"
 	"github.com/canonical/authd/internal/testutils"
@@ -27,6 +28,7 @@ func TestUpdatelocalentries(t *testing.T) {
 		newGroups     []string
 		oldGroups     []string
 		groupFilePath string
+		lockFilePID   string
 
 		wantErr bool
 	}{
@@ -45,8 +47,13 @@ func TestUpdatelocalentries(t *testing.T) {
 		"Add_and_remove_user_from_multiple_groups_with_one_remaining":             {groupFilePath: "user_in_many_groups.group"},
 
 		// Flexible accepted cases
-		"Missing_group_is_ignored":              {groupFilePath: "missing_group.group"},
-		"Group_file_with_empty_line_is_ignored": {groupFilePath: "empty_line.group"},
+		"Missing_group_is_ignored":                   {groupFilePath: "missing_group.group"},
+		"Group_file_with_empty_line_is_ignored":      {groupFilePath: "empty_line.group"},
+		"Lines_of_other_groups_are_kept_as_they_are": {groupFilePath: "unformatted_lines.group"},
+		"Stale_group_file_lock_is_removed": {
+			groupFilePath: "no_users_in_our_groups.group",
+			lockFilePID:   "2147483647",
+		},
 
 		// No new groups
 		"No-Op_for_user_with_no_groups_and_was_in_none": {newGroups: []string{}, groupFilePath: "no_users_in_our_groups.group"},
@@ -83,6 +90,11 @@ func TestUpdatelocalentries(t *testing.T) {
 			groupFilePath: "malformed_file_duplicated.group",
 			wantErr:       true,
 		},
+		"Error_when_group_file_is_locked_by_another_process": {
+			groupFilePath: "no_users_in_our_groups.group",
+			lockFilePID:   "1",
+			wantErr:       true,
+		},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -109,12 +121,19 @@ func TestUpdatelocalentries(t *testing.T) {
 				inputGroupFilePath = tempGroupFile
 			}
 
+			lockFilePath := outputGroupFilePath + ".lock"
+			if tc.lockFilePID != "" {
+				err := os.WriteFile(lockFilePath, []byte(tc.lockFilePID), 0600)
+				require.NoError(t, err, "Setup: failed to create group file lock")
+			}
+
 			defer localentriestestutils.RequireGroupFile(t, outputGroupFilePath, golden.Path(t))
 
 			entries, entriesUnlock, err := localentries.WithUserDBLock(
 				localentries.WithGroupInputPath(inputGroupFilePath),
 				localentries.WithGroupOutputPath(outputGroupFilePath),
 				localentries.WithMockUserDBLocking(),
+				localentries.WithGroupFileLockTimeout(200*time.Millisecond),
 			)
 			require.NoError(t, err, "Failed to lock the local entries")
 			t.Cleanup(func() {
@@ -125,13 +144,46 @@ func TestUpdatelocalentries(t *testing.T) {
 			err = localentries.UpdateGroups(entries, tc.username, tc.newGroups, tc.oldGroups)
 			if tc.wantErr {
 				require.Error(t, err, "Updatelocalentries should have failed")
-			} else {
-				require.NoError(t, err, "Updatelocalentries should not have failed")
+				return
 			}
+			require.NoError(t, err, "Updatelocalentries should not have failed")
+			require.NoFileExists(t, lockFilePath, "Updatelocalentries should have released the group file lock")
 		})
 	}
 }
 
+func TestUpdateGroupsKeepsConcurrentChanges(t *testing.T) {
+	t.Parallel()
+
+	groupFilePath := filepath.Join(t.TempDir(), "group")
+	err := fileutils.CopyFile(filepath.Join("testdata", "no_users_in_our_groups.group"), groupFilePath)
+	require.NoError(t, err, "Setup: failed to copy group file for testing")
+
+	entries, entriesUnlock, err := localentries.WithUserDBLock(
+		localentries.WithGroupPath(groupFilePath),
+		localentries.WithMockUserDBLocking(),
+	)
+	require.NoError(t, err, "Setup: failed to lock the local entries")
+	t.Cleanup(func() { require.NoError(t, entriesUnlock(), "entriesUnlock should not fail") })
+
+	_, err = localentries.GetGroupEntries(entries)
+	require.NoError(t, err, "Setup: GetGroupEntries should not fail")
+
+	// Another tool adds a group after we read the group file.
+	f, err := os.OpenFile(groupFilePath, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err, "Setup: failed to open group file")
+	_, err = f.WriteString("othergroup:x:50:otheruser\n")
+	require.NoError(t, err, "Setup: failed to update group file")
+	require.NoError(t, f.Close(), "Setup: failed to close group file")
+
+	err = localentries.UpdateGroups(entries, "myuser", []string{"localgroup1", "othergroup"}, nil)
+	require.NoError(t, err, "UpdateGroups should not fail")
+
+	got, err := os.ReadFile(groupFilePath)
+	require.NoError(t, err, "Failed to read the group file")
+	golden.CheckOrUpdate(t, string(got))
+}
+
 func TestGetAndSaveLocalGroups(t *testing.T) {
 	t.Parallel()
 
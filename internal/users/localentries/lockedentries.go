@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"os/user"
 	"slices"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/canonical/authd/internal/decorate"
 	"github.com/canonical/authd/internal/testsdetection"
@@ -36,6 +38,11 @@ type options struct {
 	// [GroupFile], but can be overwritten in tests.
 	outputGroupPath string
 
+	// groupFileLockTimeout is how long we wait for another process to release
+	// the group file lock. Defaults to [defaultGroupFileLockTimeout], but can
+	// be overwritten in tests.
+	groupFileLockTimeout time.Duration
+
 	// These are the lock and unlock functions to be used that can be overridden
 	// for testing purposes.
 	writeLockFunc   func() error
@@ -55,6 +62,8 @@ var defaultOptions = options{
 	inputGroupPath:  GroupFile,
 	outputGroupPath: GroupFile,
 
+	groupFileLockTimeout: defaultGroupFileLockTimeout,
+
 	writeLockFunc:   userslocking.WriteLock,
 	writeUnlockFunc: userslocking.WriteUnlock,
 }
@@ -129,6 +138,7 @@ func WithUserDBLock(args ...Option) (userDB *UserDBLocked, unlock func() error,
 
 		log.Debug(context.Background(), "Unlocking local entries")
 		userDB.userEntries = nil
+		userDB.localUserEntries = nil
 		userDB.localGroupEntries = nil
 		userDB.groupEntries = nil
 
@@ -245,13 +255,14 @@ func (l *UserDBLocked) GetLocalGroupEntries() (entries []types.GroupEntry, err e
 }
 
 // updateLocalGroupEntriesCache updates the local group entries.
-func (l *UserDBLocked) updateLocalGroupEntriesCache(entries []types.GroupEntry) {
+func (l *UserDBLocked) updateLocalGroupEntriesCache(entries []types.GroupEntry, invalidEntries []invalidEntry) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
 	l.mustBeLocked()
 
 	l.localGroupEntries = types.DeepCopyGroupEntries(entries)
+	l.localGroupInvalidEntries = invalidEntries
 }
 
 // lockGroupFile locks the read/write operation on the group file and returns
@@ -263,6 +274,35 @@ func (l *UserDBLocked) lockGroupFile() (unlock func()) {
 	return l.localGroupsMu.Unlock
 }
 
+// lockGroupFileForUpdate locks the group file like [UserDBLocked.lockGroupFile]
+// and also takes the lock file used by the other tools updating it, see
+// [takeGroupFileLock].
+func (l *UserDBLocked) lockGroupFileForUpdate() (unlock func(), err error) {
+	unlockGroupFile := l.lockGroupFile()
+
+	unlockLockFile, err := takeGroupFileLock(l.options.outputGroupPath,
+		l.options.groupFileLockTimeout)
+	if err != nil {
+		unlockGroupFile()
+		return nil, err
+	}
+
+	return func() {
+		unlockLockFile()
+		unlockGroupFile()
+	}, nil
+}
+
+// groupFileToUpdate returns the path of the group file to update: the output
+// group file, or the input one if the output file wasn't written yet, as they
+// only differ in tests.
+func (l *UserDBLocked) groupFileToUpdate() string {
+	if _, err := os.Lstat(l.options.outputGroupPath); err == nil {
+		return l.options.outputGroupPath
+	}
+	return l.options.inputGroupPath
+}
+
 // IsUniqueUserName returns if a user exists for the given name.
 func (l *UserDBLocked) IsUniqueUserName(name string) (unique bool, err error) {
 	l.MustBeLocked()
@@ -38,3 +38,14 @@ func Z_ForTests_SetGroupPath(inputGroupPath, outputGroupPath string) {
 	defaultOptions.inputGroupPath = inputGroupPath
 	defaultOptions.outputGroupPath = outputGroupPath
 }
+
+// Z_ForTests_SetPasswdPath sets the passwdPath for the defaultOptions.
+// Tests using this can't be run in parallel.
+// Call Z_ForTests_RestoreDefaultOptions to restore the original value.
+//
+// nolint:revive,nolintlint // We want to use underscores in the function name here.
+func Z_ForTests_SetPasswdPath(inputPasswdPath string) {
+	testsdetection.MustBeTesting()
+
+	defaultOptions.inputPasswdPath = inputPasswdPath
+}
@@ -0,0 +1,104 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/canonical/authd/internal/decorate"
+	"github.com/canonical/authd/internal/users/db"
+)
+
+// machineTokenSize is the number of random bytes of the machine tokens.
+const machineTokenSize = 32
+
+// EnrollMachineToken generates a new machine token for the user with the given name, replacing their previous one,
+// and returns it with its expiry date. The user is looked up like when verifying the token, so that the names they
+// had before being renamed at the provider resolve to them. Only its hash is stored, so it can't be retrieved afterwards. If validity is 0,
+// the token never expires and the expiry date is the zero time.
+func (m *Manager) EnrollMachineToken(name string, validity time.Duration) (token string, expiresAt time.Time, err error) {
+	defer decorate.OnError(&err, "failed to enroll machine token of user %q", name)
+
+	u, err := m.userByAnyName(name)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	data := make([]byte, machineTokenSize)
+	if _, err := rand.Read(data); err != nil {
+		return "", time.Time{}, err
+	}
+	token = base64.RawURLEncoding.EncodeToString(data)
+
+	var expiry int64
+	if validity != 0 {
+		expiresAt = time.Now().Add(validity)
+		expiry = expiresAt.Unix()
+	}
+	if err := m.db.SetMachineToken(u.UID, hashMachineToken(token), expiry); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// RevokeMachineToken removes the machine token of the user with the given name. It returns a NoDataFoundError if the
+// user has none.
+func (m *Manager) RevokeMachineToken(name string) (err error) {
+	defer decorate.OnError(&err, "failed to revoke machine token of user %q", name)
+
+	u, err := m.userByAnyName(name)
+	if err != nil {
+		return err
+	}
+
+	return m.db.DeleteMachineToken(u.UID)
+}
+
+// HasMachineToken returns true if the user with the given name exists and has a machine token which didn't expire.
+func (m *Manager) HasMachineToken(name string) (bool, error) {
+	row, err := m.machineToken(name)
+	if errors.Is(err, db.NoDataFoundError{}) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !machineTokenExpired(row), nil
+}
+
+// VerifyMachineToken returns true if the token is the machine token of the user with the given name and didn't
+// expire.
+func (m *Manager) VerifyMachineToken(name, token string) (ok bool, err error) {
+	defer decorate.OnError(&err, "failed to verify machine token of user %q", name)
+
+	row, err := m.machineToken(name)
+	if err != nil {
+		return false, err
+	}
+	if machineTokenExpired(row) {
+		return false, nil
+	}
+	return subtle.ConstantTimeCompare([]byte(hashMachineToken(token)), []byte(row.Hash)) == 1, nil
+}
+
+func (m *Manager) machineToken(name string) (db.MachineTokenRow, error) {
+	u, err := m.userByAnyName(name)
+	if err != nil {
+		return db.MachineTokenRow{}, err
+	}
+	return m.db.MachineToken(u.UID)
+}
+
+// hashMachineToken returns the hash of the token stored in the database. The tokens are random, so they don't need a
+// slow password hash.
+func hashMachineToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func machineTokenExpired(row db.MachineTokenRow) bool {
+	return row.ExpiresAt != 0 && time.Now().Unix() >= row.ExpiresAt
+}
@@ -0,0 +1,94 @@
+package users_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMachineToken(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		username string
+		dbFile   string
+		validity time.Duration
+		token    string
+		reenroll bool
+		revoke   bool
+
+		wantOK        bool
+		wantEnrolled  bool
+		wantEnrollErr error
+	}{
+		"Accept_the_enrolled_token":              {wantOK: true, wantEnrolled: true},
+		"Accept_the_token_before_it_expires":     {validity: time.Hour, wantOK: true, wantEnrolled: true},
+		"Reject_another_token":                   {token: "not-the-token", wantEnrolled: true},
+		"Reject_an_expired_token":                {validity: -time.Hour},
+		"Reject_the_token_replaced_by_a_new_one": {reenroll: true, wantEnrolled: true},
+		"Reject_a_revoked_token":                 {revoke: true},
+		"Accept_the_token_enrolled_with_a_previous_name": {
+			username: "olduser1@example.com", dbFile: "one_user_and_group_with_secondary_name",
+			wantOK: true, wantEnrolled: true,
+		},
+
+		"Error_if_user_does_not_exist": {username: "doesnotexist@example.com", wantEnrollErr: db.NoDataFoundError{}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.username == "" {
+				tc.username = "user1@example.com"
+			}
+			if tc.dbFile == "" {
+				tc.dbFile = "one_user_and_group_with_providerid"
+			}
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", tc.dbFile+".db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m := newManagerForTests(t, dbDir)
+
+			has, err := m.HasMachineToken("user1@example.com")
+			require.NoError(t, err, "HasMachineToken should not return an error")
+			require.False(t, has, "User should not have a machine token before enrolling")
+
+			token, expiresAt, err := m.EnrollMachineToken(tc.username, tc.validity)
+			requireErrorAssertions(t, err, tc.wantEnrollErr, false)
+			if tc.wantEnrollErr != nil {
+				return
+			}
+			require.NotEmpty(t, token, "EnrollMachineToken should return the token")
+			require.Equal(t, tc.validity == 0, expiresAt.IsZero(), "EnrollMachineToken should only return an expiry date with a validity")
+
+			if tc.reenroll {
+				newToken, _, err := m.EnrollMachineToken(tc.username, 0)
+				require.NoError(t, err, "Setup: EnrollMachineToken should not return an error")
+				require.NotEqual(t, token, newToken, "EnrollMachineToken should generate a new token")
+			}
+			if tc.revoke {
+				require.NoError(t, m.RevokeMachineToken(tc.username), "RevokeMachineToken should not return an error")
+				require.ErrorIs(t, m.RevokeMachineToken(tc.username), db.NoDataFoundError{}, "RevokeMachineToken should fail without a token")
+			}
+			if tc.token != "" {
+				token = tc.token
+			}
+
+			has, err = m.HasMachineToken(tc.username)
+			require.NoError(t, err, "HasMachineToken should not return an error")
+			require.Equal(t, tc.wantEnrolled, has, "HasMachineToken should only report the tokens which can be used")
+
+			ok, err := m.VerifyMachineToken(tc.username, token)
+			if tc.revoke {
+				require.ErrorIs(t, err, db.NoDataFoundError{}, "VerifyMachineToken should fail without a token")
+				return
+			}
+			require.NoError(t, err, "VerifyMachineToken should not return an error")
+			require.Equal(t, tc.wantOK, ok, "VerifyMachineToken should only accept the enrolled token")
+		})
+	}
+}
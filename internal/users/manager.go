@@ -8,14 +8,18 @@ import (
 	"math"
 	"os"
 	"os/user"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/canonical/authd/internal/brokers/auth"
 	"github.com/canonical/authd/internal/decorate"
 	"github.com/canonical/authd/internal/fileutils"
+	"github.com/canonical/authd/internal/sliceutils"
 	"github.com/canonical/authd/internal/users/db"
 	"github.com/canonical/authd/internal/users/localentries"
 	userslocking "github.com/canonical/authd/internal/users/locking"
@@ -31,6 +35,34 @@ type Config struct {
 	UIDMax uint32 `mapstructure:"uid_max" yaml:"uid_max"`
 	GIDMin uint32 `mapstructure:"gid_min" yaml:"gid_min"`
 	GIDMax uint32 `mapstructure:"gid_max" yaml:"gid_max"`
+	// BrokerIDRanges are the ranges of the IDs of the users and groups of some brokers, by broker name, which must not
+	// overlap with the ranges above or with each other.
+	BrokerIDRanges map[string]IDRange `mapstructure:"broker_id_ranges" yaml:"broker_id_ranges,omitempty"`
+	// DatabaseSynchronous is the synchronous level of the database, see [db.WithSynchronous].
+	DatabaseSynchronous string `mapstructure:"database_synchronous" yaml:"database_synchronous"`
+	// DatabaseSlowQueryThreshold is the duration above which the database operations are logged as slow. If 0, they
+	// are not logged.
+	DatabaseSlowQueryThreshold time.Duration `mapstructure:"database_slow_query_threshold" yaml:"database_slow_query_threshold"`
+	// DatabaseReadConnections is the number of read-only connections of the NSS lookups, see
+	// [db.WithReadConnections]. If 0, they share the connection of the updates.
+	DatabaseReadConnections int `mapstructure:"database_read_connections" yaml:"database_read_connections,omitempty"`
+	// DefaultGroups are the local groups which the new users are added to, unless their broker sets its own.
+	DefaultGroups []string `mapstructure:"default_groups" yaml:"default_groups,omitempty"`
+	// DisableEnumeration stops listing all the users and groups to the unprivileged processes, like "getent passwd".
+	// They can still look them up by name or ID.
+	DisableEnumeration bool `mapstructure:"disable_enumeration" yaml:"disable_enumeration,omitempty"`
+
+	Lifecycle     LifecycleConfig     `mapstructure:",squash" yaml:",inline"`
+	HomeCleanup   HomeCleanupConfig   `mapstructure:",squash" yaml:",inline"`
+	IDAllocation  IDAllocationConfig  `mapstructure:",squash" yaml:",inline"`
+	Normalization NormalizationConfig `mapstructure:",squash" yaml:",inline"`
+	Snapshot      SnapshotConfig      `mapstructure:",squash" yaml:",inline"`
+	LocalAccounts LocalAccountsConfig `mapstructure:",squash" yaml:",inline"`
+	Homed         HomedConfig         `mapstructure:",squash" yaml:",inline"`
+	StaleUsers    StaleUsersConfig    `mapstructure:",squash" yaml:",inline"`
+	Revalidation  RevalidationConfig  `mapstructure:",squash" yaml:",inline"`
+	SCIM          SCIMConfig          `mapstructure:",squash" yaml:",inline"`
+	LDAPServer    LDAPServerConfig    `mapstructure:",squash" yaml:",inline"`
 }
 
 // DefaultConfig is the default configuration for the user manager.
@@ -39,6 +71,28 @@ var DefaultConfig = Config{
 	UIDMax: 60000,
 	GIDMin: 10000,
 	GIDMax: 60000,
+
+	DatabaseSynchronous:        db.DefaultSynchronous,
+	DatabaseSlowQueryThreshold: db.DefaultSlowQueryThreshold,
+
+	Lifecycle: LifecycleConfig{
+		ArchiveAfter: 30 * 24 * time.Hour,
+		DeleteAfter:  90 * 24 * time.Hour,
+	},
+
+	HomeCleanup: HomeCleanupConfig{
+		Policy: HomeCleanupKeep,
+		After:  30 * 24 * time.Hour,
+	},
+
+	Snapshot: SnapshotConfig{
+		Interval: 24 * time.Hour,
+		Keep:     7,
+	},
+
+	StaleUsers: StaleUsersConfig{
+		Action: StaleUserDisable,
+	},
 }
 
 // Manager is the manager for any user related operation.
@@ -52,10 +106,32 @@ type Manager struct {
 	config         Config
 	preAuthRecords *tempentries.PreAuthUserRecords
 	idGenerator    IDGeneratorIface
+	// brokerIDRanges are the ranges of the IDs of the users of some brokers, by broker ID.
+	brokerIDRanges map[string]IDRange
+
+	// lifecycleMu serializes the changes of the deprovisioning state of users, which check the current state
+	// before changing it.
+	lifecycleMu     sync.Mutex
+	homeArchivesDir string
+	snapshotsDir    string
+	homedSecretsDir string
+
+	// totpKeyMu serializes the generation of the key of the TOTP secrets.
+	totpKeyMu   sync.Mutex
+	totpKeyPath string
+
+	idAllocator    IDAllocator
+	changeNotifier ChangeNotifier
+
+	// rolledBack is closed once the database was rolled back, see [Manager.DatabaseRolledBack].
+	rolledBack chan struct{}
 }
 
 type options struct {
-	idGenerator IDGeneratorIface
+	idGenerator    IDGeneratorIface
+	brokerIDs      map[string]string
+	idAllocator    IDAllocator
+	changeNotifier ChangeNotifier
 }
 
 // Option is a function that allows changing some of the default behaviors of the manager.
@@ -78,6 +154,11 @@ func NewManager(config Config, dbDir string, args ...Option) (m *Manager, err er
 		arg(opts)
 	}
 
+	// The ranges of the brokers are also used by the import of users, whichever generator allocates the IDs.
+	if err := config.validateBrokerIDRanges(); err != nil {
+		return nil, err
+	}
+	brokerIDRanges := config.brokerIDRanges(opts.brokerIDs)
 	if opts.idGenerator == nil {
 		// Check that the ID ranges are valid.
 		if config.UIDMin >= config.UIDMax {
@@ -96,10 +177,6 @@ func NewManager(config Config, dbDir string, args ...Option) (m *Manager, err er
 		}
 
 		// Check that the ID ranges are not overlapping with systemd dynamic service users.
-		rangesOverlap := func(min1, max1, min2, max2 uint32) bool {
-			return (min1 <= max2 && max1 >= min2) || (min2 <= max1 && max2 >= min1)
-		}
-
 		if rangesOverlap(config.UIDMin, config.UIDMax, systemdDynamicUIDMin, systemdDynamicUIDMax) {
 			return nil, fmt.Errorf("UID range (%d-%d) overlaps with systemd dynamic service users range (%d-%d)", config.UIDMin, config.UIDMax, systemdDynamicUIDMin, systemdDynamicUIDMax)
 		}
@@ -115,27 +192,113 @@ func NewManager(config Config, dbDir string, args ...Option) (m *Manager, err er
 		}
 
 		opts.idGenerator = &IDGenerator{
-			UIDMin: config.UIDMin,
-			UIDMax: config.UIDMax,
-			GIDMin: config.GIDMin,
-			GIDMax: config.GIDMax,
+			UIDMin:       config.UIDMin,
+			UIDMax:       config.UIDMax,
+			GIDMin:       config.GIDMin,
+			GIDMax:       config.GIDMax,
+			BrokerRanges: brokerIDRanges,
+		}
+	}
+
+	if err := config.HomeCleanup.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.IDAllocation.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Normalization.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Snapshot.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.LocalAccounts.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.StaleUsers.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Revalidation.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.SCIM.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.LDAPServer.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Homed.validate(); err != nil {
+		return nil, err
+	}
+	for _, g := range config.DefaultGroups {
+		if g == "" || strings.ContainsAny(g, ":, ") {
+			return nil, fmt.Errorf("invalid group %q in default_groups", g)
 		}
 	}
+	if opts.idAllocator != nil && !config.IDAllocation.Remote() {
+		return nil, errors.New("an ID allocator can only be used with an ID allocation endpoint")
+	}
 
 	m = &Manager{
-		config:         config,
-		preAuthRecords: tempentries.NewPreAuthUserRecords(),
-		idGenerator:    opts.idGenerator,
+		config:          config,
+		preAuthRecords:  tempentries.NewPreAuthUserRecords(),
+		idGenerator:     opts.idGenerator,
+		brokerIDRanges:  brokerIDRanges,
+		homeArchivesDir: filepath.Join(dbDir, homeArchivesDirName),
+		snapshotsDir:    config.Snapshot.Dir,
+		homedSecretsDir: filepath.Join(dbDir, homedSecretsDirName),
+		totpKeyPath:     filepath.Join(dbDir, totpKeyFileName),
+		idAllocator:     opts.idAllocator,
+		changeNotifier:  opts.changeNotifier,
+		rolledBack:      make(chan struct{}),
 	}
 
-	m.db, err = db.New(dbDir)
+	if m.snapshotsDir == "" {
+		m.snapshotsDir = filepath.Join(dbDir, snapshotsDirName)
+	}
+
+	dbOpts := []db.Option{
+		db.WithSlowQueryThreshold(config.DatabaseSlowQueryThreshold),
+		db.WithReadConnections(config.DatabaseReadConnections),
+	}
+	if config.DatabaseSynchronous != "" {
+		dbOpts = append(dbOpts, db.WithSynchronous(config.DatabaseSynchronous))
+	}
+	m.db, err = db.New(dbDir, dbOpts...)
 	if err != nil {
 		return nil, err
 	}
 
+	storedUsers, err := m.db.AllUsers()
+	if err != nil {
+		return nil, errors.Join(err, m.db.Close())
+	}
+	if err := config.Normalization.checkStoredNames(storedUsers); err != nil {
+		return nil, errors.Join(err, m.db.Close())
+	}
+
 	return m, nil
 }
 
+// isLegacyUserOf returns true if the user stored without a provider ID is the account of the provider in u: the
+// user has the broker of u, if it was stored, and the name of the account before it was normalized, lowercased as
+// authd always did.
+func isLegacyUserOf(old types.UserInfo, u types.UserInfo) bool {
+	if old.BrokerID != "" && old.BrokerID != u.BrokerID {
+		return false
+	}
+	originalName := u.OriginalName
+	if originalName == "" {
+		originalName = u.Name
+	}
+	return old.Name == strings.ToLower(originalName)
+}
+
+// rangesOverlap returns true if the ranges [min1, max1] and [min2, max2] have IDs in common.
+func rangesOverlap(min1, max1, min2, max2 uint32) bool {
+	return (min1 <= max2 && max1 >= min2) || (min2 <= max1 && max2 >= min1)
+}
+
 // Stop closes the underlying db.
 func (m *Manager) Stop() error {
 	return m.db.Close()
@@ -170,9 +333,27 @@ func (m *Manager) UpdateUser(u types.UserInfo) (err error) {
 		}
 	}
 
-	// Prepend the user private group
-	u.Groups = append([]types.GroupInfo{{Name: u.Name, UGID: u.Name}}, u.Groups...)
-	userPrivateGroup := &u.Groups[0]
+	sharedPrimaryGID, err := m.sharedPrimaryGID(lookupName, u.SharedPrimaryGID)
+	if err != nil {
+		return err
+	}
+
+	defaultGroups, err := m.defaultGroups(lookupName, u.DefaultGroups)
+	if err != nil {
+		return err
+	}
+	for _, g := range defaultGroups {
+		if !slices.ContainsFunc(u.Groups, func(group types.GroupInfo) bool { return group.Name == g }) {
+			u.Groups = append(u.Groups, types.GroupInfo{Name: g})
+		}
+	}
+
+	// Prepend the user private group, unless the user is in a shared primary group.
+	var userPrivateGroup *types.GroupInfo
+	if sharedPrimaryGID == nil {
+		u.Groups = append([]types.GroupInfo{{Name: u.Name, UGID: u.Name}}, u.Groups...)
+		userPrivateGroup = &u.Groups[0]
+	}
 
 	var oldUserInfo *types.UserInfo
 	var pendingDiffs []string
@@ -191,10 +372,21 @@ func (m *Manager) UpdateUser(u types.UserInfo) (err error) {
 			return true, nil
 		}
 		if oldUserInfo.BrokerID != "" && u.BrokerID != "" && oldUserInfo.BrokerID != u.BrokerID {
-			// The broker ID scopes the stored provider ID and must not change once set: a user
-			// is bound to the broker they first authenticated with.
-			return false, fmt.Errorf("user %q is already bound to broker %q and cannot authenticate with broker %q",
-				u.Name, oldUserInfo.BrokerID, u.BrokerID)
+			if err := m.checkLinkedIdentity(oldUserInfo, u.BrokerID, u.ProviderID); err != nil {
+				return false, err
+			}
+			// The user authenticated with a linked identity, their entry stays bound to the identity of their broker.
+			u.ProviderID = oldUserInfo.ProviderID
+		}
+		if oldUserInfo.ProviderID != "" && u.ProviderID != "" && oldUserInfo.ProviderID != u.ProviderID {
+			// Another account of the provider already has this name, for example because both are normalized to
+			// the same name: don't let one of them take over the other.
+			return false, NameCollisionError{Name: u.Name}
+		}
+		if oldUserInfo.ProviderID == "" && u.ProviderID != "" && !isLegacyUserOf(*oldUserInfo, u) {
+			// The user was stored before the provider IDs were, so the name is all that identifies them: only the
+			// account of the same broker with the same name can take them over.
+			return false, NameCollisionError{Name: u.Name}
 		}
 		if oldUserInfo.BrokerID != "" {
 			// The broker ID scopes the stored provider ID and should not change after it is set.
@@ -256,9 +448,62 @@ func (m *Manager) UpdateUser(u types.UserInfo) (err error) {
 	}
 	defer func() { err = errors.Join(err, unlockEntries()) }()
 
+	var localAccount *types.UserEntry
+	if oldUserInfo == nil {
+		if localAccount, err = m.linkedLocalAccount(lockedEntries, u.Name); err != nil {
+			return err
+		}
+	}
+	if localAccount != nil {
+		// The user replaces the local user, whose primary group they get instead of a private group.
+		if userPrivateGroup != nil {
+			u.Groups = u.Groups[1:]
+			userPrivateGroup = nil
+		}
+		sharedPrimaryGID = &localAccount.GID
+	}
+
 	if oldUserInfo != nil {
 		// The user already exists in the database, use the existing UID to avoid permission issues.
 		u.UID = oldUserInfo.UID
+		if u.DirectoryUID != nil && *u.DirectoryUID != u.UID {
+			log.Warningf(context.Background(), "User %q has UID %d in the LDAP directory but %d on this machine, keeping it. It can be changed with \"authctl user set-uid\".",
+				u.Name, *u.DirectoryUID, u.UID)
+		}
+	} else if u.DirectoryUID != nil {
+		// The pre-auth record, if any, only reserved a UID until the user is created.
+		_, cleanup, err := m.preAuthRecords.MaybeCompletePreauthUser(u.Name)
+		if err != nil && !errors.Is(err, tempentries.NoDataFoundError{}) {
+			return err
+		}
+		if cleanup != nil {
+			defer cleanup()
+		}
+		// The user private group has the same ID as the user.
+		if err := m.checkAllocatedID(lockedEntries, *u.DirectoryUID, sharedPrimaryGID == nil); err != nil {
+			return fmt.Errorf("UID %d of user %q in the LDAP directory can't be used: %w", *u.DirectoryUID, u.Name, err)
+		}
+		u.UID = *u.DirectoryUID
+		log.Debugf(context.Background(), "Using UID %d of the LDAP directory for user %q", u.UID, u.Name)
+	} else if localAccount != nil {
+		// The pre-auth record, if any, only reserved a UID until the user is created.
+		_, cleanup, err := m.preAuthRecords.MaybeCompletePreauthUser(u.Name)
+		if err != nil && !errors.Is(err, tempentries.NoDataFoundError{}) {
+			return err
+		}
+		if cleanup != nil {
+			defer cleanup()
+		}
+		if unique, err := lockedEntries.IsUniqueUserName(u.Name); err != nil {
+			return err
+		} else if !unique {
+			return fmt.Errorf("another system user exists with %q name", u.Name)
+		}
+		// The files of the local user stay owned by them: the user gets their UID and their home directory, and the
+		// local user keeps their name in /etc/passwd.
+		u.UID = localAccount.UID
+		u.Dir = localAccount.Dir
+		log.Noticef(context.Background(), "User %q replaces the local user %q, with UID %d and home directory %q", u.Name, localAccount.Name, u.UID, u.Dir)
 	} else {
 		preauthUID, cleanup, err := m.preAuthRecords.MaybeCompletePreauthUser(u.Name)
 		if err != nil && !errors.Is(err, tempentries.NoDataFoundError{}) {
@@ -277,19 +522,25 @@ func (m *Manager) UpdateUser(u types.UserInfo) (err error) {
 				return fmt.Errorf("another system user exists with %q name", u.Name)
 			}
 
-			var cleanupUID func()
-			u.UID, cleanupUID, err = m.idGenerator.GenerateUID(lockedEntries, m)
-			if err != nil {
-				return err
+			if uid, ok := m.uidHint(lockedEntries, u); ok {
+				u.UID = uid
+				log.Debugf(context.Background(), "Using UID %d suggested by the broker for user %q", u.UID, u.Name)
+			} else {
+				var cleanupUID func()
+				u.UID, cleanupUID, err = m.newUserID(lockedEntries, u.Name, u.BrokerID)
+				if err != nil {
+					return err
+				}
+				defer cleanupUID()
+				log.Debugf(context.Background(), "Using new UID %d for user %q", u.UID, u.Name)
 			}
-			defer cleanupUID()
-			log.Debugf(context.Background(), "Using new UID %d for user %q", u.UID, u.Name)
 		}
 	}
 
 	var groupRows []db.GroupRow
 	var localGroups []string
 	var newGroups []types.GroupInfo
+	var createdGIDs []uint32
 	for i := range u.Groups {
 		g := &u.Groups[i]
 		if g.Name == "" {
@@ -326,6 +577,7 @@ func (m *Manager) UpdateUser(u types.UserInfo) (err error) {
 				// On first login the user private group doesn't exist yet, so we default to GID = UID.
 				// Subsequent logins will find the existing group above and preserve any custom GID.
 				g.GID = &u.UID
+				createdGIDs = append(createdGIDs, u.UID)
 			} else {
 				// Else, we add it to the list of new groups to create, since we need to generate a GID for it.
 				newGroups = append(newGroups, *g)
@@ -348,7 +600,7 @@ func (m *Manager) UpdateUser(u types.UserInfo) (err error) {
 				continue
 			}
 
-			gid, cleanupGID, err := m.idGenerator.GenerateGID(lockedEntries, m)
+			gid, cleanupGID, err := m.newGroupID(lockedEntries, g.UGID, u.BrokerID)
 			if err != nil {
 				return err
 			}
@@ -356,6 +608,7 @@ func (m *Manager) UpdateUser(u types.UserInfo) (err error) {
 
 			g.GID = &gid
 			groupRows = append(groupRows, db.NewGroupRow(g.Name, *g.GID, g.UGID))
+			createdGIDs = append(createdGIDs, gid)
 			log.Debugf(context.Background(), "Using new GID %d for group %q", gid, u.Name)
 		}
 	}
@@ -371,7 +624,19 @@ func (m *Manager) UpdateUser(u types.UserInfo) (err error) {
 		}
 	}
 
-	userRow := db.NewUserRow(u.Name, u.UID, *userPrivateGroup.GID, u.Gecos, u.Dir, u.Shell, u.BrokerID, u.ProviderID)
+	primaryGID := sharedPrimaryGID
+	if primaryGID == nil {
+		primaryGID = userPrivateGroup.GID
+	}
+	userRow := db.NewUserRow(u.Name, u.UID, *primaryGID, u.Gecos, u.Dir, u.Shell, u.BrokerID, u.ProviderID)
+
+	// systemd-homed refuses to create the user once authd provides it, and the users replacing a local user keep its
+	// home.
+	if oldUserInfo == nil && localAccount == nil && m.config.Homed.Enabled() {
+		if err := m.createHomedHome(u); err != nil {
+			log.Warningf(context.Background(), "Could not create the home of user %q with systemd-homed, it gets a plain home: %v", u.Name, err)
+		}
+	}
 
 	if err = m.db.UpdateUserEntry(userRow, groupRows, localGroups); err != nil {
 		return err
@@ -395,9 +660,59 @@ func (m *Manager) UpdateUser(u types.UserInfo) (err error) {
 		log.Warningf(context.Background(), "Failed to check home directory ownership: %v", err)
 	}
 
+	m.notifyUserUpdated(oldUserInfo, u, groupRows, createdGIDs)
+
 	return nil
 }
 
+// sharedPrimaryGID returns the GID of the shared primary group of the user, or nil if their primary group is their
+// private group. The new users get the primary group of their broker, while the existing users keep theirs so that
+// their files stay owned by it.
+func (m *Manager) sharedPrimaryGID(name string, brokerSharedGID *uint32) (*uint32, error) {
+	u, err := m.db.UserByName(name)
+	if errors.Is(err, db.NoDataFoundError{}) {
+		return brokerSharedGID, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The private groups are stored in the database, while the shared primary groups are groups of the system.
+	_, err = m.db.GroupByID(u.GID)
+	if errors.Is(err, db.NoDataFoundError{}) {
+		return &u.GID, nil
+	}
+	return nil, err
+}
+
+// defaultGroups returns the local groups which the user is added to in addition to the groups of their provider: the
+// default groups of their broker, or else of the configuration. The new users get all of them, while the existing
+// users only stay in those they're already a member of, so that a group added to the default groups isn't granted to
+// all the users at their next login.
+func (m *Manager) defaultGroups(name string, brokerGroups []string) ([]string, error) {
+	groups := brokerGroups
+	if len(groups) == 0 {
+		groups = m.config.DefaultGroups
+	}
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	u, err := m.db.UserByName(name)
+	if errors.Is(err, db.NoDataFoundError{}) {
+		return groups, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	localGroups, err := m.db.UserLocalGroups(u.UID)
+	if err != nil {
+		return nil, err
+	}
+	return sliceutils.Intersection(groups, localGroups), nil
+}
+
 func (m *Manager) getOldUserInfoFromDB(name string) (oldUserInfo *types.UserInfo, err error) {
 	oldUser, oldGroups, oldLocalGroups, err := m.db.UserWithGroups(name)
 	if err != nil && !errors.Is(err, db.NoDataFoundError{}) {
@@ -506,6 +821,7 @@ func (m *Manager) SetUserID(name string, uid uint32) (resp *SetUserIDResp, err e
 		return nil, err
 	}
 	resp.IDChanged = true
+	m.notifyUserChanged(Updated, name, uid)
 
 	// Check if the home directory is currently owned by the user.
 	homeUID, _, err := getHomeDirOwner(oldUser.Dir)
@@ -601,6 +917,11 @@ func (m *Manager) SetGroupID(name string, gid uint32) (resp *SetGroupIDResp, err
 		return nil, err
 	}
 	resp.IDChanged = true
+	m.notifyGroupChanged(Updated, name, gid)
+	// The primary group of these users changed too.
+	for _, userRow := range userRows {
+		m.notifyUserChanged(Updated, userRow.Name, userRow.UID)
+	}
 
 	for _, userRow := range userRows {
 		changed, warning, updateErr := m.updateUserHomeDirOwnership(userRow, oldGroup.GID, gid)
@@ -746,7 +1067,7 @@ func (m *Manager) SetShell(username, shell string) (warnings []string, err error
 	defer m.userManagementMu.Unlock()
 
 	// Check if the user exists
-	_, err = m.db.UserByName(username)
+	u, err := m.db.UserByName(username)
 	if err != nil {
 		return nil, err
 	}
@@ -765,6 +1086,7 @@ func (m *Manager) SetShell(username, shell string) (warnings []string, err error
 	if err = m.db.SetShell(username, shell); err != nil {
 		return warnings, err
 	}
+	m.notifyUserChanged(Updated, username, u.UID)
 
 	return warnings, nil
 }
@@ -829,6 +1151,7 @@ func (m *Manager) SetHomeDir(name, home string) (resp *SetHomeDirResp, err error
 			return nil, err
 		}
 		resp.HomeDirChanged = true
+		m.notifyUserChanged(Updated, name, oldUser.UID)
 		warning := fmt.Sprintf("Warning: Current home directory '%s' does not exist, not creating the new one.", oldUser.Dir)
 		log.Warning(context.Background(), warning)
 		resp.Warnings = append(resp.Warnings, warning)
@@ -858,13 +1181,14 @@ func (m *Manager) SetHomeDir(name, home string) (resp *SetHomeDirResp, err error
 	}
 	resp.HomeDirChanged = true
 	resp.HomeDirMoved = true
+	m.notifyUserChanged(Updated, name, oldUser.UID)
 
 	return resp, nil
 }
 
 // BrokerForUser returns the broker ID for the given user.
 func (m *Manager) BrokerForUser(username string) (string, error) {
-	u, err := m.db.UserByName(username)
+	u, err := m.userByAnyName(username)
 	if err != nil {
 		return "", err
 	}
@@ -876,7 +1200,7 @@ func (m *Manager) BrokerForUser(username string) (string, error) {
 // for the user in a single database lookup. Both values are empty if not recorded (pre-migration
 // user, v2 broker, or local user).
 func (m *Manager) BrokerAndProviderIDForUser(username string) (brokerID, providerID string, err error) {
-	u, err := m.db.UserByName(username)
+	u, err := m.userByAnyName(username)
 	if err != nil {
 		return "", "", err
 	}
@@ -884,8 +1208,19 @@ func (m *Manager) BrokerAndProviderIDForUser(username string) (brokerID, provide
 	return u.BrokerID, u.ProviderID, nil
 }
 
-// UpdateBrokerForUser updates the broker ID for the given user.
+// UpdateBrokerForUser updates the broker ID for the given user. The users authenticating with one of their linked
+// identities stay bound to their broker.
 func (m *Manager) UpdateBrokerForUser(username, brokerID string) error {
+	u, err := m.db.UserByName(username)
+	if err != nil {
+		return err
+	}
+	if _, err := m.db.LinkedIdentity(u.UID, brokerID); err == nil {
+		return nil
+	} else if !errors.Is(err, db.NoDataFoundError{}) {
+		return err
+	}
+
 	if err := m.db.UpdateBrokerForUser(username, brokerID); err != nil {
 		return err
 	}
@@ -898,15 +1233,26 @@ func (m *Manager) LockUser(username string) error {
 	if err := m.db.UpdateLockedFieldForUser(username, true); err != nil {
 		return err
 	}
+	m.notifyUserChangedByName(Disabled, username)
 
 	return nil
 }
 
 // UnlockUser sets the "locked" field to false for the given user.
+// Users being deprovisioned must be restored instead.
 func (m *Manager) UnlockUser(username string) error {
+	lc, err := m.UserLifecycle(username)
+	if err != nil {
+		return err
+	}
+	if lc.State != LifecycleActive {
+		return LifecycleError{Username: username, State: lc.State, Operation: "unlocked"}
+	}
+
 	if err := m.db.UpdateLockedFieldForUser(username, false); err != nil {
 		return err
 	}
+	m.notifyUserChangedByName(Updated, username)
 
 	return nil
 }
@@ -929,7 +1275,7 @@ func (m *Manager) DeleteUser(username string, removeHome bool) (err error) {
 	defer func() { err = errors.Join(err, unlockEntries()) }()
 
 	// Remove the user from any local groups they are a member of.
-	_, _, localGroups, err := m.db.UserWithGroups(username)
+	_, groups, localGroups, err := m.db.UserWithGroups(username)
 	if err != nil {
 		return err
 	}
@@ -952,15 +1298,98 @@ func (m *Manager) DeleteUser(username string, removeHome bool) (err error) {
 		}
 	}
 
+	m.notifyUserChanged(Removed, username, userRow.UID)
+	for _, g := range groups {
+		if g.GID == userRow.GID && len(primaryUserNames) == 0 {
+			m.notifyGroupChanged(Removed, g.Name, g.GID)
+			continue
+		}
+		m.notifyGroupChanged(Updated, g.Name, g.GID)
+	}
+
+	if removeHome {
+		if err := m.removeHomedHome(username); err != nil {
+			return fmt.Errorf("failed to remove the systemd-homed home of user %q: %w", username, err)
+		}
+	} else if err := m.keepHomedSecret(username); err != nil {
+		return fmt.Errorf("failed to keep the secret of the systemd-homed home of user %q: %w", username, err)
+	}
 	if removeHome && userRow.Dir != "" {
 		if err := os.RemoveAll(userRow.Dir); err != nil {
 			return fmt.Errorf("failed to remove home directory %q for user %q: %w", userRow.Dir, username, err)
 		}
 	}
 
+	// The home of archived users is in their archive.
+	if removeHome {
+		archive := m.homeArchivePath(username)
+		if err := os.Remove(archive); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove home archive %q for user %q: %w", archive, username, err)
+		}
+	}
+
+	// The home directory and the mail spool left behind are cleaned up later, according to the policy.
+	if !removeHome {
+		m.recordDeletedUser(userRow)
+	}
+
 	return nil
 }
 
+// DeleteUserArchivingHome removes the user with the given name from the database, like DeleteUser, after moving their
+// home directory to the archives of authd. It returns the path of the archive, which is empty if the user had no home
+// directory.
+func (m *Manager) DeleteUserArchivingHome(ctx context.Context, username string) (archive string, err error) {
+	m.lifecycleMu.Lock()
+	defer m.lifecycleMu.Unlock()
+
+	userRow, err := m.db.UserByName(username)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(m.homedSecretPath(username)); err == nil {
+		return "", fmt.Errorf("the home of user %q is managed by systemd-homed and can't be archived", username)
+	}
+
+	// A user with the same name may be deleted again later, so the archives are named after the deletion time.
+	archive = filepath.Join(m.homeArchivesDir, username+".deleted-"+strconv.FormatInt(time.Now().Unix(), 10)+".tar.gz")
+
+	// The home of the archived users is already in their archive, which DeleteUser would remove.
+	lifecycleArchive := m.homeArchivePath(username)
+	if fileExists(lifecycleArchive) {
+		if err := os.Rename(lifecycleArchive, archive); err != nil {
+			return "", err
+		}
+		defer func() {
+			if err != nil {
+				_ = os.Rename(archive, lifecycleArchive)
+			}
+		}()
+	} else if _, err := os.Stat(userRow.Dir); err == nil {
+		if err := archiveHome(userRow.Dir, archive); err != nil {
+			return "", fmt.Errorf("could not archive home directory %q of user %q: %w", userRow.Dir, username, err)
+		}
+		defer func() {
+			if err != nil {
+				_ = os.Remove(archive)
+			}
+		}()
+	} else if errors.Is(err, os.ErrNotExist) {
+		archive = ""
+	} else {
+		return "", fmt.Errorf("could not check home directory %q of user %q: %w", userRow.Dir, username, err)
+	}
+
+	if err := m.DeleteUser(username, true); err != nil {
+		return "", err
+	}
+
+	if archive != "" {
+		log.Noticef(ctx, "Home directory %q of deleted user %q archived to %q", userRow.Dir, username, archive)
+	}
+	return archive, nil
+}
+
 // usersWithPrimaryGroup returns the names of users for which the given GID is
 // their primary group. It returns an empty slice when no such users exist.
 func (m *Manager) usersWithPrimaryGroup(gid uint32) ([]string, error) {
@@ -994,7 +1423,12 @@ func (m *Manager) DeleteGroup(groupname string) error {
 		return GroupIsPrimaryError{GroupName: groupname, Users: primaryUserNames}
 	}
 
-	return m.db.DeleteGroup(groupRow.GID)
+	if err := m.db.DeleteGroup(groupRow.GID); err != nil {
+		return err
+	}
+	m.notifyGroupChanged(Removed, groupname, groupRow.GID)
+
+	return nil
 }
 
 // IsUserLocked returns true if the user with the given user name is locked, false otherwise.
@@ -1019,15 +1453,283 @@ func (m *Manager) IsUserLockedByProviderID(brokerID, providerID string) (bool, e
 	return u.Locked, nil
 }
 
+// RecordLogin adds an entry to the login history of the user with the given name.
+// Only users already stored in the database have a login history.
+func (m *Manager) RecordLogin(username string, r types.LoginRecord) (err error) {
+	defer decorate.OnError(&err, "failed to record login for user %q", username)
+
+	u, err := m.db.UserByName(username)
+	if err != nil {
+		return err
+	}
+
+	row := db.NewLoginRecordRow(u.UID, r.Time, r.Service, r.BrokerID, r.Offline, r.Result)
+	row.MachineToken = r.MachineToken
+	return m.db.AddLoginRecord(row)
+}
+
+// LoginHistory returns the most recent login records of the user with the given name, newest first.
+// If limit is 0, all stored records are returned.
+func (m *Manager) LoginHistory(username string, limit uint32) ([]types.LoginRecord, error) {
+	u, err := m.db.UserByName(username)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.LoginHistory(u.UID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []types.LoginRecord
+	for _, r := range rows {
+		records = append(records, loginRecordFromRow(r))
+	}
+	return records, nil
+}
+
+// LastOnlineLogin returns the time of the last successful login of the user with their identity provider, or the
+// zero time if there is none in their login history.
+func (m *Manager) LastOnlineLogin(username string) (time.Time, error) {
+	records, err := m.LoginHistory(username, 0)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, r := range records {
+		if !r.Offline && !r.MachineToken && r.Result == auth.Granted {
+			return r.Time, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// OfflineLoginsSinceOnlineLogin returns the number of successful offline logins of the user since their last
+// successful login with their identity provider, as far as their login history goes back. The logins with a machine
+// token are neither.
+func (m *Manager) OfflineLoginsSinceOnlineLogin(username string) (n int, err error) {
+	records, err := m.LoginHistory(username, 0)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range records {
+		if r.Result != auth.Granted || r.MachineToken {
+			continue
+		}
+		if !r.Offline {
+			break
+		}
+		n++
+	}
+	return n, nil
+}
+
+// LastOnlineLoginsByBroker returns the time of the last successful login of a user with their identity provider, by
+// broker ID. It's when the broker last got a new token for one of its users.
+func (m *Manager) LastOnlineLoginsByBroker() (map[string]time.Time, error) {
+	return m.db.LastLoginsByBroker(false, auth.Granted)
+}
+
+// LastOfflineLoginsByBroker returns the time of the last successful offline login of a user, by broker ID. It's when
+// the broker last granted access with the information it cached, because it couldn't reach the identity provider.
+func (m *Manager) LastOfflineLoginsByBroker() (map[string]time.Time, error) {
+	return m.db.LastLoginsByBroker(true, auth.Granted)
+}
+
+// Stats are statistics about the users and groups managed by authd.
+type Stats struct {
+	db.Stats
+	// PendingUsers is the number of users authenticating for the first time, who are not stored in the database yet.
+	PendingUsers int
+}
+
+// Stats returns statistics about the database and the users being authenticated.
+func (m *Manager) Stats() (Stats, error) {
+	dbStats, err := m.db.Stats()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	tempUsers, err := m.preAuthRecords.AllUsers()
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to get temporary users: %w", err)
+	}
+
+	return Stats{Stats: dbStats, PendingUsers: len(tempUsers)}, nil
+}
+
+// RollBackDatabase reverts the schema migrations applied to the database since its last backup, so that the previous
+// version of authd can be installed again. It returns the schema versions of the database before and after the
+// rollback.
+//
+// The database is closed once it's rolled back, as this version of authd can't use it anymore, so the daemon must stop
+// when [Manager.DatabaseRolledBack] is closed.
+func (m *Manager) RollBackDatabase() (from, to int, err error) {
+	from, to, err = m.db.RollBack()
+	if err != nil {
+		return 0, 0, err
+	}
+	close(m.rolledBack)
+	return from, to, nil
+}
+
+// DatabaseRolledBack returns a channel which is closed once the database was rolled back.
+func (m *Manager) DatabaseRolledBack() <-chan struct{} {
+	return m.rolledBack
+}
+
+// UserGroups returns the names of the groups of the user with the given name.
+func (m *Manager) UserGroups(username string) ([]string, error) {
+	u, err := m.db.UserByName(username)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := m.db.UserGroups(u.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, g := range groups {
+		names = append(names, g.Name)
+	}
+	return names, nil
+}
+
+// GroupsForUser returns the groups of the user with the given name, without their members. The secondary names of the
+// users are resolved too, see userByAnyName.
+func (m *Manager) GroupsForUser(username string) ([]types.GroupEntry, error) {
+	u, err := m.userByAnyName(username)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := m.db.UserGroups(u.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []types.GroupEntry
+	for _, g := range groups {
+		entries = append(entries, types.GroupEntry{Name: g.Name, GID: g.GID})
+	}
+	return entries, nil
+}
+
+// CachedUserInfo returns the information stored for the user with the given name, as returned by their broker on
+// their last successful authentication.
+func (m *Manager) CachedUserInfo(username string) (types.UserInfo, error) {
+	u, groups, localGroups, err := m.db.UserWithGroups(username)
+	if err != nil {
+		return types.UserInfo{}, err
+	}
+	return *userInfoFromUserAndGroupRows(u, groups, localGroups), nil
+}
+
+// SetSessionEnv replaces the environment variables exported in the sessions of the user with the given name.
+func (m *Manager) SetSessionEnv(username string, env map[string]string) (err error) {
+	defer decorate.OnError(&err, "failed to set session environment of user %q", username)
+
+	u, err := m.db.UserByName(username)
+	if err != nil {
+		return err
+	}
+
+	return m.db.SetSessionEnv(u.UID, env)
+}
+
+// SessionEnv returns the environment variables exported in the sessions of the user with the given name.
+func (m *Manager) SessionEnv(username string) (map[string]string, error) {
+	u, err := m.db.UserByName(username)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.db.SessionEnv(u.UID)
+}
+
+// SetSSHKeys replaces the SSH public keys which the user with the given name can log in with.
+func (m *Manager) SetSSHKeys(username string, keys []string) (err error) {
+	defer decorate.OnError(&err, "failed to set SSH keys of user %q", username)
+
+	u, err := m.db.UserByName(username)
+	if err != nil {
+		return err
+	}
+
+	return m.db.SetSSHKeys(u.UID, keys)
+}
+
+// SSHKeys returns the SSH public keys which the user with the given name can log in with. Locked users have none, so
+// that they can't log in with their keys.
+func (m *Manager) SSHKeys(username string) ([]string, error) {
+	u, err := m.userByAnyName(username)
+	if err != nil {
+		return nil, err
+	}
+	if u.Locked {
+		return nil, nil
+	}
+
+	return m.db.SSHKeys(u.UID)
+}
+
+// SetLastAuthMode stores the broker and the authentication mode with which the user with the given name logged in, so
+// that the mode is preselected on their next login.
+func (m *Manager) SetLastAuthMode(username, brokerID, authMode string) (err error) {
+	defer decorate.OnError(&err, "failed to set last authentication mode of user %q", username)
+
+	u, err := m.db.UserByName(username)
+	if err != nil {
+		return err
+	}
+
+	return m.db.SetLastAuthMode(u.UID, brokerID, authMode)
+}
+
+// LastAuthMode returns the authentication mode with which the user with the given name last logged in with the broker.
+// It returns an empty mode if the user never logged in with the broker, or logged in with another one since then.
+func (m *Manager) LastAuthMode(username, brokerID string) (string, error) {
+	u, err := m.userByAnyName(username)
+	if errors.Is(err, db.NoDataFoundError{}) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	r, err := m.db.LastAuthMode(u.UID)
+	if errors.Is(err, db.NoDataFoundError{}) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if r.BrokerID != brokerID {
+		return "", nil
+	}
+	return r.AuthMode, nil
+}
+
 // UserByName returns the user information for the given user name.
 func (m *Manager) UserByName(username string) (types.UserEntry, error) {
-	usr, err := m.db.UserByName(username)
+	usr, err := m.userByAnyName(username)
 	if err != nil {
 		return types.UserEntry{}, err
 	}
 	return userEntryFromUserRow(usr), nil
 }
 
+// userByAnyName returns the user with the given name or, if there is none, the user who has it as one of their
+// secondary names, so that the names a user had before being renamed at the provider keep resolving to them.
+func (m *Manager) userByAnyName(username string) (db.UserRow, error) {
+	u, err := m.db.UserByName(username)
+	if errors.Is(err, db.NoDataFoundError{}) {
+		return m.db.UserBySecondaryName(username)
+	}
+	return u, err
+}
+
 // UserByID returns the user information for the given user ID.
 func (m *Manager) UserByID(uid uint32) (types.UserEntry, error) {
 	usr, err := m.db.UserByID(uid)
@@ -1057,6 +1759,21 @@ func (m *Manager) AllUsers() ([]types.UserEntry, error) {
 	return usrEntries, err
 }
 
+// UsersPage returns at most limit users with a UID greater than afterUID, ordered by UID. A negative limit returns
+// all of them. As in AllUsers, temporary users are not returned.
+func (m *Manager) UsersPage(afterUID uint32, limit int) ([]types.UserEntry, error) {
+	usrs, err := m.db.UsersPage(afterUID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var usrEntries []types.UserEntry
+	for _, usr := range usrs {
+		usrEntries = append(usrEntries, userEntryFromUserRow(usr))
+	}
+	return usrEntries, nil
+}
+
 // UsedUIDs returns all user IDs, including the UIDs of temporary pre-auth users.
 func (m *Manager) UsedUIDs() ([]uint32, error) {
 	var uids []uint32
@@ -1078,6 +1795,13 @@ func (m *Manager) UsedUIDs() ([]uint32, error) {
 		uids = append(uids, tempUser.UID)
 	}
 
+	// Add the IDs allocated to the users and groups of other instances, if this instance is the ID allocation authority.
+	allocatedIDs, err := m.db.AllocatedIDs()
+	if err != nil {
+		return nil, err
+	}
+	uids = append(uids, allocatedIDs...)
+
 	return uids, nil
 }
 
@@ -1118,6 +1842,21 @@ func (m *Manager) AllGroups() ([]types.GroupEntry, error) {
 	return grpEntries, nil
 }
 
+// GroupsPage returns at most limit groups with a GID greater than afterGID, ordered by GID. A negative limit returns
+// all of them. As in AllGroups, temporary groups are not returned.
+func (m *Manager) GroupsPage(afterGID uint32, limit int) ([]types.GroupEntry, error) {
+	grps, err := m.db.GroupsWithMembersPage(afterGID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var grpEntries []types.GroupEntry
+	for _, grp := range grps {
+		grpEntries = append(grpEntries, groupEntryFromGroupWithMembers(grp))
+	}
+	return grpEntries, nil
+}
+
 // UsedGIDs returns all group IDs, including the GIDs of temporary pre-auth users.
 func (m *Manager) UsedGIDs() ([]uint32, error) {
 	var gids []uint32
@@ -1147,12 +1886,19 @@ func (m *Manager) UsedGIDs() ([]uint32, error) {
 		gids = append(gids, tu.GID)
 	}
 
+	// The UIDs allocated to users are also the GIDs of their user private groups.
+	allocatedIDs, err := m.db.AllocatedIDs()
+	if err != nil {
+		return nil, err
+	}
+	gids = append(gids, allocatedIDs...)
+
 	return gids, nil
 }
 
 // ShadowByName returns the shadow information for the given user name.
 func (m *Manager) ShadowByName(username string) (types.ShadowEntry, error) {
-	usr, err := m.db.UserByName(username)
+	usr, err := m.userByAnyName(username)
 	if err != nil {
 		return types.ShadowEntry{}, err
 	}
@@ -1174,9 +1920,10 @@ func (m *Manager) AllShadows() ([]types.ShadowEntry, error) {
 }
 
 // RegisterUserPreAuth registers a temporary user with a unique UID in our NSS handler (in memory, not in the database).
+// The UID is in the range of the broker which permitted the user to log in.
 //
 // The temporary user record is removed when UpdateUser is called with the same username.
-func (m *Manager) RegisterUserPreAuth(name string) (uid uint32, err error) {
+func (m *Manager) RegisterUserPreAuth(name, brokerID string) (uid uint32, err error) {
 	defer decorate.OnError(&err, "failed to register pre-auth user %q", name)
 
 	// Do a first check without the lock, so that if the user is already there
@@ -1219,7 +1966,7 @@ func (m *Manager) RegisterUserPreAuth(name string) (uid uint32, err error) {
 		return 0, fmt.Errorf("another system user exists with %q name", name)
 	}
 
-	uid, cleanupUID, err := m.idGenerator.GenerateUID(lockedEntries, m)
+	uid, cleanupUID, err := m.newUserID(lockedEntries, name, brokerID)
 	if err != nil {
 		return 0, err
 	}
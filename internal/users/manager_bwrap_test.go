@@ -389,6 +389,38 @@ func TestSetHomeDirAcrossFilesystems(t *testing.T) {
 	require.NoDirExists(t, newHome, "New home directory should not have been created")
 }
 
+func TestImportRecordsWithRealLocking(t *testing.T) {
+	// Not parallel: ImportRecords takes the lock of the user database of the system.
+
+	if !testutils.RunningInBubblewrap() {
+		testutils.RunTestInBubbleWrap(t)
+		return
+	}
+
+	addGroupToSystem(t, 4242)
+
+	dbDir := t.TempDir()
+	err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "one_user_and_group_with_providerid.db.yaml"), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+	m := newManagerForTests(t, dbDir)
+
+	records, err := m.ExportRecords()
+	require.NoError(t, err, "Setup: ExportRecords should not return an error")
+	records.Users = append(records.Users, users.UserRecord{
+		Name: "user2@example.com", UID: 22222, GID: 22222, Dir: "/home/user2@example.com", Shell: "/bin/bash",
+		BrokerID: "broker-id", ProviderID: "providerid-user2", LocalGroups: []string{"test-4242"},
+	})
+
+	// The user database is locked and unlocked by each import, so importing twice checks that the lock is released.
+	for range 2 {
+		require.NoError(t, m.ImportRecords(records), "ImportRecords should not return an error")
+	}
+
+	output, err := exec.Command("getent", "group", "test-4242").CombinedOutput()
+	require.NoError(t, err, "Setup: getent failed: %s", output)
+	require.Equal(t, "test-4242:x:4242:user2@example.com\n", string(output), "The imported user should be added to their local group")
+}
+
 // createTemporaryHome creates a temporary home directory for the given user.
 func createTemporaryHome(t *testing.T, uid, gid int, inaccessible, cannotBeChanged bool) string {
 	t.Helper()
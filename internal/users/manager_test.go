@@ -13,6 +13,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/canonical/authd/internal/brokers/auth"
 	"github.com/canonical/authd/internal/consts"
 	"github.com/canonical/authd/internal/testutils"
 	"github.com/canonical/authd/internal/testutils/golden"
@@ -37,6 +38,8 @@ func TestNewManager(t *testing.T) {
 		uidMax          uint32
 		gidMin          uint32
 		gidMax          uint32
+		brokerIDRanges  map[string]users.IDRange
+		idGenerator     users.IDGeneratorIface
 
 		wantErr bool
 	}{
@@ -60,6 +63,27 @@ func TestNewManager(t *testing.T) {
 		"Error_if_GID_range_overlaps_with_systemd_dynamic_groups": {gidMin: users.SystemdDynamicUIDMin, gidMax: users.SystemdDynamicUIDMax, wantErr: true},
 		"Error_if_UID_range_is_larger_than_max_signed_int32":      {uidMin: 0, uidMax: math.MaxInt32 + 1, wantErr: true},
 		"Error_if_GID_range_is_larger_than_max_signed_int32":      {gidMin: 0, gidMax: math.MaxInt32 + 1, wantErr: true},
+
+		// Invalid broker ID ranges
+		"Error_if_broker_UID_MIN_is_equal_to_UID_MAX": {brokerIDRanges: map[string]users.IDRange{
+			"broker": {UIDMin: 100000, UIDMax: 100000, GIDMin: 100000, GIDMax: 200000},
+		}, wantErr: true},
+		"Error_if_broker_UID_range_is_too_small": {brokerIDRanges: map[string]users.IDRange{
+			"broker": {UIDMin: 100000, UIDMax: 101000, GIDMin: 100000, GIDMax: 200000},
+		}, wantErr: true},
+		"Error_if_broker_GID_range_is_too_small": {brokerIDRanges: map[string]users.IDRange{
+			"broker": {UIDMin: 100000, UIDMax: 200000, GIDMin: 100000, GIDMax: 101000},
+		}, wantErr: true},
+		"Error_if_broker_range_is_invalid_with_a_custom_ID_generator": {brokerIDRanges: map[string]users.IDRange{
+			"broker": {UIDMin: 100000, UIDMax: 200000, GIDMin: 50000, GIDMax: 60000},
+		}, idGenerator: &users.IDGeneratorMock{}, wantErr: true},
+		"Error_if_broker_range_overlaps_with_the_default_range": {brokerIDRanges: map[string]users.IDRange{
+			"broker": {UIDMin: 100000, UIDMax: 200000, GIDMin: 50000, GIDMax: 60000},
+		}, wantErr: true},
+		"Error_if_broker_ranges_overlap": {brokerIDRanges: map[string]users.IDRange{
+			"broker1": {UIDMin: 100000, UIDMax: 200000, GIDMin: 100000, GIDMax: 200000},
+			"broker2": {UIDMin: 300000, UIDMax: 400000, GIDMin: 150000, GIDMax: 160000},
+		}, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -95,8 +119,13 @@ func TestNewManager(t *testing.T) {
 			if tc.gidMax != 0 {
 				config.GIDMax = tc.gidMax
 			}
+			config.BrokerIDRanges = tc.brokerIDRanges
 
-			m, err := users.NewManager(config, dbDir)
+			var opts []users.Option
+			if tc.idGenerator != nil {
+				opts = append(opts, users.WithIDGenerator(tc.idGenerator))
+			}
+			m, err := users.NewManager(config, dbDir, opts...)
 			if tc.wantErr {
 				t.Logf("Manager creation exited with %v", err)
 				require.Error(t, err, "NewManager should return an error, but did not")
@@ -333,7 +362,7 @@ func TestUpdateUserProviderIDHandling(t *testing.T) {
 		err = m.UpdateUser(newUser("newuser1@example.com", "providerid-user1", types.GroupInfo{Name: "localgroup1", UGID: ""}))
 		require.NoError(t, err, "UpdateUser should not return an error, but did")
 
-		_, err = m.UserByName("user1@example.com")
+		_, err = userstestutils.DBManager(m).UserByName("user1@example.com")
 		require.Error(t, err, "old username should no longer exist")
 
 		renamed, err := userstestutils.DBManager(m).UserByName("newuser1@example.com")
@@ -341,6 +370,11 @@ func TestUpdateUserProviderIDHandling(t *testing.T) {
 		require.Equal(t, oldUser.UID, renamed.UID, "UID should be preserved when renaming by provider ID")
 		require.Equal(t, "providerid-user1", renamed.ProviderID, "provider ID should be preserved when renaming by provider ID")
 
+		byOldName, err := m.UserByName("user1@example.com")
+		require.NoError(t, err, "old username should still resolve to the renamed user")
+		require.Equal(t, "newuser1@example.com", byOldName.Name, "old username should resolve to the renamed user")
+		require.Equal(t, oldUser.UID, byOldName.UID, "old username should resolve to the UID of the renamed user")
+
 		groupContent, err := os.ReadFile(destGroupFile)
 		require.NoError(t, err, "could not read mocked group file")
 		require.Equal(t, "localgroup1:x:41:newuser1@example.com\n", string(groupContent),
@@ -505,7 +539,7 @@ func TestUpdateUserProviderIDHandling(t *testing.T) {
 		err = m.UpdateUser(newUser("newuser1@example.com", "providerid-user1"))
 		require.NoError(t, err, "UpdateUser should succeed when renaming a user whose private group has UGID == Name")
 
-		_, err = m.UserByName("user1@example.com")
+		_, err = userstestutils.DBManager(m).UserByName("user1@example.com")
 		require.Error(t, err, "old username should no longer exist after rename")
 
 		_, err = m.UserByName("newuser1@example.com")
@@ -538,6 +572,400 @@ func TestUpdateUserProviderIDHandling(t *testing.T) {
 	})
 }
 
+func TestUpdateUserDefaultGroups(t *testing.T) {
+	// This test is not parallel because it uses SetupGroupMock, which mutates the process-global localentries options.
+	destGroupFile := localgroupstestutils.SetupGroupMock(t, filepath.Join("testdata", "groups", "default_groups.group"))
+
+	newUser := func(name string, defaultGroups ...string) types.UserInfo {
+		return types.UserInfo{
+			Name:          name,
+			Gecos:         "gecos for " + name,
+			Dir:           "/home/" + name,
+			Shell:         "/bin/bash",
+			DefaultGroups: defaultGroups,
+		}
+	}
+
+	dbDir := t.TempDir()
+	config := users.DefaultConfig
+	config.DefaultGroups = []string{"users", "plugdev"}
+	m, err := users.NewManager(config, dbDir)
+	require.NoError(t, err, "Setup: NewManager should not return an error")
+
+	// The new users get the default groups of the configuration, or of their broker.
+	err = m.UpdateUser(newUser("user1@example.com"))
+	require.NoError(t, err, "UpdateUser should not return an error")
+	err = m.UpdateUser(newUser("user2@example.com", "lpadmin"))
+	require.NoError(t, err, "UpdateUser should not return an error")
+	require.NoError(t, m.Stop(), "Setup: Stop should not return an error")
+
+	// The existing users are not added to the groups added to the default groups.
+	config.DefaultGroups = []string{"users", "plugdev", "lpadmin"}
+	m, err = users.NewManager(config, dbDir)
+	require.NoError(t, err, "Setup: NewManager should not return an error")
+	t.Cleanup(func() { _ = m.Stop() })
+	err = m.UpdateUser(newUser("user1@example.com"))
+	require.NoError(t, err, "UpdateUser should not return an error")
+
+	localgroupstestutils.RequireGroupFile(t, destGroupFile, golden.Path(t))
+}
+
+func TestNewManagerWithInvalidDefaultGroups(t *testing.T) {
+	t.Parallel()
+
+	config := users.DefaultConfig
+	config.DefaultGroups = []string{"users", "plug:dev"}
+	_, err := users.NewManager(config, t.TempDir())
+	require.Error(t, err, "NewManager should return an error for an invalid default group")
+}
+
+func TestUpdateUserSharedPrimaryGroup(t *testing.T) {
+	sharedGID := uint32(100)
+	newUser := func(name, providerID string) types.UserInfo {
+		return types.UserInfo{
+			Name:             name,
+			Dir:              "/home/" + name,
+			Shell:            "/bin/bash",
+			BrokerID:         "broker-id",
+			ProviderID:       providerID,
+			Groups:           []types.GroupInfo{{Name: "group1", UGID: "ugid-group1"}},
+			SharedPrimaryGID: &sharedGID,
+		}
+	}
+
+	t.Run("New_user_gets_the_shared_primary_group", func(t *testing.T) {
+		t.Parallel()
+
+		m := newManagerForTests(t, t.TempDir())
+
+		err := m.UpdateUser(newUser("user1", "providerid-user1"))
+		require.NoError(t, err, "UpdateUser should not return an error, but did")
+
+		got, err := m.UserByName("user1")
+		require.NoError(t, err, "UserByName should not return an error, but did")
+		require.Equal(t, sharedGID, got.GID, "user should have the shared primary group")
+
+		_, err = m.GroupByName("user1")
+		require.Error(t, err, "no private group should have been created")
+	})
+
+	t.Run("Existing_user_keeps_their_private_group", func(t *testing.T) {
+		t.Parallel()
+
+		dbDir := t.TempDir()
+		err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "one_user_and_group.db.yaml"), dbDir)
+		require.NoError(t, err, "Setup: could not create database from testdata")
+
+		m := newManagerForTests(t, dbDir)
+
+		err = m.UpdateUser(newUser("user1@example.com", "providerid-user1"))
+		require.NoError(t, err, "UpdateUser should not return an error, but did")
+
+		got, err := m.UserByName("user1@example.com")
+		require.NoError(t, err, "UserByName should not return an error, but did")
+		require.NotEqual(t, sharedGID, got.GID, "existing user should not be moved to the shared primary group")
+
+		group, err := m.GroupByName("user1@example.com")
+		require.NoError(t, err, "existing user should keep their private group")
+		require.Equal(t, got.GID, group.GID, "private group should be the primary group of the user")
+	})
+}
+
+func TestUpdateUserDirectoryUID(t *testing.T) {
+	newUser := func(name, providerID string, uid uint32) types.UserInfo {
+		return types.UserInfo{
+			Name:         name,
+			Dir:          "/home/" + name,
+			Shell:        "/bin/bash",
+			BrokerID:     "broker-id",
+			ProviderID:   providerID,
+			Groups:       []types.GroupInfo{{Name: "group1", UGID: "ugid-group1"}},
+			DirectoryUID: &uid,
+		}
+	}
+
+	t.Run("New_user_gets_the_UID_of_the_directory", func(t *testing.T) {
+		t.Parallel()
+
+		m := newManagerForTests(t, t.TempDir())
+
+		err := m.UpdateUser(newUser("user1", "providerid-user1", 15000))
+		require.NoError(t, err, "UpdateUser should not return an error, but did")
+
+		got, err := m.UserByName("user1")
+		require.NoError(t, err, "UserByName should not return an error, but did")
+		require.Equal(t, uint32(15000), got.UID, "user should have the UID of the directory")
+		require.Equal(t, uint32(15000), got.GID, "user private group should have the UID of the directory")
+	})
+
+	t.Run("Existing_user_keeps_their_UID", func(t *testing.T) {
+		t.Parallel()
+
+		dbDir := t.TempDir()
+		err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "one_user_and_group.db.yaml"), dbDir)
+		require.NoError(t, err, "Setup: could not create database from testdata")
+
+		m := newManagerForTests(t, dbDir)
+
+		err = m.UpdateUser(newUser("user1@example.com", "providerid-user1", 15000))
+		require.NoError(t, err, "UpdateUser should not return an error, but did")
+
+		got, err := m.UserByName("user1@example.com")
+		require.NoError(t, err, "UserByName should not return an error, but did")
+		require.Equal(t, uint32(1111), got.UID, "existing user should keep their UID")
+	})
+
+	t.Run("Error_if_UID_of_the_directory_is_already_used", func(t *testing.T) {
+		t.Parallel()
+
+		dbDir := t.TempDir()
+		err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "one_user_and_group.db.yaml"), dbDir)
+		require.NoError(t, err, "Setup: could not create database from testdata")
+
+		m := newManagerForTests(t, dbDir)
+
+		err = m.UpdateUser(newUser("user2", "providerid-user2", 1111))
+		require.Error(t, err, "UpdateUser should return an error when the UID is used by another user, but did not")
+
+		_, err = m.UserByName("user2")
+		require.Error(t, err, "user should not have been created")
+	})
+}
+
+func TestUpdateUserLinkedLocalAccount(t *testing.T) {
+	// The tests are not parallel, as they change the passwd file read by the manager.
+
+	newUser := func(name, providerID string) types.UserInfo {
+		return types.UserInfo{
+			Name:       name,
+			Dir:        "/home/" + name,
+			Shell:      "/bin/bash",
+			BrokerID:   "broker-id",
+			ProviderID: providerID,
+			Groups:     []types.GroupInfo{{Name: "group1", UGID: "ugid-group1"}},
+		}
+	}
+
+	passwd := "root:x:0:0:root:/root:/bin/bash\n" +
+		"alice:x:1500:1600:Alice:/home/alice:/bin/bash\n" +
+		"bob:x:1111:1111:Bob:/home/bob:/bin/bash\n"
+
+	tests := map[string]struct {
+		name          string
+		localAccounts map[string]string
+		dbFile        string
+
+		wantUID uint32
+		wantGID uint32
+		wantDir string
+		wantErr bool
+	}{
+		"New_user_gets_the_UID_the_primary_group_and_the_home_of_the_local_user": {
+			name:          "alice@example.com",
+			localAccounts: map[string]string{"alice@example.com": "alice"},
+			wantUID:       1500,
+			wantGID:       1600,
+			wantDir:       "/home/alice",
+		},
+		"New_user_is_linked_with_the_normalized_name_of_the_configuration": {
+			name:          "alice@example.com",
+			localAccounts: map[string]string{"Alice@Example.com": "alice"},
+			wantUID:       1500,
+			wantGID:       1600,
+			wantDir:       "/home/alice",
+		},
+		"Existing_user_is_not_linked": {
+			name:          "user1@example.com",
+			localAccounts: map[string]string{"user1@example.com": "alice"},
+			dbFile:        "one_user_and_group",
+			wantUID:       1111,
+			wantGID:       1111,
+			wantDir:       "/home/user1@example.com",
+		},
+		"New_user_without_local_account_is_not_linked": {
+			name:          "carol@example.com",
+			localAccounts: map[string]string{"alice@example.com": "alice"},
+			wantUID:       1234,
+			wantGID:       1234,
+			wantDir:       "/home/carol@example.com",
+		},
+
+		"Error_if_the_local_user_does_not_exist": {
+			name:          "alice@example.com",
+			localAccounts: map[string]string{"alice@example.com": "doesnotexist"},
+			wantErr:       true,
+		},
+		"Error_if_the_local_user_is_root": {
+			name:          "alice@example.com",
+			localAccounts: map[string]string{"alice@example.com": "root"},
+			wantErr:       true,
+		},
+		"Error_if_the_UID_of_the_local_user_is_used_by_another_user": {
+			name:          "bob@example.com",
+			localAccounts: map[string]string{"bob@example.com": "bob"},
+			dbFile:        "one_user_and_group",
+			wantErr:       true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			passwdFile := filepath.Join(t.TempDir(), "passwd")
+			require.NoError(t, os.WriteFile(passwdFile, []byte(passwd), 0600), "Setup: could not write passwd file")
+			localentries.Z_ForTests_SetPasswdPath(passwdFile)
+			t.Cleanup(localentries.Z_ForTests_RestoreDefaultOptions)
+
+			dbDir := t.TempDir()
+			if tc.dbFile != "" {
+				err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", tc.dbFile+".db.yaml"), dbDir)
+				require.NoError(t, err, "Setup: could not create database from testdata")
+			}
+
+			config := users.DefaultConfig
+			config.LocalAccounts.LocalAccounts = tc.localAccounts
+			m, err := users.NewManager(config, dbDir, users.WithIDGenerator(&users.IDGeneratorMock{
+				UIDsToGenerate: []uint32{1234},
+				GIDsToGenerate: []uint32{4321},
+			}))
+			require.NoError(t, err, "Setup: NewManager should not return an error, but did")
+			t.Cleanup(func() { _ = m.Stop() })
+
+			err = m.UpdateUser(newUser(tc.name, "providerid-"+tc.name))
+			if tc.wantErr {
+				require.Error(t, err, "UpdateUser should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "UpdateUser should not return an error, but did")
+
+			got, err := m.UserByName(tc.name)
+			require.NoError(t, err, "UserByName should not return an error, but did")
+			require.Equal(t, tc.wantUID, got.UID, "UpdateUser should set the expected UID")
+			require.Equal(t, tc.wantGID, got.GID, "UpdateUser should set the expected primary group")
+			require.Equal(t, tc.wantDir, got.Dir, "UpdateUser should set the expected home directory")
+
+			// The user stays linked on their next logins.
+			err = m.UpdateUser(newUser(tc.name, "providerid-"+tc.name))
+			require.NoError(t, err, "UpdateUser should not return an error on the next login, but did")
+			got, err = m.UserByName(tc.name)
+			require.NoError(t, err, "UserByName should not return an error, but did")
+			require.Equal(t, tc.wantUID, got.UID, "The UID should not change on the next login")
+			require.Equal(t, tc.wantGID, got.GID, "The primary group should not change on the next login")
+			require.Equal(t, tc.wantDir, got.Dir, "The home directory should not change on the next login")
+		})
+	}
+}
+
+func TestNewManagerWithInvalidLocalAccounts(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]map[string]string{
+		"Error_if_the_name_of_the_user_is_empty":       {"": "alice"},
+		"Error_if_the_name_of_the_local_user_is_empty": {"alice@example.com": ""},
+		"Error_if_a_local_user_is_linked_twice":        {"alice@example.com": "alice", "alice@example.org": "alice"},
+	}
+	for name, localAccounts := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := users.DefaultConfig
+			config.LocalAccounts.LocalAccounts = localAccounts
+			_, err := users.NewManager(config, t.TempDir())
+			require.Error(t, err, "NewManager should return an error for invalid local accounts")
+		})
+	}
+}
+
+func TestUpdateUserUIDHint(t *testing.T) {
+	t.Parallel()
+
+	newUser := func(name string, uid uint32) types.UserInfo {
+		return types.UserInfo{
+			Name:       name,
+			Dir:        "/home/" + name,
+			Shell:      "/bin/bash",
+			BrokerID:   "broker-id",
+			ProviderID: "providerid-" + name,
+			Groups:     []types.GroupInfo{{Name: "group1", UGID: "ugid-group1"}},
+			UIDHint:    &uid,
+		}
+	}
+
+	tests := map[string]struct {
+		hint         uint32
+		existingHint uint32
+
+		wantHintUsed bool
+	}{
+		"New_user_gets_the_UID_suggested_by_the_broker": {hint: 15000, wantHintUsed: true},
+
+		"New_user_gets_a_generated_UID_if_the_suggested_one_is_not_in_the_range": {hint: 1111},
+		"New_user_gets_a_generated_UID_if_the_suggested_one_is_used":             {hint: 15000, existingHint: 15000},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := newManagerForTests(t, t.TempDir())
+			if tc.existingHint != 0 {
+				err := m.UpdateUser(newUser("user0", tc.existingHint))
+				require.NoError(t, err, "Setup: UpdateUser should not return an error, but did")
+			}
+
+			err := m.UpdateUser(newUser("user1", tc.hint))
+			require.NoError(t, err, "UpdateUser should not return an error, but did")
+
+			got, err := m.UserByName("user1")
+			require.NoError(t, err, "UserByName should not return an error, but did")
+			if tc.wantHintUsed {
+				require.Equal(t, tc.hint, got.UID, "user should have the UID suggested by the broker")
+				return
+			}
+			require.NotEqual(t, tc.hint, got.UID, "user should not have the UID suggested by the broker")
+		})
+	}
+}
+
+func TestOfflineLoginsSinceOnlineLogin(t *testing.T) {
+	t.Parallel()
+
+	online := types.LoginRecord{Result: auth.Granted}
+	offline := types.LoginRecord{Result: auth.Granted, Offline: true}
+	offlineDenied := types.LoginRecord{Result: auth.Denied, Offline: true}
+	machineToken := types.LoginRecord{Result: auth.Granted, MachineToken: true}
+
+	tests := map[string]struct {
+		logins []types.LoginRecord
+
+		want int
+	}{
+		"No_offline_logins_without_logins":          {},
+		"No_offline_logins_after_an_online_login":   {logins: []types.LoginRecord{offline, online}},
+		"Count_the_offline_logins_since_the_online": {logins: []types.LoginRecord{offline, online, offline, offlineDenied, offline}, want: 2},
+		"Count_all_offline_logins_without_online":   {logins: []types.LoginRecord{offline, offline, offline}, want: 3},
+		"Ignore_the_logins_with_a_machine_token":    {logins: []types.LoginRecord{offline, machineToken, offline, machineToken}, want: 2},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "one_user_and_group.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m := newManagerForTests(t, dbDir)
+
+			start := time.Now()
+			for i, r := range tc.logins {
+				r.Time = start.Add(time.Duration(i) * time.Second)
+				err := m.RecordLogin("user1@example.com", r)
+				require.NoError(t, err, "Setup: RecordLogin should not return an error, but did")
+			}
+
+			got, err := m.OfflineLoginsSinceOnlineLogin("user1@example.com")
+			require.NoError(t, err, "OfflineLoginsSinceOnlineLogin should not return an error, but did")
+			require.Equal(t, tc.want, got, "OfflineLoginsSinceOnlineLogin should return the number of offline logins")
+		})
+	}
+}
+
 func TestRegisterUserPreauth(t *testing.T) {
 	t.Parallel()
 
@@ -592,7 +1020,7 @@ func TestRegisterUserPreauth(t *testing.T) {
 			}
 			m := newManagerForTests(t, dbDir, managerOpts...)
 
-			uid, err := m.RegisterUserPreAuth(user.Name)
+			uid, err := m.RegisterUserPreAuth(user.Name, "")
 
 			requireErrorAssertions(t, err, nil, tc.wantErr)
 			if tc.wantErr {
@@ -702,7 +1130,7 @@ func TestConcurrentUserUpdate(t *testing.T) {
 					t.Parallel()
 
 					t.Logf("Registering pre-auth user %q", userName)
-					uid, err := m.RegisterUserPreAuth(userName)
+					uid, err := m.RegisterUserPreAuth(userName, "")
 					require.NoError(t, err, "RegisterPreAuthUser should not fail but it did")
 					preauthUID.Store(uid)
 					t.Logf("Registered pre-auth user %q with UID %d", userName, uid)
@@ -966,6 +1394,48 @@ func TestUpdateWhenNoMoreIDsAreAvailable(t *testing.T) {
 	}
 }
 
+func TestUpdateUserWithBrokerIDRanges(t *testing.T) {
+	t.Parallel()
+
+	config := users.DefaultConfig
+	config.BrokerIDRanges = map[string]users.IDRange{
+		"Broker": {UIDMin: 100000, UIDMax: 199999, GIDMin: 200000, GIDMax: 299999},
+		// Brokers which are not available are ignored.
+		"Unavailable broker": {UIDMin: 300000, UIDMax: 399999, GIDMin: 300000, GIDMax: 399999},
+	}
+	m, err := users.NewManager(config, t.TempDir(), users.WithBrokerIDs(map[string]string{"Broker": "broker-id"}))
+	require.NoError(t, err, "Setup: NewManager should not fail")
+
+	for _, tc := range []struct {
+		name, brokerID string
+		r              users.IDRange
+	}{
+		{"broker-user@example.com", "broker-id", config.BrokerIDRanges["Broker"]},
+		{"other-user@example.com", "other-broker-id",
+			users.IDRange{UIDMin: config.UIDMin, UIDMax: config.UIDMax, GIDMin: config.GIDMin, GIDMax: config.GIDMax}},
+	} {
+		err := m.UpdateUser(types.UserInfo{
+			Name:       tc.name,
+			BrokerID:   tc.brokerID,
+			ProviderID: "providerid-" + tc.name,
+			Dir:        "/home/" + tc.name,
+			Shell:      "/bin/bash",
+			Groups:     []types.GroupInfo{{Name: "group-" + tc.name, UGID: "ugid-" + tc.name}},
+		})
+		require.NoError(t, err, "UpdateUser should not fail for %q", tc.name)
+
+		u, err := m.UserByName(tc.name)
+		require.NoError(t, err, "UserByName should not fail for %q", tc.name)
+		require.GreaterOrEqual(t, u.UID, tc.r.UIDMin, "UID of %q should be in the range of its broker", tc.name)
+		require.LessOrEqual(t, u.UID, tc.r.UIDMax, "UID of %q should be in the range of its broker", tc.name)
+
+		g, err := m.GroupByName("group-" + tc.name)
+		require.NoError(t, err, "GroupByName should not fail for the group of %q", tc.name)
+		require.GreaterOrEqual(t, g.GID, tc.r.GIDMin, "GID of the group of %q should be in the range of its broker", tc.name)
+		require.LessOrEqual(t, g.GID, tc.r.GIDMax, "GID of the group of %q should be in the range of its broker", tc.name)
+	}
+}
+
 func TestBrokerForUser(t *testing.T) {
 	t.Parallel()
 
@@ -1003,6 +1473,40 @@ func TestBrokerForUser(t *testing.T) {
 	}
 }
 
+func TestUserGroups(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		username string
+
+		wantGroups  []string
+		wantErrType error
+	}{
+		"Successfully_get_groups_of_user": {username: "user1@example.com", wantGroups: []string{"group1", "commongroup"}},
+
+		"Error_if_user_does_not_exist": {username: "doesnotexist@example.com", wantErrType: db.NoDataFoundError{}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m := newManagerForTests(t, dbDir)
+
+			groups, err := m.UserGroups(tc.username)
+
+			requireErrorAssertions(t, err, tc.wantErrType, false)
+			if tc.wantErrType != nil {
+				return
+			}
+
+			require.ElementsMatch(t, tc.wantGroups, groups, "UserGroups should return the groups of the user")
+		})
+	}
+}
+
 func TestUpdateBrokerForUser(t *testing.T) {
 	t.Parallel()
 
@@ -1130,6 +1634,64 @@ func TestDeleteUser(t *testing.T) {
 	}
 }
 
+func TestDeleteUserArchivingHome(t *testing.T) {
+	tests := map[string]struct {
+		username string
+		noHome   bool
+		archived bool
+
+		wantArchive bool
+		wantErrType error
+	}{
+		"Successfully_delete_user_and_archive_home":            {wantArchive: true},
+		"Successfully_delete_archived_user_and_keep_archive":   {archived: true, wantArchive: true},
+		"Successfully_delete_user_without_home_and_no_archive": {noHome: true},
+
+		"Error_if_user_does_not_exist": {username: "doesnotexist@example.com", wantErrType: db.NoDataFoundError{}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_ = localgroupstestutils.SetupGroupMock(t, filepath.Join("testdata", "groups", "empty.group"))
+
+			if tc.username == "" {
+				tc.username = "user1@example.com"
+			}
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups_with_tmp_home.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m := newManagerForTests(t, dbDir)
+
+			t.Cleanup(func() { _ = os.RemoveAll("/tmp/authd-delete-user-test/") })
+			if !tc.noHome {
+				createTestHome(t, lifecycleTestHome)
+			}
+			if tc.archived {
+				require.NoError(t, m.DisableUser(context.Background(), tc.username), "Setup: could not disable user")
+				require.NoError(t, m.ArchiveUser(context.Background(), tc.username), "Setup: could not archive user")
+			}
+
+			archive, err := m.DeleteUserArchivingHome(context.Background(), tc.username)
+			if tc.wantErrType != nil {
+				require.ErrorIs(t, err, tc.wantErrType, "DeleteUserArchivingHome should return the expected error")
+				return
+			}
+			require.NoError(t, err, "DeleteUserArchivingHome should not return an error")
+
+			_, err = m.UserByName(tc.username)
+			require.ErrorIs(t, err, db.NoDataFoundError{}, "Deleted user should not be found")
+			require.NoDirExists(t, lifecycleTestHome, "Home directory should have been removed")
+
+			if !tc.wantArchive {
+				require.Empty(t, archive, "No archive should be returned for a user without home")
+				return
+			}
+			require.FileExists(t, archive, "Archive of the home directory should exist")
+			require.Contains(t, filepath.Base(archive), tc.username+".deleted-", "Archive should be named after the deleted user")
+		})
+	}
+}
+
 func TestDeleteGroup(t *testing.T) {
 	tests := map[string]struct {
 		groupname string
@@ -1331,7 +1893,7 @@ func TestUserByIDAndName(t *testing.T) {
 			m := newManagerForTests(t, dbDir)
 
 			if tc.isTempUser {
-				tc.uid, err = m.RegisterUserPreAuth("tempuser1@example.com")
+				tc.uid, err = m.RegisterUserPreAuth("tempuser1@example.com", "")
 				require.NoError(t, err, "RegisterUser should not return an error, but did")
 			}
 
@@ -1434,7 +1996,7 @@ func TestGroupByIDAndName(t *testing.T) {
 			}))
 
 			if tc.preAuthUser != "" {
-				tc.gid, err = m.RegisterUserPreAuth(tc.preAuthUser)
+				tc.gid, err = m.RegisterUserPreAuth(tc.preAuthUser, "")
 				require.NoError(t, err, "RegisterUserPreAuth should not fail for %q, but it did",
 					tc.preAuthUser)
 			}
@@ -1702,7 +2264,7 @@ func TestRegisterUserPreAuthWhenLocked(t *testing.T) {
 
 	m := newManagerForTests(t, dbDir)
 
-	uid, err := m.RegisterUserPreAuth("locked-user@example.com")
+	uid, err := m.RegisterUserPreAuth("locked-user@example.com", "")
 	require.ErrorIs(t, err, userslocking.ErrLock)
 	require.Zero(t, uid, "Uid should be unset")
 }
@@ -1731,7 +2293,7 @@ func TestRegisterUserPreAuthAfterUnlock(t *testing.T) {
 
 	m := newManagerForTests(t, dbDir)
 
-	uid, err := m.RegisterUserPreAuth("locked-user@example.com")
+	uid, err := m.RegisterUserPreAuth("locked-user@example.com", "")
 	require.NoError(t, err, "Registration should not fail")
 	require.NotZero(t, uid, "UID should be set")
 }
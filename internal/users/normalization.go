@@ -0,0 +1,136 @@
+package users
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/canonical/authd/internal/users/db"
+)
+
+// NormalizationConfig holds the rules which the names of the users are normalized with before they are looked up and
+// stored, so that the different spellings of a name map to the same account. The names are always lowercased.
+type NormalizationConfig struct {
+	// StripDomains are the domains removed from the end of the names of the users, for example "example.com" to name
+	// the user "alice@example.com" "alice".
+	StripDomains []string `mapstructure:"username_strip_domains" yaml:"username_strip_domains,omitempty"`
+	// TranslateInvalidChars replaces the characters which are not valid in POSIX user names with underscores.
+	TranslateInvalidChars bool `mapstructure:"username_translate_invalid_chars" yaml:"username_translate_invalid_chars"`
+	// Aliases maps the short names which the users can log in with to the names of their accounts at the provider.
+	Aliases map[string]string `mapstructure:"username_aliases" yaml:"username_aliases,omitempty"`
+}
+
+func (c NormalizationConfig) validate() error {
+	for _, d := range c.StripDomains {
+		d = strings.TrimPrefix(d, "@")
+		if d == "" || strings.ContainsAny(d, "@ ") {
+			return fmt.Errorf("invalid domain %q in username_strip_domains", d)
+		}
+	}
+	for alias, name := range c.Aliases {
+		if alias == "" || name == "" {
+			return fmt.Errorf("invalid alias %q of %q in username_aliases", alias, name)
+		}
+		if c.ResolveAlias(name) != strings.ToLower(name) {
+			return fmt.Errorf("alias %q refers to another alias %q in username_aliases", alias, name)
+		}
+	}
+	return nil
+}
+
+// ResolveAlias returns the name of the account at the provider which the name is an alias of, or the name itself
+// if it's not an alias, lowercased.
+func (c NormalizationConfig) ResolveAlias(name string) string {
+	// authd uses lowercase user names.
+	name = strings.ToLower(name)
+
+	for alias, n := range c.Aliases {
+		if strings.EqualFold(alias, name) {
+			return strings.ToLower(n)
+		}
+	}
+	return name
+}
+
+// Normalize returns the name of the user as looked up and stored by authd.
+func (c NormalizationConfig) Normalize(name string) string {
+	name = c.ResolveAlias(name)
+
+	for _, d := range c.StripDomains {
+		suffix := "@" + strings.ToLower(strings.TrimPrefix(d, "@"))
+		if n, ok := strings.CutSuffix(name, suffix); ok && n != "" {
+			name = n
+			break
+		}
+	}
+
+	if c.TranslateInvalidChars {
+		name = translateInvalidChars(name)
+	}
+
+	return name
+}
+
+// checkStoredNames returns an error if the names of some stored users are not normalized with the current
+// username_strip_domains and username_translate_invalid_chars settings, which happens when the settings were changed
+// after the users were stored. The users would not be found with their normalized names anymore, and a new user would
+// be created when they log in, so we refuse to start instead of silently splitting the accounts.
+func (c NormalizationConfig) checkStoredNames(users []db.UserRow) error {
+	if len(c.StripDomains) == 0 && !c.TranslateInvalidChars {
+		return nil
+	}
+
+	// The aliases only apply to the names the users log in with, not to the stored ones.
+	c.Aliases = nil
+
+	var affected []string
+	for _, u := range users {
+		if n := c.Normalize(u.Name); n != strings.ToLower(u.Name) {
+			affected = append(affected, fmt.Sprintf("%q (normalized to %q)", u.Name, n))
+		}
+	}
+	if len(affected) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("the names of the users %s are not normalized with the current username_strip_domains and "+
+		"username_translate_invalid_chars settings: revert the settings, or delete the users with 'authctl user delete' before "+
+		"changing them", strings.Join(affected, ", "))
+}
+
+// translateInvalidChars replaces the characters which are not in the POSIX portable filename character set with
+// underscores, as well as a leading hyphen.
+func translateInvalidChars(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '_':
+		case r == '-' && i > 0:
+		default:
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// NameCollisionError is returned when the name of a user is normalized to the name of another account of the
+// provider.
+type NameCollisionError struct {
+	Name string
+}
+
+// Error implements the error interface.
+func (e NameCollisionError) Error() string {
+	return fmt.Sprintf("user %q is already bound to another account of the provider, whose name is normalized to the same name", e.Name)
+}
+
+// NormalizeName returns the name of the user as looked up and stored by authd.
+func (m *Manager) NormalizeName(name string) string {
+	return m.config.Normalization.Normalize(name)
+}
+
+// ResolveAlias returns the name of the account at the provider which the name is an alias of, or the name itself
+// if it's not an alias, lowercased.
+func (m *Manager) ResolveAlias(name string) string {
+	return m.config.Normalization.ResolveAlias(name)
+}
@@ -0,0 +1,244 @@
+package users_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	localgroupstestutils "github.com/canonical/authd/internal/users/localentries/testutils"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]struct {
+		name                  string
+		stripDomains          []string
+		translateInvalidChars bool
+		aliases               map[string]string
+
+		want string
+	}{
+		"Lowercase_by_default":           {name: "Alice.Smith@Corp.COM", want: "alice.smith@corp.com"},
+		"Strip_domain":                   {name: "Alice.Smith@Corp.COM", stripDomains: []string{"corp.com"}, want: "alice.smith"},
+		"Strip_domain_with_leading_at":   {name: "alice@corp.com", stripDomains: []string{"@Corp.com"}, want: "alice"},
+		"Strip_only_the_first_domain":    {name: "alice@other.com@corp.com", stripDomains: []string{"corp.com", "other.com"}, want: "alice@other.com"},
+		"Translate_invalid_chars":        {name: "Alice Smith+test@corp.com", translateInvalidChars: true, want: "alice_smith_test_corp.com"},
+		"Translate_leading_hyphen":       {name: "-alice-smith", translateInvalidChars: true, want: "_alice-smith"},
+		"Translate_non_ascii_chars":      {name: "Zoë", translateInvalidChars: true, want: "zo_"},
+		"Strip_domain_then_translate":    {name: "Alice Smith@corp.com", stripDomains: []string{"corp.com"}, translateInvalidChars: true, want: "alice_smith"},
+		"Keep_names_of_other_domains":    {name: "alice@other.com", stripDomains: []string{"corp.com"}, want: "alice@other.com"},
+		"Keep_names_only_made_of_domain": {name: "@corp.com", stripDomains: []string{"corp.com"}, want: "@corp.com"},
+		"Keep_names_ending_like_domain":  {name: "alice@subcorp.com", stripDomains: []string{"corp.com"}, want: "alice@subcorp.com"},
+		"Resolve_alias":                  {name: "ASmith", aliases: map[string]string{"asmith": "Alice.Smith@Corp.com"}, want: "alice.smith@corp.com"},
+		"Resolve_alias_then_strip":       {name: "asmith", aliases: map[string]string{"asmith": "alice.smith@corp.com"}, stripDomains: []string{"corp.com"}, want: "alice.smith"},
+		"Keep_names_which_are_not_alias": {name: "bob", aliases: map[string]string{"asmith": "alice.smith@corp.com"}, want: "bob"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := users.NormalizationConfig{StripDomains: tc.stripDomains, TranslateInvalidChars: tc.translateInvalidChars, Aliases: tc.aliases}
+			require.Equal(t, tc.want, c.Normalize(tc.name), "Normalize did not return the expected name")
+		})
+	}
+}
+
+func TestNewManagerWithNormalization(t *testing.T) {
+	tests := map[string]struct {
+		stripDomains []string
+		aliases      map[string]string
+
+		wantErr bool
+	}{
+		"Successfully_create_with_domains": {stripDomains: []string{"corp.com", "@other.com"}},
+		"Successfully_create_with_aliases": {aliases: map[string]string{"asmith": "alice.smith@corp.com", "bob": "bob.jones@corp.com"}},
+
+		"Error_when_domain_is_empty":        {stripDomains: []string{"@"}, wantErr: true},
+		"Error_when_domain_has_an_at":       {stripDomains: []string{"alice@corp.com"}, wantErr: true},
+		"Error_when_domain_has_whitespaces": {stripDomains: []string{"corp .com"}, wantErr: true},
+		"Error_when_alias_is_empty":         {aliases: map[string]string{"": "alice.smith@corp.com"}, wantErr: true},
+		"Error_when_alias_target_is_empty":  {aliases: map[string]string{"asmith": ""}, wantErr: true},
+		"Error_when_alias_refers_to_alias":  {aliases: map[string]string{"asmith": "alice", "alice": "alice.smith@corp.com"}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := users.DefaultConfig
+			cfg.Normalization.StripDomains = tc.stripDomains
+			cfg.Normalization.Aliases = tc.aliases
+
+			m, err := users.NewManager(cfg, t.TempDir())
+			if tc.wantErr {
+				require.Error(t, err, "NewManager should return an error")
+				return
+			}
+			require.NoError(t, err, "NewManager should not return an error")
+			require.NoError(t, m.Stop(), "Teardown: Stop should not return an error")
+		})
+	}
+}
+
+func TestUpdateUserNameCollision(t *testing.T) {
+	// We don't care about the output of gpasswd in this test, but we still need to mock it.
+	_ = localgroupstestutils.SetupGroupMock(t, filepath.Join("testdata", "groups", "empty.group"))
+
+	cfg := users.DefaultConfig
+	cfg.Normalization.StripDomains = []string{"corp.com"}
+	m, err := users.NewManager(cfg, t.TempDir())
+	require.NoError(t, err, "Setup: could not create manager")
+
+	newUser := func(name, providerID string) types.UserInfo {
+		return types.UserInfo{
+			Name:       m.NormalizeName(name),
+			Dir:        "/home/alice",
+			Shell:      "/bin/bash",
+			BrokerID:   "broker-id",
+			ProviderID: providerID,
+		}
+	}
+
+	err = m.UpdateUser(newUser("Alice@Corp.COM", "providerid-alice"))
+	require.NoError(t, err, "UpdateUser should not return an error")
+	u, err := m.UserByName("alice")
+	require.NoError(t, err, "UserByName should find the user by their normalized name")
+
+	// Another spelling of the name of the same account maps to the same user.
+	err = m.UpdateUser(newUser("alice@corp.com", "providerid-alice"))
+	require.NoError(t, err, "UpdateUser should not return an error for the same account")
+
+	// Another account of the provider whose name is normalized to the same name is refused.
+	err = m.UpdateUser(newUser("alice", "providerid-other"))
+	require.ErrorAs(t, err, &users.NameCollisionError{}, "UpdateUser should return a collision error")
+
+	got, err := m.UserByName("alice")
+	require.NoError(t, err, "UserByName should still find the user")
+	require.Equal(t, u.UID, got.UID, "The user should not have been taken over")
+
+	_, providerID, err := m.BrokerAndProviderIDForUser("alice")
+	require.NoError(t, err, "BrokerAndProviderIDForUser should not return an error")
+	require.Equal(t, "providerid-alice", providerID, "The provider ID should not have changed")
+}
+
+func TestUpdateUserNameCollisionWithLegacyUser(t *testing.T) {
+	tests := map[string]struct {
+		storedBrokerID string
+		originalName   string
+
+		wantCollision bool
+	}{
+		"Legacy_user_is_bound_to_the_account_with_the_same_name":                {storedBrokerID: "broker-id", originalName: "Alice"},
+		"Legacy_user_without_broker_is_bound_to_the_account_with_the_same_name": {originalName: "alice"},
+
+		"Error_if_the_name_of_the_account_is_only_the_same_once_normalized": {storedBrokerID: "broker-id", originalName: "alice@corp.com", wantCollision: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// We don't care about the output of gpasswd in this test, but we still need to mock it.
+			_ = localgroupstestutils.SetupGroupMock(t, filepath.Join("testdata", "groups", "empty.group"))
+
+			// The user was stored before the provider IDs were.
+			dbYAML := filepath.Join(t.TempDir(), "legacy.db.yaml")
+			err := os.WriteFile(dbYAML, []byte(fmt.Sprintf(`users:
+    - name: alice
+      uid: 11111
+      gid: 11111
+      dir: /home/alice
+      shell: /bin/bash
+      broker_id: %q
+groups:
+    - name: alice
+      gid: 11111
+      ugid: alice
+users_to_groups:
+    - uid: 11111
+      gid: 11111
+`, tc.storedBrokerID)), 0600)
+			require.NoError(t, err, "Setup: could not write database YAML")
+			dbDir := t.TempDir()
+			require.NoError(t, db.Z_ForTests_CreateDBFromYAML(dbYAML, dbDir), "Setup: could not create database")
+
+			cfg := users.DefaultConfig
+			cfg.Normalization.StripDomains = []string{"corp.com"}
+			m, err := users.NewManager(cfg, dbDir)
+			require.NoError(t, err, "Setup: could not create manager")
+
+			err = m.UpdateUser(types.UserInfo{
+				Name:         m.NormalizeName(tc.originalName),
+				OriginalName: tc.originalName,
+				Dir:          "/home/alice",
+				Shell:        "/bin/bash",
+				BrokerID:     "broker-id",
+				ProviderID:   "providerid-alice",
+			})
+			_, providerID, idErr := m.BrokerAndProviderIDForUser("alice")
+			require.NoError(t, idErr, "BrokerAndProviderIDForUser should not return an error")
+
+			if tc.wantCollision {
+				require.ErrorAs(t, err, &users.NameCollisionError{}, "UpdateUser should return a collision error")
+				require.Empty(t, providerID, "The legacy user should not have been taken over")
+				return
+			}
+			require.NoError(t, err, "UpdateUser should not return an error")
+			require.Equal(t, "providerid-alice", providerID, "The provider ID should be stored for the legacy user")
+		})
+	}
+}
+
+func TestNewManagerWithStoredNamesNotNormalized(t *testing.T) {
+	tests := map[string]struct {
+		storedName            string
+		stripDomains          []string
+		translateInvalidChars bool
+		aliases               map[string]string
+
+		wantErr bool
+	}{
+		"Stored_names_are_normalized":                      {storedName: "alice", stripDomains: []string{"corp.com"}},
+		"Stored_names_with_other_domains_are_normalized":   {storedName: "alice@other.com", stripDomains: []string{"corp.com"}},
+		"Stored_names_are_not_checked_without_any_setting": {storedName: "alice@corp.com"},
+		"Aliases_do_not_apply_to_the_stored_names":         {storedName: "alice", aliases: map[string]string{"alice": "alice@other.com"}},
+
+		"Error_if_a_domain_of_the_stored_names_is_stripped": {storedName: "alice@corp.com", stripDomains: []string{"corp.com"}, wantErr: true},
+		"Error_if_invalid_chars_of_the_stored_names_are_translated": {
+			storedName: "alice@other.com", translateInvalidChars: true, wantErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dbYAML := filepath.Join(t.TempDir(), "users.db.yaml")
+			err := os.WriteFile(dbYAML, []byte(fmt.Sprintf(`users:
+    - name: %[1]s
+      uid: 11111
+      gid: 11111
+      dir: /home/alice
+      shell: /bin/bash
+      broker_id: broker-id
+      provider_id: providerid-alice
+groups:
+    - name: %[1]s
+      gid: 11111
+      ugid: %[1]s
+users_to_groups:
+    - uid: 11111
+      gid: 11111
+`, tc.storedName)), 0600)
+			require.NoError(t, err, "Setup: could not write database YAML")
+			dbDir := t.TempDir()
+			require.NoError(t, db.Z_ForTests_CreateDBFromYAML(dbYAML, dbDir), "Setup: could not create database")
+
+			cfg := users.DefaultConfig
+			cfg.Normalization.StripDomains = tc.stripDomains
+			cfg.Normalization.TranslateInvalidChars = tc.translateInvalidChars
+			cfg.Normalization.Aliases = tc.aliases
+			m, err := users.NewManager(cfg, dbDir)
+			if tc.wantErr {
+				require.Error(t, err, "NewManager should refuse to start")
+				require.ErrorContains(t, err, tc.storedName, "The error should name the affected user")
+				return
+			}
+			require.NoError(t, err, "NewManager should not return an error")
+			require.NoError(t, m.Stop(), "Teardown: could not stop the manager")
+		})
+	}
+}
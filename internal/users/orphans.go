@@ -0,0 +1,125 @@
+package users
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/canonical/authd/internal/users/localentries"
+)
+
+// OrphanedHome describes a home directory whose owner is neither a user of authd nor a local user.
+type OrphanedHome struct {
+	Path string
+	UID  uint32
+	// DeletedUser is the name of the deleted user of authd who owned the home directory, if it's known.
+	DeletedUser string
+	// DeletedAt is when DeletedUser was deleted.
+	DeletedAt time.Time
+}
+
+// HomePrefixes returns the directories containing the home directories of the current and of the deleted users.
+func (m *Manager) HomePrefixes() ([]string, error) {
+	users, err := m.db.AllUsers()
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := m.db.DeletedUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	var prefixes []string
+	addPrefix := func(home string) {
+		if !filepath.IsAbs(home) || filepath.Clean(home) == "/" {
+			return
+		}
+		if prefix := filepath.Dir(filepath.Clean(home)); !slices.Contains(prefixes, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	for _, u := range users {
+		addPrefix(u.Dir)
+	}
+	for _, u := range deleted {
+		addPrefix(u.Dir)
+	}
+	slices.Sort(prefixes)
+	return prefixes, nil
+}
+
+// OrphanedHomes returns the directories in the given prefixes which are not the home directory of a user of authd
+// and whose owner is neither a user of authd nor a local user. Prefixes which don't exist are ignored.
+func (m *Manager) OrphanedHomes(prefixes []string) (orphans []OrphanedHome, err error) {
+	users, err := m.db.AllUsers()
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := m.db.DeletedUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	lockedEntries, unlockEntries, err := localentries.WithUserDBLock()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errors.Join(err, unlockEntries()) }()
+	localUsers, err := lockedEntries.GetLocalUserEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	homes := make(map[string]bool, len(users))
+	owners := make(map[uint32]bool, len(users)+len(localUsers))
+	for _, u := range users {
+		homes[filepath.Clean(u.Dir)] = true
+		owners[u.UID] = true
+	}
+	for _, u := range localUsers {
+		owners[u.UID] = true
+	}
+	deletedByHome := make(map[string]OrphanedHome, len(deleted))
+	for _, u := range deleted {
+		// The deleted users are ordered by deletion time, so the last one to use the home wins.
+		deletedByHome[filepath.Clean(u.Dir)] = OrphanedHome{UID: u.UID, DeletedUser: u.Name, DeletedAt: time.Unix(u.DeletedAt, 0)}
+	}
+
+	var errs []error
+	for _, prefix := range prefixes {
+		entries, err := os.ReadDir(prefix)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, e := range entries {
+			path := filepath.Join(prefix, e.Name())
+			if !e.IsDir() || homes[path] {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			stat, ok := info.Sys().(*syscall.Stat_t)
+			if !ok || owners[stat.Uid] {
+				continue
+			}
+
+			o := OrphanedHome{Path: path, UID: stat.Uid}
+			if d, ok := deletedByHome[path]; ok && d.UID == stat.Uid {
+				o.DeletedUser, o.DeletedAt = d.DeletedUser, d.DeletedAt
+			}
+			orphans = append(orphans, o)
+		}
+	}
+
+	return orphans, errors.Join(errs...)
+}
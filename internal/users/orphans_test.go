@@ -0,0 +1,96 @@
+package users_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/canonical/authd/internal/users/localentries"
+	userstestutils "github.com/canonical/authd/internal/users/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrphanedHomes(t *testing.T) {
+	uid := uint32(os.Getuid())
+
+	tests := map[string]struct {
+		localUser       bool
+		deletedUser     bool
+		deletedOtherUID bool
+
+		wantOrphans     []string
+		wantDeletedUser bool
+	}{
+		"Report_directories_of_unknown_owners":     {wantOrphans: []string{"deleted", "unknown"}},
+		"Attribute_home_to_deleted_user_owning_it": {deletedUser: true, wantOrphans: []string{"deleted", "unknown"}, wantDeletedUser: true},
+		"Do_not_attribute_home_to_deleted_user_with_another_UID": {
+			deletedUser: true, deletedOtherUID: true, wantOrphans: []string{"deleted", "unknown"},
+		},
+		"Do_not_report_directories_of_local_users": {localUser: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var passwd string
+			if tc.localUser {
+				passwd = fmt.Sprintf("local:x:%d:%d::/home/local:/bin/sh\n", uid, uid)
+			}
+			passwdFile := filepath.Join(t.TempDir(), "passwd")
+			require.NoError(t, os.WriteFile(passwdFile, []byte(passwd), 0600), "Setup: could not write passwd file")
+			localentries.Z_ForTests_SetPasswdPath(passwdFile)
+			t.Cleanup(localentries.Z_ForTests_RestoreDefaultOptions)
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups_with_tmp_home.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m := newManagerForTests(t, dbDir)
+
+			prefix := t.TempDir()
+			for _, dir := range []string{"unknown", "deleted"} {
+				require.NoError(t, os.Mkdir(filepath.Join(prefix, dir), 0700), "Setup: could not create directory")
+			}
+			require.NoError(t, os.WriteFile(filepath.Join(prefix, "file"), nil, 0600), "Setup: could not create file")
+
+			deletedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+			if tc.deletedUser {
+				owner := uid
+				if tc.deletedOtherUID {
+					owner = uid + 1
+				}
+				r := db.DeletedUserRow{Name: "deleted@example.com", UID: owner, Dir: filepath.Join(prefix, "deleted"), DeletedAt: deletedAt.Unix()}
+				require.NoError(t, userstestutils.DBManager(m).AddDeletedUser(r), "Setup: could not record deleted user")
+			}
+
+			prefixes, err := m.HomePrefixes()
+			require.NoError(t, err, "HomePrefixes should not return an error")
+			wantPrefixes := []string{"/tmp/authd-delete-user-test/home"}
+			if tc.deletedUser {
+				wantPrefixes = append(wantPrefixes, prefix)
+			}
+			require.ElementsMatch(t, wantPrefixes, prefixes, "HomePrefixes should return the parents of the homes of the current and deleted users")
+
+			orphans, err := m.OrphanedHomes([]string{prefix, filepath.Join(prefix, "doesnotexist")})
+			require.NoError(t, err, "OrphanedHomes should not return an error")
+
+			var got []string
+			for _, o := range orphans {
+				require.Equal(t, uid, o.UID, "Orphaned home should be reported with its owner")
+				got = append(got, filepath.Base(o.Path))
+				if o.Path != filepath.Join(prefix, "deleted") || !tc.wantDeletedUser {
+					require.Empty(t, o.DeletedUser, "Orphaned home should not be attributed to a deleted user")
+					continue
+				}
+				require.Equal(t, users.OrphanedHome{
+					Path:        o.Path,
+					UID:         uid,
+					DeletedUser: "deleted@example.com",
+					DeletedAt:   deletedAt,
+				}, o, "Orphaned home should be attributed to the deleted user")
+			}
+			require.ElementsMatch(t, tc.wantOrphans, got, "OrphanedHomes should return the directories of unknown owners")
+		})
+	}
+}
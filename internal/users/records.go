@@ -0,0 +1,354 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/canonical/authd/internal/users/localentries"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/canonical/authd/log"
+)
+
+// UserRecord is a user as exported from the database, with the names of the groups they're a member of.
+type UserRecord struct {
+	Name       string
+	UID        uint32
+	GID        uint32
+	Gecos      string
+	Dir        string
+	Shell      string
+	BrokerID   string
+	ProviderID string
+	Locked     bool
+	// Groups are the names of the authd groups of the user, which must be in the exported groups.
+	Groups []string
+	// LocalGroups are the names of the groups of the user in /etc/group.
+	LocalGroups []string
+}
+
+// GroupRecord is a group as exported from the database.
+type GroupRecord struct {
+	Name string
+	GID  uint32
+	UGID string
+}
+
+// Records are the users and groups exported from the database, e.g. to provision other machines with them.
+type Records struct {
+	Users  []UserRecord
+	Groups []GroupRecord
+}
+
+// ImportError is returned when records can't be imported, e.g. because one of their IDs is already used.
+type ImportError struct {
+	Reason string
+}
+
+// Error implements the error interface for ImportError.
+func (e ImportError) Error() string {
+	return fmt.Sprintf("cannot import the records: %s", e.Reason)
+}
+
+// Is makes this error insensitive to the actual reason.
+func (ImportError) Is(target error) bool { return target == ImportError{} }
+
+// ExportRecords returns all the users and groups of the database.
+func (m *Manager) ExportRecords() (Records, error) {
+	userRows, groupRows, err := m.db.ExportEntries()
+	if err != nil {
+		return Records{}, err
+	}
+
+	var r Records
+	for _, e := range userRows {
+		u := UserRecord{
+			Name:        e.User.Name,
+			UID:         e.User.UID,
+			GID:         e.User.GID,
+			Gecos:       e.User.Gecos,
+			Dir:         e.User.Dir,
+			Shell:       e.User.Shell,
+			BrokerID:    e.User.BrokerID,
+			ProviderID:  e.User.ProviderID,
+			Locked:      e.User.Locked,
+			LocalGroups: e.LocalGroups,
+		}
+		for _, g := range e.Groups {
+			u.Groups = append(u.Groups, g.Name)
+		}
+		r.Users = append(r.Users, u)
+	}
+	for _, g := range groupRows {
+		r.Groups = append(r.Groups, GroupRecord{Name: g.Name, GID: g.GID, UGID: g.UGID})
+	}
+
+	return r, nil
+}
+
+// ImportRecords adds the users and groups to the database, or updates them if they're already in it with the same
+// IDs. It fails without importing any of them if one of their names or IDs is used by another user or group, on this
+// machine or in the records themselves, if the IDs of the new ones are not in the ranges authd allocates from, or if
+// one of their fields can't be written to the user database of the system. Existing users keep their home directory
+// and shell.
+func (m *Manager) ImportRecords(r Records) (err error) {
+	m.userManagementMu.Lock()
+	defer m.userManagementMu.Unlock()
+
+	lockedEntries, unlockEntries, err := localentries.WithUserDBLock()
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, unlockEntries()) }()
+
+	groups := make(map[string]db.GroupRow)
+	var groupRows []db.GroupRow
+	for _, g := range r.Groups {
+		if err := m.checkImportedGroup(lockedEntries, g, groupRows); err != nil {
+			return err
+		}
+		row := db.NewGroupRow(g.Name, g.GID, g.UGID)
+		groups[g.Name] = row
+		groupRows = append(groupRows, row)
+	}
+
+	localGroups, err := lockedEntries.GetLocalGroupEntries()
+	if err != nil {
+		return err
+	}
+
+	var userRows []db.UserEntryRows
+	oldLocalGroups := make(map[string][]string)
+	for _, u := range r.Users {
+		if err := m.checkImportedUser(lockedEntries, u, userRows); err != nil {
+			return err
+		}
+		if err := checkImportedPrimaryGroup(u, groupRows, localGroups); err != nil {
+			return err
+		}
+		for _, name := range u.LocalGroups {
+			if !slices.ContainsFunc(localGroups, func(g types.GroupEntry) bool { return g.Name == name }) {
+				return ImportError{Reason: fmt.Sprintf("local group %q of user %q does not exist on this system", name, u.Name)}
+			}
+		}
+
+		e := db.UserEntryRows{
+			User:        db.NewUserRow(u.Name, u.UID, u.GID, u.Gecos, u.Dir, u.Shell, u.BrokerID, u.ProviderID),
+			LocalGroups: u.LocalGroups,
+		}
+		e.User.Locked = u.Locked
+		for _, name := range u.Groups {
+			g, ok := groups[name]
+			if !ok {
+				return ImportError{Reason: fmt.Sprintf("group %q of user %q is not in the records", name, u.Name)}
+			}
+			e.Groups = append(e.Groups, g)
+		}
+		userRows = append(userRows, e)
+
+		localGroups, err := m.db.UserLocalGroups(u.UID)
+		if err != nil {
+			return err
+		}
+		oldLocalGroups[u.Name] = localGroups
+	}
+
+	if err := m.db.ImportEntries(userRows, groupRows); err != nil {
+		return err
+	}
+	log.Noticef(context.Background(), "Imported %d users and %d groups", len(userRows), len(groupRows))
+
+	for _, e := range userRows {
+		if err := localentries.UpdateGroups(lockedEntries, e.User.Name, e.LocalGroups, oldLocalGroups[e.User.Name]); err != nil {
+			return err
+		}
+		m.notifyUserChanged(Updated, e.User.Name, e.User.UID)
+	}
+
+	return nil
+}
+
+// checkImportedUser checks that the fields of the user are valid and that their name and UID are not used by another
+// user, in the database, on the system or in the users imported before. The UID of a new user must be in the range of
+// their broker, if it has one, or in the range of UID_MIN and UID_MAX.
+func (m *Manager) checkImportedUser(lockedEntries *localentries.UserDBLocked, u UserRecord, imported []db.UserEntryRows) error {
+	if u.Name == "" {
+		return ImportError{Reason: fmt.Sprintf("the user with UID %d has no name", u.UID)}
+	}
+	if err := m.checkImportedName(u.Name); err != nil {
+		return ImportError{Reason: fmt.Sprintf("invalid name of user %q: %v", u.Name, err)}
+	}
+	if u.Gecos != "" {
+		if err := checkValidPasswdField(u.Gecos); err != nil {
+			return ImportError{Reason: fmt.Sprintf("invalid gecos of user %q: %v", u.Name, err)}
+		}
+	}
+	if err := checkValidPasswdPath(u.Dir); err != nil {
+		return ImportError{Reason: fmt.Sprintf("invalid home directory of user %q: %v", u.Name, err)}
+	}
+	if err := checkValidPasswdPath(u.Shell); err != nil {
+		return ImportError{Reason: fmt.Sprintf("invalid shell of user %q: %v", u.Name, err)}
+	}
+	if err := checkValidShell(u.Shell); err != nil {
+		// The shell may only be installed later on this machine, as with SetShell.
+		log.Warningf(context.Background(), "Importing user %q: %v", u.Name, err)
+	}
+	if slices.ContainsFunc(imported, func(e db.UserEntryRows) bool { return e.User.Name == u.Name }) {
+		return ImportError{Reason: fmt.Sprintf("user %q is in the records more than once", u.Name)}
+	}
+	if i := slices.IndexFunc(imported, func(e db.UserEntryRows) bool { return e.User.UID == u.UID }); i >= 0 {
+		return ImportError{Reason: fmt.Sprintf("users %q and %q have the same UID %d", imported[i].User.Name, u.Name, u.UID)}
+	}
+
+	existing, err := m.db.UserByName(u.Name)
+	if err != nil && !errors.Is(err, db.NoDataFoundError{}) {
+		return err
+	}
+	if err == nil && existing.UID != u.UID {
+		return ImportError{Reason: fmt.Sprintf("user %q already exists with UID %d instead of %d", u.Name, existing.UID, u.UID)}
+	}
+	if err == nil {
+		// The user is already in the database with the same UID.
+		return nil
+	}
+
+	uidMin, uidMax := m.config.UIDMin, m.config.UIDMax
+	if r, ok := m.brokerIDRanges[u.BrokerID]; ok {
+		uidMin, uidMax = r.UIDMin, r.UIDMax
+	}
+	if err := checkImportedID(u.UID, uidMin, uidMax); err != nil {
+		return ImportError{Reason: fmt.Sprintf("UID %d of user %q %v", u.UID, u.Name, err)}
+	}
+
+	existing, err = m.db.UserByID(u.UID)
+	if err != nil && !errors.Is(err, db.NoDataFoundError{}) {
+		return err
+	}
+	if err == nil {
+		return ImportError{Reason: fmt.Sprintf("UID %d of user %q is already used by user %q", u.UID, u.Name, existing.Name)}
+	}
+
+	unique, err := lockedEntries.IsUniqueUID(u.UID)
+	if err != nil {
+		return err
+	}
+	if !unique {
+		return ImportError{Reason: fmt.Sprintf("UID %d of user %q is already used on this system", u.UID, u.Name)}
+	}
+
+	return nil
+}
+
+// checkImportedGroup checks that the name and the GID of the group are valid and not used by another group, in the
+// database, on the system or in the groups imported before. The GID of a new group must be in one of the ranges authd
+// allocates the IDs of its users and groups from, as the private groups of the users have the ID of their user.
+func (m *Manager) checkImportedGroup(lockedEntries *localentries.UserDBLocked, g GroupRecord, imported []db.GroupRow) error {
+	if g.Name == "" {
+		return ImportError{Reason: fmt.Sprintf("the group with GID %d has no name", g.GID)}
+	}
+	if err := m.checkImportedName(g.Name); err != nil {
+		return ImportError{Reason: fmt.Sprintf("invalid name of group %q: %v", g.Name, err)}
+	}
+	if slices.ContainsFunc(imported, func(r db.GroupRow) bool { return r.Name == g.Name }) {
+		return ImportError{Reason: fmt.Sprintf("group %q is in the records more than once", g.Name)}
+	}
+	if i := slices.IndexFunc(imported, func(r db.GroupRow) bool { return r.GID == g.GID }); i >= 0 {
+		return ImportError{Reason: fmt.Sprintf("groups %q and %q have the same GID %d", imported[i].Name, g.Name, g.GID)}
+	}
+
+	existing, err := m.db.GroupByName(g.Name)
+	if err != nil && !errors.Is(err, db.NoDataFoundError{}) {
+		return err
+	}
+	if err == nil && existing.GID != g.GID {
+		return ImportError{Reason: fmt.Sprintf("group %q already exists with GID %d instead of %d", g.Name, existing.GID, g.GID)}
+	}
+	if err == nil {
+		// The group is already in the database with the same GID.
+		return nil
+	}
+
+	if !m.isManagedGID(g.GID) {
+		return ImportError{Reason: fmt.Sprintf("GID %d of group %q is not in the ranges of UID_MIN, UID_MAX, GID_MIN, GID_MAX or broker_id_ranges", g.GID, g.Name)}
+	}
+	if err := checkImportedID(g.GID, 0, math.MaxInt32); err != nil {
+		return ImportError{Reason: fmt.Sprintf("GID %d of group %q %v", g.GID, g.Name, err)}
+	}
+
+	existing, err = m.db.GroupByID(g.GID)
+	if err != nil && !errors.Is(err, db.NoDataFoundError{}) {
+		return err
+	}
+	if err == nil {
+		return ImportError{Reason: fmt.Sprintf("GID %d of group %q is already used by group %q", g.GID, g.Name, existing.Name)}
+	}
+
+	unique, err := lockedEntries.IsUniqueGID(g.GID)
+	if err != nil {
+		return err
+	}
+	if !unique {
+		return ImportError{Reason: fmt.Sprintf("GID %d of group %q is already used on this system", g.GID, g.Name)}
+	}
+
+	return nil
+}
+
+// checkImportedName checks that the name of an imported user or group can be written to the user database of the
+// system and is the one authd would have stored for it.
+func (m *Manager) checkImportedName(name string) error {
+	if err := checkValidPasswdField(name); err != nil {
+		return err
+	}
+	// The members of the groups are separated by commas in /etc/group.
+	if strings.ContainsAny(name, ", ") {
+		return errors.New("value cannot contain ',' or spaces")
+	}
+	if strings.HasPrefix(name, "-") {
+		return errors.New("value cannot start with '-'")
+	}
+	if normalized := m.NormalizeName(name); normalized != name {
+		return fmt.Errorf("value is not normalized, expected %q", normalized)
+	}
+	return nil
+}
+
+// checkImportedID checks that the ID is in [idMin, idMax] and that it's not an ID reserved to the system.
+func checkImportedID(id, idMin, idMax uint32) error {
+	if id == 0 || id > math.MaxInt32 {
+		return errors.New("is not a valid ID")
+	}
+	if rangesOverlap(id, id, systemdDynamicUIDMin, systemdDynamicUIDMax) {
+		return fmt.Errorf("is in the systemd dynamic service users range (%d-%d)", systemdDynamicUIDMin, systemdDynamicUIDMax)
+	}
+	if id < idMin || id > idMax {
+		return fmt.Errorf("is not in the range %d-%d", idMin, idMax)
+	}
+	return nil
+}
+
+// isManagedGID returns true if the GID is in one of the ranges authd allocates the IDs of its users and groups from.
+func (m *Manager) isManagedGID(gid uint32) bool {
+	ranges := []IDRange{{UIDMin: m.config.UIDMin, UIDMax: m.config.UIDMax, GIDMin: m.config.GIDMin, GIDMax: m.config.GIDMax}}
+	for _, r := range m.brokerIDRanges {
+		ranges = append(ranges, r)
+	}
+	return slices.ContainsFunc(ranges, func(r IDRange) bool {
+		return r.overlaps(IDRange{UIDMin: gid, UIDMax: gid, GIDMin: gid, GIDMax: gid})
+	})
+}
+
+// checkImportedPrimaryGroup checks that the primary group of the user is their private group, one of the imported
+// groups or a group of the system.
+func checkImportedPrimaryGroup(u UserRecord, imported []db.GroupRow, localGroups []types.GroupEntry) error {
+	if u.GID == u.UID ||
+		slices.ContainsFunc(imported, func(g db.GroupRow) bool { return g.GID == u.GID }) ||
+		slices.ContainsFunc(localGroups, func(g types.GroupEntry) bool { return g.GID == u.GID }) {
+		return nil
+	}
+	return ImportError{Reason: fmt.Sprintf("primary group %d of user %q is not in the records or on this system", u.GID, u.Name)}
+}
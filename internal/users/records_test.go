@@ -0,0 +1,172 @@
+package users_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportAndImportRecords(t *testing.T) {
+	t.Parallel()
+
+	dbDir := t.TempDir()
+	err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "one_user_and_group_with_providerid.db.yaml"), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+	m := newManagerForTests(t, dbDir)
+	require.NoError(t, m.LockUser("user1@example.com"), "Setup: could not lock user")
+
+	records, err := m.ExportRecords()
+	require.NoError(t, err, "ExportRecords should not return an error")
+	require.Equal(t, users.Records{
+		Users: []users.UserRecord{{
+			Name:       "user1@example.com",
+			UID:        1111,
+			GID:        11111,
+			Gecos:      "User1",
+			Dir:        "/home/user1@example.com",
+			Shell:      "/bin/bash",
+			BrokerID:   "broker-id",
+			ProviderID: "providerid-user1",
+			Locked:     true,
+			Groups:     []string{"group1@example.com"},
+		}},
+		Groups: []users.GroupRecord{{Name: "group1@example.com", GID: 11111, UGID: "12345678"}},
+	}, records, "ExportRecords should return the users and groups of the database")
+
+	// Importing the records in the same database doesn't change it.
+	require.NoError(t, m.ImportRecords(records), "ImportRecords should not fail for the records of the same database")
+
+	other := newManagerForImportTests(t, t.TempDir())
+	require.NoError(t, other.ImportRecords(records), "ImportRecords should not return an error")
+	got, err := other.ExportRecords()
+	require.NoError(t, err, "ExportRecords should not return an error")
+	require.Equal(t, records, got, "The imported records should be the exported ones")
+}
+
+func TestImportRecordsErrors(t *testing.T) {
+	t.Parallel()
+
+	user := func(name string, uid uint32, groups ...string) users.UserRecord {
+		return users.UserRecord{Name: name, UID: uid, GID: uid, Dir: "/home/" + name, Shell: "/bin/bash",
+			BrokerID: "broker-id", Groups: groups}
+	}
+	group := func(name string, gid uint32) users.GroupRecord {
+		return users.GroupRecord{Name: name, GID: gid, UGID: name}
+	}
+	withUser := func(u users.UserRecord, change func(*users.UserRecord)) users.UserRecord {
+		change(&u)
+		return u
+	}
+
+	tests := map[string]struct {
+		records users.Records
+
+		wantReason string
+	}{
+		"Error_if_two_users_have_the_same_UID": {records: users.Records{
+			Users: []users.UserRecord{user("user2", 2222), user("user3", 2222)},
+		}, wantReason: "have the same UID"},
+		"Error_if_two_groups_have_the_same_GID": {records: users.Records{
+			Groups: []users.GroupRecord{group("group2", 22222), group("group3", 22222)},
+		}, wantReason: "have the same GID"},
+		"Error_if_the_UID_is_used_by_another_user": {records: users.Records{
+			Users: []users.UserRecord{user("user2", 1111)},
+		}, wantReason: "already used by user"},
+		"Error_if_the_user_exists_with_another_UID": {records: users.Records{
+			Users: []users.UserRecord{user("user1@example.com", 2222)},
+		}, wantReason: "already exists with UID"},
+		"Error_if_the_GID_is_used_by_another_group": {records: users.Records{
+			Groups: []users.GroupRecord{group("group2", 11111)},
+		}, wantReason: "already used by group"},
+		"Error_if_a_group_of_a_user_is_not_in_the_records": {records: users.Records{
+			Users: []users.UserRecord{user("user2", 2222, "group2")},
+		}, wantReason: "is not in the records"},
+
+		"Error_if_the_UID_is_not_in_the_range": {records: users.Records{
+			Users: []users.UserRecord{user("user2", 70000)},
+		}, wantReason: "is not in the range"},
+		"Error_if_the_UID_is_not_in_the_range_of_the_broker": {records: users.Records{
+			Users: []users.UserRecord{withUser(user("user2", 2222), func(u *users.UserRecord) { u.BrokerID = "other-broker-id" })},
+		}, wantReason: "is not in the range 100000-200000"},
+		"Error_if_the_UID_is_0": {records: users.Records{
+			Users: []users.UserRecord{withUser(user("user2", 0), func(u *users.UserRecord) { u.GID = 2222 })},
+		}, wantReason: "is not a valid ID"},
+		"Error_if_the_GID_is_not_in_the_ranges": {records: users.Records{
+			Groups: []users.GroupRecord{group("group2", 70000)},
+		}, wantReason: "is not in the ranges"},
+		"Error_if_the_GID_is_in_the_range_of_the_system": {records: users.Records{
+			Groups: []users.GroupRecord{group("group2", 27)},
+		}, wantReason: "is not in the ranges"},
+		"Error_if_the_primary_group_does_not_exist": {records: users.Records{
+			Users: []users.UserRecord{withUser(user("user2", 2222), func(u *users.UserRecord) { u.GID = 33333 })},
+		}, wantReason: "primary group 33333"},
+
+		"Error_if_the_user_name_contains_a_colon": {records: users.Records{
+			Users: []users.UserRecord{user("user2:x", 2222)},
+		}, wantReason: "invalid name of user"},
+		"Error_if_the_user_name_contains_a_comma": {records: users.Records{
+			Users: []users.UserRecord{user("user2,root", 2222)},
+		}, wantReason: "invalid name of user"},
+		"Error_if_the_user_name_is_not_normalized": {records: users.Records{
+			Users: []users.UserRecord{user("User2", 2222)},
+		}, wantReason: "is not normalized"},
+		"Error_if_the_group_name_contains_a_newline": {records: users.Records{
+			Groups: []users.GroupRecord{group("group2\nroot", 22222)},
+		}, wantReason: "invalid name of group"},
+		"Error_if_the_gecos_contains_a_colon": {records: users.Records{
+			Users: []users.UserRecord{withUser(user("user2", 2222), func(u *users.UserRecord) { u.Gecos = "User2:0:0" })},
+		}, wantReason: "invalid gecos"},
+		"Error_if_the_home_directory_is_relative": {records: users.Records{
+			Users: []users.UserRecord{withUser(user("user2", 2222), func(u *users.UserRecord) { u.Dir = "home/user2" })},
+		}, wantReason: "invalid home directory"},
+		"Error_if_the_home_directory_is_not_normalized": {records: users.Records{
+			Users: []users.UserRecord{withUser(user("user2", 2222), func(u *users.UserRecord) { u.Dir = "/home/../etc" })},
+		}, wantReason: "invalid home directory"},
+		"Error_if_the_shell_is_relative": {records: users.Records{
+			Users: []users.UserRecord{withUser(user("user2", 2222), func(u *users.UserRecord) { u.Shell = "bash" })},
+		}, wantReason: "invalid shell"},
+		"Error_if_a_local_group_does_not_exist": {records: users.Records{
+			Users: []users.UserRecord{withUser(user("user2", 2222), func(u *users.UserRecord) { u.LocalGroups = []string{"doesnotexist"} })},
+		}, wantReason: "does not exist on this system"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "one_user_and_group_with_providerid.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m := newManagerForImportTests(t, dbDir, users.WithBrokerIDs(map[string]string{"OtherBroker": "other-broker-id"}))
+
+			err = m.ImportRecords(tc.records)
+			require.ErrorIs(t, err, users.ImportError{}, "ImportRecords should return an ImportError")
+			require.ErrorContains(t, err, tc.wantReason, "ImportRecords should return the expected reason")
+
+			got, err := m.ExportRecords()
+			require.NoError(t, err, "ExportRecords should not return an error")
+			require.Len(t, got.Users, 1, "No user should be imported")
+			require.Len(t, got.Groups, 1, "No group should be imported")
+		})
+	}
+}
+
+// newManagerForImportTests returns a manager whose ID ranges contain the IDs of the users and groups of the test
+// databases, and a range for the broker "OtherBroker".
+func newManagerForImportTests(t *testing.T, dbDir string, opts ...users.Option) *users.Manager {
+	t.Helper()
+
+	cfg := users.DefaultConfig
+	cfg.UIDMin = 1000
+	cfg.GIDMin = 1000
+	cfg.BrokerIDRanges = map[string]users.IDRange{
+		"otherbroker": {UIDMin: 100000, UIDMax: 200000, GIDMin: 100000, GIDMax: 200000},
+	}
+	m, err := users.NewManager(cfg, dbDir, opts...)
+	require.NoError(t, err, "Setup: NewManager should not return an error")
+	t.Cleanup(func() { _ = m.Stop() })
+
+	return m
+}
@@ -0,0 +1,70 @@
+package users
+
+import (
+	"fmt"
+	"time"
+)
+
+// minRevalidationInterval is the shortest interval between two revalidations of the users, as the scheduled tasks of
+// authd run hourly.
+const minRevalidationInterval = time.Hour
+
+// RevalidationConfig holds the settings of the periodic check of the users with their broker.
+type RevalidationConfig struct {
+	// Interval is the duration between two checks of the accounts of the users with their broker. If 0, the users are
+	// only checked when they log in.
+	Interval time.Duration `mapstructure:"revalidation_interval" yaml:"revalidation_interval"`
+}
+
+// Scheduled returns true if the users are checked periodically with their broker.
+func (c RevalidationConfig) Scheduled() bool {
+	return c.Interval > 0
+}
+
+func (c RevalidationConfig) validate() error {
+	if c.Interval < 0 {
+		return fmt.Errorf("invalid revalidation_interval %s, must not be negative", c.Interval)
+	}
+	if c.Scheduled() && c.Interval < minRevalidationInterval {
+		return fmt.Errorf("invalid revalidation_interval %s, must be at least %s", c.Interval, minRevalidationInterval)
+	}
+	return nil
+}
+
+// UserToRevalidate is a user whose account is checked periodically with their broker.
+type UserToRevalidate struct {
+	Name       string
+	BrokerID   string
+	ProviderID string
+}
+
+// RevalidationPolicy returns the settings of the periodic check of the users with their broker.
+func (m *Manager) RevalidationPolicy() RevalidationConfig {
+	return m.config.Revalidation
+}
+
+// UsersToRevalidate returns the active users provided by a broker who are not locked, whose account must be checked
+// with their broker.
+func (m *Manager) UsersToRevalidate() ([]UserToRevalidate, error) {
+	users, err := m.db.AllUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	var toRevalidate []UserToRevalidate
+	for _, u := range users {
+		if u.Locked || u.BrokerID == "" {
+			continue
+		}
+		// The users being deprovisioned can't log in anymore.
+		lc, err := m.userLifecycle(u)
+		if err != nil {
+			return nil, err
+		}
+		if lc.State != LifecycleActive {
+			continue
+		}
+		toRevalidate = append(toRevalidate, UserToRevalidate{Name: u.Name, BrokerID: u.BrokerID, ProviderID: u.ProviderID})
+	}
+	return toRevalidate, nil
+}
@@ -0,0 +1,57 @@
+package users_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	localgroupstestutils "github.com/canonical/authd/internal/users/localentries/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsersToRevalidate(t *testing.T) {
+	// We don't care about the output of gpasswd in this test, but we still need to mock it.
+	_ = localgroupstestutils.SetupGroupMock(t, filepath.Join("testdata", "groups", "empty.group"))
+
+	dbDir := t.TempDir()
+	err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups_with_tmp_home.db.yaml"), dbDir)
+	require.NoError(t, err, "Setup: could not create database from testdata")
+	m := newManagerForTests(t, dbDir)
+
+	require.NoError(t, m.LockUser("user2@example.com"), "Setup: could not lock user")
+	require.NoError(t, m.DisableUser(context.Background(), "user3@example.com"), "Setup: could not disable user")
+
+	got, err := m.UsersToRevalidate()
+	require.NoError(t, err, "UsersToRevalidate should not return an error")
+	require.Equal(t, []users.UserToRevalidate{{Name: "user1@example.com", BrokerID: "broker-id"}}, got,
+		"UsersToRevalidate should only return the active users provided by a broker who are not locked")
+}
+
+func TestRevalidationConfigValidation(t *testing.T) {
+	tests := map[string]struct {
+		interval time.Duration
+
+		wantErr bool
+	}{
+		"Disabled":              {},
+		"Revalidate_daily":      {interval: 24 * time.Hour},
+		"Revalidate_hourly":     {interval: time.Hour},
+		"Error_on_negative":     {interval: -time.Hour, wantErr: true},
+		"Error_on_too_frequent": {interval: time.Minute, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := users.DefaultConfig
+			cfg.Revalidation.Interval = tc.interval
+			_, err := users.NewManager(cfg, t.TempDir())
+			if tc.wantErr {
+				require.Error(t, err, "NewManager should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "NewManager should not return an error, but did")
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package users
+
+import (
+	"errors"
+	"net"
+)
+
+// SCIMConfig holds the settings of the SCIM 2.0 server, which the identity provider pushes the creation, the update
+// and the deactivation of the users to, instead of authd only creating them when they first log in.
+type SCIMConfig struct {
+	// Listen is the TCP address on which the SCIM server is served over HTTPS. If empty, there is no SCIM server.
+	Listen string `mapstructure:"scim_listen" yaml:"scim_listen"`
+	// CertFile and KeyFile are the TLS certificate and key of the SCIM server.
+	CertFile string `mapstructure:"scim_cert" yaml:"scim_cert"`
+	KeyFile  string `mapstructure:"scim_key" yaml:"scim_key"`
+	// TokenFile is the file containing the bearer token which the identity provider authenticates itself with.
+	TokenFile string `mapstructure:"scim_token_file" yaml:"scim_token_file"`
+	// Broker is the name of the broker of the users provisioned by the identity provider.
+	Broker string `mapstructure:"scim_broker" yaml:"scim_broker"`
+}
+
+// Enabled returns true if the SCIM server is served.
+func (c SCIMConfig) Enabled() bool {
+	return c.Listen != ""
+}
+
+func (c SCIMConfig) validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		return errors.New("invalid scim_listen, must be a host and a port such as 0.0.0.0:8443")
+	}
+	if c.CertFile == "" || c.KeyFile == "" || c.TokenFile == "" {
+		return errors.New("scim_cert, scim_key and scim_token_file must be set to serve SCIM")
+	}
+	if c.Broker == "" {
+		return errors.New("scim_broker must be set to serve SCIM")
+	}
+	return nil
+}
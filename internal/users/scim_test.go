@@ -0,0 +1,48 @@
+package users_test
+
+import (
+	"testing"
+
+	"github.com/canonical/authd/internal/users"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSCIMConfigValidation(t *testing.T) {
+	valid := users.SCIMConfig{
+		Listen:    "0.0.0.0:8443",
+		CertFile:  "/etc/authd/scim.pem",
+		KeyFile:   "/etc/authd/scim.key",
+		TokenFile: "/etc/authd/scim.token",
+		Broker:    "Broker",
+	}
+
+	tests := map[string]struct {
+		cfg func(c *users.SCIMConfig)
+
+		wantErr bool
+	}{
+		"Disabled":      {cfg: func(c *users.SCIMConfig) { *c = users.SCIMConfig{} }},
+		"Serve_on_port": {},
+
+		"Error_on_listen_address_without_port": {cfg: func(c *users.SCIMConfig) { c.Listen = "0.0.0.0" }, wantErr: true},
+		"Error_on_missing_certificate":         {cfg: func(c *users.SCIMConfig) { c.CertFile = "" }, wantErr: true},
+		"Error_on_missing_token_file":          {cfg: func(c *users.SCIMConfig) { c.TokenFile = "" }, wantErr: true},
+		"Error_on_missing_broker":              {cfg: func(c *users.SCIMConfig) { c.Broker = "" }, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := users.DefaultConfig
+			cfg.SCIM = valid
+			if tc.cfg != nil {
+				tc.cfg(&cfg.SCIM)
+			}
+			m, err := users.NewManager(cfg, t.TempDir())
+			if tc.wantErr {
+				require.Error(t, err, "NewManager should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "NewManager should not return an error, but did")
+			t.Cleanup(func() { _ = m.Stop() })
+		})
+	}
+}
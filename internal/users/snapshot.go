@@ -0,0 +1,94 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/canonical/authd/log"
+)
+
+// snapshotsDirName is the directory of the state directory of authd where the snapshots of the database are kept by
+// default.
+const snapshotsDirName = "db-snapshots"
+
+// SnapshotConfig holds the settings of the scheduled snapshots of the database.
+type SnapshotConfig struct {
+	// Interval is the minimum duration between two snapshots. If 0, no snapshots are made.
+	Interval time.Duration `mapstructure:"db_snapshot_interval" yaml:"db_snapshot_interval"`
+	// Dir is the directory of the snapshots. If empty, they are kept in the state directory of authd.
+	Dir string `mapstructure:"db_snapshot_dir" yaml:"db_snapshot_dir,omitempty"`
+	// Keep is the number of snapshots which are kept, the older ones are removed.
+	Keep int `mapstructure:"db_snapshot_keep" yaml:"db_snapshot_keep"`
+}
+
+// Scheduled returns true if snapshots of the database are made.
+func (c SnapshotConfig) Scheduled() bool {
+	return c.Interval > 0
+}
+
+func (c SnapshotConfig) validate() error {
+	if c.Interval < 0 {
+		return fmt.Errorf("invalid db_snapshot_interval %s, must not be negative", c.Interval)
+	}
+	if !c.Scheduled() {
+		return nil
+	}
+	if c.Keep < 1 {
+		return fmt.Errorf("invalid db_snapshot_keep %d, at least one snapshot must be kept", c.Keep)
+	}
+	if c.Dir != "" && !filepath.IsAbs(c.Dir) {
+		return fmt.Errorf("invalid db_snapshot_dir %q, must be an absolute path", c.Dir)
+	}
+	return nil
+}
+
+// SnapshotDatabase makes a snapshot of the database if the latest one is older than the snapshot interval, and
+// removes the snapshots which are not kept anymore. It returns the new snapshot, or nil if none was due.
+func (m *Manager) SnapshotDatabase(ctx context.Context, now time.Time) (*db.Snapshot, error) {
+	if !m.config.Snapshot.Scheduled() {
+		return nil, nil
+	}
+
+	snapshots, err := db.Snapshots(m.snapshotsDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) > 0 && now.Sub(snapshots[0].Time) < m.config.Snapshot.Interval {
+		return nil, nil
+	}
+
+	s, err := m.db.Snapshot(m.snapshotsDir, now)
+	if err != nil {
+		return nil, fmt.Errorf("could not make a snapshot of the database: %w", err)
+	}
+	log.Infof(ctx, "Made a snapshot of the database to %s", s.Path)
+
+	if err := db.RemoveOldSnapshots(m.snapshotsDir, m.config.Snapshot.Keep); err != nil {
+		log.Warningf(ctx, "Could not remove old database snapshots: %v", err)
+	}
+	return &s, nil
+}
+
+// CheckLatestSnapshot returns an error if the latest snapshot of the database can't be restored. It returns nil if
+// there is no snapshot yet.
+func (m *Manager) CheckLatestSnapshot(ctx context.Context) (*db.Snapshot, error) {
+	snapshots, err := db.Snapshots(m.snapshotsDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, nil
+	}
+
+	s := snapshots[0]
+	version, err := db.CheckSnapshot(s.Path)
+	if err != nil {
+		return &s, errors.Join(fmt.Errorf("snapshot %s can't be restored", s.Path), err)
+	}
+	log.Debugf(ctx, "Snapshot %s of the database with schema version %d can be restored", s.Path, version)
+	return &s, nil
+}
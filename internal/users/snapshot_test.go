@@ -0,0 +1,123 @@
+package users_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotDatabase(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		interval  time.Duration
+		snapshots []time.Duration
+
+		wantSnapshot bool
+		wantKept     int
+	}{
+		"First_snapshot":                    {interval: 24 * time.Hour, wantSnapshot: true, wantKept: 1},
+		"Snapshot_once_the_interval_passed": {interval: 24 * time.Hour, snapshots: []time.Duration{25 * time.Hour}, wantSnapshot: true, wantKept: 2},
+		"Old_snapshots_are_removed": {
+			interval: 24 * time.Hour, snapshots: []time.Duration{25 * time.Hour, 49 * time.Hour, 73 * time.Hour},
+			wantSnapshot: true, wantKept: 3,
+		},
+		"No_snapshot_before_the_interval": {interval: 24 * time.Hour, snapshots: []time.Duration{time.Hour}, wantKept: 1},
+		"No_snapshot_when_disabled":       {},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+
+			snapshotsDir := filepath.Join(t.TempDir(), "snapshots")
+			cfg := users.DefaultConfig
+			cfg.Snapshot = users.SnapshotConfig{Interval: tc.interval, Dir: snapshotsDir, Keep: 3}
+			m, err := users.NewManager(cfg, dbDir)
+			require.NoError(t, err, "Setup: could not create manager")
+
+			for _, ago := range tc.snapshots {
+				_, err := m.DB().Snapshot(snapshotsDir, now.Add(-ago))
+				require.NoError(t, err, "Setup: could not make snapshot")
+			}
+
+			s, err := m.SnapshotDatabase(context.Background(), now)
+			require.NoError(t, err, "SnapshotDatabase should not return an error")
+			if !tc.wantSnapshot {
+				require.Nil(t, s, "SnapshotDatabase should not make a snapshot")
+			} else {
+				require.NotNil(t, s, "SnapshotDatabase should make a snapshot")
+				require.Equal(t, now, s.Time, "The snapshot should be made now")
+			}
+
+			snapshots, err := db.Snapshots(snapshotsDir)
+			require.NoError(t, err)
+			require.Len(t, snapshots, tc.wantKept, "Unexpected number of snapshots kept")
+		})
+	}
+}
+
+func TestCheckLatestSnapshot(t *testing.T) {
+	tests := map[string]struct {
+		noSnapshot      bool
+		corruptedLatest bool
+
+		wantErr bool
+	}{
+		"Latest_snapshot_can_be_restored": {},
+		"No_error_without_snapshot":       {noSnapshot: true},
+
+		"Error_when_the_latest_snapshot_is_corrupted": {corruptedLatest: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dbDir := t.TempDir()
+			cfg := users.DefaultConfig
+			cfg.Snapshot.Dir = filepath.Join(t.TempDir(), "snapshots")
+			m, err := users.NewManager(cfg, dbDir)
+			require.NoError(t, err, "Setup: could not create manager")
+
+			now := time.Now()
+			if !tc.noSnapshot {
+				_, err := m.DB().Snapshot(cfg.Snapshot.Dir, now.Add(-time.Hour))
+				require.NoError(t, err, "Setup: could not make snapshot")
+			}
+			if tc.corruptedLatest {
+				s, err := m.DB().Snapshot(cfg.Snapshot.Dir, now)
+				require.NoError(t, err, "Setup: could not make snapshot")
+				require.NoError(t, os.Truncate(s.Path, 20), "Setup: could not corrupt snapshot")
+			}
+
+			s, err := m.CheckLatestSnapshot(context.Background())
+			if tc.wantErr {
+				require.Error(t, err, "CheckLatestSnapshot should return an error")
+				return
+			}
+			require.NoError(t, err, "CheckLatestSnapshot should not return an error")
+			require.Equal(t, tc.noSnapshot, s == nil, "CheckLatestSnapshot should return the latest snapshot if any")
+		})
+	}
+}
+
+func TestSnapshotConfigValidation(t *testing.T) {
+	tests := map[string]users.SnapshotConfig{
+		"Error_if_interval_is_negative": {Interval: -time.Hour, Keep: 1},
+		"Error_if_no_snapshot_is_kept":  {Interval: time.Hour},
+		"Error_if_dir_is_relative":      {Interval: time.Hour, Keep: 1, Dir: "snapshots"},
+	}
+	for name, c := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := users.DefaultConfig
+			cfg.Snapshot = c
+			_, err := users.NewManager(cfg, t.TempDir())
+			require.Error(t, err, "NewManager should return an error")
+		})
+	}
+}
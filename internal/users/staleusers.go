@@ -0,0 +1,171 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/canonical/authd/internal/brokers/auth"
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/canonical/authd/internal/users/proc"
+	"github.com/canonical/authd/log"
+)
+
+// StaleUserAction is what is done with the users who haven't logged in for longer than the retention period.
+type StaleUserAction string
+
+const (
+	// StaleUserDisable disables the users, which starts their deprovisioning. Their home is kept until they are
+	// archived.
+	StaleUserDisable StaleUserAction = "disable"
+	// StaleUserArchive disables the users and moves their home to an archive right away.
+	StaleUserArchive StaleUserAction = "archive"
+	// StaleUserDelete deletes the users. Their home is cleaned up according to the home cleanup policy.
+	StaleUserDelete StaleUserAction = "delete"
+)
+
+// StaleUsersConfig holds the settings of the cleanup of the users who haven't logged in for a long time.
+type StaleUsersConfig struct {
+	// After is the duration without a successful login after which users are stale. If 0, no user is ever stale.
+	After time.Duration `mapstructure:"stale_users_after" yaml:"stale_users_after"`
+	// Action is what is done with the stale users.
+	Action StaleUserAction `mapstructure:"stale_users_action" yaml:"stale_users_action"`
+}
+
+// Scheduled returns true if the stale users are cleaned up.
+func (c StaleUsersConfig) Scheduled() bool {
+	return c.After > 0
+}
+
+func (c StaleUsersConfig) validate() error {
+	if c.After < 0 {
+		return fmt.Errorf("invalid stale_users_after %s, must not be negative", c.After)
+	}
+	if !c.Scheduled() {
+		return nil
+	}
+	switch c.Action {
+	case StaleUserDisable, StaleUserArchive, StaleUserDelete:
+		return nil
+	}
+	return fmt.Errorf("invalid stale_users_action %q, must be one of %q, %q or %q", c.Action, StaleUserDisable, StaleUserArchive, StaleUserDelete)
+}
+
+// StaleUser describes the cleanup of a user who hasn't logged in for longer than the retention period.
+type StaleUser struct {
+	Name       string
+	UID        uint32
+	Home       string
+	BrokerID   string
+	ProviderID string
+	// LastLogin is the time of the last successful login of the user. It's zero if none is recorded.
+	LastLogin time.Time
+	// Action is what is done with the user.
+	Action StaleUserAction
+	// Skipped explains why the user is left untouched, if they are.
+	Skipped string
+	// Done is true if the action was performed.
+	Done bool
+	// Archive is the path of the archive of the home of the user, if it was archived.
+	Archive string
+}
+
+// StaleUsersPolicy returns the settings of the cleanup of the stale users.
+func (m *Manager) StaleUsersPolicy() StaleUsersConfig {
+	return m.config.StaleUsers
+}
+
+// CleanupStaleUsers disables, archives or deletes the active users whose last successful login is older than the
+// retention period at now, according to the policy. It returns the stale users and the users without any recorded
+// login, which are left untouched. If dryRun is true, nothing is changed.
+func (m *Manager) CleanupStaleUsers(ctx context.Context, now time.Time, dryRun bool) ([]StaleUser, error) {
+	if !m.config.StaleUsers.Scheduled() {
+		return nil, nil
+	}
+
+	m.lifecycleMu.Lock()
+	defer m.lifecycleMu.Unlock()
+
+	users, err := m.db.AllUsers()
+	if err != nil {
+		return nil, err
+	}
+	lastLogins, err := m.db.LastLogins(auth.Granted)
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []StaleUser
+	var errs []error
+	for _, u := range users {
+		lc, err := m.userLifecycle(u)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// The users which are already being deprovisioned move on to their next state on their own.
+		if lc.State != LifecycleActive {
+			continue
+		}
+
+		s := StaleUser{
+			Name:       u.Name,
+			UID:        u.UID,
+			Home:       u.Dir,
+			BrokerID:   u.BrokerID,
+			ProviderID: u.ProviderID,
+			LastLogin:  lastLogins[u.UID],
+			Action:     m.config.StaleUsers.Action,
+		}
+		switch {
+		case s.LastLogin.IsZero():
+			// The login history may have been pruned, or the user imported, so their last login is unknown.
+			s.Skipped = "no login recorded"
+		case now.Sub(s.LastLogin) < m.config.StaleUsers.After:
+			continue
+		case proc.CheckUserBusy(u.Name, u.UID) != nil:
+			// The user may be in a session opened before the retention period.
+			s.Skipped = "has running processes"
+		}
+
+		if !dryRun && s.Skipped == "" {
+			if err := m.cleanupStaleUser(ctx, &s, u); err != nil {
+				errs = append(errs, fmt.Errorf("could not %s stale user %q: %w", s.Action, u.Name, err))
+			} else {
+				s.Done = true
+			}
+		}
+
+		stale = append(stale, s)
+	}
+
+	return stale, errors.Join(errs...)
+}
+
+// cleanupStaleUser performs the action of the policy on the stale user.
+func (m *Manager) cleanupStaleUser(ctx context.Context, s *StaleUser, u db.UserRow) error {
+	log.Noticef(ctx, "User %q did not log in since %s, applying stale user action %q", u.Name, s.LastLogin.Format(time.DateTime), s.Action)
+
+	if s.Action == StaleUserDelete {
+		if err := m.DeleteUser(u.Name, false); err != nil {
+			return err
+		}
+		m.runLifecycleHooks(ctx, lifecycleEvent{State: LifecycleDeleted, PreviousState: LifecycleActive, Username: u.Name, UID: u.UID, Home: u.Dir})
+		return nil
+	}
+
+	if err := m.disableUser(ctx, u); err != nil {
+		return err
+	}
+	if s.Action != StaleUserArchive {
+		return nil
+	}
+	if err := m.archiveUser(ctx, u); err != nil {
+		return err
+	}
+	if archive := m.homeArchivePath(u.Name); fileExists(archive) {
+		s.Archive = archive
+	}
+	return nil
+}
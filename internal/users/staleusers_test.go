@@ -0,0 +1,138 @@
+package users_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/users"
+	"github.com/canonical/authd/internal/users/db"
+	localgroupstestutils "github.com/canonical/authd/internal/users/localentries/testutils"
+	userstestutils "github.com/canonical/authd/internal/users/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanupStaleUsers(t *testing.T) {
+	const staleUser = "user1@example.com"
+
+	tests := map[string]struct {
+		action   users.StaleUserAction
+		after    time.Duration
+		disabled bool
+		dryRun   bool
+
+		wantState    users.LifecycleState
+		wantDeleted  bool
+		wantArchived bool
+		wantNoReport bool
+	}{
+		"Disable_stale_user":           {action: users.StaleUserDisable, wantState: users.LifecycleDisabled},
+		"Archive_stale_user":           {action: users.StaleUserArchive, wantState: users.LifecycleArchived, wantArchived: true},
+		"Delete_stale_user":            {action: users.StaleUserDelete, wantDeleted: true},
+		"Dry_run_only_reports":         {action: users.StaleUserDelete, dryRun: true, wantState: users.LifecycleActive},
+		"Do_nothing_when_not_enabled":  {action: users.StaleUserDelete, disabled: true, wantState: users.LifecycleActive, wantNoReport: true},
+		"Keep_user_logged_in_recently": {action: users.StaleUserDelete, after: 72 * time.Hour, wantState: users.LifecycleActive},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// We don't care about the output of gpasswd in this test, but we still need to mock it.
+			_ = localgroupstestutils.SetupGroupMock(t, filepath.Join("testdata", "groups", "empty.group"))
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups_with_tmp_home.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+
+			t.Cleanup(func() { _ = os.RemoveAll("/tmp/authd-delete-user-test/") })
+			createTestHome(t, lifecycleTestHome)
+
+			if tc.after == 0 && !tc.disabled {
+				tc.after = 24 * time.Hour
+			}
+			cfg := users.DefaultConfig
+			cfg.StaleUsers = users.StaleUsersConfig{After: tc.after, Action: tc.action}
+			m, err := users.NewManager(cfg, dbDir)
+			require.NoError(t, err, "Setup: could not create manager")
+
+			now := time.Now()
+			records := []db.LoginRecordRow{
+				db.NewLoginRecordRow(1111, now.Add(-48*time.Hour), "sshd", "broker-id", false, "granted"),
+				db.NewLoginRecordRow(1111, now.Add(-time.Hour), "sshd", "broker-id", false, "denied"),
+				db.NewLoginRecordRow(2222, now.Add(-time.Hour), "sshd", "broker-id", true, "granted"),
+				db.NewLoginRecordRow(3333, now.Add(-72*time.Hour), "sshd", "broker-id", false, "denied"),
+			}
+			for _, r := range records {
+				require.NoError(t, userstestutils.DBManager(m).AddLoginRecord(r), "Setup: could not add login record")
+			}
+
+			stale, err := m.CleanupStaleUsers(context.Background(), now, tc.dryRun)
+			require.NoError(t, err, "CleanupStaleUsers should not return an error")
+
+			if tc.wantNoReport {
+				require.Empty(t, stale, "No user should be reported when the cleanup is not enabled")
+			} else {
+				got := make(map[string]users.StaleUser)
+				for _, s := range stale {
+					got[s.Name] = s
+				}
+				require.NotContains(t, got, "user2@example.com", "User who logged in recently should not be reported")
+				require.Equal(t, "no login recorded", got["user3@example.com"].Skipped, "User without successful login should be skipped")
+				require.Equal(t, "no login recorded", got["userwithoutbroker@example.com"].Skipped, "User without login should be skipped")
+
+				s, ok := got[staleUser]
+				if tc.wantState == users.LifecycleActive && !tc.dryRun {
+					require.False(t, ok, "User who logged in within the retention period should not be reported")
+				} else {
+					require.True(t, ok, "Stale user should be reported")
+					require.Equal(t, tc.action, s.Action, "Stale user should be reported with the action of the policy")
+					require.WithinDuration(t, now.Add(-48*time.Hour), s.LastLogin, time.Second, "Stale user should be reported with their last successful login")
+					require.Equal(t, !tc.dryRun, s.Done, "Action should only be done when not in dry run")
+					require.Equal(t, tc.wantArchived, s.Archive != "", "Archive should only be reported when the home was archived")
+				}
+			}
+
+			lc, err := m.UserLifecycle(staleUser)
+			if tc.wantDeleted {
+				require.ErrorIs(t, err, db.NoDataFoundError{}, "Deleted stale user should not be found")
+				require.DirExists(t, lifecycleTestHome, "Home of deleted stale user should be left to the home cleanup policy")
+				return
+			}
+			require.NoError(t, err, "UserLifecycle should not return an error")
+			require.Equal(t, tc.wantState, lc.State, "Stale user should be in the expected state")
+			if tc.wantArchived {
+				require.FileExists(t, lc.Archive, "Home of stale user should be archived")
+				require.NoDirExists(t, lifecycleTestHome, "Archived home should be removed")
+				return
+			}
+			require.DirExists(t, lifecycleTestHome, "Home of stale user should be kept")
+		})
+	}
+}
+
+func TestStaleUsersConfigValidation(t *testing.T) {
+	tests := map[string]struct {
+		cfg users.StaleUsersConfig
+
+		wantErr bool
+	}{
+		"Disabled_without_action":   {},
+		"Disable_stale_users":       {cfg: users.StaleUsersConfig{After: time.Hour, Action: users.StaleUserDisable}},
+		"Delete_stale_users":        {cfg: users.StaleUsersConfig{After: time.Hour, Action: users.StaleUserDelete}},
+		"Error_on_negative_period":  {cfg: users.StaleUsersConfig{After: -time.Hour, Action: users.StaleUserDisable}, wantErr: true},
+		"Error_on_invalid_action":   {cfg: users.StaleUsersConfig{After: time.Hour, Action: "remove"}, wantErr: true},
+		"Error_on_action_not_given": {cfg: users.StaleUsersConfig{After: time.Hour}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := users.DefaultConfig
+			cfg.StaleUsers = tc.cfg
+			_, err := users.NewManager(cfg, t.TempDir())
+			if tc.wantErr {
+				require.Error(t, err, "NewManager should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "NewManager should not return an error, but did")
+		})
+	}
+}
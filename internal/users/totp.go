@@ -0,0 +1,186 @@
+package users
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/canonical/authd/internal/decorate"
+	"github.com/canonical/authd/internal/totp"
+	"github.com/canonical/authd/internal/users/db"
+)
+
+const (
+	// totpKeyFileName is the file of the state directory of authd with the key encrypting the TOTP secrets of the
+	// users in the database, so that a copy of the database alone doesn't reveal them.
+	totpKeyFileName = "totp.key"
+	// totpIssuer is the issuer of the TOTP secrets, shown by the authenticator apps.
+	totpIssuer = "authd"
+)
+
+// EnrollTOTP generates a new TOTP secret for the user with the given name, replacing their previous one, and returns
+// its otpauth URI to import in an authenticator app.
+func (m *Manager) EnrollTOTP(name string) (uri string, err error) {
+	defer decorate.OnError(&err, "failed to enroll TOTP secret of user %q", name)
+
+	u, err := m.db.UserByName(name)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := m.encryptTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	if err := m.db.SetTOTPSecret(u.UID, encrypted); err != nil {
+		return "", err
+	}
+
+	return totp.URI(secret, totpIssuer, u.Name), nil
+}
+
+// DisableTOTP removes the TOTP secret of the user with the given name. It returns a NoDataFoundError if the user has
+// none.
+func (m *Manager) DisableTOTP(name string) (err error) {
+	defer decorate.OnError(&err, "failed to disable TOTP of user %q", name)
+
+	u, err := m.db.UserByName(name)
+	if err != nil {
+		return err
+	}
+
+	return m.db.DeleteTOTPSecret(u.UID)
+}
+
+// HasTOTP returns true if the user with the given name exists and enrolled a TOTP secret.
+func (m *Manager) HasTOTP(name string) (bool, error) {
+	u, err := m.userByAnyName(name)
+	if errors.Is(err, db.NoDataFoundError{}) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = m.db.TOTPSecret(u.UID)
+	if errors.Is(err, db.NoDataFoundError{}) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// VerifyTOTP returns true if the code is a valid code of the TOTP secret of the user with the given name. Each code
+// is only accepted once.
+func (m *Manager) VerifyTOTP(name, code string) (ok bool, err error) {
+	defer decorate.OnError(&err, "failed to verify TOTP code of user %q", name)
+
+	u, err := m.userByAnyName(name)
+	if err != nil {
+		return false, err
+	}
+	row, err := m.db.TOTPSecret(u.UID)
+	if err != nil {
+		return false, err
+	}
+	secret, err := m.decryptTOTPSecret(row.Secret)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return m.db.UseTOTPStep(u.UID, step)
+}
+
+func (m *Manager) encryptTOTPSecret(secret []byte) (string, error) {
+	aead, err := m.totpCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(secret)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, secret, nil)), nil
+}
+
+func (m *Manager) decryptTOTPSecret(encrypted string) ([]byte, error) {
+	aead, err := m.totpCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("invalid TOTP secret: too short")
+	}
+	secret, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt TOTP secret: %w", err)
+	}
+	return secret, nil
+}
+
+// totpCipher returns the AES-GCM cipher of the TOTP secrets, generating its key on first use.
+func (m *Manager) totpCipher() (cipher.AEAD, error) {
+	m.totpKeyMu.Lock()
+	defer m.totpKeyMu.Unlock()
+
+	key, err := os.ReadFile(m.totpKeyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err = writeTOTPKey(m.totpKeyPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load the key of the TOTP secrets: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key of the TOTP secrets: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeTOTPKey generates a new AES-256 key and writes it at path, only readable by root.
+func writeTOTPKey(path string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(key)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
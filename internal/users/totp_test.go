@@ -0,0 +1,100 @@
+package users_test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/internal/totp"
+	"github.com/canonical/authd/internal/users/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTP(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		username string
+		code     func(secret []byte) string
+		reuse    bool
+		disable  bool
+
+		wantOK        bool
+		wantEnrollErr error
+	}{
+		"Accept_a_valid_code": {wantOK: true},
+		"Accept_the_code_of_the_previous_period": {
+			code:   func(s []byte) string { return totp.Code(s, totp.Step(time.Now())-1) },
+			wantOK: true,
+		},
+		"Reject_the_code_of_another_period": {
+			code: func(s []byte) string { return totp.Code(s, totp.Step(time.Now())+5) },
+		},
+		"Reject_a_code_used_twice":     {reuse: true},
+		"Not_enrolled_after_disabling": {disable: true},
+
+		"Error_if_user_does_not_exist": {username: "doesnotexist@example.com", wantEnrollErr: db.NoDataFoundError{}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.username == "" {
+				tc.username = "user1@example.com"
+			}
+			if tc.code == nil {
+				tc.code = func(s []byte) string { return totp.Code(s, totp.Step(time.Now())) }
+			}
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "one_user_and_group_with_providerid.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m := newManagerForTests(t, dbDir)
+
+			has, err := m.HasTOTP("user1@example.com")
+			require.NoError(t, err, "HasTOTP should not return an error")
+			require.False(t, has, "User should not have a TOTP secret before enrolling")
+
+			uri, err := m.EnrollTOTP(tc.username)
+			requireErrorAssertions(t, err, tc.wantEnrollErr, false)
+			if tc.wantEnrollErr != nil {
+				return
+			}
+
+			key, err := os.Stat(filepath.Join(dbDir, "totp.key"))
+			require.NoError(t, err, "The key of the TOTP secrets should be created")
+			require.Equal(t, os.FileMode(0600), key.Mode().Perm(), "The key of the TOTP secrets should only be readable by root")
+			has, err = m.HasTOTP(tc.username)
+			require.NoError(t, err, "HasTOTP should not return an error")
+			require.True(t, has, "User should have a TOTP secret after enrolling")
+
+			u, err := url.Parse(uri)
+			require.NoError(t, err, "EnrollTOTP should return a valid URI")
+			secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(u.Query().Get("secret"))
+			require.NoError(t, err, "EnrollTOTP should return the secret in base32")
+
+			if tc.disable {
+				require.NoError(t, m.DisableTOTP(tc.username), "DisableTOTP should not return an error")
+				has, err = m.HasTOTP(tc.username)
+				require.NoError(t, err, "HasTOTP should not return an error")
+				require.False(t, has, "User should not have a TOTP secret after disabling it")
+				require.ErrorIs(t, m.DisableTOTP(tc.username), db.NoDataFoundError{}, "DisableTOTP should fail without a secret")
+				return
+			}
+
+			code := tc.code(secret)
+			if tc.reuse {
+				ok, err := m.VerifyTOTP(tc.username, code)
+				require.NoError(t, err, "Setup: VerifyTOTP should not return an error")
+				require.True(t, ok, "Setup: VerifyTOTP should accept the code the first time")
+			}
+
+			ok, err := m.VerifyTOTP(tc.username, code)
+			require.NoError(t, err, "VerifyTOTP should not return an error")
+			require.Equal(t, tc.wantOK, ok, "VerifyTOTP should only accept the valid codes")
+		})
+	}
+}
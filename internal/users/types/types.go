@@ -1,6 +1,8 @@
 // Package types provides types for the users package.
 package types
 
+import "time"
+
 // UserInfo is the user information returned by the broker.
 type UserInfo struct {
 	Name  string
@@ -19,7 +21,39 @@ type UserInfo struct {
 	// v2 brokers or users that have not yet logged in after the migration.
 	ProviderID string `json:"provider_id,omitempty" yaml:"provider_id"`
 
+	// SharedPrimaryGID is the GID of the primary group shared by the new users of the broker, instead of a group
+	// private to the user. It is set by authd from the broker configuration, not by broker-returned JSON.
+	SharedPrimaryGID *uint32 `json:"-" yaml:"shared_primary_gid,omitempty"`
+
+	// DefaultGroups are the local groups which the new users of the broker are added to. It is set by authd from the
+	// broker configuration, not by broker-returned JSON.
+	DefaultGroups []string `json:"-" yaml:"default_groups,omitempty"`
+
+	// DirectoryUID is the UID of the user in the LDAP directory, which they get instead of a generated one when they
+	// are created. It is set by authd from the directory, not by broker-returned JSON.
+	DirectoryUID *uint32 `json:"-" yaml:"directory_uid,omitempty"`
+
+	// UIDHint is the UID suggested by the broker, which the user gets instead of a generated one when they are created
+	// if it's in the UID range of the broker and free. It is ignored when the IDs are allocated centrally.
+	UIDHint *uint32 `json:"uid_hint,omitempty" yaml:"-"`
+
+	// OriginalName is the name of the user as returned by the broker, before it was normalized. It is set by authd,
+	// not by broker-returned JSON. It is empty if the name was not normalized.
+	OriginalName string `json:"-" yaml:"original_name,omitempty"`
+
 	Groups []GroupInfo
+
+	// Env holds the environment variables exported in the sessions of the user, e.g. derived from claims of the
+	// provider.
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+
+	// SSHKeys holds the SSH public keys which the user can log in with, as lines of an authorized_keys file without
+	// options.
+	SSHKeys []string `json:"ssh_keys,omitempty" yaml:"ssh_keys,omitempty"`
+
+	// Picture is the PNG or JPEG picture of the user, published to AccountsService so that the greeter shows it. It
+	// is not stored in the database.
+	Picture []byte `json:"picture,omitempty" yaml:"-"`
 }
 
 // GroupInfo is the group information returned by the broker.
@@ -57,3 +91,14 @@ type GroupEntry struct {
 	Users  []string
 	Passwd string
 }
+
+// LoginRecord is an entry of the login history of a user.
+type LoginRecord struct {
+	Time     time.Time
+	Service  string
+	BrokerID string
+	Offline  bool
+	Result   string
+	// MachineToken is true if the user authenticated with a machine token, without their broker.
+	MachineToken bool
+}
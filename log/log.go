@@ -136,7 +136,14 @@ func log(context context.Context, level Level, args ...interface{}) {
 		return
 	}
 
-	logf(context, level, fmt.Sprint(args...))
+	msg := fmt.Sprint(args...)
+	if redacted := RedactSecrets(msg); redacted != msg {
+		callHandler(context, level, "%s", redacted)
+		return
+	}
+
+	// The message is already formatted, so its % must not be interpreted as verbs.
+	callHandler(context, level, "%s", msg)
 }
 
 func logf(context context.Context, level Level, format string, args ...interface{}) {
@@ -144,6 +151,20 @@ func logf(context context.Context, level Level, format string, args ...interface
 		return
 	}
 
+	// Only pass the redacted message if it contains secrets, so that handlers get the original
+	// format and arguments otherwise.
+	if !LogSecrets() {
+		msg := fmt.Sprintf(format, args...)
+		if redacted := RedactSecrets(msg); redacted != msg {
+			callHandler(context, level, "%s", redacted)
+			return
+		}
+	}
+
+	callHandler(context, level, format, args...)
+}
+
+func callHandler(context context.Context, level Level, format string, args ...interface{}) {
 	handlersMu.RLock()
 	handler := handlers[level]
 	handlersMu.RUnlock()
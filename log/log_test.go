@@ -81,7 +81,8 @@ func TestSetLevelHandler(t *testing.T) {
 				handlerCalled = true
 				require.Equal(t, wantCtx, ctx, "Context should match expected")
 				require.Equal(t, level, l, "Log level should match %v", l)
-				require.Equal(t, fmt.Sprint(wantArgs...), format, "Format should match")
+				require.Equal(t, "%s", format, "Format should match")
+				require.Equal(t, []any{fmt.Sprint(wantArgs...)}, args, "Arguments should be the message")
 			})
 
 			log.SetLevel(level)
@@ -149,7 +150,8 @@ func TestSetHandler(t *testing.T) {
 		handlerCalled = true
 		require.Equal(t, wantCtx, ctx, "Context should match expected")
 		require.Equal(t, wantLevel, l, "Log level should match %v", l)
-		require.Equal(t, fmt.Sprint(wantArgs...), format, "Format should match")
+		require.Equal(t, "%s", format, "Format should match")
+		require.Equal(t, []any{fmt.Sprint(wantArgs...)}, args, "Arguments should be the message")
 	})
 	for idx, level := range supportedLevels {
 		t.Run(fmt.Sprintf("Set log handler, testing level %s", level), func(t *testing.T) {})
@@ -0,0 +1,64 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"sync/atomic"
+)
+
+// redactedValue replaces secrets in the logs.
+const redactedValue = "***"
+
+var logSecrets atomic.Bool
+
+// SetLogSecrets controls whether secrets are written to the logs. Secrets are redacted by default,
+// logging them is only meant for development.
+func SetLogSecrets(enabled bool) {
+	logSecrets.Store(enabled)
+}
+
+// LogSecrets returns true if secrets are written to the logs.
+func LogSecrets() bool {
+	return logSecrets.Load()
+}
+
+// secretKeys matches the names of fields and parameters holding secrets, in snake case or in camel case.
+const secretKeys = `access_?token|refresh_?token|id_?token|client_?secret|secret|[\w-]*password|passwd|challenge|` +
+	`auth_?code|authorization_?code|device_?code|totp_?code|machine_?token`
+
+var (
+	// secretJSONRe matches JSON string values of secret keys, e.g. {"challenge":"value"}.
+	secretJSONRe = regexp.MustCompile(`(?i)("(?:` + secretKeys + `)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	// secretGoRe matches quoted Go struct fields of secret keys, as printed by %#v, e.g. {Secret:"value"}.
+	secretGoRe = regexp.MustCompile(`(?i)\b(` + secretKeys + `)(:\s*)"(?:[^"\\]|\\.)*"`)
+	// secretParamRe matches key=value parameters of secret keys, e.g. in URL queries.
+	secretParamRe = regexp.MustCompile(`(?i)\b(` + secretKeys + `)=[^\s&,;"']+`)
+	// oauthCodeRe matches the authorization code parameter of OAuth requests, in URL queries or form bodies, e.g.
+	// ?code=value or code=value&state=…, but not the other codes, e.g. "exit code=1".
+	oauthCodeRe = regexp.MustCompile(`(?i)([?&]code=)[^\s&,;"']+|\b(code=)[^\s&,;"']+(&)`)
+)
+
+// RedactSecrets masks the values of well-known secret fields (tokens, challenges, passwords,
+// authorization codes…) in msg, unless logging secrets is enabled.
+func RedactSecrets(msg string) string {
+	if LogSecrets() {
+		return msg
+	}
+
+	msg = secretJSONRe.ReplaceAllString(msg, `${1}"`+redactedValue+`"`)
+	msg = secretGoRe.ReplaceAllString(msg, `${1}${2}"`+redactedValue+`"`)
+	msg = secretParamRe.ReplaceAllString(msg, `${1}=`+redactedValue)
+	return oauthCodeRe.ReplaceAllString(msg, `${1}${2}`+redactedValue+`${3}`)
+}
+
+// Secret is a string which is redacted when formatted, unless logging secrets is enabled.
+type Secret string
+
+// Format implements fmt.Formatter.
+func (s Secret) Format(f fmt.State, verb rune) {
+	if !LogSecrets() {
+		fmt.Fprint(f, redactedValue)
+		return
+	}
+	fmt.Fprintf(f, fmt.FormatString(f, verb), string(s))
+}
@@ -0,0 +1,190 @@
+package log_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/canonical/authd/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	// This can't be parallel, as it changes the global log secrets setting.
+	t.Cleanup(func() { log.SetLogSecrets(false) })
+
+	tests := map[string]struct {
+		msg        string
+		logSecrets bool
+
+		want string
+	}{
+		"Message_without_secrets_is_unchanged": {
+			msg:  `Authentication result for user "user1": granted`,
+			want: `Authentication result for user "user1": granted`,
+		},
+		"Redact_JSON_challenge": {
+			msg:  `data: {"challenge":"my password","wait":"true"}`,
+			want: `data: {"challenge":"***","wait":"true"}`,
+		},
+		"Redact_JSON_tokens": {
+			msg:  `{"access_token": "abc", "refresh_token":"d\"ef", "token_type":"Bearer"}`,
+			want: `{"access_token": "***", "refresh_token":"***", "token_type":"Bearer"}`,
+		},
+		"Redact_Go_struct_secret": {
+			msg:  `request: &authd.IARequest_AuthenticationData_Secret{Secret:"my password"}`,
+			want: `request: &authd.IARequest_AuthenticationData_Secret{Secret:"***"}`,
+		},
+		"Redact_Go_struct_TOTP_code": {
+			msg:  `item: &authd.IARequest_AuthenticationData_TotpCode{TotpCode:"123456"}`,
+			want: `item: &authd.IARequest_AuthenticationData_TotpCode{TotpCode:"***"}`,
+		},
+		"Redact_JSON_TOTP_code": {
+			msg:  `{"totp_code":"123456","wait":"false"}`,
+			want: `{"totp_code":"***","wait":"false"}`,
+		},
+		"Redact_Go_struct_machine_token": {
+			msg:  `item: &authd.IARequest_AuthenticationData_MachineToken{MachineToken:"s3cr3t"}`,
+			want: `item: &authd.IARequest_AuthenticationData_MachineToken{MachineToken:"***"}`,
+		},
+		"Redact_JSON_machine_token": {
+			msg:  `{"machine_token":"s3cr3t"}`,
+			want: `{"machine_token":"***"}`,
+		},
+		"Redact_Go_struct_password_case_insensitively": {
+			msg:  `{Name:"user1" NewPassword:"pass"}`,
+			want: `{Name:"user1" NewPassword:"***"}`,
+		},
+		"Redact_URL_parameters": {
+			msg:  `GET /callback?code=abc123&state=xyz&client_secret=s3cr3t`,
+			want: `GET /callback?code=***&state=xyz&client_secret=***`,
+		},
+		"Redact_Go_struct_token_in_camel_case": {
+			msg:  `&oauth2.Token{AccessToken:"abc", TokenType:"Bearer", RefreshToken:"def"}`,
+			want: `&oauth2.Token{AccessToken:"***", TokenType:"Bearer", RefreshToken:"***"}`,
+		},
+		"Redact_JSON_ID_token": {
+			msg:  `{"id_token":"eyJ","expires_in":3600}`,
+			want: `{"id_token":"***","expires_in":3600}`,
+		},
+		"Redact_code_of_form_body": {
+			msg:  `POST /token body: code=abc123&redirect_uri=http://localhost`,
+			want: `POST /token body: code=***&redirect_uri=http://localhost`,
+		},
+		"Do_not_redact_mentions_of_secrets": {
+			msg:  `Token does not exist for user "user1", password authentication is not available`,
+			want: `Token does not exist for user "user1", password authentication is not available`,
+		},
+		"Do_not_redact_other_codes": {
+			msg:  `Command failed: exit code=1, {"code":"invalid_grant","error":"expired"}`,
+			want: `Command failed: exit code=1, {"code":"invalid_grant","error":"expired"}`,
+		},
+		"Do_not_redact_token_types_and_lifetimes": {
+			msg:  `token_type=Bearer token_lifetime=3600`,
+			want: `token_type=Bearer token_lifetime=3600`,
+		},
+
+		"Keep_secrets_if_logging_secrets_is_enabled": {
+			msg:        `{"challenge":"my password"}`,
+			logSecrets: true,
+			want:       `{"challenge":"my password"}`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			log.SetLogSecrets(tc.logSecrets)
+			require.Equal(t, tc.logSecrets, log.LogSecrets(), "LogSecrets should return the configured value")
+
+			require.Equal(t, tc.want, log.RedactSecrets(tc.msg), "Unexpected redacted message")
+		})
+	}
+}
+
+func TestSecret(t *testing.T) {
+	// This can't be parallel, as it changes the global log secrets setting.
+	t.Cleanup(func() { log.SetLogSecrets(false) })
+
+	s := log.Secret("my password")
+
+	log.SetLogSecrets(false)
+	require.Equal(t, "***", fmt.Sprintf("%s", s), "Secret should be redacted by default")
+	require.Equal(t, "***", fmt.Sprintf("%q", s), "Secret should be redacted with any verb")
+	require.Equal(t, "***", fmt.Sprint(s), "Secret should be redacted when printed")
+
+	log.SetLogSecrets(true)
+	require.Equal(t, "my password", fmt.Sprintf("%s", s), "Secret should be printed if logging secrets is enabled")
+	require.Equal(t, `"my password"`, fmt.Sprintf("%q", s), "Secret should respect the verb if logging secrets is enabled")
+}
+
+func TestLogRedactsSecrets(t *testing.T) {
+	// This can't be parallel, as it changes the global log handlers.
+	defaultLevel := log.GetLevel()
+	t.Cleanup(func() {
+		log.SetLevel(defaultLevel)
+		log.SetHandler(nil)
+		log.SetLogSecrets(false)
+	})
+	log.SetLevel(log.DebugLevel)
+
+	tests := map[string]struct {
+		format     string
+		args       []any
+		logSecrets bool
+
+		wantMsg string
+	}{
+		"Redact_secrets_in_formatted_arguments": {
+			format:  "Authentication request: %#v",
+			args:    []any{struct{ Secret string }{Secret: "my password"}},
+			wantMsg: `Authentication request: struct { Secret string }{Secret:"***"}`,
+		},
+		"Redact_secrets_in_format": {
+			format:  `Authentication data: {"challenge":"my password"}`,
+			wantMsg: `Authentication data: {"challenge":"***"}`,
+		},
+		"Keep_message_without_secrets": {
+			format:  "User %q logged in",
+			args:    []any{"user1"},
+			wantMsg: `User "user1" logged in`,
+		},
+
+		"Keep_secrets_if_logging_secrets_is_enabled": {
+			format:     "Authentication request: %#v",
+			args:       []any{struct{ Secret string }{Secret: "my password"}},
+			logSecrets: true,
+			wantMsg:    `Authentication request: struct { Secret string }{Secret:"my password"}`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			log.SetLogSecrets(tc.logSecrets)
+
+			var gotMsg string
+			log.SetHandler(func(_ context.Context, _ log.Level, format string, args ...interface{}) {
+				gotMsg = fmt.Sprintf(format, args...)
+			})
+
+			log.Debugf(context.Background(), tc.format, tc.args...)
+			require.Equal(t, tc.wantMsg, gotMsg, "Unexpected logged message")
+		})
+	}
+}
+
+func TestLogDoesNotFormatMessages(t *testing.T) {
+	// This can't be parallel, as it changes the global log handlers.
+	defaultLevel := log.GetLevel()
+	t.Cleanup(func() {
+		log.SetLevel(defaultLevel)
+		log.SetHandler(nil)
+	})
+	log.SetLevel(log.DebugLevel)
+
+	var gotMsg string
+	log.SetHandler(func(_ context.Context, _ log.Level, format string, args ...interface{}) {
+		gotMsg = fmt.Sprintf(format, args...)
+	})
+
+	msg := "Disk usage of /home: 95%s full, 5%d left"
+	log.Debug(context.Background(), msg)
+	require.Equal(t, msg, gotMsg, "The message should be logged as is")
+}
@@ -54,6 +54,11 @@ func TestIntegration(t *testing.T) {
 		"Get_entry_from_group_by_name":                {getentDB: "group", key: "group1"},
 		"Get_entry_from_shadow_by_name":               {getentDB: "shadow", key: "user1@example.com"},
 
+		"Get_groups_of_user_from_initgroups":               {getentDB: "initgroups", key: "user1@example.com"},
+		"Get_groups_of_user_from_initgroups_in_upper_case": {getentDB: "initgroups", key: "USER1@EXAMPLE.COM"},
+		// getent doesn't fail when the user doesn't exist, it prints no groups for them.
+		"Get_no_groups_from_initgroups_if_user_does_not_exist": {getentDB: "initgroups", key: "doesnotexist"},
+
 		"Get_entry_from_passwd_by_id": {getentDB: "passwd", key: "1111"},
 		"Get_entry_from_group_by_id":  {getentDB: "group", key: "11111"},
 
@@ -6,5 +6,6 @@ void db_override() {
     __nss_configure_lookup("passwd", "files authd");
     __nss_configure_lookup("group", "files authd");
     __nss_configure_lookup("shadow", "files authd");
+    __nss_configure_lookup("initgroups", "files authd");
 }
 #endif
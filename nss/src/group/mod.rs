@@ -1,4 +1,4 @@
-use crate::{info, REQUEST_TIMEOUT};
+use crate::{info, LIST_PAGE_SIZE, REQUEST_TIMEOUT};
 use libc::gid_t;
 use libnss::group::{Group, GroupHooks};
 use libnss::interop::Response;
@@ -45,13 +45,28 @@ fn get_all_entries() -> Response<Vec<Group>> {
             }
         };
 
-        let mut req = Request::new(authd::Empty {});
-        req.set_timeout(REQUEST_TIMEOUT);
-        match client.list_groups(req).await {
-            Ok(r) => Response::Success(authd_groups_to_group_entries(r.into_inner().groups)),
-            Err(e) => {
-                info!("error when listing groups: {}", e.code());
-                super::grpc_status_to_nss_response(e)
+        // Get the entries page by page, as a single reply could exceed the maximum size of the gRPC messages.
+        let mut entries = Vec::new();
+        let mut page_token = String::new();
+        loop {
+            let mut req = Request::new(authd::ListGroupsRequest {
+                page_size: LIST_PAGE_SIZE,
+                page_token,
+            });
+            req.set_timeout(REQUEST_TIMEOUT);
+            match client.list_groups(req).await {
+                Ok(r) => {
+                    let r = r.into_inner();
+                    entries.extend(r.groups);
+                    if r.next_page_token.is_empty() {
+                        return Response::Success(authd_groups_to_group_entries(entries));
+                    }
+                    page_token = r.next_page_token;
+                }
+                Err(e) => {
+                    info!("error when listing groups: {}", e.code());
+                    return super::grpc_status_to_nss_response(e);
+                }
             }
         }
     })
@@ -0,0 +1,121 @@
+use crate::{info, REQUEST_TIMEOUT};
+use libc::{c_char, c_int, c_long, gid_t};
+use std::ffi::CStr;
+use tokio::runtime::Builder;
+use tonic::{Code, Request};
+
+use crate::client::{self, authd};
+
+// The values of enum nss_status, as libnss doesn't provide hooks for the initgroups function.
+const NSS_STATUS_TRYAGAIN: c_int = -2;
+const NSS_STATUS_UNAVAIL: c_int = -1;
+const NSS_STATUS_NOTFOUND: c_int = 0;
+const NSS_STATUS_SUCCESS: c_int = 1;
+
+/// _nss_authd_initgroups_dyn adds the GIDs of the groups of the user to the array of the supplementary groups being
+/// built by initgroups(3) and getgrouplist(3), so that they don't have to go through all the groups.
+///
+/// `start` is the number of GIDs already in the array, `size` its allocated size and `limit` the maximum number of
+/// GIDs it can hold, or a non-positive value if there is none. The array is grown as needed. The GID of the primary
+/// group, `group`, is already in the array and must not be added.
+///
+/// # Safety
+///
+/// This function is called by glibc, which guarantees that the pointers are valid and that the array was allocated
+/// with malloc.
+#[no_mangle]
+pub unsafe extern "C" fn _nss_authd_initgroups_dyn(
+    user: *const c_char,
+    group: gid_t,
+    start: *mut c_long,
+    size: *mut c_long,
+    groupsp: *mut *mut gid_t,
+    limit: c_long,
+    errnop: *mut c_int,
+) -> c_int {
+    if user.is_null() {
+        return NSS_STATUS_NOTFOUND;
+    }
+    let name = match CStr::from_ptr(user).to_str() {
+        Ok(n) => n.to_string(),
+        Err(_) => return NSS_STATUS_NOTFOUND,
+    };
+
+    let gids = match get_groups_for_user(name) {
+        Ok(gids) => gids,
+        Err(status) => return status,
+    };
+
+    for gid in gids {
+        // Skip the primary group and the groups which were already added by another NSS module.
+        let groups = std::slice::from_raw_parts(*groupsp, *start as usize);
+        if gid == group || groups.contains(&gid) {
+            continue;
+        }
+
+        if *start == *size {
+            if limit > 0 && *size >= limit {
+                // The array is full: the other groups are ignored, as initgroups does.
+                break;
+            }
+            let mut new_size = 2 * *size;
+            if limit > 0 && new_size > limit {
+                new_size = limit;
+            }
+            let new_groups = libc::realloc(
+                *groupsp as *mut libc::c_void,
+                new_size as usize * size_of::<gid_t>(),
+            );
+            if new_groups.is_null() {
+                *errnop = libc::ENOMEM;
+                return NSS_STATUS_TRYAGAIN;
+            }
+            *groupsp = new_groups as *mut gid_t;
+            *size = new_size;
+        }
+
+        *(*groupsp).add(*start as usize) = gid;
+        *start += 1;
+    }
+
+    NSS_STATUS_SUCCESS
+}
+
+/// get_groups_for_user connects to the grpc server and asks for the GIDs of the groups of the user with the given name.
+/// It returns the nss_status to return on error.
+fn get_groups_for_user(name: String) -> Result<Vec<gid_t>, c_int> {
+    let rt = match Builder::new_current_thread().enable_all().build() {
+        Ok(rt) => rt,
+        Err(e) => {
+            info!("could not create runtime for NSS: {}", e);
+            return Err(NSS_STATUS_UNAVAIL);
+        }
+    };
+
+    rt.block_on(async {
+        let mut client = match client::new_client().await {
+            Ok(c) => c,
+            Err(e) => {
+                info!("could not connect to gRPC server: {}", e);
+                return Err(NSS_STATUS_UNAVAIL);
+            }
+        };
+
+        let mut req = Request::new(authd::GetGroupsForUserRequest { name: name.clone() });
+        req.set_timeout(REQUEST_TIMEOUT);
+        match client.get_groups_for_user(req).await {
+            Ok(r) => Ok(r.into_inner().groups.into_iter().map(|g| g.gid).collect()),
+            Err(e) => {
+                info!(
+                    "error when getting groups of user '{}': {}",
+                    name,
+                    e.code().description()
+                );
+                match e.code() {
+                    Code::NotFound => Err(NSS_STATUS_NOTFOUND),
+                    _ => Err(NSS_STATUS_UNAVAIL),
+                }
+            }
+        }
+    })
+}
@@ -16,6 +16,9 @@ use shadow::AuthdShadowHooks;
 use tonic::{Code, Status};
 libnss_shadow_hooks!(authd, AuthdShadowHooks);
 
+// libnss doesn't provide hooks for initgroups, so the module exports _nss_authd_initgroups_dyn itself.
+mod initgroups;
+
 mod logs;
 
 mod client;
@@ -32,6 +35,10 @@ const REQUEST_TIMEOUT: Duration = Duration::from_secs(10);
 
 const DEFAULT_SOCKET_PATH: &str = "/run/authd.sock";
 
+/// LIST_PAGE_SIZE is the number of entries requested at once when listing all the users or groups, so that the
+/// replies stay small on machines with many users.
+const LIST_PAGE_SIZE: u32 = 1000;
+
 /// socket_path returns the socket path to connect to the gRPC server.
 ///
 /// It uses the AUTHD_NSS_SOCKET env value if set and the custom_socket feature is enabled,
@@ -1,4 +1,4 @@
-use crate::{info, REQUEST_TIMEOUT};
+use crate::{info, LIST_PAGE_SIZE, REQUEST_TIMEOUT};
 use libc::uid_t;
 use libnss::interop::Response;
 use libnss::passwd::{Passwd, PasswdHooks};
@@ -46,13 +46,28 @@ fn get_all_entries() -> Response<Vec<Passwd>> {
             }
         };
 
-        let mut req = Request::new(authd::Empty {});
-        req.set_timeout(REQUEST_TIMEOUT);
-        match client.list_users(req).await {
-            Ok(r) => Response::Success(users_to_passwd_entries(r.into_inner().users)),
-            Err(e) => {
-                info!("error when listing passwd: {}", e.code());
-                super::grpc_status_to_nss_response(e)
+        // Get the entries page by page, as a single reply could exceed the maximum size of the gRPC messages.
+        let mut entries = Vec::new();
+        let mut page_token = String::new();
+        loop {
+            let mut req = Request::new(authd::ListUsersRequest {
+                page_size: LIST_PAGE_SIZE,
+                page_token,
+            });
+            req.set_timeout(REQUEST_TIMEOUT);
+            match client.list_users(req).await {
+                Ok(r) => {
+                    let r = r.into_inner();
+                    entries.extend(r.users);
+                    if r.next_page_token.is_empty() {
+                        return Response::Success(users_to_passwd_entries(entries));
+                    }
+                    page_token = r.next_page_token;
+                }
+                Err(e) => {
+                    info!("error when listing passwd: {}", e.code());
+                    return super::grpc_status_to_nss_response(e);
+                }
             }
         }
     })
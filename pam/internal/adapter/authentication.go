@@ -17,6 +17,7 @@ import (
 	"github.com/canonical/authd/internal/proto/authd"
 	"github.com/canonical/authd/log"
 	pam_proto "github.com/canonical/authd/pam/internal/proto"
+	"github.com/canonical/authd/secrets"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/msteinert/pam/v2"
@@ -125,12 +126,13 @@ type authenticationModel struct {
 	clientType PamClientType
 	mode       authd.SessionMode
 
-	inProgress       bool
-	currentModel     authenticationComponent
-	currentSessionID string
-	currentBrokerID  string
-	currentSecret    string
-	currentLayout    string
+	inProgress        bool
+	currentModel      authenticationComponent
+	currentSessionID  string
+	currentBrokerID   string
+	currentSecret     string
+	currentLayout     string
+	currentAuthModeID string
 
 	authTracker *authTracker
 
@@ -304,6 +306,7 @@ func (m authenticationModel) Update(msg tea.Msg) (authModel authenticationModel,
 		// we've completed the previous one(s).
 		clientType := m.clientType
 		currentLayout := m.currentLayout
+		currentAuthModeID := m.currentAuthModeID
 		return m, func() tea.Msg {
 			// waitForSlot blocks until no other auth is in flight, then registers
 			// us as the active goroutine for this generation.  It returns false
@@ -313,6 +316,16 @@ func (m authenticationModel) Update(msg tea.Msg) (authModel authenticationModel,
 			}
 
 			secret, hasSecret := msg.item.(*authd.IARequest_AuthenticationData_Secret)
+			if hasSecret && currentAuthModeID == auth.TOTPMode {
+				// The TOTP codes are verified by authd, which can't decrypt the secrets encrypted for the broker.
+				msg.item = &authd.IARequest_AuthenticationData_TotpCode{TotpCode: secret.Secret}
+				return isAuthenticatedRequestedSend{msg, ctx}
+			}
+			if hasSecret && currentAuthModeID == auth.MachineTokenMode {
+				// The machine tokens are verified by authd as well.
+				msg.item = &authd.IARequest_AuthenticationData_MachineToken{MachineToken: secret.Secret}
+				return isAuthenticatedRequestedSend{msg, ctx}
+			}
 			if hasSecret && clientType == Gdm && currentLayout == layouts.NewPassword {
 				return newPasswordCheck{ctx: ctx, password: secret.Secret}
 			}
@@ -495,9 +508,10 @@ func (m *authenticationModel) Blur() {
 
 // Compose initialize the authentication model to be used.
 // It creates and attaches the sub layout models based on UILayout.
-func (m *authenticationModel) Compose(brokerID, sessionID string, encryptionKey *rsa.PublicKey, layout *authd.UILayout) tea.Cmd {
+func (m *authenticationModel) Compose(brokerID, sessionID, authModeID string, encryptionKey *rsa.PublicKey, layout *authd.UILayout) tea.Cmd {
 	m.currentBrokerID = brokerID
 	m.currentSessionID = sessionID
+	m.currentAuthModeID = authModeID
 	m.encryptionKey = encryptionKey
 	m.currentLayout = layout.Type
 
@@ -565,6 +579,7 @@ func (m *authenticationModel) Reset() tea.Cmd {
 	m.currentSessionID = ""
 	m.currentBrokerID = ""
 	m.currentLayout = ""
+	m.currentAuthModeID = ""
 	return m.cancelIsAuthenticated()
 }
 
@@ -574,7 +589,7 @@ func dataToMsg(data string) (string, error) {
 		return "", nil
 	}
 
-	v := make(map[string]string)
+	v := make(map[string]json.RawMessage)
 	if err := json.Unmarshal([]byte(data), &v); err != nil {
 		return "", fmt.Errorf("invalid json data from provider: %v", err)
 	}
@@ -582,13 +597,29 @@ func dataToMsg(data string) (string, error) {
 		return "", nil
 	}
 
-	r, ok := v["message"]
+	rawMsg, ok := v["message"]
 	if !ok {
-		return "", fmt.Errorf("no message entry in json data from provider: %v", v)
+		return "", fmt.Errorf("no message entry in json data from provider: %v", data)
+	}
+	var r string
+	if err := json.Unmarshal(rawMsg, &r); err != nil {
+		return "", fmt.Errorf("invalid message entry in json data from provider: %v", err)
 	}
 	return r, nil
 }
 
+// dataToPasswordReset returns whether the JSON message of the provider starts the flow which re-authenticates the user
+// with the provider and resets their local password, because their password expired in the provider.
+func dataToPasswordReset(data string) bool {
+	var v struct {
+		PasswordReset bool `json:"password_reset"`
+	}
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		return false
+	}
+	return v.PasswordReset
+}
+
 // grantedTolerantMsg parses data via dataToMsg, treating a malformed or
 // unexpected message as non-fatal when access is auth.Granted: the message is
 // then a purely cosmetic notice, so an already-granted login must never fail
@@ -609,7 +640,14 @@ func (authData *isAuthenticatedRequestedSend) encryptSecretIfPresent(publicKey *
 		return nil, nil
 	}
 
-	ciphertext, err := rsa.EncryptOAEP(sha512.New(), rand.Reader, publicKey, []byte(secret.Secret), nil)
+	// Keep the plaintext copy needed for the encryption out of swap and core dumps.
+	plaintext, err := secrets.FromBytes([]byte(secret.Secret))
+	if err != nil {
+		return nil, err
+	}
+	defer plaintext.Destroy()
+
+	ciphertext, err := rsa.EncryptOAEP(sha512.New(), rand.Reader, publicKey, plaintext.Bytes(), nil)
 	if err != nil {
 		return nil, err
 	}
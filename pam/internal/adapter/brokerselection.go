@@ -125,12 +125,14 @@ func (m brokerSelectionModel) Update(msg tea.Msg) (brokerSelectionModel, tea.Cmd
 	return m, cmd
 }
 
-// AutoSelectForUser requests if any  broker was used by this user to automatically selects it.
-func AutoSelectForUser(client authd.PAMClient, username string) tea.Cmd {
+// AutoSelectForUser requests if any broker was used by this user, or is the default one of the PAM service, to
+// automatically select it.
+func AutoSelectForUser(client authd.PAMClient, username, service string) tea.Cmd {
 	return func() tea.Msg {
 		r, err := client.GetBroker(context.TODO(),
 			&authd.GBRequest{
 				Username: username,
+				Service:  service,
 			})
 		// We keep a chance to manually select the broker, not a blocker issue.
 		if err != nil {
@@ -13,6 +13,9 @@ import (
 	"github.com/msteinert/pam/v2"
 )
 
+// localtimePath is the path of the time zone of the system.
+var localtimePath = "/etc/localtime"
+
 // sendEvent sends an event msg to the main event loop.
 func sendEvent(msg tea.Msg) tea.Cmd {
 	return func() tea.Msg {
@@ -21,7 +24,7 @@ func sendEvent(msg tea.Msg) tea.Cmd {
 }
 
 // startBrokerSession returns the sessionID after marking a broker as current.
-func startBrokerSession(client authd.PAMClient, brokerID, username string, mode authd.SessionMode) tea.Cmd {
+func startBrokerSession(client authd.PAMClient, brokerID, username, service string, mode authd.SessionMode) tea.Cmd {
 	return func() tea.Msg {
 		if brokerID == brokers.LocalBrokerName {
 			return pamError{status: pam.ErrIgnore}
@@ -43,6 +46,8 @@ func startBrokerSession(client authd.PAMClient, brokerID, username string, mode
 			Username: username,
 			Lang:     lang,
 			Mode:     mode,
+			Service:  service,
+			Timezone: userTimezone(),
 		}
 
 		sbResp, err := client.SelectBroker(context.TODO(), sbReq)
@@ -67,6 +72,28 @@ func startBrokerSession(client authd.PAMClient, brokerID, username string, mode
 	}
 }
 
+// userTimezone returns the IANA name of the time zone of the user: the one set in the TZ environment variable, or
+// the one of the system otherwise. It's empty if it can't be determined.
+func userTimezone() string {
+	tz := strings.TrimPrefix(os.Getenv("TZ"), ":")
+	if tz == "" {
+		// The time zone of the system is the zoneinfo file /etc/localtime links to.
+		target, err := os.Readlink(localtimePath)
+		if err != nil {
+			return ""
+		}
+		tz = target
+	}
+	// TZ can be the path of a zoneinfo file too.
+	if _, name, found := strings.Cut(tz, "zoneinfo/"); found {
+		return name
+	}
+	if strings.HasPrefix(tz, "/") {
+		return ""
+	}
+	return tz
+}
+
 // getLayout fetches the layout for a given authModeID.
 func getLayout(client authd.PAMClient, sessionID, authModeID string) tea.Cmd {
 	return func() tea.Msg {
@@ -0,0 +1,43 @@
+package adapter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserTimezone(t *testing.T) {
+	tests := map[string]struct {
+		tz        string
+		localtime string
+
+		want string
+	}{
+		"Timezone_from_TZ":                          {tz: "Europe/Paris", want: "Europe/Paris"},
+		"Timezone_from_TZ_with_a_leading_colon":     {tz: ":Europe/Paris", want: "Europe/Paris"},
+		"Timezone_from_TZ_set_to_a_zoneinfo_file":   {tz: ":/usr/share/zoneinfo/America/New_York", want: "America/New_York"},
+		"Timezone_from_TZ_overrides_the_system_one": {tz: "Asia/Tokyo", localtime: "/usr/share/zoneinfo/Europe/Paris", want: "Asia/Tokyo"},
+		"Timezone_of_the_system_without_TZ":         {localtime: "/usr/share/zoneinfo/Europe/Paris", want: "Europe/Paris"},
+		"Timezone_of_the_system_with_a_relative_link": {
+			localtime: "../usr/share/zoneinfo/Africa/Abidjan", want: "Africa/Abidjan",
+		},
+
+		"No_timezone_if_TZ_is_a_file_outside_of_zoneinfo":  {tz: "/etc/custom-tz"},
+		"No_timezone_if_the_system_has_none":               {},
+		"No_timezone_if_the_system_one_is_not_in_zoneinfo": {localtime: "/etc/custom-tz"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The tests change the environment and the path of the system time zone.
+			t.Setenv("TZ", tc.tz)
+			localtimePath = filepath.Join(t.TempDir(), "localtime")
+			if tc.localtime != "" {
+				require.NoError(t, os.Symlink(tc.localtime, localtimePath), "Setup: could not create the localtime link")
+			}
+
+			require.Equal(t, tc.want, userTimezone(), "userTimezone should return the expected time zone")
+		})
+	}
+}
@@ -289,12 +289,23 @@ func (m gdmModel) Update(msg tea.Msg) (gdmModel, tea.Cmd) {
 			)
 		}
 
-		return m, m.emitEvent(&gdm.EventData_AuthEvent{
+		authEvent := m.emitEvent(&gdm.EventData_AuthEvent{
 			AuthEvent: &gdm.Events_AuthEvent{Response: &authd.IAResponse{
 				Access: access,
 				Msg:    authMsg,
 			}},
 		})
+		if access != auth.Next || !dataToPasswordReset(msg.msg) {
+			return m, authEvent
+		}
+
+		// Let GDM present the next steps as a single password reset flow.
+		return m, tea.Sequence(
+			m.emitEvent(&gdm.EventData_PasswordResetRequired{
+				PasswordResetRequired: &gdm.Events_PasswordResetRequired{Message: authMsg},
+			}),
+			authEvent,
+		)
 
 	case gdmStopConversations:
 		m.stopConversations()
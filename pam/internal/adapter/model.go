@@ -3,6 +3,7 @@ package adapter
 
 import (
 	"context"
+	"crypto/fips140"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
@@ -11,7 +12,9 @@ import (
 	"time"
 
 	"github.com/canonical/authd/internal/consts"
+	"github.com/canonical/authd/internal/fips"
 	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/services/pam/conversation"
 	"github.com/canonical/authd/log"
 	"github.com/canonical/authd/pam/internal/proto"
 	tea "github.com/charmbracelet/bubbletea"
@@ -134,7 +137,7 @@ func NewUIModel(mTx pam.ModuleTransaction, clientType PamClientType, mode authd.
 		userServiceClient = authd.NewUserServiceClient(conn)
 	}
 
-	m := newUIModelForClients(mTx, clientType, mode, authd.NewPAMClient(conn), userServiceClient, pamReturnValue)
+	m := newUIModelForClients(mTx, clientType, mode, conversation.New(conn), userServiceClient, pamReturnValue)
 	m.conn = conn
 	return m
 }
@@ -288,13 +291,15 @@ func (m uiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		// Got user and brokers? Time to auto or manually select.
-		return m, AutoSelectForUser(m.client, m.username())
+		service, _ := m.pamMTx.GetItem(pam.Service)
+		return m, AutoSelectForUser(m.client, m.username(), service)
 
 	case BrokerSelected:
 		safeMessageDebug(msg)
 		if m.sessionStartingForBroker == "" {
 			m.sessionStartingForBroker = msg.BrokerID
-			return m, startBrokerSession(m.client, msg.BrokerID, m.username(), m.sessionMode)
+			service, _ := m.pamMTx.GetItem(pam.Service)
+			return m, startBrokerSession(m.client, msg.BrokerID, m.username(), service, m.sessionMode)
 		}
 		if m.sessionStartingForBroker != msg.BrokerID {
 			return m, tea.Sequence(endSession(m.client, m.currentSession), sendEvent(msg))
@@ -324,6 +329,14 @@ func (m uiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				msg:    fmt.Sprintf("expected encryption key sent by broker to be  RSA public key, got %T", pubKey),
 			})
 		}
+		if fips.KernelEnabled() || fips140.Enabled() {
+			if err := fips.CheckPublicKey(rsaPublicKey); err != nil {
+				return m, sendEvent(pamError{
+					status: pam.ErrSystem,
+					msg:    fmt.Sprintf("encryption key sent by broker can't be used in FIPS mode: %v", err),
+				})
+			}
+		}
 
 		m.currentSession = &sessionInfo{
 			brokerID:      msg.brokerID,
@@ -403,6 +416,7 @@ func (m uiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.authenticationModel.Compose(
 				m.currentSession.brokerID,
 				m.currentSession.sessionID,
+				m.authModeSelectionModel.currentAuthModeSelectedID,
 				m.currentSession.encryptionKey,
 				msg.layout,
 			),
@@ -16,6 +16,7 @@ import (
 	"github.com/canonical/authd/internal/proto/authd"
 	"github.com/canonical/authd/log"
 	"github.com/canonical/authd/pam/internal/proto"
+	"github.com/canonical/authd/pam/internal/securitykey"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/msteinert/pam/v2"
 	"github.com/muesli/termenv"
@@ -36,6 +37,9 @@ type nativeModel struct {
 	currentStage         proto.Stage
 	busy                 bool
 	userSelectionAllowed bool
+	// passwordReset is true when the broker started the flow which re-authenticates the user with the provider and
+	// resets their local password, so that its first step is selected without asking the user.
+	passwordReset bool
 }
 
 const (
@@ -94,6 +98,7 @@ func newNativeModel(mTx pam.ModuleTransaction, userServiceClient authd.UserServi
 func (m nativeModel) Init() tea.Cmd {
 	rendersQrCode := m.isQrcodeRenderingSupported()
 	supportsQrCode := m.serviceName != polkitServiceName
+	supportsWebAuthn := m.isSecurityKeySupported()
 
 	return func() tea.Msg {
 		required, optional := layouts.Required, layouts.Optional
@@ -134,6 +139,15 @@ func (m nativeModel) Init() tea.Cmd {
 			})
 		}
 
+		if supportsWebAuthn {
+			supportedLayouts.layouts = append(supportedLayouts.layouts, &authd.UILayout{
+				Type:    layouts.WebAuthn,
+				Content: &required,
+				Label:   &optional,
+				Button:  &optional,
+			})
+		}
+
 		return supportedLayouts
 	}
 }
@@ -279,9 +293,18 @@ func (m nativeModel) Update(msg tea.Msg) (nativeModel, tea.Cmd) {
 			})
 		}
 
-		if len(m.authModes) == 1 {
+		// In the password reset flow, the broker only offers the modes which re-authenticate the user with the
+		// provider, so there's no actual choice to make.
+		if len(m.authModes) == 1 || m.passwordReset {
+			m.passwordReset = false
 			return m, selectAuthMode(m.authModes[0].Id)
 		}
+		// Automation can't choose a mode, so it authenticates with the machine token of the user when it's offered.
+		if !m.interactive && slices.ContainsFunc(m.authModes, func(a *authd.GAMResponse_AuthenticationMode) bool {
+			return a.Id == auth.MachineTokenMode
+		}) {
+			return m, selectAuthMode(auth.MachineTokenMode)
+		}
 
 		return m.startAsyncOp(m.authModeSelection)
 
@@ -327,6 +350,10 @@ func (m nativeModel) Update(msg tea.Msg) (nativeModel, tea.Cmd) {
 			return m, maybeSendPamError(m.sendInfo(authMsg))
 		case auth.Next:
 			m.uiLayout = nil
+			if dataToPasswordReset(msg.msg) {
+				m.passwordReset = true
+				return m, maybeSendPamError(m.sendInfo(m.formatInfo("Password expired", authMsg)))
+			}
 			return m, maybeSendPamError(m.sendInfo(authMsg))
 		case auth.Retry:
 			return m, maybeSendPamError(m.sendError(authMsg))
@@ -594,6 +621,9 @@ func (m nativeModel) startChallenge() tea.Cmd {
 	case layouts.NewPassword:
 		return m.handleNewPassword()
 
+	case layouts.WebAuthn:
+		return m.handleWebAuthn()
+
 	default:
 		return sendEvent(pamError{
 			status: pam.ErrSystem,
@@ -793,6 +823,64 @@ func (m nativeModel) isQrcodeRenderingSupported() bool {
 	}
 }
 
+// isSecurityKeySupported returns true if the user can touch a security key attached to the machine, which is not the
+// case of the remote sessions.
+func (m nativeModel) isSecurityKeySupported() bool {
+	if m.serviceName == polkitServiceName || isSSHSession(m.pamMTx) {
+		return false
+	}
+	return securitykey.Available()
+}
+
+func (m nativeModel) handleWebAuthn() tea.Cmd {
+	authMode := m.selectedAuthModeLabel("Security key")
+
+	choices := []choicePair{
+		{id: "continue", label: "Use the security key"},
+	}
+	if buttonLabel := m.uiLayout.GetButton(); buttonLabel != "" {
+		choices = append(choices, choicePair{id: layouts.Button, label: buttonLabel})
+	}
+
+	id, err := m.promptForChoiceWithMessage(authMode, m.uiLayout.GetLabel(), choices, "Choose action")
+	if errors.Is(err, errGoBack) {
+		return sendEvent(nativeGoBack{})
+	}
+	if errors.Is(err, errEmptyResponse) {
+		return sendEvent(nativeChallengeRequested{})
+	}
+	if err != nil {
+		return maybeSendPamError(err)
+	}
+	if id == layouts.Button {
+		return sendEvent(reselectAuthMode{})
+	}
+
+	pin, err := m.promptForInput(pam.PromptEchoOff, inputPromptStyleInline, "Security key PIN (leave empty if none)")
+	if errors.Is(err, errGoBack) {
+		return sendEvent(nativeGoBack{})
+	}
+	if err != nil && !errors.Is(err, errEmptyResponse) {
+		return maybeSendPamError(err)
+	}
+
+	if cmd := maybeSendPamError(m.sendInfo("Touch your security key")); cmd != nil {
+		return cmd
+	}
+	assertion, err := securitykey.Sign(context.TODO(), m.uiLayout.GetContent(), pin)
+	if err != nil {
+		log.Errorf(context.TODO(), "Could not sign the WebAuthn request: %v", err)
+		if cmd := maybeSendPamError(m.sendError("Could not use the security key: %v", err)); cmd != nil {
+			return cmd
+		}
+		return sendEvent(nativeChallengeRequested{})
+	}
+
+	return sendEvent(isAuthenticatedRequested{
+		item: &authd.IARequest_AuthenticationData_WebauthnAssertion{WebauthnAssertion: assertion},
+	})
+}
+
 func (m nativeModel) handleNewPassword() tea.Cmd {
 	if buttonLabel := m.uiLayout.GetButton(); buttonLabel != "" {
 		choices := []choicePair{
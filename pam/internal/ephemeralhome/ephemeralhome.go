@@ -0,0 +1,54 @@
+// Package ephemeralhome mounts and destroys the temporary homes of the ephemeral users.
+package ephemeralhome
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/canonical/authd/log"
+	"github.com/canonical/authd/pam/internal/skel"
+	"golang.org/x/sys/unix"
+)
+
+// skelDir is the directory whose content is copied to the new homes.
+var skelDir = skel.Dir
+
+// Mount mounts a temporary file system owned by the user at its home and fills it with the content of /etc/skel.
+// Anything already present at the home is hidden until the temporary file system is unmounted.
+func Mount(path string, uid, gid uint32) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("home %q is not an absolute path", path)
+	}
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return fmt.Errorf("could not create home %q: %w", path, err)
+	}
+
+	opts := fmt.Sprintf("mode=0700,uid=%d,gid=%d", uid, gid)
+	if err := unix.Mount("tmpfs", path, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, opts); err != nil {
+		return fmt.Errorf("could not mount temporary home %q: %w", path, err)
+	}
+
+	if err := skel.Copy(skelDir, path, int(uid), int(gid)); err != nil {
+		// The home is usable without the skeleton files.
+		log.Warningf(context.Background(), "Could not copy %s to temporary home %q: %v", skelDir, path, err)
+	}
+	return nil
+}
+
+// Destroy unmounts the temporary file system of the home, which discards its content, and removes the mount point
+// if it's empty.
+func Destroy(path string) error {
+	// The processes of the user may still use the home, so we detach it instead of failing.
+	if err := unix.Unmount(path, unix.MNT_DETACH); err != nil && !errors.Is(err, unix.EINVAL) {
+		return fmt.Errorf("could not unmount temporary home %q: %w", path, err)
+	}
+
+	// The mount point may hide a persistent home, which must not be removed.
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Debugf(context.Background(), "Not removing the mount point of temporary home %q: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package ephemeralhome_test
+
+import (
+	"testing"
+
+	"github.com/canonical/authd/pam/internal/ephemeralhome"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMountRequiresAbsolutePath(t *testing.T) {
+	t.Parallel()
+
+	err := ephemeralhome.Mount("relative/home", 1000, 1000)
+	require.Error(t, err, "Mount should return an error, but did not")
+}
@@ -0,0 +1,7 @@
+package fscrypthome
+
+// PrepareWith exposes prepare for tests.
+var PrepareWith = prepare
+
+// ChangePasswordWith exposes changePassword for tests.
+var ChangePasswordWith = changePassword
@@ -0,0 +1,206 @@
+// Package fscrypthome creates and unlocks the homes of the users encrypted with fscrypt, protected by their local
+// password.
+package fscrypthome
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/canonical/authd/log"
+	"github.com/canonical/authd/pam/internal/mountpoint"
+	"github.com/canonical/authd/pam/internal/skel"
+)
+
+// fscryptTimeout is how long we wait for fscrypt, which derives the key of the home from the password.
+const fscryptTimeout = time.Minute
+
+// skelDir is the directory whose content is copied to the new homes.
+var skelDir = skel.Dir
+
+// Prepare creates the home encrypted with the password if it doesn't exist, or unlocks it with the password if it's
+// locked. A home which is not encrypted, for example because it was created before fscrypt was enabled, is left as
+// is.
+func Prepare(ctx context.Context, path, username string, uid, gid uint32, password string) error {
+	return prepare(ctx, "fscrypt", path, username, uid, gid, password)
+}
+
+// ChangePassword protects the home of the user with the new password instead of the old one, if it's encrypted by
+// authd.
+func ChangePassword(ctx context.Context, path, username, oldPassword, newPassword string) error {
+	return changePassword(ctx, "fscrypt", path, username, oldPassword, newPassword)
+}
+
+func prepare(ctx context.Context, fscrypt, path, username string, uid, gid uint32, password string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("home %q is not an absolute path", path)
+	}
+	ctx, cancel := context.WithTimeout(ctx, fscryptTimeout)
+	defer cancel()
+
+	st, err := status(ctx, fscrypt, path)
+	if errors.Is(err, os.ErrNotExist) {
+		return create(ctx, fscrypt, path, username, uid, gid, password)
+	}
+	if err != nil {
+		return err
+	}
+	if !st.encrypted {
+		log.Infof(ctx, "Home %q is not encrypted, leaving it as is", path)
+		return nil
+	}
+	if st.unlocked {
+		return nil
+	}
+	if password == "" {
+		return fmt.Errorf("home %q is locked and the local password of the user is not available to unlock it", path)
+	}
+
+	// #nosec:G204 - the home is the one of the user of the PAM transaction, checked by authd.
+	cmd := exec.CommandContext(ctx, fscrypt, "unlock", path, "--user="+username, "--quiet")
+	cmd.Stdin = strings.NewReader(password + "\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("could not unlock home %q: %w: %s", path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// create creates the home, encrypts it while it's still empty and fills it with the skeleton files. The home is
+// removed if it can't be encrypted, so that the files of the user are never stored unencrypted.
+func create(ctx context.Context, fscrypt, path, username string, uid, gid uint32, password string) (err error) {
+	if password == "" {
+		return fmt.Errorf("home %q can't be encrypted without the local password of the user", path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not create home %q: %w", path, err)
+	}
+	if err := os.Mkdir(path, 0700); err != nil {
+		return fmt.Errorf("could not create home %q: %w", path, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(path)
+		}
+	}()
+	if err := os.Chown(path, int(uid), int(gid)); err != nil {
+		return fmt.Errorf("could not create home %q: %w", path, err)
+	}
+
+	// #nosec:G204 - the home is the one of the user of the PAM transaction, checked by authd.
+	cmd := exec.CommandContext(ctx, fscrypt, "encrypt", path, "--source=custom_passphrase",
+		"--name="+protectorName(username), "--user="+username, "--quiet")
+	cmd.Stdin = strings.NewReader(password + "\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("could not encrypt home %q: %w: %s", path, err, strings.TrimSpace(string(out)))
+	}
+
+	if err := skel.Copy(skelDir, path, int(uid), int(gid)); err != nil {
+		// The home is usable without the skeleton files.
+		log.Warningf(ctx, "Could not copy %s to encrypted home %q: %v", skelDir, path, err)
+	}
+	log.Infof(ctx, "Created home %q encrypted with fscrypt", path)
+	return nil
+}
+
+func changePassword(ctx context.Context, fscrypt, path, username, oldPassword, newPassword string) error {
+	ctx, cancel := context.WithTimeout(ctx, fscryptTimeout)
+	defer cancel()
+
+	st, err := status(ctx, fscrypt, path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	protector, ok := st.protectors[protectorName(username)]
+	if !ok {
+		// The home is not encrypted by authd.
+		return nil
+	}
+	// fscrypt stores the protectors of the directories on the file system containing them.
+	mnt, err := mountpoint.Of(path)
+	if err != nil {
+		return err
+	}
+
+	// fscrypt reads the old and the new passphrases from its standard input, one per line.
+	// #nosec:G204 - the home is the one of the user of the PAM transaction, checked by authd.
+	cmd := exec.CommandContext(ctx, fscrypt, "metadata", "change-passphrase", "--protector="+mnt+":"+protector, "--quiet")
+	cmd.Stdin = strings.NewReader(oldPassword + "\n" + newPassword + "\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("could not change the password of home %q: %w: %s", path, err, strings.TrimSpace(string(out)))
+	}
+	log.Infof(ctx, "Changed the password protecting home %q", path)
+	return nil
+}
+
+// protectorName returns the name of the protector of the home of the user created by authd.
+func protectorName(username string) string {
+	return "authd:" + username
+}
+
+// homeStatus is the encryption status of a home reported by fscrypt.
+type homeStatus struct {
+	encrypted bool
+	unlocked  bool
+	// protectors are the IDs of the custom protectors of the home, by name.
+	protectors map[string]string
+}
+
+// status returns the encryption status of the home, or an error wrapping os.ErrNotExist if it doesn't exist.
+func status(ctx context.Context, fscrypt, path string) (homeStatus, error) {
+	if _, err := os.Stat(path); err != nil {
+		return homeStatus{}, fmt.Errorf("could not access home %q: %w", path, err)
+	}
+
+	// #nosec:G204 - the home is the one of the user of the PAM transaction, checked by authd.
+	cmd := exec.CommandContext(ctx, fscrypt, "status", path)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stderr.String(), "not encrypted") {
+			return homeStatus{}, nil
+		}
+		return homeStatus{}, fmt.Errorf("could not get the encryption status of home %q: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return parseStatus(stdout.Bytes()), nil
+}
+
+// parseStatus parses the output of "fscrypt status" on an encrypted directory, which looks like:
+//
+//	"/home/user" is encrypted with fscrypt.
+//
+//	Policy:   16f3a9a2c9e6e5f1
+//	Options:  padding:32 contents:AES_256_XTS filenames:AES_256_CTS policy_version:2
+//	Unlocked: Yes
+//
+//	Protected with 1 protector:
+//	PROTECTOR         LINKED  DESCRIPTION
+//	a1b2c3d4e5f60718  No      custom protector "authd:user"
+func parseStatus(out []byte) homeStatus {
+	st := homeStatus{encrypted: true, protectors: make(map[string]string)}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "Unlocked:"); ok {
+			st.unlocked = strings.TrimSpace(value) == "Yes"
+			continue
+		}
+		_, name, ok := strings.Cut(line, `custom protector "`)
+		if !ok {
+			continue
+		}
+		st.protectors[strings.TrimSuffix(name, `"`)] = strings.Fields(line)[0]
+	}
+	return st
+}
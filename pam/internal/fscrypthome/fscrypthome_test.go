@@ -0,0 +1,192 @@
+package fscrypthome_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/pam/internal/fscrypthome"
+	"github.com/stretchr/testify/require"
+)
+
+const lockedStatus = `"/home/user1" is encrypted with fscrypt.
+
+Policy:   16f3a9a2c9e6e5f1
+Options:  padding:32 contents:AES_256_XTS filenames:AES_256_CTS policy_version:2
+Unlocked: No
+
+Protected with 1 protector:
+PROTECTOR         LINKED  DESCRIPTION
+a1b2c3d4e5f60718  No      custom protector "authd:user1"
+`
+
+func TestPrepare(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		existing    bool
+		status      string
+		password    string
+		fscryptFail bool
+
+		wantCalls   string
+		wantErr     bool
+		wantNoHome  bool
+		wantCreated bool
+	}{
+		"Create_encrypted_home_on_first_login": {
+			password:    "s3cr3t",
+			wantCalls:   "encrypt HOME --source=custom_passphrase --name=authd:user1 --user=user1 --quiet\ns3cr3t\n",
+			wantCreated: true,
+		},
+		"Unlock_locked_home": {
+			existing: true, status: lockedStatus, password: "s3cr3t",
+			wantCalls: "status HOME\nunlock HOME --user=user1 --quiet\ns3cr3t\n",
+		},
+		"Do_nothing_when_home_is_unlocked": {
+			existing: true, status: "Unlocked: Yes\n", password: "s3cr3t",
+			wantCalls: "status HOME\n",
+		},
+		"Do_nothing_when_home_is_unlocked_without_password": {
+			existing: true, status: "Unlocked: Yes\n",
+			wantCalls: "status HOME\n",
+		},
+		"Leave_home_which_is_not_encrypted": {
+			existing: true, password: "s3cr3t",
+			wantCalls: "status HOME\n",
+		},
+
+		"Error_when_home_can_not_be_created_without_password": {wantErr: true, wantNoHome: true},
+		"Error_when_home_can_not_be_encrypted":                {password: "s3cr3t", fscryptFail: true, wantErr: true, wantNoHome: true},
+		"Error_when_locked_home_can_not_be_unlocked_without_password": {
+			existing: true, status: lockedStatus, wantErr: true,
+		},
+		"Error_when_home_can_not_be_unlocked": {
+			existing: true, status: lockedStatus, password: "wrong", fscryptFail: true, wantErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			home := filepath.Join(dir, "home", "user1")
+			if tc.existing {
+				require.NoError(t, os.MkdirAll(home, 0700), "Setup: could not create home")
+			}
+			fscrypt := newFscrypt(t, dir, tc.status, tc.fscryptFail)
+
+			err := fscrypthome.PrepareWith(context.Background(), fscrypt, home, "user1", uint32(os.Getuid()), uint32(os.Getgid()), tc.password)
+			if tc.wantErr {
+				require.Error(t, err, "Prepare should return an error, but did not")
+			} else {
+				require.NoError(t, err, "Prepare should not return an error, but did")
+			}
+
+			if tc.wantNoHome {
+				require.NoDirExists(t, home, "The home should not be left unencrypted")
+				return
+			}
+			require.DirExists(t, home, "The home should exist")
+			if tc.wantErr {
+				return
+			}
+			if tc.wantCreated {
+				info, err := os.Stat(home)
+				require.NoError(t, err, "Could not stat the home")
+				require.Equal(t, os.FileMode(0700), info.Mode().Perm(), "The home should only be accessible by the user")
+			}
+			require.Equal(t, tc.wantCalls, readCalls(t, dir, home), "Unexpected calls to fscrypt")
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		missing     bool
+		status      string
+		fscryptFail bool
+
+		wantChanged bool
+		wantErr     bool
+	}{
+		"Change_password_of_home_encrypted_by_authd": {status: lockedStatus, wantChanged: true},
+
+		"Do_nothing_for_home_which_is_not_encrypted":     {},
+		"Do_nothing_for_home_not_encrypted_by_authd":     {status: "Unlocked: Yes\n"},
+		"Do_nothing_for_home_which_does_not_exist":       {missing: true},
+		"Error_when_password_of_home_can_not_be_changed": {status: lockedStatus, fscryptFail: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			home := filepath.Join(dir, "home", "user1")
+			if !tc.missing {
+				require.NoError(t, os.MkdirAll(home, 0700), "Setup: could not create home")
+			}
+			fscrypt := newFscrypt(t, dir, tc.status, tc.fscryptFail)
+
+			err := fscrypthome.ChangePasswordWith(context.Background(), fscrypt, home, "user1", "old", "new")
+			if tc.wantErr {
+				require.Error(t, err, "ChangePassword should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "ChangePassword should not return an error, but did")
+
+			calls := readCalls(t, dir, home)
+			if !tc.wantChanged {
+				require.NotContains(t, calls, "change-passphrase", "The password of the home should not be changed")
+				return
+			}
+			require.Contains(t, calls, ":a1b2c3d4e5f60718 --quiet\nold\nnew\n", "The protector of the home should get the new password")
+		})
+	}
+}
+
+// newFscrypt writes a fake fscrypt recording its calls and their input in dir. It reports status for the encrypted
+// directories, or that the directories are not encrypted if status is empty, and fails to change them if fails is
+// true.
+func newFscrypt(t *testing.T, dir, status string, fails bool) string {
+	t.Helper()
+
+	statusPath := filepath.Join(dir, "status")
+	if status != "" {
+		require.NoError(t, os.WriteFile(statusPath, []byte(status), 0600), "Setup: could not write status")
+	}
+	exit := 0
+	if fails {
+		exit = 1
+	}
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$*" >> %[1]q
+if [ "$1" = status ]; then
+	if [ -e %[2]q ]; then cat %[2]q; exit 0; fi
+	echo "error: \"$2\" is not encrypted" >&2
+	exit 1
+fi
+cat >> %[1]q
+exit %[3]d
+`, filepath.Join(dir, "calls"), statusPath, exit)
+	path := filepath.Join(dir, "fscrypt")
+	require.NoError(t, os.WriteFile(path, []byte(script), 0700), "Setup: could not write fscrypt") //nolint:gosec // The script must be executable.
+	return path
+}
+
+// readCalls returns the calls to the fake fscrypt, with the home replaced by HOME.
+func readCalls(t *testing.T, dir, home string) string {
+	t.Helper()
+
+	calls, err := os.ReadFile(filepath.Join(dir, "calls"))
+	if os.IsNotExist(err) {
+		return ""
+	}
+	require.NoError(t, err, "Could not read the calls to fscrypt")
+	return strings.ReplaceAll(string(calls), home, "HOME")
+}
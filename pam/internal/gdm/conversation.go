@@ -192,6 +192,8 @@ func EmitEvent(pamMTx pam.ModuleTransaction, event Event) error {
 		evType = EventType_userSelected
 	case *EventData_StartAuthentication:
 		evType = EventType_startAuthentication
+	case *EventData_PasswordResetRequired:
+		evType = EventType_passwordResetRequired
 	default:
 		return fmt.Errorf("no known event type %#v", event)
 	}
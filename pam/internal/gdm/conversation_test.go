@@ -961,6 +961,10 @@ func TestDataEmitEvent(t *testing.T) {
 			event:         &EventData_StartAuthentication{},
 			wantEventType: EventType_startAuthentication,
 		},
+		"Emit_event_PasswordResetRequired": {
+			event:         &EventData_PasswordResetRequired{},
+			wantEventType: EventType_passwordResetRequired,
+		},
 
 		// Error cases
 		"Error_on_nil_event": {
@@ -184,6 +184,8 @@ const (
 	EventType_isAuthenticatedCancelled EventType = 11
 	// EventType_stageChanged is stage changed EventType.
 	EventType_stageChanged EventType = 12
+	// EventType_passwordResetRequired is a password reset required EventType.
+	EventType_passwordResetRequired EventType = 13
 )
 
 // Enum value maps for EventType.
@@ -202,6 +204,7 @@ var (
 		10: "isAuthenticatedRequested",
 		11: "isAuthenticatedCancelled",
 		12: "stageChanged",
+		13: "passwordResetRequired",
 	}
 	EventType_value = map[string]int32{
 		"unknownEvent":             0,
@@ -217,6 +220,7 @@ var (
 		"isAuthenticatedRequested": 10,
 		"isAuthenticatedCancelled": 11,
 		"stageChanged":             12,
+		"passwordResetRequired":    13,
 	}
 )
 
@@ -680,6 +684,7 @@ type EventData struct {
 	//	*EventData_StartAuthentication
 	//	*EventData_UserSelected
 	//	*EventData_IsAuthenticatedCancelled
+	//	*EventData_PasswordResetRequired
 	Data          isEventData_Data `protobuf_oneof:"data"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -837,6 +842,15 @@ func (x *EventData) GetIsAuthenticatedCancelled() *Events_IsAuthenticatedCancell
 	return nil
 }
 
+func (x *EventData) GetPasswordResetRequired() *Events_PasswordResetRequired {
+	if x != nil {
+		if x, ok := x.Data.(*EventData_PasswordResetRequired); ok {
+			return x.PasswordResetRequired
+		}
+	}
+	return nil
+}
+
 type isEventData_Data interface {
 	isEventData_Data()
 }
@@ -889,6 +903,10 @@ type EventData_IsAuthenticatedCancelled struct {
 	IsAuthenticatedCancelled *Events_IsAuthenticatedCancelled `protobuf:"bytes,21,opt,name=isAuthenticatedCancelled,proto3,oneof"`
 }
 
+type EventData_PasswordResetRequired struct {
+	PasswordResetRequired *Events_PasswordResetRequired `protobuf:"bytes,22,opt,name=passwordResetRequired,proto3,oneof"`
+}
+
 func (*EventData_BrokersReceived) isEventData_Data() {}
 
 func (*EventData_BrokerSelected) isEventData_Data() {}
@@ -913,6 +931,8 @@ func (*EventData_UserSelected) isEventData_Data() {}
 
 func (*EventData_IsAuthenticatedCancelled) isEventData_Data() {}
 
+func (*EventData_PasswordResetRequired) isEventData_Data() {}
+
 type Requests_UiLayoutCapabilities struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -1577,6 +1597,50 @@ func (x *Events_UiLayoutReceived) GetUiLayout() *authd.UILayout {
 	return nil
 }
 
+type Events_PasswordResetRequired struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Events_PasswordResetRequired) Reset() {
+	*x = Events_PasswordResetRequired{}
+	mi := &file_gdm_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Events_PasswordResetRequired) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Events_PasswordResetRequired) ProtoMessage() {}
+
+func (x *Events_PasswordResetRequired) ProtoReflect() protoreflect.Message {
+	mi := &file_gdm_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Events_PasswordResetRequired.ProtoReflect.Descriptor instead.
+func (*Events_PasswordResetRequired) Descriptor() ([]byte, []int) {
+	return file_gdm_proto_rawDescGZIP(), []int{6, 12}
+}
+
+func (x *Events_PasswordResetRequired) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
 var File_gdm_proto protoreflect.FileDescriptor
 
 const file_gdm_proto_rawDesc = "" +
@@ -1617,7 +1681,7 @@ const file_gdm_proto_rawDesc = "" +
 	"\x03ack\x18\n" +
 	" \x01(\v2\x12.gdm.Responses.AckH\x00R\x03ack\x12Y\n" +
 	"\x14uiLayoutCapabilities\x18\v \x01(\v2#.gdm.Responses.UiLayoutCapabilitiesH\x00R\x14uiLayoutCapabilitiesB\x06\n" +
-	"\x04data\"\xa9\x05\n" +
+	"\x04data\"\xdc\x05\n" +
 	"\x06Events\x1aS\n" +
 	"\x0fBrokersReceived\x12@\n" +
 	"\fbrokersInfos\x18\x01 \x03(\v2\x1c.authd.ABResponse.BrokerInfoR\fbrokersInfos\x1a,\n" +
@@ -1642,7 +1706,9 @@ const file_gdm_proto_rawDesc = "" +
 	"\x05stage\x18\x01 \x01(\x0e2\n" +
 	".pam.StageR\x05stage\x1a?\n" +
 	"\x10UiLayoutReceived\x12+\n" +
-	"\buiLayout\x18\x01 \x01(\v2\x0f.authd.UILayoutR\buiLayout\"\xcd\a\n" +
+	"\buiLayout\x18\x01 \x01(\v2\x0f.authd.UILayoutR\buiLayout\x1a1\n" +
+	"\x15PasswordResetRequired\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\"\xa8\b\n" +
 	"\tEventData\x12\"\n" +
 	"\x04type\x18\x01 \x01(\x0e2\x0e.gdm.EventTypeR\x04type\x12G\n" +
 	"\x0fbrokersReceived\x18\n" +
@@ -1657,7 +1723,8 @@ const file_gdm_proto_rawDesc = "" +
 	"\x10reselectAuthMode\x18\x12 \x01(\v2\x1c.gdm.Events.ReselectAuthModeH\x00R\x10reselectAuthMode\x12S\n" +
 	"\x13startAuthentication\x18\x13 \x01(\v2\x1f.gdm.Events.StartAuthenticationH\x00R\x13startAuthentication\x12>\n" +
 	"\fuserSelected\x18\x14 \x01(\v2\x18.gdm.Events.UserSelectedH\x00R\fuserSelected\x12b\n" +
-	"\x18isAuthenticatedCancelled\x18\x15 \x01(\v2$.gdm.Events.IsAuthenticatedCancelledH\x00R\x18isAuthenticatedCancelledB\x06\n" +
+	"\x18isAuthenticatedCancelled\x18\x15 \x01(\v2$.gdm.Events.IsAuthenticatedCancelledH\x00R\x18isAuthenticatedCancelled\x12Y\n" +
+	"\x15passwordResetRequired\x18\x16 \x01(\v2!.gdm.Events.PasswordResetRequiredH\x00R\x15passwordResetRequiredB\x06\n" +
 	"\x04data*v\n" +
 	"\bDataType\x12\x0f\n" +
 	"\vunknownType\x10\x00\x12\t\n" +
@@ -1673,7 +1740,7 @@ const file_gdm_proto_rawDesc = "" +
 	"\x11updateBrokersList\x10\x01\x12\x1d\n" +
 	"\x19composeAuthenticationView\x10\x02\x12\x18\n" +
 	"\x14uiLayoutCapabilities\x10\x03\x12\x0f\n" +
-	"\vchangeStage\x10\x04*\xa7\x02\n" +
+	"\vchangeStage\x10\x04*\xc2\x02\n" +
 	"\tEventType\x12\x10\n" +
 	"\funknownEvent\x10\x00\x12\x10\n" +
 	"\fuserSelected\x10\x01\x12\x13\n" +
@@ -1688,7 +1755,8 @@ const file_gdm_proto_rawDesc = "" +
 	"\x18isAuthenticatedRequested\x10\n" +
 	"\x12\x1c\n" +
 	"\x18isAuthenticatedCancelled\x10\v\x12\x10\n" +
-	"\fstageChanged\x10\fB$Z\"github.com/canonical/authd/pam/gdmb\x06proto3"
+	"\fstageChanged\x10\f\x12\x19\n" +
+	"\x15passwordResetRequired\x10\rB$Z\"github.com/canonical/authd/pam/gdmb\x06proto3"
 
 var (
 	file_gdm_proto_rawDescOnce sync.Once
@@ -1703,7 +1771,7 @@ func file_gdm_proto_rawDescGZIP() []byte {
 }
 
 var file_gdm_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
-var file_gdm_proto_msgTypes = make([]protoimpl.MessageInfo, 24)
+var file_gdm_proto_msgTypes = make([]protoimpl.MessageInfo, 25)
 var file_gdm_proto_goTypes = []any{
 	(DataType)(0),                                // 0: gdm.DataType
 	(RequestType)(0),                             // 1: gdm.RequestType
@@ -1732,12 +1800,13 @@ var file_gdm_proto_goTypes = []any{
 	(*Events_IsAuthenticatedCancelled)(nil),      // 24: gdm.Events.IsAuthenticatedCancelled
 	(*Events_StageChanged)(nil),                  // 25: gdm.Events.StageChanged
 	(*Events_UiLayoutReceived)(nil),              // 26: gdm.Events.UiLayoutReceived
-	(proto.Stage)(0),                             // 27: pam.Stage
-	(*authd.UILayout)(nil),                       // 28: authd.UILayout
-	(*authd.ABResponse_BrokerInfo)(nil),          // 29: authd.ABResponse.BrokerInfo
-	(*authd.GAMResponse_AuthenticationMode)(nil), // 30: authd.GAMResponse.AuthenticationMode
-	(*authd.IAResponse)(nil),                     // 31: authd.IAResponse
-	(*authd.IARequest_AuthenticationData)(nil),   // 32: authd.IARequest.AuthenticationData
+	(*Events_PasswordResetRequired)(nil),         // 27: gdm.Events.PasswordResetRequired
+	(proto.Stage)(0),                             // 28: pam.Stage
+	(*authd.UILayout)(nil),                       // 29: authd.UILayout
+	(*authd.ABResponse_BrokerInfo)(nil),          // 30: authd.ABResponse.BrokerInfo
+	(*authd.GAMResponse_AuthenticationMode)(nil), // 31: authd.GAMResponse.AuthenticationMode
+	(*authd.IAResponse)(nil),                     // 32: authd.IAResponse
+	(*authd.IARequest_AuthenticationData)(nil),   // 33: authd.IARequest.AuthenticationData
 }
 var file_gdm_proto_depIdxs = []int32{
 	0,  // 0: gdm.Data.type:type_name -> gdm.DataType
@@ -1765,19 +1834,20 @@ var file_gdm_proto_depIdxs = []int32{
 	18, // 22: gdm.EventData.startAuthentication:type_name -> gdm.Events.StartAuthentication
 	17, // 23: gdm.EventData.userSelected:type_name -> gdm.Events.UserSelected
 	24, // 24: gdm.EventData.isAuthenticatedCancelled:type_name -> gdm.Events.IsAuthenticatedCancelled
-	27, // 25: gdm.Requests.ChangeStage.stage:type_name -> pam.Stage
-	28, // 26: gdm.Responses.UiLayoutCapabilities.supportedUiLayouts:type_name -> authd.UILayout
-	29, // 27: gdm.Events.BrokersReceived.brokersInfos:type_name -> authd.ABResponse.BrokerInfo
-	30, // 28: gdm.Events.AuthModesReceived.authModes:type_name -> authd.GAMResponse.AuthenticationMode
-	31, // 29: gdm.Events.AuthEvent.response:type_name -> authd.IAResponse
-	32, // 30: gdm.Events.IsAuthenticatedRequested.authentication_data:type_name -> authd.IARequest.AuthenticationData
-	27, // 31: gdm.Events.StageChanged.stage:type_name -> pam.Stage
-	28, // 32: gdm.Events.UiLayoutReceived.uiLayout:type_name -> authd.UILayout
-	33, // [33:33] is the sub-list for method output_type
-	33, // [33:33] is the sub-list for method input_type
-	33, // [33:33] is the sub-list for extension type_name
-	33, // [33:33] is the sub-list for extension extendee
-	0,  // [0:33] is the sub-list for field type_name
+	27, // 25: gdm.EventData.passwordResetRequired:type_name -> gdm.Events.PasswordResetRequired
+	28, // 26: gdm.Requests.ChangeStage.stage:type_name -> pam.Stage
+	29, // 27: gdm.Responses.UiLayoutCapabilities.supportedUiLayouts:type_name -> authd.UILayout
+	30, // 28: gdm.Events.BrokersReceived.brokersInfos:type_name -> authd.ABResponse.BrokerInfo
+	31, // 29: gdm.Events.AuthModesReceived.authModes:type_name -> authd.GAMResponse.AuthenticationMode
+	32, // 30: gdm.Events.AuthEvent.response:type_name -> authd.IAResponse
+	33, // 31: gdm.Events.IsAuthenticatedRequested.authentication_data:type_name -> authd.IARequest.AuthenticationData
+	28, // 32: gdm.Events.StageChanged.stage:type_name -> pam.Stage
+	29, // 33: gdm.Events.UiLayoutReceived.uiLayout:type_name -> authd.UILayout
+	34, // [34:34] is the sub-list for method output_type
+	34, // [34:34] is the sub-list for method input_type
+	34, // [34:34] is the sub-list for extension type_name
+	34, // [34:34] is the sub-list for extension extendee
+	0,  // [0:34] is the sub-list for field type_name
 }
 
 func init() { file_gdm_proto_init() }
@@ -1807,6 +1877,7 @@ func file_gdm_proto_init() {
 		(*EventData_StartAuthentication)(nil),
 		(*EventData_UserSelected)(nil),
 		(*EventData_IsAuthenticatedCancelled)(nil),
+		(*EventData_PasswordResetRequired)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -1814,7 +1885,7 @@ func file_gdm_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_gdm_proto_rawDesc), len(file_gdm_proto_rawDesc)),
 			NumEnums:      3,
-			NumMessages:   24,
+			NumMessages:   25,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,7 @@
+package homedhome
+
+// ActivateWith exposes activate for tests.
+var ActivateWith = activate
+
+// DeactivateWith exposes deactivate for tests.
+var DeactivateWith = deactivate
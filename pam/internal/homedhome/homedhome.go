@@ -0,0 +1,79 @@
+// Package homedhome activates and deactivates the homes of the users created with systemd-homed.
+package homedhome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// homectlTimeout is how long we wait for homectl, which unlocks or locks the home of the user.
+const homectlTimeout = time.Minute
+
+// Activate activates the home of the user with the secret protecting it. A home which is already active, for example
+// because it was activated by a session started before authd, is left as is.
+func Activate(ctx context.Context, name, secret string) error {
+	return activate(ctx, "homectl", name, secret)
+}
+
+// Deactivate deactivates the home of the user.
+func Deactivate(ctx context.Context, name string) error {
+	return deactivate(ctx, "homectl", name)
+}
+
+func activate(ctx context.Context, homectl, name, secret string) error {
+	ctx, cancel := context.WithTimeout(ctx, homectlTimeout)
+	defer cancel()
+
+	// #nosec:G204 - the user name is the one of the user of the PAM transaction, checked by authd.
+	cmd := exec.CommandContext(ctx, homectl, "activate", name)
+	cmd.Env = append(cmd.Environ(), "PASSWORD="+secret)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+
+	if active, inspectErr := isActive(ctx, homectl, name); inspectErr == nil && active {
+		return nil
+	}
+	return fmt.Errorf("could not activate the home of user %q: %w: %s", name, err, strings.TrimSpace(string(out)))
+}
+
+func deactivate(ctx context.Context, homectl, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, homectlTimeout)
+	defer cancel()
+
+	// #nosec:G204 - the user name is the one of the user of the PAM transaction, checked by authd.
+	if out, err := exec.CommandContext(ctx, homectl, "deactivate", name).CombinedOutput(); err != nil {
+		return fmt.Errorf("could not deactivate the home of user %q: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// isActive returns true if the home of the user is active.
+func isActive(ctx context.Context, homectl, name string) (bool, error) {
+	// #nosec:G204 - the user name is the one of the user of the PAM transaction, checked by authd.
+	out, err := exec.CommandContext(ctx, homectl, "inspect", "--json=short", name).Output()
+	if err != nil {
+		return false, err
+	}
+
+	var record struct {
+		Status map[string]struct {
+			State string `json:"state"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(out, &record); err != nil {
+		return false, err
+	}
+	// The status of the record is keyed by the machine ID.
+	for _, s := range record.Status {
+		if strings.HasPrefix(s.State, "active") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
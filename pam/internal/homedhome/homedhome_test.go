@@ -0,0 +1,106 @@
+package homedhome_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/pam/internal/homedhome"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		activateFails bool
+		state         string
+
+		wantErr bool
+	}{
+		"Activate_home":                    {},
+		"Accept_home_which_is_active":      {activateFails: true, state: "active"},
+		"Accept_home_which_is_active_used": {activateFails: true, state: "active-saved"},
+
+		"Error_when_home_can_not_be_activated": {activateFails: true, state: "inactive", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			homectl := newHomectl(t, dir, tc.activateFails, tc.state)
+
+			err := homedhome.ActivateWith(context.Background(), homectl, "user1", "s3cr3t")
+			if tc.wantErr {
+				require.Error(t, err, "Activate should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "Activate should not return an error, but did")
+
+			if tc.activateFails {
+				return
+			}
+			got, err := os.ReadFile(filepath.Join(dir, "calls"))
+			require.NoError(t, err, "homectl should have been called")
+			require.Equal(t, "activate user1 s3cr3t\n", string(got), "homectl should activate the home with the secret")
+		})
+	}
+}
+
+func TestDeactivate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		fails bool
+
+		wantErr bool
+	}{
+		"Deactivate_home": {},
+
+		"Error_when_home_can_not_be_deactivated": {fails: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			homectl := newHomectl(t, dir, tc.fails, "")
+
+			err := homedhome.DeactivateWith(context.Background(), homectl, "user1")
+			if tc.wantErr {
+				require.Error(t, err, "Deactivate should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "Deactivate should not return an error, but did")
+
+			got, err := os.ReadFile(filepath.Join(dir, "calls"))
+			require.NoError(t, err, "homectl should have been called")
+			require.Equal(t, "deactivate user1 \n", string(got), "homectl should deactivate the home")
+		})
+	}
+}
+
+// newHomectl writes a fake homectl recording its calls in dir, failing to change the state of the home if fails is
+// true, and reporting state when the home is inspected.
+func newHomectl(t *testing.T, dir string, fails bool, state string) string {
+	t.Helper()
+
+	exit := 0
+	if fails {
+		exit = 1
+	}
+	script := fmt.Sprintf(`#!/bin/sh
+if [ "$1" = inspect ]; then
+	echo '{"userName":"user1","status":{"0123456789abcdef":{"state":"%s"}}}'
+	exit 0
+fi
+echo "$1 $2 $PASSWORD" >> %q
+exit %d
+`, state, filepath.Join(dir, "calls"), exit)
+	path := filepath.Join(dir, "homectl")
+	require.NoError(t, os.WriteFile(path, []byte(script), 0700), "Setup: could not write homectl") //nolint:gosec // The script must be executable.
+	return path
+}
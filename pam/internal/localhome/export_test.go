@@ -0,0 +1,4 @@
+package localhome
+
+// CreateWith exposes create for tests.
+var CreateWith = create
@@ -0,0 +1,152 @@
+// Package localhome creates the homes of the users with the settings of their broker.
+package localhome
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/authd/log"
+	"github.com/canonical/authd/pam/internal/mountpoint"
+	"github.com/canonical/authd/pam/internal/skel"
+)
+
+// Settings are how the home is created.
+type Settings struct {
+	// UID and GID are the owner and the primary group of the user, which own the files of the home.
+	UID, GID uint32
+	// HomeGID is the group owning the home itself.
+	HomeGID uint32
+	// Mode is the permissions of the home and of its subdirectories.
+	Mode fs.FileMode
+	// Skel is the directory whose content is copied to the home. If empty, /etc/skel is used.
+	Skel string
+	// Subdirs are the directories created in the home, relative to it.
+	Subdirs []string
+	// Quota is the limit of the disk space of the user, in bytes, or 0 for no limit.
+	Quota uint64
+	// ProjectQuota sets Quota as a project quota on the home, whose project ID is the UID of the user, instead of a
+	// user quota on the file system of the home.
+	ProjectQuota bool
+}
+
+// Create creates the home with the settings if it doesn't exist. A home which can't be fully created is removed, so
+// that pam_mkhomedir creates it instead.
+func Create(path string, s Settings) error {
+	return create(path, s, "chattr", "setquota")
+}
+
+func create(path string, s Settings, chattr, setquota string) (err error) {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("home %q is not an absolute path", path)
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not access home %q: %w", path, err)
+	}
+
+	// The parent directories, such as the one of the domain of the users, are shared.
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not create the parent of home %q: %w", path, err)
+	}
+	if err := os.Mkdir(path, 0700); err != nil {
+		return fmt.Errorf("could not create home %q: %w", path, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(path)
+		}
+	}()
+
+	if s.Quota != 0 && s.ProjectQuota {
+		// The files created in the home, starting with the skeleton files, inherit its project ID.
+		// #nosec:G204 - the home is the one of the user of the PAM transaction, checked by authd.
+		cmd := exec.Command(chattr, "+P", "-p", strconv.FormatUint(uint64(s.UID), 10), path)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("could not set the project ID of home %q: %w: %s", path, err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	skelDir := s.Skel
+	if skelDir == "" {
+		skelDir = skel.Dir
+	}
+	if err := skel.Copy(skelDir, path, int(s.UID), int(s.GID)); err != nil {
+		return fmt.Errorf("could not copy %s to home %q: %w", skelDir, path, err)
+	}
+
+	for _, dir := range s.Subdirs {
+		subdir := filepath.Join(path, dir)
+		if err := mkdirAllOwned(path, subdir, s); err != nil {
+			return fmt.Errorf("could not create %q in home %q: %w", dir, path, err)
+		}
+	}
+
+	if s.Quota != 0 {
+		if err := setQuota(setquota, path, s); err != nil {
+			return fmt.Errorf("could not set the quota of home %q: %w", path, err)
+		}
+	}
+
+	// The permissions are set once the home is filled, so that it's never accessible while it's incomplete.
+	if err := os.Chown(path, int(s.UID), int(s.HomeGID)); err != nil {
+		return fmt.Errorf("could not set the owner of home %q: %w", path, err)
+	}
+	// The mode is not affected by the umask.
+	if err := os.Chmod(path, s.Mode); err != nil {
+		return fmt.Errorf("could not set the permissions of home %q: %w", path, err)
+	}
+
+	log.Infof(context.Background(), "Created home %q", path)
+	return nil
+}
+
+// mkdirAllOwned creates dir and its missing parents below home, owned by the user.
+func mkdirAllOwned(home, dir string, s Settings) error {
+	if dir == home {
+		return nil
+	}
+	if _, err := os.Lstat(dir); err == nil {
+		// The directory may come from the skeleton directory.
+		return nil
+	}
+	if err := mkdirAllOwned(home, filepath.Dir(dir), s); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, s.Mode); err != nil {
+		return err
+	}
+	if err := os.Chmod(dir, s.Mode); err != nil {
+		return err
+	}
+	return os.Lchown(dir, int(s.UID), int(s.GID))
+}
+
+// setQuota limits the disk space of the user, or of the home with a project quota, on the file system of the home.
+func setQuota(setquota, path string, s Settings) error {
+	mnt, err := mountpoint.Of(path)
+	if err != nil {
+		return err
+	}
+	kind := "-u"
+	if s.ProjectQuota {
+		kind = "-P"
+	}
+	// The limits are in blocks of 1 KiB. Only the hard limit of the blocks is set, the soft limit and the limits of
+	// the inodes are left unset.
+	blocks := strconv.FormatUint((s.Quota+1023)/1024, 10)
+	// #nosec:G204 - the home is the one of the user of the PAM transaction, checked by authd.
+	cmd := exec.Command(setquota, kind, strconv.FormatUint(uint64(s.UID), 10), "0", blocks, "0", "0", mnt)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	log.Infof(context.Background(), "Set a quota of %s KiB for home %q", blocks, path)
+	return nil
+}
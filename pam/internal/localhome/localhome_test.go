@@ -0,0 +1,177 @@
+package localhome_test
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/pam/internal/localhome"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		existing     bool
+		parentIsFile bool
+		noSkel       bool
+		subdirs      []string
+		mode         fs.FileMode
+
+		wantErr bool
+	}{
+		"Create_home_with_skeleton_files": {mode: 0750},
+		"Create_home_with_subdirectories": {mode: 0700, subdirs: []string{"Documents", "work/projects", "config"}},
+		"Create_home_without_skeleton":    {mode: 0755, noSkel: true},
+		"Do_nothing_when_home_exists":     {mode: 0750, existing: true},
+
+		"Error_when_parent_of_home_can_not_be_created": {mode: 0750, parentIsFile: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			skel := filepath.Join(dir, "skel")
+			if !tc.noSkel {
+				require.NoError(t, os.MkdirAll(filepath.Join(skel, "config"), 0700), "Setup: could not create skel folder")
+				require.NoError(t, os.WriteFile(filepath.Join(skel, ".profile"), []byte("profile"), 0600), "Setup: could not write file")
+			}
+			// The parent of the home is created, like the directory of the domain of the users.
+			home := filepath.Join(dir, "home", "example.com", "user1")
+			if tc.existing {
+				require.NoError(t, os.MkdirAll(home, 0700), "Setup: could not create home")
+			}
+			if tc.parentIsFile {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "home"), nil, 0600), "Setup: could not write file")
+			}
+
+			uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+			err := localhome.Create(home, localhome.Settings{UID: uid, GID: gid, HomeGID: gid, Mode: tc.mode, Skel: skel, Subdirs: tc.subdirs})
+			if tc.wantErr {
+				require.Error(t, err, "Create should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "Create should not return an error, but did")
+
+			entries, err := os.ReadDir(home)
+			require.NoError(t, err, "The home should exist")
+			if tc.existing {
+				require.Empty(t, entries, "The existing home should not be changed")
+				return
+			}
+
+			info, err := os.Stat(home)
+			require.NoError(t, err, "Could not stat the home")
+			require.Equal(t, tc.mode, info.Mode().Perm(), "The home should have the mode of the settings")
+
+			if tc.noSkel {
+				require.Len(t, entries, len(tc.subdirs), "The home should only contain the subdirectories")
+			} else {
+				require.FileExists(t, filepath.Join(home, ".profile"), "The skeleton files should have been copied")
+				info, err := os.Stat(filepath.Join(home, "config"))
+				require.NoError(t, err, "The skeleton folders should have been copied")
+				require.Equal(t, fs.FileMode(0700), info.Mode().Perm(), "The skeleton folders should keep their mode")
+			}
+			for _, subdir := range tc.subdirs {
+				if subdir == "config" && !tc.noSkel {
+					continue
+				}
+				info, err := os.Stat(filepath.Join(home, subdir))
+				require.NoError(t, err, "The subdirectory %q should have been created", subdir)
+				require.Equal(t, tc.mode, info.Mode().Perm(), "The subdirectory %q should have the mode of the settings", subdir)
+			}
+		})
+	}
+}
+
+func TestCreateWithQuota(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		quota        uint64
+		projectQuota bool
+		failing      string
+
+		wantChattr   string
+		wantSetquota string
+		wantErr      bool
+	}{
+		"Set_user_quota":                   {quota: 10 << 30, wantSetquota: "-u UID 0 10485760 0 0"},
+		"Set_project_quota":                {quota: 10 << 30, projectQuota: true, wantChattr: "+P -p UID HOME", wantSetquota: "-P UID 0 10485760 0 0"},
+		"Round_quota_up_to_1K":             {quota: 1500, wantSetquota: "-u UID 0 2 0 0"},
+		"Do_not_set_quota_without_a_limit": {projectQuota: true},
+
+		"Error_when_quota_can_not_be_set":      {quota: 10 << 30, failing: "setquota", wantSetquota: "-u UID 0 10485760 0 0", wantErr: true},
+		"Error_when_project_id_can_not_be_set": {quota: 10 << 30, projectQuota: true, failing: "chattr", wantChattr: "+P -p UID HOME", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			chattr := newCommand(t, dir, "chattr", tc.failing == "chattr")
+			setquota := newCommand(t, dir, "setquota", tc.failing == "setquota")
+			home := filepath.Join(dir, "home", "user1")
+
+			uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+			s := localhome.Settings{UID: uid, GID: gid, HomeGID: gid, Mode: 0750, Skel: filepath.Join(dir, "skel"), Quota: tc.quota, ProjectQuota: tc.projectQuota}
+			err := localhome.CreateWith(home, s, chattr, setquota)
+
+			want := strings.NewReplacer("UID", fmt.Sprint(uid), "HOME", home)
+			require.Equal(t, want.Replace(tc.wantChattr), readCalls(t, dir, "chattr"), "chattr should be called to set the project ID of the home")
+			gotSetquota := strings.Fields(readCalls(t, dir, "setquota"))
+			if tc.wantSetquota == "" {
+				require.Empty(t, gotSetquota, "setquota should not be called")
+			} else {
+				require.Equal(t, strings.Fields(want.Replace(tc.wantSetquota)), gotSetquota[:len(gotSetquota)-1], "setquota should be called to set the quota")
+				require.DirExists(t, gotSetquota[len(gotSetquota)-1], "The quota should be set on the file system of the home")
+			}
+
+			if tc.wantErr {
+				require.Error(t, err, "Create should return an error, but did not")
+				require.NoDirExists(t, home, "The home should be removed when its quota can't be set")
+				return
+			}
+			require.NoError(t, err, "Create should not return an error, but did")
+			require.DirExists(t, home, "The home should have been created")
+		})
+	}
+}
+
+// newCommand writes a fake command recording its arguments in dir, which fails if failing is true.
+func newCommand(t *testing.T, dir, name string, failing bool) string {
+	t.Helper()
+
+	exit := 0
+	if failing {
+		exit = 1
+	}
+	script := fmt.Sprintf("#!/bin/sh\necho \"$*\" >> %q\nexit %d\n", filepath.Join(dir, name+".calls"), exit)
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(script), 0700), "Setup: could not write %s", name) //nolint:gosec // The script must be executable.
+	return path
+}
+
+// readCalls returns the arguments of the calls to the fake command, without the trailing newline.
+func readCalls(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	calls, err := os.ReadFile(filepath.Join(dir, name+".calls"))
+	if os.IsNotExist(err) {
+		return ""
+	}
+	require.NoError(t, err, "Could not read the calls to %s", name)
+	return strings.TrimSuffix(string(calls), "\n")
+}
+
+func TestCreateRequiresAbsolutePath(t *testing.T) {
+	t.Parallel()
+
+	err := localhome.Create("relative/home", localhome.Settings{Mode: 0750})
+	require.Error(t, err, "Create should return an error, but did not")
+}
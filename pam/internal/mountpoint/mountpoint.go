@@ -0,0 +1,35 @@
+// Package mountpoint finds the file systems of the homes, on which fscrypt and the quotas are set.
+package mountpoint
+
+import (
+	"path/filepath"
+	"syscall"
+)
+
+// Of returns the mount point of the file system containing path.
+func Of(path string) (string, error) {
+	dev := func(p string) (uint64, error) {
+		var st syscall.Stat_t
+		if err := syscall.Stat(p, &st); err != nil {
+			return 0, err
+		}
+		return uint64(st.Dev), nil
+	}
+
+	d, err := dev(path)
+	if err != nil {
+		return "", err
+	}
+	for path != "/" {
+		parent := filepath.Dir(path)
+		pd, err := dev(parent)
+		if err != nil {
+			return "", err
+		}
+		if pd != d {
+			break
+		}
+		path = parent
+	}
+	return path, nil
+}
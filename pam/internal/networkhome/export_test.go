@@ -0,0 +1,4 @@
+package networkhome
+
+// CheckOwner exposes checkOwner for tests.
+var CheckOwner = checkOwner
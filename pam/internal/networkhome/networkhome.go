@@ -0,0 +1,86 @@
+// Package networkhome mounts and checks the homes provided by a network file system.
+package networkhome
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+)
+
+// mountTimeout is how long we wait for the mount unit of the home to be started.
+const mountTimeout = 30 * time.Second
+
+// Prepare starts the mount unit of the home, if any, and checks that the home is a directory owned by uid.
+//
+// Accessing the home triggers its automount if there is one. The home is never created: a local empty home would hide
+// the network one, or leave the user with a home which is not the one of the other machines.
+func Prepare(ctx context.Context, path string, uid uint32, mountUnit string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("home %q is not an absolute path", path)
+	}
+
+	if mountUnit != "" {
+		if err := startUnit(ctx, mountUnit); err != nil {
+			return fmt.Errorf("could not mount home %q: %w", path, err)
+		}
+	}
+
+	return checkOwner(path, uid)
+}
+
+// checkOwner returns an error if the home is not a directory owned by uid.
+func checkOwner(path string, uid uint32) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("home %q is not mounted", path)
+	}
+	if err != nil {
+		return fmt.Errorf("could not access home %q: %w", path, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("home %q is not a directory", path)
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("could not get the owner of home %q", path)
+	}
+	// The UID on the file server must be the one of the user, else they can't access their files.
+	if stat.Uid != uid {
+		return fmt.Errorf("home %q is owned by UID %d instead of UID %d of the user", path, stat.Uid, uid)
+	}
+	return nil
+}
+
+// startUnit starts the systemd unit and waits for it to be started.
+func startUnit(ctx context.Context, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, mountTimeout)
+	defer cancel()
+
+	conn, err := dbus.NewSystemConnectionContext(ctx)
+	if err != nil {
+		return fmt.Errorf("could not connect to systemd: %w", err)
+	}
+	defer conn.Close()
+
+	done := make(chan string, 1)
+	if _, err := conn.StartUnitContext(ctx, name, "replace", done); err != nil {
+		return fmt.Errorf("could not start %s: %w", name, err)
+	}
+
+	select {
+	case result := <-done:
+		if result != "done" {
+			return fmt.Errorf("could not start %s: job %s", name, result)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("could not start %s: %w", name, ctx.Err())
+	}
+}
@@ -0,0 +1,56 @@
+package networkhome_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/pam/internal/networkhome"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckOwner(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		missing bool
+		file    bool
+		uid     uint32
+
+		wantErr bool
+	}{
+		"Home_owned_by_the_user": {uid: uint32(os.Getuid())},
+
+		"Error_when_home_is_not_mounted":      {missing: true, uid: uint32(os.Getuid()), wantErr: true},
+		"Error_when_home_is_not_a_directory":  {file: true, uid: uint32(os.Getuid()), wantErr: true},
+		"Error_when_home_is_owned_by_another": {uid: uint32(os.Getuid()) + 1, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			home := filepath.Join(t.TempDir(), "home")
+			switch {
+			case tc.file:
+				require.NoError(t, os.WriteFile(home, nil, 0600), "Setup: could not create file")
+			case !tc.missing:
+				require.NoError(t, os.Mkdir(home, 0700), "Setup: could not create home")
+			}
+
+			err := networkhome.CheckOwner(home, tc.uid)
+			if tc.wantErr {
+				require.Error(t, err, "CheckOwner should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "CheckOwner should not return an error, but did")
+		})
+	}
+}
+
+func TestPrepareRequiresAbsolutePath(t *testing.T) {
+	t.Parallel()
+
+	err := networkhome.Prepare(context.Background(), "relative/home", 1000, "")
+	require.Error(t, err, "Prepare should return an error, but did not")
+}
@@ -22,6 +22,8 @@ import (
 	"github.com/google/uuid"
 	"golang.org/x/exp/maps"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type options struct {
@@ -508,6 +510,21 @@ func (dc *DummyClient) EndSession(ctx context.Context, in *authd.ESRequest, opts
 	return &authd.Empty{}, nil
 }
 
+// NotifySession simulates NotifySession using the provided parameters.
+func (dc *DummyClient) NotifySession(ctx context.Context, in *authd.NSRequest, opts ...grpc.CallOption) (*authd.NSResponse, error) {
+	log.Debugf(ctx, "NotifySession Called: %#v", in)
+	if in == nil {
+		return nil, errors.New("no input values provided")
+	}
+	return &authd.NSResponse{}, nil
+}
+
+// Converse is not supported by the dummy client, so that the requests are sent as unary calls.
+func (dc *DummyClient) Converse(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[authd.ConversationRequest, authd.ConversationResponse], error) {
+	log.Debugf(ctx, "Converse Called")
+	return nil, status.Error(codes.Unimplemented, "method Converse not implemented")
+}
+
 // Utility functions for testing purposes.
 
 // SelectedUsername returns the selected Username on the client.
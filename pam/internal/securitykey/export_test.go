@@ -0,0 +1,4 @@
+package securitykey
+
+// SignWith exposes sign for tests.
+var SignWith = sign
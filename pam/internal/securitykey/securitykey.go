@@ -0,0 +1,254 @@
+// Package securitykey signs the WebAuthn requests of the brokers with a FIDO2 security key attached to the machine,
+// using the tools of libfido2.
+package securitykey
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultTimeout is how long we wait for the user to touch their security key if the request doesn't say.
+const defaultTimeout = time.Minute
+
+// ErrNoSecurityKey is returned when no security key is attached to the machine.
+var ErrNoSecurityKey = errors.New("no security key found")
+
+// request is the WebAuthn request sent by the broker, in the format of the PublicKeyCredentialRequestOptions of the
+// WebAuthn API, with the binary values encoded in base64url.
+type request struct {
+	Challenge        string `json:"challenge"`
+	RPID             string `json:"rpId"`
+	AllowCredentials []struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	} `json:"allowCredentials"`
+	UserVerification string `json:"userVerification"`
+	Timeout          int    `json:"timeout"`
+}
+
+// assertion is the assertion returned to the broker, in the format of the PublicKeyCredential of the WebAuthn API,
+// with the binary values encoded in base64url.
+type assertion struct {
+	ID       string            `json:"id"`
+	RawID    string            `json:"rawId"`
+	Type     string            `json:"type"`
+	Response assertionResponse `json:"response"`
+}
+
+type assertionResponse struct {
+	AuthenticatorData string `json:"authenticatorData"`
+	ClientDataJSON    string `json:"clientDataJSON"`
+	Signature         string `json:"signature"`
+}
+
+// Available returns true if the tools needed to use a security key are installed.
+func Available() bool {
+	for _, tool := range []string{"fido2-token", "fido2-assert"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Sign signs the WebAuthn request with the first security key attached to the machine, unlocked with the PIN if it's
+// not empty, and returns the assertion as JSON. The user has to touch the key.
+func Sign(ctx context.Context, webAuthnRequest, pin string) (string, error) {
+	return sign(ctx, "fido2-token", "fido2-assert", webAuthnRequest, pin)
+}
+
+func sign(ctx context.Context, fido2Token, fido2Assert, webAuthnRequest, pin string) (string, error) {
+	var req request
+	if err := json.Unmarshal([]byte(webAuthnRequest), &req); err != nil {
+		return "", fmt.Errorf("could not parse the WebAuthn request: %v", err)
+	}
+	if req.Challenge == "" || req.RPID == "" {
+		return "", errors.New("the WebAuthn request has no challenge or relying party")
+	}
+	if len(req.AllowCredentials) == 0 {
+		return "", errors.New("the WebAuthn request allows no credentials")
+	}
+	challenge, err := decodeBase64(req.Challenge)
+	if err != nil {
+		return "", fmt.Errorf("invalid challenge in the WebAuthn request: %v", err)
+	}
+
+	timeout := defaultTimeout
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	device, err := firstDevice(ctx, fido2Token)
+	if err != nil {
+		return "", err
+	}
+
+	clientData, err := json.Marshal(map[string]any{
+		"type":        "webauthn.get",
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      "https://" + req.RPID,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not encode the client data: %v", err)
+	}
+	clientDataHash := sha256.Sum256(clientData)
+
+	// The key only signs with one of its own credentials, so we try each of the allowed ones until one is found.
+	var signErr error
+	for _, cred := range req.AllowCredentials {
+		credID, err := decodeBase64(cred.ID)
+		if err != nil {
+			signErr = fmt.Errorf("invalid credential ID in the WebAuthn request: %v", err)
+			continue
+		}
+		authData, signature, err := getAssertion(ctx, fido2Assert, device, clientDataHash[:], req.RPID, credID, pin)
+		if err != nil {
+			signErr = err
+			continue
+		}
+
+		a := assertion{
+			ID:    base64.RawURLEncoding.EncodeToString(credID),
+			RawID: base64.RawURLEncoding.EncodeToString(credID),
+			Type:  "public-key",
+			Response: assertionResponse{
+				AuthenticatorData: base64.RawURLEncoding.EncodeToString(authData),
+				ClientDataJSON:    base64.RawURLEncoding.EncodeToString(clientData),
+				Signature:         base64.RawURLEncoding.EncodeToString(signature),
+			},
+		}
+		out, err := json.Marshal(a)
+		if err != nil {
+			return "", fmt.Errorf("could not encode the assertion: %v", err)
+		}
+		return string(out), nil
+	}
+	return "", fmt.Errorf("the security key could not sign the request: %w", signErr)
+}
+
+// firstDevice returns the path of the first security key listed by fido2-token.
+func firstDevice(ctx context.Context, fido2Token string) (string, error) {
+	out, err := exec.CommandContext(ctx, fido2Token, "-L").Output()
+	if err != nil {
+		return "", fmt.Errorf("could not list the security keys: %v", err)
+	}
+	// Each line is like "/dev/hidraw0: vendor=0x1050, product=0x0407 (Yubico YubiKey OTP+FIDO+CCID)".
+	line, _, _ := strings.Cut(string(out), "\n")
+	device, _, found := strings.Cut(line, ": ")
+	if !found || device == "" {
+		return "", ErrNoSecurityKey
+	}
+	return device, nil
+}
+
+// getAssertion asks the security key to sign the hash of the client data with the credential, and returns the
+// authenticator data and the signature.
+func getAssertion(ctx context.Context, fido2Assert, device string, clientDataHash []byte, rpID string, credID []byte, pin string) (authData, signature []byte, err error) {
+	// The parameters are passed in a pipe, because fido2-assert reads the PIN on its standard input when it has no
+	// terminal.
+	params, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer params.Close()
+	_, err = fmt.Fprintf(w, "%s\n%s\n%s\n", base64.StdEncoding.EncodeToString(clientDataHash), rpID,
+		base64.StdEncoding.EncodeToString(credID))
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not pass the parameters to fido2-assert: %v", err)
+	}
+
+	args := []string{"-G", "-p", "-i", "/dev/fd/3"}
+	if pin != "" {
+		args = append(args, "-v")
+	}
+	args = append(args, device)
+
+	// #nosec:G204 - the device is the one listed by fido2-token.
+	cmd := exec.CommandContext(ctx, fido2Assert, args...)
+	cmd.ExtraFiles = []*os.File{params}
+	// Without a controlling terminal, fido2-assert reads the PIN on its standard input instead of prompting for it.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	cmd.Stdin = strings.NewReader(pin + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("fido2-assert failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseAssertion(out)
+}
+
+// parseAssertion parses the output of fido2-assert: the client data hash, the relying party, the authenticator data
+// and the signature, one per line and base64 encoded.
+func parseAssertion(out []byte) (authData, signature []byte, err error) {
+	var lines []string
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	if len(lines) < 4 {
+		return nil, nil, fmt.Errorf("unexpected output of fido2-assert: %d lines", len(lines))
+	}
+
+	cborAuthData, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid authenticator data: %v", err)
+	}
+	// fido2-assert outputs the authenticator data as it's returned by the key, as a CBOR byte string.
+	authData, err = cborByteString(cborAuthData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid authenticator data: %v", err)
+	}
+	signature, err = base64.StdEncoding.DecodeString(lines[3])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid signature: %v", err)
+	}
+	return authData, signature, nil
+}
+
+// cborByteString returns the content of a CBOR byte string.
+func cborByteString(b []byte) ([]byte, error) {
+	if len(b) == 0 || b[0]>>5 != 2 {
+		return nil, errors.New("not a CBOR byte string")
+	}
+
+	var n, header int
+	switch info := b[0] & 0x1f; {
+	case info < 24:
+		n, header = int(info), 1
+	case info == 24 && len(b) >= 2:
+		n, header = int(b[1]), 2
+	case info == 25 && len(b) >= 3:
+		n, header = int(b[1])<<8|int(b[2]), 3
+	default:
+		return nil, errors.New("unsupported CBOR byte string length")
+	}
+	if len(b) != header+n {
+		return nil, fmt.Errorf("CBOR byte string of %d bytes has %d bytes", n, len(b)-header)
+	}
+	return b[header:], nil
+}
+
+// decodeBase64 decodes base64 values, whether they are base64url encoded as in WebAuthn or not, padded or not.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.NewReplacer("+", "-", "/", "_").Replace(strings.TrimRight(s, "="))
+	return base64.RawURLEncoding.DecodeString(s)
+}
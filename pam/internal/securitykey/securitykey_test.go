@@ -0,0 +1,143 @@
+package securitykey_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/pam/internal/securitykey"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// testAuthData is 37 bytes of authenticator data, as a CBOR byte string encoded in base64 as output by fido2-assert.
+	testAuthData = "WCUAAQIDBAUGBwgJCgsMDQ4PEBESExQVFhcYGRobHB0eHyAhIiMk"
+	// testRawAuthData is the content of testAuthData, encoded in base64url.
+	testRawAuthData = "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gISIjJA"
+)
+
+func TestSign(t *testing.T) {
+	t.Parallel()
+
+	validRequest := `{"challenge":"Y2hhbGxlbmdl","rpId":"login.microsoft.com","allowCredentials":[{"type":"public-key","id":"b3RoZXI"},{"type":"public-key","id":"Y3JlZA"}],"timeout":60000}`
+
+	tests := map[string]struct {
+		request    string
+		pin        string
+		devices    string
+		assertOut  string
+		knownCreds string
+
+		wantArgs string
+		wantErr  bool
+	}{
+		"Sign_with_the_credential_of_the_key": {
+			wantArgs: "-G -p -i /dev/fd/3 /dev/hidraw3",
+		},
+		"Sign_with_the_PIN_of_the_key": {
+			pin:      "1234",
+			wantArgs: "-G -p -i /dev/fd/3 -v /dev/hidraw3",
+		},
+		"Sign_with_a_short_authenticator_data": {
+			assertOut: "Q2FiYw==",
+			wantArgs:  "-G -p -i /dev/fd/3 /dev/hidraw3",
+		},
+
+		"Error_when_the_request_is_not_JSON":           {request: "not-json", wantErr: true},
+		"Error_when_the_request_has_no_challenge":      {request: `{"rpId":"login.microsoft.com","allowCredentials":[{"type":"public-key","id":"Y3JlZA"}]}`, wantErr: true},
+		"Error_when_the_request_allows_no_credentials": {request: `{"challenge":"Y2hhbGxlbmdl","rpId":"login.microsoft.com"}`, wantErr: true},
+		"Error_when_no_key_is_attached":                {devices: "-", wantErr: true},
+		"Error_when_the_key_has_none_of_the_credentials": {
+			knownCreds: "none",
+			wantErr:    true,
+		},
+		"Error_when_the_authenticator_data_is_not_a_CBOR_byte_string": {
+			assertOut: "YWJj",
+			wantErr:   true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.request == "" {
+				tc.request = validRequest
+			}
+			if tc.devices == "" {
+				tc.devices = "/dev/hidraw3: vendor=0x1050, product=0x0407 (Yubico YubiKey OTP+FIDO+CCID)"
+			}
+			if tc.devices == "-" {
+				tc.devices = ""
+			}
+			if tc.assertOut == "" {
+				tc.assertOut = testAuthData
+			}
+			if tc.knownCreds == "" {
+				tc.knownCreds = base64.StdEncoding.EncodeToString([]byte("cred"))
+			}
+
+			dir := t.TempDir()
+			fido2Token := filepath.Join(dir, "fido2-token")
+			writeScript(t, fido2Token, fmt.Sprintf("echo %q", tc.devices))
+			fido2Assert := filepath.Join(dir, "fido2-assert")
+			writeScript(t, fido2Assert, fmt.Sprintf(`echo "$@" > %[1]s/args
+cat /dev/fd/3 > %[1]s/params
+read -r pin; echo "$pin" > %[1]s/pin
+{ read -r hash; read -r rp; read -r cred; } < %[1]s/params
+[ "$cred" = %[2]q ] || { echo "no credentials" >&2; exit 1; }
+printf '%%s\n%%s\n%%s\n%%s\n' "$hash" "$rp" %[3]q c2ln`, dir, tc.knownCreds, tc.assertOut))
+
+			got, err := securitykey.SignWith(context.Background(), fido2Token, fido2Assert, tc.request, tc.pin)
+			if tc.wantErr {
+				require.Error(t, err, "Sign should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "Sign should not return an error, but did")
+
+			args, err := os.ReadFile(filepath.Join(dir, "args"))
+			require.NoError(t, err, "fido2-assert should have been called")
+			require.Equal(t, tc.wantArgs, strings.TrimSpace(string(args)), "fido2-assert should be called with the device")
+			pin, err := os.ReadFile(filepath.Join(dir, "pin"))
+			require.NoError(t, err, "fido2-assert should have read the PIN")
+			require.Equal(t, tc.pin, strings.TrimSpace(string(pin)), "fido2-assert should read the PIN on its standard input")
+
+			var a struct {
+				ID       string `json:"id"`
+				RawID    string `json:"rawId"`
+				Type     string `json:"type"`
+				Response struct {
+					AuthenticatorData string `json:"authenticatorData"`
+					ClientDataJSON    string `json:"clientDataJSON"`
+					Signature         string `json:"signature"`
+				} `json:"response"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(got), &a), "Sign should return a JSON assertion")
+			require.Equal(t, "Y3JlZA", a.ID, "Assertion should be signed with the credential of the key")
+			require.Equal(t, a.ID, a.RawID, "Assertion raw ID should be the ID")
+			require.Equal(t, "public-key", a.Type, "Assertion should be a public key credential")
+			require.Equal(t, "c2ln", a.Response.Signature, "Assertion should have the signature of the key")
+			if tc.assertOut == testAuthData {
+				require.Equal(t, testRawAuthData, a.Response.AuthenticatorData, "Assertion should have the authenticator data without its CBOR header")
+			} else {
+				require.Equal(t, "YWJj", a.Response.AuthenticatorData, "Assertion should have the authenticator data without its CBOR header")
+			}
+
+			clientData, err := base64.RawURLEncoding.DecodeString(a.Response.ClientDataJSON)
+			require.NoError(t, err, "Assertion client data should be base64url encoded")
+			require.JSONEq(t, `{"type":"webauthn.get","challenge":"Y2hhbGxlbmdl","origin":"https://login.microsoft.com","crossOrigin":false}`,
+				string(clientData), "Assertion should have the client data of the request")
+		})
+	}
+}
+
+func writeScript(t *testing.T, path, content string) {
+	t.Helper()
+
+	err := os.WriteFile(path, []byte("#!/bin/sh\n"+content+"\n"), 0700)
+	require.NoError(t, err, "Setup: could not write script")
+}
@@ -0,0 +1,61 @@
+package skel_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/pam/internal/skel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopy(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		noSkel bool
+	}{
+		"Copy_skeleton_files":                {},
+		"Do_nothing_without_skeleton_folder": {noSkel: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			skelDir := filepath.Join(t.TempDir(), "skel")
+			if !tc.noSkel {
+				require.NoError(t, os.MkdirAll(filepath.Join(skelDir, ".config", "app"), 0750), "Setup: could not create skel folders")
+				require.NoError(t, os.WriteFile(filepath.Join(skelDir, ".profile"), []byte("profile"), 0644), "Setup: could not write file") //nolint:gosec // Like the real skel files.
+				require.NoError(t, os.WriteFile(filepath.Join(skelDir, ".config", "app", "conf"), []byte("conf"), 0600), "Setup: could not write file")
+				require.NoError(t, os.Symlink(".profile", filepath.Join(skelDir, ".bash_profile")), "Setup: could not create symlink")
+			}
+			home := t.TempDir()
+
+			err := skel.Copy(skelDir, home, os.Getuid(), os.Getgid())
+			require.NoError(t, err, "Copy should not return an error, but did")
+
+			if tc.noSkel {
+				entries, err := os.ReadDir(home)
+				require.NoError(t, err, "Could not read home")
+				require.Empty(t, entries, "Home should be empty")
+				return
+			}
+
+			got, err := os.ReadFile(filepath.Join(home, ".profile"))
+			require.NoError(t, err, "File should have been copied")
+			require.Equal(t, "profile", string(got), "File content should have been copied")
+
+			fi, err := os.Stat(filepath.Join(home, ".config", "app", "conf"))
+			require.NoError(t, err, "Nested file should have been copied")
+			require.Equal(t, os.FileMode(0600), fi.Mode().Perm(), "File permissions should have been kept")
+
+			fi, err = os.Stat(filepath.Join(home, ".config"))
+			require.NoError(t, err, "Folder should have been copied")
+			require.Equal(t, os.FileMode(0750), fi.Mode().Perm(), "Folder permissions should have been kept")
+
+			link, err := os.Readlink(filepath.Join(home, ".bash_profile"))
+			require.NoError(t, err, "Symlink should have been copied")
+			require.Equal(t, ".profile", link, "Symlink target should have been kept")
+		})
+	}
+}
@@ -7,7 +7,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
+	"os/user"
 	"slices"
 	"strconv"
 	"strings"
@@ -20,12 +23,19 @@ import (
 	"github.com/canonical/authd/internal/services/errmessages"
 	"github.com/canonical/authd/log"
 	"github.com/canonical/authd/pam/internal/adapter"
+	"github.com/canonical/authd/pam/internal/ephemeralhome"
+	"github.com/canonical/authd/pam/internal/fscrypthome"
 	"github.com/canonical/authd/pam/internal/gdm"
+	"github.com/canonical/authd/pam/internal/homedhome"
+	"github.com/canonical/authd/pam/internal/localhome"
+	"github.com/canonical/authd/pam/internal/networkhome"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/coreos/go-systemd/v22/journal"
 	"github.com/msteinert/pam/v2"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 // pamModule is the structure that implements the pam.ModuleHandler interface
@@ -68,6 +78,7 @@ var supportedArgs = []string{
 	"connection_timeout",  // The timeout on connecting to authd socket in milliseconds (defaults to 2 seconds).
 	"force_native_client", // Use native PAM client instead of custom UIs.
 	"force_reauth",        // Whether the authentication should be performed again even if it has been already completed.
+	"log_secrets",         // When this is set to "true", secrets are not redacted from the logs (for development only).
 }
 
 // parseArgs parses the PAM arguments and returns a map of them and a function that logs the parsing issues.
@@ -166,6 +177,15 @@ func initLogging(mTx pam.ModuleTransaction, args map[string]string, flags pam.Fl
 		}
 	}
 
+	if args["log_secrets"] == "true" {
+		baseResetFunc := resetFunc
+		log.SetLogSecrets(true)
+		resetFunc = func() {
+			log.SetLogSecrets(false)
+			baseResetFunc()
+		}
+	}
+
 	isSilent := flags&pam.Silent != 0
 	if isSilent {
 		// If PAM required us to be silent, let's use an empty log handler.
@@ -304,7 +324,8 @@ func (h *pamModule) handleAuthRequest(mode authd.SessionMode, mTx pam.ModuleTran
 			return err
 		}
 
-		response, err := c.GetBroker(context.TODO(), &authd.GBRequest{Username: username})
+		service, _ := mTx.GetItem(pam.Service)
+		response, err := c.GetBroker(context.TODO(), &authd.GBRequest{Username: username, Service: service})
 		if err != nil {
 			err = fmt.Errorf("could not get current available brokers: %w", err)
 			if msgErr := showPamMessage(mTx, pam.ErrorMsg, err.Error()); msgErr != nil {
@@ -390,6 +411,9 @@ func (h *pamModule) handleAuthRequest(mode authd.SessionMode, mTx pam.ModuleTran
 			}
 			reportOldAuthtok(returnValue.OldAuthTok)
 		}
+		if mode == authd.SessionMode_CHANGE_PASSWORD && returnValue.OldAuthTok != "" {
+			changeFscryptHomePassword(mTx, returnValue.OldAuthTok, returnValue.AuthTok)
+		}
 		return nil
 
 	case adapter.PamReturnError:
@@ -406,6 +430,23 @@ func (h *pamModule) handleAuthRequest(mode authd.SessionMode, mTx pam.ModuleTran
 	}
 }
 
+// changeFscryptHomePassword protects the home of the user with their new local password, if it's encrypted by authd
+// with fscrypt. The password is already changed, so a failure is only logged.
+func changeFscryptHomePassword(mTx pam.ModuleTransaction, oldPassword, newPassword string) {
+	username, err := mTx.GetItem(pam.User)
+	if err != nil || username == "" {
+		return
+	}
+	u, err := user.Lookup(username)
+	if err != nil {
+		log.Warningf(context.TODO(), "Impossible to get the home of user %q: %v", username, err)
+		return
+	}
+	if err := fscrypthome.ChangePassword(context.TODO(), u.HomeDir, username, oldPassword, newPassword); err != nil {
+		log.Errorf(context.TODO(), "Impossible to protect the encrypted home with the new password: %v", err)
+	}
+}
+
 // AcctMgmt is ignored because broker selection is now handled server-side during IsAuthenticated.
 func (h *pamModule) AcctMgmt(_ pam.ModuleTransaction, _ pam.Flags, _ []string) error {
 	return pam.ErrIgnore
@@ -464,11 +505,130 @@ func (h *pamModule) SetCred(pam.ModuleTransaction, pam.Flags, []string) error {
 }
 
 // OpenSession is the method that is invoked during pam_open_session request.
-func (h *pamModule) OpenSession(pam.ModuleTransaction, pam.Flags, []string) error {
-	return pam.ErrIgnore
+func (h *pamModule) OpenSession(mTx pam.ModuleTransaction, flags pam.Flags, args []string) error {
+	return h.notifySession(mTx, flags, args, authd.NSRequest_START)
 }
 
 // CloseSession is the method that is invoked during pam_close_session request.
-func (h *pamModule) CloseSession(pam.ModuleTransaction, pam.Flags, []string) error {
-	return pam.ErrIgnore
+func (h *pamModule) CloseSession(mTx pam.ModuleTransaction, flags pam.Flags, args []string) error {
+	return h.notifySession(mTx, flags, args, authd.NSRequest_STOP)
+}
+
+// notifySession reports the start or the stop of the session to authd, so that it can run the configured
+// session hooks. It returns pam.ErrIgnore on failures or if the user is not an authd user. It only prevents the
+// session from being opened if the temporary home of an ephemeral user can't be mounted, or if the network home of
+// the user is not usable.
+func (h *pamModule) notifySession(mTx pam.ModuleTransaction, flags pam.Flags, args []string, event authd.NSRequest_Event) error {
+	parsedArgs, logArgsIssues := parseArgs(args)
+	closeLogging, err := initLogging(mTx, parsedArgs, flags)
+	defer closeLogging()
+	if err != nil {
+		return pam.ErrIgnore
+	}
+	logArgsIssues()
+
+	username, err := mTx.GetItem(pam.User)
+	if err != nil || username == "" {
+		return pam.ErrIgnore
+	}
+
+	req := &authd.NSRequest{Event: event, Username: username}
+	if req.Service, err = mTx.GetItem(pam.Service); err != nil {
+		log.Warningf(context.TODO(), "Impossible to get PAM service name: %v", err)
+	}
+	if req.Tty, err = mTx.GetItem(pam.Tty); err != nil {
+		log.Debugf(context.TODO(), "Impossible to get PAM TTY: %v", err)
+	}
+	if req.Rhost, err = mTx.GetItem(pam.Rhost); err != nil {
+		log.Debugf(context.TODO(), "Impossible to get PAM remote host: %v", err)
+	}
+
+	client, closeConn, err := newClient(parsedArgs)
+	if err != nil {
+		log.Warningf(context.TODO(), "Impossible to notify session %s: %v", event, err)
+		return pam.ErrIgnore
+	}
+	defer closeConn()
+
+	resp, err := client.NotifySession(context.TODO(), req)
+	if err != nil {
+		if status.Code(err) != codes.NotFound {
+			log.Warningf(context.TODO(), "Impossible to notify session %s: %v", event, err)
+		}
+		return pam.ErrIgnore
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(resp.GetEnv())) {
+		if err := mTx.PutEnv(name + "=" + resp.GetEnv()[name]); err != nil {
+			log.Warningf(context.TODO(), "Impossible to set environment variable %q: %v", name, err)
+		}
+	}
+
+	if home := resp.GetEphemeralHome(); home != nil {
+		if event == authd.NSRequest_STOP {
+			if err := ephemeralhome.Destroy(home.GetPath()); err != nil {
+				log.Warningf(context.TODO(), "Impossible to destroy the temporary home: %v", err)
+			}
+			return nil
+		}
+		if err := ephemeralhome.Mount(home.GetPath(), home.GetUid(), home.GetGid()); err != nil {
+			// The user must not get a persistent home.
+			log.Errorf(context.TODO(), "Impossible to mount the temporary home: %v", err)
+			return pam.ErrSession
+		}
+	}
+	if home := resp.GetNetworkHome(); home != nil && event == authd.NSRequest_START {
+		if err := networkhome.Prepare(context.TODO(), home.GetPath(), home.GetUid(), home.GetMountUnit()); err != nil {
+			// The session must not go on with an empty local home created by pam_mkhomedir.
+			log.Errorf(context.TODO(), "Impossible to use the network home: %v", err)
+			return pam.ErrSession
+		}
+	}
+	if home := resp.GetFscryptHome(); home != nil && event == authd.NSRequest_START {
+		// The local password is the authentication token set when the user authenticated.
+		password, err := mTx.GetItem(pam.Authtok)
+		if err != nil {
+			log.Debugf(context.TODO(), "Impossible to get PAM authentication token: %v", err)
+		}
+		if err := fscrypthome.Prepare(context.TODO(), home.GetPath(), home.GetUserName(), home.GetUid(), home.GetGid(), password); err != nil {
+			// The session must not go on with a locked home or with a home created unencrypted by pam_mkhomedir.
+			log.Errorf(context.TODO(), "Impossible to use the encrypted home: %v", err)
+			return pam.ErrSession
+		}
+	}
+	if home := resp.GetLocalHome(); home != nil && event == authd.NSRequest_START {
+		err := localhome.Create(home.GetPath(), localhome.Settings{
+			UID:          home.GetUid(),
+			GID:          home.GetGid(),
+			HomeGID:      home.GetHomeGid(),
+			Mode:         fs.FileMode(home.GetMode()),
+			Skel:         home.GetSkel(),
+			Subdirs:      home.GetSubdirs(),
+			Quota:        home.GetQuota(),
+			ProjectQuota: home.GetProjectQuota(),
+		})
+		if err != nil && home.GetQuota() != 0 {
+			// The user must not get a home without quota from pam_mkhomedir.
+			log.Errorf(context.TODO(), "Impossible to create the home with the quota of the broker: %v", err)
+			return pam.ErrSession
+		}
+		if err != nil {
+			// pam_mkhomedir creates the home instead.
+			log.Warningf(context.TODO(), "Impossible to create the home with the settings of the broker: %v", err)
+		}
+	}
+	if home := resp.GetHomedHome(); home != nil {
+		if event == authd.NSRequest_STOP {
+			if err := homedhome.Deactivate(context.TODO(), home.GetUserName()); err != nil {
+				log.Warningf(context.TODO(), "Impossible to deactivate the systemd-homed home: %v", err)
+			}
+			return nil
+		}
+		if err := homedhome.Activate(context.TODO(), home.GetUserName(), home.GetSecret()); err != nil {
+			// The session must not go on with an empty local home created by pam_mkhomedir.
+			log.Errorf(context.TODO(), "Impossible to activate the systemd-homed home: %v", err)
+			return pam.ErrSession
+		}
+	}
+	return nil
 }
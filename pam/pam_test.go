@@ -1,8 +1,10 @@
 package main
 
 import (
+	"path/filepath"
 	"testing"
 
+	"github.com/canonical/authd/pam/internal/pam_test"
 	"github.com/msteinert/pam/v2"
 	"github.com/stretchr/testify/require"
 )
@@ -13,6 +15,24 @@ func TestUnimplementedActions(t *testing.T) {
 	// If these gets changed, go-exec module should be also adapted accordingly
 	// together with TestExecModuleUnimplementedActions
 	require.Error(t, module.SetCred(nil, pam.Flags(0), nil), pam.ErrIgnore)
-	require.Error(t, module.OpenSession(nil, pam.Flags(0), nil), pam.ErrIgnore)
-	require.Error(t, module.CloseSession(nil, pam.Flags(0), nil), pam.ErrIgnore)
+}
+
+func TestSessionIsIgnoredWithoutAuthd(t *testing.T) {
+	t.Parallel()
+
+	module := &pamModule{}
+	args := []string{
+		"socket=" + filepath.Join(t.TempDir(), "authd.sock"),
+		"connection_timeout=100",
+	}
+
+	mTx := pam_test.NewModuleTransactionDummy(nil)
+	require.ErrorIs(t, module.OpenSession(mTx, pam.Flags(0), args), pam.ErrIgnore,
+		"OpenSession should be ignored without a user")
+
+	require.NoError(t, mTx.SetItem(pam.User, "user1@example.com"), "Setup: could not set user")
+	require.ErrorIs(t, module.OpenSession(mTx, pam.Flags(0), args), pam.ErrIgnore,
+		"OpenSession should be ignored when authd is not reachable")
+	require.ErrorIs(t, module.CloseSession(mTx, pam.Flags(0), args), pam.ErrIgnore,
+		"CloseSession should be ignored when authd is not reachable")
 }
@@ -0,0 +1,115 @@
+// Package secrets provides memory buffers to hold secrets like passwords, challenges and tokens.
+//
+// The buffers are allocated outside of the Go heap, locked in memory so that they are never written to swap,
+// excluded from core dumps and zeroed when destroyed.
+package secrets
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/canonical/authd/log"
+	"golang.org/x/sys/unix"
+)
+
+// lockWarningOnce makes sure that we only warn once if secrets can't be locked in memory.
+var lockWarningOnce sync.Once
+
+// Buffer is a fixed size buffer holding a secret. It must be destroyed when the secret is not needed anymore.
+type Buffer struct {
+	mu   sync.Mutex
+	data []byte
+	size int
+}
+
+// New returns a zeroed buffer of size bytes.
+//
+// If the buffer can't be locked in memory, for example because RLIMIT_MEMLOCK is reached, the buffer is still
+// returned and a warning is logged once.
+func New(size int) (*Buffer, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid secret size %d", size)
+	}
+
+	// Secrets are allocated in their own pages, as memory is locked and excluded from core dumps by pages.
+	pageSize := os.Getpagesize()
+	mapSize := max(1, (size+pageSize-1)/pageSize) * pageSize
+	data, err := unix.Mmap(-1, 0, mapSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return nil, fmt.Errorf("could not allocate memory for secret: %w", err)
+	}
+
+	if err := unix.Madvise(data, unix.MADV_DONTDUMP); err != nil {
+		_ = unix.Munmap(data)
+		return nil, fmt.Errorf("could not exclude secret from core dumps: %w", err)
+	}
+	if err := unix.Mlock(data); err != nil {
+		lockWarningOnce.Do(func() {
+			log.Warningf(context.Background(), "Could not lock secrets in memory, they might be written to swap: %v", err)
+		})
+	}
+
+	b := &Buffer{data: data, size: size}
+	// Don't leave the secret in memory if the caller forgets to destroy the buffer.
+	runtime.SetFinalizer(b, (*Buffer).Destroy)
+	return b, nil
+}
+
+// FromBytes returns a buffer holding a copy of src, and zeroes src.
+func FromBytes(src []byte) (*Buffer, error) {
+	b, err := New(len(src))
+	if err != nil {
+		return nil, err
+	}
+	copy(b.data, src)
+	clear(src)
+	return b, nil
+}
+
+// Bytes returns the secret. The returned slice must not be used after the buffer is destroyed.
+func (b *Buffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.data == nil {
+		return nil
+	}
+	return b.data[:b.size]
+}
+
+// Len returns the size of the secret.
+func (b *Buffer) Len() int {
+	return b.size
+}
+
+// Equal returns true if the secret is equal to other. The comparison is done in constant time.
+func (b *Buffer) Equal(other []byte) bool {
+	return subtle.ConstantTimeCompare(b.Bytes(), other) == 1
+}
+
+// Destroy zeroes the secret and frees its memory. It is safe to call Destroy multiple times.
+func (b *Buffer) Destroy() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.data == nil {
+		return
+	}
+
+	clear(b.data)
+	// The memory is unlocked when it's unmapped.
+	if err := unix.Munmap(b.data); err != nil {
+		log.Warningf(context.Background(), "Could not free memory of secret: %v", err)
+	}
+	b.data = nil
+	runtime.SetFinalizer(b, nil)
+}
+
+// Format implements fmt.Formatter, so that the secret is never printed.
+func (b *Buffer) Format(f fmt.State, _ rune) {
+	fmt.Fprint(f, "***")
+}
@@ -0,0 +1,119 @@
+package secrets_test
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/canonical/authd/secrets"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		size int
+
+		wantErr bool
+	}{
+		"Allocate_empty_secret":              {size: 0},
+		"Allocate_small_secret":              {size: 16},
+		"Allocate_secret_larger_than_a_page": {size: os.Getpagesize() + 1},
+
+		"Error_on_negative_size": {size: -1, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := secrets.New(tc.size)
+			if tc.wantErr {
+				require.Error(t, err, "New should have failed")
+				return
+			}
+			require.NoError(t, err, "New should not have failed")
+			t.Cleanup(b.Destroy)
+
+			require.Equal(t, tc.size, b.Len(), "Unexpected secret size")
+			require.Equal(t, make([]byte, tc.size), b.Bytes(), "Secret should be zeroed")
+			if tc.size > 0 {
+				require.Contains(t, vmFlags(t, b.Bytes()), "dd", "Secret should be excluded from core dumps")
+			}
+		})
+	}
+}
+
+func TestFromBytes(t *testing.T) {
+	t.Parallel()
+
+	src := []byte("my password")
+	b, err := secrets.FromBytes(src)
+	require.NoError(t, err, "FromBytes should not have failed")
+	t.Cleanup(b.Destroy)
+
+	require.Equal(t, []byte("my password"), b.Bytes(), "Buffer should hold a copy of the secret")
+	require.Equal(t, make([]byte, len(src)), src, "Source should be zeroed")
+	require.True(t, b.Equal([]byte("my password")), "Equal should return true for the same secret")
+	require.False(t, b.Equal([]byte("my passwor")), "Equal should return false for a different secret")
+}
+
+func TestDestroy(t *testing.T) {
+	t.Parallel()
+
+	b, err := secrets.FromBytes([]byte("my password"))
+	require.NoError(t, err, "Setup: FromBytes should not have failed")
+
+	b.Destroy()
+	require.Nil(t, b.Bytes(), "Secret should not be accessible after being destroyed")
+	require.False(t, b.Equal([]byte("my password")), "Destroyed secret should not be equal to the secret")
+
+	// Destroying the buffer again should not panic.
+	b.Destroy()
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	b, err := secrets.FromBytes([]byte("my password"))
+	require.NoError(t, err, "Setup: FromBytes should not have failed")
+	t.Cleanup(b.Destroy)
+
+	for _, verb := range []string{"%s", "%v", "%+v", "%q", "%x"} {
+		require.Equal(t, "***", fmt.Sprintf(verb, b), "Secret should never be printed with %s", verb)
+	}
+}
+
+// vmFlags returns the VmFlags of the mapping containing data, as reported in /proc/self/smaps.
+func vmFlags(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	addr := uint64(uintptr(unsafe.Pointer(&data[0])))
+
+	f, err := os.Open("/proc/self/smaps")
+	require.NoError(t, err, "Setup: could not open smaps")
+	defer f.Close()
+
+	inMapping := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if addrRange, _, ok := strings.Cut(line, " "); ok && strings.Contains(addrRange, "-") {
+			start, end, _ := strings.Cut(addrRange, "-")
+			s, errStart := strconv.ParseUint(start, 16, 64)
+			e, errEnd := strconv.ParseUint(end, 16, 64)
+			inMapping = errStart == nil && errEnd == nil && s <= addr && addr < e
+			continue
+		}
+		if flags, ok := strings.CutPrefix(line, "VmFlags:"); ok && inMapping {
+			return strings.Fields(flags)
+		}
+	}
+	require.NoError(t, scanner.Err(), "Setup: could not read smaps")
+	require.Fail(t, "Mapping of the secret not found")
+	return nil
+}